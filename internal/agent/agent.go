@@ -5,7 +5,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/batalabs/muxd/internal/checkpoint"
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/domain"
 	"github.com/batalabs/muxd/internal/mcp"
@@ -22,42 +21,65 @@ import (
 type EventKind int
 
 const (
-	EventDelta      EventKind = iota // streaming text chunk
-	EventStreamDone                  // one API call finished
-	EventToolStart                   // about to execute a tool
-	EventToolDone                    // tool execution completed
-	EventTurnDone                    // full turn complete (end_turn)
-	EventError                       // unrecoverable error
-	EventCompacted                   // context was compacted
-	EventAskUser                     // ask_user tool: pause for user input
-	EventTitled                      // session title + tags generated
-	EventRetrying                    // rate limit retry in progress
+	EventDelta            EventKind = iota // streaming text chunk
+	EventStreamDone                        // one API call finished
+	EventToolStart                         // about to execute a tool
+	EventToolDone                          // tool execution completed
+	EventTurnDone                          // full turn complete (end_turn)
+	EventError                             // unrecoverable error
+	EventCompacted                         // context was compacted
+	EventAskUser                           // ask_user tool: pause for user input
+	EventTitled                            // session title + tags generated
+	EventRetrying                          // rate limit retry in progress
+	EventDiagram                           // diagram code block rendered to a file
+	EventApprovalRequired                  // tool call waiting for user approval
+	EventBudgetWarning                     // spend crossed the warning share of a budget
+	EventSubAgent                          // a spawn_agent worker started, progressed, or finished
+	EventVerified                          // the verification pass checked the final answer
+	EventSteered                           // steering notes were added before the next model call
 )
 
 // Event carries data for a single agent event.
 type Event struct {
 	Kind                     EventKind
-	DeltaText                string                // EventDelta
-	Blocks                   []domain.ContentBlock // EventStreamDone
-	StopReason               string                // EventStreamDone / EventTurnDone
-	InputTokens              int                   // EventStreamDone
-	OutputTokens             int                   // EventStreamDone
-	CacheCreationInputTokens int                   // EventStreamDone
-	CacheReadInputTokens     int                   // EventStreamDone
-	ToolUseID                string                // EventToolStart / EventToolDone
-	ToolName                 string                // EventToolStart / EventToolDone
-	ToolInput                map[string]any        // EventToolStart: tool input parameters
-	ToolResult               string                // EventToolDone
-	ToolIsError              bool                  // EventToolDone
-	Err                      error                 // EventError
-	AskPrompt                string                // EventAskUser: question text
-	AskResponse              chan<- string         // EventAskUser: adapter sends answer here
-	NewTitle                 string                // EventTitled
-	NewTags                  string                // EventTitled
-	ModelUsed                string                // EventTitled / EventCompacted
-	RetryAttempt             int                   // EventRetrying
-	RetryAfter               time.Duration         // EventRetrying
-	RetryMessage             string                // EventRetrying
+	DeltaText                string                  // EventDelta
+	Blocks                   []domain.ContentBlock   // EventStreamDone
+	StopReason               string                  // EventStreamDone / EventTurnDone
+	InputTokens              int                     // EventStreamDone
+	OutputTokens             int                     // EventStreamDone
+	CacheCreationInputTokens int                     // EventStreamDone
+	CacheReadInputTokens     int                     // EventStreamDone
+	ContextTokens            int                     // EventStreamDone: prompt size of the call, tools and memory included
+	ContextWindow            int                     // EventStreamDone: the model's context window, 0 when unknown
+	CompactAt                int                     // EventStreamDone: prompt size at which history is summarized
+	ToolUseID                string                  // EventToolStart / EventToolDone
+	ToolName                 string                  // EventToolStart / EventToolDone
+	ToolInput                map[string]any          // EventToolStart: tool input parameters
+	ToolResult               string                  // EventToolDone
+	ToolIsError              bool                    // EventToolDone
+	Err                      error                   // EventError
+	ErrCode                  domain.ErrorCode        // EventError: cause of Err, for clients
+	AskPrompt                string                  // EventAskUser: question text
+	AskOptions               []string                // EventAskUser: suggested answers, if any
+	AskResponse              chan<- string           // EventAskUser: adapter sends answer here
+	ApprovalResponse         chan<- ApprovalDecision // EventApprovalRequired: adapter sends the decision here
+	NewTitle                 string                  // EventTitled
+	NewTags                  string                  // EventTitled
+	ModelUsed                string                  // EventTitled / EventCompacted
+	RetryAttempt             int                     // EventRetrying
+	RetryAfter               time.Duration           // EventRetrying
+	RetryMessage             string                  // EventRetrying
+	DiagramKind              string                  // EventDiagram: "mermaid" or "graphviz"
+	DiagramPath              string                  // EventDiagram: rendered file, relative to Cwd when possible
+	Budget                   *BudgetStatus           // EventBudgetWarning
+	SubAgent                 *SubAgentStatus         // EventSubAgent
+	Verification             *Verification           // EventVerified
+	Steering                 []string                // EventSteered: the notes, in order
+}
+
+// errorEvent returns an EventError for err, classified by its code.
+func errorEvent(err error) Event {
+	return Event{Kind: EventError, Err: err, ErrCode: domain.ErrorCodeOf(err)}
 }
 
 // EventFunc is the callback signature for agent event delivery.
@@ -99,6 +121,51 @@ type ScheduledToolJobStore interface {
 	UpdateScheduledToolJob(id string, toolInput map[string]any, scheduledFor *time.Time, recurrence *string) error
 }
 
+// DraftToolJobStore is an optional extension used to queue scheduled calls
+// that need the user's approval (scheduler.draft_tools).
+type DraftToolJobStore interface {
+	CreateDraftToolJob(toolName string, toolInput map[string]any, scheduledFor time.Time, recurrence string) (string, error)
+}
+
+// TurnStateStore is an optional extension used to record which process is
+// running a session's turn, so a turn cut off by a crash can be repaired on
+// the next start.
+type TurnStateStore interface {
+	SetTurnPID(sessionID string, pid int) error
+}
+
+// TurnStore is an optional extension used to write each step of a turn in
+// one transaction, so a tool call is never stored without its results.
+type TurnStore interface {
+	AppendTurnMessages(sessionID, turnID string, msgs []store.TurnMessage) error
+}
+
+// CheckpointStore is an optional extension used to record the working
+// tree checkpoints undo and redo return to.
+type CheckpointStore interface {
+	AddCheckpoint(cp store.Checkpoint) (int64, error)
+}
+
+// AuditStore is an optional extension used to record outbound content
+// decisions.
+type AuditStore interface {
+	RecordAudit(e store.AuditEntry) error
+}
+
+// SessionParentStore is an optional extension used to link spawn_agent
+// sessions to the session that started them.
+type SessionParentStore interface {
+	SetSessionParent(sessionID, parentID string) error
+}
+
+// SpendStore is an optional extension used to track model spend and
+// enforce budget.session_usd and budget.daily_usd.
+type SpendStore interface {
+	RecordSpend(sessionID, model string, at time.Time, tokens store.TokenCounts, costUSD float64) error
+	SessionSpend(sessionID string) (float64, error)
+	DaySpend(at time.Time) (float64, error)
+}
+
 // ---------------------------------------------------------------------------
 // Service -- standalone agent loop, drivable by any adapter
 // ---------------------------------------------------------------------------
@@ -125,7 +192,12 @@ type Service struct {
 	agentLoopCount  int
 
 	running     bool
+	compacting  bool // Compact is summarizing; Submit is refused until it ends
 	canceled    bool
+	turnID      string        // tags the running turn's persisted messages
+	steering    []string      // notes from Steer, taken before the next model call
+	stopping    bool          // Stop was called; end the turn at the next safe point
+	stopCh      chan struct{} // closed by Stop
 	cancelFunc  context.CancelFunc
 	titled      bool
 	userRenamed bool // true when user manually renamed the session
@@ -136,17 +208,38 @@ type Service struct {
 	// Todos is the per-session in-memory todo list.
 	todos tools.TodoList
 
+	// shell is the persistent shell bash commands run in.
+	shell tools.ShellSession
+
 	// planMode is true when the agent is in plan mode (write tools disabled).
 	planMode bool
+	// planLocked is true when the user turned plan mode on; the model
+	// cannot leave it with plan_exit until the user turns it off.
+	planLocked bool
 
 	// isSubAgent is true when this Service is a sub-agent spawned by the task tool.
 	isSubAgent bool
+	// parent is the Service that spawned this sub-agent; its budgets apply.
+	parent *Service
+	// allowedTools, maxTurns and maxTokens bound a spawn_agent worker. A nil
+	// allowedTools or a zero limit means no restriction.
+	allowedTools map[string]bool
+	maxTurns     int
+	maxTokens    int
+
+	// budgetWarned records the budgets already warned about, by budgetKey.
+	budgetWarned map[string]bool
+
+	// approvedTools is the per-session allowlist of tools the user answered
+	// "always" for (see tools.approval_mode).
+	approvedTools map[string]bool
+
+	// sources numbers the web sources read in this session for citations.
+	sources citations
 
 	// Git state
 	gitAvailable bool
 	gitRepoRoot  string
-	checkpoints  []checkpoint.Checkpoint
-	redoStack    []checkpoint.Checkpoint
 
 	// braveAPIKey is the Brave Search API key from preferences.
 	braveAPIKey    string
@@ -158,6 +251,16 @@ type Service struct {
 	modelTags    string // for auto-tag generation
 	modelConsult string // for second-opinion consult calls
 
+	// Response style for this session (see /style).
+	styleLanguage string
+	styleTone     string
+	sampling      provider.Sampling
+
+	// verify runs a second model over each final answer before it is shown
+	// (see /verify); verifyModel overrides model.verify for the session.
+	verify      bool
+	verifyModel string
+
 	// disabledTools are excluded from model tool specs and execution.
 	disabledTools map[string]bool
 
@@ -184,6 +287,10 @@ type Service struct {
 	// hubDispatch dispatches a task to a remote hub node.
 	hubDispatch func(string, string) (string, error)
 
+	// daySpend, when set, replaces the store's DaySpend for
+	// budget.daily_usd.
+	daySpend func(at time.Time) (float64, error)
+
 	// logger writes background errors to the muxd log file.
 	logger *config.Logger
 }
@@ -426,6 +426,9 @@ func TestService_Submit_endTurn(t *testing.T) {
 			if evt.StopReason != "end_turn" {
 				t.Errorf("expected stop_reason=end_turn, got %s", evt.StopReason)
 			}
+			if evt.ContextTokens != 100 || evt.CompactAt != Tier3Threshold {
+				t.Errorf("expected context 100 compacting at %d, got %d at %d", Tier3Threshold, evt.ContextTokens, evt.CompactAt)
+			}
 		case EventTurnDone:
 			gotTurnDone = true
 		case EventError:
@@ -488,6 +491,18 @@ func TestService_SetModel(t *testing.T) {
 	}
 }
 
+func TestService_SetPlanMode(t *testing.T) {
+	svc := &Service{}
+	svc.SetPlanMode(true)
+	if !svc.PlanMode() || !svc.planLocked {
+		t.Fatal("expected plan mode on and locked")
+	}
+	svc.SetPlanMode(false)
+	if svc.PlanMode() || svc.planLocked {
+		t.Fatal("expected plan mode off and unlocked")
+	}
+}
+
 func TestService_Messages(t *testing.T) {
 	svc := &Service{
 		messages: []domain.TranscriptMessage{
@@ -698,7 +713,7 @@ func TestGenerateCompactionSummary(t *testing.T) {
 			{Role: "assistant", Content: "I'll edit it now."},
 		}
 
-		summary := svc.generateCompactionSummary(dropped)
+		summary := svc.generateCompactionSummary(dropped, func(Event) {})
 		if !strings.Contains(summary, "[Conversation summary]") {
 			t.Errorf("expected summary prefix, got %q", summary)
 		}
@@ -729,7 +744,7 @@ func TestGenerateCompactionSummary(t *testing.T) {
 			{Role: "user", Content: "hello"},
 		}
 
-		summary := svc.generateCompactionSummary(dropped)
+		summary := svc.generateCompactionSummary(dropped, func(Event) {})
 		if !strings.Contains(summary, "No summary available") {
 			t.Errorf("expected fallback message, got %q", summary)
 		}
@@ -737,7 +752,7 @@ func TestGenerateCompactionSummary(t *testing.T) {
 
 	t.Run("returns fallback for empty dropped", func(t *testing.T) {
 		svc := &Service{apiKey: "fake", modelID: "fake"}
-		summary := svc.generateCompactionSummary(nil)
+		summary := svc.generateCompactionSummary(nil, func(Event) {})
 		if !strings.Contains(summary, "No summary available") {
 			t.Errorf("expected fallback message, got %q", summary)
 		}
@@ -1135,3 +1150,150 @@ func TestRepairDanglingToolUseMessages(t *testing.T) {
 		}
 	})
 }
+
+func TestRepairInterruptedTurn(t *testing.T) {
+	prompt := domain.TranscriptMessage{Role: "user", Content: "tidy the imports"}
+	toolUse := domain.TranscriptMessage{Role: "assistant", Blocks: []domain.ContentBlock{
+		{Type: "text", Text: "Reading both."},
+		{Type: "tool_use", ToolUseID: "u1", ToolName: "file_read"},
+		{Type: "tool_use", ToolUseID: "u2", ToolName: "grep"},
+	}}
+	results := domain.TranscriptMessage{Role: "user", Blocks: []domain.ContentBlock{
+		{Type: "tool_result", ToolUseID: "u1", ToolResult: "ok"},
+		{Type: "tool_result", ToolUseID: "u2", ToolResult: "ok"},
+	}}
+
+	t.Run("dangling tool calls get results", func(t *testing.T) {
+		fix, got := RepairInterruptedTurn([]domain.TranscriptMessage{prompt, toolUse})
+		if got != "tidy the imports" {
+			t.Errorf("prompt = %q", got)
+		}
+		if len(fix) != 2 || fix[0].Role != "user" || fix[1].Role != "assistant" {
+			t.Fatalf("fix = %+v", fix)
+		}
+		ids, _ := collectToolResultIDs(fix[0].Blocks)
+		if !ids["u1"] || !ids["u2"] || len(fix[0].Blocks) != 2 {
+			t.Errorf("results = %+v", fix[0].Blocks)
+		}
+		if b := fix[0].Blocks[1]; b.ToolName != "grep" || !b.IsError {
+			t.Errorf("result = %+v", b)
+		}
+		if fix[1].Content != interruptedMarker {
+			t.Errorf("marker = %q", fix[1].Content)
+		}
+		if _, changed := repairDanglingToolUseMessages(append([]domain.TranscriptMessage{prompt, toolUse}, fix...)); changed {
+			t.Error("repaired transcript still has a dangling tool call")
+		}
+	})
+
+	t.Run("finished tools or an unanswered prompt get a marker", func(t *testing.T) {
+		for _, msgs := range [][]domain.TranscriptMessage{
+			{prompt, toolUse, results},
+			{prompt},
+		} {
+			fix, got := RepairInterruptedTurn(msgs)
+			if got != "tidy the imports" || len(fix) != 1 || fix[0].Content != interruptedMarker {
+				t.Errorf("fix = %+v, prompt = %q", fix, got)
+			}
+		}
+	})
+
+	t.Run("a finished turn needs nothing", func(t *testing.T) {
+		done := domain.TranscriptMessage{Role: "assistant", Content: "Done."}
+		for _, msgs := range [][]domain.TranscriptMessage{nil, {prompt, toolUse, results, done}} {
+			if fix, got := RepairInterruptedTurn(msgs); fix != nil || got != "" {
+				t.Errorf("fix = %+v, prompt = %q", fix, got)
+			}
+		}
+	})
+}
+
+func TestValidateTranscript(t *testing.T) {
+	user := func(s string) domain.TranscriptMessage { return domain.TranscriptMessage{Role: "user", Content: s} }
+	asst := func(s string) domain.TranscriptMessage {
+		return domain.TranscriptMessage{Role: "assistant", Content: s}
+	}
+	call := func(ids ...string) domain.TranscriptMessage {
+		m := domain.TranscriptMessage{Role: "assistant"}
+		for _, id := range ids {
+			m.Blocks = append(m.Blocks, domain.ContentBlock{Type: "tool_use", ToolUseID: id, ToolName: "bash"})
+		}
+		return m
+	}
+	result := func(ids ...string) domain.TranscriptMessage {
+		m := domain.TranscriptMessage{Role: "user"}
+		for _, id := range ids {
+			m.Blocks = append(m.Blocks, domain.ContentBlock{Type: "tool_result", ToolUseID: id, ToolResult: "ok"})
+		}
+		return m
+	}
+
+	t.Run("a valid transcript is unchanged", func(t *testing.T) {
+		msgs := []domain.TranscriptMessage{user("hi"), call("u1"), result("u1"), asst("done")}
+		got, fixes := ValidateTranscript(msgs)
+		if len(fixes) != 0 || len(got) != len(msgs) {
+			t.Errorf("fixes = %q, got %+v", fixes, got)
+		}
+	})
+
+	t.Run("empty messages are dropped and same-role runs merged", func(t *testing.T) {
+		msgs := []domain.TranscriptMessage{
+			user("one"),
+			{Role: "assistant", Blocks: []domain.ContentBlock{{Type: "text", Text: "  "}}},
+			user("two"),
+			asst("a"),
+			asst("b"),
+		}
+		got, fixes := ValidateTranscript(msgs)
+		if len(got) != 2 || got[0].Content != "one\n\ntwo" || got[1].Content != "a\n\nb" {
+			t.Errorf("got %+v", got)
+		}
+		if len(fixes) == 0 {
+			t.Error("no fixes reported")
+		}
+		if msgs[0].Content != "one" || len(msgs[1].Blocks) != 1 {
+			t.Error("input was modified")
+		}
+	})
+
+	t.Run("orphaned results are dropped", func(t *testing.T) {
+		// A compaction cut can leave a tail that opens with results.
+		msgs := []domain.TranscriptMessage{user("summary"), asst("ok"), result("u0"), call("u1"), result("u1", "u9"), asst("done")}
+		got, _ := ValidateTranscript(msgs)
+		want := []string{"user", "assistant", "user", "assistant"}
+		if len(got) != len(want) {
+			t.Fatalf("got %+v", got)
+		}
+		for i, role := range want {
+			if got[i].Role != role {
+				t.Errorf("message %d role = %s, want %s", i, got[i].Role, role)
+			}
+		}
+		if ids, _ := collectToolResultIDs(got[2].Blocks); len(ids) != 1 || !ids["u1"] {
+			t.Errorf("results = %+v", got[2].Blocks)
+		}
+	})
+
+	t.Run("missing results are added", func(t *testing.T) {
+		msgs := []domain.TranscriptMessage{user("go"), call("u1", "u2"), result("u1"), asst("done"), user("next")}
+		got, _ := ValidateTranscript(msgs)
+		if ids, _ := collectToolResultIDs(got[2].Blocks); !ids["u1"] || !ids["u2"] {
+			t.Errorf("results = %+v", got[2].Blocks)
+		}
+
+		// A call answered by a plain prompt gets results ahead of it.
+		got, _ = ValidateTranscript([]domain.TranscriptMessage{user("go"), call("u1"), user("stop")})
+		if len(got) != 3 || got[2].Blocks[0].ToolResult != missingResult || got[2].TextContent() != "stop" {
+			t.Errorf("got %+v", got)
+		}
+
+		// A transcript ending mid-call is closed out as interrupted.
+		got, _ = ValidateTranscript([]domain.TranscriptMessage{user("go"), call("u1")})
+		if len(got) != 4 || got[3].Content != interruptedMarker {
+			t.Errorf("got %+v", got)
+		}
+		if _, fixes := ValidateTranscript(got); len(fixes) != 0 {
+			t.Errorf("repaired transcript still needs %q", fixes)
+		}
+	})
+}
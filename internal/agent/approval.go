@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// ApprovalDecision is the user's answer to an EventApprovalRequired.
+type ApprovalDecision string
+
+const (
+	ApprovalAllow  ApprovalDecision = "allow"  // run this call
+	ApprovalDeny   ApprovalDecision = "deny"   // skip this call
+	ApprovalAlways ApprovalDecision = "always" // run it and stop asking for this tool
+)
+
+// ParseApprovalDecision accepts allow/deny/always and the y/n/a shorthands.
+func ParseApprovalDecision(s string) (ApprovalDecision, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "allow", "y", "yes":
+		return ApprovalAllow, nil
+	case "deny", "n", "no":
+		return ApprovalDeny, nil
+	case "always", "a":
+		return ApprovalAlways, nil
+	default:
+		return "", fmt.Errorf("invalid decision %q (use allow, deny, or always)", s)
+	}
+}
+
+// approvalSideEffectTools are built-in tools outside the plan-mode write
+// set that still change state or reach other systems, so write approval
+// mode gates them too.
+var approvalSideEffectTools = map[string]bool{
+	"sms_send":      true,
+	"sms_schedule":  true,
+	"http_request":  true,
+	"hub_dispatch":  true,
+	"schedule_task": true,
+	"tool_create":   true,
+	"tool_register": true,
+}
+
+// needsApproval reports whether a call to the named tool must be approved
+// under the given tools.approval_mode. ask_user already waits for the user
+// and is never gated. Custom tools run shell commands and count as write
+// tools; MCP tools are only gated in "all" mode.
+func needsApproval(mode, name string) bool {
+	switch mode {
+	case config.ApprovalAll:
+		return name != "ask_user"
+	case config.ApprovalWrite:
+		if isWriteTool(name) || approvalSideEffectTools[name] {
+			return true
+		}
+		if mcp.IsMCPTool(name) {
+			return false
+		}
+		_, builtin := tools.FindTool(name)
+		return !builtin
+	default:
+		return false
+	}
+}
+
+// ApprovedTools returns the tools approved with "always" in this session.
+func (a *Service) ApprovedTools() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	names := make([]string, 0, len(a.approvedTools))
+	for name := range a.approvedTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResetApprovedTools clears the session allowlist.
+func (a *Service) ResetApprovedTools() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.approvedTools = nil
+}
+
+// approveToolCall asks the user to approve a tool call when the approval
+// mode requires it. It returns whether the call may run, and false for ok
+// if the agent was canceled while waiting.
+func (a *Service) approveToolCall(call domain.ContentBlock, mode string, onEvent EventFunc, audit func(tool, decision, detail string)) (allowed, ok bool) {
+	if !needsApproval(mode, call.ToolName) {
+		return true, true
+	}
+	a.mu.Lock()
+	preapproved := a.approvedTools[call.ToolName]
+	a.mu.Unlock()
+	if preapproved {
+		return true, true
+	}
+
+	respCh := make(chan ApprovalDecision, 1)
+	onEvent(Event{
+		Kind:             EventApprovalRequired,
+		ToolUseID:        call.ToolUseID,
+		ToolName:         call.ToolName,
+		ToolInput:        call.ToolInput,
+		ApprovalResponse: respCh,
+	})
+	var decision ApprovalDecision
+	for decision == "" {
+		select {
+		case decision = <-respCh:
+		case <-time.After(100 * time.Millisecond):
+			if a.interrupted() {
+				return false, false
+			}
+		}
+	}
+
+	if decision == ApprovalAlways {
+		a.mu.Lock()
+		if a.approvedTools == nil {
+			a.approvedTools = map[string]bool{}
+		}
+		a.approvedTools[call.ToolName] = true
+		a.mu.Unlock()
+	}
+	if audit != nil {
+		audit(call.ToolName, "approval_"+string(decision), "mode "+mode)
+	}
+	return decision != ApprovalDeny, true
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// bashThenDoneProvider asks for one bash call, then ends the turn.
+type bashThenDoneProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *bashThenDoneProvider) Name() string { return "test" }
+func (p *bashThenDoneProvider) StreamMessage(apiKey, modelID string, msgs []domain.TranscriptMessage, tools []provider.ToolSpec, system string, onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.calls == 1 {
+		return []domain.ContentBlock{{
+			Type:      "tool_use",
+			ToolUseID: "tu-1",
+			ToolName:  "bash",
+			ToolInput: map[string]any{"command": "echo approved-run"},
+		}}, "tool_use", provider.Usage{}, nil
+	}
+	return []domain.ContentBlock{{Type: "text", Text: "done"}}, "end_turn", provider.Usage{}, nil
+}
+func (p *bashThenDoneProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
+	return nil, nil
+}
+
+func TestNeedsApproval(t *testing.T) {
+	tests := []struct {
+		mode, tool string
+		want       bool
+	}{
+		{config.ApprovalOff, "bash", false},
+		{config.ApprovalWrite, "bash", true},
+		{config.ApprovalWrite, "file_write", true},
+		{config.ApprovalWrite, "sms_send", true},
+		{config.ApprovalWrite, "file_read", false},
+		{config.ApprovalWrite, "mcp__fs__read", false},
+		{config.ApprovalWrite, "my_custom_tool", true},
+		{config.ApprovalAll, "file_read", true},
+		{config.ApprovalAll, "ask_user", false},
+	}
+	for _, tt := range tests {
+		if got := needsApproval(tt.mode, tt.tool); got != tt.want {
+			t.Errorf("needsApproval(%q, %q) = %v, want %v", tt.mode, tt.tool, got, tt.want)
+		}
+	}
+}
+
+func TestParseApprovalDecision(t *testing.T) {
+	for in, want := range map[string]ApprovalDecision{"y": ApprovalAllow, "Deny": ApprovalDeny, " a ": ApprovalAlways} {
+		if got, err := ParseApprovalDecision(in); err != nil || got != want {
+			t.Errorf("ParseApprovalDecision(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseApprovalDecision("maybe"); err == nil {
+		t.Error("expected error for invalid decision")
+	}
+}
+
+func TestService_Submit_approval(t *testing.T) {
+	run := func(t *testing.T, decision ApprovalDecision) (*Service, string, int) {
+		t.Helper()
+		st := newMockStore()
+		sess := &domain.Session{ID: domain.NewUUID(), Title: "New Session", Model: "m"}
+		st.addSession(sess)
+		svc := NewService("key", "m", "label", st, sess, &bashThenDoneProvider{})
+		svc.Cwd = t.TempDir()
+		svc.SetPreferences(config.Preferences{ToolsApprovalMode: config.ApprovalWrite})
+
+		var result string
+		var prompts int
+		svc.Submit("run it", func(evt Event) {
+			switch evt.Kind {
+			case EventApprovalRequired:
+				prompts++
+				if evt.ToolName != "bash" {
+					t.Errorf("approval for %q, want bash", evt.ToolName)
+				}
+				evt.ApprovalResponse <- decision
+			case EventToolDone:
+				result = evt.ToolResult
+			}
+		})
+		return svc, result, prompts
+	}
+
+	t.Run("deny skips the call", func(t *testing.T) {
+		_, result, prompts := run(t, ApprovalDeny)
+		if prompts != 1 {
+			t.Fatalf("got %d approval prompts, want 1", prompts)
+		}
+		if !strings.Contains(result, "denied") || strings.Contains(result, "approved-run") {
+			t.Errorf("expected denial result, got %q", result)
+		}
+	})
+
+	t.Run("always runs and allowlists", func(t *testing.T) {
+		svc, result, _ := run(t, ApprovalAlways)
+		if !strings.Contains(result, "approved-run") {
+			t.Errorf("expected bash output, got %q", result)
+		}
+		if got := svc.ApprovedTools(); len(got) != 1 || got[0] != "bash" {
+			t.Errorf("ApprovedTools = %v, want [bash]", got)
+		}
+		allowed, ok := svc.approveToolCall(domain.ContentBlock{ToolName: "bash"}, config.ApprovalWrite, func(Event) {
+			t.Error("allowlisted tool should not prompt")
+		}, nil)
+		if !allowed || !ok {
+			t.Errorf("approveToolCall = %v, %v; want allowed", allowed, ok)
+		}
+	})
+}
+
+func TestService_approveToolCall_canceled(t *testing.T) {
+	svc := &Service{}
+	allowed, ok := svc.approveToolCall(domain.ContentBlock{ToolName: "bash"}, config.ApprovalAll, func(Event) {
+		svc.Cancel()
+	}, nil)
+	if allowed || ok {
+		t.Errorf("approveToolCall = %v, %v; want canceled", allowed, ok)
+	}
+}
@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// Attachment limits.
+const (
+	MaxAttachmentImageBytes = 20 * 1024 * 1024 // largest image sent as an image block
+	MaxAttachmentTextBytes  = 100 * 1024       // text files are truncated to this when inlined
+)
+
+// Attachment is a file sent along with a user message.
+type Attachment struct {
+	Name      string // file name shown to the model
+	MediaType string // e.g. image/png; anything not image/* is read as text
+	Data      []byte
+}
+
+// SubmitAttachments sends text with attachments and runs the agent loop.
+// Images become image blocks when the current model accepts them; text
+// files are inlined.
+func (a *Service) SubmitAttachments(text string, attachments []Attachment, onEvent EventFunc) {
+	a.mu.Lock()
+	images := a.prov != nil && provider.SupportsImages(a.prov.Name(), a.modelID)
+	a.mu.Unlock()
+
+	blocks, err := AttachmentBlocks(text, attachments, images)
+	if err != nil {
+		onEvent(errorEvent(err))
+		return
+	}
+	a.SubmitBlocks(blocks, onEvent)
+}
+
+// AttachmentBlocks builds the content blocks for a message with attachments,
+// followed by the message text. Images are sent as image blocks when images
+// is true and described in a text block otherwise. Text files are inlined,
+// truncated to MaxAttachmentTextBytes; binary files are rejected.
+func AttachmentBlocks(text string, attachments []Attachment, images bool) ([]domain.ContentBlock, error) {
+	var blocks []domain.ContentBlock
+	for _, att := range attachments {
+		name := att.Name
+		if name == "" {
+			name = "attachment"
+		}
+		if strings.HasPrefix(att.MediaType, "image/") {
+			if len(att.Data) > MaxAttachmentImageBytes {
+				return nil, fmt.Errorf("image %s is too large (max %d MB)", name, MaxAttachmentImageBytes/(1024*1024))
+			}
+			if !images {
+				blocks = append(blocks, domain.ContentBlock{
+					Type: "text",
+					Text: fmt.Sprintf("[Image: %s was attached but not sent; the current model does not accept images]", name),
+				})
+				continue
+			}
+			blocks = append(blocks, domain.ContentBlock{
+				Type:       "image",
+				MediaType:  att.MediaType,
+				Base64Data: base64.StdEncoding.EncodeToString(att.Data),
+				ImagePath:  name,
+			})
+			continue
+		}
+		body, err := attachmentText(name, att.Data)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, domain.ContentBlock{Type: "text", Text: body})
+	}
+	if text = strings.TrimSpace(text); text != "" {
+		blocks = append(blocks, domain.ContentBlock{Type: "text", Text: text})
+	}
+	return blocks, nil
+}
+
+// attachmentText renders a text file for inlining into a message.
+func attachmentText(name string, data []byte) (string, error) {
+	truncated := len(data) > MaxAttachmentTextBytes
+	if truncated {
+		data = trimPartialRune(data[:MaxAttachmentTextBytes])
+	}
+	if !utf8.Valid(data) || strings.ContainsRune(string(data), 0) {
+		return "", fmt.Errorf("attachment %s is not a text file", name)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "[File: %s]\n", name)
+	b.Write(data)
+	if truncated {
+		fmt.Fprintf(&b, "\n[truncated to the first %d KB]", MaxAttachmentTextBytes/1024)
+	}
+	return b.String(), nil
+}
+
+// trimPartialRune drops an incomplete UTF-8 sequence left at the end of
+// data by truncation.
+func trimPartialRune(data []byte) []byte {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return data[:i]
+			}
+			break
+		}
+	}
+	return data
+}
@@ -0,0 +1,69 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAttachmentBlocks(t *testing.T) {
+	png := Attachment{Name: "shot.png", MediaType: "image/png", Data: []byte("\x89PNG")}
+	notes := Attachment{Name: "notes.md", MediaType: "text/plain", Data: []byte("# Notes\nhello")}
+
+	t.Run("vision model gets image blocks", func(t *testing.T) {
+		blocks, err := AttachmentBlocks("  look at these ", []Attachment{png, notes}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != 3 {
+			t.Fatalf("got %d blocks, want 3", len(blocks))
+		}
+		if blocks[0].Type != "image" || blocks[0].MediaType != "image/png" || blocks[0].ImagePath != "shot.png" || blocks[0].Base64Data == "" {
+			t.Errorf("image block = %+v", blocks[0])
+		}
+		if blocks[1].Type != "text" || !strings.HasPrefix(blocks[1].Text, "[File: notes.md]\n# Notes") {
+			t.Errorf("file block = %+v", blocks[1])
+		}
+		if blocks[2].Text != "look at these" {
+			t.Errorf("text block = %q", blocks[2].Text)
+		}
+	})
+
+	t.Run("text-only model gets a note instead of the image", func(t *testing.T) {
+		blocks, err := AttachmentBlocks("", []Attachment{png}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(blocks) != 1 || blocks[0].Type != "text" || !strings.Contains(blocks[0].Text, "shot.png") {
+			t.Errorf("blocks = %+v", blocks)
+		}
+	})
+
+	t.Run("large text is truncated", func(t *testing.T) {
+		big := Attachment{Name: "big.txt", MediaType: "text/plain", Data: []byte(strings.Repeat("é", MaxAttachmentTextBytes))}
+		blocks, err := AttachmentBlocks("", []Attachment{big}, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text := blocks[0].Text
+		if !strings.HasSuffix(text, "[truncated to the first 100 KB]") {
+			t.Errorf("missing truncation note: %q", text[len(text)-40:])
+		}
+		if len(text) > MaxAttachmentTextBytes+100 {
+			t.Errorf("len = %d, want about %d", len(text), MaxAttachmentTextBytes)
+		}
+	})
+
+	t.Run("binary file is rejected", func(t *testing.T) {
+		bin := Attachment{Name: "a.out", MediaType: "application/octet-stream", Data: []byte{0x7f, 'E', 'L', 'F', 0, 0}}
+		if _, err := AttachmentBlocks("", []Attachment{bin}, true); err == nil {
+			t.Error("expected error for binary attachment")
+		}
+	})
+
+	t.Run("oversized image is rejected", func(t *testing.T) {
+		huge := Attachment{Name: "huge.png", MediaType: "image/png", Data: make([]byte, MaxAttachmentImageBytes+1)}
+		if _, err := AttachmentBlocks("", []Attachment{huge}, true); err == nil {
+			t.Error("expected error for oversized image")
+		}
+	})
+}
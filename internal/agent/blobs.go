@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/sink"
+)
+
+// blobPreviewLen caps how much of an offloaded tool result stays in the
+// database. Providers send the model no more than this anyway.
+const blobPreviewLen = 10000
+
+// blobUploadTimeout bounds offloading one round of tool results.
+const blobUploadTimeout = 2 * time.Minute
+
+// offloadToolResults returns the tool results to persist. Results of at
+// least storage.blob_min_kb are stored in the storage sink and replaced by
+// a preview naming the blob; the in-memory history keeps them whole. A
+// result that fails to upload is persisted in full.
+func (a *Service) offloadToolResults(results []domain.ContentBlock) []domain.ContentBlock {
+	a.mu.Lock()
+	prefs := a.prefs
+	a.mu.Unlock()
+	minBytes := prefs.BlobMinBytes()
+	if minBytes == 0 {
+		return results
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blobUploadTimeout)
+	defer cancel()
+	var storage sink.Sink
+	var out []domain.ContentBlock
+	for i, b := range results {
+		if len(b.ToolResult) < minBytes {
+			continue
+		}
+		if storage == nil {
+			s, err := sink.FromPreferences(prefs)
+			if err != nil || s == nil {
+				a.logf("agent: offload tool results: %v", err)
+				return results
+			}
+			storage = s
+		}
+		id, err := sink.PutBlob(ctx, storage, []byte(b.ToolResult))
+		if err != nil {
+			a.logf("agent: offload %s result: %v", b.ToolName, err)
+			continue
+		}
+		if out == nil {
+			out = slices.Clone(results)
+		}
+		out[i].ToolResult = blobPreview(b.ToolResult, id, min(blobPreviewLen, minBytes/2))
+	}
+	if out == nil {
+		return results
+	}
+	return out
+}
+
+// blobPreview keeps the first n bytes of an offloaded result and names the
+// blob holding all of it.
+func blobPreview(result, id string, n int) string {
+	return fmt.Sprintf("%s\n... (full result of %d bytes stored as blob %s)", strings.ToValidUTF8(result[:n], ""), len(result), id)
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestOffloadToolResults(t *testing.T) {
+	var mu sync.Mutex
+	uploads := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		uploads[r.URL.Path] = string(body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	big := strings.Repeat("x", 4096)
+	results := []domain.ContentBlock{
+		{Type: "tool_result", ToolName: "read_file", ToolResult: big},
+		{Type: "tool_result", ToolName: "list_files", ToolResult: "small"},
+	}
+
+	svc := &Service{}
+	if got := svc.offloadToolResults(results); got[0].ToolResult != big {
+		t.Fatal("offloaded without storage.blob_min_kb")
+	}
+
+	svc.SetPreferences(config.Preferences{
+		StorageS3URL:       srv.URL + "/bucket",
+		StorageS3AccessKey: "AK",
+		StorageS3SecretKey: "SK",
+		StorageBlobMinKB:   "2",
+	})
+	got := svc.offloadToolResults(results)
+	if results[0].ToolResult != big {
+		t.Error("offloading changed the in-memory result")
+	}
+	if got[1].ToolResult != "small" {
+		t.Errorf("small result = %q", got[1].ToolResult)
+	}
+	if len(got[0].ToolResult) >= len(big) || !strings.Contains(got[0].ToolResult, "stored as blob ") {
+		t.Fatalf("persisted = %.80q", got[0].ToolResult)
+	}
+	id := got[0].ToolResult[strings.LastIndex(got[0].ToolResult, " ")+1 : len(got[0].ToolResult)-1]
+	mu.Lock()
+	defer mu.Unlock()
+	if uploads["/bucket/blobs/"+id[:2]+"/"+id+".txt"] != big {
+		t.Errorf("uploads = %v, want the full result under blob %s", len(uploads), id)
+	}
+}
@@ -0,0 +1,166 @@
+package agent
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// BudgetWarnShare is the share of a budget at which the agent warns once.
+const BudgetWarnShare = 0.8
+
+// Budget scopes.
+const (
+	BudgetSession = "session"
+	BudgetDaily   = "daily"
+)
+
+// BudgetStatus is the spend against one configured budget.
+type BudgetStatus struct {
+	Scope    string // BudgetSession or BudgetDaily
+	SpentUSD float64
+	LimitUSD float64
+}
+
+// Key returns the preference that sets the budget.
+func (b BudgetStatus) Key() string { return "budget." + b.Scope + "_usd" }
+
+// Percent returns the share of the budget spent, in percent.
+func (b BudgetStatus) Percent() int {
+	if b.LimitUSD <= 0 {
+		return 0
+	}
+	return int(b.SpentUSD / b.LimitUSD * 100)
+}
+
+func (b BudgetStatus) String() string {
+	return fmt.Sprintf("%d%% of the %s budget used ($%.2f of $%.2f)", b.Percent(), b.Scope, b.SpentUSD, b.LimitUSD)
+}
+
+// BudgetError stops a turn once a budget is used up.
+type BudgetError struct {
+	BudgetStatus
+}
+
+// ErrorCode reports the error as domain.ErrBudgetExceeded.
+func (e *BudgetError) ErrorCode() domain.ErrorCode { return domain.ErrBudgetExceeded }
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s budget reached ($%.2f of $%.2f spent); raise %s to continue",
+		e.Scope, e.SpentUSD, e.LimitUSD, e.Key())
+}
+
+// budgetOwner returns the Service whose session and budgets this one's
+// spend counts against: itself, or the top-level agent of a sub-agent.
+func (a *Service) budgetOwner() *Service {
+	for a.parent != nil {
+		a = a.parent
+	}
+	return a
+}
+
+// budgets returns the configured budgets with their spend as of now.
+// Without a SpendStore nothing is tracked, so there is nothing to enforce.
+func (a *Service) budgets(now time.Time) []BudgetStatus {
+	owner := a.budgetOwner()
+	spends, ok := owner.store.(SpendStore)
+	if !ok {
+		return nil
+	}
+	owner.mu.Lock()
+	sessionLimit, dailyLimit := owner.prefs.Budgets()
+	sess := owner.session
+	daySpend := owner.daySpend
+	owner.mu.Unlock()
+	if daySpend == nil {
+		daySpend = spends.DaySpend
+	}
+
+	var out []BudgetStatus
+	if sessionLimit > 0 && sess != nil {
+		if spent, err := spends.SessionSpend(sess.ID); err != nil {
+			a.logf("agent: session spend: %v", err)
+		} else {
+			out = append(out, BudgetStatus{Scope: BudgetSession, SpentUSD: spent, LimitUSD: sessionLimit})
+		}
+	}
+	if dailyLimit > 0 {
+		if spent, err := daySpend(now); err != nil {
+			a.logf("agent: daily spend: %v", err)
+		} else {
+			out = append(out, BudgetStatus{Scope: BudgetDaily, SpentUSD: spent, LimitUSD: dailyLimit})
+		}
+	}
+	return out
+}
+
+// checkBudget returns a *BudgetError if a budget is used up.
+func (a *Service) checkBudget(now time.Time) error {
+	for _, b := range a.budgets(now) {
+		if b.SpentUSD >= b.LimitUSD {
+			return &BudgetError{BudgetStatus: b}
+		}
+	}
+	return nil
+}
+
+// recordSpend persists the estimated cost of one model call and warns the
+// first time a budget passes BudgetWarnShare.
+func (a *Service) recordSpend(usage provider.Usage, now time.Time, onEvent EventFunc) {
+	a.mu.Lock()
+	model := a.modelID
+	a.mu.Unlock()
+	a.recordModelSpend(model, usage, now, onEvent)
+}
+
+// recordModelSpend is recordSpend for a call made with model rather than
+// the session's model, such as a verification pass.
+func (a *Service) recordModelSpend(model string, usage provider.Usage, now time.Time, onEvent EventFunc) {
+	owner := a.budgetOwner()
+	spends, ok := owner.store.(SpendStore)
+	if !ok {
+		return
+	}
+	sessionID := ""
+	if owner.session != nil {
+		sessionID = owner.session.ID
+	}
+	cost := provider.ModelCostWithCache(model, usage.InputTokens, usage.OutputTokens,
+		usage.CacheCreationInputTokens, usage.CacheReadInputTokens)
+	tokens := store.TokenCounts{
+		Input:      usage.InputTokens,
+		Output:     usage.OutputTokens,
+		CacheWrite: usage.CacheCreationInputTokens,
+		CacheRead:  usage.CacheReadInputTokens,
+	}
+	if err := spends.RecordSpend(sessionID, model, now, tokens, cost); err != nil {
+		a.logf("agent: record spend: %v", err)
+		return
+	}
+
+	for _, b := range a.budgets(now) {
+		if b.SpentUSD < b.LimitUSD*BudgetWarnShare {
+			continue
+		}
+		key := b.Scope
+		if b.Scope == BudgetDaily {
+			key += ":" + now.Local().Format(store.SpendDayLayout)
+		}
+		owner.mu.Lock()
+		warned := owner.budgetWarned[key]
+		if !warned {
+			if owner.budgetWarned == nil {
+				owner.budgetWarned = make(map[string]bool)
+			}
+			owner.budgetWarned[key] = true
+		}
+		owner.mu.Unlock()
+		if !warned {
+			status := b
+			onEvent(Event{Kind: EventBudgetWarning, Budget: &status})
+		}
+	}
+}
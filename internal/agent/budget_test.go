@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// spendMockStore adds in-memory spend tracking to mockStore.
+type spendMockStore struct {
+	*mockStore
+	spendMu sync.Mutex
+	session map[string]float64
+	day     float64
+}
+
+func (s *spendMockStore) RecordSpend(sessionID, model string, at time.Time, tokens store.TokenCounts, cost float64) error {
+	s.spendMu.Lock()
+	defer s.spendMu.Unlock()
+	s.session[sessionID] += cost
+	s.day += cost
+	return nil
+}
+
+func (s *spendMockStore) SessionSpend(sessionID string) (float64, error) {
+	s.spendMu.Lock()
+	defer s.spendMu.Unlock()
+	return s.session[sessionID], nil
+}
+
+func (s *spendMockStore) DaySpend(time.Time) (float64, error) {
+	s.spendMu.Lock()
+	defer s.spendMu.Unlock()
+	return s.day, nil
+}
+
+func TestService_Submit_budget(t *testing.T) {
+	origPricing := provider.PricingMap
+	provider.PricingMap = map[string]domain.ModelPricing{"fake": {InputPerMillion: 100_000}} // $1 per 10 input tokens
+	defer func() { provider.PricingMap = origPricing }()
+
+	st := &spendMockStore{mockStore: newMockStore(), session: map[string]float64{}}
+	sess := &domain.Session{ID: domain.NewUUID(), Title: "test", Model: "fake"}
+	st.addSession(sess)
+
+	// Every call costs $1 and asks for another tool round.
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		writeSSE(w, "message_start", map[string]any{
+			"message": map[string]any{"usage": map[string]any{"input_tokens": 10, "output_tokens": 0}},
+		})
+		writeSSE(w, "content_block_start", map[string]any{
+			"index":         0,
+			"content_block": map[string]any{"type": "tool_use", "id": "tu_budget", "name": "list_files"},
+		})
+		writeSSE(w, "content_block_delta", map[string]any{
+			"index": 0,
+			"delta": map[string]any{"type": "input_json_delta", "partial_json": `{"path":"."}`},
+		})
+		writeSSE(w, "content_block_stop", map[string]any{"index": 0})
+		writeSSE(w, "message_delta", map[string]any{
+			"usage": map[string]any{"output_tokens": 5},
+			"delta": map[string]any{"stop_reason": "tool_use"},
+		})
+	}))
+	defer server.Close()
+
+	origURL := provider.TestAPIURL
+	provider.TestAPIURL = server.URL
+	defer func() { provider.TestAPIURL = origURL }()
+
+	svc := NewService("fake-key", "fake", "fake", st, sess, &testAnthropicProvider{})
+	svc.Cwd = "."
+	svc.SetPreferences(config.Preferences{BudgetSessionUSD: "1.20"})
+
+	var warnings []BudgetStatus
+	var stopErr error
+	svc.Submit("Spend", func(evt Event) {
+		switch evt.Kind {
+		case EventBudgetWarning:
+			warnings = append(warnings, *evt.Budget)
+		case EventError:
+			stopErr = evt.Err
+		}
+	})
+
+	if n := calls.Load(); n != 2 {
+		t.Errorf("provider calls = %d, want 2 (stop once $1.20 is used up)", n)
+	}
+	if len(warnings) != 1 || warnings[0].Scope != BudgetSession || warnings[0].SpentUSD != 1 {
+		t.Errorf("warnings = %+v, want one session warning at $1", warnings)
+	}
+	var budgetErr *BudgetError
+	if !errors.As(stopErr, &budgetErr) || budgetErr.Key() != "budget.session_usd" || budgetErr.SpentUSD != 2 {
+		t.Errorf("stop error = %v, want a session BudgetError", stopErr)
+	}
+
+	// A new turn is refused before calling the model.
+	stopErr = nil
+	svc.Submit("More", func(evt Event) {
+		if evt.Kind == EventError {
+			stopErr = evt.Err
+		}
+	})
+	if !errors.As(stopErr, &budgetErr) || calls.Load() != 2 {
+		t.Errorf("second turn: err = %v, calls = %d", stopErr, calls.Load())
+	}
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// citations numbers the web sources read in a session, so the model can
+// cite them as [N] and the same URL keeps its number across turns.
+type citations struct {
+	mu     sync.Mutex
+	seeded bool
+	byURL  map[string]int
+	last   int
+}
+
+// reset forgets the numbering, for a new session.
+func (c *citations) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seeded = false
+	c.byURL = nil
+	c.last = 0
+}
+
+// cite returns the source number for url, assigning the next one on first
+// use. Numbers already in the session's transcript are loaded first, so a
+// resumed session continues its numbering.
+func (a *Service) cite(url, title string) int {
+	c := &a.sources
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.seeded {
+		c.seeded = true
+		c.byURL = map[string]int{}
+		for _, src := range domain.ParseCitations(domain.MessageBlocks(a.citationHistory())) {
+			if _, ok := c.byURL[src.URL]; !ok {
+				c.byURL[src.URL] = src.N
+			}
+			c.last = max(c.last, src.N)
+		}
+	}
+	if n, ok := c.byURL[url]; ok {
+		return n
+	}
+	c.last++
+	c.byURL[url] = c.last
+	return c.last
+}
+
+// citationHistory is the transcript that source numbers are loaded from:
+// the stored messages when available, since compaction summarizes web
+// results in memory.
+func (a *Service) citationHistory() []domain.TranscriptMessage {
+	sess := a.Session()
+	if a.store != nil && sess != nil {
+		if msgs, err := a.store.GetMessages(sess.ID); err == nil {
+			return msgs
+		}
+	}
+	return a.Messages()
+}
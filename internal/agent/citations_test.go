@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestCite(t *testing.T) {
+	st := newMockStore()
+	sess, _ := st.CreateSession("/tmp/cite", "m")
+	_ = st.AppendMessageBlocks(sess.ID, "user", []domain.ContentBlock{
+		{Type: "tool_result", ToolName: "web_search", ToolResult: "[1] Go\n   https://go.dev\n\n[2] Wiki\n   https://go.dev/wiki"},
+	}, 0)
+	a := NewService("", "m", "m", st, sess, nil)
+
+	// A resumed session continues its numbering and keeps known URLs.
+	if n := a.cite("https://go.dev/wiki", "Wiki"); n != 2 {
+		t.Errorf("known URL = %d, want 2", n)
+	}
+	if n := a.cite("https://pkg.go.dev", ""); n != 3 {
+		t.Errorf("new URL = %d, want 3", n)
+	}
+	if n := a.cite("https://pkg.go.dev", "pkg"); n != 3 {
+		t.Errorf("repeated URL = %d, want 3", n)
+	}
+
+	if err := a.NewSession("/tmp/cite"); err != nil {
+		t.Fatal(err)
+	}
+	if n := a.cite("https://pkg.go.dev", ""); n != 1 {
+		t.Errorf("new session numbering starts at %d, want 1", n)
+	}
+}
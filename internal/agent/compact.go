@@ -1,8 +1,10 @@
 package agent
 
 import (
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/batalabs/muxd/internal/domain"
 	"github.com/batalabs/muxd/internal/provider"
@@ -51,6 +53,7 @@ Be specific. Use exact file paths, function names, and variable names. Maximum 6
 type CompactResult struct {
 	Messages   []domain.TranscriptMessage // compacted list (head + placeholder + tail)
 	Dropped    []domain.TranscriptMessage // removed middle section
+	Kept       int                        // messages kept from the end
 	DidCompact bool
 }
 
@@ -106,12 +109,38 @@ func CompactMessages(msgs []domain.TranscriptMessage) CompactResult {
 	return CompactResult{
 		Messages:   compacted,
 		Dropped:    droppedMsgs,
+		Kept:       len(tail),
 		DidCompact: true,
 	}
 }
 
+// Shares of a model's context window at which each tier fires, for models
+// whose window is small enough that the fixed thresholds would overflow it.
+const (
+	tier1WindowShare = 0.50
+	tier2WindowShare = 0.62
+	tier3WindowShare = 0.75
+)
+
+// compactThresholds returns the input token counts at which tiers 1-3 fire
+// for model: the fixed thresholds, lowered to a share of the model's
+// context window when that is smaller.
+func compactThresholds(model string) (tier1, tier2, tier3 int) {
+	tier1, tier2, tier3 = Tier1Threshold, Tier2Threshold, Tier3Threshold
+	if window := provider.ContextWindow(model); window > 0 {
+		tier1 = min(tier1, int(float64(window)*tier1WindowShare))
+		tier2 = min(tier2, int(float64(window)*tier2WindowShare))
+		tier3 = min(tier3, int(float64(window)*tier3WindowShare))
+	}
+	return tier1, tier2, tier3
+}
+
+// ErrTurnRunning is returned by Compact while a turn is in progress.
+var ErrTurnRunning = errors.New("a turn is running")
+
 // compactIfNeeded applies tiered context compression when input tokens exceed
-// configured thresholds. The tiers are applied progressively:
+// the thresholds compactThresholds gives for the session's model. The tiers
+// are applied progressively:
 //
 //   - Tier 1 (>60k tokens): compress tool results in older messages (no LLM call).
 //   - Tier 2 (>75k tokens): collapse old turns to one-line summaries (no LLM call).
@@ -121,9 +150,10 @@ func CompactMessages(msgs []domain.TranscriptMessage) CompactResult {
 func (a *Service) compactIfNeeded(onEvent EventFunc) {
 	a.mu.Lock()
 	inputTokens := a.lastInputTokens
+	tier1, tier2, tier3 := compactThresholds(a.modelID)
 
 	// ── Tier 1: tool result compression ──────────────────────────────────
-	if inputTokens > Tier1Threshold && !a.tier1Applied {
+	if inputTokens > tier1 && !a.tier1Applied {
 		tailStart := len(a.messages) - CompactKeepTail
 		if tailStart < 0 {
 			tailStart = 0
@@ -136,7 +166,7 @@ func (a *Service) compactIfNeeded(onEvent EventFunc) {
 	}
 
 	// ── Tier 2: old turn collapse ────────────────────────────────────────
-	if inputTokens > Tier2Threshold && !a.tier2Applied {
+	if inputTokens > tier2 && !a.tier2Applied {
 		tailStart := len(a.messages) - CompactKeepTail
 		if tailStart < 0 {
 			tailStart = 0
@@ -147,13 +177,38 @@ func (a *Service) compactIfNeeded(onEvent EventFunc) {
 		onEvent(Event{Kind: EventCompacted, ModelUsed: "tier2"})
 		return
 	}
+	a.mu.Unlock()
 
 	// ── Tier 3: full LLM summarization ───────────────────────────────────
-	if inputTokens <= Tier3Threshold {
+	if inputTokens > tier3 {
+		a.summarizeHistory(onEvent)
+	}
+}
+
+// Compact runs Tier 3 summarization now, whatever the context size, and
+// returns the number of messages summarized. A conversation too short to
+// compact returns 0. It fails with ErrTurnRunning during a turn.
+func (a *Service) Compact(onEvent EventFunc) (int, error) {
+	a.mu.Lock()
+	if a.running || a.compacting {
 		a.mu.Unlock()
-		return
+		return 0, ErrTurnRunning
 	}
+	a.compacting = true
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.compacting = false
+		a.mu.Unlock()
+	}()
+	return a.summarizeHistory(onEvent), nil
+}
 
+// summarizeHistory replaces the middle of the conversation with an LLM
+// summary of it and persists the summary, so Resume rebuilds the same
+// context. It returns the number of messages summarized.
+func (a *Service) summarizeHistory(onEvent EventFunc) int {
+	a.mu.Lock()
 	// Reset tier flags so tiers 1 & 2 can fire again after this full recompaction.
 	a.tier1Applied = false
 	a.tier2Applied = false
@@ -161,7 +216,7 @@ func (a *Service) compactIfNeeded(onEvent EventFunc) {
 	result := CompactMessages(a.messages)
 	if !result.DidCompact {
 		a.mu.Unlock()
-		return
+		return 0
 	}
 
 	a.messages = result.Messages
@@ -173,7 +228,7 @@ func (a *Service) compactIfNeeded(onEvent EventFunc) {
 	onEvent(Event{Kind: EventToolStart, ToolUseID: "internal_compact", ToolName: "compact_context"})
 
 	// Generate LLM summary of dropped messages (unlocked — makes API call).
-	summary := a.generateCompactionSummary(result.Dropped)
+	summary := a.generateCompactionSummary(result.Dropped, onEvent)
 
 	// Replace the placeholder user message with the real summary.
 	a.mu.Lock()
@@ -185,13 +240,17 @@ func (a *Service) compactIfNeeded(onEvent EventFunc) {
 	}
 	a.mu.Unlock()
 
-	a.persistCompaction(summary)
+	a.persistCompaction(summary, result.Kept)
 	onEvent(Event{Kind: EventToolDone, ToolUseID: "internal_compact", ToolName: "compact_context", ToolResult: fmt.Sprintf("Compacted %d messages (model: %s)", droppedCount, sumModel)})
 	onEvent(Event{Kind: EventCompacted, ModelUsed: sumModel})
+	return droppedCount
 }
 
 // persistCompaction saves the current compaction state to the database.
-func (a *Service) persistCompaction(summary string) {
+// kept is the number of trailing messages left after the summary; Resume
+// loads the messages after the cutoff, so the cutoff is placed just before
+// them.
+func (a *Service) persistCompaction(summary string, kept int) {
 	if a.store == nil || a.session == nil {
 		return
 	}
@@ -199,8 +258,7 @@ func (a *Service) persistCompaction(summary string) {
 	if err != nil || maxSeq == 0 {
 		return
 	}
-	// Use the tail size to compute the cutoff point.
-	cutoff := maxSeq - CompactKeepTail
+	cutoff := maxSeq - kept
 	if cutoff <= 0 {
 		return
 	}
@@ -414,8 +472,9 @@ func (a *Service) summarizationModel() string {
 }
 
 // generateCompactionSummary uses a cheap LLM to produce a structured summary
-// of dropped messages. Falls back to a simple placeholder on error.
-func (a *Service) generateCompactionSummary(dropped []domain.TranscriptMessage) string {
+// of dropped messages and records what the call cost. Falls back to a simple
+// placeholder on error.
+func (a *Service) generateCompactionSummary(dropped []domain.TranscriptMessage, onEvent EventFunc) string {
 	fallback := fmt.Sprintf("[%d earlier messages were compacted. No summary available.]", len(dropped))
 
 	serialized := serializeMessagesForSummary(dropped)
@@ -431,17 +490,16 @@ func (a *Service) generateCompactionSummary(dropped []domain.TranscriptMessage)
 	}
 
 	sumModel := a.summarizationModel()
-	var respBlocks []domain.ContentBlock
-	var err error
 	if a.prov == nil {
 		return fallback
 	}
-	respBlocks, _, _, err = a.prov.StreamMessage(
+	respBlocks, _, usage, err := a.prov.StreamMessage(
 		a.apiKey, sumModel, msgs, nil, system, nil,
 	)
 	if err != nil {
 		return fallback
 	}
+	a.recordModelSpend(sumModel, usage, time.Now(), onEvent)
 
 	var respText string
 	for _, b := range respBlocks {
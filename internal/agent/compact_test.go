@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -101,13 +103,13 @@ func TestPersistCompaction(t *testing.T) {
 	t.Run("skips when no store", func(t *testing.T) {
 		svc := &Service{session: &domain.Session{ID: "s1"}}
 		// Should not panic
-		svc.persistCompaction("summary")
+		svc.persistCompaction("summary", CompactKeepTail)
 	})
 
 	t.Run("skips when no session", func(t *testing.T) {
 		svc := &Service{store: newMockStore()}
 		// Should not panic
-		svc.persistCompaction("summary")
+		svc.persistCompaction("summary", CompactKeepTail)
 	})
 
 	t.Run("saves compaction to store", func(t *testing.T) {
@@ -125,7 +127,7 @@ func TestPersistCompaction(t *testing.T) {
 		}
 
 		svc := NewService("key", "model", "label", st, sess, &fakeProvider{name: "test"})
-		svc.persistCompaction("test summary")
+		svc.persistCompaction("test summary", CompactKeepTail)
 
 		if !st.savedCalled {
 			t.Error("expected SaveCompaction to be called")
@@ -133,6 +135,9 @@ func TestPersistCompaction(t *testing.T) {
 		if st.savedSummary != "test summary" {
 			t.Errorf("expected saved summary 'test summary', got %q", st.savedSummary)
 		}
+		if st.savedCutoff != 10 {
+			t.Errorf("expected cutoff 10, got %d", st.savedCutoff)
+		}
 	})
 
 	t.Run("skips when maxSeq is zero", func(t *testing.T) {
@@ -144,7 +149,7 @@ func TestPersistCompaction(t *testing.T) {
 		// No messages -maxSeq will be 0
 
 		svc := NewService("key", "model", "label", st, sess, &fakeProvider{name: "test"})
-		svc.persistCompaction("summary")
+		svc.persistCompaction("summary", CompactKeepTail)
 
 		if st.savedCalled {
 			t.Error("SaveCompaction should not be called when maxSeq=0")
@@ -152,6 +157,97 @@ func TestPersistCompaction(t *testing.T) {
 	})
 }
 
+func TestCompactThresholds(t *testing.T) {
+	t.Run("large window keeps fixed thresholds", func(t *testing.T) {
+		t1, t2, t3 := compactThresholds("claude-sonnet-4-6")
+		if t1 != Tier1Threshold || t2 != Tier2Threshold || t3 != Tier3Threshold {
+			t.Errorf("got %d/%d/%d", t1, t2, t3)
+		}
+	})
+
+	t.Run("unknown model keeps fixed thresholds", func(t *testing.T) {
+		if _, _, t3 := compactThresholds("qwen2.5-coder:7b"); t3 != Tier3Threshold {
+			t.Errorf("expected %d, got %d", Tier3Threshold, t3)
+		}
+	})
+
+	t.Run("small window lowers thresholds", func(t *testing.T) {
+		t1, t2, t3 := compactThresholds("gpt-4")
+		if t3 >= 8_192 || !(t1 < t2 && t2 < t3) {
+			t.Errorf("got %d/%d/%d for an 8k window", t1, t2, t3)
+		}
+	})
+}
+
+func TestCompact(t *testing.T) {
+	newCompactService := func() (*Service, *persistCompactionMock) {
+		st := &persistCompactionMock{mockStore: newMockStore()}
+		sess := &domain.Session{ID: "sess-compact"}
+		st.addSession(sess)
+		var msgs []domain.TranscriptMessage
+		for i := 0; i < 40; i++ {
+			role := "user"
+			if i%2 == 1 {
+				role = "assistant"
+			}
+			msgs = append(msgs, domain.TranscriptMessage{Role: role, Content: fmt.Sprintf("m%d", i)})
+		}
+		st.messages[sess.ID] = append(st.messages[sess.ID], msgs...)
+		svc := NewService("key", "model", "label", st, sess, &mockConsultProvider{name: "test", response: "the summary"})
+		svc.messages = append([]domain.TranscriptMessage(nil), msgs...)
+		return svc, st
+	}
+
+	t.Run("summarizes and persists the dropped span", func(t *testing.T) {
+		svc, st := newCompactService()
+		var compacted bool
+		n, err := svc.Compact(func(evt Event) {
+			if evt.Kind == EventCompacted {
+				compacted = true
+			}
+		})
+		if err != nil {
+			t.Fatalf("Compact: %v", err)
+		}
+		if n != 40-2-CompactKeepTail {
+			t.Errorf("expected %d messages summarized, got %d", 40-2-CompactKeepTail, n)
+		}
+		if !compacted {
+			t.Error("expected EventCompacted")
+		}
+		if !strings.Contains(st.savedSummary, "the summary") {
+			t.Errorf("saved summary = %q", st.savedSummary)
+		}
+		// The tail kept in memory is what Resume loads after the cutoff.
+		if st.savedCutoff != 40-CompactKeepTail {
+			t.Errorf("expected cutoff %d, got %d", 40-CompactKeepTail, st.savedCutoff)
+		}
+		if svc.IsRunning() {
+			t.Error("expected IsRunning false after Compact")
+		}
+	})
+
+	t.Run("short conversation is left alone", func(t *testing.T) {
+		svc, st := newCompactService()
+		svc.messages = svc.messages[:4]
+		n, err := svc.Compact(func(Event) {})
+		if err != nil || n != 0 {
+			t.Errorf("got %d, %v", n, err)
+		}
+		if st.savedCalled {
+			t.Error("SaveCompaction should not be called")
+		}
+	})
+
+	t.Run("refused while a turn runs", func(t *testing.T) {
+		svc, _ := newCompactService()
+		svc.running = true
+		if _, err := svc.Compact(func(Event) {}); !errors.Is(err, ErrTurnRunning) {
+			t.Errorf("expected ErrTurnRunning, got %v", err)
+		}
+	})
+}
+
 func TestSummarizeToolInput(t *testing.T) {
 	t.Run("nil input", func(t *testing.T) {
 		got := summarizeToolInput(nil)
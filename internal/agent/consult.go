@@ -16,23 +16,34 @@ const consultSystemPrompt = "You are providing a second opinion on a coding prob
 func (a *Service) Consult(summary string) (string, error) {
 	a.mu.Lock()
 	modelConsult := a.modelConsult
-	primaryProvider := a.prov
-	primaryAPIKey := a.apiKey
 	a.mu.Unlock()
 
 	if modelConsult == "" {
 		return "", fmt.Errorf("no consult model configured")
 	}
 
-	providerName, modelID := provider.ResolveProviderAndModel(modelConsult, "")
+	prov, apiKey, modelID, err := a.sideModel(modelConsult)
+	if err != nil {
+		return "", fmt.Errorf("consult: resolving provider: %w", err)
+	}
+
+	return consultWithProvider(prov, apiKey, modelID, summary)
+}
+
+// sideModel resolves a model specifier such as "openai/gpt-4o" for a call
+// outside the main loop, returning its provider, API key, and model ID.
+func (a *Service) sideModel(spec string) (provider.Provider, string, string, error) {
+	providerName, modelID := provider.ResolveProviderAndModel(spec, "")
 
 	a.mu.Lock()
 	prefs := a.prefs
+	primaryProvider := a.prov
+	primaryAPIKey := a.apiKey
 	a.mu.Unlock()
 
 	apiKey, _ := config.LoadProviderAPIKey(prefs, providerName)
 
-	// Fall back to the primary API key when the consult model uses the same
+	// Fall back to the primary API key when the model uses the same
 	// provider as the primary model and no explicit key was resolved.
 	if apiKey == "" && primaryProvider != nil && primaryProvider.Name() == providerName {
 		apiKey = primaryAPIKey
@@ -40,10 +51,9 @@ func (a *Service) Consult(summary string) (string, error) {
 
 	prov, err := provider.GetProvider(providerName)
 	if err != nil {
-		return "", fmt.Errorf("consult: resolving provider: %w", err)
+		return nil, "", "", err
 	}
-
-	return consultWithProvider(prov, apiKey, modelID, summary)
+	return prov, apiKey, modelID, nil
 }
 
 // consultWithProvider is the testable core of Consult. It sends a single-turn
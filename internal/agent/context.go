@@ -0,0 +1,180 @@
+package agent
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// ---------------------------------------------------------------------------
+// Request assembly
+// ---------------------------------------------------------------------------
+
+// requestTools returns the tool specs offered to the model and the names of
+// the MCP tools among them.
+func (a *Service) requestTools(planMode bool, disabled map[string]bool, mcpMgr *mcp.Manager, custom *tools.CustomToolRegistry) ([]provider.ToolSpec, []string) {
+	var toolSpecs []provider.ToolSpec
+	if a.isSubAgent {
+		toolSpecs = tools.AllToolSpecsForSubAgent()
+	} else {
+		toolSpecs = tools.AllToolSpecsForModeWithDisabled(planMode, disabled)
+	}
+	// Append MCP tool specs (filtered by disabled set).
+	var mcpToolNames []string
+	if mcpMgr != nil {
+		for _, spec := range mcpMgr.ToolSpecs() {
+			if !disabled[spec.Name] {
+				toolSpecs = append(toolSpecs, spec)
+				mcpToolNames = append(mcpToolNames, spec.Name)
+			}
+		}
+	}
+	// Append custom tool specs (filtered by disabled set).
+	if custom != nil {
+		for _, spec := range custom.Specs() {
+			if !disabled[spec.Name] {
+				toolSpecs = append(toolSpecs, spec)
+			}
+		}
+	}
+	return a.restrictToolSpecs(toolSpecs, disabled), mcpToolNames
+}
+
+// systemPrompt assembles the system prompt for the next model call.
+func (a *Service) systemPrompt(cwd string, mcpToolNames []string, shell, language, tone string, planLocked bool) string {
+	memoryText := ""
+	if a.memory != nil {
+		memoryText = a.memory.FormatForPrompt()
+	}
+	system := provider.BuildSystemPrompt(cwd, mcpToolNames, memoryText) +
+		provider.StylePrompt(language, tone) +
+		tools.ShellPrompt(shell)
+	if planLocked {
+		system += provider.PlanModePrompt
+	}
+	return system
+}
+
+// ---------------------------------------------------------------------------
+// Context inspection
+// ---------------------------------------------------------------------------
+
+// ContextMessage is one message of the window sent to the model.
+type ContextMessage struct {
+	domain.TranscriptMessage
+	Tokens int // estimated
+}
+
+// PinnedFact is a project memory fact, included in every system prompt and
+// so kept across compaction.
+type PinnedFact struct {
+	Key   string
+	Value string
+}
+
+// ContextSnapshot is what the session's next model call would send.
+// Token counts are estimates at about four characters a token.
+type ContextSnapshot struct {
+	Provider string
+	Model    string
+
+	System       string
+	SystemTokens int
+	// Summary is the compaction summary standing in for older messages,
+	// empty when the history was never compacted. It is also the first
+	// user message of Messages.
+	Summary  string
+	Pinned   []PinnedFact
+	Messages []ContextMessage
+	Tools    []string
+	// ToolTokens estimates the tool definitions.
+	ToolTokens int
+
+	EstimatedTokens int
+	// LastInputTokens is the input the provider reported for the last call.
+	LastInputTokens int
+}
+
+// Context returns what the next model call would send: the system prompt,
+// compaction summary, pinned memory, message window, and tools. Image data
+// is left out of the messages.
+func (a *Service) Context() ContextSnapshot {
+	a.mu.Lock()
+	messages := make([]domain.TranscriptMessage, len(a.messages))
+	copy(messages, a.messages)
+	disabled := make(map[string]bool, len(a.disabledTools))
+	for k, v := range a.disabledTools {
+		disabled[k] = v
+	}
+	snap := ContextSnapshot{Model: a.modelID, LastInputTokens: a.lastInputTokens}
+	if a.prov != nil {
+		snap.Provider = a.prov.Name()
+	}
+	planMode, planLocked := a.planMode, a.planLocked
+	language, tone := a.styleLanguage, a.styleTone
+	mcpMgr, custom, shell := a.mcpManager, a.customTools, a.prefs.ShellBackend
+	a.mu.Unlock()
+
+	cwd := a.Cwd
+	if cwd == "" {
+		cwd, _ = tools.Getwd() //nolint:errcheck // fallback to empty string
+	}
+	toolSpecs, mcpToolNames := a.requestTools(planMode, disabled, mcpMgr, custom)
+	snap.System = a.systemPrompt(cwd, mcpToolNames, shell, language, tone, planLocked)
+	snap.SystemTokens = provider.EstimateTokens(snap.System)
+	snap.ToolTokens = provider.EstimateToolTokens(toolSpecs)
+	for _, spec := range toolSpecs {
+		snap.Tools = append(snap.Tools, spec.Name)
+	}
+	if a.memory != nil {
+		if facts, err := a.memory.Load(); err == nil {
+			for key, value := range facts {
+				snap.Pinned = append(snap.Pinned, PinnedFact{Key: key, Value: value})
+			}
+			sort.Slice(snap.Pinned, func(i, j int) bool { return snap.Pinned[i].Key < snap.Pinned[j].Key })
+		}
+	}
+
+	if repaired, changed := repairDanglingToolUseMessages(messages); changed {
+		messages = repaired
+	}
+	snap.EstimatedTokens = snap.SystemTokens + snap.ToolTokens
+	for i, msg := range messages {
+		if i < 2 && msg.Role == "user" && isCompactionSummary(msg.Content) {
+			snap.Summary = msg.Content
+		}
+		cm := ContextMessage{TranscriptMessage: withoutImageData(msg), Tokens: provider.EstimateMessageTokens(msg)}
+		snap.Messages = append(snap.Messages, cm)
+		snap.EstimatedTokens += cm.Tokens
+	}
+	return snap
+}
+
+// isCompactionSummary reports whether content is the message that replaced
+// compacted history (see compactIfNeeded and Resume).
+func isCompactionSummary(content string) bool {
+	return strings.HasPrefix(content, "[Conversation summary]") ||
+		strings.HasPrefix(content, "[Previous conversation summary]") ||
+		(strings.HasPrefix(content, "[") && strings.Contains(content, "earlier messages") && strings.Contains(content, "compacted"))
+}
+
+// withoutImageData drops base64 image bytes, keeping the media type.
+func withoutImageData(msg domain.TranscriptMessage) domain.TranscriptMessage {
+	for _, b := range msg.Blocks {
+		if b.Base64Data == "" {
+			continue
+		}
+		blocks := make([]domain.ContentBlock, len(msg.Blocks))
+		copy(blocks, msg.Blocks)
+		for i := range blocks {
+			blocks[i].Base64Data = ""
+		}
+		msg.Blocks = blocks
+		break
+	}
+	return msg
+}
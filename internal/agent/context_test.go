@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+func TestService_Context(t *testing.T) {
+	svc := NewService("key", "model-x", "label", nil, nil, &fakeProvider{name: "test"})
+	svc.Cwd = t.TempDir()
+	mem := tools.NewProjectMemory(svc.Cwd)
+	if err := mem.Save(map[string]string{"db": "postgres 16", "api": "REST"}); err != nil {
+		t.Fatal(err)
+	}
+	svc.SetMemory(mem)
+	svc.SetStyle("German", "")
+	svc.lastInputTokens = 4321
+	svc.messages = []domain.TranscriptMessage{
+		{Role: "user", Content: "[Conversation summary]\n\nWe set up the schema."},
+		{Role: "assistant", Content: "Understood. I'll continue with the context available."},
+		{Role: "user", Blocks: []domain.ContentBlock{
+			{Type: "text", Text: "what is this?"},
+			{Type: "image", MediaType: "image/png", Base64Data: "iVBORw0KGgo="},
+		}},
+		{Role: "assistant", Blocks: []domain.ContentBlock{
+			{Type: "tool_use", ToolUseID: "t1", ToolName: "bash", ToolInput: map[string]any{"command": "ls"}},
+		}},
+	}
+
+	snap := svc.Context()
+	if snap.Provider != "test" || snap.Model != "model-x" || snap.LastInputTokens != 4321 {
+		t.Errorf("header = %q %q %d", snap.Provider, snap.Model, snap.LastInputTokens)
+	}
+	if !strings.Contains(snap.System, "German") || !strings.Contains(snap.System, "postgres 16") {
+		t.Error("system prompt is missing the style or memory")
+	}
+	if len(snap.Pinned) != 2 || snap.Pinned[0].Key != "api" {
+		t.Errorf("pinned = %+v", snap.Pinned)
+	}
+	if !strings.HasPrefix(snap.Summary, "[Conversation summary]") {
+		t.Errorf("summary = %q", snap.Summary)
+	}
+	// The dangling tool_use gets the same repair the next call would apply.
+	if len(snap.Messages) != 3 || snap.Messages[2].Role != "user" {
+		t.Fatalf("messages = %+v", snap.Messages)
+	}
+	if img := snap.Messages[2].Blocks[1]; img.Base64Data != "" || img.MediaType != "image/png" {
+		t.Errorf("image block = %+v", img)
+	}
+	if svc.messages[2].Blocks[1].Base64Data == "" {
+		t.Error("Context changed the agent's messages")
+	}
+	if snap.Messages[2].Tokens < provider.ImageTokenEstimate {
+		t.Errorf("image message tokens = %d", snap.Messages[2].Tokens)
+	}
+	if len(snap.Tools) == 0 || snap.ToolTokens == 0 {
+		t.Error("no tools reported")
+	}
+	sum := snap.SystemTokens + snap.ToolTokens
+	for _, m := range snap.Messages {
+		sum += m.Tokens
+	}
+	if snap.EstimatedTokens != sum {
+		t.Errorf("estimate %d, want the parts' sum %d", snap.EstimatedTokens, sum)
+	}
+}
+
+func TestIsCompactionSummary(t *testing.T) {
+	for content, want := range map[string]bool{
+		"[Conversation summary]\n\n...":                               true,
+		"[Previous conversation summary]\n\n...":                      true,
+		"[12 earlier messages compacted to save context]":             true,
+		"[12 earlier messages were compacted. No summary available.]": true,
+		"[x] is a checkbox":                                           false,
+		"please summarize the conversation so far":                    false,
+	} {
+		if got := isCompactionSummary(content); got != want {
+			t.Errorf("isCompactionSummary(%q) = %v", content, got)
+		}
+	}
+}
+
+func TestService_Submit_contextWithoutUsage(t *testing.T) {
+	svc := NewService("key", "claude-sonnet-4", "label", nil, nil, &fakeProvider{name: "test"})
+	svc.Cwd = t.TempDir()
+
+	var done *Event
+	svc.Submit("Hello", func(evt Event) {
+		if evt.Kind == EventStreamDone {
+			done = &evt
+		}
+	})
+	if done == nil {
+		t.Fatal("expected a stream_done event")
+	}
+	// The provider reported no usage, so the prompt is estimated; the
+	// system prompt and tool definitions alone run to thousands of tokens.
+	if done.ContextTokens < 1000 {
+		t.Errorf("expected an estimate including system prompt and tools, got %d", done.ContextTokens)
+	}
+	if done.ContextWindow != 200_000 {
+		t.Errorf("expected window 200000, got %d", done.ContextWindow)
+	}
+	if done.CompactAt != Tier3Threshold {
+		t.Errorf("expected compaction at %d, got %d", Tier3Threshold, done.CompactAt)
+	}
+}
@@ -0,0 +1,45 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/batalabs/muxd/internal/diagram"
+)
+
+// renderDiagrams renders mermaid/graphviz blocks in the final assistant text
+// to SVG files under the project's diagram dir when diagrams.render is on.
+// Each result (or failure) is reported as an EventDiagram.
+func (a *Service) renderDiagrams(ctx context.Context, text string, onEvent EventFunc) {
+	a.mu.Lock()
+	enabled := a.prefs.DiagramsRender
+	krokiURL := a.prefs.DiagramsKrokiURL
+	cwd := a.Cwd
+	a.mu.Unlock()
+	if !enabled {
+		return
+	}
+	blocks := diagram.Extract(text)
+	if len(blocks) == 0 {
+		return
+	}
+	if cwd == "" {
+		cwd, _ = os.Getwd()
+	}
+
+	r := diagram.NewRenderer(krokiURL)
+	dir := filepath.Join(cwd, diagram.Dir)
+	for _, b := range blocks {
+		path, err := r.Render(ctx, b, dir)
+		if err != nil {
+			a.logf("agent: render %s diagram: %v", b.Kind, err)
+			onEvent(Event{Kind: EventDiagram, DiagramKind: b.Kind, Err: err})
+			continue
+		}
+		if rel, relErr := filepath.Rel(cwd, path); relErr == nil {
+			path = rel
+		}
+		onEvent(Event{Kind: EventDiagram, DiagramKind: b.Kind, DiagramPath: path})
+	}
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/guardrail"
+	"github.com/batalabs/muxd/internal/redact"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// Audit decisions for outbound content.
+const (
+	auditGuardrailBlocked = "guardrail_blocked"
+	auditGuardrailFlagged = "guardrail_flagged"
+	auditPIIMasked        = "pii_masked"
+	auditPIIConfirmed     = "pii_confirmed"
+	auditPIIDeclined      = "pii_declined"
+)
+
+// screenOutbound applies the outbound guardrail and PII policies to the
+// content fields of a messaging tool call. It returns the call to execute
+// (with masked input if needed), a notice to append to the tool result, and
+// whether the call was blocked, in which case the notice is the result.
+func screenOutbound(call domain.ContentBlock, ctx *tools.ToolContext) (domain.ContentBlock, string, bool) {
+	fields := tools.MessagingContentFields(call.ToolName)
+	if len(fields) == 0 {
+		return call, "", false
+	}
+	audit := func(decision, detail string) {
+		if ctx.Audit != nil {
+			ctx.Audit(call.ToolName, decision, detail)
+		}
+	}
+
+	var notice string
+	if r := ctx.OutboundGuardrail.ScreenFields(call.ToolInput, fields); r.Blocked {
+		audit(auditGuardrailBlocked, r.Summary())
+		return call, fmt.Sprintf("Blocked by outbound guardrail (%s). Remove the flagged content before sending.", r.Summary()), true
+	} else if r.Flagged() {
+		audit(auditGuardrailFlagged, r.Summary())
+		notice += "\n\n[guardrail] flagged: " + r.Summary()
+	}
+
+	policy := ctx.OutboundPII
+	if !policy.Enabled() {
+		return call, notice, false
+	}
+	masked := make(map[string]any, len(call.ToolInput))
+	for k, v := range call.ToolInput {
+		masked[k] = v
+	}
+	var found []redact.Match
+	for _, f := range fields {
+		s, ok := call.ToolInput[f].(string)
+		if !ok {
+			continue
+		}
+		m, matches := policy.Mask(s)
+		masked[f] = m
+		found = append(found, matches...)
+	}
+	if len(found) == 0 {
+		return call, notice, false
+	}
+	summary := guardrail.SummarizeMatches(found)
+
+	if policy.Mode == guardrail.PIIConfirm {
+		if ctx.AskUser == nil {
+			audit(auditPIIDeclined, summary+"; no interactive user to confirm")
+			return call, fmt.Sprintf("Not sent: message contains personal data (%s) and no user is available to confirm.", summary), true
+		}
+		question := fmt.Sprintf("%s message contains personal data (%s):\n\n%s\n\nSend it? Answer yes, mask (send with placeholders), or no.",
+			call.ToolName, summary, outboundText(call.ToolInput, fields))
+		answer, ok := ctx.AskUser(question)
+		choice := strings.ToLower(strings.TrimSpace(answer))
+		switch {
+		case ok && (choice == "y" || choice == "yes"):
+			audit(auditPIIConfirmed, summary)
+			return call, notice, false
+		case ok && (choice == "m" || choice == "mask"):
+			// Send the masked message below.
+		default:
+			audit(auditPIIDeclined, summary)
+			return call, fmt.Sprintf("Not sent: the user declined sending personal data (%s).", summary), true
+		}
+	}
+
+	audit(auditPIIMasked, summary)
+	call.ToolInput = masked
+	return call, notice + "\n\n[pii] masked before sending: " + summary, false
+}
+
+// outboundText joins the content fields of a tool input for display.
+func outboundText(input map[string]any, fields []string) string {
+	var parts []string
+	for _, f := range fields {
+		if s, ok := input[f].(string); ok {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/guardrail"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+func TestScreenOutbound(t *testing.T) {
+	smsCall := func() domain.ContentBlock {
+		return domain.ContentBlock{
+			Type:      "tool_use",
+			ToolName:  "sms_send",
+			ToolInput: map[string]any{"phone": "415-555-0100", "message": "Email jane@example.com or call 415-555-0132"},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		mode         string
+		askUser      func(string) (string, bool)
+		wantBlocked  bool
+		wantMessage  string
+		wantDecision string
+	}{
+		{"off", "off", nil, false, "Email jane@example.com or call 415-555-0132", ""},
+		{"mask", "mask", nil, false, "Email [EMAIL] or call [PHONE]", auditPIIMasked},
+		{"confirm without user", "confirm", nil, true, "", auditPIIDeclined},
+		{"confirm yes", "confirm", func(string) (string, bool) { return "yes", true }, false, "Email jane@example.com or call 415-555-0132", auditPIIConfirmed},
+		{"confirm mask", "confirm", func(string) (string, bool) { return "mask", true }, false, "Email [EMAIL] or call [PHONE]", auditPIIMasked},
+		{"confirm no", "confirm", func(string) (string, bool) { return "no", true }, true, "", auditPIIDeclined},
+		{"confirm canceled", "confirm", func(string) (string, bool) { return "", false }, true, "", auditPIIDeclined},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var decisions []string
+			ctx := &tools.ToolContext{
+				OutboundPII: guardrail.NewPIIPolicy(tt.mode, ""),
+				AskUser:     tt.askUser,
+				Audit: func(toolName, decision, detail string) {
+					if strings.Contains(detail, "jane@") {
+						t.Errorf("audit detail leaks personal data: %q", detail)
+					}
+					decisions = append(decisions, decision)
+				},
+			}
+			call, notice, blocked := screenOutbound(smsCall(), ctx)
+			if blocked != tt.wantBlocked {
+				t.Fatalf("blocked = %v, want %v (notice %q)", blocked, tt.wantBlocked, notice)
+			}
+			if !blocked {
+				if got := call.ToolInput["message"]; got != tt.wantMessage {
+					t.Errorf("message = %q, want %q", got, tt.wantMessage)
+				}
+				if call.ToolInput["phone"] != "415-555-0100" {
+					t.Errorf("recipient must not be masked, got %q", call.ToolInput["phone"])
+				}
+			}
+			switch {
+			case tt.wantDecision == "" && len(decisions) != 0:
+				t.Errorf("unexpected audit decisions: %v", decisions)
+			case tt.wantDecision != "" && (len(decisions) != 1 || decisions[0] != tt.wantDecision):
+				t.Errorf("audit decisions = %v, want [%s]", decisions, tt.wantDecision)
+			}
+		})
+	}
+}
+
+func TestScreenOutbound_customPatterns(t *testing.T) {
+	ctx := &tools.ToolContext{OutboundPII: guardrail.NewPIIPolicy("mask", `(?i)\bjane doe\b;ACME-[0-9]+`)}
+	call := domain.ContentBlock{ToolName: "sms_send", ToolInput: map[string]any{"message": "Jane Doe closed ACME-42"}}
+	call, _, _ = screenOutbound(call, ctx)
+	if got := call.ToolInput["message"]; got != "[CUSTOM] closed [CUSTOM]" {
+		t.Errorf("message = %q", got)
+	}
+}
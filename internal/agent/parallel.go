@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"sync"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// ---------------------------------------------------------------------------
+// Parallel tool execution
+// ---------------------------------------------------------------------------
+
+// isSerialTool reports whether a tool changes files or runs commands. Such
+// calls run one at a time in the order the model emitted them, so a write
+// and a later bash in the same turn never race. bash_reset shares the lane
+// with the bash calls whose shell it restarts. Custom tools run shell
+// commands and count as serial; MCP tools are left to their servers.
+func isSerialTool(name string) bool {
+	if isWriteTool(name) || name == "bash_reset" {
+		return true
+	}
+	if mcp.IsMCPTool(name) {
+		return false
+	}
+	_, builtin := tools.FindTool(name)
+	return !builtin
+}
+
+// runToolCalls executes tool_use blocks with at most limit running at once
+// and returns their tool_result blocks in the order the model emitted them.
+// Serial tools share one lane; the rest run alongside it. tool_start and
+// tool_done events carry the tool_use_id, since calls finish in any order.
+// It returns false if the agent is canceled before every call has started;
+// calls already running are waited for. After Stop, calls not yet started
+// get a not-run result instead.
+func (a *Service) runToolCalls(blocks []domain.ContentBlock, limit int, run func(domain.ContentBlock) (string, bool), onEvent EventFunc) ([]domain.ContentBlock, bool) {
+	if limit < 1 {
+		limit = 1
+	}
+	results := make([]domain.ContentBlock, len(blocks))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	canceled, stopped := false, false
+
+	// start waits for a slot and reports whether the call may run.
+	start := func() bool {
+		sem <- struct{}{}
+		mu.Lock()
+		defer mu.Unlock()
+		a.mu.Lock()
+		canceled = canceled || a.canceled
+		stopped = stopped || a.stopping
+		a.mu.Unlock()
+		if canceled || stopped {
+			<-sem
+			return false
+		}
+		return true
+	}
+	exec := func(idx int) {
+		defer func() { <-sem }()
+		b := blocks[idx]
+		onEvent(Event{
+			Kind:      EventToolStart,
+			ToolUseID: b.ToolUseID,
+			ToolName:  b.ToolName,
+			ToolInput: b.ToolInput,
+		})
+		result, isError := run(b)
+		onEvent(Event{
+			Kind:        EventToolDone,
+			ToolUseID:   b.ToolUseID,
+			ToolName:    b.ToolName,
+			ToolResult:  result,
+			ToolIsError: isError,
+		})
+		results[idx] = domain.ContentBlock{
+			Type:       "tool_result",
+			ToolUseID:  b.ToolUseID,
+			ToolName:   b.ToolName,
+			ToolResult: result,
+			IsError:    isError,
+		}
+	}
+
+	// With a limit of 1 everything goes through the lane, in order.
+	var serial, parallel []int
+	for i, b := range blocks {
+		if limit == 1 || isSerialTool(b.ToolName) {
+			serial = append(serial, i)
+		} else {
+			parallel = append(parallel, i)
+		}
+	}
+
+	if len(serial) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, idx := range serial {
+				if !start() {
+					return
+				}
+				exec(idx)
+			}
+		}()
+	}
+	for _, idx := range parallel {
+		if !start() {
+			break
+		}
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			exec(idx)
+		}(idx)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if stopped && !canceled {
+		for i, r := range results {
+			if r.Type == "" {
+				results[i] = skippedToolResult(blocks[i])
+			}
+		}
+	}
+	return results, !canceled
+}
@@ -0,0 +1,160 @@
+package agent
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func toolBlocks(names ...string) []domain.ContentBlock {
+	blocks := make([]domain.ContentBlock, len(names))
+	for i, n := range names {
+		blocks[i] = domain.ContentBlock{Type: "tool_use", ToolUseID: fmt.Sprintf("tu_%d", i), ToolName: n}
+	}
+	return blocks
+}
+
+func TestIsSerialTool(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"bash", true},
+		{"file_write", true},
+		{"file_edit", true},
+		{"patch_apply", true},
+		{"file_read", false},
+		{"grep", false},
+		{"web_fetch", false},
+		{"mcp__server__tool", false},
+		{"my_custom_tool", true},
+	}
+	for _, tt := range tests {
+		if got := isSerialTool(tt.name); got != tt.want {
+			t.Errorf("isSerialTool(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestService_runToolCalls(t *testing.T) {
+	t.Run("limits concurrency and keeps order", func(t *testing.T) {
+		svc := NewService("key", "m", "label", nil, nil, &echoProvider{})
+		var running, peak atomic.Int32
+		blocks := toolBlocks("file_read", "grep", "glob", "list_files", "file_read", "grep")
+		results, ok := svc.runToolCalls(blocks, 2, func(b domain.ContentBlock) (string, bool) {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			return "out " + b.ToolUseID, false
+		}, func(Event) {})
+		if !ok {
+			t.Fatal("runToolCalls reported cancellation")
+		}
+		if p := peak.Load(); p != 2 {
+			t.Errorf("peak concurrency = %d, want 2", p)
+		}
+		for i, r := range results {
+			if r.Type != "tool_result" || r.ToolUseID != blocks[i].ToolUseID || r.ToolResult != "out "+blocks[i].ToolUseID {
+				t.Errorf("result %d = %+v", i, r)
+			}
+		}
+	})
+
+	t.Run("serial tools run alone and in order", func(t *testing.T) {
+		svc := NewService("key", "m", "label", nil, nil, &echoProvider{})
+		var mu sync.Mutex
+		var order []string
+		var serialRunning atomic.Int32
+		blocks := toolBlocks("file_write", "file_read", "bash", "grep", "file_edit")
+		_, ok := svc.runToolCalls(blocks, 4, func(b domain.ContentBlock) (string, bool) {
+			if isSerialTool(b.ToolName) {
+				if serialRunning.Add(1) > 1 {
+					t.Errorf("%s overlapped another serial tool", b.ToolName)
+				}
+				defer serialRunning.Add(-1)
+				mu.Lock()
+				order = append(order, b.ToolName)
+				mu.Unlock()
+			}
+			time.Sleep(5 * time.Millisecond)
+			return "", false
+		}, func(Event) {})
+		if !ok {
+			t.Fatal("runToolCalls reported cancellation")
+		}
+		if fmt.Sprint(order) != "[file_write bash file_edit]" {
+			t.Errorf("serial order = %v", order)
+		}
+	})
+
+	t.Run("limit 1 runs everything in order", func(t *testing.T) {
+		svc := NewService("key", "m", "label", nil, nil, &echoProvider{})
+		var mu sync.Mutex
+		var started []string
+		svc.runToolCalls(toolBlocks("grep", "file_read", "bash", "glob"), 1, func(b domain.ContentBlock) (string, bool) {
+			return "", false
+		}, func(evt Event) {
+			if evt.Kind == EventToolStart {
+				mu.Lock()
+				started = append(started, evt.ToolUseID)
+				mu.Unlock()
+			}
+		})
+		if fmt.Sprint(started) != "[tu_0 tu_1 tu_2 tu_3]" {
+			t.Errorf("start order = %v", started)
+		}
+	})
+
+	t.Run("events are keyed by tool_use_id", func(t *testing.T) {
+		svc := NewService("key", "m", "label", nil, nil, &echoProvider{})
+		var mu sync.Mutex
+		starts, dones := map[string]bool{}, map[string]bool{}
+		svc.runToolCalls(toolBlocks("grep", "file_read", "glob"), 3, func(b domain.ContentBlock) (string, bool) {
+			return "", b.ToolName == "glob"
+		}, func(evt Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch evt.Kind {
+			case EventToolStart:
+				starts[evt.ToolUseID] = true
+			case EventToolDone:
+				if !starts[evt.ToolUseID] {
+					t.Errorf("tool_done for %s before its tool_start", evt.ToolUseID)
+				}
+				if evt.ToolIsError != (evt.ToolName == "glob") {
+					t.Errorf("done %s: ToolIsError = %v", evt.ToolUseID, evt.ToolIsError)
+				}
+				dones[evt.ToolUseID] = true
+			}
+		})
+		if len(starts) != 3 || len(dones) != 3 {
+			t.Errorf("starts = %v, dones = %v", starts, dones)
+		}
+	})
+
+	t.Run("canceled runs nothing", func(t *testing.T) {
+		svc := NewService("key", "m", "label", nil, nil, &echoProvider{})
+		svc.canceled = true
+		var calls atomic.Int32
+		_, ok := svc.runToolCalls(toolBlocks("grep", "bash"), 4, func(domain.ContentBlock) (string, bool) {
+			calls.Add(1)
+			return "", false
+		}, func(Event) {})
+		if ok {
+			t.Error("expected cancellation")
+		}
+		if n := calls.Load(); n != 0 {
+			t.Errorf("%d calls ran after cancel", n)
+		}
+	})
+}
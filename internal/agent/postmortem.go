@@ -0,0 +1,204 @@
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// postmortemMinToolFailures is how many failed tool calls a turn needs
+// before an EventError triggers an automatic post-mortem.
+const postmortemMinToolFailures = 2
+
+// PostmortemStore is an optional extension used to persist automatic
+// failure diagnoses.
+type PostmortemStore interface {
+	SavePostmortem(p store.Postmortem) error
+}
+
+// toolFailure is a single failed tool call observed during a turn.
+type toolFailure struct {
+	Tool   string
+	Result string
+}
+
+// turnFailures records tool failures and the terminal error of a turn.
+// Tool calls may run in parallel, so access is mutex-guarded.
+type turnFailures struct {
+	mu       sync.Mutex
+	failures []toolFailure
+	err      error
+}
+
+// observe wraps onEvent so failures are recorded before delivery.
+func (t *turnFailures) observe(onEvent EventFunc) EventFunc {
+	return func(evt Event) {
+		switch evt.Kind {
+		case EventToolDone:
+			if evt.ToolIsError {
+				t.mu.Lock()
+				t.failures = append(t.failures, toolFailure{Tool: evt.ToolName, Result: evt.ToolResult})
+				t.mu.Unlock()
+			}
+		case EventError:
+			t.mu.Lock()
+			t.err = evt.Err
+			t.mu.Unlock()
+		}
+		onEvent(evt)
+	}
+}
+
+// diagnosable returns the recorded failures and terminal error, or nil
+// failures when the turn does not warrant a post-mortem.
+func (t *turnFailures) diagnosable() ([]toolFailure, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil || len(t.failures) < postmortemMinToolFailures {
+		return nil, nil
+	}
+	return append([]toolFailure(nil), t.failures...), t.err
+}
+
+// runPostmortem diagnoses a failed turn and attaches the result to the
+// session. It is meant to run in its own goroutine after the turn ends.
+func (a *Service) runPostmortem(userText string, failures []toolFailure, turnErr error) {
+	a.mu.Lock()
+	st, ok := a.store.(PostmortemStore)
+	sess := a.session
+	model := a.modelCompact
+	if model == "" {
+		model = a.modelTitle
+	}
+	if model == "" {
+		model = a.modelID
+	}
+	a.mu.Unlock()
+	if !ok || sess == nil {
+		return
+	}
+
+	category, diagnosis := "", ""
+	if a.prov != nil && model != "" {
+		category, diagnosis = a.llmPostmortem(userText, failures, turnErr, model)
+	}
+	if diagnosis == "" {
+		category, diagnosis = fallbackPostmortem(failures, turnErr)
+	}
+
+	pm := store.Postmortem{
+		SessionID:    sess.ID,
+		Error:        turnErr.Error(),
+		ToolFailures: len(failures),
+		FailedTools:  strings.Join(failedToolNames(failures), ","),
+		Category:     category,
+		Diagnosis:    diagnosis,
+	}
+	if err := st.SavePostmortem(pm); err != nil {
+		a.logf("agent: save post-mortem: %v", err)
+	}
+}
+
+// llmPostmortem asks a cheap model for a one-line category and a short
+// diagnosis. Returns empty strings if the call fails or the reply is
+// malformed.
+func (a *Service) llmPostmortem(userText string, failures []toolFailure, turnErr error, model string) (category, diagnosis string) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Request: %s\n\nFailed tool calls:\n", truncateText(userText, 500))
+	for i, f := range failures {
+		fmt.Fprintf(&b, "%d. %s: %s\n", i+1, f.Tool, truncateText(strings.TrimSpace(f.Result), 300))
+	}
+	fmt.Fprintf(&b, "\nFinal error: %s", truncateText(turnErr.Error(), 500))
+
+	system := "You diagnose failed coding-agent turns. Reply with exactly two lines:\n" +
+		"CATEGORY: <2-4 word lowercase failure class, e.g. build failure, missing file, permission denied>\n" +
+		"DIAGNOSIS: <one or two sentences: what failed, why, and what the agent retried>"
+	msgs := []domain.TranscriptMessage{{Role: "user", Content: b.String()}}
+
+	blocks, _, _, err := a.prov.StreamMessage(a.apiKey, model, msgs, nil, system, nil)
+	if err != nil {
+		a.logf("agent: post-mortem: %v", err)
+		return "", ""
+	}
+	var text string
+	for _, blk := range blocks {
+		if blk.Type == "text" {
+			text += blk.Text
+		}
+	}
+	return parsePostmortem(text)
+}
+
+// parsePostmortem extracts the CATEGORY and DIAGNOSIS lines of a model reply.
+func parsePostmortem(text string) (category, diagnosis string) {
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if v, ok := cutPrefixFold(line, "CATEGORY:"); ok {
+			category = strings.ToLower(strings.TrimSpace(v))
+		} else if v, ok := cutPrefixFold(line, "DIAGNOSIS:"); ok {
+			diagnosis = strings.TrimSpace(v)
+		}
+	}
+	if diagnosis == "" {
+		return "", ""
+	}
+	return truncateText(category, 60), truncateText(diagnosis, 500)
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || !strings.EqualFold(s[:len(prefix)], prefix) {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
+
+// fallbackPostmortem builds a deterministic diagnosis from the failure
+// counts when no model is available.
+func fallbackPostmortem(failures []toolFailure, turnErr error) (category, diagnosis string) {
+	counts := map[string]int{}
+	for _, f := range failures {
+		counts[f.Tool]++
+	}
+	names := failedToolNames(failures)
+	sort.SliceStable(names, func(i, j int) bool { return counts[names[i]] > counts[names[j]] })
+	top := names[0]
+
+	last := ""
+	for i := len(failures) - 1; i >= 0; i-- {
+		if failures[i].Tool == top {
+			last = firstLine(failures[i].Result)
+			break
+		}
+	}
+	category = top + " failure"
+	diagnosis = fmt.Sprintf("The turn failed with %q after %d failed tool calls; %s failed %d time(s)",
+		truncateText(firstLine(turnErr.Error()), 120), len(failures), top, counts[top])
+	if last != "" {
+		diagnosis += fmt.Sprintf(", last with %q", truncateText(last, 120))
+	}
+	return category, diagnosis + "."
+}
+
+// failedToolNames returns the distinct tool names in order of first failure.
+func failedToolNames(failures []toolFailure) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, f := range failures {
+		if !seen[f.Tool] {
+			seen[f.Tool] = true
+			names = append(names, f.Tool)
+		}
+	}
+	return names
+}
+
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
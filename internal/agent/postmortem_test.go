@@ -0,0 +1,90 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// postmortemMockStore records saved post-mortems.
+type postmortemMockStore struct {
+	*mockStore
+	saved []store.Postmortem
+}
+
+func (s *postmortemMockStore) SavePostmortem(p store.Postmortem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, p)
+	return nil
+}
+
+func TestTurnFailures_diagnosable(t *testing.T) {
+	var delivered int
+	rec := &turnFailures{}
+	onEvent := rec.observe(func(Event) { delivered++ })
+
+	onEvent(Event{Kind: EventToolDone, ToolName: "bash", ToolResult: "exit 1", ToolIsError: true})
+	onEvent(Event{Kind: EventToolDone, ToolName: "file_read", ToolResult: "ok"})
+	if fs, _ := rec.diagnosable(); fs != nil {
+		t.Fatal("expected no post-mortem without an error")
+	}
+
+	onEvent(Event{Kind: EventError, Err: fmt.Errorf("boom")})
+	if fs, _ := rec.diagnosable(); fs != nil {
+		t.Fatal("expected no post-mortem after a single tool failure")
+	}
+
+	onEvent(Event{Kind: EventToolDone, ToolName: "bash", ToolResult: "exit 2", ToolIsError: true})
+	fs, err := rec.diagnosable()
+	if len(fs) != 2 || err == nil {
+		t.Fatalf("diagnosable = %d failures, %v; want 2 and an error", len(fs), err)
+	}
+	if delivered != 4 {
+		t.Errorf("delivered %d events, want 4", delivered)
+	}
+}
+
+func TestParsePostmortem(t *testing.T) {
+	cat, diag := parsePostmortem("CATEGORY: Build Failure\nDiagnosis: go build failed on a missing import; retried 3 times.")
+	if cat != "build failure" {
+		t.Errorf("category = %q", cat)
+	}
+	if !strings.HasPrefix(diag, "go build failed") {
+		t.Errorf("diagnosis = %q", diag)
+	}
+	if cat, diag := parsePostmortem("no idea"); cat != "" || diag != "" {
+		t.Errorf("expected empty result for malformed reply, got %q %q", cat, diag)
+	}
+}
+
+func TestService_runPostmortem_fallback(t *testing.T) {
+	st := &postmortemMockStore{mockStore: newMockStore()}
+	sess := &domain.Session{ID: "sess-pm"}
+	st.addSession(sess)
+	svc := NewService("key", "model", "label", st, sess, &errorProvider{})
+
+	failures := []toolFailure{
+		{Tool: "bash", Result: "go build: undefined: foo"},
+		{Tool: "file_edit", Result: "old_string not found"},
+		{Tool: "bash", Result: "go build: undefined: foo\nmore"},
+	}
+	svc.runPostmortem("fix the build", failures, fmt.Errorf("agent loop limit exceeded"))
+
+	if len(st.saved) != 1 {
+		t.Fatalf("saved %d post-mortems, want 1", len(st.saved))
+	}
+	pm := st.saved[0]
+	if pm.SessionID != "sess-pm" || pm.ToolFailures != 3 || pm.FailedTools != "bash,file_edit" {
+		t.Errorf("unexpected post-mortem: %+v", pm)
+	}
+	if pm.Category != "bash failure" {
+		t.Errorf("category = %q, want %q", pm.Category, "bash failure")
+	}
+	if !strings.Contains(pm.Diagnosis, "bash failed 2 time(s)") {
+		t.Errorf("diagnosis = %q", pm.Diagnosis)
+	}
+}
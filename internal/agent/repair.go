@@ -2,6 +2,62 @@ package agent
 
 import "github.com/batalabs/muxd/internal/domain"
 
+const (
+	// interruptedResult is the tool result recorded for a call that never
+	// got one because the daemon stopped mid-turn.
+	interruptedResult = "Not finished: muxd stopped while this call was running. Its effects, if any, are unknown; check before relying on them."
+
+	// interruptedMarker closes out a turn cut off by the daemon stopping.
+	interruptedMarker = "[Turn interrupted: muxd stopped before this turn finished. Nothing after the work above happened.]"
+)
+
+// RepairInterruptedTurn returns the messages to append to a transcript whose
+// last turn was cut off by the daemon stopping, so that it ends cleanly:
+// results for tool calls left without one, then an assistant note saying
+// the turn was interrupted. It also returns the prompt that started the
+// turn, so it can be offered for a re-run. A transcript that already ends
+// in a reply needs nothing.
+func RepairInterruptedTurn(msgs []domain.TranscriptMessage) (fix []domain.TranscriptMessage, prompt string) {
+	if len(msgs) == 0 {
+		return nil, ""
+	}
+	last := msgs[len(msgs)-1]
+	if last.Role == "assistant" {
+		ids := collectToolUseIDs(last.Blocks)
+		if len(ids) == 0 {
+			return nil, ""
+		}
+		names := map[string]string{}
+		for _, b := range last.Blocks {
+			names[b.ToolUseID] = b.ToolName
+		}
+		results := make([]domain.ContentBlock, 0, len(ids))
+		for _, id := range ids {
+			results = append(results, domain.ContentBlock{
+				Type:       "tool_result",
+				ToolUseID:  id,
+				ToolName:   names[id],
+				ToolResult: interruptedResult,
+				IsError:    true,
+			})
+		}
+		fix = append(fix, domain.TranscriptMessage{Role: "user", Blocks: results})
+	}
+	fix = append(fix, domain.TranscriptMessage{Role: "assistant", Content: interruptedMarker})
+
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		if m.Role != "user" {
+			continue
+		}
+		if _, isResults := collectToolResultIDs(m.Blocks); !isResults {
+			prompt = m.TextContent()
+			break
+		}
+	}
+	return fix, prompt
+}
+
 func repairDanglingToolUseMessages(msgs []domain.TranscriptMessage) ([]domain.TranscriptMessage, bool) {
 	out := make([]domain.TranscriptMessage, 0, len(msgs))
 	changed := false
@@ -39,14 +39,15 @@ func (a *Service) callProviderWithRetry(
 		prov := a.prov
 		apiKey := a.apiKey
 		modelID := a.modelID
+		sampling := a.sampling
 		a.mu.Unlock()
 
 		if prov == nil {
 			return nil, "", provider.Usage{}, fmt.Errorf("no provider configured; use /config set model <provider>/<model>")
 		}
-		blocks, stopReason, usage, err = prov.StreamMessage(
-			apiKey, modelID, messages, toolSpecs, system, onDelta,
-		)
+		blocks, stopReason, usage, err = a.streamStoppable(func(onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error) {
+			return provider.StreamWithSampling(prov, sampling, apiKey, modelID, messages, toolSpecs, system, onDelta)
+		}, onDelta)
 
 		if err == nil {
 			return blocks, stopReason, usage, nil
@@ -97,6 +98,9 @@ func (a *Service) callProviderWithRetry(
 		})
 
 		if !a.sleepWithCancel(retryWait) {
+			if a.stopRequested() {
+				return nil, StopReasonCancelled, provider.Usage{}, nil
+			}
 			return nil, "", provider.Usage{}, fmt.Errorf("canceled during retry wait")
 		}
 
@@ -131,14 +135,12 @@ func isStreamError(err error) bool {
 }
 
 // sleepWithCancel waits for the given duration, checking for cancellation
-// every 100ms. Returns true if the sleep completed, false if canceled.
+// every 100ms. Returns true if the sleep completed, false if canceled or
+// stopped.
 func (a *Service) sleepWithCancel(d time.Duration) bool {
 	deadline := time.Now().Add(d)
 	for time.Now().Before(deadline) {
-		a.mu.Lock()
-		canceled := a.canceled
-		a.mu.Unlock()
-		if canceled {
+		if a.interrupted() {
 			return false
 		}
 		remaining := time.Until(deadline)
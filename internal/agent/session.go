@@ -3,6 +3,7 @@ package agent
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/domain"
@@ -118,6 +119,12 @@ func (a *Service) Cancel() {
 	}
 }
 
+// Close stops the persistent shell. The Service stays usable; the next
+// bash call starts a new shell.
+func (a *Service) Close() {
+	a.shell.Close()
+}
+
 // Resume loads messages from the database for the current session.
 // If a compaction record exists, it loads the summary as synthetic messages
 // plus the tail messages after the cutoff point.
@@ -148,6 +155,7 @@ func (a *Service) Resume() error {
 			domain.TranscriptMessage{Role: "assistant", Content: "Understood. I'll continue with the context available."},
 		)
 		msgs = append(msgs, tail...)
+		msgs = a.validateLoaded(msgs)
 		a.mu.Lock()
 		a.messages = msgs
 		a.titled = true
@@ -160,6 +168,7 @@ func (a *Service) Resume() error {
 	if err != nil {
 		return fmt.Errorf("loading messages: %w", err)
 	}
+	msgs = a.validateLoaded(msgs)
 	a.mu.Lock()
 	a.messages = msgs
 	a.titled = len(msgs) > 0
@@ -167,6 +176,16 @@ func (a *Service) Resume() error {
 	return nil
 }
 
+// validateLoaded repairs a transcript loaded from the store, logging what
+// it changed. The stored messages are left as they are.
+func (a *Service) validateLoaded(msgs []domain.TranscriptMessage) []domain.TranscriptMessage {
+	repaired, fixes := ValidateTranscript(msgs)
+	for _, fix := range fixes {
+		a.logf("agent: session %s: %s", a.session.ID, fix)
+	}
+	return repaired
+}
+
 // SetModel changes the active model.
 func (a *Service) SetModel(label, id string) {
 	a.mu.Lock()
@@ -222,9 +241,10 @@ func (a *Service) NewSession(projectPath string) error {
 	a.titled = false
 	a.userRenamed = false
 	a.agentLoopCount = 0
-	a.checkpoints = nil
-	a.redoStack = nil
+	a.approvedTools = nil
 	a.mu.Unlock()
+	a.shell.Reset()
+	a.sources.reset()
 	return nil
 }
 
@@ -293,6 +313,65 @@ func (a *Service) ConsultModel() string {
 	return a.modelConsult
 }
 
+// SetStyle sets the response language and tone preset for this session.
+func (a *Service) SetStyle(language, tone string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.styleLanguage = language
+	a.styleTone = tone
+}
+
+// Style returns the session's response language and tone preset.
+func (a *Service) Style() (language, tone string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.styleLanguage, a.styleTone
+}
+
+// SetSampling sets the sampling parameters for this session's turns.
+// Titles, compaction, and other side calls keep the provider defaults.
+func (a *Service) SetSampling(s provider.Sampling) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sampling = s
+}
+
+// ProviderName returns the name of the session's provider, or "" when none
+// is set.
+func (a *Service) ProviderName() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.prov == nil {
+		return ""
+	}
+	return a.prov.Name()
+}
+
+// Sampling returns the session's sampling parameters.
+func (a *Service) Sampling() provider.Sampling {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sampling
+}
+
+// SetPlanMode turns user-controlled plan mode on or off. While on, write
+// tools are disabled, the model is asked for a reviewable plan, and
+// plan_exit is refused until the user turns it off.
+func (a *Service) SetPlanMode(on bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.planMode = on
+	a.planLocked = on
+}
+
+// PlanMode reports whether write tools are disabled, either by the user or
+// by the model calling plan_enter.
+func (a *Service) PlanMode() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.planMode
+}
+
 // SetPreferences stores the full preferences for API key resolution.
 func (a *Service) SetPreferences(prefs config.Preferences) {
 	a.mu.Lock()
@@ -328,6 +407,14 @@ func (a *Service) SetPushHubMemory(fn func(facts map[string]string) error) {
 	a.pushHubMemory = fn
 }
 
+// SetDaySpendFunc sets where budget.daily_usd reads the day's spend, for a
+// daemon whose sessions are spread over several databases.
+func (a *Service) SetDaySpendFunc(fn func(at time.Time) (float64, error)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.daySpend = fn
+}
+
 // SetHubDiscovery sets the callback for querying hub nodes.
 func (a *Service) SetHubDiscovery(fn func() ([]tools.HubNodeInfo, error)) {
 	a.mu.Lock()
@@ -362,45 +449,49 @@ func (a *Service) SetGitAvailable(available bool, repoRoot string) {
 	a.gitRepoRoot = repoRoot
 }
 
-// IsRunning reports whether a Submit is currently in progress.
+// IsRunning reports whether a Submit or Compact is currently in progress.
 func (a *Service) IsRunning() bool {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	return a.running
+	return a.running || a.compacting
 }
 
 // SpawnSubAgent creates a sub-agent Service, runs a prompt to completion, and
 // returns the concatenated text output. The sub-agent has no store (no
 // persistence), no git checkpoints, and cannot use the task tool.
 func (a *Service) SpawnSubAgent(description, prompt string) (string, error) {
-	a.mu.Lock()
-	disabled := make(map[string]bool, len(a.disabledTools))
-	for k, v := range a.disabledTools {
-		disabled[k] = v
-	}
-	mcpMgr := a.mcpManager
-	a.mu.Unlock()
+	return a.spawnSubAgent(description, prompt, nil)
+}
 
-	sub := &Service{
-		apiKey:        a.apiKey,
-		modelID:       a.modelID,
-		prov:          a.prov,
-		isSubAgent:    true,
-		Cwd:           a.Cwd,
-		disabledTools: disabled,
-		mcpManager:    mcpMgr,
-		memory:        a.memory,
-	}
+// spawnSubAgent runs a sub-agent under the parent's approval settings.
+// Approval requests are forwarded to parentEvent; without one they are
+// denied, since nobody could answer them.
+func (a *Service) spawnSubAgent(description, prompt string, parentEvent EventFunc) (string, error) {
+	sub := a.newSubAgent()
+	defer sub.Close()
 
 	var output strings.Builder
 	var subErr error
 
+	stop := a.propagateCancel(sub)
+	defer stop()
+
 	sub.Submit(prompt, func(evt Event) {
 		switch evt.Kind {
 		case EventDelta:
 			_, _ = output.WriteString(evt.DeltaText) // strings.Builder.Write never fails
 		case EventError:
 			subErr = evt.Err
+		case EventBudgetWarning:
+			if parentEvent != nil {
+				parentEvent(evt)
+			}
+		case EventApprovalRequired:
+			if parentEvent != nil {
+				parentEvent(evt)
+			} else {
+				evt.ApprovalResponse <- ApprovalDeny
+			}
 		}
 	})
 
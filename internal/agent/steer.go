@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Steering
+// ---------------------------------------------------------------------------
+
+// ErrNotRunning is returned by Steer when no turn is in progress.
+var ErrNotRunning = errors.New("no turn is running")
+
+// maxSteerLen caps one steering note; it is meant to be a short nudge.
+const maxSteerLen = 2000
+
+// Steer queues a note from the user for the running turn. It is added to
+// the conversation before the next model call, after any tool calls in
+// flight finish, so the user can redirect the agent without cancelling.
+func (a *Service) Steer(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("empty steering note")
+	}
+	if len(text) > maxSteerLen {
+		return errors.New("steering note too long; keep it under 2000 characters")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running {
+		return ErrNotRunning
+	}
+	a.steering = append(a.steering, text)
+	return nil
+}
+
+// takeSteering returns and clears the queued steering notes.
+func (a *Service) takeSteering() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	notes := a.steering
+	a.steering = nil
+	return notes
+}
+
+// steeringBlock wraps notes in a text block that tells the model they came
+// from the user mid-turn, not from a tool.
+func steeringBlock(notes []string) domain.ContentBlock {
+	var b strings.Builder
+	b.WriteString("[The user sent this while you were working. Take it into account from now on.]\n")
+	b.WriteString(strings.Join(notes, "\n"))
+	return domain.ContentBlock{Type: "text", Text: b.String()}
+}
+
+// appendSteering adds a message holding only steering notes and persists
+// it, for notes that arrive after the model's last tool call.
+func (a *Service) appendSteering(msg domain.TranscriptMessage, notes []string, onEvent EventFunc) {
+	a.mu.Lock()
+	a.messages = append(a.messages, msg)
+	a.mu.Unlock()
+	a.persist("steering", turnMessage(msg, 0))
+	onEvent(Event{Kind: EventSteered, Steering: notes})
+}
@@ -0,0 +1,101 @@
+package agent
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// writeTextReply streams a text reply that ends the turn.
+func writeTextReply(w http.ResponseWriter, text string) {
+	writeSSE(w, "message_start", map[string]any{
+		"message": map[string]any{"usage": map[string]any{"input_tokens": 10, "output_tokens": 0}},
+	})
+	writeSSE(w, "content_block_start", map[string]any{"index": 0, "content_block": map[string]any{"type": "text"}})
+	writeSSE(w, "content_block_delta", map[string]any{"index": 0, "delta": map[string]any{"type": "text_delta", "text": text}})
+	writeSSE(w, "content_block_stop", map[string]any{"index": 0})
+	writeSSE(w, "message_delta", map[string]any{"usage": map[string]any{"output_tokens": 5}, "delta": map[string]any{"stop_reason": "end_turn"}})
+}
+
+// writeToolUse streams a list_files call.
+func writeToolUse(w http.ResponseWriter) {
+	writeSSE(w, "message_start", map[string]any{
+		"message": map[string]any{"usage": map[string]any{"input_tokens": 10, "output_tokens": 0}},
+	})
+	writeSSE(w, "content_block_start", map[string]any{"index": 0, "content_block": map[string]any{"type": "tool_use", "id": "tu_1", "name": "list_files"}})
+	writeSSE(w, "content_block_delta", map[string]any{"index": 0, "delta": map[string]any{"type": "input_json_delta", "partial_json": `{"path":"."}`}})
+	writeSSE(w, "content_block_stop", map[string]any{"index": 0})
+	writeSSE(w, "message_delta", map[string]any{"usage": map[string]any{"output_tokens": 5}, "delta": map[string]any{"stop_reason": "tool_use"}})
+}
+
+func TestService_Steer(t *testing.T) {
+	tests := []struct {
+		name  string
+		first func(http.ResponseWriter)
+	}{
+		{"before the next tool round", writeToolUse},
+		{"after the final answer", func(w http.ResponseWriter) { writeTextReply(w, "Done.") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newMockStore()
+			sess := &domain.Session{ID: domain.NewUUID(), Title: "test", Model: "fake"}
+			store.addSession(sess)
+			var svc *Service
+			var mu sync.Mutex
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				bodies = append(bodies, string(body))
+				n := len(bodies)
+				mu.Unlock()
+				w.Header().Set("Content-Type", "text/event-stream")
+				if n == 1 {
+					if err := svc.Steer("  leave the tests alone "); err != nil {
+						t.Errorf("Steer: %v", err)
+					}
+					tt.first(w)
+					return
+				}
+				writeTextReply(w, "Understood.")
+			}))
+			defer server.Close()
+			origURL := provider.TestAPIURL
+			provider.TestAPIURL = server.URL
+			defer func() { provider.TestAPIURL = origURL }()
+
+			svc = NewService("fake-key", "fake", "fake", store, sess, &testAnthropicProvider{})
+			svc.Cwd = "."
+			var steered []string
+			svc.Submit("fix the parser", func(evt Event) {
+				switch evt.Kind {
+				case EventSteered:
+					steered = append(steered, evt.Steering...)
+				case EventError:
+					t.Errorf("unexpected error: %v", evt.Err)
+				}
+			})
+
+			if len(bodies) != 2 {
+				t.Fatalf("expected 2 model calls, got %d", len(bodies))
+			}
+			if strings.Contains(bodies[0], "leave the tests alone") || !strings.Contains(bodies[1], "leave the tests alone") {
+				t.Error("steering note should reach only the call after it was sent")
+			}
+			if len(steered) != 1 || steered[0] != "leave the tests alone" {
+				t.Errorf("EventSteered notes = %q", steered)
+			}
+			if err := svc.Steer("too late"); !errors.Is(err, ErrNotRunning) {
+				t.Errorf("Steer after the turn = %v, want ErrNotRunning", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// ---------------------------------------------------------------------------
+// Graceful stop
+// ---------------------------------------------------------------------------
+
+// StopReasonCancelled is the stop reason of a turn ended by Stop.
+const StopReasonCancelled = "cancelled"
+
+// cancelMarker ends the transcript of a stopped turn so a resumed session
+// knows the work was cut short, not finished.
+const cancelMarker = "[Turn cancelled by user. The work above was kept; nothing after it happened.]"
+
+// notRunResult is the tool result of a call skipped by Stop.
+const notRunResult = "Not run: the user cancelled the turn before this call started."
+
+// Stop ends the running turn at the next safe point, unlike Cancel, which
+// aborts it. A model reply being streamed is cut off and its text so far
+// kept. Tool calls already running finish, and calls not yet started are
+// recorded as not run. The turn then ends with a cancellation marker, and
+// EventTurnDone reports StopReasonCancelled.
+func (a *Service) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.running || a.stopping {
+		return
+	}
+	a.stopping = true
+	close(a.stopCh)
+}
+
+// stopRequested reports whether Stop was called during this turn.
+func (a *Service) stopRequested() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stopping
+}
+
+// interrupted reports whether the turn was cancelled or asked to stop, for
+// waits that should end either way.
+func (a *Service) interrupted() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.canceled || a.stopping
+}
+
+// skippedToolResult is the result recorded for a call Stop kept from running.
+func skippedToolResult(b domain.ContentBlock) domain.ContentBlock {
+	return domain.ContentBlock{
+		Type:       "tool_result",
+		ToolUseID:  b.ToolUseID,
+		ToolName:   b.ToolName,
+		ToolResult: notRunResult,
+		IsError:    true,
+	}
+}
+
+// streamStoppable runs call, returning early with the text streamed so far
+// when Stop is called. The abandoned request runs to completion in the
+// background with its output discarded; its usage is not recorded.
+func (a *Service) streamStoppable(
+	call func(onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error),
+	onDelta func(string),
+) ([]domain.ContentBlock, string, provider.Usage, error) {
+	a.mu.Lock()
+	stop := a.stopCh
+	a.mu.Unlock()
+	if stop == nil {
+		return call(onDelta)
+	}
+
+	var mu sync.Mutex
+	var partial strings.Builder
+	detached := false
+	forward := func(delta string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if detached {
+			return
+		}
+		partial.WriteString(delta)
+		onDelta(delta)
+	}
+	type result struct {
+		blocks     []domain.ContentBlock
+		stopReason string
+		usage      provider.Usage
+		err        error
+	}
+	done := make(chan result, 1)
+	go func() {
+		blocks, stopReason, usage, err := call(forward)
+		done <- result{blocks, stopReason, usage, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.blocks, r.stopReason, r.usage, r.err
+	case <-stop:
+		mu.Lock()
+		detached = true
+		text := partial.String()
+		mu.Unlock()
+		var blocks []domain.ContentBlock
+		if strings.TrimSpace(text) != "" {
+			blocks = []domain.ContentBlock{{Type: "text", Text: text}}
+		}
+		return blocks, StopReasonCancelled, provider.Usage{}, nil
+	}
+}
+
+// finishStopped ends a stopped turn: the partial reply, if any, is kept
+// with the cancellation marker after it, and the turn is reported done.
+func (a *Service) finishStopped(partial []domain.ContentBlock, onEvent EventFunc) {
+	if len(partial) > 0 {
+		onEvent(Event{Kind: EventStreamDone, Blocks: partial, StopReason: StopReasonCancelled})
+	}
+	text := cancelMarker
+	if len(partial) > 0 {
+		text = strings.TrimRight(partial[0].Text, "\n") + "\n\n" + cancelMarker
+	}
+	msg := domain.TranscriptMessage{Role: "assistant", Content: text}
+	a.mu.Lock()
+	a.messages = append(a.messages, msg)
+	a.mu.Unlock()
+	a.persist("cancellation", turnMessage(msg, 0))
+	onEvent(Event{Kind: EventTurnDone, StopReason: StopReasonCancelled})
+}
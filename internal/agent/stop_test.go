@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// newStopTestService points a service at handler and returns it with its
+// store and session.
+func newStopTestService(t *testing.T, handler http.HandlerFunc) (*Service, *mockStore, *domain.Session) {
+	t.Helper()
+	store := newMockStore()
+	sess := &domain.Session{ID: domain.NewUUID(), Title: "test", Model: "fake"}
+	store.addSession(sess)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	origURL := provider.TestAPIURL
+	provider.TestAPIURL = server.URL
+	t.Cleanup(func() { provider.TestAPIURL = origURL })
+	svc := NewService("fake-key", "fake", "fake", store, sess, &testAnthropicProvider{})
+	svc.Cwd = "."
+	return svc, store, sess
+}
+
+func TestService_Stop_keepsPartialReply(t *testing.T) {
+	release := make(chan struct{})
+	svc, store, sess := newStopTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeSSE(w, "message_start", map[string]any{
+			"message": map[string]any{"usage": map[string]any{"input_tokens": 10, "output_tokens": 0}},
+		})
+		writeSSE(w, "content_block_start", map[string]any{"index": 0, "content_block": map[string]any{"type": "text"}})
+		writeSSE(w, "content_block_delta", map[string]any{"index": 0, "delta": map[string]any{"type": "text_delta", "text": "Half an answer"}})
+		w.(http.Flusher).Flush()
+		<-release // the rest never arrives before Stop
+		writeSSE(w, "content_block_stop", map[string]any{"index": 0})
+		writeSSE(w, "message_delta", map[string]any{"usage": map[string]any{"output_tokens": 5}, "delta": map[string]any{"stop_reason": "end_turn"}})
+	})
+	defer close(release)
+
+	var once sync.Once
+	var turnDone string
+	svc.Submit("explain", func(evt Event) {
+		switch evt.Kind {
+		case EventDelta:
+			once.Do(func() { go svc.Stop() })
+		case EventTurnDone:
+			turnDone = evt.StopReason
+		}
+	})
+
+	if turnDone != StopReasonCancelled {
+		t.Errorf("turn done reason = %q", turnDone)
+	}
+	msgs := svc.Messages()
+	last := msgs[len(msgs)-1]
+	if last.Role != "assistant" || !strings.HasPrefix(last.Content, "Half an answer") || !strings.HasSuffix(last.Content, cancelMarker) {
+		t.Errorf("last message = %+v", last)
+	}
+	stored, _ := store.GetMessages(sess.ID)
+	if got := stored[len(stored)-1].Content; got != last.Content {
+		t.Errorf("persisted %q, want %q", got, last.Content)
+	}
+}
+
+func TestService_Stop_finishesRunningTool(t *testing.T) {
+	calls := 0
+	svc, _, _ := newStopTestService(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeSSE(w, "message_start", map[string]any{
+			"message": map[string]any{"usage": map[string]any{"input_tokens": 10, "output_tokens": 0}},
+		})
+		for i, id := range []string{"tu_1", "tu_2"} {
+			writeSSE(w, "content_block_start", map[string]any{"index": i, "content_block": map[string]any{"type": "tool_use", "id": id, "name": "list_files"}})
+			writeSSE(w, "content_block_delta", map[string]any{"index": i, "delta": map[string]any{"type": "input_json_delta", "partial_json": `{"path":"."}`}})
+			writeSSE(w, "content_block_stop", map[string]any{"index": i})
+		}
+		writeSSE(w, "message_delta", map[string]any{"usage": map[string]any{"output_tokens": 5}, "delta": map[string]any{"stop_reason": "tool_use"}})
+	})
+	svc.SetPreferences(config.Preferences{ToolsParallelism: "1"})
+
+	var started []string
+	svc.Submit("look around", func(evt Event) {
+		if evt.Kind == EventToolStart {
+			started = append(started, evt.ToolUseID)
+			svc.Stop()
+		}
+	})
+
+	if calls != 1 || len(started) != 1 {
+		t.Fatalf("model calls = %d, tools started = %v", calls, started)
+	}
+	msgs := svc.Messages()
+	if n := len(msgs); n != 4 || msgs[3].Content != cancelMarker {
+		t.Fatalf("messages = %+v", msgs)
+	}
+	results := msgs[2].Blocks
+	if len(results) != 2 || results[0].IsError || results[1].ToolResult != notRunResult {
+		t.Errorf("tool results = %+v", results)
+	}
+}
+
+func TestService_Stop_idle(t *testing.T) {
+	svc := NewService("", "", "", nil, nil, nil)
+	svc.Stop() // no turn: nothing to do, and no panic
+	if svc.stopRequested() {
+		t.Error("Stop with no turn running should not mark the next turn")
+	}
+}
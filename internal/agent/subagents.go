@@ -0,0 +1,314 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// ---------------------------------------------------------------------------
+// spawn_agent workers
+// ---------------------------------------------------------------------------
+
+// Sub-agent states reported in SubAgentStatus and spawn_agent results.
+const (
+	SubAgentRunning = "running"
+	SubAgentDone    = "done"
+	SubAgentStopped = "stopped" // hit its turn or token limit
+	SubAgentFailed  = "failed"
+)
+
+// maxParallelSubAgents caps how many spawn_agent workers call the model at
+// once; the rest wait for a slot.
+const maxParallelSubAgents = 4
+
+// subAgentNoUser answers ask_user for workers, which have no user to ask.
+const subAgentNoUser = "No user is available to sub-agents. Proceed with your best judgement and state any assumptions in your result."
+
+// SubAgentStatus is a progress snapshot of one spawn_agent worker.
+type SubAgentStatus struct {
+	ID        string // the worker's session ID
+	ParentID  string // session that spawned the worker
+	Name      string
+	State     string // SubAgentRunning, SubAgentDone, SubAgentStopped or SubAgentFailed
+	Tool      string // tool the worker is running, if any
+	Turns     int
+	Tokens    int
+	MaxTurns  int
+	MaxTokens int
+	Err       string
+}
+
+// limitError is returned when a worker reaches its turn or token limit.
+type limitError struct{ msg string }
+
+func (e *limitError) Error() string { return e.msg }
+
+// checkSubAgentLimits stops a spawn_agent worker before a model call that
+// would exceed its limits.
+func (a *Service) checkSubAgentLimits(loopCount int) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.maxTurns > 0 && loopCount > a.maxTurns {
+		return &limitError{fmt.Sprintf("sub-agent turn limit reached (%d turns)", a.maxTurns)}
+	}
+	if used := a.inputTokens + a.outputTokens; a.maxTokens > 0 && used >= a.maxTokens {
+		return &limitError{fmt.Sprintf("sub-agent token limit reached (%d of %d tokens)", used, a.maxTokens)}
+	}
+	return nil
+}
+
+// restrictToolSpecs drops tools outside a worker's allow-list and marks them
+// disabled so calls to them are refused as well.
+func (a *Service) restrictToolSpecs(specs []provider.ToolSpec, disabled map[string]bool) []provider.ToolSpec {
+	a.mu.Lock()
+	allowed := a.allowedTools
+	a.mu.Unlock()
+	if allowed == nil {
+		return specs
+	}
+	kept := specs[:0]
+	for _, s := range specs {
+		if allowed[s.Name] {
+			kept = append(kept, s)
+		} else {
+			disabled[s.Name] = true
+		}
+	}
+	return kept
+}
+
+// newSubAgent returns a store-less sub-agent that shares the parent's
+// provider, model, tool settings and memory.
+func (a *Service) newSubAgent() *Service {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	disabled := make(map[string]bool, len(a.disabledTools))
+	for k, v := range a.disabledTools {
+		disabled[k] = v
+	}
+	approved := make(map[string]bool, len(a.approvedTools))
+	for k, v := range a.approvedTools {
+		approved[k] = v
+	}
+	return &Service{
+		apiKey:        a.apiKey,
+		modelID:       a.modelID,
+		prov:          a.prov,
+		isSubAgent:    true,
+		parent:        a,
+		Cwd:           a.Cwd,
+		disabledTools: disabled,
+		approvedTools: approved,
+		prefs:         a.prefs,
+		mcpManager:    a.mcpManager,
+		memory:        a.memory,
+	}
+}
+
+// propagateCancel cancels sub when a is canceled, so a sub-agent waiting on
+// a forwarded approval stops with its parent. The returned func stops
+// watching.
+func (a *Service) propagateCancel(sub *Service) func() {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				a.mu.Lock()
+				canceled := a.canceled
+				a.mu.Unlock()
+				if canceled {
+					sub.Cancel()
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// spawnSubAgents runs spawn_agent workers in parallel, at most
+// maxParallelSubAgents at a time, and returns their results in spec order.
+// Each worker gets its own session, linked to the parent's when sessions are
+// stored, and reports progress as EventSubAgent.
+func (a *Service) spawnSubAgents(specs []tools.SubAgentSpec, parentEvent EventFunc) []tools.SubAgentResult {
+	// Worker events arrive from several goroutines; adapters expect them
+	// one at a time, and approvals one at a time on top of that.
+	var emitMu, approvalMu sync.Mutex
+	emit := func(evt Event) {
+		if parentEvent == nil {
+			return
+		}
+		emitMu.Lock()
+		defer emitMu.Unlock()
+		parentEvent(evt)
+	}
+	forwardApproval := func(sub *Service, evt Event) {
+		approvalMu.Lock()
+		defer approvalMu.Unlock()
+		if parentEvent == nil {
+			evt.ApprovalResponse <- ApprovalDeny
+			return
+		}
+		proxy := make(chan ApprovalDecision, 1)
+		reply := evt.ApprovalResponse
+		evt.ApprovalResponse = proxy
+		emit(evt)
+		for {
+			select {
+			case decision := <-proxy:
+				reply <- decision
+				return
+			case <-time.After(100 * time.Millisecond):
+				sub.mu.Lock()
+				canceled := sub.canceled
+				sub.mu.Unlock()
+				if canceled {
+					return
+				}
+			}
+		}
+	}
+
+	results := make([]tools.SubAgentResult, len(specs))
+	slots := make(chan struct{}, maxParallelSubAgents)
+	var wg sync.WaitGroup
+	for i, spec := range specs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = a.runSubAgent(spec, emit, forwardApproval)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// runSubAgent runs one spawn_agent worker to completion.
+func (a *Service) runSubAgent(spec tools.SubAgentSpec, emit EventFunc, forwardApproval func(*Service, Event)) tools.SubAgentResult {
+	sub := a.newSubAgent()
+	defer sub.Close()
+	sub.maxTurns = min(spec.MaxTurns, LoopLimit)
+	sub.maxTokens = spec.MaxTokens
+	if len(spec.Tools) > 0 {
+		sub.allowedTools = make(map[string]bool, len(spec.Tools))
+		for _, name := range spec.Tools {
+			sub.allowedTools[name] = true
+		}
+	}
+
+	status := SubAgentStatus{
+		ID:        domain.NewUUID(),
+		Name:      spec.Name,
+		State:     SubAgentRunning,
+		MaxTurns:  sub.maxTurns,
+		MaxTokens: sub.maxTokens,
+	}
+	a.mu.Lock()
+	st, parentSession := a.store, a.session
+	a.mu.Unlock()
+	if st != nil && parentSession != nil {
+		status.ParentID = parentSession.ID
+		sess, err := st.CreateSession(parentSession.ProjectPath, sub.modelID)
+		if err != nil {
+			a.logf("agent: create sub-agent session: %v", err)
+		} else {
+			status.ID = sess.ID
+			if err := st.UpdateSessionTitle(sess.ID, "Sub-agent: "+spec.Name); err != nil {
+				a.logf("agent: title sub-agent session: %v", err)
+			}
+			if err := st.UpdateSessionTags(sess.ID, "subagent"); err != nil {
+				a.logf("agent: tag sub-agent session: %v", err)
+			}
+			if ps, ok := st.(SessionParentStore); ok {
+				if err := ps.SetSessionParent(sess.ID, parentSession.ID); err != nil {
+					a.logf("agent: link sub-agent session: %v", err)
+				}
+			}
+			sub.store = st
+			sub.session = sess
+			sub.titled = true
+		}
+	}
+	report := func() {
+		s := status
+		emit(Event{Kind: EventSubAgent, SubAgent: &s})
+	}
+	report()
+
+	stop := a.propagateCancel(sub)
+	defer stop()
+
+	var output strings.Builder
+	var subErr error
+	sub.Submit(spec.Prompt, func(evt Event) {
+		switch evt.Kind {
+		case EventDelta:
+			_, _ = output.WriteString(evt.DeltaText) // strings.Builder.Write never fails
+		case EventStreamDone:
+			sub.mu.Lock()
+			status.Tokens = sub.inputTokens + sub.outputTokens
+			sub.mu.Unlock()
+			status.Turns++
+			status.Tool = ""
+			report()
+		case EventToolStart:
+			status.Tool = evt.ToolName
+			report()
+		case EventError:
+			subErr = evt.Err
+		case EventBudgetWarning:
+			emit(evt)
+		case EventAskUser:
+			evt.AskResponse <- subAgentNoUser
+		case EventApprovalRequired:
+			forwardApproval(sub, evt)
+		}
+	})
+
+	status.Tool = ""
+	var limit *limitError
+	switch {
+	case subErr == nil:
+		status.State = SubAgentDone
+		sub.mu.Lock()
+		if sub.canceled {
+			status.State = SubAgentStopped
+			status.Err = "canceled"
+		}
+		sub.mu.Unlock()
+	case errors.As(subErr, &limit):
+		status.State = SubAgentStopped
+		status.Err = subErr.Error()
+	default:
+		status.State = SubAgentFailed
+		status.Err = subErr.Error()
+	}
+	report()
+
+	result := tools.SubAgentResult{
+		Name:   status.Name,
+		State:  status.State,
+		Output: output.String(),
+		Turns:  status.Turns,
+		Tokens: status.Tokens,
+		Err:    status.Err,
+	}
+	if sub.session != nil {
+		result.SessionID = sub.session.ID
+	}
+	return result
+}
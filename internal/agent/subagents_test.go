@@ -0,0 +1,150 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// echoProvider answers with the last user message.
+type echoProvider struct{}
+
+func (p *echoProvider) Name() string { return "test" }
+func (p *echoProvider) StreamMessage(apiKey, modelID string, msgs []domain.TranscriptMessage, specs []provider.ToolSpec, system string, onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error) {
+	text := "echo: " + msgs[len(msgs)-1].Content
+	onDelta(text)
+	return []domain.ContentBlock{{Type: "text", Text: text}}, "end_turn", provider.Usage{InputTokens: 10, OutputTokens: 5}, nil
+}
+func (p *echoProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
+	return nil, nil
+}
+
+// bashLoopProvider asks for bash on every call and records the offered tools.
+type bashLoopProvider struct {
+	mu    sync.Mutex
+	tools []string
+}
+
+func (p *bashLoopProvider) Name() string { return "test" }
+func (p *bashLoopProvider) StreamMessage(apiKey, modelID string, msgs []domain.TranscriptMessage, specs []provider.ToolSpec, system string, onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error) {
+	p.mu.Lock()
+	p.tools = p.tools[:0]
+	for _, s := range specs {
+		p.tools = append(p.tools, s.Name)
+	}
+	p.mu.Unlock()
+	return []domain.ContentBlock{{
+		Type:      "tool_use",
+		ToolUseID: domain.NewUUID(),
+		ToolName:  "bash",
+		ToolInput: map[string]any{"command": "echo hi"},
+	}}, "tool_use", provider.Usage{InputTokens: 60, OutputTokens: 40}, nil
+}
+func (p *bashLoopProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
+	return nil, nil
+}
+
+// parentMockStore records the sessions linked by SetSessionParent.
+type parentMockStore struct {
+	*mockStore
+}
+
+func (s *parentMockStore) SetSessionParent(sessionID, parentID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[sessionID].ParentSessionID = parentID
+	return nil
+}
+
+func TestService_spawnSubAgents(t *testing.T) {
+	st := &parentMockStore{newMockStore()}
+	parent := &domain.Session{ID: domain.NewUUID(), ProjectPath: "/proj"}
+	st.addSession(parent)
+	svc := NewService("key", "m", "label", st, parent, &echoProvider{})
+	svc.Cwd = t.TempDir()
+
+	var mu sync.Mutex
+	var events []SubAgentStatus
+	results := svc.spawnSubAgents([]tools.SubAgentSpec{
+		{Name: "one", Prompt: "first", MaxTurns: 5, MaxTokens: 1000},
+		{Name: "two", Prompt: "second", MaxTurns: 5, MaxTokens: 1000},
+	}, func(evt Event) {
+		if evt.Kind == EventSubAgent {
+			mu.Lock()
+			events = append(events, *evt.SubAgent)
+			mu.Unlock()
+		}
+	})
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, want := range []string{"first", "second"} {
+		r := results[i]
+		if r.State != SubAgentDone || r.Output != "echo: "+want || r.Turns != 1 || r.Tokens != 15 {
+			t.Errorf("result %d = %+v", i, r)
+		}
+		sess := st.sessions[r.SessionID]
+		if sess == nil {
+			t.Fatalf("result %d: no session %q", i, r.SessionID)
+		}
+		if sess.ParentSessionID != parent.ID || sess.Tags != "subagent" || !strings.HasPrefix(sess.Title, "Sub-agent: ") {
+			t.Errorf("result %d session = %+v", i, sess)
+		}
+		if msgs := st.messages[r.SessionID]; len(msgs) != 2 {
+			t.Errorf("result %d: %d stored messages, want 2", i, len(msgs))
+		}
+	}
+
+	last := map[string]SubAgentStatus{}
+	for _, e := range events {
+		if _, seen := last[e.ID]; !seen && e.State != SubAgentRunning {
+			t.Errorf("first event for %s is %q, want running", e.Name, e.State)
+		}
+		last[e.ID] = e
+	}
+	if len(last) != 2 {
+		t.Fatalf("events for %d workers, want 2", len(last))
+	}
+	for _, e := range last {
+		if e.State != SubAgentDone || e.ParentID != parent.ID {
+			t.Errorf("final status = %+v", e)
+		}
+	}
+}
+
+func TestService_spawnSubAgents_limits(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      tools.SubAgentSpec
+		wantTurns int
+		wantErr   string
+	}{
+		{"turn limit", tools.SubAgentSpec{Name: "w", Prompt: "go", MaxTurns: 2, MaxTokens: 10_000}, 2, "turn limit"},
+		{"token limit", tools.SubAgentSpec{Name: "w", Prompt: "go", MaxTurns: 10, MaxTokens: 150}, 2, "token limit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prov := &bashLoopProvider{}
+			svc := NewService("key", "m", "label", nil, nil, prov)
+			svc.Cwd = t.TempDir()
+
+			tt.spec.Tools = []string{"file_read"}
+			results := svc.spawnSubAgents([]tools.SubAgentSpec{tt.spec}, nil)
+			r := results[0]
+			if r.State != SubAgentStopped || r.Turns != tt.wantTurns || !strings.Contains(r.Err, tt.wantErr) {
+				t.Errorf("result = %+v", r)
+			}
+			if r.SessionID != "" {
+				t.Errorf("SessionID = %q without a store", r.SessionID)
+			}
+			if strings.Join(prov.tools, ",") != "file_read" {
+				t.Errorf("offered tools = %v, want only file_read", prov.tools)
+			}
+		})
+	}
+}
@@ -3,13 +3,15 @@ package agent
 import (
 	"context"
 	"fmt"
-	"sync"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/batalabs/muxd/internal/checkpoint"
 	"github.com/batalabs/muxd/internal/domain"
 	"github.com/batalabs/muxd/internal/mcp"
 	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
@@ -31,24 +33,38 @@ func (a *Service) SubmitBlocks(blocks []domain.ContentBlock, onEvent EventFunc)
 // submitMessage is the shared implementation for Submit and SubmitBlocks.
 func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventFunc) {
 	a.mu.Lock()
-	if a.running {
+	if a.running || a.compacting {
 		a.mu.Unlock()
-		onEvent(Event{Kind: EventError, Err: fmt.Errorf("agent is already running")})
+		onEvent(errorEvent(fmt.Errorf("agent is already running")))
 		return
 	}
 	a.running = true
 	a.canceled = false
 	a.agentLoopCount = 0
+	a.turnID = domain.NewUUID()
+	a.steering = nil
+	a.stopping = false
+	a.stopCh = make(chan struct{})
 	ctx, cancelCtx := context.WithCancel(context.Background())
 	a.cancelFunc = cancelCtx
+	isSubAgent := a.isSubAgent
 	a.mu.Unlock()
 
+	// Record tool failures so a turn that ends in an error after repeated
+	// failures gets an automatic post-mortem.
+	failures := &turnFailures{}
+	onEvent = failures.observe(onEvent)
+
 	defer func() {
 		cancelCtx()
 		a.mu.Lock()
 		a.running = false
 		a.cancelFunc = nil
+		a.stopCh = nil
 		a.mu.Unlock()
+		if fs, turnErr := failures.diagnosable(); fs != nil && !isSubAgent {
+			go a.runPostmortem(userMsg.TextContent(), fs, turnErr)
+		}
 	}()
 
 	// 1. Append user message and persist
@@ -56,15 +72,18 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 	a.messages = append(a.messages, userMsg)
 	a.mu.Unlock()
 
+	a.persist("user message", turnMessage(userMsg, 0))
 	if a.store != nil && a.session != nil {
-		if len(userMsg.Blocks) > 0 {
-			if err := a.store.AppendMessageBlocks(a.session.ID, "user", userMsg.Blocks, 0); err != nil {
-				a.logf("agent: persist user message blocks: %v", err)
-			}
-		} else {
-			if err := a.store.AppendMessage(a.session.ID, "user", userMsg.Content, 0); err != nil {
-				a.logf("agent: persist user message: %v", err)
+		if ts, ok := a.store.(TurnStateStore); ok {
+			sessionID := a.session.ID
+			if err := ts.SetTurnPID(sessionID, os.Getpid()); err != nil {
+				a.logf("agent: record running turn: %v", err)
 			}
+			defer func() {
+				if err := ts.SetTurnPID(sessionID, 0); err != nil {
+					a.logf("agent: record finished turn: %v", err)
+				}
+			}()
 		}
 	}
 
@@ -79,6 +98,16 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 	}
 
 	// 3. Agent loop
+	toolRepairs := 0 // consecutive model calls answered with repair requests
+	var prefetch *tools.PrefetchCache
+	defer func() {
+		if prefetch == nil {
+			return
+		}
+		if cached, hits := prefetch.Stats(); hits > 0 {
+			a.logf("agent: prefetch served %d file reads (%d files cached)", hits, cached)
+		}
+	}()
 	for {
 		// Build ToolContext each iteration so hot-reloaded config
 		// (e.g. config changes mid-loop) is picked up.
@@ -87,6 +116,11 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 			a.mu.Unlock()
 			return
 		}
+		if a.stopping {
+			a.mu.Unlock()
+			a.finishStopped(nil, onEvent)
+			return
+		}
 		a.agentLoopCount++
 		loopCount := a.agentLoopCount
 		messages := make([]domain.TranscriptMessage, len(a.messages))
@@ -111,6 +145,7 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 			HubDiscovery:   a.hubDiscovery,
 			HubDispatch:    a.hubDispatch,
 			BraveAPIKey:    a.braveAPIKey,
+			Cite:           a.cite,
 			TextbeltAPIKey: a.textbeltAPIKey,
 			MCP:            mcpMgr,
 			CustomTools:    a.customTools,
@@ -151,22 +186,69 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 			}
 			toolCtx.CancelScheduledJob = schedStore.CancelScheduledToolJob
 			toolCtx.UpdateScheduledJob = schedStore.UpdateScheduledToolJob
+			if draftStore, ok := a.store.(DraftToolJobStore); ok {
+				drafts := a.prefs.DraftToolsSet()
+				toolCtx.DraftTools = drafts
+				toolCtx.ScheduleTool = func(toolName string, toolInput map[string]any, scheduledFor time.Time, recurrence string) (string, error) {
+					if tools.IsDraftJob(drafts, toolName) {
+						return draftStore.CreateDraftToolJob(toolName, toolInput, scheduledFor, recurrence)
+					}
+					return schedStore.CreateScheduledToolJob(toolName, toolInput, scheduledFor, recurrence)
+				}
+			}
 		}
 		if !a.isSubAgent {
-			toolCtx.SpawnAgent = a.SpawnSubAgent
+			toolCtx.SpawnAgent = func(description, prompt string) (string, error) {
+				return a.spawnSubAgent(description, prompt, onEvent)
+			}
+			toolCtx.SpawnAgents = func(specs []tools.SubAgentSpec) []tools.SubAgentResult {
+				return a.spawnSubAgents(specs, onEvent)
+			}
+		}
+		approvalMode := a.prefs.ApprovalMode()
+		parallelism := a.prefs.ToolParallelism()
+		if a.prefs.ToolsPrefetch && prefetch == nil {
+			prefetch = tools.NewPrefetchCache()
+		}
+		toolCtx.Prefetch = prefetch
+		toolCtx.PlanLocked = a.planLocked
+		toolCtx.OutboundGuardrail = a.prefs.OutboundGuardrail()
+		toolCtx.OutboundPII = a.prefs.OutboundPII()
+		toolCtx.TextbeltAccounts = a.prefs.TextbeltAccountKeys()
+		toolCtx.Shell = a.prefs.ShellBackend
+		toolCtx.ShellSession = &a.shell
+		toolCtx.AskUser = func(question string) (string, bool) { return a.askUser(question, nil, onEvent) }
+		if auditStore, ok := a.store.(AuditStore); ok && a.session != nil {
+			sessionID := a.session.ID
+			toolCtx.Audit = func(toolName, decision, detail string) {
+				a.logf("audit: session=%s tool=%s decision=%s %s", sessionID, toolName, decision, detail)
+				entry := store.AuditEntry{SessionID: sessionID, Tool: toolName, Decision: decision, Detail: detail}
+				if err := auditStore.RecordAudit(entry); err != nil {
+					a.logf("agent: record audit: %v", err)
+				}
+			}
 		}
 		if repaired, changed := repairDanglingToolUseMessages(messages); changed {
 			a.messages = make([]domain.TranscriptMessage, len(repaired))
 			copy(a.messages, repaired)
 			messages = repaired
 		}
+		styleLanguage, styleTone := a.styleLanguage, a.styleTone
+		planLocked := a.planLocked
+		verify := a.verify && !a.isSubAgent
 		a.mu.Unlock()
 
 		if loopCount > LoopLimit {
-			onEvent(Event{
-				Kind: EventError,
-				Err:  fmt.Errorf("agent loop limit exceeded (%d iterations)", LoopLimit),
-			})
+			onEvent(errorEvent(domain.WithErrorCode(domain.ErrLoopLimit,
+				fmt.Errorf("agent loop limit exceeded (%d iterations)", LoopLimit))))
+			return
+		}
+		if err := a.checkSubAgentLimits(loopCount); err != nil {
+			onEvent(errorEvent(err))
+			return
+		}
+		if err := a.checkBudget(time.Now()); err != nil {
+			onEvent(errorEvent(err))
 			return
 		}
 
@@ -176,63 +258,62 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 		var usage provider.Usage
 		var err error
 
-		var toolSpecs []provider.ToolSpec
-		if a.isSubAgent {
-			toolSpecs = tools.AllToolSpecsForSubAgent()
-		} else {
-			toolSpecs = tools.AllToolSpecsForModeWithDisabled(a.planMode, disabled)
-		}
-		// Append MCP tool specs (filtered by disabled set).
-		var mcpToolNames []string
-		if mcpMgr != nil {
-			for _, spec := range mcpMgr.ToolSpecs() {
-				if !disabled[spec.Name] {
-					toolSpecs = append(toolSpecs, spec)
-					mcpToolNames = append(mcpToolNames, spec.Name)
-				}
-			}
+		toolSpecs, mcpToolNames := a.requestTools(a.planMode, disabled, mcpMgr, toolCtx.CustomTools)
+		system := a.systemPrompt(cwd, mcpToolNames, toolCtx.Shell, styleLanguage, styleTone, planLocked)
+
+		// With verification on, the reply is held until it has been checked.
+		var held []string
+		onDelta := func(delta string) {
+			onEvent(Event{Kind: EventDelta, DeltaText: delta})
 		}
-		// Append custom tool specs (filtered by disabled set).
-		if toolCtx.CustomTools != nil {
-			for _, spec := range toolCtx.CustomTools.Specs() {
-				if !disabled[spec.Name] {
-					toolSpecs = append(toolSpecs, spec)
+		if verify {
+			onDelta = func(delta string) { held = append(held, delta) }
+		}
+		if prefetch != nil {
+			// Warm files the model names while it is still writing.
+			var scanner tools.PathScanner
+			emit := onDelta
+			onDelta = func(delta string) {
+				for _, p := range scanner.Feed(delta) {
+					prefetch.Warm(cwd, p)
 				}
+				emit(delta)
 			}
 		}
-		memoryText := ""
-		if a.memory != nil {
-			memoryText = a.memory.FormatForPrompt()
-		}
-		system := provider.BuildSystemPrompt(cwd, mcpToolNames, memoryText)
-
 		blocks, stopReason, usage, err = a.callProviderWithRetry(
-			messages, toolSpecs, system,
-			func(delta string) {
-				onEvent(Event{Kind: EventDelta, DeltaText: delta})
-			},
-			onEvent,
+			messages, toolSpecs, system, onDelta, onEvent,
 		)
 		if err != nil {
-			onEvent(Event{Kind: EventError, Err: err})
+			onEvent(errorEvent(err))
 			// Persist the error as an assistant message
 			a.mu.Lock()
 			errMsg := domain.TranscriptMessage{Role: "assistant", Content: "Error: " + err.Error()}
 			a.messages = append(a.messages, errMsg)
 			a.mu.Unlock()
-			if a.store != nil && a.session != nil {
-				if err := a.store.AppendMessage(a.session.ID, "assistant", errMsg.Content, 0); err != nil {
-					a.logf("agent: persist error message: %v", err)
-				}
+			a.persist("error message", turnMessage(errMsg, 0))
+			return
+		}
+		if stopReason == StopReasonCancelled {
+			for _, delta := range held {
+				onEvent(Event{Kind: EventDelta, DeltaText: delta})
 			}
+			a.finishStopped(blocks, onEvent)
 			return
 		}
 
 		// 3b. Update token counts and build assistant message
+		a.recordSpend(usage, time.Now(), onEvent)
+		// Providers that do not report usage get the estimate instead.
+		contextTokens := usage.PromptTokens()
+		if contextTokens == 0 {
+			contextTokens = provider.EstimatePromptTokens(system, toolSpecs, messages)
+		}
 		a.mu.Lock()
 		a.inputTokens += usage.InputTokens
 		a.outputTokens += usage.OutputTokens
-		a.lastInputTokens = usage.InputTokens
+		a.lastInputTokens = contextTokens
+		_, _, compactAt := compactThresholds(a.modelID)
+		contextWindow := provider.ContextWindow(a.modelID)
 
 		var asstMsg domain.TranscriptMessage
 		if len(blocks) > 0 {
@@ -244,17 +325,16 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 		a.messages = append(a.messages, asstMsg)
 		a.mu.Unlock()
 
-		// Persist assistant message
+		// Persist assistant message. One that calls tools is held back and
+		// written with their results, so a crash or cancel in between
+		// cannot leave a tool call without a result in the store.
+		var heldAsst []store.TurnMessage
+		if stopReason == "tool_use" {
+			heldAsst = append(heldAsst, turnMessage(asstMsg, usage.OutputTokens))
+		} else {
+			a.persist("assistant message", turnMessage(asstMsg, usage.OutputTokens))
+		}
 		if a.store != nil && a.session != nil {
-			if len(blocks) > 0 {
-				if err := a.store.AppendMessageBlocks(a.session.ID, "assistant", blocks, usage.OutputTokens); err != nil {
-					a.logf("agent: persist assistant blocks: %v", err)
-				}
-			} else {
-				if err := a.store.AppendMessage(a.session.ID, "assistant", asstMsg.Content, usage.OutputTokens); err != nil {
-					a.logf("agent: persist assistant message: %v", err)
-				}
-			}
 			if err := a.store.UpdateSessionTokens(a.session.ID, a.inputTokens, a.outputTokens); err != nil {
 				a.logf("agent: update session tokens: %v", err)
 			}
@@ -289,6 +369,16 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 			}
 		}
 
+		var verification *Verification
+		if verify && stopReason != "tool_use" {
+			if answer := strings.TrimSpace(asstMsg.TextContent()); answer != "" && len(blocks) > 0 {
+				verification = a.verifyAnswer(lastRequest(messages), answer, onEvent)
+			}
+		}
+		for _, delta := range held {
+			onEvent(Event{Kind: EventDelta, DeltaText: delta})
+		}
+
 		onEvent(Event{
 			Kind:                     EventStreamDone,
 			Blocks:                   blocks,
@@ -297,37 +387,36 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 			OutputTokens:             usage.OutputTokens,
 			CacheCreationInputTokens: usage.CacheCreationInputTokens,
 			CacheReadInputTokens:     usage.CacheReadInputTokens,
+			ContextTokens:            contextTokens,
+			ContextWindow:            contextWindow,
+			CompactAt:                compactAt,
 		})
 
-		// 3c. If not tool_use, the turn is done
+		if verification != nil {
+			onEvent(Event{Kind: EventVerified, Verification: verification})
+		}
+
+		// 3c. If not tool_use, the turn is done, unless the user steered
+		// while the model was answering: then it answers the note too.
 		if stopReason != "tool_use" {
+			if notes := a.takeSteering(); len(notes) > 0 {
+				steerMsg := domain.TranscriptMessage{Role: "user", Blocks: []domain.ContentBlock{steeringBlock(notes)}}
+				a.appendSteering(steerMsg, notes, onEvent)
+				continue
+			}
+			a.renderDiagrams(ctx, asstMsg.TextContent(), onEvent)
 			onEvent(Event{Kind: EventTurnDone, StopReason: stopReason})
 			return
 		}
 
-		// 3d. Create checkpoint if git available
+		// 3d. Create checkpoint: of the git working tree, or outside git of
+		// the files this step's write tools will change
 		a.mu.Lock()
 		gitAvail := a.gitAvailable
 		a.mu.Unlock()
 
-		if gitAvail && a.session != nil {
-			sha, cpErr := checkpoint.GitStashCreate()
-			if cpErr == nil {
-				cp := checkpoint.Checkpoint{TurnNumber: loopCount}
-				if sha == "" {
-					cp.IsClean = true
-				} else {
-					cp.SHA = sha
-					ref := fmt.Sprintf("refs/muxd/%s/%d", a.session.ID[:8], loopCount)
-					if err := checkpoint.GitUpdateRef(ref, sha); err != nil {
-						a.logf("agent: git update-ref: %v", err)
-					}
-				}
-				a.mu.Lock()
-				a.checkpoints = append(a.checkpoints, cp)
-				a.redoStack = nil
-				a.mu.Unlock()
-			}
+		if cpStore, ok := a.store.(CheckpointStore); ok && a.session != nil {
+			a.checkpoint(cpStore, cwd, loopCount, gitAvail, blocks)
 		}
 
 		// 3e. Collect tool_use blocks
@@ -338,10 +427,33 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 			}
 		}
 
+		// Malformed input goes back to the model with the validation error
+		// instead of running, for up to MaxToolRepairs calls in a row.
+		allToolUseBlocks := toolUseBlocks
+		var repairs []string
+		if toolRepairs < MaxToolRepairs {
+			var found bool
+			if repairs, found = a.checkToolInputs(toolUseBlocks, toolSpecs); found {
+				toolRepairs++
+				toolUseBlocks = nil
+				for i, b := range allToolUseBlocks {
+					if repairs[i] == "" {
+						toolUseBlocks = append(toolUseBlocks, b)
+					}
+				}
+			} else {
+				repairs, toolRepairs = nil, 0
+			}
+		} else {
+			toolRepairs = 0
+		}
+
 		// Check if any tool requires sequential execution.
 		hasSequential := false
 		for _, b := range toolUseBlocks {
-			if b.ToolName == "ask_user" || b.ToolName == "plan_enter" || b.ToolName == "plan_exit" || b.ToolName == "task" {
+			// Messaging tools may ask the user to confirm outbound content,
+			// and gated tools wait for approval one at a time.
+			if b.ToolName == "ask_user" || b.ToolName == "plan_enter" || b.ToolName == "plan_exit" || b.ToolName == "task" || b.ToolName == "spawn_agent" || tools.IsMessagingTool(b.ToolName) || needsApproval(approvalMode, b.ToolName) {
 				hasSequential = true
 				break
 			}
@@ -356,7 +468,12 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 					a.mu.Unlock()
 					return
 				}
+				stopping := a.stopping
 				a.mu.Unlock()
+				if stopping {
+					toolResults = append(toolResults, skippedToolResult(b))
+					continue
+				}
 
 				onEvent(Event{
 					Kind:      EventToolStart,
@@ -373,40 +490,23 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 					if question == "" {
 						question = "The agent wants your input."
 					}
-					respCh := make(chan string, 1)
-					onEvent(Event{
-						Kind:        EventAskUser,
-						AskPrompt:   question,
-						AskResponse: respCh,
-					})
-					// Block until user responds or agent is canceled
-					select {
-					case answer := <-respCh:
+					answer, ok := a.askUser(question, askOptions(b.ToolInput), onEvent)
+					switch {
+					case ok:
 						result = answer
-						isError = false
-					case <-func() chan struct{} {
-						ch := make(chan struct{})
-						go func() {
-							for {
-								a.mu.Lock()
-								canceled := a.canceled
-								a.mu.Unlock()
-								if canceled {
-									close(ch)
-									return
-								}
-								// Poll cancellation
-								select {
-								case <-time.After(100 * time.Millisecond):
-								case <-ch:
-									return
-								}
-							}
-						}()
-						return ch
-					}():
+					case a.stopRequested():
+						result, isError = notRunResult, true
+					default:
 						return
 					}
+				} else if allowed, ok := a.approveToolCall(b, approvalMode, onEvent, toolCtx.Audit); !ok {
+					if !a.stopRequested() {
+						return
+					}
+					result, isError = notRunResult, true
+				} else if !allowed {
+					result = fmt.Sprintf("The user denied this %s call. Do not retry it; ask the user how they want to proceed.", b.ToolName)
+					isError = true
 				} else {
 					result, isError = ExecuteToolCall(b, toolCtx)
 				}
@@ -428,52 +528,27 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 				})
 			}
 		} else {
-			// Parallel path: execute all tools concurrently
-			toolResults = make([]domain.ContentBlock, len(toolUseBlocks))
-			var wg sync.WaitGroup
-
-			for i, b := range toolUseBlocks {
-				a.mu.Lock()
-				if a.canceled {
-					a.mu.Unlock()
-					return
-				}
-				a.mu.Unlock()
-
-				wg.Add(1)
-				go func(idx int, block domain.ContentBlock) {
-					defer wg.Done()
-
-					onEvent(Event{
-						Kind:      EventToolStart,
-						ToolUseID: block.ToolUseID,
-						ToolName:  block.ToolName,
-						ToolInput: block.ToolInput,
-					})
-
-					result, isError := ExecuteToolCall(block, toolCtx)
-
-					onEvent(Event{
-						Kind:        EventToolDone,
-						ToolUseID:   block.ToolUseID,
-						ToolName:    block.ToolName,
-						ToolResult:  result,
-						ToolIsError: isError,
-					})
-
-					toolResults[idx] = domain.ContentBlock{
-						Type:       "tool_result",
-						ToolUseID:  block.ToolUseID,
-						ToolName:   block.ToolName,
-						ToolResult: result,
-						IsError:    isError,
-					}
-				}(i, b)
+			// Parallel path: independent tools run concurrently up to
+			// tools.parallelism, with file writes and commands in order.
+			var ok bool
+			toolResults, ok = a.runToolCalls(toolUseBlocks, parallelism, func(b domain.ContentBlock) (string, bool) {
+				return ExecuteToolCall(b, toolCtx)
+			}, onEvent)
+			if !ok {
+				return
 			}
-			wg.Wait()
 		}
 
-		// 3f. Persist tool results as user message
+		if repairs != nil {
+			toolResults = mergeRepairResults(allToolUseBlocks, repairs, toolResults)
+		}
+
+		// 3f. Persist tool results as user message, with any steering
+		// notes after them.
+		notes := a.takeSteering()
+		if len(notes) > 0 {
+			toolResults = append(toolResults, steeringBlock(notes))
+		}
 		toolMsg := domain.TranscriptMessage{
 			Role:   "user",
 			Blocks: toolResults,
@@ -484,12 +559,130 @@ func (a *Service) submitMessage(userMsg domain.TranscriptMessage, onEvent EventF
 		a.mu.Unlock()
 
 		if a.store != nil && a.session != nil {
-			if err := a.store.AppendMessageBlocks(a.session.ID, "user", toolResults, 0); err != nil {
-				a.logf("agent: persist tool results: %v", err)
-			}
+			persisted := toolMsg
+			persisted.Blocks = a.offloadToolResults(toolResults)
+			a.persist("tool results", append(heldAsst, turnMessage(persisted, 0))...)
+		}
+		if len(notes) > 0 {
+			onEvent(Event{Kind: EventSteered, Steering: notes})
 		}
 
 		// 3g. Compact if needed before looping back
 		a.compactIfNeeded(onEvent)
 	}
 }
+
+// askUser emits EventAskUser and blocks until the adapter answers. It
+// returns false if the agent is canceled first.
+func (a *Service) askUser(question string, options []string, onEvent EventFunc) (string, bool) {
+	respCh := make(chan string, 1)
+	onEvent(Event{
+		Kind:        EventAskUser,
+		AskPrompt:   question,
+		AskOptions:  options,
+		AskResponse: respCh,
+	})
+	for {
+		select {
+		case answer := <-respCh:
+			return answer, true
+		case <-time.After(100 * time.Millisecond):
+			// Poll cancellation
+			if a.interrupted() {
+				return "", false
+			}
+		}
+	}
+}
+
+// persist writes msgs for the running turn, in one transaction when the
+// store supports it.
+func (a *Service) persist(what string, msgs ...store.TurnMessage) {
+	if a.store == nil || a.session == nil {
+		return
+	}
+	a.mu.Lock()
+	turnID := a.turnID
+	a.mu.Unlock()
+	if ts, ok := a.store.(TurnStore); ok {
+		if err := ts.AppendTurnMessages(a.session.ID, turnID, msgs); err != nil {
+			a.logf("agent: persist %s: %v", what, err)
+		}
+		return
+	}
+	for _, m := range msgs {
+		var err error
+		if m.Blocks != nil {
+			err = a.store.AppendMessageBlocks(a.session.ID, m.Role, m.Blocks, m.Tokens)
+		} else {
+			err = a.store.AppendMessage(a.session.ID, m.Role, m.Content, m.Tokens)
+		}
+		if err != nil {
+			a.logf("agent: persist %s: %v", what, err)
+			return
+		}
+	}
+}
+
+// checkpoint snapshots the working tree at dir before a step's tools run
+// and records it for undo. The ref is named after the turn and step, so
+// checkpoints from earlier turns keep theirs. When dir is not in a git
+// repository, only the files the step's write tools name are snapshotted,
+// and a step without any records nothing.
+func (a *Service) checkpoint(cpStore CheckpointStore, dir string, step int, gitAvail bool, blocks []domain.ContentBlock) {
+	if gitAvail {
+		if _, err := checkpoint.GitRunIn(dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+			gitAvail = false
+		}
+	}
+	a.mu.Lock()
+	turnID := a.turnID
+	a.mu.Unlock()
+	var (
+		cp  checkpoint.Checkpoint
+		err error
+	)
+	if gitAvail {
+		cp, err = checkpoint.Create(dir, a.session.ID, fmt.Sprintf("%.8s-%d", turnID, step))
+	} else {
+		var paths []string
+		for _, b := range blocks {
+			if b.Type == "tool_use" {
+				paths = append(paths, tools.WrittenPaths(b.ToolName, b.ToolInput)...)
+			}
+		}
+		if len(paths) == 0 {
+			return
+		}
+		cp, err = checkpoint.CreateFiles(dir, paths)
+	}
+	if err != nil {
+		a.logf("agent: checkpoint: %v", err)
+		return
+	}
+	rec := store.Checkpoint{SessionID: a.session.ID, Turn: step, Dir: dir, SHA: cp.SHA}
+	if _, err := cpStore.AddCheckpoint(rec); err != nil {
+		a.logf("agent: record checkpoint: %v", err)
+	}
+}
+
+// turnMessage converts msg for persisting, as blocks when it has them.
+func turnMessage(msg domain.TranscriptMessage, tokens int) store.TurnMessage {
+	m := store.TurnMessage{Role: msg.Role, Content: msg.Content, Tokens: tokens}
+	if len(msg.Blocks) > 0 {
+		m.Blocks = msg.Blocks
+	}
+	return m
+}
+
+// askOptions returns the non-empty answers an ask_user call offers.
+func askOptions(input map[string]any) []string {
+	list, _ := input["options"].([]any)
+	var options []string
+	for _, o := range list {
+		if s, _ := o.(string); strings.TrimSpace(s) != "" {
+			options = append(options, strings.TrimSpace(s))
+		}
+	}
+	return options
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/batalabs/muxd/internal/domain"
 	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
 )
 
 func TestService_Submit_toolUseParallel(t *testing.T) {
@@ -142,7 +144,7 @@ func TestService_Submit_toolUseSequential_askUser(t *testing.T) {
 			})
 			writeSSE(w, "content_block_delta", map[string]any{
 				"index": 0,
-				"delta": map[string]any{"type": "input_json_delta", "partial_json": `{"question":"What is the target?"}`},
+				"delta": map[string]any{"type": "input_json_delta", "partial_json": `{"question":"What is the target?","options":["staging"," production ",""]}`},
 			})
 			writeSSE(w, "content_block_stop", map[string]any{"index": 0})
 			writeSSE(w, "message_delta", map[string]any{
@@ -202,6 +204,9 @@ func TestService_Submit_toolUseSequential_askUser(t *testing.T) {
 			if evt.AskPrompt != "What is the target?" {
 				t.Errorf("expected 'What is the target?', got %q", evt.AskPrompt)
 			}
+			if !slices.Equal(evt.AskOptions, []string{"staging", "production"}) {
+				t.Errorf("AskOptions = %q", evt.AskOptions)
+			}
 		case EventToolDone:
 			gotToolDone = true
 			if evt.ToolName == "ask_user" && evt.ToolResult != "production" {
@@ -428,3 +433,76 @@ func writeSSE(w http.ResponseWriter, eventType string, payload map[string]any) {
 	data, _ := json.Marshal(payload)
 	fmt.Fprintf(w, "data: %s\n\n", data)
 }
+
+func TestService_Submit_persistsToolStepsTogether(t *testing.T) {
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenStoreIn: %v", err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	var tool atomic.Value
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeSSE(w, "message_start", map[string]any{
+			"message": map[string]any{"usage": map[string]any{"input_tokens": 10, "output_tokens": 0}},
+		})
+		if calls.Add(1)%2 == 1 {
+			writeSSE(w, "content_block_start", map[string]any{"index": 0, "content_block": map[string]any{"type": "tool_use", "id": "tu_1", "name": tool.Load()}})
+			writeSSE(w, "content_block_delta", map[string]any{"index": 0, "delta": map[string]any{"type": "input_json_delta", "partial_json": `{"path":".","question":"Sure?"}`}})
+			writeSSE(w, "content_block_stop", map[string]any{"index": 0})
+			writeSSE(w, "message_delta", map[string]any{"usage": map[string]any{"output_tokens": 5}, "delta": map[string]any{"stop_reason": "tool_use"}})
+			return
+		}
+		writeSSE(w, "content_block_start", map[string]any{"index": 0, "content_block": map[string]any{"type": "text"}})
+		writeSSE(w, "content_block_delta", map[string]any{"index": 0, "delta": map[string]any{"type": "text_delta", "text": "Done."}})
+		writeSSE(w, "content_block_stop", map[string]any{"index": 0})
+		writeSSE(w, "message_delta", map[string]any{"usage": map[string]any{"output_tokens": 3}, "delta": map[string]any{"stop_reason": "end_turn"}})
+	}))
+	defer server.Close()
+	origURL := provider.TestAPIURL
+	provider.TestAPIURL = server.URL
+	defer func() { provider.TestAPIURL = origURL }()
+
+	t.Run("tool calls and results share the turn", func(t *testing.T) {
+		sess, _ := st.CreateSession(".", "fake")
+		svc := NewService("fake-key", "fake", "fake", st, sess, &testAnthropicProvider{})
+		svc.Cwd = "."
+		tool.Store("list_files")
+		svc.Submit("look around", func(Event) {})
+
+		records, err := st.GetMessageRecords(sess.ID)
+		if err != nil {
+			t.Fatalf("GetMessageRecords: %v", err)
+		}
+		if len(records) != 4 || records[1].Blocks[0].Type != "tool_use" || records[2].Blocks[0].Type != "tool_result" {
+			t.Fatalf("records = %+v", records)
+		}
+		for _, r := range records {
+			if r.TurnID == "" || r.TurnID != records[0].TurnID {
+				t.Errorf("record %d turn = %q, want %q", r.Sequence, r.TurnID, records[0].TurnID)
+			}
+		}
+	})
+
+	t.Run("a cancelled tool call is not stored", func(t *testing.T) {
+		sess, _ := st.CreateSession(".", "fake")
+		svc := NewService("fake-key", "fake", "fake", st, sess, &testAnthropicProvider{})
+		svc.Cwd = "."
+		tool.Store("ask_user")
+		svc.Submit("ask me", func(evt Event) {
+			if evt.Kind == EventAskUser {
+				go svc.Cancel()
+			}
+		})
+
+		msgs, err := st.GetMessages(sess.ID)
+		if err != nil {
+			t.Fatalf("GetMessages: %v", err)
+		}
+		if len(msgs) != 1 || msgs[0].Content != "ask me" {
+			t.Errorf("stored messages = %+v", msgs)
+		}
+	})
+}
@@ -0,0 +1,66 @@
+package agent
+
+import (
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// ---------------------------------------------------------------------------
+// Tool-call repair
+// ---------------------------------------------------------------------------
+
+// MaxToolRepairs bounds how many model calls in a row may be spent fixing
+// malformed tool input. After that, calls go to ExecuteToolCall, which
+// rejects them with the same message as an ordinary failed tool call.
+const MaxToolRepairs = 3
+
+// toolInputProblem returns the repair message for a tool_use block whose
+// input does not fit its tool's schema, or "" when it fits. Calls to tools
+// without a spec are left to ExecuteToolCall.
+func toolInputProblem(b domain.ContentBlock, specs []provider.ToolSpec) string {
+	for _, spec := range specs {
+		if spec.Name == b.ToolName {
+			return checkToolArgs(b, spec)
+		}
+	}
+	return ""
+}
+
+// checkToolInputs returns a repair message for each malformed call, indexed
+// like blocks, with "" for calls that can run.
+func (a *Service) checkToolInputs(blocks []domain.ContentBlock, specs []provider.ToolSpec) ([]string, bool) {
+	repairs := make([]string, len(blocks))
+	found := false
+	for i, b := range blocks {
+		msg := toolInputProblem(b, specs)
+		if msg == "" {
+			continue
+		}
+		a.logf("agent: malformed %s call", b.ToolName)
+		repairs[i] = msg
+		found = true
+	}
+	return repairs, found
+}
+
+// mergeRepairResults puts repair results back among the results of the
+// calls that ran, in the order the model emitted the calls.
+func mergeRepairResults(blocks []domain.ContentBlock, repairs []string, ran []domain.ContentBlock) []domain.ContentBlock {
+	out := make([]domain.ContentBlock, 0, len(blocks))
+	next := 0
+	for i, b := range blocks {
+		if repairs[i] == "" {
+			out = append(out, ran[next])
+			next++
+			continue
+		}
+		out = append(out, domain.ContentBlock{
+			Type:       "tool_result",
+			ToolUseID:  b.ToolUseID,
+			ToolName:   b.ToolName,
+			ToolResult: repairs[i],
+			IsError:    true,
+		})
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package agent
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// malformedProvider calls file_read without its required path for the first
+// bad calls, then ends the turn. It records the tool results it is sent.
+type malformedProvider struct {
+	mu      sync.Mutex
+	bad     int
+	calls   int
+	results []domain.ContentBlock
+}
+
+func (p *malformedProvider) Name() string { return "test" }
+func (p *malformedProvider) StreamMessage(apiKey, modelID string, msgs []domain.TranscriptMessage, specs []provider.ToolSpec, system string, onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if last := msgs[len(msgs)-1]; last.Role == "user" {
+		for _, b := range last.Blocks {
+			if b.Type == "tool_result" {
+				p.results = append(p.results, b)
+			}
+		}
+	}
+	usage := provider.Usage{InputTokens: 10, OutputTokens: 5}
+	if p.calls > p.bad {
+		return []domain.ContentBlock{{Type: "text", Text: "done"}}, "end_turn", usage, nil
+	}
+	return []domain.ContentBlock{
+		{Type: "tool_use", ToolUseID: domain.NewUUID(), ToolName: "file_read", ToolInput: map[string]any{"offset": "ten"}},
+		{Type: "tool_use", ToolUseID: domain.NewUUID(), ToolName: "list_files", ToolInput: map[string]any{"path": "."}},
+	}, "tool_use", usage, nil
+}
+func (p *malformedProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
+	return nil, nil
+}
+
+func TestService_Submit_repairsMalformedToolInput(t *testing.T) {
+	prov := &malformedProvider{bad: 1}
+	svc := NewService("key", "m", "label", nil, nil, prov)
+	svc.Cwd = t.TempDir()
+
+	var mu sync.Mutex
+	var started []string
+	svc.Submit("read a file", func(evt Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch evt.Kind {
+		case EventToolStart:
+			started = append(started, evt.ToolName)
+		case EventError:
+			t.Errorf("unexpected error: %v", evt.Err)
+		}
+	})
+
+	if strings.Join(started, ",") != "list_files" {
+		t.Errorf("started tools = %v, want only list_files", started)
+	}
+	if len(prov.results) != 2 {
+		t.Fatalf("got %d tool results, want 2", len(prov.results))
+	}
+	repair := prov.results[0]
+	if repair.ToolName != "file_read" || !repair.IsError {
+		t.Errorf("first result = %+v, want the file_read repair", repair)
+	}
+	for _, want := range []string{"Invalid arguments for file_read", "path is required", "offset must be an integer", "path (string, required)"} {
+		if !strings.Contains(repair.ToolResult, want) {
+			t.Errorf("repair message missing %q:\n%s", want, repair.ToolResult)
+		}
+	}
+	if prov.results[1].ToolName != "list_files" || prov.results[1].IsError {
+		t.Errorf("second result = %+v, want list_files output", prov.results[1])
+	}
+}
+
+func TestService_Submit_toolRepairsAreBounded(t *testing.T) {
+	prov := &malformedProvider{bad: MaxToolRepairs + 1}
+	svc := NewService("key", "m", "label", nil, nil, prov)
+	svc.Cwd = t.TempDir()
+
+	var mu sync.Mutex
+	fileReads := 0
+	svc.Submit("read a file", func(evt Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		if evt.Kind == EventToolStart && evt.ToolName == "file_read" {
+			fileReads++
+		}
+	})
+
+	if fileReads != 1 {
+		t.Errorf("file_read ran %d times, want once after %d repairs", fileReads, MaxToolRepairs)
+	}
+	last := prov.results[len(prov.results)-2]
+	if last.ToolName != "file_read" || !last.IsError || !strings.Contains(last.ToolResult, "Invalid arguments for file_read") {
+		t.Errorf("final file_read result = %+v, want a validation error", last)
+	}
+}
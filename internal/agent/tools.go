@@ -2,10 +2,13 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/batalabs/muxd/internal/domain"
 	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/provider"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
@@ -14,6 +17,10 @@ func ExecuteToolCall(call domain.ContentBlock, ctx *tools.ToolContext) (string,
 	if ctx != nil && ctx.Disabled != nil && ctx.Disabled[call.ToolName] {
 		return fmt.Sprintf("Tool %s is disabled by user config.", call.ToolName), true
 	}
+	// Anything that might change files invalidates speculative reads.
+	if ctx != nil && !tools.PrefetchKeeps(call.ToolName) {
+		ctx.Prefetch.Clear()
+	}
 
 	// Route MCP tools to the MCP manager.
 	if mcp.IsMCPTool(call.ToolName) {
@@ -54,11 +61,59 @@ func ExecuteToolCall(call domain.ContentBlock, ctx *tools.ToolContext) (string,
 		}
 	}
 
-	result, err := tool.Execute(call.ToolInput, ctx)
+	// Reject arguments that don't match the tool's schema before running.
+	if msg := checkToolArgs(call, tool.Spec); msg != "" {
+		return msg, true
+	}
+
+	// Screen content leaving through external messaging tools.
+	var notice string
+	if ctx != nil && tools.IsMessagingTool(call.ToolName) {
+		var blocked bool
+		call, notice, blocked = screenOutbound(call, ctx)
+		if blocked {
+			return notice, true
+		}
+	}
+
+	input := call.ToolInput
+	if _, isBuiltin := tools.FindTool(call.ToolName); isBuiltin {
+		input = tools.ResolvePathInput(tools.NormalizePathInput(input), ctx)
+	}
+	result, err := tool.Execute(input, ctx)
 	if err != nil {
-		return err.Error(), true
+		return err.Error() + notice, true
+	}
+	return result + notice, false
+}
+
+// checkToolArgs validates a call's arguments against spec. It returns the
+// message the model gets back for invalid arguments, listing each problem
+// and the expected arguments, or "" when they are valid.
+func checkToolArgs(call domain.ContentBlock, spec provider.ToolSpec) string {
+	var problems []string
+	if call.InputError != "" {
+		problems = []string{call.InputError}
+	} else if err := spec.ValidateInput(call.ToolInput); err != nil {
+		var verr *provider.ValidationError
+		if !errors.As(err, &verr) {
+			problems = []string{err.Error()}
+		} else {
+			for _, f := range verr.Fields {
+				problems = append(problems, f.Path+" "+f.Problem)
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Invalid arguments for %s:\n", spec.Name)
+	for _, p := range problems {
+		b.WriteString("- " + p + "\n")
 	}
-	return result, false
+	fmt.Fprintf(&b, "Expected arguments: %s.\nCall %s again with corrected arguments.", spec.ArgsSummary(), spec.Name)
+	return b.String()
 }
 
 // isWriteTool checks if a tool name is a write tool (for plan mode error messages).
@@ -1,9 +1,13 @@
 package agent
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/guardrail"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
@@ -70,6 +74,25 @@ func TestExecuteToolCall_planModeBlocksWriteTools(t *testing.T) {
 	}
 }
 
+func TestExecuteToolCall_outboundGuardrailBlocks(t *testing.T) {
+	call := domain.ContentBlock{
+		Type:      "tool_use",
+		ToolUseID: "tu_1",
+		ToolName:  "sms_send",
+		ToolInput: map[string]any{"phone": "+15555550100", "message": "the key is sk-ant-REDACTED"},
+	}
+	ctx := &tools.ToolContext{
+		OutboundGuardrail: guardrail.Policy{Mode: guardrail.ModeBlock},
+	}
+	result, isError := ExecuteToolCall(call, ctx)
+	if !isError {
+		t.Fatal("expected isError=true for blocked outbound content")
+	}
+	if !strings.Contains(result, "Blocked by outbound guardrail (1 secret)") {
+		t.Errorf("unexpected result: %s", result)
+	}
+}
+
 func TestExecuteToolCall_invalidMCPTool(t *testing.T) {
 	call := domain.ContentBlock{
 		Type:      "tool_use",
@@ -86,3 +109,32 @@ func TestExecuteToolCall_invalidMCPTool(t *testing.T) {
 		t.Fatal("expected non-empty error result")
 	}
 }
+
+func TestExecuteToolCall_writesClearPrefetch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache := tools.NewPrefetchCache()
+	cache.Warm(dir, "a.txt")
+	cache.Wait()
+	ctx := &tools.ToolContext{Cwd: dir, Prefetch: cache}
+
+	ExecuteToolCall(domain.ContentBlock{Type: "tool_use", ToolName: "grep", ToolInput: map[string]any{"pattern": "old", "path": dir}}, ctx)
+	if cached, _ := cache.Stats(); cached != 1 {
+		t.Fatalf("grep cleared the cache")
+	}
+
+	out, isErr := ExecuteToolCall(domain.ContentBlock{Type: "tool_use", ToolName: "file_write", ToolInput: map[string]any{"path": path, "content": "new"}}, ctx)
+	if isErr {
+		t.Fatalf("file_write: %s", out)
+	}
+	if cached, _ := cache.Stats(); cached != 0 {
+		t.Error("file_write should clear prefetched files")
+	}
+	out, _ = ExecuteToolCall(domain.ContentBlock{Type: "tool_use", ToolName: "file_read", ToolInput: map[string]any{"path": path}}, ctx)
+	if !strings.Contains(out, "new") {
+		t.Errorf("file_read after write = %q", out)
+	}
+}
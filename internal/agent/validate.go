@@ -0,0 +1,199 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// missingResult is the tool result recorded for a call whose result was
+// never saved.
+const missingResult = "No result was recorded for this call."
+
+// ValidateTranscript checks a loaded transcript against the rules providers
+// enforce and repairs what breaks them, so a damaged session fails with a
+// clear turn rather than an opaque API error. It:
+//
+//   - drops empty messages and blank text blocks,
+//   - merges consecutive messages from the same role,
+//   - drops tool results that answer no call in the message before, and
+//   - adds error results for tool calls left without one.
+//
+// It returns the repaired messages and a description of each fix, empty
+// when the transcript was already valid. msgs is not modified.
+func ValidateTranscript(msgs []domain.TranscriptMessage) ([]domain.TranscriptMessage, []string) {
+	out := make([]domain.TranscriptMessage, len(msgs))
+	copy(out, msgs)
+	var fixes []string
+	// A fix can make another necessary (dropping orphaned results can leave
+	// an empty message between two assistant turns), so repeat until the
+	// transcript holds still.
+	for range 3 {
+		n := len(fixes)
+		out = dropEmptyMessages(out, &fixes)
+		out = mergeSameRole(out, &fixes)
+		out = pairToolCalls(out, &fixes)
+		if len(fixes) == n {
+			break
+		}
+	}
+	return out, fixes
+}
+
+func dropEmptyMessages(msgs []domain.TranscriptMessage, fixes *[]string) []domain.TranscriptMessage {
+	out := msgs[:0:0]
+	blocks, dropped := 0, 0
+	for _, m := range msgs {
+		if m.HasBlocks() {
+			kept := make([]domain.ContentBlock, 0, len(m.Blocks))
+			for _, b := range m.Blocks {
+				if b.Type == "text" && strings.TrimSpace(b.Text) == "" {
+					blocks++
+					continue
+				}
+				kept = append(kept, b)
+			}
+			m.Blocks = kept
+		}
+		if !m.HasBlocks() && strings.TrimSpace(m.Content) == "" {
+			dropped++
+			continue
+		}
+		out = append(out, m)
+	}
+	if blocks > 0 {
+		*fixes = append(*fixes, fmt.Sprintf("dropped %d blank text blocks", blocks))
+	}
+	if dropped > 0 {
+		*fixes = append(*fixes, fmt.Sprintf("dropped %d empty messages", dropped))
+	}
+	return out
+}
+
+func mergeSameRole(msgs []domain.TranscriptMessage, fixes *[]string) []domain.TranscriptMessage {
+	out := msgs[:0:0]
+	merged := 0
+	for _, m := range msgs {
+		if len(out) == 0 || out[len(out)-1].Role != m.Role {
+			out = append(out, m)
+			continue
+		}
+		merged++
+		prev := &out[len(out)-1]
+		if !prev.HasBlocks() && !m.HasBlocks() {
+			prev.Content += "\n\n" + m.Content
+			continue
+		}
+		blocks := append(asBlocks(*prev), asBlocks(m)...)
+		if m.Role == "user" {
+			// Tool results must lead the message that follows a tool call.
+			results := make([]domain.ContentBlock, 0, len(blocks))
+			var rest []domain.ContentBlock
+			for _, b := range blocks {
+				if b.Type == "tool_result" {
+					results = append(results, b)
+				} else {
+					rest = append(rest, b)
+				}
+			}
+			blocks = append(results, rest...)
+		}
+		prev.Content, prev.Blocks = "", blocks
+	}
+	if merged > 0 {
+		*fixes = append(*fixes, fmt.Sprintf("merged %d messages into the one before from the same role", merged))
+	}
+	return out
+}
+
+// asBlocks returns m's content as blocks.
+func asBlocks(m domain.TranscriptMessage) []domain.ContentBlock {
+	if m.HasBlocks() {
+		return append([]domain.ContentBlock(nil), m.Blocks...)
+	}
+	return []domain.ContentBlock{{Type: "text", Text: m.Content}}
+}
+
+func pairToolCalls(msgs []domain.TranscriptMessage, fixes *[]string) []domain.TranscriptMessage {
+	out := make([]domain.TranscriptMessage, 0, len(msgs))
+	orphans, missing := 0, 0
+	for i, m := range msgs {
+		if m.Role == "user" && m.HasBlocks() {
+			// Keep only results for calls in the message just before, once each.
+			calls := map[string]bool{}
+			if len(out) > 0 && out[len(out)-1].Role == "assistant" {
+				for _, id := range collectToolUseIDs(out[len(out)-1].Blocks) {
+					calls[id] = true
+				}
+			}
+			kept := make([]domain.ContentBlock, 0, len(m.Blocks))
+			for _, b := range m.Blocks {
+				if b.Type == "tool_result" {
+					if !calls[b.ToolUseID] {
+						orphans++
+						continue
+					}
+					delete(calls, b.ToolUseID)
+				}
+				kept = append(kept, b)
+			}
+			m.Blocks = kept
+		}
+		out = append(out, m)
+
+		if m.Role != "assistant" {
+			continue
+		}
+		ids := collectToolUseIDs(m.Blocks)
+		if len(ids) == 0 {
+			continue
+		}
+		if i == len(msgs)-1 {
+			// The turn ended mid-call; close it out as an interrupted one.
+			fix, _ := RepairInterruptedTurn(out)
+			missing += len(ids)
+			out = append(out, fix...)
+			continue
+		}
+		answered := map[string]bool{}
+		next := msgs[i+1]
+		if next.Role == "user" {
+			answered, _ = collectToolResultIDs(next.Blocks)
+		}
+		var results []domain.ContentBlock
+		names := map[string]string{}
+		for _, b := range m.Blocks {
+			names[b.ToolUseID] = b.ToolName
+		}
+		for _, id := range ids {
+			if !answered[id] {
+				results = append(results, domain.ContentBlock{
+					Type:       "tool_result",
+					ToolUseID:  id,
+					ToolName:   names[id],
+					ToolResult: missingResult,
+					IsError:    true,
+				})
+			}
+		}
+		if len(results) == 0 {
+			continue
+		}
+		missing += len(results)
+		if next.Role == "user" {
+			// Lead the next message with the missing results.
+			msgs[i+1].Blocks = append(results, asBlocks(next)...)
+			msgs[i+1].Content = ""
+		} else {
+			out = append(out, domain.TranscriptMessage{Role: "user", Blocks: results})
+		}
+	}
+	if orphans > 0 {
+		*fixes = append(*fixes, fmt.Sprintf("dropped %d tool results with no matching call", orphans))
+	}
+	if missing > 0 {
+		*fixes = append(*fixes, fmt.Sprintf("added results for %d tool calls left without one", missing))
+	}
+	return out
+}
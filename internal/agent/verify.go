@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+const verifySystemPrompt = `You are checking another assistant's answer before the user sees it. Look for factual errors, code that would not compile or would do the wrong thing, and claims the request does not support.
+If you find no problems, reply with exactly "OK". Otherwise list each problem as one short bullet, most serious first. Do not rewrite the answer.`
+
+// Verification is the result of checking a final answer with a second
+// model (see SetVerify). Its tokens are recorded as spend for that model
+// but not added to the session's token totals.
+type Verification struct {
+	Model        string // model that checked the answer
+	OK           bool   // no problems were found
+	Critique     string // the problems found; empty when OK
+	InputTokens  int
+	OutputTokens int
+	Err          error // the check could not run
+}
+
+// SetVerify turns the verification pass on or off for this session. A
+// non-empty model overrides model.verify for the session.
+func (a *Service) SetVerify(on bool, model string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.verify = on
+	a.verifyModel = strings.TrimSpace(model)
+}
+
+// Verify reports whether the verification pass is on and the model that
+// runs it; an empty model means the session's own model.
+func (a *Service) Verify() (on bool, model string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.verify, a.verifyModelLocked()
+}
+
+// verifyModelLocked returns the verification model specifier: the
+// session's override, then model.verify, then model.consult. Callers hold
+// a.mu.
+func (a *Service) verifyModelLocked() string {
+	switch {
+	case a.verifyModel != "":
+		return a.verifyModel
+	case a.prefs.ModelVerify != "":
+		return a.prefs.ModelVerify
+	default:
+		return a.modelConsult
+	}
+}
+
+// verifyAnswer asks the verification model to critique answer, the final
+// reply to request.
+func (a *Service) verifyAnswer(request, answer string, onEvent EventFunc) *Verification {
+	a.mu.Lock()
+	spec := a.verifyModelLocked()
+	prov, apiKey, modelID := a.prov, a.apiKey, a.modelID
+	a.mu.Unlock()
+
+	if spec != "" {
+		var err error
+		if prov, apiKey, modelID, err = a.sideModel(spec); err != nil {
+			return &Verification{Model: spec, Err: fmt.Errorf("resolving provider: %w", err)}
+		}
+	}
+	v := &Verification{Model: modelID}
+	if prov == nil {
+		v.Err = fmt.Errorf("no provider configured")
+		return v
+	}
+
+	prompt := "Request:\n" + request + "\n\nAnswer to check:\n" + answer
+	msgs := []domain.TranscriptMessage{{Role: "user", Content: prompt}}
+	blocks, _, usage, err := prov.StreamMessage(apiKey, modelID, msgs, nil, verifySystemPrompt, nil)
+	if err != nil {
+		v.Err = err
+		return v
+	}
+	a.recordModelSpend(modelID, usage, time.Now(), onEvent)
+	v.InputTokens, v.OutputTokens = usage.InputTokens, usage.OutputTokens
+
+	var sb strings.Builder
+	for _, b := range blocks {
+		if b.Type == "text" {
+			sb.WriteString(b.Text)
+		}
+	}
+	text := strings.TrimSpace(sb.String())
+	v.OK = strings.EqualFold(strings.TrimRight(text, "."), "OK")
+	if !v.OK {
+		v.Critique = text
+	}
+	return v
+}
+
+// lastRequest returns the text of the most recent user message that is not
+// a tool result.
+func lastRequest(msgs []domain.TranscriptMessage) string {
+	for i := len(msgs) - 1; i >= 0; i-- {
+		m := msgs[i]
+		if m.Role != "user" {
+			continue
+		}
+		if text := strings.TrimSpace(m.TextContent()); text != "" {
+			return text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package agent
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// verifyingProvider answers turns with answer and verification requests
+// with verdict.
+type verifyingProvider struct {
+	answer, verdict string
+	checked         string // the prompt the verifier saw
+}
+
+func (p *verifyingProvider) Name() string { return "mock" }
+
+func (p *verifyingProvider) FetchModels(string) ([]domain.APIModelInfo, error) { return nil, nil }
+
+func (p *verifyingProvider) StreamMessage(apiKey, modelID string, msgs []domain.TranscriptMessage, tools []provider.ToolSpec, system string, onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error) {
+	text, usage := p.answer, provider.Usage{InputTokens: 100, OutputTokens: 10}
+	if system == verifySystemPrompt {
+		p.checked = msgs[0].Content
+		text, usage = p.verdict, provider.Usage{InputTokens: 7, OutputTokens: 3}
+	}
+	if onDelta != nil {
+		onDelta(text)
+	}
+	return []domain.ContentBlock{{Type: "text", Text: text}}, "end_turn", usage, nil
+}
+
+func TestSubmit_verify(t *testing.T) {
+	st := newMockStore()
+	sess, _ := st.CreateSession("/tmp/verify", "m")
+	prov := &verifyingProvider{answer: "Use sort.Slice.", verdict: "- sort.Slice is not stable; use sort.SliceStable."}
+	a := NewService("key", "m", "m", st, sess, prov)
+	a.SetVerify(true, "")
+
+	var kinds []EventKind
+	var got *Verification
+	a.Submit("how do I sort stably?", func(e Event) {
+		kinds = append(kinds, e.Kind)
+		if e.Kind == EventDelta && prov.checked == "" {
+			t.Error("reply streamed before it was checked")
+		}
+		if e.Kind == EventVerified {
+			got = e.Verification
+		}
+	})
+
+	if got == nil || got.OK || !strings.Contains(got.Critique, "SliceStable") || got.InputTokens != 7 || got.OutputTokens != 3 {
+		t.Fatalf("verification = %+v", got)
+	}
+	if !strings.Contains(prov.checked, "how do I sort stably?") || !strings.Contains(prov.checked, "Use sort.Slice.") {
+		t.Errorf("verifier prompt = %q", prov.checked)
+	}
+	want := []EventKind{EventDelta, EventStreamDone, EventVerified, EventTurnDone}
+	if len(kinds) < len(want) || !slices.Equal(kinds[len(kinds)-len(want):], want) {
+		t.Errorf("events = %v, want the reply held until it was checked: ...%v", kinds, want)
+	}
+	if in, out := a.inputTokens, a.outputTokens; in != 100 || out != 10 {
+		t.Errorf("session tokens = %d/%d, want the verification pass left out", in, out)
+	}
+
+	prov.verdict = "OK."
+	got = nil
+	a.Submit("and unstably?", func(e Event) {
+		if e.Kind == EventVerified {
+			got = e.Verification
+		}
+	})
+	if got == nil || !got.OK || got.Critique != "" {
+		t.Errorf("clean answer verification = %+v", got)
+	}
+
+	a.SetVerify(false, "")
+	got = nil
+	a.Submit("thanks", func(e Event) {
+		if e.Kind == EventVerified {
+			got = e.Verification
+		}
+	})
+	if got != nil {
+		t.Error("verification ran while off")
+	}
+}
+
+func TestVerifyModel(t *testing.T) {
+	a := NewService("", "m", "m", nil, nil, nil)
+	if _, model := a.Verify(); model != "" {
+		t.Errorf("default = %q, want the session model", model)
+	}
+	a.SetModelConsult("openai/gpt-4o")
+	if _, model := a.Verify(); model != "openai/gpt-4o" {
+		t.Errorf("with model.consult = %q", model)
+	}
+	a.SetPreferences(config.Preferences{ModelVerify: "anthropic/claude-haiku"})
+	if _, model := a.Verify(); model != "anthropic/claude-haiku" {
+		t.Errorf("with model.verify = %q", model)
+	}
+	a.SetVerify(true, "grok/grok-3")
+	if on, model := a.Verify(); !on || model != "grok/grok-3" {
+		t.Errorf("session override = %v, %q", on, model)
+	}
+}
+
+func TestLastRequest(t *testing.T) {
+	msgs := []domain.TranscriptMessage{
+		{Role: "user", Content: "fix the build"},
+		{Role: "assistant", Blocks: []domain.ContentBlock{{Type: "tool_use", ToolName: "bash"}}},
+		{Role: "user", Blocks: []domain.ContentBlock{{Type: "tool_result", ToolResult: "ok"}}},
+	}
+	if got := lastRequest(msgs); got != "fix the build" {
+		t.Errorf("lastRequest = %q", got)
+	}
+}
@@ -0,0 +1,459 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// An archive is a gzipped tar with this layout:
+//
+//	manifest.json                 what the archive holds
+//	muxd.db                       snapshot of the session database
+//	config/...                    the config directory (config.json, commands, ...)
+//	memory/<n>/memory.json        project memory, restored to Manifest.Memory[n]
+const (
+	manifestName = "manifest.json"
+	dbName       = "muxd.db"
+	configPrefix = "config/"
+	memoryPrefix = "memory/"
+
+	// FormatVersion is bumped when the archive layout changes incompatibly.
+	FormatVersion = 1
+)
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	// Memory maps the index used in memory/<n>/ to the project root the
+	// memory file belongs to.
+	Memory map[string]string `json:"memory,omitempty"`
+	Files  int               `json:"files"`
+}
+
+// Sources are what Create puts into an archive.
+type Sources struct {
+	Store     *store.Store
+	ConfigDir string   // skipped if empty or missing
+	Projects  []string // project roots whose .muxd/memory.json is included
+}
+
+// SourcesFor collects the config directory and the memory files of every
+// project with sessions in st.
+func SourcesFor(st *store.Store, configDir string) (Sources, error) {
+	projects, err := st.ProjectPaths()
+	if err != nil {
+		return Sources{}, fmt.Errorf("listing projects: %w", err)
+	}
+	return Sources{Store: st, ConfigDir: configDir, Projects: projects}, nil
+}
+
+// memoryPath is where a project keeps its memory file.
+func memoryPath(root string) string {
+	return filepath.Join(root, store.ProjectDBDir, "memory.json")
+}
+
+// Create writes an archive of src to w.
+func Create(w io.Writer, src Sources) (*Manifest, error) {
+	tmp, err := os.MkdirTemp("", "muxd-backup-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	snapshot := filepath.Join(tmp, dbName)
+	if err := src.Store.Snapshot(snapshot); err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	m := &Manifest{Version: FormatVersion, CreatedAt: time.Now().UTC()}
+
+	if err := addFile(tw, dbName, snapshot); err != nil {
+		return nil, err
+	}
+	m.Files++
+
+	if src.ConfigDir != "" {
+		err := filepath.WalkDir(src.ConfigDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == src.ConfigDir {
+					return fs.SkipDir
+				}
+				return err
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(src.ConfigDir, p)
+			if err != nil {
+				return err
+			}
+			m.Files++
+			return addFile(tw, configPrefix+filepath.ToSlash(rel), p)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("archiving config: %w", err)
+		}
+	}
+
+	for _, root := range src.Projects {
+		p := memoryPath(root)
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if m.Memory == nil {
+			m.Memory = make(map[string]string)
+		}
+		idx := strconv.Itoa(len(m.Memory))
+		m.Memory[idx] = root
+		if err := addFile(tw, memoryPrefix+idx+"/memory.json", p); err != nil {
+			return nil, err
+		}
+		m.Files++
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling manifest: %w", err)
+	}
+	if err := addBytes(tw, manifestName, data); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("writing archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("writing archive: %w", err)
+	}
+	return m, nil
+}
+
+func addFile(tw *tar.Writer, name, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: info.Size(), ModTime: info.ModTime()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	return nil
+}
+
+func addBytes(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now()}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("archiving %s: %w", name, err)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Restore
+// ---------------------------------------------------------------------------
+
+// Targets are where Restore writes an archive back to.
+type Targets struct {
+	DataDir   string // receives muxd.db
+	ConfigDir string
+	// Memory restores project memory files to their project roots. Only
+	// roots listed in Projects are written to; roots the archive names that
+	// are not listed, or that no longer exist, are skipped.
+	Memory   bool
+	Projects []string
+}
+
+// RestoreResult reports what Restore wrote.
+type RestoreResult struct {
+	Manifest Manifest
+	Config   int      // config files restored
+	Memory   []string // project roots whose memory was restored
+	// Previous is where the replaced database was moved, if there was one.
+	Previous string
+}
+
+// stagedFile is a restored file written next to its destination, renamed
+// over it once every file in the archive has been written.
+type stagedFile struct{ tmp, dst string }
+
+// Restore unpacks an archive into dst. The existing database is kept next to
+// the restored one as muxd.db.before-restore. The daemon must not be running.
+//
+// Every file is written beside its destination first, so a failure before
+// the swap changes nothing, and a failure during it puts the previous
+// database, config, and memory files back.
+func Restore(r io.Reader, dst Targets) (*RestoreResult, error) {
+	tmp, err := os.MkdirTemp("", "muxd-restore-")
+	if err != nil {
+		return nil, fmt.Errorf("creating temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	// Unpack everything first so a corrupt archive changes nothing.
+	if err := extract(r, tmp); err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(tmp, manifestName))
+	if err != nil {
+		return nil, fmt.Errorf("not a muxd backup: missing %s", manifestName)
+	}
+	res := &RestoreResult{}
+	if err := json.Unmarshal(data, &res.Manifest); err != nil {
+		return nil, fmt.Errorf("parsing manifest: %w", err)
+	}
+	if res.Manifest.Version > FormatVersion {
+		return nil, fmt.Errorf("backup format %d is newer than this muxd supports (%d)", res.Manifest.Version, FormatVersion)
+	}
+	snapshot := filepath.Join(tmp, dbName)
+	if _, err := os.Stat(snapshot); err != nil {
+		return nil, fmt.Errorf("not a muxd backup: missing %s", dbName)
+	}
+
+	if err := os.MkdirAll(dst.DataDir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating data dir: %w", err)
+	}
+
+	var staged []stagedFile
+	defer func() {
+		for _, f := range staged {
+			os.Remove(f.tmp)
+		}
+	}()
+	stage := func(src, to string) error {
+		f := stagedFile{tmp: to + ".restoring", dst: to}
+		if err := copyFile(src, f.tmp); err != nil {
+			return err
+		}
+		staged = append(staged, f)
+		return nil
+	}
+
+	dbPath := filepath.Join(dst.DataDir, dbName)
+	if err := stage(snapshot, dbPath); err != nil {
+		return nil, fmt.Errorf("restoring database: %w", err)
+	}
+
+	if dst.ConfigDir != "" {
+		configSrc := filepath.Join(tmp, strings.TrimSuffix(configPrefix, "/"))
+		err := filepath.WalkDir(configSrc, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) && p == configSrc {
+					return fs.SkipDir
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(configSrc, p)
+			if err != nil {
+				return err
+			}
+			res.Config++
+			return stage(p, filepath.Join(dst.ConfigDir, rel))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("restoring config: %w", err)
+		}
+	}
+
+	if dst.Memory {
+		for idx, root := range res.Manifest.Memory {
+			src := filepath.Join(tmp, strings.TrimSuffix(memoryPrefix, "/"), idx, "memory.json")
+			if _, err := os.Stat(src); err != nil {
+				continue
+			}
+			if !isProject(dst.Projects, root) {
+				continue
+			}
+			if info, err := os.Stat(root); err != nil || !info.IsDir() {
+				continue
+			}
+			if err := stage(src, memoryPath(root)); err != nil {
+				return nil, fmt.Errorf("restoring memory: %w", err)
+			}
+			res.Memory = append(res.Memory, root)
+		}
+	}
+
+	if err := swap(staged, dbPath, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// swap moves the current database aside and renames the staged files into
+// place. Every other file being replaced is moved aside first too, so if any
+// step fails each of them, and the previous database, is put back.
+func swap(staged []stagedFile, dbPath string, res *RestoreResult) (err error) {
+	// The WAL moves with the database: a stale one would be replayed over
+	// the restored database, and the previous one may hold its last writes.
+	suffixes := []string{"", "-wal", "-shm"}
+	var moved []string
+	var placed, aside []string // files renamed into place, and the ones they replaced
+	defer func() {
+		if err == nil {
+			for _, dst := range aside {
+				os.Remove(dst + ".before-restore")
+			}
+			return
+		}
+		for _, dst := range placed {
+			os.Remove(dst)
+		}
+		for _, dst := range aside {
+			os.Rename(dst+".before-restore", dst)
+		}
+		if res.Previous == "" {
+			os.Remove(dbPath) // there was no database before
+			return
+		}
+		for _, suffix := range moved {
+			os.Rename(res.Previous+suffix, dbPath+suffix)
+		}
+		res.Previous = ""
+	}()
+
+	if _, statErr := os.Stat(dbPath); statErr == nil {
+		res.Previous = dbPath + ".before-restore"
+		for _, suffix := range suffixes {
+			if err := os.Remove(res.Previous + suffix); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("removing old %s: %w", filepath.Base(res.Previous+suffix), err)
+			}
+		}
+		for _, suffix := range suffixes {
+			if err := os.Rename(dbPath+suffix, res.Previous+suffix); err != nil {
+				if suffix != "" && os.IsNotExist(err) {
+					continue
+				}
+				return fmt.Errorf("moving current database aside: %w", err)
+			}
+			moved = append(moved, suffix)
+		}
+	}
+	for _, suffix := range suffixes[1:] {
+		if err := os.Remove(dbPath + suffix); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", dbName+suffix, err)
+		}
+	}
+	for _, f := range staged {
+		if f.dst != dbPath {
+			// A directory is left where it is; the rename below fails on it.
+			if info, statErr := os.Lstat(f.dst); statErr == nil && !info.IsDir() {
+				if err := os.Rename(f.dst, f.dst+".before-restore"); err != nil {
+					return fmt.Errorf("moving %s aside: %w", f.dst, err)
+				}
+				aside = append(aside, f.dst)
+			}
+		}
+		if err := os.Rename(f.tmp, f.dst); err != nil {
+			return fmt.Errorf("restoring %s: %w", f.dst, err)
+		}
+		if f.dst != dbPath {
+			placed = append(placed, f.dst)
+		}
+	}
+	return nil
+}
+
+// isProject reports whether root is one of the project roots memory may be
+// restored to.
+func isProject(projects []string, root string) bool {
+	if !filepath.IsAbs(root) {
+		return false
+	}
+	root = filepath.Clean(root)
+	for _, p := range projects {
+		if filepath.Clean(p) == root {
+			return true
+		}
+	}
+	return false
+}
+
+// extract unpacks a gzipped tar into dir, rejecting entries that would land
+// outside it.
+func extract(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a muxd backup: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("archive entry %q escapes the backup", hdr.Name)
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return fmt.Errorf("reading archive: %w", err)
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return fmt.Errorf("writing %s: %w", dst, err)
+	}
+	return out.Close()
+}
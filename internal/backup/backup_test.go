@@ -0,0 +1,310 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/sink"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func TestCreateRestore_roundTrip(t *testing.T) {
+	dataDir := t.TempDir()
+	st, err := store.OpenStoreIn(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	project := t.TempDir()
+	writeFile(t, filepath.Join(project, ".muxd", "memory.json"), `{"facts":{"lang":"go"}}`)
+	sess, err := st.CreateSession(project, "claude")
+	if err != nil {
+		t.Fatal(err)
+	}
+	configDir := t.TempDir()
+	writeFile(t, filepath.Join(configDir, "config.json"), `{"model":"x"}`)
+	writeFile(t, filepath.Join(configDir, "commands", "review.md"), "review it")
+
+	src, err := SourcesFor(st, configDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	m, err := Create(&buf, src)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if m.Files != 4 || m.Memory["0"] != project {
+		t.Fatalf("manifest = %+v", m)
+	}
+
+	// Change everything, then restore over it.
+	if err := st.DeleteSession(sess.ID); err != nil {
+		t.Fatal(err)
+	}
+	st.Close()
+	writeFile(t, filepath.Join(project, ".muxd", "memory.json"), `{}`)
+	if err := os.RemoveAll(configDir); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := Restore(bytes.NewReader(buf.Bytes()), Targets{DataDir: dataDir, ConfigDir: configDir, Memory: true, Projects: []string{project}})
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if res.Config != 2 || !slices.Equal(res.Memory, []string{project}) || res.Previous == "" {
+		t.Errorf("result = %+v", res)
+	}
+
+	st2, err := store.OpenStoreIn(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st2.Close()
+	if got, err := st2.GetSession(sess.ID); err != nil || got == nil {
+		t.Errorf("session not restored: %v", err)
+	}
+	if got := readFile(t, filepath.Join(configDir, "commands", "review.md")); got != "review it" {
+		t.Errorf("command = %q", got)
+	}
+	if got := readFile(t, filepath.Join(project, ".muxd", "memory.json")); !strings.Contains(got, "lang") {
+		t.Errorf("memory = %q", got)
+	}
+}
+
+func TestRestore_rejectsBadArchives(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	_ = tw.WriteHeader(&tar.Header{Name: "../evil", Mode: 0o600, Size: 1, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("x"))
+	_ = tw.Close()
+	_ = gz.Close()
+	if _, err := Restore(&buf, Targets{DataDir: t.TempDir()}); err == nil || !strings.Contains(err.Error(), "escapes") {
+		t.Errorf("path traversal: err = %v", err)
+	}
+
+	if _, err := Restore(strings.NewReader("not gzip"), Targets{DataDir: t.TempDir()}); err == nil {
+		t.Error("expected error for a non-archive")
+	}
+}
+
+func TestRestore_memoryOnlyToListedProjects(t *testing.T) {
+	project := t.TempDir()
+	writeFile(t, filepath.Join(project, ".muxd", "memory.json"), `{"facts":{}}`)
+	buf := testArchive(t, project)
+
+	if err := os.Remove(filepath.Join(project, ".muxd", "memory.json")); err != nil {
+		t.Fatal(err)
+	}
+	res, err := Restore(bytes.NewReader(buf), Targets{DataDir: t.TempDir(), Memory: true, Projects: []string{t.TempDir()}})
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if len(res.Memory) != 0 {
+		t.Errorf("restored memory to %v", res.Memory)
+	}
+	if _, err := os.Stat(filepath.Join(project, ".muxd", "memory.json")); !os.IsNotExist(err) {
+		t.Errorf("memory written to a project that was not listed: %v", err)
+	}
+}
+
+func TestRestore_failureKeepsCurrentState(t *testing.T) {
+	buf := testArchive(t, "")
+
+	dataDir := t.TempDir()
+	writeFile(t, filepath.Join(dataDir, "muxd.db"), "current")
+	writeFile(t, filepath.Join(dataDir, "muxd.db-wal"), "current wal")
+
+	t.Run("staging", func(t *testing.T) {
+		// A config dir that is a file cannot be written to.
+		configDir := filepath.Join(t.TempDir(), "config")
+		writeFile(t, configDir, "")
+		if _, err := Restore(bytes.NewReader(buf), Targets{DataDir: dataDir, ConfigDir: configDir}); err == nil {
+			t.Fatal("expected an error")
+		}
+		assertCurrent(t, dataDir)
+	})
+
+	t.Run("swap", func(t *testing.T) {
+		// A directory in place of config.json cannot be renamed over.
+		configDir := t.TempDir()
+		writeFile(t, filepath.Join(configDir, "config.json", "keep"), "")
+		_, err := Restore(bytes.NewReader(buf), Targets{DataDir: dataDir, ConfigDir: configDir})
+		if err == nil || !strings.Contains(err.Error(), "restoring "+filepath.Join(configDir, "config.json")) {
+			t.Fatalf("err = %v", err)
+		}
+		assertCurrent(t, dataDir)
+	})
+
+	t.Run("swap after config", func(t *testing.T) {
+		// Config is renamed into place before memory, which then fails.
+		project := t.TempDir()
+		writeFile(t, filepath.Join(project, ".muxd", "memory.json"), `{"facts":{}}`)
+		buf := testArchive(t, project)
+		if err := os.Remove(filepath.Join(project, ".muxd", "memory.json")); err != nil {
+			t.Fatal(err)
+		}
+		writeFile(t, filepath.Join(project, ".muxd", "memory.json", "keep"), "")
+		configDir := t.TempDir()
+		writeFile(t, filepath.Join(configDir, "config.json"), "current config")
+
+		_, err := Restore(bytes.NewReader(buf), Targets{DataDir: dataDir, ConfigDir: configDir, Memory: true, Projects: []string{project}})
+		if err == nil || !strings.Contains(err.Error(), "restoring "+filepath.Join(project, ".muxd", "memory.json")) {
+			t.Fatalf("err = %v", err)
+		}
+		assertCurrent(t, dataDir)
+		if got := readFile(t, filepath.Join(configDir, "config.json")); got != "current config" {
+			t.Errorf("config = %q", got)
+		}
+		if entries, _ := os.ReadDir(configDir); len(entries) != 1 {
+			t.Errorf("config dir holds %d entries", len(entries))
+		}
+	})
+}
+
+// testArchive returns a backup of a fresh store and a config file, plus the
+// memory of project when it is set.
+func testArchive(t *testing.T, project string) []byte {
+	t.Helper()
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	configDir := t.TempDir()
+	writeFile(t, filepath.Join(configDir, "config.json"), `{"model":"x"}`)
+	src := Sources{Store: st, ConfigDir: configDir}
+	if project != "" {
+		src.Projects = []string{project}
+	}
+	var buf bytes.Buffer
+	if _, err := Create(&buf, src); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// assertCurrent checks that dataDir still holds the database a failed
+// restore started from, and nothing it left behind.
+func assertCurrent(t *testing.T, dataDir string) {
+	t.Helper()
+	if got := readFile(t, filepath.Join(dataDir, "muxd.db")); got != "current" {
+		t.Errorf("database = %q", got)
+	}
+	if got := readFile(t, filepath.Join(dataDir, "muxd.db-wal")); got != "current wal" {
+		t.Errorf("wal = %q", got)
+	}
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("data dir holds %v", names)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range 5 {
+		writeFile(t, filepath.Join(dir, ArchiveName(base.Add(time.Duration(i)*time.Hour))), "x")
+	}
+	writeFile(t, filepath.Join(dir, "notes.txt"), "keep me")
+
+	target := Target{Sink: sink.Dir{Root: dir}}
+	removed, err := Rotate(context.Background(), target, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 || removed[0] != ArchiveName(base) {
+		t.Errorf("removed = %v", removed)
+	}
+	keys, _ := target.Sink.List(context.Background(), "")
+	if len(keys) != 3 || !slices.Contains(keys, "notes.txt") {
+		t.Errorf("left = %v", keys)
+	}
+	latest, _ := Latest(context.Background(), target)
+	if !latest.Equal(base.Add(4 * time.Hour)) {
+		t.Errorf("latest = %v", latest)
+	}
+}
+
+func TestScheduleFromPreferences(t *testing.T) {
+	if _, ok, err := ScheduleFromPreferences(config.Preferences{BackupDir: "/tmp/b"}); ok || err != nil {
+		t.Errorf("no interval: ok=%v err=%v", ok, err)
+	}
+	if _, ok, err := ScheduleFromPreferences(config.Preferences{BackupInterval: "24h"}); ok || err != nil {
+		t.Errorf("no target: ok=%v err=%v", ok, err)
+	}
+	s, ok, err := ScheduleFromPreferences(config.Preferences{BackupInterval: "24h", BackupDir: "/tmp/b", BackupKeep: "3"})
+	if !ok || err != nil || s.Interval != 24*time.Hour || s.Keep != 3 || len(s.Targets) != 1 {
+		t.Errorf("schedule = %+v ok=%v err=%v", s, ok, err)
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, _, err := ScheduleFromPreferences(config.Preferences{BackupInterval: "1h", StorageS3URL: "https://s3.example.com/bucket"}); err == nil {
+		t.Error("expected an error for S3 without credentials")
+	}
+	s, ok, err = ScheduleFromPreferences(config.Preferences{BackupInterval: "1h", StorageS3URL: "https://s3.example.com/bucket", StorageS3AccessKey: "AK", StorageS3SecretKey: "SK"})
+	if !ok || err != nil || len(s.Targets) != 1 || s.Targets[0].Dir != StorageDir {
+		t.Errorf("storage schedule = %+v ok=%v err=%v", s, ok, err)
+	}
+}
+
+func TestScheduler_RunOnce(t *testing.T) {
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	dir := t.TempDir()
+	target := Target{Sink: sink.Dir{Root: dir}, Dir: "nested/"}
+	sched := NewScheduler(st, t.TempDir(), Schedule{Interval: time.Hour, Keep: 2, Targets: []Target{target}})
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, at := range []time.Time{now, now.Add(10 * time.Minute), now.Add(time.Hour), now.Add(2 * time.Hour)} {
+		if err := sched.RunOnce(context.Background(), at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names, _ := target.List(context.Background())
+	slices.Sort(names)
+	want := []string{ArchiveName(now.Add(time.Hour)), ArchiveName(now.Add(2 * time.Hour))}
+	if !slices.Equal(names, want) {
+		t.Errorf("archives = %v, want %v", names, want)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
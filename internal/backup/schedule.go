@@ -0,0 +1,212 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/sink"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// DefaultKeep is how many scheduled backups are kept when backup.keep is
+// not set.
+const DefaultKeep = 7
+
+// schedulerPollInterval is how often the scheduler checks whether a backup
+// is due. Backups are due by the age of the newest archive, so a daemon that
+// restarts often still backs up on time.
+const schedulerPollInterval = time.Minute
+
+// StorageDir is where scheduled backups are kept in the storage sink.
+const StorageDir = "backups/"
+
+// Schedule is a parsed backup.* configuration.
+type Schedule struct {
+	Interval time.Duration
+	Keep     int
+	Targets  []Target
+}
+
+// ScheduleFromPreferences builds the schedule configured in p. It reports
+// false when scheduled backups are off: no interval or no destination.
+func ScheduleFromPreferences(p config.Preferences) (Schedule, bool, error) {
+	if p.BackupInterval == "" {
+		return Schedule{}, false, nil
+	}
+	interval, err := time.ParseDuration(p.BackupInterval)
+	if err != nil {
+		return Schedule{}, false, fmt.Errorf("backup.interval: %w", err)
+	}
+	s := Schedule{Interval: interval, Keep: DefaultKeep}
+	if p.BackupKeep != "" {
+		if s.Keep, err = strconv.Atoi(p.BackupKeep); err != nil || s.Keep < 1 {
+			return Schedule{}, false, fmt.Errorf("backup.keep: invalid value %q", p.BackupKeep)
+		}
+	}
+	if p.BackupDir != "" {
+		s.Targets = append(s.Targets, Target{Sink: sink.Dir{Root: expandHome(p.BackupDir)}})
+	}
+	storage, err := sink.FromPreferences(p)
+	if err != nil {
+		return Schedule{}, false, err
+	}
+	if storage != nil {
+		s.Targets = append(s.Targets, Target{Sink: storage, Dir: StorageDir})
+	}
+	if len(s.Targets) == 0 {
+		return Schedule{}, false, nil
+	}
+	return s, true, nil
+}
+
+func expandHome(p string) string {
+	if rest, ok := strings.CutPrefix(p, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return p
+}
+
+// ---------------------------------------------------------------------------
+// Scheduler
+// ---------------------------------------------------------------------------
+
+// Scheduler takes a backup whenever the newest archive in a target is older
+// than the interval, then rotates each target.
+type Scheduler struct {
+	store     *store.Store
+	configDir string
+	schedule  Schedule
+	logf      func(string, ...any)
+
+	mu     sync.Mutex
+	last   map[string]time.Time // newest archive per target, by String()
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewScheduler creates a scheduler backing up st and configDir.
+func NewScheduler(st *store.Store, configDir string, s Schedule) *Scheduler {
+	return &Scheduler{
+		store:     st,
+		configDir: configDir,
+		schedule:  s,
+		logf:      func(string, ...any) {},
+		last:      make(map[string]time.Time),
+	}
+}
+
+// SetLogFunc sets a logging function for background errors.
+func (s *Scheduler) SetLogFunc(fn func(string, ...any)) {
+	if fn != nil {
+		s.logf = fn
+	}
+}
+
+// Start begins the background loop.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		ticker := time.NewTicker(schedulerPollInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.RunOnce(ctx, time.Now()); err != nil {
+				s.logf("backup: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the loop, waiting for a backup in progress to be abandoned.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// RunOnce backs up to every target whose newest archive is older than the
+// interval at now.
+func (s *Scheduler) RunOnce(ctx context.Context, now time.Time) error {
+	var due []Target
+	for _, t := range s.schedule.Targets {
+		last, ok := s.last[t.String()]
+		if !ok {
+			var err error
+			if last, err = Latest(ctx, t); err != nil {
+				return fmt.Errorf("%s: %w", t, err)
+			}
+			s.last[t.String()] = last
+		}
+		if now.Sub(last) >= s.schedule.Interval {
+			due = append(due, t)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp("", "muxd-backup-*.tar.gz")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	src, err := SourcesFor(s.store, s.configDir)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := Create(tmp, src); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	name := ArchiveName(now)
+	var errs []string
+	for _, t := range due {
+		if err := t.Put(ctx, name, tmp.Name()); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", t, err))
+			continue
+		}
+		s.last[t.String()] = now
+		s.logf("backup: wrote %s to %s", name, t)
+		removed, err := Rotate(ctx, t, s.schedule.Keep)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: rotating: %v", t, err))
+		} else if len(removed) > 0 {
+			s.logf("backup: removed %d old backup(s) from %s", len(removed), t)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package backup
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/sink"
+)
+
+// Archive names sort by the time they were taken.
+const (
+	namePrefix = "muxd-backup-"
+	nameSuffix = ".tar.gz"
+	nameLayout = "20060102-150405"
+)
+
+// ArchiveName returns the file name for a backup taken at t.
+func ArchiveName(t time.Time) string {
+	return namePrefix + t.UTC().Format(nameLayout) + nameSuffix
+}
+
+// archiveTime parses the time out of an ArchiveName, reporting false for
+// other files.
+func archiveTime(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, namePrefix) || !strings.HasSuffix(name, nameSuffix) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(nameLayout, strings.TrimSuffix(strings.TrimPrefix(name, namePrefix), nameSuffix))
+	return t, err == nil
+}
+
+// Target is where scheduled backups are kept: a sink and the key prefix
+// within it.
+type Target struct {
+	Sink sink.Sink
+	Dir  string // key prefix, empty or ending in "/"
+}
+
+func (t Target) String() string { return strings.TrimSuffix(t.Sink.String(), "/") + "/" + t.Dir }
+
+// Put stores the local archive at path under name.
+func (t Target) Put(ctx context.Context, name, path string) error {
+	return sink.PutFile(ctx, t.Sink, t.Dir+name, path)
+}
+
+// List returns the names of the archives stored, in any order.
+func (t Target) List(ctx context.Context) ([]string, error) {
+	keys, err := t.Sink.List(ctx, t.Dir+namePrefix)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, k := range keys {
+		if name := strings.TrimPrefix(k, t.Dir); !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// Rotate deletes the oldest archives in t so at most keep remain. Files that
+// are not muxd archives are left alone.
+func Rotate(ctx context.Context, t Target, keep int) ([]string, error) {
+	names, err := t.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var archives []string
+	for _, n := range names {
+		if _, ok := archiveTime(n); ok {
+			archives = append(archives, n)
+		}
+	}
+	if len(archives) <= keep {
+		return nil, nil
+	}
+	sort.Strings(archives)
+	stale := archives[:len(archives)-keep]
+	for _, n := range stale {
+		if err := t.Sink.Delete(ctx, t.Dir+n); err != nil {
+			return nil, err
+		}
+	}
+	return stale, nil
+}
+
+// Latest returns when the newest archive in t was taken, or the zero time.
+func Latest(ctx context.Context, t Target) (time.Time, error) {
+	names, err := t.List(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	var latest time.Time
+	for _, n := range names {
+		if at, ok := archiveTime(n); ok && at.After(latest) {
+			latest = at
+		}
+	}
+	return latest, nil
+}
@@ -2,17 +2,20 @@ package checkpoint
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 )
 
 // Checkpoint represents a snapshot of the working tree taken before an agent
 // turn executes tools. The SHA is a git stash commit object created with
-// `git stash create --include-untracked`.
+// `git stash create --include-untracked`, or, outside git, a file snapshot
+// (see FilePrefix).
 type Checkpoint struct {
 	TurnNumber int
-	SHA        string // git stash commit SHA (empty if tree was clean)
+	SHA        string // git stash commit SHA (empty if tree was clean) or file snapshot
 	IsClean    bool   // true = working tree matched HEAD, no stash needed
 }
 
@@ -24,7 +27,13 @@ type Checkpoint struct {
 // Stderr is captured separately so that warnings (e.g. CRLF on Windows)
 // don't corrupt the stdout result.
 func GitRun(args ...string) (string, error) {
+	return GitRunIn("", args...)
+}
+
+// GitRunIn is GitRun in the repo at dir; an empty dir uses the cwd.
+func GitRunIn(dir string, args ...string) (string, error) {
 	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
@@ -81,12 +90,7 @@ func GitDeleteRef(ref string) error {
 // The checkout step is skipped if there are no tracked files (e.g. initial
 // empty commit) since git checkout -- . would error in that case.
 func GitRestoreClean() error {
-	_, err := GitRun("checkout", "--", ".")
-	if err != nil && !strings.Contains(err.Error(), "did not match") {
-		return err
-	}
-	_, err = GitRun("clean", "-fd")
-	return err
+	return restore("", "")
 }
 
 // GitStashApply applies a stash commit (by SHA) to the working tree,
@@ -95,3 +99,127 @@ func GitStashApply(sha string) error {
 	_, err := GitRun("stash", "apply", "--index", sha)
 	return err
 }
+
+// ---------------------------------------------------------------------------
+// Snapshots
+// ---------------------------------------------------------------------------
+
+// Create snapshots the working tree of the repo at dir and anchors the
+// stash commit at refs/muxd/<session prefix>/<name>, so git gc keeps it
+// until checkpoint GC removes the ref. A clean tree gives a checkpoint with
+// IsClean set and no ref.
+func Create(dir, sessionID, name string) (Checkpoint, error) {
+	sha, err := GitRunIn(dir, "stash", "create", "--include-untracked")
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	if sha == "" {
+		return Checkpoint{IsClean: true}, nil
+	}
+	if _, err := GitRunIn(dir, "update-ref", refName(sessionID, name), sha); err != nil {
+		return Checkpoint{}, fmt.Errorf("anchoring checkpoint: %w", err)
+	}
+	return Checkpoint{SHA: sha}, nil
+}
+
+// Undo returns the working tree at dir to cp. The tree it replaces is
+// snapshotted first, anchored at refs/muxd/<session prefix>/redo-<name>,
+// and its SHA returned for Redo; it is empty when that tree was clean.
+// For a file snapshot, only the files it holds are saved and restored.
+func Undo(dir, sessionID, name string, cp Checkpoint) (redoSHA string, err error) {
+	if IsFileSnapshot(cp.SHA) {
+		return undoFiles(cp.SHA)
+	}
+	redo, err := Create(dir, sessionID, "redo-"+name)
+	if err != nil {
+		return "", fmt.Errorf("saving redo state: %w", err)
+	}
+	if err := restore(dir, cp.SHA); err != nil {
+		return "", fmt.Errorf("restoring checkpoint: %w", err)
+	}
+	return redo.SHA, nil
+}
+
+// Redo returns the working tree at dir to the state Undo replaced.
+func Redo(dir, redoSHA string) error {
+	if IsFileSnapshot(redoSHA) {
+		return redoFiles(redoSHA)
+	}
+	if err := restore(dir, redoSHA); err != nil {
+		return fmt.Errorf("applying redo state: %w", err)
+	}
+	return nil
+}
+
+// Diff returns a unified diff of what restoring cp would do to the working
+// tree at dir: its tracked changes against the snapshot (or HEAD, for a
+// clean one), then the untracked files restoring would delete, less any
+// the snapshot brings back. It assumes HEAD has not moved since cp was
+// taken; if it has, the snapshot is applied on top of the new HEAD and the
+// result can differ. A file snapshot's diff covers only the files it holds.
+func Diff(dir string, cp Checkpoint) (string, error) {
+	if IsFileSnapshot(cp.SHA) {
+		return diffFiles(dir, cp.SHA)
+	}
+	target := cp.SHA
+	if target == "" {
+		target = "HEAD"
+	}
+	tracked, err := GitRunIn(dir, "diff", "--no-color", "--no-ext-diff", "-R", target, "--", ".")
+	if err != nil {
+		return "", err
+	}
+	parts := []string{tracked}
+
+	kept := map[string]bool{}
+	if cp.SHA != "" {
+		// A stash made with untracked files keeps them in its third parent.
+		if out, err := GitRunIn(dir, "ls-tree", "-r", "--name-only", cp.SHA+"^3"); err == nil {
+			for _, f := range strings.Split(out, "\n") {
+				kept[f] = true
+			}
+		}
+	}
+	untracked, err := GitRunIn(dir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return "", err
+	}
+	for _, f := range strings.Split(untracked, "\n") {
+		if f == "" || kept[f] {
+			continue
+		}
+		// --no-index exits 1 when the files differ, which they always do here.
+		out, err := GitRunIn(dir, "diff", "--no-color", "--no-ext-diff", "--no-index", "--", f, os.DevNull)
+		var exitErr *exec.ExitError
+		if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+			return "", err
+		}
+		parts = append(parts, out)
+	}
+	return strings.TrimSpace(strings.Join(parts, "\n")), nil
+}
+
+// restore resets the working tree at dir to HEAD and applies the stash
+// commit sha on top; an empty sha leaves the clean tree.
+func restore(dir, sha string) error {
+	_, err := GitRunIn(dir, "checkout", "--", ".")
+	if err != nil && !strings.Contains(err.Error(), "did not match") {
+		return err
+	}
+	if _, err := GitRunIn(dir, "clean", "-fd"); err != nil {
+		return err
+	}
+	if sha == "" {
+		return nil
+	}
+	_, err = GitRunIn(dir, "stash", "apply", "--index", sha)
+	return err
+}
+
+func refName(sessionID, name string) string {
+	prefix := sessionID
+	if len(prefix) > sessionPrefixLen {
+		prefix = prefix[:sessionPrefixLen]
+	}
+	return RefRoot + prefix + "/" + name
+}
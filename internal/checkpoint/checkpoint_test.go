@@ -421,3 +421,101 @@ func TestUndoWithUntrackedFiles(t *testing.T) {
 		}
 	})
 }
+
+func TestCreateUndoRedo(t *testing.T) {
+	dir := initTestRepo(t)
+	// Run from elsewhere: everything must go through dir.
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "a.txt")
+	read := func() string {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	os.WriteFile(file, []byte("base"), 0o644)
+	for _, args := range [][]string{{"add", "a.txt"}, {"commit", "-m", "add a"}} {
+		if _, err := GitRunIn(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clean, err := Create(dir, "0123456789abcdef", "1")
+	if err != nil || !clean.IsClean {
+		t.Fatalf("Create on a clean tree = %+v, %v", clean, err)
+	}
+
+	os.WriteFile(file, []byte("one"), 0o644)
+	cp, err := Create(dir, "0123456789abcdef", "2")
+	if err != nil || cp.SHA == "" {
+		t.Fatalf("Create = %+v, %v", cp, err)
+	}
+	if sha, err := GitRunIn(dir, "rev-parse", "refs/muxd/01234567/2"); err != nil || sha != cp.SHA {
+		t.Errorf("checkpoint ref = %q, %v", sha, err)
+	}
+
+	os.WriteFile(file, []byte("two"), 0o644)
+	redo, err := Undo(dir, "0123456789abcdef", "2", cp)
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if got := read(); got != "one" {
+		t.Errorf("after undo a.txt = %q, want one", got)
+	}
+	if _, err := GitRunIn(dir, "rev-parse", "refs/muxd/01234567/redo-2"); err != nil {
+		t.Errorf("redo ref missing: %v", err)
+	}
+
+	if err := Redo(dir, redo); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+	if got := read(); got != "two" {
+		t.Errorf("after redo a.txt = %q, want two", got)
+	}
+
+	if _, err := Undo(dir, "0123456789abcdef", "1", clean); err != nil {
+		t.Fatalf("Undo to clean: %v", err)
+	}
+	if got := read(); got != "base" {
+		t.Errorf("after undo to a clean tree a.txt = %q, want base", got)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	dir := initTestRepo(t)
+	file := filepath.Join(dir, "a.txt")
+	os.WriteFile(file, []byte("base\n"), 0o644)
+	for _, args := range [][]string{{"add", "a.txt"}, {"commit", "-m", "add a"}} {
+		if _, err := GitRunIn(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.WriteFile(file, []byte("one\n"), 0o644)
+	cp, err := Create(dir, "0123456789abcdef", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(file, []byte("two\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "new.txt"), []byte("scratch\n"), 0o644)
+	diff, err := Diff(dir, cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"-two", "+one", "+++ /dev/null", "-scratch"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+	if got, _ := os.ReadFile(file); string(got) != "two\n" {
+		t.Errorf("Diff changed the working tree: a.txt = %q", got)
+	}
+
+	diff, err = Diff(dir, Checkpoint{IsClean: true})
+	if err != nil || !strings.Contains(diff, "-two") || !strings.Contains(diff, "+base") {
+		t.Errorf("diff to a clean checkpoint = %q, %v", diff, err)
+	}
+}
@@ -0,0 +1,324 @@
+package checkpoint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/diff"
+)
+
+// FilePrefix marks a checkpoint SHA that names a file snapshot rather than
+// a git stash commit. File snapshots back checkpoints outside git
+// repositories: they hold copies of the files a step's write tools were
+// about to change, kept in a content-addressed store under the data dir.
+const FilePrefix = "files:"
+
+// IsFileSnapshot reports whether sha names a file snapshot.
+func IsFileSnapshot(sha string) bool {
+	return strings.HasPrefix(sha, FilePrefix)
+}
+
+// snapshotDir returns the object store file snapshots are kept in.
+// Override in tests.
+var snapshotDir = func() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "checkpoints"), nil
+}
+
+// fileEntry is one file in a snapshot. An empty Hash means the file did
+// not exist, so restoring the snapshot deletes it.
+type fileEntry struct {
+	Hash string      `json:"hash,omitempty"`
+	Mode fs.FileMode `json:"mode,omitempty"`
+}
+
+// manifest maps absolute paths to their snapshotted state.
+type manifest map[string]fileEntry
+
+// CreateFiles snapshots paths, which are resolved against dir when
+// relative. Files that do not exist are recorded as absent. No paths give
+// a checkpoint with IsClean set and nothing stored.
+func CreateFiles(dir string, paths []string) (Checkpoint, error) {
+	if len(paths) == 0 {
+		return Checkpoint{IsClean: true}, nil
+	}
+	root, err := snapshotDir()
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	m := manifest{}
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		p = filepath.Clean(p)
+		if _, ok := m[p]; ok {
+			continue
+		}
+		entry, err := snapshotFile(root, p)
+		if err != nil {
+			return Checkpoint{}, err
+		}
+		m[p] = entry
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	hash, err := putObject(root, data)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("saving snapshot: %w", err)
+	}
+	return Checkpoint{SHA: FilePrefix + hash}, nil
+}
+
+func snapshotFile(root, path string) (fileEntry, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fileEntry{}, nil
+	}
+	if err != nil {
+		return fileEntry{}, err
+	}
+	if info.IsDir() {
+		return fileEntry{}, fmt.Errorf("%s is a directory", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fileEntry{}, err
+	}
+	hash, err := putObject(root, data)
+	if err != nil {
+		return fileEntry{}, fmt.Errorf("saving %s: %w", path, err)
+	}
+	return fileEntry{Hash: hash, Mode: info.Mode().Perm()}, nil
+}
+
+// undoFiles snapshots the files in the snapshot sha covers as they are
+// now, for redo, then restores them from sha.
+func undoFiles(sha string) (redoSHA string, err error) {
+	root, err := snapshotDir()
+	if err != nil {
+		return "", err
+	}
+	m, err := loadManifest(root, sha)
+	if err != nil {
+		return "", err
+	}
+	redo, err := CreateFiles("", m.paths())
+	if err != nil {
+		return "", fmt.Errorf("saving redo state: %w", err)
+	}
+	if err := restoreFiles(root, m); err != nil {
+		return "", fmt.Errorf("restoring checkpoint: %w", err)
+	}
+	return redo.SHA, nil
+}
+
+// redoFiles restores the files in the snapshot undoFiles saved.
+func redoFiles(sha string) error {
+	root, err := snapshotDir()
+	if err != nil {
+		return err
+	}
+	m, err := loadManifest(root, sha)
+	if err != nil {
+		return err
+	}
+	if err := restoreFiles(root, m); err != nil {
+		return fmt.Errorf("applying redo state: %w", err)
+	}
+	return nil
+}
+
+func restoreFiles(root string, m manifest) error {
+	for _, path := range m.paths() {
+		entry := m[path]
+		if entry.Hash == "" {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			continue
+		}
+		data, err := os.ReadFile(objectPath(root, entry.Hash))
+		if err != nil {
+			return fmt.Errorf("reading snapshot of %s: %w", path, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		mode := entry.Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if err := os.WriteFile(path, data, mode); err != nil {
+			return err
+		}
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffFiles is Diff for a file snapshot. Paths under dir are shown
+// relative to it.
+func diffFiles(dir, sha string) (string, error) {
+	root, err := snapshotDir()
+	if err != nil {
+		return "", err
+	}
+	m, err := loadManifest(root, sha)
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, path := range m.paths() {
+		current, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		var saved []byte
+		if h := m[path].Hash; h != "" {
+			if saved, err = os.ReadFile(objectPath(root, h)); err != nil {
+				return "", fmt.Errorf("reading snapshot of %s: %w", path, err)
+			}
+		}
+		name := path
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = filepath.ToSlash(rel)
+		}
+		if bytes.IndexByte(current, 0) >= 0 || bytes.IndexByte(saved, 0) >= 0 {
+			if !bytes.Equal(current, saved) {
+				parts = append(parts, "Binary file "+name+" differs")
+			}
+			continue
+		}
+		if d := diff.ComputeUnifiedDiff(string(current), string(saved), name); d != "" {
+			parts = append(parts, strings.TrimRight(d, "\n"))
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+func (m manifest) paths() []string {
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func loadManifest(root, sha string) (manifest, error) {
+	data, err := os.ReadFile(objectPath(root, strings.TrimPrefix(sha, FilePrefix)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("snapshot %s not found (removed by gc?)", sha)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", sha, err)
+	}
+	return m, nil
+}
+
+// ---------------------------------------------------------------------------
+// Object store
+// ---------------------------------------------------------------------------
+
+// putObject stores data under its SHA-256 and returns the hash. An object
+// that is already stored has its modification time refreshed instead, so
+// age-based GC keeps objects still in use.
+func putObject(root string, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	path := objectPath(root, hash)
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		return hash, os.Chtimes(path, now, now)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return hash, nil
+}
+
+func objectPath(root, hash string) string {
+	if len(hash) < 3 {
+		return filepath.Join(root, "objects", hash)
+	}
+	return filepath.Join(root, "objects", hash[:2], hash[2:])
+}
+
+// GCFiles removes file snapshot objects not used for longer than
+// opts.MaxAge. With no MaxAge it keeps everything. The result's Repo is
+// the object store and Removed lists object hashes.
+func GCFiles(opts GCOptions) RepoGC {
+	root, err := snapshotDir()
+	if err != nil {
+		return RepoGC{Err: err}
+	}
+	res := RepoGC{Repo: root}
+	objects := filepath.Join(root, "objects")
+	if _, err := os.Stat(objects); err != nil {
+		return res
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	res.Err = filepath.WalkDir(objects, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if opts.MaxAge <= 0 || now.Sub(info.ModTime()) <= opts.MaxAge {
+			res.Kept++
+			return nil
+		}
+		if !opts.DryRun {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		rel, _ := filepath.Rel(objects, path)
+		res.Removed = append(res.Removed, strings.ReplaceAll(filepath.ToSlash(rel), "/", ""))
+		return nil
+	})
+	return res
+}
@@ -0,0 +1,129 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useSnapshotDir points the file snapshot store at a temp directory.
+func useSnapshotDir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	orig := snapshotDir
+	snapshotDir = func() (string, error) { return root, nil }
+	t.Cleanup(func() { snapshotDir = orig })
+	return root
+}
+
+func TestCreateFilesUndoRedo(t *testing.T) {
+	useSnapshotDir(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	added := filepath.Join(dir, "sub", "new.txt")
+	read := func(path string) string {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "<missing>"
+		}
+		return string(data)
+	}
+	os.WriteFile(file, []byte("one\n"), 0o600)
+
+	if cp, err := CreateFiles(dir, nil); err != nil || !cp.IsClean {
+		t.Fatalf("CreateFiles with no paths = %+v, %v", cp, err)
+	}
+	cp, err := CreateFiles(dir, []string{"a.txt", "sub/new.txt", file})
+	if err != nil {
+		t.Fatalf("CreateFiles: %v", err)
+	}
+	if !IsFileSnapshot(cp.SHA) {
+		t.Fatalf("SHA = %q, want a file snapshot", cp.SHA)
+	}
+
+	os.WriteFile(file, []byte("two\n"), 0o600)
+	os.MkdirAll(filepath.Dir(added), 0o755)
+	os.WriteFile(added, []byte("scratch\n"), 0o644)
+
+	diff, err := Diff(dir, cp)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	for _, want := range []string{"a/a.txt", "-two", "+one", "sub/new.txt", "-scratch"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff missing %q:\n%s", want, diff)
+		}
+	}
+
+	redo, err := Undo(dir, "0123456789abcdef", "1", cp)
+	if err != nil {
+		t.Fatalf("Undo: %v", err)
+	}
+	if got := read(file); got != "one\n" {
+		t.Errorf("after undo a.txt = %q, want one", got)
+	}
+	if got := read(added); got != "<missing>" {
+		t.Errorf("after undo new.txt = %q, want it removed", got)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("after undo a.txt mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+
+	if err := Redo(dir, redo); err != nil {
+		t.Fatalf("Redo: %v", err)
+	}
+	if got := read(file); got != "two\n" {
+		t.Errorf("after redo a.txt = %q, want two", got)
+	}
+	if got := read(added); got != "scratch\n" {
+		t.Errorf("after redo new.txt = %q, want scratch", got)
+	}
+}
+
+func TestGCFiles(t *testing.T) {
+	root := useSnapshotDir(t)
+	if res := GCFiles(GCOptions{MaxAge: time.Hour}); res.Kept+len(res.Removed) != 0 || res.Err != nil {
+		t.Fatalf("GCFiles on an empty store = %+v", res)
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("old"), 0o644)
+	old, err := CreateFiles(dir, []string{"a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Age everything stored so far, then take a snapshot that reuses nothing.
+	past := time.Now().Add(-48 * time.Hour)
+	filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			os.Chtimes(path, past, past)
+		}
+		return nil
+	})
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("new"), 0o644)
+	fresh, err := CreateFiles(dir, []string{"a.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dry := GCFiles(GCOptions{MaxAge: 24 * time.Hour, DryRun: true})
+	if len(dry.Removed) != 2 || dry.Kept != 2 {
+		t.Fatalf("dry run = %+v, want 2 removed (old blob and manifest), 2 kept", dry)
+	}
+	if _, err := Diff(dir, old); err != nil {
+		t.Fatalf("dry run removed objects: %v", err)
+	}
+
+	res := GCFiles(GCOptions{MaxAge: 24 * time.Hour})
+	if res.Err != nil || len(res.Removed) != 2 {
+		t.Fatalf("GCFiles = %+v", res)
+	}
+	if _, err := Diff(dir, old); err == nil {
+		t.Error("old snapshot should be gone")
+	}
+	if _, err := Diff(dir, fresh); err != nil {
+		t.Errorf("fresh snapshot: %v", err)
+	}
+}
@@ -0,0 +1,194 @@
+package checkpoint
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// RefRoot is the namespace checkpoint refs live under:
+// refs/muxd/<session ID prefix>/<turn> and .../redo-<turn>.
+const RefRoot = "refs/muxd/"
+
+// sessionPrefixLen is how much of a session ID names its refs.
+const sessionPrefixLen = 8
+
+// Ref is a checkpoint ref and the time its stash commit was made.
+type Ref struct {
+	Name      string
+	Session   string // session ID prefix from the ref name
+	CreatedAt time.Time
+}
+
+// ListRefs returns the checkpoint refs in the repo at dir.
+func ListRefs(dir string) ([]Ref, error) {
+	out, err := GitRunIn(dir, "for-each-ref", "--format=%(refname) %(committerdate:unix)", RefRoot)
+	if err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	for _, line := range strings.Split(out, "\n") {
+		name, unix, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		session, _, _ := strings.Cut(strings.TrimPrefix(name, RefRoot), "/")
+		ref := Ref{Name: name, Session: session}
+		if sec, err := strconv.ParseInt(unix, 10, 64); err == nil {
+			ref.CreatedAt = time.Unix(sec, 0)
+		}
+		refs = append(refs, ref)
+	}
+	return refs, nil
+}
+
+// ---------------------------------------------------------------------------
+// Garbage collection
+// ---------------------------------------------------------------------------
+
+// GCOptions controls which checkpoint refs GC removes.
+type GCOptions struct {
+	MaxAge time.Duration // refs older than this are removed; 0 keeps them by age
+	Now    time.Time     // zero means time.Now()
+	DryRun bool          // report what would be removed without deleting
+}
+
+// RepoGC is the outcome of collecting one repo.
+type RepoGC struct {
+	Repo    string
+	Removed []string
+	Kept    int
+	Err     error
+}
+
+// GCRepo removes the checkpoint refs in the repo at root whose session is not
+// in live or that are older than opts.MaxAge. A nil live set skips the session
+// check, so only age is considered. The stash commits the refs pointed at
+// become unreachable and are pruned by git's own gc.
+func GCRepo(root string, live map[string]bool, opts GCOptions) RepoGC {
+	res := RepoGC{Repo: root}
+	refs, err := ListRefs(root)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	for _, ref := range refs {
+		stale := live != nil && !live[ref.Session]
+		if opts.MaxAge > 0 && !ref.CreatedAt.IsZero() && now.Sub(ref.CreatedAt) > opts.MaxAge {
+			stale = true
+		}
+		if !stale {
+			res.Kept++
+			continue
+		}
+		if !opts.DryRun {
+			if _, err := GitRunIn(root, "update-ref", "-d", ref.Name); err != nil {
+				res.Err = err
+				res.Kept++
+				continue
+			}
+		}
+		res.Removed = append(res.Removed, ref.Name)
+	}
+	return res
+}
+
+// GC collects checkpoint refs in every git repo that has sessions in st.
+// A ref is stale when its session no longer exists in st, in the repo's own
+// project database, or in its per-project daemon's database, or when it is older than opts.MaxAge. File
+// snapshots are collected by age alone, as the last result (see GCFiles).
+func GC(st *store.Store, opts GCOptions) ([]RepoGC, error) {
+	paths, err := st.ProjectPaths()
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+	ids, err := st.SessionIDs()
+	if err != nil {
+		return nil, fmt.Errorf("listing sessions: %w", err)
+	}
+	seen := map[string]bool{}
+	var results []RepoGC
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		root, err := GitRunIn(p, "rev-parse", "--show-toplevel")
+		if err != nil || seen[root] {
+			continue
+		}
+		seen[root] = true
+		results = append(results, GCRepo(root, liveSessions(root, ids), opts))
+	}
+	if files := GCFiles(opts); files.Err != nil || files.Kept+len(files.Removed) > 0 {
+		results = append(results, files)
+	}
+	return results, nil
+}
+
+// liveSessions returns the ref prefixes of the sessions that may own
+// checkpoints in root: those in the global database, the repo's project
+// database, and the per-project daemon's database in the data dir. It
+// returns nil (age-only GC) when either of the latter exists but cannot be
+// read, so its sessions are never mistaken for deleted ones.
+func liveSessions(root string, ids []string) map[string]bool {
+	live := map[string]bool{}
+	add := func(ids []string) {
+		for _, id := range ids {
+			live[refPrefix(id)] = true
+		}
+	}
+	add(ids)
+	dirs := []string{filepath.Join(root, store.ProjectDBDir)}
+	dataDir, err := projectDataDir(root)
+	if err != nil {
+		return nil
+	}
+	dirs = append(dirs, dataDir)
+	for _, dir := range dirs {
+		ids, ok := sessionIDsIn(dir)
+		if !ok {
+			return nil
+		}
+		add(ids)
+	}
+	return live
+}
+
+// projectDataDir is where a per-project daemon for root keeps its database.
+// Override in tests.
+var projectDataDir = config.ProjectDataDir
+
+// sessionIDsIn returns the session IDs in the muxd.db in dir, if there is
+// one. ok is false when the database exists but cannot be read.
+func sessionIDsIn(dir string) (ids []string, ok bool) {
+	if _, err := os.Stat(filepath.Join(dir, "muxd.db")); err != nil {
+		return nil, true
+	}
+	st, err := store.OpenStoreIn(dir)
+	if err != nil {
+		return nil, false
+	}
+	defer func() { _ = st.Close() }()
+	ids, err = st.SessionIDs()
+	if err != nil {
+		return nil, false
+	}
+	return ids, true
+}
+
+func refPrefix(id string) string {
+	if len(id) > sessionPrefixLen {
+		return id[:sessionPrefixLen]
+	}
+	return id
+}
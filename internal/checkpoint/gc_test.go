@@ -0,0 +1,216 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// makeRef snapshots a dirty working tree under name and returns the ref.
+func makeRef(t *testing.T, name string) string {
+	t.Helper()
+	if _, err := os.Stat("work.txt"); err != nil {
+		if err := os.WriteFile("work.txt", nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := GitRun("add", "work.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := GitRun("commit", "-m", "work"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile("work.txt", []byte(name), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sha, err := GitStashCreate()
+	if err != nil || sha == "" {
+		t.Fatalf("stash create: %q %v", sha, err)
+	}
+	ref := RefRoot + name
+	if err := GitUpdateRef(ref, sha); err != nil {
+		t.Fatal(err)
+	}
+	return ref
+}
+
+func refNames(t *testing.T, dir string) []string {
+	t.Helper()
+	refs, err := ListRefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, r := range refs {
+		names = append(names, r.Name)
+	}
+	return names
+}
+
+func TestListRefs(t *testing.T) {
+	dir := initTestRepo(t)
+	makeRef(t, "abcd1234/1")
+	makeRef(t, "abcd1234/redo-1")
+
+	refs, err := ListRefs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 refs, got %+v", refs)
+	}
+	for _, r := range refs {
+		if r.Session != "abcd1234" {
+			t.Errorf("session = %q, want abcd1234", r.Session)
+		}
+		if time.Since(r.CreatedAt) > time.Hour {
+			t.Errorf("unexpected created time %v", r.CreatedAt)
+		}
+	}
+}
+
+func TestGCRepo(t *testing.T) {
+	t.Run("removes refs of deleted sessions", func(t *testing.T) {
+		dir := initTestRepo(t)
+		makeRef(t, "live0000/1")
+		gone := makeRef(t, "gone0000/1")
+
+		res := GCRepo(dir, map[string]bool{"live0000": true}, GCOptions{})
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if len(res.Removed) != 1 || res.Removed[0] != gone || res.Kept != 1 {
+			t.Fatalf("unexpected result %+v", res)
+		}
+		if got := refNames(t, dir); len(got) != 1 || got[0] != RefRoot+"live0000/1" {
+			t.Fatalf("remaining refs = %v", got)
+		}
+	})
+
+	t.Run("removes refs older than max age", func(t *testing.T) {
+		dir := initTestRepo(t)
+		makeRef(t, "live0000/1")
+
+		opts := GCOptions{MaxAge: 24 * time.Hour, Now: time.Now().Add(48 * time.Hour)}
+		res := GCRepo(dir, map[string]bool{"live0000": true}, opts)
+		if len(res.Removed) != 1 || res.Kept != 0 {
+			t.Fatalf("unexpected result %+v", res)
+		}
+	})
+
+	t.Run("nil live set only checks age", func(t *testing.T) {
+		dir := initTestRepo(t)
+		makeRef(t, "gone0000/1")
+
+		res := GCRepo(dir, nil, GCOptions{MaxAge: 24 * time.Hour})
+		if len(res.Removed) != 0 || res.Kept != 1 {
+			t.Fatalf("unexpected result %+v", res)
+		}
+	})
+
+	t.Run("dry run keeps refs", func(t *testing.T) {
+		dir := initTestRepo(t)
+		makeRef(t, "gone0000/1")
+
+		res := GCRepo(dir, map[string]bool{}, GCOptions{DryRun: true})
+		if len(res.Removed) != 1 {
+			t.Fatalf("expected 1 ref reported, got %+v", res)
+		}
+		if got := refNames(t, dir); len(got) != 1 {
+			t.Fatalf("dry run deleted refs: %v", got)
+		}
+	})
+}
+
+// useProjectDataDir points per-project daemon databases at a temp dir and
+// returns the one for every root.
+func useProjectDataDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	orig := projectDataDir
+	projectDataDir = func(string) (string, error) { return dir, nil }
+	t.Cleanup(func() { projectDataDir = orig })
+	return dir
+}
+
+func TestGC(t *testing.T) {
+	useSnapshotDir(t)
+	useProjectDataDir(t)
+	dir := initTestRepo(t)
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	sess, err := st.CreateSession(dir, "model")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := makeRef(t, sess.ID[:8]+"/1")
+	makeRef(t, "deadbeef/1")
+
+	results, err := GC(st, GCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Removed) != 1 || !strings.HasSuffix(results[0].Removed[0], "deadbeef/1") {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if got := refNames(t, dir); len(got) != 1 || got[0] != keep {
+		t.Fatalf("remaining refs = %v", got)
+	}
+}
+
+func TestGC_perProjectDaemonSessions(t *testing.T) {
+	useSnapshotDir(t)
+	dataDir := useProjectDataDir(t)
+	dir := initTestRepo(t)
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	if _, err := st.CreateSession(dir, "model"); err != nil {
+		t.Fatal(err)
+	}
+	pst, err := store.OpenStoreIn(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sess, err := pst.CreateSession(dir, "model")
+	pst.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keep := makeRef(t, sess.ID[:8]+"/1")
+	makeRef(t, "deadbeef/1")
+
+	results, err := GC(st, GCOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Removed) != 1 || !strings.HasSuffix(results[0].Removed[0], "deadbeef/1") {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	if got := refNames(t, dir); len(got) != 1 || got[0] != keep {
+		t.Fatalf("remaining refs = %v", got)
+	}
+
+	t.Run("unreadable database only checks age", func(t *testing.T) {
+		if err := os.WriteFile(filepath.Join(dataDir, "muxd.db"), []byte("not a database"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		makeRef(t, "gone0000/1")
+		results, err := GC(st, GCOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 1 || len(results[0].Removed) != 0 {
+			t.Fatalf("unexpected results %+v", results)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+package config
+
+// ComplianceBuild reports whether this binary was built with the
+// muxd_compliance tag.
+func ComplianceBuild() bool { return complianceBuild }
+
+// ComplianceEnabled reports whether external messaging and posting
+// integrations must be disabled, either by build tag or by preference.
+func ComplianceEnabled(p Preferences) bool {
+	return complianceBuild || p.ComplianceMode
+}
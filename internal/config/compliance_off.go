@@ -0,0 +1,7 @@
+//go:build !muxd_compliance
+
+package config
+
+// complianceBuild is false for regular builds; compliance mode is then
+// controlled by the compliance.mode preference.
+const complianceBuild = false
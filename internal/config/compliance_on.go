@@ -0,0 +1,7 @@
+//go:build muxd_compliance
+
+package config
+
+// complianceBuild hard-enables compliance mode for builds made with
+// -tags muxd_compliance. It cannot be turned off through preferences.
+const complianceBuild = true
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,10 +19,11 @@ var ProviderEnvVars = map[string]string{
 	"google":    "GOOGLE_API_KEY",
 	"fireworks": "FIREWORKS_API_KEY",
 	"deepinfra": "DEEPINFRA_API_KEY",
+	"azure":     "AZURE_OPENAI_API_KEY",
 }
 
 // KnownProviders lists valid provider names for validation.
-var KnownProviders = []string{"anthropic", "zai", "grok", "mistral", "openai", "google", "ollama", "fireworks", "deepinfra"}
+var KnownProviders = []string{"anthropic", "zai", "grok", "mistral", "openai", "google", "ollama", "fireworks", "deepinfra", "azure"}
 
 // configDirOverride is set by tests to redirect ConfigDir.
 var configDirOverride string
@@ -50,15 +53,43 @@ func DataDir() (string, error) {
 	return dir, nil
 }
 
+// ProjectKey returns a short, stable identifier for a project root directory.
+// It scopes per-project daemon state (lockfile, database, port).
+func ProjectKey(root string) string {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(root)))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// ProjectDataDir returns ~/.local/share/muxd/projects/<key> for the given
+// project root, creating it if needed.
+func ProjectDataDir(root string) (string, error) {
+	base, err := DataDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(base, "projects", ProjectKey(root))
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
 // LoadProviderAPIKey resolves an API key for the given provider using:
 //  1. Environment variable (e.g. ANTHROPIC_API_KEY, OPENAI_API_KEY)
 //  2. Preferences (e.g. anthropic_api_key set via /config)
 //
-// Ollama returns empty string (no key needed).
+// Ollama returns empty string (no key needed), and so do custom providers
+// without a key, since local servers often don't check one.
 func LoadProviderAPIKey(prefs Preferences, providerName string) (string, error) {
 	if providerName == "ollama" {
 		return "", nil
 	}
+	if c, ok := prefs.CustomProviders[providerName]; ok {
+		return strings.TrimSpace(c.APIKey), nil
+	}
 
 	// 1. Check environment variable
 	if envVar, ok := ProviderEnvVars[providerName]; ok {
@@ -101,6 +132,14 @@ func LoadProviderAPIKey(prefs Preferences, providerName string) (string, error)
 		if key := strings.TrimSpace(prefs.DeepInfraAPIKey); key != "" {
 			return key, nil
 		}
+	case "azure":
+		if key := strings.TrimSpace(prefs.AzureAPIKey); key != "" {
+			return key, nil
+		}
+		// Azure AD authenticates with a token instead.
+		if tenantID, clientID, secret := prefs.AzureAD(); tenantID != "" && clientID != "" && secret != "" {
+			return "", nil
+		}
 	}
 
 	return "", fmt.Errorf("no API key found for %s: set %s or use /config set %s.api_key <key>",
@@ -148,6 +187,14 @@ func ResolveAPIKeySource(prefs Preferences, providerName string) string {
 		if prefs.DeepInfraAPIKey != "" {
 			return "config"
 		}
+	case "azure":
+		if prefs.AzureAPIKey != "" {
+			return "config"
+		}
+	default:
+		if prefs.CustomProviders[providerName].APIKey != "" {
+			return "config"
+		}
 	}
 	return ""
 }
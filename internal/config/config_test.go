@@ -72,6 +72,122 @@ func TestParseAllowedIDs(t *testing.T) {
 	}
 }
 
+func TestParseAccounts(t *testing.T) {
+	accounts, err := ParseAccounts(" Work=key1, personal = key2 ,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(accounts) != 2 || accounts["work"] != "key1" || accounts["personal"] != "key2" {
+		t.Errorf("unexpected accounts: %v", accounts)
+	}
+	for _, bad := range []string{"work", "=key", "work=", "work=a,WORK=b"} {
+		if _, err := ParseAccounts(bad); err == nil {
+			t.Errorf("ParseAccounts(%q) expected error", bad)
+		}
+	}
+	if got := MaskAccounts("work=abcdef123,alt=xyz98765"); got != "alt=****8765,work=****f123" {
+		t.Errorf("MaskAccounts = %q", got)
+	}
+}
+
+func TestParseProviderProxies(t *testing.T) {
+	got, err := ParseProviderProxies("Anthropic=http://proxy-a:3128, openai = socks5://proxy-b:1080,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got["anthropic"] != "http://proxy-a:3128" || got["openai"] != "socks5://proxy-b:1080" {
+		t.Errorf("unexpected result: %v", got)
+	}
+
+	for _, bad := range []string{"anthropic", "=http://x:1", "openai=ftp://x:1", "openai=http://"} {
+		if _, err := ParseProviderProxies(bad); err == nil {
+			t.Errorf("ParseProviderProxies(%q) expected error, got nil", bad)
+		}
+	}
+}
+
+func TestComplianceEnabled(t *testing.T) {
+	p := DefaultPreferences()
+	if ComplianceEnabled(p) != ComplianceBuild() {
+		t.Errorf("default ComplianceEnabled = %v, want %v", ComplianceEnabled(p), ComplianceBuild())
+	}
+	if err := p.Set("compliance.mode", "true"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if !ComplianceEnabled(p) {
+		t.Error("expected compliance mode enabled by preference")
+	}
+	err := p.Set("compliance.mode", "false")
+	if ComplianceBuild() && err == nil {
+		t.Error("compliance builds must reject disabling compliance mode")
+	}
+	if !ComplianceBuild() && err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseStyleTone(t *testing.T) {
+	for in, want := range map[string]string{"terse": "terse", " Code-Only ": "code-only", "default": "", "": ""} {
+		got, err := ParseStyleTone(in)
+		if err != nil || got != want {
+			t.Errorf("ParseStyleTone(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseStyleTone("chatty"); err == nil {
+		t.Error("expected error for unknown tone")
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantLo  int
+		wantHi  int
+		wantErr bool
+	}{
+		{"range", "4096-4196", 4096, 4196, false},
+		{"range with spaces", " 4096 - 4100 ", 4096, 4100, false},
+		{"single port", "5000", 5000, 5000, false},
+		{"empty", "", 0, 0, true},
+		{"reversed", "5000-4000", 0, 0, true},
+		{"out of bounds", "0-70000", 0, 0, true},
+		{"not a number", "abc-def", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lo, hi, err := ParsePortRange(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParsePortRange(%q) expected error, got nil", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePortRange(%q) unexpected error: %v", tt.input, err)
+			}
+			if lo != tt.wantLo || hi != tt.wantHi {
+				t.Errorf("ParsePortRange(%q) = %d-%d, want %d-%d", tt.input, lo, hi, tt.wantLo, tt.wantHi)
+			}
+		})
+	}
+}
+
+func TestProjectKey(t *testing.T) {
+	a := ProjectKey("/home/user/proj-a")
+	b := ProjectKey("/home/user/proj-b")
+	if len(a) != 12 {
+		t.Errorf("expected 12-char key, got %q", a)
+	}
+	if a == b {
+		t.Error("expected different keys for different projects")
+	}
+	if ProjectKey("/home/user/proj-a/") != a {
+		t.Error("expected trailing slash to produce the same key")
+	}
+}
+
 func TestPreferences_SetGet_newKeys(t *testing.T) {
 	tests := []struct {
 		key   string
@@ -126,12 +242,12 @@ func TestPreferences_Grouped(t *testing.T) {
 	p.AnthropicAPIKey = "sk-ant-api03-long-key-1234"
 
 	groups := p.Grouped()
-	if len(groups) != 6 {
-		t.Fatalf("expected 6 groups, got %d", len(groups))
+	if len(groups) != 8 {
+		t.Fatalf("expected 8 groups, got %d", len(groups))
 	}
 
 	// Verify group names
-	wantNames := []string{"models", "tools", "daemon", "hub", "node", "theme"}
+	wantNames := []string{"models", "tools", "daemon", "hub", "node", "backup", "storage", "theme"}
 	for i, g := range groups {
 		if g.Name != wantNames[i] {
 			t.Errorf("group %d name = %q, want %q", i, g.Name, wantNames[i])
@@ -153,12 +269,31 @@ func TestPreferences_Grouped(t *testing.T) {
 		}
 	}
 
-	// Theme group: booleans should show "true", emoji is a string
-	theme := groups[5]
+	// Theme group: booleans should show "true" except the opt-in viewport;
+	// emoji, clipboard and input.keymap are strings
+	theme := groups[7]
 	for _, e := range theme.Entries {
 		if e.Key == "footer.emoji" {
 			continue // string field, not a boolean
 		}
+		if e.Key == "clipboard" {
+			if e.Value != "auto" {
+				t.Errorf("clipboard = %q, want auto", e.Value)
+			}
+			continue
+		}
+		if e.Key == "input.keymap" {
+			if e.Value != "emacs" {
+				t.Errorf("input.keymap = %q, want emacs", e.Value)
+			}
+			continue
+		}
+		if e.Key == "viewport" {
+			if e.Value != "false" {
+				t.Errorf("viewport = %q, want false (opt-in)", e.Value)
+			}
+			continue
+		}
 		if e.Value != "true" {
 			t.Errorf("theme key %q = %q, want %q", e.Key, e.Value, "true")
 		}
@@ -0,0 +1,160 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Custom OpenAI-compatible providers
+// ---------------------------------------------------------------------------
+
+// CustomProvider is an OpenAI-compatible endpoint (vLLM, LM Studio, Together,
+// an internal gateway) configured under providers.custom.<name>.
+type CustomProvider struct {
+	BaseURL string `json:"base_url,omitempty"`
+	APIKey  string `json:"api_key,omitempty"`
+	Models  string `json:"models,omitempty"`
+}
+
+// ModelList returns the configured model IDs.
+func (c CustomProvider) ModelList() []string {
+	var out []string
+	for _, m := range strings.Split(c.Models, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+const customProviderPrefix = "providers.custom."
+
+// customProviderFields are the settings of a custom provider, in display order.
+var customProviderFields = []string{"base_url", "api_key", "models"}
+
+var customProviderDocs = map[string]KeyDoc{
+	"base_url": {Type: KeyTypeString, Description: "base URL of an OpenAI-compatible API", Hint: "http(s)://host[:port]/v1; empty removes the provider"},
+	"api_key":  {Type: KeyTypeSecret, Description: "API key sent as a bearer token", Hint: "API key; empty sends none"},
+	"models":   {Type: KeyTypeList, Description: "model IDs offered by the provider", Hint: "comma-separated model IDs; empty asks the server"},
+}
+
+// ParseCustomProviderKey splits a providers.custom.<name>.<field> key.
+func ParseCustomProviderKey(key string) (name, field string, ok bool) {
+	rest, found := strings.CutPrefix(key, customProviderPrefix)
+	if !found {
+		return "", "", false
+	}
+	i := strings.LastIndex(rest, ".")
+	if i <= 0 {
+		return "", "", false
+	}
+	name, field = rest[:i], rest[i+1:]
+	if !slices.Contains(customProviderFields, field) {
+		return "", "", false
+	}
+	return name, field, true
+}
+
+// ValidateCustomProviderName checks that name can be used as a model prefix
+// and does not shadow a built-in provider.
+func ValidateCustomProviderName(name string) error {
+	if name == "" {
+		return fmt.Errorf("custom provider name cannot be empty")
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("invalid custom provider name %q: use lower-case letters, digits, '-' and '_'", name)
+		}
+	}
+	if slices.Contains(KnownProviders, name) {
+		return fmt.Errorf("%s is a built-in provider", name)
+	}
+	return nil
+}
+
+// validateCustomBaseURL requires an absolute http(s) URL.
+func validateCustomBaseURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid base_url %q: expected http(s)://host[:port]/path", raw)
+	}
+	return nil
+}
+
+// CustomProviderNames returns the configured custom providers, sorted.
+func (p Preferences) CustomProviderNames() []string {
+	names := make([]string, 0, len(p.CustomProviders))
+	for name := range p.CustomProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p Preferences) getCustomProvider(name, field string) string {
+	c := p.CustomProviders[name]
+	switch field {
+	case "base_url":
+		return c.BaseURL
+	case "api_key":
+		return MaskKey(c.APIKey)
+	default:
+		return c.Models
+	}
+}
+
+// setCustomProvider updates one setting of a custom provider. Clearing
+// base_url removes the provider. The map is copied rather than changed in
+// place, since copies of Preferences handed to agents share it.
+func (p *Preferences) setCustomProvider(name, field, value string) error {
+	if err := ValidateCustomProviderName(name); err != nil {
+		return err
+	}
+	c := p.CustomProviders[name]
+	switch field {
+	case "base_url":
+		if value == "" {
+			if _, ok := p.CustomProviders[name]; ok {
+				p.CustomProviders = maps.Clone(p.CustomProviders)
+				delete(p.CustomProviders, name)
+			}
+			return nil
+		}
+		if err := validateCustomBaseURL(value); err != nil {
+			return err
+		}
+		c.BaseURL = strings.TrimRight(value, "/")
+	case "api_key":
+		c.APIKey = value
+	case "models":
+		c.Models = strings.Join(CustomProvider{Models: value}.ModelList(), ",")
+	}
+	if c.BaseURL == "" {
+		return fmt.Errorf("set %s%s.base_url first", customProviderPrefix, name)
+	}
+	p.CustomProviders = maps.Clone(p.CustomProviders)
+	if p.CustomProviders == nil {
+		p.CustomProviders = make(map[string]CustomProvider)
+	}
+	p.CustomProviders[name] = c
+	return nil
+}
+
+// customProviderEntries lists the settings of every custom provider.
+func (p Preferences) customProviderEntries() []PrefEntry {
+	var entries []PrefEntry
+	for _, name := range p.CustomProviderNames() {
+		for _, field := range customProviderFields {
+			entries = append(entries, PrefEntry{
+				Key:   customProviderPrefix + name + "." + field,
+				Value: p.getCustomProvider(name, field),
+			})
+		}
+	}
+	return entries
+}
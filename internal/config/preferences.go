@@ -3,12 +3,18 @@ package config
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/guardrail"
+	"github.com/batalabs/muxd/internal/notify"
 )
 
 // Preferences holds user-configurable display and behavior settings.
@@ -16,16 +22,30 @@ import (
 type Preferences struct {
 	FooterTokens      bool   `json:"footer_tokens"`
 	FooterCost        bool   `json:"footer_cost"`
+	FooterContext     bool   `json:"footer_context"`
 	FooterCwd         bool   `json:"footer_cwd"`
 	FooterSession     bool   `json:"footer_session"`
 	FooterKeybindings bool   `json:"footer_keybindings"`
 	FooterEmoji       string `json:"footer_emoji,omitempty"`
 	HideDiffs         bool   `json:"hide_diffs,omitempty"`
+	Clipboard         string `json:"clipboard,omitempty"`
+	Viewport          bool   `json:"viewport,omitempty"`
+	InputKeymap       string `json:"input_keymap,omitempty"`
 	Model             string `json:"model"`
 	ModelCompact      string `json:"model_compact,omitempty"`
 	ModelTitle        string `json:"model_title,omitempty"`
 	ModelTags         string `json:"model_tags,omitempty"`
 	ModelConsult      string `json:"model_consult,omitempty"`
+	ModelVerify       string `json:"model_verify,omitempty"`
+	StyleLanguage     string `json:"style_language,omitempty"`
+	StyleTone         string `json:"style_tone,omitempty"`
+
+	// Sampling defaults for new sessions; empty leaves the provider default.
+	SamplingTemperature string `json:"sampling_temperature,omitempty"`
+	SamplingTopP        string `json:"sampling_top_p,omitempty"`
+	SamplingSeed        string `json:"sampling_seed,omitempty"`
+	SamplingMaxTokens   string `json:"sampling_max_tokens,omitempty"`
+	SamplingStop        string `json:"sampling_stop,omitempty"`
 
 	// Provider and API keys
 	Provider              string `json:"provider,omitempty"`
@@ -38,23 +58,119 @@ type Preferences struct {
 	GoogleAPIKey          string `json:"google_api_key,omitempty"`
 	FireworksAPIKey       string `json:"fireworks_api_key,omitempty"`
 	DeepInfraAPIKey       string `json:"deepinfra_api_key,omitempty"`
+	AzureAPIKey           string `json:"azure_api_key,omitempty"`
+	AzureEndpoint         string `json:"azure_endpoint,omitempty"`
+	AzureAPIVersion       string `json:"azure_api_version,omitempty"`
+	AzureDeployments      string `json:"azure_deployments,omitempty"`
+	AzureTenantID         string `json:"azure_tenant_id,omitempty"`
+	AzureClientID         string `json:"azure_client_id,omitempty"`
+	AzureClientSecret     string `json:"azure_client_secret,omitempty"`
 	BraveAPIKey           string `json:"brave_api_key,omitempty"`
 	TextbeltAPIKey        string `json:"textbelt_api_key,omitempty"`
+	TextbeltAccounts      string `json:"textbelt_accounts,omitempty"`
 	SchedulerAllowedTools string `json:"scheduler_allowed_tools,omitempty"`
+	SchedulerDraftTools   string `json:"scheduler_draft_tools,omitempty"`
+	SchedulerMaxAttempts  string `json:"scheduler_max_attempts,omitempty"`
+	SchedulerRetryBackoff string `json:"scheduler_retry_backoff,omitempty"`
+	SchedulerMisfire      string `json:"scheduler_misfire,omitempty"`
 	ToolsDisabled         string `json:"tools_disabled,omitempty"`
 	ToolsAskUser          *bool  `json:"tools_ask_user,omitempty"`
+	ToolsApprovalMode     string `json:"tools_approval_mode,omitempty"`
+	ShellBackend          string `json:"shell_backend,omitempty"`
+	ToolsParallelism      string `json:"tools_parallelism,omitempty"`
+	ToolsPrefetch         bool   `json:"tools_prefetch,omitempty"`
+	EgressMode            string `json:"egress_mode,omitempty"`
+	EgressAllowlist       string `json:"egress_allowlist,omitempty"`
+	ComplianceMode        bool   `json:"compliance_mode,omitempty"`
+	DiagramsRender        bool   `json:"diagrams_render,omitempty"`
+	DiagramsKrokiURL      string `json:"diagrams_kroki_url,omitempty"`
+	GuardrailTUI          string `json:"guardrail_tui,omitempty"`
+	GuardrailOutbound     string `json:"guardrail_outbound,omitempty"`
+	GuardrailBannedTerms  string `json:"guardrail_banned_terms,omitempty"`
+	PIIOutbound           string `json:"pii_outbound,omitempty"`
+	PIIPatterns           string `json:"pii_patterns,omitempty"`
 	OllamaURL             string `json:"ollama_url,omitempty"`
+	ProxyURL              string `json:"proxy_url,omitempty"`
+	ProxyProviders        string `json:"proxy_providers,omitempty"`
+
+	// OpenAI-compatible endpoints by name (providers.custom.<name>.*)
+	CustomProviders map[string]CustomProvider `json:"providers_custom,omitempty"`
+
+	// Spending limits in US dollars
+	BudgetSessionUSD string `json:"budget_session_usd,omitempty"`
+	BudgetDailyUSD   string `json:"budget_daily_usd,omitempty"`
 
 	// Daemon settings
 	DaemonBindAddress string `json:"daemon_bind_address,omitempty"`
 	DaemonAuthToken   string `json:"daemon_auth_token,omitempty"`
+	DaemonSocketPath  string `json:"daemon_socket_path,omitempty"`
+	DaemonPortRange   string `json:"daemon_port_range,omitempty"`
+	DaemonPerProject  bool   `json:"daemon_per_project,omitempty"`
+	DaemonProjectDB   bool   `json:"daemon_project_db,omitempty"`
+
+	// Checkpoint settings
+	CheckpointRetentionDays string `json:"checkpoint_retention_days,omitempty"`
 
 	// Hub settings
 	HubBindAddress string `json:"hub_bind_address,omitempty"`
 	HubAuthToken   string `json:"hub_auth_token,omitempty"`
 	HubURL         string `json:"hub_url,omitempty"`
+	HubURLs        string `json:"hub_urls,omitempty"`
 	HubNodeToken   string `json:"hub_node_token,omitempty"`
 	HubNodeName    string `json:"hub_node_name,omitempty"`
+	HubFallbackURL string `json:"hub_fallback_url,omitempty"`
+	HubPublicURL   string `json:"hub_public_url,omitempty"`
+
+	// Hub sign-in for people, through an OIDC identity provider
+	HubOIDCIssuer       string `json:"hub_oidc_issuer,omitempty"`
+	HubOIDCClientID     string `json:"hub_oidc_client_id,omitempty"`
+	HubOIDCClientSecret string `json:"hub_oidc_client_secret,omitempty"`
+	HubOIDCRoles        string `json:"hub_oidc_roles,omitempty"`
+	HubOIDCGroupsClaim  string `json:"hub_oidc_groups_claim,omitempty"`
+
+	// Backup settings
+	BackupDir      string `json:"backup_dir,omitempty"`
+	BackupInterval string `json:"backup_interval,omitempty"`
+	BackupKeep     string `json:"backup_keep,omitempty"`
+
+	// Object storage for backups, exports, and large tool results
+	StorageS3URL       string `json:"storage_s3_url,omitempty"`
+	StorageS3Region    string `json:"storage_s3_region,omitempty"`
+	StorageS3AccessKey string `json:"storage_s3_access_key,omitempty"`
+	StorageS3SecretKey string `json:"storage_s3_secret_key,omitempty"`
+	StorageExports     bool   `json:"storage_exports,omitempty"`
+	StorageBlobMinKB   string `json:"storage_blob_min_kb,omitempty"`
+
+	// Push notifications to the mobile app
+	PushAPNsKey        string `json:"push_apns_key,omitempty"`
+	PushAPNsKeyID      string `json:"push_apns_key_id,omitempty"`
+	PushAPNsTeamID     string `json:"push_apns_team_id,omitempty"`
+	PushAPNsTopic      string `json:"push_apns_topic,omitempty"`
+	PushAPNsSandbox    bool   `json:"push_apns_sandbox,omitempty"`
+	PushFCMCredentials string `json:"push_fcm_credentials,omitempty"`
+
+	// Notifications on this machine and to external services
+	NotifyOnTurnDone     string `json:"notify_on_turn_done,omitempty"`
+	NotifyOnJobDone      string `json:"notify_on_job_done,omitempty"`
+	NotifyOnJobFailed    string `json:"notify_on_job_failed,omitempty"`
+	NotifyOnBudget       string `json:"notify_on_budget,omitempty"`
+	NotifyWebhookURL     string `json:"notify_webhook_url,omitempty"`
+	NotifyTelegramToken  string `json:"notify_telegram_token,omitempty"`
+	NotifyTelegramChatID string `json:"notify_telegram_chat_id,omitempty"`
+
+	// Slack adapter
+	SlackAppToken     string `json:"slack_app_token,omitempty"`
+	SlackBotToken     string `json:"slack_bot_token,omitempty"`
+	SlackAllowedUsers string `json:"slack_allowed_users,omitempty"`
+	SlackChannels     string `json:"slack_channels,omitempty"`
+	SlackAutostart    bool   `json:"slack_autostart,omitempty"`
+
+	// Discord adapter
+	DiscordToken        string `json:"discord_token,omitempty"`
+	DiscordAllowedUsers string `json:"discord_allowed_users,omitempty"`
+	DiscordChannels     string `json:"discord_channels,omitempty"`
+	DiscordAllowedPaths string `json:"discord_allowed_paths,omitempty"`
+	DiscordAutostart    bool   `json:"discord_autostart,omitempty"`
 }
 
 // PrefEntry holds a single key-value preference entry for display.
@@ -76,32 +192,7 @@ type ConfigGroupDef struct {
 }
 
 // ConfigGroupDefs defines the preference key groupings and their display order.
-var ConfigGroupDefs = []ConfigGroupDef{
-	{
-		Name: "models",
-		Keys: []string{"model", "model.compact", "model.title", "model.tags", "model.consult", "anthropic.api_key", "zai.api_key", "zai.coding_plan", "grok.api_key", "mistral.api_key", "openai.api_key", "google.api_key", "fireworks.api_key", "deepinfra.api_key", "ollama.url"},
-	},
-	{
-		Name: "tools",
-		Keys: []string{"tools.disabled", "tools.ask_user", "brave.api_key", "textbelt.api_key", "scheduler.allowed_tools"},
-	},
-	{
-		Name: "daemon",
-		Keys: []string{"daemon.bind_address", "daemon.auth_token"},
-	},
-	{
-		Name: "hub",
-		Keys: []string{"hub.bind_address", "hub.auth_token"},
-	},
-	{
-		Name: "node",
-		Keys: []string{"hub.url", "hub.node_token", "hub.node_name"},
-	},
-	{
-		Name: "theme",
-		Keys: []string{"footer.tokens", "footer.cost", "footer.cwd", "footer.session", "footer.keybindings", "footer.emoji", "show_diffs"},
-	},
-}
+var ConfigGroupDefs = buildConfigGroupDefs()
 
 // ConfigGroupNames returns the list of valid group names.
 func ConfigGroupNames() []string {
@@ -126,6 +217,7 @@ func DefaultPreferences() Preferences {
 	return Preferences{
 		FooterTokens:      true,
 		FooterCost:        true,
+		FooterContext:     true,
 		FooterCwd:         true,
 		FooterSession:     true,
 		FooterKeybindings: true,
@@ -151,10 +243,11 @@ func LoadPreferences() Preferences {
 	p := DefaultPreferences()
 
 	// Load config.json if it exists
-	configLoaded := false
+	configLoaded, migrated := false, false
 	if data, err := os.ReadFile(configPath); err == nil {
 		// Strip UTF-8 BOM that Windows editors (e.g. Notepad) may add
 		data = stripBOM(data)
+		data, migrated = migrateConfigJSON(data)
 		if err := json.Unmarshal(data, &p); err != nil {
 			fmt.Fprintf(os.Stderr, "config: parse %s: %v\n", configPath, err)
 		} else {
@@ -163,9 +256,10 @@ func LoadPreferences() Preferences {
 		warnInsecurePermissions(configPath)
 	}
 
-	// Only sanitize and re-save if we successfully loaded the config.
-	// This prevents overwriting the user's file with defaults on parse errors.
-	if configLoaded && sanitizePreferences(&p) {
+	// Only sanitize, migrate, and re-save if we successfully loaded the
+	// config. This prevents overwriting the user's file with defaults on
+	// parse errors.
+	if configLoaded && (sanitizePreferences(&p) || migrated) {
 		if err := SavePreferences(p); err != nil {
 			fmt.Fprintf(os.Stderr, "config: save sanitized config: %v\n", err)
 		}
@@ -175,6 +269,7 @@ func LoadPreferences() Preferences {
 	// then delete the legacy file
 	if data, err := os.ReadFile(legacyPath); err == nil {
 		data = stripBOM(data)
+		data, _ = migrateConfigJSON(data)
 		legacy := DefaultPreferences()
 		if json.Unmarshal(data, &legacy) == nil {
 			mergePreferences(&p, &legacy)
@@ -207,6 +302,30 @@ func mergePreferences(dst, src *Preferences) {
 	if src.ModelConsult != "" {
 		dst.ModelConsult = src.ModelConsult
 	}
+	if src.ModelVerify != "" {
+		dst.ModelVerify = src.ModelVerify
+	}
+	if src.StyleLanguage != "" {
+		dst.StyleLanguage = src.StyleLanguage
+	}
+	if src.StyleTone != "" {
+		dst.StyleTone = src.StyleTone
+	}
+	if src.SamplingTemperature != "" {
+		dst.SamplingTemperature = src.SamplingTemperature
+	}
+	if src.SamplingTopP != "" {
+		dst.SamplingTopP = src.SamplingTopP
+	}
+	if src.SamplingSeed != "" {
+		dst.SamplingSeed = src.SamplingSeed
+	}
+	if src.SamplingMaxTokens != "" {
+		dst.SamplingMaxTokens = src.SamplingMaxTokens
+	}
+	if src.SamplingStop != "" {
+		dst.SamplingStop = src.SamplingStop
+	}
 	if src.Provider != "" {
 		dst.Provider = src.Provider
 	}
@@ -237,27 +356,138 @@ func mergePreferences(dst, src *Preferences) {
 	if src.DeepInfraAPIKey != "" {
 		dst.DeepInfraAPIKey = src.DeepInfraAPIKey
 	}
+	if src.AzureAPIKey != "" {
+		dst.AzureAPIKey = src.AzureAPIKey
+	}
+	if src.AzureEndpoint != "" {
+		dst.AzureEndpoint = src.AzureEndpoint
+	}
+	if src.AzureAPIVersion != "" {
+		dst.AzureAPIVersion = src.AzureAPIVersion
+	}
+	if src.AzureDeployments != "" {
+		dst.AzureDeployments = src.AzureDeployments
+	}
+	if src.AzureTenantID != "" {
+		dst.AzureTenantID = src.AzureTenantID
+	}
+	if src.AzureClientID != "" {
+		dst.AzureClientID = src.AzureClientID
+	}
+	if src.AzureClientSecret != "" {
+		dst.AzureClientSecret = src.AzureClientSecret
+	}
 	if src.BraveAPIKey != "" {
 		dst.BraveAPIKey = src.BraveAPIKey
 	}
 	if src.TextbeltAPIKey != "" {
 		dst.TextbeltAPIKey = src.TextbeltAPIKey
 	}
+	if src.TextbeltAccounts != "" {
+		dst.TextbeltAccounts = src.TextbeltAccounts
+	}
 	if src.SchedulerAllowedTools != "" {
 		dst.SchedulerAllowedTools = src.SchedulerAllowedTools
 	}
+	if src.SchedulerDraftTools != "" {
+		dst.SchedulerDraftTools = src.SchedulerDraftTools
+	}
+	if src.SchedulerMaxAttempts != "" {
+		dst.SchedulerMaxAttempts = src.SchedulerMaxAttempts
+	}
+	if src.SchedulerRetryBackoff != "" {
+		dst.SchedulerRetryBackoff = src.SchedulerRetryBackoff
+	}
+	if src.SchedulerMisfire != "" {
+		dst.SchedulerMisfire = src.SchedulerMisfire
+	}
 	if src.ToolsDisabled != "" {
 		dst.ToolsDisabled = src.ToolsDisabled
 	}
+	if src.ToolsApprovalMode != "" {
+		dst.ToolsApprovalMode = src.ToolsApprovalMode
+	}
+	if src.ShellBackend != "" {
+		dst.ShellBackend = src.ShellBackend
+	}
+	if src.ToolsParallelism != "" {
+		dst.ToolsParallelism = src.ToolsParallelism
+	}
+	if src.EgressMode != "" {
+		dst.EgressMode = src.EgressMode
+	}
+	if src.EgressAllowlist != "" {
+		dst.EgressAllowlist = src.EgressAllowlist
+	}
+	if src.ComplianceMode {
+		dst.ComplianceMode = true
+	}
+	if src.DiagramsRender {
+		dst.DiagramsRender = true
+	}
+	if src.ToolsPrefetch {
+		dst.ToolsPrefetch = true
+	}
+	if src.DiagramsKrokiURL != "" {
+		dst.DiagramsKrokiURL = src.DiagramsKrokiURL
+	}
+	if src.GuardrailTUI != "" {
+		dst.GuardrailTUI = src.GuardrailTUI
+	}
+	if src.GuardrailOutbound != "" {
+		dst.GuardrailOutbound = src.GuardrailOutbound
+	}
+	if src.GuardrailBannedTerms != "" {
+		dst.GuardrailBannedTerms = src.GuardrailBannedTerms
+	}
+	if src.PIIOutbound != "" {
+		dst.PIIOutbound = src.PIIOutbound
+	}
+	if src.PIIPatterns != "" {
+		dst.PIIPatterns = src.PIIPatterns
+	}
 	if src.OllamaURL != "" {
 		dst.OllamaURL = src.OllamaURL
 	}
+	if src.ProxyURL != "" {
+		dst.ProxyURL = src.ProxyURL
+	}
+	if src.ProxyProviders != "" {
+		dst.ProxyProviders = src.ProxyProviders
+	}
+	for name, c := range src.CustomProviders {
+		if dst.CustomProviders == nil {
+			dst.CustomProviders = make(map[string]CustomProvider)
+		}
+		dst.CustomProviders[name] = c
+	}
+	if src.BudgetSessionUSD != "" {
+		dst.BudgetSessionUSD = src.BudgetSessionUSD
+	}
+	if src.BudgetDailyUSD != "" {
+		dst.BudgetDailyUSD = src.BudgetDailyUSD
+	}
 	if src.DaemonBindAddress != "" {
 		dst.DaemonBindAddress = src.DaemonBindAddress
 	}
 	if src.DaemonAuthToken != "" {
 		dst.DaemonAuthToken = src.DaemonAuthToken
 	}
+	if src.DaemonSocketPath != "" {
+		dst.DaemonSocketPath = src.DaemonSocketPath
+	}
+	if src.DaemonPortRange != "" {
+		dst.DaemonPortRange = src.DaemonPortRange
+	}
+	if src.DaemonPerProject {
+		dst.DaemonPerProject = true
+	}
+	if src.DaemonProjectDB {
+		dst.DaemonProjectDB = true
+	}
+	if src.CheckpointRetentionDays != "" {
+		dst.CheckpointRetentionDays = src.CheckpointRetentionDays
+	}
 	if src.HubBindAddress != "" {
 		dst.HubBindAddress = src.HubBindAddress
 	}
@@ -267,21 +497,145 @@ func mergePreferences(dst, src *Preferences) {
 	if src.HubURL != "" {
 		dst.HubURL = src.HubURL
 	}
+	if src.HubURLs != "" {
+		dst.HubURLs = src.HubURLs
+	}
+	if src.HubFallbackURL != "" {
+		dst.HubFallbackURL = src.HubFallbackURL
+	}
+	if src.HubPublicURL != "" {
+		dst.HubPublicURL = src.HubPublicURL
+	}
+	if src.HubOIDCIssuer != "" {
+		dst.HubOIDCIssuer = src.HubOIDCIssuer
+	}
+	if src.HubOIDCClientID != "" {
+		dst.HubOIDCClientID = src.HubOIDCClientID
+	}
+	if src.HubOIDCClientSecret != "" {
+		dst.HubOIDCClientSecret = src.HubOIDCClientSecret
+	}
+	if src.HubOIDCRoles != "" {
+		dst.HubOIDCRoles = src.HubOIDCRoles
+	}
+	if src.HubOIDCGroupsClaim != "" {
+		dst.HubOIDCGroupsClaim = src.HubOIDCGroupsClaim
+	}
 	if src.HubNodeToken != "" {
 		dst.HubNodeToken = src.HubNodeToken
 	}
 	if src.HubNodeName != "" {
 		dst.HubNodeName = src.HubNodeName
 	}
+	if src.BackupDir != "" {
+		dst.BackupDir = src.BackupDir
+	}
+	if src.BackupInterval != "" {
+		dst.BackupInterval = src.BackupInterval
+	}
+	if src.BackupKeep != "" {
+		dst.BackupKeep = src.BackupKeep
+	}
+	if src.StorageS3URL != "" {
+		dst.StorageS3URL = src.StorageS3URL
+	}
+	if src.StorageS3Region != "" {
+		dst.StorageS3Region = src.StorageS3Region
+	}
+	if src.StorageS3AccessKey != "" {
+		dst.StorageS3AccessKey = src.StorageS3AccessKey
+	}
+	if src.StorageS3SecretKey != "" {
+		dst.StorageS3SecretKey = src.StorageS3SecretKey
+	}
+	if src.StorageBlobMinKB != "" {
+		dst.StorageBlobMinKB = src.StorageBlobMinKB
+	}
+	if src.PushAPNsKey != "" {
+		dst.PushAPNsKey = src.PushAPNsKey
+	}
+	if src.PushAPNsKeyID != "" {
+		dst.PushAPNsKeyID = src.PushAPNsKeyID
+	}
+	if src.PushAPNsTeamID != "" {
+		dst.PushAPNsTeamID = src.PushAPNsTeamID
+	}
+	if src.PushAPNsTopic != "" {
+		dst.PushAPNsTopic = src.PushAPNsTopic
+	}
+	if src.PushFCMCredentials != "" {
+		dst.PushFCMCredentials = src.PushFCMCredentials
+	}
+	if src.NotifyOnTurnDone != "" {
+		dst.NotifyOnTurnDone = src.NotifyOnTurnDone
+	}
+	if src.NotifyOnJobDone != "" {
+		dst.NotifyOnJobDone = src.NotifyOnJobDone
+	}
+	if src.NotifyOnJobFailed != "" {
+		dst.NotifyOnJobFailed = src.NotifyOnJobFailed
+	}
+	if src.NotifyOnBudget != "" {
+		dst.NotifyOnBudget = src.NotifyOnBudget
+	}
+	if src.NotifyWebhookURL != "" {
+		dst.NotifyWebhookURL = src.NotifyWebhookURL
+	}
+	if src.NotifyTelegramToken != "" {
+		dst.NotifyTelegramToken = src.NotifyTelegramToken
+	}
+	if src.NotifyTelegramChatID != "" {
+		dst.NotifyTelegramChatID = src.NotifyTelegramChatID
+	}
+	if src.SlackAppToken != "" {
+		dst.SlackAppToken = src.SlackAppToken
+	}
+	if src.SlackBotToken != "" {
+		dst.SlackBotToken = src.SlackBotToken
+	}
+	if src.SlackAllowedUsers != "" {
+		dst.SlackAllowedUsers = src.SlackAllowedUsers
+	}
+	if src.SlackChannels != "" {
+		dst.SlackChannels = src.SlackChannels
+	}
+	if src.SlackAutostart {
+		dst.SlackAutostart = true
+	}
+	if src.DiscordToken != "" {
+		dst.DiscordToken = src.DiscordToken
+	}
+	if src.DiscordAllowedUsers != "" {
+		dst.DiscordAllowedUsers = src.DiscordAllowedUsers
+	}
+	if src.DiscordChannels != "" {
+		dst.DiscordChannels = src.DiscordChannels
+	}
+	if src.DiscordAllowedPaths != "" {
+		dst.DiscordAllowedPaths = src.DiscordAllowedPaths
+	}
+	if src.DiscordAutostart {
+		dst.DiscordAutostart = true
+	}
 	// Booleans: copy from src (they represent the user's last settings)
 	dst.FooterTokens = src.FooterTokens
+	dst.StorageExports = src.StorageExports
+	dst.PushAPNsSandbox = src.PushAPNsSandbox
 	dst.FooterCost = src.FooterCost
+	dst.FooterContext = src.FooterContext
 	dst.FooterCwd = src.FooterCwd
 	dst.FooterSession = src.FooterSession
 	dst.FooterKeybindings = src.FooterKeybindings
+	dst.Viewport = src.Viewport
 	if src.FooterEmoji != "" {
 		dst.FooterEmoji = src.FooterEmoji
 	}
+	if src.Clipboard != "" {
+		dst.Clipboard = src.Clipboard
+	}
+	if src.InputKeymap != "" {
+		dst.InputKeymap = src.InputKeymap
+	}
 }
 
 // SavePreferences writes preferences to ~/.config/muxd/config.json.
@@ -343,6 +697,9 @@ func (p Preferences) Grouped() []ConfigGroup {
 				Value: AnnotateValue(val, defVal),
 			})
 		}
+		if def.Name == "models" {
+			entries = append(entries, p.customProviderEntries()...)
+		}
 		groups = append(groups, ConfigGroup{Name: def.Name, Entries: entries})
 	}
 	return groups
@@ -369,230 +726,39 @@ func (p Preferences) entryMap() map[string]string {
 
 // All returns all preference entries as a flat list.
 func (p Preferences) All() []PrefEntry {
-	return []PrefEntry{
-		{"footer.tokens", strconv.FormatBool(p.FooterTokens)},
-		{"footer.cost", strconv.FormatBool(p.FooterCost)},
-		{"footer.cwd", strconv.FormatBool(p.FooterCwd)},
-		{"footer.session", strconv.FormatBool(p.FooterSession)},
-		{"footer.keybindings", strconv.FormatBool(p.FooterKeybindings)},
-		{"footer.emoji", p.FooterEmoji},
-		{"show_diffs", strconv.FormatBool(!p.HideDiffs)},
-		{"model", p.Model},
-		{"model.compact", p.ModelCompact},
-		{"model.title", p.ModelTitle},
-		{"model.tags", p.ModelTags},
-		{"model.consult", p.ModelConsult},
-		{"anthropic.api_key", resolveKeyDisplay(p.AnthropicAPIKey, "ANTHROPIC_API_KEY")},
-		{"zai.api_key", resolveKeyDisplay(p.ZAIAPIKey, "ZAI_API_KEY")},
-		{"zai.coding_plan", strconv.FormatBool(p.ZAICodingPlan)},
-		{"grok.api_key", resolveKeyDisplay(p.GrokAPIKey, "XAI_API_KEY")},
-		{"mistral.api_key", resolveKeyDisplay(p.MistralAPIKey, "MISTRAL_API_KEY")},
-		{"openai.api_key", resolveKeyDisplay(p.OpenAIAPIKey, "OPENAI_API_KEY")},
-		{"google.api_key", resolveKeyDisplay(p.GoogleAPIKey, "GOOGLE_API_KEY")},
-		{"fireworks.api_key", resolveKeyDisplay(p.FireworksAPIKey, "FIREWORKS_API_KEY")},
-		{"deepinfra.api_key", resolveKeyDisplay(p.DeepInfraAPIKey, "DEEPINFRA_API_KEY")},
-		{"brave.api_key", resolveKeyDisplay(p.BraveAPIKey, "BRAVE_SEARCH_API_KEY")},
-		{"textbelt.api_key", MaskKey(p.TextbeltAPIKey)},
-		{"scheduler.allowed_tools", p.SchedulerAllowedTools},
-		{"tools.disabled", p.ToolsDisabled},
-		{"ollama.url", p.OllamaURL},
-		{"daemon.bind_address", p.DaemonBindAddress},
-		{"daemon.auth_token", MaskKey(p.DaemonAuthToken)},
-		{"hub.bind_address", p.HubBindAddress},
-		{"hub.auth_token", MaskKey(p.HubAuthToken)},
-		{"hub.url", p.HubURL},
-		{"hub.node_token", MaskKey(p.HubNodeToken)},
-		{"hub.node_name", p.HubNodeName},
+	entries := make([]PrefEntry, len(prefSchema))
+	for i, f := range prefSchema {
+		display := f.display
+		if display == nil {
+			display = f.get
+		}
+		entries[i] = PrefEntry{Key: f.key, Value: display(&p)}
 	}
+	return append(entries, p.customProviderEntries()...)
 }
 
 // Get returns the display value for a single preference key.
 func (p Preferences) Get(key string) string {
-	switch key {
-	case "footer.tokens":
-		return strconv.FormatBool(p.FooterTokens)
-	case "footer.cost":
-		return strconv.FormatBool(p.FooterCost)
-	case "footer.cwd":
-		return strconv.FormatBool(p.FooterCwd)
-	case "footer.session":
-		return strconv.FormatBool(p.FooterSession)
-	case "footer.keybindings":
-		return strconv.FormatBool(p.FooterKeybindings)
-	case "footer.emoji":
-		return p.FooterEmoji
-	case "show_diffs":
-		return strconv.FormatBool(!p.HideDiffs)
-	case "model":
-		return p.Model
-	case "model.compact":
-		return p.ModelCompact
-	case "model.title":
-		return p.ModelTitle
-	case "model.tags":
-		return p.ModelTags
-	case "model.consult":
-		return p.ModelConsult
-	case "anthropic.api_key":
-		return MaskKey(p.AnthropicAPIKey)
-	case "zai.api_key":
-		return MaskKey(p.ZAIAPIKey)
-	case "zai.coding_plan":
-		return strconv.FormatBool(p.ZAICodingPlan)
-	case "openai.api_key":
-		return MaskKey(p.OpenAIAPIKey)
-	case "mistral.api_key":
-		return MaskKey(p.MistralAPIKey)
-	case "grok.api_key":
-		return MaskKey(p.GrokAPIKey)
-	case "google.api_key":
-		return MaskKey(p.GoogleAPIKey)
-	case "fireworks.api_key":
-		return MaskKey(p.FireworksAPIKey)
-	case "deepinfra.api_key":
-		return MaskKey(p.DeepInfraAPIKey)
-	case "brave.api_key":
-		return MaskKey(p.BraveAPIKey)
-	case "textbelt.api_key":
-		return MaskKey(p.TextbeltAPIKey)
-	case "scheduler.allowed_tools":
-		return p.SchedulerAllowedTools
-	case "tools.disabled":
-		return p.ToolsDisabled
-	case "tools.ask_user":
-		if p.ToolsAskUser != nil && !*p.ToolsAskUser {
-			return "false"
-		}
-		return "true"
-	case "ollama.url":
-		return p.OllamaURL
-	case "daemon.bind_address":
-		return p.DaemonBindAddress
-	case "daemon.auth_token":
-		return MaskKey(p.DaemonAuthToken)
-	case "hub.bind_address":
-		return p.HubBindAddress
-	case "hub.auth_token":
-		return MaskKey(p.HubAuthToken)
-	case "hub.url":
-		return p.HubURL
-	case "hub.node_token":
-		return MaskKey(p.HubNodeToken)
-	case "hub.node_name":
-		return p.HubNodeName
-	default:
+	if name, field, ok := ParseCustomProviderKey(key); ok {
+		return p.getCustomProvider(name, field)
+	}
+	f, ok := lookupPref(key)
+	if !ok {
 		return ""
 	}
+	return f.get(&p)
 }
 
 // Set updates a single preference key to the given value.
 func (p *Preferences) Set(key, value string) error {
-	value = SanitizeValue(value)
-	switch key {
-	case "footer.tokens":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.FooterTokens = b
-	case "footer.cost":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.FooterCost = b
-	case "footer.cwd":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.FooterCwd = b
-	case "footer.session":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.FooterSession = b
-	case "footer.keybindings":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.FooterKeybindings = b
-	case "footer.emoji":
-		p.FooterEmoji = ResolveEmoji(value)
-	case "show_diffs":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.HideDiffs = !b
-	case "model":
-		p.Model = value
-	case "model.compact":
-		p.ModelCompact = value
-	case "model.title":
-		p.ModelTitle = value
-	case "model.tags":
-		p.ModelTags = value
-	case "model.consult":
-		p.ModelConsult = value
-	case "anthropic.api_key":
-		p.AnthropicAPIKey = value
-	case "zai.api_key":
-		p.ZAIAPIKey = value
-	case "zai.coding_plan":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.ZAICodingPlan = b
-	case "openai.api_key":
-		p.OpenAIAPIKey = value
-	case "mistral.api_key":
-		p.MistralAPIKey = value
-	case "grok.api_key":
-		p.GrokAPIKey = value
-	case "google.api_key":
-		p.GoogleAPIKey = value
-	case "fireworks.api_key":
-		p.FireworksAPIKey = value
-	case "deepinfra.api_key":
-		p.DeepInfraAPIKey = value
-	case "brave.api_key":
-		p.BraveAPIKey = value
-	case "textbelt.api_key":
-		p.TextbeltAPIKey = value
-	case "scheduler.allowed_tools":
-		p.SchedulerAllowedTools = value
-	case "tools.disabled":
-		p.ToolsDisabled = value
-	case "tools.ask_user":
-		b, err := ParseBoolish(value)
-		if err != nil {
-			return err
-		}
-		p.ToolsAskUser = &b
-	case "ollama.url":
-		p.OllamaURL = value
-	case "daemon.bind_address":
-		p.DaemonBindAddress = value
-	case "daemon.auth_token":
-		p.DaemonAuthToken = value
-	case "hub.bind_address":
-		p.HubBindAddress = value
-	case "hub.auth_token":
-		p.HubAuthToken = value
-	case "hub.url":
-		p.HubURL = value
-	case "hub.node_token":
-		p.HubNodeToken = value
-	case "hub.node_name":
-		p.HubNodeName = value
-	default:
+	if name, field, ok := ParseCustomProviderKey(key); ok {
+		return p.setCustomProvider(name, field, SanitizeValue(value))
+	}
+	f, ok := lookupPref(key)
+	if !ok {
 		return fmt.Errorf("unknown key: %s", key)
 	}
-	return nil
+	return f.set(p, SanitizeValue(value))
 }
 
 // SanitizeValue strips null bytes, ASCII control characters (< 32 except
@@ -632,33 +798,18 @@ func sanitizePreferences(p *Preferences) bool {
 			changed = true
 		}
 	}
-	sanitize(&p.Model)
-	sanitize(&p.ModelCompact)
-	sanitize(&p.ModelTitle)
-	sanitize(&p.ModelTags)
-	sanitize(&p.ModelConsult)
 	sanitize(&p.Provider)
-	sanitize(&p.AnthropicAPIKey)
-	sanitize(&p.ZAIAPIKey)
-	sanitize(&p.GrokAPIKey)
-	sanitize(&p.MistralAPIKey)
-	sanitize(&p.OpenAIAPIKey)
-	sanitize(&p.GoogleAPIKey)
-	sanitize(&p.FireworksAPIKey)
-	sanitize(&p.DeepInfraAPIKey)
-	sanitize(&p.BraveAPIKey)
-	sanitize(&p.TextbeltAPIKey)
-	sanitize(&p.SchedulerAllowedTools)
-	sanitize(&p.ToolsDisabled)
-	sanitize(&p.OllamaURL)
-	sanitize(&p.DaemonBindAddress)
-	sanitize(&p.DaemonAuthToken)
-	sanitize(&p.HubBindAddress)
-	sanitize(&p.HubAuthToken)
-	sanitize(&p.HubURL)
-	sanitize(&p.HubNodeToken)
-	sanitize(&p.HubNodeName)
-	sanitize(&p.FooterEmoji)
+	for _, f := range prefSchema {
+		if f.str != nil {
+			sanitize(f.str(p))
+		}
+	}
+	for name, c := range p.CustomProviders {
+		sanitize(&c.BaseURL)
+		sanitize(&c.APIKey)
+		sanitize(&c.Models)
+		p.CustomProviders[name] = c
+	}
 	return changed
 }
 
@@ -731,6 +882,56 @@ func MaskKey(key string) string {
 	return "****" + key[len(key)-4:]
 }
 
+// ParseAccounts parses named account credentials in the form
+// "name=key,name=key". Names are lowercased and must be unique.
+func ParseAccounts(s string) (map[string]string, error) {
+	out := map[string]string{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, key, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		key = strings.TrimSpace(key)
+		if !ok || name == "" || key == "" {
+			return nil, fmt.Errorf("invalid account %q (use name=key)", part)
+		}
+		if _, dup := out[name]; dup {
+			return nil, fmt.Errorf("duplicate account %q", name)
+		}
+		out[name] = key
+	}
+	return out, nil
+}
+
+// MaskAccounts masks the keys of a "name=key,..." account list for display.
+func MaskAccounts(s string) string {
+	accounts, err := ParseAccounts(s)
+	if err != nil {
+		return MaskKey(s)
+	}
+	names := make([]string, 0, len(accounts))
+	for name := range accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		names[i] = name + "=" + MaskKey(accounts[name])
+	}
+	return strings.Join(names, ",")
+}
+
+// TextbeltAccountKeys returns the named Textbelt accounts. Malformed
+// values yield an empty map; Set rejects them anyway.
+func (p Preferences) TextbeltAccountKeys() map[string]string {
+	accounts, err := ParseAccounts(p.TextbeltAccounts)
+	if err != nil {
+		return map[string]string{}
+	}
+	return accounts
+}
+
 // ParseAllowedIDs parses a comma-separated list of int64 user IDs.
 func ParseAllowedIDs(s string) ([]int64, error) {
 	s = strings.TrimSpace(s)
@@ -753,6 +954,475 @@ func ParseAllowedIDs(s string) ([]int64, error) {
 	return ids, nil
 }
 
+// ParsePortRange parses a port range such as "4096-4196" into its inclusive
+// bounds. A single port ("4096") is treated as a range of one.
+func ParsePortRange(s string) (int, int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, 0, fmt.Errorf("empty port range")
+	}
+	loStr, hiStr, found := strings.Cut(s, "-")
+	if !found {
+		hiStr = loStr
+	}
+	lo, err := strconv.Atoi(strings.TrimSpace(loStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	hi, err := strconv.Atoi(strings.TrimSpace(hiStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %q: %w", s, err)
+	}
+	if lo < 1 || hi > 65535 || lo > hi {
+		return 0, 0, fmt.Errorf("invalid port range %q (use low-high within 1-65535)", s)
+	}
+	return lo, hi, nil
+}
+
+// ValidateProxyURL checks that raw is an absolute http, https, or socks5
+// proxy URL.
+func ValidateProxyURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("invalid proxy URL %q: scheme must be http, https, or socks5", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return nil
+}
+
+// ParseProviderProxies parses a comma-separated list of provider=url pairs,
+// e.g. "anthropic=http://proxy-a:3128,openai=http://proxy-b:8080".
+func ParseProviderProxies(s string) (map[string]string, error) {
+	out := make(map[string]string)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, raw, ok := strings.Cut(part, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		raw = strings.TrimSpace(raw)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid proxy entry %q (use provider=url)", part)
+		}
+		if err := ValidateProxyURL(raw); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		out[name] = raw
+	}
+	return out, nil
+}
+
+// HubRoles lists the roles hub.oidc.roles grants, from least to most
+// privileged. They are the daemon's API token scopes.
+var HubRoles = []string{"read", "submit", "admin"}
+
+// OIDCRoleRule grants Role to the identities Match selects: an email
+// address, an email domain such as @example.com, group:<name> for members
+// of a group, a subject ID, or * for anyone the identity provider signs in.
+type OIDCRoleRule struct {
+	Match string
+	Role  string
+}
+
+// ParseOIDCRoles parses a comma-separated list of match=role pairs, e.g.
+// "alice@example.com=admin,group:platform=submit,@example.com=read".
+func ParseOIDCRoles(s string) ([]OIDCRoleRule, error) {
+	var rules []OIDCRoleRule
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		match, role, ok := strings.Cut(part, "=")
+		match = strings.TrimSpace(match)
+		role = strings.ToLower(strings.TrimSpace(role))
+		if !ok || match == "" || match == "group:" {
+			return nil, fmt.Errorf("invalid role entry %q (use identity=role)", part)
+		}
+		if !slices.Contains(HubRoles, role) {
+			return nil, fmt.Errorf("invalid role %q for %s (use %s)", role, match, joinOr(HubRoles))
+		}
+		rules = append(rules, OIDCRoleRule{Match: match, Role: role})
+	}
+	return rules, nil
+}
+
+// HubOIDCEnabled reports whether people can sign in to the hub through
+// OIDC.
+func (p Preferences) HubOIDCEnabled() bool {
+	return p.HubOIDCIssuer != "" && p.HubOIDCClientID != ""
+}
+
+// EgressAllowlistHosts returns the configured egress allowlist entries.
+func (p Preferences) EgressAllowlistHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(p.EgressAllowlist, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// AzureResource returns the Azure OpenAI resource endpoint, falling back to
+// $AZURE_OPENAI_ENDPOINT, with the API version and configured deployments.
+func (p Preferences) AzureResource() (endpoint, apiVersion string, deployments []string) {
+	endpoint = p.AzureEndpoint
+	if endpoint == "" {
+		endpoint = strings.TrimSpace(os.Getenv("AZURE_OPENAI_ENDPOINT"))
+	}
+	for _, d := range strings.Split(p.AzureDeployments, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			deployments = append(deployments, d)
+		}
+	}
+	return endpoint, p.AzureAPIVersion, deployments
+}
+
+// AzureAD returns the Azure AD client credentials, falling back to the
+// standard AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET
+// variables for unset values.
+func (p Preferences) AzureAD() (tenantID, clientID, clientSecret string) {
+	pick := func(v, envVar string) string {
+		if v != "" {
+			return v
+		}
+		return strings.TrimSpace(os.Getenv(envVar))
+	}
+	return pick(p.AzureTenantID, "AZURE_TENANT_ID"), pick(p.AzureClientID, "AZURE_CLIENT_ID"), pick(p.AzureClientSecret, "AZURE_CLIENT_SECRET")
+}
+
+// Budgets returns the per-session and per-day spending limits in US
+// dollars. Zero means no limit; malformed values, which Set rejects, too.
+func (p Preferences) Budgets() (sessionUSD, dailyUSD float64) {
+	return parseBudget(p.BudgetSessionUSD), parseBudget(p.BudgetDailyUSD)
+}
+
+func parseBudget(v string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimPrefix(strings.TrimSpace(v), "$"), 64)
+	if err != nil || f <= 0 {
+		return 0
+	}
+	return f
+}
+
+// CheckpointRetention returns how long checkpoint refs of live sessions are
+// kept before garbage collection removes them (30 days by default).
+func (p Preferences) CheckpointRetention() time.Duration {
+	days, err := strconv.Atoi(strings.TrimSpace(p.CheckpointRetentionDays))
+	if err != nil || days <= 0 {
+		days = 30
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// DefaultToolParallelism is how many tool calls from one turn run at once
+// when tools.parallelism is unset.
+const DefaultToolParallelism = 4
+
+// ToolParallelism returns how many tool calls from one turn may run at once
+// (tools.parallelism, 4 by default). 1 runs them one after another.
+func (p Preferences) ToolParallelism() int {
+	n, err := strconv.Atoi(strings.TrimSpace(p.ToolsParallelism))
+	if err != nil || n <= 0 {
+		return DefaultToolParallelism
+	}
+	return n
+}
+
+// BlobMinBytes returns the size above which tool results are offloaded to
+// storage (storage.blob_min_kb), or 0 when they are kept in the database.
+func (p Preferences) BlobMinBytes() int {
+	kb, err := strconv.Atoi(strings.TrimSpace(p.StorageBlobMinKB))
+	if err != nil || kb <= 0 || p.StorageS3URL == "" {
+		return 0
+	}
+	return kb * 1024
+}
+
+// TUIGuardrail returns the screening policy for replies shown in the TUI.
+func (p Preferences) TUIGuardrail() guardrail.Policy {
+	return guardrail.NewPolicy(p.GuardrailTUI, p.GuardrailBannedTerms)
+}
+
+// OutboundGuardrail returns the screening policy for content sent through
+// external messaging tools.
+func (p Preferences) OutboundGuardrail() guardrail.Policy {
+	return guardrail.NewPolicy(p.GuardrailOutbound, p.GuardrailBannedTerms)
+}
+
+// OutboundPII returns the personal data policy for messaging tools.
+func (p Preferences) OutboundPII() guardrail.PIIPolicy {
+	return guardrail.NewPIIPolicy(p.PIIOutbound, p.PIIPatterns)
+}
+
+// Tool approval modes accepted by tools.approval_mode.
+const (
+	ApprovalOff   = "off"   // run every tool without asking
+	ApprovalWrite = "write" // ask before tools that change files or send messages
+	ApprovalAll   = "all"   // ask before every tool call
+)
+
+// Clipboard backends for the clipboard preference.
+const (
+	ClipboardAuto   = "auto"    // pick a backend for the platform and session
+	ClipboardNative = "native"  // pbcopy, PowerShell, or wl-copy/xclip
+	ClipboardXclip  = "xclip"   // X11 xclip
+	ClipboardWlCopy = "wl-copy" // Wayland wl-clipboard
+	ClipboardOSC52  = "osc52"   // terminal escape sequence; works over SSH and in tmux
+)
+
+// ParseClipboard validates a clipboard backend. Empty means auto.
+func ParseClipboard(s string) (string, error) {
+	switch b := strings.ToLower(strings.TrimSpace(s)); b {
+	case "":
+		return ClipboardAuto, nil
+	case ClipboardAuto, ClipboardNative, ClipboardXclip, ClipboardWlCopy, ClipboardOSC52:
+		return b, nil
+	default:
+		return "", fmt.Errorf("invalid clipboard backend %q (use auto, native, xclip, wl-copy, or osc52)", s)
+	}
+}
+
+// ClipboardBackend returns the effective clipboard preference.
+func (p Preferences) ClipboardBackend() string {
+	b, err := ParseClipboard(p.Clipboard)
+	if err != nil {
+		return ClipboardAuto
+	}
+	return b
+}
+
+// Input keymaps for the input.keymap preference.
+const (
+	KeymapEmacs = "emacs" // always inserting, with Ctrl+A/Ctrl+E line movement
+	KeymapVim   = "vim"   // modal editing with normal and insert modes
+)
+
+// ParseKeymap validates an input keymap. Empty means emacs.
+func ParseKeymap(s string) (string, error) {
+	switch k := strings.ToLower(strings.TrimSpace(s)); k {
+	case "":
+		return KeymapEmacs, nil
+	case KeymapEmacs, KeymapVim:
+		return k, nil
+	default:
+		return "", fmt.Errorf("invalid keymap %q (use emacs or vim)", s)
+	}
+}
+
+// Keymap returns the effective input keymap.
+func (p Preferences) Keymap() string {
+	k, err := ParseKeymap(p.InputKeymap)
+	if err != nil {
+		return KeymapEmacs
+	}
+	return k
+}
+
+// Shells accepted by shell.backend.
+const (
+	ShellAuto       = "auto"       // sh on Unix; Git Bash, then PowerShell, then cmd on Windows
+	ShellSh         = "sh"         // POSIX sh
+	ShellBash       = "bash"       // bash; Git Bash on Windows
+	ShellPwsh       = "pwsh"       // PowerShell 7+ (PowerShell Core)
+	ShellPowerShell = "powershell" // Windows PowerShell 5.1
+	ShellCmd        = "cmd"        // cmd.exe
+)
+
+// ParseShell validates a shell.backend value. Empty means auto.
+func ParseShell(s string) (string, error) {
+	switch sh := strings.ToLower(strings.TrimSpace(s)); sh {
+	case "":
+		return ShellAuto, nil
+	case ShellAuto, ShellSh, ShellBash, ShellPwsh, ShellPowerShell, ShellCmd:
+		return sh, nil
+	default:
+		return "", fmt.Errorf("invalid shell %q (use auto, sh, bash, pwsh, powershell, or cmd)", s)
+	}
+}
+
+// Shell returns the effective shell.backend.
+func (p Preferences) Shell() string {
+	sh, err := ParseShell(p.ShellBackend)
+	if err != nil {
+		return ShellAuto
+	}
+	return sh
+}
+
+// ParseApprovalMode validates a tool approval mode. Empty means off.
+func ParseApprovalMode(s string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "":
+		return ApprovalOff, nil
+	case ApprovalOff, ApprovalWrite, ApprovalAll:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid approval mode %q (use off, write, or all)", s)
+	}
+}
+
+// ApprovalMode returns the effective tools.approval_mode.
+func (p Preferences) ApprovalMode() string {
+	mode, err := ParseApprovalMode(p.ToolsApprovalMode)
+	if err != nil {
+		return ApprovalOff
+	}
+	return mode
+}
+
+// Misfire policies accepted by scheduler.misfire, for jobs that came due
+// while the daemon was not running.
+const (
+	MisfireRun  = "run"  // run the job as soon as the daemon starts
+	MisfireSkip = "skip" // skip the missed run; recurring jobs wait for the next one
+)
+
+// ParseMisfirePolicy validates a misfire policy. Empty means run.
+func ParseMisfirePolicy(s string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "":
+		return MisfireRun, nil
+	case MisfireRun, MisfireSkip:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid misfire policy %q (use run or skip)", s)
+	}
+}
+
+// MisfirePolicy returns the effective scheduler.misfire.
+func (p Preferences) MisfirePolicy() string {
+	m, err := ParseMisfirePolicy(p.SchedulerMisfire)
+	if err != nil {
+		return MisfireRun
+	}
+	return m
+}
+
+// DefaultSchedulerRetryBackoff is the delay before the first retry of a
+// failed scheduled job when scheduler.retry_backoff is unset.
+const DefaultSchedulerRetryBackoff = time.Minute
+
+// SchedulerRetry returns how many times a scheduled job runs before it is
+// marked failed (scheduler.max_attempts, 1 by default, so failures are not
+// retried) and the delay before its first retry (scheduler.retry_backoff).
+// Each further retry waits twice as long.
+func (p Preferences) SchedulerRetry() (maxAttempts int, backoff time.Duration) {
+	maxAttempts, err := strconv.Atoi(strings.TrimSpace(p.SchedulerMaxAttempts))
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = 1
+	}
+	backoff, err = time.ParseDuration(strings.TrimSpace(p.SchedulerRetryBackoff))
+	if err != nil || backoff <= 0 {
+		backoff = DefaultSchedulerRetryBackoff
+	}
+	return maxAttempts, backoff
+}
+
+// Notify returns the notification routes and sink settings from the
+// notify.* keys. Invalid sink lists route nowhere.
+func (p Preferences) Notify() notify.Config {
+	routes := map[string][]string{}
+	for event, v := range map[string]string{
+		notify.EventTurnDone:  p.NotifyOnTurnDone,
+		notify.EventJobDone:   p.NotifyOnJobDone,
+		notify.EventJobFailed: p.NotifyOnJobFailed,
+		notify.EventBudget:    p.NotifyOnBudget,
+	} {
+		if sinks, err := notify.ParseSinks(v); err == nil && len(sinks) > 0 {
+			routes[event] = sinks
+		}
+	}
+	return notify.Config{
+		Routes:         routes,
+		WebhookURL:     p.NotifyWebhookURL,
+		TelegramToken:  p.NotifyTelegramToken,
+		TelegramChatID: p.NotifyTelegramChatID,
+		Compliance:     ComplianceEnabled(p),
+	}
+}
+
+// SlackChannelList parses slack.channels into channel IDs. Empty means
+// every channel the bot is in.
+func (p Preferences) SlackChannelList() []string {
+	return splitIDs(p.SlackChannels)
+}
+
+// SlackAllowedUserList parses slack.allowed_users into user IDs.
+func (p Preferences) SlackAllowedUserList() []string {
+	return splitIDs(p.SlackAllowedUsers)
+}
+
+// DiscordAllowedUserList parses discord.allowed_users into user IDs.
+func (p Preferences) DiscordAllowedUserList() []string {
+	return splitIDs(p.DiscordAllowedUsers)
+}
+
+// DiscordChannelList parses discord.channels into channel IDs. Empty means
+// the bot answers in guild channels only when mentioned.
+func (p Preferences) DiscordChannelList() []string {
+	return splitIDs(p.DiscordChannels)
+}
+
+// DiscordAllowedPathList parses discord.allowed_paths into directories.
+// Empty means /cd is turned off.
+func (p Preferences) DiscordAllowedPathList() []string {
+	return splitIDs(p.DiscordAllowedPaths)
+}
+
+// Hubs returns the hubs this node registers with: hub.url, then hub.urls,
+// with duplicates and trailing slashes dropped. Empty means the node does
+// not join a hub.
+func (p Preferences) Hubs() []string {
+	var hubs []string
+	seen := make(map[string]bool)
+	for _, u := range append([]string{p.HubURL}, splitIDs(p.HubURLs)...) {
+		u = strings.TrimRight(strings.TrimSpace(u), "/")
+		if u != "" && !seen[u] {
+			seen[u] = true
+			hubs = append(hubs, u)
+		}
+	}
+	return hubs
+}
+
+// splitIDs parses a comma-separated list of IDs, dropping blanks.
+func splitIDs(s string) []string {
+	var out []string
+	for _, part := range strings.Split(s, ",") {
+		if id := strings.TrimSpace(part); id != "" {
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// StyleTones lists the response tone presets accepted by style.tone.
+var StyleTones = []string{"terse", "explanatory", "code-only"}
+
+// ParseStyleTone validates a tone preset. Empty or "default" clears it.
+func ParseStyleTone(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" || s == "default" {
+		return "", nil
+	}
+	for _, t := range StyleTones {
+		if s == t {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unknown tone %q (use %s, or default)", s, strings.Join(StyleTones, ", "))
+}
+
 // ParseBoolish parses a boolean-like string value.
 func ParseBoolish(s string) (bool, error) {
 	switch strings.ToLower(s) {
@@ -786,6 +1456,19 @@ func (p Preferences) DisabledToolsSet() map[string]bool {
 	return out
 }
 
+// DraftToolsSet parses scheduler.draft_tools: scheduled calls of these
+// tools are queued as drafts until the user approves them. Empty means
+// none.
+func (p Preferences) DraftToolsSet() map[string]bool {
+	out := map[string]bool{}
+	for _, part := range strings.Split(p.SchedulerDraftTools, ",") {
+		if name := strings.ToLower(strings.TrimSpace(part)); name != "" {
+			out[name] = true
+		}
+	}
+	return out
+}
+
 // ScheduledAllowedToolsSet parses scheduler.allowed_tools into a normalized set.
 // Empty value falls back to a safe default allowlist.
 func (p Preferences) ScheduledAllowedToolsSet() map[string]bool {
@@ -851,7 +1534,7 @@ func ExecuteConfigAction(prefs *Preferences, args []string) (string, error) {
 	case "show":
 		return FormatConfigGroups(prefs.Grouped()), nil
 
-	case "models", "tools", "daemon", "hub", "node", "theme":
+	case "models", "tools", "daemon", "hub", "node", "backup", "storage", "theme":
 		group := prefs.GroupByName(sub)
 		if group == nil {
 			return "", fmt.Errorf("unknown config group: %s", sub)
@@ -870,6 +1553,9 @@ func ExecuteConfigAction(prefs *Preferences, args []string) (string, error) {
 		if err := SavePreferences(*prefs); err != nil {
 			return "", fmt.Errorf("failed to save: %w", err)
 		}
+		if canonical := CanonicalKey(key); canonical != key {
+			return fmt.Sprintf("Set %s = %s (%s is deprecated; use %s)", canonical, prefs.Get(key), key, canonical), nil
+		}
 		return fmt.Sprintf("Set %s = %s", key, prefs.Get(key)), nil
 
 	case "reset":
@@ -5,8 +5,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/notify"
 )
 
 func TestConfigDir(t *testing.T) {
@@ -59,7 +63,7 @@ func TestDataDir(t *testing.T) {
 
 func TestConfigGroupNames(t *testing.T) {
 	names := ConfigGroupNames()
-	want := []string{"models", "tools", "daemon", "hub", "node", "theme"}
+	want := []string{"models", "tools", "daemon", "hub", "node", "backup", "storage", "theme"}
 	if len(names) != len(want) {
 		t.Fatalf("expected %d group names, got %d", len(want), len(names))
 	}
@@ -230,6 +234,56 @@ func TestResolveAPIKeySource(t *testing.T) {
 	})
 }
 
+func TestPreferencesHubs(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Preferences
+		want []string
+	}{
+		{"none", Preferences{}, nil},
+		{"url only", Preferences{HubURL: "http://a:4097"}, []string{"http://a:4097"}},
+		{"url then urls", Preferences{HubURL: "http://a:4097", HubURLs: "http://b:4097, http://c:4097"}, []string{"http://a:4097", "http://b:4097", "http://c:4097"}},
+		{"duplicates dropped", Preferences{HubURL: "http://a:4097/", HubURLs: "http://a:4097,http://b:4097"}, []string{"http://a:4097", "http://b:4097"}},
+		{"urls only", Preferences{HubURLs: "http://b:4097,"}, []string{"http://b:4097"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.p.Hubs()
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Hubs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOIDCRoles(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []OIDCRoleRule
+		wantErr bool
+	}{
+		{"empty", "", nil, false},
+		{"several", "ann@example.com=admin, group:eng=Submit, @example.com=read,", []OIDCRoleRule{
+			{"ann@example.com", "admin"}, {"group:eng", "submit"}, {"@example.com", "read"},
+		}, false},
+		{"missing role", "ann@example.com", nil, true},
+		{"unknown role", "*=owner", nil, true},
+		{"empty group", "group:=read", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseOIDCRoles(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOIDCRoles error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ParseOIDCRoles = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestDisabledToolsSet(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -741,6 +795,42 @@ func TestLoadProviderAPIKey_fireworksFromPrefs(t *testing.T) {
 	}
 }
 
+func TestLoadProviderAPIKey_azure(t *testing.T) {
+	for _, v := range []string{"AZURE_OPENAI_API_KEY", "AZURE_OPENAI_ENDPOINT", "AZURE_TENANT_ID", "AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET"} {
+		t.Setenv(v, "")
+	}
+	prefs := DefaultPreferences()
+	if _, err := LoadProviderAPIKey(prefs, "azure"); err == nil {
+		t.Error("expected error with neither a key nor AD credentials")
+	}
+
+	prefs.AzureAPIKey = "az-from-prefs"
+	if key, err := LoadProviderAPIKey(prefs, "azure"); err != nil || key != "az-from-prefs" {
+		t.Errorf("LoadProviderAPIKey = %q, %v", key, err)
+	}
+
+	// AD credentials stand in for the key; unset ones come from the environment.
+	prefs.AzureAPIKey = ""
+	prefs.AzureTenantID = "tenant"
+	t.Setenv("AZURE_CLIENT_ID", "app")
+	t.Setenv("AZURE_CLIENT_SECRET", "secret")
+	if key, err := LoadProviderAPIKey(prefs, "azure"); err != nil || key != "" {
+		t.Errorf("with AD credentials: %q, %v", key, err)
+	}
+	if tenant, client, secret := prefs.AzureAD(); tenant != "tenant" || client != "app" || secret != "secret" {
+		t.Errorf("AzureAD() = %q %q %q", tenant, client, secret)
+	}
+
+	t.Setenv("AZURE_OPENAI_ENDPOINT", "https://env.openai.azure.com")
+	prefs.AzureDeployments = "gpt-4o-prod, gpt-4o-mini"
+	if endpoint, version, deployments := prefs.AzureResource(); endpoint != "https://env.openai.azure.com" || version != "" || len(deployments) != 2 {
+		t.Errorf("AzureResource() = %q %q %v", endpoint, version, deployments)
+	}
+	if err := prefs.Set("azure.endpoint", "http://insecure.example.com"); err == nil {
+		t.Error("expected an error for a non-https endpoint")
+	}
+}
+
 func TestGet_additionalKeys(t *testing.T) {
 	p := DefaultPreferences()
 	p.ToolsDisabled = "web_fetch,bash"
@@ -784,6 +874,265 @@ func TestSet_additionalKeys(t *testing.T) {
 	}
 }
 
+func TestSet_approvalMode(t *testing.T) {
+	p := DefaultPreferences()
+	if got := p.Get("tools.approval_mode"); got != ApprovalOff {
+		t.Errorf("default approval mode = %q, want off", got)
+	}
+	if err := p.Set("tools.approval_mode", "Write"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if p.ApprovalMode() != ApprovalWrite {
+		t.Errorf("ApprovalMode = %q, want write", p.ApprovalMode())
+	}
+	if err := p.Set("tools.approval_mode", "sometimes"); err == nil {
+		t.Error("expected error for invalid approval mode")
+	}
+}
+
+func TestSet_budgets(t *testing.T) {
+	p := DefaultPreferences()
+	if s, d := p.Budgets(); s != 0 || d != 0 {
+		t.Errorf("default budgets = %v, %v; want none", s, d)
+	}
+	if err := p.Set("budget.session_usd", "2.50"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if err := p.Set("budget.daily_usd", "$20"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if s, d := p.Budgets(); s != 2.5 || d != 20 {
+		t.Errorf("Budgets = %v, %v; want 2.5, 20", s, d)
+	}
+	for _, bad := range []string{"0", "-1", "lots"} {
+		if err := p.Set("budget.daily_usd", bad); err == nil {
+			t.Errorf("expected error for budget %q", bad)
+		}
+	}
+}
+
+func TestSet_checkpointRetention(t *testing.T) {
+	p := DefaultPreferences()
+	if got := p.CheckpointRetention(); got != 30*24*time.Hour {
+		t.Errorf("default retention = %v, want 30 days", got)
+	}
+	if err := p.Set("checkpoint.retention_days", "7"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if got := p.CheckpointRetention(); got != 7*24*time.Hour {
+		t.Errorf("retention = %v, want 7 days", got)
+	}
+	for _, bad := range []string{"0", "-3", "week"} {
+		if err := p.Set("checkpoint.retention_days", bad); err == nil {
+			t.Errorf("expected error for retention %q", bad)
+		}
+	}
+}
+
+func TestSet_clipboard(t *testing.T) {
+	p := DefaultPreferences()
+	if got := p.ClipboardBackend(); got != ClipboardAuto {
+		t.Errorf("default clipboard = %q, want auto", got)
+	}
+	if err := p.Set("clipboard", "OSC52"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if got := p.ClipboardBackend(); got != ClipboardOSC52 {
+		t.Errorf("clipboard = %q, want osc52", got)
+	}
+	if err := p.Set("clipboard", "pbcopy"); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
+
+func TestSet_inputKeymap(t *testing.T) {
+	p := DefaultPreferences()
+	if got := p.Keymap(); got != KeymapEmacs {
+		t.Errorf("default keymap = %q, want emacs", got)
+	}
+	if err := p.Set("input.keymap", "Vim"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if got := p.Keymap(); got != KeymapVim {
+		t.Errorf("keymap = %q, want vim", got)
+	}
+	if err := p.Set("input.keymap", "helix"); err == nil {
+		t.Error("expected error for unknown keymap")
+	}
+}
+
+func TestSet_shellBackend(t *testing.T) {
+	p := DefaultPreferences()
+	if got := p.Shell(); got != ShellAuto {
+		t.Errorf("default shell = %q, want auto", got)
+	}
+	if err := p.Set("shell.backend", "PWSH"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if got := p.Shell(); got != ShellPwsh {
+		t.Errorf("shell = %q, want pwsh", got)
+	}
+	if err := p.Set("shell.backend", "fish"); err == nil {
+		t.Error("expected error for unknown shell")
+	}
+	// The old name still works.
+	if err := p.Set("tools.shell", "cmd"); err != nil {
+		t.Fatalf("Set tools.shell error: %v", err)
+	}
+	if got := p.Shell(); got != ShellCmd {
+		t.Errorf("shell = %q, want cmd", got)
+	}
+}
+
+func TestSet_toolsParallelism(t *testing.T) {
+	p := DefaultPreferences()
+	if got := p.ToolParallelism(); got != DefaultToolParallelism {
+		t.Errorf("default parallelism = %d, want %d", got, DefaultToolParallelism)
+	}
+	if err := p.Set("tools.parallelism", "2"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if got := p.ToolParallelism(); got != 2 {
+		t.Errorf("parallelism = %d, want 2", got)
+	}
+	for _, v := range []string{"0", "-1", "many"} {
+		if err := p.Set("tools.parallelism", v); err == nil {
+			t.Errorf("Set(%q): expected error", v)
+		}
+	}
+}
+
+func TestSet_schedulerRetry(t *testing.T) {
+	p := DefaultPreferences()
+	if n, d := p.SchedulerRetry(); n != 1 || d != DefaultSchedulerRetryBackoff {
+		t.Errorf("default retry = %d, %v", n, d)
+	}
+	if got := p.MisfirePolicy(); got != MisfireRun {
+		t.Errorf("default misfire = %q, want run", got)
+	}
+	for key, v := range map[string]string{"scheduler.max_attempts": "3", "scheduler.retry_backoff": "30s", "scheduler.misfire": "SKIP"} {
+		if err := p.Set(key, v); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	if n, d := p.SchedulerRetry(); n != 3 || d != 30*time.Second {
+		t.Errorf("retry = %d, %v; want 3, 30s", n, d)
+	}
+	if got := p.MisfirePolicy(); got != MisfireSkip {
+		t.Errorf("misfire = %q, want skip", got)
+	}
+	for key, v := range map[string]string{"scheduler.max_attempts": "0", "scheduler.retry_backoff": "10ms", "scheduler.misfire": "later"} {
+		if err := p.Set(key, v); err == nil {
+			t.Errorf("Set(%s, %q): expected error", key, v)
+		}
+	}
+}
+
+func TestSet_notify(t *testing.T) {
+	p := DefaultPreferences()
+	if cfg := p.Notify(); len(cfg.Routes) != 0 {
+		t.Errorf("default routes = %v, want none", cfg.Routes)
+	}
+	if err := p.Set("notify.on_turn_done", "Desktop, webhook"); err != nil {
+		t.Fatal(err)
+	}
+	if p.NotifyOnTurnDone != "desktop,webhook" {
+		t.Errorf("NotifyOnTurnDone = %q, want it normalized", p.NotifyOnTurnDone)
+	}
+	for key, v := range map[string]string{"notify.on_job_failed": "telegram", "notify.webhook_url": "https://example.com/hook", "notify.telegram_token": "123:abc", "notify.telegram_chat_id": "42"} {
+		if err := p.Set(key, v); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	cfg := p.Notify()
+	if got := strings.Join(cfg.Routes[notify.EventTurnDone], ","); got != "desktop,webhook" {
+		t.Errorf("turn_done routes = %q", got)
+	}
+	if got := strings.Join(cfg.Routes[notify.EventJobFailed], ","); got != "telegram" {
+		t.Errorf("job_failed routes = %q", got)
+	}
+	if cfg.WebhookURL != "https://example.com/hook" || cfg.TelegramToken != "123:abc" || cfg.TelegramChatID != "42" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+	if got := p.Get("notify.telegram_token"); got == "123:abc" {
+		t.Error("telegram token should be masked")
+	}
+	if err := p.Set("notify.on_budget", "desktop,pager"); err == nil {
+		t.Error("unknown sink: expected error")
+	}
+	if err := p.Set("notify.webhook_url", "ftp://example.com"); err == nil {
+		t.Error("non-http webhook URL: expected error")
+	}
+	if err := p.Set("notify.on_turn_done", ""); err != nil || p.Notify().Routes[notify.EventTurnDone] != nil {
+		t.Errorf("clearing turn_done: %v", err)
+	}
+}
+
+func TestSet_slack(t *testing.T) {
+	p := DefaultPreferences()
+	if got := p.SlackChannelList(); got != nil {
+		t.Errorf("default channels = %v, want none", got)
+	}
+	for key, v := range map[string]string{"slack.app_token": "xapp-1-abc", "slack.bot_token": "xoxb-123", "slack.allowed_users": "U01, U02", "slack.channels": "C01, C02,", "slack.autostart": "on"} {
+		if err := p.Set(key, v); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	if got := strings.Join(p.SlackAllowedUserList(), ","); got != "U01,U02" {
+		t.Errorf("SlackAllowedUserList = %q", got)
+	}
+	if got := strings.Join(p.SlackChannelList(), ","); got != "C01,C02" {
+		t.Errorf("SlackChannelList = %q", got)
+	}
+	if !p.SlackAutostart || p.SlackAppToken != "xapp-1-abc" {
+		t.Errorf("prefs = %+v", p)
+	}
+	if got := p.Get("slack.bot_token"); got == "xoxb-123" {
+		t.Error("bot token should be masked")
+	}
+}
+
+func TestSet_discord(t *testing.T) {
+	p := DefaultPreferences()
+	for key, v := range map[string]string{"discord.token": "tok", "discord.allowed_users": "111, 222", "discord.channels": "333", "discord.autostart": "true"} {
+		if err := p.Set(key, v); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	if got := strings.Join(p.DiscordAllowedUserList(), ","); got != "111,222" {
+		t.Errorf("DiscordAllowedUserList = %q", got)
+	}
+	if got := p.DiscordChannelList(); len(got) != 1 || got[0] != "333" || !p.DiscordAutostart {
+		t.Errorf("prefs = %+v", p)
+	}
+	if got := p.Get("discord.token"); got == "tok" {
+		t.Error("token should be masked")
+	}
+}
+
+func TestSet_storageKeys(t *testing.T) {
+	p := DefaultPreferences()
+	if err := p.Set("backup.s3_url", "https://s3.example.com/bucket/muxd"); err != nil {
+		t.Fatalf("Set via former key: %v", err)
+	}
+	if p.StorageS3URL != "https://s3.example.com/bucket/muxd" {
+		t.Errorf("StorageS3URL = %q", p.StorageS3URL)
+	}
+	if err := p.Set("storage.blob_min_kb", "64"); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+	if got := p.BlobMinBytes(); got != 64*1024 {
+		t.Errorf("BlobMinBytes = %d, want %d", got, 64*1024)
+	}
+	p.StorageS3URL = ""
+	if got := p.BlobMinBytes(); got != 0 {
+		t.Errorf("BlobMinBytes without storage = %d, want 0", got)
+	}
+	if err := p.Set("storage.blob_min_kb", "big"); err == nil {
+		t.Error("expected error for invalid blob size")
+	}
+}
+
 func TestSet_boolishKeys(t *testing.T) {
 	tests := []struct {
 		key   string
@@ -885,3 +1234,120 @@ func TestPerTaskModelConfig(t *testing.T) {
 		}
 	})
 }
+
+func TestDescribeKey_coversAllKeys(t *testing.T) {
+	for _, key := range ValidConfigKeys() {
+		if DescribeKey(key).Description == "" {
+			t.Errorf("config key %s has no description", key)
+		}
+	}
+}
+
+func TestCustomProviders(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	p := DefaultPreferences()
+	if err := p.Set("providers.custom.vllm.api_key", "k"); err == nil {
+		t.Error("expected an error setting api_key before base_url")
+	}
+	for key, value := range map[string]string{
+		"providers.custom.VLLM.base_url":   "http://x",
+		"providers.custom.openai.base_url": "http://x",
+		"providers.custom.vllm.base_url":   "ftp://x",
+	} {
+		if err := p.Set(key, value); err == nil {
+			t.Errorf("Set(%s, %s): expected error", key, value)
+		}
+	}
+
+	mustSet := func(key, value string) {
+		t.Helper()
+		if err := p.Set(key, value); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	mustSet("providers.custom.vllm.base_url", "http://gpu-box:8000/v1/")
+	mustSet("providers.custom.vllm.api_key", "secret-token-1234")
+	mustSet("providers.custom.vllm.models", " llama-3, mixtral ,")
+
+	if got := p.Get("providers.custom.vllm.base_url"); got != "http://gpu-box:8000/v1" {
+		t.Errorf("base_url = %q", got)
+	}
+	if got := p.Get("providers.custom.vllm.api_key"); got != "****1234" {
+		t.Errorf("api_key shown as %q, want masked", got)
+	}
+	if got := p.CustomProviders["vllm"].ModelList(); len(got) != 2 || got[1] != "mixtral" {
+		t.Errorf("models = %v", got)
+	}
+	if key, err := LoadProviderAPIKey(p, "vllm"); err != nil || key != "secret-token-1234" {
+		t.Errorf("LoadProviderAPIKey = %q, %v", key, err)
+	}
+	if DescribeKey("providers.custom.vllm.api_key").Type != KeyTypeSecret {
+		t.Error("api_key not described as a secret")
+	}
+
+	found := false
+	for _, e := range p.GroupByName("models").Entries {
+		if e.Key == "providers.custom.vllm.models" && e.Value == "llama-3,mixtral" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("custom provider not listed in the models group")
+	}
+
+	if err := SavePreferences(p); err != nil {
+		t.Fatal(err)
+	}
+	loaded := LoadPreferences()
+	if loaded.CustomProviders["vllm"] != p.CustomProviders["vllm"] {
+		t.Errorf("round trip = %+v", loaded.CustomProviders)
+	}
+
+	// A keyless local server is fine.
+	mustSet("providers.custom.lmstudio.base_url", "http://localhost:1234/v1")
+	if key, err := LoadProviderAPIKey(p, "lmstudio"); err != nil || key != "" {
+		t.Errorf("LoadProviderAPIKey(lmstudio) = %q, %v", key, err)
+	}
+
+	// Clearing base_url removes the provider without touching copies.
+	snapshot := p
+	mustSet("providers.custom.vllm.base_url", "")
+	if _, ok := p.CustomProviders["vllm"]; ok {
+		t.Error("vllm still configured")
+	}
+	if _, ok := snapshot.CustomProviders["vllm"]; !ok {
+		t.Error("removing a provider changed an earlier copy")
+	}
+}
+
+func TestSamplingPrefs(t *testing.T) {
+	p := DefaultPreferences()
+	if d := p.Sampling(); d.Temperature != nil || d.TopP != nil || d.Seed != nil || d.MaxTokens != 0 || d.Stop != nil {
+		t.Fatalf("defaults = %+v, want all unset", d)
+	}
+	for _, kv := range [][2]string{{"sampling.temperature", "0.2"}, {"sampling.top_p", "0.95"}, {"sampling.seed", "42"}, {"sampling.max_tokens", "4096"}, {"sampling.stop", `END, \n\n`}} {
+		if err := p.Set(kv[0], kv[1]); err != nil {
+			t.Fatalf("Set(%s): %v", kv[0], err)
+		}
+	}
+	d := p.Sampling()
+	if d.Temperature == nil || *d.Temperature != 0.2 || d.TopP == nil || *d.TopP != 0.95 || d.Seed == nil || *d.Seed != 42 {
+		t.Errorf("Sampling() = %+v", d)
+	}
+	if d.MaxTokens != 4096 || len(d.Stop) != 2 || d.Stop[0] != "END" || d.Stop[1] != "\n\n" {
+		t.Errorf("limits = %d %q", d.MaxTokens, d.Stop)
+	}
+
+	for _, kv := range [][2]string{{"sampling.temperature", "3"}, {"sampling.top_p", "0"}, {"sampling.seed", "1.5"}, {"sampling.max_tokens", "0"}, {"sampling.stop", " , "}} {
+		if err := p.Set(kv[0], kv[1]); err == nil {
+			t.Errorf("Set(%s, %s): expected error", kv[0], kv[1])
+		}
+	}
+	if err := p.Set("sampling.seed", ""); err != nil {
+		t.Fatalf("clearing seed: %v", err)
+	}
+	if d := p.Sampling(); d.Seed != nil {
+		t.Errorf("seed = %v after clearing", *d.Seed)
+	}
+}
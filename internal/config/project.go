@@ -0,0 +1,159 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ProjectConfigFile is the per-project settings file, read from the
+// project root.
+const ProjectConfigFile = ".muxd.toml"
+
+// ProjectKeys are the settings a project file may set: how the agent works
+// in that project. Credentials, network, and approval settings stay with
+// the user, so a cloned repository cannot change them.
+var ProjectKeys = []string{
+	"model.compact", "model.title", "model.tags", "model.consult", "model.verify",
+	"style.language", "style.tone",
+	"sampling.temperature", "sampling.top_p", "sampling.seed", "sampling.max_tokens", "sampling.stop",
+	"tools.disabled",
+}
+
+// ApplyProjectConfig overlays the settings in dir's .muxd.toml on p.
+// tools.disabled is added to the user's list rather than replacing it, so
+// a project can only turn tools off. A missing file is not an error; keys
+// outside ProjectKeys are.
+func ApplyProjectConfig(p *Preferences, dir string) error {
+	path := filepath.Join(dir, ProjectConfigFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	values, err := parseProjectConfig(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var errs []string
+	for _, kv := range values {
+		key := CanonicalKey(kv[0])
+		if !slices.Contains(ProjectKeys, key) {
+			errs = append(errs, fmt.Sprintf("%s cannot be set per project", kv[0]))
+			continue
+		}
+		value := kv[1]
+		if key == "tools.disabled" && p.ToolsDisabled != "" {
+			value = p.ToolsDisabled + "," + value
+		}
+		if err := p.Set(key, value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", kv[0], err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %s", path, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// parseProjectConfig reads the subset of TOML a settings file needs:
+// [section] headers, key = value pairs, and # comments. Values are quoted
+// strings, bare numbers and booleans, or one-line arrays, which become
+// comma-separated lists. It returns [key, value] pairs in file order, keys
+// prefixed with their section.
+func parseProjectConfig(data []byte) ([][2]string, error) {
+	var out [][2]string
+	section := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(stripTOMLComment(sc.Text()))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated section header", n)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.TrimSpace(key)
+		if section != "" {
+			key = section + "." + key
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		out = append(out, [2]string{key, value})
+	}
+	return out, sc.Err()
+}
+
+// stripTOMLComment cuts a # comment from line, leaving #s inside quoted
+// strings alone.
+func stripTOMLComment(line string) string {
+	inString := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if inString {
+				i++
+			}
+		case '"':
+			inString = !inString
+		case '#':
+			if !inString {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func parseTOMLValue(raw string) (string, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", raw)
+		}
+		return s, nil
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return "", fmt.Errorf("arrays must be on one line")
+		}
+		inner := strings.TrimSpace(raw[1 : len(raw)-1])
+		if inner == "" {
+			return "", nil
+		}
+		var items []string
+		for _, item := range strings.Split(inner, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue // trailing comma
+			}
+			v, err := parseTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ","), nil
+	case raw == "":
+		return "", fmt.Errorf("missing value")
+	default:
+		return raw, nil
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProjectConfig(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ProjectConfigFile), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestApplyProjectConfig(t *testing.T) {
+	t.Run("missing file leaves preferences alone", func(t *testing.T) {
+		p := Preferences{StyleTone: "formal"}
+		if err := ApplyProjectConfig(&p, t.TempDir()); err != nil {
+			t.Fatalf("ApplyProjectConfig: %v", err)
+		}
+		if p.StyleTone != "formal" {
+			t.Errorf("StyleTone = %q, want formal", p.StyleTone)
+		}
+	})
+
+	t.Run("sections, comments, and values", func(t *testing.T) {
+		dir := writeProjectConfig(t, `# project settings
+[style]
+language = "Pig # Latin"  # a comment
+tone = "terse"
+
+[sampling]
+temperature = 0.2
+stop = ["END", "STOP",]
+`)
+		var p Preferences
+		if err := ApplyProjectConfig(&p, dir); err != nil {
+			t.Fatalf("ApplyProjectConfig: %v", err)
+		}
+		if p.StyleLanguage != "Pig # Latin" {
+			t.Errorf("StyleLanguage = %q", p.StyleLanguage)
+		}
+		if p.StyleTone != "terse" {
+			t.Errorf("StyleTone = %q, want terse", p.StyleTone)
+		}
+		if p.SamplingTemperature != "0.2" {
+			t.Errorf("SamplingTemperature = %q, want 0.2", p.SamplingTemperature)
+		}
+		if got := p.Get("sampling.stop"); !strings.Contains(got, "END") || !strings.Contains(got, "STOP") {
+			t.Errorf("sampling.stop = %q, want END and STOP", got)
+		}
+	})
+
+	t.Run("tools.disabled adds to the user's list", func(t *testing.T) {
+		dir := writeProjectConfig(t, "[tools]\ndisabled = [\"sms_send\"]\n")
+		p := Preferences{ToolsDisabled: "web_fetch"}
+		if err := ApplyProjectConfig(&p, dir); err != nil {
+			t.Fatalf("ApplyProjectConfig: %v", err)
+		}
+		set := p.DisabledToolsSet()
+		if !set["web_fetch"] || !set["sms_send"] {
+			t.Errorf("DisabledToolsSet = %v, want web_fetch and sms_send", set)
+		}
+	})
+
+	t.Run("rejects keys outside ProjectKeys", func(t *testing.T) {
+		dir := writeProjectConfig(t, "[anthropic]\napi_key = \"sk-test\"\n")
+		var p Preferences
+		err := ApplyProjectConfig(&p, dir)
+		if err == nil || !strings.Contains(err.Error(), "cannot be set per project") {
+			t.Fatalf("expected per-project error, got %v", err)
+		}
+		if p.AnthropicAPIKey != "" {
+			t.Error("api key should not have been set")
+		}
+	})
+
+	t.Run("reports malformed lines", func(t *testing.T) {
+		dir := writeProjectConfig(t, "[style\n")
+		var p Preferences
+		if err := ApplyProjectConfig(&p, dir); err == nil || !strings.Contains(err.Error(), "line 1") {
+			t.Fatalf("expected line 1 error, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Sampling parameters
+// ---------------------------------------------------------------------------
+
+// SamplingDefaults are the configured sampling parameters for new sessions.
+// Nil and zero fields leave the provider default in place.
+type SamplingDefaults struct {
+	Temperature *float64
+	TopP        *float64
+	Seed        *int64
+	MaxTokens   int
+	Stop        []string
+}
+
+// ParseTemperature parses a sampling temperature between 0 and 2.
+func ParseTemperature(v string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || f < 0 || f > 2 {
+		return 0, fmt.Errorf("invalid temperature %q (want a number from 0 to 2)", v)
+	}
+	return f, nil
+}
+
+// ParseTopP parses a top_p cutoff above 0 and at most 1.
+func ParseTopP(v string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil || f <= 0 || f > 1 {
+		return 0, fmt.Errorf("invalid top_p %q (want a number above 0 and at most 1)", v)
+	}
+	return f, nil
+}
+
+// ParseSeed parses a sampling seed.
+func ParseSeed(v string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid seed %q (want an integer)", v)
+	}
+	return n, nil
+}
+
+// ParseMaxTokens parses a positive output token limit.
+func ParseMaxTokens(v string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid max_tokens %q (want a positive number)", v)
+	}
+	return n, nil
+}
+
+// stopEscapes lets stop sequences contain newlines and tabs.
+var stopEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t")
+
+// ParseStopSequences parses comma-separated stop sequences, in which \n and
+// \t stand for a newline and a tab.
+func ParseStopSequences(v string) ([]string, error) {
+	var out []string
+	for _, seq := range strings.Split(v, ",") {
+		if seq = stopEscapes.Replace(strings.TrimSpace(seq)); seq != "" {
+			out = append(out, seq)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("invalid stop sequences %q (want a comma-separated list)", v)
+	}
+	return out, nil
+}
+
+// Sampling returns the configured sampling defaults, skipping unset or
+// invalid values.
+func (p Preferences) Sampling() SamplingDefaults {
+	var d SamplingDefaults
+	if f, err := ParseTemperature(p.SamplingTemperature); err == nil {
+		d.Temperature = &f
+	}
+	if f, err := ParseTopP(p.SamplingTopP); err == nil {
+		d.TopP = &f
+	}
+	if n, err := ParseSeed(p.SamplingSeed); err == nil {
+		d.Seed = &n
+	}
+	if n, err := ParseMaxTokens(p.SamplingMaxTokens); err == nil {
+		d.MaxTokens = n
+	}
+	if stop, err := ParseStopSequences(p.SamplingStop); err == nil {
+		d.Stop = stop
+	}
+	return d
+}
@@ -0,0 +1,694 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/guardrail"
+	"github.com/batalabs/muxd/internal/notify"
+)
+
+// ---------------------------------------------------------------------------
+// Preference schema
+// ---------------------------------------------------------------------------
+
+// Key types, used by the TUI to pick an editor and listed in the docs.
+const (
+	KeyTypeString = "string"
+	KeyTypeBool   = "bool"
+	KeyTypeEnum   = "enum"
+	KeyTypeList   = "list"
+	KeyTypeSecret = "secret"
+)
+
+// KeyDoc describes a config key for the config picker, completion, and docs.
+type KeyDoc struct {
+	Type        string   // one of the KeyType constants
+	Description string   // one line, lower case
+	Hint        string   // accepted values; empty for free text
+	Values      []string // accepted values of bool and enum keys
+}
+
+// prefField declares one config key: the Preferences field behind it, how
+// it is displayed, parsed, and validated, and the names it used to have.
+// Get, Set, All, ConfigGroupDefs, and the docs are all driven by prefSchema.
+type prefField struct {
+	key   string
+	group string
+	doc   KeyDoc
+
+	// deprecated lists old names of the key. Get and Set still accept them,
+	// and their config.json fields are renamed on load.
+	deprecated []string
+	// json overrides the config.json field name, which is otherwise the key
+	// with dots replaced by underscores.
+	json string
+
+	get     func(p *Preferences) string
+	display func(p *Preferences) string // for listings; defaults to get
+	set     func(p *Preferences, value string) error
+	str     func(p *Preferences) *string // backing string, sanitized on load
+}
+
+var configGroupOrder = []string{"models", "tools", "daemon", "hub", "node", "backup", "storage", "theme"}
+
+var prefSchema = []prefField{
+	stringPref("model", "models", "main model for new turns", "model ID or alias, optionally provider/model", func(p *Preferences) *string { return &p.Model }),
+	stringPref("model.compact", "models", "model used to summarize history when compacting", "model ID; empty uses the main model", func(p *Preferences) *string { return &p.ModelCompact }),
+	stringPref("model.title", "models", "model used to title sessions", "model ID; empty uses the main model", func(p *Preferences) *string { return &p.ModelTitle }),
+	stringPref("model.tags", "models", "model used to tag sessions", "model ID; empty uses the main model", func(p *Preferences) *string { return &p.ModelTags }),
+	stringPref("model.consult", "models", "model asked for second opinions by the consult tool", "model ID", func(p *Preferences) *string { return &p.ModelConsult }),
+	stringPref("model.verify", "models", "model that checks final answers when /verify is on", "model ID; empty uses model.consult, then the main model", func(p *Preferences) *string { return &p.ModelVerify }),
+	stringPref("style.language", "models", "language the agent replies in", "language name, e.g. German", func(p *Preferences) *string { return &p.StyleLanguage }),
+	enumPref("style.tone", "models", "tone of the agent's replies", append(append([]string{}, StyleTones...), "default"),
+		func(p *Preferences) *string { return &p.StyleTone }, ParseStyleTone),
+	stringPref("sampling.temperature", "models", "sampling temperature for new sessions", "0 to 2; empty uses the provider default", func(p *Preferences) *string { return &p.SamplingTemperature }).
+		validated(func(v string) error { _, err := ParseTemperature(v); return err }),
+	stringPref("sampling.top_p", "models", "nucleus sampling cutoff for new sessions", "above 0, at most 1; empty uses the provider default", func(p *Preferences) *string { return &p.SamplingTopP }).
+		validated(func(v string) error { _, err := ParseTopP(v); return err }),
+	stringPref("sampling.seed", "models", "random seed for new sessions, where the provider supports one", "integer; empty for none", func(p *Preferences) *string { return &p.SamplingSeed }).
+		validated(func(v string) error { _, err := ParseSeed(v); return err }),
+	stringPref("sampling.max_tokens", "models", "output token limit per response for new sessions", "positive number; empty uses the provider default", func(p *Preferences) *string { return &p.SamplingMaxTokens }).
+		validated(func(v string) error { _, err := ParseMaxTokens(v); return err }),
+	stringPref("sampling.stop", "models", "stop sequences for new sessions", `comma-separated; \n is a newline`, func(p *Preferences) *string { return &p.SamplingStop }).
+		withType(KeyTypeList).
+		validated(func(v string) error { _, err := ParseStopSequences(v); return err }),
+	secretPref("anthropic.api_key", "models", "Anthropic API key", "ANTHROPIC_API_KEY", func(p *Preferences) *string { return &p.AnthropicAPIKey }),
+	secretPref("zai.api_key", "models", "Z.AI API key", "ZAI_API_KEY", func(p *Preferences) *string { return &p.ZAIAPIKey }),
+	boolPref("zai.coding_plan", "models", "use the Z.AI coding plan endpoint", func(p *Preferences) *bool { return &p.ZAICodingPlan }),
+	secretPref("grok.api_key", "models", "xAI (Grok) API key", "XAI_API_KEY", func(p *Preferences) *string { return &p.GrokAPIKey }),
+	secretPref("mistral.api_key", "models", "Mistral API key", "MISTRAL_API_KEY", func(p *Preferences) *string { return &p.MistralAPIKey }),
+	secretPref("openai.api_key", "models", "OpenAI API key", "OPENAI_API_KEY", func(p *Preferences) *string { return &p.OpenAIAPIKey }),
+	secretPref("google.api_key", "models", "Google Gemini API key", "GOOGLE_API_KEY", func(p *Preferences) *string { return &p.GoogleAPIKey }),
+	secretPref("fireworks.api_key", "models", "Fireworks API key", "FIREWORKS_API_KEY", func(p *Preferences) *string { return &p.FireworksAPIKey }),
+	secretPref("deepinfra.api_key", "models", "DeepInfra API key", "DEEPINFRA_API_KEY", func(p *Preferences) *string { return &p.DeepInfraAPIKey }),
+	secretPref("azure.api_key", "models", "Azure OpenAI API key", "AZURE_OPENAI_API_KEY", func(p *Preferences) *string { return &p.AzureAPIKey }),
+	stringPref("azure.endpoint", "models", "Azure OpenAI resource endpoint", "https://<resource>.openai.azure.com; empty uses $AZURE_OPENAI_ENDPOINT", func(p *Preferences) *string { return &p.AzureEndpoint }).
+		validated(validateAzureEndpoint),
+	stringPref("azure.api_version", "models", "Azure OpenAI API version", "e.g. 2024-10-21; empty uses 2024-10-21", func(p *Preferences) *string { return &p.AzureAPIVersion }),
+	stringPref("azure.deployments", "models", "Azure deployments offered in the model picker", "comma-separated deployment names", func(p *Preferences) *string { return &p.AzureDeployments }).
+		withType(KeyTypeList),
+	stringPref("azure.tenant_id", "models", "Azure AD tenant for token auth", "tenant ID; empty uses $AZURE_TENANT_ID", func(p *Preferences) *string { return &p.AzureTenantID }),
+	stringPref("azure.client_id", "models", "Azure AD app (client) ID for token auth", "client ID; empty uses $AZURE_CLIENT_ID", func(p *Preferences) *string { return &p.AzureClientID }),
+	secretPref("azure.client_secret", "models", "Azure AD client secret; with tenant and client ID, replaces the API key", "AZURE_CLIENT_SECRET", func(p *Preferences) *string { return &p.AzureClientSecret }),
+	stringPref("ollama.url", "models", "Ollama server URL", "http(s)://host[:port]", func(p *Preferences) *string { return &p.OllamaURL }),
+	stringPref("proxy.url", "models", "proxy for all provider requests", "http(s):// or socks5://host:port", func(p *Preferences) *string { return &p.ProxyURL }).
+		validated(ValidateProxyURL),
+	stringPref("proxy.providers", "models", "per-provider proxies, overriding proxy.url", "provider=url,provider=url", func(p *Preferences) *string { return &p.ProxyProviders }).
+		validated(func(v string) error { _, err := ParseProviderProxies(v); return err }),
+	stringPref("budget.session_usd", "models", "spending limit per session; turns stop when it is reached", "US dollars, e.g. 5; empty for no limit", func(p *Preferences) *string { return &p.BudgetSessionUSD }).
+		validated(validateBudget),
+	stringPref("budget.daily_usd", "models", "spending limit per day across all sessions", "US dollars, e.g. 20; empty for no limit", func(p *Preferences) *string { return &p.BudgetDailyUSD }).
+		validated(validateBudget),
+
+	listPref("tools.disabled", "tools", "tools the agent may not call", func(p *Preferences) *string { return &p.ToolsDisabled }),
+	{
+		key: "tools.ask_user", group: "tools",
+		doc: KeyDoc{Type: KeyTypeBool, Description: "let the agent ask you questions mid-turn", Hint: boolHint, Values: boolValues},
+		get: func(p *Preferences) string { return strconv.FormatBool(p.ToolsAskUser == nil || *p.ToolsAskUser) },
+		set: func(p *Preferences, v string) error {
+			b, err := ParseBoolish(v)
+			if err != nil {
+				return err
+			}
+			p.ToolsAskUser = &b
+			return nil
+		},
+	},
+	enumPref("tools.approval_mode", "tools", "which tool calls need your approval", []string{ApprovalOff, ApprovalWrite, ApprovalAll},
+		func(p *Preferences) *string { return &p.ToolsApprovalMode }, ParseApprovalMode).
+		withGet(Preferences.ApprovalMode),
+	enumPref("shell.backend", "tools", "shell used by the bash tool, custom tools, and shell mode", []string{ShellAuto, ShellSh, ShellBash, ShellPwsh, ShellPowerShell, ShellCmd},
+		func(p *Preferences) *string { return &p.ShellBackend }, ParseShell).
+		withGet(Preferences.Shell).formerly("tools.shell"),
+	boolPref("tools.prefetch", "tools", "read files the model mentions while it is still responding, so a file_read that follows is served from memory", func(p *Preferences) *bool { return &p.ToolsPrefetch }),
+	stringPref("tools.parallelism", "tools", "how many independent tool calls from one turn run at once", "positive number; empty runs 4, 1 runs them in order", func(p *Preferences) *string { return &p.ToolsParallelism }).
+		validated(validateParallelism),
+	secretPref("brave.api_key", "tools", "Brave Search API key for web_search", "BRAVE_SEARCH_API_KEY", func(p *Preferences) *string { return &p.BraveAPIKey }),
+	secretPref("textbelt.api_key", "tools", "Textbelt API key for the SMS tools", "", func(p *Preferences) *string { return &p.TextbeltAPIKey }),
+	secretPref("textbelt.accounts", "tools", "named Textbelt accounts", "", func(p *Preferences) *string { return &p.TextbeltAccounts }).
+		withGet(func(p Preferences) string { return MaskAccounts(p.TextbeltAccounts) }).
+		withHint("name=key,name=key").
+		validated(func(v string) error { _, err := ParseAccounts(v); return err }),
+	listPref("scheduler.allowed_tools", "tools", "tools scheduled jobs may run", func(p *Preferences) *string { return &p.SchedulerAllowedTools }),
+	listPref("scheduler.draft_tools", "tools", "scheduled tools queued as drafts for /drafts approval", func(p *Preferences) *string { return &p.SchedulerDraftTools }),
+	stringPref("scheduler.max_attempts", "tools", "times a failing scheduled job runs before it is marked failed", "positive number; empty runs it once", func(p *Preferences) *string { return &p.SchedulerMaxAttempts }).
+		validated(validateMaxAttempts),
+	stringPref("scheduler.retry_backoff", "tools", "delay before retrying a failed scheduled job, doubling with each retry", "duration, e.g. 30s; empty waits 1m", func(p *Preferences) *string { return &p.SchedulerRetryBackoff }).
+		validated(validateRetryBackoff),
+	enumPref("scheduler.misfire", "tools", "what to do with jobs that came due while the daemon was down", []string{MisfireRun, MisfireSkip},
+		func(p *Preferences) *string { return &p.SchedulerMisfire }, ParseMisfirePolicy).
+		withGet(Preferences.MisfirePolicy),
+	enumPref("egress.mode", "tools", "record or restrict outbound hosts",
+		[]string{string(egress.ModeOff), string(egress.ModeLog), string(egress.ModeAlert), string(egress.ModeAllowlist)},
+		func(p *Preferences) *string { return &p.EgressMode },
+		func(v string) (string, error) { m, err := egress.ParseMode(v); return string(m), err }),
+	stringPref("egress.allowlist", "tools", "hosts allowed in alert and allowlist modes", "comma-separated hosts, * wildcards allowed", func(p *Preferences) *string { return &p.EgressAllowlist }).
+		withType(KeyTypeList),
+	boolPref("compliance.mode", "tools", "remove external messaging integrations", func(p *Preferences) *bool { return &p.ComplianceMode }).
+		withGet(func(p Preferences) string { return strconv.FormatBool(ComplianceEnabled(p)) }).
+		validated(func(v string) error {
+			b, err := ParseBoolish(v)
+			if err != nil {
+				return err
+			}
+			if !b && complianceBuild {
+				return fmt.Errorf("compliance mode is enforced by this build and cannot be disabled")
+			}
+			return nil
+		}),
+	boolPref("diagrams.render", "tools", "render mermaid and graphviz blocks to SVG", func(p *Preferences) *bool { return &p.DiagramsRender }),
+	stringPref("diagrams.kroki_url", "tools", "Kroki server used when no local renderer is installed", "http(s)://host[:port]", func(p *Preferences) *string { return &p.DiagramsKrokiURL }).
+		withSet(func(p *Preferences, v string) error {
+			if err := validateKrokiURL(v); err != nil {
+				return err
+			}
+			p.DiagramsKrokiURL = strings.TrimRight(v, "/")
+			return nil
+		}),
+	enumPref("guardrail.tui", "tools", "screen replies shown in the TUI", guardrailModes,
+		func(p *Preferences) *string { return &p.GuardrailTUI }, parseGuardrailMode),
+	enumPref("guardrail.outbound", "tools", "screen outbound messages", guardrailModes,
+		func(p *Preferences) *string { return &p.GuardrailOutbound }, parseGuardrailMode),
+	stringPref("guardrail.banned_terms", "tools", "extra terms the guardrails look for", "comma-separated terms", func(p *Preferences) *string { return &p.GuardrailBannedTerms }).
+		withType(KeyTypeList),
+	enumPref("pii.outbound", "tools", "handle personal data in outbound messages",
+		[]string{string(guardrail.PIIOff), string(guardrail.PIIMask), string(guardrail.PIIConfirm)},
+		func(p *Preferences) *string { return &p.PIIOutbound },
+		func(v string) (string, error) { m, err := guardrail.ParsePIIMode(v); return string(m), err }),
+	stringPref("pii.patterns", "tools", "extra personal data patterns", "regular expressions separated by ;", func(p *Preferences) *string { return &p.PIIPatterns }).
+		validated(func(v string) error { _, err := guardrail.ParsePatterns(v); return err }),
+
+	stringPref("daemon.bind_address", "daemon", "address the daemon listens on", "host or IP, e.g. 127.0.0.1 or 0.0.0.0", func(p *Preferences) *string { return &p.DaemonBindAddress }),
+	secretPref("daemon.auth_token", "daemon", "bearer token remote clients use", "", func(p *Preferences) *string { return &p.DaemonAuthToken }).
+		withHint("any string"),
+	stringPref("daemon.socket_path", "daemon", "unix socket to listen on instead of TCP", "file path", func(p *Preferences) *string { return &p.DaemonSocketPath }),
+	stringPref("daemon.port_range", "daemon", "ports the daemon may fall back to", "port or low-high, e.g. 4096-4196", func(p *Preferences) *string { return &p.DaemonPortRange }).
+		validated(func(v string) error { _, _, err := ParsePortRange(v); return err }),
+	boolPref("daemon.per_project", "daemon", "run one daemon per project", func(p *Preferences) *bool { return &p.DaemonPerProject }),
+	boolPref("daemon.project_db", "daemon", "keep each project's sessions in .muxd/muxd.db inside the project", func(p *Preferences) *bool { return &p.DaemonProjectDB }),
+	stringPref("push.apns_key", "daemon", "APNs auth key for notifying the iOS app", "path to the .p8 key file", func(p *Preferences) *string { return &p.PushAPNsKey }),
+	stringPref("push.apns_key_id", "daemon", "key ID of the APNs auth key", "10-character key ID", func(p *Preferences) *string { return &p.PushAPNsKeyID }),
+	stringPref("push.apns_team_id", "daemon", "Apple developer team ID that owns the APNs key", "10-character team ID", func(p *Preferences) *string { return &p.PushAPNsTeamID }),
+	stringPref("push.apns_topic", "daemon", "bundle ID of the iOS app", "bundle ID, e.g. com.example.muxd", func(p *Preferences) *string { return &p.PushAPNsTopic }),
+	boolPref("push.apns_sandbox", "daemon", "send APNs notifications through the development environment", func(p *Preferences) *bool { return &p.PushAPNsSandbox }),
+	stringPref("push.fcm_credentials", "daemon", "Firebase service account for notifying the Android app", "path to the service account JSON file", func(p *Preferences) *string { return &p.PushFCMCredentials }),
+	sinksPref("notify.on_turn_done", "daemon", "where to notify when a turn finishes", func(p *Preferences) *string { return &p.NotifyOnTurnDone }),
+	sinksPref("notify.on_job_done", "daemon", "where to notify when a background or scheduled job succeeds", func(p *Preferences) *string { return &p.NotifyOnJobDone }),
+	sinksPref("notify.on_job_failed", "daemon", "where to notify when a background or scheduled job fails", func(p *Preferences) *string { return &p.NotifyOnJobFailed }),
+	sinksPref("notify.on_budget", "daemon", "where to notify when a spending budget is nearly used up", func(p *Preferences) *string { return &p.NotifyOnBudget }),
+	stringPref("notify.webhook_url", "daemon", "URL the webhook notification sink POSTs JSON to", "http(s) URL", func(p *Preferences) *string { return &p.NotifyWebhookURL }).
+		validated(validateWebhookURL),
+	secretPref("notify.telegram_token", "daemon", "Telegram bot token for the telegram notification sink", "", func(p *Preferences) *string { return &p.NotifyTelegramToken }).
+		withHint("bot token from @BotFather"),
+	stringPref("notify.telegram_chat_id", "daemon", "Telegram chat the bot messages", "numeric chat ID", func(p *Preferences) *string { return &p.NotifyTelegramChatID }),
+	secretPref("slack.app_token", "daemon", "Slack app-level token the Slack adapter connects with over Socket Mode", "", func(p *Preferences) *string { return &p.SlackAppToken }).
+		withHint("xapp-... token with connections:write"),
+	secretPref("slack.bot_token", "daemon", "Slack bot token the Slack adapter reads and posts messages with", "", func(p *Preferences) *string { return &p.SlackBotToken }).
+		withHint("xoxb-... token"),
+	listPref("slack.allowed_users", "daemon", "Slack user IDs the Slack adapter takes messages, commands, and answers from", func(p *Preferences) *string { return &p.SlackAllowedUsers }).
+		withHint("comma-separated member IDs, e.g. U0123ABCD"),
+	listPref("slack.channels", "daemon", "Slack channels the adapter answers in; empty answers wherever the bot is invited", func(p *Preferences) *string { return &p.SlackChannels }).
+		withHint("comma-separated channel IDs, e.g. C0123ABCD"),
+	boolPref("slack.autostart", "daemon", "start the Slack adapter with the daemon", func(p *Preferences) *bool { return &p.SlackAutostart }),
+	secretPref("discord.token", "daemon", "Discord bot token the Discord adapter connects with", "", func(p *Preferences) *string { return &p.DiscordToken }).
+		withHint("bot token from the Discord developer portal"),
+	listPref("discord.allowed_users", "daemon", "Discord user IDs the Discord adapter takes messages and commands from", func(p *Preferences) *string { return &p.DiscordAllowedUsers }).
+		withHint("comma-separated user IDs, e.g. 80351110224678912"),
+	listPref("discord.channels", "daemon", "Discord channels the adapter answers every message in; elsewhere it answers when mentioned or in DMs", func(p *Preferences) *string { return &p.DiscordChannels }).
+		withHint("comma-separated channel IDs"),
+	listPref("discord.allowed_paths", "daemon", "directories a Discord chat may switch into with /cd, along with everything under them", func(p *Preferences) *string { return &p.DiscordAllowedPaths }).
+		withHint("comma-separated absolute paths, e.g. /home/me/src"),
+	boolPref("discord.autostart", "daemon", "start the Discord adapter with the daemon", func(p *Preferences) *bool { return &p.DiscordAutostart }),
+	stringPref("checkpoint.retention_days", "daemon", "days to keep checkpoint refs and file snapshots before muxd gc removes them", "positive number; empty keeps 30", func(p *Preferences) *string { return &p.CheckpointRetentionDays }).
+		validated(validateRetentionDays),
+
+	stringPref("hub.bind_address", "hub", "address the hub listens on", "host or IP", func(p *Preferences) *string { return &p.HubBindAddress }),
+	secretPref("hub.auth_token", "hub", "bearer token nodes and clients use with the hub", "", func(p *Preferences) *string { return &p.HubAuthToken }).
+		withHint("any string"),
+	stringPref("hub.public_url", "hub", "address people reach the hub at, for sign-in redirects", "http(s)://host[:port]; empty uses the address of the request", func(p *Preferences) *string { return &p.HubPublicURL }).
+		validated(validateHubURLs),
+	stringPref("hub.oidc.issuer", "hub", "OIDC identity provider people sign in to the hub with; empty turns sign-in off", "issuer URL, e.g. https://accounts.google.com", func(p *Preferences) *string { return &p.HubOIDCIssuer }).
+		validated(validateHubURLs),
+	stringPref("hub.oidc.client_id", "hub", "client ID of the hub at the identity provider", "client ID", func(p *Preferences) *string { return &p.HubOIDCClientID }),
+	secretPref("hub.oidc.client_secret", "hub", "client secret of the hub at the identity provider", "", func(p *Preferences) *string { return &p.HubOIDCClientSecret }).
+		withHint("client secret; empty for a public client"),
+	listPref("hub.oidc.roles", "hub", "who may sign in to the hub, and with which role", func(p *Preferences) *string { return &p.HubOIDCRoles }).
+		withHint("comma-separated identity=read|submit|admin; identity is an email, @domain, group:<name>, subject, or *").
+		validated(func(v string) error { _, err := ParseOIDCRoles(v); return err }),
+	stringPref("hub.oidc.groups_claim", "hub", "ID token claim that lists a person's groups", "claim name; empty uses groups", func(p *Preferences) *string { return &p.HubOIDCGroupsClaim }),
+	stringPref("hub.fallback_url", "hub", "hub muxd --remote switches to when the one it was given stops answering", "host:port or http(s)://host[:port]", func(p *Preferences) *string { return &p.HubFallbackURL }),
+
+	stringPref("hub.url", "node", "hub this daemon registers with", "http(s)://host[:port]", func(p *Preferences) *string { return &p.HubURL }),
+	listPref("hub.urls", "node", "more hubs this daemon registers with, so it stays reachable while one is down", func(p *Preferences) *string { return &p.HubURLs }).
+		withHint("comma-separated http(s)://host[:port]").validated(validateHubURLs),
+	secretPref("hub.node_token", "node", "token this node uses with the hub", "", func(p *Preferences) *string { return &p.HubNodeToken }).
+		withHint("the hub's auth token"),
+	stringPref("hub.node_name", "node", "name this node registers under", "any string; defaults to the hostname", func(p *Preferences) *string { return &p.HubNodeName }),
+
+	stringPref("backup.dir", "backup", "directory scheduled backups are written to", "directory path", func(p *Preferences) *string { return &p.BackupDir }),
+	stringPref("backup.interval", "backup", "how often the daemon takes a backup; empty disables", "duration, e.g. 24h", func(p *Preferences) *string { return &p.BackupInterval }).
+		validated(validateBackupInterval),
+	stringPref("backup.keep", "backup", "number of scheduled backups to keep", "positive number; empty keeps 7", func(p *Preferences) *string { return &p.BackupKeep }).
+		validated(validateBackupKeep),
+
+	stringPref("storage.s3_url", "storage", "S3-compatible bucket for scheduled backups, exports, and large tool results", "https://host/bucket[/prefix]", func(p *Preferences) *string { return &p.StorageS3URL }).
+		validated(validateStorageS3URL).formerly("backup.s3_url"),
+	stringPref("storage.s3_region", "storage", "region used to sign S3 requests", "region name; empty uses us-east-1", func(p *Preferences) *string { return &p.StorageS3Region }).
+		formerly("backup.s3_region"),
+	secretPref("storage.s3_access_key", "storage", "access key ID for the storage bucket", "AWS_ACCESS_KEY_ID", func(p *Preferences) *string { return &p.StorageS3AccessKey }).
+		formerly("backup.s3_access_key"),
+	secretPref("storage.s3_secret_key", "storage", "secret access key for the storage bucket", "AWS_SECRET_ACCESS_KEY", func(p *Preferences) *string { return &p.StorageS3SecretKey }).
+		formerly("backup.s3_secret_key"),
+	boolPref("storage.exports", "storage", "also upload session exports and published sites to storage", func(p *Preferences) *bool { return &p.StorageExports }),
+	stringPref("storage.blob_min_kb", "storage", "offload tool results larger than this to storage, keeping a preview in the database", "size in KB, e.g. 64; empty keeps them in the database", func(p *Preferences) *string { return &p.StorageBlobMinKB }).
+		validated(validateBlobMinKB),
+
+	boolPref("footer.tokens", "theme", "show token counts in the footer", func(p *Preferences) *bool { return &p.FooterTokens }),
+	boolPref("footer.cost", "theme", "show session cost in the footer", func(p *Preferences) *bool { return &p.FooterCost }),
+	boolPref("footer.context", "theme", "show the context window gauge in the footer", func(p *Preferences) *bool { return &p.FooterContext }),
+	boolPref("footer.cwd", "theme", "show the working directory in the footer", func(p *Preferences) *bool { return &p.FooterCwd }),
+	boolPref("footer.session", "theme", "show the session ID in the footer", func(p *Preferences) *bool { return &p.FooterSession }),
+	boolPref("footer.keybindings", "theme", "show keybinding hints in the footer", func(p *Preferences) *bool { return &p.FooterKeybindings }),
+	stringPref("footer.emoji", "theme", "emoji shown in the footer", "emoji or preset name, or none", func(p *Preferences) *string { return &p.FooterEmoji }).
+		withSet(func(p *Preferences, v string) error { p.FooterEmoji = ResolveEmoji(v); return nil }),
+	boolPref("viewport", "theme", "keep the transcript in a scrollable, searchable full-screen pager (restart to apply)", func(p *Preferences) *bool { return &p.Viewport }),
+	enumPref("clipboard", "theme", "how Ctrl+Y and Ctrl+K copy to the clipboard",
+		[]string{ClipboardAuto, ClipboardNative, ClipboardXclip, ClipboardWlCopy, ClipboardOSC52},
+		func(p *Preferences) *string { return &p.Clipboard }, ParseClipboard).
+		withGet(Preferences.ClipboardBackend),
+	enumPref("input.keymap", "theme", "key bindings for editing the prompt",
+		[]string{KeymapEmacs, KeymapVim},
+		func(p *Preferences) *string { return &p.InputKeymap }, ParseKeymap).
+		withGet(Preferences.Keymap),
+	{
+		key: "show_diffs", group: "theme", json: "hide_diffs",
+		doc: KeyDoc{Type: KeyTypeBool, Description: "show diffs for file edits in the transcript", Hint: boolHint, Values: boolValues},
+		get: func(p *Preferences) string { return strconv.FormatBool(!p.HideDiffs) },
+		set: func(p *Preferences, v string) error {
+			b, err := ParseBoolish(v)
+			if err != nil {
+				return err
+			}
+			p.HideDiffs = !b
+			return nil
+		},
+	},
+}
+
+const boolHint = "true/false, on/off, yes/no"
+
+var (
+	boolValues     = []string{"true", "false"}
+	guardrailModes = []string{string(guardrail.ModeOff), string(guardrail.ModeFlag), string(guardrail.ModeBlock)}
+)
+
+// ---------------------------------------------------------------------------
+// Field builders
+// ---------------------------------------------------------------------------
+
+func stringPref(key, group, description, hint string, field func(*Preferences) *string) prefField {
+	return prefField{
+		key:   key,
+		group: group,
+		doc:   KeyDoc{Type: KeyTypeString, Description: description, Hint: hint},
+		get:   func(p *Preferences) string { return *field(p) },
+		set:   func(p *Preferences, v string) error { *field(p) = v; return nil },
+		str:   field,
+	}
+}
+
+func listPref(key, group, description string, field func(*Preferences) *string) prefField {
+	return stringPref(key, group, description, "comma-separated tool names", field).withType(KeyTypeList)
+}
+
+// sinksPref is a list of notification sinks.
+func sinksPref(key, group, description string, field func(*Preferences) *string) prefField {
+	f := stringPref(key, group, description, "comma-separated sinks: "+strings.Join(notify.SinkNames, ", "), field).withType(KeyTypeList)
+	f.set = func(p *Preferences, v string) error {
+		sinks, err := notify.ParseSinks(v)
+		if err != nil {
+			return err
+		}
+		*field(p) = strings.Join(sinks, ",")
+		return nil
+	}
+	return f
+}
+
+// secretPref masks the value everywhere it is shown. With envVar set, an
+// unset key falls back to that environment variable.
+func secretPref(key, group, description, envVar string, field func(*Preferences) *string) prefField {
+	f := stringPref(key, group, description, "API key", field).withType(KeyTypeSecret)
+	f.get = func(p *Preferences) string { return MaskKey(*field(p)) }
+	if envVar != "" {
+		f.doc.Hint = "API key; empty uses $" + envVar
+		f.display = func(p *Preferences) string { return resolveKeyDisplay(*field(p), envVar) }
+	}
+	return f
+}
+
+func boolPref(key, group, description string, field func(*Preferences) *bool) prefField {
+	return prefField{
+		key:   key,
+		group: group,
+		doc:   KeyDoc{Type: KeyTypeBool, Description: description, Hint: boolHint, Values: boolValues},
+		get:   func(p *Preferences) string { return strconv.FormatBool(*field(p)) },
+		set: func(p *Preferences, v string) error {
+			b, err := ParseBoolish(v)
+			if err != nil {
+				return err
+			}
+			*field(p) = b
+			return nil
+		},
+	}
+}
+
+// enumPref stores the value parse normalizes it to.
+func enumPref(key, group, description string, values []string, field func(*Preferences) *string, parse func(string) (string, error)) prefField {
+	f := stringPref(key, group, description, joinOr(values), field).withType(KeyTypeEnum)
+	f.doc.Values = values
+	f.set = func(p *Preferences, v string) error {
+		s, err := parse(v)
+		if err != nil {
+			return err
+		}
+		*field(p) = s
+		return nil
+	}
+	return f
+}
+
+func (f prefField) withType(t string) prefField { f.doc.Type = t; return f }
+
+func (f prefField) withHint(h string) prefField { f.doc.Hint = h; return f }
+
+func (f prefField) withSet(set func(*Preferences, string) error) prefField { f.set = set; return f }
+
+// withGet replaces how the value is read, for both Get and listings.
+func (f prefField) withGet(get func(Preferences) string) prefField {
+	f.get = func(p *Preferences) string { return get(*p) }
+	f.display = nil
+	return f
+}
+
+// formerly records old names of the key, which Get and Set still accept.
+func (f prefField) formerly(old ...string) prefField {
+	f.deprecated = append(f.deprecated, old...)
+	return f
+}
+
+// validated runs check before storing a non-empty value.
+func (f prefField) validated(check func(string) error) prefField {
+	set := f.set
+	f.set = func(p *Preferences, v string) error {
+		if v != "" {
+			if err := check(v); err != nil {
+				return err
+			}
+		}
+		return set(p, v)
+	}
+	return f
+}
+
+func (f prefField) jsonName() string {
+	if f.json != "" {
+		return f.json
+	}
+	return jsonFieldName(f.key)
+}
+
+func jsonFieldName(key string) string { return strings.ReplaceAll(key, ".", "_") }
+
+func parseGuardrailMode(v string) (string, error) {
+	m, err := guardrail.ParseMode(v)
+	return string(m), err
+}
+
+func validateKrokiURL(v string) error {
+	if v == "" {
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid kroki URL %q (want http(s)://host)", v)
+	}
+	return nil
+}
+
+func validateWebhookURL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook URL %q (want http(s)://host/path)", v)
+	}
+	return nil
+}
+
+func validateAzureEndpoint(v string) error {
+	if v == "" {
+		return nil
+	}
+	u, err := url.Parse(v)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid endpoint %q: expected https://<resource>.openai.azure.com", v)
+	}
+	return nil
+}
+
+func validateBudget(v string) error {
+	if parseBudget(v) == 0 {
+		return fmt.Errorf("invalid budget %q (want a positive dollar amount, e.g. 5 or 2.50)", v)
+	}
+	return nil
+}
+
+func validateRetentionDays(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid retention %q (want a positive number of days)", v)
+	}
+	return nil
+}
+
+func validateBackupInterval(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < time.Minute {
+		return fmt.Errorf("invalid backup interval %q (want a duration of at least 1m, e.g. 24h)", v)
+	}
+	return nil
+}
+
+func validateParallelism(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid parallelism %q (want a positive number)", v)
+	}
+	return nil
+}
+
+func validateMaxAttempts(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid max attempts %q (want a positive number)", v)
+	}
+	return nil
+}
+
+func validateRetryBackoff(v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil || d < time.Second {
+		return fmt.Errorf("invalid retry backoff %q (want a duration of at least 1s, e.g. 30s)", v)
+	}
+	return nil
+}
+
+func validateBackupKeep(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid backup keep %q (want a positive number)", v)
+	}
+	return nil
+}
+
+func validateBlobMinKB(v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return fmt.Errorf("invalid blob size %q (want a positive number of KB)", v)
+	}
+	return nil
+}
+
+func validateHubURLs(v string) error {
+	for _, h := range splitIDs(v) {
+		u, err := url.Parse(h)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid hub URL %q (want http(s)://host[:port])", h)
+		}
+	}
+	return nil
+}
+
+func validateStorageS3URL(v string) error {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return fmt.Errorf("invalid S3 URL %q (want https://host/bucket[/prefix])", v)
+	}
+	return nil
+}
+
+// joinOr renders values as "a, b, or c".
+func joinOr(values []string) string {
+	switch len(values) {
+	case 0:
+		return ""
+	case 1:
+		return values[0]
+	case 2:
+		return values[0] + " or " + values[1]
+	}
+	return strings.Join(values[:len(values)-1], ", ") + ", or " + values[len(values)-1]
+}
+
+// ---------------------------------------------------------------------------
+// Lookups
+// ---------------------------------------------------------------------------
+
+// lookupPref finds the field for key or one of its deprecated names.
+func lookupPref(key string) (*prefField, bool) {
+	for i := range prefSchema {
+		f := &prefSchema[i]
+		if f.key == key {
+			return f, true
+		}
+		for _, old := range f.deprecated {
+			if old == key {
+				return f, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// CanonicalKey returns the current name of key, which differs from key when
+// key is deprecated. Unknown keys are returned unchanged.
+func CanonicalKey(key string) string {
+	if f, ok := lookupPref(key); ok {
+		return f.key
+	}
+	return key
+}
+
+// DescribeKey returns the documentation for a config key, or a zero KeyDoc
+// for unknown keys.
+func DescribeKey(key string) KeyDoc {
+	if f, ok := lookupPref(key); ok {
+		return f.doc
+	}
+	if _, field, ok := ParseCustomProviderKey(key); ok {
+		return customProviderDocs[field]
+	}
+	return KeyDoc{}
+}
+
+// DefaultValue returns the display value a key has in DefaultPreferences.
+func DefaultValue(key string) string {
+	return DefaultPreferences().Get(key)
+}
+
+func buildConfigGroupDefs() []ConfigGroupDef {
+	defs := make([]ConfigGroupDef, len(configGroupOrder))
+	for i, name := range configGroupOrder {
+		defs[i].Name = name
+		for _, f := range prefSchema {
+			if f.group == name {
+				defs[i].Keys = append(defs[i].Keys, f.key)
+			}
+		}
+	}
+	return defs
+}
+
+// migrateConfigJSON renames config.json fields of deprecated keys to their
+// current names. When both are present the current field wins. It reports
+// whether anything was renamed.
+func migrateConfigJSON(data []byte) ([]byte, bool) {
+	var raw map[string]json.RawMessage
+	if json.Unmarshal(data, &raw) != nil {
+		return data, false
+	}
+	changed := false
+	for _, f := range prefSchema {
+		for _, old := range f.deprecated {
+			oldName := jsonFieldName(old)
+			v, ok := raw[oldName]
+			if !ok {
+				continue
+			}
+			if _, exists := raw[f.jsonName()]; !exists {
+				raw[f.jsonName()] = v
+			}
+			delete(raw, oldName)
+			changed = true
+		}
+	}
+	if !changed {
+		return data, false
+	}
+	out, err := json.Marshal(raw)
+	if err != nil {
+		return data, false
+	}
+	return out, true
+}
+
+// ---------------------------------------------------------------------------
+// Docs
+// ---------------------------------------------------------------------------
+
+// ConfigReference renders every config key as a Markdown reference, grouped
+// like /config show. docs/config.md is generated from it.
+func ConfigReference() string {
+	var b strings.Builder
+	b.WriteString("# Configuration reference\n\n")
+	b.WriteString("<!-- Generated from internal/config/schema.go. Do not edit; run\n")
+	b.WriteString("     MUXD_UPDATE_DOCS=1 go test ./internal/config -run TestConfigReference -->\n\n")
+	b.WriteString("Set keys with `/config set <key> <value>` or in the `/config` picker. ")
+	b.WriteString("Values are stored in `~/.config/muxd/config.json`.\n")
+	defaults := DefaultPreferences()
+	for _, def := range ConfigGroupDefs {
+		fmt.Fprintf(&b, "\n## %s\n\n", strings.ToUpper(def.Name[:1])+def.Name[1:])
+		b.WriteString("| Key | Type | Default | Description | Accepts |\n")
+		b.WriteString("|-----|------|---------|-------------|---------|\n")
+		for _, key := range def.Keys {
+			f, _ := lookupPref(key)
+			dflt := "-"
+			if v := f.get(&defaults); v != "" {
+				dflt = "`" + v + "`"
+			}
+			desc := f.doc.Description
+			if len(f.deprecated) > 0 {
+				desc += " (formerly `" + strings.Join(f.deprecated, "`, `") + "`)"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s | %s | %s |\n", f.key, f.doc.Type, dflt, desc, strings.ReplaceAll(f.doc.Hint, "|", "\\|"))
+		}
+	}
+
+	b.WriteString("\n## Custom providers\n\n")
+	b.WriteString("Any OpenAI-compatible API (vLLM, LM Studio, Together, an internal gateway) can be ")
+	b.WriteString("added under a name of your choice and used as `<name>/<model>`. ")
+	b.WriteString("Set `base_url` first; clearing it removes the provider.\n\n")
+	b.WriteString("| Key | Type | Description | Accepts |\n")
+	b.WriteString("|-----|------|-------------|---------|\n")
+	for _, field := range customProviderFields {
+		doc := customProviderDocs[field]
+		fmt.Fprintf(&b, "| `%s<name>.%s` | %s | %s | %s |\n", customProviderPrefix, field, doc.Type, doc.Description, doc.Hint)
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrefSchema_jsonNamesMatchStruct(t *testing.T) {
+	tags := map[string]bool{}
+	typ := reflect.TypeOf(Preferences{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		tags[name] = true
+	}
+	seen := map[string]bool{}
+	for _, f := range prefSchema {
+		if seen[f.key] {
+			t.Errorf("duplicate key %s", f.key)
+		}
+		seen[f.key] = true
+		if !tags[f.jsonName()] {
+			t.Errorf("key %s maps to json field %q, which Preferences does not have", f.key, f.jsonName())
+		}
+		if f.doc.Description == "" || f.doc.Type == "" {
+			t.Errorf("key %s is missing a description or type", f.key)
+		}
+		if !slices.Contains(configGroupOrder, f.group) {
+			t.Errorf("key %s has unknown group %q", f.key, f.group)
+		}
+	}
+}
+
+func TestPrefSchema_enumValuesAreAccepted(t *testing.T) {
+	for _, key := range ValidConfigKeys() {
+		for _, v := range DescribeKey(key).Values {
+			p := DefaultPreferences()
+			if err := p.Set(key, v); err != nil {
+				t.Errorf("Set(%s, %s): %v", key, v, err)
+			}
+		}
+	}
+}
+
+// withDeprecatedKey temporarily gives hub.node_name the old name node.name.
+func withDeprecatedKey(t *testing.T) {
+	t.Helper()
+	orig := prefSchema
+	prefSchema = slices.Clone(orig)
+	t.Cleanup(func() { prefSchema = orig })
+	for i := range prefSchema {
+		if prefSchema[i].key == "hub.node_name" {
+			prefSchema[i].deprecated = []string{"node.name"}
+		}
+	}
+}
+
+func TestPrefSchema_deprecatedKeys(t *testing.T) {
+	withDeprecatedKey(t)
+
+	p := DefaultPreferences()
+	if err := p.Set("node.name", "box"); err != nil {
+		t.Fatalf("Set via deprecated key: %v", err)
+	}
+	if p.HubNodeName != "box" || p.Get("node.name") != "box" {
+		t.Errorf("HubNodeName = %q, Get = %q", p.HubNodeName, p.Get("node.name"))
+	}
+	if got := CanonicalKey("node.name"); got != "hub.node_name" {
+		t.Errorf("CanonicalKey = %q", got)
+	}
+	if got := CanonicalKey("nope"); got != "nope" {
+		t.Errorf("CanonicalKey(unknown) = %q", got)
+	}
+}
+
+func TestMigrateConfigJSON(t *testing.T) {
+	withDeprecatedKey(t)
+
+	data, migrated := migrateConfigJSON([]byte(`{"node_name":"old","model":"m"}`))
+	if !migrated {
+		t.Fatal("expected migration")
+	}
+	got := string(data)
+	if !strings.Contains(got, `"hub_node_name":"old"`) || strings.Contains(got, `"node_name"`) {
+		t.Errorf("migrated = %s", got)
+	}
+
+	// The current field wins over the deprecated one.
+	data, _ = migrateConfigJSON([]byte(`{"node_name":"old","hub_node_name":"new"}`))
+	if !strings.Contains(string(data), `"hub_node_name":"new"`) || strings.Contains(string(data), `"old"`) {
+		t.Errorf("migrated = %s", data)
+	}
+
+	if _, migrated := migrateConfigJSON([]byte(`{"model":"m"}`)); migrated {
+		t.Error("nothing to migrate should report false")
+	}
+}
+
+func TestLoadPreferences_migratesDeprecatedKeys(t *testing.T) {
+	withDeprecatedKey(t)
+	configDirOverride = t.TempDir()
+	t.Cleanup(func() { configDirOverride = "" })
+	path := filepath.Join(configDirOverride, "config.json")
+	if err := os.WriteFile(path, []byte(`{"node_name":"box"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if p := LoadPreferences(); p.HubNodeName != "box" {
+		t.Errorf("HubNodeName = %q", p.HubNodeName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"hub_node_name": "box"`) || strings.Contains(string(data), `"node_name"`) {
+		t.Errorf("config.json was not migrated:\n%s", data)
+	}
+}
+
+func TestConfigReference(t *testing.T) {
+	path := filepath.Join("..", "..", "docs", "config.md")
+	want := ConfigReference()
+	if os.Getenv("MUXD_UPDATE_DOCS") != "" {
+		if err := os.WriteFile(path, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Error("docs/config.md is out of date; run MUXD_UPDATE_DOCS=1 go test ./internal/config -run TestConfigReference")
+	}
+}
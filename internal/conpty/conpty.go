@@ -0,0 +1,63 @@
+package conpty
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrUnsupported is returned by Run where no pseudo console is available:
+// on Unix, and on Windows before 10 1809.
+var ErrUnsupported = errors.New("pseudo console not supported")
+
+// Size is the pseudo console's size in character cells.
+type Size struct {
+	Cols int
+	Rows int
+}
+
+// Default size used when a Size field is zero.
+const (
+	defaultCols = 120
+	defaultRows = 40
+)
+
+// Sanitize prepares pseudo console output for printing into scrollback. It
+// keeps SGR color sequences, drops cursor movement, screen clearing, window
+// titles and other control sequences the console emits, and turns CRLF
+// line endings into LF.
+func Sanitize(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == 0x1b && i+1 < len(s) && s[i+1] == '[':
+			// CSI: parameters and intermediates, then a final byte in @-~.
+			j := i + 2
+			for j < len(s) && (s[j] < 0x40 || s[j] > 0x7e) {
+				j++
+			}
+			if j < len(s) && s[j] == 'm' {
+				b.WriteString(s[i : j+1])
+			}
+			i = j
+		case c == 0x1b && i+1 < len(s) && s[i+1] == ']':
+			// OSC: ends with BEL or ESC \.
+			j := i + 2
+			for j < len(s) && s[j] != 0x07 && !(s[j] == 0x1b && j+1 < len(s) && s[j+1] == '\\') {
+				j++
+			}
+			if j < len(s) && s[j] == 0x1b {
+				j++
+			}
+			i = j
+		case c == 0x1b:
+			i++ // two-byte escape such as ESC 7
+		case c == '\r':
+			// CRLF becomes LF; a lone CR would overwrite the line.
+		case c < 0x20 && c != '\n' && c != '\t':
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,25 @@
+package conpty
+
+import "testing"
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"plain", "hello\r\nworld\r\n", "hello\nworld\n"},
+		{"keeps color", "\x1b[31mred\x1b[0m", "\x1b[31mred\x1b[0m"},
+		{"drops cursor movement", "\x1b[?25l\x1b[2J\x1b[Hok\x1b[K", "ok"},
+		{"drops title", "\x1b]0;C:\\Windows\\cmd.exe\x07out", "out"},
+		{"drops title with ST", "\x1b]0;title\x1b\\out", "out"},
+		{"drops two-byte escape", "\x1b7a\x1b8b", "ab"},
+		{"drops control chars", "a\x08b\tc", "ab\tc"},
+		{"truncated CSI", "x\x1b[3", "x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sanitize(tt.in); got != tt.want {
+				t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
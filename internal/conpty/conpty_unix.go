@@ -0,0 +1,16 @@
+//go:build !windows
+
+package conpty
+
+import (
+	"context"
+	"io"
+)
+
+// Available reports whether Run can use a pseudo console.
+func Available() bool { return false }
+
+// Run is only implemented on Windows.
+func Run(ctx context.Context, cmdLine, dir string, size Size, out io.Writer) (int, error) {
+	return -1, ErrUnsupported
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package conpty
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRun_unsupported(t *testing.T) {
+	if Available() {
+		t.Fatal("Available() = true on Unix")
+	}
+	if _, err := Run(context.Background(), "echo hi", "", Size{}, io.Discard); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("err = %v, want ErrUnsupported", err)
+	}
+}
@@ -0,0 +1,154 @@
+package conpty
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procCreatePseudoConsole = windows.NewLazySystemDLL("kernel32.dll").NewProc("CreatePseudoConsole")
+
+// Available reports whether Run can use a pseudo console (Windows 10 1809
+// and later).
+func Available() bool {
+	return procCreatePseudoConsole.Find() == nil
+}
+
+// Run starts cmdLine in a new pseudo console, so programs see a terminal
+// and keep their colors and line editing, and copies the console's output
+// to out until the process exits. cmdLine is passed to CreateProcess as is.
+// It returns the exit code. Canceling ctx kills the process tree.
+func Run(ctx context.Context, cmdLine, dir string, size Size, out io.Writer) (int, error) {
+	if !Available() {
+		return -1, ErrUnsupported
+	}
+	if size.Cols <= 0 {
+		size.Cols = defaultCols
+	}
+	if size.Rows <= 0 {
+		size.Rows = defaultRows
+	}
+
+	var ptyIn, inW, outR, ptyOut windows.Handle
+	if err := windows.CreatePipe(&ptyIn, &inW, nil, 0); err != nil {
+		return -1, fmt.Errorf("creating input pipe: %w", err)
+	}
+	if err := windows.CreatePipe(&outR, &ptyOut, nil, 0); err != nil {
+		windows.CloseHandle(ptyIn)
+		windows.CloseHandle(inW)
+		return -1, fmt.Errorf("creating output pipe: %w", err)
+	}
+	var console windows.Handle
+	err := windows.CreatePseudoConsole(windows.Coord{X: int16(size.Cols), Y: int16(size.Rows)}, ptyIn, ptyOut, 0, &console)
+	// The console holds its own copies of its ends of the pipes.
+	windows.CloseHandle(ptyIn)
+	windows.CloseHandle(ptyOut)
+	if err != nil {
+		windows.CloseHandle(inW)
+		windows.CloseHandle(outR)
+		return -1, fmt.Errorf("creating pseudo console: %w", err)
+	}
+	consoleOpen := true
+	closeConsole := func() {
+		if consoleOpen {
+			windows.ClosePseudoConsole(console)
+			consoleOpen = false
+		}
+	}
+	defer closeConsole()
+
+	// Drain output while the process runs; the console blocks when its
+	// output pipe is full.
+	outFile := os.NewFile(uintptr(outR), "conpty-output")
+	copied := make(chan struct{})
+	go func() {
+		defer close(copied)
+		_, _ = io.Copy(out, outFile) // ends when the console closes
+		outFile.Close()
+	}()
+
+	pi, err := startProcess(console, cmdLine, dir)
+	// Commands get no input: close it so reads see end of file.
+	windows.CloseHandle(inW)
+	if err != nil {
+		closeConsole()
+		<-copied
+		return -1, err
+	}
+	defer windows.CloseHandle(pi.Thread)
+	defer windows.CloseHandle(pi.Process)
+
+	exited := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			killTree(pi)
+		case <-exited:
+		}
+	}()
+	_, waitErr := windows.WaitForSingleObject(pi.Process, windows.INFINITE)
+	close(exited)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(pi.Process, &code); err != nil && waitErr == nil {
+		waitErr = err
+	}
+	// Closing the console flushes what is left of its output and ends the
+	// copy.
+	closeConsole()
+	<-copied
+	if waitErr != nil {
+		return -1, fmt.Errorf("waiting for process: %w", waitErr)
+	}
+	if ctx.Err() != nil {
+		return int(code), ctx.Err()
+	}
+	return int(code), nil
+}
+
+// startProcess creates the process attached to console.
+func startProcess(console windows.Handle, cmdLine, dir string) (*windows.ProcessInformation, error) {
+	attrs, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		return nil, fmt.Errorf("creating attribute list: %w", err)
+	}
+	defer attrs.Delete()
+	// The attribute value is the console handle itself, not a pointer to it.
+	if err := attrs.Update(windows.PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE, *(*unsafe.Pointer)(unsafe.Pointer(&console)), unsafe.Sizeof(console)); err != nil {
+		return nil, fmt.Errorf("attaching pseudo console: %w", err)
+	}
+	si := &windows.StartupInfoEx{ProcThreadAttributeList: attrs.List()}
+	si.Cb = uint32(unsafe.Sizeof(*si))
+
+	line, err := windows.UTF16PtrFromString(cmdLine)
+	if err != nil {
+		return nil, fmt.Errorf("command line: %w", err)
+	}
+	var dirPtr *uint16
+	if dir != "" {
+		if dirPtr, err = windows.UTF16PtrFromString(dir); err != nil {
+			return nil, fmt.Errorf("directory: %w", err)
+		}
+	}
+	pi := new(windows.ProcessInformation)
+	flags := uint32(windows.EXTENDED_STARTUPINFO_PRESENT | windows.CREATE_UNICODE_ENVIRONMENT)
+	if err := windows.CreateProcess(nil, line, nil, nil, false, flags, nil, dirPtr, &si.StartupInfo, pi); err != nil {
+		return nil, fmt.Errorf("starting %s: %w", cmdLine, err)
+	}
+	return pi, nil
+}
+
+// killTree ends the process and its children, as the bash tool does on
+// cancellation.
+func killTree(pi *windows.ProcessInformation) {
+	kill := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(int(pi.ProcessId)))
+	if kill.Run() != nil {
+		_ = windows.TerminateProcess(pi.Process, 1)
+	}
+}
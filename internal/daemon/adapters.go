@@ -0,0 +1,252 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+// Adapter connects a chat service, such as Slack, to the daemon. Run
+// blocks until ctx is cancelled or the adapter fails for good.
+type Adapter interface {
+	Run(ctx context.Context) error
+}
+
+// AdapterFactory builds an adapter that drives the daemon through client,
+// configured from the current preferences.
+type AdapterFactory func(client *DaemonClient, prefs config.Preferences) (Adapter, error)
+
+// AdapterStatus reports whether an adapter is running.
+type AdapterStatus struct {
+	Name      string     `json:"name"`
+	Running   bool       `json:"running"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	// Error is why the adapter last stopped on its own, if it did.
+	Error string `json:"error,omitempty"`
+}
+
+// adapterStopTimeout bounds how long Shutdown and stop requests wait for an
+// adapter to return.
+const adapterStopTimeout = 5 * time.Second
+
+type adapterRun struct {
+	cancel    context.CancelFunc
+	done      chan struct{}
+	startedAt time.Time
+	err       error // set when Run returns
+}
+
+var (
+	errUnknownAdapter  = errors.New("unknown adapter")
+	errAdapterRunning  = errors.New("adapter is already running")
+	errAdapterStopped  = errors.New("adapter is not running")
+	errAdapterNotReady = errors.New("adapter did not stop in time")
+)
+
+// SetAdapterFactory registers the adapter started by POST
+// /api/adapters/{name}/start.
+func (s *Server) SetAdapterFactory(name string, f AdapterFactory) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.adapterFactories == nil {
+		s.adapterFactories = map[string]AdapterFactory{}
+	}
+	s.adapterFactories[name] = f
+}
+
+// StartAdapter builds and runs the named adapter in the background. It
+// blocks until the server is listening, since the adapter talks to it over
+// HTTP like any other client.
+func (s *Server) StartAdapter(name string) (AdapterStatus, error) {
+	s.mu.Lock()
+	f, ok := s.adapterFactories[name]
+	if !ok {
+		s.mu.Unlock()
+		return AdapterStatus{}, errUnknownAdapter
+	}
+	if run := s.adapters[name]; run != nil && !isClosed(run.done) {
+		s.mu.Unlock()
+		return AdapterStatus{}, errAdapterRunning
+	}
+	var prefs config.Preferences
+	if s.prefs != nil {
+		prefs = *s.prefs
+	}
+	s.mu.Unlock()
+
+	a, err := f(s.selfClient(), prefs)
+	if err != nil {
+		return AdapterStatus{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &adapterRun{cancel: cancel, done: make(chan struct{}), startedAt: time.Now().UTC()}
+
+	s.mu.Lock()
+	if old := s.adapters[name]; old != nil && !isClosed(old.done) {
+		s.mu.Unlock()
+		cancel()
+		return AdapterStatus{}, errAdapterRunning
+	}
+	if s.adapters == nil {
+		s.adapters = map[string]*adapterRun{}
+	}
+	s.adapters[name] = run
+	s.mu.Unlock()
+
+	go func() {
+		err := a.Run(ctx)
+		s.mu.Lock()
+		if ctx.Err() == nil && err != nil {
+			run.err = err
+		}
+		s.mu.Unlock()
+		if run.err != nil {
+			s.logf("adapter %s stopped: %v", name, run.err)
+		}
+		cancel()
+		close(run.done)
+	}()
+	s.logf("adapter %s started", name)
+	return s.adapterStatus(name), nil
+}
+
+// StopAdapter stops the named adapter and waits for it to return.
+func (s *Server) StopAdapter(name string) (AdapterStatus, error) {
+	s.mu.Lock()
+	_, ok := s.adapterFactories[name]
+	run := s.adapters[name]
+	s.mu.Unlock()
+	if !ok {
+		return AdapterStatus{}, errUnknownAdapter
+	}
+	if run == nil || isClosed(run.done) {
+		return AdapterStatus{}, errAdapterStopped
+	}
+	run.cancel()
+	select {
+	case <-run.done:
+	case <-time.After(adapterStopTimeout):
+		return AdapterStatus{}, errAdapterNotReady
+	}
+	s.logf("adapter %s stopped", name)
+	return s.adapterStatus(name), nil
+}
+
+// AdapterStatuses reports every registered adapter, sorted by name.
+func (s *Server) AdapterStatuses() []AdapterStatus {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.adapterFactories))
+	for name := range s.adapterFactories {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+	out := make([]AdapterStatus, 0, len(names))
+	for _, name := range names {
+		out = append(out, s.adapterStatus(name))
+	}
+	return out
+}
+
+func (s *Server) adapterStatus(name string) AdapterStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := AdapterStatus{Name: name}
+	run := s.adapters[name]
+	if run == nil {
+		return st
+	}
+	if run.err != nil {
+		st.Error = run.err.Error()
+	}
+	if !isClosed(run.done) {
+		st.Running = true
+		started := run.startedAt
+		st.StartedAt = &started
+	}
+	return st
+}
+
+// stopAdapters cancels every running adapter on shutdown.
+func (s *Server) stopAdapters() {
+	s.mu.Lock()
+	runs := make([]*adapterRun, 0, len(s.adapters))
+	for _, run := range s.adapters {
+		runs = append(runs, run)
+	}
+	s.mu.Unlock()
+	deadline := time.After(adapterStopTimeout)
+	for _, run := range runs {
+		run.cancel()
+		select {
+		case <-run.done:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// selfClient returns a client for this server, for adapters that run
+// in-process but use the same API as remote clients.
+func (s *Server) selfClient() *DaemonClient {
+	var c *DaemonClient
+	if s.socketPath != "" {
+		c = NewDaemonSocketClient(s.socketPath)
+	} else {
+		c = NewDaemonClient(s.Port())
+	}
+	c.SetAuthToken(s.AuthToken())
+	return c
+}
+
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+func (s *Server) handleListAdapters(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"adapters": s.AdapterStatuses()})
+}
+
+func (s *Server) handleStartAdapter(w http.ResponseWriter, r *http.Request) {
+	st, err := s.StartAdapter(r.PathValue("name"))
+	if err != nil {
+		writeJSON(w, adapterErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func (s *Server) handleStopAdapter(w http.ResponseWriter, r *http.Request) {
+	st, err := s.StopAdapter(r.PathValue("name"))
+	if err != nil {
+		writeJSON(w, adapterErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+func adapterErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errUnknownAdapter):
+		return http.StatusNotFound
+	case errors.Is(err, errAdapterRunning), errors.Is(err, errAdapterStopped):
+		return http.StatusConflict
+	case errors.Is(err, errAdapterNotReady):
+		return http.StatusGatewayTimeout
+	}
+	// The factory rejected the configuration, e.g. a missing token.
+	return http.StatusBadRequest
+}
@@ -0,0 +1,96 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+// fakeAdapter runs until cancelled, or fails when fail is closed.
+type fakeAdapter struct {
+	fail chan struct{}
+}
+
+func (a *fakeAdapter) Run(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-a.fail:
+		return errors.New("connection refused")
+	}
+}
+
+func TestAdapters(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.port = 4096
+	close(srv.ready)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	do := func(method, path string) (*httptest.ResponseRecorder, AdapterStatus) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, method, path, nil))
+		var st AdapterStatus
+		_ = json.Unmarshal(w.Body.Bytes(), &st)
+		return w, st
+	}
+
+	var gotClient *DaemonClient
+	adapter := &fakeAdapter{fail: make(chan struct{})}
+	srv.SetAdapterFactory("chat", func(c *DaemonClient, p config.Preferences) (Adapter, error) {
+		if p.SlackBotToken == "" {
+			return nil, errors.New("missing token")
+		}
+		gotClient = c
+		return adapter, nil
+	})
+
+	if w, _ := do("POST", "/api/adapters/nope/start"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown adapter: expected 404, got %d", w.Code)
+	}
+	if w, _ := do("POST", "/api/adapters/chat/start"); w.Code != http.StatusBadRequest {
+		t.Errorf("factory error: expected 400, got %d", w.Code)
+	}
+	if w, _ := do("POST", "/api/adapters/chat/stop"); w.Code != http.StatusConflict {
+		t.Errorf("stop when stopped: expected 409, got %d", w.Code)
+	}
+
+	srv.prefs.SlackBotToken = "xoxb-test"
+	w, st := do("POST", "/api/adapters/chat/start")
+	if w.Code != http.StatusOK || !st.Running || st.StartedAt == nil {
+		t.Fatalf("start: %d %+v", w.Code, st)
+	}
+	if gotClient == nil || gotClient.AuthToken() != srv.AuthToken() || gotClient.Port() != 4096 {
+		t.Errorf("adapter client not pointed at this server: %+v", gotClient)
+	}
+	if w, _ := do("POST", "/api/adapters/chat/start"); w.Code != http.StatusConflict {
+		t.Errorf("double start: expected 409, got %d", w.Code)
+	}
+	if w, st := do("POST", "/api/adapters/chat/stop"); w.Code != http.StatusOK || st.Running || st.Error != "" {
+		t.Errorf("stop: %d %+v", w.Code, st)
+	}
+
+	// An adapter that fails on its own reports why.
+	if _, st := do("POST", "/api/adapters/chat/start"); !st.Running {
+		t.Fatalf("restart: %+v", st)
+	}
+	close(adapter.fail)
+	srv.mu.Lock()
+	done := srv.adapters["chat"].done
+	srv.mu.Unlock()
+	<-done
+	statuses := srv.AdapterStatuses()
+	if len(statuses) != 1 || statuses[0].Running || statuses[0].Error != "connection refused" {
+		t.Errorf("statuses = %+v", statuses)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/adapters", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("list: %d", w.Code)
+	}
+}
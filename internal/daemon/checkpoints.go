@@ -0,0 +1,207 @@
+package daemon
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/batalabs/muxd/internal/checkpoint"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// ---------------------------------------------------------------------------
+// Checkpoints
+// ---------------------------------------------------------------------------
+
+// Checkpoints are taken by the agent before each step's tools run and kept
+// in the store, so undo and redo work from any client: they restore the
+// working tree on the daemon, where the files are.
+
+func (s *Server) handleListCheckpoints(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	if _, ok := s.checkpointSession(w, sessionID); !ok {
+		return
+	}
+	cps, err := s.sessionStore(sessionID).ListCheckpoints(sessionID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if cps == nil {
+		cps = []store.Checkpoint{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"checkpoints": cps})
+}
+
+// handleCreateCheckpoint snapshots the session's working tree now, with an
+// optional label.
+func (s *Server) handleCreateCheckpoint(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Label string `json:"label"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+	}
+	sessionID := r.PathValue("id")
+	sess, ok := s.checkpointSession(w, sessionID)
+	if !ok {
+		return
+	}
+	dir := sess.Cwd
+	if dir == "" {
+		dir, _ = tools.Getwd()
+	}
+	if _, err := checkpoint.GitRunIn(dir, "rev-parse", "--show-toplevel"); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "checkpoints on request need a git repository"})
+		return
+	}
+
+	s.checkpointMu.Lock()
+	defer s.checkpointMu.Unlock()
+	name := "manual-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	snap, err := checkpoint.Create(dir, sessionID, name)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	cp := store.Checkpoint{SessionID: sessionID, Label: req.Label, Dir: dir, SHA: snap.SHA}
+	if cp.ID, err = s.sessionStore(sessionID).AddCheckpoint(cp); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	cp.CreatedAt = time.Now().UTC()
+	writeJSON(w, http.StatusOK, cp)
+}
+
+// handleUndo returns the working tree to the newest checkpoint not yet
+// undone.
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	s.restoreCheckpoint(w, r.PathValue("id"), true)
+}
+
+// handleRedo reapplies the oldest undone checkpoint's undo.
+func (s *Server) handleRedo(w http.ResponseWriter, r *http.Request) {
+	s.restoreCheckpoint(w, r.PathValue("id"), false)
+}
+
+func (s *Server) restoreCheckpoint(w http.ResponseWriter, sessionID string, undo bool) {
+	if _, ok := s.checkpointSession(w, sessionID); !ok {
+		return
+	}
+	s.mu.Lock()
+	ag := s.agents[sessionID]
+	s.mu.Unlock()
+	if ag != nil && ag.IsRunning() {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "cannot undo or redo while a turn is running"})
+		return
+	}
+
+	s.checkpointMu.Lock()
+	defer s.checkpointMu.Unlock()
+	cps, err := s.sessionStore(sessionID).ListCheckpoints(sessionID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	i := store.FirstUndone(cps)
+	if undo {
+		if i == 0 {
+			writeJSON(w, http.StatusConflict, map[string]string{"error": "nothing to undo"})
+			return
+		}
+		cp := cps[i-1]
+		redoSHA, err := checkpoint.Undo(cp.Dir, sessionID, strconv.FormatInt(cp.ID, 10), snapshotOf(cp))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if err := s.sessionStore(sessionID).SetCheckpointUndone(cp.ID, true, redoSHA); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		cp.Undone, cp.RedoSHA = true, redoSHA
+		s.logf("session %s: undid checkpoint %d", sessionID, cp.ID)
+		writeJSON(w, http.StatusOK, cp)
+		return
+	}
+
+	if i == len(cps) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "nothing to redo"})
+		return
+	}
+	cp := cps[i]
+	if err := checkpoint.Redo(cp.Dir, cp.RedoSHA); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.sessionStore(sessionID).SetCheckpointUndone(cp.ID, false, ""); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	cp.Undone, cp.RedoSHA = false, ""
+	s.logf("session %s: redid checkpoint %d", sessionID, cp.ID)
+	writeJSON(w, http.StatusOK, cp)
+}
+
+// handleCheckpointDiff returns a unified diff of what restoring a
+// checkpoint would do to the working tree, without changing it.
+func (s *Server) handleCheckpointDiff(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	if _, ok := s.checkpointSession(w, sessionID); !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("n"), 10, 64)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid checkpoint id"})
+		return
+	}
+	cps, err := s.sessionStore(sessionID).ListCheckpoints(sessionID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	idx := slices.IndexFunc(cps, func(cp store.Checkpoint) bool { return cp.ID == id })
+	if idx < 0 {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "checkpoint not found"})
+		return
+	}
+	cp := cps[idx]
+
+	s.checkpointMu.Lock()
+	diff, err := checkpoint.Diff(cp.Dir, snapshotOf(cp))
+	s.checkpointMu.Unlock()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"checkpoint": cp, "diff": diff})
+}
+
+// snapshotOf returns the git snapshot a stored checkpoint refers to.
+func snapshotOf(cp store.Checkpoint) checkpoint.Checkpoint {
+	return checkpoint.Checkpoint{TurnNumber: cp.Turn, SHA: cp.SHA, IsClean: cp.SHA == ""}
+}
+
+// checkpointSession loads the session, writing a 404 when it does not
+// exist.
+func (s *Server) checkpointSession(w http.ResponseWriter, sessionID string) (*domain.Session, bool) {
+	sess, err := s.sessionStore(sessionID).GetSession(sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return nil, false
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	return sess, true
+}
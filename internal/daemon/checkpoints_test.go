@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func TestCheckpointEndpoints(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	file := filepath.Join(repo, "a.txt")
+	os.WriteFile(file, []byte("base"), 0o644)
+	for _, args := range [][]string{
+		{"init"},
+		{"config", "user.email", "test@test.com"},
+		{"config", "user.name", "Test"},
+		{"add", "a.txt"},
+		{"commit", "-m", "initial"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %s: %v", args, out, err)
+		}
+	}
+	read := func() string {
+		data, _ := os.ReadFile(file)
+		return string(data)
+	}
+
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	sess, _ := st.CreateSession(repo, "model")
+	if err := st.SetSessionCwd(sess.ID, repo); err != nil {
+		t.Fatal(err)
+	}
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := newAuthedRequest(srv, method, "/api/sessions/"+sess.ID+path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/undo", ""); w.Code != http.StatusConflict {
+		t.Errorf("undo with no checkpoints: expected 409, got %d", w.Code)
+	}
+
+	os.WriteFile(file, []byte("one"), 0o644)
+	w := do("POST", "/checkpoints", `{"label":"before two"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: %d %s", w.Code, w.Body.String())
+	}
+	var cp store.Checkpoint
+	if err := json.Unmarshal(w.Body.Bytes(), &cp); err != nil || cp.SHA == "" || cp.Label != "before two" || cp.Dir != repo {
+		t.Fatalf("created = %+v (%v)", cp, err)
+	}
+
+	os.WriteFile(file, []byte("two"), 0o644)
+	w = do("GET", fmt.Sprintf("/checkpoints/%d/diff", cp.ID), "")
+	var preview struct {
+		Diff string `json:"diff"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &preview); err != nil || !strings.Contains(preview.Diff, "-two") || !strings.Contains(preview.Diff, "+one") {
+		t.Errorf("diff = %d %s", w.Code, w.Body.String())
+	}
+	if got := read(); got != "two" {
+		t.Errorf("diff changed a.txt to %q", got)
+	}
+	if w := do("GET", "/checkpoints/999/diff", ""); w.Code != http.StatusNotFound {
+		t.Errorf("diff of unknown checkpoint: expected 404, got %d", w.Code)
+	}
+
+	if w := do("POST", "/undo", ""); w.Code != http.StatusOK {
+		t.Fatalf("undo: %d %s", w.Code, w.Body.String())
+	}
+	if got := read(); got != "one" {
+		t.Errorf("after undo a.txt = %q, want one", got)
+	}
+	if w := do("POST", "/undo", ""); w.Code != http.StatusConflict {
+		t.Errorf("second undo: expected 409, got %d", w.Code)
+	}
+
+	w = do("GET", "/checkpoints", "")
+	var list struct {
+		Checkpoints []store.Checkpoint `json:"checkpoints"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil || len(list.Checkpoints) != 1 || !list.Checkpoints[0].Undone {
+		t.Fatalf("list = %s (%v)", w.Body.String(), err)
+	}
+
+	if w := do("POST", "/redo", ""); w.Code != http.StatusOK {
+		t.Fatalf("redo: %d %s", w.Code, w.Body.String())
+	}
+	if got := read(); got != "two" {
+		t.Errorf("after redo a.txt = %q, want two", got)
+	}
+	if w := do("POST", "/redo", ""); w.Code != http.StatusConflict {
+		t.Errorf("second redo: expected 409, got %d", w.Code)
+	}
+
+	req := newAuthedRequest(srv, "GET", "/api/sessions/missing/checkpoints", nil)
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", rec.Code)
+	}
+}
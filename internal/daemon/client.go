@@ -3,28 +3,47 @@ package daemon
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"golang.org/x/net/websocket"
+
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/hostinfo"
+	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/notify"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/scaffold"
+	"github.com/batalabs/muxd/internal/store"
 )
 
 const (
 	clientTimeout      = 10 * time.Second
 	healthCheckTimeout = 2 * time.Second
 	pollInterval       = 50 * time.Millisecond
+
+	// unixSocketBaseURL is the placeholder base URL used for unix socket
+	// connections. The host is ignored by the socket dialer.
+	unixSocketBaseURL = "http://muxd"
 )
 
 // SSEEvent represents a parsed server-sent event from the daemon.
 type SSEEvent struct {
-	Type                     string // "delta", "tool_start", "tool_done", "stream_done", "ask_user", "turn_done", "error", "compacted", "titled", "retrying"
+	Type                     string // "delta", "tool_start", "tool_done", "stream_done", "ask_user", "approval_required", "turn_done", "error", "compacted", "titled", "retrying", "diagram", "budget_warning", "subagent", "verified", "steered"
+	ID                       int    // number of the event within its turn, when the stream sends ids
 	DeltaText                string
 	ToolUseID                string
 	ToolName                 string
@@ -35,16 +54,92 @@ type SSEEvent struct {
 	OutputTokens             int
 	CacheCreationInputTokens int
 	CacheReadInputTokens     int
+	ContextTokens            int // "stream_done": prompt size of the call
+	ContextWindow            int // "stream_done": the model's context window, 0 when unknown
+	CompactAt                int // "stream_done": prompt size at which history is summarized
 	StopReason               string
 	AskID                    string
 	AskPrompt                string
+	AskOptions               []string
+	ApprovalID               string
 	ErrorMsg                 string
+	ErrorCode                domain.ErrorCode // "error", and "tool_done" when the tool failed
 	Title                    string
 	Tags                     string
 	ModelUsed                string
 	RetryAttempt             int
 	RetryWaitMs              int
 	RetryMessage             string
+	DiagramKind              string
+	DiagramPath              string
+	Budget                   *BudgetInfo   // "budget_warning", and "error" when a budget stopped the turn
+	SubAgent                 *SubAgentInfo // "subagent"
+	Verify                   *VerifyInfo   // "verified"
+	Steering                 []string      // "steered": notes added to the turn
+}
+
+// BudgetInfo is the spend against a budget reported by the daemon.
+type BudgetInfo struct {
+	Scope    string  // "session" or "daily"
+	Key      string  // preference that sets the budget
+	SpentUSD float64 // estimated spend so far
+	LimitUSD float64
+	Message  string
+}
+
+// SessionContext is the response of GET /api/sessions/{id}/context: what
+// the session's next model call would send. Token counts are estimates.
+type SessionContext struct {
+	Provider        string           `json:"provider"`
+	Model           string           `json:"model"`
+	System          string           `json:"system"`
+	SystemTokens    int              `json:"system_tokens"`
+	Summary         string           `json:"summary,omitempty"` // compaction summary, also the first message
+	Pinned          []ContextPin     `json:"pinned"`
+	Messages        []ContextMessage `json:"messages"`
+	Tools           []string         `json:"tools"`
+	ToolTokens      int              `json:"tool_tokens"`
+	EstimatedTokens int              `json:"estimated_tokens"`
+	LastInputTokens int              `json:"last_input_tokens"` // reported by the provider for the last call
+}
+
+// ContextPin is a project memory fact included in every system prompt.
+type ContextPin struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ContextMessage is one message of the window sent to the model. Image
+// data is left out.
+type ContextMessage struct {
+	Role    string                `json:"role"`
+	Content string                `json:"content,omitempty"`
+	Blocks  []domain.ContentBlock `json:"blocks,omitempty"`
+	Tokens  int                   `json:"tokens"`
+}
+
+// SubAgentInfo is the progress of one spawn_agent worker.
+type SubAgentInfo struct {
+	ID        string `json:"id"`        // the worker's session ID
+	ParentID  string `json:"parent_id"` // session that spawned it
+	Name      string `json:"name"`
+	State     string `json:"state"` // running, done, stopped or failed
+	Tool      string `json:"tool,omitempty"`
+	Turns     int    `json:"turns"`
+	Tokens    int    `json:"tokens"`
+	MaxTurns  int    `json:"max_turns"`
+	MaxTokens int    `json:"max_tokens"`
+	Error     string `json:"error,omitempty"`
+}
+
+// VerifyInfo is the verification pass's check of a final answer.
+type VerifyInfo struct {
+	Model        string `json:"model"`
+	OK           bool   `json:"ok"`
+	Critique     string `json:"critique,omitempty"`
+	InputTokens  int    `json:"input_tokens"`
+	OutputTokens int    `json:"output_tokens"`
+	Error        string `json:"error,omitempty"`
 }
 
 // DaemonClient is the HTTP client used by the TUI to communicate with the daemon server.
@@ -52,6 +147,11 @@ type DaemonClient struct {
 	baseURL    string
 	httpClient *http.Client
 	authToken  string
+	transport  http.RoundTripper // nil uses http.DefaultTransport
+	socketPath string
+
+	wsMu    sync.Mutex
+	sockets map[string]*websocket.Conn // session ID -> socket of the running turn
 }
 
 // NewDaemonClient creates a new client for the daemon at the given port.
@@ -62,6 +162,41 @@ func NewDaemonClient(port int) *DaemonClient {
 	}
 }
 
+// NewDaemonSocketClient creates a new client for a daemon listening on the
+// unix domain socket at path. Socket connections are authorized by
+// filesystem permissions, so no auth token is required.
+func NewDaemonSocketClient(path string) *DaemonClient {
+	tr := unixSocketTransport(path)
+	return &DaemonClient{
+		baseURL:    unixSocketBaseURL,
+		httpClient: &http.Client{Timeout: clientTimeout, Transport: tr},
+		transport:  tr,
+		socketPath: path,
+	}
+}
+
+// unixSocketTransport returns an HTTP transport that dials the given unix socket
+// regardless of the request host.
+func unixSocketTransport(path string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		},
+	}
+}
+
+// SocketPath returns the unix socket path, or "" for TCP clients.
+func (c *DaemonClient) SocketPath() string {
+	return c.socketPath
+}
+
+// newHTTPClient returns an HTTP client with the given timeout that shares
+// this client's transport (TCP or unix socket). A zero timeout means none.
+func (c *DaemonClient) newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: c.transport}
+}
+
 // SetAuthToken sets the daemon bearer token used on protected endpoints.
 func (c *DaemonClient) SetAuthToken(token string) {
 	c.authToken = strings.TrimSpace(token)
@@ -99,6 +234,7 @@ type HealthInfo struct {
 	Model    string
 	Provider string
 	Mode     string // "hub" when connected to a hub, empty for direct daemon
+	OIDC     bool   // the hub lets people sign in through OIDC
 }
 
 // Health checks if the daemon is responding.
@@ -109,7 +245,7 @@ func (c *DaemonClient) Health() error {
 
 // HealthCheck returns detailed health info from the daemon.
 func (c *DaemonClient) HealthCheck() (*HealthInfo, error) {
-	client := &http.Client{Timeout: healthCheckTimeout}
+	client := c.newHTTPClient(healthCheckTimeout)
 	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/health", nil)
 	if err != nil {
 		return nil, fmt.Errorf("health check: %w", err)
@@ -145,15 +281,26 @@ func (c *DaemonClient) HealthCheck() (*HealthInfo, error) {
 	if v, ok := raw["mode"].(string); ok {
 		info.Mode = v
 	}
+	info.OIDC, _ = raw["oidc"].(bool)
 	return info, nil
 }
 
 // CreateSession creates a new session on the daemon.
 func (c *DaemonClient) CreateSession(projectPath, modelID string) (string, error) {
-	body, _ := json.Marshal(map[string]string{
+	return c.CreateSessionIn(projectPath, modelID, "")
+}
+
+// CreateSessionIn creates a new session whose tools run in cwd, an
+// absolute directory. An empty cwd uses the daemon's working directory.
+func (c *DaemonClient) CreateSessionIn(projectPath, modelID, cwd string) (string, error) {
+	fields := map[string]string{
 		"project_path": projectPath,
 		"model_id":     modelID,
-	})
+	}
+	if cwd != "" {
+		fields["cwd"] = cwd
+	}
+	body, _ := json.Marshal(fields)
 	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions", bytes.NewReader(body))
 	if err != nil {
 		return "", fmt.Errorf("creating request: %w", err)
@@ -219,6 +366,73 @@ func (c *DaemonClient) IsAgentRunning(sessionID string) bool {
 	return result.AgentRunning
 }
 
+// TurnStatus reports whether a session's agent is mid-turn and the number
+// of the turn's newest event.
+type TurnStatus struct {
+	Running   bool `json:"agent_running"`
+	LastEvent int  `json:"last_event"`
+}
+
+// GetTurnStatus returns the state of the session's current turn.
+func (c *DaemonClient) GetTurnStatus(sessionID string) (*TurnStatus, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/status", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var status TurnStatus
+	if err := c.doJSON(req, "getting turn status", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// FollowTurn reattaches to a turn that kept running in the daemon after its
+// client detached, delivering the events after the first after until the
+// turn ends.
+func (c *DaemonClient) FollowTurn(sessionID string, after int, onEvent func(SSEEvent)) error {
+	f := &turnFollower{onEvent: onEvent, last: after}
+	return c.resumeStream(sessionID, f, fmt.Errorf("daemon cannot resume turns; restart it to update"))
+}
+
+// GetSubAgents returns the spawn_agent workers started from a session, in
+// start order.
+func (c *DaemonClient) GetSubAgents(sessionID string) ([]SubAgentInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/agents", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting sub-agents: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var agents []SubAgentInfo
+	if err := json.NewDecoder(resp.Body).Decode(&agents); err != nil {
+		return nil, fmt.Errorf("parsing sub-agents: %w", err)
+	}
+	return agents, nil
+}
+
+// GetContext returns what the session's next model call would send.
+func (c *DaemonClient) GetContext(sessionID string) (*SessionContext, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/context", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting context: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var sc SessionContext
+	if err := json.NewDecoder(resp.Body).Decode(&sc); err != nil {
+		return nil, fmt.Errorf("parsing context: %w", err)
+	}
+	return &sc, nil
+}
+
 // ListSessions lists sessions for the given project path.
 func (c *DaemonClient) ListSessions(projectPath string, limit int) ([]domain.Session, error) {
 	url := fmt.Sprintf("%s/api/sessions?project=%s&limit=%d", c.baseURL, projectPath, limit)
@@ -258,29 +472,112 @@ func (c *DaemonClient) GetMessages(sessionID string) ([]domain.TranscriptMessage
 	return msgs, nil
 }
 
-// SubmitImage is an image attachment for the submit API.
+// GetMessagesPage retrieves up to limit messages starting at offset, along
+// with the session's total message count. A limit of 0 means no limit.
+func (c *DaemonClient) GetMessagesPage(sessionID string, offset, limit int) ([]domain.TranscriptMessage, int, error) {
+	url := fmt.Sprintf("%s/api/sessions/%s/messages?offset=%d&limit=%d", c.baseURL, sessionID, offset, limit)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting messages: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return nil, 0, fmt.Errorf("getting messages: %s", e.Error)
+	}
+
+	var msgs []domain.TranscriptMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msgs); err != nil {
+		return nil, 0, fmt.Errorf("parsing messages: %w", err)
+	}
+	total, _ := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	return msgs, total, nil
+}
+
+// GetMessagesAfter retrieves the messages after sequence after, along with
+// the last sequence, which the next call passes back to fetch only what is
+// new.
+func (c *DaemonClient) GetMessagesAfter(sessionID string, after int) ([]domain.TranscriptMessage, int, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/sessions/%s/messages?after=%d", c.baseURL, sessionID, after), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("getting messages: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return nil, 0, fmt.Errorf("getting messages: %s", e.Error)
+	}
+
+	var msgs []domain.TranscriptMessage
+	if err := json.NewDecoder(resp.Body).Decode(&msgs); err != nil {
+		return nil, 0, fmt.Errorf("parsing messages: %w", err)
+	}
+	last, err := strconv.Atoi(resp.Header.Get("X-Max-Sequence"))
+	if err != nil {
+		last = after + len(msgs)
+	}
+	return msgs, last, nil
+}
+
+// SubmitImage is an image for the submit API. Superseded by
+// SubmitAttachment, and still accepted from older clients.
 type SubmitImage struct {
 	Path      string `json:"path"`
 	MediaType string `json:"media_type"`
 	Data      string `json:"data"` // base64
 }
 
-// Submit sends a user message and streams SSE events back via the callback.
-// This call blocks until the turn is complete.
-func (c *DaemonClient) Submit(sessionID, text string, images []SubmitImage, onEvent func(SSEEvent)) error {
+// SubmitAttachment is a file sent with a message to the submit API. Images
+// (image/* media types) reach vision models as images; other files are
+// inlined as text.
+type SubmitAttachment struct {
+	Name      string `json:"name"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"` // base64
+}
+
+// Submit sends a user message and streams events back via the callback.
+// This call blocks until the turn is complete. It prefers the session
+// WebSocket and falls back to SSE when the socket cannot be opened, e.g.
+// against an older daemon.
+func (c *DaemonClient) Submit(sessionID, text string, attachments []SubmitAttachment, onEvent func(SSEEvent)) error {
+	err := c.submitWebSocket(sessionID, text, attachments, onEvent)
+	if !errors.Is(err, errWebSocketUnavailable) {
+		return err
+	}
+	return c.submitSSE(sessionID, text, attachments, onEvent)
+}
+
+func (c *DaemonClient) submitSSE(sessionID, text string, attachments []SubmitAttachment, onEvent func(SSEEvent)) error {
 	payload := map[string]any{"text": text}
-	if len(images) > 0 {
-		payload["images"] = images
+	if len(attachments) > 0 {
+		payload["attachments"] = attachments
 	}
 	body, _ := json.Marshal(payload)
-	req, err := http.NewRequest("POST", c.baseURL+"/api/sessions/"+sessionID+"/submit", bytes.NewReader(body))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/sessions/"+sessionID+"/submit", bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	// No timeout for long-running submit
-	client := &http.Client{}
+	client := c.newHTTPClient(0)
 	if c.authToken != "" {
 		req.Header.Set("Authorization", "Bearer "+c.authToken)
 	}
@@ -295,11 +592,224 @@ func (c *DaemonClient) Submit(sessionID, text string, images []SubmitImage, onEv
 		return fmt.Errorf("submit failed (HTTP %d): %s", resp.StatusCode, string(raw))
 	}
 
-	return ParseSSEStream(resp.Body, onEvent)
+	// A proxy that buffers the stream leaves it silent, heartbeats
+	// included. Drop it then and follow the turn by long polling.
+	f := &turnFollower{onEvent: onEvent}
+	stream := newStallReader(resp.Body, sseStallTimeout, cancel)
+	err = ParseSSEStream(stream, f.deliver)
+	if stream.stop() {
+		return c.pollEvents(sessionID, f.last, onEvent)
+	}
+	if f.ended {
+		return err
+	}
+	// The turn goes on in the daemon when the connection drops.
+	return c.resumeStream(sessionID, f, err)
+}
+
+// turnFollower passes a turn's events on and tracks how far it got.
+type turnFollower struct {
+	onEvent func(SSEEvent)
+	last    int  // number of the last event delivered
+	ended   bool // a turn_done or error event was delivered
+}
+
+func (f *turnFollower) deliver(evt SSEEvent) {
+	if evt.ID > 0 {
+		f.last = evt.ID
+	} else {
+		f.last++
+	}
+	if evt.Type == "turn_done" || evt.Type == "error" {
+		f.ended = true
+	}
+	f.onEvent(evt)
+}
+
+// resumeStream reconnects to a turn whose stream ended early and follows
+// it from the event after f.last, retrying a few times while it makes no
+// progress. cause is what ended the stream; it is returned as is when the
+// daemon is too old to resume.
+func (c *DaemonClient) resumeStream(sessionID string, f *turnFollower, cause error) error {
+	failures := 0
+	for {
+		before := f.last
+		stalled, err := c.followStream(sessionID, f)
+		if stalled {
+			return c.pollEvents(sessionID, f.last, f.onEvent)
+		}
+		if err == nil {
+			return nil
+		}
+		var status httpStatusError
+		if errors.As(err, &status) {
+			if status.code == http.StatusNotFound || status.code == http.StatusMethodNotAllowed {
+				return cause
+			}
+			return fmt.Errorf("resuming stream: %w", err)
+		}
+		if f.last > before {
+			failures = 0
+		}
+		if failures++; failures >= eventPollMaxFailures {
+			return fmt.Errorf("resuming stream: %w", err)
+		}
+		time.Sleep(eventPollRetryDelay)
+	}
+}
+
+// followStream reads GET /api/sessions/{id}/stream with Last-Event-ID set
+// to f.last, and reports whether the stream stalled.
+func (c *DaemonClient) followStream(sessionID string, f *turnFollower) (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/stream", nil)
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Last-Event-ID", strconv.Itoa(f.last))
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	resp, err := c.newHTTPClient(0).Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		return false, httpStatusError{code: resp.StatusCode, msg: errResp.Error}
+	}
+
+	stream := newStallReader(resp.Body, sseStallTimeout, cancel)
+	err = ParseSSEStream(stream, f.deliver)
+	return stream.stop(), err
+}
+
+// sseStallTimeout is how long a submit stream may send nothing before the
+// client falls back to long polling. The daemon sends a heartbeat every
+// sseHeartbeatInterval, so only a buffering proxy keeps it this quiet.
+var sseStallTimeout = 30 * time.Second
+
+// Long-poll fallback: the wait asked of the daemon, and how many failed
+// polls in a row end the turn.
+const (
+	eventPollWait        = 30 * time.Second
+	eventPollMaxFailures = 3
+)
+
+// eventPollRetryDelay is the pause after a failed poll.
+var eventPollRetryDelay = time.Second
+
+// stallReader cancels a stream that goes quiet for longer than timeout.
+type stallReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+func newStallReader(r io.Reader, timeout time.Duration, onStall func()) *stallReader {
+	s := &stallReader{r: r, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		s.stalled.Store(true)
+		onStall()
+	})
+	return s
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+// stop ends the watch and reports whether the stream stalled.
+func (s *stallReader) stop() bool {
+	s.timer.Stop()
+	return s.stalled.Load()
+}
+
+// pollEvents follows a turn through GET /api/sessions/{id}/events, starting
+// after the first after events, until the daemon reports it done.
+func (c *DaemonClient) pollEvents(sessionID string, after int, onEvent func(SSEEvent)) error {
+	client := c.newHTTPClient(eventPollWait + clientTimeout)
+	failures := 0
+	for {
+		batch, err := c.fetchEvents(client, sessionID, after)
+		if err != nil {
+			var status httpStatusError
+			if failures++; errors.As(err, &status) || failures >= eventPollMaxFailures {
+				return fmt.Errorf("polling events: %w", err)
+			}
+			time.Sleep(eventPollRetryDelay)
+			continue
+		}
+		failures = 0
+		for _, e := range batch.Events {
+			if evt := ParseSSEEvent(e.Event, string(e.Data)); evt.Type != "" {
+				onEvent(evt)
+			}
+		}
+		after = batch.Next
+		if batch.Done {
+			return nil
+		}
+	}
+}
+
+// httpStatusError is a non-200 reply, which retrying won't fix.
+type httpStatusError struct {
+	code int
+	msg  string
+}
+
+func (e httpStatusError) Error() string {
+	if e.msg != "" {
+		return e.msg
+	}
+	return fmt.Sprintf("HTTP %d", e.code)
+}
+
+// fetchEvents makes one long-poll request.
+func (c *DaemonClient) fetchEvents(client *http.Client, sessionID string, after int) (*EventBatch, error) {
+	q := url.Values{"after": {strconv.Itoa(after)}, "wait": {eventPollWait.String()}}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/events?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		return nil, httpStatusError{code: resp.StatusCode, msg: errResp.Error}
+	}
+	var batch EventBatch
+	if err := json.NewDecoder(resp.Body).Decode(&batch); err != nil {
+		return nil, fmt.Errorf("parsing events: %w", err)
+	}
+	return &batch, nil
 }
 
 // Cancel cancels the running agent loop for a session.
 func (c *DaemonClient) Cancel(sessionID string) error {
+	if c.sendOnSocket(sessionID, wsClientMessage{Type: "cancel"}) {
+		return nil
+	}
 	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/cancel", nil)
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
@@ -315,6 +825,9 @@ func (c *DaemonClient) Cancel(sessionID string) error {
 
 // SendAskResponse sends the user's answer to a pending ask_user question.
 func (c *DaemonClient) SendAskResponse(sessionID, askID, answer string) error {
+	if c.sendOnSocket(sessionID, wsClientMessage{Type: "ask_response", AskID: askID, Answer: answer}) {
+		return nil
+	}
 	body, _ := json.Marshal(map[string]string{
 		"ask_id": askID,
 		"answer": answer,
@@ -332,60 +845,497 @@ func (c *DaemonClient) SendAskResponse(sessionID, askID, answer string) error {
 	return nil
 }
 
-// SetModel changes the model for a session.
-func (c *DaemonClient) SetModel(sessionID, label, modelID string) error {
+// Stop ends the session's running turn gracefully, keeping its partial
+// reply and letting running tools finish. The turn ends with a turn_done
+// event whose stop reason is "cancelled".
+func (c *DaemonClient) Stop(sessionID string) error {
+	if c.sendOnSocket(sessionID, wsClientMessage{Type: "stop"}) {
+		return nil
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/stop", nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	return c.doJSON(req, "stopping", nil)
+}
+
+// Steer sends a note to the session's running turn; the agent sees it
+// before its next model call. It goes over HTTP even when the session has
+// a socket, so a turn that already ended is reported here.
+func (c *DaemonClient) Steer(sessionID, text string) error {
+	body, _ := json.Marshal(map[string]string{"text": text})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/steer", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.doJSON(req, "steering", nil)
+}
+
+// SendApproval answers a pending approval_required event with "allow",
+// "deny", or "always".
+func (c *DaemonClient) SendApproval(sessionID, approvalID, decision string) error {
+	if c.sendOnSocket(sessionID, wsClientMessage{Type: "approve", ApprovalID: approvalID, Decision: decision}) {
+		return nil
+	}
 	body, _ := json.Marshal(map[string]string{
-		"label":    label,
-		"model_id": modelID,
+		"approval_id": approvalID,
+		"decision":    decision,
 	})
-	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/model", bytes.NewReader(body))
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/approve", bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.do(req)
 	if err != nil {
-		return fmt.Errorf("setting model: %w", err)
+		return fmt.Errorf("sending approval: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("sending approval: %s", errResp.Error)
+		}
+		return fmt.Errorf("sending approval: HTTP %d", resp.StatusCode)
 	}
-	resp.Body.Close()
 	return nil
 }
 
-// RenameSession sets the session title via the daemon, which also marks the
-// agent as user-renamed to prevent auto-title from overwriting it.
-func (c *DaemonClient) RenameSession(sessionID, title string) error {
-	body, _ := json.Marshal(map[string]string{"title": title})
-	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/title", bytes.NewReader(body))
+// SetModel changes the model for a session.
+func (c *DaemonClient) SetModel(sessionID, label, modelID string) error {
+	body, _ := json.Marshal(map[string]string{
+		"label":    label,
+		"model_id": modelID,
+	})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/model", bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("creating request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.do(req)
 	if err != nil {
-		return fmt.Errorf("renaming session: %w", err)
+		return fmt.Errorf("setting model: %w", err)
 	}
 	resp.Body.Close()
 	return nil
 }
 
-// GetConfig retrieves the current preferences from the daemon.
-func (c *DaemonClient) GetConfig() (*config.Preferences, error) {
-	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/config", nil)
+// SessionStyle is the response language and tone preset for a session.
+type SessionStyle struct {
+	Language string `json:"language"`
+	Tone     string `json:"tone"`
+}
+
+// GetStyle returns the response style for a session.
+func (c *DaemonClient) GetStyle(sessionID string) (*SessionStyle, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/style", nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
-	resp, err := c.do(req)
+	return c.doStyle(req)
+}
+
+// SetStyle updates the response style for a session. Nil fields are left
+// unchanged; reset restores the configured defaults before applying them.
+func (c *DaemonClient) SetStyle(sessionID string, language, tone *string, reset bool) (*SessionStyle, error) {
+	body, _ := json.Marshal(map[string]any{
+		"language": language,
+		"tone":     tone,
+		"reset":    reset,
+	})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/style", bytes.NewReader(body))
 	if err != nil {
-		return nil, fmt.Errorf("getting config: %w", err)
+		return nil, fmt.Errorf("creating request: %w", err)
 	}
-	defer resp.Body.Close()
+	req.Header.Set("Content-Type", "application/json")
+	return c.doStyle(req)
+}
 
-	var prefs config.Preferences
-	if err := json.NewDecoder(resp.Body).Decode(&prefs); err != nil {
-		return nil, fmt.Errorf("parsing config: %w", err)
+func (c *DaemonClient) doStyle(req *http.Request) (*SessionStyle, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("session style: %w", err)
 	}
-	return &prefs, nil
-}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("session style: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("session style: HTTP %d", resp.StatusCode)
+	}
+
+	var style SessionStyle
+	if err := json.NewDecoder(resp.Body).Decode(&style); err != nil {
+		return nil, fmt.Errorf("parsing session style: %w", err)
+	}
+	return &style, nil
+}
+
+// SessionVerify is a session's verification pass setting. An empty Model
+// means the session's own model checks its answers.
+type SessionVerify struct {
+	Enabled bool   `json:"enabled"`
+	Model   string `json:"model"`
+}
+
+// GetVerify returns the verification pass setting for a session.
+func (c *DaemonClient) GetVerify(sessionID string) (*SessionVerify, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/verify", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var out SessionVerify
+	if err := c.doJSON(req, "session verify", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetVerify turns the verification pass on or off for a session. A
+// non-empty model overrides model.verify for the session.
+func (c *DaemonClient) SetVerify(sessionID string, enabled bool, model string) (*SessionVerify, error) {
+	body, _ := json.Marshal(map[string]any{"enabled": enabled, "model": model})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/verify", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var out SessionVerify
+	if err := c.doJSON(req, "session verify", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SessionSampling is the sampling parameters and output limits of a
+// session. Nil and zero fields use the provider default.
+type SessionSampling struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Seed        *int64   `json:"seed,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+
+	// Provider is the session's current provider. DefaultMaxTokens is the
+	// output cap muxd sends it when MaxTokens is unset (0 when the provider
+	// decides), and SupportsSeed whether it honours Seed.
+	Provider         string `json:"provider,omitempty"`
+	DefaultMaxTokens int    `json:"default_max_tokens,omitempty"`
+	SupportsSeed     bool   `json:"supports_seed"`
+}
+
+// GetSampling returns the sampling parameters of a session.
+func (c *DaemonClient) GetSampling(sessionID string) (*SessionSampling, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/sampling", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	return c.doSampling(req)
+}
+
+// SetSampling updates the sampling parameters of a session. values maps
+// temperature, top_p, seed, max_tokens, or stop to a new value; "default"
+// clears one. reset restores the configured defaults before applying them.
+func (c *DaemonClient) SetSampling(sessionID string, values map[string]string, reset bool) (*SessionSampling, error) {
+	fields := map[string]any{"reset": reset}
+	for k, v := range values {
+		fields[k] = v
+	}
+	body, _ := json.Marshal(fields)
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/sampling", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.doSampling(req)
+}
+
+func (c *DaemonClient) doSampling(req *http.Request) (*SessionSampling, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("session sampling: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("session sampling: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("session sampling: HTTP %d", resp.StatusCode)
+	}
+
+	var sampling SessionSampling
+	if err := json.NewDecoder(resp.Body).Decode(&sampling); err != nil {
+		return nil, fmt.Errorf("parsing session sampling: %w", err)
+	}
+	return &sampling, nil
+}
+
+// SetPlanMode turns user-controlled plan mode on or off for a session and
+// returns the resulting state.
+func (c *DaemonClient) SetPlanMode(sessionID string, enabled bool) (bool, error) {
+	body, _ := json.Marshal(map[string]bool{"enabled": enabled})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/plan", bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return false, fmt.Errorf("setting plan mode: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return false, fmt.Errorf("setting plan mode: %s", errResp.Error)
+		}
+		return false, fmt.Errorf("setting plan mode: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		PlanMode bool `json:"plan_mode"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("parsing plan mode: %w", err)
+	}
+	return result.PlanMode, nil
+}
+
+// ListCheckpoints returns a session's working tree checkpoints, oldest
+// first.
+func (c *DaemonClient) ListCheckpoints(sessionID string) ([]store.Checkpoint, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/checkpoints", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var out struct {
+		Checkpoints []store.Checkpoint `json:"checkpoints"`
+	}
+	if err := c.doJSON(req, "listing checkpoints", &out); err != nil {
+		return nil, err
+	}
+	return out.Checkpoints, nil
+}
+
+// CheckpointDiff returns a checkpoint and a unified diff of what restoring
+// it would change in the session's working tree.
+func (c *DaemonClient) CheckpointDiff(sessionID string, id int64) (*store.Checkpoint, string, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/api/sessions/%s/checkpoints/%d/diff", c.baseURL, sessionID, id), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("creating request: %w", err)
+	}
+	var out struct {
+		Checkpoint store.Checkpoint `json:"checkpoint"`
+		Diff       string           `json:"diff"`
+	}
+	if err := c.doJSON(req, "diffing checkpoint", &out); err != nil {
+		return nil, "", err
+	}
+	return &out.Checkpoint, out.Diff, nil
+}
+
+// CreateCheckpoint snapshots the session's working tree now.
+func (c *DaemonClient) CreateCheckpoint(sessionID, label string) (*store.Checkpoint, error) {
+	body, _ := json.Marshal(map[string]string{"label": label})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/checkpoints", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var out store.Checkpoint
+	if err := c.doJSON(req, "creating checkpoint", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Undo returns the session's working tree to its newest checkpoint not
+// yet undone, and returns that checkpoint.
+func (c *DaemonClient) Undo(sessionID string) (*store.Checkpoint, error) {
+	return c.restoreCheckpoint(sessionID, "undo")
+}
+
+// Redo reverses the session's last undo, and returns the checkpoint it
+// was for.
+func (c *DaemonClient) Redo(sessionID string) (*store.Checkpoint, error) {
+	return c.restoreCheckpoint(sessionID, "redo")
+}
+
+func (c *DaemonClient) restoreCheckpoint(sessionID, action string) (*store.Checkpoint, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/"+action, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var out store.Checkpoint
+	if err := c.doJSON(req, action, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ShareLink is a read-only live view of a session.
+type ShareLink struct {
+	Token string `json:"token"`
+	Path  string `json:"path"`
+	// URL is empty when the daemon listens on a unix socket.
+	URL string `json:"url"`
+	// LocalOnly is set when the daemon only listens on loopback, so the
+	// link works on this machine only.
+	LocalOnly bool `json:"local_only"`
+}
+
+// ShareSession returns a read-only live view link for a session, minting
+// one if it has none.
+func (c *DaemonClient) ShareSession(sessionID string) (*ShareLink, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/share", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sharing session: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("sharing session: %s", errResp.Error)
+		}
+		return nil, fmt.Errorf("sharing session: HTTP %d", resp.StatusCode)
+	}
+	var link ShareLink
+	if err := json.NewDecoder(resp.Body).Decode(&link); err != nil {
+		return nil, fmt.Errorf("parsing share response: %w", err)
+	}
+	return &link, nil
+}
+
+// UnshareSession revokes a session's share links and returns how many
+// there were.
+func (c *DaemonClient) UnshareSession(sessionID string) (int, error) {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/api/sessions/"+sessionID+"/share", nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("unsharing session: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("unsharing session: HTTP %d", resp.StatusCode)
+	}
+	var result struct {
+		Removed int `json:"removed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("parsing unshare response: %w", err)
+	}
+	return result.Removed, nil
+}
+
+// RenameSession sets the session title via the daemon, which also marks the
+// agent as user-renamed to prevent auto-title from overwriting it.
+func (c *DaemonClient) RenameSession(sessionID, title string) error {
+	body, _ := json.Marshal(map[string]string{"title": title})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/title", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.doJSON(req, "renaming session", nil)
+}
+
+// DeleteSession deletes a session and its messages on the daemon.
+func (c *DaemonClient) DeleteSession(sessionID string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/api/sessions/"+url.PathEscape(sessionID), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	return c.doJSON(req, "deleting session", nil)
+}
+
+// GetMemory returns the project memory facts of the daemon's project.
+func (c *DaemonClient) GetMemory() (map[string]string, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/memory", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var result struct {
+		Facts map[string]string `json:"facts"`
+	}
+	if err := c.doJSON(req, "reading memory", &result); err != nil {
+		return nil, err
+	}
+	if result.Facts == nil {
+		result.Facts = map[string]string{}
+	}
+	return result.Facts, nil
+}
+
+// SetMemory stores a project memory fact on the daemon.
+func (c *DaemonClient) SetMemory(key, value string) error {
+	body, _ := json.Marshal(map[string]string{"value": value})
+	req, err := http.NewRequest(http.MethodPut, c.baseURL+"/api/memory/"+url.PathEscape(key), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.doJSON(req, "saving memory", nil)
+}
+
+// DeleteMemory removes a project memory fact on the daemon.
+func (c *DaemonClient) DeleteMemory(key string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/api/memory/"+url.PathEscape(key), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	return c.doJSON(req, "removing memory", nil)
+}
+
+// InitProject writes the starting .muxd.toml, .mcp.json, and memory facts
+// for the daemon's project, keeping any that already exist.
+func (c *DaemonClient) InitProject() (*scaffold.Result, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/project/init", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var out scaffold.Result
+	if err := c.doJSON(req, "initializing project", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConfig retrieves the current preferences from the daemon.
+func (c *DaemonClient) GetConfig() (*config.Preferences, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/config", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var prefs config.Preferences
+	if err := c.doJSON(req, "getting config", &prefs); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
 
 // SetConfig updates a preference key on the daemon.
 func (c *DaemonClient) SetConfig(key, value string) (string, error) {
@@ -400,53 +1350,735 @@ func (c *DaemonClient) SetConfig(key, value string) (string, error) {
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := c.do(req)
 	if err != nil {
-		return "", fmt.Errorf("setting config: %w", err)
+		return "", fmt.Errorf("setting config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Error   string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("parsing response: %w", err)
+	}
+	if result.Error != "" {
+		return "", fmt.Errorf("%s", result.Error)
+	}
+	return result.Message, nil
+}
+
+// GetTools retrieves every tool the agent can call, with input schemas.
+func (c *DaemonClient) GetTools() ([]ToolInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/tools", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting tools: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting tools: HTTP %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Tools []ToolInfo `json:"tools"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("parsing tools: %w", err)
+	}
+	return result.Tools, nil
+}
+
+// ListMCPServers returns the daemon's MCP servers and their status.
+func (c *DaemonClient) ListMCPServers() ([]mcp.ServerInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/mcp/servers", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var result struct {
+		Servers []mcp.ServerInfo `json:"servers"`
+	}
+	if err := c.doJSON(req, "listing MCP servers", &result); err != nil {
+		return nil, err
+	}
+	return result.Servers, nil
+}
+
+// AddMCPServer connects an MCP server on the daemon. The returned entry's
+// Status and Error say whether it connected.
+func (c *DaemonClient) AddMCPServer(sr MCPServerRequest) (*mcp.ServerInfo, error) {
+	body, _ := json.Marshal(sr)
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/mcp/servers", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var info mcp.ServerInfo
+	if err := c.doJSON(req, "adding MCP server", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// RemoveMCPServer disconnects an MCP server. With save set it is also
+// deleted from the user-scope mcp.json.
+func (c *DaemonClient) RemoveMCPServer(name string, save bool) error {
+	u := c.baseURL + "/api/mcp/servers/" + url.PathEscape(name)
+	if save {
+		u += "?save=true"
+	}
+	req, err := http.NewRequest(http.MethodDelete, u, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	return c.doJSON(req, "removing MCP server", nil)
+}
+
+// RestartMCPServer reconnects an MCP server, picking up edits to its
+// entry in mcp.json.
+func (c *DaemonClient) RestartMCPServer(name string) (*mcp.ServerInfo, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/mcp/servers/"+url.PathEscape(name)+"/restart", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var info mcp.ServerInfo
+	if err := c.doJSON(req, "restarting MCP server", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// MCPServerLogs returns the last lines an MCP server wrote to stderr.
+func (c *DaemonClient) MCPServerLogs(name string) ([]string, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/mcp/servers/"+url.PathEscape(name)+"/logs", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var result struct {
+		Lines []string `json:"lines"`
+	}
+	if err := c.doJSON(req, "reading MCP server logs", &result); err != nil {
+		return nil, err
+	}
+	return result.Lines, nil
+}
+
+// doJSON sends req and decodes the reply into out, if non-nil. Error
+// replies surface the daemon's message.
+func (c *DaemonClient) doJSON(req *http.Request, action string, out any) error {
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp.Error != "" {
+			return fmt.Errorf("%s: %s", action, errResp.Error)
+		}
+		return fmt.Errorf("%s: HTTP %d", action, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s: parsing response: %w", action, err)
+	}
+	return nil
+}
+
+// MCPToolsResponse holds the response from the /api/mcp/tools endpoint.
+type MCPToolsResponse struct {
+	Tools    []string          `json:"tools"`
+	Statuses map[string]string `json:"statuses"`
+	Servers  []mcp.ServerInfo  `json:"servers"`
+}
+
+// GetMCPTools retrieves the list of MCP tool names and server statuses.
+func (c *DaemonClient) GetMCPTools() (*MCPToolsResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/mcp/tools", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting MCP tools: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting MCP tools: HTTP %d", resp.StatusCode)
+	}
+
+	var result MCPToolsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("parsing MCP tools: %w", err)
+	}
+	return &result, nil
+}
+
+// EgressReport holds the response from the /api/egress endpoint.
+type EgressReport struct {
+	Mode  string            `json:"mode"`
+	Hosts []egress.HostStat `json:"hosts"`
+}
+
+// GetEgressReport retrieves the outbound hosts recorded by the egress policy.
+func (c *DaemonClient) GetEgressReport() (*EgressReport, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/egress", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting egress report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting egress report: HTTP %d", resp.StatusCode)
+	}
+
+	var result EgressReport
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("parsing egress report: %w", err)
+	}
+	return &result, nil
+}
+
+// ListScheduledJobs returns the daemon's scheduled tool jobs.
+func (c *DaemonClient) ListScheduledJobs() ([]store.ScheduledToolJob, error) {
+	return c.listScheduledJobs("/api/schedule")
+}
+
+// ListScheduledJobsWithAttempts returns the daemon's scheduled tool jobs,
+// each with its most recent attempts.
+func (c *DaemonClient) ListScheduledJobsWithAttempts() ([]store.ScheduledToolJob, error) {
+	return c.listScheduledJobs("/api/schedule?attempts=5")
+}
+
+func (c *DaemonClient) listScheduledJobs(path string) ([]store.ScheduledToolJob, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing scheduled jobs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing scheduled jobs: HTTP %d", resp.StatusCode)
+	}
+	var jobs []store.ScheduledToolJob
+	if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
+		return nil, fmt.Errorf("parsing scheduled jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// CreateScheduledJob enqueues a tool call on the daemon's scheduler and
+// returns the job ID.
+func (c *DaemonClient) CreateScheduledJob(toolName string, toolInput map[string]any, scheduledFor time.Time, recurrence string) (string, error) {
+	body, _ := json.Marshal(map[string]any{
+		"tool_name":     toolName,
+		"tool_input":    toolInput,
+		"scheduled_for": scheduledFor,
+		"recurrence":    recurrence,
+	})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/schedule", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("scheduling job: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID    string `json:"id"`
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		if result.Error != "" {
+			return "", errors.New(result.Error)
+		}
+		return "", fmt.Errorf("scheduling job: HTTP %d", resp.StatusCode)
+	}
+	return result.ID, nil
+}
+
+// CancelScheduledJob cancels a pending, failed, or draft job.
+func (c *DaemonClient) CancelScheduledJob(id string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/api/schedule/"+url.PathEscape(id), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("canceling job: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("canceling job: HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ListDrafts returns scheduled jobs held for approval.
+func (c *DaemonClient) ListDrafts() ([]store.ScheduledToolJob, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/drafts", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing drafts: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing drafts: HTTP %d", resp.StatusCode)
+	}
+	var drafts []store.ScheduledToolJob
+	if err := json.NewDecoder(resp.Body).Decode(&drafts); err != nil {
+		return nil, fmt.Errorf("parsing drafts: %w", err)
+	}
+	return drafts, nil
+}
+
+// ApproveDraft releases the draft whose ID starts with idPrefix to the
+// scheduler. Returns the full job ID.
+func (c *DaemonClient) ApproveDraft(idPrefix string) (string, error) {
+	return c.resolveDraft(idPrefix, "approve")
+}
+
+// RejectDraft discards the draft whose ID starts with idPrefix. Returns the
+// full job ID.
+func (c *DaemonClient) RejectDraft(idPrefix string) (string, error) {
+	return c.resolveDraft(idPrefix, "reject")
+}
+
+func (c *DaemonClient) resolveDraft(idPrefix, action string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/drafts/"+url.PathEscape(idPrefix)+"/"+action, nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return "", fmt.Errorf("%s draft: %w", action, err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ID    string `json:"id"`
+		Error string `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		if result.Error != "" {
+			return "", errors.New(result.Error)
+		}
+		return "", fmt.Errorf("%s draft: HTTP %d", action, resp.StatusCode)
+	}
+	return result.ID, nil
+}
+
+// OllamaStatus checks the daemon's Ollama server and lists its models.
+func (c *DaemonClient) OllamaStatus() (*OllamaStatus, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/ollama", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var status OllamaStatus
+	if err := c.doJSON(req, "checking ollama", &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// CreateWebhook registers a webhook for events (every event when empty).
+// An empty secret asks the daemon to generate one; the returned secret is
+// not shown again.
+func (c *DaemonClient) CreateWebhook(hookURL string, events []string, secret string) (hook *store.Webhook, hookSecret string, err error) {
+	body, _ := json.Marshal(map[string]any{"url": hookURL, "events": events, "secret": secret})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/webhooks", bytes.NewReader(body))
+	if err != nil {
+		return nil, "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var resp struct {
+		store.Webhook
+		Secret string `json:"secret"`
+	}
+	if err := c.doJSON(req, "creating webhook", &resp); err != nil {
+		return nil, "", err
+	}
+	return &resp.Webhook, resp.Secret, nil
+}
+
+// ListWebhooks returns the daemon's webhooks.
+func (c *DaemonClient) ListWebhooks() ([]store.Webhook, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/webhooks", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var resp struct {
+		Webhooks []store.Webhook `json:"webhooks"`
+	}
+	if err := c.doJSON(req, "listing webhooks", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Webhooks, nil
+}
+
+// DeleteWebhook removes a webhook.
+func (c *DaemonClient) DeleteWebhook(id string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/api/webhooks/"+url.PathEscape(id), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	return c.doJSON(req, "deleting webhook", nil)
+}
+
+// NotifyTest sends a test notification to sink, or to every routed sink
+// when sink is empty, and returns the outcome for each.
+func (c *DaemonClient) NotifyTest(sink string) ([]notify.Result, error) {
+	body, _ := json.Marshal(map[string]string{"sink": sink})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/notify/test", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var resp struct {
+		Results []notify.Result `json:"results"`
+	}
+	if err := c.doJSON(req, "sending test notification", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// ListAdapters reports the chat adapters the daemon can run.
+func (c *DaemonClient) ListAdapters() ([]AdapterStatus, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/adapters", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var resp struct {
+		Adapters []AdapterStatus `json:"adapters"`
+	}
+	if err := c.doJSON(req, "listing adapters", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Adapters, nil
+}
+
+// StartAdapter starts the named chat adapter, e.g. "slack".
+func (c *DaemonClient) StartAdapter(name string) (*AdapterStatus, error) {
+	return c.adapterAction(name, "start", "starting")
+}
+
+// StopAdapter stops the named chat adapter.
+func (c *DaemonClient) StopAdapter(name string) (*AdapterStatus, error) {
+	return c.adapterAction(name, "stop", "stopping")
+}
+
+func (c *DaemonClient) adapterAction(name, action, verb string) (*AdapterStatus, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/adapters/"+url.PathEscape(name)+"/"+action, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var st AdapterStatus
+	if err := c.doJSON(req, verb+" "+name, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// PullOllamaModel pulls a model to the daemon's Ollama server, calling
+// onProgress for each update. It returns when the pull finishes.
+func (c *DaemonClient) PullOllamaModel(name string, onProgress func(provider.OllamaProgress)) error {
+	body, _ := json.Marshal(map[string]string{"model": name})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/ollama/pull", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	// No timeout: large models take a while to download.
+	resp, err := c.newHTTPClient(0).Do(req)
+	if err != nil {
+		return fmt.Errorf("pulling %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("pulling %s (HTTP %d): %s", name, resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		var line struct {
+			provider.OllamaProgress
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil {
+			continue
+		}
+		switch {
+		case line.Error != "":
+			return errors.New(line.Error)
+		case line.Status == "success":
+			return nil
+		case onProgress != nil:
+			onProgress(line.OllamaProgress)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("pulling %s: %w", name, err)
+	}
+	return fmt.Errorf("pulling %s: the daemon closed the stream early", name)
+}
+
+// DeleteOllamaModel removes a model from the daemon's Ollama server.
+func (c *DaemonClient) DeleteOllamaModel(name string) error {
+	req, err := http.NewRequest(http.MethodDelete, c.baseURL+"/api/ollama/models/"+url.PathEscape(name), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	return c.doJSON(req, "removing model", nil)
+}
+
+// CreateJob queues a headless agent job. tools limits the agent to the
+// named tools; nil allows all of them.
+func (c *DaemonClient) CreateJob(prompt string, tools []string) (*store.Job, error) {
+	body, _ := json.Marshal(map[string]any{"prompt": prompt, "tools": tools})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/jobs", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var job store.Job
+	if err := c.doJSON(req, "queueing job", &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListJobs returns the daemon's newest jobs, without their logs.
+func (c *DaemonClient) ListJobs(limit int) ([]store.Job, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/jobs?limit="+strconv.Itoa(limit), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var jobs []store.Job
+	if err := c.doJSON(req, "listing jobs", &jobs); err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// GetJob returns a job with its log.
+func (c *DaemonClient) GetJob(id string) (*store.Job, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/jobs/"+url.PathEscape(id), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var job store.Job
+	if err := c.doJSON(req, "getting job", &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// CancelJob cancels a queued job or stops a running one. It returns
+// "cancelled", or "cancelling" for a job that is still stopping.
+func (c *DaemonClient) CancelJob(id string) (string, error) {
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/jobs/"+url.PathEscape(id)+"/cancel", nil)
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	var result struct {
+		Status string `json:"status"`
+	}
+	if err := c.doJSON(req, "cancelling job", &result); err != nil {
+		return "", err
+	}
+	return result.Status, nil
+}
+
+// SendFeedback rates an assistant message. sequence 0 rates the latest
+// assistant message. Returns the sequence that was rated.
+func (c *DaemonClient) SendFeedback(sessionID, rating, note string, sequence int) (int, error) {
+	body, _ := json.Marshal(map[string]any{"rating": rating, "note": note, "sequence": sequence})
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/feedback", bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sending feedback: %w", err)
 	}
 	defer resp.Body.Close()
 
 	var result struct {
-		Status  string `json:"status"`
-		Message string `json:"message"`
-		Error   string `json:"error"`
+		Sequence int    `json:"sequence"`
+		Error    string `json:"error"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", fmt.Errorf("parsing response: %w", err)
+	_ = json.NewDecoder(resp.Body).Decode(&result)
+	if resp.StatusCode != http.StatusOK {
+		if result.Error != "" {
+			return 0, fmt.Errorf("sending feedback: %s", result.Error)
+		}
+		return 0, fmt.Errorf("sending feedback: HTTP %d", resp.StatusCode)
 	}
-	if result.Error != "" {
-		return "", fmt.Errorf("%s", result.Error)
+	return result.Sequence, nil
+}
+
+// Stats is the quality dashboard returned by GET /api/stats.
+type Stats struct {
+	Feedback store.FeedbackSummary `json:"feedback"`
+	// Failures lists recurring post-mortem categories, most frequent first.
+	Failures []store.FailurePattern `json:"failures,omitempty"`
+	// Spend lists estimated model spend per day and model, newest first,
+	// across all projects.
+	Spend []store.DailySpend `json:"spend,omitempty"`
+	// Host is the memory available to local models, reported while the
+	// daemon uses a model running on this host.
+	Host *hostinfo.Resources `json:"host,omitempty"`
+}
+
+// GetStats retrieves the quality dashboard. project filters to one project
+// path (empty for all); days limits the window (0 for all time).
+func (c *DaemonClient) GetStats(project string, days int) (*Stats, error) {
+	q := url.Values{}
+	if project != "" {
+		q.Set("project", project)
 	}
-	return result.Message, nil
+	if days > 0 {
+		q.Set("days", strconv.Itoa(days))
+	}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/stats?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getting stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("getting stats: HTTP %d", resp.StatusCode)
+	}
+
+	var result Stats
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("parsing stats: %w", err)
+	}
+	return &result, nil
 }
 
-// MCPToolsResponse holds the response from the /api/mcp/tools endpoint.
-type MCPToolsResponse struct {
-	Tools    []string          `json:"tools"`
-	Statuses map[string]string `json:"statuses"`
+// Usage is the token and spend report returned by GET /api/usage.
+type Usage struct {
+	Since   string           `json:"since"` // first day included, YYYY-MM-DD
+	GroupBy string           `json:"group_by"`
+	Rows    []store.UsageRow `json:"rows"`
+	Total   store.UsageRow   `json:"total"`
 }
 
-// GetMCPTools retrieves the list of MCP tool names and server statuses.
-func (c *DaemonClient) GetMCPTools() (*MCPToolsResponse, error) {
-	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/mcp/tools", nil)
+// GetOptimizeReport runs the cost advisor over usage since window (e.g.
+// "30d"; empty for 30 days).
+func (c *DaemonClient) GetOptimizeReport(window string) (*OptimizeReport, error) {
+	q := url.Values{}
+	if window != "" {
+		q.Set("since", window)
+	}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/usage/optimize?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var out OptimizeReport
+	if err := c.doJSON(req, "getting cost suggestions", &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUsage retrieves token usage and estimated spend since window (e.g.
+// "7d" or a YYYY-MM-DD date; empty for 30 days) grouped by groupBy ("day",
+// "model", or "project").
+func (c *DaemonClient) GetUsage(window, groupBy string) (*Usage, error) {
+	q := url.Values{}
+	if window != "" {
+		q.Set("since", window)
+	}
+	if groupBy != "" {
+		q.Set("group_by", groupBy)
+	}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/usage?"+q.Encode(), nil)
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
 	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("getting MCP tools: %w", err)
+		return nil, fmt.Errorf("getting usage: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("getting MCP tools: HTTP %d", resp.StatusCode)
+		return nil, fmt.Errorf("getting usage: HTTP %d", resp.StatusCode)
 	}
 
-	var result MCPToolsResponse
+	var result Usage
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("parsing MCP tools: %w", err)
+		return nil, fmt.Errorf("parsing usage: %w", err)
 	}
 	return &result, nil
 }
 
+// ExportSession fetches a session transcript rendered in format ("json" or
+// "md").
+func (c *DaemonClient) ExportSession(sessionID, format string) ([]byte, error) {
+	q := url.Values{"format": {format}}
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+sessionID+"/export?"+q.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exporting session: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp map[string]string
+		if json.NewDecoder(resp.Body).Decode(&errResp) == nil && errResp["error"] != "" {
+			return nil, fmt.Errorf("exporting session: %s", errResp["error"])
+		}
+		return nil, fmt.Errorf("exporting session: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading export: %w", err)
+	}
+	return data, nil
+}
+
 // Consult sends a summary to the daemon's consult endpoint and returns the
 // model name and response text.
 func (c *DaemonClient) Consult(sessionID, summary string) (model, response string, err error) {
@@ -458,7 +2090,7 @@ func (c *DaemonClient) Consult(sessionID, summary string) (model, response strin
 	req.Header.Set("Content-Type", "application/json")
 
 	// Use a longer timeout for consult calls since they invoke an LLM.
-	client := &http.Client{Timeout: 120 * time.Second}
+	client := c.newHTTPClient(120 * time.Second)
 	if c.authToken != "" {
 		req.Header.Set("Authorization", "Bearer "+c.authToken)
 	}
@@ -486,6 +2118,44 @@ func (c *DaemonClient) Consult(sessionID, summary string) (model, response strin
 	return result.Model, result.Response, nil
 }
 
+// CompactResult reports a manual compaction: how many messages were
+// summarized, and by which model. Compacted is 0 when the conversation was
+// too short to compact.
+type CompactResult struct {
+	Compacted int    `json:"compacted"`
+	Model     string `json:"model,omitempty"`
+}
+
+// Compact summarizes the session's earlier messages now.
+func (c *DaemonClient) Compact(sessionID string) (CompactResult, error) {
+	var result CompactResult
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/sessions/"+sessionID+"/compact", nil)
+	if err != nil {
+		return result, fmt.Errorf("creating request: %w", err)
+	}
+	if c.authToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+	// Summarizing calls an LLM, so allow as long as a consult.
+	resp, err := c.newHTTPClient(120 * time.Second).Do(req)
+	if err != nil {
+		return result, fmt.Errorf("compact: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&errResp)
+		return result, fmt.Errorf("compact: %s", errResp.Error)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return result, fmt.Errorf("compact: parsing response: %w", err)
+	}
+	return result, nil
+}
+
 // WaitReady polls Health() until the daemon is responsive or the timeout is reached.
 func (c *DaemonClient) WaitReady(timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
@@ -527,6 +2197,43 @@ func (c *DaemonClient) BranchSession(sessionID string, atSequence int) (*domain.
 	return &sess, nil
 }
 
+// GetAnnotations returns a session's message annotations, by sequence.
+func (c *DaemonClient) GetAnnotations(sessionID string) (map[int]string, error) {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/sessions/"+url.PathEscape(sessionID)+"/annotations", nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	var notes map[int]string
+	if err := c.doJSON(req, "getting annotations", &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// AnnotateMessage sets the note on the message at sequence. An empty note
+// removes it.
+func (c *DaemonClient) AnnotateMessage(sessionID string, sequence int, note string) error {
+	body, _ := json.Marshal(map[string]string{"note": note})
+	req, err := http.NewRequest(http.MethodPost,
+		fmt.Sprintf("%s/api/sessions/%s/messages/%d/annotation", c.baseURL, url.PathEscape(sessionID), sequence),
+		bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.doJSON(req, "annotating message", nil)
+}
+
+// DeleteMessage removes the message at sequence from a session.
+func (c *DaemonClient) DeleteMessage(sessionID string, sequence int) error {
+	req, err := http.NewRequest(http.MethodDelete,
+		fmt.Sprintf("%s/api/sessions/%s/messages/%d", c.baseURL, url.PathEscape(sessionID), sequence), nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	return c.doJSON(req, "deleting message", nil)
+}
+
 // SetBaseURL overrides the base URL (useful for testing or remote connections).
 func (c *DaemonClient) SetBaseURL(url string) {
 	c.baseURL = url
@@ -547,11 +2254,17 @@ func ParseSSEStream(body io.Reader, onEvent func(SSEEvent)) error {
 	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 
 	var eventType string
+	var eventID int
 	sawStreamDone := false
 	sawTurnDone := false
 	for scanner.Scan() {
 		line := scanner.Text()
 
+		if strings.HasPrefix(line, "id: ") {
+			eventID, _ = strconv.Atoi(strings.TrimPrefix(line, "id: "))
+			continue
+		}
+
 		if strings.HasPrefix(line, "event: ") {
 			eventType = strings.TrimPrefix(line, "event: ")
 			continue
@@ -560,6 +2273,7 @@ func ParseSSEStream(body io.Reader, onEvent func(SSEEvent)) error {
 		if strings.HasPrefix(line, "data: ") {
 			data := strings.TrimPrefix(line, "data: ")
 			evt := ParseSSEEvent(eventType, data)
+			evt.ID = eventID
 			if evt.Type != "" {
 				if evt.Type == "stream_done" {
 					sawStreamDone = true
@@ -569,7 +2283,7 @@ func ParseSSEStream(body io.Reader, onEvent func(SSEEvent)) error {
 				}
 				onEvent(evt)
 			}
-			eventType = ""
+			eventType, eventID = "", 0
 			continue
 		}
 	}
@@ -619,6 +2333,7 @@ func ParseSSEEvent(eventType, data string) SSEEvent {
 		evt.ToolName, _ = raw["tool_name"].(string)
 		evt.ToolResult, _ = raw["result"].(string)
 		evt.ToolIsError, _ = raw["is_error"].(bool)
+		evt.ErrorCode = errorCode(raw)
 
 	case "stream_done":
 		if v, ok := raw["input_tokens"].(float64); ok {
@@ -633,17 +2348,73 @@ func ParseSSEEvent(eventType, data string) SSEEvent {
 		if v, ok := raw["cache_read_input_tokens"].(float64); ok {
 			evt.CacheReadInputTokens = int(v)
 		}
+		if v, ok := raw["context_tokens"].(float64); ok {
+			evt.ContextTokens = int(v)
+		}
+		if v, ok := raw["context_window"].(float64); ok {
+			evt.ContextWindow = int(v)
+		}
+		if v, ok := raw["compact_at"].(float64); ok {
+			evt.CompactAt = int(v)
+		}
 		evt.StopReason, _ = raw["stop_reason"].(string)
 
+	case "approval_required":
+		evt.ApprovalID, _ = raw["approval_id"].(string)
+		evt.ToolUseID, _ = raw["tool_use_id"].(string)
+		evt.ToolName, _ = raw["tool_name"].(string)
+		if ti, ok := raw["tool_input"].(map[string]any); ok {
+			evt.ToolInput = ti
+		}
+
 	case "ask_user":
 		evt.AskID, _ = raw["ask_id"].(string)
 		evt.AskPrompt, _ = raw["prompt"].(string)
+		if opts, ok := raw["options"].([]any); ok {
+			for _, o := range opts {
+				if s, ok := o.(string); ok {
+					evt.AskOptions = append(evt.AskOptions, s)
+				}
+			}
+		}
 
 	case "turn_done":
 		evt.StopReason, _ = raw["stop_reason"].(string)
 
 	case "error":
 		evt.ErrorMsg, _ = raw["error"].(string)
+		evt.ErrorCode = errorCode(raw)
+		if b, ok := raw["budget"].(map[string]any); ok {
+			evt.Budget = parseBudgetInfo(b)
+		}
+
+	case "budget_warning":
+		evt.Budget = parseBudgetInfo(raw)
+
+	case "subagent":
+		evt.SubAgent = parseSubAgentInfo(raw)
+
+	case "verified":
+		v := &VerifyInfo{}
+		v.Model, _ = raw["model"].(string)
+		v.OK, _ = raw["ok"].(bool)
+		v.Critique, _ = raw["critique"].(string)
+		v.Error, _ = raw["error"].(string)
+		if n, ok := raw["input_tokens"].(float64); ok {
+			v.InputTokens = int(n)
+		}
+		if n, ok := raw["output_tokens"].(float64); ok {
+			v.OutputTokens = int(n)
+		}
+		evt.Verify = v
+
+	case "steered":
+		notes, _ := raw["notes"].([]any)
+		for _, n := range notes {
+			if s, ok := n.(string); ok {
+				evt.Steering = append(evt.Steering, s)
+			}
+		}
 
 	case "compacted":
 		evt.ModelUsed, _ = raw["model"].(string)
@@ -662,9 +2433,46 @@ func ParseSSEEvent(eventType, data string) SSEEvent {
 		}
 		evt.RetryMessage, _ = raw["message"].(string)
 
+	case "diagram":
+		evt.DiagramKind, _ = raw["kind"].(string)
+		evt.DiagramPath, _ = raw["path"].(string)
+		evt.ErrorMsg, _ = raw["error"].(string)
+
 	default:
 		return SSEEvent{}
 	}
 
 	return evt
 }
+
+func parseSubAgentInfo(raw map[string]any) *SubAgentInfo {
+	a := &SubAgentInfo{}
+	a.ID, _ = raw["id"].(string)
+	a.ParentID, _ = raw["parent_id"].(string)
+	a.Name, _ = raw["name"].(string)
+	a.State, _ = raw["state"].(string)
+	a.Tool, _ = raw["tool"].(string)
+	a.Error, _ = raw["error"].(string)
+	for key, dst := range map[string]*int{"turns": &a.Turns, "tokens": &a.Tokens, "max_turns": &a.MaxTurns, "max_tokens": &a.MaxTokens} {
+		if v, ok := raw[key].(float64); ok {
+			*dst = int(v)
+		}
+	}
+	return a
+}
+
+// errorCode reads the "code" field of an event.
+func errorCode(raw map[string]any) domain.ErrorCode {
+	code, _ := raw["code"].(string)
+	return domain.ErrorCode(code)
+}
+
+func parseBudgetInfo(raw map[string]any) *BudgetInfo {
+	b := &BudgetInfo{}
+	b.Scope, _ = raw["scope"].(string)
+	b.Key, _ = raw["key"].(string)
+	b.SpentUSD, _ = raw["spent_usd"].(float64)
+	b.LimitUSD, _ = raw["limit_usd"].(float64)
+	b.Message, _ = raw["message"].(string)
+	return b
+}
@@ -2,13 +2,19 @@ package daemon
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/tools"
 )
 
 func TestDaemonClientHealth(t *testing.T) {
@@ -44,6 +50,52 @@ func TestDaemonClientHealth(t *testing.T) {
 	})
 }
 
+func TestDaemonClientFallbackBaseURL(t *testing.T) {
+	var hits int
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Method == "POST" {
+			body, _ := io.ReadAll(r.Body)
+			if !strings.Contains(string(body), "/tmp/test") {
+				t.Errorf("expected request body to be replayed, got %q", body)
+			}
+			fmt.Fprint(w, `{"session_id":"abc-123"}`)
+			return
+		}
+		fmt.Fprint(w, `{"status":"ok"}`)
+	}))
+	defer fallback.Close()
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(primary.URL)
+	if err := client.SetFallbackBaseURL(strings.TrimPrefix(fallback.URL, "http://")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Health(); err != nil {
+		t.Fatalf("expected fallback to answer, got %v", err)
+	}
+	if _, err := client.CreateSession("/tmp/test", "model-a"); err != nil {
+		t.Fatalf("create session via fallback: %v", err)
+	}
+	if hits != 2 {
+		t.Errorf("expected 2 requests on the fallback, got %d", hits)
+	}
+	tr := client.transport.(*failoverTransport)
+	if got := tr.activeHost(tr.primary.Host); got != tr.fallback.Host {
+		t.Errorf("expected client to stay on the fallback, got %s", got)
+	}
+
+	if err := NewDaemonSocketClient("/tmp/muxd.sock").SetFallbackBaseURL("localhost:1"); err == nil {
+		t.Error("expected error for a unix socket client")
+	}
+	if err := NewDaemonClient(0).SetFallbackBaseURL("ftp://host"); err == nil {
+		t.Error("expected error for a non-http fallback")
+	}
+}
+
 func TestDaemonClientCreateSession(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" || r.URL.Path != "/api/sessions" {
@@ -101,6 +153,169 @@ func TestDaemonClientSubmitSSE(t *testing.T) {
 	}
 }
 
+func TestDaemonClientSubmitFallsBackToLongPoll(t *testing.T) {
+	origStall := sseStallTimeout
+	sseStallTimeout = 100 * time.Millisecond
+	defer func() { sseStallTimeout = origStall }()
+
+	release := make(chan struct{})
+	defer close(release)
+	var polls []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/sessions/s1/submit", func(w http.ResponseWriter, r *http.Request) {
+		// Like a buffering proxy: one event gets through, then nothing.
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: delta\ndata: {\"text\":\"Hel\"}\n\n")
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("GET /api/sessions/s1/events", func(w http.ResponseWriter, r *http.Request) {
+		polls = append(polls, r.URL.Query().Get("after"))
+		if r.URL.Query().Get("after") == "1" {
+			writeJSON(w, http.StatusOK, EventBatch{Next: 2, Events: []StreamEvent{
+				{Seq: 2, Event: "delta", Data: json.RawMessage(`{"text":"lo"}`)},
+			}})
+			return
+		}
+		writeJSON(w, http.StatusOK, EventBatch{Next: 3, Done: true, Events: []StreamEvent{
+			{Seq: 3, Event: "turn_done", Data: json.RawMessage(`{"stop_reason":"end_turn"}`)},
+		}})
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	var text strings.Builder
+	var last SSEEvent
+	err := client.Submit("s1", "hello", nil, func(evt SSEEvent) {
+		text.WriteString(evt.DeltaText)
+		last = evt
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if text.String() != "Hello" || last.Type != "turn_done" {
+		t.Errorf("text = %q, last = %+v", text.String(), last)
+	}
+	if strings.Join(polls, ",") != "1,2" {
+		t.Errorf("polled after %v, want 1 then 2", polls)
+	}
+}
+
+func TestDaemonClientSubmitResumesDroppedStream(t *testing.T) {
+	var lastEventIDs []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/sessions/s1/submit", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 1\nevent: delta\ndata: {\"text\":\"Hel\"}\n\n")
+		w.(http.Flusher).Flush()
+		// Drop the connection mid-turn.
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+	})
+	mux.HandleFunc("GET /api/sessions/s1/stream", func(w http.ResponseWriter, r *http.Request) {
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "id: 2\nevent: delta\ndata: {\"text\":\"lo\"}\n\n")
+		fmt.Fprint(w, "id: 3\nevent: turn_done\ndata: {\"stop_reason\":\"end_turn\"}\n\n")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	var text strings.Builder
+	var ids []int
+	err := client.Submit("s1", "hello", nil, func(evt SSEEvent) {
+		text.WriteString(evt.DeltaText)
+		ids = append(ids, evt.ID)
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if text.String() != "Hello" || fmt.Sprint(ids) != "[1 2 3]" {
+		t.Errorf("text = %q, ids = %v", text.String(), ids)
+	}
+	if strings.Join(lastEventIDs, ",") != "1" {
+		t.Errorf("resumed with Last-Event-ID %v, want 1", lastEventIDs)
+	}
+}
+
+func TestDaemonClientResumeStreamGone(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusGone, map[string]string{"error": "events after 5 are no longer buffered"})
+	}))
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	err := client.resumeStream("s1", &turnFollower{onEvent: func(SSEEvent) {}, last: 5}, io.ErrUnexpectedEOF)
+	if err == nil || !strings.Contains(err.Error(), "no longer buffered") {
+		t.Errorf("resumeStream error = %v", err)
+	}
+}
+
+func TestDaemonClientFollowTurn(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	log := srv.events.get("s1")
+	log.start()
+	send := srv.teeToEventLog("s1", func(string, any) {})
+	send("delta", map[string]string{"text": "before "})
+	send("delta", map[string]string{"text": "detach"})
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+	status, err := client.GetTurnStatus("s1")
+	if err != nil {
+		t.Fatalf("GetTurnStatus: %v", err)
+	}
+	if status.LastEvent != 2 {
+		t.Errorf("LastEvent = %d, want 2", status.LastEvent)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		send("delta", map[string]string{"text": "after"})
+		send("turn_done", map[string]string{"stop_reason": "end_turn"})
+		log.finish()
+	}()
+	var got []string
+	if err := client.FollowTurn("s1", status.LastEvent, func(evt SSEEvent) {
+		got = append(got, evt.Type+":"+evt.DeltaText)
+	}); err != nil {
+		t.Fatalf("FollowTurn: %v", err)
+	}
+	if strings.Join(got, ",") != "delta:after,turn_done:" {
+		t.Errorf("events = %v, want only those after the attach point", got)
+	}
+}
+
+func TestDaemonClientLongPollStopsOnHTTPError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+	}))
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	err := client.pollEvents("s1", 0, func(SSEEvent) {})
+	if err == nil || !strings.Contains(err.Error(), "unauthorized") {
+		t.Errorf("pollEvents error = %v", err)
+	}
+}
+
 func TestDaemonClientSubmitSSEToolEvents(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/event-stream")
@@ -240,7 +455,7 @@ func TestParseSSEEvent_tool_done(t *testing.T) {
 }
 
 func TestParseSSEEvent_stream_done(t *testing.T) {
-	evt := ParseSSEEvent("stream_done", `{"input_tokens":500,"output_tokens":200,"cache_creation_input_tokens":30,"cache_read_input_tokens":150,"stop_reason":"end_turn"}`)
+	evt := ParseSSEEvent("stream_done", `{"input_tokens":500,"output_tokens":200,"cache_creation_input_tokens":30,"cache_read_input_tokens":150,"context_tokens":680,"context_window":200000,"compact_at":90000,"stop_reason":"end_turn"}`)
 	if evt.Type != "stream_done" {
 		t.Errorf("Type = %q, want %q", evt.Type, "stream_done")
 	}
@@ -256,6 +471,9 @@ func TestParseSSEEvent_stream_done(t *testing.T) {
 	if evt.CacheReadInputTokens != 150 {
 		t.Errorf("CacheReadInputTokens = %d, want 150", evt.CacheReadInputTokens)
 	}
+	if evt.ContextTokens != 680 || evt.ContextWindow != 200000 || evt.CompactAt != 90000 {
+		t.Errorf("context = %d/%d (compact at %d), want 680/200000 (compact at 90000)", evt.ContextTokens, evt.ContextWindow, evt.CompactAt)
+	}
 	if evt.StopReason != "end_turn" {
 		t.Errorf("StopReason = %q, want %q", evt.StopReason, "end_turn")
 	}
@@ -272,16 +490,32 @@ func TestParseSSEEvent_ask_user(t *testing.T) {
 	if evt.AskPrompt != "Allow file write to main.go?" {
 		t.Errorf("AskPrompt = %q, want %q", evt.AskPrompt, "Allow file write to main.go?")
 	}
+	if evt.AskOptions != nil {
+		t.Errorf("AskOptions = %q, want none", evt.AskOptions)
+	}
+
+	evt = ParseSSEEvent("ask_user", `{"ask_id":"ask_xyz","prompt":"Which?","options":["staging","prod"]}`)
+	if !slices.Equal(evt.AskOptions, []string{"staging", "prod"}) {
+		t.Errorf("AskOptions = %q", evt.AskOptions)
+	}
 }
 
 func TestParseSSEEvent_error(t *testing.T) {
-	evt := ParseSSEEvent("error", `{"error":"rate limit exceeded"}`)
+	evt := ParseSSEEvent("error", `{"error":"rate limit exceeded","code":"rate_limited"}`)
 	if evt.Type != "error" {
 		t.Errorf("Type = %q, want %q", evt.Type, "error")
 	}
 	if evt.ErrorMsg != "rate limit exceeded" {
 		t.Errorf("ErrorMsg = %q, want %q", evt.ErrorMsg, "rate limit exceeded")
 	}
+	if evt.ErrorCode != domain.ErrRateLimited {
+		t.Errorf("ErrorCode = %q, want %q", evt.ErrorCode, domain.ErrRateLimited)
+	}
+
+	evt = ParseSSEEvent("tool_done", `{"tool_name":"bash","result":"exit 1","is_error":true,"code":"tool_failed"}`)
+	if evt.ErrorCode != domain.ErrToolFailed {
+		t.Errorf("tool_done ErrorCode = %q, want %q", evt.ErrorCode, domain.ErrToolFailed)
+	}
 }
 
 func TestParseSSEStream_toleratesUnexpectedEOFAfterCompletion(t *testing.T) {
@@ -521,6 +755,72 @@ func TestDaemonClientGetMCPTools_httpError(t *testing.T) {
 	}
 }
 
+func TestDaemonClientGetTools(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tools" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, `{"tools":[{"name":"grep","description":"Search","source":"builtin","input_schema":{"type":"object"}}]}`)
+	}))
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+
+	list, err := client.GetTools()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(list) != 1 || list[0].Name != "grep" || list[0].Source != "builtin" {
+		t.Errorf("tools = %+v", list)
+	}
+}
+
+func TestDaemonClientMCPServers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/mcp/servers":
+			var req MCPServerRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.Name != "db" || req.Command != "db-server" || !req.Save {
+				t.Errorf("add request = %+v", req)
+			}
+			fmt.Fprint(w, `{"name":"db","type":"stdio","target":"db-server","status":"connected","tools":2}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/mcp/servers/db":
+			if r.URL.Query().Get("save") != "true" {
+				t.Errorf("remove query = %q", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"status":"removed"}`)
+		case r.URL.Path == "/api/mcp/servers/nope/restart":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"unknown MCP server \"nope\""}`)
+		case r.URL.Path == "/api/mcp/servers/db/logs":
+			fmt.Fprint(w, `{"lines":["starting","ready"]}`)
+		default:
+			t.Errorf("unexpected %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+
+	info, err := client.AddMCPServer(MCPServerRequest{Name: "db", ServerConfig: mcp.ServerConfig{Command: "db-server"}, Save: true})
+	if err != nil || info.Status != "connected" || info.Tools != 2 {
+		t.Errorf("AddMCPServer = %+v, %v", info, err)
+	}
+	if err := client.RemoveMCPServer("db", true); err != nil {
+		t.Errorf("RemoveMCPServer: %v", err)
+	}
+	if _, err := client.RestartMCPServer("nope"); err == nil || !strings.Contains(err.Error(), `unknown MCP server "nope"`) {
+		t.Errorf("RestartMCPServer error = %v, want the daemon's message", err)
+	}
+	if lines, err := client.MCPServerLogs("db"); err != nil || len(lines) != 2 {
+		t.Errorf("MCPServerLogs = %v, %v", lines, err)
+	}
+}
+
 func TestDaemonClientBranchSession(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -631,6 +931,22 @@ func TestParseSSEEvent_retrying(t *testing.T) {
 	}
 }
 
+func TestParseSSEEvent_verified(t *testing.T) {
+	evt := ParseSSEEvent("verified", `{"model":"gpt-4o","ok":false,"critique":"- wrong flag","input_tokens":120,"output_tokens":9}`)
+	want := VerifyInfo{Model: "gpt-4o", Critique: "- wrong flag", InputTokens: 120, OutputTokens: 9}
+	if evt.Type != "verified" || evt.Verify == nil || *evt.Verify != want {
+		t.Errorf("got %+v, want Verify %+v", evt, want)
+	}
+}
+
+func TestParseSSEEvent_subagent(t *testing.T) {
+	evt := ParseSSEEvent("subagent", `{"id":"s1","parent_id":"p1","name":"docs","state":"running","tool":"grep","turns":2,"tokens":1500,"max_turns":10,"max_tokens":200000}`)
+	want := SubAgentInfo{ID: "s1", ParentID: "p1", Name: "docs", State: "running", Tool: "grep", Turns: 2, Tokens: 1500, MaxTurns: 10, MaxTokens: 200000}
+	if evt.Type != "subagent" || evt.SubAgent == nil || *evt.SubAgent != want {
+		t.Errorf("got %+v, want SubAgent %+v", evt, want)
+	}
+}
+
 func TestIsRecoverableSSEStreamErr(t *testing.T) {
 	tests := []struct {
 		name string
@@ -797,3 +1113,198 @@ func extractPort(t *testing.T, url string) int {
 	}
 	return port
 }
+
+func TestDaemonClientDrafts(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	id, _ := st.CreateDraftToolJob("sms_send", map[string]any{"message": "hi"}, time.Now().Add(time.Hour), "once")
+	drafts, err := client.ListDrafts()
+	if err != nil {
+		t.Fatalf("ListDrafts: %v", err)
+	}
+	if len(drafts) != 1 || drafts[0].ID != id {
+		t.Fatalf("unexpected drafts: %+v", drafts)
+	}
+
+	got, err := client.ApproveDraft(id[:8])
+	if err != nil || got != id {
+		t.Fatalf("ApproveDraft = %q, %v; want %q", got, err, id)
+	}
+	if _, err := client.RejectDraft(id[:8]); err == nil {
+		t.Error("expected rejecting an approved draft to fail")
+	}
+	if drafts, _ := client.ListDrafts(); len(drafts) != 0 {
+		t.Errorf("expected no drafts after approval, got %d", len(drafts))
+	}
+}
+
+func TestDaemonClientSchedule(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	at := time.Now().Add(time.Hour).Truncate(time.Second)
+	id, err := client.CreateScheduledJob("sms_send", map[string]any{"message": "hi"}, at, "daily")
+	if err != nil {
+		t.Fatalf("CreateScheduledJob: %v", err)
+	}
+	if _, err := client.CreateScheduledJob("no_such_tool", nil, at, ""); err == nil || !strings.Contains(err.Error(), "unknown tool") {
+		t.Errorf("expected unknown tool error, got %v", err)
+	}
+	if _, err := client.CreateScheduledJob("sms_send", nil, at, "fortnightly"); err == nil {
+		t.Error("expected invalid recurrence error")
+	}
+	if _, err := client.CreateScheduledJob("sms_send", nil, time.Time{}, "0 25 * * MON"); err == nil || !strings.Contains(err.Error(), "invalid hour") {
+		t.Errorf("expected invalid cron error, got %v", err)
+	}
+
+	jobs, err := client.ListScheduledJobs()
+	if err != nil {
+		t.Fatalf("ListScheduledJobs: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].ID != id || jobs[0].Recurrence != "daily" || !jobs[0].ScheduledFor.Equal(at) {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+
+	if err := srv.store.RetryScheduledToolJob(id, "boom", "", at, at.Add(time.Minute)); err != nil {
+		t.Fatalf("RetryScheduledToolJob: %v", err)
+	}
+	if jobs, _ = client.ListScheduledJobs(); len(jobs[0].Attempts) != 0 {
+		t.Errorf("expected no attempts without asking, got %+v", jobs[0].Attempts)
+	}
+	jobs, err = client.ListScheduledJobsWithAttempts()
+	if err != nil {
+		t.Fatalf("ListScheduledJobsWithAttempts: %v", err)
+	}
+	if len(jobs[0].Attempts) != 1 || jobs[0].Attempts[0].Error != "boom" || jobs[0].RetryCount != 1 {
+		t.Errorf("unexpected attempts: %+v", jobs[0])
+	}
+
+	if err := client.CancelScheduledJob(id); err != nil {
+		t.Fatalf("CancelScheduledJob: %v", err)
+	}
+	jobs, _ = client.ListScheduledJobs()
+	if len(jobs) != 1 || jobs[0].Status != "cancelled" {
+		t.Errorf("expected cancelled job, got %+v", jobs)
+	}
+}
+
+func TestDaemonClientMemory(t *testing.T) {
+	dir := t.TempDir()
+	origGetwd := tools.Getwd
+	tools.Getwd = func() (string, error) { return dir, nil }
+	t.Cleanup(func() { tools.Getwd = origGetwd })
+
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	if facts, err := client.GetMemory(); err != nil || len(facts) != 0 {
+		t.Fatalf("GetMemory = %v, %v; want empty", facts, err)
+	}
+	if err := client.SetMemory("db", "postgres 16"); err != nil {
+		t.Fatalf("SetMemory: %v", err)
+	}
+	// The fact lands in the daemon's project, where its agents read it.
+	local, err := tools.NewProjectMemory(dir).Load()
+	if err != nil || local["db"] != "postgres 16" {
+		t.Fatalf("project memory = %v, %v", local, err)
+	}
+	if facts, _ := client.GetMemory(); facts["db"] != "postgres 16" {
+		t.Errorf("GetMemory = %v", facts)
+	}
+	if err := client.SetMemory("db", " "); err == nil {
+		t.Error("expected an empty value to be rejected")
+	}
+
+	if err := client.DeleteMemory("db"); err != nil {
+		t.Fatalf("DeleteMemory: %v", err)
+	}
+	err = client.DeleteMemory("db")
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("DeleteMemory of a missing key = %v, want not found", err)
+	}
+}
+
+func TestDaemonClientRenameAndDeleteSession(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	sess, err := st.CreateSession("/tmp/test", "model-a")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := client.RenameSession(sess.ID, "renamed"); err != nil {
+		t.Fatalf("RenameSession: %v", err)
+	}
+	if got := st.SessionTitle(sess.ID); got != "renamed" {
+		t.Errorf("title = %q, want renamed", got)
+	}
+
+	if err := client.DeleteSession(sess.ID); err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	if _, err := st.GetSession(sess.ID); err == nil {
+		t.Error("expected the session to be deleted")
+	}
+	if err := client.DeleteSession(sess.ID); err == nil {
+		t.Error("expected deleting a missing session to fail")
+	}
+
+	client.SetAuthToken("wrong")
+	if err := client.RenameSession(sess.ID, "x"); err == nil {
+		t.Error("expected rename with a bad token to fail")
+	}
+}
+
+func TestDaemonClientGetMessagesAfter(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(withCompression(mux))
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	_ = st.AppendMessage(sess.ID, "user", strings.Repeat("long question ", 200), 0)
+	msgs, last, err := client.GetMessagesAfter(sess.ID, 0)
+	if err != nil || len(msgs) != 1 || last != 1 {
+		t.Fatalf("GetMessagesAfter(0) = %d msgs, %d, %v", len(msgs), last, err)
+	}
+
+	_ = st.AppendMessage(sess.ID, "assistant", "answer", 0)
+	msgs, last, err = client.GetMessagesAfter(sess.ID, last)
+	if err != nil || len(msgs) != 1 || msgs[0].Content != "answer" || last != 2 {
+		t.Errorf("GetMessagesAfter(1) = %+v, %d, %v", msgs, last, err)
+	}
+}
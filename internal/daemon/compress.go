@@ -0,0 +1,222 @@
+package daemon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// ---------------------------------------------------------------------------
+// Response compression
+// ---------------------------------------------------------------------------
+
+// compressMinSize is the smallest response body worth compressing.
+const compressMinSize = 1024
+
+// compressor is a pooled gzip or zstd encoder.
+type compressor interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+var compressorPools = map[string]*sync.Pool{
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return enc
+	}},
+	"gzip": {New: func() any { return gzip.NewWriter(nil) }},
+}
+
+// negotiateEncoding picks zstd or gzip from an Accept-Encoding header,
+// preferring zstd, or returns "" for an uncompressed response.
+func negotiateEncoding(accept string) string {
+	ok := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		ok[strings.ToLower(strings.TrimSpace(name))] = q > 0
+	}
+	for _, enc := range []string{"zstd", "gzip"} {
+		if ok[enc] {
+			return enc
+		}
+	}
+	return ""
+}
+
+// compressible reports whether a response of this content type gains from
+// compression. Event streams are left alone so every event is sent as it
+// happens.
+func compressible(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mt == "text/event-stream":
+		return false
+	case strings.HasPrefix(mt, "text/"):
+		return true
+	}
+	switch mt {
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// withCompression compresses responses for clients that accept zstd or
+// gzip, so large transcripts cost less to sync over mobile networks.
+func withCompression(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		enc := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if enc == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: enc, status: http.StatusOK}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// to compress it: the body must be compressible and at least
+// compressMinSize bytes, unless the handler flushes first.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      bytes.Buffer
+	enc      compressor
+
+	headerSet   bool // the handler called WriteHeader
+	committed   bool // headers are sent
+	passthrough bool // send the body as written
+}
+
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.headerSet || cw.committed {
+		return
+	}
+	cw.headerSet = true
+	cw.status = code
+	h := cw.Header()
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified ||
+		h.Get("Content-Encoding") != "" || (h.Get("Content-Type") != "" && !compressible(h.Get("Content-Type"))) {
+		cw.passthrough = true
+		cw.commit()
+	}
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.headerSet {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.passthrough {
+		return cw.ResponseWriter.Write(p)
+	}
+	if cw.enc != nil {
+		return cw.enc.Write(p)
+	}
+	cw.buf.Write(p)
+	if cw.buf.Len() >= compressMinSize {
+		if err := cw.startCompression(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// commit sends the headers uncompressed.
+func (cw *compressWriter) commit() {
+	if cw.committed {
+		return
+	}
+	cw.committed = true
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// startCompression sends the compressed headers and the buffered body.
+func (cw *compressWriter) startCompression() error {
+	h := cw.Header()
+	if h.Get("Content-Type") == "" {
+		// Sniff now; net/http would otherwise sniff the compressed bytes.
+		h.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+	}
+	if !compressible(h.Get("Content-Type")) {
+		cw.passthrough = true
+		cw.commit()
+		_, err := cw.ResponseWriter.Write(cw.buf.Bytes())
+		cw.buf.Reset()
+		return err
+	}
+	h.Set("Content-Encoding", cw.encoding)
+	h.Add("Vary", "Accept-Encoding")
+	h.Del("Content-Length")
+	cw.commit()
+	cw.enc = compressorPools[cw.encoding].Get().(compressor)
+	cw.enc.Reset(cw.ResponseWriter)
+	_, err := cw.enc.Write(cw.buf.Bytes())
+	cw.buf.Reset()
+	return err
+}
+
+// Flush sends what has been written so far, compressed if it can be.
+func (cw *compressWriter) Flush() {
+	if !cw.headerSet {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.passthrough && cw.enc == nil {
+		if cw.buf.Len() == 0 {
+			// Nothing to judge the body by; stream it as written.
+			cw.passthrough = true
+			cw.commit()
+		} else {
+			_ = cw.startCompression()
+		}
+	}
+	if cw.enc != nil {
+		_ = cw.enc.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// close finishes the response: a small body goes out as is.
+func (cw *compressWriter) close() {
+	if cw.enc != nil {
+		_ = cw.enc.Close()
+		cw.enc.Reset(nil)
+		compressorPools[cw.encoding].Put(cw.enc)
+		cw.enc = nil
+		return
+	}
+	if cw.passthrough || cw.committed {
+		return
+	}
+	if cw.buf.Len() > 0 {
+		cw.Header().Set("Content-Length", strconv.Itoa(cw.buf.Len()))
+	}
+	if cw.headerSet || cw.buf.Len() > 0 {
+		cw.Header().Add("Vary", "Accept-Encoding")
+		cw.commit()
+		_, _ = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+}
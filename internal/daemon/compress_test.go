@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                     "",
+		"identity":             "",
+		"gzip":                 "gzip",
+		"gzip, deflate, br":    "gzip",
+		"gzip, zstd":           "zstd",
+		"zstd;q=0, gzip;q=0.5": "gzip",
+		"GZIP":                 "gzip",
+		"br;q=1.0, gzip;q=0":   "",
+	} {
+		if got := negotiateEncoding(accept); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	big := `{"text":"` + strings.Repeat("transcript ", 500) + `"}`
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, big)
+	})
+	mux.HandleFunc("GET /small", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+	})
+	mux.HandleFunc("GET /png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		fmt.Fprint(w, strings.Repeat("x", 4096))
+	})
+	mux.HandleFunc("GET /stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		writeSSE(w, w.(http.Flusher), "delta", map[string]string{"text": "hi"})
+	})
+	handler := withCompression(mux)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+	for enc, decode := range decoders {
+		w := get("/big", enc)
+		if w.Header().Get("Content-Encoding") != enc || w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Fatalf("%s: headers = %v", enc, w.Header())
+		}
+		if w.Body.Len() >= len(big) {
+			t.Errorf("%s: body not smaller: %d >= %d", enc, w.Body.Len(), len(big))
+		}
+		r, err := decode(w.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(r)
+		if string(got) != big {
+			t.Errorf("%s: round trip lost data", enc)
+		}
+	}
+
+	if w := get("/big", ""); w.Header().Get("Content-Encoding") != "" || w.Body.String() != big {
+		t.Error("compressed for a client that did not ask")
+	}
+	w := get("/small", "gzip")
+	if w.Code != http.StatusNotFound || w.Header().Get("Content-Encoding") != "" || !strings.Contains(w.Body.String(), "session not found") {
+		t.Errorf("small response: %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	if w := get("/png", "gzip"); w.Header().Get("Content-Encoding") != "" || w.Body.Len() != 4096 {
+		t.Errorf("image was compressed: %v", w.Header())
+	}
+	w = get("/stream", "gzip")
+	if w.Header().Get("Content-Encoding") != "" || !w.Flushed || !strings.Contains(w.Body.String(), "event: delta") {
+		t.Errorf("event stream: flushed=%v headers=%v body=%q", w.Flushed, w.Header(), w.Body.String())
+	}
+}
@@ -0,0 +1,298 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// ---------------------------------------------------------------------------
+// End-to-end harness
+// ---------------------------------------------------------------------------
+
+// e2e is a daemon on a real store and HTTP listener, a client talking to
+// it, and a fake provider standing in for the model.
+type e2e struct {
+	t      *testing.T
+	srv    *Server
+	store  *store.Store
+	client *DaemonClient
+	fake   *provider.FakeProvider
+	dir    string // project directory sessions run in
+}
+
+// newE2E starts a daemon whose agents reply with steps.
+func newE2E(t *testing.T, steps ...provider.FakeStep) *e2e {
+	t.Helper()
+	srv, st := newTestServer(t)
+	fake := provider.NewFakeProvider(steps...)
+	srv.provider = fake
+	srv.modelID, srv.modelLabel = "scripted", "fake/scripted"
+	srv.SetAgentFactory(func(apiKey, modelID, modelLabel string, st *store.Store, sess *domain.Session, prov provider.Provider) *agent.Service {
+		return agent.NewService(apiKey, modelID, modelLabel, st, sess, prov)
+	})
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+	return &e2e{t: t, srv: srv, store: st, client: client, fake: fake, dir: t.TempDir()}
+}
+
+// session creates a session in the project directory.
+func (h *e2e) session() string {
+	h.t.Helper()
+	id, err := h.client.CreateSession(h.dir, "scripted")
+	if err != nil {
+		h.t.Fatalf("CreateSession: %v", err)
+	}
+	return id
+}
+
+// e2eTransports are the ways a client can run a turn.
+var e2eTransports = []struct {
+	name   string
+	submit func(c *DaemonClient, sessionID, text string, onEvent func(SSEEvent)) error
+}{
+	{"websocket", func(c *DaemonClient, id, text string, onEvent func(SSEEvent)) error {
+		return c.Submit(id, text, nil, onEvent)
+	}},
+	{"sse", func(c *DaemonClient, id, text string, onEvent func(SSEEvent)) error {
+		return c.submitSSE(id, text, nil, onEvent)
+	}},
+}
+
+// turn is the events of one submitted turn.
+type turn []SSEEvent
+
+func (tr turn) types() []string {
+	var out []string
+	for _, e := range tr {
+		out = append(out, e.Type)
+	}
+	return out
+}
+
+func (tr turn) first(typ string) (SSEEvent, bool) {
+	for _, e := range tr {
+		if e.Type == typ {
+			return e, true
+		}
+	}
+	return SSEEvent{}, false
+}
+
+func (tr turn) text() string {
+	var b strings.Builder
+	for _, e := range tr {
+		if e.Type == "delta" {
+			b.WriteString(e.DeltaText)
+		}
+	}
+	return b.String()
+}
+
+// run submits text and waits for the turn to end. onEvent, if set, sees
+// each event as it arrives, before it is recorded.
+func (h *e2e) run(submit func(*DaemonClient, string, string, func(SSEEvent)) error, sessionID, text string, onEvent func(SSEEvent)) turn {
+	h.t.Helper()
+	var mu sync.Mutex
+	var events turn
+	done := make(chan error, 1)
+	go func() {
+		done <- submit(h.client, sessionID, text, func(evt SSEEvent) {
+			if onEvent != nil {
+				onEvent(evt)
+			}
+			mu.Lock()
+			events = append(events, evt)
+			mu.Unlock()
+		})
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			h.t.Fatalf("submit: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		h.t.Fatal("turn did not finish")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return events
+}
+
+// ---------------------------------------------------------------------------
+// Flows
+// ---------------------------------------------------------------------------
+
+func TestE2E_TextReply(t *testing.T) {
+	for _, tr := range e2eTransports {
+		t.Run(tr.name, func(t *testing.T) {
+			h := newE2E(t, provider.FakeStep{Text: "Hello from the fake model.", InputTokens: 120, OutputTokens: 6})
+			id := h.session()
+
+			events := h.run(tr.submit, id, "say hello", nil)
+			if got := events.text(); got != "Hello from the fake model." {
+				t.Errorf("streamed text = %q (events %v)", got, events.types())
+			}
+			done, ok := events.first("stream_done")
+			if !ok || done.InputTokens != 120 || done.OutputTokens != 6 || done.ContextTokens != 120 {
+				t.Errorf("stream_done = %+v", done)
+			}
+			if last := events[len(events)-1]; last.Type != "turn_done" {
+				t.Errorf("last event = %s, want turn_done", last.Type)
+			}
+
+			msgs, err := h.client.GetMessages(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != 2 || msgs[0].TextContent() != "say hello" || msgs[1].TextContent() != "Hello from the fake model." {
+				t.Errorf("stored messages = %+v", msgs)
+			}
+			calls := h.fake.Calls()
+			if len(calls) == 0 || calls[0].Model != "scripted" || !slices.Contains(calls[0].Tools, "file_read") {
+				t.Errorf("first call = %+v", calls)
+			}
+		})
+	}
+}
+
+func TestE2E_ToolCall(t *testing.T) {
+	for _, tr := range e2eTransports {
+		t.Run(tr.name, func(t *testing.T) {
+			h := newE2E(t)
+			notes := filepath.Join(h.dir, "notes.txt")
+			if err := os.WriteFile(notes, []byte("the answer is 42\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			h.fake.Push(
+				provider.FakeStep{ToolCalls: []provider.FakeToolCall{{Name: "file_read", Input: map[string]any{"path": notes}}}},
+				provider.FakeStep{Text: "The file says 42."},
+			)
+			id := h.session()
+
+			events := h.run(tr.submit, id, "what is in notes.txt?", nil)
+			start, ok := events.first("tool_start")
+			if !ok || start.ToolName != "file_read" {
+				t.Fatalf("tool_start = %+v (events %v)", start, events.types())
+			}
+			done, ok := events.first("tool_done")
+			if !ok || done.ToolIsError || !strings.Contains(done.ToolResult, "the answer is 42") {
+				t.Errorf("tool_done = %+v", done)
+			}
+			if got := events.text(); !strings.Contains(got, "The file says 42.") {
+				t.Errorf("streamed text = %q", got)
+			}
+
+			// The model's second call carries the tool result back.
+			calls := h.fake.Calls()
+			if len(calls) < 2 || !historyHasToolResult(calls[1].History, start.ToolUseID, "the answer is 42") {
+				t.Errorf("second call does not carry the tool result: %+v", calls)
+			}
+			if h.fake.Remaining() != 0 {
+				t.Errorf("%d steps left unplayed", h.fake.Remaining())
+			}
+		})
+	}
+}
+
+func TestE2E_AskUser(t *testing.T) {
+	for _, tr := range e2eTransports {
+		t.Run(tr.name, func(t *testing.T) {
+			h := newE2E(t,
+				provider.FakeStep{ToolCalls: []provider.FakeToolCall{{Name: "ask_user", Input: map[string]any{
+					"question": "Which color?", "options": []any{"red", "blue"},
+				}}}},
+				provider.FakeStep{Text: "Blue it is."},
+			)
+			id := h.session()
+
+			var answerErr error
+			events := h.run(tr.submit, id, "paint it", func(evt SSEEvent) {
+				if evt.Type == "ask_user" {
+					answerErr = h.client.SendAskResponse(id, evt.AskID, "blue")
+				}
+			})
+			if answerErr != nil {
+				t.Fatalf("SendAskResponse: %v", answerErr)
+			}
+			ask, ok := events.first("ask_user")
+			if !ok || ask.AskPrompt != "Which color?" || !slices.Equal(ask.AskOptions, []string{"red", "blue"}) {
+				t.Fatalf("ask_user = %+v (events %v)", ask, events.types())
+			}
+			if got := events.text(); !strings.Contains(got, "Blue it is.") {
+				t.Errorf("streamed text = %q", got)
+			}
+			calls := h.fake.Calls()
+			if len(calls) < 2 || !historyHasToolResult(calls[1].History, "", "blue") {
+				t.Errorf("second call does not carry the answer: %+v", calls)
+			}
+		})
+	}
+}
+
+func TestE2E_RateLimitRetry(t *testing.T) {
+	h := newE2E(t, provider.FakeRateLimit(10*time.Millisecond), provider.FakeStep{Text: "made it"})
+	id := h.session()
+
+	events := h.run(e2eTransports[0].submit, id, "hi", nil)
+	retry, ok := events.first("retrying")
+	if !ok || retry.RetryAttempt != 1 || retry.RetryWaitMs != 10 {
+		t.Errorf("retrying = %+v (events %v)", retry, events.types())
+	}
+	if got := events.text(); got != "made it" {
+		t.Errorf("streamed text = %q", got)
+	}
+	if _, failed := events.first("error"); failed {
+		t.Errorf("unexpected error event: %v", events.types())
+	}
+}
+
+func TestE2E_ProviderError(t *testing.T) {
+	h := newE2E(t, provider.FakeStep{Error: &provider.FakeError{Status: 401, Type: "authentication_error", Message: "invalid x-api-key"}})
+	id := h.session()
+
+	events := h.run(e2eTransports[0].submit, id, "hi", nil)
+	evt, ok := events.first("error")
+	if !ok || evt.ErrorCode != domain.ErrAuthInvalid || !strings.Contains(evt.ErrorMsg, "invalid x-api-key") {
+		t.Errorf("error event = %+v (events %v)", evt, events.types())
+	}
+	if len(h.fake.Calls()) != 1 {
+		t.Errorf("a non-retryable error was retried: %d calls", len(h.fake.Calls()))
+	}
+
+	// The session takes the next prompt once the script answers again.
+	h.fake.Push(provider.FakeStep{Text: "back"})
+	if got := h.run(e2eTransports[0].submit, id, "again", nil).text(); got != "back" {
+		t.Errorf("next turn text = %q", got)
+	}
+}
+
+// historyHasToolResult reports whether a tool_result block in history,
+// for toolUseID when it is set, contains want.
+func historyHasToolResult(history []domain.TranscriptMessage, toolUseID, want string) bool {
+	for _, msg := range history {
+		for _, b := range msg.Blocks {
+			if b.Type == "tool_result" && (toolUseID == "" || b.ToolUseID == toolUseID) && strings.Contains(b.ToolResult, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Long-poll event log
+// ---------------------------------------------------------------------------
+
+// eventLogMax is how many events of one turn are kept for long-poll
+// clients. Older events are dropped and reported as missed.
+const eventLogMax = 20000
+
+// Long-poll waits: the default when ?wait is omitted, and the most a
+// client may ask for.
+const (
+	defaultEventWait = 30 * time.Second
+	maxEventWait     = 60 * time.Second
+)
+
+// sseHeartbeatInterval is how often an idle submit stream sends a comment,
+// so clients can tell a quiet turn from a proxy that buffers the stream.
+var sseHeartbeatInterval = 10 * time.Second
+
+// StreamEvent is one stream event as returned by the long-poll endpoint,
+// numbered from 1 within its turn. SSE streams send the number as the
+// event's id.
+type StreamEvent struct {
+	Seq   int             `json:"seq"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// EventBatch is the response of GET /api/sessions/{id}/events.
+type EventBatch struct {
+	Events []StreamEvent `json:"events"`
+	Next   int           `json:"next"`             // pass as ?after= on the next poll
+	Done   bool          `json:"done"`             // the turn finished and every event was returned
+	Missed bool          `json:"missed,omitempty"` // events after ?after= were dropped from the log
+}
+
+// eventLog holds the events of a session's current or last turn.
+type eventLog struct {
+	mu     sync.Mutex
+	first  int // seq of events[0]
+	events []StreamEvent
+	done   bool
+	wake   chan struct{} // closed and replaced when the log changes
+}
+
+func newEventLog() *eventLog {
+	return &eventLog{first: 1, done: true, wake: make(chan struct{})}
+}
+
+// notify wakes waiting pollers. The caller holds l.mu.
+func (l *eventLog) notify() {
+	close(l.wake)
+	l.wake = make(chan struct{})
+}
+
+// start clears the log for a new turn.
+func (l *eventLog) start() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.first, l.events, l.done = 1, nil, false
+	l.notify()
+}
+
+// finish marks the turn as over.
+func (l *eventLog) finish() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.done = true
+	l.notify()
+}
+
+// record logs an event and passes it to send while holding the log, so a
+// client that switches from the stream to polling can resume by counting
+// the events it already has.
+func (l *eventLog) record(event string, data any, send func(event string, data any)) {
+	raw, err := json.Marshal(data)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err == nil {
+		l.events = append(l.events, StreamEvent{Seq: l.first + len(l.events), Event: event, Data: raw})
+		if drop := len(l.events) - eventLogMax; drop > 0 {
+			l.events = append([]StreamEvent(nil), l.events[drop:]...)
+			l.first += drop
+		}
+		l.notify()
+	}
+	send(event, data)
+}
+
+// last returns the seq of the newest event, so a client can follow the
+// turn from now on.
+func (l *eventLog) last() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.first + len(l.events) - 1
+}
+
+// since returns the events after seq, and a channel closed when more arrive.
+func (l *eventLog) since(after int) (EventBatch, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	batch := EventBatch{Events: []StreamEvent{}, Next: l.first + len(l.events) - 1, Done: l.done}
+	if after < l.first-1 {
+		batch.Missed = true
+		after = l.first - 1
+	}
+	if i := after - l.first + 1; i < len(l.events) {
+		batch.Events = append(batch.Events, l.events[i:]...)
+	}
+	batch.Next = max(batch.Next, after)
+	return batch, l.wake
+}
+
+// eventLogs holds an event log per session. The zero value is ready to use.
+type eventLogs struct {
+	mu   sync.Mutex
+	logs map[string]*eventLog
+}
+
+func (e *eventLogs) get(sessionID string) *eventLog {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.logs == nil {
+		e.logs = make(map[string]*eventLog)
+	}
+	l, ok := e.logs[sessionID]
+	if !ok {
+		l = newEventLog()
+		e.logs[sessionID] = l
+	}
+	return l
+}
+
+// drop forgets a deleted session's log.
+func (e *eventLogs) drop(sessionID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.logs, sessionID)
+}
+
+// teeToEventLog wraps send so the session's events are also kept for
+// long-poll clients.
+func (s *Server) teeToEventLog(sessionID string, send func(event string, data any)) func(event string, data any) {
+	return func(event string, data any) {
+		s.events.get(sessionID).record(event, data, send)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Resumable SSE
+// ---------------------------------------------------------------------------
+
+// streamEventLog writes the events of log after seq after as SSE, each with
+// its sequence number as the id, until the turn is done or the client goes
+// away. Heartbeats let the client notice a proxy that buffers the stream.
+func streamEventLog(w http.ResponseWriter, r *http.Request, flusher http.Flusher, log *eventLog, after int) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	tick := time.NewTicker(sseHeartbeatInterval)
+	defer tick.Stop()
+	for {
+		batch, wake := log.since(after)
+		for _, e := range batch.Events {
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Event, e.Data)
+		}
+		if len(batch.Events) > 0 {
+			flusher.Flush()
+		}
+		after = batch.Next
+		if batch.Done {
+			return
+		}
+		select {
+		case <-wake:
+		case <-tick.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// handleSessionStream resumes the SSE stream of the session's current or
+// last turn after the event named by the Last-Event-ID header, for clients
+// whose submit stream dropped. It answers 410 when those events are no
+// longer buffered.
+func (s *Server) handleSessionStream(w http.ResponseWriter, r *http.Request) {
+	after := 0
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid Last-Event-ID"})
+			return
+		}
+		after = n
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return
+	}
+	log := s.events.get(r.PathValue("id"))
+	if batch, _ := log.since(after); batch.Missed {
+		writeJSON(w, http.StatusGone, map[string]string{"error": fmt.Sprintf("events after %d are no longer buffered", after)})
+		return
+	}
+	streamEventLog(w, r, flusher, log, after)
+}
+
+// ---------------------------------------------------------------------------
+// Long-poll
+// ---------------------------------------------------------------------------
+
+// handleSessionEvents is the long-poll alternative to the submit stream for
+// networks whose proxies buffer SSE, and the replay of a turn left running
+// in the background. It returns the current turn's events after ?after=
+// (or its alias ?since=), waiting up to ?wait= for the first one.
+func (s *Server) handleSessionEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	after := 0
+	param := "after"
+	if !q.Has(param) && q.Has("since") {
+		param = "since"
+	}
+	if v := q.Get(param); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + param})
+			return
+		}
+		after = n
+	}
+	wait := defaultEventWait
+	if v := q.Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid wait"})
+			return
+		}
+		wait = min(d, maxEventWait)
+	}
+
+	log := s.events.get(r.PathValue("id"))
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		batch, wake := log.since(after)
+		if len(batch.Events) > 0 || batch.Done || batch.Missed {
+			writeJSON(w, http.StatusOK, batch)
+			return
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			writeJSON(w, http.StatusOK, batch)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
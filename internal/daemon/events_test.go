@@ -0,0 +1,175 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventLog(t *testing.T) {
+	l := newEventLog()
+	var sent []string
+	send := func(event string, _ any) { sent = append(sent, event) }
+
+	l.start()
+	l.record("delta", map[string]string{"text": "a"}, send)
+	l.record("delta", map[string]string{"text": "b"}, send)
+	if len(sent) != 2 {
+		t.Errorf("sent = %v, want each event passed on", sent)
+	}
+
+	batch, _ := l.since(1)
+	if batch.Done || batch.Next != 2 || len(batch.Events) != 1 || string(batch.Events[0].Data) != `{"text":"b"}` {
+		t.Errorf("since(1) = %+v", batch)
+	}
+	batch, wake := l.since(2)
+	if len(batch.Events) != 0 || batch.Next != 2 {
+		t.Errorf("since(2) = %+v", batch)
+	}
+	l.finish()
+	select {
+	case <-wake:
+	default:
+		t.Error("finish did not wake pollers")
+	}
+	if batch, _ := l.since(2); !batch.Done {
+		t.Errorf("batch after finish = %+v, want done", batch)
+	}
+
+	// A new turn numbers its events from 1 again.
+	l.start()
+	l.record("turn_done", map[string]string{}, send)
+	if batch, _ := l.since(0); len(batch.Events) != 1 || batch.Events[0].Seq != 1 || batch.Done {
+		t.Errorf("new turn = %+v", batch)
+	}
+}
+
+func TestEventLog_missed(t *testing.T) {
+	l := newEventLog()
+	l.start()
+	for range eventLogMax + 5 {
+		l.record("delta", map[string]string{"text": "x"}, func(string, any) {})
+	}
+	batch, _ := l.since(0)
+	if !batch.Missed || len(batch.Events) != eventLogMax || batch.Events[0].Seq != 6 || batch.Next != eventLogMax+5 {
+		t.Errorf("batch: missed=%v events=%d first=%d next=%d", batch.Missed, len(batch.Events), batch.Events[0].Seq, batch.Next)
+	}
+}
+
+func TestHandleSessionStream(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	get := func(lastEventID string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := newAuthedRequest(srv, "GET", "/api/sessions/s1/stream", nil)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	log := srv.events.get("s1")
+	log.start()
+	send := srv.teeToEventLog("s1", func(string, any) {})
+	send("delta", map[string]string{"text": "Hel"})
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		send("delta", map[string]string{"text": "lo"})
+		send("turn_done", map[string]string{"stop_reason": "end_turn"})
+		log.finish()
+	}()
+
+	// Resuming after event 1 replays the rest and follows the turn to its end.
+	w := get("1")
+	want := "id: 2\nevent: delta\ndata: {\"text\":\"lo\"}\n\n" +
+		"id: 3\nevent: turn_done\ndata: {\"stop_reason\":\"end_turn\"}\n\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("resumed stream = %d %q", w.Code, w.Body.String())
+	}
+	if got := get("").Body.String(); !strings.HasPrefix(got, "id: 1\n") {
+		t.Errorf("stream without Last-Event-ID = %q, want every event", got)
+	}
+
+	log.start()
+	for range eventLogMax + 5 {
+		send("delta", map[string]string{"text": "x"})
+	}
+	log.finish()
+	if w := get("2"); w.Code != http.StatusGone {
+		t.Errorf("dropped events: expected 410, got %d", w.Code)
+	}
+	if w := get("x"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad Last-Event-ID: expected 400, got %d", w.Code)
+	}
+}
+
+func TestHandleSessionEvents(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	get := func(query string) (int, EventBatch) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/sessions/s1/events"+query, nil))
+		var batch EventBatch
+		_ = json.NewDecoder(w.Body).Decode(&batch)
+		return w.Code, batch
+	}
+
+	// No turn yet: nothing to wait for.
+	if code, batch := get("?after=0"); code != http.StatusOK || !batch.Done || len(batch.Events) != 0 {
+		t.Errorf("idle session: %d %+v", code, batch)
+	}
+
+	log := srv.events.get("s1")
+	log.start()
+	send := srv.teeToEventLog("s1", func(string, any) {})
+	send("delta", map[string]string{"text": "Hi"})
+
+	if _, batch := get("?after=0&wait=1s"); len(batch.Events) != 1 || batch.Events[0].Event != "delta" || batch.Next != 1 {
+		t.Errorf("first poll = %+v", batch)
+	}
+
+	// A poll with nothing new waits for the next event.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		send("turn_done", map[string]string{"stop_reason": "end_turn"})
+		log.finish()
+	}()
+	start := time.Now()
+	_, batch := get("?after=1&wait=5s")
+	if len(batch.Events) != 1 || batch.Events[0].Event != "turn_done" {
+		t.Errorf("waiting poll = %+v", batch)
+	}
+	if time.Since(start) > 4*time.Second {
+		t.Error("poll was not woken by the new event")
+	}
+	if _, batch := get("?after=2&wait=5s"); !batch.Done || len(batch.Events) != 0 {
+		t.Errorf("final poll = %+v", batch)
+	}
+
+	// ?since= is an alias of ?after= for replaying a background turn.
+	if _, batch := get("?since=1&wait=1s"); len(batch.Events) != 1 || batch.Events[0].Event != "turn_done" {
+		t.Errorf("since poll = %+v", batch)
+	}
+
+	// A running turn with nothing new returns empty when the wait is over.
+	log.start()
+	if _, batch := get("?after=0&wait=10ms"); batch.Done || len(batch.Events) != 0 {
+		t.Errorf("timed-out poll = %+v", batch)
+	}
+
+	for _, q := range []string{"?after=-1", "?after=x", "?since=x", "?wait=soon"} {
+		if code, _ := get(q); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, code)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Fallback address
+// ---------------------------------------------------------------------------
+
+// failoverTransport sends requests for the primary host to a fallback host
+// when the primary cannot be reached, such as a second hub the same nodes
+// register with. It stays on whichever host last answered and moves only
+// when a connection cannot be made, so no request is sent twice.
+type failoverTransport struct {
+	base              http.RoundTripper
+	primary, fallback *url.URL
+
+	mu     sync.Mutex
+	active *url.URL
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.primary.Host && req.URL.Host != t.fallback.Host {
+		return t.base.RoundTrip(req)
+	}
+	t.mu.Lock()
+	active := t.active
+	t.mu.Unlock()
+	other := t.fallback
+	if active == t.fallback {
+		other = t.primary
+	}
+
+	resp, err := t.base.RoundTrip(retarget(req, active))
+	if err == nil || !isDialError(err) {
+		return resp, err
+	}
+	retry := retarget(req, other)
+	if req.Body != nil && req.Body != http.NoBody {
+		if req.GetBody == nil {
+			return nil, err
+		}
+		body, bodyErr := req.GetBody()
+		if bodyErr != nil {
+			return nil, err
+		}
+		retry.Body = body
+	}
+	resp, retryErr := t.base.RoundTrip(retry)
+	if retryErr != nil {
+		return nil, err
+	}
+	t.mu.Lock()
+	t.active = other
+	t.mu.Unlock()
+	return resp, nil
+}
+
+// activeHost returns the host requests for host currently go to.
+func (t *failoverTransport) activeHost(host string) string {
+	if host != t.primary.Host && host != t.fallback.Host {
+		return host
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.active.Host
+}
+
+// retarget returns a copy of req addressed to target's scheme and host.
+func retarget(req *http.Request, target *url.URL) *http.Request {
+	r := req.Clone(req.Context())
+	r.URL.Scheme = target.Scheme
+	r.URL.Host = target.Host
+	r.Host = target.Host
+	return r
+}
+
+// isDialError reports whether err means no connection was made, so the
+// request never reached the server.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// SetFallbackBaseURL sets a second address that requests go to while the
+// base URL cannot be reached. Both must serve the same API, such as two
+// hubs a node registers with; the auth token is sent to both. A fallback
+// without a scheme is taken as http://host:port.
+func (c *DaemonClient) SetFallbackBaseURL(fallback string) error {
+	if c.socketPath != "" {
+		return errors.New("a unix socket client has no fallback")
+	}
+	if !strings.Contains(fallback, "://") {
+		fallback = "http://" + fallback
+	}
+	primary, err := url.Parse(c.baseURL)
+	if err != nil {
+		return fmt.Errorf("parsing base URL: %w", err)
+	}
+	fb, err := url.Parse(fallback)
+	if err != nil || fb.Host == "" || (fb.Scheme != "http" && fb.Scheme != "https") {
+		return fmt.Errorf("invalid fallback URL %q (want host:port or http(s)://host[:port])", fallback)
+	}
+	base := c.transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tr := &failoverTransport{base: base, primary: primary, fallback: fb, active: primary}
+	c.transport = tr
+	c.httpClient.Transport = tr
+	return nil
+}
@@ -3,8 +3,10 @@ package daemon
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/batalabs/muxd/internal/agent"
@@ -280,6 +282,56 @@ func TestServer_CancelRunningAgent(t *testing.T) {
 	})
 }
 
+func TestServer_Stop(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	srv.SetAgentFactory(stubAgentFactory())
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	stop := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "POST", "/api/sessions/"+sess.ID+"/stop", nil))
+		return w
+	}
+
+	if w := stop(); w.Code != http.StatusNotFound {
+		t.Errorf("no agent: expected 404, got %d", w.Code)
+	}
+	if _, err := srv.getOrCreateAgent(sess.ID); err != nil {
+		t.Fatalf("getOrCreateAgent: %v", err)
+	}
+	if w := stop(); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "stopping") {
+		t.Errorf("stop: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestServer_Steer(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	srv.SetAgentFactory(stubAgentFactory())
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	steer := func(body string) int {
+		req := newAuthedRequest(srv, "POST", "/api/sessions/"+sess.ID+"/steer", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := steer(`{"text":"focus on the parser"}`); code != http.StatusNotFound {
+		t.Errorf("no agent: expected 404, got %d", code)
+	}
+	if _, err := srv.getOrCreateAgent(sess.ID); err != nil {
+		t.Fatalf("getOrCreateAgent: %v", err)
+	}
+	if code := steer(`{"text":"focus on the parser"}`); code != http.StatusConflict {
+		t.Errorf("idle agent: expected 409, got %d", code)
+	}
+	if code := steer(`{"text":"  "}`); code != http.StatusBadRequest {
+		t.Errorf("empty note: expected 400, got %d", code)
+	}
+}
+
 func TestServer_ConfigSetDisabledTools(t *testing.T) {
 	srv, st := newTestServer(t)
 	mux := http.NewServeMux()
@@ -337,3 +389,49 @@ func TestServer_ConfigSetDisabledTools(t *testing.T) {
 		t.Errorf("expected status 'ok', got %q", resp["status"])
 	}
 }
+
+func TestServer_Compact(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	srv.SetAgentFactory(stubAgentFactory())
+	compact := func(id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "POST", "/api/sessions/"+id+"/compact", nil))
+		return w
+	}
+
+	if w := compact("missing"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", w.Code)
+	}
+
+	short, _ := st.CreateSession("/tmp/test", "model-a")
+	_ = st.AppendMessage(short.ID, "user", "hi", 0)
+	_ = st.AppendMessage(short.ID, "assistant", "hello", 0)
+	if w := compact(short.ID); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"compacted":0`) {
+		t.Errorf("short session: %d %s", w.Code, w.Body.String())
+	}
+
+	long, _ := st.CreateSession("/tmp/test", "model-a")
+	for i := 0; i < 40; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		_ = st.AppendMessage(long.ID, role, fmt.Sprintf("m%d", i), 0)
+	}
+	w := compact(long.ID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("compact: %d %s", w.Code, w.Body.String())
+	}
+	var res CompactResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decoding: %v", err)
+	}
+	if res.Compacted != 40-2-agent.CompactKeepTail {
+		t.Errorf("expected %d compacted, got %d", 40-2-agent.CompactKeepTail, res.Compacted)
+	}
+	if _, cutoff, err := st.LatestCompaction(long.ID); err != nil || cutoff != 40-agent.CompactKeepTail {
+		t.Errorf("LatestCompaction cutoff = %d, %v", cutoff, err)
+	}
+}
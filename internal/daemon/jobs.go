@@ -0,0 +1,448 @@
+package daemon
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// ---------------------------------------------------------------------------
+// Job queue
+// ---------------------------------------------------------------------------
+
+// jobProject is the project path of the sessions jobs run in.
+const jobProject = "__job__"
+
+// jobLogResultMax caps how much of a tool result goes into a job's log.
+const jobLogResultMax = 500
+
+// jobQueue runs queued jobs one at a time in the background. The zero
+// value accepts notifications but runs nothing until start.
+type jobQueue struct {
+	mu      sync.Mutex
+	wake    chan struct{} // buffered; a send means the queue may have work
+	stop    chan struct{}
+	running map[string]*runningJob
+}
+
+// runningJob is a claimed job. ag is nil until its agent is created.
+type runningJob struct {
+	ag        *agent.Service
+	cancelled bool
+}
+
+// notify wakes the runner after a job was queued.
+func (q *jobQueue) notify() {
+	q.mu.Lock()
+	wake := q.wake
+	q.mu.Unlock()
+	if wake == nil {
+		return
+	}
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// track records a claimed job, and then its agent once it exists, so it
+// can be cancelled. A job cancelled before its agent existed is stopped
+// right away.
+func (q *jobQueue) track(id string, ag *agent.Service) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.running == nil {
+		q.running = make(map[string]*runningJob)
+	}
+	job, ok := q.running[id]
+	if !ok {
+		job = &runningJob{}
+		q.running[id] = job
+	}
+	job.ag = ag
+	if job.cancelled && ag != nil {
+		ag.Cancel()
+	}
+}
+
+// untrack forgets a finished job and reports whether it was cancelled.
+func (q *jobQueue) untrack(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.running[id]
+	delete(q.running, id)
+	return ok && job.cancelled
+}
+
+// cancel stops a running job's agent and reports whether the job is
+// running here.
+func (q *jobQueue) cancel(id string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job, ok := q.running[id]
+	if !ok {
+		return false
+	}
+	job.cancelled = true
+	if job.ag != nil {
+		job.ag.Cancel()
+	}
+	return true
+}
+
+// startJobs fails jobs a previous daemon left running and starts the
+// runner.
+func (s *Server) startJobs() {
+	if s.store == nil {
+		return
+	}
+	if n, err := s.store.FailInterruptedJobs(); err != nil {
+		s.logf("jobs: %v", err)
+	} else if n > 0 {
+		s.logf("jobs: %d interrupted by the last shutdown marked failed", n)
+	}
+	s.jobs.mu.Lock()
+	s.jobs.wake = make(chan struct{}, 1)
+	s.jobs.stop = make(chan struct{})
+	wake, stop := s.jobs.wake, s.jobs.stop
+	s.jobs.mu.Unlock()
+
+	go func() {
+		for {
+			for s.runNextJob() {
+			}
+			select {
+			case <-wake:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// stopJobs stops the runner and cancels the jobs it is running. They are
+// recorded as cancelled once their agents return.
+func (s *Server) stopJobs() {
+	s.jobs.mu.Lock()
+	if s.jobs.stop != nil {
+		close(s.jobs.stop)
+		s.jobs.stop = nil
+	}
+	for _, job := range s.jobs.running {
+		job.cancelled = true
+		if job.ag != nil {
+			job.ag.Cancel()
+		}
+	}
+	s.jobs.mu.Unlock()
+}
+
+// runNextJob runs the oldest queued job and reports whether there was one.
+func (s *Server) runNextJob() bool {
+	job, err := s.store.ClaimJob()
+	if err != nil {
+		s.logf("jobs: claim: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+	s.jobs.track(job.ID, nil)
+	s.runJob(job)
+	return true
+}
+
+// runJob runs a claimed job to the end and records how it went. Tool calls,
+// retries, and errors go to the job's log.
+func (s *Server) runJob(job *store.Job) {
+	s.logf("job %s started", job.ID)
+	logLine := func(format string, args ...any) {
+		if err := s.store.AppendJobLog(job.ID, fmt.Sprintf(format, args...)+"\n"); err != nil {
+			s.logf("job %s: log: %v", job.ID, err)
+		}
+	}
+	disabled, err := s.jobDisabledTools(job.Tools)
+	if err != nil {
+		logLine("error: %v", err)
+		s.finishJob(job.ID, store.JobFailed, "", err.Error())
+		return
+	}
+
+	result, turnErr, err := s.runHeadlessAgent(jobProject, job.Prompt, disabled,
+		func(sess *domain.Session, ag *agent.Service) {
+			if err := s.store.SetJobSession(job.ID, sess.ID); err != nil {
+				s.logf("job %s: %v", job.ID, err)
+			}
+			s.jobs.track(job.ID, ag)
+		},
+		func(evt agent.Event) {
+			switch evt.Kind {
+			case agent.EventToolStart:
+				input, _ := json.Marshal(evt.ToolInput)
+				logLine("tool %s %s", evt.ToolName, input)
+			case agent.EventToolDone:
+				status := "ok"
+				if evt.ToolIsError {
+					status = "error"
+				}
+				out := evt.ToolResult
+				if len(out) > jobLogResultMax {
+					out = out[:jobLogResultMax] + "..."
+				}
+				logLine("  -> %s: %s", status, strings.ReplaceAll(out, "\n", "\n     "))
+			case agent.EventRetrying:
+				logLine("retrying (attempt %d): %s", evt.RetryAttempt, evt.RetryMessage)
+			case agent.EventApprovalRequired:
+				logLine("denied %s: approval required", evt.ToolName)
+			case agent.EventError:
+				if evt.Err != nil {
+					logLine("error: %v", evt.Err)
+				}
+			}
+		})
+	cancelled := s.jobs.untrack(job.ID)
+
+	status, errText := store.JobSucceeded, ""
+	switch {
+	case cancelled:
+		status = store.JobCancelled
+	case err != nil:
+		status, errText = store.JobFailed, err.Error()
+		logLine("error: %v", err)
+	case turnErr != nil:
+		status, errText = store.JobFailed, turnErr.Error()
+	}
+	s.finishJob(job.ID, status, result, errText)
+}
+
+func (s *Server) finishJob(id, status, result, errText string) {
+	if err := s.store.FinishJob(id, status, result, errText); err != nil {
+		s.logf("job %s: finish: %v", id, err)
+		return
+	}
+	s.logf("job %s %s", id, status)
+	switch status {
+	case store.JobSucceeded:
+		s.notifyJob("Job "+id, "", false, result)
+	case store.JobFailed:
+		s.notifyJob("Job "+id, "", true, errText)
+	}
+}
+
+// jobDisabledTools turns a job's allowed tools into the set to disable.
+// An empty list keeps every tool; unknown names are an error.
+func (s *Server) jobDisabledTools(allowed []string) (map[string]bool, error) {
+	if len(allowed) == 0 {
+		return nil, nil
+	}
+	known := tools.ToolNames()
+	if s.mcpManager != nil {
+		known = append(known, s.mcpManager.ToolNames()...)
+	}
+	allow := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		allow[name] = true
+	}
+	disabled := make(map[string]bool)
+	for _, name := range known {
+		if allow[name] {
+			delete(allow, name)
+		} else {
+			disabled[name] = true
+		}
+	}
+	if len(allow) > 0 {
+		unknown := make([]string, 0, len(allow))
+		for name := range allow {
+			unknown = append(unknown, name)
+		}
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown tools: %s", strings.Join(unknown, ", "))
+	}
+	return disabled, nil
+}
+
+// ---------------------------------------------------------------------------
+// Headless agent runs
+// ---------------------------------------------------------------------------
+
+// headlessResultMax caps the reply text kept from a headless run.
+const headlessResultMax = 50 * 1024
+
+// runHeadlessAgent runs prompt to the end in a new session under project,
+// with nobody to answer ask_user or approve tool calls. disabled adds to
+// the tools the user disabled. onStart, if set, gets the session and agent
+// before the turn starts; onEvent, if set, sees every event. It returns
+// the reply text, with any turn error appended, and the turn's last error.
+// err is set when the run could not start.
+func (s *Server) runHeadlessAgent(project, prompt string, disabled map[string]bool, onStart func(*domain.Session, *agent.Service), onEvent agent.EventFunc) (result string, turnErr, err error) {
+	if s.newAgent == nil {
+		return "", nil, fmt.Errorf("no agent factory configured")
+	}
+	st, err := s.storeForProject(project)
+	if err != nil {
+		return "", nil, fmt.Errorf("opening project db: %w", err)
+	}
+	sess, err := st.CreateSession(project, s.modelID)
+	if err != nil {
+		return "", nil, fmt.Errorf("creating session: %w", err)
+	}
+	s.rememberSession(sess.ID, st)
+	s.emitSessionCreated(sess)
+
+	s.mu.Lock()
+	ag := s.newAgent(s.apiKey, s.modelID, s.modelLabel, st, sess, s.provider)
+	s.configureAgent(ag)
+	off := map[string]bool{"ask_user": true}
+	if prefs := s.agentPrefs(); prefs != nil {
+		for name := range prefs.DisabledToolsSet() {
+			off[name] = true
+		}
+	}
+	s.mu.Unlock()
+	defer ag.Close()
+	for name, v := range disabled {
+		off[name] = off[name] || v
+	}
+	ag.SetDisabledTools(off)
+	if onStart != nil {
+		onStart(sess, ag)
+	}
+
+	var out strings.Builder
+	ag.Submit(prompt, func(evt agent.Event) {
+		switch evt.Kind {
+		case agent.EventDelta:
+			if out.Len() < headlessResultMax {
+				out.WriteString(evt.DeltaText)
+			}
+		case agent.EventError:
+			if evt.Err != nil {
+				turnErr = evt.Err
+				out.WriteString("\nError: " + evt.Err.Error())
+			}
+		case agent.EventApprovalRequired:
+			// Nobody is around to approve tool calls.
+			evt.ApprovalResponse <- agent.ApprovalDeny
+		}
+		if onEvent != nil {
+			onEvent(evt)
+		}
+	})
+
+	result = out.String()
+	if len(result) > headlessResultMax {
+		result = result[:headlessResultMax] + "\n... (truncated at 50KB)"
+	}
+	return result, turnErr, nil
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+// handleCreateJob queues a job: POST /api/jobs {"prompt": ..., "tools": [...]}.
+func (s *Server) handleCreateJob(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Prompt string   `json:"prompt"`
+		Tools  []string `json:"tools"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if strings.TrimSpace(req.Prompt) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "prompt is required"})
+		return
+	}
+	if _, err := s.jobDisabledTools(req.Tools); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	job, err := s.store.CreateJob(req.Prompt, req.Tools)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.logf("job %s queued", job.ID)
+	s.jobs.notify()
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleListJobs returns the newest jobs: GET /api/jobs?limit=N.
+func (s *Server) handleListJobs(w http.ResponseWriter, r *http.Request) {
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	jobs, err := s.store.ListJobs(limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if jobs == nil {
+		jobs = []store.Job{}
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// handleGetJob returns a job with its log.
+func (s *Server) handleGetJob(w http.ResponseWriter, r *http.Request) {
+	job, err := s.store.GetJob(r.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleCancelJob cancels a queued job, or stops a running one. A running
+// job is recorded as cancelled once its agent stops.
+func (s *Server) handleCancelJob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ok, err := s.store.CancelQueuedJob(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if ok {
+		s.logf("job %s cancelled", id)
+		writeJSON(w, http.StatusOK, map[string]string{"status": store.JobCancelled})
+		return
+	}
+	if s.jobs.cancel(id) {
+		s.logf("job %s cancelling", id)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "cancelling"})
+		return
+	}
+	job, err := s.store.GetJob(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusConflict, map[string]string{"error": "job already " + job.Status})
+}
@@ -0,0 +1,111 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func TestJobs(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(func(apiKey, modelID, modelLabel string, st *store.Store, sess *domain.Session, _ provider.Provider) *agent.Service {
+		return agent.NewService(apiKey, modelID, modelLabel, st, sess, echoProvider{})
+	})
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	if _, err := client.CreateJob(" ", nil); err == nil || !strings.Contains(err.Error(), "prompt is required") {
+		t.Errorf("empty prompt: %v", err)
+	}
+	if _, err := client.CreateJob("hi", []string{"file_read", "no_such_tool"}); err == nil || !strings.Contains(err.Error(), "unknown tools: no_such_tool") {
+		t.Errorf("unknown tool: %v", err)
+	}
+
+	job, err := client.CreateJob("say hi", []string{"file_read"})
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	if job.Status != store.JobQueued {
+		t.Errorf("status = %s, want queued", job.Status)
+	}
+	later, _ := client.CreateJob("never runs", nil)
+	if status, err := client.CancelJob(later.ID); err != nil || status != store.JobCancelled {
+		t.Errorf("CancelJob(queued) = %q, %v", status, err)
+	}
+
+	if !srv.runNextJob() {
+		t.Fatal("runNextJob found nothing to run")
+	}
+	if srv.runNextJob() {
+		t.Error("ran a cancelled job")
+	}
+
+	got, err := client.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if got.Status != store.JobSucceeded || got.Result != "hi" || got.SessionID == "" || got.FinishedAt == nil {
+		t.Errorf("finished job = %+v", got)
+	}
+	if sess, err := st.GetSession(got.SessionID); err != nil || sess.ProjectPath != jobProject {
+		t.Errorf("job session = %+v, %v", sess, err)
+	}
+	if _, err := client.CancelJob(job.ID); err == nil || !strings.Contains(err.Error(), "already succeeded") {
+		t.Errorf("CancelJob(finished) = %v", err)
+	}
+	if _, err := client.GetJob("missing"); err == nil || !strings.Contains(err.Error(), "job not found") {
+		t.Errorf("GetJob(missing) = %v", err)
+	}
+
+	jobs, err := client.ListJobs(10)
+	if err != nil || len(jobs) != 2 {
+		t.Fatalf("ListJobs = %+v, %v", jobs, err)
+	}
+}
+
+func TestJobDisabledTools(t *testing.T) {
+	srv, _ := newTestServer(t)
+	if disabled, err := srv.jobDisabledTools(nil); err != nil || disabled != nil {
+		t.Errorf("no tool list = %v, %v; want nothing disabled", disabled, err)
+	}
+	disabled, err := srv.jobDisabledTools([]string{"file_read", "grep"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if disabled["file_read"] || disabled["grep"] || !disabled["bash"] || !disabled["file_write"] {
+		t.Errorf("disabled = %v", disabled)
+	}
+}
+
+func TestJobQueue_cancel(t *testing.T) {
+	var q jobQueue
+	if q.cancel("j1") {
+		t.Error("cancelled a job that is not running")
+	}
+	q.notify() // no runner: must not block
+
+	// A job cancelled before its agent exists is still reported cancelled.
+	q.track("j1", nil)
+	if !q.cancel("j1") {
+		t.Fatal("cancel of a claimed job failed")
+	}
+	q.track("j1", agent.NewService("", "", "", nil, nil, nil))
+	if !q.untrack("j1") {
+		t.Error("untrack lost the cancellation")
+	}
+	q.track("j2", nil)
+	if q.untrack("j2") {
+		t.Error("job reported cancelled without a cancel")
+	}
+}
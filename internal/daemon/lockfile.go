@@ -13,11 +13,13 @@ import (
 
 // LockfileData is the JSON structure stored in the daemon lockfile.
 type LockfileData struct {
-	PID       int       `json:"pid"`
-	Port      int       `json:"port"`
-	BindAddr  string    `json:"bind_addr,omitempty"`
-	Token     string    `json:"token,omitempty"`
-	StartedAt time.Time `json:"started_at"`
+	PID        int       `json:"pid"`
+	Port       int       `json:"port"`
+	BindAddr   string    `json:"bind_addr,omitempty"`
+	SocketPath string    `json:"socket_path,omitempty"`
+	Project    string    `json:"project,omitempty"` // project root for per-project daemons
+	Token      string    `json:"token,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
 }
 
 // LockfileName is the filename of the daemon lockfile.
@@ -32,24 +34,42 @@ func LockfilePath() (string, error) {
 	return filepath.Join(dir, LockfileName), nil
 }
 
+// ProjectLockfilePath returns the lockfile path for a daemon scoped to the
+// given project root. Each project gets its own lockfile so that daemons for
+// different projects do not shadow each other.
+func ProjectLockfilePath(root string) (string, error) {
+	dir, err := config.ProjectDataDir(root)
+	if err != nil {
+		return "", fmt.Errorf("lockfile path: %w", err)
+	}
+	return filepath.Join(dir, LockfileName), nil
+}
+
 // WriteLockfile writes the daemon lockfile with the current PID, port, bind address, and timestamp.
-func WriteLockfile(port int, token, bindAddr string) error {
+// socketPath is set instead of a port when the daemon listens on a unix socket.
+func WriteLockfile(port int, token, bindAddr, socketPath string) error {
 	p, err := LockfilePath()
 	if err != nil {
 		return err
 	}
-	data := LockfileData{
-		PID:       os.Getpid(),
-		Port:      port,
-		BindAddr:  bindAddr,
-		Token:     token,
-		StartedAt: time.Now(),
-	}
+	return WriteLockfileAt(p, LockfileData{
+		Port:       port,
+		BindAddr:   bindAddr,
+		SocketPath: socketPath,
+		Token:      token,
+	})
+}
+
+// WriteLockfileAt writes data to the lockfile at path, filling in the
+// current PID and start time.
+func WriteLockfileAt(path string, data LockfileData) error {
+	data.PID = os.Getpid()
+	data.StartedAt = time.Now()
 	b, err := json.MarshalIndent(data, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling lockfile: %w", err)
 	}
-	return os.WriteFile(p, b, 0o600)
+	return os.WriteFile(path, b, 0o600)
 }
 
 // ReadLockfile reads and parses the daemon lockfile.
@@ -59,7 +79,12 @@ func ReadLockfile() (*LockfileData, error) {
 	if err != nil {
 		return nil, err
 	}
-	b, err := os.ReadFile(p)
+	return ReadLockfileAt(p)
+}
+
+// ReadLockfileAt reads and parses the lockfile at path.
+func ReadLockfileAt(path string) (*LockfileData, error) {
+	b, err := os.ReadFile(path)
 	if err != nil {
 		return nil, fmt.Errorf("reading lockfile: %w", err)
 	}
@@ -76,7 +101,12 @@ func RemoveLockfile() error {
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+	return RemoveLockfileAt(p)
+}
+
+// RemoveLockfileAt removes the lockfile at path. A missing file is not an error.
+func RemoveLockfileAt(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("removing lockfile: %w", err)
 	}
 	return nil
@@ -89,12 +119,21 @@ func IsLockfileStale(lf *LockfileData) bool {
 		return true
 	}
 	// PID is alive -- verify with HTTP health check
+	if lf.SocketPath != "" {
+		client := &http.Client{Timeout: 2 * time.Second, Transport: unixSocketTransport(lf.SocketPath)}
+		resp, err := client.Get(unixSocketBaseURL + "/api/health")
+		if err != nil {
+			return true
+		}
+		resp.Body.Close()
+		return resp.StatusCode != http.StatusOK
+	}
 	host := lf.BindAddr
-	if host == "" || host == "0.0.0.0" {
+	if IsWildcardAddr(host) {
 		host = "localhost" // Connect to localhost for health checks
 	}
 	client := &http.Client{Timeout: 2 * time.Second}
-	resp, err := client.Get(fmt.Sprintf("http://%s:%d/api/health", host, lf.Port))
+	resp, err := client.Get(BaseURL(host, lf.Port) + "/api/health")
 	if err != nil {
 		return true
 	}
@@ -101,3 +101,40 @@ func TestIsLockfileStale(t *testing.T) {
 		}
 	})
 }
+
+func TestWriteAndReadLockfileAt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockfileName)
+	err := WriteLockfileAt(path, LockfileData{
+		Port:    4100,
+		Project: "/home/user/proj",
+		Token:   "tok",
+	})
+	if err != nil {
+		t.Fatalf("WriteLockfileAt: %v", err)
+	}
+
+	lf, err := ReadLockfileAt(path)
+	if err != nil {
+		t.Fatalf("ReadLockfileAt: %v", err)
+	}
+	if lf.PID != os.Getpid() {
+		t.Errorf("PID = %d, want %d", lf.PID, os.Getpid())
+	}
+	if lf.Port != 4100 || lf.Project != "/home/user/proj" || lf.Token != "tok" {
+		t.Errorf("unexpected lockfile data: %+v", lf)
+	}
+	if lf.StartedAt.IsZero() {
+		t.Error("expected StartedAt to be set")
+	}
+
+	if err := RemoveLockfileAt(path); err != nil {
+		t.Fatalf("RemoveLockfileAt: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("lockfile still exists after remove")
+	}
+	// Removing again is not an error.
+	if err := RemoveLockfileAt(path); err != nil {
+		t.Errorf("second RemoveLockfileAt: %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/publish"
+	"github.com/batalabs/muxd/internal/push"
+	"github.com/batalabs/muxd/internal/redact"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// Endpoints for the mobile app: smaller payloads than the desktop API,
+// pages counted from the newest message, and push notifications for turns
+// that finish or need an answer while the app is in the background.
+
+// SessionSummary is a session as listed by the mobile app.
+type SessionSummary struct {
+	ID           string    `json:"id"`
+	Title        string    `json:"title"`
+	Project      string    `json:"project"` // last element of the project path
+	MessageCount int       `json:"message_count"`
+	Preview      string    `json:"preview,omitempty"` // start of the last text message
+	Running      bool      `json:"running"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// MobileMessage is a transcript message flattened for the mobile app.
+type MobileMessage struct {
+	Index int      `json:"index"` // position in the session, from 0
+	Role  string   `json:"role"`
+	Text  string   `json:"text,omitempty"`
+	HTML  string   `json:"html,omitempty"`  // with ?format=html
+	Tools []string `json:"tools,omitempty"` // tools the message called
+}
+
+// MobileMessagePage is one page of messages, oldest first.
+type MobileMessagePage struct {
+	Total    int             `json:"total"`
+	Offset   int             `json:"offset"` // index of the first message in the page
+	Next     int             `json:"next"`   // pass as ?after= to fetch newer messages
+	Messages []MobileMessage `json:"messages"`
+}
+
+const (
+	mobileSessionLimit = 20
+	mobilePageSize     = 30
+	mobilePreviewLen   = 140
+)
+
+// previewText collapses whitespace and cuts s to n runes.
+func previewText(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// lastTextPreview returns the start of the newest message in the session
+// that has text, skipping trailing tool results.
+func (s *Server) lastTextPreview(sessionID string, count int) string {
+	const lookback = 5
+	msgs, err := s.sessionStore(sessionID).GetMessagesPage(sessionID, max(0, count-lookback), lookback)
+	if err != nil {
+		return ""
+	}
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if text := strings.TrimSpace(msgs[i].TextContent()); text != "" {
+			return previewText(redact.Secrets(text), mobilePreviewLen)
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+// handleMobileSessions lists recent sessions as summaries. Accepts
+// ?project= and ?limit= like /api/sessions.
+func (s *Server) handleMobileSessions(w http.ResponseWriter, r *http.Request) {
+	limit := mobileSessionLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid limit"})
+			return
+		}
+		limit = n
+	}
+	sessions, err := s.listSessions(r.URL.Query().Get("project"), limit)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	out := make([]SessionSummary, 0, len(sessions))
+	for _, sess := range sessions {
+		sum := SessionSummary{
+			ID:           sess.ID,
+			Title:        sess.Title,
+			MessageCount: sess.MessageCount,
+			UpdatedAt:    sess.UpdatedAt,
+		}
+		if sess.ProjectPath != "" {
+			parts := strings.FieldsFunc(sess.ProjectPath, func(r rune) bool { return r == '/' || r == '\\' })
+			if len(parts) > 0 {
+				sum.Project = parts[len(parts)-1]
+			}
+		}
+		if sess.MessageCount > 0 {
+			sum.Preview = s.lastTextPreview(sess.ID, sess.MessageCount)
+		}
+		s.mu.Lock()
+		if ag, ok := s.agents[sess.ID]; ok {
+			sum.Running = ag.IsRunning()
+		}
+		s.mu.Unlock()
+		out = append(out, sum)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sessions": out})
+}
+
+// handleMobileMessages returns a page of a session's messages counted back
+// from ?before= (default: the end), so the app loads the newest page first
+// and scrolls back with the previous page's offset. ?format=html adds
+// rendered, redacted HTML to each message.
+func (s *Server) handleMobileMessages(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	q := r.URL.Query()
+	st := s.sessionStore(id)
+	if _, err := st.GetSession(id); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+	total, err := st.CountMessages(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	before, after, limit := total, 0, mobilePageSize
+	for name, dst := range map[string]*int{"before": &before, "after": &after, "limit": &limit} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + name})
+			return
+		}
+		*dst = n
+	}
+	html := false
+	switch q.Get("format") {
+	case "", "text":
+	case "html":
+		html = true
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid format (use text or html)"})
+		return
+	}
+
+	// ?before= pages back from the newest message; ?after= fetches what
+	// arrived since a client's last sync, oldest first.
+	var offset, count int
+	switch {
+	case q.Has("before") && q.Has("after"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "use before or after, not both"})
+		return
+	case q.Has("after"):
+		offset = min(after, total)
+		count = min(limit, total-offset)
+	default:
+		before = min(before, total)
+		offset = max(0, before-limit)
+		count = before - offset
+	}
+	var msgs []domain.TranscriptMessage
+	if count > 0 { // a limit of 0 would return every message
+		msgs, err = st.GetMessagesPage(id, offset, count)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+
+	page := MobileMessagePage{Total: total, Offset: offset, Next: offset + len(msgs), Messages: []MobileMessage{}}
+	for i, m := range msgs {
+		if m.Role == "system" {
+			continue
+		}
+		mm := MobileMessage{Index: offset + i, Role: m.Role, Text: redact.Secrets(m.TextContent())}
+		for _, b := range m.Blocks {
+			if b.Type == "tool_use" {
+				mm.Tools = append(mm.Tools, b.ToolName)
+			}
+		}
+		// Messages that only carry tool results have nothing to show.
+		if mm.Text == "" && len(mm.Tools) == 0 {
+			continue
+		}
+		if html {
+			mm.HTML = publish.RenderMessage(m)
+		}
+		page.Messages = append(page.Messages, mm)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// pushDeviceRequest registers a device, or updates one registered with the
+// same token. The app re-sends it with foreground set when it opens and
+// cleared when it goes to the background.
+type pushDeviceRequest struct {
+	Platform   string `json:"platform"` // apns or fcm
+	Token      string `json:"token"`
+	Name       string `json:"name,omitempty"`
+	Foreground bool   `json:"foreground"`
+}
+
+func (s *Server) handleRegisterPushDevice(w http.ResponseWriter, r *http.Request) {
+	var req pushDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	dev, err := s.store.SavePushDevice(req.Platform, req.Token, req.Name, req.Foreground)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, dev)
+}
+
+func (s *Server) handleListPushDevices(w http.ResponseWriter, r *http.Request) {
+	devices, err := s.store.ListPushDevices()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if devices == nil {
+		devices = []store.PushDevice{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"devices": devices})
+}
+
+func (s *Server) handleDeletePushDevice(w http.ResponseWriter, r *http.Request) {
+	ok, err := s.store.DeletePushDevice(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "device not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// ---------------------------------------------------------------------------
+// Push notifications
+// ---------------------------------------------------------------------------
+
+// SetPushSender sets the sender used for a push platform (store.PushPlatformAPNs
+// or store.PushPlatformFCM), replacing the one built from preferences.
+func (s *Server) SetPushSender(platform string, sender push.Sender) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadPushSendersLocked()
+	s.pushSenders[platform] = sender
+}
+
+// loadPushSendersLocked builds the senders configured in preferences the
+// first time they are needed. Setting a push.* key clears them. s.mu must
+// be held.
+func (s *Server) loadPushSendersLocked() {
+	if s.pushSenders != nil {
+		return
+	}
+	s.pushSenders = map[string]push.Sender{}
+	if s.prefs == nil {
+		return
+	}
+	p := *s.prefs
+	if p.PushAPNsKey != "" {
+		if a, err := push.LoadAPNs(p.PushAPNsKey, p.PushAPNsKeyID, p.PushAPNsTeamID, p.PushAPNsTopic, p.PushAPNsSandbox); err == nil {
+			s.pushSenders[store.PushPlatformAPNs] = a
+		} else {
+			s.logf("push: %v", err)
+		}
+	}
+	if p.PushFCMCredentials != "" {
+		if f, err := push.LoadFCM(p.PushFCMCredentials); err == nil {
+			s.pushSenders[store.PushPlatformFCM] = f
+		} else {
+			s.logf("push: %v", err)
+		}
+	}
+}
+
+// teeToPush wraps send so that finished turns and ask_user prompts are
+// also pushed to devices whose app is in the background.
+func (s *Server) teeToPush(sessionID string, send func(event string, data any)) func(event string, data any) {
+	return func(event string, data any) {
+		send(event, data)
+		switch event {
+		case "turn_done":
+			s.notifyDevices(sessionID, "turn_done", "", "")
+		case "ask_user":
+			d, _ := data.(map[string]any)
+			askID, _ := d["ask_id"].(string)
+			prompt, _ := d["prompt"].(string)
+			s.notifyDevices(sessionID, "ask_user", askID, prompt)
+		}
+	}
+}
+
+// notifyDevices sends the notification in the background. It does nothing
+// unless a push sender is configured.
+func (s *Server) notifyDevices(sessionID, event, askID, prompt string) {
+	s.mu.Lock()
+	s.loadPushSendersLocked()
+	senders := make(map[string]push.Sender, len(s.pushSenders))
+	for k, v := range s.pushSenders {
+		senders[k] = v
+	}
+	s.mu.Unlock()
+	if len(senders) == 0 {
+		return
+	}
+
+	go func() {
+		devices, err := s.store.BackgroundPushDevices()
+		if err != nil || len(devices) == 0 {
+			return
+		}
+		n := s.sessionNotification(sessionID, event, askID, prompt)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		for _, d := range devices {
+			sender := senders[d.Platform]
+			if sender == nil {
+				continue
+			}
+			err := sender.Send(ctx, d.Token, n)
+			switch {
+			case errors.Is(err, push.ErrUnregistered):
+				s.logf("push: forgetting device %s: %v", d.ID, err)
+				_ = s.store.DeletePushDeviceToken(d.Token)
+			case err != nil:
+				s.logf("push: device %s: %v", d.ID, err)
+			}
+		}
+	}()
+}
+
+// sessionNotification builds the alert for an event in a session. Text
+// leaves the machine through Apple or Google, so secrets are redacted.
+func (s *Server) sessionNotification(sessionID, event, askID, prompt string) push.Notification {
+	n := push.Notification{
+		Title: "muxd",
+		Data:  map[string]string{"session_id": sessionID, "event": event},
+	}
+	st := s.sessionStore(sessionID)
+	if title := st.SessionTitle(sessionID); title != "" {
+		n.Title = previewText(title, 60)
+	}
+	switch event {
+	case "ask_user":
+		n.Data["ask_id"] = askID
+		n.Body = "Question: " + previewText(redact.Secrets(prompt), mobilePreviewLen)
+	default:
+		n.Body = "Turn finished"
+		if count, err := st.CountMessages(sessionID); err == nil && count > 0 {
+			if preview := s.lastTextPreview(sessionID, count); preview != "" {
+				n.Body = preview
+			}
+		}
+	}
+	return n
+}
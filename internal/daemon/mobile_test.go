@@ -0,0 +1,268 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/push"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func TestMobileSessions(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/home/me/code/muxd", "model-a")
+	_ = st.UpdateSessionTitle(sess.ID, "Fix the parser")
+	_ = st.AppendMessage(sess.ID, "user", "hello", 0)
+	_ = st.AppendMessage(sess.ID, "assistant", "Done.\n\nThe parser   now handles tabs.", 10)
+	_ = st.AppendMessageBlocks(sess.ID, "user", []domain.ContentBlock{{Type: "tool_result", ToolResult: "ok"}}, 0)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/mobile/sessions?limit=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Sessions []SessionSummary `json:"sessions"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Sessions) != 1 {
+		t.Fatalf("sessions = %+v", resp.Sessions)
+	}
+	got := resp.Sessions[0]
+	if got.Title != "Fix the parser" || got.Project != "muxd" || got.MessageCount != 3 || got.Running {
+		t.Errorf("summary = %+v", got)
+	}
+	// The trailing tool result is skipped and whitespace collapsed.
+	if got.Preview != "Done. The parser now handles tabs." {
+		t.Errorf("Preview = %q", got.Preview)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/mobile/sessions?limit=x", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: expected 400, got %d", w.Code)
+	}
+}
+
+func TestMobileMessages(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	for i := range 5 {
+		_ = st.AppendMessage(sess.ID, "user", fmt.Sprintf("question %d", i), 0)
+	}
+	_ = st.AppendMessageBlocks(sess.ID, "assistant", []domain.ContentBlock{
+		{Type: "text", Text: "Let me look.\n```go\nfmt.Println()\n```"},
+		{Type: "tool_use", ToolName: "grep", ToolInput: map[string]any{"pattern": "x"}},
+	}, 0)
+	_ = st.AppendMessageBlocks(sess.ID, "user", []domain.ContentBlock{{Type: "tool_result", ToolResult: "found"}}, 0)
+
+	get := func(query string) (int, MobileMessagePage) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/mobile/sessions/"+sess.ID+"/messages"+query, nil))
+		var page MobileMessagePage
+		_ = json.NewDecoder(w.Body).Decode(&page)
+		return w.Code, page
+	}
+
+	// The newest page comes first; the tool-result-only message is dropped.
+	code, page := get("?limit=3")
+	if code != http.StatusOK || page.Total != 7 || page.Offset != 4 {
+		t.Fatalf("newest page: code %d, %+v", code, page)
+	}
+	if len(page.Messages) != 2 || page.Messages[0].Text != "question 4" || page.Messages[1].Index != 5 {
+		t.Fatalf("newest page messages = %+v", page.Messages)
+	}
+	if tools := page.Messages[1].Tools; len(tools) != 1 || tools[0] != "grep" || page.Messages[1].HTML != "" {
+		t.Errorf("assistant message = %+v", page.Messages[1])
+	}
+
+	// Scrolling back uses the previous page's offset.
+	_, page = get(fmt.Sprintf("?before=%d&limit=3", page.Offset))
+	if page.Offset != 1 || len(page.Messages) != 3 || page.Messages[0].Text != "question 1" {
+		t.Errorf("older page = %+v", page)
+	}
+
+	_, page = get("?limit=3&format=html")
+	if html := page.Messages[1].HTML; !strings.Contains(html, "<p>Let me look.</p>") || !strings.Contains(html, "grep") {
+		t.Errorf("HTML = %q", html)
+	}
+
+	// Resuming fetches only what came after the last sync, oldest first.
+	_, page = get("?after=4&limit=1")
+	if page.Offset != 4 || page.Next != 5 || len(page.Messages) != 1 || page.Messages[0].Text != "question 4" {
+		t.Errorf("after page = %+v", page)
+	}
+	_, page = get(fmt.Sprintf("?after=%d", page.Total))
+	if page.Next != page.Total || len(page.Messages) != 0 {
+		t.Errorf("caught-up page = %+v", page)
+	}
+	if code, _ := get("?after=1&before=3"); code != http.StatusBadRequest {
+		t.Errorf("before and after: expected 400, got %d", code)
+	}
+
+	if code, _ := get("?format=pdf"); code != http.StatusBadRequest {
+		t.Errorf("bad format: expected 400, got %d", code)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/mobile/sessions/missing/messages", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing session: expected 404, got %d", w.Code)
+	}
+}
+
+func TestPushDeviceEndpoints(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	if _, err := st.CreateAPIToken("phone", store.TokenScopeSubmit, "submit-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.CreateAPIToken("dashboard", store.TokenScopeRead, "read-secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, target, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	// Registering changes state, so a read-scoped token cannot.
+	if w := do("POST", "/api/push/devices", "read-secret", `{"platform":"apns","token":"dev-0"}`); w.Code != http.StatusForbidden {
+		t.Errorf("register with read token: expected 403, got %d", w.Code)
+	}
+
+	// A submit-scoped app token can register its device.
+	w := do("POST", "/api/push/devices", "submit-secret", `{"platform":"apns","token":"dev-1","name":"iPhone"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("register: %d %s", w.Code, w.Body.String())
+	}
+	var dev store.PushDevice
+	_ = json.NewDecoder(w.Body).Decode(&dev)
+	if dev.ID == "" || strings.Contains(w.Body.String(), "dev-1") {
+		t.Errorf("register response = %s; want an ID and no device token", w.Body.String())
+	}
+	if w := do("POST", "/api/push/devices", "submit-secret", `{"platform":"sms","token":"x"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad platform: expected 400, got %d", w.Code)
+	}
+
+	// Listing devices needs the full token.
+	if w := do("GET", "/api/push/devices", "read-secret", ""); w.Code != http.StatusForbidden && w.Code != http.StatusUnauthorized {
+		t.Errorf("list with read token: got %d", w.Code)
+	}
+	w = do("GET", "/api/push/devices", srv.AuthToken(), "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), dev.ID) {
+		t.Errorf("list: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("DELETE", "/api/push/devices/"+dev.ID, "read-secret", ""); w.Code != http.StatusForbidden {
+		t.Errorf("delete with read token: expected 403, got %d", w.Code)
+	}
+	if w := do("DELETE", "/api/push/devices/"+dev.ID, "submit-secret", ""); w.Code != http.StatusOK {
+		t.Errorf("delete: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", "/api/push/devices/"+dev.ID, "submit-secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete again: expected 404, got %d", w.Code)
+	}
+}
+
+type sentPush struct {
+	token string
+	n     push.Notification
+}
+
+type fakeSender struct {
+	sent chan sentPush
+	err  map[string]error // by token
+}
+
+func (f *fakeSender) Send(_ context.Context, token string, n push.Notification) error {
+	f.sent <- sentPush{token, n}
+	return f.err[token]
+}
+
+func TestPushNotifications(t *testing.T) {
+	srv, st := newTestServer(t)
+	sender := &fakeSender{sent: make(chan sentPush, 4), err: map[string]error{"stale": push.ErrUnregistered}}
+	srv.SetPushSender(store.PushPlatformAPNs, sender)
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	_ = st.UpdateSessionTitle(sess.ID, "Deploy")
+	_ = st.AppendMessage(sess.ID, "assistant", "Deployed with key sk-ant-REDACTED", 0)
+	_, _ = st.SavePushDevice("apns", "phone", "", false)
+	_, _ = st.SavePushDevice("apns", "open", "", true) // app in the foreground
+	_, _ = st.SavePushDevice("fcm", "android", "", false)
+
+	recv := func() sentPush {
+		t.Helper()
+		select {
+		case p := <-sender.sent:
+			return p
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification sent")
+		}
+		return sentPush{}
+	}
+
+	send := srv.teeToPush(sess.ID, func(string, any) {})
+	send("delta", map[string]string{"text": "x"})
+	send("turn_done", map[string]string{"stop_reason": "end_turn"})
+	p := recv()
+	if p.token != "phone" || p.n.Title != "Deploy" || p.n.Data["event"] != "turn_done" || p.n.Data["session_id"] != sess.ID {
+		t.Errorf("turn_done push = %+v", p)
+	}
+	if strings.Contains(p.n.Body, "sk-ant-api03") || !strings.HasPrefix(p.n.Body, "Deployed with key") {
+		t.Errorf("body = %q; want the reply preview with secrets redacted", p.n.Body)
+	}
+
+	send("ask_user", map[string]any{"ask_id": "ask-1", "prompt": "Which region?"})
+	p = recv()
+	if p.n.Body != "Question: Which region?" || p.n.Data["ask_id"] != "ask-1" {
+		t.Errorf("ask_user push = %+v", p)
+	}
+
+	// A token the service rejects is forgotten.
+	_, _ = st.SavePushDevice("apns", "stale", "", false)
+	send("turn_done", map[string]string{})
+	for range 2 {
+		recv()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		devices, _ := st.ListPushDevices()
+		stale := false
+		for _, d := range devices {
+			stale = stale || d.Token == "stale"
+		}
+		if !stale {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("unregistered device token was not removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case p := <-sender.sent:
+		t.Errorf("unexpected extra push %+v", p)
+	default:
+	}
+}
@@ -0,0 +1,52 @@
+package daemon
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Address helpers (IPv4 / IPv6)
+// ---------------------------------------------------------------------------
+
+// NormalizeBindAddr trims whitespace and strips brackets from IPv6 literals,
+// so "[::1]" and "::1" are treated the same.
+func NormalizeBindAddr(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if strings.HasPrefix(addr, "[") && strings.HasSuffix(addr, "]") {
+		addr = addr[1 : len(addr)-1]
+	}
+	return addr
+}
+
+// IsWildcardAddr reports whether addr binds all interfaces. "::" listens
+// dual-stack (IPv4 and IPv6) on platforms that support it.
+func IsWildcardAddr(addr string) bool {
+	switch NormalizeBindAddr(addr) {
+	case "", "0.0.0.0", "::":
+		return true
+	default:
+		return false
+	}
+}
+
+// IsLoopbackHost reports whether host refers to the local machine.
+func IsLoopbackHost(host string) bool {
+	host = NormalizeBindAddr(host)
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// HostPort joins host and port, bracketing IPv6 literals ("[::1]:4096").
+func HostPort(host string, port int) string {
+	return net.JoinHostPort(NormalizeBindAddr(host), strconv.Itoa(port))
+}
+
+// BaseURL returns the http:// URL for host and port.
+func BaseURL(host string, port int) string {
+	return "http://" + HostPort(host, port)
+}
@@ -0,0 +1,56 @@
+package daemon
+
+import "testing"
+
+func TestHostPort(t *testing.T) {
+	tests := []struct {
+		host string
+		port int
+		want string
+	}{
+		{"localhost", 4096, "localhost:4096"},
+		{"192.168.1.5", 4096, "192.168.1.5:4096"},
+		{"::1", 4096, "[::1]:4096"},
+		{"[2001:db8::1]", 4097, "[2001:db8::1]:4097"},
+		{"::", 0, "[::]:0"},
+	}
+	for _, tt := range tests {
+		if got := HostPort(tt.host, tt.port); got != tt.want {
+			t.Errorf("HostPort(%q, %d) = %q, want %q", tt.host, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestIsWildcardAddr(t *testing.T) {
+	for _, addr := range []string{"", "0.0.0.0", "::", "[::]"} {
+		if !IsWildcardAddr(addr) {
+			t.Errorf("IsWildcardAddr(%q) = false, want true", addr)
+		}
+	}
+	for _, addr := range []string{"localhost", "127.0.0.1", "::1", "2001:db8::1"} {
+		if IsWildcardAddr(addr) {
+			t.Errorf("IsWildcardAddr(%q) = true, want false", addr)
+		}
+	}
+}
+
+func TestIsLoopbackHost(t *testing.T) {
+	for _, host := range []string{"localhost", "127.0.0.1", "::1", "[::1]"} {
+		if !IsLoopbackHost(host) {
+			t.Errorf("IsLoopbackHost(%q) = false, want true", host)
+		}
+	}
+	if IsLoopbackHost("2001:db8::1") {
+		t.Error("IsLoopbackHost(2001:db8::1) = true, want false")
+	}
+}
+
+func TestNewConnectionInfo_IPv6(t *testing.T) {
+	info := newConnectionInfo("[2001:db8::1]", 4096, "tok")
+	if info.Host != "2001:db8::1" {
+		t.Errorf("Host = %q, want unbracketed literal", info.Host)
+	}
+	if info.URL != "http://[2001:db8::1]:4096" {
+		t.Errorf("URL = %q", info.URL)
+	}
+}
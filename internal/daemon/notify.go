@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/batalabs/muxd/internal/notify"
+	"github.com/batalabs/muxd/internal/redact"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// SetNotifier replaces the notifier built from the notify.* preferences.
+func (s *Server) SetNotifier(n *notify.Notifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifier = n
+}
+
+// notifierLocked returns the notifier, building it from preferences the
+// first time. Setting a notify.* key clears it. s.mu must be held.
+func (s *Server) notifierLocked() *notify.Notifier {
+	if s.notifier == nil {
+		var cfg notify.Config
+		if s.prefs != nil {
+			cfg = s.prefs.Notify()
+		}
+		cfg.Compliance = cfg.Compliance || tools.ComplianceMode()
+		s.notifier = notify.New(cfg)
+	}
+	return s.notifier
+}
+
+// teeToNotify wraps send so that finished turns and budget warnings are
+// also sent to the notify.* sinks.
+func (s *Server) teeToNotify(sessionID string, send func(event string, data any)) func(event string, data any) {
+	return func(event string, data any) {
+		send(event, data)
+		switch event {
+		case "turn_done":
+			s.notify(notify.EventTurnDone, sessionID, func() notify.Message {
+				n := s.sessionNotification(sessionID, "turn_done", "", "")
+				return notify.Message{Title: n.Title, Body: n.Body}
+			})
+		case "budget_warning":
+			d, _ := data.(map[string]any)
+			msg, _ := d["message"].(string)
+			s.notify(notify.EventBudget, sessionID, func() notify.Message {
+				return notify.Message{Title: "muxd budget", Body: msg}
+			})
+		}
+	}
+}
+
+// notifyJob reports a finished background or scheduled job. Cancelled jobs
+// are not reported.
+func (s *Server) notifyJob(what, sessionID string, failed bool, detail string) {
+	event, title := notify.EventJobDone, what+" succeeded"
+	if failed {
+		event, title = notify.EventJobFailed, what+" failed"
+	}
+	s.notify(event, sessionID, func() notify.Message {
+		return notify.Message{Title: title, Body: previewText(redact.Secrets(detail), mobilePreviewLen)}
+	})
+}
+
+// notify sends the message build returns in the background. It does
+// nothing unless a sink is routed for event, so build is only called when
+// the message will be sent.
+func (s *Server) notify(event, sessionID string, build func() notify.Message) {
+	s.mu.Lock()
+	n := s.notifierLocked()
+	s.mu.Unlock()
+	if !n.Enabled(event) {
+		return
+	}
+
+	go func() {
+		m := build()
+		m.Event, m.SessionID = event, sessionID
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		if err := n.Send(ctx, m); err != nil {
+			s.logf("notify: %v", err)
+		}
+	}()
+}
+
+// handleNotifyTest sends a test notification: POST /api/notify/test
+// {"sink": "desktop"}. Without a sink, every routed sink is tried.
+func (s *Server) handleNotifyTest(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Sink string `json:"sink"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+			return
+		}
+	}
+	sinks, err := notify.ParseSinks(req.Sink)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	n := s.notifierLocked()
+	s.mu.Unlock()
+	results := n.Test(r.Context(), sinks...)
+	if len(results) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no notification sinks are configured (set notify.on_turn_done or another notify.on_* key)"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"results": results})
+}
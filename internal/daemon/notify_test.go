@@ -0,0 +1,151 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/notify"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+type chanSink struct {
+	name string
+	sent chan notify.Message
+}
+
+func (c *chanSink) Name() string { return c.name }
+
+func (c *chanSink) Notify(_ context.Context, m notify.Message) error {
+	c.sent <- m
+	return nil
+}
+
+func TestNotifySinks(t *testing.T) {
+	srv, st := newTestServer(t)
+	n := notify.New(notify.Config{Routes: map[string][]string{
+		notify.EventTurnDone:  {notify.SinkDesktop},
+		notify.EventBudget:    {notify.SinkDesktop},
+		notify.EventJobFailed: {notify.SinkDesktop},
+	}})
+	sink := &chanSink{name: notify.SinkDesktop, sent: make(chan notify.Message, 4)}
+	n.SetSink(sink)
+	srv.SetNotifier(n)
+
+	recv := func() notify.Message {
+		t.Helper()
+		select {
+		case m := <-sink.sent:
+			return m
+		case <-time.After(5 * time.Second):
+			t.Fatal("no notification sent")
+		}
+		return notify.Message{}
+	}
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	_ = st.UpdateSessionTitle(sess.ID, "Deploy")
+	_ = st.AppendMessage(sess.ID, "assistant", "Deployed with key sk-ant-REDACTED", 0)
+
+	send := srv.teeToNotify(sess.ID, func(string, any) {})
+	send("delta", map[string]string{"text": "x"})
+	send("turn_done", map[string]string{"stop_reason": "end_turn"})
+	m := recv()
+	if m.Event != notify.EventTurnDone || m.Title != "Deploy" || m.SessionID != sess.ID {
+		t.Errorf("turn_done = %+v", m)
+	}
+	if strings.Contains(m.Body, "sk-ant-api03") || !strings.HasPrefix(m.Body, "Deployed with key") {
+		t.Errorf("body = %q; want the reply preview with secrets redacted", m.Body)
+	}
+
+	send("budget_warning", map[string]any{"message": "Session budget 80% used"})
+	if m := recv(); m.Event != notify.EventBudget || m.Body != "Session budget 80% used" {
+		t.Errorf("budget = %+v", m)
+	}
+
+	// Job success is not routed; failure is.
+	srv.finishJob("job-1", store.JobSucceeded, "ok", "")
+	srv.finishJob("job-2", store.JobFailed, "", "provider returned 500")
+	if m := recv(); m.Event != notify.EventJobFailed || m.Title != "Job job-2 failed" || m.Body != "provider returned 500" {
+		t.Errorf("job_failed = %+v", m)
+	}
+	select {
+	case m := <-sink.sent:
+		t.Errorf("unexpected notification %+v", m)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifyTestEndpoint(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "POST", "/api/notify/test", strings.NewReader(body)))
+		return w
+	}
+
+	if w := post(""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "no notification sinks") {
+		t.Errorf("no routes: %d %s", w.Code, w.Body)
+	}
+	if w := post(`{"sink":"pager"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown sink: expected 400, got %d", w.Code)
+	}
+
+	n := notify.New(notify.Config{Routes: map[string][]string{notify.EventTurnDone: {notify.SinkDesktop}}})
+	sink := &chanSink{name: notify.SinkDesktop, sent: make(chan notify.Message, 1)}
+	n.SetSink(sink)
+	srv.SetNotifier(n)
+
+	w := post(`{"sink":"webhook"}`)
+	var resp struct {
+		Results []notify.Result `json:"results"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("webhook: %d %v", w.Code, err)
+	}
+	if len(resp.Results) != 1 || !strings.Contains(resp.Results[0].Error, "webhook_url") {
+		t.Errorf("webhook results = %+v", resp.Results)
+	}
+
+	w = post("")
+	resp.Results = nil
+	_ = json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Results) != 1 || resp.Results[0] != (notify.Result{Sink: notify.SinkDesktop}) {
+		t.Errorf("routed results = %+v", resp.Results)
+	}
+	if m := <-sink.sent; m.Event != "test" {
+		t.Errorf("test message = %+v", m)
+	}
+}
+
+func TestNotifierSkipsOutboundSinksUnderCompliance(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.prefs.ComplianceMode = true
+	srv.prefs.NotifyOnTurnDone = "desktop,webhook,telegram"
+	srv.prefs.NotifyWebhookURL = "https://hooks.example.com/x"
+	srv.prefs.NotifyTelegramToken = "123:abc"
+	srv.prefs.NotifyTelegramChatID = "42"
+
+	srv.mu.Lock()
+	n := srv.notifierLocked()
+	srv.mu.Unlock()
+	n.SetSink(&chanSink{name: notify.SinkDesktop, sent: make(chan notify.Message, 1)})
+	for _, r := range n.Test(context.Background()) {
+		switch r.Sink {
+		case notify.SinkDesktop:
+			if r.Error != "" {
+				t.Errorf("desktop: %s", r.Error)
+			}
+		default:
+			if !strings.Contains(r.Error, "compliance") {
+				t.Errorf("%s was not skipped under compliance: %+v", r.Sink, r)
+			}
+		}
+	}
+}
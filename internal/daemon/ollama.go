@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/hostinfo"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// ---------------------------------------------------------------------------
+// Ollama model management
+// ---------------------------------------------------------------------------
+
+// OllamaStatus reports whether the configured Ollama server is reachable
+// and which models are installed on it.
+type OllamaStatus struct {
+	URL     string                 `json:"url"`
+	Version string                 `json:"version,omitempty"`
+	Error   string                 `json:"error,omitempty"` // the server could not be reached
+	Models  []provider.OllamaModel `json:"models"`
+	// Host is the memory available to models, when the Ollama server runs
+	// on the daemon's host.
+	Host *hostinfo.Resources `json:"host,omitempty"`
+}
+
+// ollamaPullEnd is the last line of a pull stream: success, or the error
+// that stopped it.
+type ollamaPullEnd struct {
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleOllamaStatus checks the Ollama server at ollama.url and lists its
+// models. An unreachable server is reported in the body, not as an HTTP
+// error.
+func (s *Server) handleOllamaStatus(w http.ResponseWriter, r *http.Request) {
+	status := OllamaStatus{URL: provider.OllamaBaseURL(), Models: []provider.OllamaModel{}}
+	version, err := provider.OllamaVersion()
+	if err == nil {
+		status.Version = version
+		var models []provider.OllamaModel
+		if models, err = provider.ListOllamaModels(); err == nil {
+			status.Models = models
+		}
+	}
+	if err != nil {
+		status.Error = err.Error()
+	} else if provider.OllamaIsLocal() {
+		host := hostinfo.Probe()
+		status.Host = &host
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// usingLocalModel reports whether the daemon's model runs on this host:
+// an Ollama model served from a loopback address.
+func (s *Server) usingLocalModel() bool {
+	s.mu.Lock()
+	prov := s.provider
+	s.mu.Unlock()
+	return prov != nil && prov.Name() == "ollama" && provider.OllamaIsLocal()
+}
+
+// handleOllamaPull pulls a model, streaming Ollama's progress updates as
+// newline-delimited JSON. The last line has status "success" or an error.
+func (s *Server) handleOllamaPull(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Model string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Model) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "model is required"})
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return
+	}
+
+	s.logf("ollama pull model=%s", req.Model)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	err := provider.PullOllamaModel(strings.TrimSpace(req.Model), func(p provider.OllamaProgress) {
+		if p.Status == "success" {
+			return
+		}
+		_ = enc.Encode(p)
+		flusher.Flush()
+	})
+	end := ollamaPullEnd{Status: "success"}
+	if err != nil {
+		end = ollamaPullEnd{Error: err.Error()}
+	}
+	_ = enc.Encode(end)
+	flusher.Flush()
+}
+
+// handleOllamaDelete removes a model from the Ollama server.
+func (s *Server) handleOllamaDelete(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := provider.DeleteOllamaModel(name); err != nil {
+		status := http.StatusBadGateway
+		var apiErr *provider.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	s.logf("ollama rm model=%s", name)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+func TestDaemonClientOllama(t *testing.T) {
+	installed := []string{"hf.co/unsloth/gemma-3:Q4"}
+	ollama := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch r.URL.Path {
+		case "/api/version":
+			fmt.Fprint(w, `{"version":"0.6.2"}`)
+		case "/api/tags":
+			var models []map[string]any
+			for _, name := range installed {
+				models = append(models, map[string]any{"name": name, "size": 1000})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"models": models})
+		case "/api/pull":
+			if req.Model == "nope" {
+				fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+				return
+			}
+			fmt.Fprintln(w, `{"status":"pulling abc","digest":"sha256:abc","total":10,"completed":5}`)
+			fmt.Fprintln(w, `{"status":"success"}`)
+			installed = append(installed, req.Model)
+		case "/api/delete":
+			if req.Model != installed[0] {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":"model not found"}`)
+				return
+			}
+			installed = installed[1:]
+		}
+	}))
+	defer ollama.Close()
+	provider.SetOllamaBaseURL(ollama.URL)
+	t.Cleanup(func() { provider.SetOllamaBaseURL("") })
+
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	var progress []provider.OllamaProgress
+	if err := client.PullOllamaModel("llama3.2", func(p provider.OllamaProgress) { progress = append(progress, p) }); err != nil {
+		t.Fatalf("PullOllamaModel: %v", err)
+	}
+	if len(progress) != 1 || progress[0].Completed != 5 || progress[0].Total != 10 {
+		t.Errorf("progress = %+v", progress)
+	}
+	if err := client.PullOllamaModel("nope", nil); err == nil || !strings.Contains(err.Error(), "file does not exist") {
+		t.Errorf("failed pull = %v", err)
+	}
+
+	status, err := client.OllamaStatus()
+	if err != nil {
+		t.Fatalf("OllamaStatus: %v", err)
+	}
+	if status.URL != ollama.URL || status.Version != "0.6.2" || status.Error != "" || len(status.Models) != 2 {
+		t.Errorf("status = %+v", status)
+	}
+
+	if err := client.DeleteOllamaModel("hf.co/unsloth/gemma-3:Q4"); err != nil {
+		t.Fatalf("DeleteOllamaModel: %v", err)
+	}
+	if err := client.DeleteOllamaModel("gone"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("deleting a missing model = %v", err)
+	}
+	if len(installed) != 1 || installed[0] != "llama3.2" {
+		t.Errorf("installed = %v", installed)
+	}
+
+	ollama.Close()
+	if status, err := client.OllamaStatus(); err != nil || !strings.Contains(status.Error, "not reachable") {
+		t.Errorf("unreachable status = %+v, %v", status, err)
+	}
+}
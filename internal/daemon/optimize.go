@@ -0,0 +1,250 @@
+package daemon
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// ---------------------------------------------------------------------------
+// Cost optimizer
+// ---------------------------------------------------------------------------
+
+// OptimizeReport is the cost advisor's reading of recent usage and the
+// config changes it suggests.
+type OptimizeReport struct {
+	Since         string          `json:"since"` // first day included, YYYY-MM-DD
+	Days          int             `json:"days"`
+	SpendUSD      float64         `json:"spend_usd"`
+	MonthlyUSD    float64         `json:"monthly_usd"`    // spend projected to 30 days
+	CacheHitRate  float64         `json:"cache_hit_rate"` // share of input tokens read from the prompt cache
+	InputPerCall  int             `json:"input_per_call"`
+	ToolCallShare float64         `json:"tool_call_share"` // share of model calls that ran tools
+	Stats         store.CallStats `json:"stats"`
+	Suggestions   []Suggestion    `json:"suggestions"`
+}
+
+// Suggestion is one change the advisor recommends. Key and Value are the
+// preference to set; Key is empty for advice that no setting carries out.
+type Suggestion struct {
+	ID         string  `json:"id"`
+	Title      string  `json:"title"`
+	Detail     string  `json:"detail"`
+	Key        string  `json:"key,omitempty"`
+	Value      string  `json:"value,omitempty"`
+	MonthlyUSD float64 `json:"monthly_usd"` // estimated savings
+}
+
+// Token sizes assumed for helper calls, which usage does not tell apart:
+// a session title or tag list reads the opening exchange and writes a
+// line, and a compaction summary is about this long.
+const (
+	helperInputTokens  = 800
+	helperOutputTokens = 30
+	summaryTokens      = 1500
+)
+
+// helperModels is the model suggested for titles, tags, and compaction
+// per provider: the cheapest current one.
+var helperModels = map[string]string{
+	"anthropic": "claude-haiku-4-5-20251001",
+	"openai":    "gpt-4o-mini",
+}
+
+// cheaperMainModels maps premium models to the tier below, suggested as
+// the main model when most calls are routine tool steps.
+var cheaperMainModels = []struct {
+	match *regexp.Regexp
+	model string
+}{
+	{regexp.MustCompile(`^claude-opus-`), "claude-sonnet-4-6"},
+	{regexp.MustCompile(`^o3$`), "o4-mini"},
+}
+
+// Thresholds for the advice.
+const (
+	toolHeavyShare   = 0.6       // main model: share of calls that run tools
+	cacheAdviceInput = 1_000_000 // cache: input tokens per window worth advising on
+	lowCacheHitRate  = 0.3
+	targetCacheRate  = 0.6 // what a warm cache reaches on agent loops
+)
+
+var openAIModel = regexp.MustCompile(`^(gpt-|o\d)`)
+
+// modelFamily returns the provider whose pricing applies to a model ID,
+// or "" for one the advisor has no alternatives for.
+func modelFamily(id string) string {
+	switch {
+	case strings.HasPrefix(id, "claude-"):
+		return "anthropic"
+	case openAIModel.MatchString(id):
+		return "openai"
+	}
+	return ""
+}
+
+// tokenCost prices tokens as provider.ModelCostWithCache does.
+func tokenCost(p domain.ModelPricing, input, output, cacheRead int) float64 {
+	effective := float64(input-cacheRead) + float64(cacheRead)*0.10
+	return math.Max(effective, 0)/1_000_000*p.InputPerMillion + float64(output)/1_000_000*p.OutputPerMillion
+}
+
+// Optimize analyzes usage by model and call counts from since to now and
+// suggests changes to prefs, pricing them with pricing. Suggestions are
+// sorted by estimated savings, highest first; ones that would save
+// nothing are left out.
+func Optimize(since, now time.Time, models []store.UsageRow, stats store.CallStats, prefs config.Preferences, pricing map[string]domain.ModelPricing) OptimizeReport {
+	days := int(math.Round(startOfDay(now).Sub(startOfDay(since)).Hours()/24)) + 1
+	if days < 1 {
+		days = 1
+	}
+	perMonth := 30 / float64(days)
+	r := OptimizeReport{Since: since.Format(store.SpendDayLayout), Days: days, Stats: stats, Suggestions: []Suggestion{}}
+
+	var input, cacheRead int
+	for _, m := range models {
+		r.SpendUSD += m.CostUSD
+		input += m.InputTokens
+		cacheRead += m.CacheReadTokens
+	}
+	r.MonthlyUSD = r.SpendUSD * perMonth
+	if input > 0 {
+		r.CacheHitRate = float64(cacheRead) / float64(input)
+	}
+	if stats.Calls > 0 {
+		r.InputPerCall = input / stats.Calls
+		r.ToolCallShare = float64(stats.ToolCalls) / float64(stats.Calls)
+	}
+	if len(models) == 0 {
+		return r
+	}
+
+	// The model with the most spend is the one worth acting on; it is also
+	// what helper calls run on when their model is unset.
+	top := models[0]
+	for _, m := range models[1:] {
+		if m.CostUSD > top.CostUSD {
+			top = m
+		}
+	}
+	topPrice, ok := pricing[top.Key]
+	if !ok {
+		return r
+	}
+	_, current := provider.ResolveProviderAndModel(prefs.Model, "")
+	add := func(s Suggestion) {
+		if s.MonthlyUSD >= 0.01 {
+			r.Suggestions = append(r.Suggestions, s)
+		}
+	}
+
+	for _, c := range cheaperMainModels {
+		cheaper, ok := pricing[c.model]
+		if !c.match.MatchString(top.Key) || !ok || current == c.model || r.ToolCallShare < toolHeavyShare {
+			continue
+		}
+		saved := tokenCost(topPrice, top.InputTokens, top.OutputTokens, top.CacheReadTokens) -
+			tokenCost(cheaper, top.InputTokens, top.OutputTokens, top.CacheReadTokens)
+		add(Suggestion{
+			ID:    "model",
+			Title: "Use " + c.model + " as the main model",
+			Detail: fmt.Sprintf("%.0f%% of model calls run tools, routine steps that %s handles well. Set model back to %s for hard problems.",
+				r.ToolCallShare*100, c.model, top.Key),
+			Key: "model", Value: c.model,
+			MonthlyUSD: saved * perMonth,
+		})
+	}
+
+	if helper, ok := helperModels[modelFamily(top.Key)]; ok && helper != top.Key {
+		helperPrice, priced := pricing[helper]
+		perCall := func(in, out int) float64 {
+			return tokenCost(topPrice, in, out, 0) - tokenCost(helperPrice, in, out, 0)
+		}
+		for _, h := range []struct {
+			key, what string
+			calls     int
+			saved     float64
+		}{
+			{"model.title", "title sessions", stats.Sessions, perCall(helperInputTokens, helperOutputTokens)},
+			{"model.tags", "tag sessions", stats.Sessions, perCall(helperInputTokens, helperOutputTokens)},
+			{"model.compact", "summarize history when compacting", stats.Compactions, perCall(r.InputPerCall, summaryTokens)},
+		} {
+			if !priced || prefs.Get(h.key) != "" || h.calls == 0 {
+				continue
+			}
+			add(Suggestion{
+				ID:         h.key,
+				Title:      fmt.Sprintf("Use %s to %s", helper, h.what),
+				Detail:     fmt.Sprintf("%s is unset, so %d call(s) in this window ran on %s.", h.key, h.calls, top.Key),
+				Key:        h.key,
+				Value:      helper,
+				MonthlyUSD: h.saved * float64(h.calls) * perMonth,
+			})
+		}
+	}
+
+	if modelFamily(top.Key) == "anthropic" && top.InputTokens >= cacheAdviceInput {
+		rate := float64(top.CacheReadTokens) / float64(top.InputTokens)
+		if rate < lowCacheHitRate {
+			missed := int((targetCacheRate - rate) * float64(top.InputTokens))
+			add(Suggestion{
+				ID:    "cache",
+				Title: "Keep the prompt cache warm",
+				Detail: fmt.Sprintf("Only %.0f%% of %s's input was read from the prompt cache. Cached prompts expire after 5 minutes idle, and changing tools, MCP servers, or project memory mid-session rebuilds them.",
+					rate*100, top.Key),
+				MonthlyUSD: tokenCost(topPrice, missed, 0, 0) * 0.9 * perMonth,
+			})
+		}
+	}
+
+	sort.SliceStable(r.Suggestions, func(i, j int) bool {
+		return r.Suggestions[i].MonthlyUSD > r.Suggestions[j].MonthlyUSD
+	})
+	return r
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// handleOptimize reports the cost advisor's suggestions for usage since
+// the window in ?since= (default 30 days).
+func (s *Server) handleOptimize(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("since")
+	if window == "" {
+		window = "30d"
+	}
+	now := time.Now()
+	since, err := ParseUsageWindow(window, now)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	models, err := s.usageSummary(since, store.UsageByModel)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	stats, err := s.callStatsSince(startOfDay(since))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	var prefs config.Preferences
+	if s.prefs != nil {
+		prefs = *s.prefs
+	}
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, Optimize(since, now, models, stats, prefs, provider.PricingMap))
+}
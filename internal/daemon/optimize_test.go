@@ -0,0 +1,117 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func TestOptimize(t *testing.T) {
+	now := time.Date(2026, 5, 30, 15, 0, 0, 0, time.Local)
+	since := now.AddDate(0, 0, -29) // 30 days
+	pricing := config.DefaultPricingMap()
+	opus := store.UsageRow{Key: "claude-opus-4-6", InputTokens: 4_000_000, OutputTokens: 200_000, CacheReadTokens: 400_000, CostUSD: 20}
+
+	t.Run("no usage", func(t *testing.T) {
+		r := Optimize(since, now, nil, store.CallStats{}, config.Preferences{}, pricing)
+		if r.Days != 30 || r.SpendUSD != 0 || len(r.Suggestions) != 0 {
+			t.Errorf("Optimize = %+v", r)
+		}
+	})
+
+	t.Run("tool-heavy opus usage", func(t *testing.T) {
+		stats := store.CallStats{Sessions: 40, Turns: 100, Calls: 400, ToolCalls: 320, Compactions: 5}
+		haiku := store.UsageRow{Key: "claude-haiku-4-5-20251001", InputTokens: 10_000, CostUSD: 0.5}
+		r := Optimize(since, now, []store.UsageRow{haiku, opus}, stats, config.Preferences{}, pricing)
+
+		if r.SpendUSD != 20.5 || r.MonthlyUSD != 20.5 {
+			t.Errorf("spend = %v, monthly = %v", r.SpendUSD, r.MonthlyUSD)
+		}
+		if r.ToolCallShare != 0.8 || r.InputPerCall != 10_025 {
+			t.Errorf("tool share = %v, input per call = %v", r.ToolCallShare, r.InputPerCall)
+		}
+		byID := map[string]Suggestion{}
+		for i, s := range r.Suggestions {
+			byID[s.ID] = s
+			if i > 0 && s.MonthlyUSD > r.Suggestions[i-1].MonthlyUSD {
+				t.Errorf("suggestions not sorted by savings: %+v", r.Suggestions)
+			}
+		}
+		for _, id := range []string{"model", "model.title", "model.tags", "model.compact", "cache"} {
+			if _, ok := byID[id]; !ok {
+				t.Errorf("missing %s suggestion in %+v", id, r.Suggestions)
+			}
+		}
+		if s := byID["model"]; s.Key != "model" || s.Value != "claude-sonnet-4-6" || s.MonthlyUSD <= 0 {
+			t.Errorf("model suggestion = %+v", s)
+		}
+		if s := byID["model.title"]; s.Value != "claude-haiku-4-5-20251001" || !strings.Contains(s.Detail, "40 call(s)") {
+			t.Errorf("title suggestion = %+v", s)
+		}
+		if s := byID["cache"]; s.Key != "" || s.MonthlyUSD <= 0 {
+			t.Errorf("cache suggestion = %+v", s)
+		}
+	})
+
+	t.Run("settings already in place", func(t *testing.T) {
+		prefs := config.Preferences{Model: "claude-sonnet-4-6", ModelTitle: "claude-haiku-4-5-20251001", ModelTags: "claude-haiku-4-5-20251001", ModelCompact: "claude-haiku-4-5-20251001"}
+		warm := opus
+		warm.CacheReadTokens = 3_000_000
+		stats := store.CallStats{Sessions: 40, Calls: 400, ToolCalls: 320, Compactions: 5}
+		r := Optimize(since, now, []store.UsageRow{warm}, stats, prefs, pricing)
+		if len(r.Suggestions) != 0 {
+			t.Errorf("expected no suggestions, got %+v", r.Suggestions)
+		}
+	})
+
+	t.Run("conversational usage keeps the main model", func(t *testing.T) {
+		stats := store.CallStats{Sessions: 10, Calls: 100, ToolCalls: 10}
+		r := Optimize(since, now, []store.UsageRow{opus}, stats, config.Preferences{}, pricing)
+		for _, s := range r.Suggestions {
+			if s.ID == "model" {
+				t.Errorf("unexpected main model suggestion %+v", s)
+			}
+		}
+	})
+
+	t.Run("projects a short window to a month", func(t *testing.T) {
+		r := Optimize(now.AddDate(0, 0, -6), now, []store.UsageRow{{Key: "unpriced", CostUSD: 7}}, store.CallStats{}, config.Preferences{}, pricing)
+		if r.Days != 7 || r.MonthlyUSD != 30 {
+			t.Errorf("days = %d, monthly = %v; want 7, 30", r.Days, r.MonthlyUSD)
+		}
+	})
+}
+
+func TestHandleOptimize(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/a", "test-model")
+	_ = st.RecordSpend(sess.ID, "opus", time.Now(), store.TokenCounts{Input: 100, Output: 10}, 2)
+
+	get := func(query string) (int, OptimizeReport) {
+		req := newAuthedRequest(srv, "GET", "/api/usage/optimize?"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var r OptimizeReport
+		_ = json.Unmarshal(w.Body.Bytes(), &r)
+		return w.Code, r
+	}
+	code, r := get("")
+	if code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if r.Days != 30 || r.SpendUSD != 2 || r.Stats.Sessions != 1 || r.Suggestions == nil {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if code, _ := get("since=soon"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad window, got %d", code)
+	}
+}
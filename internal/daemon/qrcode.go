@@ -10,20 +10,30 @@ import (
 )
 
 // ConnectionInfo holds the data encoded in QR codes for mobile connections.
+// Host is always the bare address (IPv6 without brackets); URL is the
+// ready-to-use base URL with IPv6 hosts bracketed.
 type ConnectionInfo struct {
 	Host  string `json:"host"`
 	Port  int    `json:"port"`
 	Token string `json:"token"`
+	URL   string `json:"url,omitempty"`
 }
 
-// GenerateQRCode creates a QR code PNG containing connection info.
-// The connection info is JSON-encoded and then base64-encoded for safe scanning.
-func GenerateQRCode(host string, port int, token string, size int) ([]byte, error) {
-	info := ConnectionInfo{
+// newConnectionInfo builds the QR payload for host and port.
+func newConnectionInfo(host string, port int, token string) ConnectionInfo {
+	host = NormalizeBindAddr(host)
+	return ConnectionInfo{
 		Host:  host,
 		Port:  port,
 		Token: token,
+		URL:   BaseURL(host, port),
 	}
+}
+
+// GenerateQRCode creates a QR code PNG containing connection info.
+// The connection info is JSON-encoded and then base64-encoded for safe scanning.
+func GenerateQRCode(host string, port int, token string, size int) ([]byte, error) {
+	info := newConnectionInfo(host, port, token)
 	data, err := json.Marshal(info)
 	if err != nil {
 		return nil, fmt.Errorf("marshal connection info: %w", err)
@@ -41,11 +51,7 @@ func GenerateQRCode(host string, port int, token string, size int) ([]byte, erro
 
 // GenerateQRCodeASCII creates an ASCII art QR code for terminal display.
 func GenerateQRCodeASCII(host string, port int, token string) (string, error) {
-	info := ConnectionInfo{
-		Host:  host,
-		Port:  port,
-		Token: token,
-	}
+	info := newConnectionInfo(host, port, token)
 	data, err := json.Marshal(info)
 	if err != nil {
 		return "", fmt.Errorf("marshal connection info: %w", err)
@@ -62,21 +68,27 @@ func GenerateQRCodeASCII(host string, port int, token string) (string, error) {
 	return qr.ToSmallString(false), nil
 }
 
-// GetLocalIPs returns non-loopback IPv4 addresses for LAN connections.
+// GetLocalIPs returns non-loopback addresses for LAN connections. IPv4
+// addresses come first, followed by global IPv6 addresses. Link-local IPv6
+// addresses are skipped since they are unusable without a zone.
 func GetLocalIPs() []string {
-	var ips []string
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
-		return ips
+		return nil
 	}
+	var v4, v6 []string
 	for _, addr := range addrs {
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				ips = append(ips, ipnet.IP.String())
-			}
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLoopback() {
+			continue
+		}
+		if ipnet.IP.To4() != nil {
+			v4 = append(v4, ipnet.IP.String())
+		} else if ipnet.IP.IsGlobalUnicast() {
+			v6 = append(v6, ipnet.IP.String())
 		}
 	}
-	return ips
+	return append(v4, v6...)
 }
 
 // DecodeConnectionInfo decodes a base64-encoded JSON connection info string.
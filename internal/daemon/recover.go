@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// repairInterruptedTurns closes out turns whose process died mid-way, so the
+// next submit on those sessions does not start from a dangling tool call.
+// Each repaired session keeps the interrupted prompt for the client to offer
+// a re-run.
+func (s *Server) repairInterruptedTurns() {
+	if s.store == nil {
+		return
+	}
+	n := 0
+	for _, st := range s.stores() {
+		turns, err := st.RunningTurns()
+		if err != nil {
+			s.logf("recover: %v", err)
+			continue
+		}
+		for sessionID, pid := range turns {
+			if IsProcessAlive(pid) {
+				continue
+			}
+			if err := repairInterruptedTurn(st, sessionID); err != nil {
+				s.logf("recover: session %s: %v", sessionID, err)
+				continue
+			}
+			n++
+		}
+	}
+	if n > 0 {
+		s.logf("recover: %d turns interrupted by the last shutdown repaired", n)
+	}
+}
+
+// repairInterruptedTurn appends the messages that end sessionID's cut-off
+// turn in st and records its prompt.
+func repairInterruptedTurn(st *store.Store, sessionID string) error {
+	msgs, err := st.GetMessages(sessionID)
+	if err != nil {
+		return fmt.Errorf("loading messages: %w", err)
+	}
+	fix, prompt := agent.RepairInterruptedTurn(msgs)
+	for _, m := range fix {
+		if m.HasBlocks() {
+			err = st.AppendMessageBlocks(sessionID, m.Role, m.Blocks, 0)
+		} else {
+			err = st.AppendMessage(sessionID, m.Role, m.Content, 0)
+		}
+		if err != nil {
+			return fmt.Errorf("appending repair: %w", err)
+		}
+	}
+	return st.SetInterruptedTurn(sessionID, prompt)
+}
@@ -0,0 +1,75 @@
+package daemon
+
+import (
+	"os"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestRepairInterruptedTurns(t *testing.T) {
+	srv, st := newTestServer(t)
+
+	// A dead process left this session mid-tool-call.
+	crashed, err := st.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := st.AppendMessage(crashed.ID, "user", "run the tests", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AppendMessageBlocks(crashed.ID, "assistant", []domain.ContentBlock{
+		{Type: "tool_use", ToolUseID: "u1", ToolName: "bash"},
+	}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetTurnPID(crashed.ID, 1<<30); err != nil {
+		t.Fatal(err)
+	}
+
+	// This one belongs to a live process and is left alone.
+	live, err := st.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := st.AppendMessage(live.ID, "user", "still going", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SetTurnPID(live.ID, os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+
+	srv.repairInterruptedTurns()
+
+	msgs, err := st.GetMessages(crashed.ID)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4: %+v", len(msgs), msgs)
+	}
+	if b := msgs[2].Blocks; len(b) != 1 || b[0].Type != "tool_result" || b[0].ToolUseID != "u1" {
+		t.Errorf("tool results = %+v", b)
+	}
+	if msgs[3].Role != "assistant" {
+		t.Errorf("last message = %+v", msgs[3])
+	}
+	sess, err := st.GetSession(crashed.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if sess.InterruptedPrompt != "run the tests" {
+		t.Errorf("InterruptedPrompt = %q", sess.InterruptedPrompt)
+	}
+
+	turns, err := st.RunningTurns()
+	if err != nil {
+		t.Fatalf("RunningTurns: %v", err)
+	}
+	if len(turns) != 1 || turns[live.ID] == 0 {
+		t.Errorf("RunningTurns = %v, want only the live session", turns)
+	}
+	if msgs, _ := st.GetMessages(live.ID); len(msgs) != 1 {
+		t.Errorf("live session was changed: %+v", msgs)
+	}
+}
@@ -4,28 +4,43 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"database/sql"
 	"errors"
 
 	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/backup"
+	"github.com/batalabs/muxd/internal/checkpoint"
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/export"
+	"github.com/batalabs/muxd/internal/hostinfo"
 	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/notify"
 	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/push"
+	"github.com/batalabs/muxd/internal/scaffold"
+	"github.com/batalabs/muxd/internal/sink"
 	"github.com/batalabs/muxd/internal/store"
 	"github.com/batalabs/muxd/internal/tools"
+	"github.com/batalabs/muxd/internal/webhook"
 )
 
 const (
@@ -53,14 +68,50 @@ type Server struct {
 	mu       sync.Mutex
 	agents   map[string]*agent.Service // sessionID -> agent
 	askChans map[string]chan<- string  // askID -> response channel
-
-	port     int
-	bindAddr string        // "localhost", "0.0.0.0", or specific IP
-	ready    chan struct{} // closed once port is assigned in Start()
-	server   *http.Server
-	quiet    bool
-	token    string
-	sched    *tools.ToolCallScheduler
+	// approvalChans maps approvalID -> decision channel for pending tool
+	// approvals.
+	approvalChans map[string]chan<- agent.ApprovalDecision
+	// memoryMu serializes /api/memory edits, which read-modify-write the
+	// project memory file.
+	memoryMu sync.Mutex
+	// checkpointMu serializes checkpoint creation, undo and redo, which
+	// rewrite the working tree.
+	checkpointMu sync.Mutex
+	// pushSenders maps push platform -> sender, built from preferences on
+	// first use. Guarded by mu.
+	pushSenders map[string]push.Sender
+	// notifier sends notify.* notifications, built from preferences on
+	// first use. Guarded by mu.
+	notifier *notify.Notifier
+	// webhooks caches the store's webhooks, reloaded after one is added or
+	// removed; webhookDispatcher delivers to them. Guarded by mu.
+	webhooks          []store.Webhook
+	webhooksLoaded    bool
+	webhookDispatcher *webhook.Dispatcher
+	// adapterFactories builds chat adapters by name; adapters holds the
+	// ones started. Guarded by mu.
+	adapterFactories map[string]AdapterFactory
+	adapters         map[string]*adapterRun
+
+	port       int
+	bindAddr   string // "localhost", "0.0.0.0", "::", or specific IP
+	socketPath string // unix socket path; when set, TCP is not used
+	portLo     int    // inclusive port range tried when the preferred port is taken
+	portHi     int
+	lockPath   string        // lockfile path; "" uses the global LockfilePath()
+	project    string        // project root recorded in the lockfile, if scoped
+	ready      chan struct{} // closed once port is assigned in Start()
+	server     *http.Server
+	quiet      bool
+	token      string
+	sched      *tools.ToolCallScheduler
+	backups    *backup.Scheduler
+	gcStop     chan struct{} // closed to stop checkpoint GC
+	viewers    shareViewers  // live share pages watching sessions
+	events     eventLogs     // each session's last turn, for long-poll clients
+	subAgents  subAgentTree  // spawn_agent workers by parent session
+	jobs       jobQueue      // queued headless agent jobs
+	projects   projectStores // per-project session databases, when enabled
 
 	newAgent      AgentFactory
 	detectGitRepo DetectGitRepoFunc
@@ -71,6 +122,9 @@ type Server struct {
 	hubDispatch   func(nodeIDOrName, prompt string) (string, error)
 
 	customToolRegistry *tools.CustomToolRegistry
+
+	startedAt  time.Time
+	tokensUsed atomic.Int64 // input + output tokens streamed since start
 }
 
 // NewServer creates a new daemon server.
@@ -86,16 +140,18 @@ func NewServer(st *store.Store, apiKey, modelID, modelLabel string, prov provide
 		token = generateAuthToken()
 	}
 	return &Server{
-		store:      st,
-		apiKey:     apiKey,
-		modelID:    modelID,
-		modelLabel: modelLabel,
-		provider:   prov,
-		prefs:      prefs,
-		agents:     make(map[string]*agent.Service),
-		askChans:   make(map[string]chan<- string),
-		ready:      make(chan struct{}),
-		token:      token,
+		store:         st,
+		apiKey:        apiKey,
+		modelID:       modelID,
+		modelLabel:    modelLabel,
+		provider:      prov,
+		prefs:         prefs,
+		agents:        make(map[string]*agent.Service),
+		askChans:      make(map[string]chan<- string),
+		approvalChans: make(map[string]chan<- agent.ApprovalDecision),
+		ready:         make(chan struct{}),
+		token:         token,
+		startedAt:     time.Now().UTC(),
 	}
 }
 
@@ -177,20 +233,54 @@ func (s *Server) logf(format string, args ...any) {
 	}
 }
 
-// SetBindAddress sets the network interface to bind to (e.g., "localhost", "0.0.0.0").
+// SetBindAddress sets the network interface to bind to (e.g., "localhost",
+// "0.0.0.0", or "::" for dual-stack). IPv6 literals may be bracketed.
 // Must be called before Start(). Defaults to "localhost" if not set.
 func (s *Server) SetBindAddress(addr string) {
-	s.bindAddr = addr
+	s.bindAddr = NormalizeBindAddr(addr)
+}
+
+// SetSocketPath makes the server listen on a unix domain socket at path
+// instead of TCP. Connections over the socket are trusted based on filesystem
+// permissions and skip token auth. Must be called before Start().
+func (s *Server) SetSocketPath(path string) {
+	s.socketPath = path
+}
+
+// SocketPath returns the unix socket path, or "" when listening on TCP.
+func (s *Server) SocketPath() string {
+	return s.socketPath
+}
+
+// SetLockfilePath scopes the server to a project: the lockfile is written to
+// path instead of the global location, and project is recorded in it so
+// clients can match the daemon to their working directory.
+// Must be called before Start().
+func (s *Server) SetLockfilePath(path, project string) {
+	s.lockPath = path
+	s.project = project
+}
+
+// SetPortRange sets the inclusive port range tried when the preferred port is
+// taken. When unset, the server falls back to an OS-assigned port.
+// Must be called before Start().
+func (s *Server) SetPortRange(lo, hi int) {
+	s.portLo = lo
+	s.portHi = hi
 }
 
 // NodeInfo returns the current capabilities of this daemon for hub registration.
 func (s *Server) NodeInfo() map[string]any {
+	// Probe before locking: nvidia-smi can take a moment.
+	resources := hostinfo.Probe()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	info := map[string]any{
-		"platform": runtime.GOOS,
-		"arch":     runtime.GOARCH,
+		"platform":  runtime.GOOS,
+		"arch":      runtime.GOARCH,
+		"resources": resources,
 	}
 	if s.provider != nil {
 		info["provider"] = s.provider.Name()
@@ -204,10 +294,24 @@ func (s *Server) NodeInfo() map[string]any {
 		builtinTools = append(builtinTools, t.Spec.Name)
 	}
 	info["tools"] = builtinTools
+	if tools.ComplianceMode() {
+		info["compliance_mode"] = true
+	}
 
 	if s.mcpManager != nil {
 		info["mcp_tools"] = s.mcpManager.ToolNames()
 	}
+
+	active := 0
+	for _, ag := range s.agents {
+		if ag.IsRunning() {
+			active++
+		}
+	}
+	info["active_sessions"] = active
+	info["loaded_sessions"] = len(s.agents)
+	info["tokens_used"] = s.tokensUsed.Load()
+	info["started_at"] = s.startedAt
 	return info
 }
 
@@ -219,6 +323,20 @@ func (s *Server) BindAddress() string {
 	return s.bindAddr
 }
 
+// advertisedHost is the host other machines should use to reach the daemon:
+// the first LAN address when bound to all interfaces, otherwise the bind
+// address.
+func (s *Server) advertisedHost() string {
+	bindAddr := s.BindAddress()
+	if !IsWildcardAddr(bindAddr) {
+		return bindAddr
+	}
+	if ips := GetLocalIPs(); len(ips) > 0 {
+		return ips[0]
+	}
+	return "localhost"
+}
+
 // initMCP loads .mcp.json config and starts MCP server connections.
 func (s *Server) initMCP() {
 	cwd, _ := tools.Getwd()
@@ -250,30 +368,41 @@ func (s *Server) initMCP() {
 	}
 }
 
-// Start begins listening on the given port. If the port is taken, falls back
-// to an OS-assigned port. Blocks until the server shuts down.
+// Start begins listening on the given port. If the port is taken, tries the
+// configured port range, or falls back to an OS-assigned port when no range is
+// set. When a socket path is configured, listens on the unix socket instead.
+// Blocks until the server shuts down.
 func (s *Server) Start(port int) error {
 	bindAddr := s.bindAddr
 	if bindAddr == "" {
 		bindAddr = "localhost" // secure default
 	}
 
-	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", bindAddr, port))
-	if err != nil {
-		// Port in use -- let OS assign
-		ln, err = net.Listen("tcp", fmt.Sprintf("%s:0", bindAddr))
+	var ln net.Listener
+	var err error
+	if s.socketPath != "" {
+		ln, err = listenUnix(s.socketPath)
 		if err != nil {
-			return fmt.Errorf("listening: %w", err)
+			return err
+		}
+		s.logf("server starting on unix socket %s", s.socketPath)
+		if !s.quiet {
+			fmt.Fprintf(os.Stderr, "muxd server listening on unix socket %s\n", s.socketPath)
+		}
+	} else {
+		ln, err = listenTCP(bindAddr, port, s.portLo, s.portHi)
+		if err != nil {
+			return err
+		}
+		s.port = ln.Addr().(*net.TCPAddr).Port
+		s.logf("server starting on %s", HostPort(bindAddr, s.port))
+		if !s.quiet {
+			fmt.Fprintf(os.Stderr, "muxd server listening on %s\n", HostPort(bindAddr, s.port))
 		}
-	}
-	s.port = ln.Addr().(*net.TCPAddr).Port
-	s.logf("server starting on %s:%d", bindAddr, s.port)
-	if !s.quiet {
-		fmt.Fprintf(os.Stderr, "muxd server listening on %s:%d\n", bindAddr, s.port)
 	}
 	close(s.ready) // signal that port is assigned
 
-	if err := WriteLockfile(s.port, s.token, bindAddr); err != nil {
+	if err := s.writeLockfile(bindAddr); err != nil {
 		_ = ln.Close()
 		return fmt.Errorf("writing lockfile: %w", err)
 	}
@@ -282,7 +411,7 @@ func (s *Server) Start(port int) error {
 	go s.initMCP()
 
 	s.sched = tools.NewToolCallScheduler(
-		daemonScheduledToolStore{st: s.store},
+		daemonScheduledToolStore{st: s.store, notifyJob: s.notifyJob},
 		schedulerPollInterval,
 		func() *tools.ToolContext {
 			cwd, _ := tools.Getwd()
@@ -292,11 +421,13 @@ func (s *Server) Start(port int) error {
 			allowed := map[string]bool{}
 			braveKey := ""
 			textbeltKey := ""
+			textbeltAccounts := map[string]string{}
 			if s.prefs != nil {
 				disabled = s.prefs.DisabledToolsSet()
 				allowed = s.prefs.ScheduledAllowedToolsSet()
 				braveKey = s.prefs.BraveAPIKey
 				textbeltKey = s.prefs.TextbeltAPIKey
+				textbeltAccounts = s.prefs.TextbeltAccountKeys()
 			}
 			planMode := false
 			ctx := &tools.ToolContext{
@@ -306,6 +437,7 @@ func (s *Server) Start(port int) error {
 				ScheduledAllowed: allowed,
 				BraveAPIKey:      braveKey,
 				TextbeltAPIKey:   textbeltKey,
+				TextbeltAccounts: textbeltAccounts,
 				ScheduleTool:     s.store.CreateScheduledToolJob,
 				ListScheduledJobs: func(toolName string, limit int) ([]tools.ScheduledJobInfo, error) {
 					jobs, err := s.store.ListScheduledToolJobs(limit)
@@ -356,23 +488,191 @@ func (s *Server) Start(port int) error {
 	if s.logger != nil {
 		s.sched.SetLogFunc(s.logger.Printf)
 	}
+	s.sched.SetPolicyFunc(s.schedulerPolicy)
 	s.sched.Start()
+	s.repairInterruptedTurns()
+	s.startJobs()
+	s.startBackups()
+	s.startCheckpointGC()
 
 	mux := http.NewServeMux()
 	s.registerRoutes(mux)
 
-	s.server = &http.Server{Handler: mux}
+	s.server = &http.Server{Handler: withCompression(mux), ConnContext: markUnixConn}
 	if err := s.server.Serve(ln); err != nil && err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
 
+// startBackups starts scheduled backups when backup.interval and a
+// destination are configured. Only the global daemon takes them; per-project
+// daemons would race it for the same archive names.
+func (s *Server) startBackups() {
+	if s.prefs == nil || s.lockPath != "" {
+		return
+	}
+	sched, ok, err := backup.ScheduleFromPreferences(*s.prefs)
+	if err != nil {
+		s.logf("backup: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	s.backups = backup.NewScheduler(s.store, config.ConfigDir(), sched)
+	s.backups.SetLogFunc(s.logf)
+	s.backups.Start()
+}
+
+// Checkpoint GC runs shortly after startup and then daily.
+const (
+	checkpointGCDelay    = 5 * time.Minute
+	checkpointGCInterval = 24 * time.Hour
+)
+
+// startCheckpointGC periodically removes checkpoint refs of deleted or
+// expired sessions across the repos in the global database. Like backups,
+// only the global daemon runs it; the sessions of per-project daemons are
+// read from their own databases so their refs are kept.
+func (s *Server) startCheckpointGC() {
+	if s.prefs == nil || s.lockPath != "" || s.store == nil {
+		return
+	}
+	s.gcStop = make(chan struct{})
+	maxAge := s.prefs.CheckpointRetention()
+	go func() {
+		timer := time.NewTimer(checkpointGCDelay)
+		defer timer.Stop()
+		for {
+			select {
+			case <-s.gcStop:
+				return
+			case <-timer.C:
+			}
+			s.collectCheckpoints(maxAge)
+			timer.Reset(checkpointGCInterval)
+		}
+	}()
+}
+
+func (s *Server) collectCheckpoints(maxAge time.Duration) {
+	results, err := checkpoint.GC(s.store, checkpoint.GCOptions{MaxAge: maxAge})
+	if err != nil {
+		s.logf("checkpoint gc: %v", err)
+		return
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			s.logf("checkpoint gc %s: %v", r.Repo, r.Err)
+		}
+		if len(r.Removed) > 0 {
+			s.logf("checkpoint gc %s: removed %d ref(s), kept %d", r.Repo, len(r.Removed), r.Kept)
+		}
+	}
+}
+
+// writeLockfile records this server in its lockfile (project-scoped or global).
+func (s *Server) writeLockfile(bindAddr string) error {
+	if s.lockPath == "" {
+		return WriteLockfile(s.port, s.token, bindAddr, s.socketPath)
+	}
+	return WriteLockfileAt(s.lockPath, LockfileData{
+		Port:       s.port,
+		BindAddr:   bindAddr,
+		SocketPath: s.socketPath,
+		Project:    s.project,
+		Token:      s.token,
+	})
+}
+
+// removeLockfile removes the lockfile written by writeLockfile.
+func (s *Server) removeLockfile() error {
+	if s.lockPath == "" {
+		return RemoveLockfile()
+	}
+	return RemoveLockfileAt(s.lockPath)
+}
+
+// listenTCP listens on the preferred port, then on each port of the inclusive
+// range [lo, hi]. Without a range, falls back to an OS-assigned port.
+func listenTCP(bindAddr string, port, lo, hi int) (net.Listener, error) {
+	ln, err := net.Listen("tcp", net.JoinHostPort(bindAddr, strconv.Itoa(port)))
+	if err == nil {
+		return ln, nil
+	}
+	if lo <= 0 || hi < lo {
+		// Port in use -- let OS assign
+		ln, err = net.Listen("tcp", net.JoinHostPort(bindAddr, "0"))
+		if err != nil {
+			return nil, fmt.Errorf("listening: %w", err)
+		}
+		return ln, nil
+	}
+	for p := lo; p <= hi; p++ {
+		if p == port {
+			continue
+		}
+		if ln, err = net.Listen("tcp", net.JoinHostPort(bindAddr, strconv.Itoa(p))); err == nil {
+			return ln, nil
+		}
+	}
+	return nil, fmt.Errorf("listening: no free port in range %d-%d", lo, hi)
+}
+
+// listenUnix listens on a unix domain socket at path, replacing a stale
+// socket left behind by a crashed daemon. A socket a daemon still answers
+// on is left alone. The socket is made owner-only.
+func listenUnix(path string) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("listening: %s exists and is not a socket", path)
+		}
+		conn, err := net.DialTimeout("unix", path, time.Second)
+		if err == nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("listening: a daemon is already running on %s", path)
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) && !errors.Is(err, syscall.ENOENT) {
+			return nil, fmt.Errorf("checking existing socket %s: %w", path, err)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("removing stale socket: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("creating socket dir: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening: %w", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("securing socket: %w", err)
+	}
+	return ln, nil
+}
+
+// unixConnKey marks request contexts whose connection arrived over a unix socket.
+type unixConnKey struct{}
+
+// markUnixConn is used as http.Server.ConnContext to tag unix socket connections.
+func markUnixConn(ctx context.Context, c net.Conn) context.Context {
+	if _, ok := c.(*net.UnixConn); ok {
+		return context.WithValue(ctx, unixConnKey{}, true)
+	}
+	return ctx
+}
+
 // Shutdown gracefully stops the server and removes the lockfile.
 func (s *Server) Shutdown(ctx context.Context) error {
 	s.logf("server shutting down")
 	s.mu.Lock()
 	mgr := s.mcpManager
+	for _, ag := range s.agents {
+		ag.Close()
+	}
 	s.mu.Unlock()
 	var err error
 	if mgr != nil {
@@ -381,17 +681,29 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	if s.sched != nil {
 		s.sched.Stop()
 	}
+	s.stopJobs()
+	s.closeWebhooks()
+	s.stopAdapters()
+	if s.backups != nil {
+		s.backups.Stop()
+	}
+	if s.gcStop != nil {
+		close(s.gcStop)
+		s.gcStop = nil
+	}
 	if s.server != nil {
 		err = s.server.Shutdown(ctx)
 	}
-	if err := RemoveLockfile(); err != nil {
+	s.closeProjectStores()
+	if err := s.removeLockfile(); err != nil {
 		s.logf("daemon: remove lockfile: %v", err)
 	}
 	return err
 }
 
 type daemonScheduledToolStore struct {
-	st *store.Store
+	st        *store.Store
+	notifyJob func(what, sessionID string, failed bool, detail string)
 }
 
 func (d daemonScheduledToolStore) DueScheduledToolCalls(now time.Time, limit int) ([]tools.ScheduledToolCall, error) {
@@ -408,23 +720,62 @@ func (d daemonScheduledToolStore) DueScheduledToolCalls(now time.Time, limit int
 			ToolInput:    it.ToolInput,
 			ScheduledFor: it.ScheduledFor,
 			Recurrence:   it.Recurrence,
+			Retries:      it.RetryCount,
 		})
 	}
 	return out, nil
 }
 
 func (d daemonScheduledToolStore) MarkScheduledToolCallSucceeded(call tools.ScheduledToolCall, result string, completedAt time.Time) error {
-	return d.st.MarkScheduledToolJobSucceeded(call.ID, result, completedAt)
+	if err := d.st.MarkScheduledToolJobSucceeded(call.ID, result, completedAt); err != nil {
+		return err
+	}
+	d.finished(call, false, result)
+	return nil
 }
 
 func (d daemonScheduledToolStore) MarkScheduledToolCallFailed(call tools.ScheduledToolCall, errText, result string, attemptedAt time.Time) error {
-	return d.st.MarkScheduledToolJobFailed(call.ID, errText, result, attemptedAt)
+	if err := d.st.MarkScheduledToolJobFailed(call.ID, errText, result, attemptedAt); err != nil {
+		return err
+	}
+	d.finished(call, true, errText)
+	return nil
+}
+
+func (d daemonScheduledToolStore) finished(call tools.ScheduledToolCall, failed bool, detail string) {
+	if d.notifyJob != nil {
+		d.notifyJob("Scheduled "+call.ToolName, "", failed, detail)
+	}
 }
 
 func (d daemonScheduledToolStore) RescheduleScheduledToolCall(call tools.ScheduledToolCall, next time.Time) error {
 	return d.st.RescheduleScheduledToolJob(call.ID, next)
 }
 
+func (d daemonScheduledToolStore) RetryScheduledToolCall(call tools.ScheduledToolCall, errText, result string, attemptedAt, retryAt time.Time) error {
+	return d.st.RetryScheduledToolJob(call.ID, errText, result, attemptedAt, retryAt)
+}
+
+func (d daemonScheduledToolStore) SkipScheduledToolCall(call tools.ScheduledToolCall, reason string, skippedAt time.Time) error {
+	return d.st.SkipScheduledToolJob(call.ID, reason, skippedAt)
+}
+
+// schedulerPolicy returns the scheduler's retry and misfire policy from
+// the scheduler.* preferences.
+func (s *Server) schedulerPolicy() tools.RetryPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.prefs == nil {
+		return tools.RetryPolicy{}
+	}
+	maxAttempts, backoff := s.prefs.SchedulerRetry()
+	return tools.RetryPolicy{
+		MaxAttempts:  maxAttempts,
+		Backoff:      backoff,
+		SkipMisfired: s.prefs.MisfirePolicy() == config.MisfireSkip,
+	}
+}
+
 // Port returns the actual listening port. Blocks until Start() has bound the
 // listener and assigned the port.
 func (s *Server) Port() int {
@@ -436,38 +787,171 @@ func (s *Server) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/health", s.handleHealth)
 	mux.HandleFunc("GET /api/qrcode", s.withAuth(s.handleQRCode))
 	mux.HandleFunc("POST /api/qrcode/regenerate", s.withAuth(s.handleRegenerateToken))
-	mux.HandleFunc("POST /api/sessions", s.withAuth(s.handleCreateSession))
-	mux.HandleFunc("GET /api/sessions/{id}", s.withAuth(s.handleGetSession))
+	mux.HandleFunc("POST /api/sessions", s.withScope(store.TokenScopeSubmit, s.handleCreateSession))
+	mux.HandleFunc("GET /api/sessions/{id}", s.withScope(store.TokenScopeRead, s.handleGetSession))
 	mux.HandleFunc("DELETE /api/sessions/{id}", s.withAuth(s.handleDeleteSession))
-	mux.HandleFunc("GET /api/sessions", s.withAuth(s.handleListSessions))
-	mux.HandleFunc("POST /api/sessions/{id}/submit", s.withAuth(s.handleSubmit))
-	mux.HandleFunc("POST /api/sessions/{id}/cancel", s.withAuth(s.handleCancel))
-	mux.HandleFunc("POST /api/sessions/{id}/ask-response", s.withAuth(s.handleAskResponse))
-	mux.HandleFunc("GET /api/sessions/{id}/messages", s.withAuth(s.handleGetMessages))
-	mux.HandleFunc("POST /api/sessions/{id}/model", s.withAuth(s.handleSetModel))
-	mux.HandleFunc("POST /api/sessions/{id}/title", s.withAuth(s.handleSetTitle))
-	mux.HandleFunc("POST /api/sessions/{id}/branch", s.withAuth(s.handleBranch))
+	mux.HandleFunc("GET /api/sessions", s.withScope(store.TokenScopeRead, s.handleListSessions))
+	mux.HandleFunc("POST /api/sessions/{id}/submit", s.withScope(store.TokenScopeSubmit, s.handleSubmit))
+	mux.HandleFunc("GET /api/sessions/{id}/ws", s.withScope(store.TokenScopeRead, s.handleSessionSocket))
+	mux.HandleFunc("POST /api/sessions/{id}/cancel", s.withScope(store.TokenScopeSubmit, s.handleCancel))
+	mux.HandleFunc("POST /api/sessions/{id}/stop", s.withScope(store.TokenScopeSubmit, s.handleStop))
+	mux.HandleFunc("POST /api/sessions/{id}/steer", s.withScope(store.TokenScopeSubmit, s.handleSteer))
+	mux.HandleFunc("POST /api/sessions/{id}/ask-response", s.withScope(store.TokenScopeSubmit, s.handleAskResponse))
+	mux.HandleFunc("POST /api/sessions/{id}/approve", s.withScope(store.TokenScopeSubmit, s.handleApprove))
+	mux.HandleFunc("GET /api/sessions/{id}/messages", s.withScope(store.TokenScopeRead, s.handleGetMessages))
+	mux.HandleFunc("GET /api/sessions/{id}/export", s.withScope(store.TokenScopeRead, s.handleExportSession))
+	mux.HandleFunc("POST /api/sessions/{id}/model", s.withScope(store.TokenScopeSubmit, s.handleSetModel))
+	mux.HandleFunc("POST /api/sessions/{id}/title", s.withScope(store.TokenScopeSubmit, s.handleSetTitle))
+	mux.HandleFunc("GET /api/sessions/{id}/style", s.withScope(store.TokenScopeRead, s.handleGetStyle))
+	mux.HandleFunc("GET /api/sessions/{id}/context", s.withScope(store.TokenScopeRead, s.handleGetContext))
+	mux.HandleFunc("POST /api/sessions/{id}/style", s.withScope(store.TokenScopeSubmit, s.handleSetStyle))
+	mux.HandleFunc("GET /api/sessions/{id}/verify", s.withScope(store.TokenScopeRead, s.handleGetVerify))
+	mux.HandleFunc("POST /api/sessions/{id}/verify", s.withScope(store.TokenScopeSubmit, s.handleSetVerify))
+	mux.HandleFunc("GET /api/sessions/{id}/sampling", s.withScope(store.TokenScopeRead, s.handleGetSampling))
+	mux.HandleFunc("POST /api/sessions/{id}/sampling", s.withScope(store.TokenScopeSubmit, s.handleSetSampling))
+	mux.HandleFunc("POST /api/sessions/{id}/branch", s.withScope(store.TokenScopeSubmit, s.handleBranch))
+	mux.HandleFunc("GET /api/sessions/{id}/annotations", s.withScope(store.TokenScopeRead, s.handleGetAnnotations))
+	mux.HandleFunc("POST /api/sessions/{id}/messages/{seq}/annotation", s.withScope(store.TokenScopeSubmit, s.handleAnnotateMessage))
+	mux.HandleFunc("DELETE /api/sessions/{id}/messages/{seq}", s.withScope(store.TokenScopeSubmit, s.handleDeleteMessage))
+	mux.HandleFunc("POST /api/sessions/{id}/plan", s.withScope(store.TokenScopeSubmit, s.handleSetPlanMode))
+	mux.HandleFunc("GET /api/sessions/{id}/checkpoints", s.withScope(store.TokenScopeRead, s.handleListCheckpoints))
+	mux.HandleFunc("POST /api/sessions/{id}/checkpoints", s.withScope(store.TokenScopeSubmit, s.handleCreateCheckpoint))
+	mux.HandleFunc("GET /api/sessions/{id}/checkpoints/{n}/diff", s.withScope(store.TokenScopeRead, s.handleCheckpointDiff))
+	mux.HandleFunc("POST /api/sessions/{id}/undo", s.withScope(store.TokenScopeSubmit, s.handleUndo))
+	mux.HandleFunc("POST /api/sessions/{id}/redo", s.withScope(store.TokenScopeSubmit, s.handleRedo))
 	mux.HandleFunc("POST /api/config", s.withAuth(s.handleSetConfig))
 	mux.HandleFunc("GET /api/config", s.withAuth(s.handleGetConfig))
-	mux.HandleFunc("GET /api/mcp/tools", s.withAuth(s.handleMCPTools))
-	mux.HandleFunc("POST /api/sessions/{id}/consult", s.withAuth(s.handleConsult))
-	mux.HandleFunc("GET /api/sessions/{id}/status", s.withAuth(s.handleSessionStatus))
+	mux.HandleFunc("GET /api/tools", s.withScope(store.TokenScopeRead, s.handleListTools))
+	mux.HandleFunc("GET /api/mobile/sessions", s.withScope(store.TokenScopeRead, s.handleMobileSessions))
+	mux.HandleFunc("GET /api/mobile/sessions/{id}/messages", s.withScope(store.TokenScopeRead, s.handleMobileMessages))
+	mux.HandleFunc("POST /api/push/devices", s.withScope(store.TokenScopeSubmit, s.handleRegisterPushDevice))
+	mux.HandleFunc("GET /api/push/devices", s.withAuth(s.handleListPushDevices))
+	mux.HandleFunc("POST /api/notify/test", s.withAuth(s.handleNotifyTest))
+	mux.HandleFunc("POST /api/webhooks", s.withAuth(s.handleCreateWebhook))
+	mux.HandleFunc("GET /api/webhooks", s.withAuth(s.handleListWebhooks))
+	mux.HandleFunc("DELETE /api/webhooks/{id}", s.withAuth(s.handleDeleteWebhook))
+	mux.HandleFunc("GET /api/adapters", s.withAuth(s.handleListAdapters))
+	mux.HandleFunc("POST /api/adapters/{name}/start", s.withAuth(s.handleStartAdapter))
+	mux.HandleFunc("POST /api/adapters/{name}/stop", s.withAuth(s.handleStopAdapter))
+	mux.HandleFunc("DELETE /api/push/devices/{id}", s.withScope(store.TokenScopeSubmit, s.handleDeletePushDevice))
+	mux.HandleFunc("GET /api/memory", s.withScope(store.TokenScopeRead, s.handleGetMemory))
+	mux.HandleFunc("PUT /api/memory/{key}", s.withAuth(s.handleSetMemory))
+	mux.HandleFunc("DELETE /api/memory/{key}", s.withAuth(s.handleDeleteMemory))
+	mux.HandleFunc("POST /api/project/init", s.withAuth(s.handleProjectInit))
+	mux.HandleFunc("GET /api/mcp/tools", s.withScope(store.TokenScopeRead, s.handleMCPTools))
+	mux.HandleFunc("GET /api/mcp/servers", s.withScope(store.TokenScopeRead, s.handleListMCPServers))
+	mux.HandleFunc("POST /api/mcp/servers", s.withAuth(s.handleAddMCPServer))
+	mux.HandleFunc("DELETE /api/mcp/servers/{name}", s.withAuth(s.handleRemoveMCPServer))
+	mux.HandleFunc("POST /api/mcp/servers/{name}/restart", s.withAuth(s.handleRestartMCPServer))
+	mux.HandleFunc("GET /api/mcp/servers/{name}/logs", s.withScope(store.TokenScopeRead, s.handleMCPServerLogs))
+	mux.HandleFunc("GET /api/egress", s.withAuth(s.handleEgressReport))
+	mux.HandleFunc("GET /api/ollama", s.withScope(store.TokenScopeRead, s.handleOllamaStatus))
+	mux.HandleFunc("POST /api/ollama/pull", s.withAuth(s.handleOllamaPull))
+	mux.HandleFunc("DELETE /api/ollama/models/{name...}", s.withAuth(s.handleOllamaDelete))
+	mux.HandleFunc("GET /api/schedule", s.withScope(store.TokenScopeRead, s.handleListScheduled))
+	mux.HandleFunc("POST /api/schedule", s.withAuth(s.handleCreateScheduled))
+	mux.HandleFunc("DELETE /api/schedule/{id}", s.withAuth(s.handleCancelScheduled))
+	mux.HandleFunc("GET /api/drafts", s.withScope(store.TokenScopeRead, s.handleListDrafts))
+	mux.HandleFunc("POST /api/drafts/{id}/approve", s.withAuth(s.handleApproveDraft))
+	mux.HandleFunc("POST /api/drafts/{id}/reject", s.withAuth(s.handleRejectDraft))
+	mux.HandleFunc("GET /api/jobs", s.withScope(store.TokenScopeRead, s.handleListJobs))
+	mux.HandleFunc("POST /api/jobs", s.withScope(store.TokenScopeSubmit, s.handleCreateJob))
+	mux.HandleFunc("GET /api/jobs/{id}", s.withScope(store.TokenScopeRead, s.handleGetJob))
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", s.withScope(store.TokenScopeSubmit, s.handleCancelJob))
+	mux.HandleFunc("POST /api/sessions/{id}/feedback", s.withScope(store.TokenScopeSubmit, s.handleFeedback))
+	mux.HandleFunc("GET /api/stats", s.withScope(store.TokenScopeRead, s.handleStats))
+	mux.HandleFunc("GET /api/usage", s.withScope(store.TokenScopeRead, s.handleUsage))
+	mux.HandleFunc("GET /api/usage/optimize", s.withScope(store.TokenScopeRead, s.handleOptimize))
+	mux.HandleFunc("GET /api/blobs/{id}", s.withScope(store.TokenScopeRead, s.handleGetBlob))
+	mux.HandleFunc("POST /api/sessions/{id}/consult", s.withScope(store.TokenScopeSubmit, s.handleConsult))
+	mux.HandleFunc("POST /api/sessions/{id}/compact", s.withScope(store.TokenScopeSubmit, s.handleCompact))
+	mux.HandleFunc("GET /api/sessions/{id}/status", s.withScope(store.TokenScopeRead, s.handleSessionStatus))
+	mux.HandleFunc("GET /api/sessions/{id}/events", s.withScope(store.TokenScopeRead, s.handleSessionEvents))
+	mux.HandleFunc("GET /api/sessions/{id}/stream", s.withScope(store.TokenScopeRead, s.handleSessionStream))
+	mux.HandleFunc("GET /api/sessions/{id}/agents", s.withScope(store.TokenScopeRead, s.handleSubAgents))
+	mux.HandleFunc("GET /api/tokens", s.withAuth(s.handleListTokens))
+	mux.HandleFunc("POST /api/tokens", s.withAuth(s.handleCreateToken))
+	mux.HandleFunc("DELETE /api/tokens/{id}", s.withAuth(s.handleDeleteToken))
+	mux.HandleFunc("POST /api/sessions/{id}/share", s.withAuth(s.handleShareSession))
+	mux.HandleFunc("DELETE /api/sessions/{id}/share", s.withAuth(s.handleUnshareSession))
+	// Share links carry their own credential: the token in the path.
+	mux.HandleFunc("GET /share/{token}", s.handleSharePage)
+	mux.HandleFunc("GET /share/{token}/events", s.handleShareEvents)
 }
 
+// ScopeHeader names the scope a request made with the daemon token is
+// limited to. A hub sets it when it proxies for a person signed in with a
+// role short of admin.
+const ScopeHeader = "X-Muxd-Scope"
+
+// authScopeKey carries the scope of the request's token in its context.
+type authScopeKey struct{}
+
+// withAuth requires the admin scope: the daemon token or an admin API token.
 func (s *Server) withAuth(next http.HandlerFunc) http.HandlerFunc {
+	return s.withScope(store.TokenScopeAdmin, next)
+}
+
+// withScope requires a token with at least the given scope. The daemon's
+// own token and unix socket peers have every scope.
+func (s *Server) withScope(required string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		got := strings.TrimSpace(r.Header.Get("Authorization"))
-		const bearer = "Bearer "
-		if strings.HasPrefix(got, bearer) {
-			got = strings.TrimSpace(strings.TrimPrefix(got, bearer))
-		}
-		// Constant-time compare to avoid token oracle behavior.
-		if got == "" || s.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+		scope := s.requestScope(r)
+		if scope == "" {
 			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 			return
 		}
-		next(w, r)
+		if !store.ScopeAllows(scope, required) {
+			writeJSON(w, http.StatusForbidden, map[string]string{"error": "token scope " + scope + " does not allow this request"})
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), authScopeKey{}, scope)))
+	}
+}
+
+// requestScope returns the scope the request is authorized for, or "" if
+// it is not authorized at all.
+func (s *Server) requestScope(r *http.Request) string {
+	// Unix socket peers are already authorized by the socket's file mode.
+	if fromUnix, _ := r.Context().Value(unixConnKey{}).(bool); fromUnix {
+		return store.TokenScopeAdmin
+	}
+	got := strings.TrimSpace(r.Header.Get("Authorization"))
+	const bearer = "Bearer "
+	if strings.HasPrefix(got, bearer) {
+		got = strings.TrimSpace(strings.TrimPrefix(got, bearer))
+	}
+	if got == "" {
+		return ""
+	}
+	// Constant-time compare to avoid token oracle behavior.
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1 {
+		// A hub proxying for a signed-in person narrows its token to the
+		// person's role.
+		if scope, err := store.ParseTokenScope(r.Header.Get(ScopeHeader)); err == nil {
+			return scope
+		}
+		return store.TokenScopeAdmin
+	}
+	if s.store == nil {
+		return ""
 	}
+	// API tokens are looked up by hash, so timing does not reveal them.
+	t, err := s.store.LookupAPIToken(got)
+	if err != nil {
+		s.logf("auth: token lookup: %v", err)
+		return ""
+	}
+	if t == nil {
+		return ""
+	}
+	return t.Scope
+}
+
+// canSubmit reports whether the request's token may drive turns.
+func canSubmit(r *http.Request) bool {
+	scope, _ := r.Context().Value(authScopeKey{}).(string)
+	return store.ScopeAllows(scope, store.TokenScopeSubmit)
 }
 
 // ---------------------------------------------------------------------------
@@ -493,18 +977,7 @@ func (s *Server) handleQRCode(w http.ResponseWriter, r *http.Request) {
 	// Get preferred host from query param or auto-detect
 	host := r.URL.Query().Get("host")
 	if host == "" {
-		// If bound to 0.0.0.0, try to get local IP; otherwise use bind address
-		bindAddr := s.BindAddress()
-		if bindAddr == "0.0.0.0" || bindAddr == "" {
-			ips := GetLocalIPs()
-			if len(ips) > 0 {
-				host = ips[0]
-			} else {
-				host = "localhost"
-			}
-		} else {
-			host = bindAddr
-		}
+		host = s.advertisedHost()
 	}
 
 	// Parse size from query param, default to 256
@@ -554,57 +1027,79 @@ func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		ProjectPath string `json:"project_path"`
 		ModelID     string `json:"model_id"`
+		// Cwd runs the session's tools in another directory than the
+		// daemon's own.
+		Cwd string `json:"cwd"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
+	if req.Cwd != "" {
+		if !filepath.IsAbs(req.Cwd) {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cwd must be an absolute path"})
+			return
+		}
+		if fi, err := os.Stat(req.Cwd); err != nil || !fi.IsDir() {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "cwd is not a directory: " + req.Cwd})
+			return
+		}
+	}
 	modelID := req.ModelID
 	if modelID == "" {
 		modelID = s.modelID
 	}
-	sess, err := s.store.CreateSession(req.ProjectPath, modelID)
+	st, err := s.storeForProject(req.ProjectPath)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	sess, err := st.CreateSession(req.ProjectPath, modelID)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	s.rememberSession(sess.ID, st)
+	if req.Cwd != "" {
+		if err := st.SetSessionCwd(sess.ID, req.Cwd); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		sess.Cwd = req.Cwd
+	}
 	s.logf("session created id=%s model=%s", sess.ID, modelID)
+	s.emitSessionCreated(sess)
 	writeJSON(w, http.StatusOK, map[string]string{"session_id": sess.ID})
 }
 
 func (s *Server) handleGetSession(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-	sess, err := s.store.GetSession(id)
+	sess, _, err := s.findSession(r.PathValue("id"))
 	if err != nil {
-		// Try prefix match
-		sess, err = s.store.FindSessionByPrefix(id)
-		if err != nil {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
-			return
-		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
 	}
 	writeJSON(w, http.StatusOK, sess)
 }
 
 func (s *Server) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
-	id := r.PathValue("id")
-
 	// Try to find the session first (supports prefix match)
-	sess, err := s.store.GetSession(id)
+	sess, st, err := s.findSession(r.PathValue("id"))
 	if err != nil {
-		sess, err = s.store.FindSessionByPrefix(id)
-		if err != nil {
-			writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
-			return
-		}
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
 	}
 
 	// Clean up any active agent for this session
 	s.mu.Lock()
+	if ag, ok := s.agents[sess.ID]; ok {
+		ag.Close()
+	}
 	delete(s.agents, sess.ID)
 	s.mu.Unlock()
+	s.subAgents.forget(sess.ID)
+	s.events.drop(sess.ID)
 
-	if err := s.store.DeleteSession(sess.ID); err != nil {
+	if err := st.DeleteSession(sess.ID); err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -621,7 +1116,7 @@ func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
 			limit = n
 		}
 	}
-	sessions, err := s.store.ListSessions(project, limit)
+	sessions, err := s.listSessions(project, limit)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
@@ -629,16 +1124,82 @@ func (s *Server) handleListSessions(w http.ResponseWriter, r *http.Request) {
 	if sessions == nil {
 		sessions = []domain.Session{}
 	}
+	s.mu.Lock()
+	for i := range sessions {
+		if ag, ok := s.agents[sessions[i].ID]; ok {
+			sessions[i].Running = ag.IsRunning()
+		}
+	}
+	s.mu.Unlock()
 	writeJSON(w, http.StatusOK, sessions)
 }
 
+// handleGetMessages returns a session's messages. With ?offset= and/or
+// ?limit= it returns one page and reports the full count in X-Total-Count.
+// With ?after=N it returns only the messages after sequence N, so a client
+// that has a transcript can fetch what is new. Both the full and the
+// ?after= forms report the last sequence in X-Max-Sequence.
 func (s *Server) handleGetMessages(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
-	msgs, err := s.store.GetMessages(id)
+	q := r.URL.Query()
+	if q.Has("after") {
+		after, err := strconv.Atoi(q.Get("after"))
+		if err != nil || after < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid after"})
+			return
+		}
+		msgs, err := s.sessionStore(id).GetMessagesAfterSequence(id, after)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		if msgs == nil {
+			msgs = []domain.TranscriptMessage{}
+		}
+		// Sequences run 1, 2, 3... with no gaps.
+		w.Header().Set("X-Max-Sequence", strconv.Itoa(after+len(msgs)))
+		writeJSON(w, http.StatusOK, msgs)
+		return
+	}
+	if !q.Has("offset") && !q.Has("limit") {
+		msgs, err := s.sessionStore(id).GetMessages(id)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		w.Header().Set("X-Max-Sequence", strconv.Itoa(len(msgs)))
+		writeJSON(w, http.StatusOK, msgs)
+		return
+	}
+
+	var offset, limit int
+	for name, dst := range map[string]*int{"offset": &offset, "limit": &limit} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid " + name})
+			return
+		}
+		*dst = n
+	}
+	st := s.sessionStore(id)
+	total, err := st.CountMessages(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	msgs, err := st.GetMessagesPage(id, offset, limit)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	if msgs == nil {
+		msgs = []domain.TranscriptMessage{}
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	writeJSON(w, http.StatusOK, msgs)
 }
 
@@ -646,19 +1207,12 @@ func (s *Server) handleGetMessages(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.PathValue("id")
 
-	var req struct {
-		Text   string `json:"text"`
-		Images []struct {
-			Path      string `json:"path"`
-			MediaType string `json:"media_type"`
-			Data      string `json:"data"`
-		} `json:"images,omitempty"`
-	}
+	var req submitRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
-	if strings.TrimSpace(req.Text) == "" && len(req.Images) == 0 {
+	if req.empty() {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "empty text"})
 		return
 	}
@@ -673,52 +1227,119 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Set SSE headers
-	w.Header().Set("Content-Type", "text/event-stream")
-	w.Header().Set("Cache-Control", "no-cache")
-	w.Header().Set("Connection", "keep-alive")
-
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
 		return
 	}
-	// Events can be emitted from parallel tool goroutines. Serialize SSE writes
-	// to avoid interleaved chunked responses (which appear as malformed chunked encoding).
-	var sseMu sync.Mutex
-	sendSSE := func(event string, data any) {
-		sseMu.Lock()
-		defer sseMu.Unlock()
-		writeSSE(w, flusher, event, data)
+
+	// The turn runs on its own and the response follows its event log, so
+	// a client whose connection drops can pick up the rest of the turn
+	// from GET /api/sessions/{id}/stream.
+	s.logf("submit session=%s len=%d attachments=%d", sessionID, len(req.Text), len(req.Images)+len(req.Attachments))
+	log := s.events.get(sessionID)
+	log.start()
+	go func() {
+		defer log.finish()
+		s.runTurn(sessionID, ag, req, s.agentEventHandler(sessionID, func(string, any) {}))
+	}()
+	streamEventLog(w, r, flusher, log, 0)
+}
+
+// submitRequest is a user message as sent by either transport.
+type submitRequest struct {
+	Text        string             `json:"text"`
+	Images      []SubmitImage      `json:"images,omitempty"`
+	Attachments []SubmitAttachment `json:"attachments,omitempty"`
+}
+
+func (r submitRequest) empty() bool {
+	return strings.TrimSpace(r.Text) == "" && len(r.Images) == 0 && len(r.Attachments) == 0
+}
+
+// attachments decodes the request's images and attachments.
+func (r submitRequest) attachments() ([]agent.Attachment, error) {
+	var out []agent.Attachment
+	for _, img := range r.Images {
+		data, err := base64.StdEncoding.DecodeString(img.Data)
+		if err != nil {
+			return nil, fmt.Errorf("image %s: invalid base64: %w", img.Path, err)
+		}
+		out = append(out, agent.Attachment{Name: img.Path, MediaType: img.MediaType, Data: data})
+	}
+	for _, att := range r.Attachments {
+		data, err := base64.StdEncoding.DecodeString(att.Data)
+		if err != nil {
+			return nil, fmt.Errorf("attachment %s: invalid base64: %w", att.Name, err)
+		}
+		out = append(out, agent.Attachment{Name: att.Name, MediaType: att.MediaType, Data: data})
+	}
+	return out, nil
+}
+
+// runSubmit runs one agent turn for req, blocking until it finishes, and
+// keeps its events in the session's event log.
+func (s *Server) runSubmit(sessionID string, ag *agent.Service, req submitRequest, onEvent agent.EventFunc) {
+	log := s.events.get(sessionID)
+	log.start()
+	defer log.finish()
+	s.runTurn(sessionID, ag, req, onEvent)
+}
+
+// runTurn runs one agent turn for req, blocking until it finishes.
+func (s *Server) runTurn(sessionID string, ag *agent.Service, req submitRequest, onEvent agent.EventFunc) {
+	attachments, err := req.attachments()
+	if err != nil {
+		onEvent(agent.Event{Kind: agent.EventError, Err: err})
+		return
+	}
+	s.shareUserMessage(sessionID, req)
+	if len(attachments) == 0 {
+		ag.Submit(req.Text, onEvent)
+		return
 	}
+	ag.SubmitAttachments(req.Text, attachments, onEvent)
+}
 
-	s.logf("submit session=%s len=%d images=%d", sessionID, len(req.Text), len(req.Images))
-	onEvent := func(evt agent.Event) {
+// agentEventHandler translates agent events into named stream events and
+// passes them to send. The event names and payloads are the same for SSE
+// and WebSocket clients. send must be safe for concurrent use.
+func (s *Server) agentEventHandler(sessionID string, send func(event string, data any)) agent.EventFunc {
+	send = s.teeToWebhooks(sessionID, s.teeToNotify(sessionID, s.teeToPush(sessionID, s.teeToViewers(sessionID, s.teeToEventLog(sessionID, send)))))
+	return func(evt agent.Event) {
 		switch evt.Kind {
 		case agent.EventDelta:
-			sendSSE("delta", map[string]string{"text": evt.DeltaText})
+			send("delta", map[string]string{"text": evt.DeltaText})
 
 		case agent.EventToolStart:
-			sendSSE("tool_start", map[string]any{
+			send("tool_start", map[string]any{
 				"tool_use_id": evt.ToolUseID,
 				"tool_name":   evt.ToolName,
 				"tool_input":  evt.ToolInput,
 			})
 
 		case agent.EventToolDone:
-			sendSSE("tool_done", map[string]any{
+			data := map[string]any{
 				"tool_use_id": evt.ToolUseID,
 				"tool_name":   evt.ToolName,
 				"result":      evt.ToolResult,
 				"is_error":    evt.ToolIsError,
-			})
+			}
+			if evt.ToolIsError {
+				data["code"] = domain.ErrToolFailed
+			}
+			send("tool_done", data)
 
 		case agent.EventStreamDone:
-			sendSSE("stream_done", map[string]any{
+			s.tokensUsed.Add(int64(evt.InputTokens + evt.OutputTokens))
+			send("stream_done", map[string]any{
 				"input_tokens":                evt.InputTokens,
 				"output_tokens":               evt.OutputTokens,
 				"cache_creation_input_tokens": evt.CacheCreationInputTokens,
 				"cache_read_input_tokens":     evt.CacheReadInputTokens,
+				"context_tokens":              evt.ContextTokens,
+				"context_window":              evt.ContextWindow,
+				"compact_at":                  evt.CompactAt,
 				"stop_reason":                 evt.StopReason,
 			})
 
@@ -728,20 +1349,44 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 			s.askChans[askID] = evt.AskResponse
 			s.mu.Unlock()
 
-			sendSSE("ask_user", map[string]string{
+			data := map[string]any{
 				"ask_id": askID,
 				"prompt": evt.AskPrompt,
+			}
+			if len(evt.AskOptions) > 0 {
+				data["options"] = evt.AskOptions
+			}
+			send("ask_user", data)
+
+		case agent.EventApprovalRequired:
+			approvalID := domain.NewUUID()
+			s.mu.Lock()
+			s.approvalChans[approvalID] = evt.ApprovalResponse
+			s.mu.Unlock()
+
+			send("approval_required", map[string]any{
+				"approval_id": approvalID,
+				"tool_use_id": evt.ToolUseID,
+				"tool_name":   evt.ToolName,
+				"tool_input":  evt.ToolInput,
 			})
 
 		case agent.EventRetrying:
-			sendSSE("retrying", map[string]any{
+			send("retrying", map[string]any{
 				"attempt": evt.RetryAttempt,
 				"wait_ms": evt.RetryAfter.Milliseconds(),
 				"message": evt.RetryMessage,
 			})
 
+		case agent.EventDiagram:
+			data := map[string]string{"kind": evt.DiagramKind, "path": evt.DiagramPath}
+			if evt.Err != nil {
+				data["error"] = evt.Err.Error()
+			}
+			send("diagram", data)
+
 		case agent.EventTurnDone:
-			sendSSE("turn_done", map[string]string{
+			send("turn_done", map[string]string{
 				"stop_reason": evt.StopReason,
 			})
 
@@ -750,37 +1395,65 @@ func (s *Server) handleSubmit(w http.ResponseWriter, r *http.Request) {
 			if evt.Err != nil {
 				errMsg = evt.Err.Error()
 			}
-			s.logf("error session=%s: %s", sessionID, errMsg)
-			sendSSE("error", map[string]string{"error": errMsg})
+			code := evt.ErrCode
+			if code == "" {
+				code = domain.ErrorCodeOf(evt.Err)
+			}
+			s.logf("error session=%s code=%s: %s", sessionID, code, errMsg)
+			var budgetErr *agent.BudgetError
+			if errors.As(evt.Err, &budgetErr) {
+				send("error", map[string]any{"error": errMsg, "code": code, "budget": budgetData(budgetErr.BudgetStatus)})
+				break
+			}
+			send("error", map[string]any{"error": errMsg, "code": code})
+
+		case agent.EventBudgetWarning:
+			if evt.Budget != nil {
+				s.logf("budget session=%s: %s", sessionID, evt.Budget)
+				send("budget_warning", budgetData(*evt.Budget))
+			}
+
+		case agent.EventSubAgent:
+			if evt.SubAgent != nil {
+				info := subAgentInfo(*evt.SubAgent)
+				s.subAgents.update(sessionID, info)
+				send("subagent", info)
+			}
+
+		case agent.EventSteered:
+			send("steered", map[string]any{"notes": evt.Steering})
+
+		case agent.EventVerified:
+			if v := evt.Verification; v != nil {
+				s.tokensUsed.Add(int64(v.InputTokens + v.OutputTokens))
+				info := VerifyInfo{Model: v.Model, OK: v.OK, Critique: v.Critique, InputTokens: v.InputTokens, OutputTokens: v.OutputTokens}
+				if v.Err != nil {
+					info.Error = v.Err.Error()
+				}
+				send("verified", info)
+			}
 
 		case agent.EventCompacted:
-			sendSSE("compacted", map[string]string{"model": evt.ModelUsed})
+			send("compacted", map[string]string{"model": evt.ModelUsed})
 
 		case agent.EventTitled:
-			sendSSE("titled", map[string]string{
+			send("titled", map[string]string{
 				"title": evt.NewTitle,
 				"tags":  evt.NewTags,
 				"model": evt.ModelUsed,
 			})
 		}
 	}
+}
 
-	if len(req.Images) > 0 {
-		var blocks []domain.ContentBlock
-		for _, img := range req.Images {
-			blocks = append(blocks, domain.ContentBlock{
-				Type:       "image",
-				MediaType:  img.MediaType,
-				Base64Data: img.Data,
-				ImagePath:  img.Path,
-			})
-		}
-		if strings.TrimSpace(req.Text) != "" {
-			blocks = append(blocks, domain.ContentBlock{Type: "text", Text: strings.TrimSpace(req.Text)})
-		}
-		ag.SubmitBlocks(blocks, onEvent)
-	} else {
-		ag.Submit(req.Text, onEvent)
+// budgetData is the SSE form of a budget's spend.
+func budgetData(b agent.BudgetStatus) map[string]any {
+	return map[string]any{
+		"scope":     b.Scope,
+		"key":       b.Key(),
+		"spent_usd": b.SpentUSD,
+		"limit_usd": b.LimitUSD,
+		"message":   b.String(),
 	}
 }
 
@@ -798,32 +1471,124 @@ func (s *Server) handleCancel(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "canceled"})
 }
 
-func (s *Server) handleAskResponse(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		AskID  string `json:"ask_id"`
-		Answer string `json:"answer"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+// handleStop ends the session's running turn gracefully: running tools
+// finish and the work so far is kept. See agent.Service.Stop.
+func (s *Server) handleStop(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	s.mu.Lock()
+	ag, ok := s.agents[sessionID]
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no active agent for session"})
+		return
+	}
+	ag.Stop()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "stopping"})
+}
+
+// handleSteer adds a note from the user to the session's running turn,
+// before its next model call.
+func (s *Server) handleSteer(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	var req struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	s.mu.Lock()
+	ag, ok := s.agents[sessionID]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no active agent for session"})
+		return
+	}
+	if err := ag.Steer(req.Text); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, agent.ErrNotRunning) {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	s.logf("steer session=%s len=%d", sessionID, len(req.Text))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "queued"})
+}
+
+func (s *Server) handleAskResponse(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		AskID  string `json:"ask_id"`
+		Answer string `json:"answer"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
 
+	if !s.answerAsk(req.AskID, req.Answer) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown ask_id"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// answerAsk delivers answer to a pending ask_user question. It reports
+// false if askID is unknown or already answered.
+func (s *Server) answerAsk(askID, answer string) bool {
 	s.mu.Lock()
-	ch, ok := s.askChans[req.AskID]
+	ch, ok := s.askChans[askID]
 	if ok {
-		delete(s.askChans, req.AskID)
+		delete(s.askChans, askID)
 	}
 	s.mu.Unlock()
+	if ok {
+		ch <- answer
+	}
+	return ok
+}
 
-	if !ok {
-		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown ask_id"})
+// handleApprove resolves a pending approval_required event. decision is
+// allow, deny, or always (which also adds the tool to the session
+// allowlist).
+func (s *Server) handleApprove(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ApprovalID string `json:"approval_id"`
+		Decision   string `json:"decision"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	decision, err := agent.ParseApprovalDecision(req.Decision)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	ch <- req.Answer
+	if !s.answerApproval(req.ApprovalID, decision) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown approval_id"})
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+// answerApproval delivers decision to a pending approval_required event.
+// It reports false if approvalID is unknown or already answered.
+func (s *Server) answerApproval(approvalID string, decision agent.ApprovalDecision) bool {
+	s.mu.Lock()
+	ch, ok := s.approvalChans[approvalID]
+	if ok {
+		delete(s.approvalChans, approvalID)
+	}
+	s.mu.Unlock()
+	if ok {
+		ch <- decision
+	}
+	return ok
+}
+
 func (s *Server) handleSetModel(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.PathValue("id")
 	var req struct {
@@ -850,192 +1615,1211 @@ func (s *Server) handleSetModel(w http.ResponseWriter, r *http.Request) {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": provErr.Error()})
 		return
 	}
-
-	s.mu.Lock()
-	newAPIKey := s.apiKey
-	if key, err := config.LoadProviderAPIKey(*s.prefs, newProviderName); err == nil {
-		newAPIKey = key
+
+	s.mu.Lock()
+	newAPIKey := s.apiKey
+	if key, err := config.LoadProviderAPIKey(*s.prefs, newProviderName); err == nil {
+		newAPIKey = key
+	}
+	s.modelID = req.ModelID
+	s.modelLabel = req.Label
+	s.provider = newProvider
+	s.apiKey = newAPIKey
+	if s.prefs != nil {
+		s.prefs.Model = req.Label
+		s.prefs.Provider = newProviderName
+	}
+	ag, ok := s.agents[sessionID]
+	s.mu.Unlock()
+
+	if ok {
+		ag.SetProvider(newProvider, newAPIKey)
+		ag.SetModel(req.Label, req.ModelID)
+	} else {
+		if err := s.sessionStore(sessionID).UpdateSessionModel(sessionID, req.ModelID); err != nil {
+			s.logf("daemon: update session model: %v", err)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":   "ok",
+		"label":    req.Label,
+		"model_id": req.ModelID,
+	})
+}
+
+func (s *Server) handleSetTitle(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	var req struct {
+		Title string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	if err := s.sessionStore(sessionID).UpdateSessionTitle(sessionID, req.Title); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// Mark the agent as user-renamed so auto-title doesn't overwrite.
+	s.mu.Lock()
+	if ag, ok := s.agents[sessionID]; ok {
+		ag.SetUserRenamed()
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "ok",
+		"title":  req.Title,
+	})
+}
+
+func (s *Server) handleGetStyle(w http.ResponseWriter, r *http.Request) {
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	language, tone := ag.Style()
+	writeJSON(w, http.StatusOK, SessionStyle{Language: language, Tone: tone})
+}
+
+// handleGetContext returns what the session's next model call would send,
+// for debugging what the model can and cannot see.
+func (s *Server) handleGetContext(w http.ResponseWriter, r *http.Request) {
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	snap := ag.Context()
+	resp := SessionContext{
+		Provider:        snap.Provider,
+		Model:           snap.Model,
+		System:          snap.System,
+		SystemTokens:    snap.SystemTokens,
+		Summary:         snap.Summary,
+		Pinned:          []ContextPin{},
+		Messages:        []ContextMessage{},
+		Tools:           snap.Tools,
+		ToolTokens:      snap.ToolTokens,
+		EstimatedTokens: snap.EstimatedTokens,
+		LastInputTokens: snap.LastInputTokens,
+	}
+	for _, p := range snap.Pinned {
+		resp.Pinned = append(resp.Pinned, ContextPin{Key: p.Key, Value: p.Value})
+	}
+	for _, m := range snap.Messages {
+		resp.Messages = append(resp.Messages, ContextMessage{Role: m.Role, Content: m.Content, Blocks: m.Blocks, Tokens: m.Tokens})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleSetStyle(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Language *string `json:"language"`
+		Tone     *string `json:"tone"`
+		Reset    bool    `json:"reset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	language, tone := ag.Style()
+	if req.Reset {
+		s.mu.Lock()
+		if s.prefs != nil {
+			language, tone = s.prefs.StyleLanguage, s.prefs.StyleTone
+		} else {
+			language, tone = "", ""
+		}
+		s.mu.Unlock()
+	}
+	if req.Language != nil {
+		language = strings.TrimSpace(*req.Language)
+		if strings.EqualFold(language, "default") {
+			language = ""
+		}
+	}
+	if req.Tone != nil {
+		t, err := config.ParseStyleTone(*req.Tone)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		tone = t
+	}
+	ag.SetStyle(language, tone)
+	writeJSON(w, http.StatusOK, SessionStyle{Language: language, Tone: tone})
+}
+
+func (s *Server) handleGetVerify(w http.ResponseWriter, r *http.Request) {
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	on, model := ag.Verify()
+	writeJSON(w, http.StatusOK, SessionVerify{Enabled: on, Model: model})
+}
+
+func (s *Server) handleSetVerify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled bool   `json:"enabled"`
+		Model   string `json:"model"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	ag.SetVerify(req.Enabled, req.Model)
+	on, model := ag.Verify()
+	writeJSON(w, http.StatusOK, SessionVerify{Enabled: on, Model: model})
+}
+
+func (s *Server) handleGetSampling(w http.ResponseWriter, r *http.Request) {
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, sessionSampling(ag.ProviderName(), ag.Sampling()))
+}
+
+func (s *Server) handleSetSampling(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Temperature *string `json:"temperature"`
+		TopP        *string `json:"top_p"`
+		Seed        *string `json:"seed"`
+		MaxTokens   *string `json:"max_tokens"`
+		Stop        *string `json:"stop"`
+		Reset       bool    `json:"reset"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	sampling := ag.Sampling()
+	if req.Reset {
+		s.mu.Lock()
+		sampling = provider.Sampling{}
+		if s.prefs != nil {
+			sampling = samplingDefaults(*s.prefs)
+		}
+		s.mu.Unlock()
+	}
+	if req.Temperature != nil {
+		if sampling.Temperature, err = parseSamplingValue(*req.Temperature, config.ParseTemperature); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.TopP != nil {
+		if sampling.TopP, err = parseSamplingValue(*req.TopP, config.ParseTopP); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.Seed != nil {
+		if sampling.Seed, err = parseSamplingValue(*req.Seed, config.ParseSeed); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.MaxTokens != nil {
+		n, err := parseSamplingValue(*req.MaxTokens, config.ParseMaxTokens)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		sampling.MaxTokens = 0
+		if n != nil {
+			sampling.MaxTokens = *n
+		}
+	}
+	if req.Stop != nil {
+		stop, err := parseSamplingValue(*req.Stop, config.ParseStopSequences)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		sampling.Stop = nil
+		if stop != nil {
+			sampling.Stop = *stop
+		}
+	}
+	if err := provider.ValidateSampling(ag.ProviderName(), sampling); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	ag.SetSampling(sampling)
+	writeJSON(w, http.StatusOK, sessionSampling(ag.ProviderName(), sampling))
+}
+
+// samplingDefaults converts the configured sampling defaults.
+func samplingDefaults(prefs config.Preferences) provider.Sampling {
+	d := prefs.Sampling()
+	return provider.Sampling{Temperature: d.Temperature, TopP: d.TopP, Seed: d.Seed, MaxTokens: d.MaxTokens, Stop: d.Stop}
+}
+
+// parseSamplingValue parses one sampling parameter; empty or "default"
+// clears it.
+func parseSamplingValue[T any](v string, parse func(string) (T, error)) (*T, error) {
+	v = strings.TrimSpace(v)
+	if v == "" || strings.EqualFold(v, "default") {
+		return nil, nil
+	}
+	n, err := parse(v)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func sessionSampling(providerName string, s provider.Sampling) SessionSampling {
+	return SessionSampling{
+		Temperature:      s.Temperature,
+		TopP:             s.TopP,
+		Seed:             s.Seed,
+		MaxTokens:        s.MaxTokens,
+		Stop:             s.Stop,
+		Provider:         providerName,
+		DefaultMaxTokens: provider.DefaultMaxTokens(providerName),
+		SupportsSeed:     provider.SupportsSeed(providerName),
+	}
+}
+
+func (s *Server) handleSetPlanMode(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "enabled is required"})
+		return
+	}
+
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	ag.SetPlanMode(*req.Enabled)
+	writeJSON(w, http.StatusOK, map[string]bool{"plan_mode": ag.PlanMode()})
+}
+
+func (s *Server) handleBranch(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	var req struct {
+		AtSequence int `json:"at_sequence"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	st := s.sessionStore(sessionID)
+	newSess, err := st.BranchSession(sessionID, req.AtSequence)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.rememberSession(newSess.ID, st)
+	s.emitSessionCreated(newSess)
+	writeJSON(w, http.StatusOK, newSess)
+}
+
+// messageSequence parses the {seq} path value.
+func messageSequence(r *http.Request) (int, bool) {
+	seq, err := strconv.Atoi(r.PathValue("seq"))
+	return seq, err == nil && seq > 0
+}
+
+func (s *Server) handleGetAnnotations(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	notes, err := s.sessionStore(id).MessageAnnotations(id)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, notes)
+}
+
+func (s *Server) handleAnnotateMessage(w http.ResponseWriter, r *http.Request) {
+	seq, ok := messageSequence(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid sequence"})
+		return
+	}
+	var req struct {
+		Note string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.sessionStore(id).AnnotateMessage(id, seq, req.Note); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"sequence": seq, "note": strings.TrimSpace(req.Note)})
+}
+
+// handleDeleteMessage removes a message from a session and reloads a
+// loaded agent's history, so the model no longer sees it.
+func (s *Server) handleDeleteMessage(w http.ResponseWriter, r *http.Request) {
+	seq, ok := messageSequence(r)
+	if !ok {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid sequence"})
+		return
+	}
+	sessionID := r.PathValue("id")
+
+	s.mu.Lock()
+	ag := s.agents[sessionID]
+	s.mu.Unlock()
+	if ag != nil && ag.IsRunning() {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "cannot delete messages while a turn is running"})
+		return
+	}
+
+	if err := s.sessionStore(sessionID).DeleteMessage(sessionID, seq); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if ag != nil {
+		if err := ag.Resume(); err != nil {
+			s.logf("daemon: reload agent %s: %v", sessionID, err)
+		}
+	}
+	s.logf("message deleted session=%s seq=%d", sessionID, seq)
+	writeJSON(w, http.StatusOK, map[string]int{"sequence": seq})
+}
+
+// projectMemory returns the memory file of the daemon's working directory,
+// the same one its agents read and write.
+func (s *Server) projectMemory() (*tools.ProjectMemory, error) {
+	cwd, _ := tools.Getwd()
+	if cwd == "" {
+		return nil, fmt.Errorf("cannot determine working directory")
+	}
+	return tools.NewProjectMemory(cwd), nil
+}
+
+func (s *Server) handleGetMemory(w http.ResponseWriter, r *http.Request) {
+	mem, err := s.projectMemory()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	facts, err := mem.Load()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"facts": facts})
+}
+
+func (s *Server) handleSetMemory(w http.ResponseWriter, r *http.Request) {
+	key := strings.TrimSpace(r.PathValue("key"))
+	var req struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if key == "" || strings.TrimSpace(req.Value) == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "key and value are required"})
+		return
+	}
+	mem, err := s.projectMemory()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.memoryMu.Lock()
+	defer s.memoryMu.Unlock()
+	facts, err := mem.Load()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	facts[key] = req.Value
+	if err := mem.Save(facts); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleDeleteMemory(w http.ResponseWriter, r *http.Request) {
+	key := r.PathValue("key")
+	mem, err := s.projectMemory()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	s.memoryMu.Lock()
+	defer s.memoryMu.Unlock()
+	facts, err := mem.Load()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if _, ok := facts[key]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "key " + key + " not found in project memory"})
+		return
+	}
+	delete(facts, key)
+	if err := mem.Save(facts); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
+
+// handleProjectInit gives the daemon's project a starting setup: a
+// .muxd.toml, a .mcp.json template, and detected facts in project memory.
+func (s *Server) handleProjectInit(w http.ResponseWriter, r *http.Request) {
+	cwd, _ := tools.Getwd()
+	if cwd == "" {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "cannot determine working directory"})
+		return
+	}
+	s.memoryMu.Lock()
+	res, err := scaffold.Init(cwd)
+	s.memoryMu.Unlock()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.logf("daemon: initialized project %s (%s)", cwd, res.Project)
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	prefs := *s.prefs
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, prefs)
+}
+
+func (s *Server) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+
+	s.mu.Lock()
+	if err := s.prefs.Set(req.Key, req.Value); err != nil {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := config.SavePreferences(*s.prefs); err != nil {
+		s.mu.Unlock()
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	// Agents read policy settings (guardrails, PII, messaging accounts)
+	// from their preferences snapshot, so refresh it.
+	agentPrefs := s.agentPrefs()
+	for _, ag := range s.agents {
+		ag.SetPreferences(*agentPrefs)
+	}
+	// If an API key was changed, re-resolve and update the server's active key
+	if strings.HasSuffix(req.Key, ".api_key") {
+		provName := strings.TrimSuffix(req.Key, ".api_key")
+		if name, _, ok := config.ParseCustomProviderKey(req.Key); ok {
+			provName = name
+		}
+		if key, err := config.LoadProviderAPIKey(*s.prefs, provName); err == nil {
+			// Only update the server's active key if this is the active provider
+			if s.provider != nil && s.provider.Name() == provName {
+				s.apiKey = key
+				// Update all existing agents with the new key
+				for _, ag := range s.agents {
+					ag.SetProvider(s.provider, key)
+				}
+			}
+		}
+	}
+	if req.Key == "ollama.url" {
+		provider.SetOllamaBaseURL(req.Value)
+	}
+	if req.Key == "zai.coding_plan" {
+		b, _ := config.ParseBoolish(req.Value)
+		provider.SetZAICodingPlan(b)
+	}
+	if strings.HasPrefix(req.Key, "azure.") {
+		provider.SetAzureEndpoint(s.prefs.AzureResource())
+		provider.SetAzureADCredentials(s.prefs.AzureAD())
+	}
+	if name, _, ok := config.ParseCustomProviderKey(req.Key); ok {
+		c := s.prefs.CustomProviders[name]
+		provider.SetCustomProvider(name, c.BaseURL, c.ModelList())
+	}
+	if req.Key == "brave.api_key" {
+		for _, ag := range s.agents {
+			ag.SetBraveAPIKey(req.Value)
+		}
+	}
+	if req.Key == "textbelt.api_key" {
+		for _, ag := range s.agents {
+			ag.SetTextbeltAPIKey(req.Value)
+		}
+	}
+	if strings.HasPrefix(req.Key, "push.") {
+		s.pushSenders = nil // rebuilt on the next notification
+	}
+	if strings.HasPrefix(req.Key, "notify.") {
+		s.notifier = nil
+	}
+	if req.Key == "tools.disabled" || req.Key == "tools.ask_user" {
+		disabled := agentPrefs.DisabledToolsSet()
+		for _, ag := range s.agents {
+			ag.SetDisabledTools(disabled)
+		}
+	}
+	if req.Key == "model.compact" {
+		_, id := provider.ResolveProviderAndModel(req.Value, s.provider.Name())
+		for _, ag := range s.agents {
+			ag.SetModelCompact(id)
+		}
+	}
+	if req.Key == "model.title" {
+		_, id := provider.ResolveProviderAndModel(req.Value, s.provider.Name())
+		for _, ag := range s.agents {
+			ag.SetModelTitle(id)
+		}
+	}
+	if req.Key == "model.tags" {
+		_, id := provider.ResolveProviderAndModel(req.Value, s.provider.Name())
+		for _, ag := range s.agents {
+			ag.SetModelTags(id)
+		}
+	}
+	display := s.prefs.Get(req.Key)
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":  "ok",
+		"message": fmt.Sprintf("Set %s = %s", req.Key, display),
+	})
+}
+
+// ToolInfo describes one tool the agent can call, with its arguments as a
+// JSON Schema, whether tools.disabled lets it run, and which tool profiles
+// leave it enabled.
+type ToolInfo struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Source      string         `json:"source"` // "builtin", "mcp" or "custom"
+	InputSchema map[string]any `json:"input_schema"`
+	Enabled     bool           `json:"enabled"`
+	Profiles    []string       `json:"profiles"`
+}
+
+func (s *Server) handleListTools(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	mgr := s.mcpManager
+	custom := s.customToolRegistry
+	disabled := map[string]bool{}
+	if s.prefs != nil {
+		disabled = s.prefs.DisabledToolsSet()
+	}
+	s.mu.Unlock()
+
+	list := []ToolInfo{}
+	add := func(source string, specs []provider.ToolSpec) {
+		for _, spec := range specs {
+			list = append(list, ToolInfo{
+				Name:        spec.Name,
+				Description: spec.Description,
+				Source:      source,
+				InputSchema: spec.JSONSchema(),
+				Enabled:     !disabled[spec.Name],
+				Profiles:    tools.ToolProfilesFor(spec.Name),
+			})
+		}
+	}
+	add("builtin", tools.AllToolSpecs())
+	if mgr != nil {
+		add("mcp", mgr.ToolSpecs())
+	}
+	if custom != nil {
+		add("custom", custom.Specs())
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tools": list})
+}
+
+func (s *Server) handleMCPTools(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	mgr := s.mcpManager
+	s.mu.Unlock()
+	if mgr == nil {
+		writeJSON(w, http.StatusOK, map[string]any{
+			"tools":    []string{},
+			"statuses": map[string]string{},
+			"servers":  []mcp.ServerInfo{},
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"tools":    mgr.ToolNames(),
+		"statuses": mgr.ServerStatuses(),
+		"servers":  mgr.Servers(),
+	})
+}
+
+// ensureMCP returns the MCP manager, creating it and handing it to every agent
+// when no servers were configured at startup.
+func (s *Server) ensureMCP() *mcp.Manager {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mcpManager == nil {
+		s.mcpManager = mcp.NewManager()
+		for _, ag := range s.agents {
+			ag.SetMCPManager(s.mcpManager)
+		}
+	}
+	return s.mcpManager
+}
+
+// MCPServerRequest is the body of POST /api/mcp/servers. With Save set the
+// server is also written to the user-scope mcp.json.
+type MCPServerRequest struct {
+	Name string `json:"name"`
+	mcp.ServerConfig
+	Save bool `json:"save,omitempty"`
+}
+
+// mcpServerInfo returns the listing entry for name.
+func mcpServerInfo(mgr *mcp.Manager, name string) (mcp.ServerInfo, bool) {
+	for _, info := range mgr.Servers() {
+		if info.Name == name {
+			return info, true
+		}
+	}
+	return mcp.ServerInfo{}, false
+}
+
+func (s *Server) handleListMCPServers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	mgr := s.mcpManager
+	s.mu.Unlock()
+	servers := []mcp.ServerInfo{}
+	if mgr != nil {
+		servers = mgr.Servers()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"servers": servers})
+}
+
+// handleAddMCPServer connects a server at runtime. A server that fails to
+// connect is still added; the returned entry carries the error.
+func (s *Server) handleAddMCPServer(w http.ResponseWriter, r *http.Request) {
+	var req MCPServerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	mgr := s.ensureMCP()
+	if err := mgr.AddServer(context.Background(), req.Name, req.ServerConfig); err != nil {
+		if _, ok := mcpServerInfo(mgr, req.Name); !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	if req.Save {
+		if err := mcp.SaveUserServer(req.Name, req.ServerConfig); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "saving mcp.json: " + err.Error()})
+			return
+		}
+	}
+	info, _ := mcpServerInfo(mgr, req.Name)
+	s.logf("mcp: added server %q (%s)", req.Name, info.Status)
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleRemoveMCPServer disconnects a server. ?save=true also deletes it
+// from the user-scope mcp.json; a project .mcp.json is never edited.
+func (s *Server) handleRemoveMCPServer(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if err := s.ensureMCP().RemoveServer(name); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	if r.URL.Query().Get("save") == "true" {
+		if _, err := mcp.RemoveUserServer(name); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "saving mcp.json: " + err.Error()})
+			return
+		}
+	}
+	s.logf("mcp: removed server %q", name)
+	writeJSON(w, http.StatusOK, map[string]string{"status": "removed"})
+}
+
+// handleRestartMCPServer reconnects a server. If the server is defined in
+// .mcp.json or the user mcp.json, the file's current entry is used, so a
+// fixed config takes effect without restarting muxd.
+func (s *Server) handleRestartMCPServer(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	mgr := s.ensureMCP()
+	if _, ok := mcpServerInfo(mgr, name); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown MCP server %q", name)})
+		return
+	}
+	cwd, _ := tools.Getwd()
+	cfg, err := mcp.LoadMCPConfig(cwd)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// A failed connection is reported in the returned entry's status.
+	if sc, ok := cfg.MCPServers[name]; ok {
+		_ = mgr.AddServer(context.Background(), name, sc)
+	} else {
+		_ = mgr.RestartServer(context.Background(), name)
+	}
+	info, ok := mcpServerInfo(mgr, name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("MCP server %q was removed during restart", name)})
+		return
+	}
+	s.logf("mcp: restarted server %q (%s)", name, info.Status)
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleMCPServerLogs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	mgr := s.mcpManager
+	s.mu.Unlock()
+	name := r.PathValue("name")
+	if mgr == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("unknown MCP server %q", name)})
+		return
+	}
+	lines, err := mgr.Logs(name)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"lines": lines})
+}
+
+func (s *Server) handleEgressReport(w http.ResponseWriter, r *http.Request) {
+	policy := egress.Default()
+	if policy == nil {
+		writeJSON(w, http.StatusOK, EgressReport{Mode: string(egress.ModeOff), Hosts: []egress.HostStat{}})
+		return
+	}
+	writeJSON(w, http.StatusOK, EgressReport{Mode: string(policy.Mode()), Hosts: policy.Report()})
+}
+
+// handleListScheduled returns scheduled tool jobs ordered by run time.
+// ?attempts=N includes each job's N most recent attempts.
+func (s *Server) handleListScheduled(w http.ResponseWriter, r *http.Request) {
+	jobs, err := s.store.ListScheduledToolJobs(100)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if jobs == nil {
+		jobs = []store.ScheduledToolJob{}
+	}
+	if n, _ := strconv.Atoi(r.URL.Query().Get("attempts")); n > 0 {
+		for i := range jobs {
+			if jobs[i].Attempts, err = s.store.ScheduledToolJobAttempts(jobs[i].ID, n); err != nil {
+				writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, jobs)
+}
+
+// handleCreateScheduled enqueues a tool call, or an agent task when
+// tool_name is the agent task tool.
+func (s *Server) handleCreateScheduled(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ToolName     string         `json:"tool_name"`
+		ToolInput    map[string]any `json:"tool_input"`
+		ScheduledFor time.Time      `json:"scheduled_for"`
+		Recurrence   string         `json:"recurrence"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	if req.ToolName != tools.AgentTaskToolName {
+		if _, ok := tools.FindTool(req.ToolName); !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unknown tool: " + req.ToolName})
+			return
+		}
+	}
+	recurrence, err := tools.ParseRecurrence(req.Recurrence)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	req.Recurrence = recurrence
+	if req.ScheduledFor.IsZero() && tools.IsCronRecurrence(recurrence) {
+		// A cron job without a start time first runs at its next match.
+		req.ScheduledFor, err = tools.NextCronRun(recurrence, time.Now())
+	}
+	if err != nil || req.ScheduledFor.IsZero() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "scheduled_for is required"})
+		return
+	}
+	id, err := s.store.CreateScheduledToolJob(req.ToolName, req.ToolInput, req.ScheduledFor, req.Recurrence)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id})
+}
+
+func (s *Server) handleCancelScheduled(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.CancelScheduledToolJob(r.PathValue("id")); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
+}
+
+// handleListDrafts returns scheduled jobs waiting for approval
+// (scheduler.draft_tools).
+func (s *Server) handleListDrafts(w http.ResponseWriter, r *http.Request) {
+	drafts, err := s.store.ListDraftToolJobs(100)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if drafts == nil {
+		drafts = []store.ScheduledToolJob{}
 	}
-	s.modelID = req.ModelID
-	s.modelLabel = req.Label
-	s.provider = newProvider
-	s.apiKey = newAPIKey
-	if s.prefs != nil {
-		s.prefs.Model = req.Label
-		s.prefs.Provider = newProviderName
+	writeJSON(w, http.StatusOK, drafts)
+}
+
+func (s *Server) handleApproveDraft(w http.ResponseWriter, r *http.Request) {
+	id, err := s.store.ApproveDraftToolJob(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
 	}
-	ag, ok := s.agents[sessionID]
-	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "status": "pending"})
+}
 
-	if ok {
-		ag.SetProvider(newProvider, newAPIKey)
-		ag.SetModel(req.Label, req.ModelID)
-	} else {
-		if err := s.store.UpdateSessionModel(sessionID, req.ModelID); err != nil {
-			s.logf("daemon: update session model: %v", err)
-		}
+func (s *Server) handleRejectDraft(w http.ResponseWriter, r *http.Request) {
+	id, err := s.store.RejectDraftToolJob(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
 	}
+	writeJSON(w, http.StatusOK, map[string]string{"id": id, "status": "rejected"})
+}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status":   "ok",
-		"label":    req.Label,
-		"model_id": req.ModelID,
-	})
+// ---------------------------------------------------------------------------
+// API tokens
+// ---------------------------------------------------------------------------
+
+func (s *Server) handleListTokens(w http.ResponseWriter, r *http.Request) {
+	tokens, err := s.store.ListAPITokens()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if tokens == nil {
+		tokens = []store.APIToken{}
+	}
+	writeJSON(w, http.StatusOK, tokens)
 }
 
-func (s *Server) handleSetTitle(w http.ResponseWriter, r *http.Request) {
-	sessionID := r.PathValue("id")
+// handleCreateToken issues a scoped token. The secret is only returned
+// here; the store keeps a hash.
+func (s *Server) handleCreateToken(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Title string `json:"title"`
+		Name  string `json:"name"`
+		Scope string `json:"scope"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
-
-	if err := s.store.UpdateSessionTitle(sessionID, req.Title); err != nil {
+	scope, err := store.ParseTokenScope(req.Scope)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	secret := generateAuthToken()
+	t, err := s.store.CreateAPIToken(strings.TrimSpace(req.Name), scope, secret)
+	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
+	s.logf("token created id=%s scope=%s", t.ID, t.Scope)
+	writeJSON(w, http.StatusCreated, struct {
+		store.APIToken
+		Token string `json:"token"`
+	}{*t, secret})
+}
 
-	// Mark the agent as user-renamed so auto-title doesn't overwrite.
-	s.mu.Lock()
-	if ag, ok := s.agents[sessionID]; ok {
-		ag.SetUserRenamed()
+func (s *Server) handleDeleteToken(w http.ResponseWriter, r *http.Request) {
+	if err := s.store.DeleteAPIToken(r.PathValue("id")); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
 	}
-	s.mu.Unlock()
-
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status": "ok",
-		"title":  req.Title,
-	})
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
 }
 
-func (s *Server) handleBranch(w http.ResponseWriter, r *http.Request) {
-	sessionID := r.PathValue("id")
-	var req struct {
-		AtSequence int `json:"at_sequence"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+func (s *Server) handleExportSession(w http.ResponseWriter, r *http.Request) {
+	format, err := export.ParseTranscriptFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
 
-	newSess, err := s.store.BranchSession(sessionID, req.AtSequence)
+	sess, st, err := s.findSession(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+	msgs, err := st.GetMessageRecords(sess.ID)
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, http.StatusOK, newSess)
-}
 
-func (s *Server) handleGetConfig(w http.ResponseWriter, r *http.Request) {
-	s.mu.Lock()
-	prefs := *s.prefs
-	s.mu.Unlock()
-	writeJSON(w, http.StatusOK, prefs)
+	contentType := "application/json"
+	switch format {
+	case "md":
+		contentType = "text/markdown; charset=utf-8"
+	case "claude", "codex":
+		contentType = "application/x-ndjson"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", export.TranscriptFileName(*sess, format)))
+	t := export.Transcript{Session: *sess, ExportedAt: time.Now().UTC(), Messages: msgs}
+	if err := export.WriteTranscript(w, t, format); err != nil {
+		s.logf("export session %s: %v", sess.ID, err)
+	}
 }
 
-func (s *Server) handleSetConfig(w http.ResponseWriter, r *http.Request) {
+func (s *Server) handleFeedback(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Key   string `json:"key"`
-		Value string `json:"value"`
+		Rating   string `json:"rating"`
+		Note     string `json:"note"`
+		Sequence int    `json:"sequence"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
 		return
 	}
+	rating, err := store.ParseRating(req.Rating)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
 
-	s.mu.Lock()
-	if err := s.prefs.Set(req.Key, req.Value); err != nil {
-		s.mu.Unlock()
+	sess, st, err := s.findSession(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+	seq, err := st.RateMessage(sess.ID, req.Sequence, rating, req.Note)
+	if err != nil {
 		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	if err := config.SavePreferences(*s.prefs); err != nil {
-		s.mu.Unlock()
-		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	s.logf("feedback session=%s seq=%d rating=%d", sess.ID, seq, rating)
+	writeJSON(w, http.StatusOK, map[string]int{"sequence": seq, "rating": rating})
+}
+
+// handleGetBlob serves a tool result offloaded to the storage sink.
+func (s *Server) handleGetBlob(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !sink.ValidBlobID(id) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid blob id"})
 		return
 	}
-	// If an API key was changed, re-resolve and update the server's active key
-	if strings.HasSuffix(req.Key, ".api_key") {
-		provName := strings.TrimSuffix(req.Key, ".api_key")
-		if key, err := config.LoadProviderAPIKey(*s.prefs, provName); err == nil {
-			// Only update the server's active key if this is the active provider
-			if s.provider != nil && s.provider.Name() == provName {
-				s.apiKey = key
-				// Update all existing agents with the new key
-				for _, ag := range s.agents {
-					ag.SetProvider(s.provider, key)
-				}
-			}
-		}
+	s.mu.Lock()
+	var prefs config.Preferences
+	if s.prefs != nil {
+		prefs = *s.prefs
 	}
-	if req.Key == "ollama.url" {
-		provider.SetOllamaBaseURL(req.Value)
+	s.mu.Unlock()
+	storage, err := sink.FromPreferences(prefs)
+	if err != nil || storage == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no storage configured"})
+		return
 	}
-	if req.Key == "zai.coding_plan" {
-		b, _ := config.ParseBoolish(req.Value)
-		provider.SetZAICodingPlan(b)
+	rc, err := sink.GetBlob(r.Context(), storage, id)
+	if errors.Is(err, sink.ErrNotFound) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "blob not found"})
+		return
 	}
-	if req.Key == "brave.api_key" {
-		for _, ag := range s.agents {
-			ag.SetBraveAPIKey(req.Value)
-		}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
 	}
-	if req.Key == "textbelt.api_key" {
-		for _, ag := range s.agents {
-			ag.SetTextbeltAPIKey(req.Value)
-		}
+	defer rc.Close()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if _, err := io.Copy(w, rc); err != nil {
+		s.logf("blob %s: %v", id, err)
 	}
-	if req.Key == "tools.disabled" || req.Key == "tools.ask_user" {
-		disabled := s.prefs.DisabledToolsSet()
-		for _, ag := range s.agents {
-			ag.SetDisabledTools(disabled)
+}
+
+// statsSpendDays is how many days of spend /api/stats reports when no
+// window is given.
+const statsSpendDays = 30
+
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	project := r.URL.Query().Get("project")
+	var since time.Time
+	if d := r.URL.Query().Get("days"); d != "" {
+		days, err := strconv.Atoi(d)
+		if err != nil || days < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid days"})
+			return
 		}
-	}
-	if req.Key == "model.compact" {
-		_, id := provider.ResolveProviderAndModel(req.Value, s.provider.Name())
-		for _, ag := range s.agents {
-			ag.SetModelCompact(id)
+		if days > 0 {
+			since = time.Now().AddDate(0, 0, -days)
 		}
 	}
-	if req.Key == "model.title" {
-		_, id := provider.ResolveProviderAndModel(req.Value, s.provider.Name())
-		for _, ag := range s.agents {
-			ag.SetModelTitle(id)
-		}
+	feedback, err := s.feedbackSummary(project, since)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
 	}
-	if req.Key == "model.tags" {
-		_, id := provider.ResolveProviderAndModel(req.Value, s.provider.Name())
-		for _, ag := range s.agents {
-			ag.SetModelTags(id)
-		}
+	failures, err := s.failurePatterns(project, since, 5)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
 	}
-	display := s.prefs.Get(req.Key)
-	s.mu.Unlock()
+	spendSince := since
+	if spendSince.IsZero() {
+		spendSince = time.Now().AddDate(0, 0, -statsSpendDays)
+	}
+	spend, err := s.dailySpend(spendSince)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	stats := Stats{Feedback: feedback, Failures: failures, Spend: spend}
+	if s.usingLocalModel() {
+		host := hostinfo.Probe()
+		stats.Host = &host
+	}
+	writeJSON(w, http.StatusOK, stats)
+}
 
-	writeJSON(w, http.StatusOK, map[string]string{
-		"status":  "ok",
-		"message": fmt.Sprintf("Set %s = %s", req.Key, display),
-	})
+// ParseUsageWindow parses a usage report window: a number of days ("7d" or
+// "7") or a start date (YYYY-MM-DD). It returns the first day included.
+func ParseUsageWindow(v string, now time.Time) (time.Time, error) {
+	if t, err := time.ParseInLocation(store.SpendDayLayout, v, time.Local); err == nil {
+		return t, nil
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(v, "d"))
+	if err != nil || days < 1 {
+		return time.Time{}, fmt.Errorf("invalid window %q (want e.g. 7d, 30d, or YYYY-MM-DD)", v)
+	}
+	return now.AddDate(0, 0, 1-days), nil
 }
 
-func (s *Server) handleMCPTools(w http.ResponseWriter, r *http.Request) {
-	s.mu.Lock()
-	mgr := s.mcpManager
-	s.mu.Unlock()
-	if mgr == nil {
-		writeJSON(w, http.StatusOK, map[string]any{
-			"tools":    []string{},
-			"statuses": map[string]string{},
-		})
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	window := r.URL.Query().Get("since")
+	if window == "" {
+		window = fmt.Sprintf("%dd", statsSpendDays)
+	}
+	since, err := ParseUsageWindow(window, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
-		"tools":    mgr.ToolNames(),
-		"statuses": mgr.ServerStatuses(),
-	})
+	groupBy := r.URL.Query().Get("group_by")
+	switch groupBy {
+	case "":
+		groupBy = store.UsageByDay
+	case store.UsageByDay, store.UsageByModel, store.UsageByProject:
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "group_by must be day, model, or project"})
+		return
+	}
+	rows, err := s.usageSummary(since, groupBy)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	usage := Usage{Since: since.Format(store.SpendDayLayout), GroupBy: groupBy, Rows: rows}
+	for _, row := range rows {
+		usage.Total.InputTokens += row.InputTokens
+		usage.Total.OutputTokens += row.OutputTokens
+		usage.Total.CacheWriteTokens += row.CacheWriteTokens
+		usage.Total.CacheReadTokens += row.CacheReadTokens
+		usage.Total.CostUSD += row.CostUSD
+	}
+	writeJSON(w, http.StatusOK, usage)
 }
 
 func (s *Server) handleConsult(w http.ResponseWriter, r *http.Request) {
@@ -1082,20 +2866,56 @@ func (s *Server) handleConsult(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleCompact summarizes the session's earlier messages now instead of
+// waiting for the context to fill up. See agent.Service.Compact.
+func (s *Server) handleCompact(w http.ResponseWriter, r *http.Request) {
+	ag, err := s.getOrCreateAgent(r.PathValue("id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+
+	var model string
+	n, err := ag.Compact(func(evt agent.Event) {
+		if evt.Kind == agent.EventCompacted {
+			model = evt.ModelUsed
+		}
+	})
+	if errors.Is(err, agent.ErrTurnRunning) {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": "cannot compact while a turn is running"})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, CompactResult{Compacted: n, Model: model})
+}
+
 func (s *Server) handleSessionStatus(w http.ResponseWriter, r *http.Request) {
 	sessionID := r.PathValue("id")
 	s.mu.Lock()
 	ag, ok := s.agents[sessionID]
 	s.mu.Unlock()
 
-	running := false
+	running, planMode := false, false
+	approved := []string{}
 	if ok {
 		running = ag.IsRunning()
+		planMode = ag.PlanMode()
+		approved = ag.ApprovedTools()
 	}
 
 	writeJSON(w, http.StatusOK, map[string]any{
-		"session_id":    sessionID,
-		"agent_running": running,
+		"session_id":     sessionID,
+		"agent_running":  running,
+		"plan_mode":      planMode,
+		"approved_tools": approved,
+		"last_event":     s.events.get(sessionID).last(),
 	})
 }
 
@@ -1116,7 +2936,8 @@ func (s *Server) getOrCreateAgent(sessionID string) (*agent.Service, error) {
 		return ag, nil
 	}
 
-	sess, err := s.store.GetSession(sessionID)
+	st := s.sessionStore(sessionID)
+	sess, err := st.GetSession(sessionID)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, fmt.Errorf("%w: %s", errSessionNotFound, sessionID)
 	}
@@ -1128,10 +2949,13 @@ func (s *Server) getOrCreateAgent(sessionID string) (*agent.Service, error) {
 		return nil, fmt.Errorf("no agent factory configured")
 	}
 
-	ag := s.newAgent(s.apiKey, s.modelID, s.modelLabel, s.store, sess, s.provider)
+	ag := s.newAgent(s.apiKey, s.modelID, s.modelLabel, st, sess, s.provider)
+	if sess.Cwd != "" {
+		ag.Cwd = sess.Cwd
+	}
 
 	// Try to resume messages from DB
-	if msgs, err := s.store.GetMessages(sessionID); err == nil && len(msgs) > 0 {
+	if msgs, err := st.GetMessages(sessionID); err == nil && len(msgs) > 0 {
 		if err := ag.Resume(); err != nil {
 			s.logf("daemon: resume agent %s: %v", sessionID, err)
 		}
@@ -1143,37 +2967,60 @@ func (s *Server) getOrCreateAgent(sessionID string) (*agent.Service, error) {
 	return ag, nil
 }
 
+// agentPrefs returns the preferences agents run with: s.prefs with the
+// project's .muxd.toml applied, or nil when s.prefs is. s.prefs itself,
+// which /config saves, stays as the user wrote it. Must be called with
+// s.mu held.
+func (s *Server) agentPrefs() *config.Preferences {
+	if s.prefs == nil {
+		return nil
+	}
+	p := *s.prefs
+	if cwd, _ := tools.Getwd(); cwd != "" {
+		if err := config.ApplyProjectConfig(&p, cwd); err != nil {
+			s.logf("daemon: %v", err)
+		}
+	}
+	return &p
+}
+
 // configureAgent sets up credentials, disabled tools, MCP, git, and memory
 // on an agent. Must be called with s.mu held.
 func (s *Server) configureAgent(ag *agent.Service) {
+	prefs := s.agentPrefs()
 	if s.logger != nil {
 		ag.SetLogger(s.logger)
 	}
-	if s.prefs != nil && s.prefs.BraveAPIKey != "" {
-		ag.SetBraveAPIKey(s.prefs.BraveAPIKey)
+	if s.projectDatabasesEnabled() {
+		ag.SetDaySpendFunc(s.daySpend)
 	}
-	if s.prefs != nil && s.prefs.TextbeltAPIKey != "" {
-		ag.SetTextbeltAPIKey(s.prefs.TextbeltAPIKey)
+	if prefs != nil && prefs.BraveAPIKey != "" {
+		ag.SetBraveAPIKey(prefs.BraveAPIKey)
 	}
-	if s.prefs != nil {
-		ag.SetDisabledTools(s.prefs.DisabledToolsSet())
+	if prefs != nil && prefs.TextbeltAPIKey != "" {
+		ag.SetTextbeltAPIKey(prefs.TextbeltAPIKey)
+	}
+	if prefs != nil {
+		ag.SetDisabledTools(prefs.DisabledToolsSet())
 	}
-	if s.prefs != nil && s.prefs.ModelCompact != "" {
-		_, compactID := provider.ResolveProviderAndModel(s.prefs.ModelCompact, s.provider.Name())
+	if prefs != nil && prefs.ModelCompact != "" {
+		_, compactID := provider.ResolveProviderAndModel(prefs.ModelCompact, s.provider.Name())
 		ag.SetModelCompact(compactID)
 	}
-	if s.prefs != nil && s.prefs.ModelTitle != "" {
-		_, titleID := provider.ResolveProviderAndModel(s.prefs.ModelTitle, s.provider.Name())
+	if prefs != nil && prefs.ModelTitle != "" {
+		_, titleID := provider.ResolveProviderAndModel(prefs.ModelTitle, s.provider.Name())
 		ag.SetModelTitle(titleID)
 	}
-	if s.prefs != nil && s.prefs.ModelTags != "" {
-		_, tagsID := provider.ResolveProviderAndModel(s.prefs.ModelTags, s.provider.Name())
+	if prefs != nil && prefs.ModelTags != "" {
+		_, tagsID := provider.ResolveProviderAndModel(prefs.ModelTags, s.provider.Name())
 		ag.SetModelTags(tagsID)
 	}
-	if s.prefs != nil {
-		ag.SetPreferences(*s.prefs)
-		if s.prefs.ModelConsult != "" {
-			ag.SetModelConsult(s.prefs.ModelConsult)
+	if prefs != nil {
+		ag.SetPreferences(*prefs)
+		ag.SetStyle(prefs.StyleLanguage, prefs.StyleTone)
+		ag.SetSampling(samplingDefaults(*prefs))
+		if prefs.ModelConsult != "" {
+			ag.SetModelConsult(prefs.ModelConsult)
 		}
 	}
 	if s.mcpManager != nil {
@@ -1192,8 +3039,7 @@ func (s *Server) configureAgent(ag *agent.Service) {
 	}
 
 	// Set up project memory
-	cwd, _ := tools.Getwd()
-	if cwd != "" {
+	if cwd, _ := tools.Getwd(); cwd != "" {
 		ag.SetMemory(tools.NewProjectMemory(cwd))
 	}
 
@@ -1227,46 +3073,10 @@ func (s *Server) executeScheduledAgentTask(call tools.ScheduledToolCall) (string
 	if strings.TrimSpace(prompt) == "" {
 		return "", true, fmt.Errorf("agent task has empty prompt")
 	}
-
-	// Create ephemeral session for this scheduled task.
-	sess, err := s.store.CreateSession("__scheduled_task__", s.modelID)
+	out, _, err := s.runHeadlessAgent("__scheduled_task__", prompt, nil, nil, nil)
 	if err != nil {
-		return "", true, fmt.Errorf("creating scheduled task session: %w", err)
-	}
-
-	if s.newAgent == nil {
-		return "", true, fmt.Errorf("no agent factory configured")
-	}
-
-	s.mu.Lock()
-	ag := s.newAgent(s.apiKey, s.modelID, s.modelLabel, s.store, sess, s.provider)
-	s.configureAgent(ag)
-	s.mu.Unlock()
-
-	// Disable ask_user in headless mode -no one to answer.
-	ag.SetDisabledTools(map[string]bool{"ask_user": true})
-
-	var result strings.Builder
-	const maxResultSize = 50 * 1024
-
-	ag.Submit(prompt, func(evt agent.Event) {
-		switch evt.Kind {
-		case agent.EventDelta:
-			if result.Len() < maxResultSize {
-				result.WriteString(evt.DeltaText)
-			}
-		case agent.EventError:
-			if evt.Err != nil {
-				result.WriteString("\nError: " + evt.Err.Error())
-			}
-		}
-	})
-
-	out := result.String()
-	if len(out) > maxResultSize {
-		out = out[:maxResultSize] + "\n... (truncated at 50KB)"
+		return "", true, fmt.Errorf("scheduled task: %w", err)
 	}
-
 	return out, false, nil
 }
 
@@ -5,14 +5,21 @@ import (
 	"database/sql"
 	"encoding/json"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/batalabs/muxd/internal/agent"
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/export"
+	"github.com/batalabs/muxd/internal/mcp"
 	"github.com/batalabs/muxd/internal/provider"
 	"github.com/batalabs/muxd/internal/store"
 
@@ -91,6 +98,41 @@ func TestCreateSession(t *testing.T) {
 	}
 }
 
+func TestCreateSession_cwd(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	create := func(cwd string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(map[string]string{"project_path": "/tmp/test", "cwd": cwd})
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "POST", "/api/sessions", bytes.NewReader(body)))
+		return w
+	}
+
+	for _, bad := range []string{"relative/dir", filepath.Join(t.TempDir(), "missing")} {
+		if w := create(bad); w.Code != http.StatusBadRequest {
+			t.Errorf("cwd %q: expected 400, got %d", bad, w.Code)
+		}
+	}
+
+	dir := t.TempDir()
+	w := create(dir)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	sess, err := st.GetSession(resp["session_id"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.Cwd != dir {
+		t.Errorf("Cwd = %q, want %q", sess.Cwd, dir)
+	}
+}
+
 func TestListSessions(t *testing.T) {
 	srv, st := newTestServer(t)
 	mux := http.NewServeMux()
@@ -171,6 +213,67 @@ func TestGetMessages(t *testing.T) {
 	if len(msgs) != 2 {
 		t.Errorf("expected 2 messages, got %d", len(msgs))
 	}
+
+	req = newAuthedRequest(srv, "GET", "/api/sessions/"+sess.ID+"/messages?offset=1&limit=5", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("paged: expected 200, got %d", w.Code)
+	}
+	if got := w.Header().Get("X-Total-Count"); got != "2" {
+		t.Errorf("X-Total-Count = %q, want 2", got)
+	}
+	msgs = nil
+	if err := json.NewDecoder(w.Body).Decode(&msgs); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Content != "hi there" {
+		t.Errorf("unexpected page: %+v", msgs)
+	}
+
+	req = newAuthedRequest(srv, "GET", "/api/sessions/"+sess.ID+"/messages?limit=-1", nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("negative limit: expected 400, got %d", w.Code)
+	}
+}
+
+func TestGetMessages_after(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	_ = st.AppendMessage(sess.ID, "user", "hello", 0)
+	_ = st.AppendMessage(sess.ID, "assistant", "hi there", 10)
+
+	get := func(query string) (*httptest.ResponseRecorder, []domain.TranscriptMessage) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/sessions/"+sess.ID+"/messages"+query, nil))
+		var msgs []domain.TranscriptMessage
+		_ = json.NewDecoder(w.Body).Decode(&msgs)
+		return w, msgs
+	}
+
+	w, msgs := get("")
+	if w.Header().Get("X-Max-Sequence") != "2" {
+		t.Errorf("full fetch X-Max-Sequence = %q, want 2", w.Header().Get("X-Max-Sequence"))
+	}
+	_ = st.AppendMessage(sess.ID, "user", "and now?", 0)
+
+	w, msgs = get("?after=2")
+	if w.Code != http.StatusOK || len(msgs) != 1 || msgs[0].Content != "and now?" || w.Header().Get("X-Max-Sequence") != "3" {
+		t.Errorf("after=2: %d %q %+v", w.Code, w.Header().Get("X-Max-Sequence"), msgs)
+	}
+	w, msgs = get("?after=3")
+	if msgs == nil || len(msgs) != 0 || w.Header().Get("X-Max-Sequence") != "3" {
+		t.Errorf("nothing new: %q %+v", w.Header().Get("X-Max-Sequence"), msgs)
+	}
+	if w, _ := get("?after=x"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad after: expected 400, got %d", w.Code)
+	}
 }
 
 func TestSetModel(t *testing.T) {
@@ -320,6 +423,40 @@ func TestAskResponseUnknownID(t *testing.T) {
 	}
 }
 
+func TestApprove(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	ch := make(chan agent.ApprovalDecision, 1)
+	srv.approvalChans["appr-1"] = ch
+
+	post := func(id, decision string) int {
+		body, _ := json.Marshal(map[string]string{"approval_id": id, "decision": decision})
+		req := newAuthedRequest(srv, "POST", "/api/sessions/"+sess.ID+"/approve", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post("appr-1", "maybe"); code != http.StatusBadRequest {
+		t.Errorf("invalid decision: expected 400, got %d", code)
+	}
+	if code := post("nonexistent", "allow"); code != http.StatusNotFound {
+		t.Errorf("unknown id: expected 404, got %d", code)
+	}
+	if code := post("appr-1", "always"); code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if got := <-ch; got != agent.ApprovalAlways {
+		t.Errorf("decision = %q, want always", got)
+	}
+	if code := post("appr-1", "allow"); code != http.StatusNotFound {
+		t.Errorf("answered approval: expected 404, got %d", code)
+	}
+}
+
 func TestWriteSSE(t *testing.T) {
 	w := httptest.NewRecorder()
 	// httptest.ResponseRecorder implements http.Flusher
@@ -384,6 +521,106 @@ func TestAuthMiddleware(t *testing.T) {
 	})
 }
 
+func TestScopedTokens(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	create := func(scope string) (id, token string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "POST", "/api/tokens", strings.NewReader(`{"name":"t","scope":"`+scope+`"}`)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create %s token: %d %s", scope, w.Code, w.Body.String())
+		}
+		var resp struct {
+			ID    string `json:"id"`
+			Token string `json:"token"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Token == "" {
+			t.Fatalf("decode: %v %+v", err, resp)
+		}
+		return resp.ID, resp.Token
+	}
+	do := func(token, method, target, body string) int {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	readID, read := create("read")
+	_, submit := create("submit")
+	_, admin := create("admin")
+
+	if code := do(read, "GET", "/api/sessions", ""); code != http.StatusOK {
+		t.Errorf("read token list sessions = %d", code)
+	}
+	if code := do(read, "POST", "/api/sessions", `{"project_path":"/tmp"}`); code != http.StatusForbidden {
+		t.Errorf("read token create session = %d, want 403", code)
+	}
+	if code := do(submit, "POST", "/api/sessions", `{"project_path":"/tmp"}`); code != http.StatusOK {
+		t.Errorf("submit token create session = %d", code)
+	}
+	if code := do(submit, "GET", "/api/config", ""); code != http.StatusForbidden {
+		t.Errorf("submit token get config = %d, want 403", code)
+	}
+	if code := do(submit, "POST", "/api/tokens", `{"scope":"admin"}`); code != http.StatusForbidden {
+		t.Errorf("submit token create token = %d, want 403", code)
+	}
+	if code := do(admin, "GET", "/api/tokens", ""); code != http.StatusOK {
+		t.Errorf("admin token list tokens = %d", code)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "POST", "/api/tokens", strings.NewReader(`{"scope":"root"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid scope = %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "DELETE", "/api/tokens/"+readID, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete token = %d %s", w.Code, w.Body.String())
+	}
+	if code := do(read, "GET", "/api/sessions", ""); code != http.StatusUnauthorized {
+		t.Errorf("revoked token = %d, want 401", code)
+	}
+}
+
+func TestScopeHeaderNarrowsDaemonToken(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	do := func(scope, method, target, body string) int {
+		req := newAuthedRequest(srv, method, target, strings.NewReader(body))
+		if scope != "" {
+			req.Header.Set(ScopeHeader, scope)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := do("read", "GET", "/api/sessions", ""); code != http.StatusOK {
+		t.Errorf("read scope list sessions = %d", code)
+	}
+	if code := do("read", "POST", "/api/sessions", `{"project_path":"/tmp"}`); code != http.StatusForbidden {
+		t.Errorf("read scope create session = %d, want 403", code)
+	}
+	if code := do("submit", "GET", "/api/config", ""); code != http.StatusForbidden {
+		t.Errorf("submit scope get config = %d, want 403", code)
+	}
+	if code := do("", "GET", "/api/config", ""); code != http.StatusOK {
+		t.Errorf("daemon token without scope get config = %d, want 200", code)
+	}
+	if code := do("root", "GET", "/api/config", ""); code != http.StatusOK {
+		t.Errorf("unknown scope get config = %d, want 200", code)
+	}
+}
+
 func TestGenerateAuthToken(t *testing.T) {
 	token := generateAuthToken()
 	if len(token) != 64 { // 32 bytes * 2 hex chars
@@ -581,6 +818,51 @@ func TestHandleSubmit_noAgentFactory(t *testing.T) {
 	}
 }
 
+func TestHandleSubmit_eventIDs(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(stubAgentFactory())
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	// An undecodable attachment ends the turn with a single error event.
+	body := `{"text":"hi","attachments":[{"name":"a.txt","media_type":"text/plain","data":"not base64!"}]}`
+	req := newAuthedRequest(srv, "POST", "/api/sessions/"+sess.ID+"/submit", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "id: 1\nevent: error\n") {
+		t.Fatalf("submit stream = %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestSubmitRequest_attachments(t *testing.T) {
+	var req submitRequest
+	body := `{"text":"","images":[{"path":"a.png","media_type":"image/png","data":"iVBORw=="}],` +
+		`"attachments":[{"name":"notes.md","media_type":"text/plain","data":"aGVsbG8="}]}`
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.empty() {
+		t.Error("request with attachments reported empty")
+	}
+	atts, err := req.attachments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(atts) != 2 || atts[0].Name != "a.png" || atts[1].Name != "notes.md" || string(atts[1].Data) != "hello" {
+		t.Errorf("attachments = %+v", atts)
+	}
+
+	req.Attachments[0].Data = "not base64!"
+	if _, err := req.attachments(); err == nil {
+		t.Error("expected error for invalid base64")
+	}
+}
+
 func TestHandleAskResponse_invalidBody(t *testing.T) {
 	srv, st := newTestServer(t)
 	mux := http.NewServeMux()
@@ -637,6 +919,146 @@ func TestHandleMCPTools_noManager(t *testing.T) {
 	if len(tools) != 0 {
 		t.Errorf("expected empty tools, got %d", len(tools))
 	}
+	if servers, ok := resp["servers"].([]any); !ok || len(servers) != 0 {
+		t.Errorf("expected empty servers array, got %v", resp["servers"])
+	}
+}
+
+func TestHandleListTools(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	req := newAuthedRequest(srv, "GET", "/api/tools", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	var resp struct {
+		Tools []ToolInfo `json:"tools"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var fileRead *ToolInfo
+	for i := range resp.Tools {
+		if resp.Tools[i].Name == "file_read" {
+			fileRead = &resp.Tools[i]
+		}
+	}
+	if fileRead == nil {
+		t.Fatalf("file_read missing from %d tools", len(resp.Tools))
+	}
+	if fileRead.Source != "builtin" {
+		t.Errorf("source = %q, want builtin", fileRead.Source)
+	}
+	if fileRead.InputSchema["type"] != "object" {
+		t.Errorf("schema type = %v, want object", fileRead.InputSchema["type"])
+	}
+	if req, _ := fileRead.InputSchema["required"].([]any); len(req) != 1 || req[0] != "path" {
+		t.Errorf("required = %v, want [path]", fileRead.InputSchema["required"])
+	}
+	if !fileRead.Enabled || len(fileRead.Profiles) != 3 {
+		t.Errorf("enabled = %v, profiles = %v", fileRead.Enabled, fileRead.Profiles)
+	}
+}
+
+func TestHandleListTools_disabled(t *testing.T) {
+	srv, _ := newTestServer(t)
+	srv.prefs.ToolsDisabled = "bash"
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	req := newAuthedRequest(srv, "GET", "/api/tools", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	var resp struct {
+		Tools []ToolInfo `json:"tools"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	for _, ti := range resp.Tools {
+		if ti.Enabled != (ti.Name != "bash") {
+			t.Errorf("%s: enabled = %v", ti.Name, ti.Enabled)
+		}
+	}
+}
+
+func TestHandleMCPServers_lifecycle(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	do := func(method, path string, body any) *httptest.ResponseRecorder {
+		t.Helper()
+		var r io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			r = bytes.NewReader(data)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, method, path, r))
+		return w
+	}
+
+	// An invalid config is rejected outright.
+	if w := do("POST", "/api/mcp/servers", MCPServerRequest{Name: "web", ServerConfig: mcp.ServerConfig{Type: "http"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid add: expected 400, got %d", w.Code)
+	}
+
+	// A server that fails to connect is kept, with its error.
+	w := do("POST", "/api/mcp/servers", MCPServerRequest{Name: "broken", ServerConfig: mcp.ServerConfig{Command: "muxd-no-such-command"}, Save: true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("add: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	var info mcp.ServerInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "broken" || info.Status != "error" || info.Error == "" {
+		t.Errorf("added server = %+v", info)
+	}
+	if data, err := os.ReadFile(filepath.Join(home, ".config", "muxd", "mcp.json")); err != nil || !strings.Contains(string(data), "muxd-no-such-command") {
+		t.Errorf("mcp.json = %q, %v; want the saved server", data, err)
+	}
+
+	w = do("GET", "/api/mcp/servers", nil)
+	var list struct {
+		Servers []mcp.ServerInfo `json:"servers"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Servers) != 1 || list.Servers[0].Name != "broken" {
+		t.Errorf("servers = %+v", list.Servers)
+	}
+
+	w = do("GET", "/api/mcp/servers/broken/logs", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "connect failed") {
+		t.Errorf("logs: %d %s", w.Code, w.Body)
+	}
+
+	if w := do("POST", "/api/mcp/servers/broken/restart", nil); w.Code != http.StatusOK {
+		t.Errorf("restart: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/api/mcp/servers/nope/restart", nil); w.Code != http.StatusNotFound {
+		t.Errorf("restart unknown: expected 404, got %d", w.Code)
+	}
+
+	if w := do("DELETE", "/api/mcp/servers/broken?save=true", nil); w.Code != http.StatusOK {
+		t.Fatalf("remove: expected 200, got %d: %s", w.Code, w.Body)
+	}
+	if data, _ := os.ReadFile(filepath.Join(home, ".config", "muxd", "mcp.json")); strings.Contains(string(data), "broken") {
+		t.Errorf("mcp.json still has the removed server: %s", data)
+	}
+	if w := do("DELETE", "/api/mcp/servers/broken", nil); w.Code != http.StatusNotFound {
+		t.Errorf("second remove: expected 404, got %d", w.Code)
+	}
 }
 
 func TestHandleListSessions_withLimit(t *testing.T) {
@@ -740,3 +1162,571 @@ func TestSetHubDispatch_wiresAgentOnCreate(t *testing.T) {
 	// occurred during wiring (the test reaching here is sufficient).
 	_ = called
 }
+
+func TestListenTCP_portRange(t *testing.T) {
+	// Occupy a port, then ask listenTCP for it with a range to fall back to.
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+
+	t.Run("falls back into range", func(t *testing.T) {
+		ln, err := listenTCP("127.0.0.1", busyPort, busyPort, busyPort+20)
+		if err != nil {
+			t.Fatalf("listenTCP: %v", err)
+		}
+		defer ln.Close()
+		got := ln.Addr().(*net.TCPAddr).Port
+		if got <= busyPort || got > busyPort+20 {
+			t.Errorf("expected port in (%d, %d], got %d", busyPort, busyPort+20, got)
+		}
+	})
+
+	t.Run("errors when range exhausted", func(t *testing.T) {
+		if _, err := listenTCP("127.0.0.1", busyPort, busyPort, busyPort); err == nil {
+			t.Fatal("expected error when no port in range is free")
+		}
+	})
+
+	t.Run("no range uses OS-assigned port", func(t *testing.T) {
+		ln, err := listenTCP("127.0.0.1", busyPort, 0, 0)
+		if err != nil {
+			t.Fatalf("listenTCP: %v", err)
+		}
+		defer ln.Close()
+		if ln.Addr().(*net.TCPAddr).Port == busyPort {
+			t.Error("expected a different port than the busy one")
+		}
+	})
+}
+
+func TestListenUnixLeavesLiveSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets behave differently on windows")
+	}
+	dir, err := os.MkdirTemp("", "muxd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "d.sock")
+
+	live, err := listenUnix(sock)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	defer live.Close()
+	go func() {
+		for {
+			c, err := live.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	if ln, err := listenUnix(sock); err == nil || !strings.Contains(err.Error(), "already running") {
+		if ln != nil {
+			ln.Close()
+		}
+		t.Fatalf("second listenUnix = %v, want already running", err)
+	}
+	if conn, err := net.Dial("unix", sock); err != nil {
+		t.Errorf("the live daemon lost its socket: %v", err)
+	} else {
+		conn.Close()
+	}
+
+	// A socket nobody listens on is stale and gets replaced.
+	stale := filepath.Join(dir, "s.sock")
+	ln, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+	ln, err = listenUnix(stale)
+	if err != nil {
+		t.Fatalf("listenUnix over a stale socket: %v", err)
+	}
+	ln.Close()
+}
+
+func TestUnixSocketSkipsAuth(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix socket permissions not enforced on windows")
+	}
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	// Keep the path short: unix socket paths are limited to ~100 bytes.
+	dir, err := os.MkdirTemp("", "muxd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "d.sock")
+
+	ln, err := listenUnix(sock)
+	if err != nil {
+		t.Fatalf("listenUnix: %v", err)
+	}
+	fi, err := os.Stat(sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("socket mode = %o, want 600", perm)
+	}
+
+	hs := &http.Server{Handler: mux, ConnContext: markUnixConn}
+	go hs.Serve(ln)
+	defer hs.Close()
+
+	client := NewDaemonSocketClient(sock)
+	sessions, err := client.ListSessions("", 10)
+	if err != nil {
+		t.Fatalf("ListSessions over socket without token: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("expected no sessions, got %d", len(sessions))
+	}
+
+	// A plain TCP request without a token must still be rejected.
+	req := httptest.NewRequest("GET", "/api/sessions", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 over non-socket request, got %d", w.Code)
+	}
+}
+
+func TestSessionContext(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(stubAgentFactory())
+	anthropicProv, err := provider.GetProvider("anthropic")
+	if err != nil {
+		t.Fatalf("getting anthropic provider: %v", err)
+	}
+	srv.provider = anthropicProv
+	srv.modelID = "claude-test"
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	if err := st.AppendMessage(sess.ID, "user", "remember the port is 8443", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.AppendMessage(sess.ID, "assistant", "Noted.", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/sessions/"+sess.ID+"/context", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var sc SessionContext
+	if err := json.Unmarshal(w.Body.Bytes(), &sc); err != nil {
+		t.Fatal(err)
+	}
+	if sc.Provider != "anthropic" || sc.Model != "claude-test" || sc.System == "" || len(sc.Tools) == 0 {
+		t.Errorf("context = %+v", sc)
+	}
+	if len(sc.Messages) != 2 || sc.Messages[0].Content != "remember the port is 8443" || sc.Messages[0].Tokens == 0 {
+		t.Errorf("messages = %+v", sc.Messages)
+	}
+	if sc.EstimatedTokens <= sc.SystemTokens {
+		t.Errorf("estimate %d does not cover the messages", sc.EstimatedTokens)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/sessions/nope/context", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", w.Code)
+	}
+}
+
+func TestSessionStyle(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(stubAgentFactory())
+	anthropicProv, err := provider.GetProvider("anthropic")
+	if err != nil {
+		t.Fatalf("getting anthropic provider: %v", err)
+	}
+	srv.provider = anthropicProv
+	srv.prefs.StyleTone = "terse"
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	do := func(method, body string) (int, SessionStyle) {
+		req := newAuthedRequest(srv, method, "/api/sessions/"+sess.ID+"/style", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var style SessionStyle
+		_ = json.Unmarshal(w.Body.Bytes(), &style)
+		return w.Code, style
+	}
+
+	if code, style := do("GET", ""); code != http.StatusOK || style.Tone != "terse" {
+		t.Fatalf("GET style = %d %+v, want terse default", code, style)
+	}
+	if code, style := do("POST", `{"language":"German","tone":"code-only"}`); code != http.StatusOK || style.Language != "German" || style.Tone != "code-only" {
+		t.Fatalf("POST style = %d %+v", code, style)
+	}
+	if code, _ := do("POST", `{"tone":"chatty"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown tone: expected 400, got %d", code)
+	}
+	if code, style := do("POST", `{"reset":true}`); code != http.StatusOK || style.Language != "" || style.Tone != "terse" {
+		t.Errorf("reset style = %d %+v", code, style)
+	}
+}
+
+func TestSessionVerify(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(stubAgentFactory())
+	anthropicProv, err := provider.GetProvider("anthropic")
+	if err != nil {
+		t.Fatalf("getting anthropic provider: %v", err)
+	}
+	srv.provider = anthropicProv
+	srv.prefs.ModelVerify = "openai/gpt-4o"
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	do := func(method, body string) (int, SessionVerify) {
+		req := newAuthedRequest(srv, method, "/api/sessions/"+sess.ID+"/verify", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var v SessionVerify
+		_ = json.Unmarshal(w.Body.Bytes(), &v)
+		return w.Code, v
+	}
+
+	if code, v := do("GET", ""); code != http.StatusOK || v.Enabled || v.Model != "openai/gpt-4o" {
+		t.Fatalf("GET verify = %d %+v, want off with model.verify", code, v)
+	}
+	if code, v := do("POST", `{"enabled":true,"model":"grok/grok-3"}`); code != http.StatusOK || !v.Enabled || v.Model != "grok/grok-3" {
+		t.Fatalf("POST verify = %d %+v", code, v)
+	}
+	if code, v := do("POST", `{"enabled":false}`); code != http.StatusOK || v.Enabled || v.Model != "openai/gpt-4o" {
+		t.Errorf("off = %d %+v, want the session override dropped", code, v)
+	}
+	if code, _ := do("POST", `not json`); code != http.StatusBadRequest {
+		t.Errorf("bad body: expected 400, got %d", code)
+	}
+}
+
+func TestMessageAnnotationsAndDelete(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(stubAgentFactory())
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "model-a")
+	_ = st.AppendMessage(sess.ID, "user", "hello", 0)
+	_ = st.AppendMessage(sess.ID, "assistant", "hi", 10)
+	_ = st.AppendMessage(sess.ID, "user", "again", 0)
+	ag, err := srv.getOrCreateAgent(sess.ID)
+	if err != nil {
+		t.Fatalf("getOrCreateAgent: %v", err)
+	}
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := newAuthedRequest(srv, method, "/api/sessions/"+sess.ID+path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/messages/3/annotation", `{"note":"follow up"}`); w.Code != http.StatusOK {
+		t.Fatalf("annotate: %d %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/messages/9/annotation", `{"note":"x"}`); w.Code != http.StatusNotFound {
+		t.Errorf("annotate missing message: expected 404, got %d", w.Code)
+	}
+	if w := do("POST", "/messages/abc/annotation", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad sequence: expected 400, got %d", w.Code)
+	}
+
+	if w := do("DELETE", "/messages/2", ""); w.Code != http.StatusOK {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+	// The agent reloads, merging the two user messages left side by side.
+	if msgs := ag.Messages(); len(msgs) != 1 || msgs[0].Content != "hello\n\nagain" {
+		t.Errorf("agent history after delete = %+v", msgs)
+	}
+
+	w := do("GET", "/annotations", "")
+	var notes map[int]string
+	if err := json.Unmarshal(w.Body.Bytes(), &notes); err != nil || notes[2] != "follow up" {
+		t.Errorf("annotations = %s (%v)", w.Body.String(), err)
+	}
+}
+
+func TestSessionSampling(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(stubAgentFactory())
+	anthropicProv, err := provider.GetProvider("anthropic")
+	if err != nil {
+		t.Fatalf("getting anthropic provider: %v", err)
+	}
+	srv.provider = anthropicProv
+	srv.prefs.SamplingSeed = "7"
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	do := func(method, body string) (int, SessionSampling) {
+		req := newAuthedRequest(srv, method, "/api/sessions/"+sess.ID+"/sampling", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var sampling SessionSampling
+		_ = json.Unmarshal(w.Body.Bytes(), &sampling)
+		return w.Code, sampling
+	}
+
+	if code, s := do("GET", ""); code != http.StatusOK || s.Seed == nil || *s.Seed != 7 || s.Temperature != nil {
+		t.Fatalf("GET sampling = %d %+v, want seed 7 default", code, s)
+	}
+	code, s := do("POST", `{"temperature":"0.2","seed":"default"}`)
+	if code != http.StatusOK || s.Temperature == nil || *s.Temperature != 0.2 || s.Seed != nil {
+		t.Fatalf("POST sampling = %d %+v", code, s)
+	}
+	if code, _ := do("POST", `{"top_p":"2"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid top_p: expected 400, got %d", code)
+	}
+	if code, s := do("POST", `{"reset":true}`); code != http.StatusOK || s.Temperature != nil || s.Seed == nil || *s.Seed != 7 {
+		t.Errorf("reset sampling = %d %+v", code, s)
+	}
+
+	code, s = do("POST", `{"max_tokens":"2048","stop":"END,\\n\\n"}`)
+	if code != http.StatusOK || s.MaxTokens != 2048 || len(s.Stop) != 2 || s.Stop[1] != "\n\n" {
+		t.Fatalf("POST limits = %d %+v", code, s)
+	}
+	if s.Provider != "anthropic" || s.DefaultMaxTokens != 16384 || s.SupportsSeed {
+		t.Errorf("provider limits = %+v", s)
+	}
+	if code, _ := do("POST", `{"max_tokens":"-1"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid max_tokens: expected 400, got %d", code)
+	}
+	if code, s := do("POST", `{"max_tokens":"default","stop":"default"}`); code != http.StatusOK || s.MaxTokens != 0 || s.Stop != nil {
+		t.Errorf("clear limits = %d %+v", code, s)
+	}
+}
+
+func TestFeedbackAndStats(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/fb", "test-model")
+	_ = st.AppendMessage(sess.ID, "user", "hi", 0)
+	_ = st.AppendMessage(sess.ID, "assistant", "hello", 0)
+
+	post := func(body string) int {
+		req := newAuthedRequest(srv, "POST", "/api/sessions/"+sess.ID+"/feedback", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w.Code
+	}
+	if code := post(`{"rating":"meh"}`); code != http.StatusBadRequest {
+		t.Errorf("invalid rating: expected 400, got %d", code)
+	}
+	if code := post(`{"rating":"bad","note":"ignored the test failure"}`); code != http.StatusOK {
+		t.Fatalf("feedback: expected 200, got %d", code)
+	}
+	_ = st.SavePostmortem(store.Postmortem{SessionID: sess.ID, Category: "build failure", Diagnosis: "go build failed"})
+
+	req := newAuthedRequest(srv, "GET", "/api/stats?project=/tmp/fb", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("stats: expected 200, got %d", w.Code)
+	}
+	var stats Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("decoding stats: %v", err)
+	}
+	if stats.Feedback.Bad != 1 || stats.Feedback.Turns != 1 || len(stats.Feedback.RecentNotes) != 1 {
+		t.Errorf("unexpected stats: %+v", stats.Feedback)
+	}
+	if len(stats.Failures) != 1 || stats.Failures[0].Category != "build failure" {
+		t.Errorf("unexpected failure patterns: %+v", stats.Failures)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	a, _ := st.CreateSession("/tmp/a", "test-model")
+	b, _ := st.CreateSession("/tmp/b", "test-model")
+	now := time.Now()
+	_ = st.RecordSpend(a.ID, "opus", now, store.TokenCounts{Input: 100, Output: 10}, 2)
+	_ = st.RecordSpend(b.ID, "haiku", now, store.TokenCounts{Input: 50, CacheRead: 20}, 0.5)
+	_ = st.RecordSpend(a.ID, "opus", now.AddDate(0, 0, -10), store.TokenCounts{Input: 1}, 4)
+
+	get := func(query string) (int, Usage) {
+		req := newAuthedRequest(srv, "GET", "/api/usage?"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		var u Usage
+		_ = json.Unmarshal(w.Body.Bytes(), &u)
+		return w.Code, u
+	}
+	code, u := get("since=7d&group_by=project")
+	if code != http.StatusOK {
+		t.Fatalf("usage: expected 200, got %d", code)
+	}
+	if len(u.Rows) != 2 || u.Rows[0].Key != "/tmp/a" || u.Total.CostUSD != 2.5 || u.Total.CacheReadTokens != 20 {
+		t.Errorf("unexpected usage by project: %+v", u)
+	}
+	if _, u := get(""); u.GroupBy != "day" || len(u.Rows) != 2 || u.Total.CostUSD != 6.5 {
+		t.Errorf("unexpected default usage: %+v", u)
+	}
+	for _, bad := range []string{"since=soon", "group_by=session"} {
+		if code, _ := get(bad); code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", bad, code)
+		}
+	}
+}
+
+func TestParseUsageWindow(t *testing.T) {
+	now := time.Date(2026, 5, 10, 15, 0, 0, 0, time.Local)
+	for in, want := range map[string]string{"7d": "2026-05-04", "1": "2026-05-10", "2026-04-01": "2026-04-01"} {
+		got, err := ParseUsageWindow(in, now)
+		if err != nil || got.Format(store.SpendDayLayout) != want {
+			t.Errorf("ParseUsageWindow(%q) = %v, %v; want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseUsageWindow("0d", now); err == nil {
+		t.Error("expected error for 0d")
+	}
+}
+
+func TestExportSession(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/export", "test-model")
+	_ = st.AppendMessage(sess.ID, "user", "hi", 3)
+	_ = st.AppendMessageBlocks(sess.ID, "assistant", []domain.ContentBlock{
+		{Type: "text", Text: "running"},
+		{Type: "tool_use", ToolUseID: "t1", ToolName: "bash", ToolInput: map[string]any{"command": "ls"}},
+	}, 7)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := newAuthedRequest(srv, "GET", "/api/sessions/"+sess.ID+"/export"+query, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("?format=json")
+	if w.Code != http.StatusOK {
+		t.Fatalf("json export: expected 200, got %d", w.Code)
+	}
+	var tr export.Transcript
+	if err := json.Unmarshal(w.Body.Bytes(), &tr); err != nil {
+		t.Fatalf("decoding export: %v", err)
+	}
+	if len(tr.Messages) != 2 || tr.Messages[1].Tokens != 7 || len(tr.Messages[1].Blocks) != 2 {
+		t.Errorf("unexpected transcript: %+v", tr.Messages)
+	}
+
+	w = get("?format=md")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/markdown") {
+		t.Fatalf("md export: got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "**Tool call:** `bash`") {
+		t.Errorf("markdown missing tool call:\n%s", w.Body.String())
+	}
+
+	if w := get("?format=pdf"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown format: expected 400, got %d", w.Code)
+	}
+}
+
+func TestSetPlanMode(t *testing.T) {
+	srv, st := newTestServer(t)
+	srv.SetAgentFactory(stubAgentFactory())
+	anthropicProv, err := provider.GetProvider("anthropic")
+	if err != nil {
+		t.Fatalf("getting anthropic provider: %v", err)
+	}
+	srv.provider = anthropicProv
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	sess, _ := st.CreateSession("/tmp/plan", "test-model")
+	post := func(id, body string) *httptest.ResponseRecorder {
+		req := newAuthedRequest(srv, "POST", "/api/sessions/"+id+"/plan", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post(sess.ID, `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing enabled: expected 400, got %d", w.Code)
+	}
+	if w := post("no-such-session", `{"enabled": true}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session: expected 404, got %d", w.Code)
+	}
+
+	w := post(sess.ID, `{"enabled": true}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string]bool
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if !resp["plan_mode"] {
+		t.Errorf("expected plan_mode=true, got %v", resp)
+	}
+
+	w = post(sess.ID, `{"enabled": false}`)
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp["plan_mode"] {
+		t.Errorf("expected plan mode off, got %d %v", w.Code, resp)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	id, _ := st.CreateDraftToolJob("sms_send", map[string]any{"message": "hi"}, time.Now().Add(time.Hour), "once")
+
+	do := func(method, path string) *httptest.ResponseRecorder {
+		req := newAuthedRequest(srv, method, path, nil)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/api/drafts")
+	var drafts []store.ScheduledToolJob
+	if err := json.Unmarshal(w.Body.Bytes(), &drafts); err != nil || len(drafts) != 1 || drafts[0].ID != id {
+		t.Fatalf("list drafts: %d %s", w.Code, w.Body.String())
+	}
+
+	if w := do("POST", "/api/drafts/"+id[:8]+"/approve"); w.Code != http.StatusOK {
+		t.Fatalf("approve: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/drafts/"+id+"/reject"); w.Code != http.StatusNotFound {
+		t.Errorf("reject approved job: expected 404, got %d", w.Code)
+	}
+	w = do("GET", "/api/drafts")
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("expected empty draft list, got %s", w.Body.String())
+	}
+}
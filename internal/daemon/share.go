@@ -0,0 +1,303 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/publish"
+	"github.com/batalabs/muxd/internal/redact"
+)
+
+// shareViewerBuffer is how many events a slow share page may fall behind
+// before events are dropped for it.
+const shareViewerBuffer = 256
+
+// sharePingInterval keeps idle share streams open through proxies.
+const sharePingInterval = 25 * time.Second
+
+// shareEndedEvent tells a share page that its link was revoked.
+const shareEndedEvent = "ended"
+
+// ---------------------------------------------------------------------------
+// Viewers
+// ---------------------------------------------------------------------------
+
+// shareViewers fans a session's events out to the live share pages watching
+// it. The zero value is ready to use.
+type shareViewers struct {
+	mu   sync.Mutex
+	subs map[string]map[chan wsServerMessage]struct{}
+}
+
+func (v *shareViewers) add(sessionID string) chan wsServerMessage {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.subs == nil {
+		v.subs = make(map[string]map[chan wsServerMessage]struct{})
+	}
+	if v.subs[sessionID] == nil {
+		v.subs[sessionID] = make(map[chan wsServerMessage]struct{})
+	}
+	ch := make(chan wsServerMessage, shareViewerBuffer)
+	v.subs[sessionID][ch] = struct{}{}
+	return ch
+}
+
+// remove unsubscribes ch. It is a no-op if closeSession already did.
+func (v *shareViewers) remove(sessionID string, ch chan wsServerMessage) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.subs[sessionID][ch]; !ok {
+		return
+	}
+	delete(v.subs[sessionID], ch)
+	if len(v.subs[sessionID]) == 0 {
+		delete(v.subs, sessionID)
+	}
+	close(ch)
+}
+
+func (v *shareViewers) watched(sessionID string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.subs[sessionID]) > 0
+}
+
+// publish sends an event to every viewer of the session, dropping it for
+// viewers whose buffer is full rather than stalling the agent.
+func (v *shareViewers) publish(sessionID, event string, data any) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for ch := range v.subs[sessionID] {
+		select {
+		case ch <- wsServerMessage{Event: event, Data: data}:
+		default:
+		}
+	}
+}
+
+// closeSession disconnects every viewer of the session.
+func (v *shareViewers) closeSession(sessionID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for ch := range v.subs[sessionID] {
+		close(ch)
+	}
+	delete(v.subs, sessionID)
+}
+
+// teeToViewers wraps send so share pages watching the session also get the
+// event, in its share form.
+func (s *Server) teeToViewers(sessionID string, send func(event string, data any)) func(event string, data any) {
+	return func(event string, data any) {
+		send(event, data)
+		if !s.viewers.watched(sessionID) {
+			return
+		}
+		if shared, ok := shareEventData(event, data); ok {
+			s.viewers.publish(sessionID, event, shared)
+		}
+	}
+}
+
+// shareUserMessage shows a submitted message on the session's share pages.
+func (s *Server) shareUserMessage(sessionID string, req submitRequest) {
+	if !s.viewers.watched(sessionID) {
+		return
+	}
+	images, files := len(req.Images), 0
+	for _, att := range req.Attachments {
+		if strings.HasPrefix(att.MediaType, "image/") {
+			images++
+		} else {
+			files++
+		}
+	}
+	s.viewers.publish(sessionID, "user", map[string]any{
+		"text":   redact.Secrets(req.Text),
+		"images": images,
+		"files":  files,
+	})
+}
+
+// shareEventData converts a stream event to what a share page may see:
+// secrets are redacted, and the IDs used to answer questions and approvals
+// are dropped since viewers cannot act on them. Events a viewer has no use
+// for report false. Secrets split across two deltas can slip through.
+func shareEventData(event string, data any) (any, bool) {
+	switch event {
+	case "delta":
+		d, _ := data.(map[string]string)
+		return map[string]string{"text": redact.Secrets(d["text"])}, true
+	case "tool_start":
+		d, _ := data.(map[string]any)
+		input, _ := json.MarshalIndent(d["tool_input"], "", "  ")
+		return map[string]any{"tool_name": d["tool_name"], "tool_input": redact.Secrets(string(input))}, true
+	case "tool_done":
+		d, _ := data.(map[string]any)
+		result, _ := d["result"].(string)
+		return map[string]any{"tool_name": d["tool_name"], "result": redact.Secrets(result), "is_error": d["is_error"]}, true
+	case "ask_user":
+		d, _ := data.(map[string]any)
+		prompt, _ := d["prompt"].(string)
+		return map[string]string{"prompt": redact.Secrets(prompt)}, true
+	case "approval_required":
+		d, _ := data.(map[string]any)
+		return map[string]any{"tool_name": d["tool_name"]}, true
+	case "error":
+		var msg string
+		switch d := data.(type) {
+		case map[string]string:
+			msg = d["error"]
+		case map[string]any:
+			msg, _ = d["error"].(string)
+		}
+		return map[string]string{"error": redact.Secrets(msg)}, true
+	case "turn_done", "titled", "retrying", "compacted":
+		return data, true
+	}
+	return nil, false
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+// handleShareSession mints (or returns the existing) read-only share link
+// for a session.
+func (s *Server) handleShareSession(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	st := s.sessionStore(id)
+	sess, err := st.GetSession(id)
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "session not found"})
+		return
+	}
+	sh, err := st.ShareSession(sess.ID, generateAuthToken())
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	path := "/share/" + sh.Token
+	resp := map[string]any{
+		"token":      sh.Token,
+		"path":       path,
+		"created_at": sh.CreatedAt,
+	}
+	// Browsers cannot reach a unix socket; clients fall back to the path.
+	if s.socketPath == "" {
+		host := s.advertisedHost()
+		resp["url"] = BaseURL(host, s.port) + path
+		resp["local_only"] = IsLoopbackHost(host)
+	}
+	s.logf("share session=%s", sess.ID)
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleUnshareSession revokes the session's share links and disconnects
+// anyone watching.
+func (s *Server) handleUnshareSession(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	n, err := s.sessionStore(sessionID).UnshareSession(sessionID)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.viewers.publish(sessionID, shareEndedEvent, map[string]string{})
+	s.viewers.closeSession(sessionID)
+	s.logf("unshare session=%s removed=%d", sessionID, n)
+	writeJSON(w, http.StatusOK, map[string]int64{"removed": n})
+}
+
+// sharedSession resolves the share token in the request path, writing a 404
+// if it is unknown or revoked.
+func (s *Server) sharedSession(w http.ResponseWriter, r *http.Request) (string, bool) {
+	token := r.PathValue("token")
+	for _, st := range s.stores() {
+		if sh, err := st.LookupShare(token); err == nil && sh != nil {
+			s.rememberSession(sh.SessionID, st)
+			return sh.SessionID, true
+		}
+	}
+	http.Error(w, "This share link does not exist or was revoked.", http.StatusNotFound)
+	return "", false
+}
+
+// handleSharePage serves the transcript so far, which then follows the
+// session live from handleShareEvents.
+func (s *Server) handleSharePage(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.sharedSession(w, r)
+	if !ok {
+		return
+	}
+	st := s.sessionStore(sessionID)
+	sess, err := st.GetSession(sessionID)
+	if err != nil {
+		http.Error(w, "session not found", http.StatusNotFound)
+		return
+	}
+	msgs, err := st.GetMessages(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// The token is in the URL, so keep it out of referrers, caches, and
+	// search indexes.
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	entry := publish.Entry{Session: *sess, Messages: msgs}
+	if err := publish.WriteLive(w, entry, r.URL.Path+"/events"); err != nil {
+		s.logf("share page session=%s: %v", sessionID, err)
+	}
+}
+
+// handleShareEvents streams the shared session's events as SSE until the
+// viewer leaves or the link is revoked.
+func (s *Server) handleShareEvents(w http.ResponseWriter, r *http.Request) {
+	sessionID, ok := s.sharedSession(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := s.viewers.add(sessionID)
+	defer s.viewers.remove(sessionID, ch)
+
+	s.mu.Lock()
+	ag := s.agents[sessionID]
+	s.mu.Unlock()
+	writeSSE(w, flusher, "status", map[string]bool{"running": ag != nil && ag.IsRunning()})
+
+	ping := time.NewTicker(sharePingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			writeSSE(w, flusher, msg.Event, msg.Data)
+			if msg.Event == shareEndedEvent {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"bufio"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShareSession_liveView(t *testing.T) {
+	srv, st := newTestServer(t)
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	ts, _ := newSocketTestServer(t, srv)
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	link, err := client.ShareSession(sess.ID)
+	if err != nil {
+		t.Fatalf("ShareSession: %v", err)
+	}
+	if !strings.HasPrefix(link.Path, "/share/") || link.URL == "" {
+		t.Fatalf("link = %+v", link)
+	}
+	again, _ := client.ShareSession(sess.ID)
+	if again.Token != link.Token {
+		t.Errorf("sharing again minted a new token")
+	}
+
+	// The page needs no auth token.
+	resp, err := http.Get(ts.URL + link.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(page), "EventSource") {
+		t.Fatalf("page = %d %.200s", resp.StatusCode, page)
+	}
+	if resp.Header.Get("Referrer-Policy") != "no-referrer" {
+		t.Error("page should not leak its URL in referrers")
+	}
+
+	stream, err := http.Get(ts.URL + link.Path + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Body.Close()
+	events := make(chan string, 64)
+	go func() {
+		defer close(events)
+		sc := bufio.NewScanner(stream.Body)
+		for sc.Scan() {
+			if name, ok := strings.CutPrefix(sc.Text(), "event: "); ok {
+				events <- name
+			}
+		}
+	}()
+	next := func() string {
+		t.Helper()
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return "<closed>"
+			}
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a share event")
+			return ""
+		}
+	}
+	if e := next(); e != "status" {
+		t.Fatalf("first event = %s, want status", e)
+	}
+
+	if err := client.Submit(sess.ID, "hello", nil, func(SSEEvent) {}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	var seen []string
+	for e := next(); e != "turn_done"; e = next() {
+		seen = append(seen, e)
+	}
+	if got := strings.Join(seen, ","); !strings.HasPrefix(got, "user,") || !strings.Contains(got, "delta") {
+		t.Errorf("events before turn_done = %s, want user then delta", got)
+	}
+
+	n, err := client.UnshareSession(sess.ID)
+	if err != nil || n != 1 {
+		t.Fatalf("UnshareSession = %d, %v", n, err)
+	}
+	for e := next(); e != "<closed>"; e = next() {
+		if e != shareEndedEvent {
+			t.Errorf("unexpected event after unshare: %s", e)
+		}
+	}
+	resp, err = http.Get(ts.URL + link.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("revoked page = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestShareEventData_dropsControlIDs(t *testing.T) {
+	data, ok := shareEventData("approval_required", map[string]any{"approval_id": "a1", "tool_name": "bash"})
+	if !ok || data.(map[string]any)["approval_id"] != nil {
+		t.Errorf("approval_required = %v, %v", data, ok)
+	}
+	if _, ok := shareEventData("stream_done", map[string]any{}); ok {
+		t.Error("stream_done should not be shared")
+	}
+	d, _ := shareEventData("tool_done", map[string]any{"result": "key sk-ant-REDACTED"})
+	if strings.Contains(d.(map[string]any)["result"].(string), "abcdefghijklmnop") {
+		t.Errorf("secret not redacted: %v", d)
+	}
+}
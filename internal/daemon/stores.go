@@ -0,0 +1,379 @@
+package daemon
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// ---------------------------------------------------------------------------
+// Project databases
+// ---------------------------------------------------------------------------
+
+// projectStores are the per-project databases a daemon with project
+// databases enabled keeps sessions in, keyed by project root. The daemon's
+// own store keeps everything else: tokens, jobs, schedules, webhooks, and
+// sessions with no project.
+type projectStores struct {
+	mu      sync.Mutex
+	enabled bool
+	byRoot  map[string]*store.Store
+	byID    map[string]*store.Store // session ID -> store, filled as sessions are found
+}
+
+// EnableProjectDatabases makes the daemon keep each project's sessions in
+// <root>/.muxd/muxd.db and serve them from there, alongside its own store.
+// The project databases it opened before are reopened so their sessions can
+// be found by ID.
+func (s *Server) EnableProjectDatabases() error {
+	roots, err := s.store.ProjectDatabases()
+	if err != nil {
+		return fmt.Errorf("listing project databases: %w", err)
+	}
+	p := &s.projects
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.enabled = true
+	p.byRoot = make(map[string]*store.Store)
+	p.byID = make(map[string]*store.Store)
+	for _, root := range roots {
+		if _, err := os.Stat(filepath.Join(root, store.ProjectDBDir, "muxd.db")); err != nil {
+			s.logf("project db %s: %v", root, err)
+			continue
+		}
+		st, err := store.OpenStoreForProject(root)
+		if err != nil {
+			s.logf("project db %s: %v", root, err)
+			continue
+		}
+		p.byRoot[root] = st
+	}
+	return nil
+}
+
+// projectDatabasesEnabled reports whether EnableProjectDatabases was called.
+func (s *Server) projectDatabasesEnabled() bool {
+	s.projects.mu.Lock()
+	defer s.projects.mu.Unlock()
+	return s.projects.enabled
+}
+
+// closeProjectStores closes the project databases.
+func (s *Server) closeProjectStores() {
+	p := &s.projects
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for root, st := range p.byRoot {
+		if err := st.Close(); err != nil {
+			s.logf("project db %s: close: %v", root, err)
+		}
+	}
+	p.byRoot = nil
+	p.byID = nil
+}
+
+// storeForProject returns the store new sessions under projectPath go in,
+// opening the project's database on first use. Without project databases,
+// or for a session with no absolute project path, it is the daemon's own.
+func (s *Server) storeForProject(projectPath string) (*store.Store, error) {
+	p := &s.projects
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.enabled || !filepath.IsAbs(projectPath) {
+		return s.store, nil
+	}
+	root := filepath.Clean(projectPath)
+	if st, ok := p.byRoot[root]; ok {
+		return st, nil
+	}
+	st, err := store.OpenStoreForProject(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.store.AddProjectDatabase(root); err != nil {
+		st.Close()
+		return nil, fmt.Errorf("recording project db: %w", err)
+	}
+	p.byRoot[root] = st
+	return st, nil
+}
+
+// stores returns the daemon's own store followed by the open project
+// databases, in root order.
+func (s *Server) stores() []*store.Store {
+	out := []*store.Store{s.store}
+	p := &s.projects
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	roots := make([]string, 0, len(p.byRoot))
+	for root := range p.byRoot {
+		roots = append(roots, root)
+	}
+	slices.Sort(roots)
+	for _, root := range roots {
+		out = append(out, p.byRoot[root])
+	}
+	return out
+}
+
+// rememberSession records which store holds sessionID.
+func (s *Server) rememberSession(sessionID string, st *store.Store) {
+	p := &s.projects
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.enabled && st != s.store {
+		p.byID[sessionID] = st
+	}
+}
+
+// sessionStore returns the store holding sessionID. Sessions that are in no
+// store, like everything without project databases, resolve to the daemon's
+// own.
+func (s *Server) sessionStore(sessionID string) *store.Store {
+	p := &s.projects
+	p.mu.Lock()
+	enabled, st := p.enabled, p.byID[sessionID]
+	p.mu.Unlock()
+	if !enabled {
+		return s.store
+	}
+	if st != nil {
+		return st
+	}
+	for _, st := range s.stores()[1:] {
+		if _, err := st.GetSession(sessionID); err == nil {
+			s.rememberSession(sessionID, st)
+			return st
+		}
+	}
+	return s.store
+}
+
+// findSession looks a session up by ID, then by ID prefix, across every
+// store, and returns it with the store that holds it.
+func (s *Server) findSession(idOrPrefix string) (*domain.Session, *store.Store, error) {
+	stores := s.stores()
+	for _, st := range stores {
+		sess, err := st.GetSession(idOrPrefix)
+		if err == nil {
+			s.rememberSession(sess.ID, st)
+			return sess, st, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+	}
+	for _, st := range stores {
+		sess, err := st.FindSessionByPrefix(idOrPrefix)
+		if err == nil {
+			s.rememberSession(sess.ID, st)
+			return sess, st, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, err
+		}
+	}
+	return nil, nil, sql.ErrNoRows
+}
+
+// listSessions returns the most recently updated sessions across every
+// store, newest first. A non-empty project limits them to that project.
+func (s *Server) listSessions(project string, limit int) ([]domain.Session, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	var out []domain.Session
+	for _, st := range s.stores() {
+		sessions, err := st.ListSessions(project, limit)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sessions...)
+	}
+	slices.SortStableFunc(out, func(a, b domain.Session) int {
+		return b.UpdatedAt.Compare(a.UpdatedAt)
+	})
+	if len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// Reports across stores
+// ---------------------------------------------------------------------------
+
+// Usage, spend, and ratings are recorded in the store that holds the
+// session, so with project databases the daemon's reports add up every
+// store. With one store the results pass through unchanged.
+
+// feedbackNotesMax matches the recent notes one store reports.
+const feedbackNotesMax = 10
+
+func (s *Server) feedbackSummary(project string, since time.Time) (store.FeedbackSummary, error) {
+	var sum store.FeedbackSummary
+	for _, st := range s.stores() {
+		f, err := st.FeedbackSummary(project, since)
+		if err != nil {
+			return sum, err
+		}
+		sum.Turns += f.Turns
+		sum.Good += f.Good
+		sum.Bad += f.Bad
+		sum.RecentNotes = append(sum.RecentNotes, f.RecentNotes...)
+	}
+	slices.SortStableFunc(sum.RecentNotes, func(a, b store.FeedbackNote) int { return b.At.Compare(a.At) })
+	if len(sum.RecentNotes) > feedbackNotesMax {
+		sum.RecentNotes = sum.RecentNotes[:feedbackNotesMax]
+	}
+	return sum, nil
+}
+
+// failurePatternsScan is how many categories are read from each store before
+// they are merged and cut to the requested limit.
+const failurePatternsScan = 1000
+
+func (s *Server) failurePatterns(project string, since time.Time, limit int) ([]store.FailurePattern, error) {
+	stores := s.stores()
+	if len(stores) == 1 {
+		return s.store.FailurePatterns(project, since, limit)
+	}
+	var out []store.FailurePattern
+	for _, st := range stores {
+		patterns, err := st.FailurePatterns(project, since, failurePatternsScan)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range patterns {
+			i := slices.IndexFunc(out, func(o store.FailurePattern) bool { return o.Category == p.Category })
+			if i < 0 {
+				out = append(out, p)
+				continue
+			}
+			out[i].Count += p.Count
+			out[i].Sessions += p.Sessions
+			if p.LastSeen.After(out[i].LastSeen) {
+				out[i].LastSeen, out[i].LastDiagnosis = p.LastSeen, p.LastDiagnosis
+			}
+		}
+	}
+	slices.SortStableFunc(out, func(a, b store.FailurePattern) int {
+		if a.Count != b.Count {
+			return b.Count - a.Count
+		}
+		return b.LastSeen.Compare(a.LastSeen)
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
+
+func (s *Server) dailySpend(since time.Time) ([]store.DailySpend, error) {
+	stores := s.stores()
+	if len(stores) == 1 {
+		return s.store.ListDailySpend(since)
+	}
+	var out []store.DailySpend
+	for _, st := range stores {
+		spend, err := st.ListDailySpend(since)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range spend {
+			i := slices.IndexFunc(out, func(o store.DailySpend) bool { return o.Day == d.Day && o.Model == d.Model })
+			if i < 0 {
+				out = append(out, d)
+				continue
+			}
+			out[i].InputTokens += d.InputTokens
+			out[i].OutputTokens += d.OutputTokens
+			out[i].CostUSD += d.CostUSD
+		}
+	}
+	slices.SortStableFunc(out, func(a, b store.DailySpend) int {
+		if a.Day != b.Day {
+			return strings.Compare(b.Day, a.Day)
+		}
+		if a.CostUSD != b.CostUSD {
+			return cmp.Compare(b.CostUSD, a.CostUSD)
+		}
+		return strings.Compare(a.Model, b.Model)
+	})
+	return out, nil
+}
+
+// daySpend is the spend on the local day of at, for budget.daily_usd.
+func (s *Server) daySpend(at time.Time) (float64, error) {
+	var total float64
+	for _, st := range s.stores() {
+		spent, err := st.DaySpend(at)
+		if err != nil {
+			return 0, err
+		}
+		total += spent
+	}
+	return total, nil
+}
+
+func (s *Server) usageSummary(since time.Time, groupBy string) ([]store.UsageRow, error) {
+	stores := s.stores()
+	if len(stores) == 1 {
+		return s.store.UsageSummary(since, groupBy)
+	}
+	var out []store.UsageRow
+	for _, st := range stores {
+		rows, err := st.UsageSummary(since, groupBy)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range rows {
+			i := slices.IndexFunc(out, func(o store.UsageRow) bool { return o.Key == r.Key })
+			if i < 0 {
+				out = append(out, r)
+				continue
+			}
+			out[i].InputTokens += r.InputTokens
+			out[i].OutputTokens += r.OutputTokens
+			out[i].CacheWriteTokens += r.CacheWriteTokens
+			out[i].CacheReadTokens += r.CacheReadTokens
+			out[i].CostUSD += r.CostUSD
+		}
+	}
+	slices.SortStableFunc(out, func(a, b store.UsageRow) int {
+		if groupBy == store.UsageByDay {
+			return strings.Compare(b.Key, a.Key)
+		}
+		if a.CostUSD != b.CostUSD {
+			return cmp.Compare(b.CostUSD, a.CostUSD)
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return out, nil
+}
+
+func (s *Server) callStatsSince(since time.Time) (store.CallStats, error) {
+	var sum store.CallStats
+	for _, st := range s.stores() {
+		c, err := st.CallStatsSince(since)
+		if err != nil {
+			return sum, err
+		}
+		sum.Sessions += c.Sessions
+		sum.Turns += c.Turns
+		sum.Calls += c.Calls
+		sum.ToolCalls += c.ToolCalls
+		sum.Compactions += c.Compactions
+	}
+	return sum, nil
+}
@@ -0,0 +1,115 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func TestProjectDatabases(t *testing.T) {
+	srv, global := newTestServer(t)
+	if err := srv.EnableProjectDatabases(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.closeProjectStores)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	create := func(project string) string {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"project_path": project})
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "POST", "/api/sessions", bytes.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("create in %s: expected 200, got %d: %s", project, w.Code, w.Body.String())
+		}
+		var resp map[string]string
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp["session_id"]
+	}
+
+	a, b := t.TempDir(), t.TempDir()
+	idA, idB := create(a), create(b)
+	idNone := create("")
+
+	for root, id := range map[string]string{a: idA, b: idB} {
+		if _, err := os.Stat(filepath.Join(root, store.ProjectDBDir, "muxd.db")); err != nil {
+			t.Fatalf("project db in %s: %v", root, err)
+		}
+		if _, err := global.GetSession(id); err == nil {
+			t.Errorf("session %s should not be in the global store", id)
+		}
+	}
+	if _, err := global.GetSession(idNone); err != nil {
+		t.Errorf("session with no project should be in the global store: %v", err)
+	}
+	if err := srv.sessionStore(idA).AppendMessage(idA, "user", "hello from a", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(mux *http.ServeMux, srv *Server, target string) *httptest.ResponseRecorder {
+		t.Helper()
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, "GET", target, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", target, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	var sess domain.Session
+	if err := json.NewDecoder(get(mux, srv, "/api/sessions/"+idB).Body).Decode(&sess); err != nil {
+		t.Fatal(err)
+	}
+	if sess.ProjectPath != b {
+		t.Errorf("expected project %s, got %s", b, sess.ProjectPath)
+	}
+
+	var list []domain.Session
+	if err := json.NewDecoder(get(mux, srv, "/api/sessions?limit=10").Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 3 {
+		t.Errorf("expected 3 sessions across stores, got %d", len(list))
+	}
+	list = nil
+	if err := json.NewDecoder(get(mux, srv, "/api/sessions?project="+url.QueryEscape(a)).Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != idA {
+		t.Errorf("expected only %s for project %s, got %+v", idA, a, list)
+	}
+
+	var msgs []domain.TranscriptMessage
+	if err := json.NewDecoder(get(mux, srv, "/api/sessions/"+idA+"/messages").Body).Decode(&msgs); err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Content != "hello from a" {
+		t.Errorf("expected the message from project a, got %+v", msgs)
+	}
+
+	// A restarted daemon reopens the project databases it recorded.
+	srv.closeProjectStores()
+	prefs := config.DefaultPreferences()
+	srv2 := NewServer(global, "test-key", "test-model", "test-label", nil, &prefs)
+	if err := srv2.EnableProjectDatabases(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv2.closeProjectStores)
+	mux2 := http.NewServeMux()
+	srv2.registerRoutes(mux2)
+	for _, id := range []string{idA, idB, idNone} {
+		get(mux2, srv2, "/api/sessions/"+id)
+	}
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/batalabs/muxd/internal/agent"
+)
+
+// ---------------------------------------------------------------------------
+// spawn_agent workers
+// ---------------------------------------------------------------------------
+
+// maxTrackedSubAgents caps the workers remembered per session; the oldest
+// finished ones are dropped first.
+const maxTrackedSubAgents = 64
+
+// subAgentTree tracks the spawn_agent workers started from each session.
+// Each worker runs in its own session, linked back by ParentID.
+type subAgentTree struct {
+	mu        sync.Mutex
+	bySession map[string][]SubAgentInfo
+}
+
+// update records the latest progress of a worker started from sessionID.
+func (t *subAgentTree) update(sessionID string, info SubAgentInfo) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.bySession == nil {
+		t.bySession = make(map[string][]SubAgentInfo)
+	}
+	list := t.bySession[sessionID]
+	for i := range list {
+		if list[i].ID == info.ID {
+			list[i] = info
+			return
+		}
+	}
+	list = append(list, info)
+	for len(list) > maxTrackedSubAgents {
+		drop := 0
+		for i, a := range list {
+			if a.State != agent.SubAgentRunning {
+				drop = i
+				break
+			}
+		}
+		list = append(list[:drop], list[drop+1:]...)
+	}
+	t.bySession[sessionID] = list
+}
+
+// list returns the workers started from sessionID, in start order.
+func (t *subAgentTree) list(sessionID string) []SubAgentInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]SubAgentInfo, len(t.bySession[sessionID]))
+	copy(out, t.bySession[sessionID])
+	return out
+}
+
+// forget drops the workers of a deleted session.
+func (t *subAgentTree) forget(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.bySession, sessionID)
+}
+
+func subAgentInfo(s agent.SubAgentStatus) SubAgentInfo {
+	return SubAgentInfo{
+		ID:        s.ID,
+		ParentID:  s.ParentID,
+		Name:      s.Name,
+		State:     s.State,
+		Tool:      s.Tool,
+		Turns:     s.Turns,
+		Tokens:    s.Tokens,
+		MaxTurns:  s.MaxTurns,
+		MaxTokens: s.MaxTokens,
+		Error:     s.Err,
+	}
+}
+
+func (s *Server) handleSubAgents(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.subAgents.list(r.PathValue("id")))
+}
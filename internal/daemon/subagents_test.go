@@ -0,0 +1,66 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/agent"
+)
+
+func TestSubAgentsEndpoint(t *testing.T) {
+	srv, _ := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	var sent []string
+	onEvent := srv.agentEventHandler("parent", func(event string, data any) { sent = append(sent, event) })
+	onEvent(agent.Event{Kind: agent.EventSubAgent, SubAgent: &agent.SubAgentStatus{ID: "w1", ParentID: "parent", Name: "docs", State: agent.SubAgentRunning}})
+	onEvent(agent.Event{Kind: agent.EventSubAgent, SubAgent: &agent.SubAgentStatus{ID: "w2", ParentID: "parent", Name: "tests", State: agent.SubAgentRunning}})
+	onEvent(agent.Event{Kind: agent.EventSubAgent, SubAgent: &agent.SubAgentStatus{ID: "w1", ParentID: "parent", Name: "docs", State: agent.SubAgentDone, Turns: 2}})
+
+	if len(sent) != 3 || sent[0] != "subagent" {
+		t.Errorf("sent = %v, want 3 subagent events", sent)
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/sessions/parent/agents", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var got []SubAgentInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ID != "w1" || got[0].State != agent.SubAgentDone || got[0].Turns != 2 || got[1].ID != "w2" {
+		t.Errorf("agents = %+v", got)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, newAuthedRequest(srv, "GET", "/api/sessions/other/agents", nil))
+	if w.Body.String() != "[]\n" {
+		t.Errorf("other session = %q, want []", w.Body.String())
+	}
+}
+
+func TestSubAgentTree_update(t *testing.T) {
+	var tree subAgentTree
+	tree.update("s", SubAgentInfo{ID: "running", State: agent.SubAgentRunning})
+	for i := range maxTrackedSubAgents {
+		tree.update("s", SubAgentInfo{ID: fmt.Sprint(i), State: agent.SubAgentDone})
+	}
+	list := tree.list("s")
+	if len(list) != maxTrackedSubAgents {
+		t.Fatalf("len = %d, want %d", len(list), maxTrackedSubAgents)
+	}
+	if list[0].ID != "running" || list[1].ID != "1" {
+		t.Errorf("kept %q, %q; want the running worker kept and the oldest finished dropped", list[0].ID, list[1].ID)
+	}
+
+	tree.forget("s")
+	if len(tree.list("s")) != 0 {
+		t.Error("forget kept workers")
+	}
+}
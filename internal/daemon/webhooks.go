@@ -0,0 +1,210 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/redact"
+	"github.com/batalabs/muxd/internal/store"
+	"github.com/batalabs/muxd/internal/webhook"
+)
+
+// webhookResultMax bounds the tool output included in tool_executed
+// payloads.
+const webhookResultMax = 4 * 1024
+
+// SetWebhookDispatcher replaces the dispatcher that delivers webhook
+// events, e.g. with one that retries faster in tests.
+func (s *Server) SetWebhookDispatcher(d *webhook.Dispatcher) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.webhookDispatcher = d
+	s.setWebhookResultLocked()
+}
+
+// webhookTargetsLocked returns the dispatcher and the webhooks subscribed
+// to event, loading them from the store the first time. Creating or
+// deleting a webhook clears the cached list. s.mu must be held.
+func (s *Server) webhookTargetsLocked(event string) (*webhook.Dispatcher, []store.Webhook) {
+	if !s.webhooksLoaded {
+		hooks, err := s.store.ListWebhooks()
+		if err != nil {
+			s.logf("webhooks: %v", err)
+			return nil, nil
+		}
+		s.webhooks, s.webhooksLoaded = hooks, true
+	}
+	var out []store.Webhook
+	for _, h := range s.webhooks {
+		if h.Wants(event) {
+			out = append(out, h)
+		}
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	if s.webhookDispatcher == nil {
+		s.webhookDispatcher = webhook.NewDispatcher()
+		s.setWebhookResultLocked()
+	}
+	return s.webhookDispatcher, out
+}
+
+// setWebhookResultLocked records each delivery's outcome on its webhook.
+func (s *Server) setWebhookResultLocked() {
+	s.webhookDispatcher.OnResult = func(t webhook.Target, p webhook.Payload, attempts int, err error) {
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+			s.logf("webhook %s: %s delivery failed after %d attempts: %v", t.ID, p.Event, attempts, err)
+		}
+		if err := s.store.RecordWebhookDelivery(t.ID, time.Now(), errText); err != nil {
+			s.logf("webhook %s: %v", t.ID, err)
+		}
+	}
+}
+
+// emitWebhook delivers an event to the webhooks subscribed to it.
+func (s *Server) emitWebhook(event, sessionID string, data any) {
+	s.mu.Lock()
+	d, hooks := s.webhookTargetsLocked(event)
+	s.mu.Unlock()
+	if len(hooks) == 0 {
+		return
+	}
+	p := webhook.Payload{
+		ID:        domain.NewUUID(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		SessionID: sessionID,
+		Data:      data,
+	}
+	for _, h := range hooks {
+		if !d.Send(webhook.Target{ID: h.ID, URL: h.URL, Secret: h.Secret}, p) {
+			s.logf("webhook %s: dropped %s event, too many deliveries in flight", h.ID, event)
+		}
+	}
+}
+
+// teeToWebhooks wraps send so that finished turns, tool results, and
+// errors are also delivered to webhooks. Tool output is redacted and
+// truncated.
+func (s *Server) teeToWebhooks(sessionID string, send func(event string, data any)) func(event string, data any) {
+	return func(event string, data any) {
+		send(event, data)
+		switch event {
+		case "turn_done":
+			s.emitWebhook(webhook.EventTurnDone, sessionID, data)
+		case "tool_done":
+			d, _ := data.(map[string]any)
+			result, _ := d["result"].(string)
+			result = redact.Secrets(result)
+			if len(result) > webhookResultMax {
+				result = result[:webhookResultMax] + "..."
+			}
+			s.emitWebhook(webhook.EventToolExecuted, sessionID, map[string]any{
+				"tool_use_id": d["tool_use_id"],
+				"tool_name":   d["tool_name"],
+				"is_error":    d["is_error"],
+				"result":      result,
+			})
+		case "error":
+			s.emitWebhook(webhook.EventError, sessionID, data)
+		}
+	}
+}
+
+// emitSessionCreated announces a new session to webhooks.
+func (s *Server) emitSessionCreated(sess *domain.Session) {
+	s.emitWebhook(webhook.EventSessionCreated, sess.ID, map[string]string{
+		"project_path": sess.ProjectPath,
+		"model":        sess.Model,
+	})
+}
+
+// closeWebhooks abandons pending retries on shutdown.
+func (s *Server) closeWebhooks() {
+	s.mu.Lock()
+	d := s.webhookDispatcher
+	s.mu.Unlock()
+	if d != nil {
+		d.Close()
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Handlers
+// ---------------------------------------------------------------------------
+
+// handleCreateWebhook adds a webhook: POST /api/webhooks {"url": ...,
+// "events": [...], "secret": ...}. Without a secret one is generated. The
+// response is the only place the secret is returned.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid request body"})
+		return
+	}
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid webhook URL (want http(s)://host/path)"})
+		return
+	}
+	events, err := webhook.ParseEvents(req.Events)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret = generateAuthToken()
+	}
+	hook, err := s.store.CreateWebhook(req.URL, secret, events)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	s.mu.Lock()
+	s.webhooksLoaded = false
+	s.mu.Unlock()
+	s.logf("webhook %s created url=%s", hook.ID, hook.URL)
+	writeJSON(w, http.StatusOK, struct {
+		*store.Webhook
+		Secret string `json:"secret"`
+	}{hook, secret})
+}
+
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := s.store.ListWebhooks()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if hooks == nil {
+		hooks = []store.Webhook{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"webhooks": hooks})
+}
+
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	ok, err := s.store.DeleteWebhook(r.PathValue("id"))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "webhook not found"})
+		return
+	}
+	s.mu.Lock()
+	s.webhooksLoaded = false
+	s.mu.Unlock()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "deleted"})
+}
@@ -0,0 +1,129 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/webhook"
+)
+
+type receivedHook struct {
+	payload webhook.Payload
+	header  http.Header
+	body    []byte
+}
+
+func TestWebhooks(t *testing.T) {
+	got := make(chan receivedHook, 8)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var p webhook.Payload
+		_ = json.Unmarshal(body, &p)
+		got <- receivedHook{p, r.Header.Clone(), body}
+	}))
+	defer receiver.Close()
+
+	srv, st := newTestServer(t)
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, newAuthedRequest(srv, method, path, strings.NewReader(body)))
+		return w
+	}
+	recv := func() receivedHook {
+		t.Helper()
+		select {
+		case h := <-got:
+			return h
+		case <-time.After(5 * time.Second):
+			t.Fatal("no webhook delivered")
+		}
+		return receivedHook{}
+	}
+
+	if w := do("POST", "/api/webhooks", `{"url":"ftp://example.com"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad URL: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/api/webhooks", `{"url":"https://example.com","events":["deploy"]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown event: expected 400, got %d", w.Code)
+	}
+	w := do("POST", "/api/webhooks", `{"url":"`+receiver.URL+`","events":["turn_done","tool_executed","session_created"]}`)
+	var created struct {
+		ID     string   `json:"id"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&created); err != nil || w.Code != http.StatusOK || created.Secret == "" {
+		t.Fatalf("create: %d %+v %v", w.Code, created, err)
+	}
+
+	// Subscribed events are delivered, signed with the secret.
+	w = do("POST", "/api/sessions", `{"project_path":"/tmp/p"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create session: %d", w.Code)
+	}
+	h := recv()
+	if h.payload.Event != webhook.EventSessionCreated || h.payload.SessionID == "" {
+		t.Errorf("session_created = %+v", h.payload)
+	}
+	ts, _ := strconv.ParseInt(h.header.Get(webhook.HeaderTimestamp), 10, 64)
+	if !webhook.Verify(created.Secret, ts, h.body, h.header.Get(webhook.HeaderSignature)) {
+		t.Error("delivery signature does not verify with the returned secret")
+	}
+
+	send := srv.teeToWebhooks("sess-1", func(string, any) {})
+	send("delta", map[string]string{"text": "x"})
+	send("error", map[string]any{"error": "boom"}) // not subscribed
+	send("tool_done", map[string]any{"tool_use_id": "tu_1", "tool_name": "bash", "is_error": false,
+		"result": "token sk-ant-REDACTED " + strings.Repeat("x", 2*webhookResultMax)})
+	h = recv()
+	data, _ := h.payload.Data.(map[string]any)
+	result, _ := data["result"].(string)
+	if h.payload.Event != webhook.EventToolExecuted || data["tool_name"] != "bash" || h.payload.SessionID != "sess-1" {
+		t.Errorf("tool_executed = %+v", h.payload)
+	}
+	if strings.Contains(result, "sk-ant-api03") || len(result) > webhookResultMax+3 {
+		t.Errorf("tool result not redacted and truncated: %d bytes", len(result))
+	}
+	send("turn_done", map[string]string{"stop_reason": "end_turn"})
+	if h = recv(); h.payload.Event != webhook.EventTurnDone {
+		t.Errorf("turn_done = %+v", h.payload)
+	}
+
+	// The outcome is recorded on the webhook.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		hooks, _ := st.ListWebhooks()
+		if len(hooks) == 1 && hooks[0].LastDeliveryAt != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("delivery was not recorded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	w = do("GET", "/api/webhooks", "")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), created.Secret) || !strings.Contains(w.Body.String(), created.ID) {
+		t.Errorf("list: %d %s", w.Code, w.Body)
+	}
+	if w := do("DELETE", "/api/webhooks/"+created.ID, ""); w.Code != http.StatusOK {
+		t.Errorf("delete: %d", w.Code)
+	}
+	if w := do("DELETE", "/api/webhooks/"+created.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete again: expected 404, got %d", w.Code)
+	}
+	send("turn_done", map[string]string{})
+	select {
+	case h := <-got:
+		t.Errorf("delivery after delete: %+v", h.payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
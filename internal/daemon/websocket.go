@@ -0,0 +1,267 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/agent"
+)
+
+// wsDoneEvent marks the end of a submitted turn on a WebSocket. SSE clients
+// see the end of the response body instead.
+const wsDoneEvent = "done"
+
+// wsClientMessage is a frame sent by a WebSocket client. Type is one of
+// submit, cancel, stop, steer, ask_response, or approve; the other fields mirror the
+// bodies of the matching HTTP endpoints.
+type wsClientMessage struct {
+	Type        string             `json:"type"`
+	Text        string             `json:"text,omitempty"`
+	Images      []SubmitImage      `json:"images,omitempty"`
+	Attachments []SubmitAttachment `json:"attachments,omitempty"`
+	AskID       string             `json:"ask_id,omitempty"`
+	Answer      string             `json:"answer,omitempty"`
+	ApprovalID  string             `json:"approval_id,omitempty"`
+	Decision    string             `json:"decision,omitempty"`
+}
+
+// wsServerMessage is a frame sent to a WebSocket client. Event and Data are
+// the SSE event name and payload.
+type wsServerMessage struct {
+	Event string `json:"event"`
+	Data  any    `json:"data"`
+}
+
+// ---------------------------------------------------------------------------
+// Server
+// ---------------------------------------------------------------------------
+
+// handleSessionSocket upgrades to a WebSocket carrying the session's event
+// stream. Submit, cancel, ask responses, and approvals all travel over the
+// same socket, one turn at a time.
+func (s *Server) handleSessionSocket(w http.ResponseWriter, r *http.Request) {
+	sessionID := r.PathValue("id")
+	ag, err := s.getOrCreateAgent(sessionID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	ws := websocket.Server{
+		// Requests are authorized by token (or socket permissions), and
+		// non-browser clients send no Origin, so skip the origin check.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			s.serveSessionSocket(conn, sessionID, ag, canSubmit(r))
+		},
+	}
+	ws.ServeHTTP(w, r)
+}
+
+// serveSessionSocket relays events to the client. Read-only tokens get the
+// event stream but may not submit, cancel, or answer prompts.
+func (s *Server) serveSessionSocket(conn *websocket.Conn, sessionID string, ag *agent.Service, canWrite bool) {
+	var mu sync.Mutex
+	send := func(event string, data any) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := websocket.JSON.Send(conn, wsServerMessage{Event: event, Data: data}); err != nil {
+			s.logf("ws session=%s: send %s: %v", sessionID, event, err)
+		}
+	}
+	sendError := func(msg string) {
+		send("error", map[string]string{"error": msg})
+	}
+	onEvent := s.agentEventHandler(sessionID, send)
+
+	// A dropped socket does not cancel a running turn, matching SSE.
+	var busy atomic.Bool
+	for {
+		var msg wsClientMessage
+		if err := websocket.JSON.Receive(conn, &msg); err != nil {
+			if !errors.Is(err, io.EOF) {
+				s.logf("ws session=%s: receive: %v", sessionID, err)
+			}
+			return
+		}
+		if !canWrite {
+			sendError("token scope read does not allow " + msg.Type)
+			if msg.Type == "submit" {
+				// End the client's turn; nothing will run.
+				send(wsDoneEvent, map[string]string{})
+			}
+			continue
+		}
+		switch msg.Type {
+		case "submit":
+			req := submitRequest{Text: msg.Text, Images: msg.Images, Attachments: msg.Attachments}
+			if req.empty() {
+				sendError("empty text")
+				continue
+			}
+			if !busy.CompareAndSwap(false, true) {
+				sendError("a turn is already running on this socket")
+				continue
+			}
+			s.logf("ws submit session=%s len=%d attachments=%d", sessionID, len(req.Text), len(req.Images)+len(req.Attachments))
+			go func() {
+				defer busy.Store(false)
+				s.runSubmit(sessionID, ag, req, onEvent)
+				send(wsDoneEvent, map[string]string{})
+			}()
+
+		case "cancel":
+			ag.Cancel()
+
+		case "stop":
+			ag.Stop()
+
+		case "steer":
+			if err := ag.Steer(msg.Text); err != nil {
+				sendError(err.Error())
+			}
+
+		case "ask_response":
+			if !s.answerAsk(msg.AskID, msg.Answer) {
+				sendError("unknown ask_id")
+			}
+
+		case "approve":
+			decision, err := agent.ParseApprovalDecision(msg.Decision)
+			if err != nil {
+				sendError(err.Error())
+				continue
+			}
+			if !s.answerApproval(msg.ApprovalID, decision) {
+				sendError("unknown approval_id")
+			}
+
+		default:
+			sendError(fmt.Sprintf("unknown message type %q", msg.Type))
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Client
+// ---------------------------------------------------------------------------
+
+// errWebSocketUnavailable means the socket could not be opened and no
+// message was sent, so the caller may safely retry over SSE.
+var errWebSocketUnavailable = errors.New("websocket unavailable")
+
+// dialSessionSocket opens the session's WebSocket over this client's
+// transport (TCP or unix socket).
+func (c *DaemonClient) dialSessionSocket(sessionID string) (*websocket.Conn, error) {
+	u, err := url.Parse(c.baseURL + "/api/sessions/" + sessionID + "/ws")
+	if err != nil {
+		return nil, err
+	}
+	if ft, ok := c.transport.(*failoverTransport); ok {
+		u.Host = ft.activeHost(u.Host)
+	}
+	origin := *u
+	origin.Path = ""
+	u.Scheme = "ws"
+	cfg, err := websocket.NewConfig(u.String(), origin.String())
+	if err != nil {
+		return nil, err
+	}
+	if c.authToken != "" {
+		cfg.Header.Set("Authorization", "Bearer "+c.authToken)
+	}
+
+	var nc net.Conn
+	if c.socketPath != "" {
+		nc, err = net.DialTimeout("unix", c.socketPath, clientTimeout)
+	} else {
+		nc, err = net.DialTimeout("tcp", u.Host, clientTimeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	_ = nc.SetDeadline(time.Now().Add(clientTimeout))
+	ws, err := websocket.NewClient(cfg, nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	_ = nc.SetDeadline(time.Time{})
+	return ws, nil
+}
+
+// submitWebSocket runs one turn over the session's WebSocket. While it runs,
+// Cancel, SendAskResponse, and SendApproval use the same socket.
+func (c *DaemonClient) submitWebSocket(sessionID, text string, attachments []SubmitAttachment, onEvent func(SSEEvent)) error {
+	ws, err := c.dialSessionSocket(sessionID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", errWebSocketUnavailable, err)
+	}
+	defer ws.Close()
+
+	if err := websocket.JSON.Send(ws, wsClientMessage{Type: "submit", Text: text, Attachments: attachments}); err != nil {
+		return fmt.Errorf("%w: %v", errWebSocketUnavailable, err)
+	}
+	c.setSessionSocket(sessionID, ws)
+	defer c.setSessionSocket(sessionID, nil)
+
+	f := &turnFollower{onEvent: onEvent}
+	for {
+		var msg struct {
+			Event string          `json:"event"`
+			Data  json.RawMessage `json:"data"`
+		}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			// The turn goes on in the daemon; pick it up over SSE.
+			c.setSessionSocket(sessionID, nil)
+			return c.resumeStream(sessionID, f, fmt.Errorf("reading websocket: %w", err))
+		}
+		if msg.Event == wsDoneEvent {
+			return nil
+		}
+		if evt := ParseSSEEvent(msg.Event, string(msg.Data)); evt.Type != "" {
+			f.deliver(evt)
+		} else {
+			f.last++ // keep counting in step with the daemon's event log
+		}
+	}
+}
+
+func (c *DaemonClient) setSessionSocket(sessionID string, ws *websocket.Conn) {
+	c.wsMu.Lock()
+	defer c.wsMu.Unlock()
+	if ws == nil {
+		delete(c.sockets, sessionID)
+		return
+	}
+	if c.sockets == nil {
+		c.sockets = make(map[string]*websocket.Conn)
+	}
+	c.sockets[sessionID] = ws
+}
+
+// sendOnSocket sends msg over the session's open WebSocket. It reports false
+// if there is none or the send fails, in which case the caller should use
+// the HTTP endpoint.
+func (c *DaemonClient) sendOnSocket(sessionID string, msg wsClientMessage) bool {
+	c.wsMu.Lock()
+	ws := c.sockets[sessionID]
+	c.wsMu.Unlock()
+	if ws == nil {
+		return false
+	}
+	return websocket.JSON.Send(ws, msg) == nil
+}
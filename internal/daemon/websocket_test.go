@@ -0,0 +1,191 @@
+package daemon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// echoProvider replies to every request with a single text block.
+type echoProvider struct{}
+
+func (echoProvider) StreamMessage(_, _ string, _ []domain.TranscriptMessage, _ []provider.ToolSpec, _ string, onDelta func(string)) ([]domain.ContentBlock, string, provider.Usage, error) {
+	if onDelta != nil {
+		onDelta("hi")
+	}
+	return []domain.ContentBlock{{Type: "text", Text: "hi"}}, "end_turn", provider.Usage{}, nil
+}
+
+func (echoProvider) FetchModels(string) ([]domain.APIModelInfo, error) { return nil, nil }
+func (echoProvider) Name() string                                      { return "echo" }
+
+// newSocketTestServer serves srv's routes and records the request paths.
+func newSocketTestServer(t *testing.T, srv *Server) (*httptest.Server, func() []string) {
+	t.Helper()
+	srv.SetAgentFactory(func(apiKey, modelID, modelLabel string, st *store.Store, sess *domain.Session, _ provider.Provider) *agent.Service {
+		return agent.NewService(apiKey, modelID, modelLabel, st, sess, echoProvider{})
+	})
+	mux := http.NewServeMux()
+	srv.registerRoutes(mux)
+
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), paths...)
+	}
+}
+
+func TestSubmitOverWebSocket(t *testing.T) {
+	srv, st := newTestServer(t)
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	ts, paths := newSocketTestServer(t, srv)
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+
+	var events []string
+	err := client.Submit(sess.ID, "hello", nil, func(evt SSEEvent) {
+		events = append(events, evt.Type)
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if got := strings.Join(events, ","); !strings.Contains(got, "delta") || !strings.Contains(got, "turn_done") {
+		t.Errorf("events = %s, want delta and turn_done", got)
+	}
+	for _, p := range paths() {
+		if strings.HasSuffix(p, "/submit") {
+			t.Errorf("expected no SSE fallback, got request to %s", p)
+		}
+	}
+	if len(client.sockets) != 0 {
+		t.Error("expected the socket to be released after the turn")
+	}
+}
+
+func TestSubmitWebSocketRequiresAuth(t *testing.T) {
+	srv, st := newTestServer(t)
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	ts, _ := newSocketTestServer(t, srv)
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	if _, err := client.dialSessionSocket(sess.ID); err == nil {
+		t.Fatal("expected dial without a token to fail")
+	}
+}
+
+func TestSessionSocketRejectsBadMessages(t *testing.T) {
+	srv, st := newTestServer(t)
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	ts, _ := newSocketTestServer(t, srv)
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken(srv.AuthToken())
+	ws, err := client.dialSessionSocket(sess.ID)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer ws.Close()
+
+	for _, tc := range []struct {
+		msg  wsClientMessage
+		want string
+	}{
+		{wsClientMessage{Type: "bogus"}, "unknown message type"},
+		{wsClientMessage{Type: "submit"}, "empty text"},
+		{wsClientMessage{Type: "ask_response", AskID: "nope"}, "unknown ask_id"},
+		{wsClientMessage{Type: "approve", ApprovalID: "nope", Decision: "maybe"}, "invalid"},
+	} {
+		if err := websocket.JSON.Send(ws, tc.msg); err != nil {
+			t.Fatal(err)
+		}
+		var got struct {
+			Event string            `json:"event"`
+			Data  map[string]string `json:"data"`
+		}
+		if err := websocket.JSON.Receive(ws, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Event != "error" || !strings.Contains(got.Data["error"], tc.want) {
+			t.Errorf("%s: got %s %v, want error containing %q", tc.msg.Type, got.Event, got.Data, tc.want)
+		}
+	}
+}
+
+func TestDaemonClientSubmitFallsBackToSSE(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if !strings.HasSuffix(r.URL.Path, "/submit") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: turn_done\ndata: {\"stop_reason\":\"end_turn\"}\n\n")
+	}))
+	defer ts.Close()
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+
+	var events []SSEEvent
+	if err := client.Submit("s1", "hello", nil, func(evt SSEEvent) { events = append(events, evt) }); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "turn_done" {
+		t.Errorf("events = %+v", events)
+	}
+	want := []string{"GET /api/sessions/s1/ws", "POST /api/sessions/s1/submit"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("requests = %v, want %v", paths, want)
+	}
+}
+
+func TestSubmitOverWebSocket_readOnlyToken(t *testing.T) {
+	srv, st := newTestServer(t)
+	sess, _ := st.CreateSession("/tmp/test", "test-model")
+	if _, err := st.CreateAPIToken("dashboard", store.TokenScopeRead, "read-secret"); err != nil {
+		t.Fatal(err)
+	}
+	ts, _ := newSocketTestServer(t, srv)
+
+	client := NewDaemonClient(0)
+	client.SetBaseURL(ts.URL)
+	client.SetAuthToken("read-secret")
+
+	var events []string
+	err := client.Submit(sess.ID, "hello", nil, func(evt SSEEvent) {
+		events = append(events, evt.Type)
+	})
+	if err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	if got := strings.Join(events, ","); got != "error" {
+		t.Errorf("events = %s, want a single error", got)
+	}
+	if msgs, _ := st.GetMessages(sess.ID); len(msgs) != 0 {
+		t.Errorf("read-only token stored %d messages", len(msgs))
+	}
+}
@@ -0,0 +1,204 @@
+package diagram
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+// Dir is the project-relative directory rendered diagrams are written to.
+const Dir = ".muxd/diagrams"
+
+// Block is a diagram source extracted from a fenced code block.
+type Block struct {
+	Kind   string // "mermaid" or "graphviz"
+	Source string
+}
+
+// kindForLang maps a code fence language to a diagram kind.
+func kindForLang(lang string) string {
+	switch strings.ToLower(strings.TrimSpace(lang)) {
+	case "mermaid", "mmd":
+		return "mermaid"
+	case "dot", "graphviz", "gv":
+		return "graphviz"
+	}
+	return ""
+}
+
+// Extract returns the mermaid and graphviz code blocks in a markdown text,
+// in order of appearance.
+func Extract(text string) []Block {
+	var blocks []Block
+	var cur *Block
+	var buf strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			if cur != nil {
+				buf.WriteString(line)
+				buf.WriteByte('\n')
+			}
+			continue
+		}
+		if cur != nil {
+			if src := strings.TrimSpace(buf.String()); src != "" {
+				cur.Source = src
+				blocks = append(blocks, *cur)
+			}
+			cur = nil
+			buf.Reset()
+			continue
+		}
+		if kind := kindForLang(strings.TrimPrefix(trimmed, "```")); kind != "" {
+			cur = &Block{Kind: kind}
+		}
+	}
+	return blocks
+}
+
+// FileName returns the content-addressed output file name for a block.
+func (b Block) FileName() string {
+	sum := sha256.Sum256([]byte(b.Kind + "\x00" + b.Source))
+	return b.Kind + "-" + hex.EncodeToString(sum[:6]) + ".svg"
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+// Renderer turns diagram blocks into SVG files.
+type Renderer struct {
+	// KrokiURL is an optional Kroki server (e.g. https://kroki.io) used when
+	// the local binary is not installed.
+	KrokiURL string
+	// Timeout bounds a single render. Zero means 30s.
+	Timeout time.Duration
+
+	lookPath func(string) (string, error)
+	client   *http.Client
+}
+
+// NewRenderer creates a renderer. krokiURL may be empty to use local
+// binaries only (mmdc for mermaid, dot for graphviz).
+func NewRenderer(krokiURL string) *Renderer {
+	return &Renderer{
+		KrokiURL: strings.TrimRight(krokiURL, "/"),
+		lookPath: exec.LookPath,
+		client:   &http.Client{Transport: egress.Transport(nil)},
+	}
+}
+
+// ErrNoRenderer is returned when neither a local binary nor a Kroki URL is
+// available for a diagram kind.
+var ErrNoRenderer = errors.New("no diagram renderer available")
+
+// Render writes b as an SVG file into dir and returns its path. Identical
+// sources reuse the existing file.
+func (r *Renderer) Render(ctx context.Context, b Block, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("creating diagram dir: %w", err)
+	}
+	out := filepath.Join(dir, b.FileName())
+	if _, err := os.Stat(out); err == nil {
+		return out, nil
+	}
+
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if bin := r.localBinary(b.Kind); bin != "" {
+		if err := renderLocal(ctx, bin, b, out); err != nil {
+			return "", err
+		}
+		return out, nil
+	}
+	if r.KrokiURL != "" {
+		svg, err := r.renderKroki(ctx, b)
+		if err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(out, svg, 0o644); err != nil {
+			return "", fmt.Errorf("writing diagram: %w", err)
+		}
+		return out, nil
+	}
+	return "", fmt.Errorf("%w for %s (install %s or set diagrams.kroki_url)", ErrNoRenderer, b.Kind, binaryFor(b.Kind))
+}
+
+func binaryFor(kind string) string {
+	if kind == "mermaid" {
+		return "mmdc"
+	}
+	return "dot"
+}
+
+func (r *Renderer) localBinary(kind string) string {
+	if r.lookPath == nil {
+		return ""
+	}
+	path, err := r.lookPath(binaryFor(kind))
+	if err != nil {
+		return ""
+	}
+	return path
+}
+
+func renderLocal(ctx context.Context, bin string, b Block, out string) error {
+	var cmd *exec.Cmd
+	if b.Kind == "mermaid" {
+		// mmdc reads stdin when the input is "-".
+		cmd = exec.CommandContext(ctx, bin, "-i", "-", "-o", out, "-q")
+	} else {
+		cmd = exec.CommandContext(ctx, bin, "-Tsvg", "-o", out)
+	}
+	cmd.Stdin = strings.NewReader(b.Source)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		_ = os.Remove(out)
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("%s: %s", filepath.Base(bin), msg)
+	}
+	return nil
+}
+
+func (r *Renderer) renderKroki(ctx context.Context, b Block) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.KrokiURL+"/"+b.Kind+"/svg", strings.NewReader(b.Source))
+	if err != nil {
+		return nil, fmt.Errorf("creating kroki request: %w", err)
+	}
+	req.Header.Set("Content-Type", "text/plain")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kroki request: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return nil, fmt.Errorf("reading kroki response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kroki returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
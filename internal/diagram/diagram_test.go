@@ -0,0 +1,79 @@
+package diagram
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	text := "Here is the flow:\n\n```mermaid\ngraph TD\n  A --> B\n```\n\nand the deps:\n\n```dot\ndigraph { a -> b }\n```\n\n```go\nfunc main() {}\n```\n\n```mermaid\n```\n"
+	blocks := Extract(text)
+	if len(blocks) != 2 {
+		t.Fatalf("got %d blocks, want 2: %+v", len(blocks), blocks)
+	}
+	if blocks[0].Kind != "mermaid" || blocks[0].Source != "graph TD\n  A --> B" {
+		t.Errorf("unexpected first block: %+v", blocks[0])
+	}
+	if blocks[1].Kind != "graphviz" || blocks[1].Source != "digraph { a -> b }" {
+		t.Errorf("unexpected second block: %+v", blocks[1])
+	}
+}
+
+func TestBlock_FileName(t *testing.T) {
+	a := Block{Kind: "mermaid", Source: "graph TD; A-->B"}
+	b := Block{Kind: "mermaid", Source: "graph TD; A-->C"}
+	if a.FileName() != a.FileName() {
+		t.Error("file name should be stable")
+	}
+	if a.FileName() == b.FileName() {
+		t.Error("different sources should get different names")
+	}
+}
+
+func TestRender_kroki(t *testing.T) {
+	var gotPath, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		_, _ = w.Write([]byte("<svg/>"))
+	}))
+	defer srv.Close()
+
+	r := NewRenderer(srv.URL + "/")
+	r.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+
+	dir := t.TempDir()
+	b := Block{Kind: "graphviz", Source: "digraph { a -> b }"}
+	path, err := r.Render(context.Background(), b, dir)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if gotPath != "/graphviz/svg" || gotBody != b.Source {
+		t.Errorf("kroki got path=%q body=%q", gotPath, gotBody)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "<svg/>" {
+		t.Errorf("output = %q, %v", data, err)
+	}
+
+	// A second render reuses the cached file.
+	srv.Close()
+	if again, err := r.Render(context.Background(), b, dir); err != nil || again != path {
+		t.Errorf("cached render = %q, %v", again, err)
+	}
+}
+
+func TestRender_noRenderer(t *testing.T) {
+	r := NewRenderer("")
+	r.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+	_, err := r.Render(context.Background(), Block{Kind: "mermaid", Source: "graph TD"}, t.TempDir())
+	if !errors.Is(err, ErrNoRenderer) {
+		t.Errorf("err = %v, want ErrNoRenderer", err)
+	}
+}
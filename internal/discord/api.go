@@ -0,0 +1,313 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+// DefaultAPIHost is the Discord REST API base URL.
+const DefaultAPIHost = "https://discord.com/api/v10"
+
+const (
+	requestTimeout = 30 * time.Second
+	maxRetryAfter  = 10 * time.Second
+)
+
+// api calls the Discord REST API as a bot.
+type api struct {
+	host   string
+	token  string
+	client *http.Client
+}
+
+// apiError is a non-2xx REST response.
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.Status, e.Message)
+}
+
+// call sends body as JSON and decodes the response into out. A 429 is
+// retried once after the delay Discord asks for.
+func (a *api) call(ctx context.Context, method, path string, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(a.host, "/")+path, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+a.token)
+		req.Header.Set("User-Agent", "DiscordBot (https://github.com/batalabs/muxd, 1)")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		resp, err := a.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("%s %s: %w", method, path, err)
+		}
+		raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusTooManyRequests && attempt == 0 {
+			var limit struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			_ = json.Unmarshal(raw, &limit)
+			wait := min(time.Duration(limit.RetryAfter*float64(time.Second)), maxRetryAfter)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if resp.StatusCode/100 != 2 {
+			var msg struct {
+				Message string `json:"message"`
+			}
+			_ = json.Unmarshal(raw, &msg)
+			if msg.Message == "" {
+				msg.Message = strings.TrimSpace(string(raw))
+			}
+			return fmt.Errorf("%s %s: %w", method, path, &apiError{resp.StatusCode, msg.Message})
+		}
+		if out != nil && len(raw) > 0 {
+			if err := json.Unmarshal(raw, out); err != nil {
+				return fmt.Errorf("%s %s: parsing response: %w", method, path, err)
+			}
+		}
+		return nil
+	}
+}
+
+// me returns the bot's own user ID.
+func (a *api) me(ctx context.Context) (string, error) {
+	var user struct {
+		ID string `json:"id"`
+	}
+	if err := a.call(ctx, http.MethodGet, "/users/@me", nil, &user); err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
+
+// send posts content to channel, as a reply to replyTo when it is set, and
+// returns the new message's ID. Rows of buttons go under the text.
+func (a *api) send(ctx context.Context, channel, replyTo, content string, rows ...actionRow) (string, error) {
+	body := map[string]any{
+		"content": content,
+		// Replies and model output must not ping anyone.
+		"allowed_mentions": map[string]any{"parse": []string{}},
+	}
+	if len(rows) > 0 {
+		body["components"] = rows
+	}
+	if replyTo != "" {
+		body["message_reference"] = map[string]any{"message_id": replyTo, "fail_if_not_exists": false}
+	}
+	var msg struct {
+		ID string `json:"id"`
+	}
+	if err := a.call(ctx, http.MethodPost, "/channels/"+channel+"/messages", body, &msg); err != nil {
+		return "", err
+	}
+	return msg.ID, nil
+}
+
+// edit replaces the content of a message the bot sent.
+func (a *api) edit(ctx context.Context, channel, messageID, content string) error {
+	return a.call(ctx, http.MethodPatch, "/channels/"+channel+"/messages/"+messageID, map[string]any{"content": content}, nil)
+}
+
+// registerCommands replaces the bot's global application commands.
+func (a *api) registerCommands(ctx context.Context, appID string, cmds []applicationCommand) error {
+	return a.call(ctx, http.MethodPut, "/applications/"+appID+"/commands", cmds, nil)
+}
+
+// respond answers an interaction with a message only the caller sees.
+func (a *api) respond(ctx context.Context, interactionID, token, content string) error {
+	body := map[string]any{
+		"type": 4, // CHANNEL_MESSAGE_WITH_SOURCE
+		"data": map[string]any{
+			"content":          content,
+			"flags":            64, // EPHEMERAL
+			"allowed_mentions": map[string]any{"parse": []string{}},
+		},
+	}
+	return a.call(ctx, http.MethodPost, "/interactions/"+interactionID+"/"+token+"/callback", body, nil)
+}
+
+// update answers a button click by replacing the message it was on with
+// content and no buttons.
+func (a *api) update(ctx context.Context, interactionID, token, content string) error {
+	body := map[string]any{
+		"type": 7, // UPDATE_MESSAGE
+		"data": map[string]any{
+			"content":          content,
+			"components":       []actionRow{},
+			"allowed_mentions": map[string]any{"parse": []string{}},
+		},
+	}
+	return a.call(ctx, http.MethodPost, "/interactions/"+interactionID+"/"+token+"/callback", body, nil)
+}
+
+// ---------------------------------------------------------------------------
+// Gateway
+// ---------------------------------------------------------------------------
+
+// Gateway opcodes.
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatAck   = 11
+)
+
+// Gateway intents the bot needs: guild and direct messages, and their
+// content (a privileged intent enabled in the developer portal).
+const intents = 1<<9 | 1<<12 | 1<<15
+
+// frame is a gateway payload.
+type frame struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+// message is the subset of a MESSAGE_CREATE event the bot reads.
+type message struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	Content   string `json:"content"`
+	Author    struct {
+		ID  string `json:"id"`
+		Bot bool   `json:"bot"`
+	} `json:"author"`
+	Mentions []struct {
+		ID string `json:"id"`
+	} `json:"mentions"`
+	Attachments []attachment `json:"attachments"`
+}
+
+// interaction is the subset of an INTERACTION_CREATE event the bot reads.
+type interaction struct {
+	ID        string `json:"id"`
+	Token     string `json:"token"`
+	Type      int    `json:"type"` // 2 is an application command, 3 a button click
+	ChannelID string `json:"channel_id"`
+	Member    *struct {
+		User struct {
+			ID string `json:"id"`
+		} `json:"user"`
+	} `json:"member"` // set in guilds
+	User *struct {
+		ID string `json:"id"`
+	} `json:"user"` // set in DMs
+	Data struct {
+		Name    string `json:"name"`
+		Options []struct {
+			Name  string `json:"name"`
+			Value any    `json:"value"`
+		} `json:"options"`
+		CustomID string `json:"custom_id"` // the clicked button
+	} `json:"data"`
+	// Message is the message a clicked button is on.
+	Message *struct {
+		Content string `json:"content"`
+	} `json:"message"`
+}
+
+// userID returns who invoked the interaction.
+func (i interaction) userID() string {
+	if i.Member != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// option returns a string option's value.
+func (i interaction) option(name string) string {
+	for _, o := range i.Data.Options {
+		if o.Name == name {
+			s, _ := o.Value.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+// applicationCommand is a slash command registered with Discord.
+type applicationCommand struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Options     []applicationOption `json:"options,omitempty"`
+}
+
+// actionRow is a row of up to five buttons under a message.
+type actionRow struct {
+	Type       int      `json:"type"` // 1
+	Components []button `json:"components"`
+}
+
+type button struct {
+	Type     int    `json:"type"`  // 2
+	Style    int    `json:"style"` // 1 primary, 2 secondary, 3 success, 4 danger
+	Label    string `json:"label"`
+	CustomID string `json:"custom_id"`
+}
+
+type applicationOption struct {
+	Type        int    `json:"type"` // 3 is a string
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+}
+
+// openGateway asks for the gateway URL and dials it.
+func (a *api) openGateway(ctx context.Context) (*websocket.Conn, error) {
+	var gw struct {
+		URL string `json:"url"`
+	}
+	if err := a.call(ctx, http.MethodGet, "/gateway", nil, &gw); err != nil {
+		return nil, err
+	}
+	cfg, err := websocket.NewConfig(strings.TrimRight(gw.URL, "/")+"/?v=10&encoding=json", "https://discord.com")
+	if err != nil {
+		return nil, fmt.Errorf("gateway URL: %w", err)
+	}
+	if err := egress.Check(cfg.Location.Host); err != nil {
+		return nil, err
+	}
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dialing gateway: %w", err)
+	}
+	return ws, nil
+}
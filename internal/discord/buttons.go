@@ -0,0 +1,95 @@
+package discord
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Buttons
+// ---------------------------------------------------------------------------
+
+// Questions with suggested answers and tool approvals are sent with buttons,
+// so they can be answered with a click instead of a typed reply. A button's
+// custom ID names the question or approval it answers, "ask:<id>:<index>"
+// or "approve:<id>:<decision>", so a click on an old message is not taken
+// as the answer to a newer one.
+
+const (
+	// maxAskButtons is how many answers fit: five rows of five buttons.
+	maxAskButtons = 25
+	// maxLabelLen is Discord's limit on button labels.
+	maxLabelLen = 80
+)
+
+// askButtons lays out one button per answer, five to a row.
+func askButtons(askID string, options []string) []actionRow {
+	var rows []actionRow
+	for i, o := range options {
+		if i%5 == 0 {
+			rows = append(rows, actionRow{Type: 1})
+		}
+		row := &rows[len(rows)-1]
+		row.Components = append(row.Components, button{
+			Type:     2,
+			Style:    2,
+			Label:    truncate(o, maxLabelLen),
+			CustomID: "ask:" + askID + ":" + strconv.Itoa(i),
+		})
+	}
+	return rows
+}
+
+// approvalButtons offers the three approval decisions.
+func approvalButtons(approvalID string) []actionRow {
+	return []actionRow{{Type: 1, Components: []button{
+		{Type: 2, Style: 3, Label: "Allow", CustomID: "approve:" + approvalID + ":allow"},
+		{Type: 2, Style: 1, Label: "Always", CustomID: "approve:" + approvalID + ":always"},
+		{Type: 2, Style: 4, Label: "Deny", CustomID: "approve:" + approvalID + ":deny"},
+	}}}
+}
+
+// handleButton answers the question or approval a click was for, and
+// replaces the buttons with the choice.
+func (b *Bot) handleButton(in interaction) {
+	kind, rest, _ := strings.Cut(in.Data.CustomID, ":")
+	id, choice, _ := strings.Cut(rest, ":")
+
+	reply := "You are not allowed to use this bot."
+	var p *pending
+	var sessionID string
+	b.mu.Lock()
+	ctx := b.ctx
+	if b.allowed(in.userID()) {
+		reply = "This was already answered."
+		if c := b.chats[in.ChannelID]; c != nil && c.pending != nil &&
+			(kind == "ask" && c.pending.askID == id || kind == "approve" && c.pending.approvalID == id) {
+			p, sessionID = c.pending, c.sessionID
+			c.pending = nil
+		}
+	}
+	b.mu.Unlock()
+	if p == nil {
+		if err := b.api.respond(ctx, in.ID, in.Token, reply); err != nil {
+			b.logf("discord: answering a click: %v", err)
+		}
+		return
+	}
+
+	answer := choice
+	if kind == "ask" {
+		i, err := strconv.Atoi(choice)
+		if err != nil || i < 0 || i >= len(p.options) {
+			return
+		}
+		answer = p.options[i]
+	}
+	content := "→ " + answer
+	if in.Message != nil {
+		content = truncate(in.Message.Content+"\n"+content, maxMessageLen)
+	}
+	if err := b.api.update(ctx, in.ID, in.Token, content); err != nil {
+		b.logf("discord: answering a click: %v", err)
+	}
+	b.answer(in.ChannelID, "", sessionID, p, answer)
+}
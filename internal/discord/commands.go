@@ -0,0 +1,236 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Slash commands
+// ---------------------------------------------------------------------------
+
+// commands mirror the TUI commands that make sense from a chat.
+var commands = []applicationCommand{
+	{Name: "new", Description: "Start a new session in this channel"},
+	{Name: "sessions", Description: "List recent sessions, or switch this channel to one", Options: []applicationOption{
+		{Type: 3, Name: "id", Description: "Session ID (or a unique prefix) to switch to"},
+	}},
+	{Name: "tools", Description: "List the tools the agent can use"},
+	{Name: "cancel", Description: "Stop the running turn"},
+	{Name: "cd", Description: "Start a new session working in another directory", Options: []applicationOption{
+		{Type: 3, Name: "path", Description: "Directory, absolute or relative to the current one", Required: true},
+	}},
+	{Name: "pwd", Description: "Show the directory this channel works in"},
+}
+
+// sessionListLimit caps /sessions output.
+const sessionListLimit = 10
+
+// handleInteraction runs a slash command and answers with a message only
+// the caller sees.
+func (b *Bot) handleInteraction(in interaction) {
+	reply := "You are not allowed to use this bot."
+	if b.allowed(in.userID()) {
+		arg := in.option("id")
+		if in.Data.Name == "cd" {
+			arg = in.option("path")
+		}
+		reply = b.runCommand(in.ChannelID, in.Data.Name, arg)
+	}
+	b.mu.Lock()
+	ctx := b.ctx
+	b.mu.Unlock()
+	if err := b.api.respond(ctx, in.ID, in.Token, truncate(reply, maxMessageLen)); err != nil {
+		b.logf("discord: answering /%s: %v", in.Data.Name, err)
+	}
+}
+
+// runCommand runs one slash command for channel and returns the reply.
+func (b *Bot) runCommand(channel, name, arg string) string {
+	b.mu.Lock()
+	c := b.chats[channel]
+	if c == nil {
+		c = &chat{}
+		b.chats[channel] = c
+	}
+	busy, sessionID, cwd := c.busy, c.sessionID, c.cwd
+	b.mu.Unlock()
+
+	switch name {
+	case "new":
+		if busy {
+			return "A turn is running; /cancel it first."
+		}
+		b.mu.Lock()
+		c.sessionID = ""
+		b.mu.Unlock()
+		return "Started a new session for this channel."
+	case "cancel":
+		if !busy {
+			return "Nothing is running."
+		}
+		if err := b.backend.Cancel(sessionID); err != nil {
+			return "Could not cancel: " + err.Error()
+		}
+		return "Cancelled."
+	case "sessions":
+		return b.sessions(c, busy, sessionID, arg)
+	case "cd":
+		if busy {
+			return "A turn is running; /cancel it first."
+		}
+		dir, err := b.resolveDir(cwd, arg)
+		if err != nil {
+			return "Could not change directory: " + err.Error()
+		}
+		b.mu.Lock()
+		c.cwd, c.sessionID = dir, ""
+		b.mu.Unlock()
+		return fmt.Sprintf("Started a new session in `%s`.", dir)
+	case "pwd":
+		if cwd == "" {
+			return fmt.Sprintf("`%s` (the daemon's directory)", b.cfg.ProjectPath)
+		}
+		return fmt.Sprintf("`%s`", cwd)
+	case "tools":
+		tools, err := b.backend.GetTools()
+		if err != nil {
+			return "Could not list tools: " + err.Error()
+		}
+		var sb strings.Builder
+		for _, t := range tools {
+			if t.Enabled {
+				fmt.Fprintf(&sb, "`%s` %s\n", t.Name, firstLine(t.Description))
+			}
+		}
+		if sb.Len() == 0 {
+			return "No tools are enabled."
+		}
+		return sb.String()
+	}
+	return fmt.Sprintf("Unknown command /%s.", name)
+}
+
+// sessions lists the project's recent sessions, or switches the channel to
+// the one whose ID starts with arg.
+func (b *Bot) sessions(c *chat, busy bool, current, arg string) string {
+	sessions, err := b.backend.ListSessions(b.cfg.ProjectPath, sessionListLimit)
+	if err != nil {
+		return "Could not list sessions: " + err.Error()
+	}
+	if arg != "" {
+		if busy {
+			return "A turn is running; /cancel it first."
+		}
+		var match *domain.Session
+		for i, s := range sessions {
+			if strings.HasPrefix(s.ID, arg) {
+				if match != nil {
+					return fmt.Sprintf("%q matches more than one session.", arg)
+				}
+				match = &sessions[i]
+			}
+		}
+		if match == nil {
+			return fmt.Sprintf("No recent session starts with %q.", arg)
+		}
+		b.mu.Lock()
+		c.sessionID, c.cwd = match.ID, match.Cwd
+		b.mu.Unlock()
+		return fmt.Sprintf("This channel now continues session `%s`.", shortID(match.ID))
+	}
+	if len(sessions) == 0 {
+		return "No sessions yet."
+	}
+	var sb strings.Builder
+	for _, s := range sessions {
+		marker := "  "
+		if s.ID == current {
+			marker = "▸ "
+		}
+		title := s.Title
+		if title == "" {
+			title = "(untitled)"
+		}
+		fmt.Fprintf(&sb, "%s`%s` %s · %s\n", marker, shortID(s.ID), title, s.UpdatedAt.Local().Format(time.DateTime))
+	}
+	sb.WriteString("Switch with `/sessions id:<prefix>`.")
+	return sb.String()
+}
+
+// resolveDir turns a /cd argument into an absolute directory, relative to
+// the channel's current one, and checks it lies under an allowed path.
+// Symlinks are resolved first, so a link cannot lead out of the allowed
+// paths.
+func (b *Bot) resolveDir(cwd, arg string) (string, error) {
+	if len(b.cfg.AllowedPaths) == 0 {
+		return "", errors.New("/cd is off; set discord.allowed_paths to the directories chats may use")
+	}
+	arg = strings.TrimSpace(arg)
+	if arg == "" {
+		return "", errors.New("no directory given")
+	}
+	if rest, ok := strings.CutPrefix(arg, "~"); ok && (rest == "" || rest[0] == '/' || rest[0] == filepath.Separator) {
+		if home, err := os.UserHomeDir(); err == nil {
+			arg = home + rest
+		}
+	}
+	if !filepath.IsAbs(arg) {
+		if cwd == "" {
+			cwd = b.cfg.ProjectPath
+		}
+		arg = filepath.Join(cwd, arg)
+	}
+	dir, err := filepath.EvalSymlinks(arg)
+	if err != nil {
+		return "", fmt.Errorf("no such directory: %s", arg)
+	}
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return "", fmt.Errorf("not a directory: %s", arg)
+	}
+	for _, root := range b.cfg.AllowedPaths {
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			root = real
+		}
+		if within(root, dir) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%s is outside discord.allowed_paths", dir)
+}
+
+// within reports whether dir is root or lies under it.
+func within(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
+
+// truncate cuts s to at most n bytes on a line boundary when it can.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	s = s[:n-1]
+	if i := strings.LastIndexByte(s, '\n'); i > 0 {
+		return s[:i] + "\n…"
+	}
+	return strings.ToValidUTF8(s, "") + "…"
+}
@@ -0,0 +1,535 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/gateway"
+)
+
+// Backend is the daemon API the bot drives. It is implemented by
+// *daemon.DaemonClient.
+type Backend interface {
+	CreateSessionIn(projectPath, modelID, cwd string) (string, error)
+	ListSessions(projectPath string, limit int) ([]domain.Session, error)
+	GetTools() ([]daemon.ToolInfo, error)
+	Submit(sessionID, text string, attachments []daemon.SubmitAttachment, onEvent func(daemon.SSEEvent)) error
+	Cancel(sessionID string) error
+	SendAskResponse(sessionID, askID, answer string) error
+	SendApproval(sessionID, approvalID, decision string) error
+}
+
+// Config holds the bot token and who may use the bot.
+type Config struct {
+	Token string // bot token from the Discord developer portal
+	// AllowedUsers are the Discord user IDs the bot takes messages and
+	// commands from. Everyone else is ignored.
+	AllowedUsers []string
+	// Channels are guild channel IDs where the bot answers every message.
+	// In other guild channels it answers only when mentioned; direct
+	// messages are always answered.
+	Channels    []string
+	ProjectPath string // project new sessions are created in
+	// AllowedPaths are the directories a channel may switch into with /cd,
+	// along with everything under them. Empty turns /cd off.
+	AllowedPaths []string
+	// FilesDir is where files sent to the bot are saved, in a directory
+	// per session. It defaults to a muxd-discord directory under the
+	// system temp directory.
+	FilesDir   string
+	APIHost    string // DefaultAPIHost or a test server
+	HTTPClient *http.Client
+	Logf       func(format string, args ...any)
+}
+
+// Bot answers Discord messages with agent turns. Each channel or DM gets
+// its own session, and replies stream into a message that is edited as
+// text arrives.
+type Bot struct {
+	cfg     Config
+	backend Backend
+	api     *api
+	userID  string // the bot's own user, set by Run
+
+	ctx   context.Context
+	mu    sync.Mutex
+	chats map[string]*chat // channel ID -> conversation
+	wg    sync.WaitGroup
+}
+
+// chat is one channel's conversation with the agent.
+type chat struct {
+	sessionID string
+	cwd       string // directory set with /cd; empty is the daemon's
+	busy      bool   // a turn is running
+	// pending is the question or approval the running turn is waiting on,
+	// answered by the next message in the channel.
+	pending *pending
+}
+
+type pending struct {
+	askID      string
+	approvalID string
+	options    []string // answers offered as buttons
+}
+
+// New returns a bot that drives backend. A token and at least one allowed
+// user are required.
+func New(backend Backend, cfg Config) (*Bot, error) {
+	if cfg.Token == "" {
+		return nil, errors.New("discord needs discord.token (a bot token)")
+	}
+	if len(cfg.AllowedUsers) == 0 {
+		return nil, errors.New("discord needs discord.allowed_users (the user IDs that may use the bot)")
+	}
+	if cfg.APIHost == "" {
+		cfg.APIHost = DefaultAPIHost
+	}
+	if cfg.FilesDir == "" {
+		cfg.FilesDir = filepath.Join(os.TempDir(), "muxd-discord")
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout, Transport: egress.Transport(nil)}
+	}
+	return &Bot{
+		cfg:     cfg,
+		backend: backend,
+		api:     &api{host: cfg.APIHost, token: cfg.Token, client: client},
+		ctx:     context.Background(),
+		chats:   map[string]*chat{},
+	}, nil
+}
+
+// Reconnect backoff for the gateway.
+const (
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+)
+
+// Run connects to the gateway and handles messages until ctx is
+// cancelled, reconnecting whenever Discord drops the connection. It fails
+// only when the token is rejected.
+func (b *Bot) Run(ctx context.Context) error {
+	userID, err := b.api.me(ctx)
+	if err != nil {
+		return fmt.Errorf("discord token: %w", err)
+	}
+	b.mu.Lock()
+	b.userID, b.ctx = userID, ctx
+	b.mu.Unlock()
+	defer b.wg.Wait()
+
+	wait := minReconnect
+	for {
+		connected := time.Now()
+		err := b.serve(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if time.Since(connected) > maxReconnect {
+			wait = minReconnect
+		}
+		if err != nil {
+			b.logf("discord: gateway: %v (reconnecting in %s)", err, wait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxReconnect)
+	}
+}
+
+// serve handles one gateway connection: identify, heartbeat, and dispatch
+// events until Discord closes it or asks the bot to reconnect.
+func (b *Bot) serve(ctx context.Context) error {
+	ws, err := b.api.openGateway(ctx)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+	defer ws.Close()
+
+	var hello frame
+	if err := websocket.JSON.Receive(ws, &hello); err != nil {
+		return err
+	}
+	var hb struct {
+		Interval int `json:"heartbeat_interval"`
+	}
+	if hello.Op != opHello || json.Unmarshal(hello.D, &hb) != nil || hb.Interval <= 0 {
+		return fmt.Errorf("expected hello, got op %d", hello.Op)
+	}
+	identify := map[string]any{
+		"op": opIdentify,
+		"d": map[string]any{
+			"token":      b.cfg.Token,
+			"intents":    intents,
+			"properties": map[string]string{"os": runtime.GOOS, "browser": "muxd", "device": "muxd"},
+		},
+	}
+	if err := websocket.JSON.Send(ws, identify); err != nil {
+		return err
+	}
+
+	var seq atomic.Int64
+	seq.Store(-1)
+	heartbeat := func() error {
+		var d any
+		if s := seq.Load(); s >= 0 {
+			d = s
+		}
+		return websocket.JSON.Send(ws, map[string]any{"op": opHeartbeat, "d": d})
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Duration(hb.Interval) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if heartbeat() != nil {
+					ws.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var f frame
+		if err := websocket.JSON.Receive(ws, &f); err != nil {
+			return err
+		}
+		if f.S != nil {
+			seq.Store(*f.S)
+		}
+		switch f.Op {
+		case opHeartbeat:
+			if err := heartbeat(); err != nil {
+				return err
+			}
+		case opReconnect, opInvalidSession:
+			return nil
+		case opHeartbeatAck:
+		case opDispatch:
+			b.dispatch(f)
+		}
+	}
+}
+
+// dispatch handles the gateway events the bot subscribes to.
+func (b *Bot) dispatch(f frame) {
+	switch f.T {
+	case "READY":
+		var ready struct {
+			User struct {
+				ID string `json:"id"`
+			} `json:"user"`
+			Application struct {
+				ID string `json:"id"`
+			} `json:"application"`
+		}
+		if json.Unmarshal(f.D, &ready) != nil {
+			return
+		}
+		b.mu.Lock()
+		b.userID = ready.User.ID
+		ctx := b.ctx
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			if err := b.api.registerCommands(ctx, ready.Application.ID, commands); err != nil {
+				b.logf("discord: registering commands: %v", err)
+			}
+		}()
+	case "MESSAGE_CREATE":
+		var m message
+		if json.Unmarshal(f.D, &m) == nil {
+			b.handleMessage(m)
+		}
+	case "INTERACTION_CREATE":
+		var in interaction
+		if json.Unmarshal(f.D, &in) != nil {
+			return
+		}
+		// Commands and clicks call the daemon; keep the gateway loop reading.
+		switch in.Type {
+		case 2:
+			b.wg.Add(1)
+			go func() {
+				defer b.wg.Done()
+				b.handleInteraction(in)
+			}()
+		case 3:
+			b.wg.Add(1)
+			go func() {
+				defer b.wg.Done()
+				b.handleButton(in)
+			}()
+		}
+	}
+}
+
+func (b *Bot) allowed(userID string) bool {
+	return slices.Contains(b.cfg.AllowedUsers, userID)
+}
+
+// ---------------------------------------------------------------------------
+// Messages
+// ---------------------------------------------------------------------------
+
+var mentionPattern = regexp.MustCompile(`<@!?[0-9]+>`)
+
+// handleMessage routes one message: an answer to a pending question, or a
+// prompt for a new turn.
+func (b *Bot) handleMessage(m message) {
+	b.mu.Lock()
+	self := b.userID
+	b.mu.Unlock()
+	if m.Author.Bot || m.Author.ID == self || !b.allowed(m.Author.ID) {
+		return
+	}
+	if m.GuildID != "" && !slices.Contains(b.cfg.Channels, m.ChannelID) && !mentions(m, self) {
+		return
+	}
+	text := strings.TrimSpace(mentionPattern.ReplaceAllString(m.Content, ""))
+	if text == "" && len(m.Attachments) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	c := b.chats[m.ChannelID]
+	if c == nil {
+		c = &chat{}
+		b.chats[m.ChannelID] = c
+	}
+	if p := c.pending; p != nil && text != "" {
+		c.pending = nil
+		sessionID := c.sessionID
+		b.mu.Unlock()
+		b.answer(m.ChannelID, m.ID, sessionID, p, text)
+		return
+	}
+	if c.busy {
+		b.mu.Unlock()
+		b.send(m.ChannelID, m.ID, "Still working on the previous message. Use /cancel to stop it.")
+		return
+	}
+	c.busy = true
+	sessionID, cwd := c.sessionID, c.cwd
+	b.mu.Unlock()
+
+	if sessionID == "" {
+		id, err := b.backend.CreateSessionIn(b.cfg.ProjectPath, "", cwd)
+		if err != nil {
+			b.finish(c)
+			b.send(m.ChannelID, m.ID, "Could not start a session: "+err.Error())
+			return
+		}
+		b.mu.Lock()
+		c.sessionID, sessionID = id, id
+		b.mu.Unlock()
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer b.finish(c)
+		prompt, images := b.withFiles(m.ChannelID, m.ID, sessionID, text, m.Attachments)
+		if prompt == "" {
+			return
+		}
+		b.runTurn(m.ChannelID, m.ID, sessionID, c, prompt, images)
+	}()
+}
+
+func mentions(m message, userID string) bool {
+	for _, u := range m.Mentions {
+		if u.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// answer resolves the question or approval the turn is waiting on.
+func (b *Bot) answer(channel, replyTo, sessionID string, p *pending, text string) {
+	var err error
+	if p.approvalID != "" {
+		decision, perr := agent.ParseApprovalDecision(text)
+		if perr != nil {
+			b.mu.Lock()
+			if c := b.chats[channel]; c != nil && c.busy && c.pending == nil {
+				c.pending = p
+			}
+			b.mu.Unlock()
+			b.send(channel, replyTo, "Reply allow, deny, or always.")
+			return
+		}
+		err = b.backend.SendApproval(sessionID, p.approvalID, string(decision))
+	} else {
+		err = b.backend.SendAskResponse(sessionID, p.askID, text)
+	}
+	if err != nil {
+		b.send(channel, replyTo, "Could not send your answer: "+err.Error())
+	}
+}
+
+func (b *Bot) finish(c *chat) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c.busy, c.pending = false, nil
+}
+
+// runTurn submits text and streams the reply into an edited message.
+func (b *Bot) runTurn(channel, replyTo, sessionID string, c *chat, text string, images []daemon.SubmitAttachment) {
+	s := &stream{bot: b, channel: channel, replyTo: replyTo}
+	var turnErr string
+	err := b.backend.Submit(sessionID, text, images, func(evt daemon.SSEEvent) {
+		switch evt.Type {
+		case "delta":
+			s.write(evt.DeltaText)
+		case "ask_user":
+			s.end()
+			options := evt.AskOptions[:min(len(evt.AskOptions), maxAskButtons)]
+			b.mu.Lock()
+			c.pending = &pending{askID: evt.AskID, options: options}
+			b.mu.Unlock()
+			if len(options) == 0 {
+				b.send(channel, replyTo, evt.AskPrompt+"\n*Reply with your answer.*")
+			} else {
+				b.send(channel, replyTo, evt.AskPrompt+"\n*Pick an answer, or reply with your own.*", askButtons(evt.AskID, options)...)
+			}
+		case "approval_required":
+			s.end()
+			b.mu.Lock()
+			c.pending = &pending{approvalID: evt.ApprovalID}
+			b.mu.Unlock()
+			b.send(channel, replyTo, fmt.Sprintf("Run `%s`?", evt.ToolName), approvalButtons(evt.ApprovalID)...)
+		case "error":
+			turnErr = evt.ErrorMsg
+		}
+	})
+	s.end()
+	switch {
+	case err != nil:
+		b.send(channel, replyTo, "Error: "+err.Error())
+	case turnErr != "":
+		b.send(channel, replyTo, "Error: "+turnErr)
+	}
+}
+
+// Discord caps messages at 2,000 characters.
+const maxMessageLen = 2000
+
+// editInterval throttles streaming edits to stay inside Discord's rate
+// limit of five message edits per five seconds in a channel.
+var editInterval = 1200 * time.Millisecond
+
+// stream shows streamed text in a message that is edited as it grows and
+// continued in a new message once it is full.
+type stream struct {
+	bot      *Bot
+	channel  string
+	replyTo  string
+	msgID    string // message showing text; "" until first sent
+	text     string
+	shown    string // text as last sent
+	lastEdit time.Time
+}
+
+func (s *stream) write(delta string) {
+	s.text += delta
+	if len(s.text) > maxMessageLen {
+		parts := gateway.SplitMessage(s.text, maxMessageLen)
+		for _, part := range parts[:len(parts)-1] {
+			s.text = part
+			s.flush()
+			s.msgID, s.shown = "", ""
+		}
+		s.text = parts[len(parts)-1]
+	}
+	if time.Since(s.lastEdit) >= editInterval {
+		s.flush()
+	}
+}
+
+// flush sends or edits the message to show the text so far.
+func (s *stream) flush() {
+	text := strings.TrimSpace(s.text)
+	if text == "" || text == s.shown {
+		return
+	}
+	b := s.bot
+	b.mu.Lock()
+	ctx := b.ctx
+	b.mu.Unlock()
+	var err error
+	if s.msgID == "" {
+		s.msgID, err = b.api.send(ctx, s.channel, s.replyTo, text)
+	} else {
+		err = b.api.edit(ctx, s.channel, s.msgID, text)
+	}
+	if err != nil {
+		b.logf("discord: streaming to %s: %v", s.channel, err)
+	}
+	s.shown, s.lastEdit = text, time.Now()
+}
+
+// end shows the rest of the text; anything streamed after starts a new
+// message.
+func (s *stream) end() {
+	s.flush()
+	s.msgID, s.text, s.shown = "", "", ""
+}
+
+// send posts text as a reply, split to fit Discord's message limit, with
+// any buttons under the last part.
+func (b *Bot) send(channel, replyTo, text string, rows ...actionRow) {
+	b.mu.Lock()
+	ctx := b.ctx
+	b.mu.Unlock()
+	parts := gateway.SplitMessage(text, maxMessageLen)
+	for i, part := range parts {
+		var partRows []actionRow
+		if i == len(parts)-1 {
+			partRows = rows
+		}
+		if _, err := b.api.send(ctx, channel, replyTo, part, partRows...); err != nil {
+			b.logf("discord: posting to %s: %v", channel, err)
+			return
+		}
+	}
+}
+
+func (b *Bot) logf(format string, args ...any) {
+	if b.cfg.Logf != nil {
+		b.cfg.Logf(format, args...)
+	}
+}
@@ -0,0 +1,565 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// fakeBackend replays scripted events for each turn. A question or
+// approval blocks the turn until it is answered.
+type fakeBackend struct {
+	mu       sync.Mutex
+	sessions int
+	cwds     []string
+	prompts  []string
+	images   []string
+	events   []daemon.SSEEvent
+	answers  chan string
+}
+
+func newFakeBackend(events ...daemon.SSEEvent) *fakeBackend {
+	return &fakeBackend{events: events, answers: make(chan string, 1)}
+}
+
+func (f *fakeBackend) CreateSessionIn(projectPath, modelID, cwd string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions++
+	f.cwds = append(f.cwds, cwd)
+	return "sess-" + string(rune('0'+f.sessions)), nil
+}
+
+func (f *fakeBackend) ListSessions(string, int) ([]domain.Session, error) {
+	return []domain.Session{
+		{ID: "abc12345678", Title: "Fix login"},
+		{ID: "abd98765432", Title: "Docs"},
+	}, nil
+}
+
+func (f *fakeBackend) GetTools() ([]daemon.ToolInfo, error) {
+	return []daemon.ToolInfo{
+		{Name: "file_read", Description: "Read a file.\nMore detail.", Enabled: true},
+		{Name: "bash", Description: "Run a command.", Enabled: false},
+	}, nil
+}
+
+func (f *fakeBackend) Submit(sessionID, text string, attachments []daemon.SubmitAttachment, onEvent func(daemon.SSEEvent)) error {
+	f.mu.Lock()
+	f.prompts = append(f.prompts, sessionID+": "+text)
+	for _, a := range attachments {
+		f.images = append(f.images, a.Name+" "+a.MediaType)
+	}
+	f.mu.Unlock()
+	for _, evt := range f.events {
+		onEvent(evt)
+		if evt.Type == "ask_user" || evt.Type == "approval_required" {
+			onEvent(daemon.SSEEvent{Type: "delta", DeltaText: "(got " + <-f.answers + ")"})
+		}
+	}
+	return nil
+}
+
+func (f *fakeBackend) Cancel(string) error { return nil }
+
+func (f *fakeBackend) SendAskResponse(_, askID, answer string) error {
+	f.answers <- askID + "=" + answer
+	return nil
+}
+
+func (f *fakeBackend) SendApproval(_, approvalID, decision string) error {
+	f.answers <- approvalID + "=" + decision
+	return nil
+}
+
+func (f *fakeBackend) promptLog() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.prompts...)
+}
+
+// fakeDiscord serves the REST routes the bot calls and records what it
+// sends, edits, and answers.
+type fakeDiscord struct {
+	*httptest.Server
+	mu       sync.Mutex
+	ops      []string
+	next     int
+	commands []applicationCommand
+}
+
+func newFakeDiscord(t *testing.T, gw websocket.Handler) *fakeDiscord {
+	f := &fakeDiscord{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/@me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bot test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]any{"message": "401: Unauthorized"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"id": "100"})
+	})
+	mux.HandleFunc("GET /api/gateway", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"url": "ws" + strings.TrimPrefix(f.URL, "http") + "/gw"})
+	})
+	mux.HandleFunc("POST /api/channels/{channel}/messages", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Content string `json:"content"`
+			Ref     *struct {
+				MessageID string `json:"message_id"`
+			} `json:"message_reference"`
+			Components []actionRow `json:"components"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		var labels []string
+		for _, row := range body.Components {
+			for _, b := range row.Components {
+				labels = append(labels, b.Label)
+			}
+		}
+		if labels != nil {
+			body.Content += " [" + strings.Join(labels, ",") + "]"
+		}
+		f.mu.Lock()
+		f.next++
+		id := fmt.Sprintf("m%d", f.next)
+		f.ops = append(f.ops, fmt.Sprintf("send %s>%s %s", r.PathValue("channel"), body.Ref.MessageID, body.Content))
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"id": id})
+	})
+	mux.HandleFunc("PATCH /api/channels/{channel}/messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.ops = append(f.ops, fmt.Sprintf("edit %s %s", r.PathValue("id"), body["content"]))
+		f.mu.Unlock()
+		w.Write([]byte("{}"))
+	})
+	mux.HandleFunc("GET /files/{name}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.PathValue("name") {
+		case "notes.txt":
+			w.Write([]byte("remember the milk"))
+		case "shot.png":
+			w.Write([]byte("\x89PNG\r\n\x1a\n0000"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	mux.HandleFunc("PUT /api/applications/APP/commands", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		json.NewDecoder(r.Body).Decode(&f.commands)
+		f.mu.Unlock()
+		w.Write([]byte("[]"))
+	})
+	mux.HandleFunc("POST /api/interactions/{id}/{token}/callback", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Type int `json:"type"`
+			Data struct {
+				Content string `json:"content"`
+				Flags   int    `json:"flags"`
+			} `json:"data"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		op := fmt.Sprintf("respond %s/%d %s", r.PathValue("id"), body.Data.Flags, body.Data.Content)
+		if body.Type == 7 {
+			op = fmt.Sprintf("update %s %s", r.PathValue("id"), body.Data.Content)
+		}
+		f.mu.Lock()
+		f.ops = append(f.ops, op)
+		f.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if gw != nil {
+		mux.Handle("/gw/", gw)
+	}
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeDiscord) log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.ops...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestBot(t *testing.T, backend Backend, api *fakeDiscord, channels ...string) *Bot {
+	t.Helper()
+	b, err := New(backend, Config{Token: "test-token", AllowedUsers: []string{"U1"}, Channels: channels, APIHost: api.URL + "/api", FilesDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.userID = "100"
+	return b
+}
+
+func msg(id, channel, guild, user, content string) message {
+	m := message{ID: id, ChannelID: channel, GuildID: guild, Content: content}
+	m.Author.ID = user
+	return m
+}
+
+func TestNew_requiresTokenAndUsers(t *testing.T) {
+	if _, err := New(newFakeBackend(), Config{AllowedUsers: []string{"U1"}}); err == nil {
+		t.Error("missing token accepted")
+	}
+	if _, err := New(newFakeBackend(), Config{Token: "t"}); err == nil {
+		t.Error("missing allowed users accepted")
+	}
+}
+
+func TestBot_streamsEdits(t *testing.T) {
+	old := editInterval
+	editInterval = 0
+	t.Cleanup(func() { editInterval = old })
+
+	api := newFakeDiscord(t, nil)
+	backend := newFakeBackend(daemon.SSEEvent{Type: "delta", DeltaText: "Hello"}, daemon.SSEEvent{Type: "delta", DeltaText: " there"})
+	b := newTestBot(t, backend, api, "C1")
+
+	b.handleMessage(msg("1", "C2", "G1", "U1", "other channel"))
+	b.handleMessage(msg("2", "C1", "G1", "U2", "not allowed"))
+	b.handleMessage(msg("3", "C1", "G1", "100", "own message"))
+	b.handleMessage(msg("4", "C1", "G1", "U1", "<@100> hi"))
+	b.wg.Wait()
+
+	if got := backend.promptLog(); len(got) != 1 || got[0] != "sess-1: hi" {
+		t.Fatalf("prompts = %q", got)
+	}
+	want := "send C1>4 Hello|edit m1 Hello there"
+	if got := strings.Join(api.log(), "|"); got != want {
+		t.Fatalf("ops:\n got %q\nwant %q", got, want)
+	}
+
+	// Elsewhere in a guild the bot answers only when mentioned; DMs always.
+	m := msg("5", "C2", "G1", "U1", "<@!100> there")
+	m.Mentions = append(m.Mentions, struct {
+		ID string `json:"id"`
+	}{"100"})
+	b.handleMessage(m)
+	b.wg.Wait()
+	b.handleMessage(msg("6", "D1", "", "U1", "dm"))
+	b.wg.Wait()
+	if got := backend.promptLog(); len(got) != 3 || got[1] != "sess-2: there" || got[2] != "sess-3: dm" {
+		t.Fatalf("prompts = %q", got)
+	}
+}
+
+func TestBot_askUser(t *testing.T) {
+	api := newFakeDiscord(t, nil)
+	backend := newFakeBackend(
+		daemon.SSEEvent{Type: "delta", DeltaText: "Checking."},
+		daemon.SSEEvent{Type: "ask_user", AskID: "ask-1", AskPrompt: "Which branch?"},
+		daemon.SSEEvent{Type: "approval_required", ApprovalID: "ap-1", ToolName: "bash"},
+	)
+	b := newTestBot(t, backend, api)
+
+	b.handleMessage(msg("1", "D1", "", "U1", "deploy"))
+	waitFor(t, "question", func() bool { return len(api.log()) == 2 })
+	b.handleMessage(msg("2", "D1", "", "U1", "main"))
+	waitFor(t, "approval", func() bool { return len(api.log()) == 4 })
+	b.handleMessage(msg("3", "D1", "", "U1", "maybe"))
+	waitFor(t, "re-prompt", func() bool { return len(api.log()) == 5 })
+	b.handleMessage(msg("4", "D1", "", "U1", "yes"))
+	b.wg.Wait()
+
+	want := []string{
+		"send D1>1 Checking.",
+		"send D1>1 Which branch?\n*Reply with your answer.*",
+		"send D1>1 (got ask-1=main)",
+		"send D1>1 Run `bash`? [Allow,Always,Deny]",
+		"send D1>3 Reply allow, deny, or always.",
+		"send D1>1 (got ap-1=allow)",
+	}
+	if got := api.log(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ops:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestBot_buttons(t *testing.T) {
+	api := newFakeDiscord(t, nil)
+	backend := newFakeBackend(
+		daemon.SSEEvent{Type: "ask_user", AskID: "ask-1", AskPrompt: "Where to?", AskOptions: []string{"staging", "prod"}},
+		daemon.SSEEvent{Type: "approval_required", ApprovalID: "ap-1", ToolName: "bash"},
+	)
+	b := newTestBot(t, backend, api)
+	click := func(id, user, customID, content string) {
+		in := interaction{ID: id, Token: "tok", Type: 3, ChannelID: "D1"}
+		in.User = &struct {
+			ID string `json:"id"`
+		}{user}
+		in.Data.CustomID = customID
+		in.Message = &struct {
+			Content string `json:"content"`
+		}{content}
+		b.handleButton(in)
+	}
+
+	b.handleMessage(msg("1", "D1", "", "U1", "deploy"))
+	waitFor(t, "question", func() bool { return len(api.log()) == 1 })
+	click("i1", "U2", "ask:ask-1:0", "Where to?")
+	click("i2", "U1", "ask:old:0", "Earlier question")
+	click("i3", "U1", "ask:ask-1:1", "Where to?")
+	waitFor(t, "approval", func() bool { return len(api.log()) == 6 })
+	click("i4", "U1", "approve:ap-1:always", "Run `bash`?")
+	b.wg.Wait()
+
+	want := []string{
+		"send D1>1 Where to?\n*Pick an answer, or reply with your own.* [staging,prod]",
+		"respond i1/64 You are not allowed to use this bot.",
+		"respond i2/64 This was already answered.",
+		"update i3 Where to?\n→ prod",
+		"send D1>1 (got ask-1=prod)",
+		"send D1>1 Run `bash`? [Allow,Always,Deny]",
+		"update i4 Run `bash`?\n→ always",
+		"send D1>1 (got ap-1=always)",
+	}
+	if got := api.log(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("ops:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestAskButtons_rows(t *testing.T) {
+	options := make([]string, 12)
+	for i := range options {
+		options[i] = fmt.Sprint("option ", i, strings.Repeat("x", 100))
+	}
+	rows := askButtons("a", options)
+	if len(rows) != 3 || len(rows[0].Components) != 5 || len(rows[2].Components) != 2 {
+		t.Fatalf("rows = %+v", rows)
+	}
+	if b := rows[2].Components[1]; b.CustomID != "ask:a:11" || utf8.RuneCountInString(b.Label) > maxLabelLen {
+		t.Errorf("button = %+v", b)
+	}
+}
+
+func TestBot_files(t *testing.T) {
+	api := newFakeDiscord(t, nil)
+	backend := newFakeBackend()
+	b := newTestBot(t, backend, api)
+
+	m := msg("1", "D1", "", "U1", "")
+	m.Attachments = []attachment{
+		{ID: "a1", Filename: "../notes.txt", ContentType: "text/plain; charset=utf-8", URL: api.URL + "/files/notes.txt"},
+		{ID: "a2", Filename: "shot.png", URL: api.URL + "/files/shot.png"},
+		{ID: "a3", Filename: "gone.pdf", URL: api.URL + "/files/gone.pdf"},
+		{ID: "a4", Filename: "huge.zip", Size: maxFileBytes + 1, URL: api.URL + "/files/huge.zip"},
+	}
+	b.handleMessage(m)
+	b.wg.Wait()
+
+	dir := filepath.Join(b.cfg.FilesDir, "sess-1")
+	notes, shot := filepath.Join(dir, "a1-notes.txt"), filepath.Join(dir, "a2-shot.png")
+	if data, err := os.ReadFile(notes); err != nil || string(data) != "remember the milk" {
+		t.Errorf("saved notes = %q, %v", data, err)
+	}
+	want := "sess-1: [The user attached files, saved at:\n- " + notes + " (text/plain, 17 B)\n- " + shot + " (image/png, 12 B)]"
+	if got := backend.promptLog(); len(got) != 1 || got[0] != want {
+		t.Errorf("prompts:\n got %q\nwant %q", got, want)
+	}
+	if got := backend.images; len(got) != 1 || got[0] != "shot.png image/png" {
+		t.Errorf("images = %q", got)
+	}
+	ops := strings.Join(api.log(), "|")
+	if !strings.Contains(ops, "Could not save gone.pdf: download: HTTP 404") || !strings.Contains(ops, "Could not save huge.zip: larger than 25 MB") {
+		t.Errorf("ops = %q", ops)
+	}
+}
+
+func TestBot_slashCommands(t *testing.T) {
+	api := newFakeDiscord(t, nil)
+	backend := newFakeBackend()
+	b := newTestBot(t, backend, api)
+	run := func(id, user, name, arg string) string {
+		in := interaction{ID: id, Token: "tok", Type: 2, ChannelID: "C1"}
+		in.User = &struct {
+			ID string `json:"id"`
+		}{user}
+		in.Data.Name = name
+		if arg != "" {
+			in.Data.Options = append(in.Data.Options, struct {
+				Name  string `json:"name"`
+				Value any    `json:"value"`
+			}{"id", arg})
+		}
+		b.handleInteraction(in)
+		ops := api.log()
+		return ops[len(ops)-1]
+	}
+
+	if got := run("i1", "U2", "tools", ""); got != "respond i1/64 You are not allowed to use this bot." {
+		t.Errorf("stranger: %q", got)
+	}
+	if got := run("i2", "U1", "tools", ""); got != "respond i2/64 `file_read` Read a file.\n" {
+		t.Errorf("/tools: %q", got)
+	}
+	if got := run("i3", "U1", "sessions", ""); !strings.Contains(got, "`abc12345` Fix login") {
+		t.Errorf("/sessions: %q", got)
+	}
+	if got := run("i4", "U1", "sessions", "ab"); !strings.Contains(got, "more than one") {
+		t.Errorf("ambiguous prefix: %q", got)
+	}
+	if got := run("i5", "U1", "sessions", "abd"); !strings.Contains(got, "`abd98765`") {
+		t.Errorf("switch: %q", got)
+	}
+	b.handleMessage(msg("1", "C1", "", "U1", "continue"))
+	b.wg.Wait()
+	if got := backend.promptLog(); len(got) != 1 || got[0] != "abd98765432: continue" {
+		t.Errorf("prompts after switch = %q", got)
+	}
+	if got := run("i6", "U1", "new", ""); !strings.Contains(got, "new session") {
+		t.Errorf("/new: %q", got)
+	}
+	b.handleMessage(msg("2", "C1", "", "U1", "fresh"))
+	b.wg.Wait()
+	if got := backend.promptLog(); got[1] != "sess-1: fresh" {
+		t.Errorf("prompts after /new = %q", got)
+	}
+}
+
+func TestBot_cd(t *testing.T) {
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := t.TempDir()
+	app := filepath.Join(root, "app")
+	if err := os.Mkdir(app, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	backend := newFakeBackend()
+	b := newTestBot(t, backend, newFakeDiscord(t, nil))
+	b.cfg.ProjectPath = root
+
+	if got := b.runCommand("C1", "cd", "app"); !strings.Contains(got, "/cd is off") {
+		t.Errorf("/cd without allowed paths: %q", got)
+	}
+	b.cfg.AllowedPaths = []string{root}
+	for _, arg := range []string{"..", "escape", "missing", outside} {
+		if got := b.runCommand("C1", "cd", arg); !strings.HasPrefix(got, "Could not change directory") {
+			t.Errorf("/cd %s: %q", arg, got)
+		}
+	}
+	if got := b.runCommand("C1", "pwd", ""); !strings.Contains(got, "daemon's directory") {
+		t.Errorf("/pwd before /cd: %q", got)
+	}
+	if got := b.runCommand("C1", "cd", "app"); got != "Started a new session in `"+app+"`." {
+		t.Errorf("/cd app: %q", got)
+	}
+	if got := b.runCommand("C1", "pwd", ""); got != "`"+app+"`" {
+		t.Errorf("/pwd: %q", got)
+	}
+	// A relative path is taken from the current directory.
+	if got := b.runCommand("C1", "cd", ".."); got != "Started a new session in `"+root+"`." {
+		t.Errorf("/cd ..: %q", got)
+	}
+	b.runCommand("C1", "cd", "app")
+
+	b.handleMessage(msg("1", "C1", "", "U1", "hello"))
+	b.wg.Wait()
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	if len(backend.cwds) != 1 || backend.cwds[0] != app {
+		t.Errorf("session created in %q, want %q", backend.cwds, app)
+	}
+}
+
+func TestBot_gateway(t *testing.T) {
+	identified := make(chan map[string]any, 1)
+	gw := websocket.Handler(func(ws *websocket.Conn) {
+		websocket.JSON.Send(ws, map[string]any{"op": opHello, "d": map[string]any{"heartbeat_interval": 45000}})
+		var id struct {
+			Op int            `json:"op"`
+			D  map[string]any `json:"d"`
+		}
+		if websocket.JSON.Receive(ws, &id) != nil || id.Op != opIdentify {
+			return
+		}
+		identified <- id.D
+		websocket.JSON.Send(ws, map[string]any{"op": opDispatch, "s": 1, "t": "READY", "d": map[string]any{
+			"user": map[string]any{"id": "100"}, "application": map[string]any{"id": "APP"},
+		}})
+		websocket.JSON.Send(ws, map[string]any{"op": opDispatch, "s": 2, "t": "MESSAGE_CREATE", "d": map[string]any{
+			"id": "9", "channel_id": "D1", "content": "ping", "author": map[string]any{"id": "U1"},
+		}})
+		// Hold the connection open until the bot closes it.
+		var rest map[string]any
+		websocket.JSON.Receive(ws, &rest)
+	})
+	api := newFakeDiscord(t, gw)
+	b, err := New(newFakeBackend(daemon.SSEEvent{Type: "delta", DeltaText: "pong"}), Config{
+		Token: "test-token", AllowedUsers: []string{"U1"}, APIHost: api.URL + "/api",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	select {
+	case d := <-identified:
+		if d["token"] != "test-token" || d["intents"] != float64(intents) {
+			t.Errorf("identify = %v", d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("bot did not identify")
+	}
+	waitFor(t, "reply", func() bool { return len(api.log()) == 1 })
+	if got := api.log()[0]; got != "send D1>9 pong" {
+		t.Errorf("reply = %q", got)
+	}
+	waitFor(t, "command registration", func() bool {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		return len(api.commands) == len(commands)
+	})
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestBot_runRejectsBadToken(t *testing.T) {
+	api := newFakeDiscord(t, nil)
+	b, _ := New(newFakeBackend(), Config{Token: "wrong", AllowedUsers: []string{"U1"}, APIHost: api.URL + "/api"})
+	if err := b.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Run = %v, want 401", err)
+	}
+}
@@ -0,0 +1,129 @@
+package discord
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/daemon"
+)
+
+// ---------------------------------------------------------------------------
+// Files
+// ---------------------------------------------------------------------------
+
+// maxFileBytes caps a downloaded attachment, matching the largest upload
+// Discord allows without Nitro boosts.
+const maxFileBytes = 25 << 20
+
+// attachment is a file sent with a message.
+type attachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	URL         string `json:"url"`
+}
+
+// withFiles downloads a message's files into the session's directory and
+// returns the prompt with a note listing where they were saved, plus the
+// images to send the model. Files that fail to download are reported in
+// the channel and left out.
+func (b *Bot) withFiles(channel, replyTo, sessionID, text string, files []attachment) (string, []daemon.SubmitAttachment) {
+	if len(files) == 0 {
+		return text, nil
+	}
+	dir := filepath.Join(b.cfg.FilesDir, sessionID)
+	var saved []string
+	var images []daemon.SubmitAttachment
+	for _, f := range files {
+		path, data, err := b.download(dir, f)
+		if err != nil {
+			b.send(channel, replyTo, fmt.Sprintf("Could not save %s: %v", f.Filename, err))
+			continue
+		}
+		mediaType := f.ContentType
+		if mediaType == "" {
+			mediaType = http.DetectContentType(data)
+		}
+		mediaType, _, _ = strings.Cut(mediaType, ";")
+		saved = append(saved, fmt.Sprintf("- %s (%s, %s)", path, mediaType, sizeLabel(len(data))))
+		if strings.HasPrefix(mediaType, "image/") && len(data) <= agent.MaxAttachmentImageBytes {
+			images = append(images, daemon.SubmitAttachment{
+				Name:      f.Filename,
+				MediaType: mediaType,
+				Data:      base64.StdEncoding.EncodeToString(data),
+			})
+		}
+	}
+	if len(saved) == 0 {
+		return text, images
+	}
+	note := "[The user attached files, saved at:\n" + strings.Join(saved, "\n") + "]"
+	if text == "" {
+		return note, images
+	}
+	return text + "\n\n" + note, images
+}
+
+// download saves f in dir under a name prefixed with its attachment ID, so
+// files with the same name do not overwrite each other.
+func (b *Bot) download(dir string, f attachment) (string, []byte, error) {
+	if f.Size > maxFileBytes {
+		return "", nil, fmt.Errorf("larger than %d MB", maxFileBytes>>20)
+	}
+	b.mu.Lock()
+	ctx := b.ctx
+	b.mu.Unlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.URL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := b.api.client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("download: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFileBytes+1))
+	if err != nil {
+		return "", nil, fmt.Errorf("download: %w", err)
+	}
+	if len(data) > maxFileBytes {
+		return "", nil, fmt.Errorf("larger than %d MB", maxFileBytes>>20)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", nil, err
+	}
+	path := filepath.Join(dir, f.ID+"-"+safeFileName(f.Filename))
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", nil, err
+	}
+	return path, data, nil
+}
+
+// safeFileName reduces a sender-chosen name to a plain file name.
+func safeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" || name == ".." || name == "" {
+		return "file"
+	}
+	return name
+}
+
+func sizeLabel(n int) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	if n < 1<<20 {
+		return fmt.Sprintf("%d KB", n>>10)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
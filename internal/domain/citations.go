@@ -0,0 +1,99 @@
+package domain
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Citation is a web source the agent read, numbered so replies can cite it
+// inline as [N]. Numbers are unique within a session.
+type Citation struct {
+	N     int    `json:"n"`
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// CitationHeader is the line that introduces a numbered source in a
+// web_search or web_fetch result: "[3] Title" followed by the URL on the
+// next line, or "[3] https://..." when there is no title.
+func CitationHeader(n int, title string) string {
+	return "[" + strconv.Itoa(n) + "] " + title
+}
+
+var (
+	citationLine = regexp.MustCompile(`^\[(\d+)\] (.*)$`)
+	citationRef  = regexp.MustCompile(`\[(\d+)\]`)
+)
+
+// ParseCitations returns the numbered sources in the web_search and
+// web_fetch results among blocks, in order of first appearance. Only the
+// first line of a web_fetch result is a header; the rest is page text.
+func ParseCitations(blocks []ContentBlock) []Citation {
+	var out []Citation
+	seen := map[int]bool{}
+	for _, b := range blocks {
+		if b.Type != "tool_result" || b.IsError {
+			continue
+		}
+		var lines []string
+		switch b.ToolName {
+		case "web_search":
+			lines = strings.Split(b.ToolResult, "\n")
+		case "web_fetch":
+			lines = strings.SplitN(b.ToolResult, "\n", 2)[:1]
+		default:
+			continue
+		}
+		for i, line := range lines {
+			m := citationLine.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			n, _ := strconv.Atoi(m[1])
+			c := Citation{N: n, URL: strings.TrimSpace(m[2])}
+			if !isURL(c.URL) {
+				if i+1 >= len(lines) || !isURL(strings.TrimSpace(lines[i+1])) {
+					continue
+				}
+				c.Title, c.URL = c.URL, strings.TrimSpace(lines[i+1])
+			}
+			if !seen[n] {
+				seen[n] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// MessageBlocks flattens the content blocks of msgs.
+func MessageBlocks(msgs []TranscriptMessage) []ContentBlock {
+	var out []ContentBlock
+	for _, m := range msgs {
+		out = append(out, m.Blocks...)
+	}
+	return out
+}
+
+// CitedSources returns the sources referenced as [N] in text, in order of
+// first reference. References to unknown numbers are ignored.
+func CitedSources(text string, sources []Citation) []Citation {
+	byN := make(map[int]Citation, len(sources))
+	for _, c := range sources {
+		byN[c.N] = c
+	}
+	var out []Citation
+	for _, m := range citationRef.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(m[1])
+		if c, ok := byN[n]; ok {
+			out = append(out, c)
+			delete(byN, n)
+		}
+	}
+	return out
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
@@ -15,23 +15,50 @@ var CommandDefs = []CommandDef{
 	{Name: "/sessions", Description: "list and switch sessions", Group: "session"},
 	{Name: "/continue", Description: "resume a session by ID", Group: "session"},
 	{Name: "/branch", Description: "fork conversation at current point", Group: "session"},
+	{Name: "/fork", Description: "pick a past message and fork the conversation from it", Group: "session", TUIOnly: true},
+	{Name: "/nav", Description: "step through messages to copy, quote, fork, annotate, or delete them (Ctrl+O)", Group: "session", TUIOnly: true},
 	{Name: "/rename", Description: "rename current session", Group: "session"},
+	{Name: "/feedback", Description: "rate the last reply good/bad with an optional note", Group: "session"},
+	{Name: "/stats", Description: "show response quality stats for this project", Group: "session", TUIOnly: true},
+	{Name: "/usage", Description: "show token usage and estimated spend per day, model, and project", Group: "session", TUIOnly: true},
+	{Name: "/optimize", Description: "suggest config changes that cut model spend, or /optimize apply <n|all>", Group: "session", TUIOnly: true},
+	{Name: "/context", Description: "show what the next model call will send (system, summary, messages)", Group: "session", TUIOnly: true},
+	{Name: "/compact", Description: "summarize earlier messages now to free up context", Group: "session", TUIOnly: true},
+	{Name: "/attach", Description: "attach a file or image to your next message", Group: "session", TUIOnly: true},
+	{Name: "/export", Description: "save the transcript as Markdown, JSON, or a Claude Code/Codex session", Group: "session", TUIOnly: true},
+	{Name: "/share", Description: "get a read-only live view link for this session", Group: "session", TUIOnly: true},
+	{Name: "/unshare", Description: "revoke this session's live view links", Group: "session", TUIOnly: true},
 	// Editing
-	{Name: "/undo", Description: "undo last agent turn", Group: "editing", TUIOnly: true},
-	{Name: "/redo", Description: "redo last undone turn", Group: "editing", TUIOnly: true},
+	{Name: "/plan", Description: "plan mode: read-only tools until you approve a plan", Group: "editing"},
+	{Name: "/undo", Description: "restore files to the last checkpoint (--preview shows the diff first)", Group: "editing", TUIOnly: true},
+	{Name: "/redo", Description: "reverse the last undo", Group: "editing", TUIOnly: true},
 	{Name: "/sh", Description: "drop into muxd shell", Group: "editing", TUIOnly: true},
 	// Config & tools
 	{Name: "/config", Description: "show/set preferences", Group: "config"},
 	{Name: "/tools", Description: "picker + enable/disable/profile tools", Group: "config"},
+	{Name: "/mcp", Description: "list, add, remove, restart MCP servers and read their logs", Group: "config", TUIOnly: true},
+	{Name: "/ollama", Description: "check the Ollama server and list, pull, or remove local models", Group: "config", TUIOnly: true},
 	{Name: "/emoji", Description: "pick a footer emoji", Group: "config", TUIOnly: true},
 	{Name: "/nodes", Description: "list and select hub nodes", Group: "config", TUIOnly: true},
 	{Name: "/qr", Description: "show QR code for mobile app connection", Group: "config", TUIOnly: true},
-	{Name: "/schedule", Description: "manage generic scheduled tool jobs", Group: "config"},
+	{Name: "/notify", Description: "send a test desktop, webhook, or Telegram notification", Group: "config", TUIOnly: true},
+	{Name: "/steer", Description: "send a note to the running turn before its next step", Group: "session", TUIOnly: true},
+	{Name: "/slack", Description: "start, stop, or check the Slack adapter", Group: "config", TUIOnly: true},
+	{Name: "/discord", Description: "start, stop, or check the Discord adapter", Group: "config", TUIOnly: true},
+	{Name: "/egress", Description: "show outbound hosts contacted", Group: "config", TUIOnly: true},
 	{Name: "/remember", Description: "save a fact to project memory", Group: "config"},
+	{Name: "/init", Description: "write a starting .muxd.toml, .mcp.json, and project memory", Group: "config", TUIOnly: true},
+	{Name: "/style", Description: "set response tone and language for this session", Group: "config"},
+	{Name: "/verify", Description: "check each final answer with a second model before it is shown", Group: "config"},
+	{Name: "/set", Description: "set temperature, top_p, seed, max tokens, and stop sequences for this session", Group: "config", TUIOnly: true},
 	// General
 	{Name: "/help", Description: "show this help", Group: "general"},
 	{Name: "/clear", Description: "clear chat", Group: "general", TUIOnly: true},
 	{Name: "/refresh", Description: "reload current session messages", Group: "general", TUIOnly: true},
+	{Name: "/history", Description: "show the previous page of session messages", Group: "general", TUIOnly: true},
+	{Name: "/detach", Description: "quit and leave the running turn in the daemon", Group: "general", TUIOnly: true},
+	{Name: "/quote", Description: "quote the last reply (or message N) into the input", Group: "general", TUIOnly: true},
+	{Name: "/jobs", Description: "list queued agent jobs, or /jobs run <prompt> to queue one", Group: "general", TUIOnly: true},
 	{Name: "/exit", Description: "quit muxd", Group: "general", TUIOnly: true},
 }
 
@@ -49,4 +76,5 @@ var CommandGroups = []struct {
 	{"editing", "Editing"},
 	{"config", "Config"},
 	{"general", "General"},
+	{"plugins", "Plugins"},
 }
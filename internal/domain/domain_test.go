@@ -1,6 +1,9 @@
 package domain
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"regexp"
 	"strings"
 	"testing"
@@ -339,3 +342,63 @@ func TestNewUUID_version4Bits(t *testing.T) {
 		}
 	}
 }
+
+// ---------------------------------------------------------------------------
+// errors.go
+// ---------------------------------------------------------------------------
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ErrorCode
+	}{
+		{"nil", nil, ""},
+		{"plain", errors.New("boom"), ErrUnknown},
+		{"coded", WithErrorCode(ErrLoopLimit, errors.New("too many iterations")), ErrLoopLimit},
+		{"wrapped coded", fmt.Errorf("turn: %w", WithErrorCode(ErrAuthInvalid, errors.New("401"))), ErrAuthInvalid},
+		{"deadline", fmt.Errorf("sending request: %w", context.DeadlineExceeded), ErrNetwork},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ErrorCodeOf(tt.err); got != tt.want {
+				t.Errorf("ErrorCodeOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+	if WithErrorCode(ErrUnknown, nil) != nil {
+		t.Error("WithErrorCode(nil) should be nil")
+	}
+	inner := errors.New("inner")
+	if err := WithErrorCode(ErrToolFailed, inner); !errors.Is(err, inner) || err.Error() != "inner" {
+		t.Errorf("WithErrorCode should wrap transparently, got %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// citations.go
+// ---------------------------------------------------------------------------
+
+func TestParseCitations(t *testing.T) {
+	blocks := []ContentBlock{
+		{Type: "tool_result", ToolName: "web_search", ToolResult: "[1] Go\n   https://go.dev\n   Build fast.\n\n[2] Go Wiki\n   https://go.dev/wiki"},
+		{Type: "tool_result", ToolName: "web_fetch", ToolResult: "[3] https://go.dev/doc\n\n[9] not a header\nhttps://example.com"},
+		{Type: "tool_result", ToolName: "web_fetch", ToolResult: "[4] https://down.example", IsError: true},
+		{Type: "tool_result", ToolName: "bash", ToolResult: "[5] https://ignored.example"},
+		{Type: "tool_result", ToolName: "web_search", ToolResult: "[1] Go\n   https://go.dev"},
+	}
+	got := ParseCitations(blocks)
+	want := []Citation{
+		{N: 1, URL: "https://go.dev", Title: "Go"},
+		{N: 2, URL: "https://go.dev/wiki", Title: "Go Wiki"},
+		{N: 3, URL: "https://go.dev/doc"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("ParseCitations = %+v, want %+v", got, want)
+	}
+
+	cited := CitedSources("Go is fast [2]. See [7] and [2][1].", got)
+	if len(cited) != 2 || cited[0].N != 2 || cited[1].N != 1 {
+		t.Errorf("CitedSources = %+v, want [2] then [1]", cited)
+	}
+}
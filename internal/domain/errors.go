@@ -0,0 +1,69 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrorCode classifies a failed turn so clients can react to the cause
+// rather than parse the message. It is sent with SSE error events.
+type ErrorCode string
+
+const (
+	ErrRateLimited     ErrorCode = "rate_limited"     // provider rate limit (HTTP 429)
+	ErrOverloaded      ErrorCode = "overloaded"       // provider temporarily unavailable
+	ErrAuthInvalid     ErrorCode = "auth_invalid"     // missing, wrong, or unauthorized API key
+	ErrContextExceeded ErrorCode = "context_exceeded" // request larger than the model's context window
+	ErrModelNotFound   ErrorCode = "model_not_found"  // unknown model or deployment
+	ErrInvalidRequest  ErrorCode = "invalid_request"  // other request rejected by the provider
+	ErrProviderFailed  ErrorCode = "provider_error"   // provider-side failure (HTTP 5xx)
+	ErrNetwork         ErrorCode = "network"          // connection failure or timeout
+	ErrToolFailed      ErrorCode = "tool_failed"      // a tool call returned an error
+	ErrBudgetExceeded  ErrorCode = "budget_exceeded"  // spending limit reached
+	ErrLoopLimit       ErrorCode = "loop_limit"       // agent iteration limit reached
+	ErrUnknown         ErrorCode = "unknown"
+)
+
+// CodedError is implemented by errors that know their ErrorCode.
+type CodedError interface {
+	error
+	ErrorCode() ErrorCode
+}
+
+type codedError struct {
+	code ErrorCode
+	err  error
+}
+
+func (e *codedError) Error() string        { return e.err.Error() }
+func (e *codedError) Unwrap() error        { return e.err }
+func (e *codedError) ErrorCode() ErrorCode { return e.code }
+
+// WithErrorCode attaches code to err.
+func WithErrorCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &codedError{code: code, err: err}
+}
+
+// ErrorCodeOf returns the code of err: the code of the first CodedError in
+// its chain, ErrNetwork for network failures and timeouts, and ErrUnknown
+// otherwise. A nil error has no code.
+func ErrorCodeOf(err error) ErrorCode {
+	if err == nil {
+		return ""
+	}
+	var coded CodedError
+	if errors.As(err, &coded) {
+		if code := coded.ErrorCode(); code != "" {
+			return code
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrNetwork
+	}
+	return ErrUnknown
+}
@@ -20,6 +20,9 @@ type ContentBlock struct {
 	CallerType string `json:"caller_type,omitempty"`
 	// CallerToolID is the server_tool_use ID that spawned a PTC tool call.
 	CallerToolID string `json:"caller_tool_id,omitempty"`
+	// InputError is set when the provider could not parse the tool_use
+	// arguments as JSON. ToolInput is then empty. Not persisted.
+	InputError string `json:"-"`
 
 	// Image support
 	MediaType  string `json:"media_type,omitempty"`  // e.g. "image/png", "image/jpeg"
@@ -55,19 +58,29 @@ func (m TranscriptMessage) TextContent() string {
 
 // Session holds metadata about a conversation session.
 type Session struct {
-	ID              string    `json:"id"`
-	ProjectPath     string    `json:"project_path"`
-	Title           string    `json:"title"`
-	Model           string    `json:"model"`
-	TotalTokens     int       `json:"total_tokens"`
-	InputTokens     int       `json:"input_tokens"`
-	OutputTokens    int       `json:"output_tokens"`
-	MessageCount    int       `json:"message_count"`
-	ParentSessionID string    `json:"parent_session_id,omitempty"`
-	BranchPoint     int       `json:"branch_point,omitempty"`
-	Tags            string    `json:"tags,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID              string `json:"id"`
+	ProjectPath     string `json:"project_path"`
+	Title           string `json:"title"`
+	Model           string `json:"model"`
+	TotalTokens     int    `json:"total_tokens"`
+	InputTokens     int    `json:"input_tokens"`
+	OutputTokens    int    `json:"output_tokens"`
+	MessageCount    int    `json:"message_count"`
+	ParentSessionID string `json:"parent_session_id,omitempty"`
+	BranchPoint     int    `json:"branch_point,omitempty"`
+	Tags            string `json:"tags,omitempty"`
+	// InterruptedPrompt is the prompt of a turn cut off by the daemon
+	// stopping, kept until the next turn starts.
+	InterruptedPrompt string `json:"interrupted_prompt,omitempty"`
+	// Cwd is the directory the session's tools run in when it is not the
+	// daemon's own working directory.
+	Cwd       string    `json:"cwd,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Running is set by the daemon when listing sessions whose agent is
+	// mid-turn, e.g. after the TUI detached. It is not stored.
+	Running bool `json:"running,omitempty"`
 }
 
 // TagList returns the tags as a slice of strings.
@@ -0,0 +1,244 @@
+package egress
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mode controls how the policy treats outbound requests.
+type Mode string
+
+const (
+	// ModeOff disables recording and enforcement.
+	ModeOff Mode = "off"
+	// ModeLog records every host and logs the first contact with each.
+	ModeLog Mode = "log"
+	// ModeAlert is ModeLog plus an alert for hosts not on the allowlist.
+	ModeAlert Mode = "alert"
+	// ModeAllowlist blocks (and alerts on) hosts not on the allowlist.
+	ModeAllowlist Mode = "allowlist"
+)
+
+// ParseMode validates a mode string. Empty means ModeOff.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return ModeOff, nil
+	case ModeOff, ModeLog, ModeAlert, ModeAllowlist:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid egress mode %q (use off, log, alert, or allowlist)", s)
+	}
+}
+
+// HostStat summarizes outbound traffic to a single host.
+type HostStat struct {
+	Host      string    `json:"host"`
+	Requests  int       `json:"requests"`
+	Blocked   int       `json:"blocked"`
+	Allowed   bool      `json:"allowed"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// BlockedError is returned for requests rejected in allowlist mode.
+type BlockedError struct {
+	Host string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("egress to %s blocked by allowlist (see egress.allowlist)", e.Host)
+}
+
+// Policy records outbound hosts and enforces the allowlist.
+type Policy struct {
+	mu      sync.Mutex
+	mode    Mode
+	allow   []string
+	hosts   map[string]*HostStat
+	logFn   func(format string, args ...any)
+	alertFn func(host, reason string)
+}
+
+// NewPolicy creates a policy. Allowlist entries are hostnames; a leading
+// "*." matches any subdomain (e.g. "*.anthropic.com").
+func NewPolicy(mode Mode, allowlist []string) *Policy {
+	p := &Policy{mode: mode, hosts: make(map[string]*HostStat)}
+	for _, h := range allowlist {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			p.allow = append(p.allow, h)
+		}
+	}
+	return p
+}
+
+// SetLogFunc sets the function used to log first contact with each host.
+func (p *Policy) SetLogFunc(fn func(format string, args ...any)) {
+	p.mu.Lock()
+	p.logFn = fn
+	p.mu.Unlock()
+}
+
+// SetAlertFunc sets the function called for unexpected or blocked hosts.
+func (p *Policy) SetAlertFunc(fn func(host, reason string)) {
+	p.mu.Lock()
+	p.alertFn = fn
+	p.mu.Unlock()
+}
+
+// Mode returns the policy mode.
+func (p *Policy) Mode() Mode { return p.mode }
+
+// allowed reports whether host matches the allowlist. Loopback hosts are
+// always allowed so local servers (Ollama, the daemon itself) keep working.
+func (p *Policy) allowed(host string) bool {
+	if isLoopback(host) {
+		return true
+	}
+	for _, a := range p.allow {
+		if a == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(a, "*"); ok && strings.HasSuffix(host, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Check records a request to host and returns a *BlockedError if the
+// policy forbids it. host may include a port.
+func (p *Policy) Check(host string) error {
+	if p == nil || p.mode == ModeOff || p.mode == "" {
+		return nil
+	}
+	host = normalizeHost(host)
+
+	p.mu.Lock()
+	allowed := p.allowed(host)
+	blocked := p.mode == ModeAllowlist && !allowed
+	now := time.Now()
+	st, seen := p.hosts[host]
+	if !seen {
+		st = &HostStat{Host: host, Allowed: allowed, FirstSeen: now}
+		p.hosts[host] = st
+	}
+	st.Requests++
+	st.LastSeen = now
+	if blocked {
+		st.Blocked++
+	}
+	logFn, alertFn := p.logFn, p.alertFn
+	p.mu.Unlock()
+
+	if !seen && logFn != nil {
+		logFn("egress: first contact with %s (allowed=%t)", host, allowed)
+	}
+	if alertFn != nil {
+		switch {
+		case blocked:
+			alertFn(host, "blocked: not on allowlist")
+		case !seen && !allowed && p.mode == ModeAlert:
+			alertFn(host, "not on allowlist")
+		}
+	}
+	if blocked {
+		return &BlockedError{Host: host}
+	}
+	return nil
+}
+
+// Report returns per-host statistics sorted by host name.
+func (p *Policy) Report() []HostStat {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]HostStat, 0, len(p.hosts))
+	for _, st := range p.hosts {
+		out = append(out, *st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Host < out[j].Host })
+	return out
+}
+
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ---------------------------------------------------------------------------
+// Process-wide policy
+// ---------------------------------------------------------------------------
+
+var (
+	defaultMu     sync.RWMutex
+	defaultPolicy *Policy
+)
+
+// SetDefault installs the process-wide policy used by Transport and Check.
+// Pass nil to disable.
+func SetDefault(p *Policy) {
+	defaultMu.Lock()
+	defaultPolicy = p
+	defaultMu.Unlock()
+}
+
+// Default returns the process-wide policy, or nil if none is installed.
+func Default() *Policy {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultPolicy
+}
+
+// Check runs host through the process-wide policy.
+func Check(host string) error {
+	return Default().Check(host)
+}
+
+// Transport wraps base so every request is checked against the process-wide
+// policy at send time. A nil base uses http.DefaultTransport.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := Check(req.URL.Host); err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// CloseIdleConnections forwards to the wrapped transport so
+// http.Client.CloseIdleConnections keeps working.
+func (t *transport) CloseIdleConnections() {
+	if c, ok := t.base.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
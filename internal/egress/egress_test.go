@@ -0,0 +1,111 @@
+package egress
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPolicy_allowlist(t *testing.T) {
+	p := NewPolicy(ModeAllowlist, []string{"api.anthropic.com", "*.github.com"})
+	var alerts []string
+	p.SetAlertFunc(func(host, reason string) { alerts = append(alerts, host) })
+
+	tests := []struct {
+		host    string
+		blocked bool
+	}{
+		{"api.anthropic.com:443", false},
+		{"raw.github.com", false},
+		{"localhost:11434", false},
+		{"[::1]:4096", false},
+		{"evil.example.com", true},
+	}
+	for _, tt := range tests {
+		err := p.Check(tt.host)
+		var be *BlockedError
+		if got := errors.As(err, &be); got != tt.blocked {
+			t.Errorf("Check(%q) blocked = %v, want %v (err=%v)", tt.host, got, tt.blocked, err)
+		}
+	}
+	if len(alerts) != 1 || alerts[0] != "evil.example.com" {
+		t.Errorf("alerts = %v, want [evil.example.com]", alerts)
+	}
+}
+
+func TestPolicy_report(t *testing.T) {
+	p := NewPolicy(ModeAlert, []string{"api.openai.com"})
+	var logged, alerts int
+	p.SetLogFunc(func(string, ...any) { logged++ })
+	p.SetAlertFunc(func(string, string) { alerts++ })
+
+	for _, h := range []string{"api.openai.com", "api.openai.com:443", "example.com", "example.com"} {
+		if err := p.Check(h); err != nil {
+			t.Fatalf("Check(%q): unexpected error %v", h, err)
+		}
+	}
+	report := p.Report()
+	if len(report) != 2 {
+		t.Fatalf("report has %d hosts, want 2", len(report))
+	}
+	if report[0].Host != "api.openai.com" || report[0].Requests != 2 || !report[0].Allowed {
+		t.Errorf("unexpected entry: %+v", report[0])
+	}
+	if report[1].Host != "example.com" || report[1].Allowed {
+		t.Errorf("unexpected entry: %+v", report[1])
+	}
+	if logged != 2 {
+		t.Errorf("logged %d first contacts, want 2", logged)
+	}
+	if alerts != 1 {
+		t.Errorf("got %d alerts, want 1", alerts)
+	}
+}
+
+func TestPolicy_off(t *testing.T) {
+	p := NewPolicy(ModeOff, nil)
+	if err := p.Check("example.com"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if len(p.Report()) != 0 {
+		t.Error("off mode should not record hosts")
+	}
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	SetDefault(NewPolicy(ModeAllowlist, nil))
+	t.Cleanup(func() { SetDefault(nil) })
+
+	client := &http.Client{Transport: Transport(nil)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("loopback request should be allowed: %v", err)
+	}
+	resp.Body.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://blocked.invalid/", nil)
+	if _, err := client.Do(req); err == nil {
+		t.Error("expected blocked request to fail")
+	}
+	if report := Default().Report(); len(report) != 2 {
+		t.Errorf("report has %d hosts, want 2", len(report))
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	if m, err := ParseMode(""); err != nil || m != ModeOff {
+		t.Errorf("ParseMode(\"\") = %q, %v", m, err)
+	}
+	if m, err := ParseMode(" Allowlist "); err != nil || m != ModeAllowlist {
+		t.Errorf("ParseMode(Allowlist) = %q, %v", m, err)
+	}
+	if _, err := ParseMode("strict"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
@@ -0,0 +1,785 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Other agent CLIs' session files
+// ---------------------------------------------------------------------------
+
+// SessionFormat reads and writes the session files of another agent CLI,
+// so history can move between it and muxd.
+type SessionFormat struct {
+	Name string
+	// MemoryFile is the project instructions file the CLI reads (e.g.
+	// CLAUDE.md), imported into project memory along with its sessions.
+	MemoryFile string
+	Read       func(r io.Reader) (*ImportedSession, error)
+	Write      func(w io.Writer, t Transcript) error
+	// Discover lists the CLI's session files for a project directory.
+	Discover func(project string) ([]string, error)
+	// FileName returns the name the CLI would give s's session file.
+	FileName func(s domain.Session) string
+}
+
+// ImportedSession is a session read from another CLI's files.
+type ImportedSession struct {
+	SourceID  string
+	Title     string
+	Cwd       string
+	Model     string
+	CreatedAt time.Time
+	Messages  []domain.TranscriptMessage
+}
+
+var sessionFormats = map[string]SessionFormat{
+	"claude": {
+		Name:       "claude",
+		MemoryFile: "CLAUDE.md",
+		Read:       ReadClaudeSession,
+		Write:      WriteClaudeSession,
+		Discover:   discoverClaudeSessions,
+		FileName:   func(s domain.Session) string { return s.ID + ".jsonl" },
+	},
+	"codex": {
+		Name:       "codex",
+		MemoryFile: "AGENTS.md",
+		Read:       ReadCodexSession,
+		Write:      WriteCodexSession,
+		Discover:   discoverCodexSessions,
+		FileName: func(s domain.Session) string {
+			return "rollout-" + s.CreatedAt.UTC().Format("2006-01-02T15-04-05") + "-" + s.ID + ".jsonl"
+		},
+	},
+}
+
+// LookupSessionFormat returns the session format with the given name:
+// "claude" (Claude Code) or "codex" (Codex CLI).
+func LookupSessionFormat(name string) (SessionFormat, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "claude-code" {
+		name = "claude"
+	}
+	f, ok := sessionFormats[name]
+	if !ok {
+		return SessionFormat{}, fmt.Errorf("unknown session format %q (use claude or codex)", name)
+	}
+	return f, nil
+}
+
+// toolMapping is a foreign tool's muxd equivalent, with its input fields
+// renamed (foreign name → muxd name). Fields not listed are dropped.
+type toolMapping struct {
+	name   string
+	fields map[string]string
+}
+
+// claudeTools maps Claude Code's built-in tools to muxd's.
+var claudeTools = map[string]toolMapping{
+	"Bash":      {"bash", map[string]string{"command": "command"}},
+	"Read":      {"file_read", map[string]string{"file_path": "path", "offset": "offset", "limit": "limit"}},
+	"Write":     {"file_write", map[string]string{"file_path": "path", "content": "content"}},
+	"Edit":      {"file_edit", map[string]string{"file_path": "path", "old_string": "old_string", "new_string": "new_string", "replace_all": "replace_all"}},
+	"Glob":      {"glob", map[string]string{"pattern": "pattern", "path": "path"}},
+	"Grep":      {"grep", map[string]string{"pattern": "pattern", "path": "path", "glob": "include", "-C": "context_lines"}},
+	"LS":        {"list_files", map[string]string{"path": "path"}},
+	"WebFetch":  {"web_fetch", map[string]string{"url": "url"}},
+	"WebSearch": {"web_search", map[string]string{"query": "query"}},
+}
+
+// mapInput renames input's fields per m.fields.
+func (m toolMapping) mapInput(input map[string]any) map[string]any {
+	out := make(map[string]any, len(m.fields))
+	for from, to := range m.fields {
+		if v, ok := input[from]; ok {
+			out[to] = v
+		}
+	}
+	return out
+}
+
+// reverseTools inverts a mapping table, for writing muxd tools back out.
+func reverseTools(tools map[string]toolMapping) map[string]toolMapping {
+	out := make(map[string]toolMapping, len(tools))
+	for foreign, m := range tools {
+		fields := make(map[string]string, len(m.fields))
+		for from, to := range m.fields {
+			fields[to] = from
+		}
+		out[m.name] = toolMapping{foreign, fields}
+	}
+	return out
+}
+
+// foreignToolText stands in for a call to a tool muxd does not have, so the
+// model still sees what happened without being offered a tool it cannot
+// call.
+func foreignToolText(name string, input any) string {
+	data, _ := json.Marshal(input)
+	return fmt.Sprintf("[Called %s: %s]", name, data)
+}
+
+// sessionBuilder assembles messages, merging consecutive lines from the
+// same role (both CLIs write one line per content block or tool result).
+type sessionBuilder struct {
+	msgs []domain.TranscriptMessage
+	// foreign holds IDs of calls turned into text; their results follow.
+	foreign map[string]string
+}
+
+func (b *sessionBuilder) add(role string, blocks ...domain.ContentBlock) {
+	if len(blocks) == 0 {
+		return
+	}
+	if n := len(b.msgs); n > 0 && b.msgs[n-1].Role == role {
+		b.msgs[n-1].Blocks = append(b.msgs[n-1].Blocks, blocks...)
+		return
+	}
+	b.msgs = append(b.msgs, domain.TranscriptMessage{Role: role, Blocks: blocks})
+}
+
+// call adds a tool call, mapped through tools or kept as text.
+func (b *sessionBuilder) call(tools map[string]toolMapping, id, name string, input map[string]any) {
+	if m, ok := tools[name]; ok {
+		b.add("assistant", domain.ContentBlock{Type: "tool_use", ToolUseID: id, ToolName: m.name, ToolInput: m.mapInput(input)})
+		return
+	}
+	if b.foreign == nil {
+		b.foreign = map[string]string{}
+	}
+	b.foreign[id] = name
+	b.add("assistant", domain.ContentBlock{Type: "text", Text: foreignToolText(name, input)})
+}
+
+// result adds a tool result, as text when its call was kept as text.
+func (b *sessionBuilder) result(id, output string, isError bool) {
+	if name, ok := b.foreign[id]; ok {
+		b.add("user", domain.ContentBlock{Type: "text", Text: fmt.Sprintf("[%s returned: %s]", name, output)})
+		return
+	}
+	b.add("user", domain.ContentBlock{Type: "tool_result", ToolUseID: id, ToolResult: output, IsError: isError})
+}
+
+// messages returns the assembled messages, with text-only ones flattened
+// to plain content.
+func (b *sessionBuilder) messages() []domain.TranscriptMessage {
+	for i, m := range b.msgs {
+		var texts []string
+		for _, blk := range m.Blocks {
+			if blk.Type != "text" {
+				texts = nil
+				break
+			}
+			texts = append(texts, blk.Text)
+		}
+		if texts != nil {
+			b.msgs[i] = domain.TranscriptMessage{Role: m.Role, Content: strings.Join(texts, "\n\n")}
+		}
+	}
+	return b.msgs
+}
+
+// readLines calls fn with each non-blank line of a JSONL stream. Lines can
+// hold whole file contents, so they are not length-limited.
+func readLines(r io.Reader, fn func(line []byte) error) error {
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if ferr := fn(line); ferr != nil {
+				return fmt.Errorf("line %d: %w", n, ferr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func parseTime(s string) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, s)
+	return t
+}
+
+// textOf returns the text of a result that is either a string or a list of
+// content blocks.
+func textOf(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal(raw, &blocks)
+	var parts []string
+	for _, b := range blocks {
+		if b.Text != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// ---------------------------------------------------------------------------
+// Claude Code
+// ---------------------------------------------------------------------------
+
+// Claude Code keeps one JSONL file per session under
+// ~/.claude/projects/<project>/, one line per message, with "summary"
+// lines holding the session's title.
+
+type claudeLine struct {
+	Type        string         `json:"type"`
+	SessionID   string         `json:"sessionId"`
+	UUID        string         `json:"uuid,omitempty"`
+	ParentUUID  *string        `json:"parentUuid"`
+	Cwd         string         `json:"cwd,omitempty"`
+	Timestamp   string         `json:"timestamp,omitempty"`
+	IsSidechain bool           `json:"isSidechain"`
+	IsMeta      bool           `json:"isMeta,omitempty"`
+	UserType    string         `json:"userType,omitempty"`
+	Summary     string         `json:"summary,omitempty"`
+	LeafUUID    string         `json:"leafUuid,omitempty"`
+	Message     *claudeMessage `json:"message,omitempty"`
+}
+
+type claudeMessage struct {
+	Role    string          `json:"role"`
+	Model   string          `json:"model,omitempty"`
+	Content json.RawMessage `json:"content"`
+}
+
+type claudeBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     map[string]any  `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Source    *struct {
+		Type      string `json:"type"`
+		MediaType string `json:"media_type"`
+		Data      string `json:"data"`
+	} `json:"source,omitempty"`
+}
+
+// ReadClaudeSession reads a Claude Code session file. Subagent
+// (sidechain) and meta lines are skipped, as are the local slash command
+// echoes Claude Code records as user messages. Calls to tools muxd does
+// not have become text.
+func ReadClaudeSession(r io.Reader) (*ImportedSession, error) {
+	sess := &ImportedSession{}
+	var b sessionBuilder
+	err := readLines(r, func(data []byte) error {
+		var line claudeLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return err
+		}
+		if line.Type == "summary" {
+			if sess.Title == "" {
+				sess.Title = line.Summary
+			}
+			return nil
+		}
+		if (line.Type != "user" && line.Type != "assistant") || line.Message == nil || line.IsSidechain || line.IsMeta {
+			return nil
+		}
+		if sess.SourceID == "" {
+			sess.SourceID, sess.Cwd, sess.CreatedAt = line.SessionID, line.Cwd, parseTime(line.Timestamp)
+		}
+		if line.Message.Model != "" && !strings.HasPrefix(line.Message.Model, "<") {
+			sess.Model = line.Message.Model
+		}
+
+		var text string
+		if json.Unmarshal(line.Message.Content, &text) == nil {
+			if line.Type == "user" && isClaudeCommandEcho(text) {
+				return nil
+			}
+			b.add(line.Type, domain.ContentBlock{Type: "text", Text: text})
+			return nil
+		}
+		var blocks []claudeBlock
+		if err := json.Unmarshal(line.Message.Content, &blocks); err != nil {
+			return fmt.Errorf("message content: %w", err)
+		}
+		for _, blk := range blocks {
+			switch blk.Type {
+			case "text":
+				if line.Type == "user" && isClaudeCommandEcho(blk.Text) {
+					continue
+				}
+				b.add(line.Type, domain.ContentBlock{Type: "text", Text: blk.Text})
+			case "image":
+				if blk.Source != nil && blk.Source.Type == "base64" {
+					b.add(line.Type, domain.ContentBlock{Type: "image", MediaType: blk.Source.MediaType, Base64Data: blk.Source.Data})
+				}
+			case "tool_use":
+				b.call(claudeTools, blk.ID, blk.Name, blk.Input)
+			case "tool_result":
+				b.result(blk.ToolUseID, textOf(blk.Content), blk.IsError)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sess.Messages = b.messages()
+	return sess, nil
+}
+
+func isClaudeCommandEcho(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "<command-name>") || strings.HasPrefix(text, "<local-command-stdout>")
+}
+
+// WriteClaudeSession writes t as a Claude Code session file. Saved under
+// ~/.claude/projects/<project>/, it can be resumed with "claude --resume".
+func WriteClaudeSession(w io.Writer, t Transcript) error {
+	enc := json.NewEncoder(w)
+	tools := reverseTools(claudeTools)
+	s := t.Session
+	var parent *string
+	for _, m := range t.Messages {
+		var content any = m.Content
+		if len(m.Blocks) > 0 {
+			var blocks []claudeBlock
+			for _, blk := range m.Blocks {
+				switch blk.Type {
+				case "text":
+					blocks = append(blocks, claudeBlock{Type: "text", Text: blk.Text})
+				case "tool_use":
+					name, input := blk.ToolName, blk.ToolInput
+					if tm, ok := tools[name]; ok {
+						name, input = tm.name, tm.mapInput(input)
+					}
+					if input == nil {
+						input = map[string]any{}
+					}
+					blocks = append(blocks, claudeBlock{Type: "tool_use", ID: blk.ToolUseID, Name: name, Input: input})
+				case "tool_result":
+					out, _ := json.Marshal(blk.ToolResult)
+					blocks = append(blocks, claudeBlock{Type: "tool_result", ToolUseID: blk.ToolUseID, Content: out, IsError: blk.IsError})
+				}
+			}
+			if len(blocks) == 0 {
+				continue
+			}
+			content = blocks
+		}
+		raw, err := json.Marshal(content)
+		if err != nil {
+			return err
+		}
+		msg := &claudeMessage{Role: m.Role, Content: raw}
+		if m.Role == "assistant" {
+			msg.Model = s.Model
+		}
+		id := domain.NewUUID()
+		line := claudeLine{
+			Type:       m.Role,
+			SessionID:  s.ID,
+			UUID:       id,
+			ParentUUID: parent,
+			Cwd:        s.ProjectPath,
+			Timestamp:  m.CreatedAt.UTC().Format(time.RFC3339Nano),
+			UserType:   "external",
+			Message:    msg,
+		}
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+		parent = &id
+	}
+	if s.Title != "" && parent != nil {
+		return enc.Encode(map[string]string{"type": "summary", "summary": s.Title, "leafUuid": *parent})
+	}
+	return nil
+}
+
+// claudeHome returns Claude Code's config directory.
+func claudeHome() (string, error) {
+	if dir := os.Getenv("CLAUDE_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".claude"), nil
+}
+
+// ClaudeProjectDir returns the directory Claude Code keeps a project's
+// sessions in: its path with every character other than a letter or digit
+// replaced by "-".
+func ClaudeProjectDir(home, project string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '-'
+	}, project)
+	return filepath.Join(home, "projects", name)
+}
+
+func discoverClaudeSessions(project string) ([]string, error) {
+	home, err := claudeHome()
+	if err != nil {
+		return nil, err
+	}
+	files, err := filepath.Glob(filepath.Join(ClaudeProjectDir(home, project), "*.jsonl"))
+	sort.Strings(files)
+	return files, err
+}
+
+// ---------------------------------------------------------------------------
+// Codex
+// ---------------------------------------------------------------------------
+
+// Codex keeps one "rollout" JSONL file per session under
+// ~/.codex/sessions/YYYY/MM/DD/. Current versions wrap each line as
+// {"type": "session_meta"|"response_item"|..., "payload": {...}}; older ones
+// start with a bare metadata object followed by bare response items.
+
+type codexLine struct {
+	Timestamp string          `json:"timestamp,omitempty"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+}
+
+type codexMeta struct {
+	ID        string `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Cwd       string `json:"cwd,omitempty"`
+}
+
+type codexItem struct {
+	Type      string          `json:"type"`
+	Role      string          `json:"role,omitempty"`
+	Content   []codexPart     `json:"content,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments string          `json:"arguments,omitempty"`
+	Input     string          `json:"input,omitempty"`
+	CallID    string          `json:"call_id,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty"`
+	Model     string          `json:"model,omitempty"`
+}
+
+type codexPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// codexTools maps Codex's tools to muxd's. shell is handled separately:
+// its command is an argv list.
+var codexTools = map[string]toolMapping{
+	"apply_patch":   {"patch_apply", map[string]string{"input": "patch"}},
+	"shell_command": {"bash", map[string]string{"command": "command"}},
+}
+
+// ReadCodexSession reads a Codex rollout file. Developer messages and the
+// environment and instructions preambles Codex adds as user messages are
+// skipped; calls to tools muxd does not have become text.
+func ReadCodexSession(r io.Reader) (*ImportedSession, error) {
+	sess := &ImportedSession{}
+	var b sessionBuilder
+	first := true
+	err := readLines(r, func(data []byte) error {
+		var line codexLine
+		if err := json.Unmarshal(data, &line); err != nil {
+			return err
+		}
+		item := data
+		switch {
+		case line.Type == "session_meta" || first && line.Payload == nil && line.Type == "":
+			var meta codexMeta
+			if line.Payload != nil {
+				data = line.Payload
+			}
+			if err := json.Unmarshal(data, &meta); err != nil {
+				return err
+			}
+			sess.SourceID, sess.Cwd, sess.CreatedAt = meta.ID, meta.Cwd, parseTime(meta.Timestamp)
+			first = false
+			return nil
+		case line.Type == "turn_context":
+			var ctx codexItem
+			_ = json.Unmarshal(line.Payload, &ctx)
+			if ctx.Model != "" {
+				sess.Model = ctx.Model
+			}
+			return nil
+		case line.Type == "response_item":
+			item = line.Payload
+		case line.Payload != nil:
+			return nil // event_msg and other wrapped lines
+		}
+		first = false
+
+		var it codexItem
+		if err := json.Unmarshal(item, &it); err != nil {
+			return err
+		}
+		switch it.Type {
+		case "message":
+			if it.Role != "user" && it.Role != "assistant" {
+				return nil
+			}
+			for _, p := range it.Content {
+				switch p.Type {
+				case "input_text", "output_text", "text":
+					if it.Role == "user" && isCodexPreamble(p.Text) {
+						continue
+					}
+					b.add(it.Role, domain.ContentBlock{Type: "text", Text: p.Text})
+				case "input_image":
+					if mediaType, data, ok := parseDataURL(p.ImageURL); ok {
+						b.add(it.Role, domain.ContentBlock{Type: "image", MediaType: mediaType, Base64Data: data})
+					}
+				}
+			}
+		case "function_call", "custom_tool_call":
+			input := map[string]any{}
+			if it.Type == "custom_tool_call" {
+				input["input"] = it.Input
+			} else if err := json.Unmarshal([]byte(it.Arguments), &input); err != nil {
+				input = map[string]any{"arguments": it.Arguments}
+			}
+			if it.Name == "shell" || it.Name == "container.exec" {
+				b.add("assistant", domain.ContentBlock{Type: "tool_use", ToolUseID: it.CallID, ToolName: "bash", ToolInput: map[string]any{"command": shellCommand(input["command"])}})
+				return nil
+			}
+			b.call(codexTools, it.CallID, it.Name, input)
+		case "function_call_output", "custom_tool_call_output":
+			out, isError := codexOutput(it.Output)
+			b.result(it.CallID, out, isError)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sess.Messages = b.messages()
+	return sess, nil
+}
+
+func isCodexPreamble(text string) bool {
+	text = strings.TrimSpace(text)
+	return strings.HasPrefix(text, "<environment_context>") || strings.HasPrefix(text, "<user_instructions>") ||
+		strings.HasPrefix(text, "# AGENTS.md instructions")
+}
+
+// shellCommand turns a Codex shell argv into a command line: the script of
+// a ["bash", "-lc", script] call, otherwise the words joined by spaces.
+func shellCommand(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	list, _ := v.([]any)
+	argv := make([]string, 0, len(list))
+	for _, a := range list {
+		s, _ := a.(string)
+		argv = append(argv, s)
+	}
+	if len(argv) == 3 && (argv[1] == "-lc" || argv[1] == "-c") {
+		return argv[2]
+	}
+	return strings.Join(argv, " ")
+}
+
+// codexOutput unwraps a tool output, which Codex stores either as plain
+// text or as a JSON string {"output": ..., "metadata": {"exit_code": N}}.
+func codexOutput(raw json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) != nil {
+		var obj struct {
+			Content string `json:"content"`
+		}
+		_ = json.Unmarshal(raw, &obj)
+		return obj.Content, false
+	}
+	var wrapped struct {
+		Output   *string `json:"output"`
+		Metadata struct {
+			ExitCode int `json:"exit_code"`
+		} `json:"metadata"`
+	}
+	if json.Unmarshal([]byte(s), &wrapped) == nil && wrapped.Output != nil {
+		return *wrapped.Output, wrapped.Metadata.ExitCode != 0
+	}
+	return s, false
+}
+
+// parseDataURL splits a data:<type>;base64,<data> URL.
+func parseDataURL(u string) (mediaType, data string, ok bool) {
+	rest, found := strings.CutPrefix(u, "data:")
+	if !found {
+		return "", "", false
+	}
+	meta, data, found := strings.Cut(rest, ",")
+	mediaType, found2 := strings.CutSuffix(meta, ";base64")
+	return mediaType, data, found && found2
+}
+
+// WriteCodexSession writes t as a Codex rollout file. bash calls become
+// Codex shell calls and patch_apply becomes apply_patch; other tools keep
+// their muxd names.
+func WriteCodexSession(w io.Writer, t Transcript) error {
+	enc := json.NewEncoder(w)
+	s := t.Session
+	write := func(ts time.Time, typ string, payload any) error {
+		raw, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(codexLine{Timestamp: ts.UTC().Format(time.RFC3339Nano), Type: typ, Payload: raw})
+	}
+	if err := write(s.CreatedAt, "session_meta", codexMeta{ID: s.ID, Timestamp: s.CreatedAt.UTC().Format(time.RFC3339Nano), Cwd: s.ProjectPath}); err != nil {
+		return err
+	}
+	if err := write(s.CreatedAt, "turn_context", codexItem{Model: s.Model}); err != nil {
+		return err
+	}
+	textType := map[string]string{"user": "input_text", "assistant": "output_text"}
+	for _, m := range t.Messages {
+		blocks := m.Blocks
+		if len(blocks) == 0 {
+			blocks = []domain.ContentBlock{{Type: "text", Text: m.Content}}
+		}
+		var parts []codexPart
+		flush := func() error {
+			if len(parts) == 0 {
+				return nil
+			}
+			err := write(m.CreatedAt, "response_item", codexItem{Type: "message", Role: m.Role, Content: parts})
+			parts = nil
+			return err
+		}
+		for _, blk := range blocks {
+			var item *codexItem
+			switch blk.Type {
+			case "text":
+				parts = append(parts, codexPart{Type: textType[m.Role], Text: blk.Text})
+			case "image":
+				if blk.Base64Data != "" {
+					parts = append(parts, codexPart{Type: "input_image", ImageURL: "data:" + blk.MediaType + ";base64," + blk.Base64Data})
+				}
+			case "tool_use":
+				switch blk.ToolName {
+				case "bash":
+					cmd, _ := blk.ToolInput["command"].(string)
+					args, _ := json.Marshal(map[string]any{"command": []string{"bash", "-lc", cmd}})
+					item = &codexItem{Type: "function_call", Name: "shell", Arguments: string(args), CallID: blk.ToolUseID}
+				case "patch_apply":
+					patch, _ := blk.ToolInput["patch"].(string)
+					item = &codexItem{Type: "custom_tool_call", Name: "apply_patch", Input: patch, CallID: blk.ToolUseID}
+				default:
+					args, _ := json.Marshal(blk.ToolInput)
+					item = &codexItem{Type: "function_call", Name: blk.ToolName, Arguments: string(args), CallID: blk.ToolUseID}
+				}
+			case "tool_result":
+				code := 0
+				if blk.IsError {
+					code = 1
+				}
+				out, _ := json.Marshal(map[string]any{"output": blk.ToolResult, "metadata": map[string]any{"exit_code": code}})
+				raw, _ := json.Marshal(string(out))
+				item = &codexItem{Type: "function_call_output", CallID: blk.ToolUseID, Output: raw}
+			}
+			if item == nil {
+				continue
+			}
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := write(m.CreatedAt, "response_item", item); err != nil {
+				return err
+			}
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// codexHome returns Codex's config directory.
+func codexHome() (string, error) {
+	if dir := os.Getenv("CODEX_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".codex"), nil
+}
+
+// discoverCodexSessions returns the rollout files whose session started in
+// project. Codex files sessions by date, so each file's first line is read
+// to find its working directory.
+func discoverCodexSessions(project string) ([]string, error) {
+	home, err := codexHome()
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	err = filepath.WalkDir(filepath.Join(home, "sessions"), func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasPrefix(d.Name(), "rollout-") || filepath.Ext(path) != ".jsonl" {
+			return nil
+		}
+		if codexSessionCwd(path) == project {
+			files = append(files, path)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+func codexSessionCwd(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	first, _ := bufio.NewReader(f).ReadBytes('\n')
+	var line codexLine
+	if json.Unmarshal(first, &line) != nil {
+		return ""
+	}
+	if line.Payload != nil {
+		first = line.Payload
+	}
+	var meta codexMeta
+	_ = json.Unmarshal(first, &meta)
+	return meta.Cwd
+}
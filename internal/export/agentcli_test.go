@@ -0,0 +1,193 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestReadClaudeSession(t *testing.T) {
+	in := strings.Join([]string{
+		`{"type":"summary","summary":"Fix the build","leafUuid":"u4"}`,
+		`{"type":"user","sessionId":"s1","uuid":"u0","cwd":"/work/p","timestamp":"2026-03-01T10:00:00.000Z","isMeta":true,"message":{"role":"user","content":"Caveat: ignore"}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u1","cwd":"/work/p","timestamp":"2026-03-01T10:00:01.000Z","message":{"role":"user","content":"why does it fail?"}}`,
+		`{"type":"assistant","sessionId":"s1","uuid":"u2","message":{"role":"assistant","model":"claude-sonnet-4","content":[{"type":"thinking","thinking":"hmm"},{"type":"text","text":"Checking."}]}}`,
+		`{"type":"assistant","sessionId":"s1","uuid":"u3","message":{"role":"assistant","model":"claude-sonnet-4","content":[{"type":"tool_use","id":"t1","name":"Read","input":{"file_path":"/work/p/main.go","limit":10}},{"type":"tool_use","id":"t2","name":"Task","input":{"prompt":"look around"}}]}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u4","isSidechain":true,"message":{"role":"user","content":"subagent chatter"}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u5","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t1","content":[{"type":"text","text":"package main"}]}]}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u6","message":{"role":"user","content":[{"type":"tool_result","tool_use_id":"t2","content":"nothing found","is_error":false}]}}`,
+		`{"type":"user","sessionId":"s1","uuid":"u7","message":{"role":"user","content":"<command-name>/clear</command-name>"}}`,
+		``,
+	}, "\n")
+	sess, err := ReadClaudeSession(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.SourceID != "s1" || sess.Cwd != "/work/p" || sess.Title != "Fix the build" || sess.Model != "claude-sonnet-4" || sess.CreatedAt.IsZero() {
+		t.Errorf("session = %+v", sess)
+	}
+	msgs := sess.Messages
+	if len(msgs) != 3 {
+		t.Fatalf("expected 3 messages, got %d: %+v", len(msgs), msgs)
+	}
+	if msgs[0].Content != "why does it fail?" {
+		t.Errorf("first message = %+v", msgs[0])
+	}
+	blocks := msgs[1].Blocks
+	if len(blocks) != 3 || blocks[0].Text != "Checking." {
+		t.Fatalf("assistant blocks = %+v", blocks)
+	}
+	if call := blocks[1]; call.ToolName != "file_read" || call.ToolInput["path"] != "/work/p/main.go" || call.ToolInput["limit"] != float64(10) {
+		t.Errorf("mapped call = %+v", call)
+	}
+	if blocks[2].Type != "text" || !strings.Contains(blocks[2].Text, "Task") {
+		t.Errorf("unmapped call should become text, got %+v", blocks[2])
+	}
+	results := msgs[2].Blocks
+	if len(results) != 2 || results[0].ToolResult != "package main" || results[1].Type != "text" || !strings.Contains(results[1].Text, "nothing found") {
+		t.Errorf("results = %+v", results)
+	}
+}
+
+func TestReadCodexSession(t *testing.T) {
+	in := strings.Join([]string{
+		`{"timestamp":"2026-03-01T10:00:00Z","type":"session_meta","payload":{"id":"c1","timestamp":"2026-03-01T10:00:00Z","cwd":"/work/p","originator":"codex_cli_rs"}}`,
+		`{"timestamp":"2026-03-01T10:00:00Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"<environment_context>cwd</environment_context>"}]}}`,
+		`{"timestamp":"2026-03-01T10:00:00Z","type":"turn_context","payload":{"cwd":"/work/p","model":"gpt-5-codex"}}`,
+		`{"timestamp":"2026-03-01T10:00:01Z","type":"response_item","payload":{"type":"message","role":"user","content":[{"type":"input_text","text":"list files"}]}}`,
+		`{"timestamp":"2026-03-01T10:00:01Z","type":"event_msg","payload":{"type":"user_message","message":"list files"}}`,
+		`{"timestamp":"2026-03-01T10:00:02Z","type":"response_item","payload":{"type":"reasoning","summary":[]}}`,
+		`{"timestamp":"2026-03-01T10:00:02Z","type":"response_item","payload":{"type":"function_call","name":"shell","arguments":"{\"command\":[\"bash\",\"-lc\",\"ls\"]}","call_id":"call_1"}}`,
+		`{"timestamp":"2026-03-01T10:00:03Z","type":"response_item","payload":{"type":"function_call_output","call_id":"call_1","output":"{\"output\":\"main.go\\n\",\"metadata\":{\"exit_code\":0}}"}}`,
+		`{"timestamp":"2026-03-01T10:00:04Z","type":"response_item","payload":{"type":"message","role":"assistant","content":[{"type":"output_text","text":"One file."}]}}`,
+	}, "\n")
+	sess, err := ReadCodexSession(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.SourceID != "c1" || sess.Cwd != "/work/p" || sess.Model != "gpt-5-codex" {
+		t.Errorf("session = %+v", sess)
+	}
+	msgs := sess.Messages
+	if len(msgs) != 4 {
+		t.Fatalf("expected 4 messages, got %d: %+v", len(msgs), msgs)
+	}
+	if msgs[0].Content != "list files" {
+		t.Errorf("first message = %+v", msgs[0])
+	}
+	if call := msgs[1].Blocks[0]; call.ToolName != "bash" || call.ToolInput["command"] != "ls" || call.ToolUseID != "call_1" {
+		t.Errorf("call = %+v", call)
+	}
+	if res := msgs[2].Blocks[0]; res.ToolResult != "main.go\n" || res.IsError {
+		t.Errorf("result = %+v", res)
+	}
+	if msgs[3].Content != "One file." {
+		t.Errorf("reply = %+v", msgs[3])
+	}
+}
+
+func TestReadCodexSession_legacy(t *testing.T) {
+	in := strings.Join([]string{
+		`{"id":"c0","timestamp":"2025-06-01T10:00:00Z","instructions":null}`,
+		`{"record_type":"state"}`,
+		`{"type":"message","role":"user","content":[{"type":"input_text","text":"hi"}]}`,
+		`{"type":"message","role":"assistant","content":[{"type":"output_text","text":"hello"}]}`,
+	}, "\n")
+	sess, err := ReadCodexSession(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sess.SourceID != "c0" || len(sess.Messages) != 2 || sess.Messages[1].Content != "hello" {
+		t.Errorf("session = %+v", sess)
+	}
+}
+
+func TestSessionFormats_roundTrip(t *testing.T) {
+	for _, name := range []string{"claude", "codex"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := LookupSessionFormat(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := sampleTranscript()
+			tr.Session.ProjectPath = "/work/p"
+			var buf bytes.Buffer
+			if err := WriteTranscript(&buf, tr, name); err != nil {
+				t.Fatal(err)
+			}
+			sess, err := f.Read(&buf)
+			if err != nil {
+				t.Fatalf("reading back: %v\n%s", err, buf.String())
+			}
+			if sess.SourceID != tr.Session.ID || sess.Cwd != "/work/p" {
+				t.Errorf("session = %+v", sess)
+			}
+			want := []domain.TranscriptMessage{
+				{Role: "user", Content: "why does it fail?"},
+				{Role: "assistant", Blocks: []domain.ContentBlock{
+					{Type: "text", Text: "Checking."},
+					{Type: "tool_use", ToolUseID: "t1", ToolName: "bash", ToolInput: map[string]any{"command": "go build"}},
+				}},
+				{Role: "user", Blocks: []domain.ContentBlock{
+					{Type: "tool_result", ToolUseID: "t1", ToolResult: "```\nundefined: foo", IsError: true},
+				}},
+			}
+			if len(sess.Messages) != len(want) {
+				t.Fatalf("got %d messages: %+v", len(sess.Messages), sess.Messages)
+			}
+			for i, m := range sess.Messages {
+				if m.Role != want[i].Role || m.Content != want[i].Content || len(m.Blocks) != len(want[i].Blocks) {
+					t.Errorf("message %d = %+v, want %+v", i, m, want[i])
+					continue
+				}
+				for j, b := range m.Blocks {
+					w := want[i].Blocks[j]
+					if b.Type != w.Type || b.Text != w.Text || b.ToolName != w.ToolName || b.ToolResult != w.ToolResult ||
+						b.IsError != w.IsError || b.ToolUseID != w.ToolUseID || (w.ToolInput != nil && b.ToolInput["command"] != w.ToolInput["command"]) {
+						t.Errorf("message %d block %d = %+v, want %+v", i, j, b, w)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoverClaudeSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CLAUDE_CONFIG_DIR", home)
+	dir := ClaudeProjectDir(home, "/work/my.app")
+	if filepath.Base(dir) != "-work-my-app" {
+		t.Errorf("project dir = %s", dir)
+	}
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "s1.jsonl"), nil, 0o644)
+	files, err := discoverClaudeSessions("/work/my.app")
+	if err != nil || len(files) != 1 {
+		t.Errorf("files = %v (%v)", files, err)
+	}
+}
+
+func TestDiscoverCodexSessions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("CODEX_HOME", home)
+	day := filepath.Join(home, "sessions", "2026", "03", "01")
+	os.MkdirAll(day, 0o755)
+	meta := func(cwd string) []byte {
+		return []byte(`{"type":"session_meta","payload":{"id":"x","cwd":"` + cwd + `"}}` + "\n")
+	}
+	os.WriteFile(filepath.Join(day, "rollout-a.jsonl"), meta("/work/p"), 0o644)
+	os.WriteFile(filepath.Join(day, "rollout-b.jsonl"), meta("/elsewhere"), 0o644)
+	files, err := discoverCodexSessions("/work/p")
+	if err != nil || len(files) != 1 || filepath.Base(files[0]) != "rollout-a.jsonl" {
+		t.Errorf("files = %v (%v)", files, err)
+	}
+
+	t.Setenv("CODEX_HOME", filepath.Join(home, "missing"))
+	if files, err := discoverCodexSessions("/work/p"); err != nil || len(files) != 0 {
+		t.Errorf("missing home: %v (%v)", files, err)
+	}
+}
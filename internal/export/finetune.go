@@ -0,0 +1,220 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/redact"
+)
+
+// Format selects the fine-tuning record schema.
+type Format string
+
+const (
+	// FormatOpenAI emits {"messages": [...]} with tool_calls / role "tool".
+	FormatOpenAI Format = "openai"
+	// FormatAnthropic emits {"system": ..., "messages": [...]} with
+	// tool_use / tool_result content blocks.
+	FormatAnthropic Format = "anthropic"
+)
+
+// ParseFormat validates a fine-tuning format name.
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(strings.ToLower(strings.TrimSpace(s))); f {
+	case FormatOpenAI, FormatAnthropic:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unknown format %q (use openai or anthropic)", s)
+	}
+}
+
+// FineTuneOptions controls how transcripts are converted.
+type FineTuneOptions struct {
+	Format Format
+	// System, if set, is included as the system prompt of every record.
+	System string
+	// Tools keeps tool calls and results. When false only user and
+	// assistant text is exported.
+	Tools bool
+	// Redact masks credentials in all text.
+	Redact bool
+}
+
+// WriteFineTune writes one JSONL record per conversation to w. Conversations
+// without an assistant reply are skipped. Returns the number of records.
+func WriteFineTune(w io.Writer, conversations [][]domain.TranscriptMessage, opts FineTuneOptions) (int, error) {
+	enc := json.NewEncoder(w)
+	n := 0
+	for _, msgs := range conversations {
+		var rec map[string]any
+		switch opts.Format {
+		case FormatAnthropic:
+			rec = anthropicRecord(msgs, opts)
+		default:
+			rec = openAIRecord(msgs, opts)
+		}
+		if rec == nil {
+			continue
+		}
+		if err := enc.Encode(rec); err != nil {
+			return n, fmt.Errorf("encoding record: %w", err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (o FineTuneOptions) clean(s string) string {
+	if o.Redact {
+		return redact.Secrets(s)
+	}
+	return s
+}
+
+// messageBlocks returns a message's blocks, treating plain content as a
+// single text block.
+func messageBlocks(m domain.TranscriptMessage) []domain.ContentBlock {
+	if m.HasBlocks() {
+		return m.Blocks
+	}
+	if m.Content == "" {
+		return nil
+	}
+	return []domain.ContentBlock{{Type: "text", Text: m.Content}}
+}
+
+func hasAssistant(msgs []map[string]any) bool {
+	for _, m := range msgs {
+		if m["role"] == "assistant" {
+			return true
+		}
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// OpenAI chat format
+// ---------------------------------------------------------------------------
+
+func openAIRecord(msgs []domain.TranscriptMessage, opts FineTuneOptions) map[string]any {
+	var out []map[string]any
+	if opts.System != "" {
+		out = append(out, map[string]any{"role": "system", "content": opts.clean(opts.System)})
+	}
+	for _, m := range msgs {
+		var text []string
+		var calls []map[string]any
+		for _, b := range messageBlocks(m) {
+			switch b.Type {
+			case "text":
+				if t := strings.TrimSpace(b.Text); t != "" {
+					text = append(text, opts.clean(t))
+				}
+			case "tool_use":
+				if !opts.Tools {
+					continue
+				}
+				args, _ := json.Marshal(b.ToolInput)
+				calls = append(calls, map[string]any{
+					"id":   b.ToolUseID,
+					"type": "function",
+					"function": map[string]any{
+						"name":      b.ToolName,
+						"arguments": opts.clean(string(args)),
+					},
+				})
+			case "tool_result":
+				if !opts.Tools {
+					continue
+				}
+				out = append(out, map[string]any{
+					"role":         "tool",
+					"tool_call_id": b.ToolUseID,
+					"content":      opts.clean(b.ToolResult),
+				})
+			}
+		}
+		if len(text) == 0 && len(calls) == 0 {
+			continue
+		}
+		msg := map[string]any{"role": m.Role, "content": strings.Join(text, "\n\n")}
+		if len(calls) > 0 {
+			msg["tool_calls"] = calls
+		}
+		out = append(out, msg)
+	}
+	if !hasAssistant(out) {
+		return nil
+	}
+	return map[string]any{"messages": out}
+}
+
+// ---------------------------------------------------------------------------
+// Anthropic messages format
+// ---------------------------------------------------------------------------
+
+func anthropicRecord(msgs []domain.TranscriptMessage, opts FineTuneOptions) map[string]any {
+	var out []map[string]any
+	for _, m := range msgs {
+		var content []map[string]any
+		for _, b := range messageBlocks(m) {
+			switch b.Type {
+			case "text":
+				if t := strings.TrimSpace(b.Text); t != "" {
+					content = append(content, map[string]any{"type": "text", "text": opts.clean(t)})
+				}
+			case "tool_use":
+				if !opts.Tools {
+					continue
+				}
+				input := b.ToolInput
+				if opts.Redact {
+					input = redactInput(input)
+				}
+				content = append(content, map[string]any{"type": "tool_use", "id": b.ToolUseID, "name": b.ToolName, "input": input})
+			case "tool_result":
+				if !opts.Tools {
+					continue
+				}
+				blk := map[string]any{"type": "tool_result", "tool_use_id": b.ToolUseID, "content": opts.clean(b.ToolResult)}
+				if b.IsError {
+					blk["is_error"] = true
+				}
+				content = append(content, blk)
+			}
+		}
+		if len(content) == 0 {
+			continue
+		}
+		// Consecutive same-role messages are merged so roles alternate.
+		if len(out) > 0 && out[len(out)-1]["role"] == m.Role {
+			prev := out[len(out)-1]
+			prev["content"] = append(prev["content"].([]map[string]any), content...)
+			continue
+		}
+		out = append(out, map[string]any{"role": m.Role, "content": content})
+	}
+	if !hasAssistant(out) {
+		return nil
+	}
+	rec := map[string]any{"messages": out}
+	if opts.System != "" {
+		rec["system"] = opts.clean(opts.System)
+	}
+	return rec
+}
+
+// redactInput masks credentials in string values of a tool input.
+func redactInput(in map[string]any) map[string]any {
+	out := make(map[string]any, len(in))
+	for k, v := range in {
+		if s, ok := v.(string); ok {
+			v = redact.Secrets(s)
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func sampleConversation() []domain.TranscriptMessage {
+	return []domain.TranscriptMessage{
+		{Role: "user", Content: "list files, token=supersecretvalue123"},
+		{Role: "assistant", Blocks: []domain.ContentBlock{
+			{Type: "text", Text: "Listing."},
+			{Type: "tool_use", ToolUseID: "t1", ToolName: "bash", ToolInput: map[string]any{"command": "ls"}},
+		}},
+		{Role: "user", Blocks: []domain.ContentBlock{
+			{Type: "tool_result", ToolUseID: "t1", ToolResult: "main.go"},
+		}},
+		{Role: "assistant", Content: "There is one file: main.go."},
+	}
+}
+
+func decodeLines(t *testing.T, out string) []map[string]any {
+	t.Helper()
+	var recs []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid JSONL line %q: %v", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+func TestWriteFineTune_openAI(t *testing.T) {
+	var buf bytes.Buffer
+	n, err := WriteFineTune(&buf, [][]domain.TranscriptMessage{sampleConversation(), {{Role: "user", Content: "no reply"}}},
+		FineTuneOptions{Format: FormatOpenAI, System: "You are muxd.", Tools: true, Redact: true})
+	if err != nil || n != 1 {
+		t.Fatalf("WriteFineTune = %d, %v; want 1 record", n, err)
+	}
+	if strings.Contains(buf.String(), "supersecretvalue123") {
+		t.Error("secret not redacted")
+	}
+	msgs := decodeLines(t, buf.String())[0]["messages"].([]any)
+	roles := make([]string, len(msgs))
+	for i, m := range msgs {
+		roles[i] = m.(map[string]any)["role"].(string)
+	}
+	if got := strings.Join(roles, ","); got != "system,user,assistant,tool,assistant" {
+		t.Errorf("roles = %s", got)
+	}
+	call := msgs[2].(map[string]any)["tool_calls"].([]any)[0].(map[string]any)
+	if fn := call["function"].(map[string]any); fn["name"] != "bash" || fn["arguments"] != `{"command":"ls"}` {
+		t.Errorf("unexpected tool call: %v", call)
+	}
+}
+
+func TestWriteFineTune_anthropic(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := WriteFineTune(&buf, [][]domain.TranscriptMessage{sampleConversation()},
+		FineTuneOptions{Format: FormatAnthropic, System: "You are muxd.", Tools: true}); err != nil {
+		t.Fatalf("WriteFineTune: %v", err)
+	}
+	rec := decodeLines(t, buf.String())[0]
+	if rec["system"] != "You are muxd." {
+		t.Errorf("system = %v", rec["system"])
+	}
+	msgs := rec["messages"].([]any)
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4", len(msgs))
+	}
+	result := msgs[2].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if result["type"] != "tool_result" || result["tool_use_id"] != "t1" {
+		t.Errorf("unexpected tool result block: %v", result)
+	}
+}
+
+func TestWriteFineTune_noTools(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := WriteFineTune(&buf, [][]domain.TranscriptMessage{sampleConversation()},
+		FineTuneOptions{Format: FormatOpenAI}); err != nil {
+		t.Fatalf("WriteFineTune: %v", err)
+	}
+	if strings.Contains(buf.String(), "tool") {
+		t.Errorf("tool data exported with Tools=false: %s", buf.String())
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	if f, err := ParseFormat(" OpenAI "); err != nil || f != FormatOpenAI {
+		t.Errorf("ParseFormat(OpenAI) = %q, %v", f, err)
+	}
+	if _, err := ParseFormat("gemini"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
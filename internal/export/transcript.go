@@ -0,0 +1,182 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// Transcript is a session with its full message history, as exported by
+// /api/sessions/{id}/export.
+type Transcript struct {
+	Session    domain.Session        `json:"session"`
+	ExportedAt time.Time             `json:"exported_at"`
+	Messages   []store.MessageRecord `json:"messages"`
+}
+
+// ParseTranscriptFormat validates a transcript format name. "markdown" is
+// accepted as an alias for "md"; empty defaults to "json". The session
+// formats of other agent CLIs ("claude", "codex") are accepted too.
+func ParseTranscriptFormat(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "json":
+		return "json", nil
+	case "md", "markdown":
+		return "md", nil
+	}
+	if f, err := LookupSessionFormat(s); err == nil {
+		return f.Name, nil
+	}
+	return "", fmt.Errorf("unknown format %q (use json, md, claude, or codex)", s)
+}
+
+// TranscriptFileName returns the default file name for an exported session.
+// Other CLIs' formats use the name that CLI would give the file.
+func TranscriptFileName(s domain.Session, format string) string {
+	if f, ok := sessionFormats[format]; ok {
+		return f.FileName(s)
+	}
+	return "muxd-" + s.ID[:min(8, len(s.ID))] + "." + format
+}
+
+// WriteTranscript writes t to w in the given format ("json", "md", or a
+// session format such as "claude").
+func WriteTranscript(w io.Writer, t Transcript, format string) error {
+	if f, ok := sessionFormats[format]; ok {
+		return f.Write(w, t)
+	}
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(t)
+	case "md":
+		_, err := io.WriteString(w, Markdown(t))
+		return err
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// Markdown renders a transcript as a Markdown document. Tool calls and
+// results are included as fenced blocks; images are noted by file name.
+// Web sources cited as [N] in replies become footnotes.
+func Markdown(t Transcript) string {
+	s := t.Session
+	var b strings.Builder
+	title := strings.TrimSpace(s.Title)
+	if title == "" {
+		title = "Session " + s.ID[:min(8, len(s.ID))]
+	}
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	fmt.Fprintf(&b, "- Session: `%s`\n", s.ID)
+	fmt.Fprintf(&b, "- Project: `%s`\n", s.ProjectPath)
+	fmt.Fprintf(&b, "- Model: %s\n", s.Model)
+	fmt.Fprintf(&b, "- Created: %s\n", s.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(&b, "- Tokens: %d (input %d, output %d)\n", s.TotalTokens, s.InputTokens, s.OutputTokens)
+	if tags := s.TagList(); len(tags) > 0 {
+		fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(tags, ", "))
+	}
+
+	var blocks []domain.ContentBlock
+	for _, m := range t.Messages {
+		blocks = append(blocks, m.Blocks...)
+	}
+	sources := domain.ParseCitations(blocks)
+	var replies strings.Builder
+	cite := func(role, text string) string {
+		if role != "assistant" || len(sources) == 0 {
+			return text
+		}
+		replies.WriteString(text + "\n")
+		return footnoteRefs(text, sources)
+	}
+
+	for _, m := range t.Messages {
+		fmt.Fprintf(&b, "\n## %s", roleHeading(m.Role))
+		var meta []string
+		if !m.CreatedAt.IsZero() {
+			meta = append(meta, m.CreatedAt.Format("2006-01-02 15:04:05"))
+		}
+		if m.Tokens > 0 {
+			meta = append(meta, fmt.Sprintf("%d tokens", m.Tokens))
+		}
+		if len(meta) > 0 {
+			fmt.Fprintf(&b, " · %s", strings.Join(meta, " · "))
+		}
+		b.WriteString("\n\n")
+
+		if len(m.Blocks) == 0 {
+			b.WriteString(cite(m.Role, strings.TrimSpace(m.Content)) + "\n")
+			continue
+		}
+		for _, blk := range m.Blocks {
+			switch blk.Type {
+			case "text":
+				if t := strings.TrimSpace(blk.Text); t != "" {
+					b.WriteString(cite(m.Role, t) + "\n\n")
+				}
+			case "tool_use":
+				input, _ := json.MarshalIndent(blk.ToolInput, "", "  ")
+				fmt.Fprintf(&b, "**Tool call:** `%s`\n\n%s\n\n", blk.ToolName, fence("json", string(input)))
+			case "tool_result":
+				label := "Tool result"
+				if blk.IsError {
+					label = "Tool error"
+				}
+				fmt.Fprintf(&b, "**%s:**\n\n%s\n\n", label, fence("", blk.ToolResult))
+			case "image":
+				fmt.Fprintf(&b, "*[image: %s]*\n\n", filepath.Base(blk.ImagePath))
+			}
+		}
+	}
+
+	if cited := domain.CitedSources(replies.String(), sources); len(cited) > 0 {
+		b.WriteString("\n## Sources\n\n")
+		for _, c := range cited {
+			title := c.Title
+			if title == "" {
+				title = c.URL
+			}
+			fmt.Fprintf(&b, "[^%d]: [%s](%s)\n", c.N, title, c.URL)
+		}
+	}
+	return b.String()
+}
+
+// footnoteRefs rewrites [N] citations of known sources in text as
+// Markdown footnote references [^N].
+func footnoteRefs(text string, sources []domain.Citation) string {
+	for _, c := range sources {
+		ref := fmt.Sprintf("[%d]", c.N)
+		text = strings.ReplaceAll(text, ref, fmt.Sprintf("[^%d]", c.N))
+	}
+	return text
+}
+
+func roleHeading(role string) string {
+	switch role {
+	case "user":
+		return "User"
+	case "assistant":
+		return "Assistant"
+	default:
+		return role
+	}
+}
+
+// fence wraps s in a code fence long enough not to collide with backtick
+// runs inside s.
+func fence(lang, s string) string {
+	ticks := "```"
+	for strings.Contains(s, ticks) {
+		ticks += "`"
+	}
+	return ticks + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + ticks
+}
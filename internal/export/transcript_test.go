@@ -0,0 +1,92 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func sampleTranscript() Transcript {
+	return Transcript{
+		Session: domain.Session{ID: "abcdef123456", Title: "Fix build", ProjectPath: "/tmp/p", Model: "m", TotalTokens: 42},
+		Messages: []store.MessageRecord{
+			{Sequence: 1, Role: "user", Content: "why does it fail?", CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)},
+			{Sequence: 2, Role: "assistant", Tokens: 12, Blocks: []domain.ContentBlock{
+				{Type: "text", Text: "Checking."},
+				{Type: "tool_use", ToolUseID: "t1", ToolName: "bash", ToolInput: map[string]any{"command": "go build"}},
+			}},
+			{Sequence: 3, Role: "user", Blocks: []domain.ContentBlock{
+				{Type: "tool_result", ToolUseID: "t1", ToolResult: "```\nundefined: foo", IsError: true},
+			}},
+		},
+	}
+}
+
+func TestParseTranscriptFormat(t *testing.T) {
+	for in, want := range map[string]string{"": "json", "JSON": "json", "md": "md", "markdown": "md", "claude-code": "claude", "codex": "codex"} {
+		if got, err := ParseTranscriptFormat(in); err != nil || got != want {
+			t.Errorf("ParseTranscriptFormat(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseTranscriptFormat("html"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	md := Markdown(sampleTranscript())
+	for _, want := range []string{
+		"# Fix build",
+		"- Tokens: 42",
+		"## User · 2026-01-02 03:04:05",
+		"## Assistant · 12 tokens",
+		"**Tool call:** `bash`",
+		"**Tool error:**",
+		"````\n```\nundefined: foo\n````",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestMarkdown_citations(t *testing.T) {
+	tr := sampleTranscript()
+	tr.Messages = append(tr.Messages,
+		store.MessageRecord{Sequence: 4, Role: "user", Blocks: []domain.ContentBlock{
+			{Type: "tool_result", ToolUseID: "t2", ToolName: "web_search", ToolResult: "[1] Go\n   https://go.dev\n\n[2] Wiki\n   https://go.dev/wiki"},
+		}},
+		store.MessageRecord{Sequence: 5, Role: "assistant", Blocks: []domain.ContentBlock{
+			{Type: "text", Text: "Go compiles fast [2]. Array [3] stays."},
+		}},
+	)
+	md := Markdown(tr)
+	if !strings.Contains(md, "Go compiles fast [^2]. Array [3] stays.") {
+		t.Errorf("citations not rewritten as footnotes:\n%s", md)
+	}
+	if !strings.Contains(md, "## Sources\n\n[^2]: [Wiki](https://go.dev/wiki)\n") || strings.Contains(md, "[^1]:") {
+		t.Errorf("want a footnote for the cited source only:\n%s", md)
+	}
+	if strings.Contains(Markdown(sampleTranscript()), "## Sources") {
+		t.Error("transcript without sources should have no Sources section")
+	}
+}
+
+func TestWriteTranscript_json(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTranscript(&buf, sampleTranscript(), "json"); err != nil {
+		t.Fatal(err)
+	}
+	var got Transcript
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if got.Session.ID != "abcdef123456" || len(got.Messages) != 3 || got.Messages[1].Blocks[1].ToolName != "bash" {
+		t.Errorf("unexpected round trip: %+v", got)
+	}
+}
@@ -0,0 +1,378 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/store"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// Backend is the daemon API that shared commands run against. It is
+// implemented by *daemon.DaemonClient, so every adapter sees the same
+// scheduler state as the daemon.
+type Backend interface {
+	ListScheduledJobs() ([]store.ScheduledToolJob, error)
+	ListScheduledJobsWithAttempts() ([]store.ScheduledToolJob, error)
+	CreateScheduledJob(toolName string, toolInput map[string]any, scheduledFor time.Time, recurrence string) (string, error)
+	CancelScheduledJob(id string) error
+	ListDrafts() ([]store.ScheduledToolJob, error)
+	ApproveDraft(idPrefix string) (string, error)
+	RejectDraft(idPrefix string) (string, error)
+}
+
+// Reply is the plain-text result of a command. Adapters choose how to
+// style it: Title is a heading for list output (empty for one-line
+// replies), Lines are the body, and IsError marks usage and backend
+// errors. A non-empty Submit is sent to the agent as the user's message.
+type Reply struct {
+	Title   string
+	Lines   []string
+	IsError bool
+	Submit  string
+}
+
+// Text renders the reply as plain text for adapters without styling.
+func (r Reply) Text() string {
+	if r.Title == "" {
+		return strings.Join(r.Lines, "\n")
+	}
+	return strings.Join(append([]string{r.Title}, r.Lines...), "\n")
+}
+
+func message(format string, args ...any) Reply {
+	return Reply{Lines: []string{fmt.Sprintf(format, args...)}}
+}
+
+func failure(msg string) Reply {
+	return Reply{Lines: []string{msg}, IsError: true}
+}
+
+// ---------------------------------------------------------------------------
+// /schedule
+// ---------------------------------------------------------------------------
+
+const (
+	scheduleRecurrenceUsage = `[--hourly|--daily|--weekly|--cron "<expr>" [--tz <zone>]]`
+	scheduleAddUsage        = "Usage: /schedule add <tool> <HH:MM|RFC3339> <json> " + scheduleRecurrenceUsage + " (the time is omitted with --cron)"
+	scheduleAddTaskUsage    = "Usage: /schedule add-task <HH:MM|RFC3339> <prompt> " + scheduleRecurrenceUsage + " (the time is omitted with --cron)"
+	scheduleUsage           = "Usage: /schedule add <tool> <HH:MM|RFC3339> <json> " + scheduleRecurrenceUsage + " | /schedule add-task <HH:MM|RFC3339> <prompt> " + scheduleRecurrenceUsage + " | /schedule list [--verbose] | /schedule cancel <id>"
+)
+
+// Schedule runs /schedule with the given arguments. Times are parsed
+// relative to now. A job given --cron first runs at the expression's next
+// match, so it takes no time argument.
+func Schedule(b Backend, args []string, now time.Time) Reply {
+	if len(args) == 0 {
+		return failure(scheduleUsage)
+	}
+	switch strings.ToLower(args[0]) {
+	case "list":
+		verbose := false
+		for _, a := range args[1:] {
+			if a != "--verbose" && a != "-v" {
+				return failure("Usage: /schedule list [--verbose]")
+			}
+			verbose = true
+		}
+		list := b.ListScheduledJobs
+		if verbose {
+			list = b.ListScheduledJobsWithAttempts
+		}
+		items, err := list()
+		if err != nil {
+			return failure("Failed to list scheduled jobs: " + err.Error())
+		}
+		if len(items) == 0 {
+			return message("No scheduled jobs.")
+		}
+		r := Reply{Title: "Scheduled jobs"}
+		for _, it := range items {
+			displayName := it.ToolName
+			if it.ToolName == tools.AgentTaskToolName {
+				displayName = "agent_task"
+				if p, ok := it.ToolInput["prompt"].(string); ok && p != "" {
+					if len(p) > 40 {
+						p = p[:40] + "..."
+					}
+					displayName += ": " + p
+				}
+			}
+			line := fmt.Sprintf("  %-8s %-14s %-9s %s", shortID(it.ID), displayName, it.Status, it.ScheduledFor.Local().Format("2006-01-02 15:04"))
+			if it.Recurrence != "" && it.Recurrence != "once" {
+				line += "  " + recurrenceLabel(it.Recurrence)
+			}
+			r.Lines = append(r.Lines, line)
+			if verbose {
+				r.Lines = append(r.Lines, attemptLines(it)...)
+			}
+		}
+		return r
+
+	case "cancel":
+		if len(args) < 2 {
+			return failure("Usage: /schedule cancel <id>")
+		}
+		if err := b.CancelScheduledJob(args[1]); err != nil {
+			return failure("Failed to cancel job: " + err.Error())
+		}
+		return message("Canceled scheduled job: %s", args[1])
+
+	case "add":
+		rest, recurrence, scheduledFor, err := splitRecurrence(args[1:], now)
+		if err != nil {
+			return failure(err.Error())
+		}
+		rest, scheduledFor, err = scheduleTime(rest, 1, scheduledFor, now)
+		if err != nil {
+			return failure(err.Error())
+		}
+		if len(rest) < 2 {
+			return failure(scheduleAddUsage)
+		}
+		toolName := tools.NormalizeToolName(rest[0])
+		if _, ok := tools.FindTool(toolName); !ok {
+			return failure("Unknown tool: " + toolName)
+		}
+		var input map[string]any
+		if err := json.Unmarshal([]byte(strings.Join(rest[1:], " ")), &input); err != nil {
+			return failure("Invalid JSON tool input: " + err.Error())
+		}
+		id, err := b.CreateScheduledJob(toolName, input, scheduledFor, recurrence)
+		if err != nil {
+			return failure("Failed to schedule job: " + err.Error())
+		}
+		return message("Scheduled job %s: %s at %s (%s)", shortID(id), toolName, scheduledFor.Local().Format("2006-01-02 15:04"), recurrenceLabel(recurrence))
+
+	case "add-task":
+		rest, recurrence, scheduledFor, err := splitRecurrence(args[1:], now)
+		if err != nil {
+			return failure(err.Error())
+		}
+		rest, scheduledFor, err = scheduleTime(rest, 0, scheduledFor, now)
+		if err != nil {
+			return failure(err.Error())
+		}
+		if len(rest) == 0 {
+			return failure(scheduleAddTaskUsage)
+		}
+		prompt := strings.Join(rest, " ")
+		id, err := b.CreateScheduledJob(tools.AgentTaskToolName, map[string]any{"prompt": prompt}, scheduledFor, recurrence)
+		if err != nil {
+			return failure("Failed to schedule task: " + err.Error())
+		}
+		return message("Scheduled agent task %s at %s (%s)", shortID(id), scheduledFor.Local().Format("2006-01-02 15:04"), recurrenceLabel(recurrence))
+
+	default:
+		return failure("Usage: /schedule [add|add-task|list|cancel]")
+	}
+}
+
+// splitRecurrence strips the trailing recurrence flags from args:
+// --hourly, --daily, --weekly, or --cron "<expr>" with an optional
+// --tz <zone>. The cron expression may be quoted or given as five bare
+// fields. For --cron it also returns the first run after now.
+func splitRecurrence(args []string, now time.Time) (rest []string, recurrence string, first time.Time, err error) {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			continue
+		}
+		interval, expr, tz, ok := parseRecurrenceFlags(args[i:])
+		if !ok {
+			continue
+		}
+		switch {
+		case expr == "" && tz != "":
+			return nil, "", time.Time{}, fmt.Errorf("--tz only applies to --cron")
+		case expr == "":
+			return args[:i], interval, time.Time{}, nil
+		case interval != "":
+			return nil, "", time.Time{}, fmt.Errorf("use either --%s or --cron, not both", interval)
+		}
+		recurrence, first, err := tools.CronRecurrence(expr, tz, now)
+		if err != nil {
+			return nil, "", time.Time{}, err
+		}
+		return args[:i], recurrence, first, nil
+	}
+	if slices.Contains(args, "--cron") {
+		return nil, "", time.Time{}, fmt.Errorf(`--cron takes a five-field expression, e.g. --cron "0 9 * * MON" --tz Europe/Berlin`)
+	}
+	return args, "once", time.Time{}, nil
+}
+
+// scheduleTime takes the time argument at rest[pos], unless --cron already
+// set the first run.
+func scheduleTime(rest []string, pos int, first, now time.Time) ([]string, time.Time, error) {
+	if !first.IsZero() || len(rest) <= pos {
+		return rest, first, nil
+	}
+	at, err := tools.ParseScheduleTime(rest[pos], now)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return slices.Delete(slices.Clone(rest), pos, pos+1), at, nil
+}
+
+// recurrenceLabel renders a stored recurrence for display, with a cron
+// job's time zone after its expression.
+func recurrenceLabel(recurrence string) string {
+	if rest, ok := strings.CutPrefix(recurrence, "CRON_TZ="); ok {
+		tz, expr, _ := strings.Cut(rest, " ")
+		return "cron " + expr + " " + tz
+	}
+	if tools.IsCronRecurrence(recurrence) {
+		return "cron " + recurrence
+	}
+	return recurrence
+}
+
+// parseRecurrenceFlags parses args as nothing but recurrence flags.
+func parseRecurrenceFlags(args []string) (interval, expr, tz string, ok bool) {
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--hourly", "--daily", "--weekly":
+			if interval != "" {
+				return "", "", "", false
+			}
+			interval = strings.TrimPrefix(args[i], "--")
+		case "--tz":
+			if i+1 >= len(args) || tz != "" {
+				return "", "", "", false
+			}
+			i++
+			tz = args[i]
+		case "--cron":
+			if i+1 >= len(args) || args[i+1] == "" || expr != "" {
+				return "", "", "", false
+			}
+			end := i + 5 // five bare fields
+			if strings.Contains(args[i+1], " ") {
+				end = i + 1 // already one argument
+			} else if q := args[i+1][:1]; q == `"` || q == "'" {
+				end = -1
+				for j := i + 1; j < len(args); j++ {
+					if strings.HasSuffix(args[j], q) && (j > i+1 || len(args[j]) > 1) {
+						end = j
+						break
+					}
+				}
+			}
+			if end < 0 || end >= len(args) {
+				return "", "", "", false
+			}
+			expr = strings.Trim(strings.Join(args[i+1:end+1], " "), `"'`)
+			i = end
+		default:
+			return "", "", "", false
+		}
+	}
+	return interval, expr, tz, true
+}
+
+// ---------------------------------------------------------------------------
+// /drafts
+// ---------------------------------------------------------------------------
+
+// Drafts runs /drafts with the given arguments: list (the default),
+// approve <id>, or reject <id>.
+func Drafts(b Backend, args []string) Reply {
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	switch sub {
+	case "list":
+		items, err := b.ListDrafts()
+		if err != nil {
+			return failure("Failed to list drafts: " + err.Error())
+		}
+		if len(items) == 0 {
+			return message("No drafts awaiting approval.")
+		}
+		r := Reply{Title: "Drafts awaiting approval"}
+		for _, it := range items {
+			r.Lines = append(r.Lines, fmt.Sprintf("  %-8s %-14s %s  %s", shortID(it.ID), draftToolLabel(it.ToolName), it.ScheduledFor.Local().Format("2006-01-02 15:04"), recurrenceLabel(it.Recurrence)))
+			if summary := draftSummary(it.ToolInput); summary != "" {
+				r.Lines = append(r.Lines, "           "+summary)
+			}
+		}
+		r.Lines = append(r.Lines, "  /drafts approve <id> | /drafts reject <id>")
+		return r
+
+	case "approve", "reject":
+		if len(args) < 2 {
+			return failure("Usage: /drafts " + sub + " <id>")
+		}
+		var id string
+		var err error
+		if sub == "approve" {
+			id, err = b.ApproveDraft(args[1])
+		} else {
+			id, err = b.RejectDraft(args[1])
+		}
+		if err != nil {
+			return failure("Failed to " + sub + " draft: " + err.Error())
+		}
+		if sub == "approve" {
+			return message("Approved draft %s; it will run at its scheduled time.", shortID(id))
+		}
+		return message("Rejected draft %s.", shortID(id))
+
+	default:
+		return failure("Usage: /drafts [list] | /drafts approve <id> | /drafts reject <id>")
+	}
+}
+
+func draftToolLabel(toolName string) string {
+	if toolName == tools.AgentTaskToolName {
+		return "agent_task"
+	}
+	return toolName
+}
+
+// draftSummary shows the part of a draft the user is approving: the
+// recipient and message, or an agent task prompt.
+func draftSummary(input map[string]any) string {
+	var parts []string
+	for _, key := range []string{"account", "phone", "message", "prompt"} {
+		if v, ok := input[key].(string); ok && v != "" {
+			parts = append(parts, v)
+		}
+	}
+	s := strings.Join(strings.Fields(strings.Join(parts, " · ")), " ")
+	if len(s) > 120 {
+		s = s[:120] + "..."
+	}
+	return s
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
+
+// attemptLines describes a job's attempt history for /schedule list
+// --verbose: the pending retry, if any, then the most recent attempts.
+func attemptLines(job store.ScheduledToolJob) []string {
+	var lines []string
+	if job.RetryAt != nil {
+		lines = append(lines, fmt.Sprintf("           retry %d at %s", job.RetryCount, job.RetryAt.Local().Format("2006-01-02 15:04")))
+	}
+	if len(job.Attempts) == 0 {
+		return append(lines, "           no attempts yet")
+	}
+	for _, a := range job.Attempts {
+		line := fmt.Sprintf("           %s  %-9s", a.AttemptedAt.Local().Format("2006-01-02 15:04"), a.Status)
+		if a.Error != "" {
+			line += " " + a.Error
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+	}
+	return lines
+}
@@ -0,0 +1,174 @@
+package gateway
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/store"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// fakeBackend keeps jobs in memory.
+type fakeBackend struct {
+	jobs     []store.ScheduledToolJob
+	canceled []string
+}
+
+func (f *fakeBackend) ListScheduledJobs() ([]store.ScheduledToolJob, error) { return f.jobs, nil }
+
+func (f *fakeBackend) ListScheduledJobsWithAttempts() ([]store.ScheduledToolJob, error) {
+	return f.jobs, nil
+}
+
+func (f *fakeBackend) CreateScheduledJob(toolName string, input map[string]any, at time.Time, recurrence string) (string, error) {
+	id := "job-0000000" + string(rune('0'+len(f.jobs)))
+	f.jobs = append(f.jobs, store.ScheduledToolJob{ID: id, ToolName: toolName, ToolInput: input, ScheduledFor: at, Recurrence: recurrence, Status: "pending"})
+	return id, nil
+}
+
+func (f *fakeBackend) CancelScheduledJob(id string) error {
+	f.canceled = append(f.canceled, id)
+	return nil
+}
+
+func (f *fakeBackend) ListDrafts() ([]store.ScheduledToolJob, error) {
+	var out []store.ScheduledToolJob
+	for _, j := range f.jobs {
+		if j.Status == "draft" {
+			out = append(out, j)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeBackend) ApproveDraft(idPrefix string) (string, error) {
+	for i, j := range f.jobs {
+		if j.Status == "draft" && strings.HasPrefix(j.ID, idPrefix) {
+			f.jobs[i].Status = "pending"
+			return j.ID, nil
+		}
+	}
+	return "", errors.New("no draft matches " + idPrefix)
+}
+
+func (f *fakeBackend) RejectDraft(idPrefix string) (string, error) {
+	return "", errors.New("no draft matches " + idPrefix)
+}
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2026, 1, 2, 9, 0, 0, 0, time.Local)
+	b := &fakeBackend{}
+
+	r := Schedule(b, []string{"add", "sms_send", "10:30", `{"phone":"+15551234567","message":"hi"}`, "--daily"}, now)
+	if r.IsError {
+		t.Fatalf("add: %s", r.Text())
+	}
+	if len(b.jobs) != 1 || b.jobs[0].Recurrence != "daily" || b.jobs[0].ToolInput["message"] != "hi" {
+		t.Fatalf("unexpected job: %+v", b.jobs)
+	}
+
+	r = Schedule(b, []string{"add-task", "11:00", "check", "the", "build"}, now)
+	if r.IsError || b.jobs[1].ToolName != tools.AgentTaskToolName || b.jobs[1].ToolInput["prompt"] != "check the build" || b.jobs[1].Recurrence != "once" {
+		t.Fatalf("add-task: %s %+v", r.Text(), b.jobs[1])
+	}
+
+	r = Schedule(b, []string{"list"}, now)
+	if r.Title != "Scheduled jobs" || len(r.Lines) != 2 || !strings.Contains(r.Lines[1], "agent_task: check the build") {
+		t.Errorf("list = %+v", r)
+	}
+
+	if r := Schedule(b, []string{"cancel", "job-1"}, now); r.IsError || len(b.canceled) != 1 {
+		t.Errorf("cancel: %s", r.Text())
+	}
+
+	for _, args := range [][]string{nil, {"add", "nope", "10:00", "{}"}, {"add", "sms_send", "10:00", "not json"}, {"bogus"},
+		{"add-task", "check", "--cron", `"0`, "25", "*", "*", `MON"`},
+		{"add-task", "check", "--cron", "0", "9"},
+		{"add-task", "10:00", "check", "--daily", "--tz", "UTC"},
+	} {
+		if r := Schedule(b, args, now); !r.IsError {
+			t.Errorf("Schedule(%v) should fail, got %q", args, r.Text())
+		}
+	}
+}
+
+func TestSchedule_recurrence(t *testing.T) {
+	now := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC) // a Friday
+	b := &fakeBackend{}
+
+	r := Schedule(b, []string{"add-task", "summarize", "open", "PRs", "--cron", `"0`, "9", "*", "*", `MON"`, "--tz", "Europe/Berlin"}, now)
+	if r.IsError {
+		t.Fatalf("add-task --cron: %s", r.Text())
+	}
+	job := b.jobs[0]
+	if job.Recurrence != "CRON_TZ=Europe/Berlin 0 9 * * MON" || job.ToolInput["prompt"] != "summarize open PRs" {
+		t.Errorf("job = %+v", job)
+	}
+	if want := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC); !job.ScheduledFor.Equal(want) {
+		t.Errorf("first run = %v, want %v", job.ScheduledFor, want)
+	}
+	if !strings.Contains(r.Text(), "cron 0 9 * * MON Europe/Berlin") {
+		t.Errorf("reply = %q", r.Text())
+	}
+
+	r = Schedule(b, []string{"add", "sms_send", `{"phone":"+15551234567","message":"use`, "--daily", `please"}`, "--cron", "*/30", "*", "*", "*", "*"}, now)
+	if r.IsError || b.jobs[1].Recurrence != "*/30 * * * *" || b.jobs[1].ToolInput["message"] != "use --daily please" {
+		t.Errorf("add --cron: %s %+v", r.Text(), b.jobs[1])
+	}
+
+	r = Schedule(b, []string{"add-task", "10:00", "water", "the", "plants", "--weekly"}, now)
+	if r.IsError || b.jobs[2].Recurrence != "weekly" {
+		t.Errorf("add-task --weekly: %s %+v", r.Text(), b.jobs[2])
+	}
+
+	r = Schedule(b, []string{"list"}, now)
+	if !strings.Contains(r.Lines[0], "cron 0 9 * * MON Europe/Berlin") || !strings.Contains(r.Lines[2], "weekly") {
+		t.Errorf("list = %q", r.Lines)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	b := &fakeBackend{jobs: []store.ScheduledToolJob{
+		{ID: "draft-123456", ToolName: "sms_send", ToolInput: map[string]any{"phone": "+15551234567", "message": "hello"}, Status: "draft", Recurrence: "once"},
+	}}
+
+	r := Drafts(b, nil)
+	if r.Title != "Drafts awaiting approval" || !strings.Contains(r.Text(), "+15551234567 · hello") {
+		t.Errorf("list = %q", r.Text())
+	}
+	if r := Drafts(b, []string{"approve", "draft-12"}); r.IsError || !strings.Contains(r.Text(), "Approved draft draft-12") {
+		t.Errorf("approve = %q", r.Text())
+	}
+	if r := Drafts(b, nil); r.Text() != "No drafts awaiting approval." {
+		t.Errorf("after approve = %q", r.Text())
+	}
+	if r := Drafts(b, []string{"reject", "zzz"}); !r.IsError || !strings.HasPrefix(r.Text(), "Failed to reject draft") {
+		t.Errorf("reject = %q", r.Text())
+	}
+}
+
+func TestSchedule_listVerbose(t *testing.T) {
+	now := time.Date(2026, 1, 2, 9, 0, 0, 0, time.Local)
+	retryAt := now.Add(2 * time.Minute)
+	b := &fakeBackend{jobs: []store.ScheduledToolJob{
+		{ID: "job-00000001", ToolName: "sms_send", Status: "pending", ScheduledFor: now, RetryCount: 1, RetryAt: &retryAt,
+			Attempts: []store.ScheduledToolJobAttempt{{Status: "failed", Error: "boom", AttemptedAt: now}}},
+		{ID: "job-00000002", ToolName: "web_fetch", Status: "pending", ScheduledFor: now},
+	}}
+
+	r := Schedule(b, []string{"list", "--verbose"}, now)
+	text := r.Text()
+	for _, want := range []string{"retry 1 at 2026-01-02 09:02", "2026-01-02 09:00  failed    boom", "no attempts yet"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("verbose list missing %q:\n%s", want, text)
+		}
+	}
+	if r := Schedule(b, []string{"list"}, now); strings.Contains(r.Text(), "boom") {
+		t.Errorf("plain list shows attempts:\n%s", r.Text())
+	}
+	if r := Schedule(b, []string{"list", "--all"}, now); !r.IsError {
+		t.Errorf("expected usage error, got %q", r.Text())
+	}
+}
@@ -0,0 +1,123 @@
+package gateway
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PromptCommandsDir returns the directory of user-defined prompt commands:
+// ~/.config/muxd/commands/
+func PromptCommandsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("resolving home dir: %w", err)
+	}
+	return filepath.Join(home, ".config", "muxd", "commands"), nil
+}
+
+// LoadPromptCommands registers a command for each *.md file in dir. The file
+// name (without extension) is the command name and the body is a prompt
+// template sent to the agent; $ARGUMENTS is replaced by the command's
+// arguments, or they are appended when the template does not use it. An
+// optional front matter block may set a description:
+//
+//	---
+//	description: review the staged diff
+//	---
+//
+// A missing dir is not an error. Files that fail to load are reported
+// together after the rest are registered.
+func LoadPromptCommands(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading commands dir: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var errs []string
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".md") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		name := "/" + strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		if err := Register(PromptCommand(name, string(data))); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", e.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("loading prompt commands: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// PromptCommand builds a command that submits the template, expanded with
+// the command's arguments, as the user's message.
+func PromptCommand(name, source string) Command {
+	description, template := parsePromptFrontMatter(source)
+	if description == "" {
+		description = "run the " + strings.TrimPrefix(name, "/") + " prompt"
+	}
+	return Command{
+		Name:        name,
+		Usage:       "[arguments]",
+		Description: description,
+		Group:       "plugins",
+		Run: func(_ Env, args []string) Reply {
+			prompt := expandPrompt(template, strings.Join(args, " "))
+			if prompt == "" {
+				return failure("Prompt command " + name + " is empty.")
+			}
+			// Adapters treat a leading slash as another command, which
+			// could loop back here.
+			if strings.HasPrefix(prompt, "/") {
+				return failure("Prompt command " + name + " must not expand to a slash command.")
+			}
+			return Reply{Submit: prompt}
+		},
+	}
+}
+
+// parsePromptFrontMatter splits an optional leading "---" block from the
+// template and returns its description field.
+func parsePromptFrontMatter(source string) (description, body string) {
+	source = strings.ReplaceAll(source, "\r\n", "\n")
+	if !strings.HasPrefix(source, "---\n") {
+		return "", strings.TrimSpace(source)
+	}
+	head, rest, ok := strings.Cut(source[len("---\n"):], "\n---")
+	if !ok {
+		return "", strings.TrimSpace(source)
+	}
+	for _, line := range strings.Split(head, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if ok && strings.TrimSpace(key) == "description" {
+			description = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return description, strings.TrimSpace(rest)
+}
+
+func expandPrompt(template, args string) string {
+	args = strings.TrimSpace(args)
+	if strings.Contains(template, "$ARGUMENTS") {
+		return strings.TrimSpace(strings.ReplaceAll(template, "$ARGUMENTS", args))
+	}
+	if args == "" {
+		return template
+	}
+	if template == "" {
+		return args
+	}
+	return template + "\n\n" + args
+}
@@ -0,0 +1,134 @@
+package gateway
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// Env is what a command can reach from any interface. Backend is nil when
+// the interface has no daemon connection.
+type Env struct {
+	Backend Backend
+	Now     time.Time
+}
+
+// Command is a slash command that runs the same way in every interface.
+type Command struct {
+	Name        string // including the leading slash
+	Usage       string // argument syntax shown in help, e.g. "[list] | approve <id>"
+	Description string
+	Group       string // help group key (see domain.CommandGroups)
+	// Subcommands are offered as completions for the first argument.
+	Subcommands []string
+	Run         func(env Env, args []string) Reply
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Command{}
+)
+
+func init() {
+	for _, c := range []Command{
+		{
+			Name:        "/schedule",
+			Usage:       "add <tool> <when> <json> | add-task <when> <prompt> | list | cancel <id>",
+			Description: "manage generic scheduled tool jobs",
+			Group:       "config",
+			Subcommands: []string{"add", "add-task", "list", "cancel"},
+			Run:         withBackend(func(env Env, args []string) Reply { return Schedule(env.Backend, args, env.Now) }),
+		},
+		{
+			Name:        "/drafts",
+			Usage:       "[list] | approve <id> | reject <id>",
+			Description: "review scheduled drafts: list, approve, reject",
+			Group:       "config",
+			Subcommands: []string{"approve", "list", "reject"},
+			Run:         withBackend(func(env Env, args []string) Reply { return Drafts(env.Backend, args) }),
+		},
+	} {
+		if err := Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// withBackend fails commands that need the daemon when there is none.
+func withBackend(run func(Env, []string) Reply) func(Env, []string) Reply {
+	return func(env Env, args []string) Reply {
+		if env.Backend == nil {
+			return failure("No daemon connection available.")
+		}
+		return run(env, args)
+	}
+}
+
+// Register adds a command to the registry. Names must start with "/",
+// contain no spaces, and not collide with a built-in or registered command.
+func Register(c Command) error {
+	c.Name = strings.ToLower(strings.TrimSpace(c.Name))
+	if !strings.HasPrefix(c.Name, "/") || len(c.Name) < 2 || strings.ContainsAny(c.Name, " \t") {
+		return fmt.Errorf("invalid command name %q", c.Name)
+	}
+	if c.Run == nil {
+		return fmt.Errorf("command %s has no handler", c.Name)
+	}
+	for _, def := range domain.CommandDefs {
+		if def.Name == c.Name {
+			return fmt.Errorf("command %s conflicts with a built-in command", c.Name)
+		}
+	}
+	if c.Group == "" {
+		c.Group = "plugins"
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[c.Name]; ok {
+		return fmt.Errorf("command %s is already registered", c.Name)
+	}
+	registry[c.Name] = c
+	return nil
+}
+
+// Unregister removes a command. It is a no-op for unknown names.
+func Unregister(name string) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	delete(registry, strings.ToLower(name))
+}
+
+// Lookup returns the registered command with the given name.
+func Lookup(name string) (Command, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[strings.ToLower(name)]
+	return c, ok
+}
+
+// Commands returns every registered command sorted by name.
+func Commands() []Command {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]Command, 0, len(registry))
+	for _, c := range registry {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Names returns the names of every registered command, sorted.
+func Names() []string {
+	cmds := Commands()
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.Name
+	}
+	return names
+}
@@ -0,0 +1,110 @@
+package gateway
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	run := func(Env, []string) Reply { return message("ok") }
+
+	if err := Register(Command{Name: "/Greet", Run: run}); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	t.Cleanup(func() { Unregister("/greet") })
+
+	c, ok := Lookup("/greet")
+	if !ok || c.Group != "plugins" {
+		t.Fatalf("Lookup = %+v, %v", c, ok)
+	}
+	if r := c.Run(Env{}, nil); r.Text() != "ok" {
+		t.Errorf("Run = %q", r.Text())
+	}
+
+	for _, bad := range []Command{
+		{Name: "/greet", Run: run},     // duplicate
+		{Name: "/help", Run: run},      // built-in TUI command
+		{Name: "/schedule", Run: run},  // built-in registry command
+		{Name: "greet2", Run: run},     // no slash
+		{Name: "/two words", Run: run}, // space
+		{Name: "/nohandler"},           // no Run
+	} {
+		if err := Register(bad); err == nil {
+			t.Errorf("Register(%q) should fail", bad.Name)
+			Unregister(bad.Name)
+		}
+	}
+}
+
+func TestBuiltinCommandsNeedBackend(t *testing.T) {
+	for _, name := range []string{"/schedule", "/drafts"} {
+		c, ok := Lookup(name)
+		if !ok {
+			t.Fatalf("%s is not registered", name)
+		}
+		if r := c.Run(Env{}, []string{"list"}); !r.IsError {
+			t.Errorf("%s without a backend = %q, want error", name, r.Text())
+		}
+	}
+	c, _ := Lookup("/drafts")
+	if r := c.Run(Env{Backend: &fakeBackend{}}, nil); r.Text() != "No drafts awaiting approval." {
+		t.Errorf("/drafts = %q", r.Text())
+	}
+}
+
+func TestLoadPromptCommands(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"review.md":  "---\ndescription: review a file\n---\nReview $ARGUMENTS for bugs.\n",
+		"explain.md": "Explain this code.",
+		"loop.md":    "/review again",
+		"notes.txt":  "ignored",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := LoadPromptCommands(dir); err != nil {
+		t.Fatalf("LoadPromptCommands: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, n := range []string{"/review", "/explain", "/loop"} {
+			Unregister(n)
+		}
+	})
+	if _, ok := Lookup("/notes"); ok {
+		t.Error("non-markdown files should be ignored")
+	}
+
+	review, _ := Lookup("/review")
+	if review.Description != "review a file" {
+		t.Errorf("description = %q", review.Description)
+	}
+	if r := review.Run(Env{}, []string{"main.go"}); r.Submit != "Review main.go for bugs." {
+		t.Errorf("review submit = %q", r.Submit)
+	}
+
+	explain, _ := Lookup("/explain")
+	if r := explain.Run(Env{}, nil); r.Submit != "Explain this code." {
+		t.Errorf("explain submit = %q", r.Submit)
+	}
+	if r := explain.Run(Env{}, []string{"slowly"}); r.Submit != "Explain this code.\n\nslowly" {
+		t.Errorf("explain with args = %q", r.Submit)
+	}
+
+	loop, _ := Lookup("/loop")
+	if r := loop.Run(Env{}, nil); !r.IsError || r.Submit != "" {
+		t.Errorf("loop = %+v, want error", r)
+	}
+
+	// Loading again reports the duplicates.
+	if err := LoadPromptCommands(dir); err == nil || !strings.Contains(err.Error(), "already registered") {
+		t.Errorf("reload err = %v", err)
+	}
+	if err := LoadPromptCommands(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("missing dir: %v", err)
+	}
+}
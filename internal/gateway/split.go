@@ -0,0 +1,58 @@
+package gateway
+
+import "strings"
+
+// fence opens and closes a Markdown code block.
+const fence = "```"
+
+// SplitMessage breaks text into parts of at most limit bytes for chat
+// services that cap message length, preferring line breaks, then spaces. A code block cut between parts is closed at
+// the end of one and reopened at the start of the next so both render.
+func SplitMessage(text string, limit int) []string {
+	if len(text) <= limit {
+		return []string{text}
+	}
+	// Leave room for a closing and reopening fence.
+	budget := limit - 2*(len(fence)+1)
+	var parts []string
+	inFence := false
+	for text != "" {
+		prefix := ""
+		if inFence {
+			prefix = fence + "\n"
+		}
+		if len(prefix)+len(text) <= limit {
+			parts = append(parts, prefix+text)
+			break
+		}
+		cut := splitPoint(text, budget)
+		chunk := strings.TrimRight(text[:cut], "\n")
+		text = strings.TrimLeft(text[cut:], "\n")
+		if strings.Count(chunk, fence)%2 == 1 {
+			inFence = !inFence
+		}
+		part := prefix + chunk
+		if inFence {
+			part += "\n" + fence
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// splitPoint returns where to cut text so the first part is at most n
+// bytes, never inside a UTF-8 sequence.
+func splitPoint(text string, n int) int {
+	if i := strings.LastIndexByte(text[:n], '\n'); i > n/2 {
+		return i + 1
+	}
+	if i := strings.LastIndexByte(text[:n], ' '); i > n/2 {
+		return i + 1
+	}
+	for n > 0 && !isRuneStart(text[n]) {
+		n--
+	}
+	return n
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
@@ -0,0 +1,47 @@
+package gateway
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitMessage(t *testing.T) {
+	if got := SplitMessage("short", 100); len(got) != 1 || got[0] != "short" {
+		t.Errorf("short = %q", got)
+	}
+
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, strings.Repeat("x", 9))
+	}
+	got := SplitMessage(strings.Join(lines, "\n"), 50)
+	for _, part := range got {
+		if len(part) > 50 {
+			t.Errorf("part longer than limit: %d", len(part))
+		}
+		if strings.HasPrefix(part, "\n") || strings.HasSuffix(part, "\n") {
+			t.Errorf("part not trimmed: %q", part)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(lines, "\n") {
+		t.Error("split lost text")
+	}
+
+	code := "intro\n```\n" + strings.Repeat("code line\n", 10) + "```\noutro"
+	got = SplitMessage(code, 60)
+	for i, part := range got {
+		if strings.Count(part, "```")%2 != 0 {
+			t.Errorf("part %d has an unbalanced fence: %q", i, part)
+		}
+		if len(part) > 60 {
+			t.Errorf("part %d longer than limit: %d", i, len(part))
+		}
+	}
+
+	// A word longer than the limit is cut, never inside a UTF-8 sequence.
+	for _, part := range SplitMessage(strings.Repeat("é", 40), 25) {
+		if !strings.HasPrefix(part, "é") || len(part) > 25 {
+			t.Errorf("bad cut: %q", part)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/redact"
+)
+
+// Mode controls what happens when screening finds something.
+type Mode string
+
+const (
+	// ModeOff disables screening.
+	ModeOff Mode = "off"
+	// ModeFlag lets content through with a warning.
+	ModeFlag Mode = "flag"
+	// ModeBlock withholds content that has any finding.
+	ModeBlock Mode = "block"
+)
+
+// ParseMode validates a guardrail mode. Empty means off.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return ModeOff, nil
+	case ModeOff, ModeFlag, ModeBlock:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid guardrail mode %q (use off, flag, or block)", s)
+	}
+}
+
+// Policy is the screening configuration for one interface.
+type Policy struct {
+	Mode        Mode
+	BannedTerms []string
+}
+
+// NewPolicy builds a policy from a mode and a comma-separated banned term
+// list. An invalid mode is treated as off.
+func NewPolicy(mode, bannedTerms string) Policy {
+	m, err := ParseMode(mode)
+	if err != nil {
+		m = ModeOff
+	}
+	var terms []string
+	for _, t := range strings.Split(bannedTerms, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	return Policy{Mode: m, BannedTerms: terms}
+}
+
+// Enabled reports whether the policy screens anything.
+func (p Policy) Enabled() bool {
+	return p.Mode == ModeFlag || p.Mode == ModeBlock
+}
+
+// Finding is one policy violation.
+type Finding struct {
+	Kind string // "secret", "email", "phone", "ssn", "card", or "banned"
+	Text string
+}
+
+// Result is the outcome of screening a piece of text.
+type Result struct {
+	Findings []Finding
+	Blocked  bool
+}
+
+// Flagged reports whether anything was found.
+func (r Result) Flagged() bool {
+	return len(r.Findings) > 0
+}
+
+// Summary describes the findings by kind without repeating the matched
+// text, e.g. "1 secret, 2 email".
+func (r Result) Summary() string {
+	counts := map[string]int{}
+	for _, f := range r.Findings {
+		counts[f.Kind]++
+	}
+	kinds := make([]string, 0, len(counts))
+	for k := range counts {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	parts := make([]string, len(kinds))
+	for i, k := range kinds {
+		parts[i] = fmt.Sprintf("%d %s", counts[k], k)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Screen checks text against the policy. With ModeOff it returns an empty
+// result.
+func (p Policy) Screen(text string) Result {
+	if !p.Enabled() || strings.TrimSpace(text) == "" {
+		return Result{}
+	}
+	var r Result
+	for _, m := range redact.Find(text) {
+		r.Findings = append(r.Findings, Finding{Kind: m.Kind, Text: m.Text})
+	}
+	for _, term := range p.BannedTerms {
+		re, err := regexp.Compile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`)
+		if err != nil {
+			continue
+		}
+		for _, m := range re.FindAllString(text, -1) {
+			r.Findings = append(r.Findings, Finding{Kind: "banned", Text: m})
+		}
+	}
+	r.Blocked = p.Mode == ModeBlock && r.Flagged()
+	return r
+}
+
+// ScreenFields screens the named string fields of a tool input.
+func (p Policy) ScreenFields(input map[string]any, fields []string) Result {
+	var parts []string
+	for _, k := range fields {
+		if s, ok := input[k].(string); ok {
+			parts = append(parts, s)
+		}
+	}
+	return p.Screen(strings.Join(parts, "\n"))
+}
@@ -0,0 +1,95 @@
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/redact"
+)
+
+// PIIMode controls how personal data in outbound messages is handled.
+type PIIMode string
+
+const (
+	// PIIOff sends messages unchanged.
+	PIIOff PIIMode = "off"
+	// PIIMask replaces personal data with placeholders before sending.
+	PIIMask PIIMode = "mask"
+	// PIIConfirm asks the user before sending a message with personal data.
+	PIIConfirm PIIMode = "confirm"
+)
+
+// ParsePIIMode validates a PII mode. Empty means off.
+func ParsePIIMode(s string) (PIIMode, error) {
+	switch m := PIIMode(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return PIIOff, nil
+	case PIIOff, PIIMask, PIIConfirm:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid PII mode %q (use off, mask, or confirm)", s)
+	}
+}
+
+// ParsePatterns compiles a semicolon-separated list of regular expressions,
+// e.g. `\bJane Doe\b;ACME-[0-9]+`.
+func ParsePatterns(s string) ([]*regexp.Regexp, error) {
+	var out []*regexp.Regexp
+	for _, p := range strings.Split(s, ";") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+		out = append(out, re)
+	}
+	return out, nil
+}
+
+// PIIPolicy is the personal data policy for outbound messages.
+type PIIPolicy struct {
+	Mode     PIIMode
+	Patterns []*regexp.Regexp
+}
+
+// NewPIIPolicy builds a policy from a mode and a pattern list. An invalid
+// mode is treated as off and invalid patterns are ignored.
+func NewPIIPolicy(mode, patterns string) PIIPolicy {
+	m, err := ParsePIIMode(mode)
+	if err != nil {
+		m = PIIOff
+	}
+	var res []*regexp.Regexp
+	for _, p := range strings.Split(patterns, ";") {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if re, err := regexp.Compile(p); err == nil {
+			res = append(res, re)
+		}
+	}
+	return PIIPolicy{Mode: m, Patterns: res}
+}
+
+// Enabled reports whether the policy inspects messages.
+func (p PIIPolicy) Enabled() bool {
+	return p.Mode == PIIMask || p.Mode == PIIConfirm
+}
+
+// Mask replaces personal data in s and returns the masked text and the
+// matches found.
+func (p PIIPolicy) Mask(s string) (string, []redact.Match) {
+	return redact.MaskPII(s, p.Patterns)
+}
+
+// SummarizeMatches describes matches by kind, e.g. "1 email, 2 phone".
+func SummarizeMatches(matches []redact.Match) string {
+	findings := make([]Finding, len(matches))
+	for i, m := range matches {
+		findings[i] = Finding{Kind: m.Kind, Text: m.Text}
+	}
+	return Result{Findings: findings}.Summary()
+}
@@ -0,0 +1,190 @@
+package hostinfo
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Host resources
+// ---------------------------------------------------------------------------
+
+// probeTimeout bounds the GPU query, so a hung driver does not stall
+// /stats or a hub heartbeat.
+const probeTimeout = 5 * time.Second
+
+// Resources is the memory available to local models on this host. Sizes
+// are bytes; zero means unknown.
+type Resources struct {
+	MemoryTotal     uint64 `json:"memory_total,omitempty"`
+	MemoryAvailable uint64 `json:"memory_available,omitempty"`
+	GPUs            []GPU  `json:"gpus,omitempty"`
+	// UnifiedMemory is set on Apple Silicon, where the GPU uses system
+	// memory rather than VRAM of its own.
+	UnifiedMemory bool `json:"unified_memory,omitempty"`
+}
+
+// GPU is one graphics card as reported by nvidia-smi.
+type GPU struct {
+	Name        string `json:"name"`
+	MemoryTotal uint64 `json:"memory_total"`
+	MemoryUsed  uint64 `json:"memory_used"`
+}
+
+// MemoryFree returns the GPU's unused VRAM.
+func (g GPU) MemoryFree() uint64 {
+	if g.MemoryUsed >= g.MemoryTotal {
+		return 0
+	}
+	return g.MemoryTotal - g.MemoryUsed
+}
+
+// queryGPUs runs nvidia-smi; tests replace it.
+var queryGPUs = func(ctx context.Context) ([]byte, error) {
+	return exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=name,memory.total,memory.used", "--format=csv,noheader,nounits").Output()
+}
+
+// Probe reads the host's RAM and, where nvidia-smi is installed, its
+// GPUs' VRAM. Values it cannot read are left zero.
+func Probe() Resources {
+	var r Resources
+	r.MemoryTotal, r.MemoryAvailable = memory()
+	r.UnifiedMemory = runtime.GOOS == "darwin" && runtime.GOARCH == "arm64"
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	if out, err := queryGPUs(ctx); err == nil {
+		r.GPUs = parseNvidiaSMI(string(out))
+	}
+	return r
+}
+
+// parseNvidiaSMI parses nvidia-smi CSV output: one "name, total, used"
+// line per GPU, sizes in MiB.
+func parseNvidiaSMI(out string) []GPU {
+	var gpus []GPU
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, ",")
+		if len(fields) != 3 {
+			continue
+		}
+		total, err1 := strconv.ParseUint(strings.TrimSpace(fields[1]), 10, 64)
+		used, err2 := strconv.ParseUint(strings.TrimSpace(fields[2]), 10, 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		gpus = append(gpus, GPU{Name: strings.TrimSpace(fields[0]), MemoryTotal: total << 20, MemoryUsed: used << 20})
+	}
+	return gpus
+}
+
+// parseMeminfo reads MemTotal and MemAvailable from Linux /proc/meminfo,
+// whose sizes are in kB.
+func parseMeminfo(r io.Reader) (total, available uint64) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, rest, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch key {
+		case "MemTotal":
+			total = n << 10
+		case "MemAvailable":
+			available = n << 10
+		}
+	}
+	return total, available
+}
+
+// Known reports whether anything was probed.
+func (r Resources) Known() bool {
+	return r.MemoryTotal > 0 || len(r.GPUs) > 0
+}
+
+// VRAMFree returns the unused VRAM across all GPUs.
+func (r Resources) VRAMFree() uint64 {
+	var free uint64
+	for _, g := range r.GPUs {
+		free += g.MemoryFree()
+	}
+	return free
+}
+
+// memoryFree returns the free system memory, or the total when the
+// available amount is unknown.
+func (r Resources) memoryFree() uint64 {
+	if r.MemoryAvailable > 0 {
+		return r.MemoryAvailable
+	}
+	return r.MemoryTotal
+}
+
+// Lines describes the resources for display, one line for system memory
+// and one per GPU.
+func (r Resources) Lines() []string {
+	var lines []string
+	if r.MemoryTotal > 0 {
+		line := "RAM: " + FormatSize(r.MemoryTotal)
+		if r.MemoryAvailable > 0 {
+			line = fmt.Sprintf("RAM: %s free of %s", FormatSize(r.MemoryAvailable), FormatSize(r.MemoryTotal))
+		}
+		if r.UnifiedMemory {
+			line += " (shared with the GPU)"
+		}
+		lines = append(lines, line)
+	}
+	for i, g := range r.GPUs {
+		lines = append(lines, fmt.Sprintf("GPU %d: %s, %s free of %s", i, g.Name, FormatSize(g.MemoryFree()), FormatSize(g.MemoryTotal)))
+	}
+	return lines
+}
+
+// modelOverhead is the share added to a model's size for its context
+// cache and runtime buffers when checking whether it fits.
+const modelOverhead = 0.2
+
+// ModelFit returns a warning when a model of the given size, in bytes,
+// will not fit in this host's free memory: either it cannot load at all,
+// or part of it has to run on the CPU. It returns "" when the model fits
+// or the resources are unknown.
+func (r Resources) ModelFit(size uint64) string {
+	if size == 0 || !r.Known() {
+		return ""
+	}
+	need := size + uint64(float64(size)*modelOverhead)
+	vram, ram := r.VRAMFree(), r.memoryFree()
+	if r.UnifiedMemory || len(r.GPUs) == 0 {
+		vram = 0
+	}
+	switch {
+	case need > vram+ram:
+		return fmt.Sprintf("needs about %s of memory, but only %s is free; it will likely fail to load", FormatSize(need), FormatSize(vram+ram))
+	case len(r.GPUs) > 0 && !r.UnifiedMemory && need > vram:
+		return fmt.Sprintf("needs about %s but only %s of VRAM is free; part of it will run on the CPU, which is much slower", FormatSize(need), FormatSize(vram))
+	}
+	return ""
+}
+
+// FormatSize renders a byte count in GB, or MB below one GB.
+func FormatSize(n uint64) string {
+	if n >= 1<<30 {
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	}
+	return fmt.Sprintf("%d MB", n>>20)
+}
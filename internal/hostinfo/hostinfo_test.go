@@ -0,0 +1,85 @@
+package hostinfo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const gb = 1 << 30
+
+func TestParseNvidiaSMI(t *testing.T) {
+	out := "NVIDIA GeForce RTX 4090, 24564, 1024\nNVIDIA A100-SXM4-80GB, 81920, 0\n\nnot, a, gpu\n"
+	gpus := parseNvidiaSMI(out)
+	if len(gpus) != 2 {
+		t.Fatalf("got %d GPUs, want 2: %+v", len(gpus), gpus)
+	}
+	if gpus[0].Name != "NVIDIA GeForce RTX 4090" || gpus[0].MemoryTotal != 24564<<20 || gpus[0].MemoryFree() != (24564-1024)<<20 {
+		t.Errorf("unexpected first GPU: %+v", gpus[0])
+	}
+}
+
+func TestParseMeminfo(t *testing.T) {
+	total, available := parseMeminfo(strings.NewReader("MemTotal:       32768000 kB\nMemFree:         1000000 kB\nMemAvailable:   16384000 kB\n"))
+	if total != 32768000<<10 || available != 16384000<<10 {
+		t.Errorf("parseMeminfo = %d, %d", total, available)
+	}
+}
+
+func TestProbe_gpus(t *testing.T) {
+	orig := queryGPUs
+	t.Cleanup(func() { queryGPUs = orig })
+
+	queryGPUs = func(context.Context) ([]byte, error) { return []byte("Test GPU, 8192, 2048\n"), nil }
+	if r := Probe(); len(r.GPUs) != 1 || r.GPUs[0].Name != "Test GPU" {
+		t.Errorf("GPUs = %+v", r.GPUs)
+	}
+	queryGPUs = func(context.Context) ([]byte, error) { return nil, errors.New("nvidia-smi: not found") }
+	if r := Probe(); len(r.GPUs) != 0 {
+		t.Errorf("expected no GPUs without nvidia-smi, got %+v", r.GPUs)
+	}
+}
+
+func TestResources_ModelFit(t *testing.T) {
+	gpu := Resources{MemoryTotal: 64 * gb, MemoryAvailable: 32 * gb, GPUs: []GPU{{Name: "gpu", MemoryTotal: 24 * gb, MemoryUsed: 4 * gb}}}
+	cpu := Resources{MemoryTotal: 16 * gb, MemoryAvailable: 8 * gb}
+	mac := Resources{MemoryTotal: 32 * gb, UnifiedMemory: true}
+
+	tests := []struct {
+		name string
+		r    Resources
+		size uint64
+		want string
+	}{
+		{"fits in VRAM", gpu, 8 * gb, ""},
+		{"spills to CPU", gpu, 20 * gb, "part of it will run on the CPU"},
+		{"too large for VRAM and RAM", gpu, 50 * gb, "fail to load"},
+		{"fits in RAM without a GPU", cpu, 4 * gb, ""},
+		{"too large for RAM", cpu, 8 * gb, "fail to load"},
+		{"unified memory uses RAM", mac, 20 * gb, ""},
+		{"unified memory too small", mac, 30 * gb, "fail to load"},
+		{"unknown resources", Resources{}, 100 * gb, ""},
+		{"unknown size", gpu, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.r.ModelFit(tt.size)
+			if tt.want == "" && got != "" || tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("ModelFit(%s) = %q, want %q", FormatSize(tt.size), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResources_Lines(t *testing.T) {
+	r := Resources{MemoryTotal: 32 * gb, MemoryAvailable: 16 * gb, GPUs: []GPU{{Name: "RTX", MemoryTotal: 8 * gb, MemoryUsed: 2 * gb}}}
+	got := strings.Join(r.Lines(), "\n")
+	want := "RAM: 16.0 GB free of 32.0 GB\nGPU 0: RTX, 6.0 GB free of 8.0 GB"
+	if got != want {
+		t.Errorf("Lines =\n%s\nwant\n%s", got, want)
+	}
+	if FormatSize(512<<20) != "512 MB" {
+		t.Errorf("FormatSize(512MB) = %q", FormatSize(512<<20))
+	}
+}
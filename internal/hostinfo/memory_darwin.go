@@ -0,0 +1,13 @@
+package hostinfo
+
+import "golang.org/x/sys/unix"
+
+// memory returns the installed memory. macOS has no single figure for
+// available memory, so it is left unknown.
+func memory() (total, available uint64) {
+	total, err := unix.SysctlUint64("hw.memsize")
+	if err != nil {
+		return 0, 0
+	}
+	return total, 0
+}
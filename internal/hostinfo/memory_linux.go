@@ -0,0 +1,12 @@
+package hostinfo
+
+import "os"
+
+func memory() (total, available uint64) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, 0
+	}
+	defer f.Close()
+	return parseMeminfo(f)
+}
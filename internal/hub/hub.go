@@ -7,16 +7,20 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/hostinfo"
+	"github.com/batalabs/muxd/internal/store"
 )
 
 // NodeStatus represents the health state of a registered node.
@@ -50,6 +54,29 @@ type Node struct {
 	Model    string   `json:"model,omitempty"`
 	Tools    []string `json:"tools,omitempty"`
 	MCPTools []string `json:"mcp_tools,omitempty"`
+
+	// Load reported in heartbeats. It is kept in memory only.
+	NodeLoad
+	// Resources is the node's memory and GPUs as of its last heartbeat,
+	// for choosing a node that can run a local model. Kept in memory only.
+	Resources *hostinfo.Resources `json:"resources,omitempty"`
+}
+
+// NodeLoad is a node's load as of its last heartbeat.
+type NodeLoad struct {
+	ActiveSessions int       `json:"active_sessions"` // sessions with a turn running
+	LoadedSessions int       `json:"loaded_sessions"` // sessions with an agent in memory
+	TokensUsed     int64     `json:"tokens_used"`     // tokens spent since the node started
+	StartedAt      time.Time `json:"started_at"`      // when the node's daemon started
+}
+
+// Uptime returns how long the node's daemon has been running, or 0 if it
+// has not reported a start time.
+func (l NodeLoad) Uptime(now time.Time) time.Duration {
+	if l.StartedAt.IsZero() {
+		return 0
+	}
+	return now.Sub(l.StartedAt)
 }
 
 // Hub is the central coordinator that tracks nodes, proxies requests,
@@ -59,6 +86,7 @@ type Hub struct {
 	token     string
 	prefs     *config.Preferences
 	mu        sync.RWMutex
+	regMu     sync.Mutex // serializes registrations so name conflicts resolve one at a time
 	nodes     map[string]*Node
 	version   string
 	logBroker *logBroker
@@ -68,6 +96,7 @@ type Hub struct {
 	ready     chan struct{}
 	done      chan struct{}
 	logger    *config.Logger
+	oidc      *oidcAuth // nil when sign-in is not configured
 }
 
 // NewHub creates a new Hub instance. Token resolution order:
@@ -102,6 +131,14 @@ func NewHub(db *sql.DB, prefs *config.Preferences, logger *config.Logger, explic
 		done:      make(chan struct{}),
 		logger:    logger,
 	}
+	if prefs != nil && prefs.HubOIDCEnabled() {
+		auth, err := newOIDCAuth(*prefs)
+		if err != nil {
+			h.logf("oidc sign-in disabled: %v", err)
+		} else {
+			h.oidc = auth
+		}
+	}
 	// Load existing nodes from the database into memory.
 	h.loadNodes()
 	return h
@@ -119,8 +156,9 @@ func (h *Hub) Port() int {
 // SetVersion sets the version string reported by the health endpoint.
 func (h *Hub) SetVersion(v string) { h.version = v }
 
-// SetBindAddress sets the network interface to bind to.
-func (h *Hub) SetBindAddress(addr string) { h.bindAddr = addr }
+// SetBindAddress sets the network interface to bind to. IPv6 literals may be
+// bracketed; "::" listens dual-stack.
+func (h *Hub) SetBindAddress(addr string) { h.bindAddr = daemon.NormalizeBindAddr(addr) }
 
 // Start begins serving the hub HTTP API on the given port.
 func (h *Hub) Start(port int) error {
@@ -129,16 +167,16 @@ func (h *Hub) Start(port int) error {
 		bindAddr = "localhost"
 	}
 
-	ln, err := net.Listen("tcp", fmt.Sprintf("%s:%d", bindAddr, port))
+	ln, err := net.Listen("tcp", daemon.HostPort(bindAddr, port))
 	if err != nil {
-		ln, err = net.Listen("tcp", fmt.Sprintf("%s:0", bindAddr))
+		ln, err = net.Listen("tcp", daemon.HostPort(bindAddr, 0))
 		if err != nil {
 			return fmt.Errorf("listening: %w", err)
 		}
 	}
 	h.port = ln.Addr().(*net.TCPAddr).Port
-	h.logf("hub starting on %s:%d", bindAddr, h.port)
-	fmt.Fprintf(os.Stderr, "muxd hub listening on %s:%d\n", bindAddr, h.port)
+	h.logf("hub starting on %s", daemon.HostPort(bindAddr, h.port))
+	fmt.Fprintf(os.Stderr, "muxd hub listening on %s\n", daemon.HostPort(bindAddr, h.port))
 	h.printConnectionQR(bindAddr)
 	close(h.ready)
 
@@ -178,12 +216,14 @@ func (h *Hub) Shutdown(ctx context.Context) error {
 
 // NodeCapabilities holds runtime information reported by a node.
 type NodeCapabilities struct {
-	Platform string
-	Arch     string
-	Provider string
-	Model    string
-	Tools    []string
-	MCPTools []string
+	Platform  string
+	Arch      string
+	Provider  string
+	Model     string
+	Tools     []string
+	MCPTools  []string
+	Load      *NodeLoad           // nil when the node did not report load
+	Resources *hostinfo.Resources // nil when the node did not report resources
 }
 
 func (c NodeCapabilities) applyTo(n *Node) {
@@ -205,34 +245,72 @@ func (c NodeCapabilities) applyTo(n *Node) {
 	if len(c.MCPTools) > 0 {
 		n.MCPTools = c.MCPTools
 	}
+	if c.Load != nil {
+		n.NodeLoad = *c.Load
+	}
+	if c.Resources != nil {
+		n.Resources = c.Resources
+	}
 }
 
+// registerNode registers a node under an ID the hub assigns.
 func (h *Hub) registerNode(name, host string, port int, token, version string, caps NodeCapabilities) (*Node, error) {
+	return h.registerNodeAs("", name, host, port, token, version, caps)
+}
+
+// errInvalidNodeID is returned for a registration whose node ID is not
+// 8-64 letters, digits, or dashes.
+var errInvalidNodeID = errors.New("invalid node id")
+
+var validNodeID = regexp.MustCompile(`^[A-Za-z0-9-]{8,64}$`)
+
+// NameConflictError is returned when a node registers under a name that
+// an online node with a different ID holds.
+type NameConflictError struct {
+	Name   string
+	Holder string // ID of the node holding the name
+}
+
+func (e *NameConflictError) Error() string {
+	return fmt.Sprintf("name %q is in use by online node %s", e.Name, e.Holder)
+}
+
+// registerNodeAs registers a node under the ID it chose, so it has the
+// same ID on every hub it registers with. Without an ID the hub assigns
+// one. Name conflicts resolve the same way on every hub: a node without an
+// ID replaces the node holding its name (a restart of an older node), and
+// a node with an ID takes the name only from a node that is offline; an
+// online holder keeps it and the registration fails with a
+// NameConflictError.
+func (h *Hub) registerNodeAs(id, name, host string, port int, token, version string, caps NodeCapabilities) (*Node, error) {
+	h.regMu.Lock()
+	defer h.regMu.Unlock()
 	now := time.Now().UTC()
 
-	// Check for an existing node with the same name -replace it instead of
-	// creating a duplicate. This handles daemon restarts cleanly.
-	if existingID := h.findNodeByName(name); existingID != "" {
-		h.mu.Lock()
-		if n, ok := h.nodes[existingID]; ok {
-			n.Host = host
-			n.Port = port
-			n.Token = token
-			n.Version = version
-			n.Status = StatusOnline
-			n.LastSeenAt = now
-			caps.applyTo(n)
+	if id == "" {
+		// Check for an existing node with the same name -replace it instead of
+		// creating a duplicate. This handles daemon restarts cleanly.
+		if existingID := h.findNodeByName(name); existingID != "" {
+			return h.updateNode(existingID, name, host, port, token, version, caps, now), nil
+		}
+		id = generateNodeID()
+	} else {
+		if !validNodeID.MatchString(id) {
+			return nil, errInvalidNodeID
+		}
+		if holderID := h.findNodeByName(name); holderID != "" && holderID != id {
+			if holder := h.getNode(holderID); holder != nil && holder.Status == StatusOnline {
+				return nil, &NameConflictError{Name: name, Holder: holderID}
+			}
+			if err := h.deregisterNode(holderID); err != nil {
+				return nil, err
+			}
+		}
+		if h.getNode(id) != nil {
+			return h.updateNode(id, name, host, port, token, version, caps, now), nil
 		}
-		h.mu.Unlock()
-		h.db.Exec(
-			`UPDATE nodes SET host = ?, port = ?, token = ?, version = ?, status = ?, last_seen_at = ? WHERE id = ?`,
-			host, port, token, version, string(StatusOnline), now.Format(time.RFC3339), existingID,
-		)
-		h.logf("node re-registered: %s (%s:%d)", existingID, host, port)
-		return h.getNode(existingID), nil
 	}
 
-	id := generateNodeID()
 	node := &Node{
 		ID:           id,
 		Name:         name,
@@ -266,6 +344,28 @@ func (h *Hub) registerNode(name, host string, port int, token, version string, c
 	return node, nil
 }
 
+// updateNode refreshes a registered node from a new registration.
+func (h *Hub) updateNode(id, name, host string, port int, token, version string, caps NodeCapabilities, now time.Time) *Node {
+	h.mu.Lock()
+	if n, ok := h.nodes[id]; ok {
+		n.Name = name
+		n.Host = host
+		n.Port = port
+		n.Token = token
+		n.Version = version
+		n.Status = StatusOnline
+		n.LastSeenAt = now
+		caps.applyTo(n)
+	}
+	h.mu.Unlock()
+	h.db.Exec(
+		`UPDATE nodes SET name = ?, host = ?, port = ?, token = ?, version = ?, status = ?, last_seen_at = ? WHERE id = ?`,
+		name, host, port, token, version, string(StatusOnline), now.Format(time.RFC3339), id,
+	)
+	h.logf("node re-registered: %s (%s:%d)", id, host, port)
+	return h.getNode(id)
+}
+
 func (h *Hub) findNodeByName(name string) string {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -403,14 +503,11 @@ func (h *Hub) loadNodes() {
 // Auth middleware
 // ---------------------------------------------------------------------------
 
+// withAuth admits only the hub token. Node registration and log ingest
+// use it; routes people use go through withRole.
 func (h *Hub) withAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		got := strings.TrimSpace(r.Header.Get("Authorization"))
-		const bearer = "Bearer "
-		if strings.HasPrefix(got, bearer) {
-			got = strings.TrimSpace(strings.TrimPrefix(got, bearer))
-		}
-		if got == "" || h.token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) != 1 {
+		if !h.isHubToken(bearerToken(r)) {
 			writeHubJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
 			return
 		}
@@ -418,6 +515,73 @@ func (h *Hub) withAuth(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// roleKey is the context key for the role a request is authorized as.
+type roleKey struct{}
+
+// userSessionKey is the context key for the signed-in session, if any.
+type userSessionKey struct{}
+
+// withRole admits the hub token, which may do anything, and signed-in
+// sessions whose role allows required.
+func (h *Hub) withRole(required string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret := requestCredential(r)
+		if h.isHubToken(secret) {
+			next(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, store.TokenScopeAdmin)))
+			return
+		}
+		var sess *userSession
+		if h.oidc != nil && secret != "" {
+			var err error
+			if sess, err = h.lookupUserSession(secret, time.Now()); err != nil {
+				h.logf("looking up session: %v", err)
+			}
+		}
+		if sess == nil {
+			writeHubJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if !store.ScopeAllows(sess.Role, required) {
+			writeHubJSON(w, http.StatusForbidden, map[string]string{"error": fmt.Sprintf("the %s role cannot do this (needs %s)", sess.Role, required)})
+			return
+		}
+		ctx := context.WithValue(r.Context(), roleKey{}, sess.Role)
+		next(w, r.WithContext(context.WithValue(ctx, userSessionKey{}, sess)))
+	}
+}
+
+// requestRole returns the role withRole authorized the request as.
+func requestRole(r *http.Request) string {
+	role, _ := r.Context().Value(roleKey{}).(string)
+	return role
+}
+
+func (h *Hub) isHubToken(got string) bool {
+	return got != "" && h.token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(h.token)) == 1
+}
+
+// bearerToken returns the request's Authorization token.
+func bearerToken(r *http.Request) string {
+	got := strings.TrimSpace(r.Header.Get("Authorization"))
+	const bearer = "Bearer "
+	if strings.HasPrefix(got, bearer) {
+		got = strings.TrimSpace(strings.TrimPrefix(got, bearer))
+	}
+	return got
+}
+
+// requestCredential returns the bearer token, or the session cookie a
+// browser sign-in set.
+func requestCredential(r *http.Request) string {
+	if got := bearerToken(r); got != "" {
+		return got
+	}
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
 // ---------------------------------------------------------------------------
 // Helpers
 // ---------------------------------------------------------------------------
@@ -425,7 +589,7 @@ func (h *Hub) withAuth(next http.HandlerFunc) http.HandlerFunc {
 func (h *Hub) printConnectionQR(bindAddr string) {
 	// Determine which host to encode -use LAN IP when bound to all interfaces.
 	host := bindAddr
-	if host == "0.0.0.0" || host == "" {
+	if daemon.IsWildcardAddr(host) {
 		if ips := daemon.GetLocalIPs(); len(ips) > 0 {
 			host = ips[0]
 		}
@@ -438,7 +602,7 @@ func (h *Hub) printConnectionQR(bindAddr string) {
 	}
 
 	fmt.Fprintf(os.Stderr, "\nScan to connect:\n%s\n", ascii)
-	fmt.Fprintf(os.Stderr, "  hub:   %s:%d\n", host, h.port)
+	fmt.Fprintf(os.Stderr, "  hub:   %s\n", daemon.HostPort(host, h.port))
 	fmt.Fprintf(os.Stderr, "  token: %s\n", h.token)
 	if ips := daemon.GetLocalIPs(); len(ips) > 1 {
 		fmt.Fprintf(os.Stderr, "  also available on:")
@@ -449,7 +613,7 @@ func (h *Hub) printConnectionQR(bindAddr string) {
 		}
 		fmt.Fprintf(os.Stderr, "\n")
 	}
-	fmt.Fprintf(os.Stderr, "\n  connect: muxd --remote %s:%d --token %s\n\n", host, h.port, h.token)
+	fmt.Fprintf(os.Stderr, "\n  connect: muxd --remote %s --token %s\n\n", daemon.HostPort(host, h.port), h.token)
 }
 
 func (h *Hub) logf(format string, args ...any) {
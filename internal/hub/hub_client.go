@@ -1,10 +1,17 @@
 package hub
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 	"time"
+
+	"github.com/batalabs/muxd/internal/config"
 )
 
 const hubClientTimeout = 10 * time.Second
@@ -53,3 +60,162 @@ func (c *HubClient) ListNodes() ([]*Node, error) {
 	}
 	return nodes, nil
 }
+
+// ---------------------------------------------------------------------------
+// Sign-in
+// ---------------------------------------------------------------------------
+
+// StartDeviceLogin asks the hub to start a device sign-in.
+func (c *HubClient) StartDeviceLogin() (*DeviceLogin, error) {
+	var dl DeviceLogin
+	status, err := c.postJSON("/auth/device", struct{}{}, &dl)
+	if err != nil {
+		return nil, fmt.Errorf("starting sign-in: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("starting sign-in: HTTP %d", status)
+	}
+	return &dl, nil
+}
+
+// PollDeviceLogin checks on a device sign-in once. It returns nil and the
+// hub's status ("authorization_pending" or "slow_down") while the person
+// has not finished.
+func (c *HubClient) PollDeviceLogin(deviceCode string) (*SignIn, string, error) {
+	var resp struct {
+		SignIn
+		Status string `json:"status"`
+		Error  string `json:"error"`
+	}
+	status, err := c.postJSON("/auth/device/token", map[string]string{"device_code": deviceCode}, &resp)
+	if err != nil {
+		return nil, "", fmt.Errorf("signing in: %w", err)
+	}
+	switch status {
+	case http.StatusOK:
+		return &resp.SignIn, "", nil
+	case http.StatusAccepted:
+		return nil, resp.Status, nil
+	}
+	if resp.Error != "" {
+		return nil, "", fmt.Errorf("signing in: %s", resp.Error)
+	}
+	return nil, "", fmt.Errorf("signing in: HTTP %d", status)
+}
+
+// DeviceSignIn runs a device sign-in: it writes where to go and the code
+// to enter to out, then polls until the person finishes, the code expires,
+// or ctx is done.
+func (c *HubClient) DeviceSignIn(ctx context.Context, out io.Writer) (*SignIn, error) {
+	dl, err := c.StartDeviceLogin()
+	if err != nil {
+		return nil, err
+	}
+	if dl.VerificationURIComplete != "" {
+		fmt.Fprintf(out, "To sign in, open %s\nand check the code matches %s\n", dl.VerificationURIComplete, dl.UserCode)
+	} else {
+		fmt.Fprintf(out, "To sign in, open %s\nand enter the code %s\n", dl.VerificationURI, dl.UserCode)
+	}
+	interval := time.Duration(max(dl.Interval, 5)) * time.Second
+	deadline := time.Now().Add(time.Duration(dl.ExpiresIn) * time.Second)
+	for dl.ExpiresIn <= 0 || time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		signIn, status, err := c.PollDeviceLogin(dl.DeviceCode)
+		if err != nil {
+			return nil, err
+		}
+		if signIn != nil {
+			return signIn, nil
+		}
+		if status == "slow_down" {
+			interval += 5 * time.Second
+		}
+	}
+	return nil, fmt.Errorf("signing in: the code expired")
+}
+
+func (c *HubClient) postJSON(path string, body, out any) (int, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && resp.StatusCode < 300 {
+		return resp.StatusCode, fmt.Errorf("parsing response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// ---------------------------------------------------------------------------
+// Saved sign-ins
+// ---------------------------------------------------------------------------
+
+const signInsFileName = "hub_signins.json"
+
+// SavedSignIn returns the unexpired sign-in saved for the hub at baseURL,
+// or nil.
+func SavedSignIn(baseURL string) *SignIn {
+	dir, err := config.DataDir()
+	if err != nil {
+		return nil
+	}
+	return loadSignIn(filepath.Join(dir, signInsFileName), baseURL, time.Now())
+}
+
+// SaveSignIn saves the sign-in for the hub at baseURL so the next
+// muxd --remote reuses it.
+func SaveSignIn(baseURL string, s *SignIn) error {
+	dir, err := config.DataDir()
+	if err != nil {
+		return fmt.Errorf("data dir: %w", err)
+	}
+	return saveSignIn(filepath.Join(dir, signInsFileName), baseURL, s, time.Now())
+}
+
+func readSignIns(path string) map[string]*SignIn {
+	signIns := map[string]*SignIn{}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &signIns)
+	}
+	return signIns
+}
+
+func loadSignIn(path, baseURL string, now time.Time) *SignIn {
+	s := readSignIns(path)[baseURL]
+	if s == nil || s.Token == "" || !now.Before(s.ExpiresAt) {
+		return nil
+	}
+	return s
+}
+
+// saveSignIn stores s under baseURL and drops expired sign-ins.
+func saveSignIn(path, baseURL string, s *SignIn, now time.Time) error {
+	signIns := readSignIns(path)
+	for url, old := range signIns {
+		if old == nil || !now.Before(old.ExpiresAt) {
+			delete(signIns, url)
+		}
+	}
+	signIns[baseURL] = s
+	data, err := json.MarshalIndent(signIns, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("saving sign-in: %w", err)
+	}
+	return nil
+}
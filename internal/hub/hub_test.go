@@ -2,15 +2,25 @@ package hub
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/egress"
 	_ "modernc.org/sqlite"
 )
 
@@ -188,6 +198,87 @@ func TestNodeClient_PushMemory(t *testing.T) {
 	}
 }
 
+func TestNodeClient_egressAllowlist(t *testing.T) {
+	egress.SetDefault(egress.NewPolicy(egress.ModeAllowlist, []string{"hub.example.com"}))
+	defer egress.SetDefault(nil)
+
+	c := NewNodeClient("https://blocked.example.net", "hub-tok", "node-tok")
+	_, err := c.FetchMemory()
+	var blocked *egress.BlockedError
+	if !errors.As(err, &blocked) {
+		t.Errorf("FetchMemory err = %v, want blocked by the egress policy", err)
+	}
+	if _, err := c.proxySubmit("node-1", "sess-1", "hi"); !errors.As(err, &blocked) {
+		t.Errorf("proxySubmit err = %v, want blocked by the egress policy", err)
+	}
+}
+
+func TestNodeClients_FetchMemory(t *testing.T) {
+	serve := func(facts map[string]string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(facts)
+		}))
+	}
+	first := serve(map[string]string{"stack": "Go"})
+	defer first.Close()
+	second := serve(map[string]string{"stack": "Rust", "db": "SQLite"})
+	defer second.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	cs := NewNodeClients([]string{down.URL, first.URL, second.URL}, "hub-tok", "node-tok", "node-id-1")
+	got, err := cs.FetchMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["stack"] != "Go" {
+		t.Errorf("expected first hub to win, got stack=%q", got["stack"])
+	}
+	if got["db"] != "SQLite" {
+		t.Errorf("expected facts merged from second hub, got %v", got)
+	}
+
+	if _, err := NewNodeClients([]string{down.URL}, "hub-tok", "node-tok", "node-id-1").FetchMemory(); err == nil {
+		t.Error("expected error when no hub answers")
+	}
+}
+
+func TestNodeClients_ListNodes_failover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]NodeListEntry{{ID: "node-id-1", Name: "alpha"}})
+	}))
+	defer up.Close()
+
+	cs := NewNodeClients([]string{down.URL, up.URL}, "hub-tok", "node-tok", "")
+	nodes, err := cs.ListNodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Name != "alpha" {
+		t.Errorf("unexpected nodes: %+v", nodes)
+	}
+}
+
+func TestLoadNodeID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), nodeIDFileName)
+	id, err := loadNodeID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !validNodeID.MatchString(id) {
+		t.Fatalf("generated id %q is not valid", id)
+	}
+	again, err := loadNodeID(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != id {
+		t.Errorf("expected stable id %q, got %q", id, again)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Node Registration & Lifecycle
 // ---------------------------------------------------------------------------
@@ -251,6 +342,88 @@ func TestHub_RegisterNode_reregister(t *testing.T) {
 	}
 }
 
+func TestHub_RegisterNodeAs(t *testing.T) {
+	t.Run("same id updates the node", func(t *testing.T) {
+		h := newTestHub(t)
+		if _, err := h.registerNodeAs("node-id-1", "alpha", "127.0.0.1", 9000, "tok", "0.1.0", NodeCapabilities{}); err != nil {
+			t.Fatalf("first register: %v", err)
+		}
+		node, err := h.registerNodeAs("node-id-1", "beta", "127.0.0.1", 9001, "tok", "0.2.0", NodeCapabilities{})
+		if err != nil {
+			t.Fatalf("second register: %v", err)
+		}
+		if node.ID != "node-id-1" || node.Name != "beta" || node.Port != 9001 {
+			t.Errorf("unexpected node after update: %+v", node)
+		}
+		if n := len(h.listNodes()); n != 1 {
+			t.Errorf("expected 1 node, got %d", n)
+		}
+	})
+
+	t.Run("online name holder keeps the name", func(t *testing.T) {
+		h := newTestHub(t)
+		if _, err := h.registerNodeAs("node-id-1", "alpha", "127.0.0.1", 9000, "tok", "0.1.0", NodeCapabilities{}); err != nil {
+			t.Fatalf("first register: %v", err)
+		}
+		_, err := h.registerNodeAs("node-id-2", "alpha", "127.0.0.2", 9000, "tok", "0.1.0", NodeCapabilities{})
+		var conflict *NameConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("expected NameConflictError, got %v", err)
+		}
+		if conflict.Holder != "node-id-1" {
+			t.Errorf("expected holder node-id-1, got %s", conflict.Holder)
+		}
+	})
+
+	t.Run("offline name holder is replaced", func(t *testing.T) {
+		h := newTestHub(t)
+		if _, err := h.registerNodeAs("node-id-1", "alpha", "127.0.0.1", 9000, "tok", "0.1.0", NodeCapabilities{}); err != nil {
+			t.Fatalf("first register: %v", err)
+		}
+		h.mu.Lock()
+		h.nodes["node-id-1"].Status = StatusOffline
+		h.mu.Unlock()
+
+		node, err := h.registerNodeAs("node-id-2", "alpha", "127.0.0.2", 9000, "tok", "0.1.0", NodeCapabilities{})
+		if err != nil {
+			t.Fatalf("second register: %v", err)
+		}
+		if node.ID != "node-id-2" {
+			t.Errorf("expected node-id-2, got %s", node.ID)
+		}
+		if h.getNode("node-id-1") != nil {
+			t.Error("expected offline holder to be removed")
+		}
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		h := newTestHub(t)
+		for _, id := range []string{"short", "has space in it", "../../etc/passwd"} {
+			if _, err := h.registerNodeAs(id, "alpha", "127.0.0.1", 9000, "tok", "0.1.0", NodeCapabilities{}); !errors.Is(err, errInvalidNodeID) {
+				t.Errorf("id %q: expected errInvalidNodeID, got %v", id, err)
+			}
+		}
+	})
+}
+
+func TestHub_HandleRegisterNode_nameConflict(t *testing.T) {
+	h := newTestHub(t)
+	if _, err := h.registerNodeAs("node-id-1", "alpha", "127.0.0.1", 9000, "tok", "0.1.0", NodeCapabilities{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	mux := newTestMux(h)
+	body := `{"id":"node-id-2","name":"alpha","host":"127.0.0.2","port":9000,"token":"tok","version":"0.1.0"}`
+	req := httptest.NewRequest("POST", "/api/hub/nodes/register", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestHub_DeregisterNode(t *testing.T) {
 	h := newTestHub(t)
 
@@ -645,6 +818,67 @@ func TestHub_HandleHeartbeat_unknownNode(t *testing.T) {
 	}
 }
 
+func TestHub_HandleHeartbeat_load(t *testing.T) {
+	h := newTestHub(t)
+	node, err := h.registerNode("loaded", "127.0.0.1", 8003, "tok", "0.1.0", NodeCapabilities{})
+	if err != nil {
+		t.Fatalf("registerNode: %v", err)
+	}
+	mux := newTestMux(h)
+
+	heartbeat := func(body string) {
+		t.Helper()
+		var r *http.Request
+		if body == "" {
+			r = httptest.NewRequest("POST", "/api/hub/nodes/"+node.ID+"/heartbeat", nil)
+		} else {
+			r = httptest.NewRequest("POST", "/api/hub/nodes/"+node.ID+"/heartbeat", strings.NewReader(body))
+		}
+		r.Header.Set("Authorization", "Bearer test-token")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("heartbeat: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	started := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	heartbeat(fmt.Sprintf(`{"model":"claude-sonnet","load":{"active_sessions":2,"loaded_sessions":5,"tokens_used":12345,"started_at":%q}}`,
+		started.Format(time.RFC3339)))
+	// A heartbeat without a body keeps the last reported load.
+	heartbeat("")
+
+	req := httptest.NewRequest("GET", "/api/hub/nodes", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var nodes []Node
+	if err := json.NewDecoder(w.Body).Decode(&nodes); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(nodes) != 1 {
+		t.Fatalf("expected 1 node, got %d", len(nodes))
+	}
+	got := nodes[0]
+	if got.ActiveSessions != 2 || got.LoadedSessions != 5 || got.TokensUsed != 12345 || got.Model != "claude-sonnet" {
+		t.Errorf("unexpected load: %+v", got)
+	}
+	if !got.StartedAt.Equal(started) {
+		t.Errorf("StartedAt = %v, want %v", got.StartedAt, started)
+	}
+	if up := got.Uptime(started.Add(time.Hour)); up != time.Hour {
+		t.Errorf("Uptime = %v, want 1h", up)
+	}
+	if got.Resources != nil {
+		t.Errorf("expected no resources before the node reports them, got %+v", got.Resources)
+	}
+
+	heartbeat(`{"resources":{"memory_total":68719476736,"gpus":[{"name":"RTX 4090","memory_total":25769803776,"memory_used":0}]}}`)
+	if n := h.getNode(node.ID); n.Resources == nil || len(n.Resources.GPUs) != 1 || n.Resources.GPUs[0].Name != "RTX 4090" {
+		t.Errorf("unexpected resources: %+v", n.Resources)
+	}
+}
+
 func TestNodeClient_Heartbeat_404_returnsNodePurged(t *testing.T) {
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		writeHubJSON(w, http.StatusNotFound, map[string]string{"error": "node not found"})
@@ -1074,3 +1308,361 @@ func TestNodeClient_Dispatch(t *testing.T) {
 		}
 	})
 }
+
+// ---------------------------------------------------------------------------
+// OIDC sign-in
+// ---------------------------------------------------------------------------
+
+// fakeIdP is an identity provider that signs in whoever claims holds,
+// answering the device flow with authorization_pending pending times first.
+type fakeIdP struct {
+	*httptest.Server
+	claims  map[string]any
+	pending int
+	nonce   string // nonce from the last authorization request
+	verify  string // PKCE challenge from the last authorization request
+}
+
+func newFakeIdP(t *testing.T, claims map[string]any) *fakeIdP {
+	t.Helper()
+	idp := &fakeIdP{claims: claims}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcMetadata{
+			Issuer:                      idp.URL,
+			AuthorizationEndpoint:       idp.URL + "/authorize",
+			TokenEndpoint:               idp.URL + "/token",
+			DeviceAuthorizationEndpoint: idp.URL + "/device",
+		})
+	})
+	mux.HandleFunc("POST /device", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(DeviceLogin{DeviceCode: "dev-1", UserCode: "ABCD-EFGH", VerificationURI: idp.URL + "/activate", ExpiresIn: 600, Interval: 1})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		claims := map[string]any{"iss": idp.URL, "aud": "muxd", "exp": time.Now().Add(time.Hour).Unix()}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+			if r.Form.Get("code") != "code-1" || base64.RawURLEncoding.EncodeToString(sum[:]) != idp.verify {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+				return
+			}
+			claims["nonce"] = idp.nonce
+		case deviceGrantType:
+			if idp.pending > 0 {
+				idp.pending--
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+				return
+			}
+		}
+		payload, _ := json.Marshal(claims)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+func newOIDCTestHub(t *testing.T, idp *fakeIdP, roles string) *Hub {
+	t.Helper()
+	h := newTestHub(t)
+	auth, err := newOIDCAuth(config.Preferences{HubOIDCIssuer: idp.URL, HubOIDCClientID: "muxd", HubOIDCRoles: roles})
+	if err != nil {
+		t.Fatalf("newOIDCAuth: %v", err)
+	}
+	h.oidc = auth
+	return h
+}
+
+func TestNewOIDCAuth(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		prefs config.Preferences
+		ok    bool
+	}{
+		{"https issuer", config.Preferences{HubOIDCIssuer: "https://id.example.com", HubOIDCRoles: "*=read"}, true},
+		{"loopback http issuer", config.Preferences{HubOIDCIssuer: "http://127.0.0.1:9000", HubOIDCRoles: "*=read"}, true},
+		{"remote http issuer", config.Preferences{HubOIDCIssuer: "http://id.example.com", HubOIDCRoles: "*=read"}, false},
+		{"no roles", config.Preferences{HubOIDCIssuer: "https://id.example.com"}, false},
+		{"bad role", config.Preferences{HubOIDCIssuer: "https://id.example.com", HubOIDCRoles: "*=owner"}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := newOIDCAuth(tc.prefs)
+			if (err == nil) != tc.ok {
+				t.Errorf("newOIDCAuth error = %v, want ok=%v", err, tc.ok)
+			}
+		})
+	}
+}
+
+func TestOIDC_RoleFor(t *testing.T) {
+	rules, err := config.ParseOIDCRoles("@example.com=read, group:eng=submit, ops@example.com=admin, u-42=admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &oidcAuth{rules: rules}
+	for _, tc := range []struct {
+		name string
+		id   identity
+		want string
+	}{
+		{"domain", identity{Subject: "s", Email: "ann@Example.com", emailVerified: true}, "read"},
+		{"highest rule wins", identity{Subject: "s", Email: "ann@example.com", Groups: []string{"eng"}, emailVerified: true}, "submit"},
+		{"exact email", identity{Subject: "s", Email: "ops@example.com", emailVerified: true}, "admin"},
+		{"unverified email", identity{Subject: "s", Email: "ops@example.com"}, ""},
+		{"subject", identity{Subject: "u-42"}, "admin"},
+		{"other domain", identity{Subject: "s", Email: "ann@example.com.evil.org", emailVerified: true}, ""},
+		{"no match", identity{Subject: "s"}, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := o.roleFor(tc.id); got != tc.want {
+				t.Errorf("roleFor = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestOIDC_ParseIDToken(t *testing.T) {
+	o := &oidcAuth{issuer: "https://id.example.com", clientID: "muxd", groupsClaim: "groups"}
+	now := time.Unix(1_700_000_000, 0)
+	token := func(edit func(map[string]any)) string {
+		claims := map[string]any{
+			"iss": "https://id.example.com", "aud": []string{"muxd", "other"}, "exp": now.Add(time.Hour).Unix(),
+			"sub": "u-1", "email": "ann@example.com", "email_verified": false, "nonce": "n-1", "groups": []string{"eng"},
+		}
+		edit(claims)
+		payload, _ := json.Marshal(claims)
+		return "e30." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+	}
+
+	id, err := o.parseIDToken(token(func(map[string]any) {}), "n-1", now)
+	if err != nil {
+		t.Fatalf("parseIDToken: %v", err)
+	}
+	if id.Subject != "u-1" || id.Email != "ann@example.com" || id.emailVerified || len(id.Groups) != 1 {
+		t.Errorf("identity = %+v", id)
+	}
+
+	for name, edit := range map[string]func(map[string]any){
+		"issuer":   func(c map[string]any) { c["iss"] = "https://evil.example.com" },
+		"audience": func(c map[string]any) { c["aud"] = "other" },
+		"expired":  func(c map[string]any) { c["exp"] = now.Add(-time.Hour).Unix() },
+		"nonce":    func(c map[string]any) { c["nonce"] = "n-2" },
+		"subject":  func(c map[string]any) { delete(c, "sub") },
+	} {
+		if _, err := o.parseIDToken(token(edit), "n-1", now); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := o.parseIDToken("not-a-jwt", "", now); err == nil {
+		t.Error("malformed token: expected an error")
+	}
+}
+
+func TestHub_WithRole(t *testing.T) {
+	idp := newFakeIdP(t, nil)
+	h := newOIDCTestHub(t, idp, "*=read")
+	secret, _, err := h.createUserSession(identity{Subject: "u-1"}, "read", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _, err := h.createUserSession(identity{Subject: "u-2"}, "admin", time.Now().Add(-2*userSessionTTL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newTestMux(h)
+
+	for _, tc := range []struct {
+		name, method, path string
+		auth               func(*http.Request)
+		want               int
+	}{
+		{"hub token", "PUT", "/api/hub/memory", func(r *http.Request) { r.Header.Set("Authorization", "Bearer test-token") }, http.StatusOK},
+		{"no credential", "GET", "/api/hub/nodes", func(*http.Request) {}, http.StatusUnauthorized},
+		{"read session reads", "GET", "/api/hub/nodes", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+secret) }, http.StatusOK},
+		{"read session cookie", "GET", "/api/hub/nodes", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: sessionCookieName, Value: secret}) }, http.StatusOK},
+		{"read session writes", "PUT", "/api/hub/memory", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+secret) }, http.StatusForbidden},
+		{"expired session", "GET", "/api/hub/nodes", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+expired) }, http.StatusUnauthorized},
+		{"session cannot register", "POST", "/api/hub/nodes/register", func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+secret) }, http.StatusUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{"facts":{"a":"b"}}`))
+			tc.auth(req)
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tc.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestHub_OIDCBrowserSignIn(t *testing.T) {
+	idp := newFakeIdP(t, map[string]any{"sub": "u-1", "email": "ann@example.com", "email_verified": true})
+	h := newOIDCTestHub(t, idp, "@example.com=submit")
+	mux := newTestMux(h)
+
+	req := httptest.NewRequest("GET", "http://hub.local/auth/login", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("login status = %d, want 302", w.Code)
+	}
+	loc, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), idp.URL+"/authorize?") {
+		t.Fatalf("login redirect = %q", w.Header().Get("Location"))
+	}
+	q := loc.Query()
+	if q.Get("redirect_uri") != "http://hub.local/auth/callback" || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("authorization request = %v", q)
+	}
+	idp.nonce, idp.verify = q.Get("nonce"), q.Get("code_challenge")
+
+	req = httptest.NewRequest("GET", "/auth/callback?code=code-1&state="+url.QueryEscape(q.Get("state")), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback status = %d: %s", w.Code, w.Body.String())
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookieName || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %v", cookies)
+	}
+	if !strings.Contains(w.Body.String(), cookies[0].Value) || !strings.Contains(w.Body.String(), "ann@example.com") {
+		t.Errorf("sign-in page does not show the identity and token: %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/auth/me", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	var me map[string]any
+	json.NewDecoder(w.Body).Decode(&me)
+	if w.Code != http.StatusOK || me["role"] != "submit" || me["email"] != "ann@example.com" {
+		t.Errorf("/auth/me = %d %v", w.Code, me)
+	}
+
+	// The state is single use.
+	req = httptest.NewRequest("GET", "/auth/callback?code=code-1&state="+url.QueryEscape(q.Get("state")), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("replayed callback status = %d, want 400", w.Code)
+	}
+}
+
+func TestHub_OIDCDeviceSignIn(t *testing.T) {
+	idp := newFakeIdP(t, map[string]any{"sub": "u-1", "groups": []string{"eng"}})
+	h := newOIDCTestHub(t, idp, "group:eng=read")
+	srv := httptest.NewServer(newTestMux(h))
+	defer srv.Close()
+	idp.pending = 1
+
+	c := NewHubClient(srv.URL, "")
+	dl, err := c.StartDeviceLogin()
+	if err != nil {
+		t.Fatalf("StartDeviceLogin: %v", err)
+	}
+	if dl.UserCode != "ABCD-EFGH" || dl.DeviceCode != "dev-1" {
+		t.Errorf("device login = %+v", dl)
+	}
+	signIn, status, err := c.PollDeviceLogin(dl.DeviceCode)
+	if err != nil || signIn != nil || status != "authorization_pending" {
+		t.Fatalf("first poll = %v, %q, %v; want pending", signIn, status, err)
+	}
+	signIn, _, err = c.PollDeviceLogin(dl.DeviceCode)
+	if err != nil || signIn == nil {
+		t.Fatalf("second poll = %v, %v", signIn, err)
+	}
+	if signIn.Role != "read" || signIn.Subject != "u-1" || signIn.Token == "" {
+		t.Errorf("sign-in = %+v", signIn)
+	}
+	if _, err := NewHubClient(srv.URL, signIn.Token).ListNodes(); err != nil {
+		t.Errorf("ListNodes with the session token: %v", err)
+	}
+
+	// Someone hub.oidc.roles does not name is refused.
+	idp.claims = map[string]any{"sub": "u-2"}
+	if _, _, err := c.PollDeviceLogin(dl.DeviceCode); err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("poll for an unlisted user = %v, want not allowed", err)
+	}
+}
+
+func TestHub_OIDCNotConfigured(t *testing.T) {
+	mux := newTestMux(newTestHub(t))
+	req := httptest.NewRequest("POST", "/auth/device", nil)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestHub_ProxyNarrowsScope(t *testing.T) {
+	var gotScope, gotAuth, gotCookie string
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotScope, gotAuth, gotCookie = r.Header.Get(daemon.ScopeHeader), r.Header.Get("Authorization"), r.Header.Get("Cookie")
+	}))
+	defer node.Close()
+	nodeURL, _ := url.Parse(node.URL)
+	port, _ := strconv.Atoi(nodeURL.Port())
+
+	idp := newFakeIdP(t, nil)
+	h := newOIDCTestHub(t, idp, "*=read")
+	n, err := h.registerNode("n1", nodeURL.Hostname(), port, "node-token", "dev", NodeCapabilities{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := h.createUserSession(identity{Subject: "u-1"}, "submit", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := newTestMux(h)
+
+	req := httptest.NewRequest("GET", "/api/hub/proxy/"+n.ID+"/api/sessions", nil)
+	req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: secret})
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	if gotScope != "submit" || gotAuth != "Bearer node-token" || gotCookie != "" {
+		t.Errorf("session proxy: scope=%q auth=%q cookie=%q", gotScope, gotAuth, gotCookie)
+	}
+
+	req = httptest.NewRequest("GET", "/api/hub/proxy/"+n.ID+"/api/sessions", nil)
+	req.Header.Set("Authorization", "Bearer test-token")
+	req.Header.Set(daemon.ScopeHeader, "read")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+	if gotScope != "" {
+		t.Errorf("hub token proxy: scope=%q, want none", gotScope)
+	}
+}
+
+func TestSaveSignIn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), signInsFileName)
+	now := time.Now()
+	if s := loadSignIn(path, "http://a", now); s != nil {
+		t.Errorf("missing file: got %+v", s)
+	}
+	if err := saveSignIn(path, "http://a", &SignIn{Token: "tok-a", ExpiresAt: now.Add(time.Hour)}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSignIn(path, "http://b", &SignIn{Token: "tok-b", ExpiresAt: now.Add(time.Minute)}, now); err != nil {
+		t.Fatal(err)
+	}
+	if s := loadSignIn(path, "http://a", now); s == nil || s.Token != "tok-a" {
+		t.Errorf("hub a: got %+v", s)
+	}
+	if s := loadSignIn(path, "http://b", now.Add(2*time.Minute)); s != nil {
+		t.Errorf("expired sign-in: got %+v", s)
+	}
+	if s := loadSignIn(path, "http://c", now); s != nil {
+		t.Errorf("other hub: got %+v", s)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("sign-in file mode = %v, %v; want 0600", info.Mode().Perm(), err)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/hostinfo"
 )
 
 const nodeClientTimeout = 10 * time.Second
@@ -19,6 +21,7 @@ type NodeClient struct {
 	baseURL   string
 	hubToken  string
 	nodeToken string
+	nodeID    string // stable ID sent on registration; empty lets the hub assign one
 	client    *http.Client
 }
 
@@ -28,23 +31,38 @@ func NewNodeClient(hubURL, hubToken, nodeToken string) *NodeClient {
 		baseURL:   hubURL,
 		hubToken:  hubToken,
 		nodeToken: nodeToken,
-		client:    &http.Client{Timeout: nodeClientTimeout},
+		client:    &http.Client{Timeout: nodeClientTimeout, Transport: egress.Transport(nil)},
 	}
 }
 
+// SetNodeID sets the ID this node registers under. A node that registers
+// with several hubs uses the same ID on each, so clients can reach it
+// through any of them. See LocalNodeID.
+func (c *NodeClient) SetNodeID(id string) {
+	c.nodeID = id
+}
+
+// URL returns the hub's base URL.
+func (c *NodeClient) URL() string {
+	return c.baseURL
+}
+
 // NodeInfo holds runtime capabilities sent during registration and heartbeats.
 type NodeInfo struct {
-	Platform string   `json:"platform,omitempty"`
-	Arch     string   `json:"arch,omitempty"`
-	Provider string   `json:"provider,omitempty"`
-	Model    string   `json:"model,omitempty"`
-	Tools    []string `json:"tools,omitempty"`
-	MCPTools []string `json:"mcp_tools,omitempty"`
+	Platform  string              `json:"platform,omitempty"`
+	Arch      string              `json:"arch,omitempty"`
+	Provider  string              `json:"provider,omitempty"`
+	Model     string              `json:"model,omitempty"`
+	Tools     []string            `json:"tools,omitempty"`
+	MCPTools  []string            `json:"mcp_tools,omitempty"`
+	Load      *NodeLoad           `json:"load,omitempty"`
+	Resources *hostinfo.Resources `json:"resources,omitempty"`
 }
 
 // Register registers this node with the hub. Returns the assigned node ID.
 func (c *NodeClient) Register(name, host string, port int, version string, info ...NodeInfo) (string, error) {
 	regReq := registerRequest{
+		ID:      c.nodeID,
 		Name:    name,
 		Host:    host,
 		Port:    port,
@@ -58,6 +76,8 @@ func (c *NodeClient) Register(name, host string, port int, version string, info
 		regReq.Model = info[0].Model
 		regReq.Tools = info[0].Tools
 		regReq.MCPTools = info[0].MCPTools
+		regReq.Load = info[0].Load
+		regReq.Resources = info[0].Resources
 	}
 	body, err := json.Marshal(regReq)
 	if err != nil {
@@ -94,16 +114,17 @@ func (c *NodeClient) Register(name, host string, port int, version string, info
 
 // NodeListEntry is a node returned by the hub's list-nodes API.
 type NodeListEntry struct {
-	ID       string   `json:"id"`
-	Name     string   `json:"name"`
-	Version  string   `json:"version"`
-	Status   string   `json:"status"`
-	Platform string   `json:"platform"`
-	Arch     string   `json:"arch"`
-	Provider string   `json:"provider"`
-	Model    string   `json:"model"`
-	Tools    []string `json:"tools"`
-	MCPTools []string `json:"mcp_tools"`
+	ID        string              `json:"id"`
+	Name      string              `json:"name"`
+	Version   string              `json:"version"`
+	Status    string              `json:"status"`
+	Platform  string              `json:"platform"`
+	Arch      string              `json:"arch"`
+	Provider  string              `json:"provider"`
+	Model     string              `json:"model"`
+	Tools     []string            `json:"tools"`
+	MCPTools  []string            `json:"mcp_tools"`
+	Resources *hostinfo.Resources `json:"resources,omitempty"`
 }
 
 // ListNodes fetches the list of all nodes registered with the hub.
@@ -341,7 +362,7 @@ func (c *NodeClient) proxySubmit(nodeID, sessionID, prompt string) (string, erro
 	req.Header.Set("Authorization", "Bearer "+c.hubToken)
 
 	// No timeout — agent loops can take a long time.
-	client := &http.Client{}
+	client := &http.Client{Transport: egress.Transport(nil)}
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
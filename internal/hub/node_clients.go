@@ -0,0 +1,128 @@
+package hub
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+// ---------------------------------------------------------------------------
+// Multiple hubs
+// ---------------------------------------------------------------------------
+
+// NodeClients is the hubs a node registers with, in the order hub.url and
+// hub.urls list them. Reads go to the first hub that answers and writes go
+// to every hub, so the fleet stays reachable while any one hub is up.
+type NodeClients []*NodeClient
+
+// NewNodeClients creates a client per hub URL, each registering under
+// nodeID.
+func NewNodeClients(hubURLs []string, hubToken, nodeToken, nodeID string) NodeClients {
+	clients := make(NodeClients, len(hubURLs))
+	for i, u := range hubURLs {
+		clients[i] = NewNodeClient(u, hubToken, nodeToken)
+		clients[i].SetNodeID(nodeID)
+	}
+	return clients
+}
+
+// ListNodes lists the nodes registered with the first hub that answers.
+func (cs NodeClients) ListNodes() ([]NodeListEntry, error) {
+	var errs []error
+	for _, c := range cs {
+		nodes, err := c.ListNodes()
+		if err == nil {
+			return nodes, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", c.URL(), err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// FetchMemory merges the shared memory of every hub that answers. When
+// hubs disagree on a fact, the one listed first wins, so every node
+// resolves the conflict the same way. It fails only when no hub answers.
+func (cs NodeClients) FetchMemory() (map[string]string, error) {
+	var errs []error
+	merged := make(map[string]string)
+	for i := len(cs) - 1; i >= 0; i-- {
+		facts, err := cs[i].FetchMemory()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cs[i].URL(), err))
+			continue
+		}
+		for k, v := range facts {
+			merged[k] = v
+		}
+	}
+	if len(errs) == len(cs) && len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return merged, nil
+}
+
+// PushMemory sends facts to every hub. It reports the hubs that could not
+// be reached; the rest are updated regardless.
+func (cs NodeClients) PushMemory(facts map[string]string) error {
+	var errs []error
+	for _, c := range cs {
+		if err := c.PushMemory(facts); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.URL(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Dispatch runs prompt on a node through the first hub that answers. A
+// dispatch that fails after it reached a hub is not retried on another,
+// since the node may already be running it.
+func (cs NodeClients) Dispatch(nodeIDOrName, prompt string) (string, error) {
+	var errs []error
+	for _, c := range cs {
+		if _, err := c.ListNodes(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.URL(), err))
+			continue
+		}
+		return c.Dispatch(nodeIDOrName, prompt)
+	}
+	return "", errors.Join(errs...)
+}
+
+// ---------------------------------------------------------------------------
+// Node identity
+// ---------------------------------------------------------------------------
+
+const nodeIDFileName = "node_id"
+
+// LocalNodeID returns this machine's node ID, creating it in the data dir
+// on first use.
+func LocalNodeID() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", fmt.Errorf("data dir: %w", err)
+	}
+	return loadNodeID(filepath.Join(dir, nodeIDFileName))
+}
+
+func loadNodeID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if id := strings.TrimSpace(string(data)); validNodeID.MatchString(id) {
+			return id, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", fmt.Errorf("reading node id: %w", err)
+	}
+	id := generateNodeID()
+	if id == "" {
+		return "", errors.New("generating node id")
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("saving node id: %w", err)
+	}
+	return id, nil
+}
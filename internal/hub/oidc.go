@@ -0,0 +1,676 @@
+package hub
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// ---------------------------------------------------------------------------
+// OIDC sign-in
+// ---------------------------------------------------------------------------
+
+const (
+	// userSessionTTL is how long a sign-in lasts before the person signs
+	// in again.
+	userSessionTTL = 24 * time.Hour
+	// loginTimeout bounds the time between starting a browser sign-in and
+	// the identity provider redirecting back.
+	loginTimeout = 10 * time.Minute
+
+	sessionCookieName = "muxd_hub_session"
+	oidcScopes        = "openid email profile"
+	deviceGrantType   = "urn:ietf:params:oauth:grant-type:device_code"
+)
+
+// oidcAuth signs people in to the hub through an OIDC identity provider
+// and maps who they are to a role. Nodes keep using the hub token.
+type oidcAuth struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	publicURL    string // base of the redirect URI; empty uses the request
+	groupsClaim  string
+	rules        []config.OIDCRoleRule
+	client       *http.Client
+
+	mu      sync.Mutex
+	meta    *oidcMetadata           // fetched on first use
+	pending map[string]pendingLogin // browser sign-ins by state
+}
+
+// oidcMetadata is the part of the identity provider's discovery document
+// the hub uses.
+type oidcMetadata struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+}
+
+type pendingLogin struct {
+	verifier    string // PKCE code verifier
+	nonce       string
+	redirectURI string
+	started     time.Time
+}
+
+// identity is who the identity provider says signed in.
+type identity struct {
+	Subject       string   `json:"subject"`
+	Email         string   `json:"email,omitempty"`
+	Name          string   `json:"name,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+	emailVerified bool
+}
+
+// label is how the identity is shown: the email when there is one.
+func (id identity) label() string {
+	if id.Email != "" {
+		return id.Email
+	}
+	if id.Name != "" {
+		return id.Name
+	}
+	return id.Subject
+}
+
+// newOIDCAuth builds the sign-in configuration from the hub.oidc.* keys.
+// The issuer must use https, except on a loopback address.
+func newOIDCAuth(p config.Preferences) (*oidcAuth, error) {
+	issuer := strings.TrimRight(p.HubOIDCIssuer, "/")
+	u, err := url.Parse(issuer)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid hub.oidc.issuer %q", p.HubOIDCIssuer)
+	}
+	if u.Scheme != "https" && !(u.Scheme == "http" && isLoopbackHost(u.Hostname())) {
+		return nil, fmt.Errorf("hub.oidc.issuer %q must use https", p.HubOIDCIssuer)
+	}
+	rules, err := config.ParseOIDCRoles(p.HubOIDCRoles)
+	if err != nil {
+		return nil, fmt.Errorf("hub.oidc.roles: %w", err)
+	}
+	if len(rules) == 0 {
+		return nil, errors.New("hub.oidc.roles grants no one a role")
+	}
+	groupsClaim := p.HubOIDCGroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+	return &oidcAuth{
+		issuer:       issuer,
+		clientID:     p.HubOIDCClientID,
+		clientSecret: p.HubOIDCClientSecret,
+		publicURL:    strings.TrimRight(p.HubPublicURL, "/"),
+		groupsClaim:  groupsClaim,
+		rules:        rules,
+		client:       &http.Client{Timeout: 15 * time.Second, Transport: egress.Transport(nil)},
+		pending:      make(map[string]pendingLogin),
+	}, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// metadata returns the identity provider's discovery document, fetching it
+// on first use.
+func (o *oidcAuth) metadata() (*oidcMetadata, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.meta != nil {
+		return o.meta, nil
+	}
+	resp, err := o.client.Get(o.issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: HTTP %d", resp.StatusCode)
+	}
+	var meta oidcMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return nil, fmt.Errorf("oidc discovery: decode: %w", err)
+	}
+	if strings.TrimRight(meta.Issuer, "/") != o.issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer %q does not match hub.oidc.issuer", meta.Issuer)
+	}
+	if meta.TokenEndpoint == "" {
+		return nil, errors.New("oidc discovery: no token endpoint")
+	}
+	o.meta = &meta
+	return o.meta, nil
+}
+
+// redirectURI is where the identity provider sends the browser back to.
+func (o *oidcAuth) redirectURI(r *http.Request) string {
+	base := o.publicURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/auth/callback"
+}
+
+// tokenResponse is the identity provider's token endpoint reply.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (t tokenResponse) err(status int) error {
+	msg := t.ErrorDescription
+	if msg == "" {
+		msg = t.Error
+	}
+	if msg == "" {
+		msg = "no ID token"
+	}
+	return fmt.Errorf("oidc token: HTTP %d: %s", status, msg)
+}
+
+// postForm sends form to an identity provider endpoint with the hub's
+// client credentials and decodes the JSON reply into out.
+func (o *oidcAuth) postForm(endpoint string, form url.Values, out any) (int, error) {
+	form.Set("client_id", o.clientID)
+	if o.clientSecret != "" {
+		form.Set("client_secret", o.clientSecret)
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); err != nil {
+		return resp.StatusCode, fmt.Errorf("HTTP %d: decoding response: %w", resp.StatusCode, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// parseIDToken checks the ID token's issuer, audience, expiry, and nonce
+// and returns who it names. The signature is not checked: the token comes
+// straight from the token endpoint over TLS, which OIDC Core 3.1.3.7
+// allows in place of it.
+func (o *oidcAuth) parseIDToken(raw, nonce string, now time.Time) (identity, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return identity{}, errors.New("malformed ID token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return identity{}, fmt.Errorf("malformed ID token: %w", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return identity{}, fmt.Errorf("malformed ID token: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != o.issuer {
+		return identity{}, fmt.Errorf("ID token issuer %q is not hub.oidc.issuer", iss)
+	}
+	if !slices.Contains(stringsClaim(claims["aud"]), o.clientID) {
+		return identity{}, errors.New("ID token is not for this hub's client ID")
+	}
+	if exp, _ := claims["exp"].(float64); now.After(time.Unix(int64(exp), 0).Add(time.Minute)) {
+		return identity{}, errors.New("ID token has expired")
+	}
+	if got, _ := claims["nonce"].(string); nonce != "" && got != nonce {
+		return identity{}, errors.New("ID token nonce does not match the sign-in")
+	}
+	id := identity{Groups: stringsClaim(claims[o.groupsClaim]), emailVerified: true}
+	id.Subject, _ = claims["sub"].(string)
+	id.Email, _ = claims["email"].(string)
+	id.Name, _ = claims["name"].(string)
+	if id.Name == "" {
+		id.Name, _ = claims["preferred_username"].(string)
+	}
+	// Providers that never verify emails leave the claim out.
+	if v, ok := claims["email_verified"].(bool); ok {
+		id.emailVerified = v
+	}
+	if id.Subject == "" {
+		return identity{}, errors.New("ID token has no subject")
+	}
+	return id, nil
+}
+
+// stringsClaim reads a claim that is a string or a list of strings.
+func stringsClaim(v any) []string {
+	switch v := v.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		var out []string
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// roleFor returns the highest role hub.oidc.roles grants id, or "" when it
+// grants none. Email rules only match verified emails.
+func (o *oidcAuth) roleFor(id identity) string {
+	best := -1
+	for _, rule := range o.rules {
+		var match bool
+		switch m := rule.Match; {
+		case m == "*":
+			match = true
+		case strings.HasPrefix(m, "group:"):
+			match = slices.Contains(id.Groups, strings.TrimPrefix(m, "group:"))
+		case strings.HasPrefix(m, "@"):
+			match = id.emailVerified && strings.HasSuffix(strings.ToLower(id.Email), strings.ToLower(m))
+		case strings.Contains(m, "@"):
+			match = id.emailVerified && strings.EqualFold(id.Email, m)
+		default:
+			match = m == id.Subject
+		}
+		if rank := slices.Index(config.HubRoles, rule.Role); match && rank > best {
+			best = rank
+		}
+	}
+	if best < 0 {
+		return ""
+	}
+	return config.HubRoles[best]
+}
+
+// ---------------------------------------------------------------------------
+// Signed-in sessions
+// ---------------------------------------------------------------------------
+
+// userSession is a person's sign-in. Its bearer token is stored hashed.
+type userSession struct {
+	identity
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func hashSessionToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// createUserSession signs id in with role and returns the session's bearer
+// token. Expired sessions are removed on the way.
+func (h *Hub) createUserSession(id identity, role string, now time.Time) (string, *userSession, error) {
+	secret := generateHubToken()
+	if secret == "" {
+		return "", nil, errors.New("generating session token")
+	}
+	sess := &userSession{identity: id, Role: role, ExpiresAt: now.Add(userSessionTTL).UTC().Truncate(time.Second)}
+	if _, err := h.db.Exec(`DELETE FROM user_sessions WHERE expires_at < ?`, now.UTC().Format(time.RFC3339)); err != nil {
+		h.logf("pruning user sessions: %v", err)
+	}
+	_, err := h.db.Exec(
+		`INSERT INTO user_sessions (token_hash, subject, email, name, role, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		hashSessionToken(secret), id.Subject, id.Email, id.Name, role,
+		now.UTC().Format(time.RFC3339), sess.ExpiresAt.Format(time.RFC3339),
+	)
+	if err != nil {
+		return "", nil, fmt.Errorf("saving session: %w", err)
+	}
+	return secret, sess, nil
+}
+
+// lookupUserSession returns the unexpired session whose token is secret,
+// or nil if there is none.
+func (h *Hub) lookupUserSession(secret string, now time.Time) (*userSession, error) {
+	var sess userSession
+	var expires string
+	err := h.db.QueryRow(`SELECT subject, email, name, role, expires_at FROM user_sessions WHERE token_hash = ?`, hashSessionToken(secret)).
+		Scan(&sess.Subject, &sess.Email, &sess.Name, &sess.Role, &expires)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sess.ExpiresAt, err = time.Parse(time.RFC3339, expires)
+	if err != nil || !now.Before(sess.ExpiresAt) {
+		return nil, nil
+	}
+	return &sess, nil
+}
+
+// deleteUserSession signs the session whose token is secret out.
+func (h *Hub) deleteUserSession(secret string) error {
+	_, err := h.db.Exec(`DELETE FROM user_sessions WHERE token_hash = ?`, hashSessionToken(secret))
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Sign-in routes
+// ---------------------------------------------------------------------------
+
+func (h *Hub) requireOIDC(w http.ResponseWriter) bool {
+	if h.oidc == nil {
+		writeHubJSON(w, http.StatusNotFound, map[string]string{"error": "sign-in is not configured on this hub (set hub.oidc.issuer)"})
+		return false
+	}
+	return true
+}
+
+// handleLogin starts a browser sign-in: it redirects to the identity
+// provider with a PKCE challenge.
+func (h *Hub) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOIDC(w) {
+		return
+	}
+	meta, err := h.oidc.metadata()
+	if err != nil {
+		h.logf("login: %v", err)
+		writeHubJSON(w, http.StatusBadGateway, map[string]string{"error": "identity provider unavailable"})
+		return
+	}
+	state, nonce, verifier := generateHubToken(), generateHubToken(), generateHubToken()
+	if state == "" || nonce == "" || verifier == "" {
+		writeHubJSON(w, http.StatusInternalServerError, map[string]string{"error": "generating sign-in state"})
+		return
+	}
+	redirectURI := h.oidc.redirectURI(r)
+	now := time.Now()
+	h.oidc.mu.Lock()
+	for k, p := range h.oidc.pending {
+		if now.Sub(p.started) > loginTimeout {
+			delete(h.oidc.pending, k)
+		}
+	}
+	h.oidc.pending[state] = pendingLogin{verifier: verifier, nonce: nonce, redirectURI: redirectURI, started: now}
+	h.oidc.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {h.oidc.clientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {oidcScopes},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	http.Redirect(w, r, meta.AuthorizationEndpoint+sep+q.Encode(), http.StatusFound)
+}
+
+// handleCallback finishes a browser sign-in: it exchanges the code for an
+// ID token, signs the person in, and shows the token for muxd --remote.
+func (h *Hub) handleCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOIDC(w) {
+		return
+	}
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		renderSignInPage(w, http.StatusUnauthorized, signInPage{Error: "The identity provider refused the sign-in: " + firstNonEmpty(q.Get("error_description"), e)})
+		return
+	}
+	h.oidc.mu.Lock()
+	pending, ok := h.oidc.pending[q.Get("state")]
+	delete(h.oidc.pending, q.Get("state"))
+	h.oidc.mu.Unlock()
+	if !ok || time.Since(pending.started) > loginTimeout {
+		renderSignInPage(w, http.StatusBadRequest, signInPage{Error: "This sign-in has expired. Start again at /auth/login."})
+		return
+	}
+	meta, err := h.oidc.metadata()
+	if err != nil {
+		h.logf("login callback: %v", err)
+		renderSignInPage(w, http.StatusBadGateway, signInPage{Error: "The identity provider is unavailable."})
+		return
+	}
+	var tok tokenResponse
+	status, err := h.oidc.postForm(meta.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {q.Get("code")},
+		"redirect_uri":  {pending.redirectURI},
+		"code_verifier": {pending.verifier},
+	}, &tok)
+	if err == nil && (status != http.StatusOK || tok.IDToken == "") {
+		err = tok.err(status)
+	}
+	if err != nil {
+		h.logf("login callback: %v", err)
+		renderSignInPage(w, http.StatusBadGateway, signInPage{Error: "The identity provider did not complete the sign-in."})
+		return
+	}
+	secret, sess, code, err := h.signIn(tok.IDToken, pending.nonce)
+	if err != nil {
+		renderSignInPage(w, code, signInPage{Error: err.Error()})
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    secret,
+		Path:     "/",
+		Expires:  sess.ExpiresAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(pending.redirectURI, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	renderSignInPage(w, http.StatusOK, signInPage{Who: sess.label(), Role: sess.Role, Token: secret, Expires: sess.ExpiresAt.Local().Format(time.RFC1123)})
+}
+
+// signIn checks an ID token and starts a session for the person it names.
+// On failure it returns the HTTP status to answer with.
+func (h *Hub) signIn(idToken, nonce string) (string, *userSession, int, error) {
+	id, err := h.oidc.parseIDToken(idToken, nonce, time.Now())
+	if err != nil {
+		h.logf("sign-in: %v", err)
+		return "", nil, http.StatusUnauthorized, errors.New("the identity provider's answer could not be verified")
+	}
+	role := h.oidc.roleFor(id)
+	if role == "" {
+		h.logf("sign-in refused for %s: no role in hub.oidc.roles", id.label())
+		return "", nil, http.StatusForbidden, fmt.Errorf("%s is not allowed on this hub", id.label())
+	}
+	secret, sess, err := h.createUserSession(id, role, time.Now())
+	if err != nil {
+		h.logf("sign-in: %v", err)
+		return "", nil, http.StatusInternalServerError, errors.New("the session could not be saved")
+	}
+	h.logf("%s signed in as %s", id.label(), role)
+	return secret, sess, http.StatusOK, nil
+}
+
+// DeviceLogin is a device sign-in in progress: the person opens
+// VerificationURI and enters UserCode while the client polls with
+// DeviceCode.
+type DeviceLogin struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// handleDeviceLogin starts a device-code sign-in for a terminal client,
+// on the hub's behalf so clients need no client credentials.
+func (h *Hub) handleDeviceLogin(w http.ResponseWriter, _ *http.Request) {
+	if !h.requireOIDC(w) {
+		return
+	}
+	meta, err := h.oidc.metadata()
+	if err != nil {
+		h.logf("device login: %v", err)
+		writeHubJSON(w, http.StatusBadGateway, map[string]string{"error": "identity provider unavailable"})
+		return
+	}
+	if meta.DeviceAuthorizationEndpoint == "" {
+		writeHubJSON(w, http.StatusNotImplemented, map[string]string{"error": "the identity provider does not offer device sign-in; sign in at /auth/login and pass the token with --token"})
+		return
+	}
+	var resp struct {
+		DeviceLogin
+		VerificationURL string `json:"verification_url"` // Google's name for verification_uri
+		Error           string `json:"error"`
+		ErrorDesc       string `json:"error_description"`
+	}
+	status, err := h.oidc.postForm(meta.DeviceAuthorizationEndpoint, url.Values{"scope": {oidcScopes}}, &resp)
+	if err == nil && (status != http.StatusOK || resp.DeviceCode == "") {
+		err = fmt.Errorf("HTTP %d: %s", status, firstNonEmpty(resp.ErrorDesc, resp.Error, "no device code"))
+	}
+	if err != nil {
+		h.logf("device login: %v", err)
+		writeHubJSON(w, http.StatusBadGateway, map[string]string{"error": "the identity provider did not start the sign-in"})
+		return
+	}
+	if resp.VerificationURI == "" {
+		resp.VerificationURI = resp.VerificationURL
+	}
+	writeHubJSON(w, http.StatusOK, resp.DeviceLogin)
+}
+
+// SignIn is a finished sign-in to a hub: the bearer token and what it
+// grants.
+type SignIn struct {
+	Token     string    `json:"token"`
+	Role      string    `json:"role"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	Name      string    `json:"name,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// handleDeviceToken polls a device sign-in. While the person has not
+// finished it answers 202 with the provider's status, authorization_pending
+// or slow_down.
+func (h *Hub) handleDeviceToken(w http.ResponseWriter, r *http.Request) {
+	if !h.requireOIDC(w) {
+		return
+	}
+	var req struct {
+		DeviceCode string `json:"device_code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DeviceCode == "" {
+		writeHubJSON(w, http.StatusBadRequest, map[string]string{"error": "device_code is required"})
+		return
+	}
+	meta, err := h.oidc.metadata()
+	if err != nil {
+		h.logf("device token: %v", err)
+		writeHubJSON(w, http.StatusBadGateway, map[string]string{"error": "identity provider unavailable"})
+		return
+	}
+	var tok tokenResponse
+	status, err := h.oidc.postForm(meta.TokenEndpoint, url.Values{
+		"grant_type":  {deviceGrantType},
+		"device_code": {req.DeviceCode},
+	}, &tok)
+	if err != nil {
+		h.logf("device token: %v", err)
+		writeHubJSON(w, http.StatusBadGateway, map[string]string{"error": "identity provider unavailable"})
+		return
+	}
+	switch {
+	case tok.Error == "authorization_pending" || tok.Error == "slow_down":
+		writeHubJSON(w, http.StatusAccepted, map[string]string{"status": tok.Error})
+		return
+	case status != http.StatusOK || tok.IDToken == "":
+		writeHubJSON(w, http.StatusUnauthorized, map[string]string{"error": tok.err(status).Error()})
+		return
+	}
+	secret, sess, code, err := h.signIn(tok.IDToken, "")
+	if err != nil {
+		writeHubJSON(w, code, map[string]string{"error": err.Error()})
+		return
+	}
+	writeHubJSON(w, http.StatusOK, SignIn{
+		Token: secret, Role: sess.Role, Subject: sess.Subject, Email: sess.Email, Name: sess.Name, ExpiresAt: sess.ExpiresAt,
+	})
+}
+
+// handleLogout ends the session the request carries.
+func (h *Hub) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if secret := requestCredential(r); secret != "" {
+		if err := h.deleteUserSession(secret); err != nil {
+			h.logf("logout: %v", err)
+		}
+	}
+	http.SetCookie(w, &http.Cookie{Name: sessionCookieName, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	writeHubJSON(w, http.StatusOK, map[string]string{"status": "signed out"})
+}
+
+// handleWhoAmI reports who the request is authorized as.
+func (h *Hub) handleWhoAmI(w http.ResponseWriter, r *http.Request) {
+	if sess, _ := r.Context().Value(userSessionKey{}).(*userSession); sess != nil {
+		writeHubJSON(w, http.StatusOK, sess)
+		return
+	}
+	writeHubJSON(w, http.StatusOK, map[string]string{"subject": "hub token", "role": store.TokenScopeAdmin})
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// signInPage is what the browser sees at the end of a sign-in.
+type signInPage struct {
+	Who, Role, Token, Expires string
+	Error                     string
+}
+
+var signInTemplate = template.Must(template.New("signin").Parse(`<!doctype html>
+<html><head><meta charset="utf-8"><title>muxd hub sign-in</title>
+<style>body{font-family:system-ui,sans-serif;max-width:40em;margin:4em auto;padding:0 1em;color:#222}code{display:block;padding:.8em;background:#f4f4f4;word-break:break-all}</style>
+</head><body>
+{{if .Error}}<h1>Sign-in failed</h1><p>{{.Error}}</p>
+{{else}}<h1>Signed in</h1>
+<p>You are signed in to the muxd hub as <b>{{.Who}}</b> with the <b>{{.Role}}</b> role, until {{.Expires}}.</p>
+<p>To connect from a terminal, pass this token:</p>
+<code>muxd --remote &lt;hub&gt; --token {{.Token}}</code>
+<p>Or run <code>muxd --remote &lt;hub&gt; --login</code> to sign in from the terminal instead.</p>
+{{end}}</body></html>
+`))
+
+func renderSignInPage(w http.ResponseWriter, status int, page signInPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(status)
+	_ = signInTemplate.Execute(w, page)
+}
@@ -1,10 +1,12 @@
 package hub
 
 import (
-	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/store"
 )
 
 func (h *Hub) handleProxy(w http.ResponseWriter, r *http.Request) {
@@ -21,7 +23,7 @@ func (h *Hub) handleProxy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	target, err := url.Parse(fmt.Sprintf("http://%s:%d", node.Host, node.Port))
+	target, err := url.Parse(daemon.BaseURL(node.Host, node.Port))
 	if err != nil {
 		writeHubJSON(w, http.StatusInternalServerError, map[string]string{"error": "invalid node address"})
 		return
@@ -36,6 +38,12 @@ func (h *Hub) handleProxy(w http.ResponseWriter, r *http.Request) {
 			req.Host = target.Host
 			// Replace hub token with node's daemon token
 			req.Header.Set("Authorization", "Bearer "+node.Token)
+			req.Header.Del("Cookie")
+			if role := requestRole(r); role != store.TokenScopeAdmin {
+				req.Header.Set(daemon.ScopeHeader, role)
+			} else {
+				req.Header.Del(daemon.ScopeHeader)
+			}
 		},
 	}
 	proxy.ServeHTTP(w, r)
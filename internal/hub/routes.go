@@ -4,12 +4,18 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"sync"
 	"time"
+
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/hostinfo"
+	"github.com/batalabs/muxd/internal/store"
 )
 
 const sessionAggregationTimeout = 5 * time.Second
@@ -17,17 +23,26 @@ const sessionAggregationTimeout = 5 * time.Second
 func (h *Hub) registerRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("GET /api/health", h.handleHealth)
 	mux.HandleFunc("POST /api/hub/nodes/register", h.withAuth(h.handleRegisterNode))
-	mux.HandleFunc("DELETE /api/hub/nodes/{id}", h.withAuth(h.handleDeregisterNode))
-	mux.HandleFunc("GET /api/hub/nodes", h.withAuth(h.handleListNodes))
-	mux.HandleFunc("GET /api/hub/nodes/{id}", h.withAuth(h.handleGetNode))
+	mux.HandleFunc("DELETE /api/hub/nodes/{id}", h.withRole(store.TokenScopeAdmin, h.handleDeregisterNode))
+	mux.HandleFunc("GET /api/hub/nodes", h.withRole(store.TokenScopeRead, h.handleListNodes))
+	mux.HandleFunc("GET /api/hub/nodes/{id}", h.withRole(store.TokenScopeRead, h.handleGetNode))
 	mux.HandleFunc("POST /api/hub/nodes/{id}/heartbeat", h.withAuth(h.handleHeartbeat))
-	mux.HandleFunc("GET /api/hub/sessions", h.withAuth(h.handleAggregatedSessions))
+	mux.HandleFunc("GET /api/hub/sessions", h.withRole(store.TokenScopeRead, h.handleAggregatedSessions))
 	mux.HandleFunc("POST /api/hub/logs", h.withAuth(h.handleIngestLog))
-	mux.HandleFunc("GET /api/hub/logs/stream", h.withAuth(h.handleLogStream))
-	mux.HandleFunc("GET /api/hub/memory", h.withAuth(h.handleGetMemory))
-	mux.HandleFunc("PUT /api/hub/memory", h.withAuth(h.handlePutMemory))
-	// Proxy routes -match any method via wildcard
-	mux.HandleFunc("/api/hub/proxy/{nodeID}/{path...}", h.withAuth(h.handleProxy))
+	mux.HandleFunc("GET /api/hub/logs/stream", h.withRole(store.TokenScopeRead, h.handleLogStream))
+	mux.HandleFunc("GET /api/hub/memory", h.withRole(store.TokenScopeRead, h.handleGetMemory))
+	mux.HandleFunc("PUT /api/hub/memory", h.withRole(store.TokenScopeSubmit, h.handlePutMemory))
+	// Proxy routes -match any method via wildcard. The node's daemon
+	// narrows the request to the caller's role.
+	mux.HandleFunc("/api/hub/proxy/{nodeID}/{path...}", h.withRole(store.TokenScopeRead, h.handleProxy))
+
+	// OIDC sign-in
+	mux.HandleFunc("GET /auth/login", h.handleLogin)
+	mux.HandleFunc("GET /auth/callback", h.handleCallback)
+	mux.HandleFunc("POST /auth/device", h.handleDeviceLogin)
+	mux.HandleFunc("POST /auth/device/token", h.handleDeviceToken)
+	mux.HandleFunc("POST /auth/logout", h.handleLogout)
+	mux.HandleFunc("GET /auth/me", h.withRole(store.TokenScopeRead, h.handleWhoAmI))
 }
 
 // ---------------------------------------------------------------------------
@@ -45,6 +60,7 @@ func (h *Hub) handleHealth(w http.ResponseWriter, _ *http.Request) {
 		"pid":     os.Getpid(),
 		"port":    h.port,
 		"version": v,
+		"oidc":    h.oidc != nil,
 	})
 }
 
@@ -53,17 +69,20 @@ func (h *Hub) handleHealth(w http.ResponseWriter, _ *http.Request) {
 // ---------------------------------------------------------------------------
 
 type registerRequest struct {
-	Name     string   `json:"name"`
-	Host     string   `json:"host"`
-	Port     int      `json:"port"`
-	Token    string   `json:"token"`
-	Version  string   `json:"version"`
-	Platform string   `json:"platform,omitempty"`
-	Arch     string   `json:"arch,omitempty"`
-	Provider string   `json:"provider,omitempty"`
-	Model    string   `json:"model,omitempty"`
-	Tools    []string `json:"tools,omitempty"`
-	MCPTools []string `json:"mcp_tools,omitempty"`
+	ID        string              `json:"id,omitempty"` // stable ID chosen by the node; empty lets the hub assign one
+	Name      string              `json:"name"`
+	Host      string              `json:"host"`
+	Port      int                 `json:"port"`
+	Token     string              `json:"token"`
+	Version   string              `json:"version"`
+	Platform  string              `json:"platform,omitempty"`
+	Arch      string              `json:"arch,omitempty"`
+	Provider  string              `json:"provider,omitempty"`
+	Model     string              `json:"model,omitempty"`
+	Tools     []string            `json:"tools,omitempty"`
+	MCPTools  []string            `json:"mcp_tools,omitempty"`
+	Load      *NodeLoad           `json:"load,omitempty"`
+	Resources *hostinfo.Resources `json:"resources,omitempty"`
 }
 
 func (h *Hub) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
@@ -77,15 +96,25 @@ func (h *Hub) handleRegisterNode(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	caps := NodeCapabilities{
-		Platform: req.Platform,
-		Arch:     req.Arch,
-		Provider: req.Provider,
-		Model:    req.Model,
-		Tools:    req.Tools,
-		MCPTools: req.MCPTools,
+		Platform:  req.Platform,
+		Arch:      req.Arch,
+		Provider:  req.Provider,
+		Model:     req.Model,
+		Tools:     req.Tools,
+		MCPTools:  req.MCPTools,
+		Load:      req.Load,
+		Resources: req.Resources,
 	}
-	node, err := h.registerNode(req.Name, req.Host, req.Port, req.Token, req.Version, caps)
-	if err != nil {
+	node, err := h.registerNodeAs(req.ID, req.Name, req.Host, req.Port, req.Token, req.Version, caps)
+	var conflict *NameConflictError
+	switch {
+	case errors.Is(err, errInvalidNodeID):
+		writeHubJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	case errors.As(err, &conflict):
+		writeHubJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	case err != nil:
 		writeHubJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
@@ -142,12 +171,14 @@ func (h *Hub) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
 }
 
 type heartbeatRequest struct {
-	Platform string   `json:"platform,omitempty"`
-	Arch     string   `json:"arch,omitempty"`
-	Provider string   `json:"provider,omitempty"`
-	Model    string   `json:"model,omitempty"`
-	Tools    []string `json:"tools,omitempty"`
-	MCPTools []string `json:"mcp_tools,omitempty"`
+	Platform  string              `json:"platform,omitempty"`
+	Arch      string              `json:"arch,omitempty"`
+	Provider  string              `json:"provider,omitempty"`
+	Model     string              `json:"model,omitempty"`
+	Tools     []string            `json:"tools,omitempty"`
+	MCPTools  []string            `json:"mcp_tools,omitempty"`
+	Load      *NodeLoad           `json:"load,omitempty"`
+	Resources *hostinfo.Resources `json:"resources,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -167,7 +198,7 @@ func (h *Hub) handleAggregatedSessions(w http.ResponseWriter, r *http.Request) {
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 
-	client := &http.Client{Timeout: sessionAggregationTimeout}
+	client := &http.Client{Timeout: sessionAggregationTimeout, Transport: egress.Transport(nil)}
 	for _, node := range nodes {
 		if node.Status != StatusOnline {
 			continue
@@ -175,7 +206,7 @@ func (h *Hub) handleAggregatedSessions(w http.ResponseWriter, r *http.Request) {
 		wg.Add(1)
 		go func(node *Node) {
 			defer wg.Done()
-			url := fmt.Sprintf("http://%s:%d/api/sessions", node.Host, node.Port)
+			url := daemon.BaseURL(node.Host, node.Port) + "/api/sessions"
 			req, err := http.NewRequestWithContext(r.Context(), "GET", url, nil)
 			if err != nil {
 				return
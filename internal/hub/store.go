@@ -66,6 +66,15 @@ func migrateHub(db *sql.DB) error {
 			key TEXT PRIMARY KEY,
 			value TEXT NOT NULL
 		);
+		CREATE TABLE IF NOT EXISTS user_sessions (
+			token_hash TEXT PRIMARY KEY,
+			subject TEXT NOT NULL,
+			email TEXT NOT NULL DEFAULT '',
+			name TEXT NOT NULL DEFAULT '',
+			role TEXT NOT NULL,
+			created_at TEXT NOT NULL DEFAULT (datetime('now')),
+			expires_at TEXT NOT NULL
+		);
 	`)
 	return err
 }
@@ -3,10 +3,13 @@ package mcp
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	"golang.org/x/net/http/httpguts"
 )
 
 // MCPConfig holds MCP server configuration loaded from .mcp.json files.
@@ -16,11 +19,32 @@ type MCPConfig struct {
 
 // ServerConfig describes how to connect to a single MCP server.
 type ServerConfig struct {
-	Type    string            `json:"type"`              // "stdio" or "http"
-	Command string            `json:"command,omitempty"` // stdio: executable
-	Args    []string          `json:"args,omitempty"`    // stdio: arguments
-	Env     map[string]string `json:"env,omitempty"`     // stdio: env vars
-	URL     string            `json:"url,omitempty"`     // http: server URL
+	Type      string            `json:"type"`                // "stdio", "http" or "sse"
+	Transport string            `json:"transport,omitempty"` // alias for Type
+	Command   string            `json:"command,omitempty"`   // stdio: executable
+	Args      []string          `json:"args,omitempty"`      // stdio: arguments
+	Env       map[string]string `json:"env,omitempty"`       // stdio: env vars
+	URL       string            `json:"url,omitempty"`       // http/sse: server URL
+	Headers   map[string]string `json:"headers,omitempty"`   // http/sse: request headers, e.g. Authorization
+	Proxy     string            `json:"proxy,omitempty"`     // http/sse: proxy URL (defaults to proxy.url / env)
+}
+
+// kind returns the server's transport: Type, else Transport, else "stdio".
+func (sc ServerConfig) kind() string {
+	switch {
+	case sc.Type != "":
+		return sc.Type
+	case sc.Transport != "":
+		return sc.Transport
+	}
+	return "stdio"
+}
+
+// remote reports whether the server is reached over the network rather
+// than run as a child process.
+func (sc ServerConfig) remote() bool {
+	k := sc.kind()
+	return k == "http" || k == "sse"
 }
 
 // userConfigDir returns the user-scope MCP config directory.
@@ -63,14 +87,7 @@ func LoadMCPConfig(cwd string) (MCPConfig, error) {
 
 	// 3. Expand env vars and validate
 	for name, sc := range merged.MCPServers {
-		sc.Command = expandEnvVars(sc.Command)
-		sc.URL = expandEnvVars(sc.URL)
-		for i, arg := range sc.Args {
-			sc.Args[i] = expandEnvVars(arg)
-		}
-		for k, v := range sc.Env {
-			sc.Env[k] = expandEnvVars(v)
-		}
+		sc = expandServerConfig(sc)
 		if err := validateServerConfig(name, sc); err != nil {
 			return MCPConfig{}, err
 		}
@@ -80,6 +97,35 @@ func LoadMCPConfig(cwd string) (MCPConfig, error) {
 	return merged, nil
 }
 
+// expandServerConfig returns sc with ${VAR} references expanded. Args and
+// Env are copied, so sc itself is left as written.
+func expandServerConfig(sc ServerConfig) ServerConfig {
+	sc.Command = expandEnvVars(sc.Command)
+	sc.URL = expandEnvVars(sc.URL)
+	if sc.Args != nil {
+		args := make([]string, len(sc.Args))
+		for i, arg := range sc.Args {
+			args[i] = expandEnvVars(arg)
+		}
+		sc.Args = args
+	}
+	if sc.Env != nil {
+		env := make(map[string]string, len(sc.Env))
+		for k, v := range sc.Env {
+			env[k] = expandEnvVars(v)
+		}
+		sc.Env = env
+	}
+	if sc.Headers != nil {
+		headers := make(map[string]string, len(sc.Headers))
+		for k, v := range sc.Headers {
+			headers[k] = expandEnvVars(v)
+		}
+		sc.Headers = headers
+	}
+	return sc
+}
+
 func loadConfigFile(path string) (MCPConfig, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -96,21 +142,111 @@ func loadConfigFile(path string) (MCPConfig, error) {
 }
 
 func validateServerConfig(name string, sc ServerConfig) error {
-	switch sc.Type {
-	case "stdio", "":
+	if sc.Type != "" && sc.Transport != "" && sc.Type != sc.Transport {
+		return fmt.Errorf("MCP server %q: type %q and transport %q disagree", name, sc.Type, sc.Transport)
+	}
+	switch kind := sc.kind(); kind {
+	case "stdio":
 		if sc.Command == "" {
 			return fmt.Errorf("MCP server %q: stdio type requires 'command'", name)
 		}
-	case "http":
+	case "http", "sse":
 		if sc.URL == "" {
-			return fmt.Errorf("MCP server %q: http type requires 'url'", name)
+			return fmt.Errorf("MCP server %q: %s type requires 'url'", name, kind)
+		}
+		if sc.Proxy != "" {
+			if _, err := url.Parse(sc.Proxy); err != nil {
+				return fmt.Errorf("MCP server %q: invalid proxy: %w", name, err)
+			}
+		}
+		for k := range sc.Headers {
+			if !httpguts.ValidHeaderFieldName(k) {
+				return fmt.Errorf("MCP server %q: invalid header name %q", name, k)
+			}
 		}
 	default:
-		return fmt.Errorf("MCP server %q: unknown type %q (expected 'stdio' or 'http')", name, sc.Type)
+		return fmt.Errorf("MCP server %q: unknown type %q (expected 'stdio', 'http' or 'sse')", name, kind)
 	}
 	return nil
 }
 
+// UserConfigPath returns the user-scope mcp.json, or "" if there is no home
+// directory.
+func UserConfigPath() string {
+	dir := userConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "mcp.json")
+}
+
+// SaveUserServer adds or replaces a server in the user-scope mcp.json.
+// Other servers and fields in the file are kept as written.
+func SaveUserServer(name string, sc ServerConfig) error {
+	return editUserConfig(func(servers map[string]json.RawMessage) (bool, error) {
+		data, err := json.Marshal(sc)
+		if err != nil {
+			return false, err
+		}
+		servers[name] = data
+		return true, nil
+	})
+}
+
+// RemoveUserServer deletes a server from the user-scope mcp.json. It
+// reports whether the server was there.
+func RemoveUserServer(name string) (bool, error) {
+	removed := false
+	err := editUserConfig(func(servers map[string]json.RawMessage) (bool, error) {
+		_, removed = servers[name]
+		delete(servers, name)
+		return removed, nil
+	})
+	return removed, err
+}
+
+// editUserConfig rewrites the mcpServers object of the user-scope mcp.json
+// when edit reports a change.
+func editUserConfig(edit func(map[string]json.RawMessage) (bool, error)) error {
+	path := UserConfigPath()
+	if path == "" {
+		return fmt.Errorf("no user config directory")
+	}
+	doc := map[string]json.RawMessage{}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	servers := map[string]json.RawMessage{}
+	if raw, ok := doc["mcpServers"]; ok {
+		if err := json.Unmarshal(raw, &servers); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	changed, err := edit(servers)
+	if err != nil || !changed {
+		return err
+	}
+	raw, err := json.Marshal(servers)
+	if err != nil {
+		return err
+	}
+	doc["mcpServers"] = raw
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(out, '\n'), 0o600)
+}
+
 // envVarPattern matches ${VAR} and ${VAR:-default}.
 var envVarPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}`)
 
@@ -164,6 +164,21 @@ func TestLoadMCPConfig_ValidationErrors(t *testing.T) {
 			json:    `{"mcpServers":{"svc":{"type":"grpc","command":"x"}}}`,
 			wantErr: "unknown type",
 		},
+		{
+			name:    "sse missing url",
+			json:    `{"mcpServers":{"svc":{"transport":"sse"}}}`,
+			wantErr: "sse type requires 'url'",
+		},
+		{
+			name:    "type and transport disagree",
+			json:    `{"mcpServers":{"svc":{"type":"stdio","transport":"http","url":"http://x"}}}`,
+			wantErr: "disagree",
+		},
+		{
+			name:    "bad header name",
+			json:    `{"mcpServers":{"svc":{"transport":"http","url":"http://x","headers":{"Bad Header":"x"}}}}`,
+			wantErr: "invalid header name",
+		},
 	}
 
 	for _, tt := range tests {
@@ -217,3 +232,73 @@ func TestLoadMCPConfig_DefaultTypeIsStdio(t *testing.T) {
 		t.Errorf("command = %q, want %q", cfg.MCPServers["svc"].Command, "my-server")
 	}
 }
+
+func TestSaveAndRemoveUserServer(t *testing.T) {
+	userDir := t.TempDir()
+	path := filepath.Join(userDir, "mcp.json")
+	data := `{"note":"kept","mcpServers":{"db":{"type":"http","url":"${DB_URL}","extra":true}}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	origDir := userConfigDir
+	userConfigDir = func() string { return userDir }
+	defer func() { userConfigDir = origDir }()
+
+	if err := SaveUserServer("fs", ServerConfig{Type: "stdio", Command: "fs-server", Args: []string{"${HOME}"}}); err != nil {
+		t.Fatalf("SaveUserServer: %v", err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"note": "kept"`, `"url": "${DB_URL}"`, `"extra": true`, `"command": "fs-server"`, `"${HOME}"`} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("mcp.json missing %s:\n%s", want, got)
+		}
+	}
+
+	removed, err := RemoveUserServer("fs")
+	if err != nil || !removed {
+		t.Fatalf("RemoveUserServer = %v, %v", removed, err)
+	}
+	if removed, _ := RemoveUserServer("fs"); removed {
+		t.Error("second remove reported the server as present")
+	}
+	cfg, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.MCPServers["fs"]; ok || len(cfg.MCPServers) != 1 {
+		t.Errorf("servers after remove = %v", cfg.MCPServers)
+	}
+}
+
+func TestLoadMCPConfig_RemoteTransport(t *testing.T) {
+	dir := t.TempDir()
+	data := `{"mcpServers":{"docs":{"transport":"http","url":"https://mcp.example.com/mcp","headers":{"Authorization":"Bearer ${DOCS_TOKEN}"}}}}`
+	if err := os.WriteFile(filepath.Join(dir, ".mcp.json"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	origDir, origEnv := userConfigDir, lookupEnvFunc
+	userConfigDir = func() string { return "" }
+	lookupEnvFunc = func(key string) (string, bool) {
+		if key == "DOCS_TOKEN" {
+			return "secret", true
+		}
+		return "", false
+	}
+	defer func() { userConfigDir, lookupEnvFunc = origDir, origEnv }()
+
+	cfg, err := LoadMCPConfig(dir)
+	if err != nil {
+		t.Fatalf("LoadMCPConfig: %v", err)
+	}
+	sc := cfg.MCPServers["docs"]
+	if sc.kind() != "http" || !sc.remote() {
+		t.Errorf("kind = %q, want http from the transport key", sc.kind())
+	}
+	if got := sc.Headers["Authorization"]; got != "Bearer secret" {
+		t.Errorf("Authorization header = %q, want it expanded", got)
+	}
+}
@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Server logs
+// ---------------------------------------------------------------------------
+
+// maxLogLines is how many lines of output are kept per server.
+const maxLogLines = 200
+
+// logBuffer keeps the last lines an MCP server wrote to stderr, interleaved
+// with muxd's own notes about connecting to it. It is an io.Writer so it
+// can be a stdio server's Stderr.
+type logBuffer struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	text := b.partial + string(p)
+	parts := strings.Split(text, "\n")
+	b.partial = parts[len(parts)-1]
+	for _, line := range parts[:len(parts)-1] {
+		b.appendLocked(strings.TrimRight(line, "\r"))
+	}
+	return len(p), nil
+}
+
+// note records an event from muxd itself, e.g. a failed connection.
+func (b *logBuffer) note(format string, args ...any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.appendLocked(fmt.Sprintf("[muxd %s] ", time.Now().Format("15:04:05")) + fmt.Sprintf(format, args...))
+}
+
+func (b *logBuffer) appendLocked(line string) {
+	b.lines = append(b.lines, line)
+	if over := len(b.lines) - maxLogLines; over > 0 {
+		b.lines = append(b.lines[:0], b.lines[over:]...)
+	}
+}
+
+// Lines returns the kept lines, oldest first, including an unfinished
+// last line.
+func (b *logBuffer) Lines() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := append([]string(nil), b.lines...)
+	if b.partial != "" {
+		out = append(out, b.partial)
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package mcp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLogBuffer(t *testing.T) {
+	t.Run("splits writes into lines", func(t *testing.T) {
+		var b logBuffer
+		fmt.Fprint(&b, "one\r\ntw")
+		fmt.Fprint(&b, "o\nthree")
+		if got := strings.Join(b.Lines(), "|"); got != "one|two|three" {
+			t.Errorf("Lines = %q", got)
+		}
+	})
+
+	t.Run("keeps the last lines", func(t *testing.T) {
+		var b logBuffer
+		for i := range maxLogLines + 5 {
+			fmt.Fprintf(&b, "line %d\n", i)
+		}
+		lines := b.Lines()
+		if len(lines) != maxLogLines || lines[0] != "line 5" {
+			t.Errorf("got %d lines starting %q", len(lines), lines[0])
+		}
+	})
+
+	t.Run("notes are marked", func(t *testing.T) {
+		var b logBuffer
+		b.note("connect failed: %s", "boom")
+		if lines := b.Lines(); len(lines) != 1 || !strings.HasPrefix(lines[0], "[muxd ") || !strings.HasSuffix(lines[0], "connect failed: boom") {
+			t.Errorf("Lines = %q", lines)
+		}
+	})
+}
@@ -2,7 +2,11 @@ package mcp
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"sort"
@@ -12,6 +16,7 @@ import (
 
 	mcpsdk "github.com/modelcontextprotocol/go-sdk/mcp"
 
+	"github.com/batalabs/muxd/internal/egress"
 	"github.com/batalabs/muxd/internal/provider"
 	"github.com/batalabs/muxd/internal/tools"
 )
@@ -24,6 +29,7 @@ const (
 	statusConnecting
 	statusConnected
 	statusError
+	statusReconnecting
 )
 
 func (s serverStatus) String() string {
@@ -36,6 +42,8 @@ func (s serverStatus) String() string {
 		return "connected"
 	case statusError:
 		return "error"
+	case statusReconnecting:
+		return "reconnecting"
 	default:
 		return "unknown"
 	}
@@ -50,8 +58,32 @@ type serverConn struct {
 	cancel  context.CancelFunc
 	status  serverStatus
 	lastErr error
+	logs    *logBuffer
+
+	// Remote servers are retried with backoff when they fail or drop.
+	attempts int         // failed reconnects since the last success
+	retryAt  time.Time   // when the next reconnect is due
+	retry    *time.Timer // pending reconnect
+}
+
+// close ends the session and kills a stdio server's process.
+func (c *serverConn) close() {
+	if c.retry != nil {
+		c.retry.Stop()
+	}
+	if c.session != nil {
+		if err := c.session.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "mcp: close session: %v\n", err)
+		}
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
 }
 
+// ErrUnknownServer is returned for a server name the manager doesn't have.
+var ErrUnknownServer = errors.New("unknown MCP server")
+
 // Manager manages MCP server connections and tool discovery.
 type Manager struct {
 	mu      sync.RWMutex
@@ -70,44 +102,282 @@ const (
 	connectTimeout = 30 * time.Second
 	// callTimeout is the timeout for a single MCP tool invocation.
 	callTimeout = 30 * time.Second
+	// maxReconnectAttempts is how many times a remote server is retried
+	// before it is left in the error state for /mcp restart.
+	maxReconnectAttempts = 8
+)
+
+// Reconnect backoff: the delay doubles from reconnectBaseDelay up to
+// reconnectMaxDelay. Vars so tests can shorten them.
+var (
+	reconnectBaseDelay = time.Second
+	reconnectMaxDelay  = 2 * time.Minute
 )
 
+// reconnectDelay returns the wait before the given reconnect attempt.
+func reconnectDelay(attempt int) time.Duration {
+	d := reconnectBaseDelay
+	for i := 1; i < attempt && d < reconnectMaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, reconnectMaxDelay)
+}
+
 // StartAll connects to all configured MCP servers. Errors for individual
 // servers are logged to stderr but do not prevent other servers from starting.
 func (m *Manager) StartAll(ctx context.Context, cfg MCPConfig) error {
 	for name, sc := range cfg.MCPServers {
-		conn := &serverConn{
-			name:   name,
-			config: sc,
-			status: statusConnecting,
+		if err := m.startServer(ctx, name, sc); err != nil {
+			fmt.Fprintf(os.Stderr, "mcp: server %q failed to connect: %v\n", name, err)
 		}
-		m.mu.Lock()
-		m.servers[name] = conn
-		m.mu.Unlock()
-
-		if err := m.connectServer(ctx, conn); err != nil {
-			m.mu.Lock()
-			conn.status = statusError
-			conn.lastErr = err
+	}
+	return nil
+}
+
+// startServer connects sc as name, replacing any server of that name. A
+// server that fails to connect stays listed with its error, so its logs can
+// be read and it can be restarted once fixed. Remote servers are retried in
+// the background instead.
+func (m *Manager) startServer(ctx context.Context, name string, sc ServerConfig) error {
+	return m.connect(ctx, name, sc, nil)
+}
+
+// connect does the work of startServer. A reconnect passes the connection
+// it replaces as prev, and is dropped if prev was removed or restarted
+// while it waited.
+func (m *Manager) connect(ctx context.Context, name string, sc ServerConfig, prev *serverConn) error {
+	conn := &serverConn{
+		name:   name,
+		config: sc,
+		status: statusConnecting,
+		logs:   &logBuffer{},
+	}
+	m.mu.Lock()
+	old := m.servers[name]
+	if prev != nil {
+		if old != prev || prev.status != statusReconnecting {
 			m.mu.Unlock()
-			fmt.Fprintf(os.Stderr, "mcp: server %q failed to connect: %v\n", name, err)
-			continue
+			return nil
 		}
+		conn.attempts = prev.attempts
+	}
+	if old != nil {
+		// Keep the log across restarts; it shows why the last run failed.
+		conn.logs = old.logs
+		old.status = statusDisconnected
+	}
+	m.servers[name] = conn
+	m.mu.Unlock()
+	if old != nil {
+		old.close()
+	}
 
-		m.mu.Lock()
-		conn.status = statusConnected
-		m.mu.Unlock()
+	err := m.connectServer(ctx, conn)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.servers[name] != conn {
+		// Removed or replaced while connecting.
+		if err == nil {
+			conn.close()
+		}
+		return fmt.Errorf("MCP server %q was removed while connecting", name)
+	}
+	if err != nil {
+		conn.status = statusError
+		conn.lastErr = err
+		conn.logs.note("connect failed: %v", err)
+		if sc.remote() {
+			m.scheduleReconnect(conn)
+		}
+		return err
+	}
+	conn.status = statusConnected
+	conn.attempts = 0
+	conn.logs.note("connected, %d tools", len(conn.tools))
+	if sc.remote() {
+		go m.watch(conn)
 	}
 	return nil
 }
 
+// scheduleReconnect retries a remote server after a backoff delay, or
+// leaves it in the error state once the attempts run out. The caller holds
+// m.mu and has set conn.lastErr.
+func (m *Manager) scheduleReconnect(conn *serverConn) {
+	conn.attempts++
+	if conn.attempts > maxReconnectAttempts {
+		conn.status = statusError
+		conn.logs.note("giving up after %d reconnect attempts", maxReconnectAttempts)
+		return
+	}
+	delay := reconnectDelay(conn.attempts)
+	conn.status = statusReconnecting
+	conn.retryAt = time.Now().Add(delay)
+	conn.logs.note("reconnecting in %s (attempt %d of %d)", delay, conn.attempts, maxReconnectAttempts)
+	conn.retry = time.AfterFunc(delay, func() {
+		_ = m.connect(context.Background(), conn.name, conn.config, conn)
+	})
+}
+
+// watch waits for a remote server's session to end and reconnects unless
+// the server was stopped, removed or restarted on purpose.
+func (m *Manager) watch(conn *serverConn) {
+	err := conn.session.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.servers[conn.name] != conn || conn.status != statusConnected {
+		return
+	}
+	if err == nil {
+		err = errors.New("server closed the connection")
+	}
+	conn.lastErr = fmt.Errorf("connection lost: %w", err)
+	conn.logs.note("%v", conn.lastErr)
+	m.scheduleReconnect(conn)
+}
+
+// AddServer connects a server at runtime, replacing one of the same name.
+// ${VAR} references in sc are expanded as in .mcp.json. The server is kept
+// even if it fails to connect; the error says why.
+func (m *Manager) AddServer(ctx context.Context, name string, sc ServerConfig) error {
+	if name == "" || strings.ContainsAny(name, "/ \t\n") {
+		return fmt.Errorf("invalid MCP server name %q", name)
+	}
+	sc = expandServerConfig(sc)
+	if err := validateServerConfig(name, sc); err != nil {
+		return err
+	}
+	return m.startServer(ctx, name, sc)
+}
+
+// RemoveServer disconnects a server and drops it with its tools.
+func (m *Manager) RemoveServer(name string) error {
+	m.mu.Lock()
+	conn, ok := m.servers[name]
+	if ok {
+		conn.status = statusDisconnected
+		delete(m.servers, name)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownServer, name)
+	}
+	conn.close()
+	return nil
+}
+
+// RestartServer reconnects a server with its current configuration.
+func (m *Manager) RestartServer(ctx context.Context, name string) error {
+	m.mu.RLock()
+	conn, ok := m.servers[name]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w %q", ErrUnknownServer, name)
+	}
+	return m.startServer(ctx, name, conn.config)
+}
+
+// Logs returns the last lines a server wrote to stderr, oldest first, along
+// with muxd's notes on connecting to it. HTTP servers only have the notes.
+func (m *Manager) Logs(name string) ([]string, error) {
+	m.mu.RLock()
+	conn, ok := m.servers[name]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownServer, name)
+	}
+	return conn.logs.Lines(), nil
+}
+
+// ServerInfo describes a configured MCP server.
+type ServerInfo struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`   // "stdio", "http" or "sse"
+	Target string `json:"target"` // command line or URL
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	Tools  int    `json:"tools"`
+	// Set while a remote server is reconnecting.
+	Attempt int        `json:"attempt,omitempty"`
+	RetryAt *time.Time `json:"retry_at,omitempty"`
+}
+
+// Servers lists every server, connected or not, sorted by name.
+func (m *Manager) Servers() []ServerInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	out := make([]ServerInfo, 0, len(m.servers))
+	for name, conn := range m.servers {
+		info := ServerInfo{
+			Name:   name,
+			Type:   conn.config.kind(),
+			Target: strings.Join(append([]string{conn.config.Command}, conn.config.Args...), " "),
+			Status: conn.status.String(),
+		}
+		if conn.config.remote() {
+			info.Target = conn.config.URL
+		}
+		if conn.status == statusConnected {
+			info.Tools = len(conn.tools)
+		}
+		if (conn.status == statusError || conn.status == statusReconnecting) && conn.lastErr != nil {
+			info.Error = conn.lastErr.Error()
+		}
+		if conn.status == statusReconnecting {
+			retryAt := conn.retryAt
+			info.Attempt = conn.attempts
+			info.RetryAt = &retryAt
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
 // newTransport creates the appropriate MCP transport. Extracted for testability.
 var newTransport = defaultNewTransport
 
-func defaultNewTransport(sc ServerConfig) (mcpsdk.Transport, context.CancelFunc) {
-	switch sc.Type {
+// headerTransport adds a remote server's configured headers to each request.
+type headerTransport struct {
+	headers map[string]string
+	base    http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// httpClientFor returns the HTTP client for a remote server, routed through
+// its configured proxy or the default egress proxy, sending its headers.
+func httpClientFor(sc ServerConfig) *http.Client {
+	proxy := provider.ProxyFunc("")
+	if sc.Proxy != "" {
+		if u, err := url.Parse(sc.Proxy); err == nil {
+			proxy = http.ProxyURL(u)
+		}
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.Proxy = proxy
+	rt := egress.Transport(tr)
+	if len(sc.Headers) > 0 {
+		rt = headerTransport{headers: sc.Headers, base: rt}
+	}
+	return &http.Client{Transport: rt}
+}
+
+func defaultNewTransport(sc ServerConfig, stderr io.Writer) (mcpsdk.Transport, context.CancelFunc) {
+	switch sc.kind() {
 	case "http":
-		return &mcpsdk.StreamableClientTransport{Endpoint: sc.URL}, func() {}
+		return &mcpsdk.StreamableClientTransport{Endpoint: sc.URL, HTTPClient: httpClientFor(sc)}, func() {}
+	case "sse":
+		return &mcpsdk.SSEClientTransport{Endpoint: sc.URL, HTTPClient: httpClientFor(sc)}, func() {}
 	default: // stdio
 		cmd := exec.Command(sc.Command, sc.Args...)
 		if len(sc.Env) > 0 {
@@ -116,7 +386,8 @@ func defaultNewTransport(sc ServerConfig) (mcpsdk.Transport, context.CancelFunc)
 				cmd.Env = append(cmd.Env, k+"="+v)
 			}
 		}
-		// Discard child stderr to avoid polluting the TUI.
+		// Keep child stderr for /mcp logs instead of the TUI's terminal.
+		cmd.Stderr = stderr
 		return &mcpsdk.CommandTransport{Command: cmd}, func() {
 			if cmd.Process != nil {
 				// Ignore "invalid argument" / "process already finished" errors
@@ -133,7 +404,7 @@ var checkCommand = true
 
 func (m *Manager) connectServer(ctx context.Context, conn *serverConn) error {
 	// For stdio servers, verify the command exists before trying to connect.
-	if checkCommand && (conn.config.Type == "" || conn.config.Type == "stdio") {
+	if checkCommand && conn.config.kind() == "stdio" {
 		if _, lookErr := exec.LookPath(conn.config.Command); lookErr != nil {
 			return fmt.Errorf("%q not found in PATH — install it or check your MCP config", conn.config.Command)
 		}
@@ -144,7 +415,7 @@ func (m *Manager) connectServer(ctx context.Context, conn *serverConn) error {
 		Version: "1.0",
 	}, nil)
 
-	transport, killFunc := newTransport(conn.config)
+	transport, killFunc := newTransport(conn.config, conn.logs)
 
 	connCtx, cancel := context.WithTimeout(ctx, connectTimeout)
 	defer cancel()
@@ -177,14 +448,7 @@ func (m *Manager) StopAll() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	for _, conn := range m.servers {
-		if conn.session != nil {
-			if err := conn.session.Close(); err != nil {
-				fmt.Fprintf(os.Stderr, "mcp: close session: %v\n", err)
-			}
-		}
-		if conn.cancel != nil {
-			conn.cancel()
-		}
+		conn.close()
 		conn.status = statusDisconnected
 	}
 }
@@ -316,7 +580,7 @@ func (m *Manager) ServerStatuses() map[string]string {
 	statuses := make(map[string]string, len(m.servers))
 	for name, conn := range m.servers {
 		s := conn.status.String()
-		if conn.lastErr != nil && conn.status == statusError {
+		if conn.lastErr != nil && (conn.status == statusError || conn.status == statusReconnecting) {
 			s += ": " + conn.lastErr.Error()
 		}
 		statuses[name] = s
@@ -348,5 +612,10 @@ func diagnoseMCPError(sc ServerConfig, err error) string {
 		return fmt.Sprintf("connection refused at %s — is the server running?", sc.URL)
 	}
 
+	// Rejected credentials for HTTP servers.
+	if sc.remote() && (strings.Contains(msg, "401") || strings.Contains(msg, "403") || strings.Contains(msg, "Unauthorized") || strings.Contains(msg, "Forbidden")) {
+		return fmt.Sprintf("%s rejected the request (%s) — check the server's headers", sc.URL, msg)
+	}
+
 	return msg
 }
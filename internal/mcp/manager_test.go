@@ -3,8 +3,13 @@ package mcp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -46,7 +51,7 @@ func setupTestServer(t *testing.T, serverName string, mcpTools []*mcpsdk.Tool, h
 	origCheckCommand := checkCommand
 	checkCommand = false
 	origTransport := newTransport
-	newTransport = func(sc ServerConfig) (mcpsdk.Transport, context.CancelFunc) {
+	newTransport = func(sc ServerConfig, _ io.Writer) (mcpsdk.Transport, context.CancelFunc) {
 		return clientTransport, func() {}
 	}
 
@@ -460,3 +465,264 @@ func TestManager_ToolDefs_errorResult(t *testing.T) {
 		t.Errorf("result = %q, want %q", result, "boom")
 	}
 }
+
+// useTestServers makes every connection reach a fresh in-memory server
+// offering one "ping" tool, so servers can be added and restarted.
+func useTestServers(t *testing.T) {
+	t.Helper()
+	origCheckCommand, origTransport := checkCommand, newTransport
+	checkCommand = false
+	newTransport = func(sc ServerConfig, stderr io.Writer) (mcpsdk.Transport, context.CancelFunc) {
+		server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "test-server", Version: "1.0"}, nil)
+		server.AddTool(&mcpsdk.Tool{Name: "ping", InputSchema: map[string]any{"type": "object"}},
+			func(ctx context.Context, req *mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+				return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "pong"}}}, nil
+			})
+		serverTransport, clientTransport := mcpsdk.NewInMemoryTransports()
+		ss, err := server.Connect(context.Background(), serverTransport, nil)
+		if err != nil {
+			t.Fatalf("server connect: %v", err)
+		}
+		fmt.Fprintln(stderr, "server started")
+		return clientTransport, func() { ss.Close() }
+	}
+	t.Cleanup(func() {
+		checkCommand, newTransport = origCheckCommand, origTransport
+	})
+}
+
+func TestManager_AddRestartRemove(t *testing.T) {
+	useTestServers(t)
+	mgr := NewManager()
+	defer mgr.StopAll()
+	ctx := context.Background()
+
+	if err := mgr.AddServer(ctx, "db", ServerConfig{Command: "db-server", Args: []string{"--port", "1"}}); err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	servers := mgr.Servers()
+	if len(servers) != 1 || servers[0].Status != "connected" || servers[0].Tools != 1 || servers[0].Target != "db-server --port 1" || servers[0].Type != "stdio" {
+		t.Fatalf("Servers = %+v", servers)
+	}
+	if result, isErr := mgr.CallTool(ctx, "db", "ping", nil); isErr || result != "pong" {
+		t.Errorf("CallTool = %q, %v", result, isErr)
+	}
+
+	if err := mgr.RestartServer(ctx, "db"); err != nil {
+		t.Fatalf("RestartServer: %v", err)
+	}
+	if result, isErr := mgr.CallTool(ctx, "db", "ping", nil); isErr || result != "pong" {
+		t.Errorf("CallTool after restart = %q, %v", result, isErr)
+	}
+	logs, err := mgr.Logs("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started := strings.Count(strings.Join(logs, "\n"), "server started"); started != 2 {
+		t.Errorf("logs = %q, want output from both runs", logs)
+	}
+
+	if err := mgr.RemoveServer("db"); err != nil {
+		t.Fatalf("RemoveServer: %v", err)
+	}
+	if len(mgr.Servers()) != 0 || len(mgr.ToolNames()) != 0 {
+		t.Errorf("server still listed after remove: %+v", mgr.Servers())
+	}
+	if err := mgr.RemoveServer("db"); !errors.Is(err, ErrUnknownServer) {
+		t.Errorf("second RemoveServer error = %v, want ErrUnknownServer", err)
+	}
+	if err := mgr.RestartServer(ctx, "db"); !errors.Is(err, ErrUnknownServer) {
+		t.Errorf("RestartServer error = %v, want ErrUnknownServer", err)
+	}
+}
+
+func TestManager_AddServer_invalid(t *testing.T) {
+	mgr := NewManager()
+	if err := mgr.AddServer(context.Background(), "web", ServerConfig{Type: "http"}); err == nil {
+		t.Error("expected error for http server without url")
+	}
+	if err := mgr.AddServer(context.Background(), "a b", ServerConfig{Command: "x"}); err == nil {
+		t.Error("expected error for name with a space")
+	}
+	if len(mgr.Servers()) != 0 {
+		t.Errorf("invalid servers were added: %+v", mgr.Servers())
+	}
+}
+
+func TestManager_AddServer_failureIsKept(t *testing.T) {
+	mgr := NewManager()
+	err := mgr.AddServer(context.Background(), "missing", ServerConfig{Command: "muxd-no-such-command"})
+	if err == nil {
+		t.Fatal("expected connect error")
+	}
+	servers := mgr.Servers()
+	if len(servers) != 1 || servers[0].Status != "error" || servers[0].Error == "" {
+		t.Fatalf("Servers = %+v, want the failed server with its error", servers)
+	}
+	logs, _ := mgr.Logs("missing")
+	if len(logs) == 0 || !strings.Contains(logs[len(logs)-1], "connect failed") {
+		t.Errorf("logs = %q", logs)
+	}
+}
+
+func TestReconnectDelay(t *testing.T) {
+	for attempt, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 20: reconnectMaxDelay} {
+		if got := reconnectDelay(attempt); got != want {
+			t.Errorf("reconnectDelay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+// failingTransport refuses every connection.
+type failingTransport struct{}
+
+func (failingTransport) Connect(context.Context) (mcpsdk.Connection, error) {
+	return nil, errors.New("connection refused")
+}
+
+// waitForStatus polls until the named server reaches status.
+func waitForStatus(t *testing.T, mgr *Manager, name, status string) ServerInfo {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		for _, info := range mgr.Servers() {
+			if info.Name == name && info.Status == status {
+				return info
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server %q never reached %q: %+v", name, status, mgr.Servers())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManager_RemoteReconnect(t *testing.T) {
+	origTransport, origDelay := newTransport, reconnectBaseDelay
+	reconnectBaseDelay = 20 * time.Millisecond
+	var (
+		mu       sync.Mutex
+		dials    int
+		sessions []*mcpsdk.ServerSession
+	)
+	newTransport = func(sc ServerConfig, _ io.Writer) (mcpsdk.Transport, context.CancelFunc) {
+		mu.Lock()
+		defer mu.Unlock()
+		dials++
+		if dials == 1 {
+			return failingTransport{}, func() {}
+		}
+		server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "remote", Version: "1.0"}, nil)
+		server.AddTool(&mcpsdk.Tool{Name: "ping", InputSchema: map[string]any{"type": "object"}},
+			func(ctx context.Context, req *mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+				return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "pong"}}}, nil
+			})
+		serverTransport, clientTransport := mcpsdk.NewInMemoryTransports()
+		ss, err := server.Connect(context.Background(), serverTransport, nil)
+		if err != nil {
+			t.Errorf("server connect: %v", err)
+		}
+		sessions = append(sessions, ss)
+		return clientTransport, func() {}
+	}
+	t.Cleanup(func() { newTransport, reconnectBaseDelay = origTransport, origDelay })
+
+	mgr := NewManager()
+	defer mgr.StopAll()
+
+	// The first attempt fails; the server is retried rather than left in error.
+	err := mgr.AddServer(context.Background(), "docs", ServerConfig{Transport: "http", URL: "http://mcp.invalid/mcp"})
+	if err == nil {
+		t.Fatal("expected the first connect to fail")
+	}
+	info := mgr.Servers()[0]
+	if info.Type != "http" || info.Target != "http://mcp.invalid/mcp" {
+		t.Errorf("Servers = %+v", info)
+	}
+	if info.Status == "reconnecting" && (info.Attempt != 1 || info.RetryAt == nil || info.Error == "") {
+		t.Errorf("reconnecting entry = %+v, want attempt, retry time and error", info)
+	}
+	waitForStatus(t, mgr, "docs", "connected")
+	if result, isErr := mgr.CallTool(context.Background(), "docs", "ping", nil); isErr || result != "pong" {
+		t.Errorf("CallTool = %q, %v", result, isErr)
+	}
+
+	// A dropped session is reconnected too.
+	mu.Lock()
+	first := sessions[0]
+	mu.Unlock()
+	first.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := dials
+		mu.Unlock()
+		if n >= 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("dropped session was not reconnected: %+v", mgr.Servers())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitForStatus(t, mgr, "docs", "connected")
+	logs, _ := mgr.Logs("docs")
+	if joined := strings.Join(logs, "\n"); !strings.Contains(joined, "connection lost") || !strings.Contains(joined, "reconnecting in") {
+		t.Errorf("logs = %q", logs)
+	}
+
+	// Removing the server stops further attempts.
+	if err := mgr.RemoveServer("docs"); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	before := dials
+	mu.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if dials != before {
+		t.Errorf("removed server was redialled")
+	}
+}
+
+func TestManager_RemoteHeaders(t *testing.T) {
+	server := mcpsdk.NewServer(&mcpsdk.Implementation{Name: "remote", Version: "1.0"}, nil)
+	server.AddTool(&mcpsdk.Tool{Name: "ping", InputSchema: map[string]any{"type": "object"}},
+		func(ctx context.Context, req *mcpsdk.CallToolRequest) (*mcpsdk.CallToolResult, error) {
+			return &mcpsdk.CallToolResult{Content: []mcpsdk.Content{&mcpsdk.TextContent{Text: "pong"}}}, nil
+		})
+	handler := mcpsdk.NewStreamableHTTPHandler(func(*http.Request) *mcpsdk.Server { return server }, nil)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	origDelay := reconnectBaseDelay
+	reconnectBaseDelay = time.Hour
+	defer func() { reconnectBaseDelay = origDelay }()
+
+	mgr := NewManager()
+	defer mgr.StopAll()
+	ctx := context.Background()
+
+	err := mgr.AddServer(ctx, "docs", ServerConfig{Type: "http", URL: ts.URL, Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err != nil {
+		t.Fatalf("AddServer: %v", err)
+	}
+	if result, isErr := mgr.CallTool(ctx, "docs", "ping", nil); isErr || result != "pong" {
+		t.Errorf("CallTool = %q, %v", result, isErr)
+	}
+
+	err = mgr.AddServer(ctx, "anon", ServerConfig{Type: "http", URL: ts.URL})
+	if err == nil || !strings.Contains(err.Error(), "check the server's headers") {
+		t.Errorf("connect without the header: %v", err)
+	}
+	if info := waitForStatus(t, mgr, "anon", "reconnecting"); info.Attempt != 1 {
+		t.Errorf("anon = %+v", info)
+	}
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+// Events a notification can be sent for.
+const (
+	EventTurnDone  = "turn_done"
+	EventJobDone   = "job_done"
+	EventJobFailed = "job_failed"
+	EventBudget    = "budget"
+)
+
+// Sink names accepted in notify.on_* preferences.
+const (
+	SinkDesktop  = "desktop"
+	SinkWebhook  = "webhook"
+	SinkTelegram = "telegram"
+)
+
+// SinkNames lists every sink in the order they are tried.
+var SinkNames = []string{SinkDesktop, SinkWebhook, SinkTelegram}
+
+// Message is one notification.
+type Message struct {
+	Event     string `json:"event"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	SessionID string `json:"session_id,omitempty"`
+}
+
+// Sink delivers messages to one destination.
+type Sink interface {
+	Name() string
+	Notify(ctx context.Context, m Message) error
+}
+
+// sendTimeout bounds a single delivery.
+const sendTimeout = 15 * time.Second
+
+func defaultClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return &http.Client{Timeout: sendTimeout, Transport: egress.Transport(nil)}
+}
+
+// ParseSinks normalizes a comma-separated list of sink names, e.g.
+// "desktop, webhook". Unknown names are an error.
+func ParseSinks(v string) ([]string, error) {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" || slices.Contains(out, name) {
+			continue
+		}
+		if !slices.Contains(SinkNames, name) {
+			return nil, fmt.Errorf("unknown notification sink %q (use %s)", name, strings.Join(SinkNames, ", "))
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+// ---------------------------------------------------------------------------
+// Notifier
+// ---------------------------------------------------------------------------
+
+// Config selects which sinks each event goes to and how to reach them.
+type Config struct {
+	// Routes maps an event to the sink names it is sent to.
+	Routes map[string][]string
+
+	WebhookURL     string
+	TelegramToken  string
+	TelegramChatID string
+
+	// Compliance leaves out the sinks that send messages off the machine,
+	// keeping only desktop notifications.
+	Compliance bool
+}
+
+// Notifier routes messages to the sinks configured for their event.
+type Notifier struct {
+	routes map[string][]string
+	sinks  map[string]Sink
+	errs   map[string]error // sinks that are routed to but not configured
+}
+
+// New builds the sinks cfg needs. A sink that is missing settings, such as
+// a webhook without a URL, fails every send with the reason.
+func New(cfg Config) *Notifier {
+	n := &Notifier{routes: cfg.Routes, sinks: map[string]Sink{}, errs: map[string]error{}}
+	for _, name := range SinkNames {
+		sink, err := newSink(name, cfg)
+		if err != nil {
+			n.errs[name] = err
+			continue
+		}
+		n.sinks[name] = sink
+	}
+	return n
+}
+
+func newSink(name string, cfg Config) (Sink, error) {
+	if cfg.Compliance && name != SinkDesktop {
+		return nil, errors.New("disabled in compliance mode")
+	}
+	switch name {
+	case SinkDesktop:
+		return Desktop{}, nil
+	case SinkWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, errors.New("notify.webhook_url is not set")
+		}
+		return &Webhook{URL: cfg.WebhookURL}, nil
+	case SinkTelegram:
+		if cfg.TelegramToken == "" || cfg.TelegramChatID == "" {
+			return nil, errors.New("notify.telegram_token and notify.telegram_chat_id must both be set")
+		}
+		return &Telegram{Token: cfg.TelegramToken, ChatID: cfg.TelegramChatID}, nil
+	}
+	return nil, fmt.Errorf("unknown notification sink %q", name)
+}
+
+// SetSink replaces the sink registered under its name.
+func (n *Notifier) SetSink(s Sink) {
+	n.sinks[s.Name()] = s
+	delete(n.errs, s.Name())
+}
+
+// Enabled reports whether any sink is routed for event.
+func (n *Notifier) Enabled(event string) bool {
+	return n != nil && len(n.routes[event]) > 0
+}
+
+// Send delivers m to every sink routed for m.Event and joins their errors.
+func (n *Notifier) Send(ctx context.Context, m Message) error {
+	if n == nil {
+		return nil
+	}
+	var errs []error
+	for _, name := range n.routes[m.Event] {
+		if err := n.deliver(ctx, name, m); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Result is the outcome of a test notification for one sink.
+type Result struct {
+	Sink  string `json:"sink"`
+	Error string `json:"error,omitempty"`
+}
+
+// Test sends a test message to the named sinks, or with none named, to
+// every sink that is routed for some event.
+func (n *Notifier) Test(ctx context.Context, names ...string) []Result {
+	if len(names) == 0 {
+		for _, name := range SinkNames {
+			for _, sinks := range n.routes {
+				if slices.Contains(sinks, name) {
+					names = append(names, name)
+					break
+				}
+			}
+		}
+	}
+	m := Message{Event: "test", Title: "muxd", Body: "Test notification from muxd"}
+	results := make([]Result, 0, len(names))
+	for _, name := range names {
+		r := Result{Sink: name}
+		if err := n.deliver(ctx, name, m); err != nil {
+			r.Error = err.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func (n *Notifier) deliver(ctx context.Context, name string, m Message) error {
+	if err, ok := n.errs[name]; ok {
+		return err
+	}
+	sink, ok := n.sinks[name]
+	if !ok {
+		return fmt.Errorf("unknown notification sink %q", name)
+	}
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return sink.Notify(ctx, m)
+}
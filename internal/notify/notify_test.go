@@ -0,0 +1,213 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSinks(t *testing.T) {
+	got, err := ParseSinks(" Desktop, webhook,,desktop ")
+	if err != nil || !slices.Equal(got, []string{"desktop", "webhook"}) {
+		t.Errorf("ParseSinks = %v, %v", got, err)
+	}
+	if got, err := ParseSinks(""); err != nil || got != nil {
+		t.Errorf("empty = %v, %v", got, err)
+	}
+	if _, err := ParseSinks("desktop,pager"); err == nil || !strings.Contains(err.Error(), "pager") {
+		t.Errorf("unknown sink error = %v", err)
+	}
+}
+
+type fakeSink struct {
+	name string
+	got  []Message
+	err  error
+}
+
+func (f *fakeSink) Name() string { return f.name }
+
+func (f *fakeSink) Notify(_ context.Context, m Message) error {
+	f.got = append(f.got, m)
+	return f.err
+}
+
+func TestNotifierRoutesByEvent(t *testing.T) {
+	n := New(Config{Routes: map[string][]string{
+		EventTurnDone:  {SinkDesktop},
+		EventJobFailed: {SinkDesktop, SinkWebhook},
+	}})
+	desktop := &fakeSink{name: SinkDesktop}
+	n.SetSink(desktop)
+
+	if !n.Enabled(EventTurnDone) || n.Enabled(EventJobDone) {
+		t.Error("Enabled should follow the routes")
+	}
+	if err := n.Send(context.Background(), Message{Event: EventJobDone}); err != nil || len(desktop.got) != 0 {
+		t.Errorf("unrouted event: err=%v sent=%d", err, len(desktop.got))
+	}
+	if err := n.Send(context.Background(), Message{Event: EventTurnDone, Title: "t"}); err != nil || len(desktop.got) != 1 {
+		t.Errorf("turn_done: err=%v sent=%d", err, len(desktop.got))
+	}
+	// The webhook has no URL, so its error is reported but desktop still gets the message.
+	err := n.Send(context.Background(), Message{Event: EventJobFailed})
+	if err == nil || !strings.Contains(err.Error(), "webhook: notify.webhook_url is not set") {
+		t.Errorf("job_failed err = %v", err)
+	}
+	if len(desktop.got) != 2 {
+		t.Errorf("desktop got %d messages, want 2", len(desktop.got))
+	}
+
+	var nilNotifier *Notifier
+	if nilNotifier.Enabled(EventTurnDone) || nilNotifier.Send(context.Background(), Message{}) != nil {
+		t.Error("a nil notifier should do nothing")
+	}
+}
+
+func TestNotifierCompliance(t *testing.T) {
+	n := New(Config{
+		Routes:         map[string][]string{EventTurnDone: SinkNames},
+		WebhookURL:     "https://hooks.example.com/x",
+		TelegramToken:  "123:abc",
+		TelegramChatID: "42",
+		Compliance:     true,
+	})
+	var names []string
+	for name := range n.sinks {
+		names = append(names, name)
+	}
+	if !slices.Equal(names, []string{SinkDesktop}) {
+		t.Errorf("sinks under compliance = %v, want only desktop", names)
+	}
+	for _, name := range []string{SinkWebhook, SinkTelegram} {
+		if err := n.deliver(context.Background(), name, Message{}); err == nil || !strings.Contains(err.Error(), "compliance") {
+			t.Errorf("%s: err = %v, want the compliance reason", name, err)
+		}
+	}
+}
+
+func TestNotifierTest(t *testing.T) {
+	n := New(Config{Routes: map[string][]string{EventTurnDone: {SinkWebhook}, EventBudget: {SinkDesktop}}})
+	desktop := &fakeSink{name: SinkDesktop, err: errors.New("no display")}
+	webhook := &fakeSink{name: SinkWebhook}
+	n.SetSink(desktop)
+	n.SetSink(webhook)
+
+	got := n.Test(context.Background())
+	want := []Result{{Sink: SinkDesktop, Error: "no display"}, {Sink: SinkWebhook}}
+	if !slices.Equal(got, want) {
+		t.Errorf("Test() = %+v, want %+v", got, want)
+	}
+	if got := n.Test(context.Background(), SinkTelegram); len(got) != 1 || !strings.Contains(got[0].Error, "telegram_token") {
+		t.Errorf("Test(telegram) = %+v", got)
+	}
+	if len(webhook.got) != 1 || webhook.got[0].Event != "test" {
+		t.Errorf("webhook got %+v", webhook.got)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	var got Message
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	m := Message{Event: EventJobDone, Title: "Job 1 succeeded", Body: "done", SessionID: "s1"}
+	if err := (&Webhook{URL: srv.URL}).Notify(context.Background(), m); err != nil {
+		t.Fatal(err)
+	}
+	if got != m {
+		t.Errorf("posted %+v, want %+v", got, m)
+	}
+
+	fail := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusForbidden)
+	}))
+	defer fail.Close()
+	if err := (&Webhook{URL: fail.URL}).Notify(context.Background(), m); err == nil || !strings.Contains(err.Error(), "HTTP 403: nope") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestTelegram(t *testing.T) {
+	var path string
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["chat_id"] == "bad" {
+			http.Error(w, `{"ok":false}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	tg := &Telegram{Token: "123:secret", ChatID: "42", Host: srv.URL}
+	if err := tg.Notify(context.Background(), Message{Title: "muxd", Body: "Turn finished"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/bot123:secret/sendMessage" || body["chat_id"] != "42" || body["text"] != "muxd\nTurn finished" {
+		t.Errorf("path=%q body=%v", path, body)
+	}
+
+	tg.ChatID = "bad"
+	if err := tg.Notify(context.Background(), Message{Title: "x"}); err == nil || !strings.Contains(err.Error(), "HTTP 400") {
+		t.Errorf("err = %v", err)
+	}
+	// Transport errors carry the URL; the token must not leak.
+	tg.Host = "http://127.0.0.1:1"
+	err := tg.Notify(context.Background(), Message{Title: "x"})
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("err = %v; want an error without the token", err)
+	}
+}
+
+func TestDesktopCommand(t *testing.T) {
+	m := Message{Title: `Say "hi"`, Body: "it's done"}
+	tests := []struct {
+		goos, name, contains string
+	}{
+		{"linux", "notify-send", "it's done"},
+		{"darwin", "osascript", `with title "Say \"hi\""`},
+		{"windows", "powershell", "'it''s done'"},
+	}
+	for _, tt := range tests {
+		name, args := desktopCommand(tt.goos, m)
+		if name != tt.name || !strings.Contains(strings.Join(args, " "), tt.contains) {
+			t.Errorf("%s: %s %q; want %s containing %q", tt.goos, name, args, tt.name, tt.contains)
+		}
+	}
+	if name, _ := desktopCommand("plan9", m); name != "" {
+		t.Errorf("plan9: %s; want unsupported", name)
+	}
+}
+
+func TestDesktopNotify(t *testing.T) {
+	orig := runCommand
+	defer func() { runCommand = orig }()
+	var ran string
+	runCommand = func(_ context.Context, name string, args ...string) error {
+		ran = name
+		return nil
+	}
+	name, _ := desktopCommand(runtime.GOOS, Message{})
+	err := Desktop{}.Notify(context.Background(), Message{Title: "t", Body: "b"})
+	if name == "" {
+		if err == nil {
+			t.Error("expected an unsupported-platform error")
+		}
+		return
+	}
+	if err != nil || ran != name {
+		t.Errorf("ran %q, err %v; want %q", ran, err, name)
+	}
+}
@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Desktop
+// ---------------------------------------------------------------------------
+
+// Desktop shows an OS notification: notify-send on Linux, osascript on
+// macOS, and a toast through PowerShell on Windows.
+type Desktop struct{}
+
+// runCommand runs a notification helper; tests replace it.
+var runCommand = func(ctx context.Context, name string, args ...string) error {
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%s: %w: %s", name, err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
+
+func (Desktop) Name() string { return SinkDesktop }
+
+func (Desktop) Notify(ctx context.Context, m Message) error {
+	name, args := desktopCommand(runtime.GOOS, m)
+	if name == "" {
+		return fmt.Errorf("desktop notifications are not supported on %s", runtime.GOOS)
+	}
+	return runCommand(ctx, name, args...)
+}
+
+// desktopCommand returns the helper and arguments that show m on goos.
+func desktopCommand(goos string, m Message) (string, []string) {
+	switch goos {
+	case "linux", "freebsd", "openbsd", "netbsd":
+		return "notify-send", []string{"--app-name=muxd", m.Title, m.Body}
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(m.Body), appleScriptString(m.Title))
+		return "osascript", []string{"-e", script}
+	case "windows":
+		script := `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode(` + powerShellString(m.Title) + `)) | Out-Null
+$text.Item(1).AppendChild($xml.CreateTextNode(` + powerShellString(m.Body) + `)) | Out-Null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('muxd').Show([Windows.UI.Notifications.ToastNotification]::new($xml))`
+		return "powershell", []string{"-NoProfile", "-NonInteractive", "-Command", script}
+	}
+	return "", nil
+}
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// ---------------------------------------------------------------------------
+// Webhook
+// ---------------------------------------------------------------------------
+
+// Webhook POSTs each message as JSON to URL.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+func (w *Webhook) Name() string { return SinkWebhook }
+
+func (w *Webhook) Notify(ctx context.Context, m Message) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return post(ctx, defaultClient(w.Client), w.URL, body)
+}
+
+// ---------------------------------------------------------------------------
+// Telegram
+// ---------------------------------------------------------------------------
+
+// TelegramHost is the Telegram Bot API host.
+const TelegramHost = "https://api.telegram.org"
+
+// Telegram sends each message as a direct message from a bot.
+type Telegram struct {
+	Token  string
+	ChatID string
+	Host   string // TelegramHost or a test server
+	Client *http.Client
+}
+
+func (t *Telegram) Name() string { return SinkTelegram }
+
+func (t *Telegram) Notify(ctx context.Context, m Message) error {
+	host := t.Host
+	if host == "" {
+		host = TelegramHost
+	}
+	text := m.Title
+	if m.Body != "" {
+		text += "\n" + m.Body
+	}
+	body, err := json.Marshal(map[string]string{"chat_id": t.ChatID, "text": text})
+	if err != nil {
+		return err
+	}
+	err = post(ctx, defaultClient(t.Client), host+"/bot"+t.Token+"/sendMessage", body)
+	if err != nil {
+		// The token is part of the URL, which net/http echoes in errors.
+		return errors.New(strings.ReplaceAll(err.Error(), t.Token, "<token>"))
+	}
+	return nil
+}
+
+// post sends a JSON body and fails on a non-2xx response.
+func post(ctx context.Context, client *http.Client, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
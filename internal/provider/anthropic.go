@@ -7,12 +7,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/egress"
 )
 
 const (
@@ -28,15 +28,18 @@ const (
 // encoding failures. TLSNextProto is left nil so Go auto-negotiates HTTP/2,
 // which uses its own binary framing instead of chunked transfer encoding,
 // avoiding Go 1.25+'s strict bare-LF rejection (CVE-2025-22871).
+// Proxy routing is per request; see proxyForRequest. Requests pass through
+// the egress policy before being sent.
 var streamHTTPClient = &http.Client{
-	Transport: &http.Transport{
+	Transport: egress.Transport(&http.Transport{
+		Proxy:                 proxyForRequest,
 		TLSHandshakeTimeout:   tlsHandshakeTimeout,
 		ResponseHeaderTimeout: responseHeaderTimeout,
 		IdleConnTimeout:       idleConnTimeout,
 		DisableCompression:    true,
 		ForceAttemptHTTP2:     true,
 		MaxIdleConnsPerHost:   4,
-	},
+	}),
 }
 
 // CloseIdleConnections drops all idle connections from the shared HTTP
@@ -89,7 +92,7 @@ func StreamMessagePureWithURL(
 	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	blocks, stopReason, usage, _, err := anthropicStreamWithURL(
-		apiURL, apiKey, modelID, history, tools, system, onDelta, "",
+		apiURL, apiKey, modelID, history, tools, system, onDelta, "", Sampling{},
 	)
 	return blocks, stopReason, usage, err
 }
@@ -110,14 +113,14 @@ func (p *AnthropicProvider) Name() string { return "anthropic" }
 
 // FetchModels retrieves the list of available models from the Anthropic API.
 func (p *AnthropicProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
-	httpReq, err := http.NewRequest(http.MethodGet, "https://api.anthropic.com/v1/models?limit=100", nil)
+	httpReq, err := newProviderRequest("anthropic", http.MethodGet, "https://api.anthropic.com/v1/models?limit=100", nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("x-api-key", apiKey)
 	httpReq.Header.Set("anthropic-version", "2023-06-01")
 
-	client := &http.Client{Timeout: fetchModelsTimeout}
+	client := &http.Client{Timeout: fetchModelsTimeout, Transport: streamHTTPClient.Transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -153,13 +156,26 @@ func (p *AnthropicProvider) StreamMessage(
 	tools []ToolSpec,
 	system string,
 	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters. The
+// Messages API has no seed, so Sampling.Seed is ignored.
+func (p *AnthropicProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	p.mu.Lock()
 	containerID := p.containerID
 	p.mu.Unlock()
 
 	blocks, stopReason, usage, newContainer, err := anthropicStreamWithURL(
-		AnthropicMessagesURL, apiKey, modelID, history, tools, system, onDelta, containerID,
+		AnthropicMessagesURL, apiKey, modelID, history, tools, system, onDelta, containerID, sampling,
 	)
 
 	if newContainer != "" {
@@ -275,6 +291,9 @@ type anthropicRequest struct {
 	System            []anthropicSystemBlock `json:"system,omitempty"`
 	Container         string                 `json:"container,omitempty"` // PTC container reuse
 	ContextManagement *anthropicContextMgmt  `json:"context_management,omitempty"`
+	Temperature       *float64               `json:"temperature,omitempty"`
+	TopP              *float64               `json:"top_p,omitempty"`
+	Stop              []string               `json:"stop_sequences,omitempty"`
 }
 
 // anthropicContextMgmt enables server-side context management features.
@@ -500,6 +519,7 @@ func anthropicStreamWithURL(
 	system string,
 	onDelta func(string),
 	containerID string,
+	sampling Sampling,
 ) ([]domain.ContentBlock, string, Usage, string, error) {
 	msgs := buildAnthropicMessages(history)
 
@@ -516,13 +536,19 @@ func anthropicStreamWithURL(
 	}
 
 	reqBody := anthropicRequest{
-		Model:     modelID,
-		MaxTokens: 16384,
-		Messages:  msgs,
-		Stream:    true,
-		Tools:     toAnthropicTools(tools, modelID),
-		System:    systemBlocks,
-		Container: containerID,
+		Model:       modelID,
+		MaxTokens:   anthropicDefaultMaxTokens,
+		Messages:    msgs,
+		Stream:      true,
+		Tools:       toAnthropicTools(tools, modelID),
+		System:      systemBlocks,
+		Container:   containerID,
+		Temperature: sampling.Temperature,
+		TopP:        sampling.TopP,
+		Stop:        sampling.Stop,
+	}
+	if sampling.MaxTokens > 0 {
+		reqBody.MaxTokens = sampling.MaxTokens
 	}
 
 	// Server-side compaction only for models that support it (not Haiku).
@@ -539,7 +565,7 @@ func anthropicStreamWithURL(
 		return nil, "", Usage{}, "", fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, apiURL, bytes.NewReader(body))
+	httpReq, err := newProviderRequest("anthropic", http.MethodPost, apiURL, bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, "", fmt.Errorf("creating request: %w", err)
 	}
@@ -832,16 +858,18 @@ func assembleBlocks(blocks []streamBlock) []domain.ContentBlock {
 			})
 		case "tool_use":
 			input := map[string]any{}
+			var inputErr string
 			if jsonStr := sb.jsonBuf.String(); jsonStr != "" {
 				if err := json.Unmarshal([]byte(jsonStr), &input); err != nil {
-					fmt.Fprintf(os.Stderr, "anthropic: unmarshal tool input: %v\n", err)
+					input, inputErr = map[string]any{}, "arguments are not valid JSON: "+err.Error()
 				}
 			}
 			block := domain.ContentBlock{
-				Type:      "tool_use",
-				ToolUseID: sb.toolID,
-				ToolName:  sb.toolName,
-				ToolInput: input,
+				Type:       "tool_use",
+				ToolUseID:  sb.toolID,
+				ToolName:   sb.toolName,
+				ToolInput:  input,
+				InputError: inputErr,
 			}
 			if sb.callerType != "" {
 				block.CallerType = sb.callerType
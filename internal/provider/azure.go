@@ -0,0 +1,259 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// AzureDefaultAPIVersion is the Azure OpenAI API version used when none is
+// configured.
+const AzureDefaultAPIVersion = "2024-10-21"
+
+// azureADScope is the OAuth scope of Azure OpenAI (Cognitive Services).
+const azureADScope = "https://cognitiveservices.azure.com/.default"
+
+// azureAuthorityURL is the Microsoft identity platform, overridden in tests.
+var azureAuthorityURL = "https://login.microsoftonline.com"
+
+var (
+	azureMu          sync.Mutex
+	azureEndpointURL string
+	azureAPIVersion  = AzureDefaultAPIVersion
+	azureDeployments []string
+
+	azureTenantID, azureClientID, azureClientSecret string
+
+	azureToken       string
+	azureTokenExpiry time.Time
+)
+
+// SetAzureEndpoint configures the Azure OpenAI resource, e.g.
+// https://my-resource.openai.azure.com, the API version (empty for
+// AzureDefaultAPIVersion), and the deployments offered by FetchModels.
+func SetAzureEndpoint(endpoint, apiVersion string, deployments []string) {
+	azureMu.Lock()
+	defer azureMu.Unlock()
+	azureEndpointURL = strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	azureAPIVersion = strings.TrimSpace(apiVersion)
+	if azureAPIVersion == "" {
+		azureAPIVersion = AzureDefaultAPIVersion
+	}
+	azureDeployments = deployments
+}
+
+// SetAzureADCredentials configures Azure AD (Entra ID) client credentials.
+// When all three are set, requests carry a bearer token obtained for the
+// service principal instead of an api-key header.
+func SetAzureADCredentials(tenantID, clientID, clientSecret string) {
+	azureMu.Lock()
+	defer azureMu.Unlock()
+	azureTenantID, azureClientID, azureClientSecret = tenantID, clientID, clientSecret
+	azureToken, azureTokenExpiry = "", time.Time{}
+}
+
+// AzureADConfigured reports whether Azure AD credentials are set, in which
+// case no API key is needed.
+func AzureADConfigured() bool {
+	azureMu.Lock()
+	defer azureMu.Unlock()
+	return azureADConfiguredLocked()
+}
+
+func azureADConfiguredLocked() bool {
+	return azureTenantID != "" && azureClientID != "" && azureClientSecret != ""
+}
+
+// azureADToken returns a cached access token, fetching a new one when it is
+// missing or about to expire.
+func azureADToken() (string, error) {
+	azureMu.Lock()
+	defer azureMu.Unlock()
+	if azureToken != "" && time.Until(azureTokenExpiry) > 5*time.Minute {
+		return azureToken, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {azureClientID},
+		"client_secret": {azureClientSecret},
+		"scope":         {azureADScope},
+	}
+	tokenURL := azureAuthorityURL + "/" + url.PathEscape(azureTenantID) + "/oauth2/v2.0/token"
+	req, err := newProviderRequest("azure", http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := &http.Client{Transport: streamHTTPClient.Transport, Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("azure ad token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var tok struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("azure ad token: HTTP %d: decoding response: %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= 400 || tok.AccessToken == "" {
+		msg := tok.ErrorDescription
+		if msg == "" {
+			msg = tok.Error
+		}
+		return "", fmt.Errorf("azure ad token: HTTP %d: %s", resp.StatusCode, msg)
+	}
+	azureToken = tok.AccessToken
+	azureTokenExpiry = time.Now().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return azureToken, nil
+}
+
+// AzureProvider implements Provider for Azure OpenAI. The model ID is the
+// deployment name, so "azure/<deployment>" selects a deployment.
+type AzureProvider struct{}
+
+// Name returns "azure".
+func (p *AzureProvider) Name() string { return "azure" }
+
+// azureRequest builds a request for path under the configured resource,
+// authenticated with Azure AD when configured and the API key otherwise.
+func azureRequest(method, path string, body io.Reader, apiKey string) (*http.Request, error) {
+	azureMu.Lock()
+	endpoint, version, useAD := azureEndpointURL, azureAPIVersion, azureADConfiguredLocked()
+	azureMu.Unlock()
+	if endpoint == "" {
+		return nil, fmt.Errorf("azure endpoint not configured; use /config set azure.endpoint https://<resource>.openai.azure.com")
+	}
+
+	req, err := newProviderRequest("azure", method, endpoint+path+"?api-version="+url.QueryEscape(version), body)
+	if err != nil {
+		return nil, err
+	}
+	if useAD {
+		token, err := azureADToken()
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Set("api-key", apiKey)
+	}
+	return req, nil
+}
+
+// FetchModels returns the configured deployments. Azure lists deployments
+// only through its management API, so they are not discovered.
+func (p *AzureProvider) FetchModels(_ string) ([]domain.APIModelInfo, error) {
+	azureMu.Lock()
+	deployments := azureDeployments
+	azureMu.Unlock()
+	if len(deployments) == 0 {
+		return nil, fmt.Errorf("no azure deployments configured; use /config set azure.deployments <name>,<name>")
+	}
+	models := make([]domain.APIModelInfo, len(deployments))
+	for i, d := range deployments {
+		models[i] = domain.APIModelInfo{ID: d}
+	}
+	return models, nil
+}
+
+// StreamMessage sends a streaming chat completion request to a deployment.
+func (p *AzureProvider) StreamMessage(
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *AzureProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	msgs := buildOpenAIMessages(history, system)
+	streamOpts := &struct {
+		IncludeUsage bool `json:"include_usage"`
+	}{IncludeUsage: true}
+
+	reqBody := openaiRequest{
+		Model:         modelID,
+		Messages:      msgs,
+		Stream:        true,
+		Tools:         toOpenAITools(tools),
+		StreamOptions: streamOpts,
+	}
+	sampling.applyOpenAI(&reqBody, p.Name())
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := azureRequest(http.MethodPost, "/openai/deployments/"+url.PathEscape(modelID)+"/chat/completions", bytes.NewReader(body), apiKey)
+	if err != nil {
+		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := streamHTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, "", Usage{}, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		raw, _ := io.ReadAll(resp.Body)
+		errType := ""
+		errMessage := string(raw)
+		if errMessage == "" {
+			errMessage = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		}
+		var errResp struct {
+			Error *struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+				Code    string `json:"code"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &errResp) == nil && errResp.Error != nil {
+			errType = errResp.Error.Type
+			if errType == "" {
+				errType = errResp.Error.Code // e.g. DeploymentNotFound, content_filter
+			}
+			errMessage = errResp.Error.Message
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			errMessage += fmt.Sprintf(" (is %q a deployment name on this resource?)", modelID)
+		}
+		if resp.StatusCode == 400 && historyHasImages(history) {
+			errMessage += " (this model may not support images — try a vision-capable model)"
+		}
+		return nil, "", Usage{}, NewAPIError(resp.StatusCode, errType, errMessage, resp.Header)
+	}
+
+	tr := newTimeoutReader(resp.Body)
+	defer func() { _ = tr.Close() }()
+	return parseOpenAISSE(tr, onDelta)
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func azureChatServer(t *testing.T, checkAuth func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/my-gpt4o/chat/completions" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("api-version"); got != "2025-01-01-preview" {
+			t.Errorf("api-version = %q", got)
+		}
+		checkAuth(r)
+		w.Header().Set("Content-Type", "text/event-stream")
+		// Azure sends a prompt filter chunk with no choices first.
+		fmt.Fprint(w, "data: {\"choices\":[],\"prompt_filter_results\":[{\"prompt_index\":0}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":7,\"completion_tokens\":1}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func resetAzure() {
+	SetAzureEndpoint("", "", nil)
+	SetAzureADCredentials("", "", "")
+}
+
+func TestAzureProvider_APIKey(t *testing.T) {
+	srv := azureChatServer(t, func(r *http.Request) {
+		if r.Header.Get("api-key") != "az-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("auth headers = %v", r.Header)
+		}
+	})
+	defer srv.Close()
+	SetAzureEndpoint(srv.URL+"/", "2025-01-01-preview", nil)
+	defer resetAzure()
+
+	prov, model := ResolveProviderAndModel("azure/my-gpt4o", "")
+	if prov != "azure" || model != "my-gpt4o" {
+		t.Fatalf("resolved (%q, %q)", prov, model)
+	}
+	p, err := GetProvider(prov)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := []domain.TranscriptMessage{{Role: "user", Content: "hello"}}
+	blocks, stop, usage, err := p.StreamMessage("az-key", model, history, nil, "", nil)
+	if err != nil {
+		t.Fatalf("StreamMessage: %v", err)
+	}
+	if len(blocks) != 1 || blocks[0].Text != "Hi" || stop != "end_turn" || usage.InputTokens != 7 || usage.OutputTokens != 1 {
+		t.Errorf("blocks=%+v stop=%q usage=%+v", blocks, stop, usage)
+	}
+	if !RequiresAPIKey("azure") {
+		t.Error("azure without AD credentials should need a key")
+	}
+}
+
+func TestAzureProvider_ADToken(t *testing.T) {
+	var tokenRequests atomic.Int32
+	authority := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		if r.URL.Path != "/tenant-1/oauth2/v2.0/token" {
+			t.Errorf("token path = %s", r.URL.Path)
+		}
+		_ = r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "app-1" ||
+			r.Form.Get("client_secret") != "s3cret" || r.Form.Get("scope") != azureADScope {
+			t.Errorf("token form = %v", r.Form)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "ad-token", "expires_in": 3600})
+	}))
+	defer authority.Close()
+	origAuthority := azureAuthorityURL
+	azureAuthorityURL = authority.URL
+	defer func() { azureAuthorityURL = origAuthority }()
+
+	srv := azureChatServer(t, func(r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ad-token" || r.Header.Get("api-key") != "" {
+			t.Errorf("auth headers = %v", r.Header)
+		}
+	})
+	defer srv.Close()
+	SetAzureEndpoint(srv.URL, "2025-01-01-preview", nil)
+	SetAzureADCredentials("tenant-1", "app-1", "s3cret")
+	defer resetAzure()
+
+	if RequiresAPIKey("azure") {
+		t.Error("azure with AD credentials should not need a key")
+	}
+	p := &AzureProvider{}
+	for range 2 {
+		if _, _, _, err := p.StreamMessage("", "my-gpt4o", nil, nil, "", nil); err != nil {
+			t.Fatalf("StreamMessage: %v", err)
+		}
+	}
+	if n := tokenRequests.Load(); n != 1 {
+		t.Errorf("token requested %d times, want it cached", n)
+	}
+}
+
+func TestAzureProvider_ADTokenError(t *testing.T) {
+	authority := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "AADSTS7000215: Invalid client secret provided."})
+	}))
+	defer authority.Close()
+	origAuthority := azureAuthorityURL
+	azureAuthorityURL = authority.URL
+	defer func() { azureAuthorityURL = origAuthority }()
+
+	SetAzureEndpoint("https://example.openai.azure.com", "", nil)
+	SetAzureADCredentials("t", "c", "wrong")
+	defer resetAzure()
+
+	_, _, _, err := (&AzureProvider{}).StreamMessage("", "d", nil, nil, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "Invalid client secret") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestAzureProvider_FetchModels(t *testing.T) {
+	defer resetAzure()
+	if _, err := (&AzureProvider{}).FetchModels(""); err == nil {
+		t.Error("expected an error with no deployments configured")
+	}
+	SetAzureEndpoint("https://example.openai.azure.com", "", []string{"gpt-4o-prod", "gpt-4o-mini"})
+	models, err := (&AzureProvider{}).FetchModels("")
+	if err != nil || len(models) != 2 || models[1].ID != "gpt-4o-mini" {
+		t.Errorf("models = %+v, %v", models, err)
+	}
+
+	SetAzureEndpoint("", "", nil)
+	_, _, _, err = (&AzureProvider{}).StreamMessage("k", "d", nil, nil, "", nil)
+	if err == nil || !strings.Contains(err.Error(), "azure.endpoint") {
+		t.Errorf("unconfigured endpoint: err = %v", err)
+	}
+}
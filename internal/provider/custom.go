@@ -0,0 +1,228 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// customEndpoint is a registered OpenAI-compatible server.
+type customEndpoint struct {
+	baseURL string
+	models  []string
+}
+
+var (
+	customMu        sync.RWMutex
+	customEndpoints = map[string]customEndpoint{}
+)
+
+// SetCustomProvider registers an OpenAI-compatible endpoint under name, so
+// "<name>/<model>" resolves to it. An empty baseURL unregisters it. models,
+// if set, is returned by FetchModels instead of asking the server.
+func SetCustomProvider(name, baseURL string, models []string) {
+	customMu.Lock()
+	defer customMu.Unlock()
+	name = strings.ToLower(name)
+	if baseURL == "" {
+		delete(customEndpoints, name)
+		return
+	}
+	customEndpoints[name] = customEndpoint{baseURL: strings.TrimRight(baseURL, "/"), models: models}
+}
+
+// IsCustomProvider reports whether name is a registered custom endpoint.
+func IsCustomProvider(name string) bool {
+	_, ok := lookupCustom(name)
+	return ok
+}
+
+func lookupCustom(name string) (customEndpoint, bool) {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	ep, ok := customEndpoints[strings.ToLower(name)]
+	return ep, ok
+}
+
+// customNames returns the registered names, sorted.
+func customNames() []string {
+	customMu.RLock()
+	defer customMu.RUnlock()
+	names := make([]string, 0, len(customEndpoints))
+	for name := range customEndpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// customProviderForModel returns the custom provider whose configured model
+// list contains modelID.
+func customProviderForModel(modelID string) (string, bool) {
+	for _, name := range customNames() {
+		if ep, ok := lookupCustom(name); ok && slices.Contains(ep.models, modelID) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// CustomProvider implements Provider for a user-configured OpenAI-compatible
+// endpoint such as vLLM, LM Studio, Together, or an internal gateway.
+type CustomProvider struct {
+	name string
+}
+
+// Name returns the name the endpoint was registered under.
+func (p *CustomProvider) Name() string { return p.name }
+
+func (p *CustomProvider) endpoint() (customEndpoint, error) {
+	ep, ok := lookupCustom(p.name)
+	if !ok {
+		return customEndpoint{}, fmt.Errorf("custom provider %s is not configured; set providers.custom.%s.base_url", p.name, p.name)
+	}
+	return ep, nil
+}
+
+// setAuth sends the key as a bearer token. Local servers often need none.
+func setAuth(req *http.Request, apiKey string) {
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// FetchModels returns the configured model list, or asks the server's
+// /models endpoint when none is configured.
+func (p *CustomProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
+	ep, err := p.endpoint()
+	if err != nil {
+		return nil, err
+	}
+	if len(ep.models) > 0 {
+		models := make([]domain.APIModelInfo, len(ep.models))
+		for i, id := range ep.models {
+			models[i] = domain.APIModelInfo{ID: id}
+		}
+		return models, nil
+	}
+
+	httpReq, err := newProviderRequest(p.name, http.MethodGet, ep.baseURL+"/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	setAuth(httpReq, apiKey)
+
+	client := &http.Client{Transport: streamHTTPClient.Transport}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		raw, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(raw))
+	}
+
+	var listResp struct {
+		Data []domain.APIModelInfo `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+	return listResp.Data, nil
+}
+
+// StreamMessage sends a streaming chat completion request to the endpoint.
+func (p *CustomProvider) StreamMessage(
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *CustomProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	ep, err := p.endpoint()
+	if err != nil {
+		return nil, "", Usage{}, err
+	}
+
+	msgs := buildOpenAIMessages(history, system)
+	streamOpts := &struct {
+		IncludeUsage bool `json:"include_usage"`
+	}{IncludeUsage: true}
+
+	reqBody := openaiRequest{
+		Model:         modelID,
+		Messages:      msgs,
+		Stream:        true,
+		Tools:         toOpenAITools(tools),
+		StreamOptions: streamOpts,
+	}
+	sampling.applyOpenAI(&reqBody, p.Name())
+
+	body, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
+	}
+
+	httpReq, err := newProviderRequest(p.name, http.MethodPost, ep.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept-Encoding", "identity")
+	setAuth(httpReq, apiKey)
+
+	resp, err := streamHTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, "", Usage{}, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		raw, _ := io.ReadAll(resp.Body)
+		errType := ""
+		errMessage := string(raw)
+		if errMessage == "" {
+			errMessage = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		}
+		var errResp struct {
+			Error *struct {
+				Message string `json:"message"`
+				Type    string `json:"type"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(raw, &errResp) == nil && errResp.Error != nil {
+			errType = errResp.Error.Type
+			errMessage = errResp.Error.Message
+		}
+		if resp.StatusCode == 400 && historyHasImages(history) {
+			errMessage += " (this model may not support images — try a vision-capable model)"
+		}
+		return nil, "", Usage{}, NewAPIError(resp.StatusCode, errType, errMessage, resp.Header)
+	}
+
+	tr := newTimeoutReader(resp.Body)
+	defer func() { _ = tr.Close() }()
+	return parseOpenAISSE(tr, onDelta)
+}
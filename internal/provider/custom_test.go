@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestCustomProvider_StreamMessage(t *testing.T) {
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("path = %s, want /v1/chat/completions", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer gw-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Hel\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"lo\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":12,\"completion_tokens\":3}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	SetCustomProvider("gateway", srv.URL+"/v1/", nil)
+	defer SetCustomProvider("gateway", "", nil)
+
+	prov, err := GetProvider("gateway")
+	if err != nil {
+		t.Fatalf("GetProvider: %v", err)
+	}
+	if prov.Name() != "gateway" {
+		t.Errorf("Name() = %q", prov.Name())
+	}
+	var streamed string
+	history := []domain.TranscriptMessage{{Role: "user", Content: "hi"}}
+	blocks, stop, usage, err := prov.StreamMessage("gw-key", "llama-3-70b", history, nil, "be brief", func(s string) { streamed += s })
+	if err != nil {
+		t.Fatalf("StreamMessage: %v", err)
+	}
+	if streamed != "Hello" || len(blocks) != 1 || blocks[0].Text != "Hello" {
+		t.Errorf("streamed %q, blocks %+v", streamed, blocks)
+	}
+	if stop != "end_turn" {
+		t.Errorf("stop = %q, want end_turn", stop)
+	}
+	if usage.InputTokens != 12 || usage.OutputTokens != 3 {
+		t.Errorf("usage = %+v", usage)
+	}
+	if gotBody["model"] != "llama-3-70b" || gotBody["stream"] != true {
+		t.Errorf("request body = %v", gotBody)
+	}
+}
+
+func TestCustomProvider_noKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization = %q, want none", got)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": []map[string]string{{"id": "qwen2.5-coder"}}})
+	}))
+	defer srv.Close()
+
+	SetCustomProvider("lmstudio", srv.URL, nil)
+	defer SetCustomProvider("lmstudio", "", nil)
+
+	models, err := (&CustomProvider{name: "lmstudio"}).FetchModels("")
+	if err != nil {
+		t.Fatalf("FetchModels: %v", err)
+	}
+	if len(models) != 1 || models[0].ID != "qwen2.5-coder" {
+		t.Errorf("models = %+v", models)
+	}
+}
+
+func TestCustomProvider_configuredModels(t *testing.T) {
+	SetCustomProvider("vllm", "http://127.0.0.1:1/v1", []string{"meta-llama/Llama-3.1-8B", "mixtral"})
+	defer SetCustomProvider("vllm", "", nil)
+
+	models, err := (&CustomProvider{name: "vllm"}).FetchModels("")
+	if err != nil {
+		t.Fatalf("FetchModels: %v", err)
+	}
+	if len(models) != 2 || models[0].ID != "meta-llama/Llama-3.1-8B" {
+		t.Errorf("models = %+v", models)
+	}
+
+	for spec, want := range map[string][2]string{
+		"vllm/meta-llama/Llama-3.1-8B": {"vllm", "meta-llama/Llama-3.1-8B"},
+		"VLLM/mixtral":                 {"vllm", "mixtral"},
+		"mixtral":                      {"vllm", "mixtral"},
+		"claude-sonnet":                {"anthropic", ResolveModel("claude-sonnet")},
+	} {
+		prov, model := ResolveProviderAndModel(spec, "openai")
+		if prov != want[0] || model != want[1] {
+			t.Errorf("ResolveProviderAndModel(%q) = (%q, %q), want %v", spec, prov, model, want)
+		}
+	}
+}
+
+func TestCustomProvider_unregistered(t *testing.T) {
+	if _, err := GetProvider("nowhere"); err == nil {
+		t.Error("expected error for an unregistered provider")
+	}
+	SetCustomProvider("gone", "http://localhost:8000/v1", nil)
+	p, err := GetProvider("gone")
+	if err != nil {
+		t.Fatalf("GetProvider: %v", err)
+	}
+	SetCustomProvider("gone", "", nil)
+	if _, _, _, err := p.StreamMessage("", "m", nil, nil, "", nil); err == nil {
+		t.Error("expected error after the provider was removed")
+	}
+}
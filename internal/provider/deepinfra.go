@@ -21,13 +21,13 @@ func (p *DeepInfraProvider) Name() string { return "deepinfra" }
 
 // FetchModels retrieves the list of models from DeepInfra.
 func (p *DeepInfraProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
-	httpReq, err := http.NewRequest(http.MethodGet, deepinfraAPIBaseURL+"/models", nil)
+	httpReq, err := newProviderRequest("deepinfra", http.MethodGet, deepinfraAPIBaseURL+"/models", nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
+	client := &http.Client{Transport: streamHTTPClient.Transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -55,6 +55,18 @@ func (p *DeepInfraProvider) StreamMessage(
 	tools []ToolSpec,
 	system string,
 	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *DeepInfraProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	msgs := buildOpenAIMessages(history, system)
 	streamOpts := &struct {
@@ -68,13 +80,14 @@ func (p *DeepInfraProvider) StreamMessage(
 		Tools:         toOpenAITools(tools),
 		StreamOptions: streamOpts,
 	}
+	sampling.applyOpenAI(&reqBody, p.Name())
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, deepinfraAPIBaseURL+"/chat/completions", bytes.NewReader(body))
+	httpReq, err := newProviderRequest("deepinfra", http.MethodPost, deepinfraAPIBaseURL+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -6,6 +6,8 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
 )
 
 // APIError represents a structured API error with retry metadata.
@@ -41,6 +43,59 @@ func (e *APIError) IsRetryable() bool {
 	return false
 }
 
+// contextExceededMarkers are phrases providers use when a request does not
+// fit the model's context window, matched case-insensitively.
+var contextExceededMarkers = []string{
+	"prompt is too long",           // Anthropic
+	"context_length_exceeded",      // OpenAI, Azure
+	"maximum context length",       // OpenAI-compatible servers
+	"context window",               // Mistral, Grok
+	"too many tokens",              // Fireworks, DeepInfra
+	"exceeds the context",          // Ollama
+	"input length and `max_tokens", // Anthropic, with max_tokens
+}
+
+// ErrorCode classifies the error by status code, provider error type, and,
+// for context overflows that providers report as plain bad requests, the
+// message.
+func (e *APIError) ErrorCode() domain.ErrorCode {
+	lower := strings.ToLower(e.ErrorType + " " + e.Message)
+	for _, marker := range contextExceededMarkers {
+		if strings.Contains(lower, marker) {
+			return domain.ErrContextExceeded
+		}
+	}
+	switch e.ErrorType {
+	case "rate_limit_error", "rate_limit_exceeded":
+		return domain.ErrRateLimited
+	case "overloaded_error":
+		return domain.ErrOverloaded
+	case "authentication_error", "permission_error", "invalid_api_key":
+		return domain.ErrAuthInvalid
+	case "not_found_error", "model_not_found", "DeploymentNotFound":
+		return domain.ErrModelNotFound
+	}
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return domain.ErrRateLimited
+	case e.StatusCode == http.StatusServiceUnavailable || e.StatusCode == 529:
+		return domain.ErrOverloaded
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return domain.ErrAuthInvalid
+	case e.StatusCode == http.StatusNotFound:
+		return domain.ErrModelNotFound
+	case e.StatusCode == http.StatusRequestEntityTooLarge:
+		return domain.ErrContextExceeded
+	case e.StatusCode >= 500:
+		return domain.ErrProviderFailed
+	case e.StatusCode >= 400:
+		return domain.ErrInvalidRequest
+	case e.ErrorType == "api_error":
+		return domain.ErrProviderFailed
+	}
+	return domain.ErrUnknown
+}
+
 // NewAPIError creates an APIError from HTTP response metadata.
 func NewAPIError(statusCode int, errorType, message string, header http.Header) *APIError {
 	return &APIError{
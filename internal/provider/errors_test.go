@@ -1,9 +1,12 @@
 package provider
 
 import (
+	"fmt"
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
 )
 
 func TestAPIError_Error(t *testing.T) {
@@ -171,3 +174,36 @@ func TestParseRetryAfter(t *testing.T) {
 		})
 	}
 }
+
+func TestAPIError_ErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  APIError
+		want domain.ErrorCode
+	}{
+		{"anthropic rate limit", APIError{StatusCode: 429, ErrorType: "rate_limit_error"}, domain.ErrRateLimited},
+		{"bare 429", APIError{StatusCode: 429}, domain.ErrRateLimited},
+		{"overloaded", APIError{StatusCode: 529, ErrorType: "overloaded_error"}, domain.ErrOverloaded},
+		{"mid-stream overloaded", APIError{ErrorType: "overloaded_error"}, domain.ErrOverloaded},
+		{"anthropic auth", APIError{StatusCode: 401, ErrorType: "authentication_error", Message: "invalid x-api-key"}, domain.ErrAuthInvalid},
+		{"openai key", APIError{StatusCode: 401, ErrorType: "invalid_request_error", Message: "Incorrect API key provided"}, domain.ErrAuthInvalid},
+		{"anthropic prompt too long", APIError{StatusCode: 400, ErrorType: "invalid_request_error", Message: "prompt is too long: 210000 tokens > 200000 maximum"}, domain.ErrContextExceeded},
+		{"openai context", APIError{StatusCode: 400, ErrorType: "context_length_exceeded", Message: "This model's maximum context length is 128000 tokens."}, domain.ErrContextExceeded},
+		{"payload too large", APIError{StatusCode: 413}, domain.ErrContextExceeded},
+		{"azure deployment", APIError{StatusCode: 404, ErrorType: "DeploymentNotFound"}, domain.ErrModelNotFound},
+		{"bad request", APIError{StatusCode: 400, ErrorType: "invalid_request_error", Message: "tools.0: bad schema"}, domain.ErrInvalidRequest},
+		{"server error", APIError{StatusCode: 500}, domain.ErrProviderFailed},
+		{"no status", APIError{Message: "stream ended"}, domain.ErrUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.ErrorCode(); got != tt.want {
+				t.Errorf("ErrorCode() = %q, want %q", got, tt.want)
+			}
+			wrapped := fmt.Errorf("turn: %w", &tt.err)
+			if got := domain.ErrorCodeOf(wrapped); got != tt.want {
+				t.Errorf("ErrorCodeOf(wrapped) = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
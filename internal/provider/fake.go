@@ -0,0 +1,205 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Fake provider
+// ---------------------------------------------------------------------------
+
+// FakeScriptEnv names a JSON file of FakeSteps the "fake" provider plays
+// back, so integrations can be tested against a real daemon without a
+// model: MUXD_FAKE_SCRIPT=script.json muxd --daemon --model fake/scripted.
+const FakeScriptEnv = "MUXD_FAKE_SCRIPT"
+
+// FakeStep is one scripted model reply: streamed text, tool calls, or an
+// error in place of a reply.
+type FakeStep struct {
+	Text      string         `json:"text,omitempty"`
+	ToolCalls []FakeToolCall `json:"tool_calls,omitempty"`
+	Error     *FakeError     `json:"error,omitempty"`
+	DelayMs   int            `json:"delay_ms,omitempty"` // wait before replying
+
+	// Token counts to report; zero estimates them from the request.
+	InputTokens  int `json:"input_tokens,omitempty"`
+	OutputTokens int `json:"output_tokens,omitempty"`
+}
+
+// FakeToolCall is a tool call a FakeStep makes.
+type FakeToolCall struct {
+	Name  string         `json:"name"`
+	Input map[string]any `json:"input,omitempty"`
+}
+
+// FakeError is an API error a FakeStep returns instead of a reply.
+type FakeError struct {
+	Status       int    `json:"status"`
+	Type         string `json:"type,omitempty"`
+	Message      string `json:"message,omitempty"`
+	RetryAfterMs int    `json:"retry_after_ms,omitempty"`
+}
+
+// FakeRateLimit is a step that fails with a 429 the agent retries after
+// retryAfter.
+func FakeRateLimit(retryAfter time.Duration) FakeStep {
+	return FakeStep{Error: &FakeError{
+		Status: 429, Type: "rate_limit_error", Message: "fake rate limit", RetryAfterMs: int(retryAfter.Milliseconds()),
+	}}
+}
+
+// FakeCall is a request the fake provider received.
+type FakeCall struct {
+	Model   string
+	System  string
+	History []domain.TranscriptMessage
+	Tools   []string
+}
+
+// FakeProvider plays back scripted replies, one per agent turn, and
+// records every request. Requests that offer no tools (titles,
+// compaction summaries, verification) do not use the script. Once the
+// script runs out it echoes the last user message.
+type FakeProvider struct {
+	mu     sync.Mutex
+	script []FakeStep
+	calls  []FakeCall
+	nextID int
+}
+
+// NewFakeProvider returns a fake provider that plays steps in order.
+func NewFakeProvider(steps ...FakeStep) *FakeProvider {
+	return &FakeProvider{script: steps}
+}
+
+// LoadFakeScript reads a JSON array of FakeSteps.
+func LoadFakeScript(path string) ([]FakeStep, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fake script: %w", err)
+	}
+	var steps []FakeStep
+	if err := json.Unmarshal(data, &steps); err != nil {
+		return nil, fmt.Errorf("parsing fake script %s: %w", path, err)
+	}
+	return steps, nil
+}
+
+// newFakeProviderFromEnv returns the fake provider, scripted from the
+// file FakeScriptEnv names when it is set.
+func newFakeProviderFromEnv() (*FakeProvider, error) {
+	path := strings.TrimSpace(os.Getenv(FakeScriptEnv))
+	if path == "" {
+		return NewFakeProvider(), nil
+	}
+	steps, err := LoadFakeScript(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFakeProvider(steps...), nil
+}
+
+// Push appends steps to the script.
+func (p *FakeProvider) Push(steps ...FakeStep) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.script = append(p.script, steps...)
+}
+
+// Remaining returns the number of steps not yet played.
+func (p *FakeProvider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.script)
+}
+
+// Calls returns the requests received so far.
+func (p *FakeProvider) Calls() []FakeCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeCall(nil), p.calls...)
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) FetchModels(string) ([]domain.APIModelInfo, error) {
+	return []domain.APIModelInfo{{ID: "scripted", DisplayName: "Fake (scripted)"}}, nil
+}
+
+func (p *FakeProvider) StreamMessage(
+	_, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	call := FakeCall{Model: modelID, System: system, History: append([]domain.TranscriptMessage(nil), history...)}
+	for _, t := range tools {
+		call.Tools = append(call.Tools, t.Name)
+	}
+
+	p.mu.Lock()
+	p.calls = append(p.calls, call)
+	step := FakeStep{Text: "echo: " + lastUserText(history)}
+	if len(tools) > 0 && len(p.script) > 0 {
+		step = p.script[0]
+		p.script = p.script[1:]
+	}
+	var blocks []domain.ContentBlock
+	if step.Text != "" {
+		blocks = append(blocks, domain.ContentBlock{Type: "text", Text: step.Text})
+	}
+	for _, tc := range step.ToolCalls {
+		p.nextID++
+		blocks = append(blocks, domain.ContentBlock{
+			Type:      "tool_use",
+			ToolUseID: fmt.Sprintf("fake_tool_%d", p.nextID),
+			ToolName:  tc.Name,
+			ToolInput: tc.Input,
+		})
+	}
+	p.mu.Unlock()
+
+	if step.DelayMs > 0 {
+		time.Sleep(time.Duration(step.DelayMs) * time.Millisecond)
+	}
+	if e := step.Error; e != nil {
+		return nil, "", Usage{}, &APIError{StatusCode: e.Status, ErrorType: e.Type, Message: e.Message, RetryAfterMs: e.RetryAfterMs}
+	}
+	if onDelta != nil && step.Text != "" {
+		for _, chunk := range strings.SplitAfter(step.Text, " ") {
+			onDelta(chunk)
+		}
+	}
+
+	stopReason := "end_turn"
+	if len(step.ToolCalls) > 0 {
+		stopReason = "tool_use"
+	}
+	usage := Usage{InputTokens: step.InputTokens, OutputTokens: step.OutputTokens}
+	if usage.InputTokens == 0 {
+		usage.InputTokens = EstimatePromptTokens(system, tools, history)
+	}
+	if usage.OutputTokens == 0 {
+		usage.OutputTokens = EstimateTokens(step.Text)
+	}
+	return blocks, stopReason, usage, nil
+}
+
+// lastUserText returns the text of the last user message, or "" when it
+// carries only tool results.
+func lastUserText(history []domain.TranscriptMessage) string {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Role == "user" {
+			return history[i].TextContent()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,110 @@
+package provider
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestFakeProvider(t *testing.T) {
+	tools := []ToolSpec{{Name: "file_read"}}
+	history := []domain.TranscriptMessage{{Role: "user", Content: "hello there"}}
+
+	t.Run("plays steps in order", func(t *testing.T) {
+		p := NewFakeProvider(
+			FakeStep{Text: "reading it", ToolCalls: []FakeToolCall{{Name: "file_read", Input: map[string]any{"path": "a.txt"}}}},
+			FakeStep{Text: "done", InputTokens: 7, OutputTokens: 3},
+		)
+		var deltas []string
+		blocks, stop, _, err := p.StreamMessage("", "scripted", history, tools, "sys", func(s string) { deltas = append(deltas, s) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stop != "tool_use" || len(blocks) != 2 || blocks[1].ToolName != "file_read" || blocks[1].ToolUseID == "" {
+			t.Errorf("first reply = %q %+v", stop, blocks)
+		}
+		if strings.Join(deltas, "") != "reading it" || len(deltas) != 2 {
+			t.Errorf("deltas = %q", deltas)
+		}
+
+		blocks, stop, usage, err := p.StreamMessage("", "scripted", history, tools, "sys", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stop != "end_turn" || len(blocks) != 1 || blocks[0].Text != "done" {
+			t.Errorf("second reply = %q %+v", stop, blocks)
+		}
+		if usage.InputTokens != 7 || usage.OutputTokens != 3 {
+			t.Errorf("usage = %+v", usage)
+		}
+		if p.Remaining() != 0 || len(p.Calls()) != 2 || p.Calls()[0].Tools[0] != "file_read" {
+			t.Errorf("remaining = %d, calls = %+v", p.Remaining(), p.Calls())
+		}
+	})
+
+	t.Run("echoes once the script runs out", func(t *testing.T) {
+		p := NewFakeProvider()
+		blocks, _, usage, err := p.StreamMessage("", "scripted", history, tools, "", nil)
+		if err != nil || len(blocks) != 1 || blocks[0].Text != "echo: hello there" {
+			t.Errorf("reply = %+v, %v", blocks, err)
+		}
+		if usage.InputTokens == 0 || usage.OutputTokens == 0 {
+			t.Errorf("usage not estimated: %+v", usage)
+		}
+	})
+
+	t.Run("requests without tools skip the script", func(t *testing.T) {
+		p := NewFakeProvider(FakeStep{Text: "scripted"})
+		blocks, _, _, _ := p.StreamMessage("", "scripted", history, nil, "title this", nil)
+		if blocks[0].Text != "echo: hello there" || p.Remaining() != 1 {
+			t.Errorf("reply = %+v, remaining %d", blocks, p.Remaining())
+		}
+	})
+
+	t.Run("induced errors", func(t *testing.T) {
+		p := NewFakeProvider(FakeRateLimit(50*time.Millisecond), FakeStep{Error: &FakeError{Status: 401, Type: "authentication_error", Message: "bad key"}})
+		_, _, _, err := p.StreamMessage("", "scripted", history, tools, "", nil)
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !apiErr.IsRetryable() || apiErr.RetryAfterMs != 50 || apiErr.ErrorCode() != domain.ErrRateLimited {
+			t.Errorf("rate limit error = %v", err)
+		}
+		_, _, _, err = p.StreamMessage("", "scripted", history, tools, "", nil)
+		if !errors.As(err, &apiErr) || apiErr.IsRetryable() || apiErr.ErrorCode() != domain.ErrAuthInvalid {
+			t.Errorf("auth error = %v", err)
+		}
+	})
+}
+
+func TestGetProviderFake(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script.json")
+	script := `[{"text":"hi","input_tokens":5},{"tool_calls":[{"name":"ask_user","input":{"question":"ok?"}}]},{"error":{"status":529,"type":"overloaded_error"}}]`
+	if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(FakeScriptEnv, path)
+
+	p, err := GetProvider("fake")
+	if err != nil {
+		t.Fatalf("GetProvider: %v", err)
+	}
+	fake, ok := p.(*FakeProvider)
+	if !ok || fake.Remaining() != 3 {
+		t.Fatalf("provider = %T with %d steps", p, fake.Remaining())
+	}
+	if RequiresAPIKey("fake") {
+		t.Error("the fake provider should not need an API key")
+	}
+	if prov, model := ResolveProviderAndModel("fake/scripted", "anthropic"); prov != "fake" || model != "scripted" {
+		t.Errorf("ResolveProviderAndModel = %s, %s", prov, model)
+	}
+
+	t.Setenv(FakeScriptEnv, filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := GetProvider("fake"); err == nil {
+		t.Error("expected an error for a missing script")
+	}
+}
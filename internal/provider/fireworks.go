@@ -38,13 +38,13 @@ func (p *FireworksProvider) Name() string { return "fireworks" }
 
 // FetchModels retrieves the list of models from Fireworks AI.
 func (p *FireworksProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
-	httpReq, err := http.NewRequest(http.MethodGet, fireworksAPIBaseURL+"/models", nil)
+	httpReq, err := newProviderRequest("fireworks", http.MethodGet, fireworksAPIBaseURL+"/models", nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
+	client := &http.Client{Transport: streamHTTPClient.Transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -72,6 +72,18 @@ func (p *FireworksProvider) StreamMessage(
 	tools []ToolSpec,
 	system string,
 	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *FireworksProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	msgs := buildOpenAIMessages(history, system)
 	streamOpts := &struct {
@@ -85,13 +97,14 @@ func (p *FireworksProvider) StreamMessage(
 		Tools:         toOpenAITools(tools),
 		StreamOptions: streamOpts,
 	}
+	sampling.applyOpenAI(&reqBody, p.Name())
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, fireworksAPIBaseURL+"/chat/completions", bytes.NewReader(body))
+	httpReq, err := newProviderRequest("fireworks", http.MethodPost, fireworksAPIBaseURL+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -21,13 +21,13 @@ func (p *GrokProvider) Name() string { return "grok" }
 
 // FetchModels retrieves the list of models from xAI.
 func (p *GrokProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
-	httpReq, err := http.NewRequest(http.MethodGet, grokAPIBaseURL+"/v1/models", nil)
+	httpReq, err := newProviderRequest("grok", http.MethodGet, grokAPIBaseURL+"/v1/models", nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
+	client := &http.Client{Transport: streamHTTPClient.Transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -55,6 +55,18 @@ func (p *GrokProvider) StreamMessage(
 	tools []ToolSpec,
 	system string,
 	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *GrokProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	msgs := buildOpenAIMessages(history, system)
 	streamOpts := &struct {
@@ -68,13 +80,14 @@ func (p *GrokProvider) StreamMessage(
 		Tools:         toOpenAITools(tools),
 		StreamOptions: streamOpts,
 	}
+	sampling.applyOpenAI(&reqBody, p.Name())
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, grokAPIBaseURL+"/v1/chat/completions", bytes.NewReader(body))
+	httpReq, err := newProviderRequest("grok", http.MethodPost, grokAPIBaseURL+"/v1/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -21,13 +21,13 @@ func (p *MistralProvider) Name() string { return "mistral" }
 
 // FetchModels retrieves the list of models from Mistral.
 func (p *MistralProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
-	httpReq, err := http.NewRequest(http.MethodGet, mistralAPIBaseURL+"/v1/models", nil)
+	httpReq, err := newProviderRequest("mistral", http.MethodGet, mistralAPIBaseURL+"/v1/models", nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
+	client := &http.Client{Transport: streamHTTPClient.Transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -55,6 +55,18 @@ func (p *MistralProvider) StreamMessage(
 	tools []ToolSpec,
 	system string,
 	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *MistralProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	msgs := buildOpenAIMessages(history, system)
 	reqBody := openaiRequest{
@@ -63,13 +75,14 @@ func (p *MistralProvider) StreamMessage(
 		Stream:   true,
 		Tools:    toOpenAITools(tools),
 	}
+	sampling.applyOpenAI(&reqBody, p.Name())
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, mistralAPIBaseURL+"/v1/chat/completions", bytes.NewReader(body))
+	httpReq, err := newProviderRequest("mistral", http.MethodPost, mistralAPIBaseURL+"/v1/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -5,7 +5,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"sort"
 	"strings"
@@ -37,38 +36,32 @@ type OllamaProvider struct{}
 func (p *OllamaProvider) Name() string { return "ollama" }
 
 func (p *OllamaProvider) FetchModels(_ string) ([]domain.APIModelInfo, error) {
-	req, err := http.NewRequest(http.MethodGet, ollamaBaseURL+"/api/tags", nil)
+	models, err := ListOllamaModels()
 	if err != nil {
 		return nil, err
 	}
-	resp, err := streamHTTPClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 400 {
-		raw, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("ollama HTTP %d: %s", resp.StatusCode, string(raw))
-	}
-
-	var parsed struct {
-		Models []struct {
-			Name string `json:"name"`
-		} `json:"models"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
-		return nil, fmt.Errorf("decoding ollama models: %w", err)
-	}
-
-	out := make([]domain.APIModelInfo, 0, len(parsed.Models))
-	for _, m := range parsed.Models {
+	out := make([]domain.APIModelInfo, 0, len(models))
+	for _, m := range models {
 		out = append(out, domain.APIModelInfo{ID: m.Name, DisplayName: m.Name})
 	}
 	return out, nil
 }
 
 func (p *OllamaProvider) StreamMessage(
+	apiKey string,
+	modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters, sent
+// as model options.
+func (p *OllamaProvider) StreamMessageWithSampling(
+	sampling Sampling,
 	_ string,
 	modelID string,
 	history []domain.TranscriptMessage,
@@ -78,11 +71,11 @@ func (p *OllamaProvider) StreamMessage(
 ) ([]domain.ContentBlock, string, Usage, error) {
 	messages := buildOllamaMessages(history, system)
 	toolDefs := toOllamaTools(tools)
-	blocks, stopReason, usage, err := streamOllamaChat(modelID, messages, toolDefs, onDelta)
+	blocks, stopReason, usage, err := streamOllamaChat(modelID, messages, toolDefs, sampling, onDelta)
 	if err != nil && len(toolDefs) > 0 && isOllamaToolsUnsupported(err) {
 		// Model supports chat but not tools (e.g. some Gemma variants).
 		// Retry without tools so the user still gets a response.
-		return streamOllamaChat(modelID, messages, nil, onDelta)
+		return streamOllamaChat(modelID, messages, nil, sampling, onDelta)
 	}
 	return blocks, stopReason, usage, err
 }
@@ -91,6 +84,7 @@ func streamOllamaChat(
 	modelID string,
 	messages []map[string]any,
 	toolDefs []map[string]any,
+	sampling Sampling,
 	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	reqBody := struct {
@@ -98,18 +92,37 @@ func streamOllamaChat(
 		Messages []map[string]any `json:"messages"`
 		Tools    []map[string]any `json:"tools,omitempty"`
 		Stream   bool             `json:"stream"`
+		Options  map[string]any   `json:"options,omitempty"`
 	}{
 		Model:    modelID,
 		Messages: messages,
 		Tools:    toolDefs,
 		Stream:   true,
 	}
+	if !sampling.IsZero() {
+		reqBody.Options = map[string]any{}
+		if sampling.Temperature != nil {
+			reqBody.Options["temperature"] = *sampling.Temperature
+		}
+		if sampling.TopP != nil {
+			reqBody.Options["top_p"] = *sampling.TopP
+		}
+		if sampling.Seed != nil {
+			reqBody.Options["seed"] = *sampling.Seed
+		}
+		if sampling.MaxTokens > 0 {
+			reqBody.Options["num_predict"] = sampling.MaxTokens
+		}
+		if len(sampling.Stop) > 0 {
+			reqBody.Options["stop"] = sampling.Stop
+		}
+	}
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	req, err := http.NewRequest(http.MethodPost, ollamaBaseURL+"/api/chat", bytes.NewReader(body))
+	req, err := newProviderRequest("ollama", http.MethodPost, ollamaBaseURL+"/api/chat", bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -122,17 +135,17 @@ func streamOllamaChat(
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		raw, _ := io.ReadAll(resp.Body)
-		return nil, "", Usage{}, fmt.Errorf("ollama HTTP %d: %s", resp.StatusCode, string(raw))
+		return nil, "", Usage{}, ollamaError(resp)
 	}
 
 	var text strings.Builder
 	usage := Usage{}
 	stopReason := "end_turn"
 	type toolBuilder struct {
-		id   string
-		name string
-		args map[string]any
+		id      string
+		name    string
+		args    map[string]any
+		argsErr string
 	}
 	toolBuilders := make(map[int]*toolBuilder)
 
@@ -184,7 +197,7 @@ func streamOllamaChat(
 				if tc.Function.Name != "" {
 					builder.name = tc.Function.Name
 				}
-				builder.args = parseOllamaArgs(tc.Function.Arguments)
+				builder.args, builder.argsErr = parseOllamaArgs(tc.Function.Arguments)
 			}
 		}
 		if chunk.PromptEvalCount > 0 {
@@ -221,10 +234,11 @@ func streamOllamaChat(
 				b.args = map[string]any{}
 			}
 			blocks = append(blocks, domain.ContentBlock{
-				Type:      "tool_use",
-				ToolUseID: b.id,
-				ToolName:  b.name,
-				ToolInput: b.args,
+				Type:       "tool_use",
+				ToolUseID:  b.id,
+				ToolName:   b.name,
+				ToolInput:  b.args,
+				InputError: b.argsErr,
 			})
 		}
 		stopReason = "tool_use"
@@ -392,25 +406,35 @@ func convertOllamaProp(v ToolProp) map[string]any {
 	return prop
 }
 
-func parseOllamaArgs(v any) map[string]any {
+// parseOllamaArgs decodes tool arguments, which Ollama sends as an object
+// or, from some models, as a JSON string. On failure it returns an empty map
+// and a description of the problem for the repair loop.
+func parseOllamaArgs(v any) (map[string]any, string) {
 	switch t := v.(type) {
+	case nil:
+		return map[string]any{}, ""
 	case map[string]any:
-		return t
+		return t, ""
 	case string:
+		if strings.TrimSpace(t) == "" {
+			return map[string]any{}, ""
+		}
 		var m map[string]any
-		if json.Unmarshal([]byte(t), &m) == nil {
-			return m
+		if err := json.Unmarshal([]byte(t), &m); err != nil {
+			return map[string]any{}, "arguments are not valid JSON: " + err.Error()
 		}
+		if m == nil {
+			m = map[string]any{}
+		}
+		return m, ""
 	}
-	if v != nil {
-		if b, err := json.Marshal(v); err == nil {
-			var m map[string]any
-			if json.Unmarshal(b, &m) == nil {
-				return m
-			}
+	if b, err := json.Marshal(v); err == nil {
+		var m map[string]any
+		if json.Unmarshal(b, &m) == nil && m != nil {
+			return m, ""
 		}
 	}
-	return map[string]any{}
+	return map[string]any{}, fmt.Sprintf("arguments must be a JSON object, got %T", v)
 }
 
 func normalizeOllamaStop(reason string) string {
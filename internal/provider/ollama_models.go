@@ -0,0 +1,206 @@
+package provider
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Ollama model management
+// ---------------------------------------------------------------------------
+
+// ollamaHealthTimeout bounds the health check, so an unreachable server is
+// reported quickly rather than after the stream timeouts.
+const ollamaHealthTimeout = 5 * time.Second
+
+// OllamaModel is a model installed on the Ollama server.
+type OllamaModel struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	ModifiedAt    time.Time `json:"modified_at"`
+	ParameterSize string    `json:"parameter_size,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+}
+
+// OllamaProgress is one progress update of a model pull. Total and
+// Completed are bytes of the layer named by Digest, when one is
+// downloading.
+type OllamaProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+}
+
+// OllamaBaseURL returns the configured Ollama endpoint (see ollama.url).
+func OllamaBaseURL() string { return ollamaBaseURL }
+
+// OllamaIsLocal reports whether the Ollama server is on this host, so its
+// models use this host's memory.
+func OllamaIsLocal() bool {
+	u, err := url.Parse(ollamaBaseURL)
+	if err != nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// OllamaVersion checks that the Ollama server is reachable and returns its
+// version.
+func OllamaVersion() (string, error) {
+	req, err := newProviderRequest("ollama", http.MethodGet, ollamaBaseURL+"/api/version", nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Transport: streamHTTPClient.Transport, Timeout: ollamaHealthTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ollama is not reachable at %s (is `ollama serve` running?): %w", ollamaBaseURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return "", ollamaError(resp)
+	}
+	var parsed struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", fmt.Errorf("decoding ollama version: %w", err)
+	}
+	return parsed.Version, nil
+}
+
+// ListOllamaModels returns the models installed on the Ollama server.
+func ListOllamaModels() ([]OllamaModel, error) {
+	req, err := newProviderRequest("ollama", http.MethodGet, ollamaBaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, ollamaError(resp)
+	}
+
+	var parsed struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			Size       int64     `json:"size"`
+			ModifiedAt time.Time `json:"modified_at"`
+			Details    struct {
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decoding ollama models: %w", err)
+	}
+	out := make([]OllamaModel, 0, len(parsed.Models))
+	for _, m := range parsed.Models {
+		out = append(out, OllamaModel{
+			Name:          m.Name,
+			Size:          m.Size,
+			ModifiedAt:    m.ModifiedAt,
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+		})
+	}
+	return out, nil
+}
+
+// PullOllamaModel downloads a model to the Ollama server, calling
+// onProgress for each update Ollama reports.
+func PullOllamaModel(name string, onProgress func(OllamaProgress)) error {
+	body, _ := json.Marshal(map[string]any{"model": name, "stream": true})
+	req, err := newProviderRequest("ollama", http.MethodPost, ollamaBaseURL+"/api/pull", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("pulling %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("pulling %s: %w", name, ollamaError(resp))
+	}
+
+	tr := newTimeoutReader(resp.Body)
+	defer func() { _ = tr.Close() }()
+	scanner := bufio.NewScanner(tr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var update struct {
+			OllamaProgress
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &update); err != nil {
+			continue
+		}
+		if update.Error != "" {
+			return fmt.Errorf("pulling %s: %s", name, update.Error)
+		}
+		if onProgress != nil {
+			onProgress(update.OllamaProgress)
+		}
+		if update.Status == "success" {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("pulling %s: %w", name, err)
+	}
+	return fmt.Errorf("pulling %s: stream ended before the pull finished", name)
+}
+
+// DeleteOllamaModel removes a model from the Ollama server.
+func DeleteOllamaModel(name string) error {
+	body, _ := json.Marshal(map[string]string{"model": name})
+	req, err := newProviderRequest("ollama", http.MethodDelete, ollamaBaseURL+"/api/delete", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := streamHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("removing %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("removing %s: %w", name, ollamaError(resp))
+	}
+	return nil
+}
+
+// ollamaError converts an Ollama error response, whose body is
+// {"error": "..."}, to an APIError so it is classified like other
+// providers' errors (a missing model is a 404).
+func ollamaError(resp *http.Response) error {
+	raw, _ := io.ReadAll(resp.Body)
+	msg := strings.TrimSpace(string(raw))
+	var parsed struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(raw, &parsed) == nil && parsed.Error != "" {
+		msg = parsed.Error
+	}
+	return NewAPIError(resp.StatusCode, "", "ollama: "+msg, resp.Header)
+}
@@ -0,0 +1,135 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// fakeOllama serves the model management API with installed as the
+// model list.
+func fakeOllama(t *testing.T, installed *[]string) {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string `json:"model"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/version":
+			fmt.Fprint(w, `{"version":"0.6.2"}`)
+		case "GET /api/tags":
+			var models []map[string]any
+			for _, name := range *installed {
+				models = append(models, map[string]any{"name": name, "size": 2019393189, "details": map[string]any{"parameter_size": "3.2B"}})
+			}
+			_ = json.NewEncoder(w).Encode(map[string]any{"models": models})
+		case "POST /api/pull":
+			if req.Model == "nope" {
+				fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+				fmt.Fprintln(w, `{"error":"pull model manifest: file does not exist"}`)
+				return
+			}
+			fmt.Fprintln(w, `{"status":"pulling manifest"}`)
+			fmt.Fprintln(w, `{"status":"pulling dde5aa3fc5ff","digest":"sha256:dde5aa3fc5ff","total":100,"completed":40}`)
+			fmt.Fprintln(w, `{"status":"success"}`)
+			*installed = append(*installed, req.Model)
+		case "DELETE /api/delete":
+			for i, name := range *installed {
+				if name == req.Model {
+					*installed = append((*installed)[:i], (*installed)[i+1:]...)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model '%s' not found"}`, req.Model)
+		case "POST /api/chat":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"error":"model \"%s\" not found, try pulling it first"}`, req.Model)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	prev := ollamaBaseURL
+	SetOllamaBaseURL(ts.URL)
+	t.Cleanup(func() { SetOllamaBaseURL(prev) })
+}
+
+func TestOllamaModelManagement(t *testing.T) {
+	installed := []string{"gemma3:4b"}
+	fakeOllama(t, &installed)
+
+	if v, err := OllamaVersion(); err != nil || v != "0.6.2" {
+		t.Fatalf("OllamaVersion = %q, %v", v, err)
+	}
+
+	var updates []OllamaProgress
+	if err := PullOllamaModel("llama3.2", func(p OllamaProgress) { updates = append(updates, p) }); err != nil {
+		t.Fatalf("PullOllamaModel: %v", err)
+	}
+	if len(updates) != 3 || updates[1].Completed != 40 || updates[1].Total != 100 || updates[2].Status != "success" {
+		t.Errorf("progress = %+v", updates)
+	}
+	if err := PullOllamaModel("nope", nil); err == nil || !strings.Contains(err.Error(), "file does not exist") {
+		t.Errorf("failed pull = %v", err)
+	}
+
+	models, err := ListOllamaModels()
+	if err != nil || len(models) != 2 || models[1].Name != "llama3.2" || models[1].ParameterSize != "3.2B" {
+		t.Fatalf("ListOllamaModels = %+v, %v", models, err)
+	}
+
+	if err := DeleteOllamaModel("gemma3:4b"); err != nil {
+		t.Fatalf("DeleteOllamaModel: %v", err)
+	}
+	if err := DeleteOllamaModel("gemma3:4b"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("deleting a missing model = %v", err)
+	}
+	if len(installed) != 1 || installed[0] != "llama3.2" {
+		t.Errorf("installed = %v", installed)
+	}
+}
+
+func TestOllamaModelNotFound(t *testing.T) {
+	installed := []string{}
+	fakeOllama(t, &installed)
+
+	_, _, _, err := (&OllamaProvider{}).StreamMessage("", "llama3.2", nil, nil, "", nil)
+	if code := domain.ErrorCodeOf(err); code != domain.ErrModelNotFound {
+		t.Errorf("code = %q (%v), want %q", code, err, domain.ErrModelNotFound)
+	}
+}
+
+func TestOllamaVersion_unreachable(t *testing.T) {
+	prev := ollamaBaseURL
+	SetOllamaBaseURL("http://127.0.0.1:1")
+	t.Cleanup(func() { SetOllamaBaseURL(prev) })
+
+	if _, err := OllamaVersion(); err == nil || !strings.Contains(err.Error(), "not reachable at http://127.0.0.1:1") {
+		t.Errorf("OllamaVersion = %v", err)
+	}
+}
+
+func TestOllamaIsLocal(t *testing.T) {
+	prev := ollamaBaseURL
+	t.Cleanup(func() { SetOllamaBaseURL(prev) })
+
+	for url, want := range map[string]bool{
+		"http://localhost:11434":     true,
+		"http://127.0.0.1:11434":     true,
+		"http://[::1]:11434":         true,
+		"http://gpu-box.lan:11434":   false,
+		"https://ollama.example.com": false,
+	} {
+		SetOllamaBaseURL(url)
+		if got := OllamaIsLocal(); got != want {
+			t.Errorf("OllamaIsLocal(%s) = %v, want %v", url, got, want)
+		}
+	}
+}
@@ -113,6 +113,36 @@ func TestOllamaProvider_StreamMessage_ToolUse(t *testing.T) {
 	}
 }
 
+func TestParseOllamaArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      any
+		wantKey string
+		wantErr string
+	}{
+		{"object", map[string]any{"path": "a"}, "path", ""},
+		{"json string", `{"path":"a"}`, "path", ""},
+		{"nil", nil, "", ""},
+		{"empty string", " ", "", ""},
+		{"bad json", `{"path":}`, "", "not valid JSON"},
+		{"not an object", []any{"a"}, "", "must be a JSON object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errMsg := parseOllamaArgs(tt.in)
+			if got == nil {
+				t.Fatal("got nil map")
+			}
+			if tt.wantKey != "" && got[tt.wantKey] == nil {
+				t.Errorf("got %v, want key %q", got, tt.wantKey)
+			}
+			if tt.wantErr == "" && errMsg != "" || !strings.Contains(errMsg, tt.wantErr) {
+				t.Errorf("err = %q, want %q", errMsg, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestBuildOllamaMessages_MapsToolHistory(t *testing.T) {
 	history := []domain.TranscriptMessage{
 		{
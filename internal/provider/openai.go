@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/batalabs/muxd/internal/domain"
@@ -29,13 +28,13 @@ func (p *OpenAIProvider) Name() string { return "openai" }
 
 // FetchModels retrieves the list of available models from the OpenAI API.
 func (p *OpenAIProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
-	httpReq, err := http.NewRequest(http.MethodGet, "https://api.openai.com/v1/models", nil)
+	httpReq, err := newProviderRequest("openai", http.MethodGet, "https://api.openai.com/v1/models", nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{Timeout: fetchModelsTimeout}
+	client := &http.Client{Timeout: fetchModelsTimeout, Transport: streamHTTPClient.Transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -105,6 +104,18 @@ func (p *OpenAIProvider) StreamMessage(
 	tools []ToolSpec,
 	system string,
 	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *OpenAIProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	msgs := buildOpenAIMessages(history, system)
 
@@ -119,13 +130,14 @@ func (p *OpenAIProvider) StreamMessage(
 		Tools:         toOpenAITools(tools),
 		StreamOptions: streamOpts,
 	}
+	sampling.applyOpenAI(&reqBody, p.Name())
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
+	httpReq, err := newProviderRequest("openai", http.MethodPost, "https://api.openai.com/v1/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
 	}
@@ -205,6 +217,13 @@ type openaiRequest struct {
 	StreamOptions *struct {
 		IncludeUsage bool `json:"include_usage"`
 	} `json:"stream_options,omitempty"`
+	Temperature         *float64 `json:"temperature,omitempty"`
+	TopP                *float64 `json:"top_p,omitempty"`
+	Seed                *int64   `json:"seed,omitempty"`
+	RandomSeed          *int64   `json:"random_seed,omitempty"` // Mistral
+	MaxTokens           int      `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int      `json:"max_completion_tokens,omitempty"` // OpenAI, Azure
+	Stop                []string `json:"stop,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -275,6 +294,18 @@ func buildOpenAIMessages(history []domain.TranscriptMessage, system string) []op
 						})
 					}
 				}
+				// Text alongside tool results (a note the user sent mid-turn)
+				// follows them as a user message.
+				var texts []string
+				for _, b := range m.Blocks {
+					if b.Type == "text" && strings.TrimSpace(b.Text) != "" {
+						texts = append(texts, b.Text)
+					}
+				}
+				if len(texts) > 0 {
+					raw, _ := json.Marshal(strings.Join(texts, "\n"))
+					msgs = append(msgs, openaiMessage{Role: "user", Content: raw})
+				}
 				continue
 			}
 
@@ -494,16 +525,18 @@ func parseOpenAISSE(body io.Reader, onDelta func(string)) ([]domain.ContentBlock
 			continue
 		}
 		input := map[string]any{}
+		var inputErr string
 		if argsStr := builder.args.String(); argsStr != "" {
 			if err := json.Unmarshal([]byte(argsStr), &input); err != nil {
-				fmt.Fprintf(os.Stderr, "openai: unmarshal tool args: %v\n", err)
+				input, inputErr = map[string]any{}, "arguments are not valid JSON: "+err.Error()
 			}
 		}
 		blocks = append(blocks, domain.ContentBlock{
-			Type:      "tool_use",
-			ToolUseID: builder.id,
-			ToolName:  builder.name,
-			ToolInput: input,
+			Type:       "tool_use",
+			ToolUseID:  builder.id,
+			ToolName:   builder.name,
+			ToolInput:  input,
+			InputError: inputErr,
 		})
 	}
 
@@ -81,6 +81,26 @@ data: [DONE]
 	}
 }
 
+func TestParseOpenAISSE_malformedToolArgs(t *testing.T) {
+	sse := `data: {"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"bash","arguments":"{\"command\": ls}"}}]}}]}
+
+data: {"choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}
+
+data: [DONE]
+
+`
+	blocks, _, _, err := parseOpenAISSE(strings.NewReader(sse), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("expected 1 tool block, got %d", len(blocks))
+	}
+	if !strings.Contains(blocks[0].InputError, "not valid JSON") || len(blocks[0].ToolInput) != 0 {
+		t.Errorf("block = %+v, want InputError and empty input", blocks[0])
+	}
+}
+
 func TestParseOpenAISSE_textAndTools(t *testing.T) {
 	sse := `data: {"choices":[{"index":0,"delta":{"content":"thinking..."}}]}
 
@@ -211,6 +231,22 @@ func TestBuildOpenAIMessages(t *testing.T) {
 		}
 	})
 
+	t.Run("text after tool results", func(t *testing.T) {
+		history := []domain.TranscriptMessage{
+			{Role: "user", Blocks: []domain.ContentBlock{
+				{Type: "tool_result", ToolUseID: "call_1", ToolResult: "ok"},
+				{Type: "text", Text: "focus on the parser"},
+			}},
+		}
+		msgs := buildOpenAIMessages(history, "")
+		if len(msgs) != 2 || msgs[0].Role != "tool" || msgs[1].Role != "user" {
+			t.Fatalf("messages = %+v", msgs)
+		}
+		if string(msgs[1].Content) != `"focus on the parser"` {
+			t.Errorf("user content = %s", msgs[1].Content)
+		}
+	})
+
 	t.Run("no system", func(t *testing.T) {
 		history := []domain.TranscriptMessage{
 			{Role: "user", Content: "hi"},
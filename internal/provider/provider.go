@@ -100,9 +100,104 @@ func GetProvider(name string) (Provider, error) {
 		return &FireworksProvider{}, nil
 	case "deepinfra":
 		return &DeepInfraProvider{}, nil
+	case "azure":
+		return &AzureProvider{}, nil
+	case "fake":
+		return newFakeProviderFromEnv()
 	default:
-		return nil, fmt.Errorf("unknown provider: %s (supported: anthropic, zai, grok, mistral, openai, ollama, fireworks, deepinfra)", name)
+		if IsCustomProvider(name) {
+			return &CustomProvider{name: strings.ToLower(name)}, nil
+		}
+		return nil, fmt.Errorf("unknown provider: %s (supported: anthropic, zai, grok, mistral, openai, ollama, fireworks, deepinfra, azure, or a providers.custom name)", name)
+	}
+}
+
+// RequiresAPIKey reports whether requests to the named provider need an API
+// key: Ollama and custom endpoints may run without one, and Azure can
+// authenticate with Azure AD instead.
+func RequiresAPIKey(name string) bool {
+	switch strings.ToLower(name) {
+	case "ollama", "fake":
+		return false
+	case "azure":
+		return !AzureADConfigured()
 	}
+	return !IsCustomProvider(name)
+}
+
+// ---------------------------------------------------------------------------
+// Capabilities
+// ---------------------------------------------------------------------------
+
+// visionModelHints are substrings of model IDs that take image input on
+// providers whose catalogs mix text-only and vision models.
+var visionModelHints = []string{"vision", "-vl", "vl-", "llava", "pixtral", "gemma3", "llama-4", "llama4", "4.5v", "4.6v"}
+
+// SupportsImages reports whether modelID on providerName accepts image
+// content blocks. Unknown models are assumed to be text-only so images are
+// described instead of failing the request.
+func SupportsImages(providerName, modelID string) bool {
+	id := strings.ToLower(modelID)
+	for _, hint := range visionModelHints {
+		if strings.Contains(id, hint) {
+			return true
+		}
+	}
+	switch strings.ToLower(providerName) {
+	case "anthropic":
+		return true
+	case "openai", "azure": // Azure deployments are usually named after their model
+		for _, textOnly := range []string{"o1-mini", "o3-mini"} {
+			if strings.HasPrefix(id, textOnly) {
+				return false
+			}
+		}
+		for _, vision := range []string{"gpt-4", "gpt-5", "o1", "o3", "o4"} {
+			if strings.HasPrefix(id, vision) {
+				return true
+			}
+		}
+		return false
+	case "grok":
+		return strings.HasPrefix(id, "grok-4")
+	case "mistral":
+		return strings.HasPrefix(id, "mistral-medium") || strings.HasPrefix(id, "mistral-small")
+	}
+	return false
+}
+
+// contextWindows maps model ID prefixes to context window sizes in tokens.
+// More specific prefixes come first.
+var contextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"claude-", 200_000},
+	{"gpt-4.1", 1_047_576},
+	{"gpt-5", 400_000},
+	{"gpt-4o", 128_000},
+	{"gpt-4-turbo", 128_000},
+	{"gpt-4", 8_192},
+	{"gpt-3.5", 16_385},
+	{"o1", 200_000},
+	{"o3", 200_000},
+	{"o4", 200_000},
+	{"grok-4", 256_000},
+	{"grok-3", 131_072},
+	{"codestral", 256_000},
+	{"mistral-", 128_000},
+}
+
+// ContextWindow returns the context window of modelID in tokens, or 0 when
+// it is not known.
+func ContextWindow(modelID string) int {
+	id := strings.ToLower(modelID)
+	for _, w := range contextWindows {
+		if strings.HasPrefix(id, w.prefix) {
+			return w.tokens
+		}
+	}
+	return 0
 }
 
 // ---------------------------------------------------------------------------
@@ -115,6 +210,8 @@ func GetProvider(name string) (Provider, error) {
 // Rules:
 //   - "openai/gpt-4o" -> ("openai", "gpt-4o")
 //   - "anthropic/claude-sonnet" -> ("anthropic", resolved alias)
+//   - "azure/my-gpt4o" -> ("azure", "my-gpt4o") -- Azure deployment name
+//   - "vllm/llama-3" -> ("vllm", "llama-3") -- registered custom provider
 //   - "claude-sonnet" -> ("anthropic", resolved alias) -- known Anthropic alias
 //   - "gpt-4o" -> (currentProvider, "gpt-4o") -- bare unknown name
 func ResolveProviderAndModel(spec string, currentProvider string) (string, string) {
@@ -134,7 +231,12 @@ func ResolveProviderAndModel(spec string, currentProvider string) (string, strin
 			return "zai", model
 		case "grok", "xai":
 			return "grok", model
-		case "mistral", "openai", "google", "ollama", "fireworks", "deepinfra":
+		case "mistral", "openai", "google", "ollama", "fireworks", "deepinfra", "azure", "fake":
+			return prefix, model
+		case "azure-openai":
+			return "azure", model
+		}
+		if IsCustomProvider(prefix) {
 			return prefix, model
 		}
 		// Unknown prefix (e.g. "accounts/fireworks/models/...") --
@@ -154,6 +256,11 @@ func ResolveProviderAndModel(spec string, currentProvider string) (string, strin
 		return "anthropic", ResolveModel(model)
 	}
 
+	// Bare name listed by a custom provider
+	if name, ok := customProviderForModel(spec); ok {
+		return name, spec
+	}
+
 	// Bare name: check if it's a known Anthropic alias
 	lower := strings.ToLower(spec)
 	if _, ok := ModelAliases[lower]; ok {
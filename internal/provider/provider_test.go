@@ -379,3 +379,53 @@ func TestIsOpenAIChatModel(t *testing.T) {
 		})
 	}
 }
+
+func TestSupportsImages(t *testing.T) {
+	tests := []struct {
+		provider, model string
+		want            bool
+	}{
+		{"anthropic", "claude-sonnet-4-5", true},
+		{"openai", "gpt-4o", true},
+		{"openai", "gpt-3.5-turbo", false},
+		{"openai", "o3-mini", false},
+		{"openai", "o3", true},
+		{"grok", "grok-4", true},
+		{"grok", "grok-3-mini", false},
+		{"ollama", "llava:13b", true},
+		{"ollama", "qwen2.5-coder:7b", false},
+		{"fireworks", "accounts/fireworks/models/qwen2p5-vl-32b-instruct", true},
+		{"zai", "glm-4.5v", true},
+		{"zai", "glm-4.6", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.provider+"/"+tt.model, func(t *testing.T) {
+			if got := SupportsImages(tt.provider, tt.model); got != tt.want {
+				t.Errorf("SupportsImages(%q, %q) = %v, want %v", tt.provider, tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContextWindow(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int
+	}{
+		{"claude-sonnet-4-6", 200_000},
+		{"gpt-4o-mini", 128_000},
+		{"gpt-4.1-mini", 1_047_576},
+		{"gpt-4", 8_192},
+		{"o4-mini", 200_000},
+		{"grok-3-mini", 131_072},
+		{"mistral-small-latest", 128_000},
+		{"qwen2.5-coder:7b", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			if got := ContextWindow(tt.model); got != tt.want {
+				t.Errorf("ContextWindow(%q) = %d, want %d", tt.model, got, tt.want)
+			}
+		})
+	}
+}
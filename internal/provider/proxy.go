@@ -0,0 +1,124 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ---------------------------------------------------------------------------
+// Egress proxies
+// ---------------------------------------------------------------------------
+
+// Proxy settings are populated at startup from preferences. A per-provider
+// proxy wins over the default proxy, which wins over the standard
+// HTTP_PROXY / HTTPS_PROXY / NO_PROXY environment variables.
+var (
+	proxyMu         sync.RWMutex
+	defaultProxy    *url.URL
+	providerProxies map[string]*url.URL
+)
+
+// providerCtxKey tags outbound requests with the provider name so the shared
+// transport can pick the right proxy.
+type providerCtxKey struct{}
+
+// SetProxies configures the default proxy and per-provider proxies. Empty
+// values are ignored. Returns an error if any URL is invalid, in which case
+// the previous settings are kept.
+func SetProxies(defaultURL string, perProvider map[string]string) error {
+	def, err := parseProxyURL(defaultURL)
+	if err != nil {
+		return err
+	}
+	m := make(map[string]*url.URL, len(perProvider))
+	for name, raw := range perProvider {
+		u, err := parseProxyURL(raw)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if u != nil {
+			m[strings.ToLower(name)] = u
+		}
+	}
+	proxyMu.Lock()
+	defaultProxy = def
+	providerProxies = m
+	proxyMu.Unlock()
+	return nil
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL %q: %w", raw, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, or socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", raw)
+	}
+	return u, nil
+}
+
+// ProxyFunc returns an http.Transport Proxy function for the named provider.
+// Pass an empty name for clients that only honour the default proxy (e.g.
+// MCP HTTP transports).
+func ProxyFunc(name string) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		return resolveProxy(strings.ToLower(name), req)
+	}
+}
+
+// proxyForRequest resolves the proxy using the provider tagged on the
+// request context by newProviderRequest.
+func proxyForRequest(req *http.Request) (*url.URL, error) {
+	name, _ := req.Context().Value(providerCtxKey{}).(string)
+	return resolveProxy(name, req)
+}
+
+func resolveProxy(name string, req *http.Request) (*url.URL, error) {
+	proxyMu.RLock()
+	u := providerProxies[name]
+	if u == nil && !isLoopbackURL(req.URL) {
+		u = defaultProxy
+	}
+	proxyMu.RUnlock()
+	if u != nil {
+		return u, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// isLoopbackURL reports whether u points at the local machine (e.g. a local
+// Ollama server), which should bypass the default proxy.
+func isLoopbackURL(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// newProviderRequest is http.NewRequest with the provider name attached so
+// the request is routed through that provider's proxy.
+func newProviderRequest(name, method, rawURL string, body io.Reader) (*http.Request, error) {
+	ctx := context.WithValue(context.Background(), providerCtxKey{}, name)
+	return http.NewRequestWithContext(ctx, method, rawURL, body)
+}
@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestProxyForRequest(t *testing.T) {
+	t.Cleanup(func() { _ = SetProxies("", nil) })
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("HTTP_PROXY", "")
+
+	if err := SetProxies("http://default:3128", map[string]string{"OpenAI": "http://openai-proxy:8080"}); err != nil {
+		t.Fatalf("SetProxies: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		url      string
+		want     string
+	}{
+		{"per-provider", "openai", "https://api.openai.com/v1/models", "http://openai-proxy:8080"},
+		{"default", "anthropic", "https://api.anthropic.com/v1/messages", "http://default:3128"},
+		{"loopback bypasses default", "ollama", "http://localhost:11434/api/tags", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := newProviderRequest(tt.provider, http.MethodGet, tt.url, nil)
+			if err != nil {
+				t.Fatalf("newProviderRequest: %v", err)
+			}
+			u, err := proxyForRequest(req)
+			if err != nil {
+				t.Fatalf("proxyForRequest: %v", err)
+			}
+			got := ""
+			if u != nil {
+				got = u.String()
+			}
+			if got != tt.want {
+				t.Errorf("proxy = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetProxies_invalid(t *testing.T) {
+	t.Cleanup(func() { _ = SetProxies("", nil) })
+	if err := SetProxies("ftp://nope", nil); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+	if err := SetProxies("", map[string]string{"openai": "http://"}); err == nil {
+		t.Error("expected error for missing host")
+	}
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// Sampling holds optional sampling parameters for a request. Nil and zero
+// fields leave the provider's default in place.
+type Sampling struct {
+	Temperature *float64
+	TopP        *float64
+	Seed        *int64
+	// MaxTokens caps the output tokens of each response.
+	MaxTokens int
+	// Stop lists sequences that end a response when generated.
+	Stop []string
+}
+
+// IsZero reports whether no parameter is set.
+func (s Sampling) IsZero() bool {
+	return s.Temperature == nil && s.TopP == nil && s.Seed == nil && s.MaxTokens == 0 && len(s.Stop) == 0
+}
+
+// String renders the set parameters, e.g. "temperature=0.2 seed=42".
+func (s Sampling) String() string {
+	var parts []string
+	if s.Temperature != nil {
+		parts = append(parts, "temperature="+strconv.FormatFloat(*s.Temperature, 'g', -1, 64))
+	}
+	if s.TopP != nil {
+		parts = append(parts, "top_p="+strconv.FormatFloat(*s.TopP, 'g', -1, 64))
+	}
+	if s.Seed != nil {
+		parts = append(parts, "seed="+strconv.FormatInt(*s.Seed, 10))
+	}
+	if s.MaxTokens > 0 {
+		parts = append(parts, "max_tokens="+strconv.Itoa(s.MaxTokens))
+	}
+	if len(s.Stop) > 0 {
+		quoted := make([]string, len(s.Stop))
+		for i, seq := range s.Stop {
+			quoted[i] = strconv.Quote(seq)
+		}
+		parts = append(parts, "stop="+strings.Join(quoted, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ---------------------------------------------------------------------------
+// Provider capabilities
+// ---------------------------------------------------------------------------
+
+// samplingCaps describes the sampling options a provider's API accepts.
+type samplingCaps struct {
+	seed bool
+	// maxStop is the most stop sequences accepted; 0 for no limit.
+	maxStop int
+	// defaultMaxTokens is the output cap muxd sends when none is set; 0
+	// leaves it to the provider.
+	defaultMaxTokens int
+}
+
+// anthropicDefaultMaxTokens is sent to Anthropic, which requires max_tokens.
+const anthropicDefaultMaxTokens = 16384
+
+var providerSamplingCaps = map[string]samplingCaps{
+	"anthropic": {defaultMaxTokens: anthropicDefaultMaxTokens},
+	"openai":    {seed: true, maxStop: 4},
+	"azure":     {seed: true, maxStop: 4},
+	"grok":      {seed: true, maxStop: 4},
+	"deepinfra": {seed: true, maxStop: 4},
+	"fireworks": {maxStop: 4},
+	"mistral":   {seed: true},
+	"zai":       {maxStop: 1},
+	"ollama":    {seed: true},
+}
+
+// capsFor returns the capabilities of a provider. Custom endpoints are
+// assumed to follow the OpenAI API.
+func capsFor(providerName string) samplingCaps {
+	if caps, ok := providerSamplingCaps[providerName]; ok {
+		return caps
+	}
+	return samplingCaps{seed: true, maxStop: 4}
+}
+
+// SupportsSeed reports whether a provider's API accepts a sampling seed.
+func SupportsSeed(providerName string) bool {
+	return capsFor(providerName).seed
+}
+
+// DefaultMaxTokens returns the output cap used for a provider when none is
+// set, or 0 when the provider's own default applies.
+func DefaultMaxTokens(providerName string) int {
+	return capsFor(providerName).defaultMaxTokens
+}
+
+// ValidateSampling checks s against what a provider's API accepts.
+func ValidateSampling(providerName string, s Sampling) error {
+	if s.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens must be positive")
+	}
+	for _, seq := range s.Stop {
+		if seq == "" {
+			return fmt.Errorf("stop sequences cannot be empty")
+		}
+	}
+	if n := capsFor(providerName).maxStop; n > 0 && len(s.Stop) > n {
+		return fmt.Errorf("%s accepts at most %d stop sequence(s), got %d", providerName, n, len(s.Stop))
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Streaming with sampling
+// ---------------------------------------------------------------------------
+
+// SamplingProvider is implemented by providers that accept sampling
+// parameters. Parameters a provider's API has no equivalent for (a seed on
+// Anthropic, for instance) are dropped.
+type SamplingProvider interface {
+	Provider
+	StreamMessageWithSampling(
+		sampling Sampling,
+		apiKey, modelID string,
+		history []domain.TranscriptMessage,
+		tools []ToolSpec,
+		system string,
+		onDelta func(string),
+	) ([]domain.ContentBlock, string, Usage, error)
+}
+
+// StreamWithSampling streams a message through prov, passing sampling when
+// prov supports it. Sampling the provider cannot accept is an error.
+func StreamWithSampling(
+	prov Provider,
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	if sp, ok := prov.(SamplingProvider); ok && !sampling.IsZero() {
+		if err := ValidateSampling(prov.Name(), sampling); err != nil {
+			return nil, "", Usage{}, err
+		}
+		return sp.StreamMessageWithSampling(sampling, apiKey, modelID, history, tools, system, onDelta)
+	}
+	return prov.StreamMessage(apiKey, modelID, history, tools, system, onDelta)
+}
+
+// applyOpenAI sets the parameters on an OpenAI-style request for the named
+// provider, leaving out what its API rejects.
+func (s Sampling) applyOpenAI(req *openaiRequest, providerName string) {
+	req.Temperature = s.Temperature
+	req.TopP = s.TopP
+	req.Stop = s.Stop
+	switch {
+	case providerName == "mistral":
+		req.RandomSeed = s.Seed
+	case SupportsSeed(providerName):
+		req.Seed = s.Seed
+	}
+	switch providerName {
+	case "openai", "azure":
+		// Reasoning models reject max_tokens.
+		req.MaxCompletionTokens = s.MaxTokens
+	default:
+		req.MaxTokens = s.MaxTokens
+	}
+}
@@ -0,0 +1,152 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestStreamWithSampling_openAICompatible(t *testing.T) {
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody = nil
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	SetCustomProvider("sampled", srv.URL, nil)
+	defer SetCustomProvider("sampled", "", nil)
+	prov, err := GetProvider("sampled")
+	if err != nil {
+		t.Fatalf("GetProvider: %v", err)
+	}
+	history := []domain.TranscriptMessage{{Role: "user", Content: "hi"}}
+
+	temp, seed := 0.2, int64(42)
+	if _, _, _, err := StreamWithSampling(prov, Sampling{Temperature: &temp, Seed: &seed}, "", "m", history, nil, "", nil); err != nil {
+		t.Fatalf("StreamWithSampling: %v", err)
+	}
+	if gotBody["temperature"] != 0.2 || gotBody["seed"] != float64(42) {
+		t.Errorf("request body = %v, want temperature and seed", gotBody)
+	}
+	if _, ok := gotBody["top_p"]; ok {
+		t.Errorf("unset top_p was sent: %v", gotBody)
+	}
+
+	if _, _, _, err := StreamWithSampling(prov, Sampling{}, "", "m", history, nil, "", nil); err != nil {
+		t.Fatalf("StreamWithSampling: %v", err)
+	}
+	if _, ok := gotBody["temperature"]; ok {
+		t.Errorf("zero sampling sent temperature: %v", gotBody)
+	}
+}
+
+func TestStreamWithSampling_ollamaOptions(t *testing.T) {
+	var gotBody map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&gotBody)
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"ok"},"done":true}`+"\n")
+	}))
+	defer srv.Close()
+	prev := ollamaBaseURL
+	SetOllamaBaseURL(srv.URL)
+	t.Cleanup(func() { SetOllamaBaseURL(prev) })
+
+	topP, seed := 0.9, int64(7)
+	history := []domain.TranscriptMessage{{Role: "user", Content: "hi"}}
+	if _, _, _, err := StreamWithSampling(&OllamaProvider{}, Sampling{TopP: &topP, Seed: &seed}, "", "llama3", history, nil, "", nil); err != nil {
+		t.Fatalf("StreamWithSampling: %v", err)
+	}
+	opts, _ := gotBody["options"].(map[string]any)
+	if opts["top_p"] != 0.9 || opts["seed"] != float64(7) {
+		t.Errorf("options = %v", gotBody["options"])
+	}
+}
+
+func TestSampling_applyOpenAI(t *testing.T) {
+	temp, seed := 1.0, int64(3)
+	s := Sampling{Temperature: &temp, Seed: &seed, MaxTokens: 512, Stop: []string{"END"}}
+
+	var req openaiRequest
+	s.applyOpenAI(&req, "zai")
+	if req.Temperature == nil || *req.Temperature != 1 || req.Seed != nil {
+		t.Errorf("zai: %+v", req)
+	}
+	if req.MaxTokens != 512 || len(req.Stop) != 1 {
+		t.Errorf("zai limits: %+v", req)
+	}
+
+	req = openaiRequest{}
+	s.applyOpenAI(&req, "openai")
+	if req.Seed == nil || *req.Seed != 3 {
+		t.Errorf("openai seed: %+v", req)
+	}
+	if req.MaxCompletionTokens != 512 || req.MaxTokens != 0 {
+		t.Errorf("openai max tokens: %+v", req)
+	}
+
+	req = openaiRequest{}
+	s.applyOpenAI(&req, "mistral")
+	if req.Seed != nil || req.RandomSeed == nil || *req.RandomSeed != 3 {
+		t.Errorf("mistral seed: %+v", req)
+	}
+
+	if got := s.String(); got != `temperature=1 seed=3 max_tokens=512 stop="END"` {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestValidateSampling(t *testing.T) {
+	stops := Sampling{Stop: []string{"a", "b", "c", "d", "e"}}
+	if err := ValidateSampling("openai", stops); err == nil {
+		t.Error("openai: expected error for five stop sequences")
+	}
+	if err := ValidateSampling("anthropic", stops); err != nil {
+		t.Errorf("anthropic: %v", err)
+	}
+	if _, _, _, err := StreamWithSampling(&OpenAIProvider{}, stops, "", "gpt-4o", nil, nil, "", nil); err == nil {
+		t.Error("StreamWithSampling: expected the openai stop limit to be enforced")
+	}
+	if err := ValidateSampling("zai", Sampling{Stop: []string{"a", "b"}}); err == nil {
+		t.Error("zai: expected error for two stop sequences")
+	}
+	if err := ValidateSampling("ollama", Sampling{Stop: []string{""}}); err == nil {
+		t.Error("expected error for an empty stop sequence")
+	}
+	if got := DefaultMaxTokens("anthropic"); got != anthropicDefaultMaxTokens {
+		t.Errorf("DefaultMaxTokens(anthropic) = %d", got)
+	}
+	if SupportsSeed("anthropic") || !SupportsSeed("my-gateway") {
+		t.Error("SupportsSeed: anthropic has none, custom endpoints do")
+	}
+}
+
+// plainProvider implements only Provider.
+type plainProvider struct{ called bool }
+
+func (p *plainProvider) Name() string { return "plain" }
+func (p *plainProvider) FetchModels(string) ([]domain.APIModelInfo, error) {
+	return nil, nil
+}
+func (p *plainProvider) StreamMessage(string, string, []domain.TranscriptMessage, []ToolSpec, string, func(string)) ([]domain.ContentBlock, string, Usage, error) {
+	p.called = true
+	return nil, "end_turn", Usage{}, nil
+}
+
+func TestStreamWithSampling_unsupportedProvider(t *testing.T) {
+	p := &plainProvider{}
+	temp := 0.0
+	if _, _, _, err := StreamWithSampling(p, Sampling{Temperature: &temp}, "", "m", nil, nil, "", nil); err != nil {
+		t.Fatalf("StreamWithSampling: %v", err)
+	}
+	if !p.called {
+		t.Error("expected StreamMessage to be called")
+	}
+}
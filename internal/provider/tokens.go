@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"encoding/json"
+	"unicode/utf8"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Token estimation
+// ---------------------------------------------------------------------------
+
+// ImageTokenEstimate is the rough cost of one image block.
+const ImageTokenEstimate = 1600
+
+// EstimateTokens estimates the tokens in s at about four characters a token.
+func EstimateTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// EstimateMessageTokens estimates the tokens one message adds to a prompt.
+func EstimateMessageTokens(msg domain.TranscriptMessage) int {
+	if !msg.HasBlocks() {
+		return EstimateTokens(msg.Content)
+	}
+	n := 0
+	for _, b := range msg.Blocks {
+		switch b.Type {
+		case "image":
+			n += ImageTokenEstimate
+		case "tool_use":
+			raw, _ := json.Marshal(b.ToolInput)
+			n += EstimateTokens(b.ToolName) + EstimateTokens(string(raw))
+		case "tool_result":
+			n += EstimateTokens(b.ToolResult)
+		default:
+			n += EstimateTokens(b.Text)
+		}
+	}
+	return n
+}
+
+// EstimateToolTokens estimates the tokens the tool definitions take.
+func EstimateToolTokens(tools []ToolSpec) int {
+	n := 0
+	for _, spec := range tools {
+		if raw, err := json.Marshal(spec); err == nil {
+			n += EstimateTokens(string(raw))
+		}
+	}
+	return n
+}
+
+// EstimatePromptTokens estimates the size of a request: the system prompt
+// (which carries project memory), the tool definitions, and the history.
+func EstimatePromptTokens(system string, tools []ToolSpec, history []domain.TranscriptMessage) int {
+	n := EstimateTokens(system) + EstimateToolTokens(tools)
+	for _, msg := range history {
+		n += EstimateMessageTokens(msg)
+	}
+	return n
+}
+
+// PromptTokens is the size of the prompt the call sent, counting tokens
+// read from or written to the prompt cache, which Anthropic reports apart
+// from InputTokens.
+func (u Usage) PromptTokens() int {
+	return u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+}
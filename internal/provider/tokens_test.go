@@ -0,0 +1,56 @@
+package provider
+
+import (
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestEstimatePromptTokens(t *testing.T) {
+	history := []domain.TranscriptMessage{
+		{Role: "user", Content: "abcdefgh"}, // 2
+		{Role: "assistant", Blocks: []domain.ContentBlock{
+			{Type: "text", Text: "abcd"},                                      // 1
+			{Type: "tool_use", ToolName: "bash", ToolInput: map[string]any{}}, // 1 + 1 ("{}")
+		}},
+		{Role: "user", Blocks: []domain.ContentBlock{
+			{Type: "tool_result", ToolResult: "abcdefghijkl"}, // 3
+			{Type: "image", Base64Data: "AAAA"},
+		}},
+	}
+	tools := []ToolSpec{{Name: "bash", Description: "run a command"}}
+
+	got := EstimatePromptTokens("abcdefgh", tools, history)
+	want := 2 + EstimateToolTokens(tools) + 2 + 1 + 1 + 1 + 3 + ImageTokenEstimate
+	if got != want {
+		t.Errorf("EstimatePromptTokens = %d, want %d", got, want)
+	}
+	if EstimateToolTokens(tools) == 0 {
+		t.Error("expected tool definitions to count")
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+	}{
+		{"", 0},
+		{"a", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"日本語です", 2}, // counted in runes, not bytes
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.in); got != tt.want {
+			t.Errorf("EstimateTokens(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUsagePromptTokens(t *testing.T) {
+	u := Usage{InputTokens: 120, OutputTokens: 900, CacheCreationInputTokens: 30, CacheReadInputTokens: 5000}
+	if got := u.PromptTokens(); got != 5150 {
+		t.Errorf("PromptTokens = %d, want 5150", got)
+	}
+}
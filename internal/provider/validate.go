@@ -0,0 +1,252 @@
+package provider
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Tool input validation
+// ---------------------------------------------------------------------------
+
+// FieldError is one problem with a tool argument.
+type FieldError struct {
+	Path    string `json:"path"`    // argument path, e.g. "edits[0].old_text"
+	Problem string `json:"problem"` // e.g. "is required" or "must be an integer, got string \"5\""
+}
+
+// ValidationError lists every problem ValidateInput found in a tool call.
+type ValidationError struct {
+	Tool   string
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Path + " " + f.Problem
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ValidateInput checks tool arguments against the spec: required fields,
+// JSON types and enum values, including nested arrays and objects.
+// Properties the spec does not describe, and properties with an unknown
+// type, are accepted. On failure the error is a *ValidationError listing
+// every problem found.
+func (s ToolSpec) ValidateInput(input map[string]any) error {
+	v := &ValidationError{Tool: s.Name}
+	v.object("", s.Properties, s.Required, input)
+	if len(v.Fields) == 0 {
+		return nil
+	}
+	return v
+}
+
+func (e *ValidationError) add(path, format string, args ...any) {
+	e.Fields = append(e.Fields, FieldError{Path: path, Problem: fmt.Sprintf(format, args...)})
+}
+
+func (e *ValidationError) object(path string, props map[string]ToolProp, required []string, obj map[string]any) {
+	for _, name := range required {
+		if v, ok := obj[name]; !ok || v == nil {
+			e.add(joinPath(path, name), "is required")
+		}
+	}
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		prop, ok := props[name]
+		if !ok || obj[name] == nil {
+			continue
+		}
+		e.value(joinPath(path, name), prop, obj[name])
+	}
+}
+
+func (e *ValidationError) value(path string, prop ToolProp, v any) {
+	switch prop.Type {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			e.add(path, "must be a string, got %s", jsonTypeName(v))
+			return
+		}
+		if len(prop.Enum) > 0 && !slices.Contains(prop.Enum, s) {
+			e.add(path, "must be one of %s, got %q", strings.Join(prop.Enum, ", "), s)
+		}
+	case "integer":
+		f, ok := asNumber(v)
+		if !ok || f != math.Trunc(f) {
+			e.add(path, "must be an integer, got %s", jsonTypeName(v))
+		}
+	case "number":
+		if _, ok := asNumber(v); !ok {
+			e.add(path, "must be a number, got %s", jsonTypeName(v))
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			e.add(path, "must be a boolean, got %s", jsonTypeName(v))
+		}
+	case "array":
+		items, ok := v.([]any)
+		if !ok {
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+				items = make([]any, rv.Len())
+				for i := range items {
+					items[i] = rv.Index(i).Interface()
+				}
+			} else {
+				e.add(path, "must be an array, got %s", jsonTypeName(v))
+				return
+			}
+		}
+		if prop.Items == nil {
+			return
+		}
+		for i, item := range items {
+			e.value(fmt.Sprintf("%s[%d]", path, i), *prop.Items, item)
+		}
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			e.add(path, "must be an object, got %s", jsonTypeName(v))
+			return
+		}
+		e.object(path, prop.Properties, prop.Required, obj)
+	}
+}
+
+// asNumber accepts decoded JSON numbers and the Go numeric types callers
+// build inputs with.
+func asNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonTypeName names a decoded JSON value's type as the model wrote it.
+func jsonTypeName(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return fmt.Sprintf("string %q", t)
+	case float64, float32, int, int32, int64, json.Number:
+		return fmt.Sprintf("number %v", t)
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// ArgsSummary describes the spec's top-level arguments on one line, e.g.
+// "path (string, required), limit (integer)", for repair prompts.
+func (s ToolSpec) ArgsSummary() string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := slices.Contains(s.Required, names[i]), slices.Contains(s.Required, names[j])
+		if ri != rj {
+			return ri
+		}
+		return names[i] < names[j]
+	})
+	parts := make([]string, len(names))
+	for i, name := range names {
+		p := s.Properties[name]
+		desc := p.Type
+		if p.Type == "array" && p.Items != nil && p.Items.Type != "" {
+			desc = "array of " + p.Items.Type
+		}
+		if len(p.Enum) > 0 {
+			desc += ": " + strings.Join(p.Enum, "|")
+		}
+		if slices.Contains(s.Required, name) {
+			desc += ", required"
+		}
+		parts[i] = fmt.Sprintf("%s (%s)", name, desc)
+	}
+	if len(parts) == 0 {
+		return "no arguments"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// JSONSchema returns the spec's arguments as a JSON Schema object, the form
+// GET /api/tools serves.
+func (s ToolSpec) JSONSchema() map[string]any {
+	return objectSchema(s.Properties, s.Required)
+}
+
+func objectSchema(props map[string]ToolProp, required []string) map[string]any {
+	properties := make(map[string]any, len(props))
+	for name, p := range props {
+		properties[name] = p.JSONSchema()
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// JSONSchema returns the property as a JSON Schema.
+func (p ToolProp) JSONSchema() map[string]any {
+	if p.Type == "object" {
+		schema := objectSchema(p.Properties, p.Required)
+		if p.Description != "" {
+			schema["description"] = p.Description
+		}
+		return schema
+	}
+	schema := map[string]any{}
+	if p.Type != "" {
+		schema["type"] = p.Type
+	}
+	if p.Description != "" {
+		schema["description"] = p.Description
+	}
+	if len(p.Enum) > 0 {
+		schema["enum"] = p.Enum
+	}
+	if p.Items != nil {
+		schema["items"] = p.Items.JSONSchema()
+	}
+	return schema
+}
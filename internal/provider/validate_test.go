@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolSpec_ValidateInput(t *testing.T) {
+	spec := ToolSpec{
+		Name: "demo",
+		Properties: map[string]ToolProp{
+			"path":  {Type: "string"},
+			"limit": {Type: "integer"},
+			"ratio": {Type: "number"},
+			"all":   {Type: "boolean"},
+			"mode":  {Type: "string", Enum: []string{"fast", "slow"}},
+			"tags":  {Type: "array", Items: &ToolProp{Type: "string"}},
+			"items": {Type: "array", Items: &ToolProp{Type: "object", Properties: map[string]ToolProp{
+				"name": {Type: "string"},
+			}, Required: []string{"name"}}},
+			"opts": {Type: "object", Properties: map[string]ToolProp{"depth": {Type: "integer"}}},
+			"any":  {},
+		},
+		Required: []string{"path"},
+	}
+	tests := []struct {
+		name    string
+		input   map[string]any
+		wantErr []string
+	}{
+		{"valid", map[string]any{"path": "a", "limit": float64(3), "ratio": 0.5, "all": true, "mode": "fast", "tags": []any{"x"}, "opts": map[string]any{"depth": float64(1)}}, nil},
+		{"extra and untyped allowed", map[string]any{"path": "a", "extra": 1, "any": []any{1}}, nil},
+		{"null optional allowed", map[string]any{"path": "a", "limit": nil}, nil},
+		{"missing required", map[string]any{}, []string{"path is required"}},
+		{"null required", map[string]any{"path": nil}, []string{"path is required"}},
+		{"wrong types", map[string]any{"path": float64(1), "limit": "5", "all": "yes"}, []string{
+			"all must be a boolean, got string \"yes\"",
+			"limit must be an integer, got string \"5\"",
+			"path must be a string, got number 1",
+		}},
+		{"fractional integer", map[string]any{"path": "a", "limit": 1.5}, []string{"limit must be an integer, got number 1.5"}},
+		{"enum", map[string]any{"path": "a", "mode": "medium"}, []string{`mode must be one of fast, slow, got "medium"`}},
+		{"array items", map[string]any{"path": "a", "tags": []any{"x", true}}, []string{"tags[1] must be a string, got boolean"}},
+		{"nested object", map[string]any{"path": "a", "items": []any{map[string]any{}}, "opts": map[string]any{"depth": "deep"}}, []string{
+			"items[0].name is required",
+			"opts.depth must be an integer",
+		}},
+		{"not an array", map[string]any{"path": "a", "tags": "x"}, []string{"tags must be an array"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := spec.ValidateInput(tt.input)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error containing %q", tt.wantErr)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q missing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestToolSpec_ArgsSummary(t *testing.T) {
+	spec := ToolSpec{
+		Properties: map[string]ToolProp{
+			"path":  {Type: "string"},
+			"limit": {Type: "integer"},
+			"mode":  {Type: "string", Enum: []string{"a", "b"}},
+			"tags":  {Type: "array", Items: &ToolProp{Type: "string"}},
+		},
+		Required: []string{"path"},
+	}
+	want := "path (string, required), limit (integer), mode (string: a|b), tags (array of string)"
+	if got := spec.ArgsSummary(); got != want {
+		t.Errorf("ArgsSummary = %q, want %q", got, want)
+	}
+	if got := (ToolSpec{}).ArgsSummary(); got != "no arguments" {
+		t.Errorf("empty ArgsSummary = %q", got)
+	}
+}
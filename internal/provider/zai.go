@@ -39,13 +39,13 @@ func (p *ZAIProvider) Name() string { return "zai" }
 
 // FetchModels retrieves the list of models from Z.AI.
 func (p *ZAIProvider) FetchModels(apiKey string) ([]domain.APIModelInfo, error) {
-	httpReq, err := http.NewRequest(http.MethodGet, zaiAPIBaseURL+"/models", nil)
+	httpReq, err := newProviderRequest("zai", http.MethodGet, zaiAPIBaseURL+"/models", nil)
 	if err != nil {
 		return nil, err
 	}
 	httpReq.Header.Set("Authorization", "Bearer "+apiKey)
 
-	client := &http.Client{}
+	client := &http.Client{Transport: streamHTTPClient.Transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return nil, err
@@ -73,6 +73,18 @@ func (p *ZAIProvider) StreamMessage(
 	tools []ToolSpec,
 	system string,
 	onDelta func(string),
+) ([]domain.ContentBlock, string, Usage, error) {
+	return p.StreamMessageWithSampling(Sampling{}, apiKey, modelID, history, tools, system, onDelta)
+}
+
+// StreamMessageWithSampling is StreamMessage with sampling parameters.
+func (p *ZAIProvider) StreamMessageWithSampling(
+	sampling Sampling,
+	apiKey, modelID string,
+	history []domain.TranscriptMessage,
+	tools []ToolSpec,
+	system string,
+	onDelta func(string),
 ) ([]domain.ContentBlock, string, Usage, error) {
 	msgs := buildOpenAIMessages(history, system)
 	streamOpts := &struct {
@@ -86,13 +98,14 @@ func (p *ZAIProvider) StreamMessage(
 		Tools:         toOpenAITools(tools),
 		StreamOptions: streamOpts,
 	}
+	sampling.applyOpenAI(&reqBody, p.Name())
 
 	body, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest(http.MethodPost, zaiAPIBaseURL+"/chat/completions", bytes.NewReader(body))
+	httpReq, err := newProviderRequest("zai", http.MethodPost, zaiAPIBaseURL+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, "", Usage{}, fmt.Errorf("creating request: %w", err)
 	}
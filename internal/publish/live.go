@@ -0,0 +1,24 @@
+package publish
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+)
+
+type liveData struct {
+	sessionData
+	Events string
+	CSS    template.CSS
+}
+
+// WriteLive writes a self-contained page showing e's transcript that keeps
+// following the session from the SSE stream at eventsURL, as served for
+// shared sessions by the daemon. Secrets are redacted like in Build.
+func WriteLive(w io.Writer, e Entry, eventsURL string) error {
+	data := liveData{sessionData: sessionPage(e), Events: eventsURL, CSS: template.CSS(siteCSS + liveCSS)}
+	if err := liveTmpl.Execute(w, data); err != nil {
+		return fmt.Errorf("rendering live page: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,273 @@
+package publish
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2/quick"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/redact"
+)
+
+// Entry is a session and its transcript to publish.
+type Entry struct {
+	Session  domain.Session
+	Messages []domain.TranscriptMessage
+}
+
+// Options controls site generation.
+type Options struct {
+	// Title is shown on the index page. Defaults to "muxd sessions".
+	Title string
+}
+
+// Build writes a static HTML site for entries into dir: an index grouped by
+// project and tag, plus one page per session under sessions/. Secrets in
+// message text and tool input/output are redacted.
+func Build(dir string, entries []Entry, opts Options) error {
+	if opts.Title == "" {
+		opts.Title = "muxd sessions"
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "sessions"), 0o755); err != nil {
+		return fmt.Errorf("creating site dir: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "style.css"), []byte(siteCSS), 0o644); err != nil {
+		return fmt.Errorf("writing stylesheet: %w", err)
+	}
+
+	for _, e := range entries {
+		var buf bytes.Buffer
+		if err := sessionTmpl.Execute(&buf, sessionPage(e)); err != nil {
+			return fmt.Errorf("rendering session %s: %w", e.Session.ID, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "sessions", e.Session.ID+".html"), buf.Bytes(), 0o644); err != nil {
+			return fmt.Errorf("writing session %s: %w", e.Session.ID, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := indexTmpl.Execute(&buf, indexPage(opts.Title, entries)); err != nil {
+		return fmt.Errorf("rendering index: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "index.html"), buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing index: %w", err)
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Index
+// ---------------------------------------------------------------------------
+
+type indexLink struct {
+	ID      string
+	Title   string
+	Project string
+	Updated string
+}
+
+type indexGroup struct {
+	Name     string
+	Sessions []indexLink
+}
+
+type indexData struct {
+	Title    string
+	Projects []indexGroup
+	Tags     []indexGroup
+}
+
+func indexPage(title string, entries []Entry) indexData {
+	byProject := map[string][]indexLink{}
+	byTag := map[string][]indexLink{}
+	for _, e := range entries {
+		s := e.Session
+		link := indexLink{
+			ID:      s.ID,
+			Title:   sessionTitle(s),
+			Project: filepath.Base(s.ProjectPath),
+			Updated: s.UpdatedAt.Format("2006-01-02"),
+		}
+		byProject[s.ProjectPath] = append(byProject[s.ProjectPath], link)
+		for _, tag := range s.TagList() {
+			byTag[tag] = append(byTag[tag], link)
+		}
+	}
+	return indexData{Title: title, Projects: sortedGroups(byProject), Tags: sortedGroups(byTag)}
+}
+
+func sortedGroups(m map[string][]indexLink) []indexGroup {
+	groups := make([]indexGroup, 0, len(m))
+	for name, links := range m {
+		groups = append(groups, indexGroup{Name: name, Sessions: links})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+func sessionTitle(s domain.Session) string {
+	if strings.TrimSpace(s.Title) != "" {
+		return redact.Secrets(s.Title)
+	}
+	return "Session " + s.ID[:min(8, len(s.ID))]
+}
+
+// ---------------------------------------------------------------------------
+// Session pages
+// ---------------------------------------------------------------------------
+
+type pageMessage struct {
+	Role string
+	Body template.HTML
+}
+
+type sessionData struct {
+	Title    string
+	Project  string
+	Model    string
+	Tags     []string
+	Created  string
+	Messages []pageMessage
+}
+
+func sessionPage(e Entry) sessionData {
+	s := e.Session
+	data := sessionData{
+		Title:   sessionTitle(s),
+		Project: s.ProjectPath,
+		Model:   s.Model,
+		Tags:    s.TagList(),
+		Created: s.CreatedAt.Format("2006-01-02 15:04"),
+	}
+	for _, m := range e.Messages {
+		body := RenderMessage(m)
+		if body == "" {
+			continue
+		}
+		data.Messages = append(data.Messages, pageMessage{Role: m.Role, Body: template.HTML(body)})
+	}
+	return data
+}
+
+// RenderMessage converts a transcript message to redacted HTML.
+func RenderMessage(m domain.TranscriptMessage) string {
+	if !m.HasBlocks() {
+		return renderMarkdown(m.Content)
+	}
+	var b strings.Builder
+	for _, blk := range m.Blocks {
+		switch blk.Type {
+		case "text":
+			b.WriteString(renderMarkdown(blk.Text))
+		case "tool_use":
+			input, _ := json.MarshalIndent(blk.ToolInput, "", "  ")
+			fmt.Fprintf(&b, "<details class=\"tool\"><summary>%s</summary><pre>%s</pre></details>\n",
+				html.EscapeString(blk.ToolName), html.EscapeString(redact.Secrets(string(input))))
+		case "tool_result":
+			class := "tool-result"
+			if blk.IsError {
+				class += " error"
+			}
+			fmt.Fprintf(&b, "<details class=\"%s\"><summary>result</summary><pre>%s</pre></details>\n",
+				class, html.EscapeString(redact.Secrets(blk.ToolResult)))
+		case "image":
+			fmt.Fprintf(&b, "<p class=\"image\">[image: %s]</p>\n", html.EscapeString(filepath.Base(blk.ImagePath)))
+		}
+	}
+	return b.String()
+}
+
+// renderMarkdown renders paragraphs and fenced code blocks. Code is
+// syntax highlighted; everything else is escaped text.
+func renderMarkdown(text string) string {
+	text = redact.Secrets(text)
+	var b strings.Builder
+	var para, code []string
+	inCode := false
+	lang := ""
+
+	flushPara := func() {
+		if len(para) == 0 {
+			return
+		}
+		b.WriteString("<p>" + strings.Join(para, "<br>\n") + "</p>\n")
+		para = nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") {
+			if inCode {
+				b.WriteString(highlight(strings.Join(code, "\n"), lang))
+				code = nil
+				inCode = false
+			} else {
+				flushPara()
+				lang = strings.TrimPrefix(trimmed, "```")
+				inCode = true
+			}
+			continue
+		}
+		if inCode {
+			code = append(code, line)
+			continue
+		}
+		if trimmed == "" {
+			flushPara()
+			continue
+		}
+		para = append(para, html.EscapeString(line))
+	}
+	if inCode {
+		b.WriteString(highlight(strings.Join(code, "\n"), lang))
+	}
+	flushPara()
+	return b.String()
+}
+
+func highlight(code, lang string) string {
+	var buf bytes.Buffer
+	if lang == "" {
+		lang = "plaintext"
+	}
+	if err := quick.Highlight(&buf, code, lang, "html", "dracula"); err != nil {
+		return "<pre>" + html.EscapeString(code) + "</pre>\n"
+	}
+	return buf.String() + "\n"
+}
+
+// ---------------------------------------------------------------------------
+// Git branch push
+// ---------------------------------------------------------------------------
+
+// Push commits the site in dir to branch and force-pushes it to remote
+// (a URL or a remote name resolved from the current repository).
+func Push(dir, remote, branch string) error {
+	if url, err := exec.Command("git", "remote", "get-url", remote).Output(); err == nil {
+		remote = strings.TrimSpace(string(url))
+	}
+	steps := [][]string{
+		{"init", "-q"},
+		{"checkout", "-q", "-B", branch},
+		{"add", "-A"},
+		{"commit", "-q", "--allow-empty", "-m", "Publish muxd sessions"},
+		{"push", "-q", "-f", remote, "HEAD:refs/heads/" + branch},
+	}
+	for _, args := range steps {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package publish
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	entries := []Entry{
+		{
+			Session: domain.Session{ID: "abc12345-0000", ProjectPath: "/src/muxd", Title: "Fix the <build>", Tags: "go,ci", UpdatedAt: time.Now()},
+			Messages: []domain.TranscriptMessage{
+				{Role: "user", Content: "my key is sk-ant-REDACTED"},
+				{Role: "assistant", Blocks: []domain.ContentBlock{
+					{Type: "text", Text: "Run this:\n\n```go\nfunc main() {}\n```"},
+					{Type: "tool_use", ToolName: "bash", ToolInput: map[string]any{"command": "go test ./..."}},
+				}},
+			},
+		},
+		{
+			Session: domain.Session{ID: "def67890-0000", ProjectPath: "/src/other", Tags: "ci"},
+		},
+	}
+	if err := Build(dir, entries, Options{}); err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	index, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatalf("reading index: %v", err)
+	}
+	for _, want := range []string{"muxd sessions", "/src/muxd", "/src/other", "sessions/abc12345-0000.html", "Fix the &lt;build&gt;", "Session def67890", ">ci<"} {
+		if !strings.Contains(string(index), want) {
+			t.Errorf("index missing %q", want)
+		}
+	}
+
+	page, err := os.ReadFile(filepath.Join(dir, "sessions", "abc12345-0000.html"))
+	if err != nil {
+		t.Fatalf("reading session page: %v", err)
+	}
+	if strings.Contains(string(page), "sk-ant-api03") {
+		t.Error("session page leaks an API key")
+	}
+	for _, want := range []string{"[REDACTED]", "<summary>bash</summary>", "go test ./...", "<pre"} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("session page missing %q", want)
+		}
+	}
+}
+
+func TestRenderMarkdown_escapes(t *testing.T) {
+	got := renderMarkdown("a <script>alert(1)</script>\nsecond line\n\nnew para")
+	if strings.Contains(got, "<script>") {
+		t.Errorf("unescaped html: %s", got)
+	}
+	if strings.Count(got, "<p>") != 2 || !strings.Contains(got, "<br>") {
+		t.Errorf("unexpected paragraphs: %s", got)
+	}
+}
@@ -0,0 +1,172 @@
+package publish
+
+import "html/template"
+
+var indexTmpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<h2>By project</h2>
+{{range .Projects}}<section>
+<h3>{{.Name}}</h3>
+<ul>{{range .Sessions}}
+<li><a href="sessions/{{.ID}}.html">{{.Title}}</a> <span class="meta">{{.Updated}}</span></li>{{end}}
+</ul>
+</section>
+{{end}}{{if .Tags}}<h2>By tag</h2>
+{{range .Tags}}<section>
+<h3 class="tag">{{.Name}}</h3>
+<ul>{{range .Sessions}}
+<li><a href="sessions/{{.ID}}.html">{{.Title}}</a> <span class="meta">{{.Project}} · {{.Updated}}</span></li>{{end}}
+</ul>
+</section>
+{{end}}{{end}}</body>
+</html>
+`))
+
+var sessionTmpl = template.Must(template.New("session").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<link rel="stylesheet" href="../style.css">
+</head>
+<body>
+<p><a href="../index.html">&larr; All sessions</a></p>
+<h1>{{.Title}}</h1>
+<p class="meta">{{.Project}} · {{.Model}} · {{.Created}}{{range .Tags}} <span class="tag">{{.}}</span>{{end}}</p>
+{{range .Messages}}<article class="msg {{.Role}}">
+<div class="role">{{.Role}}</div>
+{{.Body}}</article>
+{{end}}</body>
+</html>
+`))
+
+const siteCSS = `body { font: 15px/1.55 -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 860px; margin: 2rem auto; padding: 0 1rem; color: #222; }
+a { color: #6a3fb5; }
+.meta { color: #777; font-size: 0.85em; }
+.tag { background: #eee; border-radius: 4px; padding: 0 0.4em; font-size: 0.85em; }
+.msg { border-left: 3px solid #ccc; padding: 0.2rem 1rem; margin: 1.2rem 0; }
+.msg.user { border-color: #6aa0ff; }
+.msg.assistant { border-color: #e06ca8; }
+.role { font-weight: 600; text-transform: capitalize; color: #555; }
+pre { overflow-x: auto; padding: 0.6rem; border-radius: 4px; background: #282a36; color: #f8f8f2; }
+details.tool, details.tool-result { margin: 0.4rem 0; }
+details.error summary { color: #c0392b; }
+`
+
+// liveTmpl is the page the daemon serves for a shared session. New events
+// are appended as plain text; the transcript so far is rendered like a
+// published session.
+var liveTmpl = template.Must(template.New("live").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>{{.CSS}}</style>
+</head>
+<body>
+<h1 id="title">{{.Title}}</h1>
+<p class="meta">{{.Project}} · {{.Model}} · {{.Created}}{{range .Tags}} <span class="tag">{{.}}</span>{{end}}</p>
+<div id="log">
+{{range .Messages}}<article class="msg {{.Role}}">
+<div class="role">{{.Role}}</div>
+{{.Body}}</article>
+{{end}}</div>
+<p id="status" class="status">connecting…</p>
+<script>
+(function () {
+  var log = document.getElementById("log");
+  var status = document.getElementById("status");
+  var current = null, text = null;
+
+  function article(role) {
+    var a = document.createElement("article");
+    a.className = "msg " + role;
+    var r = document.createElement("div");
+    r.className = "role";
+    r.textContent = role;
+    a.appendChild(r);
+    log.appendChild(a);
+    return a;
+  }
+  function paragraph(a) {
+    var p = document.createElement("p");
+    p.className = "live-text";
+    a.appendChild(p);
+    return p;
+  }
+  function details(a, cls, summary, body) {
+    var d = document.createElement("details");
+    d.className = cls;
+    var s = document.createElement("summary");
+    s.textContent = summary;
+    var pre = document.createElement("pre");
+    pre.textContent = body;
+    d.appendChild(s);
+    d.appendChild(pre);
+    a.appendChild(d);
+  }
+  function assistant() {
+    if (!current) { current = article("assistant"); text = null; }
+    return current;
+  }
+  function follow() {
+    if (window.innerHeight + window.scrollY >= document.body.scrollHeight - 120) {
+      window.scrollTo(0, document.body.scrollHeight);
+    }
+  }
+  function on(name, fn) {
+    es.addEventListener(name, function (e) { fn(e.data ? JSON.parse(e.data) : {}); follow(); });
+  }
+
+  var es = new EventSource({{.Events}});
+  es.onopen = function () { status.textContent = "live"; };
+  on("status", function (d) { status.textContent = d.running ? "agent running" : "live, agent idle"; });
+  on("user", function (d) {
+    current = null;
+    var p = paragraph(article("user"));
+    p.textContent = d.text + (d.images ? " [" + d.images + " image(s)]" : "");
+    status.textContent = "agent running";
+  });
+  on("delta", function (d) {
+    var a = assistant();
+    if (!text) { text = paragraph(a); }
+    text.textContent += d.text;
+  });
+  on("tool_start", function (d) { details(assistant(), "tool", d.tool_name, d.tool_input); text = null; });
+  on("tool_done", function (d) { details(assistant(), d.is_error ? "tool-result error" : "tool-result", "result", d.result); text = null; });
+  on("ask_user", function (d) { status.textContent = "waiting for an answer: " + d.prompt; });
+  on("approval_required", function (d) { status.textContent = "waiting for approval of " + d.tool_name; });
+  on("retrying", function (d) { status.textContent = "retrying: " + d.message; });
+  on("titled", function (d) { if (d.title) { document.getElementById("title").textContent = d.title; } });
+  on("turn_done", function () { current = null; text = null; status.textContent = "live, agent idle"; });
+  on("ended", function () { status.textContent = "sharing stopped"; es.close(); });
+  es.addEventListener("error", function (e) {
+    if (e.data) {
+      var p = paragraph(assistant());
+      p.className += " error";
+      p.textContent = JSON.parse(e.data).error;
+    } else if (es.readyState !== EventSource.CLOSED) {
+      status.textContent = "reconnecting…";
+    }
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+const liveCSS = `.live-text { white-space: pre-wrap; }
+.live-text.error { color: #c0392b; }
+.status { color: #777; font-size: 0.85em; font-style: italic; }
+`
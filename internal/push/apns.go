@@ -0,0 +1,162 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// APNs hosts.
+const (
+	APNsProduction = "https://api.push.apple.com"
+	APNsSandbox    = "https://api.sandbox.push.apple.com"
+)
+
+// apnsTokenTTL is how long a provider token is reused. Apple rejects
+// tokens older than an hour and refreshes more often than every 20
+// minutes.
+const apnsTokenTTL = 50 * time.Minute
+
+// APNs sends notifications through the Apple Push Notification service,
+// authenticating with a token signed by an auth key (.p8).
+type APNs struct {
+	Key    *ecdsa.PrivateKey
+	KeyID  string
+	TeamID string
+	Topic  string // the app's bundle ID
+	Host   string // APNsProduction, APNsSandbox, or a test server
+	Client *http.Client
+
+	mu        sync.Mutex
+	token     string
+	tokenTime time.Time
+}
+
+// LoadAPNs reads the auth key at keyPath and returns a sender for topic.
+func LoadAPNs(keyPath, keyID, teamID, topic string, sandbox bool) (*APNs, error) {
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, fmt.Errorf("APNs needs a key ID, team ID, and topic")
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading APNs key: %w", err)
+	}
+	key, err := ParseAPNsKey(data)
+	if err != nil {
+		return nil, err
+	}
+	host := APNsProduction
+	if sandbox {
+		host = APNsSandbox
+	}
+	return &APNs{Key: key, KeyID: keyID, TeamID: teamID, Topic: topic, Host: host}, nil
+}
+
+// ParseAPNsKey parses a PEM-encoded PKCS #8 P-256 key, the format of the
+// .p8 files Apple issues.
+func ParseAPNsKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("APNs key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing APNs key: %w", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("APNs key is not an ECDSA key")
+	}
+	return key, nil
+}
+
+// providerToken returns the cached ES256 provider token, signing a new one
+// when it is due.
+func (a *APNs) providerToken(now time.Time) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != "" && now.Sub(a.tokenTime) < apnsTokenTTL {
+		return a.token, nil
+	}
+	header := map[string]string{"alg": "ES256", "kid": a.KeyID}
+	claims := map[string]any{"iss": a.TeamID, "iat": now.Unix()}
+	tok, err := signJWT(header, claims, func(digest []byte) ([]byte, error) {
+		r, s, err := ecdsa.Sign(rand.Reader, a.Key, digest)
+		if err != nil {
+			return nil, err
+		}
+		// JWS wants r and s as fixed-size big-endian integers.
+		sig := make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+		return sig, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	a.token, a.tokenTime = tok, now
+	return tok, nil
+}
+
+// Send delivers n to the device token as an alert.
+func (a *APNs) Send(ctx context.Context, token string, n Notification) error {
+	jwt, err := a.providerToken(time.Now())
+	if err != nil {
+		return err
+	}
+	payload := map[string]any{
+		"aps": map[string]any{
+			"alert": map[string]string{"title": n.Title, "body": n.Body},
+			"sound": "default",
+		},
+	}
+	for k, v := range n.Data {
+		if k != "aps" {
+			payload[k] = v
+		}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.Host+"/3/device/"+url.PathEscape(token), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "bearer "+jwt)
+	req.Header.Set("apns-topic", a.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := defaultClient(a.Client).Do(req)
+	if err != nil {
+		return fmt.Errorf("sending APNs notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var reply struct {
+		Reason string `json:"reason"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reply)
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		reply.Reason == "BadDeviceToken", reply.Reason == "Unregistered", reply.Reason == "DeviceTokenNotForTopic":
+		return fmt.Errorf("APNs: %s: %w", reply.Reason, ErrUnregistered)
+	case reply.Reason != "":
+		return fmt.Errorf("APNs: HTTP %d: %s", resp.StatusCode, reply.Reason)
+	}
+	return fmt.Errorf("APNs: HTTP %d", resp.StatusCode)
+}
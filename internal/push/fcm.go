@@ -0,0 +1,191 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FCMHost is the Firebase Cloud Messaging API host.
+const FCMHost = "https://fcm.googleapis.com"
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCM sends notifications through the Firebase Cloud Messaging HTTP v1
+// API, authenticating as a service account.
+type FCM struct {
+	ProjectID   string
+	ClientEmail string
+	Key         *rsa.PrivateKey
+	TokenURL    string // OAuth token endpoint from the service account
+	Host        string // FCMHost or a test server
+	Client      *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expires     time.Time
+}
+
+// serviceAccount is the subset of a Google service account key file that
+// FCM needs.
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadFCM reads a service account key file downloaded from the Firebase
+// console.
+func LoadFCM(credentialsPath string) (*FCM, error) {
+	data, err := os.ReadFile(credentialsPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading FCM credentials: %w", err)
+	}
+	return ParseFCMCredentials(data)
+}
+
+// ParseFCMCredentials parses a service account key file.
+func ParseFCMCredentials(data []byte) (*FCM, error) {
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("parsing FCM credentials: %w", err)
+	}
+	if sa.ProjectID == "" || sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("FCM credentials need project_id, client_email, and private_key")
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("FCM private key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing FCM private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("FCM private key is not an RSA key")
+	}
+	tokenURL := sa.TokenURI
+	if tokenURL == "" {
+		tokenURL = "https://oauth2.googleapis.com/token"
+	}
+	return &FCM{ProjectID: sa.ProjectID, ClientEmail: sa.ClientEmail, Key: key, TokenURL: tokenURL, Host: FCMHost}, nil
+}
+
+// oauthToken returns a cached access token, exchanging a signed assertion
+// for a new one shortly before the old one expires.
+func (f *FCM) oauthToken(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if f.accessToken != "" && now.Before(f.expires.Add(-time.Minute)) {
+		return f.accessToken, nil
+	}
+
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]any{
+		"iss":   f.ClientEmail,
+		"scope": fcmScope,
+		"aud":   f.TokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	assertion, err := signJWT(header, claims, func(digest []byte) ([]byte, error) {
+		return rsa.SignPKCS1v15(rand.Reader, f.Key, crypto.SHA256, digest)
+	})
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := defaultClient(f.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting FCM access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("requesting FCM access token: HTTP %d: %s", resp.StatusCode, errorBody(resp))
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("parsing FCM access token: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("requesting FCM access token: empty token")
+	}
+	f.accessToken = tok.AccessToken
+	f.expires = now.Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return f.accessToken, nil
+}
+
+// Send delivers n to the device registration token.
+func (f *FCM) Send(ctx context.Context, token string, n Notification) error {
+	access, err := f.oauthToken(ctx)
+	if err != nil {
+		return err
+	}
+	msg := map[string]any{
+		"token":        token,
+		"notification": map[string]string{"title": n.Title, "body": n.Body},
+	}
+	if len(n.Data) > 0 {
+		msg["data"] = n.Data
+	}
+	body, err := json.Marshal(map[string]any{"message": msg})
+	if err != nil {
+		return err
+	}
+	endpoint := f.Host + "/v1/projects/" + url.PathEscape(f.ProjectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+access)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := defaultClient(f.Client).Do(req)
+	if err != nil {
+		return fmt.Errorf("sending FCM notification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var reply struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	_ = json.NewDecoder(resp.Body).Decode(&reply)
+	if resp.StatusCode == http.StatusNotFound || reply.Error.Status == "UNREGISTERED" || reply.Error.Status == "NOT_FOUND" {
+		return fmt.Errorf("FCM: %s: %w", reply.Error.Status, ErrUnregistered)
+	}
+	if reply.Error.Message != "" {
+		return fmt.Errorf("FCM: HTTP %d: %s", resp.StatusCode, reply.Error.Message)
+	}
+	return fmt.Errorf("FCM: HTTP %d", resp.StatusCode)
+}
@@ -0,0 +1,77 @@
+package push
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+// Notification is an alert shown by the mobile app.
+type Notification struct {
+	Title string
+	Body  string
+	// Data is delivered to the app with the alert, e.g. the session ID to
+	// open when it is tapped.
+	Data map[string]string
+}
+
+// Sender delivers notifications to device tokens of one push platform.
+type Sender interface {
+	Send(ctx context.Context, token string, n Notification) error
+}
+
+// ErrUnregistered means the push service no longer accepts a device token,
+// e.g. because the app was uninstalled. Callers should forget the token.
+var ErrUnregistered = errors.New("device token is no longer registered")
+
+// sendTimeout bounds a single push request.
+const sendTimeout = 15 * time.Second
+
+func defaultClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return &http.Client{Timeout: sendTimeout, Transport: egress.Transport(nil)}
+}
+
+// ---------------------------------------------------------------------------
+// JWT
+// ---------------------------------------------------------------------------
+
+// signJWT encodes header and claims and signs them with sign, which gets
+// the SHA-256 digest of the signing input.
+func signJWT(header, claims any, sign func(digest []byte) ([]byte, error)) (string, error) {
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := b64(h) + "." + b64(c)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := sign(digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing token: %w", err)
+	}
+	return input + "." + b64(sig), nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// errorBody reads up to 1 KB of an error response for the error message.
+func errorBody(resp *http.Response) string {
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return string(b)
+}
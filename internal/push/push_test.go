@@ -0,0 +1,231 @@
+package push
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func pemPKCS8(t *testing.T, key any) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+// splitJWT returns the decoded claims, signing input digest, and signature.
+func splitJWT(t *testing.T, tok string) (map[string]any, []byte, []byte) {
+	t.Helper()
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		t.Fatalf("malformed JWT %q", tok)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	return claims, digest[:], sig
+}
+
+func TestAPNsSend(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "AuthKey.p8")
+	if err := os.WriteFile(keyPath, pemPKCS8(t, key), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/3/device/gone" {
+			w.WriteHeader(http.StatusGone)
+			fmt.Fprint(w, `{"reason":"Unregistered"}`)
+			return
+		}
+		if r.URL.Path != "/3/device/dev-1" || r.Header.Get("apns-topic") != "com.example.muxd" || r.Header.Get("apns-push-type") != "alert" {
+			t.Errorf("request %s topic=%q", r.URL.Path, r.Header.Get("apns-topic"))
+		}
+		claims, digest, sig := splitJWT(t, strings.TrimPrefix(r.Header.Get("Authorization"), "bearer "))
+		if claims["iss"] != "TEAM123456" {
+			t.Errorf("claims = %v", claims)
+		}
+		rs, ss := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		if len(sig) != 64 || !ecdsa.Verify(&key.PublicKey, digest, rs, ss) {
+			t.Error("provider token signature does not verify")
+		}
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer ts.Close()
+
+	if _, err := LoadAPNs(keyPath, "", "TEAM123456", "com.example.muxd", false); err == nil {
+		t.Error("expected an error without a key ID")
+	}
+	a, err := LoadAPNs(keyPath, "KEY1234567", "TEAM123456", "com.example.muxd", true)
+	if err != nil {
+		t.Fatalf("LoadAPNs: %v", err)
+	}
+	if a.Host != APNsSandbox {
+		t.Errorf("Host = %q, want sandbox", a.Host)
+	}
+	a.Host = ts.URL
+
+	n := Notification{Title: "muxd", Body: "Turn finished", Data: map[string]string{"session_id": "s1"}}
+	if err := a.Send(context.Background(), "dev-1", n); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	alert := payload["aps"].(map[string]any)["alert"].(map[string]any)
+	if alert["body"] != "Turn finished" || payload["session_id"] != "s1" {
+		t.Errorf("payload = %v", payload)
+	}
+
+	err = a.Send(context.Background(), "gone", n)
+	if !errors.Is(err, ErrUnregistered) {
+		t.Errorf("Send to an unregistered token = %v, want ErrUnregistered", err)
+	}
+}
+
+func TestAPNsProviderTokenCached(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	a := &APNs{Key: key, KeyID: "K", TeamID: "T"}
+	now := time.Now()
+	first, err := a.providerToken(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := a.providerToken(now.Add(10 * time.Minute)); again != first {
+		t.Error("token re-signed before it was due")
+	}
+	if later, _ := a.providerToken(now.Add(apnsTokenTTL)); later == first {
+		t.Error("token not refreshed after its TTL")
+	}
+}
+
+func TestParseAPNsKey_invalid(t *testing.T) {
+	if _, err := ParseAPNsKey([]byte("not pem")); err == nil {
+		t.Error("expected an error for non-PEM input")
+	}
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := ParseAPNsKey(pemPKCS8(t, rsaKey)); err == nil {
+		t.Error("expected an error for an RSA key")
+	}
+}
+
+func TestFCMSend(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenRequests := 0
+	var message map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests++
+		_ = r.ParseForm()
+		claims, digest, sig := splitJWT(t, r.PostForm.Get("assertion"))
+		if claims["iss"] != "muxd@example.iam.gserviceaccount.com" || claims["scope"] != fcmScope {
+			t.Errorf("claims = %v", claims)
+		}
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest, sig); err != nil {
+			t.Errorf("assertion signature: %v", err)
+		}
+		fmt.Fprint(w, `{"access_token":"access-1","expires_in":3600}`)
+	})
+	mux.HandleFunc("POST /v1/projects/muxd-app/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access-1" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct {
+			Message map[string]any `json:"message"`
+		}
+		_ = json.Unmarshal(body, &req)
+		if req.Message["token"] == "gone" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"status":"NOT_FOUND","message":"Requested entity was not found."}}`)
+			return
+		}
+		message = req.Message
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	creds, _ := json.Marshal(map[string]string{
+		"project_id":   "muxd-app",
+		"client_email": "muxd@example.iam.gserviceaccount.com",
+		"private_key":  string(pemPKCS8(t, key)),
+		"token_uri":    ts.URL + "/token",
+	})
+	path := filepath.Join(t.TempDir(), "service-account.json")
+	if err := os.WriteFile(path, creds, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFCM(path)
+	if err != nil {
+		t.Fatalf("LoadFCM: %v", err)
+	}
+	f.Host = ts.URL
+
+	n := Notification{Title: "muxd", Body: "Question", Data: map[string]string{"event": "ask_user"}}
+	for range 2 {
+		if err := f.Send(context.Background(), "dev-1", n); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	if tokenRequests != 1 {
+		t.Errorf("token requests = %d, want the access token reused", tokenRequests)
+	}
+	if message["token"] != "dev-1" || message["data"].(map[string]any)["event"] != "ask_user" {
+		t.Errorf("message = %v", message)
+	}
+
+	err = f.Send(context.Background(), "gone", n)
+	if !errors.Is(err, ErrUnregistered) {
+		t.Errorf("Send to an unregistered token = %v, want ErrUnregistered", err)
+	}
+}
+
+func TestParseFCMCredentials_invalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"not json":    "{",
+		"missing key": `{"project_id":"p","client_email":"e"}`,
+		"bad key":     `{"project_id":"p","client_email":"e","private_key":"nope"}`,
+	} {
+		if _, err := ParseFCMCredentials([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
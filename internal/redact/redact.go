@@ -0,0 +1,173 @@
+package redact
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholder replaces every redacted secret.
+const Placeholder = "[REDACTED]"
+
+// secretPatterns match common credential formats. Patterns with a capture
+// group keep the group (e.g. the key name) and redact only the value.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_\-]{16,}`),
+	regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_\-]{20,}`),
+	regexp.MustCompile(`\bxai-[A-Za-z0-9]{20,}`),
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{30,}`),
+	regexp.MustCompile(`\bgithub_pat_[A-Za-z0-9_]{30,}`),
+	regexp.MustCompile(`\bglpat-[A-Za-z0-9_\-]{20,}`),
+	regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9\-]{10,}`),
+	regexp.MustCompile(`\bxapp-[0-9]-[A-Za-z0-9\-]{10,}`), // Slack app-level token
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[A-Z0-9]{16}\b`),
+	regexp.MustCompile(`\bAIza[0-9A-Za-z_\-]{35}\b`),
+	regexp.MustCompile(`\b[0-9]{8,10}:AA[A-Za-z0-9_\-]{33}\b`),                                  // Telegram bot token
+	regexp.MustCompile(`\b[MNO][A-Za-z0-9_\-]{23,27}\.[A-Za-z0-9_\-]{6}\.[A-Za-z0-9_\-]{27,}`),  // Discord bot token
+	regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.eyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`), // JWT
+	regexp.MustCompile(`(?i)(\bbearer\s+)[A-Za-z0-9._~+/\-]{16,}=*`),
+	regexp.MustCompile(`(?i)((?:api[_-]?key|secret|token|password|passwd|auth)["']?\s*[:=]\s*["']?)[^\s"',;]{8,}`),
+}
+
+// Secrets replaces API keys, tokens, private keys, and similar credentials
+// in s with Placeholder.
+func Secrets(s string) string {
+	for _, re := range secretPatterns {
+		if re.NumSubexp() > 0 {
+			s = re.ReplaceAllString(s, "${1}"+Placeholder)
+		} else {
+			s = re.ReplaceAllString(s, Placeholder)
+		}
+	}
+	return s
+}
+
+// ---------------------------------------------------------------------------
+// Detection
+// ---------------------------------------------------------------------------
+
+// Match is a sensitive value found in text.
+type Match struct {
+	Kind string // "secret", "email", "phone", "ssn", "card", or "custom"
+	Text string
+}
+
+var piiPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"email", regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`)},
+	{"ssn", regexp.MustCompile(`\b[0-9]{3}-[0-9]{2}-[0-9]{4}\b`)},
+	{"card", regexp.MustCompile(`\b(?:[0-9][ \-]?){12,18}[0-9]\b`)},
+	{"phone", regexp.MustCompile(`(?:\+[0-9]{1,3}[ \-.]?)?\(?\b[0-9]{3}\)?[ \-.][0-9]{3}[ \-.][0-9]{4}\b`)},
+}
+
+// Find returns the credentials and personal data (emails, phone numbers,
+// SSNs, and Luhn-valid card numbers) in s, in pattern order. A span matched
+// by an earlier pattern is not reported again.
+func Find(s string) []Match {
+	var sc scanner
+	for _, re := range secretPatterns {
+		sc.scan(s, "secret", re)
+	}
+	for _, p := range piiPatterns {
+		sc.scan(s, p.kind, p.re)
+	}
+	return sc.matches
+}
+
+// FindPII returns the personal data in s: emails, phone numbers, SSNs,
+// card numbers, and matches of the extra patterns (kind "custom", e.g.
+// configured names).
+func FindPII(s string, extra []*regexp.Regexp) []Match {
+	return scanPII(s, extra).matches
+}
+
+// MaskPII replaces every FindPII match with a placeholder naming its kind,
+// e.g. "[EMAIL]", and returns the masked text with the matches.
+func MaskPII(s string, extra []*regexp.Regexp) (string, []Match) {
+	sc := scanPII(s, extra)
+	if len(sc.matches) == 0 {
+		return s, nil
+	}
+	order := make([]int, len(sc.spans))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return sc.spans[order[a]][0] < sc.spans[order[b]][0] })
+
+	var b strings.Builder
+	last := 0
+	for _, i := range order {
+		span := sc.spans[i]
+		b.WriteString(s[last:span[0]])
+		b.WriteString("[" + strings.ToUpper(sc.matches[i].Kind) + "]")
+		last = span[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), sc.matches
+}
+
+func scanPII(s string, extra []*regexp.Regexp) *scanner {
+	sc := &scanner{}
+	// Built-in patterns run first so a configured name inside an email
+	// address is masked together with the whole address.
+	for _, p := range piiPatterns {
+		sc.scan(s, p.kind, p.re)
+	}
+	for _, re := range extra {
+		sc.scan(s, "custom", re)
+	}
+	return sc
+}
+
+// scanner collects non-overlapping matches.
+type scanner struct {
+	matches []Match
+	spans   [][]int
+}
+
+func (sc *scanner) scan(s, kind string, re *regexp.Regexp) {
+	for _, loc := range re.FindAllStringIndex(s, -1) {
+		if loc[0] == loc[1] || overlaps(sc.spans, loc) {
+			continue
+		}
+		text := s[loc[0]:loc[1]]
+		if kind == "card" && !luhnValid(text) {
+			continue
+		}
+		sc.spans = append(sc.spans, loc)
+		sc.matches = append(sc.matches, Match{Kind: kind, Text: text})
+	}
+}
+
+func overlaps(spans [][]int, loc []int) bool {
+	for _, sp := range spans {
+		if loc[0] < sp[1] && sp[0] < loc[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
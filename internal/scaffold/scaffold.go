@@ -0,0 +1,266 @@
+// Package scaffold gives a new project its starting muxd setup: a
+// .muxd.toml, a .mcp.json template, and detected facts in project memory.
+package scaffold
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// Project is what Detect found out about a repository. Fields it could not
+// work out are empty.
+type Project struct {
+	Language  string `json:"language,omitempty"`
+	BuildTool string `json:"build_tool,omitempty"`
+	Build     string `json:"build_command,omitempty"`
+	Test      string `json:"test_command,omitempty"`
+	Lint      string `json:"lint_command,omitempty"`
+}
+
+// Facts returns p as project memory facts.
+func (p Project) Facts() map[string]string {
+	facts := map[string]string{}
+	for key, value := range map[string]string{
+		"language":      p.Language,
+		"build_tool":    p.BuildTool,
+		"build_command": p.Build,
+		"test_command":  p.Test,
+		"lint_command":  p.Lint,
+	} {
+		if value != "" {
+			facts[key] = value
+		}
+	}
+	return facts
+}
+
+// String describes p in one line, e.g. "Go (go); test: go test ./...".
+func (p Project) String() string {
+	if p.Language == "" {
+		return "unknown project type"
+	}
+	s := p.Language
+	if p.BuildTool != "" {
+		s += " (" + p.BuildTool + ")"
+	}
+	if p.Test != "" {
+		s += "; test: " + p.Test
+	}
+	return s
+}
+
+// Detect inspects dir's marker files (go.mod, package.json, Cargo.toml,
+// ...) for the language, build tool, and build, test, and lint commands.
+// The first marker found wins; a Makefile with a test target overrides the
+// test command, since projects that have one usually mean it.
+func Detect(dir string) Project {
+	has := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	var p Project
+	switch {
+	case has("go.mod"):
+		p = Project{Language: "Go", BuildTool: "go", Build: "go build ./...", Test: "go test ./...", Lint: "go vet ./..."}
+	case has("Cargo.toml"):
+		p = Project{Language: "Rust", BuildTool: "cargo", Build: "cargo build", Test: "cargo test", Lint: "cargo clippy"}
+	case has("package.json"):
+		p = detectNode(dir, has)
+	case has("pyproject.toml") || has("requirements.txt") || has("setup.py"):
+		p = Project{Language: "Python", BuildTool: "pip", Test: "pytest"}
+		switch {
+		case has("uv.lock"):
+			p.BuildTool, p.Test = "uv", "uv run pytest"
+		case has("poetry.lock"):
+			p.BuildTool, p.Test = "poetry", "poetry run pytest"
+		}
+	case has("pom.xml"):
+		p = Project{Language: "Java", BuildTool: "maven", Build: "mvn -q package", Test: "mvn -q test"}
+	case has("build.gradle.kts") || has("build.gradle"):
+		gradle := "gradle"
+		if has("gradlew") {
+			gradle = "./gradlew"
+		}
+		p = Project{Language: "Java", BuildTool: "gradle", Build: gradle + " build", Test: gradle + " test"}
+		if has("build.gradle.kts") {
+			p.Language = "Kotlin"
+		}
+	case has("Gemfile"):
+		p = Project{Language: "Ruby", BuildTool: "bundler", Test: "bundle exec rake test"}
+		if has("spec") {
+			p.Test = "bundle exec rspec"
+		}
+	case has("mix.exs"):
+		p = Project{Language: "Elixir", BuildTool: "mix", Build: "mix compile", Test: "mix test"}
+	case has("CMakeLists.txt"):
+		p = Project{Language: "C/C++", BuildTool: "cmake", Build: "cmake -B build && cmake --build build", Test: "ctest --test-dir build"}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "Makefile")); err == nil {
+		if p.BuildTool == "" {
+			p.BuildTool, p.Build = "make", "make"
+		}
+		if makeTarget.Match(data) {
+			p.Test = "make test"
+		}
+	}
+	return p
+}
+
+var makeTarget = regexp.MustCompile(`(?m)^test\s*:`)
+
+func detectNode(dir string, has func(string) bool) Project {
+	p := Project{Language: "JavaScript", BuildTool: "npm"}
+	if has("tsconfig.json") {
+		p.Language = "TypeScript"
+	}
+	switch {
+	case has("pnpm-lock.yaml"):
+		p.BuildTool = "pnpm"
+	case has("yarn.lock"):
+		p.BuildTool = "yarn"
+	case has("bun.lockb") || has("bun.lock"):
+		p.BuildTool = "bun"
+	}
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, "package.json"))
+	_ = json.Unmarshal(data, &pkg)
+	run := func(script string) string {
+		if _, ok := pkg.Scripts[script]; !ok {
+			return ""
+		}
+		if script == "test" {
+			return p.BuildTool + " test"
+		}
+		return p.BuildTool + " run " + script
+	}
+	p.Build, p.Test, p.Lint = run("build"), run("test"), run("lint")
+	return p
+}
+
+// ---------------------------------------------------------------------------
+// Init
+// ---------------------------------------------------------------------------
+
+// Result reports what Init did.
+type Result struct {
+	Project Project  `json:"project"`
+	Written []string `json:"written,omitempty"` // files created
+	Kept    []string `json:"kept,omitempty"`    // files that already existed
+	Facts   []string `json:"facts,omitempty"`   // memory keys added
+}
+
+// Init gives the project at dir a starting setup: a commented .muxd.toml,
+// a .mcp.json template with no servers enabled, and the detected facts in
+// project memory. Existing files and memory keys are left alone, so it is
+// safe to run again.
+func Init(dir string) (Result, error) {
+	res := Result{Project: Detect(dir)}
+	files := []struct {
+		name    string
+		content string
+	}{
+		{config.ProjectConfigFile, projectConfigTemplate(res.Project)},
+		{".mcp.json", mcpTemplate},
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f.name)
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if os.IsExist(err) {
+			res.Kept = append(res.Kept, f.name)
+			continue
+		}
+		if err != nil {
+			return res, err
+		}
+		_, err = file.WriteString(f.content)
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return res, fmt.Errorf("writing %s: %w", f.name, err)
+		}
+		res.Written = append(res.Written, f.name)
+	}
+
+	mem := tools.NewProjectMemory(dir)
+	facts, err := mem.Load()
+	if err != nil {
+		return res, err
+	}
+	for key, value := range res.Project.Facts() {
+		if _, ok := facts[key]; !ok {
+			facts[key] = value
+			res.Facts = append(res.Facts, key)
+		}
+	}
+	if len(res.Facts) > 0 {
+		sort.Strings(res.Facts)
+		if err := mem.Save(facts); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+func projectConfigTemplate(p Project) string {
+	var b strings.Builder
+	b.WriteString("# muxd settings for this project. They override your own config in\n")
+	b.WriteString("# sessions here; only the keys below are read from this file.\n")
+	if p.Language != "" {
+		fmt.Fprintf(&b, "# Detected: %s.\n", p)
+	}
+	b.WriteString(`
+[model]
+# compact = "claude-haiku"   # summarizes long sessions
+# title = "claude-haiku"     # names sessions
+# tags = ""
+# consult = ""
+# verify = ""
+
+[style]
+# language = "English"
+# tone = "terse"            # terse, explanatory, or code-only
+
+[sampling]
+# temperature = 0.2
+# top_p = 1.0
+# seed = 0
+# max_tokens = 8192
+# stop = []
+
+[tools]
+# Turned off in this project, on top of your own tools.disabled.
+# disabled = ["sms_send", "sms_schedule"]
+`)
+	return b.String()
+}
+
+// mcpTemplate has no servers enabled. JSON has no comments, so the notes
+// and examples sit under keys MCP loaders ignore.
+const mcpTemplate = `{
+  "//": "MCP servers muxd starts for this project, on top of ~/.config/muxd/mcp.json. Move an entry from _examples into mcpServers to enable it. ${VAR} is expanded from the environment.",
+  "mcpServers": {},
+  "_examples": {
+    "filesystem": {
+      "type": "stdio",
+      "command": "npx",
+      "args": ["-y", "@modelcontextprotocol/server-filesystem", "."]
+    },
+    "github": {
+      "type": "http",
+      "url": "https://api.githubcopilot.com/mcp/",
+      "headers": {"Authorization": "Bearer ${GITHUB_TOKEN}"}
+    }
+  }
+}
+`
@@ -0,0 +1,147 @@
+package scaffold
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  Project
+	}{
+		{
+			name:  "go module",
+			files: map[string]string{"go.mod": "module example.com/x\n"},
+			want:  Project{Language: "Go", BuildTool: "go", Build: "go build ./...", Test: "go test ./...", Lint: "go vet ./..."},
+		},
+		{
+			name: "typescript with pnpm scripts",
+			files: map[string]string{
+				"package.json":   `{"scripts": {"build": "tsc", "test": "vitest"}}`,
+				"tsconfig.json":  "{}",
+				"pnpm-lock.yaml": "",
+			},
+			want: Project{Language: "TypeScript", BuildTool: "pnpm", Build: "pnpm run build", Test: "pnpm test"},
+		},
+		{
+			name:  "python with uv",
+			files: map[string]string{"pyproject.toml": "", "uv.lock": ""},
+			want:  Project{Language: "Python", BuildTool: "uv", Test: "uv run pytest"},
+		},
+		{
+			name:  "makefile test target overrides",
+			files: map[string]string{"Cargo.toml": "", "Makefile": "build:\n\tcargo build\ntest:\n\tcargo nextest run\n"},
+			want:  Project{Language: "Rust", BuildTool: "cargo", Build: "cargo build", Test: "make test", Lint: "cargo clippy"},
+		},
+		{
+			name: "unknown",
+			want: Project{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tt.files)
+			if got := Detect(dir); got != tt.want {
+				t.Errorf("Detect = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInit(t *testing.T) {
+	t.Run("writes files and facts", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{"go.mod": "module example.com/x\n"})
+
+		res, err := Init(dir)
+		if err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		if !slices.Equal(res.Written, []string{config.ProjectConfigFile, ".mcp.json"}) {
+			t.Errorf("Written = %v", res.Written)
+		}
+		if !slices.Contains(res.Facts, "test_command") {
+			t.Errorf("Facts = %v, want test_command", res.Facts)
+		}
+
+		// The generated files must load cleanly.
+		var p config.Preferences
+		if err := config.ApplyProjectConfig(&p, dir); err != nil {
+			t.Errorf("generated %s: %v", config.ProjectConfigFile, err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, ".mcp.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var mcp struct {
+			MCPServers map[string]any `json:"mcpServers"`
+		}
+		if err := json.Unmarshal(data, &mcp); err != nil {
+			t.Fatalf("generated .mcp.json: %v", err)
+		}
+		if len(mcp.MCPServers) != 0 {
+			t.Errorf("template should enable no servers, got %v", mcp.MCPServers)
+		}
+
+		facts, err := tools.NewProjectMemory(dir).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if facts["test_command"] != "go test ./..." {
+			t.Errorf("test_command = %q", facts["test_command"])
+		}
+	})
+
+	t.Run("keeps existing files and facts", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFiles(t, dir, map[string]string{
+			"go.mod":    "module example.com/x\n",
+			".mcp.json": `{"mcpServers": {}}`,
+		})
+		mem := tools.NewProjectMemory(dir)
+		if err := mem.Save(map[string]string{"test_command": "make check"}); err != nil {
+			t.Fatal(err)
+		}
+
+		res, err := Init(dir)
+		if err != nil {
+			t.Fatalf("Init: %v", err)
+		}
+		if !slices.Equal(res.Kept, []string{".mcp.json"}) {
+			t.Errorf("Kept = %v", res.Kept)
+		}
+		if slices.Contains(res.Facts, "test_command") {
+			t.Error("existing test_command should be kept")
+		}
+		facts, _ := mem.Load()
+		if facts["test_command"] != "make check" {
+			t.Errorf("test_command = %q, want make check", facts["test_command"])
+		}
+
+		again, err := Init(dir)
+		if err != nil {
+			t.Fatalf("second Init: %v", err)
+		}
+		if len(again.Written) != 0 || len(again.Facts) != 0 {
+			t.Errorf("second Init changed things: %+v", again)
+		}
+	})
+}
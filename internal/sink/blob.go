@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// BlobsDir is where large tool results are kept in the storage sink.
+const BlobsDir = "blobs/"
+
+// PutBlob stores data under its SHA-256, so storing the same content twice
+// keeps one copy, and returns that ID.
+func PutBlob(ctx context.Context, s Sink, data []byte) (string, error) {
+	sum := sha256.Sum256(data)
+	id := hex.EncodeToString(sum[:])
+	if err := PutBytes(ctx, s, blobKey(id), data); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// GetBlob opens the blob with the given ID.
+func GetBlob(ctx context.Context, s Sink, id string) (io.ReadCloser, error) {
+	if !ValidBlobID(id) {
+		return nil, fmt.Errorf("invalid blob ID %q", id)
+	}
+	return s.Get(ctx, blobKey(id))
+}
+
+// ValidBlobID reports whether id looks like an ID returned by PutBlob.
+func ValidBlobID(id string) bool {
+	if len(id) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(id)
+	return err == nil
+}
+
+func blobKey(id string) string { return BlobsDir + id[:2] + "/" + id + ".txt" }
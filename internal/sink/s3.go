@@ -0,0 +1,335 @@
+package sink
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+// S3 keeps objects in an S3-compatible bucket, addressed path-style
+// (https://host/bucket/prefix) so it also works with MinIO, R2, and others.
+// Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	Endpoint  *url.URL // scheme and host
+	Bucket    string
+	Prefix    string // key prefix, without a trailing slash
+	Region    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+}
+
+// NewS3 parses rawURL as https://host/bucket[/prefix].
+func NewS3(rawURL string, c Credentials) (*S3, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid S3 URL %q", rawURL)
+	}
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("S3 URL %q has no bucket", rawURL)
+	}
+	if c.AccessKey == "" || c.SecretKey == "" {
+		return nil, fmt.Errorf("S3 credentials are not set")
+	}
+	region := c.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &S3{
+		Endpoint:  &url.URL{Scheme: u.Scheme, Host: u.Host},
+		Bucket:    bucket,
+		Prefix:    strings.Trim(prefix, "/"),
+		Region:    region,
+		AccessKey: c.AccessKey,
+		SecretKey: c.SecretKey,
+		Client:    &http.Client{Timeout: 10 * time.Minute, Transport: egress.Transport(nil)},
+	}, nil
+}
+
+func (s *S3) key(name string) string {
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+func (s *S3) objectURL(key string) (*url.URL, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	u := *s.Endpoint
+	u.Path = "/" + s.Bucket + "/" + s.key(k)
+	return &u, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, r io.ReadSeeker) error {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, r)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), io.NopCloser(r))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if strings.HasSuffix(key, ".tar.gz") {
+		contentType = "application/gzip"
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if _, err := s.do(req, hex.EncodeToString(h.Sum(nil))); err != nil {
+		return fmt.Errorf("uploading %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.send(req, emptyPayloadHash)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("downloading %s: %w", key, err)
+	}
+	return resp.Body, nil
+}
+
+// listBucketResult is the subset of a ListObjectsV2 response we read.
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	token := ""
+	for {
+		u := *s.Endpoint
+		u.Path = "/" + s.Bucket
+		q := url.Values{"list-type": {"2"}, "prefix": {s.key(prefix)}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		body, err := s.do(req, emptyPayloadHash)
+		if err != nil {
+			return nil, fmt.Errorf("listing %s: %w", s, err)
+		}
+		var res listBucketResult
+		if err := xml.Unmarshal(body, &res); err != nil {
+			return nil, fmt.Errorf("listing %s: %w", s, err)
+		}
+		for _, c := range res.Contents {
+			keys = append(keys, strings.TrimPrefix(c.Key, s.key("")))
+		}
+		if !res.IsTruncated || res.NextContinuationToken == "" {
+			return keys, nil
+		}
+		token = res.NextContinuationToken
+	}
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	if _, err := s.do(req, emptyPayloadHash); err != nil {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3) String() string {
+	return s.Endpoint.String() + "/" + s.Bucket + "/" + s.Prefix
+}
+
+// do signs and sends req, returning the response body of a 2xx response.
+func (s *S3) do(req *http.Request, payloadHash string) ([]byte, error) {
+	resp, err := s.send(req, payloadHash)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+}
+
+// send signs and sends req. A response that is not 2xx is closed and turned
+// into an error, ErrNotFound for a missing key.
+func (s *S3) send(req *http.Request, payloadHash string) (*http.Response, error) {
+	signV4(req, payloadHash, s.Region, s.AccessKey, s.SecretKey, time.Now())
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	_ = xml.Unmarshal(body, &e)
+	if e.Code == "NoSuchKey" || (req.Method == http.MethodGet && resp.StatusCode == http.StatusNotFound && e.Code == "") {
+		return nil, ErrNotFound
+	}
+	if e.Code != "" {
+		return nil, fmt.Errorf("%s: %s", e.Code, e.Message)
+	}
+	return nil, fmt.Errorf("unexpected status %s", resp.Status)
+}
+
+// ---------------------------------------------------------------------------
+// Signature Version 4
+// ---------------------------------------------------------------------------
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 adds the x-amz-date, x-amz-content-sha256, and Authorization
+// headers to req. It signs the host and every header already set on req
+// except Content-Type, which some S3-compatible services rewrite.
+func signV4(req *http.Request, payloadHash, region, accessKey, secretKey string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || lk == "authorization" {
+			continue
+		}
+		headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+sig)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalURI encodes each path segment; S3 does not normalize paths.
+func canonicalURI(p string) string {
+	if p == "" {
+		return "/"
+	}
+	segs := strings.Split(p, "/")
+	for i, s := range segs {
+		segs[i] = uriEncode(s)
+	}
+	return strings.Join(segs, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vals := append([]string(nil), q[k]...)
+		sort.Strings(vals)
+		for _, v := range vals {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but RFC 3986 unreserved characters.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
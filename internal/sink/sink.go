@@ -0,0 +1,232 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+// Sink is somewhere muxd offloads data it would otherwise keep on the local
+// disk: backups, exports, and large tool results. Keys are slash-separated
+// paths relative to the sink's root.
+type Sink interface {
+	// Put stores the contents of r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.ReadSeeker) error
+	// Get opens the object stored under key, or returns ErrNotFound.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// List returns the keys that start with prefix, in any order.
+	List(ctx context.Context, prefix string) ([]string, error)
+	Delete(ctx context.Context, key string) error
+	String() string
+}
+
+// ErrNotFound is returned by Get for keys with no object.
+var ErrNotFound = errors.New("object not found")
+
+// PutFile stores the file at p under key.
+func PutFile(ctx context.Context, s Sink, key, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Put(ctx, key, f)
+}
+
+// PutBytes stores data under key.
+func PutBytes(ctx context.Context, s Sink, key string, data []byte) error {
+	return s.Put(ctx, key, bytes.NewReader(data))
+}
+
+// cleanKey rejects keys that are empty or would escape the sink's root.
+func cleanKey(key string) (string, error) {
+	k := path.Clean(strings.TrimPrefix(key, "/"))
+	if k == "." || k == ".." || strings.HasPrefix(k, "../") {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return k, nil
+}
+
+// ---------------------------------------------------------------------------
+// Configuration
+// ---------------------------------------------------------------------------
+
+// Credentials sign requests to an S3-compatible service.
+type Credentials struct {
+	Region    string
+	AccessKey string
+	SecretKey string
+}
+
+// CredentialsFromPreferences returns the storage.s3_* credentials, falling
+// back to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY.
+func CredentialsFromPreferences(p config.Preferences) Credentials {
+	return Credentials{
+		Region:    strings.TrimSpace(p.StorageS3Region),
+		AccessKey: firstNonEmpty(p.StorageS3AccessKey, os.Getenv("AWS_ACCESS_KEY_ID")),
+		SecretKey: firstNonEmpty(p.StorageS3SecretKey, os.Getenv("AWS_SECRET_ACCESS_KEY")),
+	}
+}
+
+// FromPreferences returns the sink configured by storage.s3_url, or nil if
+// there is none.
+func FromPreferences(p config.Preferences) (Sink, error) {
+	if p.StorageS3URL == "" {
+		return nil, nil
+	}
+	s, err := NewS3(p.StorageS3URL, CredentialsFromPreferences(p))
+	if err != nil {
+		return nil, fmt.Errorf("storage.s3_url: %w", err)
+	}
+	return s, nil
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if strings.TrimSpace(v) != "" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// Local directory
+// ---------------------------------------------------------------------------
+
+// Dir keeps objects as files under a local directory.
+type Dir struct {
+	Root string
+}
+
+func (d Dir) path(key string) (string, error) {
+	k, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(d.Root, filepath.FromSlash(k)), nil
+}
+
+func (d Dir) Put(_ context.Context, key string, r io.ReadSeeker) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return fmt.Errorf("creating %s: %w", filepath.Dir(p), err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".muxd-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (d Dir) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	p, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (d Dir) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(d.Root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if p == d.Root && errors.Is(err, fs.ErrNotExist) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".muxd-") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Root, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", d.Root, err)
+	}
+	return keys, nil
+}
+
+func (d Dir) Delete(_ context.Context, key string) error {
+	p, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("deleting %s: %w", key, err)
+	}
+	return nil
+}
+
+func (d Dir) String() string { return d.Root }
+
+// ---------------------------------------------------------------------------
+// Exports
+// ---------------------------------------------------------------------------
+
+// ExportsDir is where exports are kept in the storage sink.
+const ExportsDir = "exports/"
+
+// ExportSink returns the storage sink when storage.exports is on, or nil.
+func ExportSink(p config.Preferences) (Sink, error) {
+	if !p.StorageExports {
+		return nil, nil
+	}
+	s, err := FromPreferences(p)
+	if err == nil && s == nil {
+		err = fmt.Errorf("storage.exports is on but storage.s3_url is not set")
+	}
+	return s, err
+}
+
+// PutDir stores every file under dir at keys under prefix and reports how
+// many there were.
+func PutDir(ctx context.Context, s Sink, prefix, dir string) (int, error) {
+	n := 0
+	err := filepath.WalkDir(dir, func(p string, e fs.DirEntry, err error) error {
+		if err != nil || !e.Type().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if err := PutFile(ctx, s, prefix+filepath.ToSlash(rel), p); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
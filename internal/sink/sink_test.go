@@ -0,0 +1,66 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+func TestDir(t *testing.T) {
+	ctx := context.Background()
+	d := Dir{Root: t.TempDir() + "/sink"}
+	if keys, err := d.List(ctx, ""); err != nil || len(keys) != 0 {
+		t.Errorf("List of a missing dir = %v, %v", keys, err)
+	}
+	for _, key := range []string{"exports/a.md", "exports/b.md", "blobs/c.txt"} {
+		if err := PutBytes(ctx, d, key, []byte("body of "+key)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+	keys, err := d.List(ctx, "exports/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{"exports/a.md", "exports/b.md"}) {
+		t.Errorf("List = %v", keys)
+	}
+	rc, err := d.Get(ctx, "blobs/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(body) != "body of blobs/c.txt" {
+		t.Errorf("Get = %q", body)
+	}
+	if err := d.Delete(ctx, "blobs/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Get(ctx, "blobs/c.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := PutBytes(ctx, d, "../../escape", nil); err == nil {
+		t.Error("expected an error for a key outside the sink")
+	}
+}
+
+func TestFromPreferences(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-ak")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-sk")
+	if s, err := FromPreferences(config.Preferences{}); s != nil || err != nil {
+		t.Errorf("no storage.s3_url: %v, %v", s, err)
+	}
+	s, err := FromPreferences(config.Preferences{StorageS3URL: "https://s3.example.com/bucket/muxd", StorageS3AccessKey: "pref-ak"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s3 := s.(*S3)
+	if s3.AccessKey != "pref-ak" || s3.SecretKey != "env-sk" || s3.Prefix != "muxd" {
+		t.Errorf("sink = %+v", s3)
+	}
+}
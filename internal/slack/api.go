@@ -0,0 +1,142 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+// DefaultAPIHost is the Slack Web API base URL.
+const DefaultAPIHost = "https://slack.com/api"
+
+const requestTimeout = 30 * time.Second
+
+// api calls Slack Web API methods.
+type api struct {
+	host   string
+	client *http.Client
+}
+
+// call POSTs body as JSON to method with token and decodes the response
+// into out. Slack reports failures as {"ok": false, "error": "..."} with a
+// 200 status.
+func (a *api) call(ctx context.Context, method, token string, body, out any) error {
+	if body == nil {
+		body = struct{}{}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(a.host, "/")+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: HTTP %d", method, resp.StatusCode)
+	}
+	var raw json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return fmt.Errorf("%s: parsing response: %w", method, err)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("%s: parsing response: %w", method, err)
+	}
+	if !status.OK {
+		return fmt.Errorf("%s: %s", method, status.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(raw, out); err != nil {
+			return fmt.Errorf("%s: parsing response: %w", method, err)
+		}
+	}
+	return nil
+}
+
+// authTest returns the bot's own user ID, so its messages can be ignored.
+func (a *api) authTest(ctx context.Context, botToken string) (string, error) {
+	var resp struct {
+		UserID string `json:"user_id"`
+	}
+	if err := a.call(ctx, "auth.test", botToken, nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.UserID, nil
+}
+
+// postMessage sends text to channel, as a reply in thread when it is set.
+func (a *api) postMessage(ctx context.Context, botToken, channel, thread, text string) error {
+	body := map[string]string{"channel": channel, "text": text}
+	if thread != "" {
+		body["thread_ts"] = thread
+	}
+	return a.call(ctx, "chat.postMessage", botToken, body, nil)
+}
+
+// ---------------------------------------------------------------------------
+// Socket Mode
+// ---------------------------------------------------------------------------
+
+// envelope is a Socket Mode frame. Frames with an envelope_id must be
+// acknowledged or Slack redelivers them.
+type envelope struct {
+	EnvelopeID string `json:"envelope_id"`
+	Type       string `json:"type"` // "hello", "events_api", "disconnect", ...
+	Payload    struct {
+		Event event `json:"event"`
+	} `json:"payload"`
+}
+
+// event is the subset of a message or app_mention event the bot reads.
+type event struct {
+	Type     string `json:"type"`
+	Subtype  string `json:"subtype"`
+	Channel  string `json:"channel"`
+	User     string `json:"user"`
+	BotID    string `json:"bot_id"`
+	Text     string `json:"text"`
+	TS       string `json:"ts"`
+	ThreadTS string `json:"thread_ts"`
+}
+
+// openSocket asks Slack for a Socket Mode URL with the app-level token and
+// dials it.
+func (a *api) openSocket(ctx context.Context, appToken string) (*websocket.Conn, error) {
+	var resp struct {
+		URL string `json:"url"`
+	}
+	if err := a.call(ctx, "apps.connections.open", appToken, nil, &resp); err != nil {
+		return nil, err
+	}
+	cfg, err := websocket.NewConfig(resp.URL, "https://slack.com")
+	if err != nil {
+		return nil, fmt.Errorf("socket mode URL: %w", err)
+	}
+	if err := egress.Check(cfg.Location.Host); err != nil {
+		return nil, err
+	}
+	ws, err := cfg.DialContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dialing socket mode: %w", err)
+	}
+	return ws, nil
+}
@@ -0,0 +1,469 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/gateway"
+)
+
+// Backend is the daemon API the bot drives. It is implemented by
+// *daemon.DaemonClient.
+type Backend interface {
+	gateway.Backend
+	CreateSession(projectPath, modelID string) (string, error)
+	Submit(sessionID, text string, attachments []daemon.SubmitAttachment, onEvent func(daemon.SSEEvent)) error
+	Cancel(sessionID string) error
+	SendAskResponse(sessionID, askID, answer string) error
+	SendApproval(sessionID, approvalID, decision string) error
+}
+
+// Config holds the Slack app's credentials and where the bot listens.
+type Config struct {
+	AppToken string // app-level token (xapp-...) with connections:write, for Socket Mode
+	BotToken string // bot token (xoxb-...) for reading and posting messages
+	// AllowedUsers are the Slack user IDs the bot takes messages, commands,
+	// and answers from. Everyone else is ignored.
+	AllowedUsers []string
+	// Channels limits the bot to these channel IDs. Empty answers in every
+	// channel the bot is invited to, and in direct messages.
+	Channels    []string
+	ProjectPath string // project new sessions are created in
+	APIHost     string // DefaultAPIHost or a test server
+	HTTPClient  *http.Client
+	Logf        func(format string, args ...any)
+}
+
+// Bot answers Slack messages with agent turns. Each channel gets its own
+// session; replies, questions, and approvals go in a thread under the
+// message that started the turn.
+type Bot struct {
+	cfg     Config
+	backend Backend
+	api     *api
+	userID  string // the bot's own Slack user, set by Run
+
+	ctx   context.Context
+	mu    sync.Mutex
+	chats map[string]*chat // channel ID -> conversation
+	seen  []string         // recent channel/ts keys, oldest first
+	wg    sync.WaitGroup
+}
+
+// chat is one channel's conversation with the agent.
+type chat struct {
+	sessionID string
+	busy      bool   // a turn is running
+	thread    string // thread the running turn replies in
+	// pending is the question or approval the running turn is waiting on,
+	// answered by the next reply in thread.
+	pending *pending
+}
+
+type pending struct {
+	askID      string
+	approvalID string
+}
+
+// New returns a bot that drives backend. Both tokens and at least one
+// allowed user are required.
+func New(backend Backend, cfg Config) (*Bot, error) {
+	if cfg.AppToken == "" || cfg.BotToken == "" {
+		return nil, errors.New("slack needs slack.app_token (xapp-...) and slack.bot_token (xoxb-...)")
+	}
+	if !strings.HasPrefix(cfg.AppToken, "xapp-") {
+		return nil, errors.New("slack.app_token must be an app-level token (xapp-...)")
+	}
+	if len(cfg.AllowedUsers) == 0 {
+		return nil, errors.New("slack needs slack.allowed_users (the user IDs that may use the bot)")
+	}
+	if cfg.APIHost == "" {
+		cfg.APIHost = DefaultAPIHost
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout, Transport: egress.Transport(nil)}
+	}
+	return &Bot{
+		cfg:     cfg,
+		backend: backend,
+		api:     &api{host: cfg.APIHost, client: client},
+		ctx:     context.Background(),
+		chats:   map[string]*chat{},
+	}, nil
+}
+
+// Reconnect backoff for Socket Mode.
+const (
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+)
+
+// Run connects over Socket Mode and handles messages until ctx is
+// cancelled, reconnecting whenever Slack drops the connection. It fails
+// only when the tokens are rejected.
+func (b *Bot) Run(ctx context.Context) error {
+	userID, err := b.api.authTest(ctx, b.cfg.BotToken)
+	if err != nil {
+		return fmt.Errorf("slack bot token: %w", err)
+	}
+	b.mu.Lock()
+	b.userID, b.ctx = userID, ctx
+	b.mu.Unlock()
+	defer b.wg.Wait()
+
+	wait := minReconnect
+	for {
+		connected := time.Now()
+		err := b.serve(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if isAuthError(err) {
+			return fmt.Errorf("slack app token: %w", err)
+		}
+		if time.Since(connected) > maxReconnect {
+			wait = minReconnect
+		}
+		if err != nil {
+			b.logf("slack: socket mode: %v (reconnecting in %s)", err, wait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxReconnect)
+	}
+}
+
+// serve handles one Socket Mode connection until Slack closes it or asks
+// the bot to reconnect.
+func (b *Bot) serve(ctx context.Context) error {
+	ws, err := b.api.openSocket(ctx, b.cfg.AppToken)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+	defer ws.Close()
+
+	for {
+		var env envelope
+		if err := websocket.JSON.Receive(ws, &env); err != nil {
+			return err
+		}
+		if env.EnvelopeID != "" {
+			if err := websocket.JSON.Send(ws, map[string]string{"envelope_id": env.EnvelopeID}); err != nil {
+				return err
+			}
+		}
+		switch env.Type {
+		case "disconnect":
+			return nil
+		case "events_api":
+			b.handle(env.Payload.Event)
+		}
+	}
+}
+
+func isAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "invalid_auth") || strings.Contains(msg, "not_authed") || strings.Contains(msg, "token_revoked")
+}
+
+// ---------------------------------------------------------------------------
+// Messages
+// ---------------------------------------------------------------------------
+
+var mentionPattern = regexp.MustCompile(`<@[A-Z0-9]+>`)
+
+// handle routes one message event: an answer to a pending question, a
+// !command, or a prompt for a new turn.
+func (b *Bot) handle(e event) {
+	if e.Type != "message" && e.Type != "app_mention" {
+		return
+	}
+	b.mu.Lock()
+	self := b.userID
+	b.mu.Unlock()
+	// Edits, joins, and bot posts (including our own replies) are subtypes
+	// or carry a bot_id.
+	if e.Subtype != "" || e.BotID != "" || e.User == "" || e.User == self {
+		return
+	}
+	// Checked before anything else so no one else can start a turn or
+	// answer a pending question or approval.
+	if !slices.Contains(b.cfg.AllowedUsers, e.User) {
+		return
+	}
+	if len(b.cfg.Channels) > 0 && !slices.Contains(b.cfg.Channels, e.Channel) {
+		return
+	}
+	// A mention arrives as both message and app_mention when the app
+	// subscribes to both.
+	if !b.firstSeen(e.Channel + "/" + e.TS) {
+		return
+	}
+	text := strings.TrimSpace(mentionPattern.ReplaceAllString(e.Text, ""))
+	if text == "" {
+		return
+	}
+	thread := e.ThreadTS
+	if thread == "" {
+		thread = e.TS
+	}
+
+	b.mu.Lock()
+	c := b.chats[e.Channel]
+	if c == nil {
+		c = &chat{}
+		b.chats[e.Channel] = c
+	}
+	if c.pending != nil && e.ThreadTS != "" && e.ThreadTS == c.thread {
+		p := c.pending
+		c.pending = nil
+		sessionID := c.sessionID
+		b.mu.Unlock()
+		b.answer(e.Channel, thread, sessionID, p, text)
+		return
+	}
+	b.mu.Unlock()
+
+	if strings.HasPrefix(text, "!") {
+		b.command(e.Channel, thread, text)
+		return
+	}
+	b.prompt(e.Channel, thread, text)
+}
+
+// seenMax bounds the keys remembered for de-duplicating events.
+const seenMax = 64
+
+// firstSeen reports whether key is new, remembering it.
+func (b *Bot) firstSeen(key string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if slices.Contains(b.seen, key) {
+		return false
+	}
+	b.seen = append(b.seen, key)
+	if len(b.seen) > seenMax {
+		b.seen = b.seen[1:]
+	}
+	return true
+}
+
+// answer resolves the question or approval the turn is waiting on.
+func (b *Bot) answer(channel, thread, sessionID string, p *pending, text string) {
+	var err error
+	if p.approvalID != "" {
+		decision, perr := agent.ParseApprovalDecision(text)
+		if perr != nil {
+			b.mu.Lock()
+			if c := b.chats[channel]; c != nil && c.busy && c.pending == nil {
+				c.pending = p
+			}
+			b.mu.Unlock()
+			b.post(channel, thread, "Reply allow, deny, or always.")
+			return
+		}
+		err = b.backend.SendApproval(sessionID, p.approvalID, string(decision))
+	} else {
+		err = b.backend.SendAskResponse(sessionID, p.askID, text)
+	}
+	if err != nil {
+		b.post(channel, thread, "Could not send your answer: "+err.Error())
+	}
+}
+
+// prompt starts a turn, creating the channel's session on first use.
+func (b *Bot) prompt(channel, thread, text string) {
+	b.mu.Lock()
+	c := b.chats[channel]
+	if c.busy {
+		b.mu.Unlock()
+		b.post(channel, thread, "Still working on the previous message. Send `!cancel` to stop it.")
+		return
+	}
+	c.busy, c.thread = true, thread
+	sessionID := c.sessionID
+	b.mu.Unlock()
+
+	if sessionID == "" {
+		id, err := b.backend.CreateSession(b.cfg.ProjectPath, "")
+		if err != nil {
+			b.finish(c)
+			b.post(channel, thread, "Could not start a session: "+err.Error())
+			return
+		}
+		b.mu.Lock()
+		c.sessionID, sessionID = id, id
+		b.mu.Unlock()
+	}
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer b.finish(c)
+		b.runTurn(channel, thread, sessionID, c, text)
+	}()
+}
+
+func (b *Bot) finish(c *chat) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	c.busy, c.pending = false, nil
+}
+
+// runTurn submits text and posts the reply. Text streamed before a
+// question or approval is posted first so the question has its context.
+func (b *Bot) runTurn(channel, thread, sessionID string, c *chat, text string) {
+	var reply strings.Builder
+	var turnErr string
+	flush := func() {
+		if s := strings.TrimSpace(reply.String()); s != "" {
+			b.post(channel, thread, s)
+		}
+		reply.Reset()
+	}
+	err := b.backend.Submit(sessionID, text, nil, func(evt daemon.SSEEvent) {
+		switch evt.Type {
+		case "delta":
+			reply.WriteString(evt.DeltaText)
+		case "ask_user":
+			flush()
+			b.mu.Lock()
+			c.pending = &pending{askID: evt.AskID}
+			b.mu.Unlock()
+			b.post(channel, thread, evt.AskPrompt+"\n_Reply in this thread to answer._")
+		case "approval_required":
+			flush()
+			b.mu.Lock()
+			c.pending = &pending{approvalID: evt.ApprovalID}
+			b.mu.Unlock()
+			b.post(channel, thread, fmt.Sprintf("Run `%s`?\n_Reply allow, deny, or always in this thread._", evt.ToolName))
+		case "error":
+			turnErr = evt.ErrorMsg
+		}
+	})
+	flush()
+	switch {
+	case err != nil:
+		b.post(channel, thread, "Error: "+err.Error())
+	case turnErr != "":
+		b.post(channel, thread, "Error: "+turnErr)
+	}
+}
+
+// command runs a !command. Slack reserves a leading "/" for its own slash
+// commands, so the shared gateway commands are typed with "!" instead,
+// e.g. "!drafts approve 1a2b".
+func (b *Bot) command(channel, thread, text string) {
+	fields := strings.Fields(strings.TrimPrefix(text, "!"))
+	if len(fields) == 0 {
+		return
+	}
+	name, args := strings.ToLower(fields[0]), fields[1:]
+	switch name {
+	case "help":
+		b.post(channel, thread, helpText())
+		return
+	case "new":
+		b.mu.Lock()
+		c := b.chats[channel]
+		busy := c.busy
+		if !busy {
+			c.sessionID = ""
+		}
+		b.mu.Unlock()
+		if busy {
+			b.post(channel, thread, "A turn is running; `!cancel` it first.")
+			return
+		}
+		b.post(channel, thread, "Started a new session for this channel.")
+		return
+	case "cancel":
+		b.mu.Lock()
+		c := b.chats[channel]
+		busy, sessionID := c.busy, c.sessionID
+		b.mu.Unlock()
+		if !busy {
+			b.post(channel, thread, "Nothing is running.")
+			return
+		}
+		if err := b.backend.Cancel(sessionID); err != nil {
+			b.post(channel, thread, "Could not cancel: "+err.Error())
+			return
+		}
+		b.post(channel, thread, "Cancelled.")
+		return
+	}
+
+	cmd, ok := gateway.Lookup("/" + name)
+	if !ok {
+		b.post(channel, thread, fmt.Sprintf("Unknown command !%s. Send `!help` for the list.", name))
+		return
+	}
+	reply := cmd.Run(gateway.Env{Backend: b.backend, Now: time.Now()}, args)
+	if s := reply.Text(); s != "" {
+		b.post(channel, thread, s)
+	}
+	if reply.Submit != "" {
+		b.prompt(channel, thread, reply.Submit)
+	}
+}
+
+func helpText() string {
+	lines := []string{
+		"Send a message to talk to the agent; each channel has its own session.",
+		"`!new` start a new session",
+		"`!cancel` stop the running turn",
+	}
+	for _, c := range gateway.Commands() {
+		line := "`!" + strings.TrimPrefix(c.Name, "/")
+		if c.Usage != "" {
+			line += " " + c.Usage
+		}
+		lines = append(lines, line+"` "+c.Description)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// post sends text in thread, split to fit Slack's message limit.
+func (b *Bot) post(channel, thread, text string) {
+	b.mu.Lock()
+	ctx := b.ctx
+	b.mu.Unlock()
+	for _, part := range gateway.SplitMessage(text, maxMessageLen) {
+		if err := b.api.postMessage(ctx, b.cfg.BotToken, channel, thread, part); err != nil {
+			b.logf("slack: posting to %s: %v", channel, err)
+			return
+		}
+	}
+}
+
+func (b *Bot) logf(format string, args ...any) {
+	if b.cfg.Logf != nil {
+		b.cfg.Logf(format, args...)
+	}
+}
+
+// maxMessageLen keeps each post under the 4,000 characters Slack
+// recommends for chat.postMessage; longer text is truncated by clients.
+const maxMessageLen = 3900
@@ -0,0 +1,350 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// fakeBackend replays scripted events for each turn. A question or
+// approval blocks the turn until it is answered.
+type fakeBackend struct {
+	mu       sync.Mutex
+	sessions int
+	prompts  []string
+	events   []daemon.SSEEvent
+	answers  chan string
+}
+
+func newFakeBackend(events ...daemon.SSEEvent) *fakeBackend {
+	return &fakeBackend{events: events, answers: make(chan string, 1)}
+}
+
+func (f *fakeBackend) CreateSession(projectPath, modelID string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sessions++
+	return "sess-" + string(rune('0'+f.sessions)), nil
+}
+
+func (f *fakeBackend) Submit(sessionID, text string, _ []daemon.SubmitAttachment, onEvent func(daemon.SSEEvent)) error {
+	f.mu.Lock()
+	f.prompts = append(f.prompts, sessionID+": "+text)
+	f.mu.Unlock()
+	for _, evt := range f.events {
+		onEvent(evt)
+		if evt.Type == "ask_user" || evt.Type == "approval_required" {
+			onEvent(daemon.SSEEvent{Type: "delta", DeltaText: " (got " + <-f.answers + ")"})
+		}
+	}
+	return nil
+}
+
+func (f *fakeBackend) Cancel(string) error { return nil }
+
+func (f *fakeBackend) SendAskResponse(_, askID, answer string) error {
+	f.answers <- askID + "=" + answer
+	return nil
+}
+
+func (f *fakeBackend) SendApproval(_, approvalID, decision string) error {
+	f.answers <- approvalID + "=" + decision
+	return nil
+}
+
+func (f *fakeBackend) ListScheduledJobs() ([]store.ScheduledToolJob, error) { return nil, nil }
+
+func (f *fakeBackend) ListScheduledJobsWithAttempts() ([]store.ScheduledToolJob, error) {
+	return nil, nil
+}
+
+func (f *fakeBackend) CreateScheduledJob(string, map[string]any, time.Time, string) (string, error) {
+	return "", errors.New("not supported")
+}
+
+func (f *fakeBackend) CancelScheduledJob(string) error               { return nil }
+func (f *fakeBackend) ListDrafts() ([]store.ScheduledToolJob, error) { return nil, nil }
+func (f *fakeBackend) ApproveDraft(string) (string, error)           { return "", nil }
+func (f *fakeBackend) RejectDraft(string) (string, error)            { return "", nil }
+
+func (f *fakeBackend) promptLog() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.prompts...)
+}
+
+// fakeSlack serves the Web API methods the bot calls and records posts.
+type fakeSlack struct {
+	*httptest.Server
+	mu    sync.Mutex
+	posts []map[string]string
+}
+
+func newFakeSlack(t *testing.T, socket websocket.Handler) *fakeSlack {
+	f := &fakeSlack{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/auth.test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": "invalid_auth"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "user_id": "UBOT"})
+	})
+	mux.HandleFunc("POST /api/apps.connections.open", func(w http.ResponseWriter, r *http.Request) {
+		url := "ws" + strings.TrimPrefix(f.URL, "http") + "/socket"
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "url": url})
+	})
+	mux.HandleFunc("POST /api/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		f.mu.Lock()
+		f.posts = append(f.posts, body)
+		f.mu.Unlock()
+		json.NewEncoder(w).Encode(map[string]any{"ok": true})
+	})
+	if socket != nil {
+		mux.Handle("/socket", socket)
+	}
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeSlack) texts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []string
+	for _, p := range f.posts {
+		out = append(out, p["thread_ts"]+" "+p["text"])
+	}
+	return out
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestBot(t *testing.T, backend Backend, api *fakeSlack, channels ...string) *Bot {
+	t.Helper()
+	b, err := New(backend, Config{AppToken: "xapp-test", BotToken: "xoxb-test", AllowedUsers: []string{"U1"}, Channels: channels, APIHost: api.URL + "/api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.userID = "UBOT"
+	return b
+}
+
+func TestNew_requiresTokens(t *testing.T) {
+	if _, err := New(newFakeBackend(), Config{BotToken: "xoxb-1"}); err == nil {
+		t.Error("missing app token accepted")
+	}
+	if _, err := New(newFakeBackend(), Config{AppToken: "xoxb-1", BotToken: "xoxb-1", AllowedUsers: []string{"U1"}}); err == nil {
+		t.Error("bot token accepted as app token")
+	}
+	if _, err := New(newFakeBackend(), Config{AppToken: "xapp-1", BotToken: "xoxb-1"}); err == nil {
+		t.Error("no allowed users accepted")
+	}
+}
+
+func TestBot_replyInThread(t *testing.T) {
+	api := newFakeSlack(t, nil)
+	backend := newFakeBackend(daemon.SSEEvent{Type: "delta", DeltaText: "Hello"}, daemon.SSEEvent{Type: "delta", DeltaText: " there"})
+	b := newTestBot(t, backend, api, "C1")
+
+	b.handle(event{Type: "message", Channel: "C2", User: "U1", Text: "other channel", TS: "1.0"})
+	b.handle(event{Type: "message", Channel: "C1", User: "UBOT", Text: "own message", TS: "1.1"})
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", BotID: "B1", Text: "a bot", TS: "1.2"})
+	b.handle(event{Type: "message", Subtype: "message_changed", Channel: "C1", Text: "edit", TS: "1.3"})
+	b.handle(event{Type: "app_mention", Channel: "C1", User: "U1", Text: "<@UBOT> hi", TS: "2.0"})
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "<@UBOT> hi", TS: "2.0"}) // same message
+	b.wg.Wait()
+
+	if got := backend.promptLog(); len(got) != 1 || got[0] != "sess-1: hi" {
+		t.Fatalf("prompts = %q", got)
+	}
+	if got := api.texts(); len(got) != 1 || got[0] != "2.0 Hello there" {
+		t.Fatalf("posts = %q", got)
+	}
+
+	// The channel keeps its session across turns.
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "again", TS: "3.0"})
+	b.wg.Wait()
+	if got := backend.promptLog(); len(got) != 2 || got[1] != "sess-1: again" {
+		t.Fatalf("prompts = %q", got)
+	}
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "!new", TS: "4.0"})
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "fresh", TS: "5.0"})
+	b.wg.Wait()
+	if got := backend.promptLog(); got[2] != "sess-2: fresh" {
+		t.Fatalf("prompts after !new = %q", got)
+	}
+}
+
+func TestBot_askUserInThread(t *testing.T) {
+	api := newFakeSlack(t, nil)
+	backend := newFakeBackend(
+		daemon.SSEEvent{Type: "delta", DeltaText: "Checking."},
+		daemon.SSEEvent{Type: "ask_user", AskID: "ask-1", AskPrompt: "Which branch?"},
+		daemon.SSEEvent{Type: "approval_required", ApprovalID: "ap-1", ToolName: "bash"},
+	)
+	b := newTestBot(t, backend, api)
+
+	b.handle(event{Type: "message", Channel: "D1", User: "U1", Text: "deploy", TS: "1.0"})
+	waitFor(t, "question", func() bool { return len(api.texts()) == 2 })
+
+	// Another message while the turn runs is not an answer.
+	b.handle(event{Type: "message", Channel: "D1", User: "U1", Text: "hello?", TS: "1.5"})
+	b.handle(event{Type: "message", Channel: "D1", User: "U1", Text: "main", TS: "2.0", ThreadTS: "1.0"})
+	waitFor(t, "approval", func() bool { return len(api.texts()) == 5 })
+	b.handle(event{Type: "message", Channel: "D1", User: "U1", Text: "maybe", TS: "3.0", ThreadTS: "1.0"})
+	b.handle(event{Type: "message", Channel: "D1", User: "U1", Text: "yes", TS: "4.0", ThreadTS: "1.0"})
+	b.wg.Wait()
+
+	got := api.texts()
+	want := []string{
+		"1.0 Checking.",
+		"1.0 Which branch?\n_Reply in this thread to answer._",
+		"1.5 Still working on the previous message. Send `!cancel` to stop it.",
+		"1.0 (got ask-1=main)",
+		"1.0 Run `bash`?\n_Reply allow, deny, or always in this thread._",
+		"1.0 Reply allow, deny, or always.",
+		"1.0 (got ap-1=allow)",
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("posts:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestBot_ignoresOtherUsers(t *testing.T) {
+	api := newFakeSlack(t, nil)
+	backend := newFakeBackend(daemon.SSEEvent{Type: "approval_required", ApprovalID: "ap-1", ToolName: "bash"})
+	b := newTestBot(t, backend, api)
+
+	b.handle(event{Type: "message", Channel: "C1", User: "U2", Text: "run this", TS: "1.0"})
+	b.handle(event{Type: "message", Channel: "C1", User: "U2", Text: "!help", TS: "1.1"})
+	b.wg.Wait()
+	if got := backend.promptLog(); len(got) != 0 {
+		t.Fatalf("prompts from another user = %q", got)
+	}
+	if got := api.texts(); len(got) != 0 {
+		t.Fatalf("posts for another user = %q", got)
+	}
+
+	// Someone else's reply in the thread does not answer the approval.
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "deploy", TS: "2.0"})
+	waitFor(t, "approval", func() bool { return len(api.texts()) == 1 })
+	b.handle(event{Type: "message", Channel: "C1", User: "U2", Text: "always", TS: "3.0", ThreadTS: "2.0"})
+	select {
+	case answer := <-backend.answers:
+		t.Fatalf("approval answered by another user: %s", answer)
+	case <-time.After(50 * time.Millisecond):
+	}
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "deny", TS: "4.0", ThreadTS: "2.0"})
+	b.wg.Wait()
+	if got := api.texts(); len(got) != 2 || got[1] != "2.0 (got ap-1=deny)" {
+		t.Errorf("posts = %q", got)
+	}
+}
+
+func TestBot_commands(t *testing.T) {
+	api := newFakeSlack(t, nil)
+	b := newTestBot(t, newFakeBackend(), api)
+
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "!schedule list", TS: "1.0"})
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "!bogus", TS: "2.0"})
+	b.handle(event{Type: "message", Channel: "C1", User: "U1", Text: "!help", TS: "3.0"})
+	got := api.texts()
+	if len(got) != 3 {
+		t.Fatalf("posts = %q", got)
+	}
+	if !strings.HasPrefix(got[0], "1.0 ") || strings.Contains(got[0], "Unknown") {
+		t.Errorf("!schedule list = %q", got[0])
+	}
+	if !strings.Contains(got[1], "Unknown command !bogus") {
+		t.Errorf("!bogus = %q", got[1])
+	}
+	if !strings.Contains(got[2], "`!drafts") || !strings.Contains(got[2], "`!cancel`") {
+		t.Errorf("!help = %q", got[2])
+	}
+}
+
+func TestBot_socketMode(t *testing.T) {
+	acked := make(chan string, 2)
+	socket := websocket.Handler(func(ws *websocket.Conn) {
+		websocket.JSON.Send(ws, map[string]any{"type": "hello"})
+		websocket.JSON.Send(ws, map[string]any{
+			"envelope_id": "env-1",
+			"type":        "events_api",
+			"payload": map[string]any{"event": map[string]any{
+				"type": "message", "channel": "C1", "user": "U1", "text": "ping", "ts": "9.0",
+			}},
+		})
+		var ack map[string]string
+		if websocket.JSON.Receive(ws, &ack) == nil {
+			acked <- ack["envelope_id"]
+		}
+		// Hold the connection open until the bot closes it.
+		var rest map[string]any
+		websocket.JSON.Receive(ws, &rest)
+	})
+	api := newFakeSlack(t, socket)
+	b, err := New(newFakeBackend(daemon.SSEEvent{Type: "delta", DeltaText: "pong"}), Config{
+		AppToken: "xapp-test", BotToken: "xoxb-test", AllowedUsers: []string{"U1"}, APIHost: api.URL + "/api",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+
+	select {
+	case id := <-acked:
+		if id != "env-1" {
+			t.Errorf("ack = %q", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("envelope not acknowledged")
+	}
+	waitFor(t, "reply", func() bool { return len(api.texts()) == 1 })
+	if got := api.texts()[0]; got != "9.0 pong" {
+		t.Errorf("reply = %q", got)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+}
+
+func TestBot_runRejectsBadToken(t *testing.T) {
+	api := newFakeSlack(t, nil)
+	b, _ := New(newFakeBackend(), Config{AppToken: "xapp-test", BotToken: "xoxb-wrong", AllowedUsers: []string{"U1"}, APIHost: api.URL + "/api"})
+	if err := b.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "invalid_auth") {
+		t.Errorf("Run = %v, want invalid_auth", err)
+	}
+}
@@ -1,18 +1,22 @@
 package store
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/domain"
 
-	_ "modernc.org/sqlite"
+	"modernc.org/sqlite"
 )
 
 // Store wraps a SQLite database for session and message persistence.
@@ -26,6 +30,12 @@ func OpenStore() (*Store, error) {
 	if err != nil {
 		return nil, fmt.Errorf("data dir: %w", err)
 	}
+	return OpenStoreIn(dir)
+}
+
+// OpenStoreIn opens (or creates) the SQLite database in the given directory.
+// Used for per-project daemons, which keep their sessions separate.
+func OpenStoreIn(dir string) (*Store, error) {
 	dsn := filepath.Join(dir, "muxd.db")
 
 	db, err := sql.Open("sqlite", dsn+"?_pragma=journal_mode(wal)&_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)")
@@ -47,6 +57,45 @@ func OpenStore() (*Store, error) {
 	return s, nil
 }
 
+// ProjectDBDir is the directory inside a project root that holds the
+// project's database when sessions are kept with the repo (--project-db).
+const ProjectDBDir = ".muxd"
+
+// OpenStoreForProject opens (or creates) the database in <root>/.muxd, so a
+// project's sessions live with the repo and can be shared or ignored there.
+func OpenStoreForProject(root string) (*Store, error) {
+	dir := filepath.Join(root, ProjectDBDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("project db dir: %w", err)
+	}
+	return OpenStoreIn(dir)
+}
+
+// AddProjectDatabase records that root's .muxd/muxd.db holds sessions this
+// store's daemon serves.
+func (s *Store) AddProjectDatabase(root string) error {
+	_, err := s.db.Exec(`INSERT OR IGNORE INTO project_databases (root) VALUES (?)`, root)
+	return err
+}
+
+// ProjectDatabases returns the project roots recorded by AddProjectDatabase.
+func (s *Store) ProjectDatabases() ([]string, error) {
+	rows, err := s.db.Query(`SELECT root FROM project_databases ORDER BY root`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var roots []string
+	for rows.Next() {
+		var root string
+		if err := rows.Scan(&root); err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	return roots, rows.Err()
+}
+
 // NewFromDB creates a Store from an existing *sql.DB and runs migrations.
 // This is useful for testing with an in-memory database.
 func NewFromDB(db *sql.DB) (*Store, error) {
@@ -103,6 +152,17 @@ func (s *Store) migrate() error {
 		`ALTER TABLE sessions ADD COLUMN parent_session_id TEXT NOT NULL DEFAULT ''`,
 		`ALTER TABLE sessions ADD COLUMN branch_point INTEGER DEFAULT 0`,
 		`ALTER TABLE sessions ADD COLUMN tags TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE messages ADD COLUMN rating INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE messages ADD COLUMN feedback_note TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE messages ADD COLUMN feedback_at TEXT`,
+		`ALTER TABLE sessions ADD COLUMN cost_usd REAL NOT NULL DEFAULT 0`,
+		`ALTER TABLE messages ADD COLUMN annotation TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE scheduled_tool_jobs ADD COLUMN retry_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE scheduled_tool_jobs ADD COLUMN retry_at TEXT`,
+		`ALTER TABLE sessions ADD COLUMN turn_pid INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE sessions ADD COLUMN interrupted_prompt TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE messages ADD COLUMN turn_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE sessions ADD COLUMN cwd TEXT NOT NULL DEFAULT ''`,
 	} {
 		// ALTER TABLE errors expected -column may already exist.
 		_, _ = s.db.Exec(q)
@@ -140,6 +200,186 @@ func (s *Store) migrate() error {
 			last_result TEXT NOT NULL DEFAULT '',
 			last_attempt_at TEXT,
 			completed_at TEXT,
+			retry_count INTEGER NOT NULL DEFAULT 0,
+			retry_at TEXT,
+			created_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+	`); err != nil {
+		return err
+	}
+
+	// One row per run of a scheduled tool job: succeeded, failed, or
+	// skipped as a misfire.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS scheduled_tool_job_attempts (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			job_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			attempted_at TEXT NOT NULL
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Queued headless agent jobs. log holds the job's tool activity.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS jobs (
+			id TEXT PRIMARY KEY,
+			prompt TEXT NOT NULL,
+			tools_json TEXT NOT NULL DEFAULT '[]',
+			status TEXT NOT NULL DEFAULT 'queued',
+			session_id TEXT NOT NULL DEFAULT '',
+			result TEXT NOT NULL DEFAULT '',
+			error TEXT NOT NULL DEFAULT '',
+			log TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL DEFAULT (datetime('now')),
+			started_at TEXT,
+			finished_at TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status, created_at);
+	`); err != nil {
+		return err
+	}
+
+	// Automatic post-mortems for errored turns.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS postmortems (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+			error TEXT NOT NULL DEFAULT '',
+			tool_failures INTEGER NOT NULL DEFAULT 0,
+			failed_tools TEXT NOT NULL DEFAULT '',
+			category TEXT NOT NULL DEFAULT '',
+			diagnosis TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Audit log of outbound content decisions.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS audit_log (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL DEFAULT '',
+			tool TEXT NOT NULL DEFAULT '',
+			decision TEXT NOT NULL,
+			detail TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Scoped API tokens for the daemon. Only a hash of each token is kept.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS api_tokens (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL DEFAULT '',
+			scope TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_at TEXT NOT NULL DEFAULT (datetime('now')),
+			last_used_at TEXT
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Project databases a daemon with daemon.project_db keeps sessions in,
+	// so it can reopen them after a restart.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS project_databases (
+			root TEXT PRIMARY KEY,
+			added_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Read-only share links for live session views. The token is the whole
+	// credential, so revoking a share deletes its row.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS session_shares (
+			token TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+			created_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+		CREATE INDEX IF NOT EXISTS idx_session_shares_session ON session_shares(session_id);
+	`); err != nil {
+		return err
+	}
+
+	// Mobile devices registered for push notifications, one row per device
+	// token.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS push_devices (
+			id TEXT PRIMARY KEY,
+			platform TEXT NOT NULL,
+			token TEXT NOT NULL UNIQUE,
+			name TEXT NOT NULL DEFAULT '',
+			foreground INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL DEFAULT (datetime('now')),
+			updated_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Webhooks that receive daemon events. events is a comma-separated
+	// list; empty subscribes to every event.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS webhooks (
+			id TEXT PRIMARY KEY,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL DEFAULT '',
+			events TEXT NOT NULL DEFAULT '',
+			created_at TEXT NOT NULL DEFAULT (datetime('now')),
+			last_delivery_at TEXT,
+			last_error TEXT NOT NULL DEFAULT ''
+		);
+	`); err != nil {
+		return err
+	}
+
+	// Token usage and estimated spend per local day, model, and project, for
+	// budgets and usage reports.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS usage_daily (
+			day TEXT NOT NULL,
+			model TEXT NOT NULL DEFAULT '',
+			project_path TEXT NOT NULL DEFAULT '',
+			input_tokens INTEGER NOT NULL DEFAULT 0,
+			output_tokens INTEGER NOT NULL DEFAULT 0,
+			cache_write_tokens INTEGER NOT NULL DEFAULT 0,
+			cache_read_tokens INTEGER NOT NULL DEFAULT 0,
+			cost_usd REAL NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, model, project_path)
+		);
+	`); err != nil {
+		return err
+	}
+	// Legacy: fold the earlier per-model daily_spend aggregates into
+	// usage_daily; they have no project. Errors mean there is nothing to move.
+	if _, err := s.db.Exec(`
+		INSERT OR IGNORE INTO usage_daily (day, model, input_tokens, output_tokens, cost_usd)
+		SELECT day, model, input_tokens, output_tokens, cost_usd FROM daily_spend
+	`); err == nil {
+		_, _ = s.db.Exec(`DROP TABLE daily_spend`)
+	}
+
+	// Working tree checkpoints taken before agent turns run tools, for undo
+	// and redo. Undone checkpoints are always the newest ones.
+	if _, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS checkpoints (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+			turn INTEGER NOT NULL DEFAULT 0,
+			label TEXT NOT NULL DEFAULT '',
+			dir TEXT NOT NULL DEFAULT '',
+			sha TEXT NOT NULL DEFAULT '',
+			redo_sha TEXT NOT NULL DEFAULT '',
+			undone INTEGER NOT NULL DEFAULT 0,
 			created_at TEXT NOT NULL DEFAULT (datetime('now'))
 		);
 	`); err != nil {
@@ -153,6 +393,10 @@ func (s *Store) migrate() error {
 		CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_id, sequence);
 		CREATE INDEX IF NOT EXISTS idx_compactions_session ON compactions(session_id);
 		CREATE INDEX IF NOT EXISTS idx_scheduled_tool_jobs_due ON scheduled_tool_jobs(status, scheduled_for);
+		CREATE INDEX IF NOT EXISTS idx_scheduled_tool_job_attempts_job ON scheduled_tool_job_attempts(job_id, id);
+		CREATE INDEX IF NOT EXISTS idx_postmortems_session ON postmortems(session_id);
+		CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
+		CREATE INDEX IF NOT EXISTS idx_checkpoints_session ON checkpoints(session_id, id);
 	`)
 	return err
 }
@@ -187,7 +431,7 @@ func (s *Store) CreateSession(projectPath, model string) (*domain.Session, error
 // GetSession retrieves a session by its full ID.
 func (s *Store) GetSession(id string) (*domain.Session, error) {
 	row := s.db.QueryRow(
-		`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), created_at, updated_at
+		`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), interrupted_prompt, cwd, created_at, updated_at
 		 FROM sessions WHERE id = ?`, id)
 	return scanSession(row)
 }
@@ -195,7 +439,7 @@ func (s *Store) GetSession(id string) (*domain.Session, error) {
 // LatestSession returns the most recently updated session for a project path.
 func (s *Store) LatestSession(projectPath string) (*domain.Session, error) {
 	row := s.db.QueryRow(
-		`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), created_at, updated_at
+		`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), interrupted_prompt, cwd, created_at, updated_at
 		 FROM sessions WHERE project_path = ? ORDER BY updated_at DESC LIMIT 1`, projectPath)
 	return scanSession(row)
 }
@@ -211,12 +455,12 @@ func (s *Store) ListSessions(projectPath string, limit int) ([]domain.Session, e
 	var err error
 	if projectPath == "" {
 		rows, err = s.db.Query(
-			`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), created_at, updated_at
+			`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), interrupted_prompt, cwd, created_at, updated_at
 			 FROM sessions ORDER BY updated_at DESC LIMIT ?`,
 			limit)
 	} else {
 		rows, err = s.db.Query(
-			`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), created_at, updated_at
+			`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), interrupted_prompt, cwd, created_at, updated_at
 			 FROM sessions WHERE project_path = ? ORDER BY updated_at DESC LIMIT ?`,
 			projectPath, limit)
 	}
@@ -232,7 +476,7 @@ func (s *Store) ListSessions(projectPath string, limit int) ([]domain.Session, e
 		if err := rows.Scan(&sess.ID, &sess.ProjectPath, &sess.Title, &sess.Model,
 			&sess.TotalTokens, &sess.InputTokens, &sess.OutputTokens,
 			&sess.MessageCount, &sess.ParentSessionID, &sess.BranchPoint,
-			&sess.Tags, &createdStr, &updatedStr); err != nil {
+			&sess.Tags, &sess.InterruptedPrompt, &sess.Cwd, &createdStr, &updatedStr); err != nil {
 			return nil, err
 		}
 		if t, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
@@ -285,6 +529,15 @@ func (s *Store) UpdateSessionTags(id, tags string) error {
 	return err
 }
 
+// SetSessionParent records parentID as the session that spawned id, as
+// spawn_agent does for sub-agent sessions.
+func (s *Store) SetSessionParent(id, parentID string) error {
+	_, err := s.db.Exec(
+		`UPDATE sessions SET parent_session_id = ?, updated_at = datetime('now') WHERE id = ?`,
+		parentID, id)
+	return err
+}
+
 // TouchSession updates the session's updated_at timestamp.
 func (s *Store) TouchSession(id string) error {
 	_, err := s.db.Exec(
@@ -292,59 +545,116 @@ func (s *Store) TouchSession(id string) error {
 	return err
 }
 
+// SetSessionCwd sets the directory the session's tools run in. An empty
+// cwd means the daemon's own working directory.
+func (s *Store) SetSessionCwd(id, cwd string) error {
+	_, err := s.db.Exec(`UPDATE sessions SET cwd = ? WHERE id = ?`, cwd, id)
+	return err
+}
+
+// SetTurnPID records the process running a turn on the session, or 0 when
+// the turn ends. Starting a turn clears any interrupted prompt.
+func (s *Store) SetTurnPID(id string, pid int) error {
+	q := `UPDATE sessions SET turn_pid = ? WHERE id = ?`
+	if pid != 0 {
+		q = `UPDATE sessions SET turn_pid = ?, interrupted_prompt = '' WHERE id = ?`
+	}
+	_, err := s.db.Exec(q, pid, id)
+	return err
+}
+
+// RunningTurns maps the sessions with a turn in progress to the process
+// running it. A turn whose process is gone was cut off mid-way.
+func (s *Store) RunningTurns() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT id, turn_pid FROM sessions WHERE turn_pid != 0`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	turns := map[string]int{}
+	for rows.Next() {
+		var id string
+		var pid int
+		if err := rows.Scan(&id, &pid); err != nil {
+			return nil, err
+		}
+		turns[id] = pid
+	}
+	return turns, rows.Err()
+}
+
+// SetInterruptedTurn closes out a cut-off turn, keeping the prompt that
+// started it so the user can be offered a re-run.
+func (s *Store) SetInterruptedTurn(id, prompt string) error {
+	_, err := s.db.Exec(
+		`UPDATE sessions SET turn_pid = 0, interrupted_prompt = ? WHERE id = ?`, prompt, id)
+	return err
+}
+
 // ---------------------------------------------------------------------------
 // Message CRUD
 // ---------------------------------------------------------------------------
 
 // AppendMessage stores a plain-text message for a session.
 func (s *Store) AppendMessage(sessionID, role, content string, tokens int) error {
-	var seq int
-	row := s.db.QueryRow(
-		`SELECT COALESCE(MAX(sequence), 0) FROM messages WHERE session_id = ?`, sessionID)
-	if err := row.Scan(&seq); err != nil {
-		return err
+	return s.AppendTurnMessages(sessionID, "", []TurnMessage{{Role: role, Content: content, Tokens: tokens}})
+}
+
+// AppendMessageBlocks stores a message with structured content blocks for a session.
+func (s *Store) AppendMessageBlocks(sessionID, role string, blocks []domain.ContentBlock, tokens int) error {
+	if blocks == nil {
+		blocks = []domain.ContentBlock{}
 	}
-	seq++
+	return s.AppendTurnMessages(sessionID, "", []TurnMessage{{Role: role, Blocks: blocks, Tokens: tokens}})
+}
 
-	_, err := s.db.Exec(
-		`INSERT INTO messages (id, session_id, role, content, tokens, sequence)
-		 VALUES (?, ?, ?, ?, ?, ?)`,
-		domain.NewUUID(), sessionID, role, content, tokens, seq)
+// TurnMessage is a message to store with AppendTurnMessages.
+type TurnMessage struct {
+	Role    string
+	Content string                // used when Blocks is nil
+	Blocks  []domain.ContentBlock // stored as blocks when non-nil
+	Tokens  int
+}
+
+// AppendTurnMessages stores msgs in one transaction, tagged with the agent
+// turn they belong to, so that a tool call and its results are written
+// together or not at all.
+func (s *Store) AppendTurnMessages(sessionID, turnID string, msgs []TurnMessage) error {
+	tx, err := s.db.Begin()
 	if err != nil {
-		return err
+		return fmt.Errorf("begin tx: %w", err)
 	}
-	_, err = s.db.Exec(
-		`UPDATE sessions SET message_count = ?, updated_at = datetime('now') WHERE id = ?`,
-		seq, sessionID)
-	return err
-}
+	defer tx.Rollback()
 
-// AppendMessageBlocks stores a message with structured content blocks for a session.
-func (s *Store) AppendMessageBlocks(sessionID, role string, blocks []domain.ContentBlock, tokens int) error {
 	var seq int
-	row := s.db.QueryRow(
+	row := tx.QueryRow(
 		`SELECT COALESCE(MAX(sequence), 0) FROM messages WHERE session_id = ?`, sessionID)
 	if err := row.Scan(&seq); err != nil {
 		return err
 	}
-	seq++
-
-	blocksJSON, err := json.Marshal(blocks)
-	if err != nil {
-		return fmt.Errorf("marshaling blocks: %w", err)
+	for _, m := range msgs {
+		content, contentType := m.Content, "text"
+		if m.Blocks != nil {
+			blocksJSON, err := json.Marshal(m.Blocks)
+			if err != nil {
+				return fmt.Errorf("marshaling blocks: %w", err)
+			}
+			content, contentType = string(blocksJSON), "blocks"
+		}
+		seq++
+		if _, err := tx.Exec(
+			`INSERT INTO messages (id, session_id, role, content, content_type, tokens, sequence, turn_id)
+			 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			domain.NewUUID(), sessionID, m.Role, content, contentType, m.Tokens, seq, turnID); err != nil {
+			return err
+		}
 	}
-
-	_, err = s.db.Exec(
-		`INSERT INTO messages (id, session_id, role, content, content_type, tokens, sequence)
-		 VALUES (?, ?, ?, ?, 'blocks', ?, ?)`,
-		domain.NewUUID(), sessionID, role, string(blocksJSON), tokens, seq)
-	if err != nil {
+	if _, err := tx.Exec(
+		`UPDATE sessions SET message_count = ?, updated_at = datetime('now') WHERE id = ?`,
+		seq, sessionID); err != nil {
 		return err
 	}
-	_, err = s.db.Exec(
-		`UPDATE sessions SET message_count = ?, updated_at = datetime('now') WHERE id = ?`,
-		seq, sessionID)
-	return err
+	return tx.Commit()
 }
 
 // GetMessages returns all messages for a session, ordered by sequence.
@@ -355,6 +665,39 @@ func (s *Store) GetMessages(sessionID string) ([]domain.TranscriptMessage, error
 	if err != nil {
 		return nil, err
 	}
+	return scanTranscriptMessages(rows)
+}
+
+// GetMessagesPage returns up to limit messages of a session starting at the
+// zero-based offset, in sequence order. A limit <= 0 returns every message
+// from offset on.
+func (s *Store) GetMessagesPage(sessionID string, offset, limit int) ([]domain.TranscriptMessage, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	if limit <= 0 {
+		limit = -1 // SQLite: no limit
+	}
+	rows, err := s.db.Query(
+		`SELECT role, content, COALESCE(content_type, 'text') FROM messages
+		 WHERE session_id = ? ORDER BY sequence LIMIT ? OFFSET ?`,
+		sessionID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	return scanTranscriptMessages(rows)
+}
+
+// CountMessages returns the number of stored messages in a session.
+func (s *Store) CountMessages(sessionID string) (int, error) {
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM messages WHERE session_id = ?`, sessionID).Scan(&n)
+	return n, err
+}
+
+// scanTranscriptMessages reads (role, content, content_type) rows, decoding
+// block messages and flattening their text into Content. It closes rows.
+func scanTranscriptMessages(rows *sql.Rows) ([]domain.TranscriptMessage, error) {
 	defer rows.Close()
 
 	var msgs []domain.TranscriptMessage
@@ -382,6 +725,131 @@ func (s *Store) GetMessages(sessionID string) ([]domain.TranscriptMessage, error
 	return msgs, rows.Err()
 }
 
+// MessageRecord is a stored message with its bookkeeping columns, used for
+// full-fidelity transcript exports.
+type MessageRecord struct {
+	Sequence     int                   `json:"sequence"`
+	Role         string                `json:"role"`
+	Content      string                `json:"content"`
+	Blocks       []domain.ContentBlock `json:"blocks,omitempty"`
+	Tokens       int                   `json:"tokens"`
+	TurnID       string                `json:"turn_id,omitempty"` // the agent turn that wrote it
+	Rating       int                   `json:"rating,omitempty"`
+	FeedbackNote string                `json:"feedback_note,omitempty"`
+	Annotation   string                `json:"annotation,omitempty"`
+	CreatedAt    time.Time             `json:"created_at"`
+}
+
+// GetMessageRecords returns every message of a session in sequence order,
+// including token counts, timestamps, and feedback.
+func (s *Store) GetMessageRecords(sessionID string) ([]MessageRecord, error) {
+	rows, err := s.db.Query(
+		`SELECT sequence, role, content, COALESCE(content_type, 'text'), COALESCE(tokens, 0),
+		        turn_id, rating, feedback_note, annotation, created_at
+		 FROM messages WHERE session_id = ? ORDER BY sequence`,
+		sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []MessageRecord
+	for rows.Next() {
+		var m MessageRecord
+		var contentType, createdStr string
+		if err := rows.Scan(&m.Sequence, &m.Role, &m.Content, &contentType, &m.Tokens,
+			&m.TurnID, &m.Rating, &m.FeedbackNote, &m.Annotation, &createdStr); err != nil {
+			return nil, err
+		}
+		if contentType == "blocks" {
+			var blocks []domain.ContentBlock
+			if err := json.Unmarshal([]byte(m.Content), &blocks); err == nil {
+				m.Blocks = blocks
+				m.Content = domain.TranscriptMessage{Blocks: blocks}.TextContent()
+			}
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+			m.CreatedAt = t
+		}
+		out = append(out, m)
+	}
+	return out, rows.Err()
+}
+
+// AnnotateMessage sets the note attached to the message at sequence. An
+// empty note removes it. Annotations are for the user and never reach the
+// model.
+func (s *Store) AnnotateMessage(sessionID string, sequence int, note string) error {
+	res, err := s.db.Exec(
+		`UPDATE messages SET annotation = ? WHERE session_id = ? AND sequence = ?`,
+		strings.TrimSpace(note), sessionID, sequence)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no message at sequence %d", sequence)
+	}
+	return nil
+}
+
+// MessageAnnotations returns the annotated messages of a session, by
+// sequence.
+func (s *Store) MessageAnnotations(sessionID string) (map[int]string, error) {
+	rows, err := s.db.Query(
+		`SELECT sequence, annotation FROM messages WHERE session_id = ? AND annotation != ''`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notes := make(map[int]string)
+	for rows.Next() {
+		var seq int
+		var note string
+		if err := rows.Scan(&seq, &note); err != nil {
+			return nil, err
+		}
+		notes[seq] = note
+	}
+	return notes, rows.Err()
+}
+
+// DeleteMessage removes the message at sequence from a session. Later
+// messages move down one so sequences stay contiguous, and a compaction
+// cutoff past the message moves with them.
+func (s *Store) DeleteMessage(sessionID string, sequence int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`DELETE FROM messages WHERE session_id = ? AND sequence = ?`, sessionID, sequence)
+	if err != nil {
+		return fmt.Errorf("delete message: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("no message at sequence %d", sequence)
+	}
+	if _, err := tx.Exec(
+		`UPDATE messages SET sequence = sequence - 1 WHERE session_id = ? AND sequence > ?`,
+		sessionID, sequence); err != nil {
+		return fmt.Errorf("renumber messages: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE compactions SET cutoff_sequence = cutoff_sequence - 1 WHERE session_id = ? AND cutoff_sequence >= ?`,
+		sessionID, sequence); err != nil {
+		return fmt.Errorf("update compactions: %w", err)
+	}
+	if _, err := tx.Exec(
+		`UPDATE sessions SET message_count = (SELECT COUNT(*) FROM messages WHERE session_id = ?), updated_at = datetime('now')
+		 WHERE id = ?`,
+		sessionID, sessionID); err != nil {
+		return fmt.Errorf("update message_count: %w", err)
+	}
+	return tx.Commit()
+}
+
 // ---------------------------------------------------------------------------
 // Compaction persistence
 // ---------------------------------------------------------------------------
@@ -414,32 +882,8 @@ func (s *Store) GetMessagesAfterSequence(sessionID string, afterSequence int) ([
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var msgs []domain.TranscriptMessage
-	for rows.Next() {
-		var m domain.TranscriptMessage
-		var contentType string
-		if err := rows.Scan(&m.Role, &m.Content, &contentType); err != nil {
-			return nil, err
-		}
-		if contentType == "blocks" {
-			var blocks []domain.ContentBlock
-			if err := json.Unmarshal([]byte(m.Content), &blocks); err == nil {
-				m.Blocks = blocks
-				var texts []string
-				for _, b := range blocks {
-					if b.Type == "text" {
-						texts = append(texts, b.Text)
-					}
-				}
-				m.Content = strings.Join(texts, "\n")
-			}
-		}
-		msgs = append(msgs, m)
-	}
-	return msgs, rows.Err()
-}
+	return scanTranscriptMessages(rows)
+}
 
 // MessageMaxSequence returns the highest message sequence number for a session, or 0 if none.
 func (s *Store) MessageMaxSequence(sessionID string) (int, error) {
@@ -456,22 +900,43 @@ func (s *Store) MessageMaxSequence(sessionID string) (int, error) {
 
 // ScheduledToolJob is a queued tool call for deferred execution.
 type ScheduledToolJob struct {
-	ID            string
-	ToolName      string
-	ToolInput     map[string]any
-	ScheduledFor  time.Time
-	Recurrence    string
-	Status        string
-	AttemptCount  int
-	LastError     string
-	LastResult    string
-	LastAttemptAt *time.Time
-	CompletedAt   *time.Time
-	CreatedAt     time.Time
+	ID            string         `json:"id"`
+	ToolName      string         `json:"tool_name"`
+	ToolInput     map[string]any `json:"tool_input"`
+	ScheduledFor  time.Time      `json:"scheduled_for"`
+	Recurrence    string         `json:"recurrence"` // once, hourly, daily, weekly, or "[CRON_TZ=<zone> ]<cron expression>"
+	Status        string         `json:"status"`
+	AttemptCount  int            `json:"attempt_count"`
+	LastError     string         `json:"last_error,omitempty"`
+	LastResult    string         `json:"last_result,omitempty"`
+	LastAttemptAt *time.Time     `json:"last_attempt_at,omitempty"`
+	CompletedAt   *time.Time     `json:"completed_at,omitempty"`
+	RetryCount    int            `json:"retry_count,omitempty"` // failed attempts of the current run
+	RetryAt       *time.Time     `json:"retry_at,omitempty"`    // when the next retry runs
+	CreatedAt     time.Time      `json:"created_at"`
+
+	Attempts []ScheduledToolJobAttempt `json:"attempts,omitempty"` // newest first; filled on request
+}
+
+// ScheduledToolJobAttempt is one recorded run of a scheduled tool job.
+type ScheduledToolJobAttempt struct {
+	Status      string    `json:"status"` // succeeded, failed, or skipped
+	Error       string    `json:"error,omitempty"`
+	AttemptedAt time.Time `json:"attempted_at"`
 }
 
 // CreateScheduledToolJob enqueues a generic tool call for future execution.
 func (s *Store) CreateScheduledToolJob(toolName string, toolInput map[string]any, scheduledFor time.Time, recurrence string) (string, error) {
+	return s.createScheduledToolJob(toolName, toolInput, scheduledFor, recurrence, "pending")
+}
+
+// CreateDraftToolJob queues a tool call as a draft. The scheduler ignores
+// it until ApproveDraftToolJob moves it to pending.
+func (s *Store) CreateDraftToolJob(toolName string, toolInput map[string]any, scheduledFor time.Time, recurrence string) (string, error) {
+	return s.createScheduledToolJob(toolName, toolInput, scheduledFor, recurrence, "draft")
+}
+
+func (s *Store) createScheduledToolJob(toolName string, toolInput map[string]any, scheduledFor time.Time, recurrence, status string) (string, error) {
 	if recurrence == "" {
 		recurrence = "once"
 	}
@@ -482,8 +947,8 @@ func (s *Store) CreateScheduledToolJob(toolName string, toolInput map[string]any
 	id := domain.NewUUID()
 	_, err = s.db.Exec(
 		`INSERT INTO scheduled_tool_jobs (id, tool_name, tool_input_json, scheduled_for, recurrence, status)
-		 VALUES (?, ?, ?, ?, ?, 'pending')`,
-		id, strings.ToLower(strings.TrimSpace(toolName)), string(payload), scheduledFor.UTC().Format(time.RFC3339), recurrence,
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		id, strings.ToLower(strings.TrimSpace(toolName)), string(payload), scheduledFor.UTC().Format(time.RFC3339), recurrence, status,
 	)
 	if err != nil {
 		return "", err
@@ -498,7 +963,7 @@ func (s *Store) ListScheduledToolJobs(limit int) ([]ScheduledToolJob, error) {
 	}
 	rows, err := s.db.Query(
 		`SELECT id, tool_name, tool_input_json, scheduled_for, recurrence, status, attempt_count, last_error, last_result,
-		        COALESCE(last_attempt_at,''), COALESCE(completed_at,''), created_at
+		        COALESCE(last_attempt_at,''), COALESCE(completed_at,''), retry_count, COALESCE(retry_at,''), created_at
 		   FROM scheduled_tool_jobs
 		  ORDER BY scheduled_for ASC
 		  LIMIT ?`, limit)
@@ -516,10 +981,10 @@ func (s *Store) DueScheduledToolJobs(now time.Time, limit int) ([]ScheduledToolJ
 	}
 	rows, err := s.db.Query(
 		`SELECT id, tool_name, tool_input_json, scheduled_for, recurrence, status, attempt_count, last_error, last_result,
-		        COALESCE(last_attempt_at,''), COALESCE(completed_at,''), created_at
+		        COALESCE(last_attempt_at,''), COALESCE(completed_at,''), retry_count, COALESCE(retry_at,''), created_at
 		   FROM scheduled_tool_jobs
-		  WHERE status = 'pending' AND scheduled_for <= ?
-		  ORDER BY scheduled_for ASC
+		  WHERE status = 'pending' AND COALESCE(retry_at, scheduled_for) <= ?
+		  ORDER BY COALESCE(retry_at, scheduled_for) ASC
 		  LIMIT ?`,
 		now.UTC().Format(time.RFC3339), limit)
 	if err != nil {
@@ -534,12 +999,82 @@ func (s *Store) CancelScheduledToolJob(id string) error {
 	_, err := s.db.Exec(
 		`UPDATE scheduled_tool_jobs
 		    SET status = 'cancelled'
-		  WHERE id = ? AND status IN ('pending', 'failed')`,
+		  WHERE id = ? AND status IN ('pending', 'failed', 'draft')`,
 		id,
 	)
 	return err
 }
 
+// ListDraftToolJobs returns jobs awaiting approval, ordered by schedule time.
+func (s *Store) ListDraftToolJobs(limit int) ([]ScheduledToolJob, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.Query(
+		`SELECT id, tool_name, tool_input_json, scheduled_for, recurrence, status, attempt_count, last_error, last_result,
+		        COALESCE(last_attempt_at,''), COALESCE(completed_at,''), retry_count, COALESCE(retry_at,''), created_at
+		   FROM scheduled_tool_jobs
+		  WHERE status = 'draft'
+		  ORDER BY scheduled_for ASC
+		  LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanScheduledToolJobs(rows)
+}
+
+// ApproveDraftToolJob moves a draft to pending so the scheduler runs it at
+// its scheduled time (or on the next poll if that time has passed). id may
+// be a unique prefix. Returns the full job ID.
+func (s *Store) ApproveDraftToolJob(id string) (string, error) {
+	return s.resolveDraftToolJob(id, "pending")
+}
+
+// RejectDraftToolJob marks a draft as rejected so it never runs. id may be
+// a unique prefix. Returns the full job ID.
+func (s *Store) RejectDraftToolJob(id string) (string, error) {
+	return s.resolveDraftToolJob(id, "rejected")
+}
+
+func (s *Store) resolveDraftToolJob(prefix, status string) (string, error) {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return "", fmt.Errorf("draft id is required")
+	}
+	rows, err := s.db.Query(
+		`SELECT id FROM scheduled_tool_jobs WHERE status = 'draft' AND substr(id, 1, ?) = ? LIMIT 2`,
+		len(prefix), prefix,
+	)
+	if err != nil {
+		return "", err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return "", err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no draft matching %q", prefix)
+	case 1:
+	default:
+		return "", fmt.Errorf("draft id %q is ambiguous", prefix)
+	}
+	if _, err := s.db.Exec(
+		`UPDATE scheduled_tool_jobs SET status = ? WHERE id = ? AND status = 'draft'`,
+		status, ids[0],
+	); err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
 // MarkScheduledToolJobSucceeded records a successful execution.
 func (s *Store) MarkScheduledToolJobSucceeded(id, result string, completedAt time.Time) error {
 	result = truncateStoreText(result, 4000)
@@ -550,11 +1085,16 @@ func (s *Store) MarkScheduledToolJobSucceeded(id, result string, completedAt tim
 		        last_error = '',
 		        attempt_count = attempt_count + 1,
 		        last_attempt_at = ?,
-		        completed_at = ?
+		        completed_at = ?,
+		        retry_count = 0,
+		        retry_at = NULL
 		  WHERE id = ?`,
 		result, completedAt.UTC().Format(time.RFC3339), completedAt.UTC().Format(time.RFC3339), id,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	return s.recordScheduledToolJobAttempt(id, "succeeded", "", completedAt)
 }
 
 // MarkScheduledToolJobFailed records a failed execution.
@@ -568,13 +1108,99 @@ func (s *Store) MarkScheduledToolJobFailed(id, lastErr, result string, attempted
 		        last_result = ?,
 		        attempt_count = attempt_count + 1,
 		        last_attempt_at = ?,
-		        completed_at = NULL
+		        completed_at = NULL,
+		        retry_at = NULL
 		  WHERE id = ?`,
 		lastErr, result, attemptedAt.UTC().Format(time.RFC3339), id,
 	)
+	if err != nil {
+		return err
+	}
+	return s.recordScheduledToolJobAttempt(id, "failed", lastErr, attemptedAt)
+}
+
+// RetryScheduledToolJob records a failed execution and keeps the job
+// pending, to run again at retryAt. Its scheduled time is unchanged, so a
+// recurring job's later runs stay on their schedule.
+func (s *Store) RetryScheduledToolJob(id, lastErr, result string, attemptedAt, retryAt time.Time) error {
+	lastErr = truncateStoreText(lastErr, 2000)
+	result = truncateStoreText(result, 4000)
+	_, err := s.db.Exec(
+		`UPDATE scheduled_tool_jobs
+		    SET status = 'pending',
+		        last_error = ?,
+		        last_result = ?,
+		        attempt_count = attempt_count + 1,
+		        last_attempt_at = ?,
+		        completed_at = NULL,
+		        retry_count = retry_count + 1,
+		        retry_at = ?
+		  WHERE id = ?`,
+		lastErr, result, attemptedAt.UTC().Format(time.RFC3339), retryAt.UTC().Format(time.RFC3339), id,
+	)
+	if err != nil {
+		return err
+	}
+	return s.recordScheduledToolJobAttempt(id, "failed", lastErr, attemptedAt)
+}
+
+// SkipScheduledToolJob marks a job skipped without running it, as when it
+// came due while the daemon was down and scheduler.misfire is skip.
+func (s *Store) SkipScheduledToolJob(id, reason string, skippedAt time.Time) error {
+	_, err := s.db.Exec(
+		`UPDATE scheduled_tool_jobs
+		    SET status = 'skipped',
+		        last_error = ?,
+		        retry_count = 0,
+		        retry_at = NULL
+		  WHERE id = ?`,
+		reason, id,
+	)
+	if err != nil {
+		return err
+	}
+	return s.recordScheduledToolJobAttempt(id, "skipped", reason, skippedAt)
+}
+
+func (s *Store) recordScheduledToolJobAttempt(id, status, errText string, at time.Time) error {
+	_, err := s.db.Exec(
+		`INSERT INTO scheduled_tool_job_attempts (job_id, status, error, attempted_at) VALUES (?, ?, ?, ?)`,
+		id, status, errText, at.UTC().Format(time.RFC3339),
+	)
 	return err
 }
 
+// ScheduledToolJobAttempts returns the most recent recorded runs of a job,
+// newest first.
+func (s *Store) ScheduledToolJobAttempts(id string, limit int) ([]ScheduledToolJobAttempt, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	rows, err := s.db.Query(
+		`SELECT status, error, attempted_at
+		   FROM scheduled_tool_job_attempts
+		  WHERE job_id = ?
+		  ORDER BY id DESC
+		  LIMIT ?`, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []ScheduledToolJobAttempt
+	for rows.Next() {
+		var a ScheduledToolJobAttempt
+		var attemptedAt string
+		if err := rows.Scan(&a.Status, &a.Error, &attemptedAt); err != nil {
+			return nil, err
+		}
+		if t, err := parseAnyTime(attemptedAt); err == nil {
+			a.AttemptedAt = t
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
 // UpdateScheduledToolJob updates a pending job's tool input, scheduled time, and/or recurrence.
 // Only fields with non-nil/non-empty values are updated. Only modifies jobs with status = 'pending'.
 func (s *Store) UpdateScheduledToolJob(id string, toolInput map[string]any, scheduledFor *time.Time, recurrence *string) error {
@@ -618,7 +1244,9 @@ func (s *Store) RescheduleScheduledToolJob(id string, next time.Time) error {
 		    SET status = 'pending',
 		        scheduled_for = ?,
 		        last_error = '',
-		        completed_at = NULL
+		        completed_at = NULL,
+		        retry_count = 0,
+		        retry_at = NULL
 		  WHERE id = ?`,
 		next.UTC().Format(time.RFC3339), id,
 	)
@@ -629,7 +1257,7 @@ func scanScheduledToolJobs(rows *sql.Rows) ([]ScheduledToolJob, error) {
 	var out []ScheduledToolJob
 	for rows.Next() {
 		var item ScheduledToolJob
-		var inputJSON, scheduledForStr, lastAttemptStr, completedAtStr, createdAtStr string
+		var inputJSON, scheduledForStr, lastAttemptStr, completedAtStr, retryAtStr, createdAtStr string
 		if err := rows.Scan(
 			&item.ID,
 			&item.ToolName,
@@ -642,6 +1270,8 @@ func scanScheduledToolJobs(rows *sql.Rows) ([]ScheduledToolJob, error) {
 			&item.LastResult,
 			&lastAttemptStr,
 			&completedAtStr,
+			&item.RetryCount,
+			&retryAtStr,
 			&createdAtStr,
 		); err != nil {
 			return nil, err
@@ -666,11 +1296,212 @@ func scanScheduledToolJobs(rows *sql.Rows) ([]ScheduledToolJob, error) {
 		if t, ok := parseOptionalTime(completedAtStr); ok {
 			item.CompletedAt = &t
 		}
+		if t, ok := parseOptionalTime(retryAtStr); ok {
+			item.RetryAt = &t
+		}
 		out = append(out, item)
 	}
 	return out, rows.Err()
 }
 
+// ---------------------------------------------------------------------------
+// Jobs
+// ---------------------------------------------------------------------------
+
+// Job states. A job is queued until the daemon's job runner picks it up,
+// then running until it ends in one of the other three.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// Job limits: the stored result and the captured log are cut off at these
+// sizes.
+const (
+	maxJobResult = 50 * 1024
+	maxJobLog    = 256 * 1024
+)
+
+// Job is a headless agent run queued through the job API.
+type Job struct {
+	ID         string     `json:"id"`
+	Prompt     string     `json:"prompt"`
+	Tools      []string   `json:"tools,omitempty"` // the only tools the agent may use; empty allows all
+	Status     string     `json:"status"`
+	SessionID  string     `json:"session_id,omitempty"`
+	Result     string     `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Log        string     `json:"log,omitempty"` // filled by GetJob only
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// CreateJob queues a job.
+func (s *Store) CreateJob(prompt string, tools []string) (*Job, error) {
+	if tools == nil {
+		tools = []string{}
+	}
+	payload, err := json.Marshal(tools)
+	if err != nil {
+		return nil, fmt.Errorf("marshal job tools: %w", err)
+	}
+	id := domain.NewUUID()
+	if _, err := s.db.Exec(
+		`INSERT INTO jobs (id, prompt, tools_json, status) VALUES (?, ?, ?, ?)`,
+		id, prompt, string(payload), JobQueued,
+	); err != nil {
+		return nil, err
+	}
+	return s.GetJob(id)
+}
+
+const jobColumns = `id, prompt, tools_json, status, session_id, result, error,
+	COALESCE(started_at,''), COALESCE(finished_at,''), created_at`
+
+// GetJob returns a job with its log. It returns sql.ErrNoRows when there is
+// no such job.
+func (s *Store) GetJob(id string) (*Job, error) {
+	rows, err := s.db.Query(`SELECT `+jobColumns+`, log FROM jobs WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	jobs, err := scanJobs(rows, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return &jobs[0], nil
+}
+
+// ListJobs returns the newest jobs first, without their logs.
+func (s *Store) ListJobs(limit int) ([]Job, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.Query(`SELECT `+jobColumns+` FROM jobs ORDER BY created_at DESC, rowid DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanJobs(rows, false)
+}
+
+// ClaimJob marks the oldest queued job as running and returns it, or nil
+// when the queue is empty.
+func (s *Store) ClaimJob() (*Job, error) {
+	var id string
+	err := s.db.QueryRow(`SELECT id FROM jobs WHERE status = ? ORDER BY created_at, rowid LIMIT 1`, JobQueued).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.db.Exec(
+		`UPDATE jobs SET status = ?, started_at = ? WHERE id = ? AND status = ?`,
+		JobRunning, time.Now().UTC().Format(time.RFC3339), id, JobQueued,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		// Cancelled in between; try the next one.
+		return s.ClaimJob()
+	}
+	return s.GetJob(id)
+}
+
+// SetJobSession records the session a running job works in.
+func (s *Store) SetJobSession(id, sessionID string) error {
+	_, err := s.db.Exec(`UPDATE jobs SET session_id = ? WHERE id = ?`, sessionID, id)
+	return err
+}
+
+// AppendJobLog adds text to a job's log until the log reaches its size
+// limit.
+func (s *Store) AppendJobLog(id, text string) error {
+	_, err := s.db.Exec(
+		`UPDATE jobs SET log = log || ? WHERE id = ? AND length(log) < ?`,
+		text, id, maxJobLog,
+	)
+	return err
+}
+
+// FinishJob records how a running job ended.
+func (s *Store) FinishJob(id, status, result, errText string) error {
+	_, err := s.db.Exec(
+		`UPDATE jobs SET status = ?, result = ?, error = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		status, truncateStoreText(result, maxJobResult), truncateStoreText(errText, 2000),
+		time.Now().UTC().Format(time.RFC3339), id, JobRunning,
+	)
+	return err
+}
+
+// CancelQueuedJob cancels a job that has not started yet and reports
+// whether it did.
+func (s *Store) CancelQueuedJob(id string) (bool, error) {
+	res, err := s.db.Exec(
+		`UPDATE jobs SET status = ?, finished_at = ? WHERE id = ? AND status = ?`,
+		JobCancelled, time.Now().UTC().Format(time.RFC3339), id, JobQueued,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// FailInterruptedJobs marks jobs left running by a daemon that stopped as
+// failed. It returns how many there were.
+func (s *Store) FailInterruptedJobs() (int, error) {
+	res, err := s.db.Exec(
+		`UPDATE jobs SET status = ?, error = 'interrupted: the daemon stopped', finished_at = ? WHERE status = ?`,
+		JobFailed, time.Now().UTC().Format(time.RFC3339), JobRunning,
+	)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+func scanJobs(rows *sql.Rows, withLog bool) ([]Job, error) {
+	var out []Job
+	for rows.Next() {
+		var job Job
+		var toolsJSON, startedStr, finishedStr, createdStr string
+		dest := []any{&job.ID, &job.Prompt, &toolsJSON, &job.Status, &job.SessionID, &job.Result, &job.Error,
+			&startedStr, &finishedStr, &createdStr}
+		if withLog {
+			dest = append(dest, &job.Log)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(toolsJSON), &job.Tools); err != nil {
+			fmt.Fprintf(os.Stderr, "store: unmarshal job tools: %v\n", err)
+		}
+		if t, err := parseAnyTime(createdStr); err == nil {
+			job.CreatedAt = t
+		}
+		if t, ok := parseOptionalTime(startedStr); ok {
+			job.StartedAt = &t
+		}
+		if t, ok := parseOptionalTime(finishedStr); ok {
+			job.FinishedAt = &t
+		}
+		out = append(out, job)
+	}
+	return out, rows.Err()
+}
+
 func truncateStoreText(s string, n int) string {
 	if len(s) <= n {
 		return s
@@ -698,54 +1529,1020 @@ func parseAnyTime(s string) (time.Time, error) {
 }
 
 // ---------------------------------------------------------------------------
-// Branching
+// Feedback
 // ---------------------------------------------------------------------------
 
-// BranchSession creates a new session forked from fromSessionID, copying
-// messages up to atSequence. If atSequence <= 0, all messages are copied.
-func (s *Store) BranchSession(fromSessionID string, atSequence int) (*domain.Session, error) {
-	src, err := s.GetSession(fromSessionID)
+// Rating values stored on assistant messages.
+const (
+	RatingBad  = -1
+	RatingNone = 0
+	RatingGood = 1
+)
+
+// ParseRating converts "good"/"bad"/"clear" (and aliases) to a rating.
+func ParseRating(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "good", "up", "+", "+1", "1":
+		return RatingGood, nil
+	case "bad", "down", "-", "-1":
+		return RatingBad, nil
+	case "clear", "none", "0":
+		return RatingNone, nil
+	default:
+		return 0, fmt.Errorf("invalid rating %q (use good, bad, or clear)", s)
+	}
+}
+
+// RateMessage records feedback on the assistant message at sequence. A
+// sequence of 0 rates the session's most recent assistant message. Returns
+// the sequence that was rated.
+func (s *Store) RateMessage(sessionID string, sequence, rating int, note string) (int, error) {
+	if sequence <= 0 {
+		err := s.db.QueryRow(
+			`SELECT COALESCE(MAX(sequence), 0) FROM messages WHERE session_id = ? AND role = 'assistant'`,
+			sessionID).Scan(&sequence)
+		if err != nil {
+			return 0, err
+		}
+		if sequence == 0 {
+			return 0, fmt.Errorf("no assistant message to rate")
+		}
+	}
+	res, err := s.db.Exec(
+		`UPDATE messages SET rating = ?, feedback_note = ?, feedback_at = datetime('now')
+		 WHERE session_id = ? AND sequence = ? AND role = 'assistant'`,
+		rating, strings.TrimSpace(note), sessionID, sequence)
 	if err != nil {
-		return nil, fmt.Errorf("source session: %w", err)
+		return 0, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, fmt.Errorf("no assistant message at sequence %d", sequence)
 	}
+	return sequence, nil
+}
 
-	if atSequence <= 0 {
-		maxSeq, seqErr := s.MessageMaxSequence(fromSessionID)
-		if seqErr != nil {
-			return nil, fmt.Errorf("max sequence: %w", seqErr)
+// SessionFeedback returns the number of good and bad ratings in a session.
+func (s *Store) SessionFeedback(sessionID string) (good, bad int, err error) {
+	err = s.db.QueryRow(
+		`SELECT COALESCE(SUM(rating = 1), 0), COALESCE(SUM(rating = -1), 0) FROM messages WHERE session_id = ?`,
+		sessionID).Scan(&good, &bad)
+	return
+}
+
+// FeedbackNote is a rated message with a note, for the quality dashboard.
+type FeedbackNote struct {
+	SessionID    string    `json:"session_id"`
+	SessionTitle string    `json:"session_title"`
+	Sequence     int       `json:"sequence"`
+	Rating       int       `json:"rating"`
+	Note         string    `json:"note"`
+	At           time.Time `json:"at"`
+}
+
+// FeedbackSummary aggregates ratings across sessions.
+type FeedbackSummary struct {
+	Turns       int            `json:"turns"` // assistant messages considered
+	Good        int            `json:"good"`
+	Bad         int            `json:"bad"`
+	RecentNotes []FeedbackNote `json:"recent_notes,omitempty"`
+}
+
+// FeedbackSummary aggregates ratings for assistant messages created since
+// the given time (zero means all time). An empty projectPath covers all
+// projects.
+func (s *Store) FeedbackSummary(projectPath string, since time.Time) (FeedbackSummary, error) {
+	var sum FeedbackSummary
+	sinceStr := ""
+	if !since.IsZero() {
+		sinceStr = since.UTC().Format("2006-01-02 15:04:05")
+	}
+	err := s.db.QueryRow(
+		`SELECT COUNT(*), COALESCE(SUM(m.rating = 1), 0), COALESCE(SUM(m.rating = -1), 0)
+		 FROM messages m JOIN sessions s ON s.id = m.session_id
+		 WHERE m.role = 'assistant' AND (? = '' OR s.project_path = ?) AND (? = '' OR m.created_at >= ?)`,
+		projectPath, projectPath, sinceStr, sinceStr).Scan(&sum.Turns, &sum.Good, &sum.Bad)
+	if err != nil {
+		return sum, err
+	}
+
+	rows, err := s.db.Query(
+		`SELECT m.session_id, s.title, m.sequence, m.rating, m.feedback_note, COALESCE(m.feedback_at, '')
+		 FROM messages m JOIN sessions s ON s.id = m.session_id
+		 WHERE m.feedback_note != '' AND (? = '' OR s.project_path = ?) AND (? = '' OR m.created_at >= ?)
+		 ORDER BY m.feedback_at DESC LIMIT 10`,
+		projectPath, projectPath, sinceStr, sinceStr)
+	if err != nil {
+		return sum, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var n FeedbackNote
+		var atStr string
+		if err := rows.Scan(&n.SessionID, &n.SessionTitle, &n.Sequence, &n.Rating, &n.Note, &atStr); err != nil {
+			return sum, err
 		}
-		atSequence = maxSeq
+		if t, err := time.Parse("2006-01-02 15:04:05", atStr); err == nil {
+			n.At = t
+		}
+		sum.RecentNotes = append(sum.RecentNotes, n)
 	}
+	return sum, rows.Err()
+}
 
-	newID := domain.NewUUID()
-	now := time.Now().UTC().Format(time.RFC3339)
+// ---------------------------------------------------------------------------
+// Checkpoints
+// ---------------------------------------------------------------------------
+
+// Checkpoint is a snapshot of a session's working tree. SHA is the git
+// stash commit holding it, empty when the tree matched HEAD. An undone
+// checkpoint keeps in RedoSHA the tree its undo replaced.
+type Checkpoint struct {
+	ID        int64     `json:"id"`
+	SessionID string    `json:"session_id"`
+	Turn      int       `json:"turn"`            // agent loop iteration; 0 for one taken on request
+	Label     string    `json:"label,omitempty"` // set on checkpoints taken on request
+	Dir       string    `json:"dir"`             // working tree the snapshot is of
+	SHA       string    `json:"sha,omitempty"`
+	RedoSHA   string    `json:"redo_sha,omitempty"`
+	Undone    bool      `json:"undone"`
+	CreatedAt time.Time `json:"created_at"`
+}
 
+// AddCheckpoint records a new checkpoint and returns its ID. Checkpoints
+// undone before it are dropped, since a new one ends the redo history.
+func (s *Store) AddCheckpoint(cp Checkpoint) (int64, error) {
 	tx, err := s.db.Begin()
 	if err != nil {
-		return nil, fmt.Errorf("begin tx: %w", err)
+		return 0, err
 	}
 	defer tx.Rollback()
-
-	// Insert new session with parent reference.
-	_, err = tx.Exec(
-		`INSERT INTO sessions (id, project_path, title, model, total_tokens, input_tokens, output_tokens, message_count, parent_session_id, branch_point, tags, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, datetime(?), datetime(?))`,
-		newID, src.ProjectPath, src.Title+" (branch)", src.Model,
-		0, 0, 0,
-		fromSessionID, atSequence, src.Tags,
-		now, now,
-	)
+	if _, err := tx.Exec(`DELETE FROM checkpoints WHERE session_id = ? AND undone = 1`, cp.SessionID); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec(
+		`INSERT INTO checkpoints (session_id, turn, label, dir, sha) VALUES (?, ?, ?, ?, ?)`,
+		cp.SessionID, cp.Turn, cp.Label, cp.Dir, cp.SHA)
 	if err != nil {
-		return nil, fmt.Errorf("insert session: %w", err)
+		return 0, err
 	}
-
-	// Copy messages up to atSequence with new UUIDs and renumbered sequence.
-	rows, err := tx.Query(
-		`SELECT role, content, COALESCE(content_type, 'text'), tokens, sequence FROM messages
-		 WHERE session_id = ? AND sequence <= ? ORDER BY sequence`,
-		fromSessionID, atSequence)
+	id, err := res.LastInsertId()
 	if err != nil {
-		return nil, fmt.Errorf("query messages: %w", err)
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+// ListCheckpoints returns a session's checkpoints, oldest first.
+func (s *Store) ListCheckpoints(sessionID string) ([]Checkpoint, error) {
+	rows, err := s.db.Query(
+		`SELECT id, session_id, turn, label, dir, sha, redo_sha, undone, created_at
+		 FROM checkpoints WHERE session_id = ? ORDER BY id`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Checkpoint
+	for rows.Next() {
+		var cp Checkpoint
+		var createdStr string
+		if err := rows.Scan(&cp.ID, &cp.SessionID, &cp.Turn, &cp.Label, &cp.Dir, &cp.SHA, &cp.RedoSHA, &cp.Undone, &createdStr); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+			cp.CreatedAt = t
+		}
+		out = append(out, cp)
+	}
+	return out, rows.Err()
+}
+
+// FirstUndone returns the index in cps (oldest first) of the first of the
+// undone checkpoints, which are always the newest ones: undo takes the
+// checkpoint before it, redo this one. It is len(cps) when none are undone.
+func FirstUndone(cps []Checkpoint) int {
+	i := len(cps)
+	for i > 0 && cps[i-1].Undone {
+		i--
+	}
+	return i
+}
+
+// SetCheckpointUndone marks a checkpoint undone, keeping the SHA of the
+// tree to restore on redo, or redone when undone is false.
+func (s *Store) SetCheckpointUndone(id int64, undone bool, redoSHA string) error {
+	if !undone {
+		redoSHA = ""
+	}
+	_, err := s.db.Exec(`UPDATE checkpoints SET undone = ?, redo_sha = ? WHERE id = ?`, undone, redoSHA, id)
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Post-mortems
+// ---------------------------------------------------------------------------
+
+// Postmortem is an automatic diagnosis of a turn that ended in an error
+// after repeated tool failures.
+type Postmortem struct {
+	ID           string    `json:"id"`
+	SessionID    string    `json:"session_id"`
+	Error        string    `json:"error"`
+	ToolFailures int       `json:"tool_failures"`
+	FailedTools  string    `json:"failed_tools"` // comma-separated
+	Category     string    `json:"category"`
+	Diagnosis    string    `json:"diagnosis"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SavePostmortem attaches a post-mortem to its session.
+func (s *Store) SavePostmortem(p Postmortem) error {
+	if p.ID == "" {
+		p.ID = domain.NewUUID()
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO postmortems (id, session_id, error, tool_failures, failed_tools, category, diagnosis)
+		 VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, p.SessionID, truncateStoreText(p.Error, 2000), p.ToolFailures, p.FailedTools,
+		strings.ToLower(strings.TrimSpace(p.Category)), p.Diagnosis)
+	return err
+}
+
+// ListPostmortems returns a session's post-mortems, newest first.
+func (s *Store) ListPostmortems(sessionID string) ([]Postmortem, error) {
+	rows, err := s.db.Query(
+		`SELECT id, session_id, error, tool_failures, failed_tools, category, diagnosis, created_at
+		 FROM postmortems WHERE session_id = ? ORDER BY created_at DESC, rowid DESC`, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Postmortem
+	for rows.Next() {
+		var p Postmortem
+		var createdStr string
+		if err := rows.Scan(&p.ID, &p.SessionID, &p.Error, &p.ToolFailures, &p.FailedTools, &p.Category, &p.Diagnosis, &createdStr); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+			p.CreatedAt = t
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// FailurePattern groups post-mortems that share a category.
+type FailurePattern struct {
+	Category      string    `json:"category"`
+	Count         int       `json:"count"`
+	Sessions      int       `json:"sessions"`
+	LastDiagnosis string    `json:"last_diagnosis"`
+	LastSeen      time.Time `json:"last_seen"`
+}
+
+// FailurePatterns returns post-mortem categories ordered by frequency, for
+// post-mortems created since the given time (zero means all time). An empty
+// projectPath covers all projects.
+func (s *Store) FailurePatterns(projectPath string, since time.Time, limit int) ([]FailurePattern, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+	sinceStr := ""
+	if !since.IsZero() {
+		sinceStr = since.UTC().Format("2006-01-02 15:04:05")
+	}
+	rows, err := s.db.Query(
+		`SELECT p.category, COUNT(*), COUNT(DISTINCT p.session_id), MAX(p.created_at),
+		        (SELECT p2.diagnosis FROM postmortems p2 WHERE p2.category = p.category ORDER BY p2.created_at DESC, p2.rowid DESC LIMIT 1)
+		 FROM postmortems p JOIN sessions s ON s.id = p.session_id
+		 WHERE (? = '' OR s.project_path = ?) AND (? = '' OR p.created_at >= ?)
+		 GROUP BY p.category ORDER BY COUNT(*) DESC, MAX(p.created_at) DESC LIMIT ?`,
+		projectPath, projectPath, sinceStr, sinceStr, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []FailurePattern
+	for rows.Next() {
+		var fp FailurePattern
+		var lastStr string
+		if err := rows.Scan(&fp.Category, &fp.Count, &fp.Sessions, &lastStr, &fp.LastDiagnosis); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", lastStr); err == nil {
+			fp.LastSeen = t
+		}
+		out = append(out, fp)
+	}
+	return out, rows.Err()
+}
+
+// ---------------------------------------------------------------------------
+// Audit log
+// ---------------------------------------------------------------------------
+
+// AuditEntry records a decision about content leaving muxd, e.g. personal
+// data masked before an SMS was sent. Detail must not contain the data
+// itself.
+type AuditEntry struct {
+	ID        string    `json:"id"`
+	SessionID string    `json:"session_id,omitempty"`
+	Tool      string    `json:"tool"`
+	Decision  string    `json:"decision"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecordAudit appends an entry to the audit log.
+func (s *Store) RecordAudit(e AuditEntry) error {
+	if e.ID == "" {
+		e.ID = domain.NewUUID()
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO audit_log (id, session_id, tool, decision, detail) VALUES (?, ?, ?, ?, ?)`,
+		e.ID, e.SessionID, e.Tool, e.Decision, truncateStoreText(e.Detail, 2000))
+	return err
+}
+
+// ListAuditEntries returns the newest audit entries, optionally limited to
+// one session.
+func (s *Store) ListAuditEntries(sessionID string, limit int) ([]AuditEntry, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.Query(
+		`SELECT id, session_id, tool, decision, detail, created_at FROM audit_log
+		 WHERE (? = '' OR session_id = ?) ORDER BY created_at DESC, rowid DESC LIMIT ?`,
+		sessionID, sessionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var createdStr string
+		if err := rows.Scan(&e.ID, &e.SessionID, &e.Tool, &e.Decision, &e.Detail, &createdStr); err != nil {
+			return nil, err
+		}
+		if t, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+			e.CreatedAt = t
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// ---------------------------------------------------------------------------
+// API tokens
+// ---------------------------------------------------------------------------
+
+// Token scopes, from least to most privileged. Each scope includes the
+// ones before it.
+const (
+	TokenScopeRead   = "read"   // list and read sessions, stream events
+	TokenScopeSubmit = "submit" // also create sessions and drive turns
+	TokenScopeAdmin  = "admin"  // everything, including config and tokens
+)
+
+var tokenScopeRank = map[string]int{TokenScopeRead: 1, TokenScopeSubmit: 2, TokenScopeAdmin: 3}
+
+// ParseTokenScope validates a token scope.
+func ParseTokenScope(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if _, ok := tokenScopeRank[s]; !ok {
+		return "", fmt.Errorf("invalid token scope %q (use read, submit, or admin)", s)
+	}
+	return s, nil
+}
+
+// ScopeAllows reports whether a token with scope may do what required
+// needs.
+func ScopeAllows(scope, required string) bool {
+	have, ok := tokenScopeRank[scope]
+	return ok && have >= tokenScopeRank[required]
+}
+
+// APIToken is a named, scoped bearer token for the daemon API. The secret
+// itself is only known when the token is created.
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func hashAPIToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIToken stores a token for secret with the given name and scope.
+func (s *Store) CreateAPIToken(name, scope, secret string) (*APIToken, error) {
+	scope, err := ParseTokenScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("empty token")
+	}
+	t := &APIToken{ID: domain.NewUUID(), Name: name, Scope: scope, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	_, err = s.db.Exec(
+		`INSERT INTO api_tokens (id, name, scope, token_hash, created_at) VALUES (?, ?, ?, ?, datetime(?))`,
+		t.ID, t.Name, t.Scope, hashAPIToken(secret), t.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("creating token: %w", err)
+	}
+	return t, nil
+}
+
+// apiTokenUseInterval is how stale a token's last_used_at must be before a
+// lookup records the use again, so steady API traffic does not turn every
+// request into a write.
+const apiTokenUseInterval = time.Minute
+
+// LookupAPIToken returns the token whose secret is secret, or nil if there is
+// none. The use is recorded when the last recorded one is older than
+// apiTokenUseInterval.
+func (s *Store) LookupAPIToken(secret string) (*APIToken, error) {
+	h := hashAPIToken(secret)
+	var t APIToken
+	var createdStr, usedStr string
+	err := s.db.QueryRow(`SELECT id, name, scope, created_at, COALESCE(last_used_at, '') FROM api_tokens WHERE token_hash = ?`, h).
+		Scan(&t.ID, &t.Name, &t.Scope, &createdStr, &usedStr)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+		t.CreatedAt = c
+	}
+	now := time.Now().UTC()
+	if u, ok := parseOptionalTime(usedStr); ok && now.Sub(u) < apiTokenUseInterval {
+		t.LastUsedAt = &u
+		return &t, nil
+	}
+	t.LastUsedAt = &now
+	if _, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = datetime(?) WHERE id = ?`, now.Format(time.RFC3339), t.ID); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListAPITokens returns all tokens, oldest first.
+func (s *Store) ListAPITokens() ([]APIToken, error) {
+	rows, err := s.db.Query(`SELECT id, name, scope, created_at, COALESCE(last_used_at, '') FROM api_tokens ORDER BY created_at, rowid`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []APIToken
+	for rows.Next() {
+		var t APIToken
+		var createdStr, usedStr string
+		if err := rows.Scan(&t.ID, &t.Name, &t.Scope, &createdStr, &usedStr); err != nil {
+			return nil, err
+		}
+		if c, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+			t.CreatedAt = c
+		}
+		if u, ok := parseOptionalTime(usedStr); ok {
+			t.LastUsedAt = &u
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// DeleteAPIToken revokes the token with the given ID or unique ID prefix.
+func (s *Store) DeleteAPIToken(prefix string) error {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return fmt.Errorf("token id is required")
+	}
+	rows, err := s.db.Query(`SELECT id FROM api_tokens WHERE substr(id, 1, ?) = ? LIMIT 2`, len(prefix), prefix)
+	if err != nil {
+		return err
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	switch len(ids) {
+	case 0:
+		return fmt.Errorf("no token matching %q", prefix)
+	case 1:
+	default:
+		return fmt.Errorf("token id %q is ambiguous", prefix)
+	}
+	_, err = s.db.Exec(`DELETE FROM api_tokens WHERE id = ?`, ids[0])
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Session shares
+// ---------------------------------------------------------------------------
+
+// SessionShare is a read-only link to a session's live transcript.
+type SessionShare struct {
+	Token     string
+	SessionID string
+	CreatedAt time.Time
+}
+
+// ShareSession returns the session's share, creating one with token if it
+// has none. Sharing an already shared session keeps the existing link.
+func (s *Store) ShareSession(sessionID, token string) (*SessionShare, error) {
+	if token == "" {
+		return nil, fmt.Errorf("empty share token")
+	}
+	if sh, err := s.sessionShare(`session_id = ?`, sessionID); sh != nil || err != nil {
+		return sh, err
+	}
+	sh := &SessionShare{Token: token, SessionID: sessionID, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	_, err := s.db.Exec(`INSERT INTO session_shares (token, session_id, created_at) VALUES (?, ?, datetime(?))`,
+		sh.Token, sh.SessionID, sh.CreatedAt.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("sharing session: %w", err)
+	}
+	return sh, nil
+}
+
+// LookupShare returns the share with token, or nil if there is none.
+func (s *Store) LookupShare(token string) (*SessionShare, error) {
+	if token == "" {
+		return nil, nil
+	}
+	return s.sessionShare(`token = ?`, token)
+}
+
+func (s *Store) sessionShare(where string, arg string) (*SessionShare, error) {
+	var sh SessionShare
+	var createdStr string
+	err := s.db.QueryRow(`SELECT token, session_id, created_at FROM session_shares WHERE `+where+` LIMIT 1`, arg).
+		Scan(&sh.Token, &sh.SessionID, &createdStr)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+		sh.CreatedAt = c
+	}
+	return &sh, nil
+}
+
+// UnshareSession revokes every share link of the session and reports how
+// many there were.
+func (s *Store) UnshareSession(sessionID string) (int64, error) {
+	res, err := s.db.Exec(`DELETE FROM session_shares WHERE session_id = ?`, sessionID)
+	if err != nil {
+		return 0, fmt.Errorf("unsharing session: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// ---------------------------------------------------------------------------
+// Push devices
+// ---------------------------------------------------------------------------
+
+// Push platforms.
+const (
+	PushPlatformAPNs = "apns"
+	PushPlatformFCM  = "fcm"
+)
+
+// PushDevice is a mobile app install that receives push notifications.
+// Foreground is set while the app is open; notifications only go to
+// devices in the background.
+type PushDevice struct {
+	ID         string    `json:"id"`
+	Platform   string    `json:"platform"`
+	Token      string    `json:"-"`
+	Name       string    `json:"name"`
+	Foreground bool      `json:"foreground"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// SavePushDevice registers a device token, or updates the name and
+// foreground state of an already registered one, and returns the device.
+func (s *Store) SavePushDevice(platform, token, name string, foreground bool) (*PushDevice, error) {
+	platform = strings.ToLower(strings.TrimSpace(platform))
+	if platform != PushPlatformAPNs && platform != PushPlatformFCM {
+		return nil, fmt.Errorf("invalid push platform %q (use apns or fcm)", platform)
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, fmt.Errorf("empty device token")
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	_, err := s.db.Exec(`
+		INSERT INTO push_devices (id, platform, token, name, foreground, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, datetime(?), datetime(?))
+		ON CONFLICT(token) DO UPDATE SET
+			platform = excluded.platform,
+			name = excluded.name,
+			foreground = excluded.foreground,
+			updated_at = excluded.updated_at`,
+		domain.NewUUID(), platform, token, name, foreground, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("saving push device: %w", err)
+	}
+	devices, err := s.pushDevices(`WHERE token = ?`, token)
+	if err != nil || len(devices) == 0 {
+		return nil, err
+	}
+	return &devices[0], nil
+}
+
+// ListPushDevices returns all registered devices, oldest first.
+func (s *Store) ListPushDevices() ([]PushDevice, error) {
+	return s.pushDevices("")
+}
+
+// BackgroundPushDevices returns the devices that should be notified: those
+// whose app is not in the foreground.
+func (s *Store) BackgroundPushDevices() ([]PushDevice, error) {
+	return s.pushDevices(`WHERE foreground = 0`)
+}
+
+func (s *Store) pushDevices(where string, args ...any) ([]PushDevice, error) {
+	rows, err := s.db.Query(`SELECT id, platform, token, name, foreground, created_at, updated_at
+		FROM push_devices `+where+` ORDER BY created_at, rowid`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []PushDevice
+	for rows.Next() {
+		var d PushDevice
+		var createdStr, updatedStr string
+		if err := rows.Scan(&d.ID, &d.Platform, &d.Token, &d.Name, &d.Foreground, &createdStr, &updatedStr); err != nil {
+			return nil, err
+		}
+		if c, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+			d.CreatedAt = c
+		}
+		if u, err := time.Parse("2006-01-02 15:04:05", updatedStr); err == nil {
+			d.UpdatedAt = u
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// DeletePushDevice unregisters the device with the given ID and reports
+// whether it existed.
+func (s *Store) DeletePushDevice(id string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM push_devices WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("deleting push device: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeletePushDeviceToken unregisters the device with the given token, used
+// when the push service reports it is no longer valid.
+func (s *Store) DeletePushDeviceToken(token string) error {
+	_, err := s.db.Exec(`DELETE FROM push_devices WHERE token = ?`, token)
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Webhooks
+// ---------------------------------------------------------------------------
+
+// Webhook is an endpoint that receives daemon events. Secret keys the HMAC
+// signature of each delivery and is only returned when it is created.
+type Webhook struct {
+	ID             string     `json:"id"`
+	URL            string     `json:"url"`
+	Secret         string     `json:"-"`
+	Events         []string   `json:"events"` // empty means every event
+	CreatedAt      time.Time  `json:"created_at"`
+	LastDeliveryAt *time.Time `json:"last_delivery_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+}
+
+// Wants reports whether the webhook is subscribed to event.
+func (w Webhook) Wants(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// CreateWebhook saves a webhook and returns it.
+func (s *Store) CreateWebhook(url, secret string, events []string) (*Webhook, error) {
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return nil, fmt.Errorf("empty webhook URL")
+	}
+	id := domain.NewUUID()
+	if _, err := s.db.Exec(`INSERT INTO webhooks (id, url, secret, events) VALUES (?, ?, ?, ?)`,
+		id, url, secret, strings.Join(events, ",")); err != nil {
+		return nil, fmt.Errorf("saving webhook: %w", err)
+	}
+	hooks, err := s.webhooks(`WHERE id = ?`, id)
+	if err != nil || len(hooks) == 0 {
+		return nil, err
+	}
+	return &hooks[0], nil
+}
+
+// ListWebhooks returns all webhooks, oldest first.
+func (s *Store) ListWebhooks() ([]Webhook, error) {
+	return s.webhooks("")
+}
+
+func (s *Store) webhooks(where string, args ...any) ([]Webhook, error) {
+	rows, err := s.db.Query(`SELECT id, url, secret, events, created_at, last_delivery_at, last_error
+		FROM webhooks `+where+` ORDER BY created_at, rowid`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Webhook
+	for rows.Next() {
+		var w Webhook
+		var events, createdStr string
+		var lastStr sql.NullString
+		if err := rows.Scan(&w.ID, &w.URL, &w.Secret, &events, &createdStr, &lastStr, &w.LastError); err != nil {
+			return nil, err
+		}
+		if events != "" {
+			w.Events = strings.Split(events, ",")
+		}
+		if c, err := time.Parse("2006-01-02 15:04:05", createdStr); err == nil {
+			w.CreatedAt = c
+		}
+		if lastStr.Valid {
+			if t, err := time.Parse(time.RFC3339, lastStr.String); err == nil {
+				w.LastDeliveryAt = &t
+			}
+		}
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+// DeleteWebhook removes the webhook with the given ID and reports whether
+// it existed.
+func (s *Store) DeleteWebhook(id string) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("deleting webhook: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// RecordWebhookDelivery stores the outcome of the latest delivery; an
+// empty errText means it succeeded.
+func (s *Store) RecordWebhookDelivery(id string, at time.Time, errText string) error {
+	_, err := s.db.Exec(`UPDATE webhooks SET last_delivery_at = ?, last_error = ? WHERE id = ?`,
+		at.UTC().Format(time.RFC3339), errText, id)
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// Spend
+// ---------------------------------------------------------------------------
+
+// SpendDayLayout formats the day keys of daily spend aggregates.
+const SpendDayLayout = "2006-01-02"
+
+// DailySpend is the estimated spend on one model during one day.
+type DailySpend struct {
+	Day          string  `json:"day"`
+	Model        string  `json:"model"`
+	InputTokens  int     `json:"input_tokens"`
+	OutputTokens int     `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"`
+}
+
+// TokenCounts are the tokens billed for one model call.
+type TokenCounts struct {
+	Input      int
+	Output     int
+	CacheWrite int
+	CacheRead  int
+}
+
+// RecordSpend adds the usage and cost of one model call to the session's
+// total and to the aggregate for the local day of at and the session's
+// project. sessionID may be empty for calls that belong to no session.
+func (s *Store) RecordSpend(sessionID, model string, at time.Time, tokens TokenCounts, costUSD float64) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("recording spend: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(
+		`INSERT INTO usage_daily (day, model, project_path, input_tokens, output_tokens, cache_write_tokens, cache_read_tokens, cost_usd)
+		 VALUES (?, ?, COALESCE((SELECT project_path FROM sessions WHERE id = ?), ''), ?, ?, ?, ?, ?)
+		 ON CONFLICT(day, model, project_path) DO UPDATE SET
+		   input_tokens = input_tokens + excluded.input_tokens,
+		   output_tokens = output_tokens + excluded.output_tokens,
+		   cache_write_tokens = cache_write_tokens + excluded.cache_write_tokens,
+		   cache_read_tokens = cache_read_tokens + excluded.cache_read_tokens,
+		   cost_usd = cost_usd + excluded.cost_usd`,
+		at.Local().Format(SpendDayLayout), model, sessionID,
+		tokens.Input, tokens.Output, tokens.CacheWrite, tokens.CacheRead, costUSD); err != nil {
+		return fmt.Errorf("recording spend: %w", err)
+	}
+	if sessionID != "" {
+		if _, err := tx.Exec(`UPDATE sessions SET cost_usd = cost_usd + ? WHERE id = ?`, costUSD, sessionID); err != nil {
+			return fmt.Errorf("recording spend: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// SessionSpend returns the session's estimated spend, or 0 if it has none.
+func (s *Store) SessionSpend(sessionID string) (float64, error) {
+	var cost float64
+	err := s.db.QueryRow(`SELECT COALESCE(cost_usd, 0) FROM sessions WHERE id = ?`, sessionID).Scan(&cost)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return cost, err
+}
+
+// DaySpend returns the estimated spend across all models on the local day
+// of at.
+func (s *Store) DaySpend(at time.Time) (float64, error) {
+	var cost float64
+	err := s.db.QueryRow(`SELECT COALESCE(SUM(cost_usd), 0) FROM usage_daily WHERE day = ?`,
+		at.Local().Format(SpendDayLayout)).Scan(&cost)
+	return cost, err
+}
+
+// ListDailySpend returns the per-model aggregates from the local day of
+// since onwards, newest day first.
+func (s *Store) ListDailySpend(since time.Time) ([]DailySpend, error) {
+	rows, err := s.db.Query(
+		`SELECT day, model, SUM(input_tokens), SUM(output_tokens), SUM(cost_usd) AS cost FROM usage_daily
+		 WHERE day >= ? GROUP BY day, model ORDER BY day DESC, cost DESC, model`,
+		since.Local().Format(SpendDayLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []DailySpend
+	for rows.Next() {
+		var d DailySpend
+		if err := rows.Scan(&d.Day, &d.Model, &d.InputTokens, &d.OutputTokens, &d.CostUSD); err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// Groupings for UsageSummary.
+const (
+	UsageByDay     = "day"
+	UsageByModel   = "model"
+	UsageByProject = "project"
+)
+
+// UsageRow is the token usage and estimated spend for one day, model, or
+// project, depending on the grouping.
+type UsageRow struct {
+	Key              string  `json:"key"`
+	InputTokens      int     `json:"input_tokens"`
+	OutputTokens     int     `json:"output_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// UsageSummary aggregates usage from the local day of since onwards by
+// groupBy (UsageByDay, UsageByModel, or UsageByProject). Days are listed
+// newest first; models and projects by cost, highest first.
+func (s *Store) UsageSummary(since time.Time, groupBy string) ([]UsageRow, error) {
+	var col, order string
+	switch groupBy {
+	case UsageByDay:
+		col, order = "day", "day DESC"
+	case UsageByModel:
+		col, order = "model", "cost DESC, model"
+	case UsageByProject:
+		col, order = "project_path", "cost DESC, project_path"
+	default:
+		return nil, fmt.Errorf("unknown usage grouping %q", groupBy)
+	}
+	rows, err := s.db.Query(
+		`SELECT `+col+`, SUM(input_tokens), SUM(output_tokens), SUM(cache_write_tokens), SUM(cache_read_tokens), SUM(cost_usd) AS cost
+		 FROM usage_daily WHERE day >= ? GROUP BY `+col+` ORDER BY `+order,
+		since.Local().Format(SpendDayLayout))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UsageRow
+	for rows.Next() {
+		var u UsageRow
+		if err := rows.Scan(&u.Key, &u.InputTokens, &u.OutputTokens, &u.CacheWriteTokens, &u.CacheReadTokens, &u.CostUSD); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// CallStats counts the activity behind usage: sessions started, user
+// turns, model calls (assistant messages) and how many of those called
+// tools, and compactions.
+type CallStats struct {
+	Sessions    int `json:"sessions"`
+	Turns       int `json:"turns"`
+	Calls       int `json:"calls"`
+	ToolCalls   int `json:"tool_calls"`
+	Compactions int `json:"compactions"`
+}
+
+// CallStatsSince returns the CallStats for messages, sessions, and
+// compactions created at or after since. Tool results, which are stored
+// as user messages, do not count as turns.
+func (s *Store) CallStatsSince(since time.Time) (CallStats, error) {
+	at := since.UTC().Format("2006-01-02 15:04:05")
+	var c CallStats
+	err := s.db.QueryRow(
+		`SELECT
+		   COALESCE(SUM(role = 'user' AND content NOT LIKE '%"type":"tool_result"%'), 0),
+		   COALESCE(SUM(role = 'assistant'), 0),
+		   COALESCE(SUM(role = 'assistant' AND content LIKE '%"type":"tool_use"%'), 0)
+		 FROM messages WHERE created_at >= ?`, at).Scan(&c.Turns, &c.Calls, &c.ToolCalls)
+	if err != nil {
+		return c, err
+	}
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE created_at >= ?`, at).Scan(&c.Sessions); err != nil {
+		return c, err
+	}
+	err = s.db.QueryRow(`SELECT COUNT(*) FROM compactions WHERE created_at >= ?`, at).Scan(&c.Compactions)
+	return c, err
+}
+
+// ---------------------------------------------------------------------------
+// Branching
+// ---------------------------------------------------------------------------
+
+// BranchSession creates a new session forked from fromSessionID, copying
+// messages up to atSequence. If atSequence <= 0, all messages are copied.
+func (s *Store) BranchSession(fromSessionID string, atSequence int) (*domain.Session, error) {
+	src, err := s.GetSession(fromSessionID)
+	if err != nil {
+		return nil, fmt.Errorf("source session: %w", err)
+	}
+
+	if atSequence <= 0 {
+		maxSeq, seqErr := s.MessageMaxSequence(fromSessionID)
+		if seqErr != nil {
+			return nil, fmt.Errorf("max sequence: %w", seqErr)
+		}
+		atSequence = maxSeq
+	}
+
+	newID := domain.NewUUID()
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Insert new session with parent reference.
+	_, err = tx.Exec(
+		`INSERT INTO sessions (id, project_path, title, model, total_tokens, input_tokens, output_tokens, message_count, parent_session_id, branch_point, tags, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, datetime(?), datetime(?))`,
+		newID, src.ProjectPath, src.Title+" (branch)", src.Model,
+		0, 0, 0,
+		fromSessionID, atSequence, src.Tags,
+		now, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("insert session: %w", err)
+	}
+
+	// Copy messages up to atSequence with new UUIDs and renumbered sequence.
+	rows, err := tx.Query(
+		`SELECT role, content, COALESCE(content_type, 'text'), tokens, sequence FROM messages
+		 WHERE session_id = ? AND sequence <= ? ORDER BY sequence`,
+		fromSessionID, atSequence)
+	if err != nil {
+		return nil, fmt.Errorf("query messages: %w", err)
 	}
 
 	var count int
@@ -785,6 +2582,57 @@ func (s *Store) BranchSession(fromSessionID string, atSequence int) (*domain.Ses
 // Helpers
 // ---------------------------------------------------------------------------
 
+// ProjectPaths returns the distinct project paths that have sessions.
+func (s *Store) ProjectPaths() ([]string, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT project_path FROM sessions WHERE project_path != '' ORDER BY project_path`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var paths []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		paths = append(paths, p)
+	}
+	return paths, rows.Err()
+}
+
+// Snapshot writes a consistent copy of the database to path using SQLite's
+// online backup API, so it is safe while the daemon is writing.
+func (s *Store) Snapshot(path string) error {
+	conn, err := s.db.Conn(context.Background())
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	defer conn.Close()
+	err = conn.Raw(func(driverConn any) error {
+		src, ok := driverConn.(interface {
+			NewBackup(string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return fmt.Errorf("driver does not support backups")
+		}
+		bck, err := src.NewBackup(path)
+		if err != nil {
+			return err
+		}
+		for more := true; more; {
+			if more, err = bck.Step(-1); err != nil {
+				_ = bck.Finish()
+				return err
+			}
+		}
+		return bck.Finish()
+	})
+	if err != nil {
+		return fmt.Errorf("snapshot: %w", err)
+	}
+	return nil
+}
+
 // SessionTitle returns the title for a session, or "Unknown" if not found.
 func (s *Store) SessionTitle(id string) string {
 	var title string
@@ -798,7 +2646,7 @@ func (s *Store) SessionTitle(id string) string {
 // FindSessionByPrefix matches a session by ID prefix (at least 4 chars).
 func (s *Store) FindSessionByPrefix(prefix string) (*domain.Session, error) {
 	row := s.db.QueryRow(
-		`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), created_at, updated_at
+		`SELECT id, project_path, title, model, total_tokens, COALESCE(input_tokens,0), COALESCE(output_tokens,0), message_count, COALESCE(parent_session_id,''), COALESCE(branch_point,0), COALESCE(tags,''), interrupted_prompt, cwd, created_at, updated_at
 		 FROM sessions WHERE id LIKE ? || '%' ORDER BY updated_at DESC LIMIT 1`, prefix)
 	return scanSession(row)
 }
@@ -809,7 +2657,7 @@ func scanSession(row *sql.Row) (*domain.Session, error) {
 	err := row.Scan(&sess.ID, &sess.ProjectPath, &sess.Title, &sess.Model,
 		&sess.TotalTokens, &sess.InputTokens, &sess.OutputTokens,
 		&sess.MessageCount, &sess.ParentSessionID, &sess.BranchPoint,
-		&sess.Tags, &createdStr, &updatedStr)
+		&sess.Tags, &sess.InterruptedPrompt, &sess.Cwd, &createdStr, &updatedStr)
 	if err != nil {
 		return nil, err
 	}
@@ -821,3 +2669,21 @@ func scanSession(row *sql.Row) (*domain.Session, error) {
 	}
 	return &sess, nil
 }
+
+// SessionIDs returns the IDs of every session.
+func (s *Store) SessionIDs() ([]string, error) {
+	rows, err := s.db.Query(`SELECT id FROM sessions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -2,7 +2,11 @@ package store
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -28,6 +32,44 @@ func testStore(t *testing.T) *Store {
 	return s
 }
 
+func TestOpenStoreForProject(t *testing.T) {
+	root := t.TempDir()
+	s, err := OpenStoreForProject(root)
+	if err != nil {
+		t.Fatalf("OpenStoreForProject: %v", err)
+	}
+	sess, err := s.CreateSession(root, "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	s.Close()
+
+	if _, err := os.Stat(filepath.Join(root, ProjectDBDir, "muxd.db")); err != nil {
+		t.Fatalf("expected database inside the project: %v", err)
+	}
+	s, err = OpenStoreForProject(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.GetSession(sess.ID); err != nil {
+		t.Errorf("session not persisted: %v", err)
+	}
+}
+
+func TestStore_ProjectDatabases(t *testing.T) {
+	s := testStore(t)
+	for _, root := range []string{"/b", "/a", "/b"} {
+		if err := s.AddProjectDatabase(root); err != nil {
+			t.Fatalf("AddProjectDatabase: %v", err)
+		}
+	}
+	roots, err := s.ProjectDatabases()
+	if err != nil || !slices.Equal(roots, []string{"/a", "/b"}) {
+		t.Errorf("ProjectDatabases = %v, %v", roots, err)
+	}
+}
+
 func TestStore_CreateSession(t *testing.T) {
 	s := testStore(t)
 
@@ -360,6 +402,56 @@ func TestStore_AppendMessageBlocks(t *testing.T) {
 	})
 }
 
+func TestStore_AppendTurnMessages(t *testing.T) {
+	s := testStore(t)
+	sess, err := s.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.AppendMessage(sess.ID, "user", "list files", 0); err != nil {
+		t.Fatalf("AppendMessage: %v", err)
+	}
+
+	err = s.AppendTurnMessages(sess.ID, "turn-1", []TurnMessage{
+		{Role: "assistant", Blocks: []domain.ContentBlock{{Type: "tool_use", ToolUseID: "t1", ToolName: "list_files"}}, Tokens: 12},
+		{Role: "user", Blocks: []domain.ContentBlock{{Type: "tool_result", ToolUseID: "t1", ToolResult: "a.go"}}},
+		{Role: "assistant", Content: "One file."},
+	})
+	if err != nil {
+		t.Fatalf("AppendTurnMessages: %v", err)
+	}
+	records, err := s.GetMessageRecords(sess.ID)
+	if err != nil {
+		t.Fatalf("GetMessageRecords: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("got %d records, want 4", len(records))
+	}
+	for i, r := range records {
+		wantTurn := "turn-1"
+		if i == 0 {
+			wantTurn = ""
+		}
+		if r.Sequence != i+1 || r.TurnID != wantTurn {
+			t.Errorf("record %d: sequence = %d, turn = %q", i, r.Sequence, r.TurnID)
+		}
+	}
+	if records[1].Tokens != 12 || len(records[1].Blocks) != 1 || records[3].Content != "One file." {
+		t.Errorf("records = %+v", records)
+	}
+	if got, _ := s.GetSession(sess.ID); got.MessageCount != 4 {
+		t.Errorf("MessageCount = %d, want 4", got.MessageCount)
+	}
+
+	// A failed write leaves none of the turn's messages behind.
+	if err := s.AppendTurnMessages("no-such-session", "turn-2", []TurnMessage{{Role: "user", Content: "x"}}); err == nil {
+		t.Fatal("AppendTurnMessages to a missing session succeeded")
+	}
+	if msgs, _ := s.GetMessages("no-such-session"); len(msgs) != 0 {
+		t.Errorf("messages written despite the error: %+v", msgs)
+	}
+}
+
 func TestStore_GetMessages_empty(t *testing.T) {
 	s := testStore(t)
 
@@ -379,6 +471,55 @@ func TestStore_GetMessages_empty(t *testing.T) {
 	})
 }
 
+func TestStore_GetMessagesPage(t *testing.T) {
+	s := testStore(t)
+
+	sess, err := s.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := s.AppendMessage(sess.ID, "user", fmt.Sprintf("m%d", i), 1); err != nil {
+			t.Fatalf("AppendMessage: %v", err)
+		}
+	}
+
+	t.Run("counts messages", func(t *testing.T) {
+		n, err := s.CountMessages(sess.ID)
+		if err != nil || n != 5 {
+			t.Errorf("CountMessages = %d, %v; want 5", n, err)
+		}
+	})
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []string
+	}{
+		{"first page", 0, 2, []string{"m0", "m1"}},
+		{"middle page", 2, 2, []string{"m2", "m3"}},
+		{"short last page", 4, 2, []string{"m4"}},
+		{"past the end", 9, 2, nil},
+		{"no limit", 3, 0, []string{"m3", "m4"}},
+		{"negative offset", -1, 1, []string{"m0"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := s.GetMessagesPage(sess.ID, tt.offset, tt.limit)
+			if err != nil {
+				t.Fatalf("GetMessagesPage: %v", err)
+			}
+			var got []string
+			for _, m := range msgs {
+				got = append(got, m.Content)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestStore_SessionTitle(t *testing.T) {
 	s := testStore(t)
 
@@ -495,6 +636,100 @@ func TestStore_NewFieldDefaults(t *testing.T) {
 	})
 }
 
+func TestStore_SetSessionParent(t *testing.T) {
+	s := testStore(t)
+
+	parent, err := s.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	child, err := s.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.SetSessionParent(child.ID, parent.ID); err != nil {
+		t.Fatalf("SetSessionParent: %v", err)
+	}
+	got, err := s.GetSession(child.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.ParentSessionID != parent.ID {
+		t.Errorf("ParentSessionID = %q, want %q", got.ParentSessionID, parent.ID)
+	}
+}
+
+func TestStore_TurnState(t *testing.T) {
+	s := testStore(t)
+
+	sess, err := s.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.SetTurnPID(sess.ID, 4242); err != nil {
+		t.Fatalf("SetTurnPID: %v", err)
+	}
+	turns, err := s.RunningTurns()
+	if err != nil {
+		t.Fatalf("RunningTurns: %v", err)
+	}
+	if len(turns) != 1 || turns[sess.ID] != 4242 {
+		t.Fatalf("RunningTurns = %v", turns)
+	}
+
+	if err := s.SetInterruptedTurn(sess.ID, "fix the parser"); err != nil {
+		t.Fatalf("SetInterruptedTurn: %v", err)
+	}
+	if turns, _ := s.RunningTurns(); len(turns) != 0 {
+		t.Errorf("RunningTurns after interruption = %v", turns)
+	}
+	got, err := s.GetSession(sess.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.InterruptedPrompt != "fix the parser" {
+		t.Errorf("InterruptedPrompt = %q", got.InterruptedPrompt)
+	}
+
+	// The next turn clears the interrupted prompt.
+	if err := s.SetTurnPID(sess.ID, 4243); err != nil {
+		t.Fatalf("SetTurnPID: %v", err)
+	}
+	if err := s.SetTurnPID(sess.ID, 0); err != nil {
+		t.Fatalf("SetTurnPID: %v", err)
+	}
+	got, _ = s.GetSession(sess.ID)
+	if got.InterruptedPrompt != "" {
+		t.Errorf("InterruptedPrompt after next turn = %q", got.InterruptedPrompt)
+	}
+	if turns, _ := s.RunningTurns(); len(turns) != 0 {
+		t.Errorf("RunningTurns after turn end = %v", turns)
+	}
+}
+
+func TestStore_SetSessionCwd(t *testing.T) {
+	s := testStore(t)
+
+	sess, err := s.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	if err := s.SetSessionCwd(sess.ID, "/srv/app"); err != nil {
+		t.Fatalf("SetSessionCwd: %v", err)
+	}
+	got, err := s.GetSession(sess.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got.Cwd != "/srv/app" {
+		t.Errorf("Cwd = %q", got.Cwd)
+	}
+	list, err := s.ListSessions("/tmp", 10)
+	if err != nil || len(list) != 1 || list[0].Cwd != "/srv/app" {
+		t.Errorf("ListSessions = %+v, %v", list, err)
+	}
+}
+
 func TestStore_UpdateSessionTags(t *testing.T) {
 	s := testStore(t)
 
@@ -882,6 +1117,65 @@ func TestStore_ScheduledToolJobs(t *testing.T) {
 	}
 }
 
+func TestStore_ScheduledToolJobRetries(t *testing.T) {
+	s := testStore(t)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	due := now.Add(-time.Minute)
+	id, err := s.CreateScheduledToolJob("sms_send", map[string]any{"text": "hello"}, due, "daily")
+	if err != nil {
+		t.Fatalf("CreateScheduledToolJob: %v", err)
+	}
+
+	retryAt := now.Add(time.Minute)
+	if err := s.RetryScheduledToolJob(id, "boom", "", now, retryAt); err != nil {
+		t.Fatalf("RetryScheduledToolJob: %v", err)
+	}
+	if items, _ := s.DueScheduledToolJobs(now, 10); len(items) != 0 {
+		t.Fatalf("expected no due jobs before the retry time, got %d", len(items))
+	}
+	items, err := s.DueScheduledToolJobs(retryAt, 10)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("DueScheduledToolJobs at retry time = %d, %v", len(items), err)
+	}
+	job := items[0]
+	if job.Status != "pending" || job.RetryCount != 1 || job.RetryAt == nil || !job.RetryAt.Equal(retryAt) || !job.ScheduledFor.Equal(due) {
+		t.Errorf("unexpected job after retry: %+v", job)
+	}
+
+	if err := s.MarkScheduledToolJobSucceeded(id, "ok", retryAt); err != nil {
+		t.Fatalf("MarkScheduledToolJobSucceeded: %v", err)
+	}
+	if err := s.RescheduleScheduledToolJob(id, due.Add(24*time.Hour)); err != nil {
+		t.Fatalf("RescheduleScheduledToolJob: %v", err)
+	}
+	if err := s.SkipScheduledToolJob(id, "missed", retryAt); err != nil {
+		t.Fatalf("SkipScheduledToolJob: %v", err)
+	}
+	items, _ = s.ListScheduledToolJobs(10)
+	if items[0].Status != "skipped" || items[0].RetryCount != 0 || items[0].RetryAt != nil || items[0].AttemptCount != 2 {
+		t.Errorf("unexpected job after skip: %+v", items[0])
+	}
+
+	attempts, err := s.ScheduledToolJobAttempts(id, 10)
+	if err != nil {
+		t.Fatalf("ScheduledToolJobAttempts: %v", err)
+	}
+	var statuses []string
+	for _, a := range attempts {
+		statuses = append(statuses, a.Status)
+	}
+	if got := strings.Join(statuses, ","); got != "skipped,succeeded,failed" {
+		t.Errorf("attempt statuses = %s, want newest first", got)
+	}
+	if attempts[2].Error != "boom" || !attempts[2].AttemptedAt.Equal(now) {
+		t.Errorf("unexpected failed attempt: %+v", attempts[2])
+	}
+	if limited, _ := s.ScheduledToolJobAttempts(id, 1); len(limited) != 1 || limited[0].Status != "skipped" {
+		t.Errorf("limited attempts = %+v", limited)
+	}
+}
+
 func TestStore_UpdateScheduledToolJob(t *testing.T) {
 	s := testStore(t)
 
@@ -1289,6 +1583,71 @@ func TestStore_CreateScheduledToolJob_normalizesName(t *testing.T) {
 	t.Fatal("job not found")
 }
 
+func TestStore_Jobs(t *testing.T) {
+	s := testStore(t)
+	first, err := s.CreateJob("summarize the changelog", []string{"file_read"})
+	if err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+	if first.Status != JobQueued || len(first.Tools) != 1 || first.StartedAt != nil {
+		t.Errorf("new job = %+v", first)
+	}
+	second, _ := s.CreateJob("run the tests", nil)
+	third, _ := s.CreateJob("never mind", nil)
+
+	if ok, err := s.CancelQueuedJob(third.ID); err != nil || !ok {
+		t.Fatalf("CancelQueuedJob = %v, %v", ok, err)
+	}
+
+	// Jobs run oldest first and skip cancelled ones.
+	claimed, err := s.ClaimJob()
+	if err != nil || claimed == nil || claimed.ID != first.ID || claimed.Status != JobRunning || claimed.StartedAt == nil {
+		t.Fatalf("ClaimJob = %+v, %v", claimed, err)
+	}
+	if ok, _ := s.CancelQueuedJob(first.ID); ok {
+		t.Error("cancelled a running job as queued")
+	}
+	_ = s.SetJobSession(first.ID, "sess-1")
+	_ = s.AppendJobLog(first.ID, "tool file_read\n")
+	_ = s.AppendJobLog(first.ID, "done\n")
+	if err := s.FinishJob(first.ID, JobSucceeded, "All good.", ""); err != nil {
+		t.Fatalf("FinishJob: %v", err)
+	}
+	got, err := s.GetJob(first.ID)
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if got.Status != JobSucceeded || got.Result != "All good." || got.SessionID != "sess-1" || got.Log != "tool file_read\ndone\n" || got.FinishedAt == nil {
+		t.Errorf("finished job = %+v", got)
+	}
+	// A finished job stays finished.
+	_ = s.FinishJob(first.ID, JobFailed, "", "late")
+	if got, _ := s.GetJob(first.ID); got.Status != JobSucceeded {
+		t.Errorf("status = %s after a second finish", got.Status)
+	}
+
+	if _, err := s.ClaimJob(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.FailInterruptedJobs(); err != nil || n != 1 {
+		t.Errorf("FailInterruptedJobs = %d, %v", n, err)
+	}
+	if got, _ := s.GetJob(second.ID); got.Status != JobFailed || !strings.Contains(got.Error, "interrupted") {
+		t.Errorf("interrupted job = %+v", got)
+	}
+	if next, err := s.ClaimJob(); err != nil || next != nil {
+		t.Errorf("ClaimJob on an empty queue = %+v, %v", next, err)
+	}
+
+	jobs, err := s.ListJobs(10)
+	if err != nil || len(jobs) != 3 || jobs[0].ID != third.ID || jobs[0].Log != "" {
+		t.Errorf("ListJobs = %+v, %v", jobs, err)
+	}
+	if _, err := s.GetJob("missing"); err != sql.ErrNoRows {
+		t.Errorf("GetJob(missing) error = %v", err)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // ListSessions with negative limit
 // ---------------------------------------------------------------------------
@@ -1307,3 +1666,621 @@ func TestStore_ListSessions_negativeLimit(t *testing.T) {
 		t.Errorf("expected 1 session, got %d", len(sessions))
 	}
 }
+
+func TestStore_RateMessage(t *testing.T) {
+	s := testStore(t)
+	sess, _ := s.CreateSession("/tmp/rate", "m")
+	_ = s.AppendMessage(sess.ID, "user", "q1", 0)
+	_ = s.AppendMessage(sess.ID, "assistant", "a1", 0)
+	_ = s.AppendMessage(sess.ID, "user", "q2", 0)
+	_ = s.AppendMessage(sess.ID, "assistant", "a2", 0)
+
+	t.Run("latest assistant message", func(t *testing.T) {
+		seq, err := s.RateMessage(sess.ID, 0, RatingBad, "  wrong file  ")
+		if err != nil {
+			t.Fatalf("RateMessage: %v", err)
+		}
+		if seq != 4 {
+			t.Errorf("rated sequence %d, want 4", seq)
+		}
+	})
+
+	t.Run("explicit sequence", func(t *testing.T) {
+		if _, err := s.RateMessage(sess.ID, 2, RatingGood, ""); err != nil {
+			t.Fatalf("RateMessage: %v", err)
+		}
+	})
+
+	t.Run("rejects user messages", func(t *testing.T) {
+		if _, err := s.RateMessage(sess.ID, 1, RatingGood, ""); err == nil {
+			t.Error("expected error rating a user message")
+		}
+	})
+
+	good, bad, err := s.SessionFeedback(sess.ID)
+	if err != nil || good != 1 || bad != 1 {
+		t.Errorf("SessionFeedback = %d, %d, %v; want 1, 1", good, bad, err)
+	}
+
+	sum, err := s.FeedbackSummary("/tmp/rate", time.Time{})
+	if err != nil {
+		t.Fatalf("FeedbackSummary: %v", err)
+	}
+	if sum.Turns != 2 || sum.Good != 1 || sum.Bad != 1 {
+		t.Errorf("summary = %+v", sum)
+	}
+	if len(sum.RecentNotes) != 1 || sum.RecentNotes[0].Note != "wrong file" || sum.RecentNotes[0].Rating != RatingBad {
+		t.Errorf("notes = %+v", sum.RecentNotes)
+	}
+
+	if other, _ := s.FeedbackSummary("/tmp/other", time.Time{}); other.Turns != 0 {
+		t.Errorf("other project summary = %+v", other)
+	}
+}
+
+func TestStore_AnnotateAndDeleteMessage(t *testing.T) {
+	s := testStore(t)
+	sess, _ := s.CreateSession("/tmp/annotate", "m")
+	for _, c := range []string{"q1", "a1", "q2", "a2"} {
+		role := "user"
+		if c[0] == 'a' {
+			role = "assistant"
+		}
+		_ = s.AppendMessage(sess.ID, role, c, 0)
+	}
+	if err := s.SaveCompaction(sess.ID, "summary", 3); err != nil {
+		t.Fatalf("SaveCompaction: %v", err)
+	}
+
+	if err := s.AnnotateMessage(sess.ID, 4, "  check this  "); err != nil {
+		t.Fatalf("AnnotateMessage: %v", err)
+	}
+	if err := s.AnnotateMessage(sess.ID, 9, "x"); err == nil {
+		t.Error("expected error annotating a missing message")
+	}
+	notes, err := s.MessageAnnotations(sess.ID)
+	if err != nil || len(notes) != 1 || notes[4] != "check this" {
+		t.Errorf("MessageAnnotations = %v, %v", notes, err)
+	}
+
+	if err := s.DeleteMessage(sess.ID, 2); err != nil {
+		t.Fatalf("DeleteMessage: %v", err)
+	}
+	if err := s.DeleteMessage(sess.ID, 9); err == nil {
+		t.Error("expected error deleting a missing message")
+	}
+	msgs, _ := s.GetMessages(sess.ID)
+	if len(msgs) != 3 || msgs[1].Content != "q2" {
+		t.Fatalf("messages after delete = %+v", msgs)
+	}
+	if max, _ := s.MessageMaxSequence(sess.ID); max != 3 {
+		t.Errorf("max sequence = %d, want 3", max)
+	}
+	if notes, _ := s.MessageAnnotations(sess.ID); notes[3] != "check this" {
+		t.Errorf("annotation did not move with its message: %v", notes)
+	}
+	if _, cutoff, _ := s.LatestCompaction(sess.ID); cutoff != 2 {
+		t.Errorf("compaction cutoff = %d, want 2", cutoff)
+	}
+	if got, _ := s.GetSession(sess.ID); got.MessageCount != 3 {
+		t.Errorf("message_count = %d, want 3", got.MessageCount)
+	}
+	recs, _ := s.GetMessageRecords(sess.ID)
+	if recs[2].Annotation != "check this" {
+		t.Errorf("record annotation = %q", recs[2].Annotation)
+	}
+}
+
+func TestStore_RateMessage_noAssistant(t *testing.T) {
+	s := testStore(t)
+	sess, _ := s.CreateSession("/tmp", "m")
+	if _, err := s.RateMessage(sess.ID, 0, RatingGood, ""); err == nil {
+		t.Error("expected error when there is nothing to rate")
+	}
+}
+
+func TestParseRating(t *testing.T) {
+	for in, want := range map[string]int{"good": RatingGood, "Bad": RatingBad, "+1": RatingGood, "clear": RatingNone} {
+		if got, err := ParseRating(in); err != nil || got != want {
+			t.Errorf("ParseRating(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	if _, err := ParseRating("meh"); err == nil {
+		t.Error("expected error for unknown rating")
+	}
+}
+
+func TestStore_Checkpoints(t *testing.T) {
+	s := testStore(t)
+	sess, err := s.CreateSession("/tmp", "model")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+
+	var ids []int64
+	for turn, sha := range []string{"", "aaa", "bbb"} {
+		id, err := s.AddCheckpoint(Checkpoint{SessionID: sess.ID, Turn: turn + 1, Dir: "/repo", SHA: sha})
+		if err != nil {
+			t.Fatalf("AddCheckpoint: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := s.SetCheckpointUndone(ids[2], true, "ccc"); err != nil {
+		t.Fatalf("SetCheckpointUndone: %v", err)
+	}
+	if err := s.SetCheckpointUndone(ids[1], true, "ddd"); err != nil {
+		t.Fatalf("SetCheckpointUndone: %v", err)
+	}
+	if err := s.SetCheckpointUndone(ids[1], false, "ignored"); err != nil {
+		t.Fatalf("SetCheckpointUndone: %v", err)
+	}
+
+	cps, err := s.ListCheckpoints(sess.ID)
+	if err != nil {
+		t.Fatalf("ListCheckpoints: %v", err)
+	}
+	if len(cps) != 3 || cps[0].Turn != 1 || cps[1].SHA != "aaa" || cps[0].Dir != "/repo" {
+		t.Fatalf("checkpoints = %+v", cps)
+	}
+	if cps[1].Undone || cps[1].RedoSHA != "" {
+		t.Errorf("redone checkpoint = %+v", cps[1])
+	}
+	if !cps[2].Undone || cps[2].RedoSHA != "ccc" {
+		t.Errorf("undone checkpoint = %+v", cps[2])
+	}
+	if i := FirstUndone(cps); i != 2 {
+		t.Errorf("FirstUndone = %d, want 2", i)
+	}
+
+	// A new checkpoint ends the redo history.
+	if _, err := s.AddCheckpoint(Checkpoint{SessionID: sess.ID, Label: "before refactor", Dir: "/repo"}); err != nil {
+		t.Fatalf("AddCheckpoint: %v", err)
+	}
+	cps, _ = s.ListCheckpoints(sess.ID)
+	if len(cps) != 3 || cps[2].Label != "before refactor" || cps[2].Undone {
+		t.Errorf("after new checkpoint = %+v", cps)
+	}
+	if i := FirstUndone(cps); i != 3 {
+		t.Errorf("FirstUndone with nothing undone = %d, want 3", i)
+	}
+}
+
+func TestStore_Postmortems(t *testing.T) {
+	s := testStore(t)
+	a, _ := s.CreateSession("/tmp/pm", "m")
+	b, _ := s.CreateSession("/tmp/pm", "m")
+	other, _ := s.CreateSession("/tmp/elsewhere", "m")
+
+	for _, p := range []Postmortem{
+		{SessionID: a.ID, Category: "Build failure", Diagnosis: "go build failed on a missing import", ToolFailures: 3, FailedTools: "bash"},
+		{SessionID: b.ID, Category: "build failure", Diagnosis: "tests did not compile", ToolFailures: 2, FailedTools: "bash"},
+		{SessionID: b.ID, Category: "patch mismatch", Diagnosis: "file_edit old_string not found", ToolFailures: 4, FailedTools: "file_edit"},
+		{SessionID: other.ID, Category: "network", Diagnosis: "fetch timed out", ToolFailures: 2, FailedTools: "web_fetch"},
+	} {
+		if err := s.SavePostmortem(p); err != nil {
+			t.Fatalf("SavePostmortem: %v", err)
+		}
+	}
+
+	list, err := s.ListPostmortems(b.ID)
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListPostmortems = %d, %v; want 2", len(list), err)
+	}
+
+	patterns, err := s.FailurePatterns("/tmp/pm", time.Time{}, 0)
+	if err != nil {
+		t.Fatalf("FailurePatterns: %v", err)
+	}
+	if len(patterns) != 2 {
+		t.Fatalf("got %d patterns, want 2: %+v", len(patterns), patterns)
+	}
+	if patterns[0].Category != "build failure" || patterns[0].Count != 2 || patterns[0].Sessions != 2 {
+		t.Errorf("unexpected top pattern: %+v", patterns[0])
+	}
+
+	all, _ := s.FailurePatterns("", time.Time{}, 0)
+	if len(all) != 3 {
+		t.Errorf("all-project patterns = %d, want 3", len(all))
+	}
+}
+
+func TestStore_DraftToolJobs(t *testing.T) {
+	s := testStore(t)
+	past := time.Now().Add(-time.Minute)
+
+	draftID, err := s.CreateDraftToolJob("sms_send", map[string]any{"message": "launch"}, past, "once")
+	if err != nil {
+		t.Fatalf("CreateDraftToolJob: %v", err)
+	}
+	otherID, _ := s.CreateDraftToolJob("sms_send", map[string]any{"message": "spam"}, past, "once")
+
+	due, _ := s.DueScheduledToolJobs(time.Now(), 10)
+	if len(due) != 0 {
+		t.Fatalf("drafts must not be due, got %d jobs", len(due))
+	}
+	drafts, err := s.ListDraftToolJobs(10)
+	if err != nil || len(drafts) != 2 {
+		t.Fatalf("ListDraftToolJobs = %d, %v; want 2", len(drafts), err)
+	}
+
+	if _, err := s.ApproveDraftToolJob("zzzz"); err == nil {
+		t.Error("expected error for unknown draft")
+	}
+	if _, err := s.ApproveDraftToolJob(""); err == nil {
+		t.Error("expected error for empty id")
+	}
+	id, err := s.ApproveDraftToolJob(draftID[:8])
+	if err != nil || id != draftID {
+		t.Fatalf("ApproveDraftToolJob = %q, %v; want %q", id, err, draftID)
+	}
+	if _, err := s.ApproveDraftToolJob(draftID); err == nil {
+		t.Error("expected error approving a draft twice")
+	}
+	if _, err := s.RejectDraftToolJob(otherID); err != nil {
+		t.Fatalf("RejectDraftToolJob: %v", err)
+	}
+
+	due, _ = s.DueScheduledToolJobs(time.Now(), 10)
+	if len(due) != 1 || due[0].ID != draftID {
+		t.Fatalf("expected only the approved draft to be due, got %+v", due)
+	}
+	if drafts, _ := s.ListDraftToolJobs(10); len(drafts) != 0 {
+		t.Errorf("expected no drafts left, got %d", len(drafts))
+	}
+}
+
+func TestStore_AuditLog(t *testing.T) {
+	s := testStore(t)
+	for _, e := range []AuditEntry{
+		{SessionID: "a", Tool: "sms_send", Decision: "pii_masked", Detail: "1 email"},
+		{SessionID: "b", Tool: "sms_send", Decision: "pii_declined", Detail: "1 phone"},
+		{SessionID: "a", Tool: "sms_send", Decision: "pii_confirmed", Detail: "1 phone"},
+	} {
+		if err := s.RecordAudit(e); err != nil {
+			t.Fatalf("RecordAudit: %v", err)
+		}
+	}
+	all, err := s.ListAuditEntries("", 0)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("ListAuditEntries = %d, %v; want 3", len(all), err)
+	}
+	if all[0].Decision != "pii_confirmed" {
+		t.Errorf("expected newest first, got %q", all[0].Decision)
+	}
+	onlyA, _ := s.ListAuditEntries("a", 1)
+	if len(onlyA) != 1 || onlyA[0].SessionID != "a" {
+		t.Errorf("unexpected filtered entries: %+v", onlyA)
+	}
+}
+
+func TestStore_APITokens(t *testing.T) {
+	s := testStore(t)
+	tok, err := s.CreateAPIToken("dashboard", "READ", "secret-1")
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	if tok.Scope != TokenScopeRead {
+		t.Errorf("scope = %q, want read", tok.Scope)
+	}
+	if _, err := s.CreateAPIToken("x", "owner", "secret-2"); err == nil {
+		t.Error("expected invalid scope error")
+	}
+
+	got, err := s.LookupAPIToken("secret-1")
+	if err != nil || got == nil || got.ID != tok.ID || got.Name != "dashboard" {
+		t.Fatalf("LookupAPIToken = %+v, %v", got, err)
+	}
+	if miss, err := s.LookupAPIToken("nope"); err != nil || miss != nil {
+		t.Errorf("unknown token = %+v, %v; want nil", miss, err)
+	}
+
+	list, err := s.ListAPITokens()
+	if err != nil || len(list) != 1 || list[0].LastUsedAt == nil {
+		t.Fatalf("ListAPITokens = %+v, %v", list, err)
+	}
+
+	// A recent use is not written again; a stale one is.
+	if _, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = datetime('now', '-30 seconds')`); err != nil {
+		t.Fatal(err)
+	}
+	recent, _ := s.ListAPITokens()
+	if got, _ := s.LookupAPIToken("secret-1"); got == nil || !got.LastUsedAt.Equal(*recent[0].LastUsedAt) {
+		t.Errorf("recent use rewritten: %+v, want %v", got, recent[0].LastUsedAt)
+	}
+	if list, _ := s.ListAPITokens(); !list[0].LastUsedAt.Equal(*recent[0].LastUsedAt) {
+		t.Errorf("last_used_at = %v, want %v", list[0].LastUsedAt, recent[0].LastUsedAt)
+	}
+	if _, err := s.db.Exec(`UPDATE api_tokens SET last_used_at = datetime('now', '-2 minutes')`); err != nil {
+		t.Fatal(err)
+	}
+	s.LookupAPIToken("secret-1")
+	if list, _ := s.ListAPITokens(); time.Since(*list[0].LastUsedAt) > 10*time.Second {
+		t.Errorf("stale use not recorded: last_used_at = %v", list[0].LastUsedAt)
+	}
+
+	if err := s.DeleteAPIToken(tok.ID[:8]); err != nil {
+		t.Fatalf("DeleteAPIToken: %v", err)
+	}
+	if got, _ := s.LookupAPIToken("secret-1"); got != nil {
+		t.Error("deleted token still resolves")
+	}
+	if err := s.DeleteAPIToken(tok.ID); err == nil {
+		t.Error("expected error deleting a missing token")
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	tests := []struct {
+		scope, required string
+		want            bool
+	}{
+		{TokenScopeAdmin, TokenScopeRead, true},
+		{TokenScopeSubmit, TokenScopeRead, true},
+		{TokenScopeSubmit, TokenScopeAdmin, false},
+		{TokenScopeRead, TokenScopeSubmit, false},
+		{"", TokenScopeRead, false},
+	}
+	for _, tt := range tests {
+		if got := ScopeAllows(tt.scope, tt.required); got != tt.want {
+			t.Errorf("ScopeAllows(%q, %q) = %v", tt.scope, tt.required, got)
+		}
+	}
+}
+
+func TestStore_SessionShares(t *testing.T) {
+	s := testStore(t)
+	sess, err := s.CreateSession("/tmp/p", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh, err := s.ShareSession(sess.ID, "tok-1")
+	if err != nil || sh.Token != "tok-1" {
+		t.Fatalf("ShareSession = %+v, %v", sh, err)
+	}
+	again, err := s.ShareSession(sess.ID, "tok-2")
+	if err != nil || again.Token != "tok-1" {
+		t.Errorf("sharing again = %+v, %v; want the existing link", again, err)
+	}
+	got, err := s.LookupShare("tok-1")
+	if err != nil || got == nil || got.SessionID != sess.ID {
+		t.Fatalf("LookupShare = %+v, %v", got, err)
+	}
+	if miss, err := s.LookupShare("tok-2"); err != nil || miss != nil {
+		t.Errorf("unknown share = %+v, %v; want nil", miss, err)
+	}
+
+	n, err := s.UnshareSession(sess.ID)
+	if err != nil || n != 1 {
+		t.Fatalf("UnshareSession = %d, %v", n, err)
+	}
+	if got, _ := s.LookupShare("tok-1"); got != nil {
+		t.Error("revoked share still resolves")
+	}
+}
+
+func TestStore_PushDevices(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.SavePushDevice("sms", "tok", "", false); err == nil {
+		t.Error("expected invalid platform error")
+	}
+	if _, err := s.SavePushDevice("apns", " ", "", false); err == nil {
+		t.Error("expected empty token error")
+	}
+
+	phone, err := s.SavePushDevice("APNs", "tok-phone", "iPhone", true)
+	if err != nil || phone.Platform != PushPlatformAPNs || !phone.Foreground {
+		t.Fatalf("SavePushDevice = %+v, %v", phone, err)
+	}
+	if _, err := s.SavePushDevice("fcm", "tok-tablet", "Pixel", false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Registering the same token again updates it in place.
+	again, err := s.SavePushDevice("apns", "tok-phone", "iPhone 16", false)
+	if err != nil || again.ID != phone.ID || again.Name != "iPhone 16" || again.Foreground {
+		t.Fatalf("re-register = %+v, %v; want the same device updated", again, err)
+	}
+	if list, _ := s.ListPushDevices(); len(list) != 2 {
+		t.Errorf("ListPushDevices = %d devices, want 2", len(list))
+	}
+	if _, err := s.SavePushDevice("apns", "tok-phone", "iPhone 16", true); err != nil {
+		t.Fatal(err)
+	}
+	bg, err := s.BackgroundPushDevices()
+	if err != nil || len(bg) != 1 || bg[0].Token != "tok-tablet" {
+		t.Fatalf("BackgroundPushDevices = %+v, %v; want only the tablet", bg, err)
+	}
+
+	if ok, err := s.DeletePushDevice(phone.ID); err != nil || !ok {
+		t.Errorf("DeletePushDevice = %v, %v", ok, err)
+	}
+	if ok, _ := s.DeletePushDevice(phone.ID); ok {
+		t.Error("deleting a missing device reported success")
+	}
+	if err := s.DeletePushDeviceToken("tok-tablet"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := s.ListPushDevices(); len(list) != 0 {
+		t.Errorf("devices left after deletes: %+v", list)
+	}
+}
+
+func TestStore_Webhooks(t *testing.T) {
+	s := testStore(t)
+	if _, err := s.CreateWebhook(" ", "", nil); err == nil {
+		t.Error("expected empty URL error")
+	}
+	all, err := s.CreateWebhook("https://example.com/all", "secret-1", nil)
+	if err != nil || all.Secret != "secret-1" || len(all.Events) != 0 {
+		t.Fatalf("CreateWebhook = %+v, %v", all, err)
+	}
+	turns, err := s.CreateWebhook("https://example.com/turns", "", []string{"turn_done", "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !all.Wants("tool_executed") || !turns.Wants("error") || turns.Wants("tool_executed") {
+		t.Error("Wants does not follow the subscribed events")
+	}
+
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := s.RecordWebhookDelivery(turns.ID, at, "HTTP 500: down"); err != nil {
+		t.Fatal(err)
+	}
+	list, err := s.ListWebhooks()
+	if err != nil || len(list) != 2 {
+		t.Fatalf("ListWebhooks = %+v, %v", list, err)
+	}
+	got := list[1]
+	if got.ID != turns.ID || got.LastDeliveryAt == nil || !got.LastDeliveryAt.Equal(at) || got.LastError != "HTTP 500: down" {
+		t.Errorf("after delivery = %+v", got)
+	}
+	if data, _ := json.Marshal(list[0]); strings.Contains(string(data), "secret-1") {
+		t.Errorf("secret leaked in JSON: %s", data)
+	}
+
+	if ok, err := s.DeleteWebhook(all.ID); err != nil || !ok {
+		t.Errorf("DeleteWebhook = %v, %v", ok, err)
+	}
+	if ok, _ := s.DeleteWebhook(all.ID); ok {
+		t.Error("deleting a missing webhook reported success")
+	}
+}
+
+func TestStore_Spend(t *testing.T) {
+	s := testStore(t)
+	sess, err := s.CreateSession("/tmp/p", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2026, 5, 1, 12, 0, 0, 0, time.Local)
+	for _, call := range []struct {
+		session, model string
+		at             time.Time
+		cost           float64
+	}{
+		{sess.ID, "opus", day, 1.5},
+		{sess.ID, "opus", day.Add(time.Hour), 0.5},
+		{"", "haiku", day, 0.25},
+		{sess.ID, "opus", day.AddDate(0, 0, 1), 1},
+	} {
+		if err := s.RecordSpend(call.session, call.model, call.at, TokenCounts{Input: 100, Output: 10, CacheRead: 50}, call.cost); err != nil {
+			t.Fatalf("RecordSpend: %v", err)
+		}
+	}
+
+	if got, err := s.SessionSpend(sess.ID); err != nil || got != 3 {
+		t.Errorf("SessionSpend = %v, %v; want 3", got, err)
+	}
+	if got, err := s.SessionSpend("missing"); err != nil || got != 0 {
+		t.Errorf("SessionSpend(missing) = %v, %v", got, err)
+	}
+	if got, err := s.DaySpend(day); err != nil || got != 2.25 {
+		t.Errorf("DaySpend = %v, %v; want 2.25", got, err)
+	}
+
+	rows, err := s.ListDailySpend(day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0].Day != "2026-05-02" || rows[1].Model != "opus" || rows[1].InputTokens != 200 {
+		t.Errorf("ListDailySpend = %+v", rows)
+	}
+
+	byProject, err := s.UsageSummary(day, UsageByProject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byProject) != 2 || byProject[0].Key != "/tmp/p" || byProject[0].CostUSD != 3 || byProject[0].CacheReadTokens != 150 {
+		t.Errorf("UsageSummary(project) = %+v", byProject)
+	}
+	byDay, err := s.UsageSummary(day, UsageByDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byDay) != 2 || byDay[1].Key != "2026-05-01" || byDay[1].InputTokens != 300 || byDay[1].CostUSD != 2.25 {
+		t.Errorf("UsageSummary(day) = %+v", byDay)
+	}
+	byModel, err := s.UsageSummary(day.AddDate(0, 0, 1), UsageByModel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byModel) != 1 || byModel[0].Key != "opus" || byModel[0].CostUSD != 1 {
+		t.Errorf("UsageSummary(model) = %+v", byModel)
+	}
+	if _, err := s.UsageSummary(day, "session"); err == nil {
+		t.Error("expected error for unknown grouping")
+	}
+}
+
+func TestStore_migratesDailySpend(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE daily_spend (day TEXT, model TEXT, input_tokens INTEGER, output_tokens INTEGER, cost_usd REAL, PRIMARY KEY (day, model));
+		INSERT INTO daily_spend VALUES ('2026-05-01', 'opus', 10, 5, 1.25)`); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFromDB(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	got, err := s.DaySpend(time.Date(2026, 5, 1, 12, 0, 0, 0, time.Local))
+	if err != nil || got != 1.25 {
+		t.Errorf("DaySpend after migration = %v, %v; want 1.25", got, err)
+	}
+}
+
+func TestStore_SessionIDs(t *testing.T) {
+	s := testStore(t)
+	a, _ := s.CreateSession("/a", "m")
+	b, _ := s.CreateSession("/b", "m")
+	if err := s.DeleteSession(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	ids, err := s.SessionIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != b.ID {
+		t.Fatalf("expected [%s], got %v", b.ID, ids)
+	}
+}
+
+func TestStore_CallStatsSince(t *testing.T) {
+	s := testStore(t)
+	start := time.Now().Add(-time.Minute)
+	sess, err := s.CreateSession("/tmp/p", "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(s.AppendMessage(sess.ID, "user", "fix the build", 5))
+	must(s.AppendMessageBlocks(sess.ID, "assistant", []domain.ContentBlock{
+		{Type: "text", Text: "Looking."},
+		{Type: "tool_use", ToolUseID: "t1", ToolName: "bash", ToolInput: map[string]any{"command": "go build"}},
+	}, 20))
+	must(s.AppendMessageBlocks(sess.ID, "user", []domain.ContentBlock{
+		{Type: "tool_result", ToolUseID: "t1", ToolResult: "ok"},
+	}, 5))
+	must(s.AppendMessage(sess.ID, "assistant", "Fixed.", 3))
+
+	got, err := s.CallStatsSince(start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CallStats{Sessions: 1, Turns: 1, Calls: 2, ToolCalls: 1}
+	if got != want {
+		t.Errorf("CallStatsSince = %+v, want %+v", got, want)
+	}
+
+	if got, err := s.CallStatsSince(time.Now().Add(time.Hour)); err != nil || got != (CallStats{}) {
+		t.Errorf("CallStatsSince(future) = %+v, %v", got, err)
+	}
+}
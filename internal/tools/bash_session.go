@@ -0,0 +1,271 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Persistent shell
+// ---------------------------------------------------------------------------
+
+// maxBashOutput caps the output a bash call returns.
+const maxBashOutput = 50 * 1024
+
+// ErrShellExited is returned by ShellSession.Run when the command exits the
+// shell, e.g. with exit or after set -e. The next Run starts a new shell.
+var ErrShellExited = errors.New("shell exited")
+
+// ShellSession is a long-lived shell that runs one agent session's bash
+// calls in turn, so cd, exported variables and activated virtualenvs carry
+// over between calls. The shell starts on first use and again after Reset,
+// a timeout, or the shell exiting. Only POSIX shells (sh, bash) can be kept
+// running; other shells run each command on its own.
+type ShellSession struct {
+	mu   sync.Mutex // one command at a time
+	proc *shellProc
+}
+
+// shellProc is a running shell and the reader draining its output.
+type shellProc struct {
+	shell  Shell
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stdin  io.WriteCloser
+	output *os.File      // read end of the stdout/stderr pipe
+	out    chan []byte   // output chunks; closed at EOF
+	done   chan struct{} // closed to stop the reader
+	exited chan struct{} // closed once the shell has exited
+	code   int           // exit code, set before exited closes
+	marker string
+}
+
+// exitDrain is how long Run keeps reading after the shell exits. Children
+// left in the background may hold the pipe open, so EOF can't be awaited.
+const exitDrain = 200 * time.Millisecond
+
+// NewShellSession returns a session whose shell starts on first use.
+func NewShellSession() *ShellSession {
+	return &ShellSession{}
+}
+
+// Run runs command in the session's shell and returns its combined output,
+// capped at 50 KB, and exit code. The shell starts in dir when it is not
+// already running. On timeout or cancellation the shell is killed, losing
+// its state, and the context's error is returned. If the command exits the
+// shell, Run returns ErrShellExited with the shell's exit code.
+func (s *ShellSession) Run(ctx context.Context, shell Shell, dir, command string, timeout time.Duration) (string, int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.proc != nil && s.proc.shell != shell {
+		s.stopLocked()
+	}
+	if s.proc == nil {
+		p, err := startShell(shell, dir)
+		if err != nil {
+			return "", 0, err
+		}
+		s.proc = p
+	}
+	p := s.proc
+
+	// The marker is printed in two halves so a set -x trace of this line
+	// can't be mistaken for it.
+	half := len(p.marker) / 2
+	script := "command eval " + shellQuote(command) + " < /dev/null\nprintf '\\n%s%s %s\\n' " + p.marker[:half] + " " + p.marker[half:] + " \"$?\"\n"
+	if _, err := io.WriteString(p.stdin, script); err != nil {
+		s.stopLocked()
+		return "", 0, fmt.Errorf("writing to shell: %w", err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	exited := p.exited
+	var out cappedBuffer
+	var pending []byte
+	for {
+		select {
+		case chunk, ok := <-p.out:
+			if !ok {
+				out.Write(pending)
+				<-p.exited
+				code := p.code
+				s.stopLocked()
+				return out.String(), code, ErrShellExited
+			}
+			pending = append(pending, chunk...)
+			if i := bytes.Index(pending, []byte(p.marker)); i >= 0 {
+				if nl := bytes.IndexByte(pending[i:], '\n'); nl >= 0 {
+					code, _ := strconv.Atoi(strings.TrimSpace(string(pending[i+len(p.marker) : i+nl])))
+					// Drop the newline printed before the marker.
+					out.Write(bytes.TrimSuffix(pending[:i], []byte("\n")))
+					return out.String(), code, nil
+				}
+				continue
+			}
+			// Keep enough bytes back to spot a marker split across reads.
+			if keep := len(p.marker); len(pending) > keep {
+				out.Write(pending[:len(pending)-keep])
+				pending = append(pending[:0], pending[len(pending)-keep:]...)
+			}
+		case <-exited:
+			// Give output still in the pipe a moment to arrive.
+			exited = nil
+			timer.Reset(exitDrain)
+			timeout = 0
+		case <-timer.C:
+			out.Write(pending)
+			if timeout == 0 {
+				code := p.code
+				s.stopLocked()
+				return out.String(), code, ErrShellExited
+			}
+			s.stopLocked()
+			return out.String(), 0, context.DeadlineExceeded
+		case <-ctx.Done():
+			out.Write(pending)
+			s.stopLocked()
+			return out.String(), 0, ctx.Err()
+		}
+	}
+}
+
+// Reset kills the shell. The next Run starts a fresh one.
+func (s *ShellSession) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopLocked()
+}
+
+// Close kills the shell. It is Reset under the name owners expect.
+func (s *ShellSession) Close() {
+	s.Reset()
+}
+
+// Running reports whether a shell is currently kept.
+func (s *ShellSession) Running() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.proc != nil
+}
+
+func (s *ShellSession) stopLocked() {
+	if s.proc == nil {
+		return
+	}
+	p := s.proc
+	s.proc = nil
+	close(p.done)
+	p.stdin.Close()
+	p.cancel()
+	<-p.exited
+	p.output.Close()
+}
+
+func startShell(shell Shell, dir string) (*shellProc, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, fmt.Errorf("shell marker: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, shell.Path)
+	cmd.Dir = dir
+	setProcGroup(cmd)
+	cmd.WaitDelay = bashWaitDelay
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("shell stdin: %w", err)
+	}
+	// One pipe for stdout and stderr keeps their output in order.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("shell output: %w", err)
+	}
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	if err := cmd.Start(); err != nil {
+		cancel()
+		pr.Close()
+		pw.Close()
+		return nil, fmt.Errorf("starting %s: %w", shell.Name(), err)
+	}
+	pw.Close()
+
+	p := &shellProc{
+		shell:  shell,
+		cmd:    cmd,
+		cancel: cancel,
+		stdin:  stdin,
+		output: pr,
+		out:    make(chan []byte, 16),
+		done:   make(chan struct{}),
+		exited: make(chan struct{}),
+		marker: "__MUXD_DONE_" + hex.EncodeToString(id[:]) + "__",
+	}
+	go func() {
+		defer close(p.out)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := pr.Read(buf)
+			if n > 0 {
+				select {
+				case p.out <- bytes.Clone(buf[:n]):
+				case <-p.done:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		var exitErr *exec.ExitError
+		if err := cmd.Wait(); errors.As(err, &exitErr) {
+			p.code = exitErr.ExitCode()
+		}
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+// shellQuote quotes s as a single POSIX shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// cappedBuffer keeps the first maxBashOutput bytes written to it.
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) {
+	if room := maxBashOutput - b.buf.Len(); len(p) > room {
+		p = p[:max(room, 0)]
+		b.truncated = true
+	}
+	b.buf.Write(p)
+}
+
+func (b *cappedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n... (truncated at 50KB)"
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,204 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+func testShell(t *testing.T) Shell {
+	t.Helper()
+	p := findShell(config.ShellSh, "linux")
+	if p == "" {
+		t.Skip("sh not available")
+	}
+	return Shell{Kind: config.ShellSh, Path: p}
+}
+
+func TestShellSession_Run(t *testing.T) {
+	sh := testShell(t)
+	dir := t.TempDir()
+	s := NewShellSession()
+	defer s.Close()
+	run := func(command string) (string, int, error) {
+		t.Helper()
+		return s.Run(context.Background(), sh, dir, command, 10*time.Second)
+	}
+
+	t.Run("state carries over", func(t *testing.T) {
+		if _, _, err := run("mkdir sub && cd sub && export MUXD_TEST_VAR=kept"); err != nil {
+			t.Fatal(err)
+		}
+		out, code, err := run(`basename "$PWD"; echo "$MUXD_TEST_VAR"`)
+		if err != nil || code != 0 || out != "sub\nkept\n" {
+			t.Errorf("got %q code=%d err=%v", out, code, err)
+		}
+	})
+
+	t.Run("exit code and stderr", func(t *testing.T) {
+		out, code, err := run("echo out; echo err >&2; false")
+		if err != nil || code != 1 || out != "out\nerr\n" {
+			t.Errorf("got %q code=%d err=%v", out, code, err)
+		}
+	})
+
+	t.Run("output without trailing newline", func(t *testing.T) {
+		out, _, _ := run("printf abc")
+		if out != "abc" {
+			t.Errorf("got %q", out)
+		}
+	})
+
+	t.Run("no stdin", func(t *testing.T) {
+		out, code, err := run("cat; echo done")
+		if err != nil || code != 0 || out != "done\n" {
+			t.Errorf("got %q code=%d err=%v", out, code, err)
+		}
+	})
+
+	t.Run("syntax error keeps the shell", func(t *testing.T) {
+		if _, code, err := run("if then"); err != nil || code == 0 {
+			t.Errorf("code=%d err=%v, want a nonzero code from the same shell", code, err)
+		}
+		out, _, _ := run(`echo "$MUXD_TEST_VAR"`)
+		if out != "kept\n" {
+			t.Errorf("state lost after syntax error: %q", out)
+		}
+	})
+
+	t.Run("quotes survive", func(t *testing.T) {
+		out, _, _ := run(`echo 'single' "double" it\'s`)
+		if out != "single double it's\n" {
+			t.Errorf("got %q", out)
+		}
+	})
+
+	t.Run("output is capped", func(t *testing.T) {
+		out, code, err := run("i=0; while [ $i -lt 2000 ]; do echo 0123456789012345678901234567890123456789; i=$((i+1)); done")
+		if err != nil || code != 0 {
+			t.Fatalf("code=%d err=%v", code, err)
+		}
+		if !strings.HasSuffix(out, "(truncated at 50KB)") || len(out) > maxBashOutput+64 {
+			t.Errorf("len=%d, tail %q", len(out), out[len(out)-40:])
+		}
+		if out, _, _ := run("echo next"); out != "next\n" {
+			t.Errorf("after capped output got %q", out)
+		}
+	})
+
+	t.Run("exit starts a new shell", func(t *testing.T) {
+		_, code, err := run("exit 3")
+		if !errors.Is(err, ErrShellExited) || code != 3 {
+			t.Errorf("code=%d err=%v, want ErrShellExited with 3", code, err)
+		}
+		if s.Running() {
+			t.Error("shell still marked running")
+		}
+		out, _, err := run(`echo "[$MUXD_TEST_VAR]"`)
+		if err != nil || out != "[]\n" {
+			t.Errorf("new shell got %q err=%v", out, err)
+		}
+	})
+}
+
+func TestShellSession_timeoutAndReset(t *testing.T) {
+	sh := testShell(t)
+	s := NewShellSession()
+	defer s.Close()
+
+	if _, _, err := s.Run(context.Background(), sh, t.TempDir(), "export X=1", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	out, _, err := s.Run(context.Background(), sh, t.TempDir(), "echo before; sleep 30", 300*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want DeadlineExceeded", err)
+	}
+	if out != "before\n" {
+		t.Errorf("partial output = %q", out)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("timeout took %v", time.Since(start))
+	}
+	if s.Running() {
+		t.Error("shell kept after timeout")
+	}
+
+	// A background child holding the output pipe must not block Reset.
+	if _, _, err := s.Run(context.Background(), sh, t.TempDir(), "sleep 30 &", 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	start = time.Now()
+	s.Reset()
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Reset took %v", time.Since(start))
+	}
+	out, _, _ = s.Run(context.Background(), sh, t.TempDir(), `echo "[$X]"`, 10*time.Second)
+	if out != "[]\n" {
+		t.Errorf("state survived reset: %q", out)
+	}
+}
+
+func TestShellSession_cancel(t *testing.T) {
+	sh := testShell(t)
+	s := NewShellSession()
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	if _, _, err := s.Run(ctx, sh, t.TempDir(), "sleep 30", time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want Canceled", err)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "''"},
+		{"ls -la", "'ls -la'"},
+		{"it's", `'it'\''s'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestBashTool_persistentSession(t *testing.T) {
+	testShell(t)
+	if sh, err := ResolveShell(""); err != nil || !sh.POSIX() {
+		t.Skip("default shell is not POSIX")
+	}
+	ctx := &ToolContext{ShellSession: NewShellSession()}
+	defer ctx.ShellSession.Close()
+	bash, reset := bashTool(), bashResetTool()
+
+	if _, err := bash.Execute(map[string]any{"command": "export MUXD_TEST_VAR=kept"}, ctx); err != nil {
+		t.Fatal(err)
+	}
+	out, err := bash.Execute(map[string]any{"command": `echo "[$MUXD_TEST_VAR]"; exit 4`}, ctx)
+	if err != nil || !strings.HasPrefix(out, "[kept]\n") || !strings.Contains(out, "shell exited with code 4") {
+		t.Errorf("got %q err=%v", out, err)
+	}
+	out, _ = bash.Execute(map[string]any{"command": "export MUXD_TEST_VAR=again; false"}, ctx)
+	if !strings.HasSuffix(out, "(exit code: 1)") {
+		t.Errorf("got %q, want exit code note", out)
+	}
+
+	out, err = reset.Execute(map[string]any{}, ctx)
+	if err != nil || !strings.HasPrefix(out, "Shell reset.") {
+		t.Errorf("bash_reset = %q err=%v", out, err)
+	}
+	out, _ = bash.Execute(map[string]any{"command": `echo "[$MUXD_TEST_VAR]"`}, ctx)
+	if out != "[]\n" {
+		t.Errorf("after reset got %q", out)
+	}
+
+	out, _ = reset.Execute(map[string]any{}, &ToolContext{})
+	if !strings.Contains(out, "No persistent shell") {
+		t.Errorf("bash_reset without session = %q", out)
+	}
+}
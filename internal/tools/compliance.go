@@ -0,0 +1,64 @@
+package tools
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ---------------------------------------------------------------------------
+// Compliance mode
+// ---------------------------------------------------------------------------
+
+// MessagingToolNames lists tools that send messages or post content to
+// external services. They are removed entirely in compliance mode.
+var MessagingToolNames = []string{"sms_send", "sms_status", "sms_schedule"}
+
+// messagingContentFields names the input fields of each messaging tool
+// that carry message content, as opposed to recipients or IDs.
+var messagingContentFields = map[string][]string{
+	"sms_send":     {"message"},
+	"sms_schedule": {"message"},
+}
+
+// MessagingContentFields returns the content fields of a messaging tool's
+// input, or nil if the tool sends no free-form content.
+func MessagingContentFields(name string) []string {
+	return messagingContentFields[name]
+}
+
+var complianceMode atomic.Bool
+
+// SetComplianceMode hard-disables messaging tools. Call once at startup,
+// before any agent is created.
+func SetComplianceMode(on bool) {
+	complianceMode.Store(on)
+}
+
+// ComplianceMode reports whether messaging tools are hard-disabled.
+func ComplianceMode() bool {
+	return complianceMode.Load()
+}
+
+// IsMessagingTool reports whether name is an external messaging tool.
+func IsMessagingTool(name string) bool {
+	for _, n := range MessagingToolNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// VerifyCompliance checks that no messaging tool is reachable while
+// compliance mode is on.
+func VerifyCompliance() error {
+	if !ComplianceMode() {
+		return nil
+	}
+	for _, t := range AllTools() {
+		if IsMessagingTool(t.Spec.Name) {
+			return fmt.Errorf("compliance mode: messaging tool %q is still registered", t.Spec.Name)
+		}
+	}
+	return nil
+}
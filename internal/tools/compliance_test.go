@@ -0,0 +1,28 @@
+package tools
+
+import "testing"
+
+func TestComplianceMode_removesMessagingTools(t *testing.T) {
+	SetComplianceMode(true)
+	defer SetComplianceMode(false)
+
+	for _, name := range MessagingToolNames {
+		if _, ok := FindTool(name); ok {
+			t.Errorf("%s should not be registered in compliance mode", name)
+		}
+	}
+	if _, ok := FindTool("bash"); !ok {
+		t.Error("non-messaging tools should remain registered")
+	}
+	if err := VerifyCompliance(); err != nil {
+		t.Errorf("VerifyCompliance: %v", err)
+	}
+}
+
+func TestComplianceMode_offKeepsMessagingTools(t *testing.T) {
+	for _, name := range MessagingToolNames {
+		if _, ok := FindTool(name); !ok {
+			t.Errorf("%s should be registered outside compliance mode", name)
+		}
+	}
+}
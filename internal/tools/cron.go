@@ -0,0 +1,259 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ---------------------------------------------------------------------------
+// Cron recurrence
+// ---------------------------------------------------------------------------
+
+// cronTZPrefix introduces the time zone of a cron recurrence stored with a
+// job, as in "CRON_TZ=Europe/Berlin 0 9 * * MON". Without it the
+// expression is evaluated in the daemon's local time.
+const cronTZPrefix = "CRON_TZ="
+
+// CronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month, and day of week.
+type CronSchedule struct {
+	Expr     string         // normalized expression
+	TZ       string         // time zone name; empty means local time
+	Location *time.Location // where the expression is evaluated
+
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // names for min, min+1, ...
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+// ParseCron parses a five-field cron expression evaluated in the named time
+// zone; an empty tz means the daemon's local time. Fields accept *, lists,
+// ranges, steps, and month and weekday names (JAN, MON). Day of week 7 is
+// Sunday, and when both day fields are restricted a day matching either
+// one runs, as in cron.
+func ParseCron(expr, tz string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields (minute hour day-of-month month day-of-week)", expr)
+	}
+	c := &CronSchedule{Expr: strings.Join(fields, " "), TZ: strings.TrimSpace(tz), Location: time.Local}
+	if c.TZ != "" {
+		loc, err := time.LoadLocation(c.TZ)
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", c.TZ)
+		}
+		c.Location = loc
+	}
+	sets := []*uint64{&c.minute, &c.hour, &c.dom, &c.month, &c.dow}
+	for i, f := range cronFields {
+		bits, err := f.parse(fields[i])
+		if err != nil {
+			return nil, err
+		}
+		*sets[i] = bits
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAny = strings.HasPrefix(fields[2], "*")
+	c.dowAny = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+// parse returns the bit set of values matched by one field.
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in %s field %q", f.name, part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+			if f.max == 7 {
+				hi = 6
+			}
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range in %s field %q", f.name, part)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if step == 1 {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name in the field's range.
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q (want %d-%d)", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Recurrence returns the value stored as a job's recurrence.
+func (c *CronSchedule) Recurrence() string {
+	if c.TZ == "" {
+		return c.Expr
+	}
+	return cronTZPrefix + c.TZ + " " + c.Expr
+}
+
+// Next returns the first matching minute strictly after t, or the zero time
+// when the expression never matches (such as 0 0 30 FEB *).
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	loc := c.Location
+	t = t.In(loc).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// parseCronRecurrence parses a stored cron recurrence, with or without its
+// CRON_TZ= prefix.
+func parseCronRecurrence(s string) (*CronSchedule, error) {
+	s = strings.TrimSpace(s)
+	tz := ""
+	if rest, ok := strings.CutPrefix(s, cronTZPrefix); ok {
+		tz, s, _ = strings.Cut(rest, " ")
+	}
+	return ParseCron(s, tz)
+}
+
+// ParseRecurrence validates a job recurrence and returns it normalized:
+// once (the default when empty), hourly, daily, weekly, or a cron
+// expression with an optional CRON_TZ=<zone> prefix.
+func ParseRecurrence(s string) (string, error) {
+	switch v := strings.ToLower(strings.TrimSpace(s)); v {
+	case "":
+		return "once", nil
+	case "once", "hourly", "daily", "weekly":
+		return v, nil
+	}
+	c, err := parseCronRecurrence(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid recurrence %q (use once, hourly, daily, weekly, or a cron expression): %w", s, err)
+	}
+	return c.Recurrence(), nil
+}
+
+// CronRecurrence validates a cron expression and time zone, returning the
+// recurrence to store with the job and its first run after now.
+func CronRecurrence(expr, tz string, now time.Time) (string, time.Time, error) {
+	c, err := ParseCron(expr, tz)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	next, err := NextCronRun(c.Recurrence(), now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return c.Recurrence(), next, nil
+}
+
+// NextCronRun returns the first run of a stored cron recurrence after t.
+func NextCronRun(recurrence string, t time.Time) (time.Time, error) {
+	c, err := parseCronRecurrence(recurrence)
+	if err != nil {
+		return time.Time{}, err
+	}
+	next := c.Next(t)
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never runs", c.Expr)
+	}
+	return next.UTC(), nil
+}
+
+// IsCronRecurrence reports whether a stored recurrence is a cron
+// expression rather than one of the fixed intervals.
+func IsCronRecurrence(recurrence string) bool {
+	_, err := parseCronRecurrence(recurrence)
+	return err == nil
+}
+
+// scheduleRecurrence reads the recurrence and timezone inputs of a
+// scheduling tool. For a cron recurrence it also returns the first run
+// after now, used when the call gives no time.
+func scheduleRecurrence(input map[string]any, now time.Time) (string, time.Time, error) {
+	raw, _ := input["recurrence"].(string)
+	tz, _ := input["timezone"].(string)
+	rec, err := ParseRecurrence(raw)
+	if err != nil || !IsCronRecurrence(rec) {
+		return rec, time.Time{}, err
+	}
+	expr, recTZ := rec, ""
+	if rest, ok := strings.CutPrefix(rec, cronTZPrefix); ok {
+		recTZ, expr, _ = strings.Cut(rest, " ")
+	}
+	if strings.TrimSpace(tz) == "" {
+		tz = recTZ
+	}
+	return CronRecurrence(expr, tz, now)
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronSchedule_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	// Wednesday 2026-02-18 10:30 in Berlin.
+	from := time.Date(2026, 2, 18, 10, 30, 0, 0, berlin)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"0 9 * * MON", time.Date(2026, 2, 23, 9, 0, 0, 0, berlin)},
+		{"*/15 * * * *", time.Date(2026, 2, 18, 10, 45, 0, 0, berlin)},
+		{"30 10 * * *", time.Date(2026, 2, 19, 10, 30, 0, 0, berlin)},
+		{"0 0 1 JAN-MAR *", time.Date(2026, 3, 1, 0, 0, 0, 0, berlin)},
+		{"0 12 * * 7", time.Date(2026, 2, 22, 12, 0, 0, 0, berlin)},
+		{"0 8-17/4 * * mon-fri", time.Date(2026, 2, 18, 12, 0, 0, 0, berlin)},
+		// Both day fields restricted: either one matches.
+		{"0 0 20 * MON", time.Date(2026, 2, 20, 0, 0, 0, 0, berlin)},
+		// Spring forward skips 02:30 on 2026-03-29.
+		{"30 2 29 3 *", time.Date(2027, 3, 29, 2, 30, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		c, err := ParseCron(tt.expr, "Europe/Berlin")
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := c.Next(from); !got.Equal(tt.want) {
+			t.Errorf("%q: Next = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	c, _ := ParseCron("0 0 30 FEB *", "")
+	if got := c.Next(from); !got.IsZero() {
+		t.Errorf("impossible date: Next = %v, want zero", got)
+	}
+}
+
+func TestParseCron_errors(t *testing.T) {
+	tests := []struct{ expr, tz, want string }{
+		{"0 9 * *", "", "5 fields"},
+		{"60 9 * * *", "", "invalid minute"},
+		{"0 9 * * FUNDAY", "", "invalid day of week"},
+		{"0 17-9 * * *", "", "invalid range"},
+		{"*/0 * * * *", "", "invalid step"},
+		{"0 9 * * *", "Mars/Olympus", "unknown time zone"},
+	}
+	for _, tt := range tests {
+		_, err := ParseCron(tt.expr, tt.tz)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseCron(%q, %q) = %v, want %q", tt.expr, tt.tz, err, tt.want)
+		}
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"", "once"},
+		{" Daily ", "daily"},
+		{"weekly", "weekly"},
+		{"0  9 * *   MON", "0 9 * * MON"},
+		{"CRON_TZ=UTC 0 9 * * MON", "CRON_TZ=UTC 0 9 * * MON"},
+	}
+	for _, tt := range tests {
+		got, err := ParseRecurrence(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseRecurrence(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := ParseRecurrence("fortnightly"); err == nil {
+		t.Error("expected an error for an unknown recurrence")
+	}
+}
+
+func TestCronRecurrence(t *testing.T) {
+	now := time.Date(2026, 2, 18, 10, 30, 0, 0, time.UTC)
+	rec, first, err := CronRecurrence("0 9 * * MON", "UTC", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec != "CRON_TZ=UTC 0 9 * * MON" || !first.Equal(time.Date(2026, 2, 23, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("CronRecurrence = %q, %v", rec, first)
+	}
+	if !IsCronRecurrence(rec) || IsCronRecurrence("daily") {
+		t.Error("IsCronRecurrence misclassified a recurrence")
+	}
+	if _, _, err := CronRecurrence("0 0 31 FEB *", "", now); err == nil {
+		t.Error("expected an error for an expression that never runs")
+	}
+}
@@ -10,7 +10,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
@@ -159,33 +158,28 @@ func paramsToEnv(params map[string]any) []string {
 }
 
 // Execute finds the named tool, substitutes params into its command (or sets
-// environment variables for script tools), and runs it via the system shell
-// with a 30-second timeout. It returns stdout on success, or an error
+// environment variables for script tools), and runs it with the shell.backend
+// shell and a 30-second timeout. It returns stdout on success, or an error
 // containing stderr on non-zero exit.
-func (r *CustomToolRegistry) Execute(name string, input map[string]any, cwd string) (string, error) {
+func (r *CustomToolRegistry) Execute(name string, input map[string]any, cwd, shellPref string) (string, error) {
 	def := r.Find(name)
 	if def == nil {
 		return "", fmt.Errorf("custom tool %q not found", name)
 	}
+	shell, err := ResolveShell(shellPref)
+	if err != nil {
+		return "", err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	var cmd *exec.Cmd
 	if def.Command != "" {
-		cmdStr := substituteParams(def.Command, input)
-		if runtime.GOOS == "windows" {
-			cmd = exec.CommandContext(ctx, "cmd", "/C", cmdStr)
-		} else {
-			cmd = exec.CommandContext(ctx, "sh", "-c", cmdStr)
-		}
+		cmd = shell.Command(ctx, substituteParams(def.Command, input))
 	} else {
 		// Script: pass params as environment variables.
-		if runtime.GOOS == "windows" {
-			cmd = exec.CommandContext(ctx, "cmd", "/C", def.Script)
-		} else {
-			cmd = exec.CommandContext(ctx, "sh", "-c", def.Script)
-		}
+		cmd = shell.Command(ctx, def.Script)
 		cmd.Env = append(cmd.Environ(), paramsToEnv(input)...)
 	}
 
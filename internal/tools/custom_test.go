@@ -220,7 +220,7 @@ func TestCustomToolRegistry_Execute(t *testing.T) {
 		t.Fatalf("Register error: %v", err)
 	}
 
-	out, err := reg.Execute("greet_cmd", map[string]any{"name": "world"}, "")
+	out, err := reg.Execute("greet_cmd", map[string]any{"name": "world"}, "", "")
 	if err != nil {
 		t.Fatalf("Execute returned unexpected error: %v", err)
 	}
@@ -232,7 +232,7 @@ func TestCustomToolRegistry_Execute(t *testing.T) {
 
 func TestCustomToolRegistry_ExecuteUnknown(t *testing.T) {
 	reg := NewCustomToolRegistry()
-	_, err := reg.Execute("no_such_tool", map[string]any{}, "")
+	_, err := reg.Execute("no_such_tool", map[string]any{}, "", "")
 	if err == nil {
 		t.Error("expected error executing unknown tool, got nil")
 	}
@@ -23,7 +23,7 @@ func toolCreateDef() ToolDef {
 				"description": {Type: "string", Description: "Human-readable description of what the tool does"},
 				"command":     {Type: "string", Description: "Shell command template; use {{param_name}} for parameter substitution"},
 				"parameters":  {Type: "object", Description: "Parameter definitions as an object mapping parameter names to {type, description} objects"},
-				"required":    {Type: "array", Description: "List of required parameter names"},
+				"required":    {Type: "array", Description: "List of required parameter names", Items: &provider.ToolProp{Type: "string"}},
 				"persistent":  {Type: "boolean", Description: "If true, save the tool to disk so it is available in future sessions (default: false)"},
 			},
 			Required: []string{"name", "description", "command"},
@@ -104,7 +104,7 @@ func toolRegisterDef() ToolDef {
 				"description": {Type: "string", Description: "Human-readable description of what the tool does"},
 				"script":      {Type: "string", Description: "Path to an existing script file to execute"},
 				"parameters":  {Type: "object", Description: "Parameter definitions as an object mapping parameter names to {type, description} objects"},
-				"required":    {Type: "array", Description: "List of required parameter names"},
+				"required":    {Type: "array", Description: "List of required parameter names", Items: &provider.ToolProp{Type: "string"}},
 				"persistent":  {Type: "boolean", Description: "If true, save the registration to disk so it is available in future sessions (default: false)"},
 			},
 			Required: []string{"name", "description", "script"},
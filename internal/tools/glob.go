@@ -33,7 +33,7 @@ func globTool() ToolDef {
 				return "", fmt.Errorf("pattern is required")
 			}
 
-			basePath := "."
+			basePath := ctx.Dir()
 			if v, ok := input["path"].(string); ok && v != "" {
 				basePath = v
 			}
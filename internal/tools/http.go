@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/batalabs/muxd/internal/egress"
 	"github.com/batalabs/muxd/internal/provider"
 )
 
@@ -77,7 +78,7 @@ func httpRequestTool() ToolDef {
 				}
 			}
 
-			client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+			client := &http.Client{Timeout: time.Duration(timeout) * time.Second, Transport: egress.Transport(nil)}
 			resp, err := client.Do(req)
 			if err != nil {
 				return "", fmt.Errorf("request failed: %w", err)
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/batalabs/muxd/internal/hostinfo"
 	"github.com/batalabs/muxd/internal/provider"
 )
 
@@ -19,13 +20,15 @@ type HubNodeInfo struct {
 	Model    string   `json:"model"`
 	Tools    []string `json:"tools"`
 	MCPTools []string `json:"mcp_tools"`
+	// Resources is the node's free memory and GPUs, when it reports them.
+	Resources *hostinfo.Resources `json:"resources,omitempty"`
 }
 
 func hubDiscoveryTool() ToolDef {
 	return ToolDef{
 		Spec: provider.ToolSpec{
 			Name:        "hub_discovery",
-			Description: "Discover nodes connected to the muxd hub. List all nodes with their platform, model, and available tools.",
+			Description: "Discover nodes connected to the muxd hub. List all nodes with their platform, model, memory and GPUs, and available tools.",
 			Properties: map[string]provider.ToolProp{
 				"action": {
 					Type:        "string",
@@ -99,6 +102,11 @@ func formatNodeList(nodes []HubNodeInfo) string {
 		if n.Version != "" {
 			fmt.Fprintf(&b, "    Version:  %s\n", n.Version)
 		}
+		if n.Resources != nil {
+			for _, line := range n.Resources.Lines() {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
 		fmt.Fprintf(&b, "    Tools:    %d built-in", len(n.Tools))
 		if len(n.MCPTools) > 0 {
 			fmt.Fprintf(&b, ", %d MCP", len(n.MCPTools))
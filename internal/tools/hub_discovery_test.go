@@ -3,6 +3,8 @@ package tools
 import (
 	"strings"
 	"testing"
+
+	"github.com/batalabs/muxd/internal/hostinfo"
 )
 
 func TestHubDiscoveryTool_NoHub(t *testing.T) {
@@ -32,7 +34,8 @@ func TestHubDiscoveryTool_ListNodes(t *testing.T) {
 			ID: "id2", Name: "server01", Status: "online",
 			Platform: "linux", Arch: "arm64",
 			Provider: "ollama", Model: "llama3",
-			MCPTools: []string{"mcp__fs__read"},
+			MCPTools:  []string{"mcp__fs__read"},
+			Resources: &hostinfo.Resources{GPUs: []hostinfo.GPU{{Name: "RTX 4090", MemoryTotal: 24 << 30}}},
 		},
 	}
 	ctx := &ToolContext{
@@ -55,6 +58,9 @@ func TestHubDiscoveryTool_ListNodes(t *testing.T) {
 	if !strings.Contains(result, "anthropic/claude-sonnet") {
 		t.Error("expected model info in output")
 	}
+	if !strings.Contains(result, "GPU 0: RTX 4090, 24.0 GB free of 24.0 GB") {
+		t.Errorf("expected GPU info in output, got: %s", result)
+	}
 }
 
 func TestHubDiscoveryTool_ListNodes_Empty(t *testing.T) {
@@ -182,7 +182,15 @@ func (m *ProjectMemory) MergeHub(hubFacts map[string]string) error {
 // Returns "" if no facts exist.
 func (m *ProjectMemory) FormatForPrompt() string {
 	facts, err := m.Load()
-	if err != nil || len(facts) == 0 {
+	if err != nil {
+		return ""
+	}
+	return FormatFacts(facts)
+}
+
+// FormatFacts formats facts as sorted "key: value" lines.
+func FormatFacts(facts map[string]string) string {
+	if len(facts) == 0 {
 		return ""
 	}
 
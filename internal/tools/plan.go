@@ -60,6 +60,9 @@ func planExitTool() ToolDef {
 			if !*ctx.PlanMode {
 				return "Not in plan mode.", nil
 			}
+			if ctx.PlanLocked {
+				return "The user turned plan mode on. Present your plan and wait for the user to approve it; they will turn plan mode off.", nil
+			}
 			*ctx.PlanMode = false
 			return "Exited plan mode. All tools are now available.", nil
 		},
@@ -79,6 +79,21 @@ func TestPlanExitTool(t *testing.T) {
 			t.Errorf("expected not in plan mode message, got: %s", result)
 		}
 	})
+
+	t.Run("locked by user", func(t *testing.T) {
+		planMode := true
+		ctx := &ToolContext{PlanMode: &planMode, PlanLocked: true}
+		result, err := tool.Execute(map[string]any{}, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !planMode {
+			t.Error("expected planMode to stay true while locked")
+		}
+		if !strings.Contains(result, "wait for the user to approve") {
+			t.Errorf("expected locked message, got: %s", result)
+		}
+	})
 }
 
 func TestAllToolsForMode(t *testing.T) {
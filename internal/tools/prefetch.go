@@ -0,0 +1,284 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/batalabs/muxd/internal/docread"
+)
+
+// ---------------------------------------------------------------------------
+// Speculative prefetch
+// ---------------------------------------------------------------------------
+
+// Limits that keep prefetching cheap and read-only.
+const (
+	prefetchMaxFileSize = 256 * 1024 // larger files are left to file_read
+	prefetchMaxEntries  = 32         // files cached at once
+	prefetchMaxPerTurn  = 8          // files warmed per model response
+	prefetchMaxPathLen  = 256
+)
+
+// PrefetchCache holds files the model mentioned while it was still
+// streaming, so a file_read that follows can skip the disk. Entries are
+// served only while the file's size and modification time are unchanged,
+// so a stale read is never returned.
+//
+// Prefetching never writes, runs commands, or touches the network: it
+// reads regular files under the working directory that file_read would
+// also be allowed to read.
+type PrefetchCache struct {
+	mu      sync.Mutex
+	entries map[string]prefetchEntry
+	order   []string // insertion order, for eviction
+	pending map[string]bool
+	gen     int // bumped by Clear so reads started before it are dropped
+	hits    int
+	wg      sync.WaitGroup
+}
+
+type prefetchEntry struct {
+	data    []byte
+	size    int64
+	modTime time.Time
+}
+
+// NewPrefetchCache returns an empty cache.
+func NewPrefetchCache() *PrefetchCache {
+	return &PrefetchCache{entries: map[string]prefetchEntry{}, pending: map[string]bool{}}
+}
+
+// Warm reads path in the background if it is safe to prefetch. Relative
+// paths are resolved against cwd, and the file must be inside cwd.
+func (c *PrefetchCache) Warm(cwd, path string) {
+	abs, ok := prefetchPath(cwd, path)
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	if _, cached := c.entries[abs]; cached || c.pending[abs] {
+		c.mu.Unlock()
+		return
+	}
+	c.pending[abs] = true
+	gen := c.gen
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	go func() {
+		defer c.wg.Done()
+		e, ok := readPrefetch(cwd, abs)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.pending, abs)
+		if ok && gen == c.gen {
+			c.store(abs, e)
+		}
+	}()
+}
+
+// Clear drops every cached file. The agent calls it before any tool that
+// may change files runs.
+func (c *PrefetchCache) Clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]prefetchEntry{}
+	c.order = nil
+	c.gen++
+}
+
+// Wait blocks until background reads started by Warm have finished.
+func (c *PrefetchCache) Wait() { c.wg.Wait() }
+
+// store adds an entry, evicting the oldest once the cache is full. c.mu
+// must be held.
+func (c *PrefetchCache) store(abs string, e prefetchEntry) {
+	if _, ok := c.entries[abs]; !ok {
+		c.order = append(c.order, abs)
+	}
+	c.entries[abs] = e
+	for len(c.order) > prefetchMaxEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// Get returns the cached contents of path if the file has not changed
+// since it was prefetched. A nil cache never hits.
+func (c *PrefetchCache) Get(path string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	e, ok := c.entries[filepath.Clean(abs)]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	info, err := os.Stat(abs)
+	if err != nil || !info.Mode().IsRegular() || info.Size() != e.size || !info.ModTime().Equal(e.modTime) {
+		return nil, false
+	}
+	c.mu.Lock()
+	c.hits++
+	c.mu.Unlock()
+	return e.data, true
+}
+
+// Stats returns how many files are cached and how many reads they served.
+func (c *PrefetchCache) Stats() (cached, hits int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries), c.hits
+}
+
+// PrefetchKeeps reports whether running the named tool leaves the cache
+// valid. Anything else might change files and clears it.
+func PrefetchKeeps(toolName string) bool {
+	switch toolName {
+	case "file_read", "grep", "glob", "list_files", "git_status":
+		return true
+	}
+	return false
+}
+
+// prefetchPath resolves path and reports whether it may be prefetched.
+func prefetchPath(cwd, path string) (string, bool) {
+	if cwd == "" || path == "" || len(path) > prefetchMaxPathLen {
+		return "", false
+	}
+	abs := path
+	if !filepath.IsAbs(abs) {
+		abs = filepath.Join(cwd, abs)
+	}
+	abs = filepath.Clean(abs)
+	if !withinDir(cwd, abs) || IsDeniedConfigFile(abs) {
+		return "", false
+	}
+	// Documents go through extraction, which is not worth doing on a guess.
+	if docread.CanExtract(strings.ToLower(filepath.Ext(abs))) {
+		return "", false
+	}
+	return abs, true
+}
+
+// readPrefetch reads a small regular file whose real path, after
+// following symlinks, is still inside cwd.
+func readPrefetch(cwd, abs string) (prefetchEntry, bool) {
+	realCwd, err := filepath.EvalSymlinks(cwd)
+	if err != nil {
+		return prefetchEntry{}, false
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err != nil || !withinDir(realCwd, real) {
+		return prefetchEntry{}, false
+	}
+	info, err := os.Stat(real)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > prefetchMaxFileSize {
+		return prefetchEntry{}, false
+	}
+	data, err := os.ReadFile(real)
+	if err != nil || int64(len(data)) != info.Size() || IsBinary(data) {
+		return prefetchEntry{}, false
+	}
+	return prefetchEntry{data: data, size: info.Size(), modTime: info.ModTime()}, true
+}
+
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(filepath.Clean(dir), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// ---------------------------------------------------------------------------
+// Path mentions
+// ---------------------------------------------------------------------------
+
+// PathScanner picks file paths out of streamed text, e.g. the model
+// writing "let me look at `internal/tools/tools.go`". Text is fed in
+// chunks; a path is reported once the character after it has arrived.
+type PathScanner struct {
+	partial string
+	seen    map[string]bool
+	found   int
+}
+
+// Feed adds a chunk of text and returns paths completed by it, each at
+// most once and no more than prefetchMaxPerTurn in total.
+func (p *PathScanner) Feed(chunk string) []string {
+	if p.found >= prefetchMaxPerTurn {
+		return nil
+	}
+	text := p.partial + chunk
+	end := strings.LastIndexFunc(text, isPathDelimiter)
+	if end < 0 {
+		p.partial = tail(text)
+		return nil
+	}
+	p.partial = tail(text[end+1:])
+
+	var out []string
+	for _, word := range strings.FieldsFunc(text[:end], isPathDelimiter) {
+		word = strings.TrimRight(word, ".")
+		if !looksLikePath(word) {
+			continue
+		}
+		if p.seen == nil {
+			p.seen = map[string]bool{}
+		}
+		if p.seen[word] {
+			continue
+		}
+		p.seen[word] = true
+		out = append(out, word)
+		if p.found++; p.found >= prefetchMaxPerTurn {
+			break
+		}
+	}
+	return out
+}
+
+// tail bounds the carried-over partial word so a long run of text without
+// delimiters cannot grow it.
+func tail(s string) string {
+	if len(s) > prefetchMaxPathLen {
+		return ""
+	}
+	return s
+}
+
+func isPathDelimiter(r rune) bool {
+	switch r {
+	case ' ', '\t', '\n', '\r', '`', '"', '\'', '(', ')', '[', ']', '<', '>', ',', ';', ':', '*', '|':
+		return true
+	}
+	return false
+}
+
+// looksLikePath accepts words with a file extension, like main.go or
+// internal/tools/tools.go, but not version numbers like 1.2.
+func looksLikePath(w string) bool {
+	if len(w) < 3 || len(w) > prefetchMaxPathLen || strings.Contains(w, "..") {
+		return false
+	}
+	ext := filepath.Ext(w)
+	if len(ext) < 2 || len(ext) > 8 || !strings.ContainsFunc(ext, unicode.IsLetter) {
+		return false
+	}
+	for _, r := range w {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("./_-\\", r)) {
+			return false
+		}
+	}
+	return true
+}
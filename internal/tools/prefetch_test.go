@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPathScanner(t *testing.T) {
+	var s PathScanner
+	var got []string
+	for _, chunk := range []string{"Let me read `internal/to", "ols/tools.go` and main.", "go. Version 1.2 is ", "fine; see README.md, then main.go again\n"} {
+		got = append(got, s.Feed(chunk)...)
+	}
+	want := []string{"internal/tools/tools.go", "main.go", "README.md"}
+	if !slices.Equal(got, want) {
+		t.Errorf("paths = %q, want %q", got, want)
+	}
+
+	// A path is only reported once the character after it arrives.
+	var partial PathScanner
+	if got := partial.Feed("open cmd/app.go"); len(got) != 0 {
+		t.Errorf("incomplete word reported: %q", got)
+	}
+	if got := partial.Feed(" now"); !slices.Equal(got, []string{"cmd/app.go"}) {
+		t.Errorf("completed word = %q", got)
+	}
+
+	for _, w := range []string{"https//x", "../secret.txt", "a.b.c.d.verylongext", "no_extension", "weird$name.go"} {
+		if looksLikePath(w) {
+			t.Errorf("looksLikePath(%q) = true", w)
+		}
+	}
+}
+
+func TestPathScanner_limit(t *testing.T) {
+	var s PathScanner
+	var b strings.Builder
+	for i := range 20 {
+		b.WriteString("f" + string(rune('a'+i)) + ".go ")
+	}
+	if got := s.Feed(b.String()); len(got) != prefetchMaxPerTurn {
+		t.Errorf("got %d paths, want the limit of %d", len(got), prefetchMaxPerTurn)
+	}
+	if got := s.Feed("more.go "); len(got) != 0 {
+		t.Errorf("paths after the limit: %q", got)
+	}
+}
+
+func TestPrefetchCache(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewPrefetchCache()
+	c.Warm(dir, "main.go")
+	c.Wait()
+	data, ok := c.Get(path)
+	if !ok || string(data) != "package main\n" {
+		t.Fatalf("Get = %q, %v", data, ok)
+	}
+	if cached, hits := c.Stats(); cached != 1 || hits != 1 {
+		t.Errorf("Stats = %d, %d", cached, hits)
+	}
+
+	// A changed file is read from disk again.
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(path, []byte("package main // changed\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Chtimes(path, later, later)
+	if _, ok := c.Get(path); ok {
+		t.Error("stale entry served after the file changed")
+	}
+
+	c.Warm(dir, "main.go") // already cached, not read again
+	c.Clear()
+	if _, ok := c.Get(path); ok {
+		t.Error("entry served after Clear")
+	}
+
+	var nilCache *PrefetchCache
+	if _, ok := nilCache.Get(path); ok {
+		t.Error("nil cache hit")
+	}
+	nilCache.Clear()
+}
+
+func TestPrefetchCache_rules(t *testing.T) {
+	root := t.TempDir()
+	cwd := filepath.Join(root, "project")
+	if err := os.MkdirAll(cwd, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(root, "secret.txt")
+	big := filepath.Join(cwd, "big.txt")
+	bin := filepath.Join(cwd, "blob.dat")
+	_ = os.WriteFile(outside, []byte("secret"), 0o644)
+	_ = os.WriteFile(big, make([]byte, prefetchMaxFileSize+1), 0o644)
+	_ = os.WriteFile(bin, []byte{0, 1, 2, 3}, 0o644)
+
+	c := NewPrefetchCache()
+	for _, p := range []string{"../secret.txt", outside, "big.txt", "blob.dat", "missing.go", "docs/spec.pdf"} {
+		c.Warm(cwd, p)
+	}
+	if runtime.GOOS != "windows" {
+		link := filepath.Join(cwd, "link.txt")
+		if err := os.Symlink(outside, link); err == nil {
+			c.Warm(cwd, "link.txt")
+		}
+	}
+	c.Wait()
+	if cached, _ := c.Stats(); cached != 0 {
+		t.Errorf("cached %d files; want none of them", cached)
+	}
+}
+
+func TestFileReadUsesPrefetch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := NewPrefetchCache()
+	c.Warm(dir, "a.txt")
+	c.Wait()
+
+	out, err := fileReadTool().Execute(map[string]any{"path": path}, &ToolContext{Prefetch: c})
+	if err != nil || !strings.Contains(out, "2 │ two") {
+		t.Fatalf("file_read = %q, %v", out, err)
+	}
+	if _, hits := c.Stats(); hits != 1 {
+		t.Errorf("hits = %d, want the read served from the cache", hits)
+	}
+}
@@ -16,3 +16,6 @@ func setProcGroup(cmd *exec.Cmd) {
 		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
 	}
 }
+
+// setCmdLine is only needed for cmd.exe on Windows.
+func setCmdLine(cmd *exec.Cmd, line string) {}
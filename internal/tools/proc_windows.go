@@ -11,9 +11,10 @@ import (
 // Cancel to kill the entire process tree so child processes don't
 // survive and hold stdout/stderr pipes open.
 func setProcGroup(cmd *exec.Cmd) {
-	cmd.SysProcAttr = &syscall.SysProcAttr{
-		CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP,
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
 	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
 	cmd.Cancel = func() error {
 		// Use taskkill /T /F to kill the entire process tree.
 		// TerminateProcess alone only kills the parent.
@@ -26,3 +27,12 @@ func setProcGroup(cmd *exec.Cmd) {
 		return nil
 	}
 }
+
+// setCmdLine passes line to the process verbatim instead of quoting
+// cmd.Args, which cmd.exe would misread.
+func setCmdLine(cmd *exec.Cmd, line string) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CmdLine = line
+}
@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// ---------------------------------------------------------------------------
+// Tool reference
+// ---------------------------------------------------------------------------
+
+// ToolReference renders docs/tools.md: every built-in tool with its
+// arguments, generated from the same specs the agent sends to providers.
+func ToolReference() string {
+	var b strings.Builder
+	b.WriteString("# Tool reference\n\n")
+	b.WriteString("<!-- Generated from internal/tools. Do not edit; run\n")
+	b.WriteString("     MUXD_UPDATE_DOCS=1 go test ./internal/tools -run TestToolReference -->\n\n")
+	b.WriteString("Built-in tools the agent can call. Arguments are checked against these ")
+	b.WriteString("schemas before a tool runs. `GET /api/tools` on the daemon returns the same ")
+	b.WriteString("list as JSON Schema, along with MCP and custom tools.\n")
+	for _, spec := range AllToolSpecs() {
+		fmt.Fprintf(&b, "\n## `%s`\n\n%s\n\n", spec.Name, spec.Description)
+		if len(spec.Properties) == 0 {
+			b.WriteString("No arguments.\n")
+			continue
+		}
+		b.WriteString("| Argument | Type | Required | Description |\n")
+		b.WriteString("|----------|------|----------|-------------|\n")
+		writePropRows(&b, "", spec.Properties, spec.Required)
+	}
+	return b.String()
+}
+
+// writePropRows writes one table row per property, required ones first,
+// then recurses into object properties and array items with dotted paths.
+func writePropRows(b *strings.Builder, prefix string, props map[string]provider.ToolProp, required []string) {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ri, rj := slices.Contains(required, names[i]), slices.Contains(required, names[j])
+		if ri != rj {
+			return ri
+		}
+		return names[i] < names[j]
+	})
+	for _, name := range names {
+		p := props[name]
+		path := prefix + name
+		typ := p.Type
+		if p.Type == "array" && p.Items != nil {
+			typ = p.Items.Type + "[]"
+		}
+		desc := p.Description
+		if len(p.Enum) > 0 {
+			desc = strings.TrimSpace(desc + " One of `" + strings.Join(p.Enum, "`, `") + "`.")
+		}
+		req := ""
+		if slices.Contains(required, name) {
+			req = "yes"
+		}
+		fmt.Fprintf(b, "| `%s` | %s | %s | %s |\n", path, typ, req, markdownCell(desc))
+		switch {
+		case p.Type == "object":
+			writePropRows(b, path+".", p.Properties, p.Required)
+		case p.Type == "array" && p.Items != nil && p.Items.Type == "object":
+			writePropRows(b, path+"[].", p.Items.Properties, p.Items.Required)
+		}
+	}
+}
+
+// markdownCell makes text safe for a single table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.Join(strings.Fields(s), " ")
+}
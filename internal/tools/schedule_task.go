@@ -39,10 +39,11 @@ func scheduleTaskTool() ToolDef {
 			Description: "Schedule a multi-step agent task for future execution. At the scheduled time, a full agent loop is spawned with the given prompt and all tools. Use this for complex workflows that require multiple tool calls (e.g., 'search for tweets about X and reply to 5').",
 			Properties: map[string]provider.ToolProp{
 				"prompt":     {Type: "string", Description: "The prompt describing the task to execute"},
-				"time":       {Type: "string", Description: "When to execute (RFC3339 e.g. '2026-02-24T16:00:00Z' or HH:MM e.g. '16:00')"},
-				"recurrence": {Type: "string", Description: "How often to repeat: 'once' (default), 'daily', or 'hourly'"},
+				"time":       {Type: "string", Description: "When to execute (RFC3339 e.g. '2026-02-24T16:00:00Z' or HH:MM e.g. '16:00'). Optional with a cron recurrence, which then first runs at its next match"},
+				"recurrence": {Type: "string", Description: "How often to repeat: 'once' (default), 'hourly', 'daily', 'weekly', or a five-field cron expression such as '0 9 * * MON'"},
+				"timezone":   {Type: "string", Description: "IANA time zone for a cron recurrence, e.g. 'Europe/Berlin' (default: local time)"},
 			},
-			Required: []string{"prompt", "time"},
+			Required: []string{"prompt"},
 		},
 		Execute: func(input map[string]any, ctx *ToolContext) (string, error) {
 			if ctx == nil || ctx.ScheduleTool == nil {
@@ -54,24 +55,18 @@ func scheduleTaskTool() ToolDef {
 				return "", fmt.Errorf("prompt is required")
 			}
 
-			rawTime, _ := input["time"].(string)
-			if strings.TrimSpace(rawTime) == "" {
-				return "", fmt.Errorf("time is required")
-			}
-
-			scheduledFor, err := ParseScheduleTime(rawTime, nowFunc())
+			recurrence, scheduledFor, err := scheduleRecurrence(input, nowFunc())
 			if err != nil {
-				return "", fmt.Errorf("invalid time: %w", err)
+				return "", err
 			}
 
-			recurrence := "once"
-			if v, _ := input["recurrence"].(string); v != "" {
-				v = strings.ToLower(strings.TrimSpace(v))
-				switch v {
-				case "once", "daily", "hourly":
-					recurrence = v
-				default:
-					return "", fmt.Errorf("invalid recurrence %q: must be once, daily, or hourly", v)
+			rawTime, _ := input["time"].(string)
+			if strings.TrimSpace(rawTime) == "" && scheduledFor.IsZero() {
+				return "", fmt.Errorf("time is required")
+			}
+			if strings.TrimSpace(rawTime) != "" {
+				if scheduledFor, err = ParseScheduleTime(rawTime, nowFunc()); err != nil {
+					return "", fmt.Errorf("invalid time: %w", err)
 				}
 			}
 
@@ -81,6 +76,10 @@ func scheduleTaskTool() ToolDef {
 				return "", fmt.Errorf("scheduling task: %w", err)
 			}
 
+			if IsDraftJob(ctx.DraftTools, AgentTaskToolName) {
+				return fmt.Sprintf("Queued agent task draft %s for %s (%s). It will not run until the user approves it with /drafts approve:\n%s",
+					id, scheduledFor.Local().Format("2006-01-02 15:04"), recurrence, prompt), nil
+			}
 			return fmt.Sprintf("Scheduled agent task %s for %s (%s):\n%s",
 				id, scheduledFor.Local().Format("2006-01-02 15:04"), recurrence, prompt), nil
 		},
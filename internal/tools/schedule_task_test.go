@@ -53,6 +53,18 @@ func TestScheduleTaskTool(t *testing.T) {
 			ctx:    makeCtx(),
 			wantOK: "hourly",
 		},
+		{
+			name:   "cron without a time",
+			input:  map[string]any{"prompt": "standup notes", "recurrence": "0 9 * * MON", "timezone": "UTC"},
+			ctx:    makeCtx(),
+			wantOK: "CRON_TZ=UTC 0 9 * * MON",
+		},
+		{
+			name:    "invalid cron",
+			input:   map[string]any{"prompt": "standup notes", "recurrence": "0 25 * * MON"},
+			ctx:     makeCtx(),
+			wantErr: "invalid hour",
+		},
 		{
 			name:    "empty prompt",
 			input:   map[string]any{"prompt": "", "time": "16:00"},
@@ -85,7 +97,7 @@ func TestScheduleTaskTool(t *testing.T) {
 		},
 		{
 			name:    "invalid recurrence",
-			input:   map[string]any{"prompt": "do something", "time": "16:00", "recurrence": "weekly"},
+			input:   map[string]any{"prompt": "do something", "time": "16:00", "recurrence": "fortnightly"},
 			ctx:     makeCtx(),
 			wantErr: "invalid recurrence",
 		},
@@ -11,6 +11,16 @@ import (
 // single tool call.
 const AgentTaskToolName = "__agent_task__"
 
+// IsDraftJob reports whether scheduled calls of toolName are held as drafts
+// under scheduler.draft_tools. Agent tasks are listed there as
+// schedule_task.
+func IsDraftJob(draftTools map[string]bool, toolName string) bool {
+	if toolName == AgentTaskToolName {
+		toolName = "schedule_task"
+	}
+	return draftTools[toolName]
+}
+
 // ScheduledToolCall represents one scheduled tool execution request.
 type ScheduledToolCall struct {
 	ID           string
@@ -19,6 +29,7 @@ type ScheduledToolCall struct {
 	ToolInput    map[string]any
 	ScheduledFor time.Time
 	Recurrence   string
+	Retries      int // failed attempts of this run so far
 }
 
 // ScheduledToolCallStore provides persistence for scheduled tool calls.
@@ -27,6 +38,31 @@ type ScheduledToolCallStore interface {
 	MarkScheduledToolCallSucceeded(call ScheduledToolCall, result string, completedAt time.Time) error
 	MarkScheduledToolCallFailed(call ScheduledToolCall, errText, result string, attemptedAt time.Time) error
 	RescheduleScheduledToolCall(call ScheduledToolCall, next time.Time) error
+	RetryScheduledToolCall(call ScheduledToolCall, errText, result string, attemptedAt, retryAt time.Time) error
+	SkipScheduledToolCall(call ScheduledToolCall, reason string, skippedAt time.Time) error
+}
+
+// RetryPolicy controls how the scheduler handles failed and missed runs.
+type RetryPolicy struct {
+	MaxAttempts  int           // runs of a failing job before it is marked failed; below 2 never retries
+	Backoff      time.Duration // delay before the first retry, doubled for each further one
+	SkipMisfired bool          // skip runs that came due before the scheduler started
+}
+
+// maxRetryBackoff caps the doubling retry delay.
+const maxRetryBackoff = 24 * time.Hour
+
+// retryDelay returns how long to wait before retrying a call that has
+// failed retries times before this failure.
+func (p RetryPolicy) retryDelay(retries int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = time.Minute
+	}
+	for i := 0; i < retries && d < maxRetryBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxRetryBackoff)
 }
 
 // ScheduledToolCallExecutor executes one scheduled call with provided context.
@@ -45,7 +81,9 @@ type ToolCallScheduler struct {
 	stopCh      chan struct{}
 	doneCh      chan struct{}
 	running     bool
+	started     time.Time
 	logFunc     func(string, ...any)
+	policyFunc  func() RetryPolicy
 }
 
 // NewToolCallScheduler creates a generic scheduled tool-call engine.
@@ -68,6 +106,25 @@ func (s *ToolCallScheduler) SetLogFunc(fn func(string, ...any)) {
 	s.logFunc = fn
 }
 
+// SetPolicyFunc sets the function that returns the retry and misfire
+// policy. It is called on every run, so policy changes apply to the next
+// due job. Without one, failures are not retried and missed runs run.
+func (s *ToolCallScheduler) SetPolicyFunc(fn func() RetryPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policyFunc = fn
+}
+
+func (s *ToolCallScheduler) policy() RetryPolicy {
+	s.mu.Lock()
+	fn := s.policyFunc
+	s.mu.Unlock()
+	if fn == nil {
+		return RetryPolicy{}
+	}
+	return fn()
+}
+
 // logf writes a log line if a log function is configured.
 func (s *ToolCallScheduler) logf(format string, args ...any) {
 	if s.logFunc != nil {
@@ -85,6 +142,7 @@ func (s *ToolCallScheduler) Start() {
 	s.stopCh = make(chan struct{})
 	s.doneCh = make(chan struct{})
 	s.running = true
+	s.started = nowFunc().UTC()
 	stop := s.stopCh
 	done := s.doneCh
 	s.mu.Unlock()
@@ -133,8 +191,16 @@ func (s *ToolCallScheduler) RunOnce() error {
 	if err != nil {
 		return err
 	}
+	policy := s.policy()
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
 	for _, call := range calls {
 		attempted := nowFunc().UTC()
+		if policy.SkipMisfired && s.misfired(call, started) {
+			s.skipMisfired(call, attempted)
+			continue
+		}
 		ctx := &ToolContext{}
 		if s.ctxProvider != nil {
 			if provided := s.ctxProvider(); provided != nil {
@@ -151,15 +217,11 @@ func (s *ToolCallScheduler) RunOnce() error {
 
 		result, isToolError, execErr := s.executor(call, ctx)
 		if execErr != nil {
-			if err := s.store.MarkScheduledToolCallFailed(call, execErr.Error(), result, attempted); err != nil {
-				s.logf("scheduler: mark failed (exec): %v", err)
-			}
+			s.fail(call, policy, execErr.Error(), result, attempted)
 			continue
 		}
 		if isToolError {
-			if err := s.store.MarkScheduledToolCallFailed(call, "tool execution returned an error result", result, attempted); err != nil {
-				s.logf("scheduler: mark failed (tool error): %v", err)
-			}
+			s.fail(call, policy, "tool execution returned an error result", result, attempted)
 			continue
 		}
 		if err := s.store.MarkScheduledToolCallSucceeded(call, result, attempted); err != nil {
@@ -175,6 +237,47 @@ func (s *ToolCallScheduler) RunOnce() error {
 	return nil
 }
 
+// fail records a failed run, retrying it after the backoff delay while
+// the policy allows more attempts.
+func (s *ToolCallScheduler) fail(call ScheduledToolCall, policy RetryPolicy, errText, result string, attempted time.Time) {
+	if call.Retries+1 < policy.MaxAttempts {
+		retryAt := attempted.Add(policy.retryDelay(call.Retries))
+		if err := s.store.RetryScheduledToolCall(call, errText, result, attempted, retryAt); err != nil {
+			s.logf("scheduler: retry: %v", err)
+		}
+		return
+	}
+	if err := s.store.MarkScheduledToolCallFailed(call, errText, result, attempted); err != nil {
+		s.logf("scheduler: mark failed: %v", err)
+	}
+}
+
+// misfired reports whether call came due while the daemon was down: its
+// first attempt was due more than one poll interval before the scheduler
+// started.
+func (s *ToolCallScheduler) misfired(call ScheduledToolCall, started time.Time) bool {
+	return !started.IsZero() && call.Retries == 0 && call.ScheduledFor.Before(started.Add(-s.interval))
+}
+
+// skipMisfired records a missed run as skipped. A recurring job moves on
+// to its first run after now.
+func (s *ToolCallScheduler) skipMisfired(call ScheduledToolCall, now time.Time) {
+	reason := "skipped: due at " + call.ScheduledFor.UTC().Format(time.RFC3339) + " while the daemon was not running"
+	if err := s.store.SkipScheduledToolCall(call, reason, now); err != nil {
+		s.logf("scheduler: skip misfired: %v", err)
+		return
+	}
+	next, recurring := nextRecurringTime(call.Recurrence, call.ScheduledFor)
+	for recurring && !next.After(now) {
+		next, recurring = nextRecurringTime(call.Recurrence, next)
+	}
+	if recurring {
+		if err := s.store.RescheduleScheduledToolCall(call, next); err != nil {
+			s.logf("scheduler: reschedule: %v", err)
+		}
+	}
+}
+
 func isSchedulerAllowed(toolName string, ctx *ToolContext) bool {
 	name := strings.ToLower(strings.TrimSpace(toolName))
 	if name == "" {
@@ -194,13 +297,27 @@ func isSchedulerAllowed(toolName string, ctx *ToolContext) bool {
 	return ctx.ScheduledAllowed[name]
 }
 
+// nextRecurringTime returns when a recurring job that was due at from runs
+// next. Cron jobs skip any runs missed while the daemon was down.
 func nextRecurringTime(recurrence string, from time.Time) (time.Time, bool) {
 	switch strings.ToLower(strings.TrimSpace(recurrence)) {
 	case "daily":
 		return from.UTC().Add(24 * time.Hour), true
 	case "hourly":
 		return from.UTC().Add(time.Hour), true
-	default:
+	case "weekly":
+		return from.UTC().Add(7 * 24 * time.Hour), true
+	}
+	c, err := parseCronRecurrence(recurrence)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if now := nowFunc(); now.After(from) {
+		from = now
+	}
+	next := c.Next(from)
+	if next.IsZero() {
 		return time.Time{}, false
 	}
+	return next.UTC(), true
 }
@@ -45,6 +45,16 @@ func TestIsSchedulerAllowed(t *testing.T) {
 // nextRecurringTime
 // ---------------------------------------------------------------------------
 
+func TestIsDraftJob(t *testing.T) {
+	drafts := map[string]bool{"sms_send": true, "schedule_task": true}
+	if !IsDraftJob(drafts, "sms_send") || !IsDraftJob(drafts, AgentTaskToolName) {
+		t.Error("expected sms_send and agent tasks to be drafts")
+	}
+	if IsDraftJob(drafts, "web_fetch") || IsDraftJob(nil, "sms_send") {
+		t.Error("unexpected draft")
+	}
+}
+
 func TestNextRecurringTime(t *testing.T) {
 	base := time.Date(2026, 2, 20, 10, 0, 0, 0, time.UTC)
 
@@ -90,6 +100,26 @@ func TestNextRecurringTime(t *testing.T) {
 			t.Error("expected recurring=true for DAILY")
 		}
 	})
+
+	t.Run("weekly adds 7 days", func(t *testing.T) {
+		next, ok := nextRecurringTime("weekly", base)
+		if !ok || !next.Equal(base.AddDate(0, 0, 7)) {
+			t.Errorf("next = %v, %v", next, ok)
+		}
+	})
+
+	t.Run("cron skips missed runs", func(t *testing.T) {
+		origNow := nowFunc
+		t.Cleanup(func() { nowFunc = origNow })
+		// Friday, two days after the job was due.
+		nowFunc = func() time.Time { return base.AddDate(0, 0, 2) }
+
+		next, ok := nextRecurringTime("CRON_TZ=UTC 0 9 * * MON", base)
+		want := time.Date(2026, 2, 23, 9, 0, 0, 0, time.UTC)
+		if !ok || !next.Equal(want) {
+			t.Errorf("next = %v, %v, want %v", next, ok, want)
+		}
+	})
 }
 
 // ---------------------------------------------------------------------------
@@ -126,6 +156,10 @@ type fakeSchedulerStore struct {
 	succeededIDs   []string
 	failedIDs      []string
 	rescheduledIDs []string
+	retriedIDs     []string
+	skippedIDs     []string
+	retryAt        time.Time
+	rescheduledTo  time.Time
 }
 
 func (f *fakeSchedulerStore) DueScheduledToolCalls(now time.Time, limit int) ([]ScheduledToolCall, error) {
@@ -144,6 +178,18 @@ func (f *fakeSchedulerStore) MarkScheduledToolCallFailed(call ScheduledToolCall,
 
 func (f *fakeSchedulerStore) RescheduleScheduledToolCall(call ScheduledToolCall, next time.Time) error {
 	f.rescheduledIDs = append(f.rescheduledIDs, call.ID)
+	f.rescheduledTo = next
+	return nil
+}
+
+func (f *fakeSchedulerStore) RetryScheduledToolCall(call ScheduledToolCall, errText, result string, attemptedAt, retryAt time.Time) error {
+	f.retriedIDs = append(f.retriedIDs, call.ID)
+	f.retryAt = retryAt
+	return nil
+}
+
+func (f *fakeSchedulerStore) SkipScheduledToolCall(call ScheduledToolCall, reason string, skippedAt time.Time) error {
+	f.skippedIDs = append(f.skippedIDs, call.ID)
 	return nil
 }
 
@@ -229,3 +275,124 @@ func TestToolCallScheduler_RunOnce(t *testing.T) {
 		}
 	})
 }
+
+func TestRetryPolicy_retryDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Minute}
+	for retries, want := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute} {
+		if got := p.retryDelay(retries); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", retries, got, want)
+		}
+	}
+	if got := p.retryDelay(40); got != maxRetryBackoff {
+		t.Errorf("retryDelay(40) = %v, want the %v cap", got, maxRetryBackoff)
+	}
+}
+
+func TestToolCallScheduler_retries(t *testing.T) {
+	base := time.Date(2026, 2, 20, 10, 0, 0, 0, time.UTC)
+	origNow := nowFunc
+	t.Cleanup(func() { nowFunc = origNow })
+	nowFunc = func() time.Time { return base }
+
+	failing := func(call ScheduledToolCall, ctx *ToolContext) (string, bool, error) {
+		return "", false, errors.New("boom")
+	}
+	run := func(call ScheduledToolCall, policy RetryPolicy) *fakeSchedulerStore {
+		st := &fakeSchedulerStore{dueJobs: []ScheduledToolCall{call}}
+		s := NewToolCallScheduler(st, time.Minute, func() *ToolContext {
+			return &ToolContext{ScheduledAllowed: map[string]bool{"sms_send": true}}
+		}, failing)
+		s.SetPolicyFunc(func() RetryPolicy { return policy })
+		if err := s.RunOnce(); err != nil {
+			t.Fatalf("RunOnce error: %v", err)
+		}
+		return st
+	}
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}
+
+	t.Run("retries with backoff while attempts remain", func(t *testing.T) {
+		st := run(ScheduledToolCall{ID: "a", ToolName: "sms_send", ScheduledFor: base, Retries: 1}, policy)
+		if len(st.retriedIDs) != 1 || len(st.failedIDs) != 0 {
+			t.Fatalf("retried = %v, failed = %v", st.retriedIDs, st.failedIDs)
+		}
+		if want := base.Add(2 * time.Minute); !st.retryAt.Equal(want) {
+			t.Errorf("retryAt = %v, want %v", st.retryAt, want)
+		}
+	})
+
+	t.Run("fails after the last attempt", func(t *testing.T) {
+		st := run(ScheduledToolCall{ID: "b", ToolName: "sms_send", ScheduledFor: base, Retries: 2}, policy)
+		if len(st.retriedIDs) != 0 || len(st.failedIDs) != 1 {
+			t.Fatalf("retried = %v, failed = %v", st.retriedIDs, st.failedIDs)
+		}
+	})
+
+	t.Run("no retries by default", func(t *testing.T) {
+		st := run(ScheduledToolCall{ID: "c", ToolName: "sms_send", ScheduledFor: base}, RetryPolicy{})
+		if len(st.retriedIDs) != 0 || len(st.failedIDs) != 1 {
+			t.Fatalf("retried = %v, failed = %v", st.retriedIDs, st.failedIDs)
+		}
+	})
+
+	t.Run("policy failures are not retried", func(t *testing.T) {
+		st := run(ScheduledToolCall{ID: "d", ToolName: "bash", ScheduledFor: base}, policy)
+		if len(st.retriedIDs) != 0 || len(st.failedIDs) != 1 {
+			t.Fatalf("retried = %v, failed = %v", st.retriedIDs, st.failedIDs)
+		}
+	})
+}
+
+func TestToolCallScheduler_misfire(t *testing.T) {
+	base := time.Date(2026, 2, 20, 10, 0, 0, 0, time.UTC)
+	origNow := nowFunc
+	t.Cleanup(func() { nowFunc = origNow })
+	nowFunc = func() time.Time { return base }
+
+	run := func(call ScheduledToolCall, skip bool) (*fakeSchedulerStore, int) {
+		st := &fakeSchedulerStore{dueJobs: []ScheduledToolCall{call}}
+		ran := 0
+		s := NewToolCallScheduler(st, time.Minute, func() *ToolContext {
+			return &ToolContext{ScheduledAllowed: map[string]bool{"sms_send": true}}
+		}, func(call ScheduledToolCall, ctx *ToolContext) (string, bool, error) {
+			ran++
+			return "ok", false, nil
+		})
+		s.SetPolicyFunc(func() RetryPolicy { return RetryPolicy{SkipMisfired: skip} })
+		s.started = base
+		if err := s.RunOnce(); err != nil {
+			t.Fatalf("RunOnce error: %v", err)
+		}
+		return st, ran
+	}
+
+	t.Run("skips a one-shot job missed while down", func(t *testing.T) {
+		st, ran := run(ScheduledToolCall{ID: "a", ToolName: "sms_send", ScheduledFor: base.Add(-3 * time.Hour), Recurrence: "once"}, true)
+		if ran != 0 || len(st.skippedIDs) != 1 || len(st.rescheduledIDs) != 0 {
+			t.Fatalf("ran = %d, skipped = %v, rescheduled = %v", ran, st.skippedIDs, st.rescheduledIDs)
+		}
+	})
+
+	t.Run("moves a recurring job to its next run", func(t *testing.T) {
+		st, ran := run(ScheduledToolCall{ID: "b", ToolName: "sms_send", ScheduledFor: base.Add(-150 * time.Minute), Recurrence: "hourly"}, true)
+		if ran != 0 || len(st.skippedIDs) != 1 || len(st.rescheduledIDs) != 1 {
+			t.Fatalf("ran = %d, skipped = %v, rescheduled = %v", ran, st.skippedIDs, st.rescheduledIDs)
+		}
+		if want := base.Add(30 * time.Minute); !st.rescheduledTo.Equal(want) {
+			t.Errorf("rescheduled to %v, want %v", st.rescheduledTo, want)
+		}
+	})
+
+	t.Run("runs jobs due since the start", func(t *testing.T) {
+		st, ran := run(ScheduledToolCall{ID: "c", ToolName: "sms_send", ScheduledFor: base.Add(-30 * time.Second), Recurrence: "once"}, true)
+		if ran != 1 || len(st.skippedIDs) != 0 {
+			t.Fatalf("ran = %d, skipped = %v", ran, st.skippedIDs)
+		}
+	})
+
+	t.Run("runs missed jobs under the run policy", func(t *testing.T) {
+		st, ran := run(ScheduledToolCall{ID: "d", ToolName: "sms_send", ScheduledFor: base.Add(-3 * time.Hour), Recurrence: "once"}, false)
+		if ran != 1 || len(st.skippedIDs) != 0 {
+			t.Fatalf("ran = %d, skipped = %v", ran, st.skippedIDs)
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+// ---------------------------------------------------------------------------
+// Shell selection
+// ---------------------------------------------------------------------------
+
+// Shell is the command interpreter used by the bash tool, custom tools and
+// shell mode, chosen with shell.backend.
+type Shell struct {
+	Kind string // config.ShellSh, ShellBash, ShellPwsh, ShellPowerShell or ShellCmd
+	Path string // executable
+}
+
+// lookPath is exec.LookPath, replaceable in tests.
+var lookPath = exec.LookPath
+
+// ResolveShell returns the shell for a shell.backend value. auto never fails:
+// it picks sh on Unix and Git Bash, PowerShell 7, Windows PowerShell, then
+// cmd on Windows. A named shell that is not installed is an error.
+func ResolveShell(pref string) (Shell, error) {
+	kind, err := config.ParseShell(pref)
+	if err != nil {
+		return Shell{}, err
+	}
+	if kind == config.ShellAuto {
+		return autoShell(runtime.GOOS), nil
+	}
+	if p := findShell(kind, runtime.GOOS); p != "" {
+		return Shell{Kind: kind, Path: p}, nil
+	}
+	return Shell{}, fmt.Errorf("shell.backend is %s but %s was not found", kind, kind)
+}
+
+func autoShell(goos string) Shell {
+	if goos != "windows" {
+		return Shell{Kind: config.ShellSh, Path: "/bin/sh"}
+	}
+	for _, kind := range []string{config.ShellBash, config.ShellPwsh, config.ShellPowerShell} {
+		if p := findShell(kind, goos); p != "" {
+			return Shell{Kind: kind, Path: p}
+		}
+	}
+	return Shell{Kind: config.ShellCmd, Path: "cmd.exe"}
+}
+
+// findShell locates kind's executable, or returns "".
+func findShell(kind, goos string) string {
+	var names []string
+	switch kind {
+	case config.ShellSh:
+		names = []string{"sh"}
+	case config.ShellBash:
+		if goos == "windows" {
+			if p := findGitBash(); p != "" {
+				return p
+			}
+		}
+		names = []string{"bash"}
+	case config.ShellPwsh:
+		names = []string{"pwsh"}
+	case config.ShellPowerShell:
+		names = []string{"powershell"}
+	case config.ShellCmd:
+		if goos != "windows" {
+			return ""
+		}
+		names = []string{"cmd"}
+	}
+	for _, n := range names {
+		if p, err := lookPath(n); err == nil {
+			return p
+		}
+	}
+	return ""
+}
+
+// findGitBash returns Git for Windows' bash from its usual install
+// locations. WSL's bash.exe in System32 is skipped: it runs commands in a
+// Linux distribution that cannot see Windows paths as the tools write them.
+func findGitBash() string {
+	for _, base := range []string{os.Getenv("ProgramFiles"), os.Getenv("ProgramFiles(x86)"), filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs")} {
+		if base == "" {
+			continue
+		}
+		p := filepath.Join(base, "Git", "bin", "bash.exe")
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	if p, err := lookPath("bash"); err == nil && !strings.EqualFold(filepath.Dir(p), filepath.Join(os.Getenv("SystemRoot"), "System32")) {
+		return p
+	}
+	return ""
+}
+
+// Name is how the shell is described to the model and the user.
+func (s Shell) Name() string {
+	switch s.Kind {
+	case config.ShellPwsh:
+		return "PowerShell 7 (pwsh)"
+	case config.ShellPowerShell:
+		return "Windows PowerShell"
+	case config.ShellCmd:
+		return "cmd.exe"
+	case config.ShellBash:
+		return "bash"
+	default:
+		return "sh"
+	}
+}
+
+// POSIX reports whether the shell takes POSIX sh syntax.
+func (s Shell) POSIX() bool {
+	return s.Kind == config.ShellSh || s.Kind == config.ShellBash
+}
+
+// Args returns the arguments that run command.
+func (s Shell) Args(command string) []string {
+	switch s.Kind {
+	case config.ShellPwsh, config.ShellPowerShell:
+		return []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-OutputFormat", "Text", "-EncodedCommand", encodePowerShell(powerShellScript(command))}
+	case config.ShellCmd:
+		return []string{"/S", "/C", command}
+	default:
+		return []string{"-c", command}
+	}
+}
+
+// powerShellScript wraps command so PowerShell writes UTF-8 whatever the
+// console code page, and exits with the last command's status: 0 on
+// success, a native program's own exit code, or 1 for a failed cmdlet.
+// Without it, -Command exits 0 or 1 and native exit codes are lost.
+func powerShellScript(command string) string {
+	return "$ProgressPreference = 'SilentlyContinue'\n" +
+		"[Console]::OutputEncoding = [System.Text.Encoding]::UTF8\n" +
+		"$OutputEncoding = [System.Text.Encoding]::UTF8\n" +
+		"$global:LASTEXITCODE = 0\n" +
+		command + "\n" +
+		"if ($?) { exit 0 }\n" +
+		"if ($LASTEXITCODE) { exit $LASTEXITCODE }\n" +
+		"exit 1\n"
+}
+
+// encodePowerShell encodes script for -EncodedCommand (base64 UTF-16LE),
+// which sidesteps PowerShell's own handling of quotes in its arguments.
+func encodePowerShell(script string) string {
+	units := utf16.Encode([]rune(script))
+	buf := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(buf[2*i:], u)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// CommandLine returns the Windows command line that runs command. cmd.exe
+// does not follow the usual argument quoting rules, so its command is passed
+// verbatim inside the quotes /S strips.
+func (s Shell) CommandLine(command string) string {
+	if s.Kind == config.ShellCmd {
+		return quoteWindowsArg(s.Path) + ` /S /C "` + command + `"`
+	}
+	args := append([]string{s.Path}, s.Args(command)...)
+	for i, a := range args {
+		args[i] = quoteWindowsArg(a)
+	}
+	return strings.Join(args, " ")
+}
+
+// Command returns an exec.Cmd that runs command in the shell.
+func (s Shell) Command(ctx context.Context, command string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, s.Path, s.Args(command)...)
+	if s.Kind == config.ShellCmd {
+		setCmdLine(cmd, s.CommandLine(command))
+	}
+	return cmd
+}
+
+// quoteWindowsArg quotes an argument following the rules of
+// CommandLineToArgvW, as Go does for exec on Windows.
+func quoteWindowsArg(s string) string {
+	if s == "" {
+		return `""`
+	}
+	if !strings.ContainsAny(s, " \t\n\v\"") {
+		return s
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	slashes := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '\\':
+			slashes++
+		case '"':
+			b.WriteString(strings.Repeat(`\`, slashes+1))
+			slashes = 0
+		default:
+			slashes = 0
+		}
+		b.WriteByte(c)
+	}
+	b.WriteString(strings.Repeat(`\`, slashes))
+	b.WriteByte('"')
+	return b.String()
+}
+
+// ShellPrompt tells the model which shell the bash tool uses when it does
+// not take POSIX syntax. Returns "" for sh and bash.
+func ShellPrompt(pref string) string {
+	s, err := ResolveShell(pref)
+	if err != nil || s.POSIX() {
+		return ""
+	}
+	return fmt.Sprintf("\n\nThe bash tool runs commands with %s, not a POSIX shell. Write commands in its syntax.", s.Name())
+}
+
+// ---------------------------------------------------------------------------
+// Paths
+// ---------------------------------------------------------------------------
+
+// NormalizePath converts a path the model wrote in Unix style to the
+// platform's form. On Windows, Git Bash (/c/Users), Cygwin (/cygdrive/c)
+// and WSL (/mnt/c) drive paths become C:\Users and forward slashes become
+// backslashes. Elsewhere the path is returned unchanged. Tools report paths
+// with forward slashes, which Windows accepts, so paths round-trip.
+func NormalizePath(p string) string {
+	if runtime.GOOS != "windows" {
+		return p
+	}
+	return windowsPath(p)
+}
+
+func windowsPath(p string) string {
+	s := strings.ReplaceAll(p, `\`, "/")
+	for _, prefix := range []string{"/cygdrive/", "/mnt/", "/"} {
+		rest, ok := strings.CutPrefix(s, prefix)
+		if !ok || len(rest) == 0 || !isDriveLetter(rest[0]) || len(rest) > 1 && rest[1] != '/' {
+			continue
+		}
+		s = strings.ToUpper(rest[:1]) + ":/" + strings.TrimPrefix(rest[1:], "/")
+		break
+	}
+	return strings.ReplaceAll(s, "/", `\`)
+}
+
+func isDriveLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// NormalizePathInput returns input with its path argument passed through
+// NormalizePath. The map is copied when the path changes.
+func NormalizePathInput(input map[string]any) map[string]any {
+	p, ok := input["path"].(string)
+	if !ok || p == "" {
+		return input
+	}
+	np := NormalizePath(p)
+	if np == p {
+		return input
+	}
+	out := make(map[string]any, len(input))
+	for k, v := range input {
+		out[k] = v
+	}
+	out["path"] = np
+	return out
+}
@@ -0,0 +1,266 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+func TestShell_Args(t *testing.T) {
+	tests := []struct {
+		kind string
+		want string
+	}{
+		{config.ShellSh, "-c|echo hi"},
+		{config.ShellBash, "-c|echo hi"},
+		{config.ShellPwsh, "-NoLogo|-NoProfile|-NonInteractive|-OutputFormat|Text|-EncodedCommand|" + encodePowerShell(powerShellScript("echo hi"))},
+		{config.ShellPowerShell, "-NoLogo|-NoProfile|-NonInteractive|-OutputFormat|Text|-EncodedCommand|" + encodePowerShell(powerShellScript("echo hi"))},
+		{config.ShellCmd, "/S|/C|echo hi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			got := strings.Join(Shell{Kind: tt.kind}.Args("echo hi"), "|")
+			if got != tt.want {
+				t.Errorf("Args = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShell_CommandLine(t *testing.T) {
+	tests := []struct {
+		name  string
+		shell Shell
+		cmd   string
+		want  string
+	}{
+		{"cmd verbatim", Shell{Kind: config.ShellCmd, Path: `C:\Windows\System32\cmd.exe`}, `dir "C:\Program Files"`, `C:\Windows\System32\cmd.exe /S /C "dir "C:\Program Files""`},
+		{"bash quoted path", Shell{Kind: config.ShellBash, Path: `C:\Program Files\Git\bin\bash.exe`}, "ls -la", `"C:\Program Files\Git\bin\bash.exe" -c "ls -la"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.shell.CommandLine(tt.cmd); got != tt.want {
+				t.Errorf("CommandLine = %s\nwant          %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodePowerShell(t *testing.T) {
+	got := encodePowerShell(`Write-Output "héllo"`)
+	raw, err := base64.StdEncoding.DecodeString(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	units := make([]uint16, len(raw)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(raw[2*i:])
+	}
+	if s := string(utf16.Decode(units)); s != `Write-Output "héllo"` {
+		t.Errorf("decoded = %q", s)
+	}
+}
+
+func TestPowerShellScript(t *testing.T) {
+	script := powerShellScript("git status")
+	if !strings.Contains(script, "\ngit status\n") {
+		t.Errorf("command missing from script:\n%s", script)
+	}
+	if !strings.Contains(script, "[Console]::OutputEncoding = [System.Text.Encoding]::UTF8") {
+		t.Error("script does not set UTF-8 output")
+	}
+	if !strings.HasSuffix(script, "if ($LASTEXITCODE) { exit $LASTEXITCODE }\nexit 1\n") {
+		t.Errorf("script does not propagate exit codes:\n%s", script)
+	}
+}
+
+func TestShell_powerShellExitCode(t *testing.T) {
+	path, err := exec.LookPath("pwsh")
+	if err != nil {
+		t.Skip("pwsh not installed")
+	}
+	sh := Shell{Kind: config.ShellPwsh, Path: path}
+	exit3 := "sh -c 'exit 3'"
+	if runtime.GOOS == "windows" {
+		exit3 = "cmd /c exit 3"
+	}
+	out, err := sh.Command(context.Background(), "Write-Output 'héllo'; "+exit3).CombinedOutput()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("err = %v, want exit code 3", err)
+	}
+	if !strings.Contains(string(out), "héllo") {
+		t.Errorf("output = %q, want UTF-8 héllo", out)
+	}
+}
+
+func TestQuoteWindowsArg(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", `""`},
+		{"plain", "plain"},
+		{`C:\dir\`, `C:\dir\`},
+		{`a b`, `"a b"`},
+		{`a b\`, `"a b\\"`},
+		{`say "hi"`, `"say \"hi\""`},
+		{`a\"b`, `"a\\\"b"`},
+	}
+	for _, tt := range tests {
+		if got := quoteWindowsArg(tt.in); got != tt.want {
+			t.Errorf("quoteWindowsArg(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResolveShell(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		if _, err := ResolveShell("fish"); err == nil {
+			t.Fatal("expected error for unknown shell")
+		}
+	})
+
+	t.Run("auto on unix", func(t *testing.T) {
+		if got := autoShell("linux"); got.Kind != config.ShellSh || got.Path != "/bin/sh" {
+			t.Errorf("autoShell(linux) = %+v", got)
+		}
+	})
+
+	t.Run("missing shell", func(t *testing.T) {
+		orig := lookPath
+		defer func() { lookPath = orig }()
+		lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+		if _, err := ResolveShell(config.ShellPwsh); err == nil || !strings.Contains(err.Error(), "not found") {
+			t.Errorf("err = %v, want not found", err)
+		}
+	})
+
+	t.Run("auto on windows falls back to cmd", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Git Bash lookup checks install directories")
+		}
+		orig := lookPath
+		defer func() { lookPath = orig }()
+		lookPath = func(string) (string, error) { return "", exec.ErrNotFound }
+		t.Setenv("ProgramFiles", "")
+		t.Setenv("ProgramFiles(x86)", "")
+		t.Setenv("LOCALAPPDATA", t.TempDir())
+		if got := autoShell("windows"); got.Kind != config.ShellCmd || got.Path != "cmd.exe" {
+			t.Errorf("autoShell(windows) = %+v, want cmd.exe", got)
+		}
+	})
+
+	t.Run("cmd only on windows", func(t *testing.T) {
+		if p := findShell(config.ShellCmd, "linux"); p != "" {
+			t.Errorf("findShell(cmd, linux) = %q, want empty", p)
+		}
+	})
+}
+
+func TestShellPrompt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("auto picks a platform-dependent shell")
+	}
+	if got := ShellPrompt(config.ShellAuto); got != "" {
+		t.Errorf("ShellPrompt(auto) = %q, want empty for sh", got)
+	}
+}
+
+func TestWindowsPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/c/Users/me/file.go", `C:\Users\me\file.go`},
+		{"/C/Users", `C:\Users`},
+		{"/cygdrive/d/src", `D:\src`},
+		{"/mnt/c/work/x.txt", `C:\work\x.txt`},
+		{"/c", `C:\`},
+		{"C:/Users/me", `C:\Users\me`},
+		{`C:\Users\me`, `C:\Users\me`},
+		{"src/main.go", `src\main.go`},
+		{"/usr/local", `\usr\local`},
+		{"/mnt/data/x", `\mnt\data\x`},
+	}
+	for _, tt := range tests {
+		if got := windowsPath(tt.in); got != tt.want {
+			t.Errorf("windowsPath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestResolvePathInput(t *testing.T) {
+	wd, _ := Getwd()
+	in := map[string]any{"path": "src/a.go"}
+	if out := ResolvePathInput(in, &ToolContext{Cwd: wd}); out["path"] != "src/a.go" {
+		t.Errorf("path changed in the process's directory: %v", out["path"])
+	}
+	if out := ResolvePathInput(in, nil); out["path"] != "src/a.go" {
+		t.Errorf("path changed without a context: %v", out["path"])
+	}
+
+	dir := t.TempDir()
+	ctx := &ToolContext{Cwd: dir}
+	if got := ctx.Dir(); got != dir {
+		t.Errorf("Dir() = %q, want %q", got, dir)
+	}
+	out := ResolvePathInput(in, ctx)
+	if out["path"] != filepath.Join(dir, "src", "a.go") {
+		t.Errorf("path = %v", out["path"])
+	}
+	if in["path"] != "src/a.go" {
+		t.Error("input map was modified")
+	}
+	abs := map[string]any{"path": wd}
+	if out := ResolvePathInput(abs, ctx); out["path"] != wd {
+		t.Errorf("absolute path changed: %v", out["path"])
+	}
+}
+
+func TestNormalizePathInput(t *testing.T) {
+	in := map[string]any{"path": "/c/src/a.go", "content": "x"}
+	out := NormalizePathInput(in)
+	if runtime.GOOS != "windows" {
+		if out["path"] != "/c/src/a.go" {
+			t.Errorf("path changed off Windows: %v", out["path"])
+		}
+		return
+	}
+	if out["path"] != `C:\src\a.go` || out["content"] != "x" {
+		t.Errorf("out = %v", out)
+	}
+	if in["path"] != "/c/src/a.go" {
+		t.Error("input map was modified")
+	}
+}
+
+func TestWrittenPaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		tool  string
+		input map[string]any
+		want  []string
+	}{
+		{"file_write", "file_write", map[string]any{"path": "a.go", "content": "x"}, []string{"a.go"}},
+		{"file_edit", "file_edit", map[string]any{"path": "/abs/b.go"}, []string{"/abs/b.go"}},
+		{"patch_apply", "patch_apply", map[string]any{"patch": "--- a/x.go\n+++ b/x.go\n@@ -1 +1 @@\n-a\n+b\n--- /dev/null\n+++ b/y.go\n@@ -0,0 +1 @@\n+c\n"}, []string{"x.go", "y.go"}},
+		{"bash", "bash", map[string]any{"command": "rm -rf build"}, nil},
+		{"missing path", "file_write", map[string]any{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WrittenPaths(tt.tool, tt.input)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("WrittenPaths = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
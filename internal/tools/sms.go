@@ -6,16 +6,18 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/batalabs/muxd/internal/egress"
 	"github.com/batalabs/muxd/internal/provider"
 )
 
 const smsTimeout = 15 * time.Second
 
 // smsHTTPClient is overridable in tests.
-var smsHTTPClient = &http.Client{Timeout: smsTimeout}
+var smsHTTPClient = &http.Client{Timeout: smsTimeout, Transport: egress.Transport(nil)}
 
 // smsTextURL is the Textbelt endpoint for sending SMS.
 var smsTextURL = "https://textbelt.com/text"
@@ -36,6 +38,7 @@ func smsSendTool() ToolDef {
 			Properties: map[string]provider.ToolProp{
 				"phone":   {Type: "string", Description: "Phone number to send to. U.S./Canada: 10-digit with area code. International: E.164 format with country code (e.g. +44...)"},
 				"message": {Type: "string", Description: "The SMS message content"},
+				"account": {Type: "string", Description: "Optional named Textbelt account to send from (see textbelt.accounts). Omit to use the default key."},
 			},
 			Required: []string{"phone", "message"},
 		},
@@ -49,12 +52,9 @@ func smsSendTool() ToolDef {
 				return "", fmt.Errorf("message is required")
 			}
 
-			apiKey := ""
-			if ctx != nil {
-				apiKey = ctx.TextbeltAPIKey
-			}
-			if apiKey == "" {
-				return "", fmt.Errorf("textbelt API key not configured, use /config set textbelt.api_key <key>")
+			apiKey, err := textbeltKey(ctx, input)
+			if err != nil {
+				return "", err
 			}
 
 			return textbeltSend(phone, message, apiKey)
@@ -62,6 +62,34 @@ func smsSendTool() ToolDef {
 	}
 }
 
+// textbeltKey resolves the Textbelt API key for a tool call: the named
+// account in input["account"], or the default key when none is given.
+func textbeltKey(ctx *ToolContext, input map[string]any) (string, error) {
+	if ctx == nil {
+		ctx = &ToolContext{}
+	}
+	account, _ := input["account"].(string)
+	account = strings.ToLower(strings.TrimSpace(account))
+	if account == "" {
+		if ctx.TextbeltAPIKey == "" {
+			return "", fmt.Errorf("textbelt API key not configured, use /config set textbelt.api_key <key>")
+		}
+		return ctx.TextbeltAPIKey, nil
+	}
+	if key, ok := ctx.TextbeltAccounts[account]; ok {
+		return key, nil
+	}
+	names := make([]string, 0, len(ctx.TextbeltAccounts))
+	for name := range ctx.TextbeltAccounts {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("unknown textbelt account %q: no accounts configured, use /config set textbelt.accounts name=key,...", account)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown textbelt account %q (available: %s)", account, strings.Join(names, ", "))
+}
+
 // textbeltSendResponse is the JSON response from the /text endpoint.
 // TextID is any because the API returns it as a string despite docs showing int.
 type textbeltSendResponse struct {
@@ -167,14 +195,16 @@ func smsScheduleTool() ToolDef {
 	return ToolDef{
 		Spec: provider.ToolSpec{
 			Name:        "sms_schedule",
-			Description: "Schedule an SMS for later sending. Time format: RFC3339 (e.g. '2026-03-01T14:00:00Z') or HH:MM for today/tomorrow. Recurrence: once, daily, or hourly.",
+			Description: "Schedule an SMS for later sending. Time format: RFC3339 (e.g. '2026-03-01T14:00:00Z') or HH:MM for today/tomorrow. Recurrence: once, hourly, daily, weekly, or a cron expression.",
 			Properties: map[string]provider.ToolProp{
 				"phone":      {Type: "string", Description: "Phone number to send to"},
 				"message":    {Type: "string", Description: "The SMS message content"},
-				"time":       {Type: "string", Description: "Schedule time: RFC3339 or HH:MM (local time)"},
-				"recurrence": {Type: "string", Description: "Optional recurrence: once, daily, hourly"},
+				"time":       {Type: "string", Description: "Schedule time: RFC3339 or HH:MM (local time). Optional with a cron recurrence"},
+				"recurrence": {Type: "string", Description: "Optional recurrence: once, hourly, daily, weekly, or a five-field cron expression such as '0 9 * * MON'"},
+				"timezone":   {Type: "string", Description: "Optional IANA time zone for a cron recurrence (default: local time)"},
+				"account":    {Type: "string", Description: "Optional named Textbelt account to send from (see textbelt.accounts)"},
 			},
-			Required: []string{"phone", "message", "time"},
+			Required: []string{"phone", "message"},
 		},
 		Execute: func(input map[string]any, ctx *ToolContext) (string, error) {
 			if ctx == nil || ctx.ScheduleTool == nil {
@@ -189,39 +219,42 @@ func smsScheduleTool() ToolDef {
 			if !ok || message == "" {
 				return "", fmt.Errorf("message is required")
 			}
-			timeStr, ok := input["time"].(string)
-			if !ok || timeStr == "" {
-				return "", fmt.Errorf("time is required")
-			}
-
-			apiKey := ""
-			if ctx != nil {
-				apiKey = ctx.TextbeltAPIKey
+			recurrence, scheduledFor, err := scheduleRecurrence(input, nowFunc())
+			if err != nil {
+				return "", err
 			}
-			if apiKey == "" {
-				return "", fmt.Errorf("textbelt API key not configured, use /config set textbelt.api_key <key>")
+			timeStr, _ := input["time"].(string)
+			if timeStr == "" && scheduledFor.IsZero() {
+				return "", fmt.Errorf("time is required")
 			}
 
-			scheduledFor, err := parseScheduleTime(timeStr)
-			if err != nil {
+			if _, err := textbeltKey(ctx, input); err != nil {
 				return "", err
 			}
 
-			recurrence := "once"
-			if v, ok := input["recurrence"].(string); ok && v != "" {
-				recurrence = strings.ToLower(v)
+			if timeStr != "" {
+				if scheduledFor, err = parseScheduleTime(timeStr); err != nil {
+					return "", err
+				}
 			}
 
 			toolInput := map[string]any{
 				"phone":   phone,
 				"message": message,
 			}
+			if account, _ := input["account"].(string); account != "" {
+				toolInput["account"] = account
+			}
 
 			id, err := ctx.ScheduleTool("sms_send", toolInput, scheduledFor, recurrence)
 			if err != nil {
 				return "", fmt.Errorf("scheduling SMS: %w", err)
 			}
 
+			if IsDraftJob(ctx.DraftTools, "sms_send") {
+				return fmt.Sprintf("Queued SMS draft to %s for %s (id: %s, recurrence: %s). It will not be sent until the user approves it with /drafts approve.",
+					phone, scheduledFor.Format(time.RFC3339), id, recurrence), nil
+			}
 			return fmt.Sprintf("Scheduled SMS to %s for %s (id: %s, recurrence: %s)",
 				phone, scheduledFor.Format(time.RFC3339), id, recurrence), nil
 		},
@@ -130,6 +130,41 @@ func TestSmsSendTool(t *testing.T) {
 	})
 }
 
+func TestTextbeltKey(t *testing.T) {
+	ctx := &ToolContext{
+		TextbeltAPIKey:   "default-key",
+		TextbeltAccounts: map[string]string{"work": "work-key", "personal": "personal-key"},
+	}
+
+	t.Run("defaults without account", func(t *testing.T) {
+		key, err := textbeltKey(ctx, map[string]any{})
+		if err != nil || key != "default-key" {
+			t.Errorf("got %q, %v; want default-key", key, err)
+		}
+	})
+
+	t.Run("selects named account", func(t *testing.T) {
+		key, err := textbeltKey(ctx, map[string]any{"account": " Work "})
+		if err != nil || key != "work-key" {
+			t.Errorf("got %q, %v; want work-key", key, err)
+		}
+	})
+
+	t.Run("unknown account lists available", func(t *testing.T) {
+		_, err := textbeltKey(ctx, map[string]any{"account": "team"})
+		if err == nil || !strings.Contains(err.Error(), "available: personal, work") {
+			t.Errorf("expected unknown account error, got: %v", err)
+		}
+	})
+
+	t.Run("named account works without default key", func(t *testing.T) {
+		key, err := textbeltKey(&ToolContext{TextbeltAccounts: map[string]string{"work": "w"}}, map[string]any{"account": "work"})
+		if err != nil || key != "w" {
+			t.Errorf("got %q, %v; want w", key, err)
+		}
+	})
+}
+
 // ---------------------------------------------------------------------------
 // sms_status
 // ---------------------------------------------------------------------------
@@ -257,6 +292,60 @@ func TestSmsScheduleTool(t *testing.T) {
 		}
 	})
 
+	t.Run("reports drafts awaiting approval", func(t *testing.T) {
+		ctx := &ToolContext{
+			TextbeltAPIKey: "test-key",
+			DraftTools:     map[string]bool{"sms_send": true},
+			ScheduleTool: func(toolName string, input map[string]any, scheduledFor time.Time, recurrence string) (string, error) {
+				return "draft-1", nil
+			},
+		}
+		result, err := tool.Execute(map[string]any{
+			"phone":   "5555555555",
+			"message": "Launch day",
+			"time":    "2026-03-01T14:00:00Z",
+		}, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(result, "draft") || !strings.Contains(result, "/drafts approve") {
+			t.Errorf("expected draft message, got: %s", result)
+		}
+	})
+
+	t.Run("keeps account on scheduled job", func(t *testing.T) {
+		var capturedInput map[string]any
+		ctx := &ToolContext{
+			TextbeltAccounts: map[string]string{"product": "product-key"},
+			ScheduleTool: func(toolName string, input map[string]any, scheduledFor time.Time, recurrence string) (string, error) {
+				capturedInput = input
+				return "job-456", nil
+			},
+		}
+		_, err := tool.Execute(map[string]any{
+			"phone":   "5555555555",
+			"message": "Launch day",
+			"time":    "2026-03-01T14:00:00Z",
+			"account": "product",
+		}, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if capturedInput["account"] != "product" {
+			t.Errorf("expected account in scheduled input, got: %v", capturedInput)
+		}
+
+		_, err = tool.Execute(map[string]any{
+			"phone":   "5555555555",
+			"message": "Launch day",
+			"time":    "2026-03-01T14:00:00Z",
+			"account": "missing",
+		}, ctx)
+		if err == nil {
+			t.Fatal("expected error for unknown account")
+		}
+	})
+
 	t.Run("schedules SMS with HH:MM time", func(t *testing.T) {
 		origNow := nowFunc
 		nowFunc = func() time.Time {
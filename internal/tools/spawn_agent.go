@@ -0,0 +1,164 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// ---------------------------------------------------------------------------
+// spawn_agent -bounded parallel sub-agents
+// ---------------------------------------------------------------------------
+
+// Sub-agent limits for spawn_agent.
+const (
+	MaxSpawnedAgents        = 8       // workers per spawn_agent call
+	DefaultSubAgentTurns    = 15      // model calls per worker unless max_turns is set
+	DefaultSubAgentTokens   = 200_000 // input+output tokens per worker unless max_tokens is set
+	maxSubAgentResultOutput = 20 * 1024
+)
+
+// SubAgentSpec describes one worker started by spawn_agent.
+type SubAgentSpec struct {
+	Name      string
+	Prompt    string
+	Tools     []string // allowed tools; empty allows every sub-agent tool
+	MaxTurns  int
+	MaxTokens int
+}
+
+// SubAgentResult is the outcome of one spawn_agent worker.
+type SubAgentResult struct {
+	Name      string
+	SessionID string // the worker's own session, when sessions are stored
+	State     string // done, stopped (hit a limit) or failed
+	Output    string
+	Turns     int
+	Tokens    int
+	Err       string
+}
+
+func spawnAgentTool() ToolDef {
+	return ToolDef{
+		Spec: provider.ToolSpec{
+			Name: "spawn_agent",
+			Description: "Run several bounded sub-agents in parallel and collect their results. Each worker gets its own session with a fresh conversation, " +
+				"an optional allow-list of tools, and limits on model turns and tokens; a worker that hits a limit returns what it has so far. " +
+				"Workers cannot spawn agents or ask the user questions. Use this for independent research or worker tasks that can run side by side; " +
+				"give each worker a complete, self-contained prompt.",
+			Properties: map[string]provider.ToolProp{
+				"agents": {
+					Type:        "array",
+					Description: fmt.Sprintf("Workers to run, at most %d", MaxSpawnedAgents),
+					Items: &provider.ToolProp{
+						Type: "object",
+						Properties: map[string]provider.ToolProp{
+							"name":       {Type: "string", Description: "Short name for the worker (a few words)"},
+							"prompt":     {Type: "string", Description: "Complete instructions for the worker"},
+							"tools":      {Type: "array", Description: "Tool names the worker may use; omit for all sub-agent tools", Items: &provider.ToolProp{Type: "string"}},
+							"max_turns":  {Type: "integer", Description: fmt.Sprintf("Maximum model calls (default %d)", DefaultSubAgentTurns)},
+							"max_tokens": {Type: "integer", Description: fmt.Sprintf("Maximum input+output tokens (default %d)", DefaultSubAgentTokens)},
+						},
+						Required: []string{"name", "prompt"},
+					},
+				},
+			},
+			Required: []string{"agents"},
+		},
+		Execute: func(input map[string]any, ctx *ToolContext) (string, error) {
+			if ctx == nil || ctx.SpawnAgents == nil {
+				return "", fmt.Errorf("sub-agent spawning not available")
+			}
+			specs, err := parseSubAgentSpecs(input)
+			if err != nil {
+				return "", err
+			}
+			return formatSubAgentResults(ctx.SpawnAgents(specs)), nil
+		},
+	}
+}
+
+// parseSubAgentSpecs validates the agents input of spawn_agent and fills in
+// default limits.
+func parseSubAgentSpecs(input map[string]any) ([]SubAgentSpec, error) {
+	raw, _ := input["agents"].([]any)
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("agents is required")
+	}
+	if len(raw) > MaxSpawnedAgents {
+		return nil, fmt.Errorf("at most %d agents per call, got %d", MaxSpawnedAgents, len(raw))
+	}
+	specs := make([]SubAgentSpec, 0, len(raw))
+	for i, r := range raw {
+		obj, ok := r.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("agents[%d] must be an object", i)
+		}
+		spec := SubAgentSpec{MaxTurns: DefaultSubAgentTurns, MaxTokens: DefaultSubAgentTokens}
+		spec.Name, _ = obj["name"].(string)
+		spec.Prompt, _ = obj["prompt"].(string)
+		spec.Name = strings.TrimSpace(spec.Name)
+		if spec.Name == "" {
+			spec.Name = fmt.Sprintf("agent %d", i+1)
+		}
+		if strings.TrimSpace(spec.Prompt) == "" {
+			return nil, fmt.Errorf("agents[%d] (%s): prompt is required", i, spec.Name)
+		}
+		if list, ok := obj["tools"].([]any); ok {
+			for _, t := range list {
+				name, _ := t.(string)
+				if name == "" {
+					continue
+				}
+				if IsSubAgentTool(name) {
+					return nil, fmt.Errorf("agents[%d] (%s): tool %s is not available to sub-agents", i, spec.Name, name)
+				}
+				spec.Tools = append(spec.Tools, name)
+			}
+		}
+		if v, ok := obj["max_turns"].(float64); ok && v > 0 {
+			spec.MaxTurns = int(v)
+		}
+		if v, ok := obj["max_tokens"].(float64); ok && v > 0 {
+			spec.MaxTokens = int(v)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
+
+// formatSubAgentResults renders worker results as one tool result, each
+// output capped so a chatty worker cannot crowd out the others.
+func formatSubAgentResults(results []SubAgentResult) string {
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "## %s (%s, %d turns, %d tokens", r.Name, r.State, r.Turns, r.Tokens)
+		if r.SessionID != "" {
+			fmt.Fprintf(&b, ", session %s", shortID(r.SessionID))
+		}
+		b.WriteString(")\n")
+		if r.Err != "" {
+			fmt.Fprintf(&b, "Error: %s\n", r.Err)
+		}
+		out := strings.TrimSpace(r.Output)
+		if len(out) > maxSubAgentResultOutput {
+			out = out[:maxSubAgentResultOutput] + "\n... (output truncated at 20KB)"
+		}
+		if out == "" {
+			out = "(no output)"
+		}
+		b.WriteString(out)
+	}
+	return b.String()
+}
+
+func shortID(id string) string {
+	if len(id) > 8 {
+		return id[:8]
+	}
+	return id
+}
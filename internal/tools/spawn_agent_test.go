@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpawnAgentTool(t *testing.T) {
+	tool := spawnAgentTool()
+
+	t.Run("nil SpawnAgents returns error", func(t *testing.T) {
+		_, err := tool.Execute(map[string]any{
+			"agents": []any{map[string]any{"name": "a", "prompt": "p"}},
+		}, &ToolContext{})
+		if err == nil {
+			t.Fatal("expected error for nil SpawnAgents")
+		}
+	})
+
+	t.Run("runs workers and formats results", func(t *testing.T) {
+		var got []SubAgentSpec
+		ctx := &ToolContext{SpawnAgents: func(specs []SubAgentSpec) []SubAgentResult {
+			got = specs
+			return []SubAgentResult{
+				{Name: "docs", State: "done", Output: "found it", Turns: 2, Tokens: 900, SessionID: "0123456789abcdef"},
+				{Name: "tests", State: "stopped", Turns: 3, Tokens: 5000, Err: "sub-agent turn limit reached (3 turns)"},
+			}
+		}}
+		out, err := tool.Execute(map[string]any{
+			"agents": []any{
+				map[string]any{"name": "docs", "prompt": "read the docs", "tools": []any{"file_read", "grep"}},
+				map[string]any{"name": "tests", "prompt": "run tests", "max_turns": float64(3), "max_tokens": float64(5000)},
+			},
+		}, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d specs, want 2", len(got))
+		}
+		if got[0].MaxTurns != DefaultSubAgentTurns || got[0].MaxTokens != DefaultSubAgentTokens || strings.Join(got[0].Tools, ",") != "file_read,grep" {
+			t.Errorf("spec 0 = %+v", got[0])
+		}
+		if got[1].MaxTurns != 3 || got[1].MaxTokens != 5000 || got[1].Tools != nil {
+			t.Errorf("spec 1 = %+v", got[1])
+		}
+		for _, want := range []string{
+			"## docs (done, 2 turns, 900 tokens, session 01234567)\nfound it",
+			"## tests (stopped, 3 turns, 5000 tokens)\nError: sub-agent turn limit reached (3 turns)\n(no output)",
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("output missing %q:\n%s", want, out)
+			}
+		}
+	})
+}
+
+func TestParseSubAgentSpecs(t *testing.T) {
+	tooMany := make([]any, MaxSpawnedAgents+1)
+	for i := range tooMany {
+		tooMany[i] = map[string]any{"name": "a", "prompt": "p"}
+	}
+	tests := []struct {
+		name    string
+		agents  any
+		wantErr string
+	}{
+		{"missing", nil, "agents is required"},
+		{"empty", []any{}, "agents is required"},
+		{"too many", tooMany, "at most"},
+		{"not an object", []any{"x"}, "must be an object"},
+		{"missing prompt", []any{map[string]any{"name": "a"}}, "prompt is required"},
+		{"excluded tool", []any{map[string]any{"name": "a", "prompt": "p", "tools": []any{"spawn_agent"}}}, "not available to sub-agents"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSubAgentSpecs(map[string]any{"agents": tt.agents})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("default name", func(t *testing.T) {
+		specs, err := parseSubAgentSpecs(map[string]any{"agents": []any{map[string]any{"prompt": "p"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if specs[0].Name != "agent 1" {
+			t.Errorf("Name = %q, want %q", specs[0].Name, "agent 1")
+		}
+	})
+}
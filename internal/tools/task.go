@@ -50,7 +50,7 @@ func taskTool() ToolDef {
 // IsSubAgentTool returns true for tool names that should not be available
 // to sub-agents (to prevent recursion).
 func IsSubAgentTool(name string) bool {
-	return name == "task" || name == "schedule_task" || name == "hub_dispatch" ||
+	return name == "task" || name == "spawn_agent" || name == "schedule_task" || name == "hub_dispatch" ||
 		name == "tool_create" || name == "tool_register" || name == "tool_list_custom" ||
 		name == "consult"
 }
@@ -2,13 +2,12 @@ package tools
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
@@ -18,6 +17,7 @@ import (
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/diff"
 	"github.com/batalabs/muxd/internal/docread"
+	"github.com/batalabs/muxd/internal/guardrail"
 	"github.com/batalabs/muxd/internal/provider"
 )
 
@@ -65,9 +65,13 @@ type ToolContext struct {
 	Todos              *TodoList
 	Memory             *ProjectMemory
 	PlanMode           *bool
+	PlanLocked         bool // plan mode was turned on by the user; plan_exit is refused
 	Disabled           map[string]bool
 	ScheduledAllowed   map[string]bool
+	Shell              string        // shell.backend; empty picks the platform default
+	ShellSession       *ShellSession // persistent shell for bash; nil runs each command alone
 	SpawnAgent         func(description, prompt string) (string, error)
+	SpawnAgents        func(specs []SubAgentSpec) []SubAgentResult
 	ScheduleTool       func(toolName string, input map[string]any, scheduledFor time.Time, recurrence string) (string, error)
 	ListScheduledJobs  func(toolName string, limit int) ([]ScheduledJobInfo, error)
 	CancelScheduledJob func(id string) error
@@ -75,11 +79,19 @@ type ToolContext struct {
 	ConsultFunc        func(summary string) (model string, response string, err error)
 	PushHubMemory      func(facts map[string]string) error
 	BraveAPIKey        string
+	Cite               func(url, title string) int // numbers a web source for [N] citations; nil leaves results unnumbered
 	TextbeltAPIKey     string
+	TextbeltAccounts   map[string]string // named Textbelt keys (textbelt.accounts)
+	DraftTools         map[string]bool   // scheduled calls of these tools wait for approval (scheduler.draft_tools)
 	MCP                MCPManager
 	HubDiscovery       func() ([]HubNodeInfo, error)                     // returns node info from hub
 	HubDispatch        func(nodeIDOrName, prompt string) (string, error) // dispatch task to remote node
 	CustomTools        *CustomToolRegistry
+	OutboundGuardrail  guardrail.Policy                        // screens messaging tool input before sending
+	OutboundPII        guardrail.PIIPolicy                     // masks or confirms personal data in messaging tool input
+	AskUser            func(question string) (string, bool)    // asks the interactive user; false if unavailable or canceled
+	Audit              func(toolName, decision, detail string) // records an outbound content decision
+	Prefetch           *PrefetchCache                          // files warmed while the model streamed; nil disables
 }
 
 // ToolFunc is the signature for tool execution functions.
@@ -95,16 +107,74 @@ type ToolDef struct {
 // Override in tests to control the working directory.
 var Getwd = os.Getwd
 
+// Dir returns the directory relative paths are resolved against: "." when
+// ctx's Cwd is the process's own working directory (or unset), and Cwd when
+// the session runs somewhere else.
+func (ctx *ToolContext) Dir() string {
+	if ctx == nil || ctx.Cwd == "" {
+		return "."
+	}
+	if wd, _ := Getwd(); wd == ctx.Cwd {
+		return "."
+	}
+	return ctx.Cwd
+}
+
+// ResolvePathInput returns input with a relative path argument joined to
+// ctx.Dir(). The map is copied when the path changes.
+func ResolvePathInput(input map[string]any, ctx *ToolContext) map[string]any {
+	p, ok := input["path"].(string)
+	dir := ctx.Dir()
+	if !ok || p == "" || dir == "." || filepath.IsAbs(p) {
+		return input
+	}
+	out := make(map[string]any, len(input))
+	for k, v := range input {
+		out[k] = v
+	}
+	out["path"] = filepath.Join(dir, p)
+	return out
+}
+
+// WrittenPaths returns the files a call to the named tool would write, as
+// given in its input: path for file_write and file_edit, and each
+// destination in a patch_apply patch. Other tools, bash included, give
+// nil, since what they change is not known before they run.
+func WrittenPaths(name string, input map[string]any) []string {
+	switch name {
+	case "file_write", "file_edit":
+		if p, _ := input["path"].(string); p != "" {
+			return []string{NormalizePath(p)}
+		}
+	case "patch_apply":
+		patch, _ := input["patch"].(string)
+		files, err := parsePatch(patch)
+		if err != nil {
+			return nil
+		}
+		var paths []string
+		for _, fd := range files {
+			if fd.path != "" && fd.path != "/dev/null" {
+				paths = append(paths, fd.path)
+			}
+		}
+		return paths
+	}
+	return nil
+}
+
 // AllTools returns the full list of tool definitions.
 // PTC (AllowedCallers) and Tool Search (DeferLoading) infrastructure is in the
 // provider layer but disabled by default. Set these fields on individual tools
 // to enable once compatibility with your model is confirmed.
+// In compliance mode, external messaging tools are omitted.
 func AllTools() []ToolDef {
-	return []ToolDef{
+	all := []ToolDef{
 		fileReadTool(),
 		fileWriteTool(),
 		fileEditTool(),
 		bashTool(),
+		bashResetTool(),
 		grepTool(),
 		globTool(),
 		listFilesTool(),
@@ -122,6 +192,7 @@ func AllTools() []ToolDef {
 		planEnterTool(),
 		planExitTool(),
 		taskTool(),
+		spawnAgentTool(),
 		gitStatusTool(),
 		memoryReadTool(),
 		memoryWriteTool(),
@@ -135,6 +206,16 @@ func AllTools() []ToolDef {
 		toolListCustomDef(),
 		consultToolDef(),
 	}
+	if !ComplianceMode() {
+		return all
+	}
+	filtered := all[:0]
+	for _, t := range all {
+		if !IsMessagingTool(t.Spec.Name) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
 }
 
 // AllToolSpecs returns the provider-agnostic tool specifications.
@@ -173,7 +254,7 @@ func FindToolWithCustom(name string, registry *CustomToolRegistry) *ToolDef {
 	td := ToolDef{
 		Spec: def.ToSpec(),
 		Execute: func(input map[string]any, ctx *ToolContext) (string, error) {
-			return registry.Execute(def.Name, input, ctx.Cwd)
+			return registry.Execute(def.Name, input, ctx.Cwd, ctx.Shell)
 		},
 	}
 	return &td
@@ -235,6 +316,21 @@ func ToolRiskTags(name string) []string {
 	}
 }
 
+// ToolProfiles lists the preset profiles, from most to least restricted
+// shell and network access.
+var ToolProfiles = []string{"safe", "coder", "research"}
+
+// ToolProfilesFor returns the profiles that leave name enabled.
+func ToolProfilesFor(name string) []string {
+	var out []string
+	for _, p := range ToolProfiles {
+		if !ToolProfileDisabledSet(p)[name] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
 // ToolProfileDisabledSet returns disabled tools for a named preset profile.
 // Profiles: safe, coder, research.
 func ToolProfileDisabledSet(profile string) map[string]bool {
@@ -322,9 +418,17 @@ func fileReadTool() ToolDef {
 				return strings.Join(lines[start:end], "\n"), nil
 			}
 
-			data, err := os.ReadFile(path)
-			if err != nil {
-				return "", fmt.Errorf("reading %s: %w", path, err)
+			var prefetched *PrefetchCache
+			if ctx != nil {
+				prefetched = ctx.Prefetch
+			}
+			data, ok := prefetched.Get(path)
+			if !ok {
+				var err error
+				data, err = os.ReadFile(path)
+				if err != nil {
+					return "", fmt.Errorf("reading %s: %w", path, err)
+				}
 			}
 
 			text := strings.ReplaceAll(string(data), "\r\n", "\n")
@@ -486,7 +590,7 @@ func bashTool() ToolDef {
 	return ToolDef{
 		Spec: provider.ToolSpec{
 			Name:        "bash",
-			Description: "Run a shell command and return stdout+stderr. Use for git, build commands, installers, and other CLI tools. Prefer file_read/file_edit/grep for file operations.",
+			Description: "Run a shell command and return stdout+stderr. Use for git, build commands, installers, and other CLI tools. Prefer file_read/file_edit/grep for file operations. Commands run in one persistent shell, so cd, exported variables, and activated virtualenvs carry over to later calls; file tools still resolve relative paths against the project directory. Commands get no stdin. Use bash_reset for a clean shell.",
 			Properties: map[string]provider.ToolProp{
 				"command": {Type: "string", Description: "Shell command to execute"},
 				"timeout": {Type: "integer", Description: "Timeout in seconds (default: 30, max: 120)"},
@@ -513,82 +617,125 @@ func bashTool() ToolDef {
 			} else {
 				parent = context.Background()
 			}
-			cmdCtx, cancel := context.WithTimeout(parent, time.Duration(timeout)*time.Second)
-			defer cancel()
-
-			var cmd *exec.Cmd
-			if runtime.GOOS == "windows" {
-				// Prefer a POSIX shell when command syntax requires it (e.g. heredocs).
-				if needsPosixShell(command) {
-					if _, err := exec.LookPath("bash"); err == nil {
-						cmd = exec.CommandContext(cmdCtx, "bash", "-lc", command)
-					} else if _, err := exec.LookPath("sh"); err == nil {
-						cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
-					}
-				}
-				if cmd == nil {
-					cmd = exec.CommandContext(cmdCtx, "cmd", "/C", command)
-				}
-			} else {
-				cmd = exec.CommandContext(cmdCtx, "sh", "-c", command)
+
+			shellPref := ""
+			if ctx != nil {
+				shellPref = ctx.Shell
+			}
+			shell, err := ResolveShell(shellPref)
+			if err != nil {
+				return "", err
 			}
 			cwd, _ := Getwd()
-			cmd.Dir = cwd
-
-			// Kill the entire process group so child processes don't
-			// survive after cancellation.
-			setProcGroup(cmd)
-
-			// Redirect stdout/stderr to a temp file instead of pipes.
-			// When output goes to an *os.File, Go passes the handle
-			// directly to the child process without creating I/O-copying
-			// goroutines. This means cmd.Wait() only blocks on process
-			// exit — not on pipe reads — and is fully cancellable by
-			// cmd.Cancel (taskkill on Windows, kill -PGID on Unix).
-			// With pipes, child processes that inherit the pipe handles
-			// (e.g. a launched browser) keep them open after the parent
-			// exits, causing CombinedOutput to hang indefinitely.
-			tmpFile, tmpErr := os.CreateTemp("", "muxd-bash-*.txt")
-			if tmpErr != nil {
-				return "", fmt.Errorf("creating temp file: %w", tmpErr)
-			}
-			tmpPath := tmpFile.Name()
-			defer os.Remove(tmpPath)
-
-			cmd.Stdout = tmpFile
-			cmd.Stderr = tmpFile
-
-			// WaitDelay as a safety net: if Cancel fails to kill the
-			// process, Wait returns after this delay regardless.
-			cmd.WaitDelay = bashWaitDelay
-
-			err := cmd.Run()
-			tmpFile.Close()
-
-			out, _ := os.ReadFile(tmpPath)
-			result := string(out)
-			if len(result) > 50*1024 {
-				result = result[:50*1024] + "\n... (truncated at 50KB)"
+			if dir := ctx.Dir(); dir != "." {
+				cwd = dir
 			}
-
-			if err != nil {
-				if cmdCtx.Err() == context.Canceled {
-					return result + "\n(canceled)", nil
-				}
-				if cmdCtx.Err() == context.DeadlineExceeded {
-					return result + "\n(command timed out after " + strconv.Itoa(timeout) + "s)", nil
-				}
-				return result + "\n(exit code: " + err.Error() + ")", nil
+			if ctx != nil && ctx.ShellSession != nil && shell.POSIX() {
+				return runBashInSession(parent, ctx.ShellSession, shell, cwd, command, timeout), nil
 			}
-
-			return result, nil
+			return runBashOnce(parent, shell, cwd, command, timeout)
 		},
 	}
 }
 
-func needsPosixShell(command string) bool {
-	// Minimal heuristic: heredoc syntax is unsupported in cmd.exe.
-	return strings.Contains(command, "<<")
+// runBashInSession runs command in the session's persistent shell.
+func runBashInSession(parent context.Context, session *ShellSession, shell Shell, cwd, command string, timeout int) string {
+	result, code, err := session.Run(parent, shell, cwd, command, time.Duration(timeout)*time.Second)
+	switch {
+	case errors.Is(err, context.Canceled):
+		return result + "\n(canceled; the shell was restarted)"
+	case errors.Is(err, context.DeadlineExceeded):
+		return result + "\n(command timed out after " + strconv.Itoa(timeout) + "s; the shell was restarted)"
+	case errors.Is(err, ErrShellExited):
+		return result + "\n(shell exited with code " + strconv.Itoa(code) + "; the next command starts a new shell)"
+	case err != nil:
+		return result + "\n(" + err.Error() + ")"
+	case code != 0:
+		return result + "\n(exit code: " + strconv.Itoa(code) + ")"
+	}
+	return result
+}
+
+// runBashOnce runs command in a shell of its own.
+func runBashOnce(parent context.Context, shell Shell, cwd, command string, timeout int) (string, error) {
+	cmdCtx, cancel := context.WithTimeout(parent, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	cmd := shell.Command(cmdCtx, command)
+	cmd.Dir = cwd
+
+	// Kill the entire process group so child processes don't
+	// survive after cancellation.
+	setProcGroup(cmd)
+
+	// Redirect stdout/stderr to a temp file instead of pipes.
+	// When output goes to an *os.File, Go passes the handle
+	// directly to the child process without creating I/O-copying
+	// goroutines. This means cmd.Wait() only blocks on process
+	// exit — not on pipe reads — and is fully cancellable by
+	// cmd.Cancel (taskkill on Windows, kill -PGID on Unix).
+	// With pipes, child processes that inherit the pipe handles
+	// (e.g. a launched browser) keep them open after the parent
+	// exits, causing CombinedOutput to hang indefinitely.
+	tmpFile, tmpErr := os.CreateTemp("", "muxd-bash-*.txt")
+	if tmpErr != nil {
+		return "", fmt.Errorf("creating temp file: %w", tmpErr)
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	cmd.Stdout = tmpFile
+	cmd.Stderr = tmpFile
+
+	// WaitDelay as a safety net: if Cancel fails to kill the
+	// process, Wait returns after this delay regardless.
+	cmd.WaitDelay = bashWaitDelay
+
+	err := cmd.Run()
+	tmpFile.Close()
+
+	out, _ := os.ReadFile(tmpPath)
+	result := string(out)
+	if len(result) > maxBashOutput {
+		result = result[:maxBashOutput] + "\n... (truncated at 50KB)"
+	}
+
+	if err != nil {
+		if cmdCtx.Err() == context.Canceled {
+			return result + "\n(canceled)", nil
+		}
+		if cmdCtx.Err() == context.DeadlineExceeded {
+			return result + "\n(command timed out after " + strconv.Itoa(timeout) + "s)", nil
+		}
+		return result + "\n(exit code: " + err.Error() + ")", nil
+	}
+
+	return result, nil
+}
+
+// ---------------------------------------------------------------------------
+// bash_reset
+// ---------------------------------------------------------------------------
+
+func bashResetTool() ToolDef {
+	return ToolDef{
+		Spec: provider.ToolSpec{
+			Name:        "bash_reset",
+			Description: "Restart the persistent shell bash commands run in, dropping its working directory, environment variables, and activated virtualenvs. Use when the shell is in a bad state or you need a clean environment.",
+			Properties:  map[string]provider.ToolProp{},
+		},
+		Execute: func(input map[string]any, ctx *ToolContext) (string, error) {
+			if ctx == nil || ctx.ShellSession == nil {
+				return "No persistent shell to reset; each bash command already runs in a fresh shell.", nil
+			}
+			ctx.ShellSession.Reset()
+			cwd, _ := Getwd()
+			if dir := ctx.Dir(); dir != "." {
+				cwd = dir
+			}
+			return "Shell reset. The next bash command starts a fresh shell in " + cwd + ".", nil
+		},
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -619,7 +766,7 @@ func grepTool() ToolDef {
 				return "", fmt.Errorf("invalid regex: %w", err)
 			}
 
-			searchPath := "."
+			searchPath := ctx.Dir()
 			if v, ok := input["path"].(string); ok && v != "" {
 				searchPath = v
 			}
@@ -685,7 +832,7 @@ func grepTool() ToolDef {
 				if contextLines == 0 {
 					for i, line := range lines {
 						if re.MatchString(line) {
-							matches = append(matches, fmt.Sprintf("%s:%d:%s", path, i+1, line))
+							matches = append(matches, fmt.Sprintf("%s:%d:%s", filepath.ToSlash(path), i+1, line))
 							if len(matches) >= maxMatches {
 								return errLimitReached
 							}
@@ -739,7 +886,7 @@ func grepTool() ToolDef {
 					if matchSet[i] {
 						prefix = ":"
 					}
-					matches = append(matches, fmt.Sprintf("%s:%d%s%s", path, i+1, prefix, lines[i]))
+					matches = append(matches, fmt.Sprintf("%s:%d%s%s", filepath.ToSlash(path), i+1, prefix, lines[i]))
 					prevEmitted = i
 					if len(matches) >= maxMatches {
 						return errLimitReached
@@ -822,7 +969,7 @@ func listFilesTool() ToolDef {
 			Required: []string{},
 		},
 		Execute: func(input map[string]any, ctx *ToolContext) (string, error) {
-			dirPath := "."
+			dirPath := ctx.Dir()
 			if v, ok := input["path"].(string); ok && v != "" {
 				dirPath = v
 			}
@@ -971,6 +1118,7 @@ func askUserTool() ToolDef {
 			Description: "Ask the user a question and wait for their response. Use when you need clarification, a decision, or confirmation before proceeding. The agent loop will pause until the user replies.",
 			Properties: map[string]provider.ToolProp{
 				"question": {Type: "string", Description: "The question to ask the user"},
+				"options":  {Type: "array", Description: "Answers to offer when the question has a few fixed choices, e.g. [\"yes\", \"no\"]; the user can still answer in their own words", Items: &provider.ToolProp{Type: "string"}},
 			},
 			Required: []string{"question"},
 		},
@@ -1,11 +1,14 @@
 package tools
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+
+	"github.com/batalabs/muxd/internal/provider"
 )
 
 // ---------------------------------------------------------------------------
@@ -620,7 +623,7 @@ func TestAllToolSpecs(t *testing.T) {
 	specs := AllToolSpecs()
 
 	t.Run("correct count", func(t *testing.T) {
-		expected := 33 // glob + git_status + memory_read/write + schedule_task/list/cancel + sms_send/status/schedule + log_read + http_request + hub_discovery + hub_dispatch + tool_create + tool_register + tool_list_custom + consult + core tools
+		expected := 35 // bash_reset + spawn_agent + glob + git_status + memory_read/write + schedule_task/list/cancel + sms_send/status/schedule + log_read + http_request + hub_discovery + hub_dispatch + tool_create + tool_register + tool_list_custom + consult + core tools
 		if len(specs) != expected {
 			t.Errorf("expected %d tools, got %d", expected, len(specs))
 		}
@@ -633,6 +636,43 @@ func TestAllToolSpecs(t *testing.T) {
 			}
 		}
 	})
+
+	t.Run("schemas are well formed", func(t *testing.T) {
+		for _, s := range specs {
+			for _, name := range s.Required {
+				if _, ok := s.Properties[name]; !ok {
+					t.Errorf("%s: required %q is not a property", s.Name, name)
+				}
+			}
+			for name, p := range s.Properties {
+				checkToolProp(t, s.Name+"."+name, p)
+			}
+		}
+	})
+}
+
+func checkToolProp(t *testing.T, path string, p provider.ToolProp) {
+	t.Helper()
+	switch p.Type {
+	case "string", "integer", "number", "boolean":
+	case "array":
+		if p.Items == nil {
+			t.Errorf("%s: array without items", path)
+			return
+		}
+		checkToolProp(t, path+"[]", *p.Items)
+	case "object":
+		for _, name := range p.Required {
+			if _, ok := p.Properties[name]; !ok && len(p.Properties) > 0 {
+				t.Errorf("%s: required %q is not a property", path, name)
+			}
+		}
+		for name, sub := range p.Properties {
+			checkToolProp(t, path+"."+name, sub)
+		}
+	default:
+		t.Errorf("%s: unknown type %q", path, p.Type)
+	}
 }
 
 // ---------------------------------------------------------------------------
@@ -757,6 +797,23 @@ func TestToolRiskTags(t *testing.T) {
 // ToolProfileDisabledSet
 // ---------------------------------------------------------------------------
 
+func TestToolProfilesFor(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"file_read", "[safe coder research]"},
+		{"bash", "[coder]"},
+		{"file_write", "[safe coder]"},
+		{"web_fetch", "[coder research]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(ToolProfilesFor(tt.name)); got != tt.want {
+			t.Errorf("ToolProfilesFor(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
 func TestToolProfileDisabledSet(t *testing.T) {
 	t.Run("safe profile disables dangerous tools", func(t *testing.T) {
 		disabled := ToolProfileDisabledSet("safe")
@@ -804,30 +861,6 @@ func TestToolProfileDisabledSet(t *testing.T) {
 	})
 }
 
-// ---------------------------------------------------------------------------
-// needsPosixShell
-// ---------------------------------------------------------------------------
-
-func TestNeedsPosixShell(t *testing.T) {
-	tests := []struct {
-		command string
-		want    bool
-	}{
-		{"echo hello", false},
-		{"cat <<EOF\nhello\nEOF", true},
-		{"git commit -m \"$(cat <<'EOF'\nmessage\nEOF\n)\"", true},
-		{"", false},
-	}
-	for _, tt := range tests {
-		t.Run(tt.command, func(t *testing.T) {
-			got := needsPosixShell(tt.command)
-			if got != tt.want {
-				t.Errorf("needsPosixShell(%q) = %v, want %v", tt.command, got, tt.want)
-			}
-		})
-	}
-}
-
 // ---------------------------------------------------------------------------
 // IsDeniedConfigFile
 // ---------------------------------------------------------------------------
@@ -947,3 +980,24 @@ func TestIsBinary(t *testing.T) {
 		})
 	}
 }
+
+// ---------------------------------------------------------------------------
+// ToolReference
+// ---------------------------------------------------------------------------
+
+func TestToolReference(t *testing.T) {
+	path := filepath.Join("..", "..", "docs", "tools.md")
+	want := ToolReference()
+	if os.Getenv("MUXD_UPDATE_DOCS") != "" {
+		if err := os.WriteFile(path, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Error("docs/tools.md is out of date; run MUXD_UPDATE_DOCS=1 go test ./internal/tools -run TestToolReference")
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/egress"
 	"github.com/batalabs/muxd/internal/provider"
 )
 
@@ -47,16 +49,18 @@ func webSearchTool() ToolDef {
 			}
 
 			var ctxKey string
+			var cite func(url, title string) int
 			if ctx != nil {
 				ctxKey = ctx.BraveAPIKey
+				cite = ctx.Cite
 			}
-			return braveSearch(query, count, ctxKey)
+			return braveSearch(query, count, ctxKey, cite)
 		},
 	}
 }
 
 // braveSearchHTTPClient is overridable in tests.
-var braveSearchHTTPClient = &http.Client{Timeout: braveSearchTimeout}
+var braveSearchHTTPClient = &http.Client{Timeout: braveSearchTimeout, Transport: egress.Transport(nil)}
 
 // braveSearchURL is the base URL for the Brave Search API. Override in tests.
 var braveSearchURL = "https://api.search.brave.com/res/v1/web/search"
@@ -66,7 +70,8 @@ var getEnvFunc = os.Getenv
 
 // braveSearch calls the Brave Search API and returns formatted results.
 // It checks the env var first, then falls back to the provided config key.
-func braveSearch(query string, count int, configKey string) (string, error) {
+// With cite set, each result is headed by its session-wide source number.
+func braveSearch(query string, count int, configKey string, cite func(url, title string) int) (string, error) {
 	apiKey := getEnvFunc("BRAVE_SEARCH_API_KEY")
 	if apiKey == "" {
 		apiKey = configKey
@@ -113,7 +118,11 @@ func braveSearch(query string, count int, configKey string) (string, error) {
 
 	var b strings.Builder
 	for i, r := range result.Web.Results {
-		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		if cite != nil {
+			fmt.Fprintf(&b, "%s\n   %s\n", domain.CitationHeader(cite(r.URL, r.Title), r.Title), r.URL)
+		} else {
+			fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		}
 		if r.Description != "" {
 			fmt.Fprintf(&b, "   %s\n", r.Description)
 		}
@@ -152,13 +161,17 @@ func webFetchTool() ToolDef {
 				return "", fmt.Errorf("url is required")
 			}
 
-			return fetchAndExtractText(rawURL)
+			content, err := fetchAndExtractText(rawURL)
+			if err != nil || ctx == nil || ctx.Cite == nil {
+				return content, err
+			}
+			return domain.CitationHeader(ctx.Cite(rawURL, ""), rawURL) + "\n\n" + content, nil
 		},
 	}
 }
 
 // webFetchHTTPClient is overridable in tests.
-var webFetchHTTPClient = &http.Client{Timeout: webFetchTimeout}
+var webFetchHTTPClient = &http.Client{Timeout: webFetchTimeout, Transport: egress.Transport(nil)}
 
 // fetchAndExtractText fetches a URL and returns the text content.
 func fetchAndExtractText(rawURL string) (string, error) {
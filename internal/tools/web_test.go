@@ -89,6 +89,17 @@ func TestWebSearchTool(t *testing.T) {
 		if !strings.Contains(result, "Build fast") {
 			t.Errorf("expected description in result, got: %s", result)
 		}
+
+		// With citations on, results carry session-wide source numbers.
+		next := 6
+		ctx := &ToolContext{Cite: func(url, title string) int { next++; return next }}
+		result, err = tool.Execute(map[string]any{"query": "golang"}, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(result, "[7] Go Programming Language\n   https://go.dev\n") || !strings.Contains(result, "[8] Go Wiki") {
+			t.Errorf("expected numbered sources, got: %s", result)
+		}
 	})
 
 	t.Run("no results", func(t *testing.T) {
@@ -221,6 +232,15 @@ func TestWebFetchTool(t *testing.T) {
 		if result != "Hello, World!" {
 			t.Errorf("expected 'Hello, World!', got: %s", result)
 		}
+
+		ctx := &ToolContext{Cite: func(url, title string) int { return 4 }}
+		result, err = tool.Execute(map[string]any{"url": server.URL}, ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := "[4] " + server.URL + "\n\nHello, World!"; result != want {
+			t.Errorf("cited fetch = %q, want %q", result, want)
+		}
 	})
 
 	t.Run("strips HTML", func(t *testing.T) {
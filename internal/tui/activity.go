@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
@@ -21,6 +22,30 @@ var thinkingMessages = []string{
 }
 
 // describeToolStart returns a short description of what a tool is about to do.
+// describeApproval summarizes a tool call for an approval prompt, showing
+// the argument the user most needs to see.
+func describeApproval(toolName string, input map[string]any) string {
+	detail := ""
+	for _, key := range []string{"command", "path", "url", "phone", "prompt", "name"} {
+		if v, ok := input[key].(string); ok && v != "" {
+			detail = v
+			break
+		}
+	}
+	if detail == "" && len(input) > 0 {
+		b, _ := json.Marshal(input)
+		detail = string(b)
+	}
+	detail = strings.Join(strings.Fields(detail), " ")
+	if len(detail) > 200 {
+		detail = detail[:200] + "..."
+	}
+	if detail == "" {
+		return toolName
+	}
+	return toolName + ": " + detail
+}
+
 func describeToolStart(toolName string, input map[string]any) string {
 	getStr := func(key string) string {
 		if v, ok := input[key].(string); ok {
@@ -72,6 +97,10 @@ func describeToolStart(toolName string, input map[string]any) string {
 		if d := getStr("description"); d != "" {
 			return "Sub-agent: " + shorten(d, 30)
 		}
+	case "spawn_agent":
+		if agents, ok := input["agents"].([]any); ok {
+			return fmt.Sprintf("Running %d sub-agents", len(agents))
+		}
 	case "consult":
 		return "Consulting another model"
 	case "tool_create":
@@ -147,6 +176,8 @@ func describeToolAction(toolName, result string) string {
 		return "Created custom tool"
 	case "task":
 		return "Ran sub-agent"
+	case "spawn_agent":
+		return "Ran sub-agents"
 	default:
 		return "Ran " + toolName
 	}
@@ -157,7 +188,9 @@ func (m Model) buildActivityStatus() string {
 	var parts []string
 
 	// Primary status: streaming, current tool, or fun thinking message.
-	if m.streaming {
+	if m.stopping {
+		parts = append(parts, "Stopping after this step · Esc again to abort")
+	} else if m.streaming {
 		if m.turnLastAction != "" {
 			parts = append(parts, m.turnLastAction+" → Writing response")
 		} else {
@@ -0,0 +1,108 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/daemon"
+)
+
+// ---------------------------------------------------------------------------
+// /slack, /discord: chat adapters
+// ---------------------------------------------------------------------------
+
+// chatAdapter describes a daemon chat adapter for its slash command.
+type chatAdapter struct {
+	name    string // adapter and command name
+	title   string // display name
+	started string // shown after /<name> start
+	setup   string // what to configure before starting it
+}
+
+var chatAdapters = map[string]chatAdapter{
+	"slack": {
+		name:    "slack",
+		title:   "Slack",
+		started: "Message the bot in Slack; replies arrive in threads.",
+		setup:   "Set slack.app_token, slack.bot_token, and slack.allowed_users",
+	},
+	"discord": {
+		name:    "discord",
+		title:   "Discord",
+		started: "Message the bot in Discord; replies stream into its messages.",
+		setup:   "Set discord.token and discord.allowed_users",
+	},
+}
+
+// AdapterSubcommands are the subcommands of each adapter command.
+var AdapterSubcommands = []string{"start", "status", "stop"}
+
+// AdapterMsg carries a chat adapter's state after a /slack or /discord
+// command.
+type AdapterMsg struct {
+	Name   string
+	Action string // "start", "stop", or "status"
+	Status *daemon.AdapterStatus
+	Err    error
+}
+
+func (m Model) handleAdapterCommand(name string, args []string) (tea.Model, tea.Cmd) {
+	a := chatAdapters[name]
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError(fmt.Sprintf("The %s adapter runs in the daemon; connect to one first.", a.title)))
+	}
+	action := "status"
+	if len(args) > 0 {
+		action = strings.ToLower(args[0])
+	}
+	if len(args) > 1 || (action != "start" && action != "stop" && action != "status") {
+		return m, PrintToScrollback(m.renderError(fmt.Sprintf("Usage: /%s start | stop | status", name)))
+	}
+	d := m.Daemon
+	return m, func() tea.Msg {
+		var st *daemon.AdapterStatus
+		var err error
+		switch action {
+		case "start":
+			st, err = d.StartAdapter(name)
+		case "stop":
+			st, err = d.StopAdapter(name)
+		default:
+			var all []daemon.AdapterStatus
+			all, err = d.ListAdapters()
+			for i := range all {
+				if all[i].Name == name {
+					st = &all[i]
+				}
+			}
+			if err == nil && st == nil {
+				err = fmt.Errorf("this daemon has no %s adapter (compliance mode leaves it out)", a.title)
+			}
+		}
+		return AdapterMsg{Name: name, Action: action, Status: st, Err: err}
+	}
+}
+
+func (m Model) handleAdapter(msg AdapterMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError(msg.Err.Error()))
+	}
+	return m, PrintToScrollback(formatAdapterStatus(chatAdapters[msg.Name], msg.Action, msg.Status))
+}
+
+func formatAdapterStatus(a chatAdapter, action string, st *daemon.AdapterStatus) string {
+	switch {
+	case action == "start":
+		return FooterMeta.Render(a.title + " adapter started. " + a.started)
+	case action == "stop":
+		return FooterMeta.Render(a.title + " adapter stopped.")
+	case st.Running && st.StartedAt != nil:
+		return FooterMeta.Render(a.title + " adapter running since " + st.StartedAt.Local().Format(time.Kitchen) + ".")
+	case st.Error != "":
+		return ErrorLineStyle.Render(a.title + " adapter stopped: " + st.Error)
+	}
+	return FooterMeta.Render(fmt.Sprintf("%s adapter is not running. %s, then /%s start.", a.title, a.setup, a.name))
+}
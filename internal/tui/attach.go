@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/docread"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/tools"
+)
+
+// ---------------------------------------------------------------------------
+// Attachments
+// ---------------------------------------------------------------------------
+
+// handleAttachCommand handles /attach <path>, /attach (list) and
+// /attach clear. Attached files are sent with the next message.
+func (m Model) handleAttachCommand(arg string) (tea.Model, tea.Cmd) {
+	switch strings.TrimSpace(arg) {
+	case "":
+		if len(m.attachments) == 0 {
+			return m, PrintToScrollback(FooterMeta.Render("No attachments. Use /attach <path> or drop a file into the terminal."))
+		}
+		lines := []string{FooterHead.Render("Attached to your next message:")}
+		for _, p := range m.attachments {
+			lines = append(lines, FooterMeta.Render("  "+p))
+		}
+		return m, PrintToScrollback(strings.Join(lines, "\n"))
+	case "clear":
+		m.attachments = nil
+		return m, PrintToScrollback(FooterMeta.Render("Attachments cleared."))
+	}
+	paths := splitPaths(arg)
+	if len(paths) == 0 {
+		return m, PrintToScrollback(m.renderError("Usage: /attach <path> | /attach clear"))
+	}
+	return m.attach(paths)
+}
+
+// attach queues paths for the next message after checking they can be sent.
+func (m Model) attach(paths []string) (tea.Model, tea.Cmd) {
+	var notes []string
+	for _, p := range paths {
+		if abs, err := filepath.Abs(p); err == nil {
+			p = abs
+		}
+		att, err := loadAttachment(p)
+		if err != nil {
+			return m, PrintToScrollback(m.renderError(err.Error()))
+		}
+		m.attachments = append(m.attachments, p)
+		notes = append(notes, fmt.Sprintf("Attached %s (%s).", att.Name, formatBytes(base64.StdEncoding.DecodedLen(len(att.Data)))))
+	}
+	notes = append(notes, "Sent with your next message; /attach clear to drop.")
+	return m, PrintToScrollback(FooterMeta.Render(strings.Join(notes, " ")))
+}
+
+// loadAttachment reads a file for the submit API. Images keep their media
+// type, documents such as PDFs are converted to text, and anything else must
+// be a text file.
+func loadAttachment(path string) (daemon.SubmitAttachment, error) {
+	name := filepath.Base(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return daemon.SubmitAttachment{}, fmt.Errorf("cannot attach %s: %w", name, err)
+	}
+	if info.IsDir() {
+		return daemon.SubmitAttachment{}, fmt.Errorf("cannot attach %s: is a directory", name)
+	}
+	if mediaType := tools.MediaTypeFromExt(path); mediaType != "" {
+		if info.Size() > agent.MaxAttachmentImageBytes {
+			return daemon.SubmitAttachment{}, fmt.Errorf("image %s is too large (max %d MB)", name, agent.MaxAttachmentImageBytes/(1024*1024))
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return daemon.SubmitAttachment{}, fmt.Errorf("reading %s: %w", name, err)
+		}
+		return daemon.SubmitAttachment{Name: name, MediaType: mediaType, Data: base64.StdEncoding.EncodeToString(data)}, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return daemon.SubmitAttachment{}, fmt.Errorf("reading %s: %w", name, err)
+	}
+	if !looksLikeText(data) {
+		if !docread.CanExtract(filepath.Ext(path)) {
+			return daemon.SubmitAttachment{}, fmt.Errorf("cannot attach %s: not a text file, image, or supported document", name)
+		}
+		text, err := docread.Extract(path)
+		if err != nil {
+			return daemon.SubmitAttachment{}, fmt.Errorf("reading %s: %w", name, err)
+		}
+		data = []byte(text)
+	}
+	return daemon.SubmitAttachment{Name: name, MediaType: "text/plain", Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+// looksLikeText reports whether the start of data is UTF-8 without NULs.
+func looksLikeText(data []byte) bool {
+	head := data[:min(len(data), 8192)]
+	if bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	for i := 0; i < utf8.UTFMax && len(head) > 0 && !utf8.Valid(head); i++ {
+		head = head[:len(head)-1] // the cut may split a character
+	}
+	return utf8.Valid(head)
+}
+
+// attachmentBlock is how an attachment is shown in the local transcript.
+func attachmentBlock(att daemon.SubmitAttachment) domain.ContentBlock {
+	if strings.HasPrefix(att.MediaType, "image/") {
+		return domain.ContentBlock{Type: "image", MediaType: att.MediaType, Base64Data: att.Data, ImagePath: att.Name}
+	}
+	size := base64.StdEncoding.DecodedLen(len(att.Data))
+	return domain.ContentBlock{Type: "text", Text: fmt.Sprintf("[file: %s (%s)]", att.Name, formatBytes(size))}
+}
+
+// ---------------------------------------------------------------------------
+// Drag and drop
+// ---------------------------------------------------------------------------
+
+// droppedPaths returns the files in pasted text when it consists only of
+// absolute paths to existing files, which is what terminals paste when a
+// file is dropped onto them. Paths may be quoted, backslash-escaped, or
+// file:// URLs.
+func droppedPaths(text string) []string {
+	text = strings.TrimSpace(text)
+	if text == "" || strings.Contains(text, "\n") && !strings.Contains(text, "file://") {
+		return nil
+	}
+	paths := splitPaths(text)
+	if len(paths) == 0 {
+		return nil
+	}
+	for _, p := range paths {
+		if !filepath.IsAbs(p) {
+			return nil
+		}
+		if info, err := os.Stat(p); err != nil || info.IsDir() {
+			return nil
+		}
+	}
+	return paths
+}
+
+// splitPaths splits text into paths, honouring double and single quotes and
+// backslash-escaped spaces, and converting file:// URLs to paths.
+func splitPaths(text string) []string {
+	var paths []string
+	var cur strings.Builder
+	var quote rune
+	inToken := false
+	flush := func() {
+		if !inToken {
+			return
+		}
+		p := cur.String()
+		if strings.HasPrefix(p, "file://") {
+			if u, err := url.Parse(p); err == nil {
+				p = u.Path
+			}
+		}
+		if strings.HasPrefix(p, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				p = filepath.Join(home, p[2:])
+			}
+		}
+		paths = append(paths, p)
+		cur.Reset()
+		inToken = false
+	}
+	r := []rune(text)
+	for i := 0; i < len(r); i++ {
+		c := r[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				cur.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inToken = true
+		case c == '\\' && i+1 < len(r) && (r[i+1] == ' ' || r[i+1] == '\'' || r[i+1] == '"' || r[i+1] == '\\' || r[i+1] == '(' || r[i+1] == ')'):
+			i++
+			cur.WriteRune(r[i])
+			inToken = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			cur.WriteRune(c)
+			inToken = true
+		}
+	}
+	flush()
+	return paths
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPaths(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"/tmp/a.txt", []string{"/tmp/a.txt"}},
+		{`/tmp/my\ file.txt /tmp/b.txt`, []string{"/tmp/my file.txt", "/tmp/b.txt"}},
+		{`'/tmp/my file.txt'`, []string{"/tmp/my file.txt"}},
+		{`"/tmp/my file.txt"`, []string{"/tmp/my file.txt"}},
+		{"file:///tmp/my%20file.txt", []string{"/tmp/my file.txt"}},
+		{"   ", nil},
+	}
+	for _, tt := range tests {
+		if got := splitPaths(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPaths(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDroppedPaths(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "my notes.txt")
+	if err := os.WriteFile(file, []byte("hi"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	escaped := strings.ReplaceAll(file, " ", `\ `)
+
+	if got := droppedPaths(escaped + " "); len(got) != 1 || got[0] != file {
+		t.Errorf("droppedPaths(escaped) = %q", got)
+	}
+	for _, text := range []string{
+		"please read " + escaped,      // prose around the path
+		filepath.Join(dir, "missing"), // not a file
+		dir,                           // directory
+		"my notes.txt",                // relative
+	} {
+		if got := droppedPaths(text); got != nil {
+			t.Errorf("droppedPaths(%q) = %q, want nil", text, got)
+		}
+	}
+}
+
+func TestLoadAttachment(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	att, err := loadAttachment(write("main.go", []byte("package main\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.Name != "main.go" || att.MediaType != "text/plain" || att.Data != base64.StdEncoding.EncodeToString([]byte("package main\n")) {
+		t.Errorf("text attachment = %+v", att)
+	}
+
+	att, err = loadAttachment(write("shot.png", []byte("\x89PNG\r\n\x1a\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if att.MediaType != "image/png" {
+		t.Errorf("image media type = %q", att.MediaType)
+	}
+	if blk := attachmentBlock(att); blk.Type != "image" || blk.ImagePath != "shot.png" {
+		t.Errorf("image block = %+v", blk)
+	}
+
+	if _, err := loadAttachment(write("a.out", []byte{0x7f, 'E', 'L', 'F', 0, 1})); err == nil {
+		t.Error("expected error for binary file")
+	}
+	if _, err := loadAttachment(dir); err == nil {
+		t.Error("expected error for directory")
+	}
+}
+
+func TestAttachCommand(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(file, []byte("# notes"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m := Model{}
+	got, _ := m.handleAttachCommand(`"` + file + `"`)
+	m = got.(Model)
+	if len(m.attachments) != 1 || m.attachments[0] != file {
+		t.Fatalf("attachments = %q", m.attachments)
+	}
+	got, _ = m.handleAttachCommand("clear")
+	if n := len(got.(Model).attachments); n != 0 {
+		t.Errorf("attachments after clear = %d", n)
+	}
+}
@@ -0,0 +1,46 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// citedSourcesCmd looks up the web sources the finished reply cites as [N].
+// The transcript is only loaded when the reply contains a reference.
+func (m Model) citedSourcesCmd(reply string) tea.Cmd {
+	if m.Session == nil || !strings.Contains(reply, "[") {
+		return nil
+	}
+	return func() tea.Msg {
+		msgs, err := m.sessionMessages()
+		if err != nil {
+			return nil
+		}
+		cited := domain.CitedSources(reply, domain.ParseCitations(domain.MessageBlocks(msgs)))
+		if len(cited) == 0 {
+			return nil
+		}
+		return SourcesMsg{Sources: cited}
+	}
+}
+
+// renderSources renders numbered citations under a reply, with the URL of
+// each so the terminal can open it.
+func renderSources(sources []domain.Citation) string {
+	if len(sources) == 0 {
+		return ""
+	}
+	lines := []string{FooterHead.Render("Sources")}
+	for _, c := range sources {
+		line := FooterMeta.Render(fmt.Sprintf("  [%d] ", c.N))
+		if c.Title != "" {
+			line += LinkTextStyle.Render(c.Title) + " "
+		}
+		lines = append(lines, line+LinkURLStyle.Render(c.URL))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,39 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func TestCitedSourcesCmd(t *testing.T) {
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	sess, _ := st.CreateSession("/tmp/cite", "test-model")
+	_ = st.AppendMessageBlocks(sess.ID, "user", []domain.ContentBlock{
+		{Type: "tool_result", ToolUseID: "t1", ToolName: "web_search", ToolResult: "[1] Go\n   https://go.dev\n\n[2] Wiki\n   https://go.dev/wiki"},
+	}, 0)
+	m := Model{Store: st, Session: sess}
+
+	if cmd := m.citedSourcesCmd("no references here"); cmd != nil {
+		t.Error("a reply without references should not load the transcript")
+	}
+	if msg := m.citedSourcesCmd("see [9]")(); msg != nil {
+		t.Errorf("unknown reference = %#v, want nothing", msg)
+	}
+	msg, ok := m.citedSourcesCmd("Go is fast [2].")().(SourcesMsg)
+	if !ok || len(msg.Sources) != 1 || msg.Sources[0].URL != "https://go.dev/wiki" {
+		t.Fatalf("cited = %#v", msg)
+	}
+	out := renderSources(msg.Sources)
+	for _, want := range []string{"Sources", "[2]", "Wiki", "https://go.dev/wiki"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("renderSources missing %q:\n%s", want, out)
+		}
+	}
+}
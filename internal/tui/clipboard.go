@@ -1,70 +1,281 @@
 package tui
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/config"
 )
 
-// PasteMsg carries clipboard read results to the TUI model.
+// PasteMsg carries clipboard read results to the TUI model. ImagePath is
+// set instead of Text when the clipboard held an image, saved as a PNG.
 type PasteMsg struct {
-	Text string
-	Err  error
+	Text      string
+	ImagePath string
+	Err       error
 }
 
 // ClipboardWriteMsg carries clipboard write results to the TUI model.
 type ClipboardWriteMsg struct {
-	OK  bool
-	Err error
+	OK      bool
+	Backend string // backend that took the text
+	Err     error
 }
 
 // ReadClipboardCmd returns a Bubble Tea Cmd that reads the system clipboard
-// and delivers the contents as a PasteMsg.
-// Supports Windows (powershell), macOS (pbpaste), and Linux (xclip).
-func ReadClipboardCmd() tea.Cmd {
+// with the first working backend for pref and delivers the contents as a
+// PasteMsg. When the clipboard holds no text but an image, the image is
+// saved to a temp file. OSC 52 reads are not supported; use the terminal's
+// own paste.
+func ReadClipboardCmd(pref string) tea.Cmd {
 	return func() tea.Msg {
-		if out, err := exec.Command("powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw").Output(); err == nil {
-			return PasteMsg{Text: string(out)}
+		backends := clipboardBackends(pref, os.Getenv)
+		read := false
+		for _, b := range backends {
+			for _, args := range clipboardReadCommands(b) {
+				if out, err := exec.Command(args[0], args[1:]...).Output(); err == nil {
+					if strings.TrimSpace(string(out)) != "" {
+						return PasteMsg{Text: string(out)}
+					}
+					read = true
+				}
+			}
 		}
-		if out, err := exec.Command("pbpaste").Output(); err == nil {
-			return PasteMsg{Text: string(out)}
+		for _, b := range backends {
+			if path, err := saveClipboardImage(b); err == nil {
+				return PasteMsg{ImagePath: path}
+			}
 		}
-		if out, err := exec.Command("xclip", "-selection", "clipboard", "-o").Output(); err == nil {
-			return PasteMsg{Text: string(out)}
+		if read {
+			return PasteMsg{}
 		}
 		return PasteMsg{Err: fmt.Errorf("clipboard read not available")}
 	}
 }
 
-// WriteClipboardCmd returns a Bubble Tea Cmd that writes text to the system
-// clipboard and delivers a ClipboardWriteMsg on completion.
-// Supports Windows (powershell), macOS (pbcopy), and Linux (xclip).
-func WriteClipboardCmd(text string) tea.Cmd {
+// WriteClipboardCmd returns a Bubble Tea Cmd that writes text with the first
+// working backend for pref and delivers a ClipboardWriteMsg on completion.
+func WriteClipboardCmd(pref, text string) tea.Cmd {
 	return func() tea.Msg {
 		if text == "" {
 			return ClipboardWriteMsg{Err: fmt.Errorf("nothing to copy")}
 		}
+		for _, b := range clipboardBackends(pref, os.Getenv) {
+			if b == config.ClipboardOSC52 {
+				if err := writeOSC52(osc52Output, text, os.Getenv); err == nil {
+					return ClipboardWriteMsg{OK: true, Backend: b}
+				}
+				continue
+			}
+			for _, args := range clipboardWriteCommands(b) {
+				cmd := exec.Command(args[0], args[1:]...)
+				cmd.Stdin = strings.NewReader(text)
+				if err := cmd.Run(); err == nil {
+					return ClipboardWriteMsg{OK: true, Backend: b}
+				}
+			}
+		}
+		return ClipboardWriteMsg{Err: fmt.Errorf("clipboard write not available (try /config set clipboard osc52)")}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Backend selection
+// ---------------------------------------------------------------------------
+
+// clipboardBackends returns the backends to try, in order, for the clipboard
+// preference. An explicit backend is used alone. Auto prefers OSC 52 over
+// SSH, where local utilities would fill the remote machine's clipboard, and
+// otherwise tries the platform's utilities with OSC 52 as the fallback.
+func clipboardBackends(pref string, getenv func(string) string) []string {
+	if b, err := config.ParseClipboard(pref); err == nil && b != config.ClipboardAuto {
+		return []string{b}
+	}
+	if getenv("SSH_TTY") != "" || getenv("SSH_CONNECTION") != "" {
+		return []string{config.ClipboardOSC52}
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return []string{config.ClipboardNative, config.ClipboardOSC52}
+	}
+	var out []string
+	if getenv("WAYLAND_DISPLAY") != "" {
+		out = append(out, config.ClipboardWlCopy)
+	}
+	if getenv("DISPLAY") != "" {
+		out = append(out, config.ClipboardXclip)
+	}
+	return append(out, config.ClipboardOSC52)
+}
+
+// clipboardWriteCommands returns the commands, in order, that copy stdin to
+// the clipboard for backend.
+func clipboardWriteCommands(backend string) [][]string {
+	switch backend {
+	case config.ClipboardXclip:
+		return [][]string{{"xclip", "-selection", "clipboard"}}
+	case config.ClipboardWlCopy:
+		return [][]string{{"wl-copy"}}
+	case config.ClipboardNative:
+		switch runtime.GOOS {
+		case "windows":
+			return [][]string{{"powershell", "-NoProfile", "-Command", "$input | Set-Clipboard"}}
+		case "darwin":
+			return [][]string{{"pbcopy"}}
+		}
+		return [][]string{{"wl-copy"}, {"xclip", "-selection", "clipboard"}}
+	}
+	return nil
+}
 
-		winCmd := exec.Command("powershell", "-NoProfile", "-Command", "$input | Set-Clipboard")
-		winCmd.Stdin = strings.NewReader(text)
-		if err := winCmd.Run(); err == nil {
-			return ClipboardWriteMsg{OK: true}
+// clipboardReadCommands returns the commands, in order, that print the
+// clipboard for backend.
+func clipboardReadCommands(backend string) [][]string {
+	switch backend {
+	case config.ClipboardXclip:
+		return [][]string{{"xclip", "-selection", "clipboard", "-o"}}
+	case config.ClipboardWlCopy:
+		return [][]string{{"wl-paste", "--no-newline"}}
+	case config.ClipboardNative:
+		switch runtime.GOOS {
+		case "windows":
+			return [][]string{{"powershell", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}
+		case "darwin":
+			return [][]string{{"pbpaste"}}
 		}
+		return [][]string{{"wl-paste", "--no-newline"}, {"xclip", "-selection", "clipboard", "-o"}}
+	}
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// Images
+// ---------------------------------------------------------------------------
+
+// pasteImageDir is where images pasted from the clipboard are saved.
+func pasteImageDir() string {
+	return filepath.Join(os.TempDir(), "muxd-paste")
+}
 
-		macCmd := exec.Command("pbcopy")
-		macCmd.Stdin = strings.NewReader(text)
-		if err := macCmd.Run(); err == nil {
-			return ClipboardWriteMsg{OK: true}
+// saveClipboardImage saves an image on the clipboard as a PNG with the
+// given backend and returns its path.
+func saveClipboardImage(backend string) (string, error) {
+	cmds := clipboardImageCommands(backend, "")
+	if len(cmds) == 0 {
+		return "", fmt.Errorf("image paste not supported by %s", backend)
+	}
+	dir := pasteImageDir()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, "paste-"+time.Now().Format("20060102-150405.000")+".png")
+	for _, c := range clipboardImageCommands(backend, path) {
+		cmd := exec.Command(c.args[0], c.args[1:]...)
+		if c.stdout {
+			out, err := cmd.Output()
+			if err != nil || !isPNG(out) {
+				continue
+			}
+			if err := os.WriteFile(path, out, 0o600); err != nil {
+				return "", err
+			}
+			return path, nil
+		}
+		if err := cmd.Run(); err != nil {
+			continue
+		}
+		if data, err := os.ReadFile(path); err == nil && isPNG(data) {
+			return path, nil
 		}
+	}
+	_ = os.Remove(path)
+	return "", fmt.Errorf("no image on the clipboard")
+}
 
-		linuxCmd := exec.Command("xclip", "-selection", "clipboard")
-		linuxCmd.Stdin = strings.NewReader(text)
-		if err := linuxCmd.Run(); err == nil {
-			return ClipboardWriteMsg{OK: true}
+// imageCommand reads a clipboard image either to stdout or into the path it
+// was built with.
+type imageCommand struct {
+	args   []string
+	stdout bool
+}
+
+// clipboardImageCommands returns the commands, in order, that read a PNG
+// from the clipboard for backend, writing file-based output to path.
+func clipboardImageCommands(backend, path string) []imageCommand {
+	xclip := imageCommand{args: []string{"xclip", "-selection", "clipboard", "-t", "image/png", "-o"}, stdout: true}
+	wlPaste := imageCommand{args: []string{"wl-paste", "--type", "image/png"}, stdout: true}
+	switch backend {
+	case config.ClipboardXclip:
+		return []imageCommand{xclip}
+	case config.ClipboardWlCopy:
+		return []imageCommand{wlPaste}
+	case config.ClipboardNative:
+		switch runtime.GOOS {
+		case "windows":
+			script := "Add-Type -AssemblyName System.Windows.Forms; Add-Type -AssemblyName System.Drawing; " +
+				"$img = [System.Windows.Forms.Clipboard]::GetImage(); if ($img -eq $null) { exit 1 }; " +
+				"$img.Save('" + strings.ReplaceAll(path, "'", "''") + "', [System.Drawing.Imaging.ImageFormat]::Png)"
+			return []imageCommand{{args: []string{"powershell", "-NoProfile", "-STA", "-Command", script}}}
+		case "darwin":
+			script := []string{
+				"set png to (the clipboard as «class PNGf»)",
+				"set f to open for access (POSIX file " + appleScriptString(path) + ") with write permission",
+				"write png to f",
+				"close access f",
+			}
+			args := []string{"osascript"}
+			for _, line := range script {
+				args = append(args, "-e", line)
+			}
+			return []imageCommand{{args: args}}
 		}
+		return []imageCommand{wlPaste, xclip}
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+var pngMagic = []byte("\x89PNG\r\n\x1a\n")
+
+func isPNG(data []byte) bool {
+	return bytes.HasPrefix(data, pngMagic)
+}
+
+// ---------------------------------------------------------------------------
+// OSC 52
+// ---------------------------------------------------------------------------
+
+// osc52Output is where OSC 52 sequences are written; the terminal running
+// the TUI.
+var osc52Output io.Writer = os.Stdout
 
-		return ClipboardWriteMsg{Err: fmt.Errorf("clipboard write not available")}
+// osc52Sequence returns the escape sequence that asks the terminal to set
+// its clipboard to text. Inside tmux and screen the sequence is wrapped in a
+// DCS passthrough so it reaches the outer terminal.
+func osc52Sequence(text string, getenv func(string) string) string {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\x07"
+	switch {
+	case getenv("TMUX") != "":
+		return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	case strings.HasPrefix(getenv("TERM"), "screen"):
+		return "\x1bP" + seq + "\x1b\\"
 	}
+	return seq
+}
+
+func writeOSC52(w io.Writer, text string, getenv func(string) string) error {
+	_, err := io.WriteString(w, osc52Sequence(text, getenv))
+	return err
 }
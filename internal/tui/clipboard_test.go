@@ -0,0 +1,105 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/base64"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+func envFunc(env map[string]string) func(string) string {
+	return func(k string) string { return env[k] }
+}
+
+func TestClipboardBackends(t *testing.T) {
+	t.Run("explicit preference is used alone", func(t *testing.T) {
+		got := clipboardBackends("xclip", envFunc(nil))
+		if len(got) != 1 || got[0] != config.ClipboardXclip {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("ssh prefers osc52", func(t *testing.T) {
+		got := clipboardBackends("auto", envFunc(map[string]string{"SSH_TTY": "/dev/pts/1", "DISPLAY": ":0"}))
+		if len(got) != 1 || got[0] != config.ClipboardOSC52 {
+			t.Errorf("got %v", got)
+		}
+	})
+
+	t.Run("local session falls back to osc52", func(t *testing.T) {
+		got := clipboardBackends("", envFunc(map[string]string{"WAYLAND_DISPLAY": "wayland-0"}))
+		if got[len(got)-1] != config.ClipboardOSC52 {
+			t.Errorf("expected osc52 last, got %v", got)
+		}
+		if runtime.GOOS == "linux" && (len(got) != 2 || got[0] != config.ClipboardWlCopy) {
+			t.Errorf("expected wl-copy then osc52, got %v", got)
+		}
+	})
+}
+
+func TestOSC52Sequence(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString([]byte("héllo"))
+
+	plain := osc52Sequence("héllo", envFunc(nil))
+	if plain != "\x1b]52;c;"+payload+"\x07" {
+		t.Errorf("plain sequence = %q", plain)
+	}
+
+	tmux := osc52Sequence("héllo", envFunc(map[string]string{"TMUX": "/tmp/tmux-1000/default,1,0"}))
+	if !strings.HasPrefix(tmux, "\x1bPtmux;\x1b\x1b]52;c;"+payload) || !strings.HasSuffix(tmux, "\x07\x1b\\") {
+		t.Errorf("tmux sequence = %q", tmux)
+	}
+
+	screen := osc52Sequence("x", envFunc(map[string]string{"TERM": "screen-256color"}))
+	if !strings.HasPrefix(screen, "\x1bP\x1b]52;c;") || !strings.HasSuffix(screen, "\x1b\\") {
+		t.Errorf("screen sequence = %q", screen)
+	}
+}
+
+func TestWriteClipboardCmd_osc52(t *testing.T) {
+	var buf bytes.Buffer
+	orig := osc52Output
+	osc52Output = &buf
+	t.Cleanup(func() { osc52Output = orig })
+	t.Setenv("TMUX", "")
+	t.Setenv("TERM", "xterm-256color")
+
+	msg := WriteClipboardCmd("osc52", "copied text")().(ClipboardWriteMsg)
+	if !msg.OK || msg.Backend != config.ClipboardOSC52 {
+		t.Fatalf("unexpected msg %+v", msg)
+	}
+	want := base64.StdEncoding.EncodeToString([]byte("copied text"))
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("terminal output %q missing payload", buf.String())
+	}
+
+	if msg := WriteClipboardCmd("osc52", "")().(ClipboardWriteMsg); msg.Err == nil {
+		t.Error("expected error for empty text")
+	}
+}
+
+func TestClipboardImageCommands(t *testing.T) {
+	if got := clipboardImageCommands(config.ClipboardOSC52, "/tmp/x.png"); len(got) != 0 {
+		t.Errorf("osc52 should not read images, got %v", got)
+	}
+	got := clipboardImageCommands(config.ClipboardXclip, "/tmp/x.png")
+	if len(got) != 1 || !got[0].stdout || !strings.Contains(strings.Join(got[0].args, " "), "-t image/png") {
+		t.Errorf("xclip = %+v", got)
+	}
+	got = clipboardImageCommands(config.ClipboardWlCopy, "/tmp/x.png")
+	if len(got) != 1 || got[0].args[0] != "wl-paste" {
+		t.Errorf("wl-copy = %+v", got)
+	}
+}
+
+func TestIsPNG(t *testing.T) {
+	if !isPNG([]byte("\x89PNG\r\n\x1a\nrest")) {
+		t.Error("expected PNG")
+	}
+	if isPNG([]byte("hello")) {
+		t.Error("text is not PNG")
+	}
+}
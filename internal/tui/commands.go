@@ -1,10 +1,11 @@
 package tui
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"os"
-	"sort"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -12,8 +13,15 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/daemon"
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/export"
+	"github.com/batalabs/muxd/internal/gateway"
 	"github.com/batalabs/muxd/internal/mcp"
+	"github.com/batalabs/muxd/internal/scaffold"
+	"github.com/batalabs/muxd/internal/sink"
+	"github.com/batalabs/muxd/internal/store"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
@@ -43,19 +51,33 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		m.inputTokens = 0
 		m.outputTokens = 0
 		m.lastInputTokens = 0
+		m.ctxUsage = contextUsage{}
 		m.lastOutputTokens = 0
 		m.cacheCreationInputTokens = 0
 		m.cacheReadInputTokens = 0
 		m.lastCacheCreationInputTokens = 0
 		m.lastCacheReadInputTokens = 0
+		if m.pager != nil {
+			m.pager.Clear()
+		}
 		return m, tea.ClearScreen
 
 	case "/exit", "/quit":
 		return m, tea.Quit
 
+	case "/detach":
+		if !m.thinking {
+			return m, PrintToScrollback(m.renderError("No turn is running."))
+		}
+		if m.Daemon == nil || m.EmbeddedDaemon {
+			return m, PrintToScrollback(m.renderError("This turn runs inside this muxd process and would stop with it. Start muxd --daemon first to run turns in the background."))
+		}
+		m.detached = true
+		m.appendRuntimeLog("detach: " + m.Session.ID)
+		return m, tea.Quit
+
 	case "/new":
 		cwd := MustGetwd()
-		oldPrefix := m.Session.ID[:8]
 		if m.Daemon != nil {
 			sessionID, err := m.Daemon.CreateSession(cwd, m.modelID)
 			if err != nil {
@@ -77,6 +99,7 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		m.inputTokens = 0
 		m.outputTokens = 0
 		m.lastInputTokens = 0
+		m.ctxUsage = contextUsage{}
 		m.lastOutputTokens = 0
 		m.cacheCreationInputTokens = 0
 		m.cacheReadInputTokens = 0
@@ -86,18 +109,17 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		m.history = nil
 		m.historyIdx = -1
 		m.historyDraft = ""
-		m.checkpoints = nil
-		m.redoStack = nil
-		var cmds []tea.Cmd
-		cmds = append(cmds, PrintToScrollback(WelcomeStyle.Render("New session started.")))
-		if m.gitAvailable {
-			cmds = append(cmds, CleanupCheckpointRefs(oldPrefix))
-		}
-		return m, tea.Batch(cmds...)
+		return m, PrintToScrollback(WelcomeStyle.Render("New session started."))
 
 	case "/refresh":
 		return m.refreshCurrentSession()
 
+	case "/history":
+		if m.Session == nil || m.historyOffset == 0 {
+			return m, PrintToScrollback(WelcomeStyle.Render("No earlier messages."))
+		}
+		return m, m.loadOlderHistory()
+
 	case "/nodes":
 		if m.hubBaseURL == "" {
 			return m, PrintToScrollback(m.renderError("Not connected to a hub. Use --remote to connect."))
@@ -113,23 +135,55 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 			if n, err := strconv.Atoi(parts[2]); err == nil && n > 0 {
 				atSequence = n
 			} else {
-				return m, PrintToScrollback(m.renderError("Usage: /branch [--at N]"))
+				return m, PrintToScrollback(m.renderError("Usage: /branch [--at N]  (or /fork to pick the message)"))
 			}
 		}
-		sessionID := m.Session.ID
-		daemon := m.Daemon
-		store := m.Store
-		return m, func() tea.Msg {
-			if daemon != nil {
-				sess, err := daemon.BranchSession(sessionID, atSequence)
-				return BranchDoneMsg{Session: sess, Err: err}
+		return m, m.branchAt(atSequence)
+
+	case "/fork":
+		if m.thinking {
+			return m, PrintToScrollback(m.renderError("Cannot branch while agent is running."))
+		}
+		return m, m.openMessagePicker()
+
+	case "/nav":
+		return m, m.openMessageNav(0)
+
+	case "/jobs":
+		if len(parts) >= 2 && parts[1] == "run" {
+			prompt := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(clean, parts[0])), "run"))
+			if prompt == "" {
+				return m, PrintToScrollback(m.renderError("Usage: /jobs run <prompt>"))
+			}
+			return m, m.jobCmd("run", prompt)
+		}
+		if len(parts) >= 2 {
+			return m, PrintToScrollback(m.renderError("Usage: /jobs [run <prompt>]"))
+		}
+		return m, m.openJobPicker()
+
+	case "/quote":
+		sequence := 0
+		if len(parts) >= 2 {
+			n, err := strconv.Atoi(strings.TrimPrefix(parts[1], "#"))
+			if err != nil || n <= 0 {
+				return m, PrintToScrollback(m.renderError("Usage: /quote [N]  (message number; Ctrl+O to browse)"))
 			}
-			if store != nil {
-				sess, err := store.BranchSession(sessionID, atSequence)
-				return BranchDoneMsg{Session: sess, Err: err}
+			sequence = n
+		}
+		msgs, err := m.sessionMessages()
+		if err != nil {
+			return m, PrintToScrollback(m.renderError("Quote failed: " + err.Error()))
+		}
+		fp, ok := quoteTarget(forkPoints(msgs), sequence)
+		if !ok {
+			if sequence > 0 {
+				return m, PrintToScrollback(m.renderError(fmt.Sprintf("No message #%d with text to quote.", sequence)))
 			}
-			return BranchDoneMsg{Err: fmt.Errorf("no store available")}
+			return m, PrintToScrollback(m.renderError("No reply to quote yet."))
 		}
+		m.setInput(quoteText(fp.Text))
+		return m, nil
 
 	case "/rename":
 		if len(parts) < 2 {
@@ -139,11 +193,11 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		if m.Daemon != nil {
 			// Use the daemon endpoint which also marks agent as user-renamed.
 			if err := m.Daemon.RenameSession(m.Session.ID, newTitle); err != nil {
-				fmt.Fprintf(os.Stderr, "tui: rename via daemon: %v\n", err)
+				return m, PrintToScrollback(m.renderError("Rename failed: " + err.Error()))
 			}
 		} else if m.Store != nil {
 			if err := m.Store.UpdateSessionTitle(m.Session.ID, newTitle); err != nil {
-				fmt.Fprintf(os.Stderr, "tui: update session title: %v\n", err)
+				return m, PrintToScrollback(m.renderError("Rename failed: " + err.Error()))
 			}
 		}
 		m.Session.Title = newTitle
@@ -173,6 +227,7 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		m.inputTokens = sess.InputTokens
 		m.outputTokens = sess.OutputTokens
 		m.lastInputTokens = 0
+		m.ctxUsage = contextUsage{}
 		m.lastOutputTokens = 0
 		m.cacheCreationInputTokens = 0
 		m.cacheReadInputTokens = 0
@@ -183,7 +238,7 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		m.historyIdx = -1
 		m.historyDraft = ""
 		m.resuming = true
-		return m, m.loadSessionHistory()
+		return m, m.resumeSession()
 
 	case "/config":
 		if len(parts) == 1 {
@@ -198,6 +253,14 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 		}
+		if m.Daemon != nil {
+			switch strings.ToLower(parts[1]) {
+			case "set":
+				return m.setDaemonConfig(parts[2:])
+			case "reset":
+				return m, PrintToScrollback(m.renderError("/config reset is not available through the daemon; use /config set for each key."))
+			}
+		}
 		result, err := config.ExecuteConfigAction(&m.Prefs, parts[1:])
 		if err != nil {
 			return m, PrintToScrollback(m.renderError(err.Error()))
@@ -213,37 +276,38 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		}
 		return m, PrintToScrollback(strings.Join(styled, "\n"))
 
-	case "/undo":
-		if !m.gitAvailable {
-			return m, PrintToScrollback(m.renderError("Undo requires a git repository."))
+	case "/undo", "/redo":
+		if m.Daemon == nil || m.Session == nil {
+			return m, PrintToScrollback(m.renderError("Undo and redo need a daemon connection and an active session."))
 		}
 		if m.thinking {
-			return m, PrintToScrollback(m.renderError("Cannot undo while agent is running."))
-		}
-		if len(m.checkpoints) == 0 {
-			return m, PrintToScrollback(m.renderError("Nothing to undo."))
+			return m, PrintToScrollback(m.renderError("Cannot " + cmd[1:] + " while agent is running."))
 		}
-		cp := m.checkpoints[len(m.checkpoints)-1]
-		m.checkpoints = m.checkpoints[:len(m.checkpoints)-1]
-		m.redoStack = append(m.redoStack, cp)
-		sessionPrefix := m.Session.ID[:8]
-		return m, RestoreCheckpoint(cp, sessionPrefix)
-
-	case "/redo":
-		if !m.gitAvailable {
-			return m, PrintToScrollback(m.renderError("Redo requires a git repository."))
+		d, sessionID := m.Daemon, m.Session.ID
+		if cmd == "/undo" && len(parts) > 1 && (parts[1] == "--preview" || parts[1] == "preview") {
+			return m, func() tea.Msg {
+				cps, err := d.ListCheckpoints(sessionID)
+				if err != nil {
+					return UndoPreviewMsg{Err: err}
+				}
+				i := store.FirstUndone(cps)
+				if i == 0 {
+					return UndoPreviewMsg{Err: fmt.Errorf("nothing to undo")}
+				}
+				cp, diff, err := d.CheckpointDiff(sessionID, cps[i-1].ID)
+				return UndoPreviewMsg{Checkpoint: cp, Diff: diff, Err: err}
+			}
 		}
-		if m.thinking {
-			return m, PrintToScrollback(m.renderError("Cannot redo while agent is running."))
+		if cmd == "/undo" {
+			return m, func() tea.Msg {
+				cp, err := d.Undo(sessionID)
+				return UndoDoneMsg{Checkpoint: cp, Err: err}
+			}
 		}
-		if len(m.redoStack) == 0 {
-			return m, PrintToScrollback(m.renderError("Nothing to redo."))
+		return m, func() tea.Msg {
+			cp, err := d.Redo(sessionID)
+			return RedoDoneMsg{Checkpoint: cp, Err: err}
 		}
-		cp := m.redoStack[len(m.redoStack)-1]
-		m.redoStack = m.redoStack[:len(m.redoStack)-1]
-		m.checkpoints = append(m.checkpoints, cp)
-		sessionPrefix := m.Session.ID[:8]
-		return m, RestoreForRedo(cp, sessionPrefix)
 
 	case "/sh":
 		m.shellActive = true
@@ -268,11 +332,83 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 	case "/remember":
 		return m.handleRememberCommand(parts[1:])
 
+	case "/init":
+		return m.handleInitCommand()
+
 	case "/tools":
 		return m.handleToolsCommand(parts[1:])
 
-	case "/schedule":
-		return m.handleScheduleCommand(parts[1:])
+	case "/mcp":
+		return m.handleMCPCommand(parts[1:])
+
+	case "/ollama":
+		return m.handleOllamaCommand(parts[1:])
+
+	case "/egress":
+		return m.handleEgressCommand()
+
+	case "/notify":
+		return m.handleNotifyCommand(parts[1:])
+	case "/steer":
+		return m.handleSteerCommand(strings.TrimSpace(clean[len(parts[0]):]))
+
+	case "/slack", "/discord":
+		return m.handleAdapterCommand(strings.TrimPrefix(cmd, "/"), parts[1:])
+
+	case "/style":
+		return m.handleStyleCommand(parts[1:])
+
+	case "/verify":
+		return m.handleVerifyCommand(parts[1:])
+
+	case "/set":
+		return m.handleSetCommand(parts[1:])
+
+	case "/feedback":
+		if len(parts) < 2 {
+			return m, PrintToScrollback(m.renderError("Usage: /feedback <good|bad|clear> [note]  (or Ctrl+G / Ctrl+B)"))
+		}
+		note := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(clean, "/feedback")), parts[1]))
+		return m, m.sendFeedback(strings.ToLower(parts[1]), note)
+
+	case "/stats":
+		return m.handleStatsCommand(parts[1:])
+
+	case "/usage":
+		return m.handleUsageCommand(parts[1:])
+
+	case "/optimize":
+		return m.handleOptimizeCommand(parts[1:])
+
+	case "/context":
+		return m.handleContextCommand(parts[1:])
+
+	case "/compact":
+		if m.Daemon == nil || m.Session == nil {
+			return m, PrintToScrollback(m.renderError("Compact needs a daemon connection and an active session."))
+		}
+		if m.thinking {
+			return m, PrintToScrollback(m.renderError("Cannot compact while agent is running."))
+		}
+		return m, tea.Sequence(
+			PrintToScrollback(FooterMeta.Render("Summarizing earlier messages...")),
+			CompactCmd(m.Daemon, m.Session.ID),
+		)
+
+	case "/attach":
+		return m.handleAttachCommand(strings.TrimSpace(clean[len(parts[0]):]))
+
+	case "/export":
+		return m.handleExportCommand(parts[1:])
+
+	case "/plan":
+		return m.handlePlanCommand(parts[1:])
+
+	case "/share":
+		return m.handleShareCommand()
+
+	case "/unshare":
+		return m.handleUnshareCommand()
 
 	case "/consult":
 		question := strings.TrimSpace(strings.TrimPrefix(clean, "/consult"))
@@ -311,6 +447,9 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 		for _, c := range cmds {
 			grouped[c.Group] = append(grouped[c.Group], c)
 		}
+		for _, c := range gateway.Commands() {
+			grouped[c.Group] = append(grouped[c.Group], domain.CommandDef{Name: c.Name, Description: c.Description, Group: c.Group})
+		}
 		var lines []string
 		for _, g := range domain.CommandGroups {
 			defs := grouped[g.Key]
@@ -323,35 +462,86 @@ func (m Model) handleSlashCommand(input string) (tea.Model, tea.Cmd) {
 			}
 			lines = append(lines, "")
 		}
-		lines = append(lines, FooterMeta.Render("  Ctrl+R to open session picker  |  Tab to autocomplete"))
+		lines = append(lines, FooterMeta.Render("  Ctrl+R to open session picker  |  Ctrl+O to step through messages  |  Ctrl+G / Ctrl+B to rate the last reply  |  Tab to autocomplete"))
 		return m, PrintToScrollback(strings.Join(lines, "\n"))
 
 	default:
+		if c, ok := gateway.Lookup(cmd); ok {
+			return m.runGatewayCommand(c, parts[1:])
+		}
 		return m, PrintToScrollback(m.renderError("Unknown command: " + cmd + "  (try /help)"))
 	}
 }
 
+// setDaemonConfig handles /config set when a daemon is connected: the
+// value is validated locally, then written to the daemon's preferences.
+func (m Model) setDaemonConfig(args []string) (tea.Model, tea.Cmd) {
+	if len(args) < 2 {
+		return m, PrintToScrollback(m.renderError("usage: /config set <key> <value>"))
+	}
+	key, value := args[0], args[1]
+	prefs := m.Prefs
+	if err := prefs.Set(key, value); err != nil {
+		return m, PrintToScrollback(m.renderError(err.Error()))
+	}
+	if _, err := m.Daemon.SetConfig(key, value); err != nil {
+		return m, PrintToScrollback(m.renderError("Config save failed: " + err.Error()))
+	}
+	m.Prefs = prefs
+	m.applyConfigSetting(key, value)
+	msg := fmt.Sprintf("Set %s = %s", key, m.Prefs.Get(key))
+	if canonical := config.CanonicalKey(key); canonical != key {
+		msg = fmt.Sprintf("Set %s = %s (%s is deprecated; use %s)", canonical, m.Prefs.Get(key), key, canonical)
+	}
+	return m, tea.Sequence(PrintToScrollback(FooterMeta.Render(msg)), m.ollamaModelCheck(key))
+}
+
 func (m Model) handleRememberCommand(args []string) (tea.Model, tea.Cmd) {
+	// With a daemon, memory lives in the daemon's project, which may be
+	// on another machine.
+	if m.Daemon != nil {
+		return m.rememberVia(args, m.Daemon.GetMemory, m.Daemon.SetMemory, m.Daemon.DeleteMemory)
+	}
+
 	cwd, _ := tools.Getwd()
 	if cwd == "" {
 		return m, PrintToScrollback(m.renderError("Cannot determine working directory."))
 	}
 
 	mem := tools.NewProjectMemory(cwd)
-
-	// /remember --remove <key>
-	if len(args) >= 2 && args[0] == "--remove" {
-		key := args[1]
+	set := func(key, value string) error {
 		facts, err := mem.Load()
 		if err != nil {
-			return m, PrintToScrollback(m.renderError("Loading memory: " + err.Error()))
+			return err
+		}
+		facts[key] = value
+		return mem.Save(facts)
+	}
+	remove := func(key string) error {
+		facts, err := mem.Load()
+		if err != nil {
+			return err
 		}
 		if _, ok := facts[key]; !ok {
-			return m, PrintToScrollback(m.renderError("Key " + key + " not found in project memory."))
+			return fmt.Errorf("key %s not found in project memory", key)
 		}
 		delete(facts, key)
-		if err := mem.Save(facts); err != nil {
-			return m, PrintToScrollback(m.renderError("Saving memory: " + err.Error()))
+		return mem.Save(facts)
+	}
+	return m.rememberVia(args, mem.Load, set, remove)
+}
+
+func (m Model) rememberVia(
+	args []string,
+	load func() (map[string]string, error),
+	set func(key, value string) error,
+	remove func(key string) error,
+) (tea.Model, tea.Cmd) {
+	// /remember --remove <key>
+	if len(args) >= 2 && args[0] == "--remove" {
+		key := args[1]
+		if err := remove(key); err != nil {
+			return m, PrintToScrollback(m.renderError("Removing memory: " + err.Error()))
 		}
 		return m, PrintToScrollback(WelcomeStyle.Render("Removed memory fact: " + key))
 	}
@@ -359,14 +549,14 @@ func (m Model) handleRememberCommand(args []string) (tea.Model, tea.Cmd) {
 	// /remember <key> <value...>
 	if len(args) < 2 {
 		// Show current facts
-		facts, err := mem.Load()
+		facts, err := load()
 		if err != nil {
 			return m, PrintToScrollback(m.renderError("Loading memory: " + err.Error()))
 		}
 		if len(facts) == 0 {
 			return m, PrintToScrollback(FooterMeta.Render("No project memory facts stored. Usage: /remember <key> <value>"))
 		}
-		formatted := mem.FormatForPrompt()
+		formatted := tools.FormatFacts(facts)
 		var lines []string
 		lines = append(lines, FooterHead.Render("Project Memory"))
 		for _, line := range strings.Split(formatted, "\n") {
@@ -378,42 +568,68 @@ func (m Model) handleRememberCommand(args []string) (tea.Model, tea.Cmd) {
 	key := args[0]
 	value := strings.Join(args[1:], " ")
 
-	facts, err := mem.Load()
-	if err != nil {
-		return m, PrintToScrollback(m.renderError("Loading memory: " + err.Error()))
-	}
-	facts[key] = value
-	if err := mem.Save(facts); err != nil {
+	if err := set(key, value); err != nil {
 		return m, PrintToScrollback(m.renderError("Saving memory: " + err.Error()))
 	}
 
 	return m, PrintToScrollback(WelcomeStyle.Render(fmt.Sprintf("Saved memory fact: %s = %s", key, value)))
 }
 
+func (m Model) handleInitCommand() (tea.Model, tea.Cmd) {
+	var (
+		res *scaffold.Result
+		err error
+	)
+	// Like /remember, set up the daemon's project when there is one.
+	if m.Daemon != nil {
+		res, err = m.Daemon.InitProject()
+	} else {
+		cwd, _ := tools.Getwd()
+		if cwd == "" {
+			return m, PrintToScrollback(m.renderError("Cannot determine working directory."))
+		}
+		var r scaffold.Result
+		r, err = scaffold.Init(cwd)
+		res = &r
+	}
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Initializing project: " + err.Error()))
+	}
+	lines := []string{FooterHead.Render("Project: " + res.Project.String())}
+	for _, name := range res.Written {
+		lines = append(lines, FooterMeta.Render("  wrote "+name))
+	}
+	for _, name := range res.Kept {
+		lines = append(lines, FooterMeta.Render("  kept existing "+name))
+	}
+	if len(res.Facts) > 0 {
+		lines = append(lines, FooterMeta.Render("  remembered "+strings.Join(res.Facts, ", ")))
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
 func (m Model) handleToolsCommand(args []string) (tea.Model, tea.Cmd) {
 	sub := "list"
 	if len(args) > 0 {
 		sub = strings.ToLower(strings.TrimSpace(args[0]))
 	}
 	disabled := m.Prefs.DisabledToolsSet()
-	toolNames := tools.ToolNames()
-	// Merge MCP tool names into the list.
-	if len(m.mcpToolNames) > 0 {
-		toolNames = append(toolNames, m.mcpToolNames...)
-		sort.Strings(toolNames)
-	}
 
 	switch sub {
 	case "list":
-		m.toolPicker = NewToolPicker(toolNames, disabled)
-		return m, nil
+		// Show the daemon's current tools rather than what this TUI
+		// knows locally.
+		if m.Daemon != nil {
+			return m, fetchTools(m.Daemon, true)
+		}
+		return m, m.openToolPicker()
 
 	case "profile":
 		if len(args) < 2 {
 			return m, PrintToScrollback(m.renderError("Usage: /tools profile <safe|coder|research>"))
 		}
 		profile := strings.ToLower(strings.TrimSpace(args[1]))
-		if profile != "safe" && profile != "coder" && profile != "research" {
+		if !slices.Contains(tools.ToolProfiles, profile) {
 			return m, PrintToScrollback(m.renderError("Unknown profile: " + profile))
 		}
 		disabled = tools.ToolProfileDisabledSet(profile)
@@ -425,10 +641,10 @@ func (m Model) handleToolsCommand(args []string) (tea.Model, tea.Cmd) {
 			return m, PrintToScrollback(m.renderError("Usage: /tools " + sub + " <tool_name>"))
 		}
 		name := tools.NormalizeToolName(args[1])
-		// Accept both built-in tools and MCP tools.
+		// Accept built-in and MCP tools, and anything else the daemon runs.
 		_, isBuiltin := tools.FindTool(name)
 		isMCP := mcp.IsMCPTool(name)
-		if !isBuiltin && !isMCP {
+		if !isBuiltin && !isMCP && !slices.Contains(m.toolNames(), name) {
 			return m, PrintToScrollback(m.renderError("Unknown tool: " + name))
 		}
 		switch sub {
@@ -456,112 +672,780 @@ func (m Model) handleToolsCommand(args []string) (tea.Model, tea.Cmd) {
 	}
 }
 
-func (m Model) handleScheduleCommand(args []string) (tea.Model, tea.Cmd) {
-	if m.Store == nil {
-		return m, PrintToScrollback(m.renderError("Scheduler unavailable: no store configured."))
+func (m Model) handleStyleCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("Style requires a daemon connection and an active session."))
+	}
+	var (
+		style *daemon.SessionStyle
+		err   error
+	)
+	switch {
+	case len(args) == 0:
+		style, err = m.Daemon.GetStyle(m.Session.ID)
+	case strings.ToLower(args[0]) == "reset":
+		style, err = m.Daemon.SetStyle(m.Session.ID, nil, nil, true)
+	case strings.ToLower(args[0]) == "lang":
+		if len(args) < 2 {
+			return m, PrintToScrollback(m.renderError("Usage: /style lang <language|default>"))
+		}
+		language := strings.Join(args[1:], " ")
+		style, err = m.Daemon.SetStyle(m.Session.ID, &language, nil, false)
+	default:
+		tone := args[0]
+		style, err = m.Daemon.SetStyle(m.Session.ID, nil, &tone, false)
+	}
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Failed to update style: " + err.Error()))
+	}
+
+	language, tone := style.Language, style.Tone
+	if language == "" {
+		language = "default"
+	}
+	if tone == "" {
+		tone = "default"
+	}
+	lines := []string{
+		FooterHead.Render("Response style (this session)"),
+		FooterMeta.Render("  tone:     " + tone),
+		FooterMeta.Render("  language: " + language),
 	}
 	if len(args) == 0 {
-		return m, PrintToScrollback(m.renderError("Usage: /schedule add <tool> <HH:MM|RFC3339> <json> [--daily|--hourly] | /schedule add-task <HH:MM|RFC3339> <prompt> [--daily|--hourly] | /schedule list | /schedule cancel <id>"))
+		lines = append(lines, FooterMeta.Render("  Usage: /style <"+strings.Join(config.StyleTones, "|")+"|default> | /style lang <language|default> | /style reset"))
 	}
-	switch strings.ToLower(args[0]) {
-	case "list":
-		items, err := m.Store.ListScheduledToolJobs(100)
-		if err != nil {
-			return m, PrintToScrollback(m.renderError("Failed to list scheduled jobs: " + err.Error()))
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// handleVerifyCommand shows or changes the session's verification pass.
+// Usage: /verify [on [model]|off].
+func (m Model) handleVerifyCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("Verify requires a daemon connection and an active session."))
+	}
+	var (
+		v   *daemon.SessionVerify
+		err error
+	)
+	switch {
+	case len(args) == 0:
+		v, err = m.Daemon.GetVerify(m.Session.ID)
+	case strings.ToLower(args[0]) == "on":
+		v, err = m.Daemon.SetVerify(m.Session.ID, true, strings.Join(args[1:], " "))
+	case strings.ToLower(args[0]) == "off" && len(args) == 1:
+		v, err = m.Daemon.SetVerify(m.Session.ID, false, "")
+	default:
+		return m, PrintToScrollback(m.renderError("Usage: /verify [on [model]|off]"))
+	}
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Failed to update verification: " + err.Error()))
+	}
+
+	state := "off"
+	if v.Enabled {
+		state = "on"
+	}
+	model := v.Model
+	if model == "" {
+		model = m.modelLabel + " (session model)"
+	}
+	lines := []string{
+		FooterHead.Render("Answer verification (this session)"),
+		FooterMeta.Render("  state: " + state),
+		FooterMeta.Render("  model: " + model),
+	}
+	if m.verifyInputTokens+m.verifyOutputTokens > 0 {
+		lines = append(lines, FooterMeta.Render(fmt.Sprintf("  used:  %d in / %d out", m.verifyInputTokens, m.verifyOutputTokens)))
+	}
+	if len(args) == 0 {
+		lines = append(lines, FooterMeta.Render("  Usage: /verify on [model] | /verify off"))
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// setParams maps /set parameter names to sampling fields.
+var setParams = map[string]string{
+	"temp":        "temperature",
+	"temperature": "temperature",
+	"top_p":       "top_p",
+	"topp":        "top_p",
+	"seed":        "seed",
+	"max_tokens":  "max_tokens",
+	"max":         "max_tokens",
+	"stop":        "stop",
+}
+
+// handleSetCommand shows or changes the session's sampling parameters:
+// /set temp 0.2, /set max_tokens 4096, /set stop END,\n\n, /set seed default,
+// /set reset.
+func (m Model) handleSetCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("Sampling settings require a daemon connection and an active session."))
+	}
+	const usage = "Usage: /set <temp|top_p|seed|max_tokens|stop> <value|default> | /set reset"
+	var (
+		sampling *daemon.SessionSampling
+		err      error
+	)
+	switch {
+	case len(args) == 0:
+		sampling, err = m.Daemon.GetSampling(m.Session.ID)
+	case strings.ToLower(args[0]) == "reset":
+		sampling, err = m.Daemon.SetSampling(m.Session.ID, nil, true)
+	case len(args) < 2:
+		return m, PrintToScrollback(m.renderError(usage))
+	default:
+		field, ok := setParams[strings.ToLower(args[0])]
+		if !ok {
+			return m, PrintToScrollback(m.renderError(usage))
 		}
-		if len(items) == 0 {
-			return m, PrintToScrollback(WelcomeStyle.Render("No scheduled jobs."))
+		sampling, err = m.Daemon.SetSampling(m.Session.ID, map[string]string{field: strings.Join(args[1:], " ")}, false)
+	}
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Failed to update sampling: " + err.Error()))
+	}
+
+	temperature, topP, seed := "default", "default", "none"
+	if sampling.Temperature != nil {
+		temperature = strconv.FormatFloat(*sampling.Temperature, 'g', -1, 64)
+	}
+	if sampling.TopP != nil {
+		topP = strconv.FormatFloat(*sampling.TopP, 'g', -1, 64)
+	}
+	if sampling.Seed != nil {
+		seed = strconv.FormatInt(*sampling.Seed, 10)
+		if !sampling.SupportsSeed {
+			seed += " (not supported by " + sampling.Provider + ")"
 		}
-		var lines []string
-		lines = append(lines, FooterHead.Render("Scheduled jobs"))
-		for _, it := range items {
-			id := it.ID
-			if len(id) > 8 {
-				id = id[:8]
+	}
+	lines := []string{
+		FooterHead.Render("Sampling (this session)"),
+		FooterMeta.Render("  temperature: " + temperature),
+		FooterMeta.Render("  top_p:       " + topP),
+		FooterMeta.Render("  seed:        " + seed),
+	}
+	lines = append(lines, outputLimitLines(sampling)...)
+	if len(args) == 0 {
+		lines = append(lines, FooterMeta.Render("  "+usage))
+		lines = append(lines, FooterMeta.Render("  Defaults for new sessions: /config set sampling.<name>"))
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// outputLimitLines renders the effective max tokens and stop sequences.
+func outputLimitLines(s *daemon.SessionSampling) []string {
+	maxTokens := "provider default"
+	switch {
+	case s.MaxTokens > 0:
+		maxTokens = strconv.Itoa(s.MaxTokens)
+	case s.DefaultMaxTokens > 0:
+		maxTokens = fmt.Sprintf("%d (%s default)", s.DefaultMaxTokens, s.Provider)
+	}
+	stop := "none"
+	if len(s.Stop) > 0 {
+		quoted := make([]string, len(s.Stop))
+		for i, seq := range s.Stop {
+			quoted[i] = strconv.Quote(seq)
+		}
+		stop = strings.Join(quoted, ", ")
+	}
+	return []string{
+		FooterMeta.Render("  max tokens:  " + maxTokens),
+		FooterMeta.Render("  stop:        " + stop),
+	}
+}
+
+// sendFeedback rates the latest assistant reply via the daemon.
+func (m Model) sendFeedback(rating, note string) tea.Cmd {
+	if m.Daemon == nil || m.Session == nil {
+		return PrintToScrollback(m.renderError("Feedback requires a daemon connection and an active session."))
+	}
+	d, sessionID := m.Daemon, m.Session.ID
+	return func() tea.Msg {
+		seq, err := d.SendFeedback(sessionID, rating, note, 0)
+		return FeedbackSentMsg{Rating: rating, Note: note, Sequence: seq, Err: err}
+	}
+}
+
+func (m Model) handleFeedbackSent(msg FeedbackSentMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError("Feedback failed: " + msg.Err.Error()))
+	}
+	var text string
+	switch msg.Rating {
+	case "clear", "none":
+		text = "Feedback cleared."
+	default:
+		text = fmt.Sprintf("Marked last reply as %s.", msg.Rating)
+		if msg.Note == "" {
+			text += " Add a note with /feedback " + msg.Rating + " <note>."
+		}
+	}
+	return m, PrintToScrollback(WelcomeStyle.Render(text))
+}
+
+func (m Model) handleStatsCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError("No daemon connection available."))
+	}
+	days := 0
+	if len(args) > 0 {
+		n, err := strconv.Atoi(strings.TrimSuffix(args[0], "d"))
+		if err != nil || n < 0 {
+			return m, PrintToScrollback(m.renderError("Usage: /stats [days]"))
+		}
+		days = n
+	}
+	project := ""
+	if m.Session != nil {
+		project = m.Session.ProjectPath
+	}
+	stats, err := m.Daemon.GetStats(project, days)
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Failed to get stats: " + err.Error()))
+	}
+
+	window := "all time"
+	if days > 0 {
+		window = fmt.Sprintf("last %d days", days)
+	}
+	fb := stats.Feedback
+	lines := []string{FooterHead.Render("Response quality (" + window + ")")}
+	rated := fb.Good + fb.Bad
+	lines = append(lines, FooterMeta.Render(fmt.Sprintf("  Replies: %d   rated: %d   good: %d   bad: %d", fb.Turns, rated, fb.Good, fb.Bad)))
+	if rated > 0 {
+		lines = append(lines, FooterMeta.Render(fmt.Sprintf("  Approval: %d%%", fb.Good*100/rated)))
+	} else {
+		lines = append(lines, FooterMeta.Render("  No ratings yet. Rate replies with Ctrl+G / Ctrl+B or /feedback."))
+	}
+	if len(fb.RecentNotes) > 0 {
+		lines = append(lines, "", FooterHead.Render("Recent feedback notes"))
+		for _, n := range fb.RecentNotes {
+			mark := "good"
+			if n.Rating < 0 {
+				mark = "bad "
 			}
-			displayName := it.ToolName
-			if it.ToolName == tools.AgentTaskToolName {
-				displayName = "agent_task"
-				if p, ok := it.ToolInput["prompt"].(string); ok && p != "" {
-					if len(p) > 40 {
-						p = p[:40] + "..."
-					}
-					displayName += ": " + p
-				}
+			lines = append(lines, FooterMeta.Render(fmt.Sprintf("  %s  %s #%d: %s", mark, n.SessionTitle, n.Sequence, n.Note)))
+		}
+	}
+	if len(stats.Failures) > 0 {
+		lines = append(lines, "", FooterHead.Render("Recurring failures"))
+		for _, f := range stats.Failures {
+			lines = append(lines, FooterMeta.Render(fmt.Sprintf("  %dx %s (%d sessions)", f.Count, f.Category, f.Sessions)))
+			if f.LastDiagnosis != "" {
+				lines = append(lines, FooterMeta.Render("      "+f.LastDiagnosis))
 			}
-			line := fmt.Sprintf("  %-8s %-14s %-9s %s", id, displayName, it.Status, it.ScheduledFor.Local().Format("2006-01-02 15:04"))
-			lines = append(lines, FooterMeta.Render(line))
 		}
-		return m, PrintToScrollback(strings.Join(lines, "\n"))
-	case "cancel":
-		if len(args) < 2 {
-			return m, PrintToScrollback(m.renderError("Usage: /schedule cancel <id>"))
+	}
+	if len(stats.Spend) > 0 {
+		lines = append(lines, "", FooterHead.Render("Estimated spend per day"))
+		var days []string
+		totals := map[string]float64{}
+		models := map[string][]string{}
+		for _, d := range stats.Spend {
+			if _, ok := totals[d.Day]; !ok {
+				days = append(days, d.Day)
+			}
+			totals[d.Day] += d.CostUSD
+			models[d.Day] = append(models[d.Day], fmt.Sprintf("%s $%.2f", d.Model, d.CostUSD))
 		}
-		if err := m.Store.CancelScheduledToolJob(args[1]); err != nil {
-			return m, PrintToScrollback(m.renderError("Failed to cancel job: " + err.Error()))
+		for _, day := range days {
+			lines = append(lines, FooterMeta.Render(fmt.Sprintf("  %s  $%.2f  (%s)", day, totals[day], strings.Join(models[day], ", "))))
 		}
-		return m, PrintToScrollback(WelcomeStyle.Render("Canceled scheduled job: " + args[1]))
-	case "add":
-		if len(args) < 4 {
-			return m, PrintToScrollback(m.renderError("Usage: /schedule add <tool> <HH:MM|RFC3339> <json> [--daily|--hourly]"))
+	}
+	if stats.Host != nil && stats.Host.Known() {
+		lines = append(lines, "", FooterHead.Render("Host resources"))
+		for _, line := range stats.Host.Lines() {
+			lines = append(lines, FooterMeta.Render("  "+line))
 		}
-		toolName := tools.NormalizeToolName(args[1])
-		if _, ok := tools.FindTool(toolName); !ok {
-			return m, PrintToScrollback(m.renderError("Unknown tool: " + toolName))
+	}
+	if m.Session != nil {
+		if sampling, err := m.Daemon.GetSampling(m.Session.ID); err == nil {
+			lines = append(lines, "", FooterHead.Render("Output limits (this session)"))
+			lines = append(lines, outputLimitLines(sampling)...)
 		}
-		scheduledFor, err := tools.ParseScheduleTime(args[2], time.Now())
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// handleUsageCommand shows token usage and estimated spend across all
+// sessions. Usage: /usage [7d|30d|<days>d].
+func (m Model) handleUsageCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError("No daemon connection available."))
+	}
+	window := "7d"
+	if len(args) > 0 {
+		window = args[0]
+		if !strings.HasSuffix(window, "d") {
+			window += "d"
+		}
+		if _, err := daemon.ParseUsageWindow(window, time.Now()); err != nil {
+			return m, PrintToScrollback(m.renderError("Usage: /usage [7d|30d]"))
+		}
+	}
+
+	var lines []string
+	for _, g := range []struct{ groupBy, title string }{
+		{store.UsageByDay, "Day"},
+		{store.UsageByModel, "Model"},
+		{store.UsageByProject, "Project"},
+	} {
+		usage, err := m.Daemon.GetUsage(window, g.groupBy)
 		if err != nil {
-			return m, PrintToScrollback(m.renderError(err.Error()))
+			return m, PrintToScrollback(m.renderError("Failed to get usage: " + err.Error()))
 		}
-		recurrence := "once"
-		rawTail := strings.TrimSpace(strings.Join(args[3:], " "))
-		if strings.HasSuffix(rawTail, " --daily") {
-			recurrence = "daily"
-			rawTail = strings.TrimSpace(strings.TrimSuffix(rawTail, " --daily"))
-		} else if strings.HasSuffix(rawTail, " --hourly") {
-			recurrence = "hourly"
-			rawTail = strings.TrimSpace(strings.TrimSuffix(rawTail, " --hourly"))
+		if g.groupBy == store.UsageByDay {
+			lines = append(lines, FooterHead.Render(fmt.Sprintf("Token usage since %s (estimated $%.2f)", usage.Since, usage.Total.CostUSD)))
+			if len(usage.Rows) == 0 {
+				lines = append(lines, FooterMeta.Render("  No usage recorded in this window."))
+				break
+			}
+		}
+		lines = append(lines, "")
+		lines = append(lines, renderUsageTable(g.title, usage.Rows)...)
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// handleOptimizeCommand runs the cost advisor over recent usage and lists
+// its suggestions. Usage: /optimize [7d|30d], /optimize apply <n,...|all>.
+func (m Model) handleOptimizeCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError("No daemon connection available."))
+	}
+	if len(args) > 0 && args[0] == "apply" {
+		return m.applyOptimizeSuggestions(args[1:])
+	}
+	window := "30d"
+	if len(args) > 0 {
+		window = args[0]
+		if !strings.HasSuffix(window, "d") {
+			window += "d"
 		}
-		var input map[string]any
-		if err := json.Unmarshal([]byte(rawTail), &input); err != nil {
-			return m, PrintToScrollback(m.renderError("Invalid JSON tool input: " + err.Error()))
+		if _, err := daemon.ParseUsageWindow(window, time.Now()); err != nil {
+			return m, PrintToScrollback(m.renderError("Usage: /optimize [7d|30d]  or  /optimize apply <n,...|all>"))
 		}
-		id, err := m.Store.CreateScheduledToolJob(toolName, input, scheduledFor, recurrence)
-		if err != nil {
-			return m, PrintToScrollback(m.renderError("Failed to schedule job: " + err.Error()))
+	}
+	report, err := m.Daemon.GetOptimizeReport(window)
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Failed to get cost suggestions: " + err.Error()))
+	}
+	m.optimizeReport = report
+	return m, PrintToScrollback(renderOptimizeReport(report))
+}
+
+// applyOptimizeSuggestions sets the preferences behind the numbered
+// suggestions of the last /optimize report, as /config set would.
+func (m Model) applyOptimizeSuggestions(args []string) (tea.Model, tea.Cmd) {
+	report := m.optimizeReport
+	if report == nil {
+		return m, PrintToScrollback(m.renderError("Run /optimize first to see the suggestions."))
+	}
+	if len(args) == 0 {
+		return m, PrintToScrollback(m.renderError("Usage: /optimize apply <n,...|all>"))
+	}
+	var picked []daemon.Suggestion
+	if args[0] == "all" {
+		for _, s := range report.Suggestions {
+			if s.Key != "" {
+				picked = append(picked, s)
+			}
 		}
-		return m, PrintToScrollback(WelcomeStyle.Render(
-			fmt.Sprintf("Scheduled job %s: %s at %s (%s)", id[:8], toolName, scheduledFor.Local().Format("2006-01-02 15:04"), recurrence),
-		))
-	case "add-task":
-		if len(args) < 3 {
-			return m, PrintToScrollback(m.renderError("Usage: /schedule add-task <HH:MM|RFC3339> <prompt> [--daily|--hourly]"))
+	} else {
+		for _, field := range strings.FieldsFunc(strings.Join(args, ","), func(r rune) bool { return r == ',' || r == ' ' }) {
+			n, err := strconv.Atoi(field)
+			if err != nil || n < 1 || n > len(report.Suggestions) {
+				return m, PrintToScrollback(m.renderError(fmt.Sprintf("No suggestion %s (1-%d).", field, len(report.Suggestions))))
+			}
+			s := report.Suggestions[n-1]
+			if s.Key == "" {
+				return m, PrintToScrollback(m.renderError(fmt.Sprintf("Suggestion %d is advice only; there is no setting to apply.", n)))
+			}
+			picked = append(picked, s)
 		}
-		scheduledFor, err := tools.ParseScheduleTime(args[1], time.Now())
-		if err != nil {
-			return m, PrintToScrollback(m.renderError(err.Error()))
+	}
+	if len(picked) == 0 {
+		return m, PrintToScrollback(FooterMeta.Render("No suggestions to apply."))
+	}
+
+	var lines []string
+	var saved float64
+	for _, s := range picked {
+		prefs := m.Prefs
+		if err := prefs.Set(s.Key, s.Value); err != nil {
+			return m, PrintToScrollback(m.renderError(s.Key + ": " + err.Error()))
 		}
-		recurrence := "once"
-		rawTail := strings.TrimSpace(strings.Join(args[2:], " "))
-		if strings.HasSuffix(rawTail, " --daily") {
-			recurrence = "daily"
-			rawTail = strings.TrimSpace(strings.TrimSuffix(rawTail, " --daily"))
-		} else if strings.HasSuffix(rawTail, " --hourly") {
-			recurrence = "hourly"
-			rawTail = strings.TrimSpace(strings.TrimSuffix(rawTail, " --hourly"))
+		if _, err := m.Daemon.SetConfig(s.Key, s.Value); err != nil {
+			return m, PrintToScrollback(m.renderError("Config save failed: " + err.Error()))
 		}
-		if strings.TrimSpace(rawTail) == "" {
-			return m, PrintToScrollback(m.renderError("prompt is required"))
+		m.Prefs = prefs
+		m.applyConfigSetting(s.Key, s.Value)
+		saved += s.MonthlyUSD
+		lines = append(lines, FooterMeta.Render(fmt.Sprintf("  Set %s = %s", s.Key, s.Value)))
+	}
+	m.optimizeReport = nil
+	lines = append(lines, WelcomeStyle.Render(fmt.Sprintf("Applied %d suggestion(s), estimated to save $%.2f a month.", len(picked), saved)))
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// renderOptimizeReport formats a cost advisor report with its suggestions
+// numbered for /optimize apply.
+func renderOptimizeReport(r *daemon.OptimizeReport) string {
+	lines := []string{
+		FooterHead.Render(fmt.Sprintf("Cost review since %s: $%.2f spent, about $%.2f a month", r.Since, r.SpendUSD, r.MonthlyUSD)),
+		FooterMeta.Render(fmt.Sprintf("  %d session(s), %d turn(s), %d model call(s)  |  %s input per call  |  %.0f%% of calls run tools  |  %.0f%% cache hits",
+			r.Stats.Sessions, r.Stats.Turns, r.Stats.Calls, formatTokenCount(int64(r.InputPerCall)), r.ToolCallShare*100, r.CacheHitRate*100)),
+		"",
+	}
+	if len(r.Suggestions) == 0 {
+		lines = append(lines, FooterMeta.Render("  No suggestions: your settings already fit this usage."))
+		return strings.Join(lines, "\n")
+	}
+	applicable := false
+	for i, s := range r.Suggestions {
+		lines = append(lines, WelcomeStyle.Render(fmt.Sprintf("  %d. %s  (saves ~$%.2f/month)", i+1, s.Title, s.MonthlyUSD)))
+		lines = append(lines, FooterMeta.Render("     "+s.Detail))
+		if s.Key != "" {
+			applicable = true
+			lines = append(lines, FooterMeta.Render(fmt.Sprintf("     sets %s = %s", s.Key, s.Value)))
 		}
-		input := map[string]any{"prompt": rawTail}
-		id, err := m.Store.CreateScheduledToolJob(tools.AgentTaskToolName, input, scheduledFor, recurrence)
-		if err != nil {
-			return m, PrintToScrollback(m.renderError("Failed to schedule task: " + err.Error()))
+	}
+	if applicable {
+		lines = append(lines, "", FooterMeta.Render("  Savings are estimates. Apply with /optimize apply <n,...> or /optimize apply all."))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// handleContextCommand shows what the next model call will send, to debug
+// what the model can no longer see. Usage: /context [system|tools].
+func (m Model) handleContextCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("No daemon connection available."))
+	}
+	view := ""
+	if len(args) > 0 {
+		view = strings.ToLower(args[0])
+	}
+	if view != "" && view != "system" && view != "tools" {
+		return m, PrintToScrollback(m.renderError("Usage: /context [system|tools]"))
+	}
+	sc, err := m.Daemon.GetContext(m.Session.ID)
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Failed to get context: " + err.Error()))
+	}
+	return m, PrintToScrollback(renderContext(sc, view))
+}
+
+// renderContext formats a session context: an overview by default, or the
+// full system prompt or tool list.
+func renderContext(sc *daemon.SessionContext, view string) string {
+	switch view {
+	case "system":
+		return FooterHead.Render(fmt.Sprintf("System prompt (~%s tokens)", formatTokenCount(int64(sc.SystemTokens)))) + "\n" + sc.System
+	case "tools":
+		return FooterHead.Render(fmt.Sprintf("Tools (%d, ~%s tokens)", len(sc.Tools), formatTokenCount(int64(sc.ToolTokens)))) + "\n" +
+			FooterMeta.Render("  "+strings.Join(sc.Tools, ", "))
+	}
+
+	msgTokens := 0
+	for _, msg := range sc.Messages {
+		msgTokens += msg.Tokens
+	}
+	head := fmt.Sprintf("Next call to %s/%s: ~%s tokens", sc.Provider, sc.Model, formatTokenCount(int64(sc.EstimatedTokens)))
+	if sc.LastInputTokens > 0 {
+		head += fmt.Sprintf(" (last call used %s)", formatTokenCount(int64(sc.LastInputTokens)))
+	}
+	lines := []string{
+		FooterHead.Render(head),
+		FooterMeta.Render(fmt.Sprintf("  %-16s ~%s tokens   /context system", "System prompt", formatTokenCount(int64(sc.SystemTokens)))),
+		FooterMeta.Render(fmt.Sprintf("  %-16s ~%s tokens   /context tools", fmt.Sprintf("Tools (%d)", len(sc.Tools)), formatTokenCount(int64(sc.ToolTokens)))),
+		FooterMeta.Render(fmt.Sprintf("  %-16s ~%s tokens", fmt.Sprintf("Messages (%d)", len(sc.Messages)), formatTokenCount(int64(msgTokens)))),
+	}
+
+	if len(sc.Pinned) > 0 {
+		lines = append(lines, "", FooterHead.Render("Pinned memory"))
+		for _, p := range sc.Pinned {
+			lines = append(lines, FooterMeta.Render("  "+contextPreview(p.Key+": "+p.Value, 100)))
+		}
+	}
+	if sc.Summary != "" {
+		lines = append(lines, "", FooterHead.Render("Compaction summary (older messages are not sent)"))
+		for _, l := range strings.Split(strings.TrimSpace(sc.Summary), "\n") {
+			lines = append(lines, FooterMeta.Render("  "+l))
+		}
+	}
+
+	lines = append(lines, "", FooterHead.Render("Message window"))
+	if len(sc.Messages) == 0 {
+		lines = append(lines, FooterMeta.Render("  No messages yet."))
+	}
+	for i, msg := range sc.Messages {
+		lines = append(lines, FooterMeta.Render(fmt.Sprintf("  %3d  %-9s %7s  %s", i+1, msg.Role, formatTokenCount(int64(msg.Tokens)), contextMessagePreview(msg))))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// contextMessagePreview summarizes a message on one line.
+func contextMessagePreview(msg daemon.ContextMessage) string {
+	if len(msg.Blocks) == 0 {
+		return contextPreview(msg.Content, 80)
+	}
+	var parts []string
+	for _, b := range msg.Blocks {
+		switch b.Type {
+		case "tool_use":
+			parts = append(parts, "[tool_use "+b.ToolName+"]")
+		case "tool_result":
+			parts = append(parts, "[tool_result] "+b.ToolResult)
+		case "image":
+			parts = append(parts, "[image "+b.MediaType+"]")
+		default:
+			parts = append(parts, b.Text)
 		}
-		return m, PrintToScrollback(WelcomeStyle.Render(
-			fmt.Sprintf("Scheduled agent task %s at %s (%s)", id[:8], scheduledFor.Local().Format("2006-01-02 15:04"), recurrence),
-		))
+	}
+	return contextPreview(strings.Join(parts, " "), 80)
+}
+
+// contextPreview collapses whitespace and cuts s to n runes.
+func contextPreview(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
+
+// renderUsageTable formats usage rows as an aligned table under a header
+// naming the grouping.
+func renderUsageTable(title string, rows []store.UsageRow) []string {
+	keyWidth := len(title)
+	for _, r := range rows {
+		keyWidth = max(keyWidth, len(usageKey(r.Key)))
+	}
+	row := func(key, in, out, cw, cr, cost string) string {
+		return fmt.Sprintf("  %-*s  %8s  %8s  %9s  %9s  %9s", keyWidth, key, in, out, cw, cr, cost)
+	}
+	lines := []string{FooterHead.Render(row(title, "input", "output", "cache wr", "cache rd", "cost"))}
+	for _, r := range rows {
+		lines = append(lines, FooterMeta.Render(row(usageKey(r.Key),
+			formatTokenCount(int64(r.InputTokens)), formatTokenCount(int64(r.OutputTokens)),
+			formatTokenCount(int64(r.CacheWriteTokens)), formatTokenCount(int64(r.CacheReadTokens)),
+			fmt.Sprintf("$%.2f", r.CostUSD))))
+	}
+	return lines
+}
+
+func usageKey(key string) string {
+	if key == "" {
+		return "(none)"
+	}
+	return key
+}
+
+// inPlanMode reports whether the user turned plan mode on for the current
+// session.
+func (m Model) inPlanMode() bool {
+	return m.Session != nil && m.planSessionID == m.Session.ID
+}
+
+// handlePlanCommand toggles user-controlled plan mode. Usage:
+// /plan [on|off|approve]. "approve" turns plan mode off and tells the
+// agent to implement the plan it proposed.
+func (m Model) handlePlanCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("Plan mode requires a daemon connection and an active session."))
+	}
+	sub := ""
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	switch sub {
+	case "":
+		state := "off"
+		if m.inPlanMode() {
+			state = "on"
+		}
+		return m, PrintToScrollback(FooterMeta.Render("Plan mode is " + state + ". Usage: /plan on|off|approve"))
+	case "on", "off", "approve":
 	default:
-		return m, PrintToScrollback(m.renderError("Usage: /schedule [add|add-task|list|cancel]"))
+		return m, PrintToScrollback(m.renderError("Usage: /plan on|off|approve"))
+	}
+	if sub == "approve" && m.thinking {
+		return m, PrintToScrollback(m.renderError("Wait for the agent to finish before approving the plan."))
+	}
+
+	on, err := m.Daemon.SetPlanMode(m.Session.ID, sub == "on")
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Plan mode: " + err.Error()))
+	}
+	if on {
+		m.planSessionID = m.Session.ID
+		return m, PrintToScrollback(WelcomeStyle.Render("Plan mode on: write tools are disabled and the agent will propose a plan for review. Use /plan approve to let it proceed."))
+	}
+	m.planSessionID = ""
+	if sub == "off" {
+		return m, PrintToScrollback(WelcomeStyle.Render("Plan mode off: all tools are available again."))
+	}
+	notice := PrintToScrollback(WelcomeStyle.Render("Plan approved. Write tools are enabled again."))
+	next, cmd := m.submit("The plan is approved. Go ahead and implement it.")
+	return next, tea.Batch(notice, cmd)
+}
+
+// handleExportCommand writes the current session transcript to a file.
+// Usage: /export [md|json|claude|codex] [path]. With only a path, the
+// format is taken from its extension.
+func (m Model) handleExportCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("No active session to export."))
+	}
+	format, path := "md", ""
+	if len(args) > 0 {
+		if f, err := export.ParseTranscriptFormat(args[0]); err == nil {
+			format = f
+			args = args[1:]
+		} else if ext := strings.TrimPrefix(filepath.Ext(args[0]), "."); ext == "json" {
+			format = "json"
+		}
+	}
+	if len(args) > 1 {
+		return m, PrintToScrollback(m.renderError("Usage: /export [md|json|claude|codex] [path]"))
+	}
+	if len(args) == 1 {
+		path = args[0]
+	} else {
+		path = export.TranscriptFileName(*m.Session, format)
+	}
+
+	data, err := m.Daemon.ExportSession(m.Session.ID, format)
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Export failed: " + err.Error()))
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return m, PrintToScrollback(m.renderError("Writing export: " + err.Error()))
+	}
+	done := PrintToScrollback(WelcomeStyle.Render(fmt.Sprintf("Exported session to %s (%d bytes)", path, len(data))))
+	if !m.Prefs.StorageExports {
+		return m, done
+	}
+	return m, tea.Sequence(done, m.uploadExportCmd(path, data))
+}
+
+// uploadExportCmd copies an export to the storage sink (storage.exports)
+// in the background.
+func (m Model) uploadExportCmd(path string, data []byte) tea.Cmd {
+	return func() tea.Msg {
+		var line string
+		storage, err := sink.ExportSink(m.Prefs)
+		if err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+			defer cancel()
+			key := sink.ExportsDir + filepath.Base(path)
+			if err = sink.PutBytes(ctx, storage, key, data); err == nil {
+				line = WelcomeStyle.Render("Uploaded export to " + strings.TrimSuffix(storage.String(), "/") + "/" + key)
+			}
+		}
+		if err != nil {
+			line = m.renderError("Uploading export: " + err.Error())
+		}
+		return PrintToScrollback(line)()
+	}
+}
+
+// handleShareCommand prints a read-only live view link for the session.
+func (m Model) handleShareCommand() (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("Sharing requires a daemon connection and an active session."))
+	}
+	link, err := m.Daemon.ShareSession(m.Session.ID)
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Share failed: " + err.Error()))
+	}
+	lines := []string{FooterHead.Render("Live view (read-only)")}
+	if link.URL == "" {
+		lines = append(lines,
+			FooterMeta.Render("  "+link.Path),
+			FooterMeta.Render("  The daemon listens on a unix socket; serve it over TCP for browsers to reach this path."))
+	} else {
+		lines = append(lines, FooterMeta.Render("  "+link.URL))
+		if link.LocalOnly {
+			lines = append(lines, FooterMeta.Render("  Only reachable from this machine. Set daemon.bind_address to share it on your network."))
+		}
+	}
+	lines = append(lines, FooterMeta.Render("  Anyone with the link can watch this session. Revoke it with /unshare."))
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// handleUnshareCommand revokes the session's live view links.
+func (m Model) handleUnshareCommand() (tea.Model, tea.Cmd) {
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("Sharing requires a daemon connection and an active session."))
+	}
+	n, err := m.Daemon.UnshareSession(m.Session.ID)
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Unshare failed: " + err.Error()))
+	}
+	if n == 0 {
+		return m, PrintToScrollback(WelcomeStyle.Render("This session is not shared."))
+	}
+	return m, PrintToScrollback(WelcomeStyle.Render("Share link revoked; viewers were disconnected."))
+}
+
+func (m Model) handleEgressCommand() (tea.Model, tea.Cmd) {
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError("No daemon connection available."))
+	}
+	report, err := m.Daemon.GetEgressReport()
+	if err != nil {
+		return m, PrintToScrollback(m.renderError("Failed to get egress report: " + err.Error()))
+	}
+	if report.Mode == string(egress.ModeOff) {
+		return m, PrintToScrollback(WelcomeStyle.Render("Egress reporting is off. Enable it with /config set egress.mode log"))
+	}
+	if len(report.Hosts) == 0 {
+		return m, PrintToScrollback(WelcomeStyle.Render("No outbound hosts contacted yet (mode: " + report.Mode + ")."))
+	}
+	var lines []string
+	lines = append(lines, FooterHead.Render("Outbound hosts (mode: "+report.Mode+")"))
+	for _, h := range report.Hosts {
+		status := "allowed"
+		if !h.Allowed {
+			status = "unlisted"
+		}
+		if h.Blocked > 0 {
+			status = fmt.Sprintf("blocked x%d", h.Blocked)
+		}
+		line := fmt.Sprintf("  %-40s %6d  %-12s %s", h.Host, h.Requests, status, h.LastSeen.Local().Format("2006-01-02 15:04"))
+		lines = append(lines, FooterMeta.Render(line))
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// renderReply styles a shared gateway command reply for the scrollback.
+func (m Model) renderReply(r gateway.Reply) tea.Cmd {
+	if r.IsError {
+		return PrintToScrollback(m.renderError(r.Text()))
+	}
+	if r.Title == "" {
+		return PrintToScrollback(WelcomeStyle.Render(r.Text()))
+	}
+	lines := []string{FooterHead.Render(r.Title)}
+	for _, l := range r.Lines {
+		lines = append(lines, FooterMeta.Render(l))
+	}
+	return PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// runGatewayCommand runs a registry command such as /schedule, /drafts, or
+// a plugin prompt command, and submits its prompt if it returns one.
+func (m Model) runGatewayCommand(c gateway.Command, args []string) (tea.Model, tea.Cmd) {
+	env := gateway.Env{Now: time.Now()}
+	if m.Daemon != nil {
+		env.Backend = m.Daemon
+	}
+	r := c.Run(env, args)
+	if r.Submit != "" {
+		return m.submit(r.Submit)
 	}
+	return m, m.renderReply(r)
 }
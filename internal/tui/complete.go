@@ -2,34 +2,81 @@ package tui
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/gateway"
+	"github.com/batalabs/muxd/internal/notify"
 	"github.com/batalabs/muxd/internal/provider"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
-// SlashCommands lists the available slash commands.
+// SlashCommands lists the slash commands handled by the TUI itself. Shared
+// and plugin commands come from the gateway registry; see allSlashCommands.
 var SlashCommands = []string{
-	"/branch", "/clear", "/config", "/consult", "/continue", "/emoji", "/exit", "/help",
-	"/new", "/nodes", "/qr", "/quit", "/redo", "/refresh", "/remember", "/rename", "/resume", "/schedule", "/sessions", "/sh", "/tools", "/undo",
+	"/attach", "/branch", "/clear", "/config", "/consult", "/context", "/continue", "/detach", "/discord", "/egress", "/emoji", "/exit", "/export", "/feedback", "/fork", "/help",
+	"/history", "/jobs", "/mcp", "/nav", "/new", "/nodes", "/notify", "/ollama", "/plan", "/qr", "/quit", "/quote", "/redo", "/refresh", "/remember", "/rename", "/resume", "/sessions", "/set", "/sh", "/share", "/slack", "/stats", "/steer", "/style", "/tools", "/undo", "/unshare", "/usage", "/verify",
+}
+
+// allSlashCommands returns SlashCommands plus the registered gateway
+// commands, sorted.
+func allSlashCommands() []string {
+	all := append(append([]string(nil), SlashCommands...), gateway.Names()...)
+	slices.Sort(all)
+	return all
 }
 
 // ConfigSubcommands lists the available /config subcommands.
 var ConfigSubcommands = []string{"models", "reset", "set", "show", "theme", "tools"}
 var ToolSubcommands = []string{"list", "enable", "disable", "toggle", "profile"}
+var MCPSubcommands = []string{"add", "list", "logs", "remove", "restart"}
+var OllamaSubcommands = []string{"list", "pull", "rm"}
+var NotifySubcommands = []string{"test"}
 var ToolProfiles = []string{"safe", "coder", "research"}
-var ScheduleSubcommands = []string{"add", "add-task", "list", "cancel"}
-
-// ConfigKeys lists the available /config set keys.
-var ConfigKeys = []string{
-	"anthropic.api_key", "brave.api_key", "fireworks.api_key", "footer.cost", "footer.cwd",
-	"footer.keybindings", "footer.session", "footer.tokens", "google.api_key",
-	"grok.api_key", "mistral.api_key", "model", "ollama.url", "openai.api_key",
-	"scheduler.allowed_tools",
-	"textbelt.api_key",
-	"tools.disabled",
-	"zai.api_key",
+var StyleSubcommands = append(append([]string{}, config.StyleTones...), "default", "lang", "reset")
+
+// VerifySubcommands are the arguments accepted by /verify.
+var VerifySubcommands = []string{"on", "off"}
+
+// SetSubcommands are the parameters accepted by /set.
+var SetSubcommands = []string{"temp", "top_p", "seed", "max_tokens", "stop", "reset"}
+var FeedbackSubcommands = []string{"good", "bad", "clear"}
+var ExportFormats = []string{"json", "md"}
+var PlanSubcommands = []string{"approve", "off", "on"}
+
+// ConfigKeys lists the available /config set keys, sorted.
+var ConfigKeys = func() []string {
+	keys := config.ValidConfigKeys()
+	slices.Sort(keys)
+	return keys
+}()
+
+// ConfigValues lists the accepted values of enumerated /config set keys.
+var ConfigValues = func() map[string][]string {
+	values := make(map[string][]string)
+	for _, key := range config.ValidConfigKeys() {
+		if v := config.DescribeKey(key).Values; len(v) > 0 {
+			values[key] = v
+		}
+	}
+	return values
+}()
+
+// toolListConfigKeys are /config set keys whose value is a comma-separated
+// list of tool names.
+var toolListConfigKeys = []string{"scheduler.allowed_tools", "scheduler.draft_tools", "tools.disabled"}
+
+// CompletionSources supplies the candidates that depend on the running
+// session. The funcs are only called when the input needs them; nil funcs
+// contribute nothing.
+type CompletionSources struct {
+	ModelIDs   []string        // extra model IDs (e.g. from the API)
+	SessionIDs func() []string // session ID prefixes for /continue and /resume
+	JobIDs     func() []string // scheduled job IDs for /schedule cancel
+	Cwd        string          // base directory for @path completion
 }
 
 // ModelAliasNames returns the sorted list of model alias names.
@@ -46,20 +93,30 @@ func ModelAliasNames() []string {
 // input string. extraModelIDs are additional model identifiers (e.g. from the
 // API) to include when completing /model arguments.
 func ComputeCompletions(input string, extraModelIDs []string) []string {
+	return ComputeCompletionsFrom(input, CompletionSources{ModelIDs: extraModelIDs})
+}
+
+// ComputeCompletionsFrom is ComputeCompletions with session-dependent
+// sources: session IDs, scheduled job IDs, and file paths after "@".
+func ComputeCompletionsFrom(input string, src CompletionSources) []string {
+	if prefix, partial, ok := atPathToken(input); ok {
+		return completePaths(src.Cwd, prefix+"@", partial)
+	}
 	if !strings.HasPrefix(input, "/") {
 		return nil
 	}
+	extraModelIDs := src.ModelIDs
 
 	fields := strings.Fields(input)
 	if len(fields) == 0 {
-		return FilterByPrefix(SlashCommands, "/", "")
+		return FilterByPrefix(allSlashCommands(), "/", "")
 	}
 
 	cmd := strings.ToLower(fields[0])
 
 	// Still typing the command name (no space after it yet).
 	if len(fields) == 1 && !strings.HasSuffix(input, " ") {
-		return FilterByPrefix(SlashCommands, "", cmd)
+		return FilterByPrefix(allSlashCommands(), "", cmd)
 	}
 
 	// Command is complete -- dispatch on subcommand context.
@@ -100,11 +157,85 @@ func ComputeCompletions(input string, extraModelIDs []string) []string {
 				}
 				return FilterByPrefix(candidates, "/config set model ", partial)
 			}
+			if len(fields) == 3 && strings.HasSuffix(input, " ") || len(fields) == 4 && !strings.HasSuffix(input, " ") {
+				key := strings.ToLower(fields[2])
+				partial := ""
+				if len(fields) == 4 {
+					partial = fields[3]
+				}
+				if slices.Contains(toolListConfigKeys, key) {
+					// Complete the last entry of the comma-separated list.
+					done, last := "", partial
+					if i := strings.LastIndex(partial, ","); i >= 0 {
+						done, last = partial[:i+1], partial[i+1:]
+					}
+					return FilterByPrefix(tools.ToolNames(), "/config set "+key+" "+done, last)
+				}
+				return FilterByPrefix(ConfigValues[key], "/config set "+key+" ", partial)
+			}
 		}
 		return nil
 
 	case "/continue", "/resume":
-		// Could add session ID completion in the future.
+		if src.SessionIDs != nil && (len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " "))) {
+			partial := ""
+			if len(fields) == 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(src.SessionIDs(), cmd+" ", partial)
+		}
+		return nil
+	case "/branch":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) == 2 {
+				partial = fields[1]
+			}
+			return FilterByPrefix([]string{"--at"}, "/branch ", partial)
+		}
+		return nil
+	case "/mcp":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(MCPSubcommands, "/mcp ", partial)
+		}
+		return nil
+	case "/ollama":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(OllamaSubcommands, "/ollama ", partial)
+		}
+		return nil
+	case "/notify":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(NotifySubcommands, "/notify ", partial)
+		}
+		if strings.ToLower(fields[1]) == "test" && (len(fields) == 2 || (len(fields) == 3 && !strings.HasSuffix(input, " "))) {
+			partial := ""
+			if len(fields) == 3 {
+				partial = strings.ToLower(fields[2])
+			}
+			return FilterByPrefix(notify.SinkNames, "/notify test ", partial)
+		}
+		return nil
+	case "/slack", "/discord":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(AdapterSubcommands, fields[0]+" ", partial)
+		}
 		return nil
 	case "/tools":
 		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
@@ -137,16 +268,70 @@ func ComputeCompletions(input string, extraModelIDs []string) []string {
 			return FilterByPrefix(ToolProfiles, "/tools profile ", partial)
 		}
 		return nil
-	case "/schedule":
+	case "/style":
 		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
 			partial := ""
 			if len(fields) >= 2 {
 				partial = strings.ToLower(fields[1])
 			}
-			return FilterByPrefix(ScheduleSubcommands, "/schedule ", partial)
+			return FilterByPrefix(StyleSubcommands, "/style ", partial)
 		}
-		sub := strings.ToLower(fields[1])
-		if sub == "add" {
+		return nil
+	case "/verify":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(VerifySubcommands, "/verify ", partial)
+		}
+		return nil
+	case "/set":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(SetSubcommands, "/set ", partial)
+		}
+		return nil
+	case "/feedback":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(FeedbackSubcommands, "/feedback ", partial)
+		}
+		return nil
+	case "/plan":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(PlanSubcommands, "/plan ", partial)
+		}
+		return nil
+	case "/export":
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(ExportFormats, "/export ", partial)
+		}
+		return nil
+	case "/schedule":
+		if src.JobIDs != nil && len(fields) >= 2 && strings.ToLower(fields[1]) == "cancel" &&
+			(len(fields) == 2 && strings.HasSuffix(input, " ") || len(fields) == 3 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) == 3 {
+				partial = fields[2]
+			}
+			return FilterByPrefix(src.JobIDs(), "/schedule cancel ", partial)
+		}
+		if len(fields) >= 2 && (len(fields) > 2 || strings.HasSuffix(input, " ")) && strings.ToLower(fields[1]) == "add" {
 			if len(fields) <= 3 && (len(fields) != 3 || !strings.HasSuffix(input, " ")) {
 				partial := ""
 				if len(fields) >= 3 {
@@ -159,13 +344,80 @@ func ComputeCompletions(input string, extraModelIDs []string) []string {
 				}
 				return FilterByPrefix(candidates, "/schedule add ", partial)
 			}
+			return nil
 		}
-		return nil
 	}
 
+	// Registry commands complete their first argument from Subcommands.
+	if c, ok := gateway.Lookup(cmd); ok && len(c.Subcommands) > 0 {
+		if len(fields) == 1 || (len(fields) == 2 && !strings.HasSuffix(input, " ")) {
+			partial := ""
+			if len(fields) >= 2 {
+				partial = strings.ToLower(fields[1])
+			}
+			return FilterByPrefix(c.Subcommands, cmd+" ", partial)
+		}
+	}
 	return nil
 }
 
+// atPathToken reports whether the input ends in an "@path" mention being
+// typed, returning the text before the "@" and the partial path after it.
+func atPathToken(input string) (prefix, partial string, ok bool) {
+	if input == "" || strings.HasSuffix(input, " ") || strings.HasSuffix(input, "\n") {
+		return "", "", false
+	}
+	start := strings.LastIndexAny(input, " \n") + 1
+	if !strings.HasPrefix(input[start:], "@") {
+		return "", "", false
+	}
+	return input[:start], input[start+1:], true
+}
+
+// maxPathCompletions caps the @path candidates from one directory.
+const maxPathCompletions = 50
+
+// completePaths lists the entries of partial's directory (relative to cwd)
+// whose names start with its last element. Directories end in "/", and
+// dotfiles are only offered when the partial name starts with ".".
+func completePaths(cwd, prefix, partial string) []string {
+	dir, base := "", partial
+	if i := strings.LastIndex(partial, "/"); i >= 0 {
+		dir, base = partial[:i+1], partial[i+1:]
+	}
+	root := dir
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(cwd, dir)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if e.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	result := FilterByPrefix(names, prefix+dir, base)
+	if len(result) > maxPathCompletions {
+		result = result[:maxPathCompletions]
+	}
+	return result
+}
+
+// IsPathCompletion reports whether a selected completion is an @path
+// mention rather than a command.
+func IsPathCompletion(completion string) bool {
+	_, _, ok := atPathToken(completion)
+	return ok
+}
+
 // FilterByPrefix returns candidates that start with partial, each prefixed
 // with the given prefix string. If partial is empty, all candidates match.
 func FilterByPrefix(candidates []string, prefix, partial string) []string {
@@ -189,16 +441,32 @@ func CommandExpectsArgs(completion string) bool {
 	}
 	cmd := strings.ToLower(fields[0])
 	switch cmd {
-	case "/continue", "/resume", "/rename":
+	case "/attach", "/continue", "/resume", "/rename", "/steer":
 		return len(fields) == 1
 	case "/remember":
 		return len(fields) == 1
+	case "/style":
+		return len(fields) == 2 && strings.ToLower(fields[1]) == "lang"
+	case "/set":
+		return len(fields) == 2 && strings.ToLower(fields[1]) != "reset"
 	case "/schedule":
 		if len(fields) == 1 {
 			return true
 		}
 		sub := strings.ToLower(fields[1])
 		return (sub == "add" || sub == "add-task" || sub == "cancel") && len(fields) == 2
+	case "/drafts":
+		if len(fields) == 2 {
+			sub := strings.ToLower(fields[1])
+			return sub == "approve" || sub == "reject"
+		}
+		return false
+	case "/mcp":
+		return len(fields) == 2 && strings.ToLower(fields[1]) != "list"
+	case "/ollama":
+		return len(fields) == 2 && strings.ToLower(fields[1]) != "list"
+	case "/notify":
+		return len(fields) == 1
 	case "/tools":
 		if len(fields) == 1 {
 			return false
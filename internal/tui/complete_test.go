@@ -1,6 +1,8 @@
 package tui
 
 import (
+	"os"
+	"path/filepath"
 	"slices"
 	"testing"
 
@@ -17,7 +19,7 @@ func TestComputeCompletions(t *testing.T) {
 		{
 			name:  "bare slash shows all commands",
 			input: "/",
-			want:  SlashCommands,
+			want:  allSlashCommands(),
 		},
 		{
 			name:  "partial match filters commands",
@@ -58,7 +60,7 @@ func TestComputeCompletions(t *testing.T) {
 		{
 			name:  "config set partial key",
 			input: "/config set footer.c",
-			want:  []string{"/config set footer.cost", "/config set footer.cwd"},
+			want:  []string{"/config set footer.context", "/config set footer.cost", "/config set footer.cwd"},
 		},
 		{
 			name:  "schedule subcommands",
@@ -152,6 +154,60 @@ func TestComputeCompletions(t *testing.T) {
 	}
 }
 
+func TestComputeCompletionsFrom(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"internal/tui", "main.go", "Makefile", ".env"} {
+		path := filepath.Join(dir, p)
+		if filepath.Ext(p) == "" && p != "Makefile" {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := CompletionSources{
+		Cwd:        dir,
+		SessionIDs: func() []string { return []string{"abcd1234", "abff0000", "c0ffee00"} },
+		JobIDs:     func() []string { return []string{"job-1111", "job-2222"} },
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"continue lists sessions", "/continue ", []string{"/continue abcd1234", "/continue abff0000", "/continue c0ffee00"}},
+		{"resume filters session prefix", "/resume ab", []string{"/resume abcd1234", "/resume abff0000"}},
+		{"branch offers --at", "/branch ", []string{"/branch --at"}},
+		{"schedule cancel lists jobs", "/schedule cancel ", []string{"/schedule cancel job-1111", "/schedule cancel job-2222"}},
+		{"schedule cancel filters jobs", "/schedule cancel job-2", []string{"/schedule cancel job-2222"}},
+		{"config set enum values", "/config set tools.approval_mode w", []string{"/config set tools.approval_mode write"}},
+		{"config set bool values", "/config set footer.cost ", []string{"/config set footer.cost true", "/config set footer.cost false"}},
+		{"config set tool list completes last entry", "/config set tools.disabled bash,web_f", []string{"/config set tools.disabled bash,web_fetch"}},
+		{"config set free-form key has no values", "/config set ollama.url ", nil},
+		{"at path lists cwd without dotfiles", "look at @", []string{"look at @Makefile", "look at @internal/", "look at @main.go"}},
+		{"at path filters by prefix", "@ma", []string{"@Makefile", "@main.go"}},
+		{"at path descends into dirs", "/consult see @internal/t", []string{"/consult see @internal/tui/"}},
+		{"at path shows dotfiles on dot", "@.", []string{"@.env"}},
+		{"at path ended by space", "@main.go ", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ComputeCompletionsFrom(tt.input, src)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ComputeCompletionsFrom(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+
+	if got := ComputeCompletions("/continue ", nil); got != nil {
+		t.Errorf("without sources, /continue should not complete: %v", got)
+	}
+}
+
 func TestModelAliasNames(t *testing.T) {
 	names := ModelAliasNames()
 	if len(names) != len(provider.ModelAliases) {
@@ -237,6 +293,8 @@ func TestCommandExpectsArgs(t *testing.T) {
 		{"schedule expects args", "/schedule", true},
 		{"schedule add expects args", "/schedule add", true},
 		{"schedule list does not", "/schedule list", false},
+		{"drafts does not", "/drafts", false},
+		{"drafts approve expects args", "/drafts approve", true},
 		{"remember expects args", "/remember", true},
 		{"remember with key does not", "/remember auth JWT", false},
 	}
@@ -24,16 +24,63 @@ type ConfigPicker struct {
 
 	editKey string
 	editBuf string
+	err     string // shown inline until the next key press
+
+	// search filters keys across all groups while its filter is set.
+	search pickerList[configSearchEntry]
+}
+
+// configSearchEntry is a key in the flattened search list.
+type configSearchEntry struct {
+	group string
+	entry config.PrefEntry
 }
 
 func NewConfigPicker(prefs config.Preferences) *ConfigPicker {
-	return &ConfigPicker{
-		groups: prefs.Grouped(),
+	p := &ConfigPicker{
 		active: true,
 		mode:   configPickerGroups,
 	}
+	p.search = newPickerList("config", nil, func(e configSearchEntry) []string {
+		return []string{e.entry.Key, e.group}
+	}, func(e configSearchEntry) string { return e.entry.Key })
+	p.search.detail = func(e configSearchEntry) []string {
+		return append(configKeyDetail(e.entry), "group: "+e.group)
+	}
+	p.setGroups(prefs.Grouped())
+	return p
+}
+
+func (p *ConfigPicker) setGroups(groups []config.ConfigGroup) {
+	p.groups = groups
+	var entries []configSearchEntry
+	for _, g := range groups {
+		for _, e := range g.Entries {
+			entries = append(entries, configSearchEntry{group: g.Name, entry: e})
+		}
+	}
+	idx := p.search.selectedIdx
+	p.search.items = entries
+	p.search.applyFilter()
+	if idx < len(p.search.filtered) {
+		p.search.selectedIdx = idx
+	}
 }
 
+// Searching reports whether a search filter is active.
+func (p *ConfigPicker) Searching() bool {
+	return p.search.filter != ""
+}
+
+// AppendFilter adds a rune to the search filter.
+func (p *ConfigPicker) AppendFilter(r rune) { p.search.AppendFilter(r) }
+
+// BackspaceFilter removes the last rune from the search filter.
+func (p *ConfigPicker) BackspaceFilter() { p.search.BackspaceFilter() }
+
+// ClearFilter leaves search.
+func (p *ConfigPicker) ClearFilter() { p.search.SetFilter("") }
+
 func NewConfigPickerAtGroup(prefs config.Preferences, group string) *ConfigPicker {
 	p := NewConfigPicker(prefs)
 	p.FocusGroup(group)
@@ -44,7 +91,7 @@ func (p *ConfigPicker) IsActive() bool { return p != nil && p.active }
 func (p *ConfigPicker) Dismiss()       { p.active = false }
 
 func (p *ConfigPicker) Refresh(prefs config.Preferences) {
-	p.groups = prefs.Grouped()
+	p.setGroups(prefs.Grouped())
 	if p.groupIdx >= len(p.groups) {
 		p.groupIdx = max(0, len(p.groups)-1)
 	}
@@ -73,6 +120,13 @@ func (p *ConfigPicker) selectedGroup() *config.ConfigGroup {
 }
 
 func (p *ConfigPicker) selectedEntry() *config.PrefEntry {
+	if p.Searching() {
+		e, ok := p.search.current()
+		if !ok {
+			return nil
+		}
+		return &e.entry
+	}
 	g := p.selectedGroup()
 	if g == nil || len(g.Entries) == 0 || p.keyIdx < 0 || p.keyIdx >= len(g.Entries) {
 		return nil
@@ -81,6 +135,10 @@ func (p *ConfigPicker) selectedEntry() *config.PrefEntry {
 }
 
 func (p *ConfigPicker) MoveUp() {
+	if p.Searching() && p.mode != configPickerEdit {
+		p.search.MoveUp()
+		return
+	}
 	switch p.mode {
 	case configPickerGroups:
 		if p.groupIdx > 0 {
@@ -94,6 +152,10 @@ func (p *ConfigPicker) MoveUp() {
 }
 
 func (p *ConfigPicker) MoveDown() {
+	if p.Searching() && p.mode != configPickerEdit {
+		p.search.MoveDown()
+		return
+	}
 	switch p.mode {
 	case configPickerGroups:
 		if p.groupIdx < len(p.groups)-1 {
@@ -126,12 +188,29 @@ func (p *ConfigPicker) Back() {
 	}
 }
 
+// recordSearchPick ranks the highlighted search result higher next time.
+func (p *ConfigPicker) recordSearchPick() {
+	if e, ok := p.search.current(); ok && p.Searching() {
+		p.search.recordPick(e)
+	}
+}
+
 func (p *ConfigPicker) StartEdit(key, initial string) {
 	p.mode = configPickerEdit
 	p.editKey = key
 	p.editBuf = initial
 }
 
+// SetError shows msg inline; an empty msg clears it.
+func (p *ConfigPicker) SetError(msg string) { p.err = msg }
+
+// RetryEdit reopens the editor with the rejected value and the reason it
+// was rejected.
+func (p *ConfigPicker) RetryEdit(key, value string, err error) {
+	p.StartEdit(key, value)
+	p.err = err.Error()
+}
+
 func (p *ConfigPicker) AppendEdit(r rune) {
 	p.editBuf += string(r)
 }
@@ -157,14 +236,17 @@ func (p *ConfigPicker) CommitEdit() (key, value string, ok bool) {
 }
 
 func (p *ConfigPicker) View(width int) string {
-	_ = width // reserved for future layout use
 	var b strings.Builder
 	b.WriteString(FooterHead.Render("Config Picker"))
 	b.WriteString("\n")
 
+	if p.Searching() && p.mode != configPickerEdit {
+		return p.searchView(&b, width)
+	}
+
 	switch p.mode {
 	case configPickerGroups:
-		b.WriteString(FooterMeta.Render("  Enter=select group  Esc=close"))
+		b.WriteString(FooterMeta.Render("  Enter=select group  type to search  Esc=close"))
 		b.WriteString("\n\n")
 		for i, g := range p.groups {
 			line := fmt.Sprintf("  %s", g.Name)
@@ -199,13 +281,82 @@ func (p *ConfigPicker) View(width int) string {
 			}
 			b.WriteString("\n")
 		}
+		b.WriteString(p.errorView())
+		if e := p.selectedEntry(); e != nil {
+			b.WriteString(renderPreview(configKeyDetail(*e), width))
+		}
 	case configPickerEdit:
 		b.WriteString(FooterMeta.Render("  Edit " + p.editKey + "  Enter=save  Esc=cancel"))
-		b.WriteString("\n\n")
+		b.WriteString("\n")
+		doc := config.DescribeKey(p.editKey)
+		if doc.Description != "" {
+			b.WriteString(FooterMeta.Render("  " + doc.Description))
+			b.WriteString("\n")
+		}
+		if doc.Hint != "" {
+			b.WriteString(FooterMeta.Render("  accepts: " + doc.Hint))
+			b.WriteString("\n")
+		}
+		b.WriteString("\n")
 		b.WriteString(FooterMeta.Render("  Value: " + p.editBuf))
 		b.WriteString(CursorStyle.Render("█"))
 		b.WriteString("\n")
+		b.WriteString(p.errorView())
 	}
 
 	return b.String()
 }
+
+func (p *ConfigPicker) errorView() string {
+	if p.err == "" {
+		return ""
+	}
+	return ErrorLineStyle.Render("  "+p.err) + "\n"
+}
+
+// configKeyDetail describes a key: what it does, its current and default
+// values, and what it accepts.
+func configKeyDetail(e config.PrefEntry) []string {
+	doc := config.DescribeKey(e.Key)
+	var lines []string
+	if doc.Description != "" {
+		lines = append(lines, e.Key+": "+doc.Description)
+	}
+	lines = append(lines, "value: "+e.Value+"  default: "+config.AnnotateValue(config.DefaultValue(e.Key), ""))
+	if doc.Hint != "" {
+		lines = append(lines, "accepts: "+doc.Hint)
+	}
+	return lines
+}
+
+// searchView lists the keys matching the search filter across all groups.
+func (p *ConfigPicker) searchView(b *strings.Builder, width int) string {
+	b.WriteString(FooterMeta.Render("  Search: " + p.search.filter))
+	b.WriteString(CursorStyle.Render("█"))
+	b.WriteString("\n")
+	b.WriteString(FooterMeta.Render("  Enter=edit/toggle  Esc=clear search"))
+	b.WriteString("\n\n")
+	if len(p.search.filtered) == 0 {
+		b.WriteString(FooterMeta.Render("  No matching keys."))
+		b.WriteString("\n")
+		return b.String()
+	}
+	const maxVisible = 12
+	start, end := p.search.window(maxVisible)
+	for i := start; i < end; i++ {
+		e := p.search.filtered[i].entry
+		if i == p.search.selectedIdx {
+			b.WriteString(CompletionSelStyle.Render(fmt.Sprintf("> %-24s %s", e.Key, e.Value)))
+		} else {
+			b.WriteString(FooterMeta.Render(fmt.Sprintf("  %-24s %s", e.Key, e.Value)))
+		}
+		b.WriteString("\n")
+	}
+	if len(p.search.filtered) > maxVisible {
+		b.WriteString(FooterMeta.Render(fmt.Sprintf("  ... %d total", len(p.search.filtered))))
+		b.WriteString("\n")
+	}
+	b.WriteString(p.errorView())
+	b.WriteString(p.search.previewView(width))
+	return b.String()
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -319,3 +320,51 @@ func TestConfigPicker_View(t *testing.T) {
 		}
 	})
 }
+
+func TestConfigPicker_Search(t *testing.T) {
+	p := NewConfigPicker(testPrefs())
+	for _, r := range "ftok" {
+		p.AppendFilter(r)
+	}
+	if !p.Searching() {
+		t.Fatal("expected search to be active")
+	}
+	entry := p.selectedEntry()
+	if entry == nil || entry.Key != "footer.tokens" {
+		t.Fatalf("selectedEntry = %+v, want footer.tokens", entry)
+	}
+	if view := p.View(80); !strings.Contains(view, "Search: ftok") || !strings.Contains(view, "group: theme") {
+		t.Errorf("search view missing filter or preview:\n%s", view)
+	}
+
+	p.ClearFilter()
+	if p.Searching() || p.mode != configPickerGroups {
+		t.Error("clearing the filter should return to the group list")
+	}
+}
+
+func TestConfigPicker_KeyDetailAndInlineError(t *testing.T) {
+	p := NewConfigPicker(testPrefs())
+	p.FocusGroup("daemon")
+	for p.selectedEntry().Key != "daemon.port_range" {
+		p.MoveDown()
+	}
+	view := p.View(100)
+	for _, want := range []string{"ports the daemon may fall back to", "default: (not set)", "accepts: port or low-high"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("keys view missing %q:\n%s", want, view)
+		}
+	}
+
+	p.RetryEdit("daemon.port_range", "abc", fmt.Errorf("invalid port range"))
+	if p.mode != configPickerEdit || p.editBuf != "abc" {
+		t.Fatalf("RetryEdit should reopen the editor with the value, got mode %d buf %q", p.mode, p.editBuf)
+	}
+	if view := p.View(100); !strings.Contains(view, "invalid port range") || !strings.Contains(view, "accepts:") {
+		t.Errorf("edit view missing inline error or hint:\n%s", view)
+	}
+	p.SetError("")
+	if strings.Contains(p.View(100), "invalid port range") {
+		t.Error("SetError(\"\") should clear the error")
+	}
+}
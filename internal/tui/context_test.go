@@ -0,0 +1,42 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+func TestRenderContext(t *testing.T) {
+	sc := &daemon.SessionContext{
+		Provider:        "anthropic",
+		Model:           "claude-test",
+		System:          "You are muxd.",
+		SystemTokens:    1200,
+		Summary:         "[Conversation summary]\n\nSchema is done.",
+		Pinned:          []daemon.ContextPin{{Key: "db", Value: "postgres 16"}},
+		Tools:           []string{"bash", "file_read"},
+		ToolTokens:      3000,
+		EstimatedTokens: 4300,
+		LastInputTokens: 4100,
+		Messages: []daemon.ContextMessage{
+			{Role: "user", Content: "[Conversation summary]\n\nSchema is done.", Tokens: 10},
+			{Role: "assistant", Blocks: []domain.ContentBlock{{Type: "tool_use", ToolName: "bash"}}, Tokens: 20},
+			{Role: "user", Content: strings.Repeat("long ", 40), Tokens: 50},
+		},
+	}
+
+	out := renderContext(sc, "")
+	for _, want := range []string{"anthropic/claude-test", "last call used", "Tools (2)", "Messages (3)", "db: postgres 16", "Schema is done.", "[tool_use bash]", "…"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("overview missing %q:\n%s", want, out)
+		}
+	}
+	if out := renderContext(sc, "system"); !strings.Contains(out, "You are muxd.") {
+		t.Errorf("system view = %q", out)
+	}
+	if out := renderContext(sc, "tools"); !strings.Contains(out, "bash, file_read") {
+		t.Errorf("tools view = %q", out)
+	}
+}
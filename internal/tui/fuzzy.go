@@ -0,0 +1,111 @@
+package tui
+
+import (
+	"unicode"
+)
+
+// Fuzzy match scoring, loosely modeled on fzf's v1 algorithm: every pattern
+// rune must appear in order, and matches score higher when they are
+// consecutive or start a word.
+const (
+	fuzzyScoreMatch       = 16
+	fuzzyBonusBoundary    = 8
+	fuzzyBonusFirstChar   = 8 // extra when the first pattern rune starts a word
+	fuzzyBonusConsecutive = 6
+	fuzzyPenaltyGapStart  = 3
+	fuzzyPenaltyGapExtend = 1
+)
+
+// fuzzyScore reports whether pattern matches text as a case-insensitive
+// subsequence and, if so, how well. An empty pattern matches with score 0.
+func fuzzyScore(pattern, text string) (int, bool) {
+	pat := []rune(toLowerRunes(pattern))
+	if len(pat) == 0 {
+		return 0, true
+	}
+	orig := []rune(text)
+	txt := []rune(toLowerRunes(text))
+
+	// Forward pass: find where the first full match ends.
+	pi, end := 0, -1
+	for i, r := range txt {
+		if r == pat[pi] {
+			pi++
+			if pi == len(pat) {
+				end = i
+				break
+			}
+		}
+	}
+	if end < 0 {
+		return 0, false
+	}
+	// Backward pass: tighten the start of the match window.
+	pi, start := len(pat)-1, 0
+	for i := end; i >= 0; i-- {
+		if txt[i] == pat[pi] {
+			pi--
+			if pi < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	score, prev := 0, -1
+	pi = 0
+	for i := start; i <= end && pi < len(pat); i++ {
+		if txt[i] != pat[pi] {
+			continue
+		}
+		score += fuzzyScoreMatch
+		if isWordStart(orig, i) {
+			score += fuzzyBonusBoundary
+			if pi == 0 {
+				score += fuzzyBonusFirstChar
+			}
+		}
+		if prev >= 0 {
+			if i == prev+1 {
+				score += fuzzyBonusConsecutive
+			} else {
+				score -= fuzzyPenaltyGapStart + (i-prev-2)*fuzzyPenaltyGapExtend
+			}
+		}
+		prev = i
+		pi++
+	}
+	return score, true
+}
+
+// fuzzyBest returns the best score of pattern against any of fields.
+func fuzzyBest(pattern string, fields []string) (int, bool) {
+	best, matched := 0, false
+	for _, f := range fields {
+		if s, ok := fuzzyScore(pattern, f); ok && (!matched || s > best) {
+			best, matched = s, true
+		}
+	}
+	return best, matched
+}
+
+// isWordStart reports whether text[i] begins a word: the start of the text,
+// after a separator, or a lower-to-upper case change.
+func isWordStart(text []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, cur := text[i-1], text[i]
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
+
+func toLowerRunes(s string) string {
+	rs := []rune(s)
+	for i, r := range rs {
+		rs[i] = unicode.ToLower(r)
+	}
+	return string(rs)
+}
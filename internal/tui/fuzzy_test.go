@@ -0,0 +1,64 @@
+package tui
+
+import "testing"
+
+func TestFuzzyScore(t *testing.T) {
+	t.Run("matches subsequences case-insensitively", func(t *testing.T) {
+		for _, tc := range []struct{ pattern, text string }{
+			{"", "anything"},
+			{"fl", "file_read"},
+			{"FR", "file_read"},
+			{"lgn", "Fix login bug"},
+		} {
+			if _, ok := fuzzyScore(tc.pattern, tc.text); !ok {
+				t.Errorf("fuzzyScore(%q, %q) should match", tc.pattern, tc.text)
+			}
+		}
+	})
+
+	t.Run("rejects out-of-order runes", func(t *testing.T) {
+		for _, tc := range []struct{ pattern, text string }{
+			{"rf", "file_read"},
+			{"zz", "bash"},
+			{"bashx", "bash"},
+		} {
+			if _, ok := fuzzyScore(tc.pattern, tc.text); ok {
+				t.Errorf("fuzzyScore(%q, %q) should not match", tc.pattern, tc.text)
+			}
+		}
+	})
+
+	better := func(pattern, a, b string) {
+		t.Helper()
+		sa, _ := fuzzyScore(pattern, a)
+		sb, _ := fuzzyScore(pattern, b)
+		if sa <= sb {
+			t.Errorf("%q: %q scored %d, want more than %q (%d)", pattern, a, sa, b, sb)
+		}
+	}
+
+	t.Run("ranks consecutive over scattered", func(t *testing.T) {
+		better("read", "file_read", "rename_and_delete")
+	})
+	t.Run("ranks word starts over mid-word", func(t *testing.T) {
+		better("fr", "file_read", "buffer")
+		better("wf", "web_fetch", "wolf")
+		better("gh", "gitHub", "aghast")
+	})
+	t.Run("ranks tight windows over loose ones", func(t *testing.T) {
+		better("log", "login", "l_o_g")
+	})
+}
+
+func TestFuzzyBest(t *testing.T) {
+	score, ok := fuzzyBest("bug", []string{"Fix login", "bugfix"})
+	if !ok {
+		t.Fatal("expected a match in the second field")
+	}
+	if want, _ := fuzzyScore("bug", "bugfix"); score != want {
+		t.Errorf("score = %d, want the best field's score %d", score, want)
+	}
+	if _, ok := fuzzyBest("zzz", []string{"a", "b"}); ok {
+		t.Error("expected no match")
+	}
+}
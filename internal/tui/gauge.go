@@ -0,0 +1,85 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// ---------------------------------------------------------------------------
+// Context gauge
+// ---------------------------------------------------------------------------
+
+// contextGaugeWidth is the number of cells in the context usage bar.
+const contextGaugeWidth = 20
+
+// contextWarnShare is the share of the compaction point past which the
+// gauge turns amber.
+const contextWarnShare = 0.8
+
+var (
+	gaugeWarnStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("222"))
+	gaugeFullStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("203"))
+)
+
+// contextUsage is the size of the last prompt sent to the model, as the
+// daemon reported it with stream_done.
+type contextUsage struct {
+	tokens    int
+	window    int // 0 when the model's window is unknown
+	compactAt int // 0 when the daemon did not say
+}
+
+// renderContextGauge renders the footer line showing how full the context
+// window is and how close the history is to being summarized, or "" before
+// the first model call of the session.
+func renderContextGauge(u contextUsage, indent string) string {
+	if u.tokens <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(indent + "context ")
+	if u.window > 0 {
+		b.WriteString(contextBar(u) + " ")
+		b.WriteString(fmt.Sprintf("%s / %s (%d%%)",
+			formatTokenCount(int64(u.tokens)), formatTokenCount(int64(u.window)), u.tokens*100/u.window))
+	} else {
+		b.WriteString(formatTokenCount(int64(u.tokens)))
+	}
+	style := FooterTokens
+	switch {
+	case u.compactAt <= 0:
+	case u.tokens > u.compactAt:
+		b.WriteString(" \u00b7 summarizes before the next reply")
+		style = gaugeFullStyle
+	default:
+		b.WriteString(" \u00b7 summarizes at " + formatTokenCount(int64(u.compactAt)))
+		if float64(u.tokens) >= float64(u.compactAt)*contextWarnShare {
+			style = gaugeWarnStyle
+		}
+	}
+	return style.Render(b.String())
+}
+
+// contextBar draws the share of the window in use, with a tick where
+// compaction starts.
+func contextBar(u contextUsage) string {
+	filled := min(contextGaugeWidth, (u.tokens*contextGaugeWidth+u.window-1)/u.window)
+	tick := -1
+	if u.compactAt > 0 && u.compactAt < u.window {
+		tick = u.compactAt * contextGaugeWidth / u.window
+	}
+	var b strings.Builder
+	for i := range contextGaugeWidth {
+		switch {
+		case i < filled:
+			b.WriteString("█")
+		case i == tick:
+			b.WriteString("│")
+		default:
+			b.WriteString("░")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,51 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderContextGauge(t *testing.T) {
+	tests := []struct {
+		name string
+		u    contextUsage
+		want []string
+	}{
+		{"before first call", contextUsage{}, nil},
+		{"known window", contextUsage{tokens: 50_000, window: 200_000, compactAt: 90_000}, []string{"50.0k / 200.0k (25%)", "summarizes at 90.0k"}},
+		{"past compaction point", contextUsage{tokens: 95_000, window: 200_000, compactAt: 90_000}, []string{"(47%)", "summarizes before the next reply"}},
+		{"unknown window", contextUsage{tokens: 1200, compactAt: 90_000}, []string{"context 1.2k", "summarizes at 90.0k"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := renderContextGauge(tt.u, "")
+			if tt.want == nil {
+				if got != "" {
+					t.Errorf("expected no gauge, got %q", got)
+				}
+				return
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("gauge %q missing %q", got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestContextBar(t *testing.T) {
+	bar := contextBar(contextUsage{tokens: 50_000, window: 200_000, compactAt: 100_000})
+	if n := len([]rune(bar)); n != contextGaugeWidth {
+		t.Fatalf("bar is %d cells, want %d", n, contextGaugeWidth)
+	}
+	if got := strings.Count(bar, "█"); got != 5 {
+		t.Errorf("filled cells = %d, want 5", got)
+	}
+	if got := []rune(bar)[10]; got != '│' {
+		t.Errorf("expected compaction tick at cell 10, got %q", got)
+	}
+	if got := strings.Count(contextBar(contextUsage{tokens: 300_000, window: 200_000}), "█"); got != contextGaugeWidth {
+		t.Errorf("overfull bar filled %d cells, want %d", got, contextGaugeWidth)
+	}
+}
@@ -5,23 +5,26 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/batalabs/muxd/internal/agent"
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/daemon"
 	"github.com/batalabs/muxd/internal/diff"
 	"github.com/batalabs/muxd/internal/domain"
 	"github.com/batalabs/muxd/internal/hub"
 	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/store"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
 func (m Model) handleHistoryLoaded() (tea.Model, tea.Cmd) {
-	msgs, _ := m.Store.GetMessages(m.Session.ID)
+	msgs, _ := m.sessionMessages()
 	m.messages = msgs
 	m.titled = len(msgs) > 0
 	// Populate input history from past user messages (skip tool result messages).
@@ -40,23 +43,91 @@ func (m Model) handleAskUser(msg AskUserMsg) (tea.Model, tea.Cmd) {
 	m.thinking = false
 	m.toolStatus = ""
 	prompt := AsstIconStyle.Render("? ") + msg.Prompt
+	if len(msg.Options) > 0 {
+		prompt += "\n" + FooterMeta.Render("  Options: "+strings.Join(msg.Options, " · "))
+	}
 	m.appendRuntimeLog("ask_user: " + summarizeForLog(msg.Prompt))
 	return m, PrintToScrollback(prompt)
 }
 
+func (m Model) handleApprovalRequired(msg ApprovalRequiredMsg) (tea.Model, tea.Cmd) {
+	m.pendingApprovalID = msg.ApprovalID
+	m.pendingApprovalTool = msg.ToolName
+	m.thinking = false
+	m.toolStatus = ""
+	prompt := AsstIconStyle.Render("? ") + "Allow " + describeApproval(msg.ToolName, msg.Input) + "?\n" +
+		FooterMeta.Render("  [y] yes  [n] no  [a] always allow "+msg.ToolName+" in this session")
+	m.appendRuntimeLog("approval_required: " + msg.ToolName)
+	return m, PrintToScrollback(prompt)
+}
+
+// handleApprovalKey answers a pending approval: y, n, or a. Esc denies the
+// call; Ctrl+C cancels the agent loop.
+func (m Model) handleApprovalKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	tool := m.pendingApprovalTool
+	var decision, note string
+	switch {
+	case msg.Type == tea.KeyCtrlC:
+		m.pendingApprovalID = ""
+		m.pendingApprovalTool = ""
+		if m.Daemon != nil && m.Session != nil {
+			go func() { _ = m.Daemon.Cancel(m.Session.ID) }()
+		}
+		return m, PrintToScrollback(WelcomeStyle.Render("Agent loop canceled."))
+	case msg.Type == tea.KeyEsc:
+		decision, note = "deny", "Denied "+tool+"."
+	case msg.Type == tea.KeyRunes && len(msg.Runes) == 1:
+		switch msg.Runes[0] {
+		case 'y', 'Y':
+			decision, note = "allow", "Allowed "+tool+"."
+		case 'n', 'N':
+			decision, note = "deny", "Denied "+tool+"."
+		case 'a', 'A':
+			decision, note = "always", "Allowed "+tool+" for the rest of this session."
+		}
+	}
+	if decision == "" {
+		return m, nil
+	}
+
+	approvalID := m.pendingApprovalID
+	m.pendingApprovalID = ""
+	m.pendingApprovalTool = ""
+	m.thinking = true
+	m.toolStatus = "Thinking..."
+	m.appendRuntimeLog("approval: " + tool + " " + decision)
+	sessionID := ""
+	if m.Session != nil {
+		sessionID = m.Session.ID
+	}
+	return m, tea.Batch(
+		PrintToScrollback(FooterMeta.Render("  "+note)),
+		SendApprovalCmd(m.Daemon, sessionID, approvalID, decision),
+	)
+}
+
 func (m Model) handleToolStatus(msg ToolStatusMsg) (tea.Model, tea.Cmd) {
 	m.turnToolCount++
-	m.turnCurrentTool = describeToolStart(msg.Name, msg.Input)
+	m.turnRunning = append(slices.Clip(m.turnRunning), runningTool{id: msg.ID, name: msg.Name, desc: describeToolStart(msg.Name, msg.Input)})
+	m.turnCurrentTool = runningToolsLabel(m.turnRunning)
 	m.toolStatus = "Running " + msg.Name + "..."
 	m.appendRuntimeLog("tool_start: " + msg.Name)
 	return m, nil
 }
 
 func (m Model) handleToolResult(msg ToolResultMsg) (tea.Model, tea.Cmd) {
-	m.toolStatus = fmt.Sprintf("Finished %s, waiting for model...", msg.Name)
-	m.turnCurrentTool = ""
+	m.turnRunning = removeRunningTool(m.turnRunning, msg.ID, msg.Name)
+	m.turnCurrentTool = runningToolsLabel(m.turnRunning)
+	if len(m.turnRunning) > 0 {
+		m.toolStatus = "Running " + m.turnRunning[len(m.turnRunning)-1].name + "..."
+	} else {
+		m.toolStatus = fmt.Sprintf("Finished %s, waiting for model...", msg.Name)
+	}
 	// Set human-readable last action for status display.
 	m.turnLastAction = describeToolAction(msg.Name, msg.Result)
+	if msg.Name == "spawn_agent" {
+		m.subAgents = nil
+	}
 	// Track file changes for status display.
 	if msg.Name == "file_edit" || msg.Name == "file_write" {
 		if m.turnFilesChanged == nil {
@@ -83,34 +154,147 @@ func (m Model) handleToolResult(msg ToolResultMsg) (tea.Model, tea.Cmd) {
 	return m, PrintToScrollback(resultFormatted)
 }
 
+// runningTool is a tool call that has started but not finished. Parallel
+// calls finish in any order, so they are matched by tool_use_id.
+type runningTool struct {
+	id, name, desc string
+}
+
+// removeRunningTool drops the call with id, or the oldest call named name
+// when the daemon did not send an id.
+func removeRunningTool(running []runningTool, id, name string) []runningTool {
+	for i, r := range running {
+		if id != "" && r.id == id || id == "" && r.name == name {
+			return slices.Delete(slices.Clone(running), i, i+1)
+		}
+	}
+	return running
+}
+
+// runningToolsLabel describes the newest running call and how many others
+// are still going.
+func runningToolsLabel(running []runningTool) string {
+	switch len(running) {
+	case 0:
+		return ""
+	case 1:
+		return running[0].desc
+	default:
+		return fmt.Sprintf("%s (+%d more)", running[len(running)-1].desc, len(running)-1)
+	}
+}
+
 func (m Model) handleTurnDone(msg TurnDoneMsg) (tea.Model, tea.Cmd) {
 	m.thinking = false
 	m.toolStatus = ""
 	m.turnToolCount = 0
 	m.turnFilesChanged = nil
 	m.turnCurrentTool = ""
+	m.turnRunning = nil
 	m.streaming = false
 	// streamBuf is already flushed to viewLines by handleStreamDone
 	m.streamBuf = ""
 	m.streamFlushedLen = 0
 	m.appendRuntimeLog("turn_done: " + msg.StopReason)
-	return m, nil
+	reply := m.turnReply
+	m.turnReply = ""
+	if msg.StopReason == agent.StopReasonCancelled {
+		m.stopping = false
+		return m, PrintToScrollback(WelcomeStyle.Render("Turn stopped; the work so far was kept." + m.returnQueued()))
+	}
+	cited := m.citedSourcesCmd(reply)
+	m, next := m.sendQueued()
+	return m, tea.Sequence(cited, next)
+}
+
+func (m Model) handleTurnReattached(msg TurnReattachedMsg) (tea.Model, tea.Cmd) {
+	if m.Session == nil || msg.SessionID != m.Session.ID {
+		return m, nil
+	}
+	m.thinking = true
+	m.streaming = false
+	m.streamBuf = ""
+	m.streamFlushedLen = 0
+	m.turnToolCount = 0
+	m.turnFilesChanged = nil
+	m.turnStartTime = time.Now()
+	m.turnCurrentTool = ""
+	m.turnRunning = nil
+	m.turnReply = ""
+	m.appendRuntimeLog("reattach: " + msg.SessionID)
+	return m, tea.Batch(
+		PrintToScrollback(WelcomeStyle.Render("Reattached to a turn running in the background.")),
+		m.spinner.Tick,
+	)
+}
+
+func (m Model) handleVerified(msg VerifiedMsg) (tea.Model, tea.Cmd) {
+	v := msg.Info
+	m.verifyInputTokens += v.InputTokens
+	m.verifyOutputTokens += v.OutputTokens
+	model := v.Model
+	if model == "" {
+		model = m.modelLabel
+	}
+	m.appendRuntimeLog(fmt.Sprintf("verified: model=%s ok=%t in=%d out=%d", model, v.OK, v.InputTokens, v.OutputTokens))
+	usage := fmt.Sprintf("%d in / %d out", v.InputTokens, v.OutputTokens)
+	switch {
+	case v.Error != "":
+		return m, PrintToScrollback(m.renderError("Verification failed (" + model + "): " + v.Error))
+	case v.OK:
+		return m, PrintToScrollback(FooterMeta.Render(fmt.Sprintf("  ✓ Verified by %s · %s", model, usage)))
+	}
+	lines := []string{BulletStyle.Render(fmt.Sprintf("  Verification by %s found issues (%s):", model, usage))}
+	for _, line := range strings.Split(v.Critique, "\n") {
+		lines = append(lines, "    "+line)
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+func (m Model) handleDiagram(msg DiagramMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != "" {
+		m.appendRuntimeLog("diagram: " + msg.Err)
+		return m, PrintToScrollback(m.renderError(fmt.Sprintf("Could not render %s diagram: %s", msg.Kind, msg.Err)))
+	}
+	m.appendRuntimeLog("diagram: " + msg.Path)
+	line := WelcomeStyle.Render(fmt.Sprintf("  %s diagram: ", msg.Kind)) + LinkURLStyle.Render(msg.Path)
+	return m, PrintToScrollback(line)
 }
 
 func (m Model) handleUndoDone(msg UndoDoneMsg) (tea.Model, tea.Cmd) {
 	if msg.Err != nil {
 		return m, PrintToScrollback(m.renderError("Undo failed: " + msg.Err.Error()))
 	}
-	text := fmt.Sprintf("Undid agent turn %d.", msg.RestoredTurn)
-	return m, PrintToScrollback(WelcomeStyle.Render(text))
+	return m, PrintToScrollback(WelcomeStyle.Render("Undid " + checkpointLabel(msg.Checkpoint) + "."))
+}
+
+func (m Model) handleUndoPreview(msg UndoPreviewMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError("Undo preview failed: " + msg.Err.Error()))
+	}
+	if msg.Diff == "" {
+		return m, PrintToScrollback(WelcomeStyle.Render("Undo would revert " + checkpointLabel(msg.Checkpoint) + ", but the working tree already matches the checkpoint."))
+	}
+	header := WelcomeStyle.Render("/undo would revert " + checkpointLabel(msg.Checkpoint) + ":")
+	return m, PrintToScrollback(header + "\n" + RenderDiff(msg.Diff, max(20, m.width-2)))
 }
 
 func (m Model) handleRedoDone(msg RedoDoneMsg) (tea.Model, tea.Cmd) {
 	if msg.Err != nil {
 		return m, PrintToScrollback(m.renderError("Redo failed: " + msg.Err.Error()))
 	}
-	text := fmt.Sprintf("Redid agent turn %d.", msg.RestoredTurn)
-	return m, PrintToScrollback(WelcomeStyle.Render(text))
+	return m, PrintToScrollback(WelcomeStyle.Render("Redid " + checkpointLabel(msg.Checkpoint) + "."))
+}
+
+// checkpointLabel names what an undo or redo reversed.
+func checkpointLabel(cp *store.Checkpoint) string {
+	if cp.Label != "" {
+		return fmt.Sprintf("the changes since checkpoint %q", cp.Label)
+	}
+	if cp.Turn == 0 {
+		return "the changes since the checkpoint"
+	}
+	return fmt.Sprintf("agent step %d", cp.Turn)
 }
 
 // handleShellResult processes the result of a shell command.
@@ -123,13 +307,21 @@ func (m Model) handlePaste(msg PasteMsg) (tea.Model, tea.Cmd) {
 	if msg.Err != nil || m.thinking {
 		return m, nil
 	}
-	pasted := strings.ReplaceAll(msg.Text, "\r\n", "\n")
-	pasted = strings.ReplaceAll(pasted, "\r", "\n")
-	pasted = strings.TrimRight(pasted, "\n")
-	if pasted != "" {
-		m.insertInputAtCursor(pasted)
+	if msg.ImagePath != "" {
+		// Quoted so the path survives spaces; submit attaches it as an image
+		// block like any other image path in the message.
+		ref := `"` + msg.ImagePath + `"`
+		if m.inputCursor > 0 && !strings.HasSuffix(string([]rune(m.input)[:m.inputCursor]), " ") {
+			ref = " " + ref
+		}
+		m.insertInputAtCursor(ref + " ")
 		m.resetHistory()
+		return m, PrintToScrollback(FooterMeta.Render("Pasted image from clipboard; it is attached when you send the message."))
+	}
+	if paths := droppedPaths(msg.Text); paths != nil {
+		return m.attach(paths)
 	}
+	m.insertPaste(msg.Text)
 	return m, nil
 }
 
@@ -137,6 +329,8 @@ func (m Model) handleClipboardWrite(msg ClipboardWriteMsg) (tea.Model, tea.Cmd)
 	var sysText string
 	if msg.Err != nil {
 		sysText = "Copy failed: " + msg.Err.Error()
+	} else if msg.OK && msg.Backend == config.ClipboardOSC52 {
+		sysText = "Sent to the terminal clipboard (OSC 52)."
 	} else if msg.OK {
 		sysText = "Copied to clipboard."
 	}
@@ -157,6 +351,7 @@ func (m Model) handleBranchDone(msg BranchDoneMsg) (tea.Model, tea.Cmd) {
 	m.inputTokens = msg.Session.InputTokens
 	m.outputTokens = msg.Session.OutputTokens
 	m.lastInputTokens = 0
+	m.ctxUsage = contextUsage{}
 	m.lastOutputTokens = 0
 	m.cacheCreationInputTokens = 0
 	m.cacheReadInputTokens = 0
@@ -166,8 +361,6 @@ func (m Model) handleBranchDone(msg BranchDoneMsg) (tea.Model, tea.Cmd) {
 	m.history = nil
 	m.historyIdx = -1
 	m.historyDraft = ""
-	m.checkpoints = nil
-	m.redoStack = nil
 	m.resuming = true
 	return m, tea.Batch(
 		PrintToScrollback(WelcomeStyle.Render(fmt.Sprintf("Branched to new session %s", msg.Session.ID[:8]))),
@@ -175,6 +368,273 @@ func (m Model) handleBranchDone(msg BranchDoneMsg) (tea.Model, tea.Cmd) {
 	)
 }
 
+// branchAt branches the session, keeping messages up to atSequence (all of
+// them when 0).
+func (m Model) branchAt(atSequence int) tea.Cmd {
+	sessionID := m.Session.ID
+	daemon := m.Daemon
+	store := m.Store
+	return func() tea.Msg {
+		if daemon != nil {
+			sess, err := daemon.BranchSession(sessionID, atSequence)
+			return BranchDoneMsg{Session: sess, Err: err}
+		}
+		if store != nil {
+			sess, err := store.BranchSession(sessionID, atSequence)
+			return BranchDoneMsg{Session: sess, Err: err}
+		}
+		return BranchDoneMsg{Err: fmt.Errorf("no store available")}
+	}
+}
+
+// openMessagePicker loads the session's transcript and opens the picker
+// for choosing where to fork.
+func (m Model) openMessagePicker() tea.Cmd {
+	sessionID := m.Session.ID
+	daemon := m.Daemon
+	store := m.Store
+	return func() tea.Msg {
+		if daemon != nil {
+			msgs, err := daemon.GetMessages(sessionID)
+			return MessagePickerMsg{Messages: msgs, Err: err}
+		}
+		if store != nil {
+			msgs, err := store.GetMessages(sessionID)
+			return MessagePickerMsg{Messages: msgs, Err: err}
+		}
+		return MessagePickerMsg{Err: fmt.Errorf("no store available")}
+	}
+}
+
+// handleMessagePickerKey intercepts all keys when the fork picker is active.
+func (m Model) handleMessagePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEscape, tea.KeyCtrlC:
+		m.messagePicker.Dismiss()
+		return m, nil
+
+	case tea.KeyEnter:
+		seq := m.messagePicker.Selected()
+		if seq == 0 {
+			return m, nil
+		}
+		m.messagePicker.Dismiss()
+		return m, m.branchAt(seq)
+
+	case tea.KeyUp:
+		m.messagePicker.MoveUp()
+		return m, nil
+
+	case tea.KeyDown:
+		m.messagePicker.MoveDown()
+		return m, nil
+
+	case tea.KeyBackspace, tea.KeyDelete:
+		m.messagePicker.BackspaceFilter()
+		return m, nil
+
+	default:
+		if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
+			for _, r := range msg.Runes {
+				m.messagePicker.AppendFilter(r)
+			}
+		}
+		return m, nil
+	}
+}
+
+// openJobPicker loads the daemon's jobs for the /jobs picker.
+func (m Model) openJobPicker() tea.Cmd {
+	d := m.Daemon
+	return func() tea.Msg {
+		if d == nil {
+			return JobsMsg{Err: fmt.Errorf("jobs need a daemon connection")}
+		}
+		jobs, err := d.ListJobs(100)
+		return JobsMsg{Jobs: jobs, Err: err}
+	}
+}
+
+// jobCmd queues, fetches, or cancels a job on the daemon. arg is the prompt
+// for "run" and the job ID otherwise.
+func (m Model) jobCmd(action, arg string) tea.Cmd {
+	d := m.Daemon
+	return func() tea.Msg {
+		if d == nil {
+			return JobMsg{Action: action, Err: fmt.Errorf("jobs need a daemon connection")}
+		}
+		switch action {
+		case "run":
+			job, err := d.CreateJob(arg, nil)
+			if err != nil {
+				return JobMsg{Action: action, Err: err}
+			}
+			return JobMsg{Action: action, Job: *job}
+		case "cancel":
+			status, err := d.CancelJob(arg)
+			return JobMsg{Action: action, Job: store.Job{ID: arg}, Status: status, Err: err}
+		default:
+			job, err := d.GetJob(arg)
+			if err != nil {
+				return JobMsg{Action: action, Err: err}
+			}
+			return JobMsg{Action: action, Job: *job}
+		}
+	}
+}
+
+// handleJobPickerKey intercepts all keys when the job picker is active.
+func (m Model) handleJobPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyEscape, tea.KeyCtrlC:
+		m.jobPicker.Dismiss()
+		return m, nil
+	case tea.KeyEnter:
+		job, ok := m.jobPicker.Selected()
+		if !ok {
+			return m, nil
+		}
+		m.jobPicker.Dismiss()
+		return m, m.jobCmd("show", job.ID)
+	case tea.KeyCtrlX:
+		job, ok := m.jobPicker.Selected()
+		if !ok {
+			return m, nil
+		}
+		return m, m.jobCmd("cancel", job.ID)
+	case tea.KeyUp:
+		m.jobPicker.MoveUp()
+	case tea.KeyDown:
+		m.jobPicker.MoveDown()
+	case tea.KeyBackspace, tea.KeyDelete:
+		m.jobPicker.BackspaceFilter()
+	case tea.KeyRunes:
+		for _, r := range msg.Runes {
+			m.jobPicker.AppendFilter(r)
+		}
+	}
+	return m, nil
+}
+
+func (m Model) handleJob(msg JobMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError("Job: " + msg.Err.Error()))
+	}
+	switch msg.Action {
+	case "run":
+		return m, PrintToScrollback(WelcomeStyle.Render(fmt.Sprintf("Queued job %s. /jobs shows its progress.", msg.Job.ID[:8])))
+	case "cancel":
+		if m.jobPicker.IsActive() {
+			m.jobPicker.SetStatus(msg.Job.ID, msg.Status)
+			return m, nil
+		}
+		return m, PrintToScrollback(WelcomeStyle.Render(fmt.Sprintf("Job %s %s.", msg.Job.ID[:8], msg.Status)))
+	}
+	return m, PrintToScrollback(formatJob(msg.Job))
+}
+
+// openMessageNav loads the transcript and its annotations and opens the
+// message navigator, with the cursor on the message at selectSeq (the
+// newest when 0).
+func (m Model) openMessageNav(selectSeq int) tea.Cmd {
+	sessionID := m.Session.ID
+	daemon := m.Daemon
+	store := m.Store
+	return func() tea.Msg {
+		var msgs []domain.TranscriptMessage
+		var notes map[int]string
+		var err error
+		switch {
+		case daemon != nil:
+			if msgs, err = daemon.GetMessages(sessionID); err == nil {
+				notes, err = daemon.GetAnnotations(sessionID)
+			}
+		case store != nil:
+			if msgs, err = store.GetMessages(sessionID); err == nil {
+				notes, err = store.MessageAnnotations(sessionID)
+			}
+		default:
+			err = fmt.Errorf("no store available")
+		}
+		return MessageNavMsg{Messages: msgs, Notes: notes, Select: selectSeq, Err: err}
+	}
+}
+
+// editMessage annotates or deletes a stored message.
+func (m Model) editMessage(req navRequest) tea.Cmd {
+	sessionID := m.Session.ID
+	daemon := m.Daemon
+	store := m.Store
+	return func() tea.Msg {
+		var err error
+		switch {
+		case daemon != nil && req.Action == "annotate":
+			err = daemon.AnnotateMessage(sessionID, req.Sequence, req.Note)
+		case daemon != nil:
+			err = daemon.DeleteMessage(sessionID, req.Sequence)
+		case store != nil && req.Action == "annotate":
+			err = store.AnnotateMessage(sessionID, req.Sequence, req.Note)
+		case store != nil:
+			err = store.DeleteMessage(sessionID, req.Sequence)
+		default:
+			err = fmt.Errorf("no store available")
+		}
+		return MessageEditedMsg{Action: req.Action, Sequence: req.Sequence, Note: req.Note, Err: err}
+	}
+}
+
+// handleMessageNavKey intercepts all keys when the message navigator is
+// active and runs the action a key completes.
+func (m Model) handleMessageNavKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	req, ok := m.messageNav.HandleKey(msg)
+	if !ok {
+		return m, nil
+	}
+	switch req.Action {
+	case "copy":
+		return m, WriteClipboardCmd(m.Prefs.Clipboard, req.Text)
+	case "quote":
+		m.messageNav.Dismiss()
+		m.setInput(m.input + quoteText(req.Text))
+		return m, nil
+	case "fork":
+		if m.thinking {
+			return m, PrintToScrollback(m.renderError("Cannot branch while agent is running."))
+		}
+		m.messageNav.Dismiss()
+		return m, m.branchAt(req.Sequence)
+	case "delete":
+		if m.thinking {
+			return m, PrintToScrollback(m.renderError("Cannot delete messages while the agent is working."))
+		}
+		return m, m.editMessage(req)
+	default:
+		return m, m.editMessage(req)
+	}
+}
+
+// handleMessageEdited reports an annotation or deletion and refreshes the
+// navigator.
+func (m Model) handleMessageEdited(msg MessageEditedMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError(fmt.Sprintf("Failed to %s message: %v", msg.Action, msg.Err)))
+	}
+	if msg.Action == "annotate" {
+		if m.messageNav != nil {
+			m.messageNav.SetNote(msg.Sequence, msg.Note)
+		}
+		return m, nil
+	}
+	if msgs, err := m.sessionMessages(); err == nil {
+		m.messages = msgs
+	}
+	notice := PrintToScrollback(FooterMeta.Render(fmt.Sprintf("Deleted message #%d. The model will no longer see it.", msg.Sequence)))
+	if !m.messageNav.IsActive() {
+		return m, notice
+	}
+	return m, tea.Batch(notice, m.openMessageNav(msg.Sequence))
+}
+
 // handleNodePickerKey intercepts all keys when the node picker is active.
 func (m Model) handleNodePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.Type {
@@ -191,6 +651,7 @@ func (m Model) handleNodePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if node == nil {
 			return m, nil
 		}
+		m.nodePicker.recordPick(node)
 		m.nodePicker.Dismiss()
 
 		// Set DaemonClient baseURL to proxy through hub
@@ -237,14 +698,27 @@ func (m Model) handleNodePickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
-// openNodePicker fetches nodes from the hub and opens the picker.
+// openNodePicker fetches nodes from the hub, or from the fallback hub when
+// it does not answer, and opens the picker.
 func (m Model) openNodePicker() tea.Cmd {
-	baseURL := m.hubBaseURL
+	hubs := []string{m.hubBaseURL}
+	if m.hubFallbackURL != "" {
+		fallback := m.hubFallbackURL
+		if !strings.Contains(fallback, "://") {
+			fallback = "http://" + fallback
+		}
+		hubs = append(hubs, fallback)
+	}
 	token := m.hubToken
 	return func() tea.Msg {
-		hc := hub.NewHubClient(baseURL, token)
-		nodes, err := hc.ListNodes()
-		return NodePickerMsg{Nodes: nodes, Err: err}
+		var err error
+		for _, u := range hubs {
+			var nodes []*hub.Node
+			if nodes, err = hub.NewHubClient(u, token).ListNodes(); err == nil {
+				return NodePickerMsg{Nodes: nodes}
+			}
+		}
+		return NodePickerMsg{Err: err}
 	}
 }
 
@@ -280,6 +754,7 @@ func (m Model) handlePickerBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 		sess := *sel
+		m.picker.recordPick(sess)
 		m.picker.Dismiss()
 		m.picker = nil
 		// Switch to selected session
@@ -288,6 +763,7 @@ func (m Model) handlePickerBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.inputTokens = sess.InputTokens
 		m.outputTokens = sess.OutputTokens
 		m.lastInputTokens = 0
+		m.ctxUsage = contextUsage{}
 		m.lastOutputTokens = 0
 		m.cacheCreationInputTokens = 0
 		m.cacheReadInputTokens = 0
@@ -298,7 +774,7 @@ func (m Model) handlePickerBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.historyIdx = -1
 		m.historyDraft = ""
 		m.resuming = true
-		return m, m.loadSessionHistory()
+		return m, m.resumeSession()
 
 	case tea.KeyUp:
 		m.picker.MoveUp()
@@ -352,14 +828,14 @@ func (m Model) handlePickerRename(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case tea.KeyEnter:
 		id, newTitle := m.picker.CommitRename()
 		if id != "" && newTitle != "" {
+			var err error
 			if m.Daemon != nil {
-				if err := m.Daemon.RenameSession(id, newTitle); err != nil {
-					fmt.Fprintf(os.Stderr, "tui: rename via daemon: %v\n", err)
-				}
+				err = m.Daemon.RenameSession(id, newTitle)
 			} else if m.Store != nil {
-				if err := m.Store.UpdateSessionTitle(id, newTitle); err != nil {
-					fmt.Fprintf(os.Stderr, "tui: update session title: %v\n", err)
-				}
+				err = m.Store.UpdateSessionTitle(id, newTitle)
+			}
+			if err != nil {
+				return m, PrintToScrollback(m.renderError("Rename failed: " + err.Error()))
 			}
 		}
 		return m, nil
@@ -385,23 +861,21 @@ func (m Model) handlePickerDelete(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if len(msg.Runes) == 1 {
 			switch msg.Runes[0] {
 			case 'y', 'Y':
+				var ids []string
 				if m.picker.SelectedCount() > 0 {
-					ids := m.picker.RemoveSelectedMulti()
-					if m.Store != nil {
-						for _, id := range ids {
-							if err := m.Store.DeleteSession(id); err != nil {
-								fmt.Fprintf(os.Stderr, "tui: delete session %s: %v\n", id[:8], err)
-							}
-						}
-					}
-				} else {
-					id := m.picker.RemoveSelected()
-					if id != "" && m.Store != nil {
-						if err := m.Store.DeleteSession(id); err != nil {
-							fmt.Fprintf(os.Stderr, "tui: delete session: %v\n", err)
-						}
+					ids = m.picker.RemoveSelectedMulti()
+				} else if id := m.picker.RemoveSelected(); id != "" {
+					ids = []string{id}
+				}
+				var failed []string
+				for _, id := range ids {
+					if err := m.deleteSession(id); err != nil {
+						failed = append(failed, id[:8]+": "+err.Error())
 					}
 				}
+				if len(failed) > 0 {
+					return m, PrintToScrollback(m.renderError("Delete failed: " + strings.Join(failed, "; ")))
+				}
 				return m, nil
 			case 'n', 'N':
 				m.picker.CancelMode()
@@ -468,12 +942,10 @@ func (m Model) handleToolPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				} else if mapsEqualBool(cur, tools.ToolProfileDisabledSet("coder")) {
 					next = "research"
 				}
-				allNames := tools.ToolNames()
-				if len(m.mcpToolNames) > 0 {
-					allNames = append(allNames, m.mcpToolNames...)
-					sort.Strings(allNames)
+				m.toolPicker = NewToolPicker(m.toolNames(), tools.ToolProfileDisabledSet(next))
+				if len(m.daemonTools) > 0 {
+					m.toolPicker.SetToolInfo(m.daemonTools)
 				}
-				m.toolPicker = NewToolPicker(allNames, tools.ToolProfileDisabledSet(next))
 				return m, PrintToScrollback(WelcomeStyle.Render("Staged tools profile: " + next + " (press 'a' to apply)"))
 			}
 		}
@@ -493,6 +965,18 @@ func (m Model) handleToolPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
+// deleteSession removes a session from the daemon when connected, so
+// its agent is shut down too, otherwise from the local store.
+func (m Model) deleteSession(id string) error {
+	if m.Daemon != nil {
+		return m.Daemon.DeleteSession(id)
+	}
+	if m.Store != nil {
+		return m.Store.DeleteSession(id)
+	}
+	return nil
+}
+
 func (m Model) handleEmojiPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.Type {
 	case tea.KeyEsc, tea.KeyCtrlC:
@@ -511,7 +995,9 @@ func (m Model) handleEmojiPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.Prefs.FooterEmoji = emoji
 		m.emojiPicker.Dismiss()
 		m.emojiPicker = nil
-		_ = config.SavePreferences(m.Prefs)
+		if err := m.saveConfig("footer.emoji", emoji); err != nil {
+			return m, PrintToScrollback(m.renderError("Config save failed: " + err.Error()))
+		}
 		if name == "none" {
 			return m, PrintToScrollback(WelcomeStyle.Render("Footer emoji removed."))
 		}
@@ -522,8 +1008,13 @@ func (m Model) handleEmojiPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) handleConfigPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.configPicker.SetError("")
 	switch msg.Type {
 	case tea.KeyEsc, tea.KeyCtrlC:
+		if m.configPicker.Searching() && m.configPicker.mode != configPickerEdit {
+			m.configPicker.ClearFilter()
+			return m, nil
+		}
 		if m.configPicker.mode == configPickerGroups {
 			m.configPicker.Dismiss()
 			m.configPicker = nil
@@ -538,7 +1029,11 @@ func (m Model) handleConfigPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.configPicker.MoveDown()
 		return m, nil
 	case tea.KeyEnter:
-		switch m.configPicker.mode {
+		mode := m.configPicker.mode
+		if m.configPicker.Searching() && mode != configPickerEdit {
+			mode = configPickerKeys
+		}
+		switch mode {
 		case configPickerGroups:
 			m.configPicker.EnterGroup()
 			return m, nil
@@ -547,6 +1042,7 @@ func (m Model) handleConfigPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			if entry == nil {
 				return m, nil
 			}
+			m.configPicker.recordSearchPick()
 			key := entry.Key
 			if key == "footer.emoji" {
 				m.configPicker.Dismiss()
@@ -561,9 +1057,10 @@ func (m Model) handleConfigPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 					next = "false"
 				}
 				if err := m.Prefs.Set(key, next); err != nil {
-					return m, PrintToScrollback(m.renderError("Config update failed: " + err.Error()))
+					m.configPicker.SetError(err.Error())
+					return m, nil
 				}
-				if err := config.SavePreferences(m.Prefs); err != nil {
+				if err := m.saveConfig(key, next); err != nil {
 					return m, PrintToScrollback(m.renderError("Config save failed: " + err.Error()))
 				}
 				m.applyConfigSetting(key, next)
@@ -578,17 +1075,19 @@ func (m Model) handleConfigPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				return m, nil
 			}
 			if err := m.validateConfigInput(key, val); err != nil {
-				return m, PrintToScrollback(m.renderError("Invalid value: " + err.Error()))
+				m.configPicker.RetryEdit(key, val, err)
+				return m, nil
 			}
 			if err := m.Prefs.Set(key, val); err != nil {
-				return m, PrintToScrollback(m.renderError("Config update failed: " + err.Error()))
+				m.configPicker.RetryEdit(key, val, err)
+				return m, nil
 			}
-			if err := config.SavePreferences(m.Prefs); err != nil {
+			if err := m.saveConfig(key, val); err != nil {
 				return m, PrintToScrollback(m.renderError("Config save failed: " + err.Error()))
 			}
 			m.applyConfigSetting(key, val)
 			m.configPicker.Refresh(m.Prefs)
-			return m, nil
+			return m, m.ollamaModelCheck(key)
 		}
 	case tea.KeySpace:
 		if m.configPicker.mode == configPickerEdit {
@@ -598,12 +1097,18 @@ func (m Model) handleConfigPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case tea.KeyBackspace, tea.KeyDelete:
 		if m.configPicker.mode == configPickerEdit {
 			m.configPicker.BackspaceEdit()
+		} else {
+			m.configPicker.BackspaceFilter()
 		}
 		return m, nil
 	default:
-		if m.configPicker.mode == configPickerEdit && msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
+		if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
 			for _, r := range msg.Runes {
-				m.configPicker.AppendEdit(r)
+				if m.configPicker.mode == configPickerEdit {
+					m.configPicker.AppendEdit(r)
+				} else {
+					m.configPicker.AppendFilter(r)
+				}
 			}
 		}
 		return m, nil
@@ -611,9 +1116,61 @@ func (m Model) handleConfigPickerKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// fetchTools loads the daemon's tool list. With open set, the tool picker
+// opens once it arrives.
+func fetchTools(d *daemon.DaemonClient, open bool) tea.Cmd {
+	return func() tea.Msg {
+		list, err := d.GetTools()
+		return ToolsMsg{Tools: list, Err: err, Open: open}
+	}
+}
+
+// toolNames lists the tools the picker offers: the daemon's when known,
+// otherwise the built-in tools.
+func (m Model) toolNames() []string {
+	if len(m.daemonTools) == 0 {
+		return tools.ToolNames()
+	}
+	names := make([]string, len(m.daemonTools))
+	for i, ti := range m.daemonTools {
+		names[i] = ti.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// openToolPicker opens the tool picker. Enabled state comes from the
+// daemon's tool list when there is one, otherwise from local preferences.
+func (m *Model) openToolPicker() tea.Cmd {
+	disabled := m.Prefs.DisabledToolsSet()
+	if len(m.daemonTools) > 0 {
+		disabled = map[string]bool{}
+		for _, ti := range m.daemonTools {
+			if !ti.Enabled {
+				disabled[ti.Name] = true
+			}
+		}
+	}
+	m.toolPicker = NewToolPicker(m.toolNames(), disabled)
+	if len(m.daemonTools) > 0 {
+		m.toolPicker.SetToolInfo(m.daemonTools)
+	}
+	if tools.ComplianceMode() {
+		return PrintToScrollback(FooterMeta.Render("Compliance mode: messaging tools (" + strings.Join(tools.MessagingToolNames, ", ") + ") are disabled."))
+	}
+	return nil
+}
+
 func (m *Model) applyDisabledToolsSetting(disabled map[string]bool) {
 	disabledCSV := disabledToolsCSV(disabled)
 	m.Prefs.ToolsDisabled = disabledCSV
+	if len(m.daemonTools) > 0 {
+		updated := slices.Clone(m.daemonTools)
+		for i := range updated {
+			updated[i].Enabled = !disabled[updated[i].Name]
+		}
+		m.daemonTools = updated
+	}
 	if m.Daemon != nil {
 		if _, err := m.Daemon.SetConfig("tools.disabled", disabledCSV); err != nil {
 			fmt.Fprintf(os.Stderr, "tui: set disabled tools config: %v\n", err)
@@ -651,15 +1208,26 @@ func disabledToolsCSV(disabled map[string]bool) string {
 	return strings.Join(out, ",")
 }
 
+// saveConfig persists a preference already set on m.Prefs. With a daemon
+// its preferences are the source of truth, so the value is written (and
+// applied) there; otherwise the local config file is saved.
+func (m *Model) saveConfig(key, value string) error {
+	if m.Daemon != nil {
+		_, err := m.Daemon.SetConfig(key, value)
+		return err
+	}
+	return config.SavePreferences(m.Prefs)
+}
+
+// applyConfigSetting propagates a saved preference to this TUI's runtime
+// state.
 func (m *Model) applyConfigSetting(key, value string) {
-	// API key changed -update daemon and local state
+	// API key changed -update local state
 	if strings.HasSuffix(key, ".api_key") {
-		if m.Daemon != nil {
-			if _, err := m.Daemon.SetConfig(key, value); err != nil {
-				fmt.Fprintf(os.Stderr, "tui: set config %s: %v\n", key, err)
-			}
-		}
 		provName := strings.TrimSuffix(key, ".api_key")
+		if name, _, ok := config.ParseCustomProviderKey(key); ok {
+			provName = name
+		}
 		if resolved, rerr := config.LoadProviderAPIKey(m.Prefs, provName); rerr == nil {
 			m.APIKey = resolved
 		}
@@ -684,8 +1252,10 @@ func (m *Model) applyConfigSetting(key, value string) {
 		m.modelLabel = name
 		// Keep prefs.Provider in sync so restarts resolve correctly.
 		m.Prefs.Provider = newProvName
-		if err := config.SavePreferences(m.Prefs); err != nil {
-			fmt.Fprintf(os.Stderr, "tui: save prefs: %v\n", err)
+		if m.Daemon == nil {
+			if err := config.SavePreferences(m.Prefs); err != nil {
+				fmt.Fprintf(os.Stderr, "tui: save prefs: %v\n", err)
+			}
 		}
 		if m.Daemon != nil && m.Session != nil {
 			if err := m.Daemon.SetModel(m.Session.ID, name, newID); err != nil {
@@ -695,35 +1265,18 @@ func (m *Model) applyConfigSetting(key, value string) {
 	}
 	if key == "ollama.url" {
 		provider.SetOllamaBaseURL(value)
-		if m.Daemon != nil {
-			if _, err := m.Daemon.SetConfig(key, value); err != nil {
-				fmt.Fprintf(os.Stderr, "tui: set config %s: %v\n", key, err)
-			}
-		}
 	}
 	if key == "zai.coding_plan" {
 		b, _ := config.ParseBoolish(value)
 		provider.SetZAICodingPlan(b)
-		if m.Daemon != nil {
-			if _, err := m.Daemon.SetConfig(key, value); err != nil {
-				fmt.Fprintf(os.Stderr, "tui: set config %s: %v\n", key, err)
-			}
-		}
-	}
-	if strings.HasPrefix(key, "x.") && m.Daemon != nil {
-		if _, err := m.Daemon.SetConfig(key, value); err != nil {
-			fmt.Fprintf(os.Stderr, "tui: set config %s: %v\n", key, err)
-		}
 	}
-	if key == "scheduler.allowed_tools" && m.Daemon != nil {
-		if _, err := m.Daemon.SetConfig(key, value); err != nil {
-			fmt.Fprintf(os.Stderr, "tui: set config %s: %v\n", key, err)
-		}
+	if strings.HasPrefix(key, "azure.") {
+		provider.SetAzureEndpoint(m.Prefs.AzureResource())
+		provider.SetAzureADCredentials(m.Prefs.AzureAD())
 	}
-	if key == "tools.disabled" && m.Daemon != nil {
-		if _, err := m.Daemon.SetConfig(key, value); err != nil {
-			fmt.Fprintf(os.Stderr, "tui: set config %s: %v\n", key, err)
-		}
+	if name, _, ok := config.ParseCustomProviderKey(key); ok {
+		c := m.Prefs.CustomProviders[name]
+		provider.SetCustomProvider(name, c.BaseURL, c.ModelList())
 	}
 }
 
@@ -766,27 +1319,24 @@ func (m Model) validateConfigInput(key, value string) error {
 }
 
 func isBoolConfigKey(key string) bool {
-	switch key {
-	case "footer.tokens", "footer.cost", "footer.cwd", "footer.session", "footer.keybindings":
-		return true
-	default:
-		return false
-	}
+	return config.DescribeKey(key).Type == config.KeyTypeBool
 }
 
 func (m Model) configEditInitialValue(key string) string {
-	switch key {
-	case "anthropic.api_key", "zai.api_key", "grok.api_key", "mistral.api_key", "openai.api_key", "google.api_key", "brave.api_key", "fireworks.api_key", "textbelt.api_key":
+	// Secrets are shown masked, so editing starts from scratch.
+	if config.DescribeKey(key).Type == config.KeyTypeSecret {
 		return ""
-	default:
-		return m.Prefs.Get(key)
 	}
+	return m.Prefs.Get(key)
 }
 
 func (m Model) handleQRCommand(args []string) (tea.Model, tea.Cmd) {
 	if m.Daemon == nil {
 		return m, PrintToScrollback(m.renderError("No daemon connection available."))
 	}
+	if m.Daemon.SocketPath() != "" {
+		return m, PrintToScrollback(m.renderError("QR codes are unavailable while the daemon listens on a unix socket."))
+	}
 
 	// /qr new -regenerate token before showing QR code
 	if len(args) > 0 && args[0] == "new" {
@@ -845,11 +1395,11 @@ func (m Model) handleQRCommand(args []string) (tea.Model, tea.Cmd) {
 			bindAddr = "localhost"
 		}
 		ips := daemon.GetLocalIPs()
-		if len(ips) > 0 && (bindAddr == "0.0.0.0" || bindAddr == "") {
+		if len(ips) > 0 && daemon.IsWildcardAddr(bindAddr) {
 			lines = append(lines, "")
 			lines = append(lines, FooterMeta.Render("Local IPs: "+strings.Join(ips, ", ")))
 		}
-		lines = append(lines, FooterMeta.Render(fmt.Sprintf("Server: %s:%d", bindAddr, lf.Port)))
+		lines = append(lines, FooterMeta.Render("Server: "+daemon.HostPort(bindAddr, lf.Port)))
 
 		// Show token for manual entry
 		lines = append(lines, "")
@@ -860,17 +1410,7 @@ func (m Model) handleQRCommand(args []string) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) refreshCurrentSession() (tea.Model, tea.Cmd) {
-	var (
-		msgs []domain.TranscriptMessage
-		err  error
-	)
-	if m.Daemon != nil {
-		msgs, err = m.Daemon.GetMessages(m.Session.ID)
-	} else if m.Store != nil {
-		msgs, err = m.Store.GetMessages(m.Session.ID)
-	} else {
-		return m, PrintToScrollback(m.renderError("No session store available."))
-	}
+	msgs, err := m.sessionMessages()
 	if err != nil {
 		return m, PrintToScrollback(m.renderError("Refresh failed: " + err.Error()))
 	}
@@ -0,0 +1,159 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/batalabs/muxd/internal/store"
+)
+
+// JobPicker lists the daemon's headless jobs with their state, for /jobs.
+type JobPicker struct {
+	pickerList[store.Job]
+	active bool
+}
+
+// NewJobPicker creates a picker over jobs, newest first as the daemon
+// returns them.
+func NewJobPicker(jobs []store.Job) *JobPicker {
+	p := &JobPicker{
+		pickerList: newPickerList("job", jobs, func(j store.Job) []string { return []string{j.Prompt, j.ID, j.Status} }, nil),
+		active:     true,
+	}
+	p.detail = jobDetail
+	return p
+}
+
+// jobDetail is the preview pane for a job.
+func jobDetail(j store.Job) []string {
+	lines := []string{fmt.Sprintf("%s  %s  queued %s", j.ID, j.Status, TimeAgo(j.CreatedAt))}
+	if took := jobDuration(j); took != "" {
+		lines[0] += "  took " + took
+	}
+	if len(j.Tools) > 0 {
+		lines = append(lines, "tools: "+strings.Join(j.Tools, ", "))
+	}
+	if j.Error != "" {
+		lines = append(lines, "error: "+j.Error)
+	}
+	prompt := strings.Split(strings.TrimSpace(j.Prompt), "\n")
+	if len(prompt) > 4 {
+		prompt = append(prompt[:4], "…")
+	}
+	return append(lines, prompt...)
+}
+
+// jobDuration is how long a started job ran, or has been running.
+func jobDuration(j store.Job) string {
+	if j.StartedAt == nil {
+		return ""
+	}
+	end := time.Now()
+	if j.FinishedAt != nil {
+		end = *j.FinishedAt
+	}
+	return end.Sub(*j.StartedAt).Round(time.Second).String()
+}
+
+// IsActive reports whether the picker is currently shown.
+func (p *JobPicker) IsActive() bool {
+	return p != nil && p.active
+}
+
+// Dismiss closes the picker.
+func (p *JobPicker) Dismiss() {
+	p.active = false
+}
+
+// Selected returns the highlighted job.
+func (p *JobPicker) Selected() (store.Job, bool) {
+	return p.current()
+}
+
+// SetStatus updates a job's state after it was cancelled from the picker.
+func (p *JobPicker) SetStatus(id, status string) {
+	for _, list := range [][]store.Job{p.items, p.filtered} {
+		for i := range list {
+			if list[i].ID == id {
+				list[i].Status = status
+			}
+		}
+	}
+}
+
+// View renders the picker as a string.
+func (p *JobPicker) View(width int) string {
+	var b strings.Builder
+
+	b.WriteString(FooterHead.Render("Jobs"))
+	b.WriteString("\n")
+	b.WriteString(FooterMeta.Render("  Filter: " + p.filter))
+	b.WriteString(CursorStyle.Render("█"))
+	b.WriteString("\n\n")
+
+	if len(p.filtered) == 0 {
+		b.WriteString(FooterMeta.Render("  No matching jobs."))
+		b.WriteString("\n")
+	} else {
+		const maxVisible = 10
+		start, end := p.window(maxVisible)
+		previewWidth := max(width-34, 20)
+		for i := start; i < end; i++ {
+			j := p.filtered[i]
+			indicator := "  "
+			if i == p.selectedIdx {
+				indicator = "> "
+			}
+			line := fmt.Sprintf("%s%-8s  %-9s  %-8s  %s", indicator, j.ID[:8], j.Status, TimeAgo(j.CreatedAt), contextPreview(j.Prompt, previewWidth))
+			if i == p.selectedIdx {
+				b.WriteString(CompletionSelStyle.Render(line))
+			} else {
+				b.WriteString(FooterMeta.Render(line))
+			}
+			b.WriteString("\n")
+		}
+		if len(p.filtered) > maxVisible {
+			b.WriteString(FooterMeta.Render(fmt.Sprintf("  ... %d total", len(p.filtered))))
+			b.WriteString("\n")
+		}
+		b.WriteString(p.previewView(width))
+	}
+
+	b.WriteString("\n")
+	b.WriteString(FooterMeta.Render("  Enter=show log and result  Ctrl+X=cancel job  Esc=close"))
+	b.WriteString("\n")
+	return b.String()
+}
+
+// formatJob renders a job with its log and result for the scrollback.
+func formatJob(j store.Job) string {
+	var b strings.Builder
+	b.WriteString(WelcomeStyle.Render(fmt.Sprintf("Job %s  %s", j.ID[:8], j.Status)))
+	meta := []string{"prompt: " + strings.TrimSpace(j.Prompt)}
+	if j.SessionID != "" {
+		meta = append(meta, "session: "+j.SessionID)
+	}
+	if took := jobDuration(j); took != "" {
+		meta = append(meta, "took: "+took)
+	}
+	if j.Error != "" {
+		meta = append(meta, "error: "+j.Error)
+	}
+	for _, line := range meta {
+		b.WriteString("\n" + FooterMeta.Render("  "+line))
+	}
+	if log := strings.TrimRight(j.Log, "\n"); log != "" {
+		b.WriteString("\n" + FooterHead.Render("  log"))
+		for _, line := range strings.Split(log, "\n") {
+			b.WriteString("\n" + FooterMeta.Render("    "+line))
+		}
+	}
+	if result := strings.TrimSpace(j.Result); result != "" {
+		b.WriteString("\n" + FooterHead.Render("  result"))
+		for _, line := range strings.Split(result, "\n") {
+			b.WriteString("\n    " + line)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+package tui
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func testJobs() []store.Job {
+	started := time.Now().Add(-time.Minute)
+	finished := started.Add(30 * time.Second)
+	return []store.Job{
+		{ID: "job-running-1", Prompt: "refactor the parser", Status: store.JobRunning, CreatedAt: started, StartedAt: &started},
+		{ID: "job-failed-22", Prompt: "update deps", Status: store.JobFailed, Error: "rate limited", CreatedAt: started, StartedAt: &started, FinishedAt: &finished},
+	}
+}
+
+func TestJobPicker(t *testing.T) {
+	p := NewJobPicker(testJobs())
+	view := p.View(100)
+	for _, want := range []string{"job-runn", "running", "refactor the parser", "update deps", "Ctrl+X=cancel job"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+
+	p.AppendFilter('d')
+	p.AppendFilter('e')
+	p.AppendFilter('p')
+	j, ok := p.Selected()
+	if !ok || j.ID != "job-failed-22" {
+		t.Fatalf("filtered selection = %+v, %v", j, ok)
+	}
+	if !strings.Contains(p.View(100), "error: rate limited") {
+		t.Error("detail pane should show the job error")
+	}
+
+	p.SetStatus("job-failed-22", store.JobCancelled)
+	if j, _ := p.Selected(); j.Status != store.JobCancelled {
+		t.Errorf("status after SetStatus = %s", j.Status)
+	}
+	p.BackspaceFilter()
+	p.BackspaceFilter()
+	p.BackspaceFilter()
+	if p.items[1].Status != store.JobCancelled {
+		t.Errorf("SetStatus did not update the unfiltered list")
+	}
+}
+
+func TestFormatJob(t *testing.T) {
+	j := testJobs()[1]
+	j.SessionID = "sess-1"
+	j.Log = "tool bash started\ntool bash done\n"
+	j.Result = "all updated"
+	out := formatJob(j)
+	for _, want := range []string{"Job job-fail", "failed", "session: sess-1", "took: 30s", "error: rate limited", "tool bash done", "all updated"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("formatJob missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestJobsCommand(t *testing.T) {
+	var queued string
+	cancelled := false
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Prompt string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		queued = req.Prompt
+		_ = json.NewEncoder(w).Encode(store.Job{ID: "job-new-123", Prompt: req.Prompt, Status: store.JobQueued})
+	})
+	mux.HandleFunc("GET /api/jobs", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(testJobs())
+	})
+	mux.HandleFunc("POST /api/jobs/{id}/cancel", func(w http.ResponseWriter, r *http.Request) {
+		cancelled = r.PathValue("id") == "job-running-1"
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "cancelling"})
+	})
+	m := Model{Daemon: fakeDaemon(t, mux)}
+
+	if _, cmd := m.handleSlashCommand("/jobs run"); cmd == nil || queued != "" {
+		t.Error("/jobs run without a prompt should print usage")
+	}
+	_, cmd := m.handleSlashCommand("/jobs run  write the changelog")
+	if msg := cmd().(JobMsg); msg.Err != nil || msg.Job.ID != "job-new-123" {
+		t.Errorf("run = %+v", msg)
+	}
+	if queued != "write the changelog" {
+		t.Errorf("queued prompt = %q", queued)
+	}
+
+	_, cmd = m.handleSlashCommand("/jobs")
+	updated, _ := m.Update(cmd())
+	m = updated.(Model)
+	if !m.jobPicker.IsActive() {
+		t.Fatal("/jobs should open the picker")
+	}
+	updated, cmd = m.Update(tea.KeyMsg{Type: tea.KeyCtrlX})
+	m = updated.(Model)
+	updated, _ = m.Update(cmd())
+	m = updated.(Model)
+	if !cancelled {
+		t.Error("Ctrl+X did not cancel the selected job")
+	}
+	if j, _ := m.jobPicker.Selected(); j.Status != "cancelling" {
+		t.Errorf("status after cancel = %s", j.Status)
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyEscape})
+	if updated.(Model).jobPicker.IsActive() {
+		t.Error("Esc should close the picker")
+	}
+}
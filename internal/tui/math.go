@@ -0,0 +1,301 @@
+package tui
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// LaTeX math -> unicode approximation
+// ---------------------------------------------------------------------------
+
+// latexSymbols maps LaTeX commands to their unicode equivalents.
+var latexSymbols = map[string]string{
+	// Greek
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ε", "varepsilon": "ε",
+	"zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π", "rho": "ρ", "sigma": "σ",
+	"tau": "τ", "upsilon": "υ", "phi": "φ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π",
+	"Sigma": "Σ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	// Operators and relations
+	"times": "×", "cdot": "·", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "sim": "∼", "simeq": "≃", "equiv": "≡", "propto": "∝",
+	"ll": "≪", "gg": "≫", "in": "∈", "notin": "∉", "subset": "⊂", "subseteq": "⊆",
+	"supset": "⊃", "supseteq": "⊇", "cup": "∪", "cap": "∩", "emptyset": "∅",
+	"forall": "∀", "exists": "∃", "neg": "¬", "land": "∧", "lor": "∨",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"leftrightarrow": "↔", "Leftrightarrow": "⇔", "implies": "⇒", "iff": "⇔", "mapsto": "↦",
+	"sum": "∑", "prod": "∏", "int": "∫", "iint": "∬", "oint": "∮",
+	"partial": "∂", "nabla": "∇", "infty": "∞", "sqrt": "√", "degree": "°",
+	"ldots": "…", "cdots": "⋯", "dots": "…", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉",
+	"mathbb{R}": "ℝ", "mathbb{N}": "ℕ", "mathbb{Z}": "ℤ", "mathbb{Q}": "ℚ", "mathbb{C}": "ℂ",
+	"hbar": "ℏ", "ell": "ℓ", "prime": "′",
+	// Spacing and delimiters
+	",": " ", ";": " ", ":": " ", "!": "", "quad": "  ", "qquad": "    ",
+	"{": "{", "}": "}", "%": "%", "$": "$", "_": "_", "#": "#", "&": "&",
+	"left": "", "right": "", "big": "", "Big": "", "bigg": "", "Bigg": "",
+}
+
+var superscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶', '7': '⁷', '8': '⁸', '9': '⁹',
+	'+': '⁺', '-': '⁻', '=': '⁼', '(': '⁽', ')': '⁾', 'n': 'ⁿ', 'i': 'ⁱ', 'T': 'ᵀ',
+	'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ', 'k': 'ᵏ', 'm': 'ᵐ', 'x': 'ˣ', 'y': 'ʸ',
+}
+
+var subscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆', '7': '₇', '8': '₈', '9': '₉',
+	'+': '₊', '-': '₋', '=': '₌', '(': '₍', ')': '₎',
+	'a': 'ₐ', 'e': 'ₑ', 'i': 'ᵢ', 'j': 'ⱼ', 'k': 'ₖ', 'n': 'ₙ', 'o': 'ₒ', 'x': 'ₓ', 't': 'ₜ',
+}
+
+// parenMathRe matches \(...\) inline math.
+var parenMathRe = regexp.MustCompile(`\\\((.+?)\\\)`)
+
+// LatexToUnicode converts a LaTeX math expression into a readable unicode
+// approximation. Unknown commands are kept without their backslash.
+func LatexToUnicode(expr string) string {
+	var b strings.Builder
+	rs := []rune(strings.TrimSpace(expr))
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '\\':
+			name, next := readLatexCommand(rs, i+1)
+			i = next
+			switch name {
+			case "frac", "dfrac", "tfrac":
+				num, n1 := readLatexGroup(rs, i)
+				den, n2 := readLatexGroup(rs, n1)
+				i = n2
+				b.WriteString(wrapIfComplex(LatexToUnicode(num)) + "/" + wrapIfComplex(LatexToUnicode(den)))
+			case "sqrt":
+				arg, n := readLatexGroup(rs, i)
+				i = n
+				b.WriteString("√" + wrapIfComplex(LatexToUnicode(arg)))
+			case "text", "mathrm", "mathbf", "mathit", "mathsf", "mathtt", "operatorname", "textbf", "textit", "boldsymbol":
+				arg, n := readLatexGroup(rs, i)
+				i = n
+				b.WriteString(LatexToUnicode(arg))
+			case "mathbb":
+				arg, n := readLatexGroup(rs, i)
+				i = n
+				if sym, ok := latexSymbols["mathbb{"+arg+"}"]; ok {
+					b.WriteString(sym)
+				} else {
+					b.WriteString(arg)
+				}
+			case "hat", "bar", "vec", "tilde", "dot", "overline":
+				arg, n := readLatexGroup(rs, i)
+				i = n
+				b.WriteString(LatexToUnicode(arg) + accentMark(name))
+			default:
+				if sym, ok := latexSymbols[name]; ok {
+					b.WriteString(sym)
+				} else {
+					b.WriteString(name)
+				}
+			}
+		case r == '^' || r == '_':
+			arg, n := readLatexGroup(rs, i+1)
+			i = n
+			table := superscripts
+			if r == '_' {
+				table = subscripts
+			}
+			b.WriteString(scriptOrFallback(LatexToUnicode(arg), table, r))
+		case r == '{' || r == '}':
+			i++
+		case r == '~':
+			b.WriteRune(' ')
+			i++
+		default:
+			b.WriteRune(r)
+			i++
+		}
+	}
+	return b.String()
+}
+
+// readLatexCommand reads a command name starting at i (just after the
+// backslash). Returns the name and the index after it.
+func readLatexCommand(rs []rune, i int) (string, int) {
+	if i >= len(rs) {
+		return "", i
+	}
+	if !isASCIILetter(rs[i]) {
+		return string(rs[i]), i + 1
+	}
+	j := i
+	for j < len(rs) && isASCIILetter(rs[j]) {
+		j++
+	}
+	return string(rs[i:j]), j
+}
+
+// readLatexGroup reads a {braced} group or a single token starting at i,
+// skipping leading spaces. Returns the group contents and the next index.
+func readLatexGroup(rs []rune, i int) (string, int) {
+	for i < len(rs) && rs[i] == ' ' {
+		i++
+	}
+	if i >= len(rs) {
+		return "", i
+	}
+	if rs[i] == '\\' {
+		name, next := readLatexCommand(rs, i+1)
+		return `\` + name, next
+	}
+	if rs[i] != '{' {
+		return string(rs[i]), i + 1
+	}
+	depth := 0
+	for j := i; j < len(rs); j++ {
+		switch rs[j] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return string(rs[i+1 : j]), j + 1
+			}
+		}
+	}
+	return string(rs[i+1:]), len(rs)
+}
+
+func isASCIILetter(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// scriptOrFallback converts s to super/subscript characters when every rune
+// has a unicode form, otherwise falls back to ^(s) / _(s).
+func scriptOrFallback(s string, table map[rune]rune, marker rune) string {
+	var b strings.Builder
+	for _, r := range s {
+		mapped, ok := table[r]
+		if !ok {
+			if len([]rune(s)) == 1 {
+				return string(marker) + s
+			}
+			return string(marker) + "(" + s + ")"
+		}
+		b.WriteRune(mapped)
+	}
+	return b.String()
+}
+
+// wrapIfComplex parenthesizes s if it contains spaces or operators so that
+// a/b fractions stay unambiguous.
+func wrapIfComplex(s string) string {
+	if strings.ContainsAny(s, " +-−×·/=") {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func accentMark(name string) string {
+	switch name {
+	case "hat":
+		return "̂"
+	case "bar", "overline":
+		return "̄"
+	case "vec":
+		return "⃗"
+	case "tilde":
+		return "̃"
+	case "dot":
+		return "̇"
+	}
+	return ""
+}
+
+// RenderInlineMath replaces $...$ and \(...\) spans in a line of prose with
+// unicode approximations. Inline code spans are left untouched.
+func RenderInlineMath(line string) string {
+	if !strings.Contains(line, "$") && !strings.Contains(line, `\(`) {
+		return line
+	}
+	parts := strings.Split(line, "`")
+	for i := 0; i < len(parts); i += 2 { // even indices are outside backticks
+		parts[i] = parenMathRe.ReplaceAllStringFunc(parts[i], func(m string) string {
+			return LatexToUnicode(parenMathRe.FindStringSubmatch(m)[1])
+		})
+		parts[i] = replaceDollarMath(parts[i])
+	}
+	return strings.Join(parts, "`")
+}
+
+// replaceDollarMath converts $...$ spans. The content must not start or end
+// with a space and the closing $ must not be followed by a digit, so prices
+// like "$5 and $10" are left alone. The content must also look like TeX, so
+// shell variables like "$HOME/$USER" are left alone too. $$ and escaped \$
+// are skipped.
+func replaceDollarMath(s string) string {
+	var b strings.Builder
+	i := 0
+	for i < len(s) {
+		if s[i] != '$' || (i > 0 && (s[i-1] == '\\' || s[i-1] == '$')) || (i+1 < len(s) && s[i+1] == '$') {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		end := strings.IndexByte(s[i+1:], '$')
+		if end <= 0 {
+			b.WriteString(s[i:])
+			break
+		}
+		end += i + 1
+		inner := s[i+1 : end]
+		after := end + 1
+		if inner[0] == ' ' || inner[len(inner)-1] == ' ' || (after < len(s) && s[after] >= '0' && s[after] <= '9') || !looksLikeTeX(inner) {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		b.WriteString(LatexToUnicode(inner))
+		i = after
+	}
+	return b.String()
+}
+
+// looksLikeTeX reports whether an inline math span uses TeX markup: a
+// backslash command, a brace group, or a superscript or subscript on a digit
+// or brace group. Underscores inside identifiers like SRC_DIR do not count.
+func looksLikeTeX(s string) bool {
+	if strings.ContainsAny(s, `\{`) {
+		return true
+	}
+	for i := 0; i+1 < len(s); i++ {
+		if (s[i] == '^' || s[i] == '_') && (s[i+1] == '{' || (s[i+1] >= '0' && s[i+1] <= '9')) {
+			return true
+		}
+	}
+	return false
+}
+
+// renderMathBlock renders a display math expression as indented unicode.
+func renderMathBlock(expr string, width int) []string {
+	var out []string
+	for _, wl := range WrapWords(LatexToUnicode(expr), width-2) {
+		out = append(out, "  "+MathStyle.Render(wl))
+	}
+	return out
+}
+
+// displayMathBounds reports whether trimmed opens or closes a display math
+// block ($$ or \[ \]). For single-line blocks ("$$x^2$$") it returns the
+// inner expression and oneLine=true.
+func displayMathBounds(trimmed string) (delim bool, inner string, oneLine bool) {
+	for _, d := range [][2]string{{"$$", "$$"}, {`\[`, `\]`}} {
+		if trimmed == d[0] || trimmed == d[1] {
+			return true, "", false
+		}
+		if len(trimmed) > len(d[0])+len(d[1]) && strings.HasPrefix(trimmed, d[0]) && strings.HasSuffix(trimmed, d[1]) {
+			return true, trimmed[len(d[0]) : len(trimmed)-len(d[1])], true
+		}
+	}
+	return false, "", false
+}
@@ -0,0 +1,64 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLatexToUnicode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`x^2 + y^2`, "x² + y²"},
+		{`\alpha \leq \beta`, "α ≤ β"},
+		{`\frac{a+b}{2}`, "(a+b)/2"},
+		{`\sqrt{x}`, "√x"},
+		{`x_{i+1}`, "xᵢ₊₁"},
+		{`e^{-\lambda t}`, "e^(-λ t)"},
+		{`\sum_{i=1}^{n} x_i`, "∑ᵢ₌₁ⁿ xᵢ"},
+		{`\mathbb{R}^n`, "ℝⁿ"},
+		{`\text{loss} = \mathbf{w}`, "loss = w"},
+	}
+	for _, tt := range tests {
+		if got := LatexToUnicode(tt.in); got != tt.want {
+			t.Errorf("LatexToUnicode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderInlineMath(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"the area is $\\pi r^2$ here", "the area is π r² here"},
+		{"costs $5 and $10", "costs $5 and $10"},
+		{"both $x_1$ and $\\beta$", "both x₁ and β"},
+		{"bare $a$ and $b$", "bare $a$ and $b$"},
+		{"set $HOME/$USER first", "set $HOME/$USER first"},
+		{"copy $SRC_DIR/$DST_DIR now", "copy $SRC_DIR/$DST_DIR now"},
+		{"run echo $PATH then $SHELL", "run echo $PATH then $SHELL"},
+		{"from $ x^2$ on", "from $ x^2$ on"},
+		{"between $x^2$5", "between $x^2$5"},
+		{"paren \\(\\alpha\\) form", "paren α form"},
+		{"code `$x^2$` stays", "code `$x^2$` stays"},
+		{"no math", "no math"},
+	}
+	for _, tt := range tests {
+		if got := RenderInlineMath(tt.in); got != tt.want {
+			t.Errorf("RenderInlineMath(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderAssistantLines_DisplayMath(t *testing.T) {
+	lines := RenderAssistantLines("Result:\n$$\n\\frac{1}{n} \\sum x_i\n$$\ndone", 80)
+	joined := strings.Join(lines, "\n")
+	if strings.Contains(joined, "$$") || strings.Contains(joined, `\frac`) {
+		t.Errorf("raw LaTeX leaked into output:\n%s", joined)
+	}
+	if !strings.Contains(joined, "1/n ∑ xᵢ") {
+		t.Errorf("expected rendered math, got:\n%s", joined)
+	}
+}
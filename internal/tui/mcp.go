@@ -0,0 +1,178 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/mcp"
+)
+
+// ---------------------------------------------------------------------------
+// /mcp: MCP server management
+// ---------------------------------------------------------------------------
+
+const mcpUsage = "Usage: /mcp [list|add <name> [KEY=value...] <command> [args...] [--save]|add <name> <url> [--save]|remove <name> [--save]|restart <name>|logs <name>]"
+
+// MCPResultMsg carries the outcome of an /mcp command. Changed asks for the
+// tool list to be refetched, since the daemon's MCP tools may differ.
+type MCPResultMsg struct {
+	Text    string
+	Err     error
+	Changed bool
+}
+
+func (m Model) handleMCPCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError("MCP servers are managed by the daemon; connect to one first."))
+	}
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	save := false
+	var rest []string
+	for _, a := range args[min(1, len(args)):] {
+		if a == "--save" {
+			save = true
+			continue
+		}
+		rest = append(rest, a)
+	}
+	d := m.Daemon
+
+	switch sub {
+	case "list":
+		return m, func() tea.Msg {
+			servers, err := d.ListMCPServers()
+			return MCPResultMsg{Text: formatMCPServers(servers), Err: err}
+		}
+
+	case "add":
+		req, err := parseMCPAdd(rest)
+		if err != nil {
+			return m, PrintToScrollback(m.renderError(err.Error()))
+		}
+		req.Save = save
+		return m, func() tea.Msg {
+			info, err := d.AddMCPServer(req)
+			if err != nil {
+				return MCPResultMsg{Err: err}
+			}
+			text := formatMCPServers([]mcp.ServerInfo{*info})
+			if save {
+				text += "\nSaved to the daemon's user mcp.json."
+			}
+			return MCPResultMsg{Text: text, Changed: true}
+		}
+
+	case "remove", "restart", "logs":
+		if len(rest) != 1 {
+			return m, PrintToScrollback(m.renderError("Usage: /mcp " + sub + " <name>"))
+		}
+		name := rest[0]
+		return m, func() tea.Msg {
+			switch sub {
+			case "remove":
+				if err := d.RemoveMCPServer(name, save); err != nil {
+					return MCPResultMsg{Err: err}
+				}
+				return MCPResultMsg{Text: fmt.Sprintf("Removed MCP server %s.", name), Changed: true}
+			case "restart":
+				info, err := d.RestartMCPServer(name)
+				if err != nil {
+					return MCPResultMsg{Err: err}
+				}
+				return MCPResultMsg{Text: formatMCPServers([]mcp.ServerInfo{*info}), Changed: true}
+			default:
+				lines, err := d.MCPServerLogs(name)
+				if err != nil {
+					return MCPResultMsg{Err: err}
+				}
+				if len(lines) == 0 {
+					return MCPResultMsg{Text: "No output from " + name + " yet."}
+				}
+				return MCPResultMsg{Text: strings.Join(lines, "\n")}
+			}
+		}
+
+	default:
+		return m, PrintToScrollback(m.renderError(mcpUsage))
+	}
+}
+
+// handleMCPResult prints an /mcp outcome and refreshes the tool list when
+// servers changed.
+func (m Model) handleMCPResult(msg MCPResultMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError(msg.Err.Error()))
+	}
+	cmd := PrintToScrollback(FooterMeta.Render(msg.Text))
+	if msg.Changed && m.Daemon != nil {
+		cmd = tea.Batch(cmd, fetchTools(m.Daemon, false))
+	}
+	return m, cmd
+}
+
+// parseMCPAdd reads "<name> [KEY=value...] <command> [args...]" or
+// "<name> <url>" into a request.
+func parseMCPAdd(args []string) (daemon.MCPServerRequest, error) {
+	if len(args) < 2 {
+		return daemon.MCPServerRequest{}, fmt.Errorf("%s", mcpUsage)
+	}
+	req := daemon.MCPServerRequest{Name: args[0]}
+	rest := args[1:]
+	if len(rest) == 1 && (strings.HasPrefix(rest[0], "http://") || strings.HasPrefix(rest[0], "https://")) {
+		req.Type = "http"
+		req.URL = rest[0]
+		return req, nil
+	}
+	for len(rest) > 0 {
+		k, v, ok := strings.Cut(rest[0], "=")
+		if !ok || k == "" || strings.ContainsAny(k, "/\\") {
+			break
+		}
+		if req.Env == nil {
+			req.Env = map[string]string{}
+		}
+		req.Env[k] = v
+		rest = rest[1:]
+	}
+	if len(rest) == 0 {
+		return daemon.MCPServerRequest{}, fmt.Errorf("/mcp add %s: missing command", req.Name)
+	}
+	req.Type = "stdio"
+	req.Command = rest[0]
+	req.Args = rest[1:]
+	return req, nil
+}
+
+// formatMCPServers renders servers one per line: name, status, tool count
+// and command or URL, with the error under a failed server.
+func formatMCPServers(servers []mcp.ServerInfo) string {
+	if len(servers) == 0 {
+		return "No MCP servers. Add one with /mcp add <name> <command> [args...]."
+	}
+	width := 0
+	for _, s := range servers {
+		width = max(width, len(s.Name))
+	}
+	var b strings.Builder
+	for i, s := range servers {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "%-*s  %-12s %3d tools  %s", width, s.Name, s.Status, s.Tools, s.Target)
+		if s.Error != "" {
+			fmt.Fprintf(&b, "\n%-*s  %s", width, "", s.Error)
+			if s.RetryAt != nil {
+				wait := max(time.Until(*s.RetryAt).Round(time.Second), 0)
+				fmt.Fprintf(&b, " (attempt %d, retrying in %s)", s.Attempt, wait)
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/mcp"
+)
+
+func TestParseMCPAdd(t *testing.T) {
+	t.Run("stdio with env and args", func(t *testing.T) {
+		req, err := parseMCPAdd([]string{"gh", "GITHUB_TOKEN=${GH_TOKEN}", "npx", "-y", "@modelcontextprotocol/server-github"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Name != "gh" || req.Type != "stdio" || req.Command != "npx" || strings.Join(req.Args, " ") != "-y @modelcontextprotocol/server-github" {
+			t.Errorf("req = %+v", req)
+		}
+		if req.Env["GITHUB_TOKEN"] != "${GH_TOKEN}" {
+			t.Errorf("env = %v", req.Env)
+		}
+	})
+
+	t.Run("http url", func(t *testing.T) {
+		req, err := parseMCPAdd([]string{"docs", "https://mcp.example.com/mcp"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if req.Type != "http" || req.URL != "https://mcp.example.com/mcp" || req.Command != "" {
+			t.Errorf("req = %+v", req)
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, args := range [][]string{nil, {"gh"}, {"gh", "A=1"}} {
+			if _, err := parseMCPAdd(args); err == nil {
+				t.Errorf("parseMCPAdd(%q): expected error", args)
+			}
+		}
+	})
+}
+
+func TestFormatMCPServers(t *testing.T) {
+	retryAt := time.Now().Add(30*time.Second + 100*time.Millisecond)
+	got := formatMCPServers([]mcp.ServerInfo{
+		{Name: "db", Status: "connected", Tools: 3, Target: "db-server --ro"},
+		{Name: "github", Status: "error", Target: "npx gh", Error: "server process exited"},
+		{Name: "docs", Status: "reconnecting", Target: "https://mcp.example.com", Error: "connection lost: EOF", Attempt: 2, RetryAt: &retryAt},
+	})
+	lines := strings.Split(got, "\n")
+	if len(lines) != 5 {
+		t.Fatalf("got %d lines:\n%s", len(lines), got)
+	}
+	if !strings.HasPrefix(lines[0], "db      connected") || !strings.Contains(lines[0], "3 tools  db-server --ro") {
+		t.Errorf("line 0 = %q", lines[0])
+	}
+	if strings.TrimSpace(lines[2]) != "server process exited" {
+		t.Errorf("error line = %q", lines[2])
+	}
+	if !strings.HasSuffix(lines[4], "connection lost: EOF (attempt 2, retrying in 30s)") {
+		t.Errorf("reconnecting line = %q", lines[4])
+	}
+	if !strings.Contains(formatMCPServers(nil), "/mcp add") {
+		t.Error("empty list should say how to add a server")
+	}
+}
+
+func TestHandleMCPCommand_noDaemon(t *testing.T) {
+	m := Model{}
+	_, cmd := m.handleMCPCommand([]string{"list"})
+	if cmd == nil {
+		t.Fatal("expected an error message")
+	}
+}
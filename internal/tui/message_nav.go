@@ -0,0 +1,358 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// ---------------------------------------------------------------------------
+// Transcript navigation
+// ---------------------------------------------------------------------------
+
+// navAction is something that can be done to the message under the cursor.
+type navAction struct {
+	Key   rune
+	Name  string
+	Label string
+}
+
+// navActions are offered in the action menu and as direct shortcuts.
+var navActions = []navAction{
+	{'c', "copy", "copy to clipboard"},
+	{'q', "quote", "quote into input"},
+	{'f', "fork", "fork from here"},
+	{'a', "annotate", "annotate"},
+	{'d', "delete", "delete"},
+}
+
+type navMode int
+
+const (
+	navBrowse navMode = iota
+	navMenu
+	navAnnotate
+	navConfirmDelete
+)
+
+// navMessage is a message of the transcript with its annotation.
+type navMessage struct {
+	forkPoint
+	Note string
+}
+
+// navRequest is an action the model carries out for the selected message.
+type navRequest struct {
+	Action   string // a navAction name
+	Sequence int
+	Text     string
+	Note     string // annotate only
+}
+
+// MessageNav walks the transcript one message at a time (j/k) and runs
+// actions on the selected message: copy, quote, fork, annotate, delete.
+type MessageNav struct {
+	messages []navMessage // oldest first
+	cursor   int
+	mode     navMode
+	action   int    // highlighted entry of the action menu
+	note     string // annotation being typed
+	active   bool
+}
+
+// NewMessageNav creates a navigator over a session's transcript with the
+// cursor on the newest message. notes maps sequences to annotations.
+func NewMessageNav(messages []domain.TranscriptMessage, notes map[int]string) *MessageNav {
+	points := forkPoints(messages)
+	n := &MessageNav{active: true}
+	for i := len(points) - 1; i >= 0; i-- {
+		n.messages = append(n.messages, navMessage{forkPoint: points[i], Note: notes[points[i].Sequence]})
+	}
+	n.cursor = max(len(n.messages)-1, 0)
+	return n
+}
+
+// IsActive reports whether the navigator is currently shown.
+func (n *MessageNav) IsActive() bool {
+	return n != nil && n.active
+}
+
+// Dismiss closes the navigator.
+func (n *MessageNav) Dismiss() {
+	n.active = false
+}
+
+// Len returns the number of messages.
+func (n *MessageNav) Len() int {
+	return len(n.messages)
+}
+
+func (n *MessageNav) current() (navMessage, bool) {
+	if n.cursor < 0 || n.cursor >= len(n.messages) {
+		return navMessage{}, false
+	}
+	return n.messages[n.cursor], true
+}
+
+// Move moves the cursor by delta messages, stopping at either end.
+func (n *MessageNav) Move(delta int) {
+	n.cursor = min(max(n.cursor+delta, 0), max(len(n.messages)-1, 0))
+}
+
+// SetNote records an annotation saved for sequence.
+func (n *MessageNav) SetNote(sequence int, note string) {
+	for i := range n.messages {
+		if n.messages[i].Sequence == sequence {
+			n.messages[i].Note = note
+		}
+	}
+}
+
+// Select moves the cursor to the message at sequence, or the closest one
+// before it.
+func (n *MessageNav) Select(sequence int) {
+	for i, msg := range n.messages {
+		if msg.Sequence > sequence {
+			break
+		}
+		n.cursor = i
+	}
+}
+
+// HandleKey updates the navigator for a key press and returns the action to
+// run, if the key completed one.
+func (n *MessageNav) HandleKey(msg tea.KeyMsg) (navRequest, bool) {
+	switch n.mode {
+	case navMenu:
+		return n.handleMenuKey(msg)
+	case navAnnotate:
+		return n.handleAnnotateKey(msg)
+	case navConfirmDelete:
+		if msg.Type == tea.KeyRunes && string(msg.Runes) == "y" {
+			n.mode = navBrowse
+			return n.request("delete")
+		}
+		n.mode = navBrowse
+		return navRequest{}, false
+	}
+
+	switch msg.Type {
+	case tea.KeyEscape, tea.KeyCtrlC:
+		n.Dismiss()
+	case tea.KeyUp:
+		n.Move(-1)
+	case tea.KeyDown:
+		n.Move(1)
+	case tea.KeyPgUp:
+		n.Move(-10)
+	case tea.KeyPgDown:
+		n.Move(10)
+	case tea.KeyHome:
+		n.cursor = 0
+	case tea.KeyEnd:
+		n.Move(len(n.messages))
+	case tea.KeyEnter:
+		if _, ok := n.current(); ok {
+			n.mode, n.action = navMenu, 0
+		}
+	case tea.KeyRunes:
+		switch r := string(msg.Runes); r {
+		case "j":
+			n.Move(1)
+		case "k":
+			n.Move(-1)
+		case "g":
+			n.cursor = 0
+		case "G":
+			n.Move(len(n.messages))
+		default:
+			for _, a := range navActions {
+				if r == string(a.Key) {
+					return n.start(a.Name)
+				}
+			}
+		}
+	}
+	return navRequest{}, false
+}
+
+func (n *MessageNav) handleMenuKey(msg tea.KeyMsg) (navRequest, bool) {
+	switch msg.Type {
+	case tea.KeyEscape, tea.KeyCtrlC:
+		n.mode = navBrowse
+	case tea.KeyUp:
+		n.action = max(n.action-1, 0)
+	case tea.KeyDown:
+		n.action = min(n.action+1, len(navActions)-1)
+	case tea.KeyEnter:
+		return n.start(navActions[n.action].Name)
+	case tea.KeyRunes:
+		switch r := string(msg.Runes); r {
+		case "j":
+			n.action = min(n.action+1, len(navActions)-1)
+		case "k":
+			n.action = max(n.action-1, 0)
+		default:
+			for _, a := range navActions {
+				if r == string(a.Key) {
+					return n.start(a.Name)
+				}
+			}
+		}
+	}
+	return navRequest{}, false
+}
+
+func (n *MessageNav) handleAnnotateKey(msg tea.KeyMsg) (navRequest, bool) {
+	switch msg.Type {
+	case tea.KeyEscape, tea.KeyCtrlC:
+		n.mode = navBrowse
+	case tea.KeyEnter:
+		n.mode = navBrowse
+		req, ok := n.request("annotate")
+		req.Note = strings.TrimSpace(n.note)
+		return req, ok
+	case tea.KeyBackspace, tea.KeyDelete:
+		if r := []rune(n.note); len(r) > 0 {
+			n.note = string(r[:len(r)-1])
+		}
+	case tea.KeySpace:
+		n.note += " "
+	case tea.KeyRunes:
+		n.note += string(msg.Runes)
+	}
+	return navRequest{}, false
+}
+
+// start begins the named action on the selected message. Annotating and
+// deleting first ask for a note or a confirmation.
+func (n *MessageNav) start(action string) (navRequest, bool) {
+	cur, ok := n.current()
+	if !ok {
+		return navRequest{}, false
+	}
+	switch action {
+	case "annotate":
+		n.mode, n.note = navAnnotate, cur.Note
+		return navRequest{}, false
+	case "delete":
+		n.mode = navConfirmDelete
+		return navRequest{}, false
+	}
+	n.mode = navBrowse
+	return n.request(action)
+}
+
+func (n *MessageNav) request(action string) (navRequest, bool) {
+	cur, ok := n.current()
+	if !ok {
+		return navRequest{}, false
+	}
+	return navRequest{Action: action, Sequence: cur.Sequence, Text: cur.Text}, true
+}
+
+// quoteTarget finds the message /quote quotes: the one at sequence, or the
+// newest assistant reply when sequence is 0. points are newest first, as
+// forkPoints returns them. Messages with only tool calls have nothing to
+// quote.
+func quoteTarget(points []forkPoint, sequence int) (forkPoint, bool) {
+	for _, fp := range points {
+		if fp.Text == "" {
+			continue
+		}
+		if sequence > 0 && fp.Sequence == sequence || sequence == 0 && fp.Role == "assistant" {
+			return fp, true
+		}
+	}
+	return forkPoint{}, false
+}
+
+// quoteText formats text as a Markdown quote for the input.
+func quoteText(text string) string {
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight("> "+line, " ")
+	}
+	return strings.Join(lines, "\n") + "\n\n"
+}
+
+// View renders the navigator as a string.
+func (n *MessageNav) View(width int) string {
+	var b strings.Builder
+
+	b.WriteString(FooterHead.Render("Messages"))
+	b.WriteString("\n\n")
+
+	const maxVisible = 10
+	start := 0
+	if n.cursor >= maxVisible {
+		start = n.cursor - maxVisible + 1
+	}
+	end := min(start+maxVisible, len(n.messages))
+	previewWidth := max(width-26, 20)
+	for i := start; i < end; i++ {
+		msg := n.messages[i]
+		indicator := "  "
+		if i == n.cursor {
+			indicator = "> "
+		}
+		mark := " "
+		if msg.Note != "" {
+			mark = "✎"
+		}
+		text := msg.Text
+		if text == "" {
+			text = "[" + strings.Join(msg.Tools, ", ") + "]"
+		}
+		line := fmt.Sprintf("%s%-5s %s %-9s  %s", indicator, "#"+strconv.Itoa(msg.Sequence), mark, msg.Role, contextPreview(text, previewWidth))
+		if i == n.cursor {
+			b.WriteString(CompletionSelStyle.Render(line))
+		} else {
+			b.WriteString(FooterMeta.Render(line))
+		}
+		b.WriteString("\n")
+	}
+	if len(n.messages) > maxVisible {
+		b.WriteString(FooterMeta.Render(fmt.Sprintf("  %d of %d", n.cursor+1, len(n.messages))))
+		b.WriteString("\n")
+	}
+
+	cur, ok := n.current()
+	if ok {
+		lines := forkPointDetail(cur.forkPoint)
+		if cur.Note != "" {
+			lines = append(lines[:1], append([]string{"note: " + cur.Note}, lines[1:]...)...)
+		}
+		b.WriteString(renderPreview(lines, width))
+	}
+
+	b.WriteString("\n")
+	switch n.mode {
+	case navMenu:
+		for i, a := range navActions {
+			line := fmt.Sprintf("  %c  %s", a.Key, a.Label)
+			if i == n.action {
+				b.WriteString(CompletionSelStyle.Render("> " + line[2:]))
+			} else {
+				b.WriteString(FooterMeta.Render(line))
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(FooterMeta.Render("  j/k=move  Enter=run  Esc=back"))
+	case navAnnotate:
+		b.WriteString(FooterMeta.Render(fmt.Sprintf("  Note for #%d: %s", cur.Sequence, n.note)))
+		b.WriteString(CursorStyle.Render("█"))
+		b.WriteString("\n")
+		b.WriteString(FooterMeta.Render("  Enter=save (empty removes)  Esc=cancel"))
+	case navConfirmDelete:
+		b.WriteString(FooterMeta.Render(fmt.Sprintf("  Delete #%d from the session? The model will no longer see it.  y=delete  any other key=cancel", cur.Sequence)))
+	default:
+		b.WriteString(FooterMeta.Render("  j/k=move  Enter=actions  c=copy  q=quote  f=fork  a=annotate  d=delete  Esc=close"))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,208 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func runes(s string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(s)}
+}
+
+func TestMessageNav_move(t *testing.T) {
+	n := NewMessageNav(forkTestMessages(), map[int]string{4: "shipped"})
+	if n.Len() != 4 {
+		t.Fatalf("Len = %d, want 4 (tool results skipped)", n.Len())
+	}
+	cur, _ := n.current()
+	if cur.Sequence != 5 {
+		t.Errorf("cursor starts on #%d, want the newest message", cur.Sequence)
+	}
+	n.HandleKey(runes("k"))
+	if cur, _ = n.current(); cur.Sequence != 4 || cur.Note != "shipped" {
+		t.Errorf("after k: %+v", cur)
+	}
+	n.HandleKey(runes("g"))
+	if cur, _ = n.current(); cur.Sequence != 1 {
+		t.Errorf("after g: #%d, want #1", cur.Sequence)
+	}
+	n.HandleKey(runes("k"))
+	n.HandleKey(runes("j"))
+	if cur, _ = n.current(); cur.Sequence != 2 {
+		t.Errorf("after k at top then j: #%d, want #2", cur.Sequence)
+	}
+	n.Select(3)
+	if cur, _ = n.current(); cur.Sequence != 2 {
+		t.Errorf("Select(3) = #%d, want the closest earlier message #2", cur.Sequence)
+	}
+	n.HandleKey(tea.KeyMsg{Type: tea.KeyEscape})
+	if n.IsActive() {
+		t.Error("Esc should close the navigator")
+	}
+}
+
+func TestMessageNav_actions(t *testing.T) {
+	n := NewMessageNav(forkTestMessages(), nil)
+
+	req, ok := n.HandleKey(runes("c"))
+	if !ok || req.Action != "copy" || req.Sequence != 5 || req.Text != "now add metrics" {
+		t.Errorf("copy = %+v, %v", req, ok)
+	}
+
+	// The action menu runs the highlighted entry.
+	n.HandleKey(tea.KeyMsg{Type: tea.KeyEnter})
+	if !strings.Contains(n.View(100), "fork from here") {
+		t.Errorf("menu not shown:\n%s", n.View(100))
+	}
+	n.HandleKey(runes("j"))
+	n.HandleKey(runes("j"))
+	if req, ok = n.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}); !ok || req.Action != "fork" {
+		t.Errorf("menu fork = %+v, %v", req, ok)
+	}
+
+	// Annotating asks for a note first.
+	if _, ok = n.HandleKey(runes("a")); ok {
+		t.Error("annotate should wait for the note")
+	}
+	n.HandleKey(runes("todo"))
+	n.HandleKey(tea.KeyMsg{Type: tea.KeySpace})
+	n.HandleKey(runes("x"))
+	n.HandleKey(tea.KeyMsg{Type: tea.KeyBackspace})
+	if req, ok = n.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}); !ok || req.Action != "annotate" || req.Note != "todo" {
+		t.Errorf("annotate = %+v, %v", req, ok)
+	}
+	n.SetNote(5, "todo")
+	if !strings.Contains(n.View(100), "note: todo") {
+		t.Errorf("note not shown:\n%s", n.View(100))
+	}
+
+	// Deleting needs confirmation; any other key cancels.
+	n.HandleKey(runes("d"))
+	if _, ok = n.HandleKey(runes("n")); ok {
+		t.Error("n should cancel the delete")
+	}
+	n.HandleKey(runes("d"))
+	if req, ok = n.HandleKey(runes("y")); !ok || req.Action != "delete" || req.Sequence != 5 {
+		t.Errorf("delete = %+v, %v", req, ok)
+	}
+}
+
+func TestQuoteText(t *testing.T) {
+	if got, want := quoteText("first\n\nsecond\n"), "> first\n>\n> second\n\n"; got != want {
+		t.Errorf("quoteText = %q, want %q", got, want)
+	}
+}
+
+func TestMessageNav_quoteIntoInput(t *testing.T) {
+	m := Model{input: "see:\n"}
+	m.messageNav = NewMessageNav(forkTestMessages(), nil)
+	updated, _ := m.handleMessageNavKey(runes("q"))
+	got := updated.(Model)
+	if got.input != "see:\n> now add metrics\n\n" {
+		t.Errorf("input = %q", got.input)
+	}
+	if got.messageNav.IsActive() {
+		t.Error("quoting should close the navigator")
+	}
+}
+
+func TestQuoteTarget(t *testing.T) {
+	points := forkPoints(forkTestMessages())
+	tests := []struct {
+		sequence int
+		want     string
+		ok       bool
+	}{
+		{0, "Added /healthz.", true},       // newest reply
+		{1, "add a health endpoint", true}, // by number
+		{2, "", false},                     // tool calls only
+		{9, "", false},
+	}
+	for _, tt := range tests {
+		fp, ok := quoteTarget(points, tt.sequence)
+		if ok != tt.ok || fp.Text != tt.want {
+			t.Errorf("quoteTarget(%d) = %q, %v; want %q, %v", tt.sequence, fp.Text, ok, tt.want, tt.ok)
+		}
+	}
+	if _, ok := quoteTarget(forkPoints(forkTestMessages()[:1]), 0); ok {
+		t.Error("quoted a reply before there was one")
+	}
+}
+
+func TestQuoteCommand(t *testing.T) {
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+	sess, _ := st.CreateSession("/tmp/quote", "test-model")
+	for _, msg := range forkTestMessages() {
+		_ = st.AppendMessage(sess.ID, msg.Role, msg.TextContent(), 0)
+	}
+
+	m := Model{Store: st, Session: sess}
+	updated, _ := m.handleSlashCommand("/quote")
+	m = updated.(Model)
+	if m.input != "> Added /healthz.\n\n" {
+		t.Errorf("input = %q", m.input)
+	}
+	updated, _ = m.handleSlashCommand("/quote #1")
+	if got := updated.(Model).input; got != "> add a health endpoint\n\n" {
+		t.Errorf("input = %q", got)
+	}
+	for _, bad := range []string{"/quote x", "/quote 9"} {
+		updated, cmd := m.handleSlashCommand(bad)
+		if updated.(Model).input != "" || cmd == nil {
+			t.Errorf("%s: want an error and no quote", bad)
+		}
+	}
+}
+
+func TestMessageNav_annotateAndDelete(t *testing.T) {
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenStoreIn: %v", err)
+	}
+	defer st.Close()
+	sess, _ := st.CreateSession("/tmp/nav", "test-model")
+	for _, msg := range forkTestMessages() {
+		_ = st.AppendMessage(sess.ID, msg.Role, msg.TextContent(), 0)
+	}
+
+	m := Model{Session: sess, Store: st}
+	loaded, ok := m.openMessageNav(0)().(MessageNavMsg)
+	if !ok || loaded.Err != nil {
+		t.Fatalf("openMessageNav = %+v", loaded)
+	}
+	next, _ := m.Update(loaded)
+	m = next.(Model)
+
+	m.messageNav.HandleKey(runes("a"))
+	m.messageNav.HandleKey(runes("later"))
+	next, cmd := m.handleMessageNavKey(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(Model)
+	next, _ = m.Update(cmd())
+	m = next.(Model)
+	if notes, _ := st.MessageAnnotations(sess.ID); notes[5] != "later" {
+		t.Errorf("stored annotations = %v", notes)
+	}
+	if cur, _ := m.messageNav.current(); cur.Note != "later" {
+		t.Errorf("navigator note = %q", cur.Note)
+	}
+
+	m.messageNav.HandleKey(runes("k"))
+	m.messageNav.HandleKey(runes("d"))
+	_, cmd = m.handleMessageNavKey(runes("y"))
+	edited, ok := cmd().(MessageEditedMsg)
+	if !ok || edited.Err != nil || edited.Action != "delete" || edited.Sequence != 4 {
+		t.Fatalf("delete = %+v", edited)
+	}
+	msgs, _ := st.GetMessages(sess.ID)
+	if len(msgs) != 4 || msgs[3].Content != "now add metrics" {
+		t.Errorf("messages after delete = %+v", msgs)
+	}
+}
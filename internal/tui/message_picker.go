@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/batalabs/muxd/internal/domain"
+)
+
+// forkPoint is a message a session can be branched from.
+type forkPoint struct {
+	Sequence int // stored sequence; branching at it keeps this message
+	Role     string
+	Text     string
+	Tools    []string // tools the assistant called in this message
+}
+
+// MessagePicker lists a session's messages so one can be picked as the
+// point to fork a new branch from, without counting sequence numbers.
+type MessagePicker struct {
+	pickerList[forkPoint]
+	active bool
+}
+
+// NewMessagePicker creates a picker over a session's transcript, newest
+// message first. Messages that only carry tool results are left out.
+func NewMessagePicker(messages []domain.TranscriptMessage) *MessagePicker {
+	points := forkPoints(messages)
+	p := &MessagePicker{
+		pickerList: newPickerList("fork", points, func(fp forkPoint) []string { return []string{fp.Text, fp.Role} }, nil),
+		active:     true,
+	}
+	p.detail = forkPointDetail
+	return p
+}
+
+// forkPoints converts a transcript into fork points, newest first. Messages
+// are stored with consecutive sequence numbers starting at 1.
+func forkPoints(messages []domain.TranscriptMessage) []forkPoint {
+	var points []forkPoint
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		fp := forkPoint{Sequence: i + 1, Role: msg.Role, Text: strings.TrimSpace(msg.TextContent())}
+		for _, b := range msg.Blocks {
+			if b.Type == "tool_use" {
+				fp.Tools = append(fp.Tools, b.ToolName)
+			}
+		}
+		if fp.Text == "" && len(fp.Tools) == 0 {
+			continue
+		}
+		points = append(points, fp)
+	}
+	return points
+}
+
+// forkPointDetail is the preview pane for a message: its first lines.
+func forkPointDetail(fp forkPoint) []string {
+	lines := []string{fmt.Sprintf("#%d %s", fp.Sequence, fp.Role)}
+	if len(fp.Tools) > 0 {
+		lines = append(lines, "tools: "+strings.Join(fp.Tools, ", "))
+	}
+	text := strings.Split(fp.Text, "\n")
+	if len(text) > 6 {
+		text = append(text[:6], "…")
+	}
+	return append(lines, text...)
+}
+
+// IsActive reports whether the picker is currently shown.
+func (p *MessagePicker) IsActive() bool {
+	return p != nil && p.active
+}
+
+// Dismiss closes the picker.
+func (p *MessagePicker) Dismiss() {
+	p.active = false
+}
+
+// Selected returns the highlighted message's sequence, or 0 if none.
+func (p *MessagePicker) Selected() int {
+	fp, ok := p.current()
+	if !ok {
+		return 0
+	}
+	return fp.Sequence
+}
+
+// View renders the picker as a string.
+func (p *MessagePicker) View(width int) string {
+	var b strings.Builder
+
+	b.WriteString(FooterHead.Render("Fork from message"))
+	b.WriteString("\n")
+	b.WriteString(FooterMeta.Render("  Filter: " + p.filter))
+	b.WriteString(CursorStyle.Render("█"))
+	b.WriteString("\n\n")
+
+	if len(p.filtered) == 0 {
+		b.WriteString(FooterMeta.Render("  No matching messages."))
+		b.WriteString("\n")
+	} else {
+		const maxVisible = 10
+		start, end := p.window(maxVisible)
+		previewWidth := max(width-24, 20)
+		for i := start; i < end; i++ {
+			fp := p.filtered[i]
+			indicator := "  "
+			if i == p.selectedIdx {
+				indicator = "> "
+			}
+			text := fp.Text
+			if text == "" {
+				text = "[" + strings.Join(fp.Tools, ", ") + "]"
+			}
+			line := fmt.Sprintf("%s%-5s  %-9s  %s", indicator, "#"+strconv.Itoa(fp.Sequence), fp.Role, contextPreview(text, previewWidth))
+			if i == p.selectedIdx {
+				b.WriteString(CompletionSelStyle.Render(line))
+			} else {
+				b.WriteString(FooterMeta.Render(line))
+			}
+			b.WriteString("\n")
+		}
+		if len(p.filtered) > maxVisible {
+			b.WriteString(FooterMeta.Render(fmt.Sprintf("  ... %d total", len(p.filtered))))
+			b.WriteString("\n")
+		}
+		b.WriteString(p.previewView(width))
+	}
+
+	b.WriteString("\n")
+	b.WriteString(FooterMeta.Render("  Enter=fork from here (keeps this message)  Esc=cancel"))
+	b.WriteString("\n")
+	return b.String()
+}
@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/store"
+)
+
+func forkTestMessages() []domain.TranscriptMessage {
+	return []domain.TranscriptMessage{
+		{Role: "user", Content: "add a health endpoint"},
+		{Role: "assistant", Blocks: []domain.ContentBlock{{Type: "tool_use", ToolName: "file_write"}}},
+		{Role: "user", Blocks: []domain.ContentBlock{{Type: "tool_result", ToolResult: "ok"}}},
+		{Role: "assistant", Content: "Added /healthz."},
+		{Role: "user", Content: "now add metrics"},
+	}
+}
+
+func TestForkPoints(t *testing.T) {
+	points := forkPoints(forkTestMessages())
+	var seqs []int
+	for _, fp := range points {
+		seqs = append(seqs, fp.Sequence)
+	}
+	want := []int{5, 4, 2, 1}
+	if len(seqs) != len(want) {
+		t.Fatalf("sequences = %v, want %v (tool results skipped, newest first)", seqs, want)
+	}
+	for i := range want {
+		if seqs[i] != want[i] {
+			t.Fatalf("sequences = %v, want %v", seqs, want)
+		}
+	}
+	if points[2].Tools[0] != "file_write" {
+		t.Errorf("tool-only message = %+v", points[2])
+	}
+}
+
+func TestMessagePicker_filterAndView(t *testing.T) {
+	p := NewMessagePicker(forkTestMessages())
+	if p.Selected() != 5 {
+		t.Errorf("default selection = %d, want the newest message", p.Selected())
+	}
+	for _, r := range "healthz" {
+		p.AppendFilter(r)
+	}
+	if p.Selected() != 4 {
+		t.Errorf("filtered selection = %d, want 4", p.Selected())
+	}
+	view := p.View(100)
+	for _, want := range []string{"Fork from message", "#4", "Added /healthz.", "Enter=fork from here"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestMessagePicker_forksAtSelection(t *testing.T) {
+	st, err := store.OpenStoreIn(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenStoreIn: %v", err)
+	}
+	defer st.Close()
+	sess, _ := st.CreateSession("/tmp/fork", "test-model")
+	for _, msg := range forkTestMessages() {
+		_ = st.AppendMessage(sess.ID, msg.Role, msg.TextContent(), 0)
+	}
+
+	m := Model{Session: sess, Store: st}
+	m.messagePicker = NewMessagePicker(forkTestMessages())
+	m.messagePicker.MoveDown() // #4, the assistant reply
+
+	next, cmd := m.handleMessagePickerKey(tea.KeyMsg{Type: tea.KeyEnter})
+	if next.(Model).messagePicker.IsActive() {
+		t.Error("picker still active after Enter")
+	}
+	if cmd == nil {
+		t.Fatal("expected a branch command")
+	}
+	done, ok := cmd().(BranchDoneMsg)
+	if !ok || done.Err != nil {
+		t.Fatalf("branch = %+v", done)
+	}
+	msgs, _ := st.GetMessages(done.Session.ID)
+	if len(msgs) != 4 || msgs[3].Content != "Added /healthz." {
+		t.Errorf("branch has %d messages, want the first 4", len(msgs))
+	}
+}
@@ -3,7 +3,6 @@ package tui
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -35,8 +34,12 @@ type StreamDoneMsg struct {
 	OutputTokens             int
 	CacheCreationInputTokens int
 	CacheReadInputTokens     int
+	ContextTokens            int // prompt size of the call
+	ContextWindow            int // the model's context window, 0 when unknown
+	CompactAt                int // prompt size at which history is summarized
 	StopReason               string
 	Err                      error
+	ErrCode                  domain.ErrorCode // cause of Err, when the daemon reported one
 }
 
 // ScrollbackDoneMsg signals that a scrollback print completed (legacy, unused).
@@ -57,6 +60,7 @@ type HistoryLoadedMsg struct{}
 
 // ToolStatusMsg is sent when a tool starts executing on the server.
 type ToolStatusMsg struct {
+	ID     string // tool_use_id
 	Name   string
 	Status string
 	Input  map[string]any
@@ -64,19 +68,29 @@ type ToolStatusMsg struct {
 
 // ToolResultMsg is sent when a tool finishes executing on the server.
 type ToolResultMsg struct {
+	ID      string // tool_use_id
 	Name    string
 	Result  string
 	IsError bool
 }
 
+// TurnReattachedMsg is sent before the events of a turn that kept running
+// in the daemon while no TUI was attached.
+type TurnReattachedMsg struct {
+	SessionID string
+}
+
 // TurnDoneMsg signals that the full agent turn is complete (server-driven).
 type TurnDoneMsg struct {
 	StopReason string
 }
 
-// MCPToolsMsg delivers MCP tool names fetched from the daemon.
-type MCPToolsMsg struct {
-	Names []string
+// ToolsMsg delivers the daemon's tool list. With Open set, the tool picker
+// opens once it arrives.
+type ToolsMsg struct {
+	Tools []daemon.ToolInfo
+	Err   error
+	Open  bool
 }
 
 // CompactedMsg signals that the server compacted the context.
@@ -84,10 +98,25 @@ type CompactedMsg struct {
 	ModelUsed string
 }
 
+// CompactDoneMsg reports the result of /compact.
+type CompactDoneMsg struct {
+	Result daemon.CompactResult
+	Err    error
+}
+
 // AskUserMsg is sent when the agent's ask_user tool needs user input.
 type AskUserMsg struct {
-	Prompt string
-	AskID  string
+	Prompt  string
+	AskID   string
+	Options []string // suggested answers, if any
+}
+
+// ApprovalRequiredMsg is sent when a tool call waits for user approval
+// (tools.approval_mode).
+type ApprovalRequiredMsg struct {
+	ApprovalID string
+	ToolName   string
+	Input      map[string]any
 }
 
 // TitledMsg signals that the session title and tags were generated.
@@ -104,22 +133,47 @@ type RetryingMsg struct {
 	Message string
 }
 
-// GitAvailableMsg reports git repo availability.
-type GitAvailableMsg struct {
-	Available bool
-	RepoRoot  string
+// BudgetWarningMsg signals that spend passed the warning share of a budget.
+type BudgetWarningMsg struct {
+	Message string
+	Key     string
+}
+
+// VerifiedMsg carries the verification pass's check of the final answer.
+type VerifiedMsg struct {
+	Info daemon.VerifyInfo
+}
+
+// DiagramMsg reports a diagram code block rendered to an image file.
+type DiagramMsg struct {
+	Kind string
+	Path string
+	Err  string
+}
+
+// SourcesMsg lists the web sources cited in the reply that just finished.
+type SourcesMsg struct {
+	Sources []domain.Citation
 }
 
 // UndoDoneMsg reports undo completion.
 type UndoDoneMsg struct {
-	RestoredTurn int
-	Err          error
+	Checkpoint *store.Checkpoint
+	Err        error
+}
+
+// UndoPreviewMsg carries the diff /undo --preview shows: what undoing
+// Checkpoint would change in the working tree.
+type UndoPreviewMsg struct {
+	Checkpoint *store.Checkpoint
+	Diff       string
+	Err        error
 }
 
 // RedoDoneMsg reports redo completion.
 type RedoDoneMsg struct {
-	RestoredTurn int
-	Err          error
+	Checkpoint *store.Checkpoint
+	Err        error
 }
 
 // ShellResultMsg carries the result of a shell command execution.
@@ -139,11 +193,12 @@ type ConsultResponseMsg struct {
 	Text  string
 }
 
-// Checkpoint represents a snapshot of the working tree.
-type Checkpoint struct {
-	TurnNumber int
-	SHA        string
-	IsClean    bool
+// FeedbackSentMsg reports the result of rating an assistant reply.
+type FeedbackSentMsg struct {
+	Rating   string
+	Note     string
+	Sequence int
+	Err      error
 }
 
 // ---------------------------------------------------------------------------
@@ -171,6 +226,9 @@ type Model struct {
 	cacheReadInputTokens         int
 	lastCacheCreationInputTokens int
 	lastCacheReadInputTokens     int
+	verifyInputTokens            int // verification pass tokens, kept apart from the session's
+	verifyOutputTokens           int
+	ctxUsage                     contextUsage // size of the last prompt, for the footer gauge
 	messages                     []domain.TranscriptMessage
 	spinner                      spinner.Model
 
@@ -183,17 +241,28 @@ type Model struct {
 	resuming bool
 	titled   bool
 
+	// historyOffset is the index of the oldest persisted message printed
+	// for this session. Anything before it is loaded by /history.
+	historyOffset int
+
 	// Daemon client (TUI communicates via HTTP)
 	Daemon       *daemon.DaemonClient
 	pendingAskID string
 
+	// EmbeddedDaemon is set when Daemon runs inside this process, so turns
+	// stop when the TUI exits and /detach is unavailable.
+	EmbeddedDaemon bool
+	detached       bool // quit with /detach, leaving the turn running
+
 	// Tool status display
 	toolStatus       string
 	turnToolCount    int
 	turnFilesChanged map[string]bool
 	turnStartTime    time.Time
 	turnCurrentTool  string
-	turnLastAction   string // human-readable summary of last completed action
+	turnRunning      []runningTool // tools started but not finished, in start order
+	turnLastAction   string        // human-readable summary of last completed action
+	turnReply        string        // text the agent streamed this turn, checked for [N] citations
 
 	Prefs    config.Preferences
 	Provider provider.Provider
@@ -202,21 +271,39 @@ type Model struct {
 	// Ask-user state: agent paused waiting for input
 	pendingAsk bool
 
+	// Approval state: agent paused until the user answers y/n/a for a
+	// tool call.
+	pendingApprovalID   string
+	pendingApprovalTool string
+
+	// Ollama state: a model offered for pulling (y/n), and the pull in
+	// progress with its latest update.
+	pendingPull    string
+	ollamaPull     string
+	ollamaProgress provider.OllamaProgress
+
+	// optimizeReport is the last /optimize report, whose numbered
+	// suggestions /optimize apply refers to.
+	optimizeReport *daemon.OptimizeReport
+
+	// planSessionID is the session the user turned plan mode on for
+	// (see /plan). Tracking the ID keeps the footer right across switches.
+	planSessionID string
+
 	// Autocomplete state
 	completions   []string
 	completionIdx int
 	completionOn  bool
 
-	// Checkpoint/undo state
-	gitAvailable bool
-	gitRepoRoot  string
-	checkpoints  []Checkpoint
-	redoStack    []Checkpoint
-
 	// Session picker overlay
 	picker *SessionPicker
 	// Node picker overlay (hub connections)
 	nodePicker *NodePicker
+	// Message picker overlay for /fork
+	messagePicker *MessagePicker
+	messageNav    *MessageNav
+	// Job picker overlay for /jobs
+	jobPicker *JobPicker
 	// Tool picker overlay
 	toolPicker *ToolPicker
 	// Config picker overlay
@@ -224,25 +311,47 @@ type Model struct {
 	// Emoji picker overlay
 	emojiPicker *EmojiPicker
 
-	// MCP tool names (fetched from daemon at startup)
-	mcpToolNames []string
+	// Tools the daemon will execute, with their enabled state (fetched from
+	// the daemon at startup and whenever the tool picker opens)
+	daemonTools []daemon.ToolInfo
 
 	// Hub connection state (non-empty when connected via --remote to a hub)
-	hubBaseURL string
-	hubToken   string
+	hubBaseURL     string
+	hubFallbackURL string
+	hubToken       string
 
 	// Rendered message blocks displayed in the View (replaces Prog.Println scrollback)
 	viewLines []string
 
+	// pager holds the transcript in viewport mode; nil when output goes to
+	// the terminal scrollback.
+	pager *TranscriptPager
+
 	// Runtime diagnostics log path (best effort, may be empty).
 	runtimeLogPath string
 
-	// Paste detection: rapid keystrokes (< 5ms apart) indicate pasted text.
+	// Paste detection: rapid keystrokes (< 5ms apart) indicate pasted text,
+	// unless the terminal has shown it delivers bracketed pastes.
 	lastKeypressTime time.Time
+	bracketedPaste   bool
+
+	// Modal editing state when input.keymap is vim.
+	vim vimState
+
+	// Files queued with /attach or drag and drop for the next message.
+	attachments []string
+
+	// Messages submitted while a turn was running, sent when it ends.
+	queued   []queuedMessage
+	steering []string // /steer notes the agent has not seen yet
+	stopping bool     // Esc asked the daemon to stop the turn after this step
+
+	// spawn_agent workers of the running tool call, shown under the spinner.
+	subAgents []daemon.SubAgentInfo
 
 	// Last submit payload for session-recovery retry.
-	lastSubmitText   string
-	lastSubmitImages []daemon.SubmitImage
+	lastSubmitText        string
+	lastSubmitAttachments []daemon.SubmitAttachment
 
 	// Shell mode: interactive shell session
 	shellActive      bool
@@ -274,6 +383,9 @@ func InitialModel(d *daemon.DaemonClient, version, modelLabel, modelID string, s
 		APIKey:         apiKey,
 		runtimeLogPath: defaultRuntimeLogPath(),
 	}
+	if viewportMode {
+		m.pager = NewTranscriptPager()
+	}
 	if session != nil {
 		m.inputTokens = session.InputTokens
 		m.outputTokens = session.OutputTokens
@@ -285,20 +397,29 @@ func InitialModel(d *daemon.DaemonClient, version, modelLabel, modelID string, s
 	return m
 }
 
+// Detached reports whether the TUI quit with /detach, leaving its turn
+// running in the daemon.
+func (m Model) Detached() bool {
+	return m.detached
+}
+
 // SetHubConnection configures the model for hub mode, enabling the node
-// picker on startup. Call this before passing the model to tea.NewProgram.
-func (m *Model) SetHubConnection(baseURL, token string) {
+// picker on startup. fallbackURL, if set, is a second hub the nodes are
+// listed from when baseURL does not answer. Call this before passing the
+// model to tea.NewProgram.
+func (m *Model) SetHubConnection(baseURL, fallbackURL, token string) {
 	m.hubBaseURL = baseURL
+	m.hubFallbackURL = fallbackURL
 	m.hubToken = token
 	m.viewLines = []string{WelcomeStyle.Render("Connecting to hub...")}
 }
 
 // Init initializes the Bubble Tea model.
 func (m Model) Init() tea.Cmd {
-	cmds := []tea.Cmd{m.spinner.Tick, CheckGitRepo()}
+	cmds := []tea.Cmd{m.spinner.Tick}
 
 	if m.resuming {
-		cmds = append(cmds, m.loadSessionHistory())
+		cmds = append(cmds, m.resumeSession())
 	}
 
 	// If connected to a hub without a session, fetch nodes on startup.
@@ -306,52 +427,179 @@ func (m Model) Init() tea.Cmd {
 		cmds = append(cmds, m.openNodePicker())
 	}
 
-	// Fetch MCP tool names from daemon in background.
+	// Fetch the daemon's tools in background.
 	if m.Daemon != nil && m.Session != nil {
-		d := m.Daemon
-		cmds = append(cmds, func() tea.Msg {
-			resp, err := d.GetMCPTools()
-			if err != nil || len(resp.Tools) == 0 {
-				return MCPToolsMsg{}
-			}
-			return MCPToolsMsg{Names: resp.Tools}
-		})
+		cmds = append(cmds, fetchTools(m.Daemon, false))
 	}
 
 	return tea.Batch(cmds...)
 }
 
-// loadSessionHistory replays persisted messages into the view buffer.
+// resumeSession replays the session's history, then reattaches to its turn
+// if one is still running in the daemon, or offers to re-run one the daemon
+// was cut off in.
+func (m Model) resumeSession() tea.Cmd {
+	return tea.Sequence(m.loadSessionHistory(), ReattachViaDaemon(m.Daemon, m.Session.ID), m.checkInterruptedTurn())
+}
+
+// interruptedTurnMsg carries the prompt of a turn the daemon was cut off
+// in, read fresh since the repair happens when the daemon starts.
+type interruptedTurnMsg struct {
+	prompt string
+}
+
+// checkInterruptedTurn looks up whether the session's last turn was
+// interrupted by the daemon stopping.
+func (m Model) checkInterruptedTurn() tea.Cmd {
+	d, st, sessionID := m.Daemon, m.Store, m.Session.ID
+	return func() tea.Msg {
+		var sess *domain.Session
+		var err error
+		switch {
+		case d != nil:
+			sess, err = d.GetSession(sessionID)
+		case st != nil:
+			sess, err = st.GetSession(sessionID)
+		default:
+			return nil
+		}
+		if err != nil || sess.InterruptedPrompt == "" {
+			return nil
+		}
+		return interruptedTurnMsg{prompt: sess.InterruptedPrompt}
+	}
+}
+
+// handleInterruptedTurn puts an interrupted prompt back in an empty input so
+// Enter runs it again.
+func (m Model) handleInterruptedTurn(msg interruptedTurnMsg) (tea.Model, tea.Cmd) {
+	if m.thinking || m.input != "" {
+		return m, PrintToScrollback(WelcomeStyle.Render("The last turn was cut off when muxd stopped."))
+	}
+	m.setInput(msg.prompt)
+	return m, PrintToScrollback(WelcomeStyle.Render("The last turn was cut off when muxd stopped. Its prompt is back in the input: press Enter to run it again."))
+}
+
+// historyPageSize is how many persisted messages are replayed at once when
+// resuming a session or paging back with /history.
+const historyPageSize = 200
+
+// loadSessionHistory replays the most recent page of persisted messages
+// into the view buffer.
 func (m Model) loadSessionHistory() tea.Cmd {
-	sessionID := m.Session.ID
+	sessionID, title := m.Session.ID, m.Session.Title
+	page := m.messagesPage()
 	st := m.Store
 	return func() tea.Msg {
-		msgs, err := st.GetMessages(sessionID)
+		// Read a single message first to learn the total.
+		_, total, err := page(sessionID, 0, 1)
+		offset := max(0, total-historyPageSize)
+		var msgs []domain.TranscriptMessage
+		if err == nil && total > 0 {
+			msgs, _, err = page(sessionID, offset, historyPageSize)
+		}
 		if err != nil || len(msgs) == 0 {
 			return BatchViewMsg{Lines: []string{
 				WelcomeStyle.Render("Welcome to muxd. One prompt away from wizardry."),
 			}}
 		}
 
-		var lines []string
-		lines = append(lines, WelcomeStyle.Render(fmt.Sprintf("  Resumed: %s  (%d messages)", st.SessionTitle(sessionID), len(msgs))))
+		if st != nil {
+			title = st.SessionTitle(sessionID)
+		}
+		header := fmt.Sprintf("  Resumed: %s  (%d messages)", title, total)
+		if offset > 0 {
+			header += fmt.Sprintf("  showing the last %d, /history for earlier", len(msgs))
+		}
+		return historyBatchMsg{render: historyPage(header, msgs), offset: offset}
+	}
+}
+
+// loadOlderHistory prints the page of messages before the oldest one shown.
+// The terminal scrollback is append-only, so the page appears below the
+// current output under a header giving its position; in viewport mode it is
+// inserted above the transcript instead.
+func (m Model) loadOlderHistory() tea.Cmd {
+	sessionID := m.Session.ID
+	page := m.messagesPage()
+	end := m.historyOffset
+	return func() tea.Msg {
+		start := max(0, end-historyPageSize)
+		msgs, _, err := page(sessionID, start, end-start)
+		if err != nil {
+			return BatchViewMsg{Lines: []string{m.renderError("Error loading history: " + err.Error())}}
+		}
+		header := fmt.Sprintf("  Earlier messages %d-%d", start+1, end)
+		if start > 0 {
+			header += "  (/history for more)"
+		}
+		return olderHistoryMsg{render: historyPage(header, msgs), offset: start}
+	}
+}
 
-		width := 80
+// messagesPage returns a func that reads a page of a session's messages
+// and its total message count, from the daemon when connected so remote
+// sessions replay too, otherwise from the local store.
+func (m Model) messagesPage() func(sessionID string, offset, limit int) ([]domain.TranscriptMessage, int, error) {
+	if d := m.Daemon; d != nil {
+		return d.GetMessagesPage
+	}
+	st := m.Store
+	return func(sessionID string, offset, limit int) ([]domain.TranscriptMessage, int, error) {
+		if st == nil {
+			return nil, 0, fmt.Errorf("no session store available")
+		}
+		total, err := st.CountMessages(sessionID)
+		if err != nil {
+			return nil, 0, err
+		}
+		msgs, err := st.GetMessagesPage(sessionID, offset, limit)
+		return msgs, total, err
+	}
+}
+
+// sessionMessages reads all of the current session's messages, from the
+// daemon when connected, otherwise from the local store.
+func (m Model) sessionMessages() ([]domain.TranscriptMessage, error) {
+	if m.Daemon != nil {
+		return m.Daemon.GetMessages(m.Session.ID)
+	}
+	if m.Store != nil {
+		return m.Store.GetMessages(m.Session.ID)
+	}
+	return nil, fmt.Errorf("no session store available")
+}
+
+// historyWidth is the width replayed history is rendered at outside
+// viewport mode, where it may be printed before the terminal size is known.
+const historyWidth = 80
+
+// historyPage returns a render func for a page of replayed messages under
+// header.
+func historyPage(header string, msgs []domain.TranscriptMessage) func(int) string {
+	return func(width int) string {
+		lines := []string{WelcomeStyle.Render(header)}
 		for _, msg := range msgs {
 			if msg.Role == "system" {
 				continue
 			}
 			lines = append(lines, FormatBlockMessage(msg, width))
 		}
-
-		return historyBatchMsg{lines: lines}
+		return strings.Join(lines, "\n\n")
 	}
 }
 
-// historyBatchMsg is an internal message that carries both view lines and
-// signals that history loading is complete.
+// historyBatchMsg is an internal message that carries a page of replayed
+// history and signals that history loading is complete.
 type historyBatchMsg struct {
-	lines []string
+	render func(width int) string
+	offset int // index of the first message in the page
+}
+
+// olderHistoryMsg carries a page loaded by /history.
+type olderHistoryMsg struct {
+	render func(width int) string
+	offset int
 }
 
 // Update handles Bubble Tea messages.
@@ -361,6 +609,15 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
+		if m.pager != nil {
+			m.pager.SetWidth(msg.Width)
+		}
+		return m, nil
+
+	case PagerAppendMsg:
+		if m.pager != nil {
+			m.pager.Append(msg.Render)
+		}
 		return m, nil
 
 	case tea.KeyMsg:
@@ -393,23 +650,57 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case TurnDoneMsg:
 		return m.handleTurnDone(msg)
 
+	case TurnReattachedMsg:
+		return m.handleTurnReattached(msg)
+
 	case CompactedMsg:
+		// The next stream_done reports the smaller prompt.
+		m.ctxUsage = contextUsage{}
 		return m, nil
 
-	case historyBatchMsg:
-		var historyCmd tea.Cmd
-		if len(msg.lines) > 0 {
-			historyCmd = PrintToScrollback(strings.Join(msg.lines, "\n\n"))
+	case CompactDoneMsg:
+		switch {
+		case msg.Err != nil:
+			return m, PrintToScrollback(m.renderError("Compact failed: " + msg.Err.Error()))
+		case msg.Result.Compacted == 0:
+			return m, PrintToScrollback(FooterMeta.Render("Nothing to compact: the conversation is still short."))
+		}
+		m.ctxUsage = contextUsage{}
+		return m, PrintToScrollback(FooterMeta.Render(fmt.Sprintf("Compacted %d earlier messages into a summary (model: %s). /context shows it.",
+			msg.Result.Compacted, msg.Result.Model)))
+
+	case interruptedTurnMsg:
+		return m.handleInterruptedTurn(msg)
+
+	case olderHistoryMsg:
+		m.historyOffset = msg.offset
+		if m.pager != nil {
+			m.pager.Prepend(msg.render)
+			return m, nil
 		}
+		return m, PrintRendered(historyWidth, msg.render)
+
+	case historyBatchMsg:
+		m.historyOffset = msg.offset
+		historyCmd := PrintRendered(historyWidth, msg.render)
 		next, loadedCmd := m.handleHistoryLoaded()
 		if mm, ok := next.(Model); ok {
 			m = mm
 		}
 		return m, tea.Batch(historyCmd, loadedCmd)
 
-	case MCPToolsMsg:
-		m.mcpToolNames = msg.Names
-		return m, nil
+	case ToolsMsg:
+		if msg.Err == nil {
+			m.daemonTools = msg.Tools
+		}
+		if !msg.Open {
+			return m, nil
+		}
+		cmd := m.openToolPicker()
+		if msg.Err != nil {
+			cmd = tea.Batch(PrintToScrollback(m.renderError("Loading tools from daemon: "+msg.Err.Error())), cmd)
+		}
+		return m, cmd
 
 	case HistoryLoadedMsg:
 		return m.handleHistoryLoaded()
@@ -428,14 +719,38 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case AskUserMsg:
 		return m.handleAskUser(msg)
 
-	case GitAvailableMsg:
-		m.gitAvailable = msg.Available
-		m.gitRepoRoot = msg.RepoRoot
-		return m, nil
+	case ApprovalRequiredMsg:
+		return m.handleApprovalRequired(msg)
+
+	case DiagramMsg:
+		return m.handleDiagram(msg)
+
+	case SourcesMsg:
+		return m, PrintToScrollback(renderSources(msg.Sources))
+
+	case SubAgentMsg:
+		return m.handleSubAgent(msg)
+
+	case VerifiedMsg:
+		return m.handleVerified(msg)
+
+	case SteerMsg:
+		return m.handleSteer(msg)
+
+	case SteeredMsg:
+		return m.handleSteered(msg)
+
+	case BudgetWarningMsg:
+		m.appendRuntimeLog("budget: " + msg.Message)
+		line := BulletStyle.Render(fmt.Sprintf("  Budget warning: %s. Turns stop at the limit; /config set %s raises it.", msg.Message, msg.Key))
+		return m, PrintToScrollback(line)
 
 	case UndoDoneMsg:
 		return m.handleUndoDone(msg)
 
+	case UndoPreviewMsg:
+		return m.handleUndoPreview(msg)
+
 	case RedoDoneMsg:
 		return m.handleRedoDone(msg)
 
@@ -450,6 +765,33 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		formatted := FormatConsultResponse(msg.Model, msg.Text, m.width)
 		return m, PrintToScrollback(formatted)
 
+	case FeedbackSentMsg:
+		return m.handleFeedbackSent(msg)
+
+	case MCPResultMsg:
+		return m.handleMCPResult(msg)
+
+	case OllamaStatusMsg:
+		return m.handleOllamaStatus(msg)
+
+	case OllamaProgressMsg:
+		if msg.Model == m.ollamaPull {
+			m.ollamaProgress = msg.Progress
+		}
+		return m, nil
+
+	case OllamaPulledMsg:
+		return m.handleOllamaPulled(msg)
+
+	case OllamaRemovedMsg:
+		return m.handleOllamaRemoved(msg)
+
+	case NotifyTestMsg:
+		return m.handleNotifyTest(msg)
+
+	case AdapterMsg:
+		return m.handleAdapter(msg)
+
 	case spinner.TickMsg:
 		if m.thinking {
 			var cmd tea.Cmd
@@ -489,6 +831,48 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.nodePicker = NewNodePicker(msg.Nodes)
 		return m, nil
 
+	case MessagePickerMsg:
+		if msg.Err != nil {
+			return m, PrintToScrollback(m.renderError("Failed to load messages: " + msg.Err.Error()))
+		}
+		picker := NewMessagePicker(msg.Messages)
+		if len(picker.items) == 0 {
+			return m, PrintToScrollback(FooterMeta.Render("No messages to fork from yet."))
+		}
+		m.messagePicker = picker
+		return m, nil
+
+	case MessageNavMsg:
+		if msg.Err != nil {
+			return m, PrintToScrollback(m.renderError("Failed to load messages: " + msg.Err.Error()))
+		}
+		nav := NewMessageNav(msg.Messages, msg.Notes)
+		if nav.Len() == 0 {
+			m.messageNav = nil
+			return m, PrintToScrollback(FooterMeta.Render("No messages yet."))
+		}
+		if msg.Select > 0 {
+			nav.Select(msg.Select)
+		}
+		m.messageNav = nav
+		return m, nil
+
+	case MessageEditedMsg:
+		return m.handleMessageEdited(msg)
+
+	case JobsMsg:
+		if msg.Err != nil {
+			return m, PrintToScrollback(m.renderError("Failed to load jobs: " + msg.Err.Error()))
+		}
+		if len(msg.Jobs) == 0 {
+			return m, PrintToScrollback(FooterMeta.Render("No jobs yet. Queue one with /jobs run <prompt>."))
+		}
+		m.jobPicker = NewJobPicker(msg.Jobs)
+		return m, nil
+
+	case JobMsg:
+		return m.handleJob(msg)
+
 	case BranchDoneMsg:
 		return m.handleBranchDone(msg)
 
@@ -506,6 +890,18 @@ func (m Model) View() string {
 		b.WriteString(m.nodePicker.View(m.width))
 		return b.String()
 	}
+	if m.messagePicker.IsActive() {
+		b.WriteString(m.messagePicker.View(m.width))
+		return b.String()
+	}
+	if m.messageNav.IsActive() {
+		b.WriteString(m.messageNav.View(m.width))
+		return b.String()
+	}
+	if m.jobPicker.IsActive() {
+		b.WriteString(m.jobPicker.View(m.width))
+		return b.String()
+	}
 	// Render session picker overlay if active
 	if m.picker.IsActive() {
 		b.WriteString(m.picker.View(m.width))
@@ -524,6 +920,18 @@ func (m Model) View() string {
 		return b.String()
 	}
 
+	active := m.activeView()
+	if m.pager != nil {
+		return m.pager.View(m.height-lipgloss.Height(active)) + "\n" + active
+	}
+	return active
+}
+
+// activeView renders the input area, status, and footer below the
+// transcript.
+func (m Model) activeView() string {
+	var b strings.Builder
+
 	// Calculate available width for text wrapping
 	promptWidth := 2
 	availWidth := m.width - promptWidth
@@ -578,19 +986,35 @@ func (m Model) View() string {
 	if m.pendingAsk {
 		b.WriteString(ThinkingStyle.Render("Agent is waiting for your response...") + "\n\n")
 	}
+	if m.pendingPull != "" {
+		b.WriteString(ThinkingStyle.Render("Pull "+m.pendingPull+" from Ollama? (y/n)") + "\n\n")
+	}
+	if m.ollamaPull != "" {
+		b.WriteString(ThinkingStyle.Render(m.ollamaPullStatus()) + "\n\n")
+	}
 
 	if m.thinking {
-		b.WriteString(ThinkingStyle.Render(m.spinner.View()+" "+m.buildActivityStatus()) + "\n\n")
+		b.WriteString(ThinkingStyle.Render(m.spinner.View()+" "+m.buildActivityStatus()) + "\n")
+		if len(m.subAgents) > 0 {
+			b.WriteString(FooterMeta.Render(strings.TrimSuffix(renderSubAgents(m.subAgents), "\n")) + "\n")
+		}
+		b.WriteString("\n")
 	}
+	b.WriteString(m.renderSteering(availWidth))
+	b.WriteString(m.renderQueued(availWidth))
 
 	// Multi-line input with inline cursor and visual line wrapping.
 	inputLines := strings.Split(withInlineCursor(m.input, m.inputCursor), "\n")
+	prompt := "\u276f "
+	if m.normalMode() {
+		prompt = "\u276e " // vim normal mode
+	}
 	first := true
 	for _, line := range inputLines {
 		wrapped := hardWrapLine(line, availWidth)
 		for _, wl := range wrapped {
 			if first {
-				b.WriteString(PromptStyle.Render("\u276f ") + InputStyle.Render(wl))
+				b.WriteString(PromptStyle.Render(prompt) + InputStyle.Render(wl))
 				first = false
 			} else {
 				b.WriteString("\n" + PromptStyle.Render("  ") + InputStyle.Render(wl))
@@ -618,6 +1042,9 @@ func (m Model) View() string {
 	if disabledCount > 0 {
 		footerParts = append(footerParts, fmt.Sprintf("tools off: %d", disabledCount))
 	}
+	if m.inPlanMode() {
+		footerParts = append(footerParts, "plan mode")
+	}
 	b.WriteString(FooterHead.Render(strings.Join(footerParts, " \u00b7 ")))
 	if m.Prefs.FooterTokens {
 		b.WriteString("\n")
@@ -656,6 +1083,12 @@ func (m Model) View() string {
 		}
 		b.WriteString(FooterTokens.Render(tokenStr))
 	}
+	if m.Prefs.FooterContext {
+		if gauge := renderContextGauge(m.ctxUsage, indent); gauge != "" {
+			b.WriteString("\n")
+			b.WriteString(gauge)
+		}
+	}
 	if m.Prefs.FooterCwd {
 		b.WriteString("\n")
 		b.WriteString(FooterMeta.Render(fmt.Sprintf("%scwd: %s", indent, MustGetwd())))
@@ -682,6 +1115,15 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	if m.nodePicker.IsActive() {
 		return m.handleNodePickerKey(msg)
 	}
+	if m.messagePicker.IsActive() {
+		return m.handleMessagePickerKey(msg)
+	}
+	if m.messageNav.IsActive() {
+		return m.handleMessageNavKey(msg)
+	}
+	if m.jobPicker.IsActive() {
+		return m.handleJobPickerKey(msg)
+	}
 	// Route to picker when active.
 	if m.picker.IsActive() {
 		return m.handlePickerKey(msg)
@@ -696,11 +1138,27 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleEmojiPickerKey(msg)
 	}
 
+	// A pending tool approval takes single-key answers.
+	if m.pendingApprovalID != "" {
+		return m.handleApprovalKey(msg)
+	}
+	if m.pendingPull != "" {
+		return m.handleOllamaPullKey(msg)
+	}
+
 	// Route to shell mode when active.
 	if m.shellActive {
 		return m.handleShellKey(msg)
 	}
 
+	if m.pager != nil && m.pager.HandleKey(msg, m.input == "") {
+		return m, nil
+	}
+
+	if m.handleVimKey(msg) {
+		return m, nil
+	}
+
 	switch msg.Type {
 	case tea.KeyCtrlC:
 		if m.completionOn {
@@ -717,13 +1175,14 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, PrintToScrollback(WelcomeStyle.Render("Agent loop canceled."))
 		}
 		if m.thinking {
+			m.stopping = false
 			m.thinking = false
 			m.streaming = false
 			m.toolStatus = ""
 			if m.Daemon != nil {
 				go func() { _ = m.Daemon.Cancel(m.Session.ID) }()
 			}
-			return m, PrintToScrollback(WelcomeStyle.Render("Agent loop canceled."))
+			return m, PrintToScrollback(WelcomeStyle.Render("Agent loop canceled." + m.returnQueued()))
 		}
 		return m, tea.Quit
 
@@ -741,24 +1200,31 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			}
 			return m, PrintToScrollback(WelcomeStyle.Render("Agent loop canceled."))
 		}
+		// The first Esc stops the turn after the current step and keeps
+		// its work; a second one aborts it.
+		if m.thinking && !m.stopping && m.Daemon != nil && m.Session != nil {
+			m.stopping = true
+			d, sessionID := m.Daemon, m.Session.ID
+			go func() { _ = d.Stop(sessionID) }()
+			m.appendRuntimeLog("stop requested")
+			return m, nil
+		}
 		if m.thinking {
+			m.stopping = false
 			m.thinking = false
 			m.streaming = false
 			m.toolStatus = ""
 			if m.Daemon != nil {
 				go func() { _ = m.Daemon.Cancel(m.Session.ID) }()
 			}
-			return m, PrintToScrollback(WelcomeStyle.Render("Agent loop canceled."))
+			return m, PrintToScrollback(WelcomeStyle.Render("Agent loop canceled." + m.returnQueued()))
 		}
 		return m, tea.Quit
 
 	case tea.KeyTab:
-		if m.thinking {
-			return m, nil
-		}
-		if strings.HasPrefix(m.input, "/") {
+		if strings.HasPrefix(m.input, "/") || IsPathCompletion(m.input) {
 			if !m.completionOn {
-				m.completions = ComputeCompletions(m.input, nil)
+				m.completions = ComputeCompletionsFrom(m.input, m.completionSources())
 				if len(m.completions) > 0 {
 					m.completionOn = true
 					m.completionIdx = 0
@@ -772,9 +1238,6 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyShiftTab:
-		if m.thinking {
-			return m, nil
-		}
 		if m.completionOn && len(m.completions) > 0 {
 			m.completionIdx = (m.completionIdx - 1 + len(m.completions)) % len(m.completions)
 			m.setInput(m.completions[m.completionIdx])
@@ -782,21 +1245,15 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case tea.KeyCtrlJ:
-		if !m.thinking {
-			m.dismissCompletions()
-			m.insertInputAtCursor("\n")
-			m.resetHistory()
-		}
+		m.dismissCompletions()
+		m.insertInputAtCursor("\n")
+		m.resetHistory()
 		return m, nil
 
 	case tea.KeyEnter:
-		if m.thinking {
-			return m, nil
-		}
-		// Paste detection: bracketed paste flag OR rapid keystrokes (< 5ms)
-		// indicate pasted text -treat Enter as a literal newline, not submit.
+		// Pasted newlines become literal newlines, not submit.
 		now := time.Now()
-		isPaste := msg.Paste || (!m.lastKeypressTime.IsZero() && now.Sub(m.lastKeypressTime) < 5*time.Millisecond)
+		isPaste := m.looksLikePaste(msg, now)
 		m.lastKeypressTime = now
 		if isPaste {
 			m.insertInputAtCursor("\n")
@@ -815,15 +1272,23 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.setInput("")
 			m.thinking = true
 			m.toolStatus = "Thinking..."
-			formatted := FormatMessageForScrollback(domain.TranscriptMessage{Role: "user", Content: answer}, m.width)
+			answerMsg := domain.TranscriptMessage{Role: "user", Content: answer}
 			return m, tea.Batch(
-				PrintToScrollback(formatted),
+				PrintRendered(m.width, func(width int) string { return FormatMessageForScrollback(answerMsg, width) }),
 				SendAskResponseCmd(m.Daemon, sessionID, askID, answer),
 			)
 		}
 		if m.completionOn {
 			selected := m.input
 			m.dismissCompletions()
+			if IsPathCompletion(selected) {
+				// Accept the path and keep composing the message.
+				if !strings.HasSuffix(selected, "/") {
+					selected += " "
+				}
+				m.setInput(selected)
+				return m, nil
+			}
 			if CommandExpectsArgs(selected) {
 				m.setInput(selected + " ")
 				return m, nil
@@ -831,87 +1296,74 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.setInput(selected)
 		}
 		trimmed := strings.TrimSpace(m.input)
-		if trimmed == "" {
+		if trimmed == "" && len(m.attachments) == 0 {
 			m.setInput("")
 			return m, nil
 		}
+		if m.thinking && isSteerCommand(trimmed) {
+			return m.submit(trimmed)
+		}
+		if m.thinking || len(m.queued) > 0 {
+			m.queueInput(trimmed)
+			return m, nil
+		}
 		return m.submit(trimmed)
 
 	case tea.KeyUp:
-		if !m.thinking {
-			m.dismissCompletions()
+		m.dismissCompletions()
+		if !m.unqueueLast() {
 			m.browseHistoryBack()
 		}
 		return m, nil
 
 	case tea.KeyDown:
-		if !m.thinking {
-			m.dismissCompletions()
-			m.browseHistoryForward()
-		}
+		m.dismissCompletions()
+		m.browseHistoryForward()
 		return m, nil
 
 	case tea.KeyLeft:
-		if !m.thinking {
-			m.moveInputCursor(-1)
-		}
+		m.moveInputCursor(-1)
 		return m, nil
 
 	case tea.KeyRight:
-		if !m.thinking {
-			m.moveInputCursor(1)
-		}
+		m.moveInputCursor(1)
 		return m, nil
 
 	case tea.KeyHome, tea.KeyCtrlA:
-		if !m.thinking {
-			m.moveInputCursorToStart()
-		}
+		m.moveInputCursorToStart()
 		return m, nil
 
 	case tea.KeyEnd, tea.KeyCtrlE:
-		if !m.thinking {
-			m.moveInputCursorToEnd()
-		}
+		m.moveInputCursorToEnd()
 		return m, nil
 
 	case tea.KeyBackspace:
-		if !m.thinking {
-			m.dismissCompletions()
-			if m.deleteInputBeforeCursor() {
-				m.resetHistory()
-			}
+		m.dismissCompletions()
+		if m.deleteInputBeforeCursor() {
+			m.resetHistory()
 		}
 		return m, nil
 
 	case tea.KeyDelete:
-		if !m.thinking {
-			m.dismissCompletions()
-			if m.deleteInputAtCursor() {
-				m.resetHistory()
-			}
+		m.dismissCompletions()
+		if m.deleteInputAtCursor() {
+			m.resetHistory()
 		}
 		return m, nil
 
 	case tea.KeyCtrlV:
-		if m.thinking {
-			return m, nil
-		}
 		m.dismissCompletions()
-		return m, ReadClipboardCmd()
+		return m, ReadClipboardCmd(m.Prefs.Clipboard)
 
 	case tea.KeyInsert:
-		if m.thinking {
-			return m, nil
-		}
 		m.dismissCompletions()
-		return m, ReadClipboardCmd()
+		return m, ReadClipboardCmd(m.Prefs.Clipboard)
 
 	case tea.KeyCtrlY:
-		return m, WriteClipboardCmd(m.lastAssistantMessage())
+		return m, WriteClipboardCmd(m.Prefs.Clipboard, m.lastAssistantMessage())
 
 	case tea.KeyCtrlK:
-		return m, WriteClipboardCmd(m.plainTranscript())
+		return m, WriteClipboardCmd(m.Prefs.Clipboard, m.plainTranscript())
 
 	case tea.KeyCtrlR:
 		if !m.thinking {
@@ -919,27 +1371,43 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
-	case tea.KeySpace:
+	case tea.KeyCtrlO:
+		m.dismissCompletions()
+		return m, m.openMessageNav(0)
+
+	case tea.KeyCtrlG:
 		if !m.thinking {
-			m.dismissCompletions()
-			m.insertInputAtCursor(" ")
-			m.resetHistory()
-			m.lastKeypressTime = time.Now()
+			return m, m.sendFeedback("good", "")
 		}
 		return m, nil
 
-	default:
+	case tea.KeyCtrlB:
 		if !m.thinking {
-			m.dismissCompletions()
-			if msg.Paste && len(msg.Runes) > 0 {
-				m.insertInputAtCursor(filterNulls(msg.Runes))
-				m.resetHistory()
-				m.lastKeypressTime = time.Now()
-			} else if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
-				m.insertInputAtCursor(filterNulls(msg.Runes))
-				m.resetHistory()
-				m.lastKeypressTime = time.Now()
+			return m, m.sendFeedback("bad", "")
+		}
+		return m, nil
+
+	case tea.KeySpace:
+		m.dismissCompletions()
+		m.insertInputAtCursor(" ")
+		m.resetHistory()
+		m.lastKeypressTime = time.Now()
+		return m, nil
+
+	default:
+		m.dismissCompletions()
+		if msg.Paste && len(msg.Runes) > 0 {
+			m.bracketedPaste = true
+			m.lastKeypressTime = time.Now()
+			text := filterNulls(msg.Runes)
+			if paths := droppedPaths(text); paths != nil {
+				return m.attach(paths)
 			}
+			m.insertPaste(text)
+		} else if msg.Type == tea.KeyRunes && len(msg.Runes) > 0 {
+			m.insertInputAtCursor(filterNulls(msg.Runes))
+			m.resetHistory()
+			m.lastKeypressTime = time.Now()
 		}
 		return m, nil
 	}
@@ -951,7 +1419,72 @@ func (m *Model) dismissCompletions() {
 	m.completionIdx = 0
 }
 
+// completionSources looks up session and scheduled job IDs on demand for
+// argument completion.
+func (m Model) completionSources() CompletionSources {
+	daemon, st := m.Daemon, m.Store
+	return CompletionSources{
+		Cwd: MustGetwd(),
+		SessionIDs: func() []string {
+			var sessions []domain.Session
+			if daemon != nil {
+				sessions, _ = daemon.ListSessions("", 100)
+			} else if st != nil {
+				sessions, _ = st.ListSessions("", 100)
+			}
+			ids := make([]string, 0, len(sessions))
+			for _, s := range sessions {
+				if len(s.ID) >= 8 {
+					ids = append(ids, s.ID[:8])
+				}
+			}
+			return ids
+		},
+		JobIDs: func() []string {
+			if daemon == nil {
+				return nil
+			}
+			jobs, _ := daemon.ListScheduledJobs()
+			ids := make([]string, 0, len(jobs))
+			for _, j := range jobs {
+				ids = append(ids, j.ID)
+			}
+			return ids
+		},
+	}
+}
+
 // filterNulls removes null bytes from runes before appending to input.
+// pasteKeystrokeGap is the keystroke spacing below which input is assumed
+// to be pasted by a terminal without bracketed paste.
+const pasteKeystrokeGap = 5 * time.Millisecond
+
+// looksLikePaste reports whether an Enter key at now is part of pasted
+// text. Bracketed pastes say so; once the terminal has sent one, keystroke
+// timing is no longer trusted, so fast typists never lose a submit.
+func (m Model) looksLikePaste(msg tea.KeyMsg, now time.Time) bool {
+	if msg.Paste {
+		return true
+	}
+	if m.bracketedPaste || m.lastKeypressTime.IsZero() {
+		return false
+	}
+	return now.Sub(m.lastKeypressTime) < pasteKeystrokeGap
+}
+
+// insertPaste inserts pasted text at the cursor with line endings
+// normalized and trailing newlines dropped.
+func (m *Model) insertPaste(text string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.TrimRight(text, "\n")
+	if text == "" {
+		return
+	}
+	m.insertInputAtCursor(text)
+	m.resetHistory()
+}
+
 func filterNulls(runes []rune) string {
 	clean := make([]rune, 0, len(runes))
 	for _, r := range runes {
@@ -974,6 +1507,43 @@ type NodePickerMsg struct {
 	Err   error
 }
 
+// MessagePickerMsg carries a session's transcript for the /fork picker.
+type MessagePickerMsg struct {
+	Messages []domain.TranscriptMessage
+	Err      error
+}
+
+// MessageNavMsg carries a session's transcript and annotations for the
+// message navigator. Select is the sequence to put the cursor on.
+type MessageNavMsg struct {
+	Messages []domain.TranscriptMessage
+	Notes    map[int]string
+	Select   int
+	Err      error
+}
+
+// MessageEditedMsg signals that a message was annotated or deleted.
+type MessageEditedMsg struct {
+	Action   string // "annotate" or "delete"
+	Sequence int
+	Note     string
+	Err      error
+}
+
+// JobsMsg carries the daemon's jobs for the /jobs picker.
+type JobsMsg struct {
+	Jobs []store.Job
+	Err  error
+}
+
+// JobMsg reports a job that was queued, fetched for display, or cancelled.
+type JobMsg struct {
+	Action string // "run", "show", or "cancel"
+	Job    store.Job
+	Status string // cancel only: "cancelled" or "cancelling"
+	Err    error
+}
+
 // BranchDoneMsg signals that a session branch completed.
 type BranchDoneMsg struct {
 	Session *domain.Session
@@ -1126,150 +1696,6 @@ func hardWrapLine(line string, width int) []string {
 	return lines
 }
 
-// CheckGitRepo detects whether the cwd is inside a git repo at startup.
-func CheckGitRepo() tea.Cmd {
-	return func() tea.Msg {
-		root, ok := DetectGitRepo()
-		return GitAvailableMsg{Available: ok, RepoRoot: root}
-	}
-}
-
-// DetectGitRepo returns the repo root if the cwd is inside a git repo.
-func DetectGitRepo() (string, bool) {
-	root, err := GitRun("rev-parse", "--show-toplevel")
-	if err != nil {
-		return "", false
-	}
-	return root, true
-}
-
-// GitRun executes a git command and returns trimmed stdout.
-func GitRun(args ...string) (string, error) {
-	return gitRun(args...)
-}
-
-// ---------------------------------------------------------------------------
-// Git helpers
-// ---------------------------------------------------------------------------
-
-func gitRun(args ...string) (string, error) {
-	c := exec.Command("git", args...)
-	out, err := c.CombinedOutput()
-	if err != nil {
-		return strings.TrimSpace(string(out)), fmt.Errorf("git %s: %s: %w", args[0], strings.TrimSpace(string(out)), err)
-	}
-	return strings.TrimSpace(string(out)), nil
-}
-
-// ---------------------------------------------------------------------------
-// Checkpoint / Undo / Redo (Tea.Cmd wrappers)
-// ---------------------------------------------------------------------------
-
-// RestoreCheckpoint undoes the current working tree state back to the given checkpoint.
-func RestoreCheckpoint(cp Checkpoint, sessionPrefix string) tea.Cmd {
-	return func() tea.Msg {
-		// Save current state for redo
-		redoSHA, err := gitStashCreate()
-		if err != nil {
-			return UndoDoneMsg{Err: fmt.Errorf("saving redo state: %w", err)}
-		}
-		if redoSHA != "" {
-			ref := fmt.Sprintf("refs/muxd/%s/redo-%d", sessionPrefix, cp.TurnNumber)
-			if refErr := gitUpdateRef(ref, redoSHA); refErr != nil {
-				return UndoDoneMsg{Err: fmt.Errorf("anchoring redo ref: %w", refErr)}
-			}
-		}
-
-		if err := gitRestoreClean(); err != nil {
-			return UndoDoneMsg{Err: fmt.Errorf("resetting working tree: %w", err)}
-		}
-
-		if !cp.IsClean && cp.SHA != "" {
-			if err := gitStashApply(cp.SHA); err != nil {
-				return UndoDoneMsg{Err: fmt.Errorf("restoring checkpoint: %w", err)}
-			}
-		}
-
-		return UndoDoneMsg{RestoredTurn: cp.TurnNumber}
-	}
-}
-
-// RestoreForRedo re-applies a previously undone state.
-func RestoreForRedo(cp Checkpoint, sessionPrefix string) tea.Cmd {
-	return func() tea.Msg {
-		if err := gitRestoreClean(); err != nil {
-			return RedoDoneMsg{Err: fmt.Errorf("resetting working tree: %w", err)}
-		}
-
-		redoRef := fmt.Sprintf("refs/muxd/%s/redo-%d", sessionPrefix, cp.TurnNumber)
-		redoSHA, err := gitRun("rev-parse", redoRef)
-		if err != nil {
-			return RedoDoneMsg{Err: fmt.Errorf("finding redo ref: %w", err)}
-		}
-
-		if err := gitStashApply(redoSHA); err != nil {
-			return RedoDoneMsg{Err: fmt.Errorf("applying redo state: %w", err)}
-		}
-
-		return RedoDoneMsg{RestoredTurn: cp.TurnNumber}
-	}
-}
-
-// CleanupCheckpointRefs removes all refs/muxd/<prefix>/* refs for a session.
-func CleanupCheckpointRefs(prefix string) tea.Cmd {
-	return func() tea.Msg {
-		refPrefix := fmt.Sprintf("refs/muxd/%s/", prefix)
-		out, err := gitRun("for-each-ref", "--format=%(refname)", refPrefix)
-		if err != nil || out == "" {
-			return nil
-		}
-		for _, ref := range strings.Split(out, "\n") {
-			ref = strings.TrimSpace(ref)
-			if ref != "" {
-				if err := gitDeleteRef(ref); err != nil {
-					fmt.Fprintf(os.Stderr, "tui: delete git ref %s: %v\n", ref, err)
-				}
-			}
-		}
-		return nil
-	}
-}
-
-func gitStashCreate() (string, error) {
-	sha, err := gitRun("stash", "create", "--include-untracked")
-	if err != nil {
-		return "", err
-	}
-	return sha, nil
-}
-
-func gitUpdateRef(ref, sha string) error {
-	_, err := gitRun("update-ref", ref, sha)
-	return err
-}
-
-func gitDeleteRef(ref string) error {
-	_, err := gitRun("update-ref", "-d", ref)
-	if err != nil && strings.Contains(err.Error(), "not a valid SHA1") {
-		return nil
-	}
-	return err
-}
-
-func gitRestoreClean() error {
-	_, err := gitRun("checkout", "--", ".")
-	if err != nil && !strings.Contains(err.Error(), "did not match") {
-		return err
-	}
-	_, err = gitRun("clean", "-fd")
-	return err
-}
-
-func gitStashApply(sha string) error {
-	_, err := gitRun("stash", "apply", "--index", sha)
-	return err
-}
-
 // ---------------------------------------------------------------------------
 // Transcript helpers (for clipboard operations)
 // ---------------------------------------------------------------------------
@@ -1310,6 +1736,10 @@ func PrintToScrollback(text string) tea.Cmd {
 	if strings.TrimSpace(text) == "" {
 		return nil
 	}
+	if viewportMode {
+		render := rewrapText(stripTrailingBlankLines(text))
+		return func() tea.Msg { return PagerAppendMsg{Render: render} }
+	}
 	// Add a small visual gap between finalized message blocks.
 	return tea.Println(stripTrailingBlankLines(text) + "\n")
 }
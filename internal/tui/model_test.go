@@ -1,11 +1,21 @@
 package tui
 
 import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/daemon"
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/tools"
 )
 
 func TestFilterNulls(t *testing.T) {
@@ -84,6 +94,7 @@ func TestIsBoolConfigKey(t *testing.T) {
 	}{
 		{"footer.tokens", true},
 		{"footer.cost", true},
+		{"footer.context", true},
 		{"footer.cwd", true},
 		{"footer.session", true},
 		{"footer.keybindings", true},
@@ -222,89 +233,504 @@ func TestHardWrapLine(t *testing.T) {
 	}
 }
 
-func TestCompactMessages(t *testing.T) {
-	t.Run("short conversation unchanged", func(t *testing.T) {
-		msgs := make([]domain.TranscriptMessage, 10)
-		for i := range msgs {
-			if i%2 == 0 {
-				msgs[i] = domain.TranscriptMessage{Role: "user", Content: "msg"}
-			} else {
-				msgs[i] = domain.TranscriptMessage{Role: "assistant", Content: "reply"}
-			}
-		}
-		got := CompactMessages(msgs)
-		if len(got) != len(msgs) {
-			t.Errorf("short conversation should be unchanged, got %d want %d", len(got), len(msgs))
+func TestDefaultRuntimeLogPath(t *testing.T) {
+	path := defaultRuntimeLogPath()
+	if path == "" {
+		t.Skip("config.DataDir returned empty (no home dir)")
+	}
+	if !strings.HasSuffix(path, "runtime.log") {
+		t.Errorf("path = %q, want suffix runtime.log", path)
+	}
+	if !strings.Contains(path, "logs") {
+		t.Errorf("path = %q, want to contain 'logs' directory", path)
+	}
+}
+
+func TestMustGetwd(t *testing.T) {
+	wd := MustGetwd()
+	if wd == "" || wd == "." {
+		t.Errorf("MustGetwd() = %q, want non-empty absolute path", wd)
+	}
+}
+
+func TestLooksLikePaste(t *testing.T) {
+	now := time.Now()
+	key := tea.KeyMsg{Type: tea.KeyEnter}
+
+	t.Run("bracketed paste event", func(t *testing.T) {
+		m := Model{}
+		if !m.looksLikePaste(tea.KeyMsg{Type: tea.KeyRunes, Paste: true}, now) {
+			t.Error("expected paste")
 		}
 	})
 
-	t.Run("long conversation compacted", func(t *testing.T) {
-		msgs := make([]domain.TranscriptMessage, 50)
-		for i := range msgs {
-			if i%2 == 0 {
-				msgs[i] = domain.TranscriptMessage{Role: "user", Content: "msg"}
-			} else {
-				msgs[i] = domain.TranscriptMessage{Role: "assistant", Content: "reply"}
-			}
-		}
-		got := CompactMessages(msgs)
-		if len(got) >= len(msgs) {
-			t.Error("long conversation should be compacted")
-		}
-		// Should contain compaction notice
-		found := false
-		for _, m := range got {
-			if strings.Contains(m.Content, "compacted") {
-				found = true
-				break
-			}
-		}
-		if !found {
-			t.Error("compacted messages should contain notice")
+	t.Run("fast keystrokes without bracketed paste", func(t *testing.T) {
+		m := Model{lastKeypressTime: now.Add(-time.Millisecond)}
+		if !m.looksLikePaste(key, now) {
+			t.Error("expected timing heuristic to detect paste")
 		}
-		// First message should be from original head
-		if got[0].Role != "user" || got[0].Content != "msg" {
-			t.Error("head should be preserved")
+	})
+
+	t.Run("slow keystrokes", func(t *testing.T) {
+		m := Model{lastKeypressTime: now.Add(-time.Second)}
+		if m.looksLikePaste(key, now) {
+			t.Error("expected typed enter")
 		}
 	})
 
-	t.Run("preserves head through first assistant", func(t *testing.T) {
-		msgs := make([]domain.TranscriptMessage, 50)
-		msgs[0] = domain.TranscriptMessage{Role: "user", Content: "system setup"}
-		msgs[1] = domain.TranscriptMessage{Role: "assistant", Content: "acknowledged"}
-		for i := 2; i < len(msgs); i++ {
-			if i%2 == 0 {
-				msgs[i] = domain.TranscriptMessage{Role: "user", Content: "msg"}
-			} else {
-				msgs[i] = domain.TranscriptMessage{Role: "assistant", Content: "reply"}
-			}
+	t.Run("heuristic off once bracketed paste seen", func(t *testing.T) {
+		m := Model{lastKeypressTime: now.Add(-time.Millisecond), bracketedPaste: true}
+		if m.looksLikePaste(key, now) {
+			t.Error("expected enter to submit when the terminal brackets pastes")
 		}
-		got := CompactMessages(msgs)
-		if got[0].Content != "system setup" {
-			t.Error("first user message should be preserved in head")
+	})
+}
+
+func TestInsertPaste(t *testing.T) {
+	m := Model{input: "ab", inputCursor: 1, historyIdx: 2}
+	m.insertPaste("x\r\ny\rz\n\n")
+	if m.input != "ax\ny\nzb" {
+		t.Errorf("input = %q", m.input)
+	}
+	if m.inputCursor != 6 {
+		t.Errorf("cursor = %d, want 6", m.inputCursor)
+	}
+	if m.historyIdx != -1 {
+		t.Errorf("historyIdx = %d, want -1", m.historyIdx)
+	}
+}
+
+func TestHandlePaste_image(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "shot 1.png")
+	if err := os.WriteFile(path, []byte("\x89PNG\r\n\x1a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m := Model{input: "see", inputCursor: 3}
+	got, cmd := m.handlePaste(PasteMsg{ImagePath: path})
+	if cmd == nil {
+		t.Error("expected a notice")
+	}
+	input := got.(Model).input
+	paths, rest := tools.ExtractImagePaths(input)
+	if len(paths) != 1 || paths[0] != path {
+		t.Errorf("ExtractImagePaths(%q) = %v", input, paths)
+	}
+	if strings.TrimSpace(rest) != "see" {
+		t.Errorf("remaining = %q", rest)
+	}
+}
+
+func TestHandleToolResult_interleaved(t *testing.T) {
+	m := Model{}
+	for _, msg := range []ToolStatusMsg{
+		{ID: "t1", Name: "grep", Input: map[string]any{"pattern": "foo"}},
+		{ID: "t2", Name: "file_read", Input: map[string]any{"path": "a.go"}},
+	} {
+		next, _ := m.handleToolStatus(msg)
+		m = next.(Model)
+	}
+	if !strings.HasSuffix(m.turnCurrentTool, "(+1 more)") {
+		t.Errorf("turnCurrentTool = %q, want a count of other running tools", m.turnCurrentTool)
+	}
+
+	// t1 finishes after t2 started: t2 is still running.
+	next, _ := m.handleToolResult(ToolResultMsg{ID: "t1", Name: "grep"})
+	m = next.(Model)
+	if len(m.turnRunning) != 1 || m.turnRunning[0].id != "t2" {
+		t.Fatalf("turnRunning = %+v, want only t2", m.turnRunning)
+	}
+	if m.toolStatus != "Running file_read..." {
+		t.Errorf("toolStatus = %q", m.toolStatus)
+	}
+
+	next, _ = m.handleToolResult(ToolResultMsg{ID: "t2", Name: "file_read"})
+	m = next.(Model)
+	if len(m.turnRunning) != 0 || m.turnCurrentTool != "" {
+		t.Errorf("after all done: turnRunning = %+v, turnCurrentTool = %q", m.turnRunning, m.turnCurrentTool)
+	}
+	if !strings.HasPrefix(m.toolStatus, "Finished file_read") {
+		t.Errorf("toolStatus = %q", m.toolStatus)
+	}
+}
+
+func TestUpdate_toolsMsgOpensPickerWithDaemonState(t *testing.T) {
+	m := Model{}
+	next, _ := m.Update(ToolsMsg{Open: true, Tools: []daemon.ToolInfo{
+		{Name: "bash", Source: "builtin", Enabled: false},
+		{Name: "file_read", Source: "builtin", Enabled: true},
+		{Name: "mcp__db__query", Source: "mcp", Enabled: true},
+	}})
+	m = next.(Model)
+	if !m.toolPicker.IsActive() {
+		t.Fatal("tool picker not opened")
+	}
+	if got := fmt.Sprint(m.toolPicker.names); got != "[bash file_read mcp__db__query]" {
+		t.Errorf("picker tools = %s, want the daemon's list", got)
+	}
+	if d := m.toolPicker.DisabledMap(); !d["bash"] || len(d) != 1 {
+		t.Errorf("disabled = %v, want only bash", d)
+	}
+
+	// Applying updates the cached state so the next open matches. Without
+	// a daemon the preferences are saved, so keep them out of the real home.
+	t.Setenv("HOME", t.TempDir())
+	m.toolPicker.ToggleSelected() // bash is first: enable it
+	m.applyDisabledToolsSetting(m.toolPicker.DisabledMap())
+	for _, ti := range m.daemonTools {
+		if !ti.Enabled {
+			t.Errorf("%s still disabled after apply", ti.Name)
 		}
-		if got[1].Content != "acknowledged" {
-			t.Error("first assistant message should be preserved in head")
+	}
+}
+
+// fakeDaemon returns a client for a daemon served by h.
+func fakeDaemon(t *testing.T, h http.Handler) *daemon.DaemonClient {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	d := daemon.NewDaemonClient(0)
+	d.SetBaseURL(ts.URL)
+	return d
+}
+
+func TestDaemonIsSourceOfTruth(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	var got []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/config", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Key, Value string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		got = append(got, "config "+req.Key+"="+req.Value)
+		if req.Key == "footer.emoji" && req.Value == "reject" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"rejected by daemon"}`)
+			return
 		}
+		fmt.Fprint(w, `{"status":"ok"}`)
+	})
+	mux.HandleFunc("PUT /api/memory/{key}", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Value string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		got = append(got, "memory "+r.PathValue("key")+"="+req.Value)
+		fmt.Fprint(w, `{"status":"ok"}`)
 	})
+	mux.HandleFunc("POST /api/sessions/{id}/title", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"session not found"}`)
+	})
+
+	m := Model{Daemon: fakeDaemon(t, mux), Session: &domain.Session{ID: "sess-1234", Title: "old"}}
+	next, _ := m.handleSlashCommand("/config set footer.emoji rocket")
+	m = next.(Model)
+	if m.Prefs.FooterEmoji == "" {
+		t.Error("local preferences not updated")
+	}
+	next, _ = m.handleSlashCommand("/remember db postgres 16")
+	m = next.(Model)
+
+	want := "[config footer.emoji=rocket memory db=postgres 16]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("daemon calls = %v, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "muxd")); !os.IsNotExist(err) {
+		t.Errorf("local config written despite the daemon: %v", err)
+	}
+
+	// A value the daemon refuses is not kept locally.
+	before := m.Prefs.FooterEmoji
+	next, _ = m.handleSlashCommand("/config set footer.emoji reject")
+	m = next.(Model)
+	if m.Prefs.FooterEmoji != before {
+		t.Errorf("FooterEmoji = %q after a rejected set, want %q", m.Prefs.FooterEmoji, before)
+	}
+
+	// A failed rename keeps the old title.
+	next, _ = m.handleSlashCommand("/rename new title")
+	m = next.(Model)
+	if m.Session.Title != "old" {
+		t.Errorf("title = %q after a failed rename", m.Session.Title)
+	}
 }
 
-func TestDefaultRuntimeLogPath(t *testing.T) {
-	path := defaultRuntimeLogPath()
-	if path == "" {
-		t.Skip("config.DataDir returned empty (no home dir)")
+func TestDetach(t *testing.T) {
+	d := fakeDaemon(t, http.NewServeMux())
+	m := Model{Daemon: d, Session: &domain.Session{ID: "sess-1234"}}
+
+	// Nothing to leave running.
+	next, _ := m.handleSlashCommand("/detach")
+	if next.(Model).Detached() {
+		t.Error("detached without a running turn")
 	}
-	if !strings.HasSuffix(path, "runtime.log") {
-		t.Errorf("path = %q, want suffix runtime.log", path)
+
+	// An embedded daemon exits with the TUI, taking the turn with it.
+	m.thinking = true
+	m.EmbeddedDaemon = true
+	next, _ = m.handleSlashCommand("/detach")
+	if next.(Model).Detached() {
+		t.Error("detached from an embedded daemon")
 	}
-	if !strings.Contains(path, "logs") {
-		t.Errorf("path = %q, want to contain 'logs' directory", path)
+
+	m.EmbeddedDaemon = false
+	next, cmd := m.handleSlashCommand("/detach")
+	if !next.(Model).Detached() {
+		t.Fatal("expected detach")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("detach should quit the TUI")
 	}
 }
 
-func TestMustGetwd(t *testing.T) {
-	wd := MustGetwd()
-	if wd == "" || wd == "." {
-		t.Errorf("MustGetwd() = %q, want non-empty absolute path", wd)
+func TestVerify(t *testing.T) {
+	var got map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/sessions/{id}/verify", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(daemon.SessionVerify{Enabled: got["enabled"] == true, Model: "openai/gpt-4o"})
+	})
+	m := Model{Daemon: fakeDaemon(t, mux), Session: &domain.Session{ID: "sess-1234"}}
+
+	if _, cmd := m.handleSlashCommand("/verify on openai/gpt-4o"); cmd == nil || got["enabled"] != true || got["model"] != "openai/gpt-4o" {
+		t.Errorf("/verify on sent %v", got)
+	}
+	m.handleSlashCommand("/verify off")
+	if got["enabled"] != false {
+		t.Errorf("/verify off sent %v", got)
+	}
+	got = nil
+	m.handleSlashCommand("/verify maybe")
+	if got != nil {
+		t.Error("bad argument reached the daemon")
+	}
+
+	// The pass's tokens are kept apart from the session's.
+	next, cmd := m.Update(VerifiedMsg{Info: daemon.VerifyInfo{Model: "gpt-4o", Critique: "- wrong flag", InputTokens: 120, OutputTokens: 9}})
+	m = next.(Model)
+	if m.verifyInputTokens != 120 || m.verifyOutputTokens != 9 || m.inputTokens != 0 {
+		t.Errorf("tokens: verify %d/%d, session %d", m.verifyInputTokens, m.verifyOutputTokens, m.inputTokens)
+	}
+	if cmd == nil {
+		t.Error("critique should be printed")
+	}
+}
+
+func TestQueuedMessages(t *testing.T) {
+	m := Model{Session: &domain.Session{ID: "sess-1"}, thinking: true, historyIdx: -1}
+	press := func(keys ...tea.KeyMsg) {
+		t.Helper()
+		for _, k := range keys {
+			next, _ := m.Update(k)
+			m = next.(Model)
+		}
+	}
+	typeLine := func(s string) {
+		t.Helper()
+		// Keys followed at once by Enter look like a paste.
+		m.setInput(s)
+		m.lastKeypressTime = time.Time{}
+		press(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+
+	// Typing and Enter during a turn queue the message.
+	typeLine("first")
+	typeLine("second")
+	if len(m.queued) != 2 || m.queued[0].text != "first" || m.input != "" {
+		t.Fatalf("queued = %+v, input = %q", m.queued, m.input)
+	}
+	if view := m.renderQueued(80); !strings.Contains(view, "queued 2: second") {
+		t.Errorf("queued view = %q", view)
+	}
+
+	// Up on an empty prompt takes the last one back for editing.
+	press(tea.KeyMsg{Type: tea.KeyUp})
+	if len(m.queued) != 1 || m.input != "second" {
+		t.Fatalf("after up: queued = %+v, input = %q", m.queued, m.input)
+	}
+	m.setInput("")
+	typeLine("third")
+
+	// The end of the turn sends the next queued message.
+	next, _ := m.handleTurnDone(TurnDoneMsg{})
+	m = next.(Model)
+	if !m.thinking || len(m.queued) != 1 || m.queued[0].text != "third" {
+		t.Fatalf("after turn: thinking = %v, queued = %+v", m.thinking, m.queued)
+	}
+	if last := m.messages[len(m.messages)-1]; last.Content != "first" {
+		t.Errorf("submitted %q, want first", last.Content)
+	}
+
+	// Cancelling the turn returns the rest to the input instead of sending.
+	press(tea.KeyMsg{Type: tea.KeyEsc})
+	if m.thinking || len(m.queued) != 0 || m.input != "third" {
+		t.Errorf("after cancel: thinking = %v, queued = %+v, input = %q", m.thinking, m.queued, m.input)
+	}
+}
+
+func TestEscStopsThenCancels(t *testing.T) {
+	requests := make(chan string, 2)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r.URL.Path
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer ts.Close()
+	d := daemon.NewDaemonClient(0)
+	d.SetBaseURL(ts.URL)
+	m := Model{Session: &domain.Session{ID: "sess-1"}, Daemon: d, thinking: true, historyIdx: -1}
+	m.queued = []queuedMessage{{text: "next"}}
+	esc := func() {
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+		m = next.(Model)
+	}
+
+	// The first Esc asks for a graceful stop and keeps the turn running.
+	esc()
+	if !m.thinking || !m.stopping {
+		t.Fatalf("after Esc: thinking = %v, stopping = %v", m.thinking, m.stopping)
+	}
+	if path := <-requests; path != "/api/sessions/sess-1/stop" {
+		t.Errorf("request = %s", path)
+	}
+	if status := m.buildActivityStatus(); !strings.Contains(status, "Esc again to abort") {
+		t.Errorf("status = %q", status)
+	}
+
+	// A stopped turn hands the queue back instead of sending it.
+	next, _ := m.handleTurnDone(TurnDoneMsg{StopReason: "cancelled"})
+	m = next.(Model)
+	if m.thinking || m.stopping || len(m.queued) != 0 || m.input != "next" {
+		t.Fatalf("after stop: thinking = %v, stopping = %v, queued = %+v, input = %q", m.thinking, m.stopping, m.queued, m.input)
+	}
+
+	// While stopping, a second Esc aborts.
+	m.setInput("")
+	m.thinking = true
+	esc()
+	<-requests
+	esc()
+	if m.thinking || m.stopping {
+		t.Errorf("after second Esc: thinking = %v, stopping = %v", m.thinking, m.stopping)
+	}
+}
+
+func TestInterruptedTurnOffer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":"sess-1","interrupted_prompt":"run the tests"}`))
+	}))
+	defer ts.Close()
+	d := daemon.NewDaemonClient(0)
+	d.SetBaseURL(ts.URL)
+	m := Model{Session: &domain.Session{ID: "sess-1"}, Daemon: d, historyIdx: -1}
+
+	msg, ok := m.checkInterruptedTurn()().(interruptedTurnMsg)
+	if !ok || msg.prompt != "run the tests" {
+		t.Fatalf("msg = %+v", msg)
+	}
+	next, _ := m.Update(msg)
+	m = next.(Model)
+	if m.input != "run the tests" {
+		t.Errorf("input = %q", m.input)
+	}
+
+	// A draft in progress is not replaced.
+	m.setInput("something else")
+	next, _ = m.Update(msg)
+	if got := next.(Model).input; got != "something else" {
+		t.Errorf("input = %q", got)
+	}
+}
+
+func TestSteerCommand(t *testing.T) {
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, r.URL.Path+" "+body["text"])
+		w.Write([]byte(`{"status":"queued"}`))
+	}))
+	defer ts.Close()
+	d := daemon.NewDaemonClient(0)
+	d.SetBaseURL(ts.URL)
+	m := Model{Session: &domain.Session{ID: "sess-1"}, Daemon: d, historyIdx: -1}
+
+	// Without a running turn there is nothing to steer.
+	next, _ := m.handleSlashCommand("/steer focus")
+	m = next.(Model)
+	if len(m.steering) != 0 {
+		t.Fatalf("steering while idle = %q", m.steering)
+	}
+
+	// During a turn /steer is sent at once rather than queued.
+	m.thinking = true
+	m.setInput("/steer  leave the tests alone")
+	m.lastKeypressTime = time.Time{}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(Model)
+	if len(m.queued) != 0 || len(m.steering) != 1 || m.steering[0] != "leave the tests alone" {
+		t.Fatalf("queued = %+v, steering = %q", m.queued, m.steering)
+	}
+	if msg, ok := cmd().(SteerMsg); !ok || msg.Err != nil {
+		t.Fatalf("steer cmd = %+v", msg)
+	}
+	if len(got) != 1 || got[0] != "/api/sessions/sess-1/steer leave the tests alone" {
+		t.Errorf("requests = %q", got)
+	}
+	if view := m.renderSteering(80); !strings.Contains(view, "steering: leave the tests alone") {
+		t.Errorf("steering view = %q", view)
+	}
+
+	// Delivery clears the pending note.
+	next, _ = m.handleSteered(SteeredMsg{Notes: []string{"leave the tests alone"}})
+	m = next.(Model)
+	if len(m.steering) != 0 {
+		t.Errorf("after delivery: steering = %q", m.steering)
+	}
+}
+
+func TestUndoRedoUseDaemon(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/sessions/sess-1/undo":
+			w.Write([]byte(`{"id":4,"turn":2,"undone":true}`))
+		case "/api/sessions/sess-1/checkpoints":
+			w.Write([]byte(`{"checkpoints":[{"id":3,"turn":1},{"id":4,"turn":2},{"id":5,"turn":3,"undone":true}]}`))
+		case "/api/sessions/sess-1/checkpoints/4/diff":
+			w.Write([]byte(`{"checkpoint":{"id":4,"turn":2},"diff":"--- a/a.txt\n+++ b/a.txt\n-two\n+one"}`))
+		default:
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":"nothing to redo"}`))
+		}
+	}))
+	defer ts.Close()
+	d := daemon.NewDaemonClient(0)
+	d.SetBaseURL(ts.URL)
+	m := Model{Session: &domain.Session{ID: "sess-1"}, Daemon: d, historyIdx: -1}
+
+	_, cmd := m.handleSlashCommand("/undo")
+	msg, ok := cmd().(UndoDoneMsg)
+	if !ok || msg.Err != nil || msg.Checkpoint.Turn != 2 {
+		t.Fatalf("undo = %+v", msg)
+	}
+	if got := checkpointLabel(msg.Checkpoint); got != "agent step 2" {
+		t.Errorf("label = %q", got)
+	}
+
+	_, cmd = m.handleSlashCommand("/undo --preview")
+	preview, ok := cmd().(UndoPreviewMsg)
+	if !ok || preview.Err != nil || preview.Checkpoint.ID != 4 || !strings.Contains(preview.Diff, "+one") {
+		t.Fatalf("preview = %+v", preview)
+	}
+
+	_, cmd = m.handleSlashCommand("/redo")
+	if msg, ok := cmd().(RedoDoneMsg); !ok || msg.Err == nil || !strings.Contains(msg.Err.Error(), "nothing to redo") {
+		t.Errorf("redo = %+v", msg)
+	}
+
+	m.thinking = true
+	if _, cmd := m.handleSlashCommand("/undo"); cmd == nil {
+		t.Error("undo while running printed nothing")
+	} else if _, ok := cmd().(UndoDoneMsg); ok {
+		t.Error("undo ran while a turn was running")
 	}
 }
@@ -3,26 +3,48 @@ package tui
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/batalabs/muxd/internal/daemon"
 	"github.com/batalabs/muxd/internal/hub"
 )
 
 // NodePicker is an interactive node selector overlay for hub connections.
 type NodePicker struct {
-	nodes       []*hub.Node
-	filtered    []*hub.Node
-	selectedIdx int
-	filter      string
-	active      bool
+	pickerList[*hub.Node]
+	active bool
 }
 
 // NewNodePicker creates a picker with the given nodes.
 func NewNodePicker(nodes []*hub.Node) *NodePicker {
-	return &NodePicker{
-		nodes:    nodes,
-		filtered: nodes,
-		active:   true,
+	p := &NodePicker{
+		pickerList: newPickerList("node", nodes, nodeFields, func(n *hub.Node) string { return n.ID }),
+		active:     true,
 	}
+	p.detail = nodeDetail
+	return p
+}
+
+// nodeFields are matched by the filter: name, host, and ID.
+func nodeFields(n *hub.Node) []string {
+	return []string{n.Name, n.Host, n.ID}
+}
+
+// nodeDetail is the preview pane for a node.
+func nodeDetail(n *hub.Node) []string {
+	lines := []string{
+		fmt.Sprintf("%s  %s  %s", n.Name, n.ID, daemon.HostPort(n.Host, n.Port)),
+		fmt.Sprintf("status: %s  version: %s  last heartbeat %s", n.Status, n.Version, TimeAgo(n.LastSeenAt)),
+		fmt.Sprintf("sessions: %d active, %d loaded  tokens: %s  uptime: %s",
+			n.ActiveSessions, n.LoadedSessions, formatTokenCount(n.TokensUsed), formatUptime(n.Uptime(time.Now()))),
+	}
+	if n.Platform != "" || n.Model != "" {
+		lines = append(lines, strings.TrimSpace(fmt.Sprintf("%s/%s  %s %s", n.Platform, n.Arch, n.Provider, n.Model)))
+	}
+	if n.Resources != nil {
+		lines = append(lines, n.Resources.Lines()...)
+	}
+	return lines
 }
 
 // IsActive reports whether the picker is currently shown.
@@ -37,66 +59,18 @@ func (p *NodePicker) Dismiss() {
 
 // SelectedNode returns the currently highlighted node, or nil.
 func (p *NodePicker) SelectedNode() *hub.Node {
-	if len(p.filtered) == 0 {
-		return nil
-	}
-	return p.filtered[p.selectedIdx]
-}
-
-// MoveUp moves the selection up.
-func (p *NodePicker) MoveUp() {
-	if p.selectedIdx > 0 {
-		p.selectedIdx--
-	}
-}
-
-// MoveDown moves the selection down.
-func (p *NodePicker) MoveDown() {
-	if p.selectedIdx < len(p.filtered)-1 {
-		p.selectedIdx++
-	}
-}
-
-// AppendFilter adds a rune to the filter.
-func (p *NodePicker) AppendFilter(r rune) {
-	p.filter += string(r)
-	p.applyFilter()
-}
-
-// BackspaceFilter removes the last rune from the filter.
-func (p *NodePicker) BackspaceFilter() {
-	if len(p.filter) > 0 {
-		runes := []rune(p.filter)
-		p.filter = string(runes[:len(runes)-1])
-		p.applyFilter()
-	}
-}
-
-func (p *NodePicker) applyFilter() {
-	if p.filter == "" {
-		p.filtered = p.nodes
-	} else {
-		lower := strings.ToLower(p.filter)
-		p.filtered = nil
-		for _, n := range p.nodes {
-			if strings.Contains(strings.ToLower(n.Name), lower) ||
-				strings.Contains(strings.ToLower(n.Host), lower) ||
-				strings.Contains(strings.ToLower(n.ID), lower) {
-				p.filtered = append(p.filtered, n)
-			}
-		}
-	}
-	p.selectedIdx = 0
+	n, _ := p.current()
+	return n
 }
 
 // View renders the picker as a string.
 func (p *NodePicker) View(width int) string {
-	_ = width // reserved for future layout use
-
 	var b strings.Builder
 
 	b.WriteString(FooterHead.Render("Node Picker"))
 	b.WriteString("\n")
+	b.WriteString(FooterMeta.Render("  " + hubOverview(p.items)))
+	b.WriteString("\n")
 
 	filterLine := "  Filter: " + p.filter
 	b.WriteString(FooterMeta.Render(filterLine))
@@ -108,15 +82,12 @@ func (p *NodePicker) View(width int) string {
 		b.WriteString("\n")
 	} else {
 		const maxVisible = 10
-		start := 0
-		if p.selectedIdx >= maxVisible {
-			start = p.selectedIdx - maxVisible + 1
-		}
-		end := start + maxVisible
-		if end > len(p.filtered) {
-			end = len(p.filtered)
-		}
+		start, end := p.window(maxVisible)
 
+		now := time.Now()
+		b.WriteString(FooterMeta.Render(fmt.Sprintf("  %-16s  %-22s  %-7s  %-7s  %-6s  %s",
+			"NAME", "ADDRESS", "STATUS", "UPTIME", "LOAD", "MODEL")))
+		b.WriteString("\n")
 		for i := start; i < end; i++ {
 			n := p.filtered[i]
 			indicator := "  "
@@ -129,13 +100,18 @@ func (p *NodePicker) View(width int) string {
 				name = name[:13] + "..."
 			}
 
-			addr := fmt.Sprintf("%s:%d", n.Host, n.Port)
+			addr := daemon.HostPort(n.Host, n.Port)
 			if len(addr) > 22 {
 				addr = addr[:19] + "..."
 			}
 
-			line := fmt.Sprintf("%s%-16s  %-22s  %-7s  %s",
-				indicator, name, addr, string(n.Status), n.Version)
+			uptime, load := "-", "-"
+			if n.Status == hub.StatusOnline {
+				uptime = formatUptime(n.Uptime(now))
+				load = fmt.Sprintf("%d/%d", n.ActiveSessions, n.LoadedSessions)
+			}
+			line := fmt.Sprintf("%s%-16s  %-22s  %-7s  %-7s  %-6s  %s",
+				indicator, name, addr, string(n.Status), uptime, load, n.Model)
 
 			if i == p.selectedIdx {
 				b.WriteString(CompletionSelStyle.Render(line))
@@ -150,6 +126,7 @@ func (p *NodePicker) View(width int) string {
 			b.WriteString(FooterMeta.Render(more))
 			b.WriteString("\n")
 		}
+		b.WriteString(p.previewView(width))
 	}
 
 	b.WriteString("\n")
@@ -158,3 +135,43 @@ func (p *NodePicker) View(width int) string {
 
 	return b.String()
 }
+
+// hubOverview summarizes the hub's nodes for the picker header.
+func hubOverview(nodes []*hub.Node) string {
+	online, active := 0, 0
+	var tokens int64
+	for _, n := range nodes {
+		if n.Status == hub.StatusOnline {
+			online++
+			active += n.ActiveSessions
+		}
+		tokens += n.TokensUsed
+	}
+	return fmt.Sprintf("%d nodes, %d online  |  %d active sessions  |  %s tokens", len(nodes), online, active, formatTokenCount(tokens))
+}
+
+// formatUptime renders a duration at day, hour, or minute precision.
+func formatUptime(d time.Duration) string {
+	switch {
+	case d <= 0:
+		return "-"
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd%dh", int(d.Hours())/24, int(d.Hours())%24)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+}
+
+// formatTokenCount renders a token count as 950, 12.3k, or 4.5M.
+func formatTokenCount(n int64) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
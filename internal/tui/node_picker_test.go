@@ -0,0 +1,46 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/hub"
+)
+
+func TestNodePicker_Overview(t *testing.T) {
+	started := time.Now().Add(-90 * time.Minute)
+	nodes := []*hub.Node{
+		{ID: "a", Name: "alpha", Status: hub.StatusOnline, Model: "sonnet",
+			NodeLoad: hub.NodeLoad{ActiveSessions: 2, LoadedSessions: 3, TokensUsed: 12_300, StartedAt: started}},
+		{ID: "b", Name: "beta", Status: hub.StatusOffline,
+			NodeLoad: hub.NodeLoad{ActiveSessions: 4, TokensUsed: 700}},
+	}
+	if got := hubOverview(nodes); got != "2 nodes, 1 online  |  2 active sessions  |  13.0k tokens" {
+		t.Errorf("hubOverview = %q", got)
+	}
+
+	view := NewNodePicker(nodes).View(100)
+	for _, want := range []string{"UPTIME", "1h30m", "2/3", "sonnet"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("view missing %q:\n%s", want, view)
+		}
+	}
+}
+
+func TestFormatUptime(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "-"},
+		{42 * time.Minute, "42m"},
+		{3*time.Hour + 5*time.Minute, "3h5m"},
+		{50 * time.Hour, "2d2h"},
+	}
+	for _, tt := range tests {
+		if got := formatUptime(tt.d); got != tt.want {
+			t.Errorf("formatUptime(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
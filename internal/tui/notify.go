@@ -0,0 +1,58 @@
+package tui
+
+import (
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/notify"
+)
+
+// ---------------------------------------------------------------------------
+// /notify: notification sinks
+// ---------------------------------------------------------------------------
+
+const notifyUsage = "Usage: /notify test [desktop|webhook|telegram]"
+
+// NotifyTestMsg carries the outcome of a test notification per sink.
+type NotifyTestMsg struct {
+	Results []notify.Result
+	Err     error
+}
+
+func (m Model) handleNotifyCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError("Notifications are sent by the daemon; connect to one first."))
+	}
+	if len(args) == 0 || strings.ToLower(args[0]) != "test" || len(args) > 2 {
+		return m, PrintToScrollback(m.renderError(notifyUsage))
+	}
+	sink := ""
+	if len(args) == 2 {
+		sink = args[1]
+	}
+	d := m.Daemon
+	return m, func() tea.Msg {
+		results, err := d.NotifyTest(sink)
+		return NotifyTestMsg{Results: results, Err: err}
+	}
+}
+
+func (m Model) handleNotifyTest(msg NotifyTestMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError(msg.Err.Error()))
+	}
+	return m, PrintToScrollback(formatNotifyResults(msg.Results))
+}
+
+func formatNotifyResults(results []notify.Result) string {
+	lines := []string{FooterHead.Render("Test notifications")}
+	for _, r := range results {
+		if r.Error != "" {
+			lines = append(lines, ErrorLineStyle.Render("  "+r.Sink+": "+r.Error))
+			continue
+		}
+		lines = append(lines, FooterMeta.Render("  "+r.Sink+": sent"))
+	}
+	return strings.Join(lines, "\n")
+}
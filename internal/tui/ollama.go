@@ -0,0 +1,248 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/config"
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/hostinfo"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+// ---------------------------------------------------------------------------
+// /ollama: local model management
+// ---------------------------------------------------------------------------
+
+const ollamaUsage = "Usage: /ollama [list|pull <model>|rm <model>]"
+
+// OllamaStatusMsg carries the daemon's Ollama status. Model is set when the
+// status was checked after switching to that model, so a missing one can
+// be offered for pulling.
+type OllamaStatusMsg struct {
+	Status *daemon.OllamaStatus
+	Model  string
+	Err    error
+}
+
+// OllamaProgressMsg is one progress update of a running pull.
+type OllamaProgressMsg struct {
+	Model    string
+	Progress provider.OllamaProgress
+}
+
+// OllamaPulledMsg reports a finished pull.
+type OllamaPulledMsg struct {
+	Model string
+	Err   error
+}
+
+// OllamaRemovedMsg reports a removed model.
+type OllamaRemovedMsg struct {
+	Model string
+	Err   error
+}
+
+func (m Model) handleOllamaCommand(args []string) (tea.Model, tea.Cmd) {
+	if m.Daemon == nil {
+		return m, PrintToScrollback(m.renderError("Ollama models are managed by the daemon; connect to one first."))
+	}
+	sub := "list"
+	if len(args) > 0 {
+		sub = strings.ToLower(args[0])
+	}
+	d := m.Daemon
+
+	switch sub {
+	case "list":
+		if len(args) > 1 {
+			return m, PrintToScrollback(m.renderError(ollamaUsage))
+		}
+		return m, ollamaStatusCmd(d, "")
+
+	case "pull":
+		if len(args) != 2 {
+			return m, PrintToScrollback(m.renderError("Usage: /ollama pull <model>"))
+		}
+		return m.startOllamaPull(args[1])
+
+	case "rm":
+		if len(args) != 2 {
+			return m, PrintToScrollback(m.renderError("Usage: /ollama rm <model>"))
+		}
+		name := args[1]
+		return m, func() tea.Msg {
+			return OllamaRemovedMsg{Model: name, Err: d.DeleteOllamaModel(name)}
+		}
+
+	default:
+		return m, PrintToScrollback(m.renderError(ollamaUsage))
+	}
+}
+
+// ollamaStatusCmd fetches the daemon's Ollama status. A non-empty model
+// asks for it to be offered for pulling when it is not installed.
+func ollamaStatusCmd(d *daemon.DaemonClient, model string) tea.Cmd {
+	return func() tea.Msg {
+		status, err := d.OllamaStatus()
+		return OllamaStatusMsg{Status: status, Model: model, Err: err}
+	}
+}
+
+// ollamaModelCheck checks the Ollama server after the model setting
+// changed to an Ollama model, so a missing model or a stopped server is
+// reported right away rather than on the next message.
+func (m Model) ollamaModelCheck(key string) tea.Cmd {
+	if config.CanonicalKey(key) != "model" || m.Daemon == nil || !m.usingOllama() {
+		return nil
+	}
+	return ollamaStatusCmd(m.Daemon, m.modelID)
+}
+
+func (m Model) usingOllama() bool {
+	return m.Provider != nil && m.Provider.Name() == "ollama"
+}
+
+// startOllamaPull starts pulling model in the background. Progress arrives
+// as OllamaProgressMsg and the result as OllamaPulledMsg.
+func (m Model) startOllamaPull(model string) (tea.Model, tea.Cmd) {
+	if m.ollamaPull != "" {
+		return m, PrintToScrollback(m.renderError("Already pulling " + m.ollamaPull + "."))
+	}
+	m.ollamaPull = model
+	m.ollamaProgress = provider.OllamaProgress{Status: "starting"}
+	d := m.Daemon
+	return m, func() tea.Msg {
+		err := d.PullOllamaModel(model, func(p provider.OllamaProgress) {
+			if Prog != nil {
+				Prog.Send(OllamaProgressMsg{Model: model, Progress: p})
+			}
+		})
+		return OllamaPulledMsg{Model: model, Err: err}
+	}
+}
+
+// offerOllamaPull asks whether to pull a model that is not installed.
+func (m Model) offerOllamaPull(model string) (tea.Model, tea.Cmd) {
+	if m.ollamaPull == model {
+		return m, nil
+	}
+	m.pendingPull = model
+	return m, PrintToScrollback(FooterMeta.Render(fmt.Sprintf("  %s is not installed on the Ollama server. Pull it now? (y/n)", model)))
+}
+
+// handleOllamaPullKey answers the offer to pull a missing model.
+func (m Model) handleOllamaPullKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	model := m.pendingPull
+	switch {
+	case msg.Type == tea.KeyEnter, msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && (msg.Runes[0] == 'y' || msg.Runes[0] == 'Y'):
+		m.pendingPull = ""
+		return m.startOllamaPull(model)
+	case msg.Type == tea.KeyEsc, msg.Type == tea.KeyCtrlC, msg.Type == tea.KeyRunes && len(msg.Runes) == 1 && (msg.Runes[0] == 'n' || msg.Runes[0] == 'N'):
+		m.pendingPull = ""
+		return m, PrintToScrollback(FooterMeta.Render("  Not pulling " + model + ". /ollama pull " + model + " pulls it later."))
+	}
+	return m, nil
+}
+
+func (m Model) handleOllamaStatus(msg OllamaStatusMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError("Ollama: " + msg.Err.Error()))
+	}
+	if msg.Model == "" {
+		return m, PrintToScrollback(formatOllamaStatus(*msg.Status))
+	}
+	if msg.Status.Error != "" {
+		return m, PrintToScrollback(m.renderError(msg.Status.Error))
+	}
+	mod, ok := ollamaInstalled(msg.Status.Models, msg.Model)
+	if !ok {
+		return m.offerOllamaPull(msg.Model)
+	}
+	if warning := ollamaFitWarning(msg.Status.Host, mod); warning != "" {
+		return m, PrintToScrollback(m.renderError(warning))
+	}
+	return m, nil
+}
+
+// ollamaFitWarning warns when an installed model will not fit in the free
+// memory of the host running Ollama. host is nil when Ollama runs on
+// another machine.
+func ollamaFitWarning(host *hostinfo.Resources, mod provider.OllamaModel) string {
+	if host == nil || mod.Size <= 0 {
+		return ""
+	}
+	if fit := host.ModelFit(uint64(mod.Size)); fit != "" {
+		return mod.Name + " " + fit + "."
+	}
+	return ""
+}
+
+func (m Model) handleOllamaPulled(msg OllamaPulledMsg) (tea.Model, tea.Cmd) {
+	m.ollamaPull = ""
+	m.ollamaProgress = provider.OllamaProgress{}
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError("Pull failed: " + msg.Err.Error()))
+	}
+	note := "Switch to it with /config set model ollama/" + msg.Model + "."
+	if m.usingOllama() && m.modelID == msg.Model {
+		note = "Send your message again to use it."
+	}
+	return m, PrintToScrollback(WelcomeStyle.Render(fmt.Sprintf("Pulled %s. %s", msg.Model, note)))
+}
+
+func (m Model) handleOllamaRemoved(msg OllamaRemovedMsg) (tea.Model, tea.Cmd) {
+	if msg.Err != nil {
+		return m, PrintToScrollback(m.renderError(msg.Err.Error()))
+	}
+	return m, PrintToScrollback(FooterMeta.Render("Removed " + msg.Model + " from the Ollama server."))
+}
+
+// ollamaPullStatus describes the running pull for the status line, with
+// the percentage of the layer being downloaded.
+func (m Model) ollamaPullStatus() string {
+	p := m.ollamaProgress
+	line := fmt.Sprintf("Pulling %s: %s", m.ollamaPull, p.Status)
+	if p.Total > 0 {
+		line += fmt.Sprintf(" %d%% (%s / %s)", p.Completed*100/p.Total, formatBytes(int(p.Completed)), formatBytes(int(p.Total)))
+	}
+	return line
+}
+
+// ollamaInstalled finds name among models, where a name without a tag
+// means :latest.
+func ollamaInstalled(models []provider.OllamaModel, name string) (provider.OllamaModel, bool) {
+	for _, mod := range models {
+		if mod.Name == name || mod.Name == name+":latest" {
+			return mod, true
+		}
+	}
+	return provider.OllamaModel{}, false
+}
+
+// formatOllamaStatus renders the server's health and installed models.
+func formatOllamaStatus(st daemon.OllamaStatus) string {
+	if st.Error != "" {
+		return FooterMeta.Render(st.Error)
+	}
+	lines := []string{FooterHead.Render(fmt.Sprintf("Ollama %s at %s", st.Version, st.URL))}
+	if len(st.Models) == 0 {
+		lines = append(lines, FooterMeta.Render("  No models installed. Pull one with /ollama pull <model>."))
+	}
+	for _, mod := range st.Models {
+		detail := strings.TrimSpace(mod.ParameterSize + " " + mod.Quantization)
+		line := fmt.Sprintf("  %-32s %-14s %9s", mod.Name, detail, formatBytes(int(mod.Size)))
+		if !mod.ModifiedAt.IsZero() {
+			line += "  " + mod.ModifiedAt.Local().Format("2006-01-02")
+		}
+		lines = append(lines, FooterMeta.Render(line))
+	}
+	if st.Host != nil && st.Host.Known() {
+		for _, line := range st.Host.Lines() {
+			lines = append(lines, FooterMeta.Render("  "+line))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
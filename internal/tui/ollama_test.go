@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/hostinfo"
+	"github.com/batalabs/muxd/internal/provider"
+)
+
+func TestOllamaOfferPull(t *testing.T) {
+	var pulled []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/ollama/pull", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Model string }
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		pulled = append(pulled, req.Model)
+		fmt.Fprintln(w, `{"status":"pulling abc","total":10,"completed":10}`)
+		fmt.Fprintln(w, `{"status":"success"}`)
+	})
+	m := Model{Provider: &provider.OllamaProvider{}, modelID: "llama3.2", Daemon: fakeDaemon(t, mux)}
+
+	next, _ := m.handleStreamDone(StreamDoneMsg{Err: errors.New(`HTTP 404: ollama: model "llama3.2" not found`), ErrCode: domain.ErrModelNotFound})
+	m = next.(Model)
+	if m.pendingPull != "llama3.2" {
+		t.Fatalf("pendingPull = %q, want the missing model offered", m.pendingPull)
+	}
+	if !strings.Contains(m.View(), "Pull llama3.2 from Ollama? (y/n)") {
+		t.Error("view does not show the offer")
+	}
+
+	next, cmd := m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'y'}})
+	m = next.(Model)
+	if m.pendingPull != "" || m.ollamaPull != "llama3.2" || cmd == nil {
+		t.Fatalf("after y: pendingPull=%q ollamaPull=%q", m.pendingPull, m.ollamaPull)
+	}
+	msg := cmd()
+	if done, ok := msg.(OllamaPulledMsg); !ok || done.Err != nil || len(pulled) != 1 {
+		t.Fatalf("pull result = %#v, pulled %v", msg, pulled)
+	}
+	next, _ = m.Update(msg)
+	if m = next.(Model); m.ollamaPull != "" {
+		t.Error("pull still shown as running")
+	}
+
+	// Declining clears the offer without pulling.
+	m.pendingPull = "gemma3"
+	next, _ = m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}})
+	if m = next.(Model); m.pendingPull != "" || m.ollamaPull != "" {
+		t.Errorf("after n: pendingPull=%q ollamaPull=%q", m.pendingPull, m.ollamaPull)
+	}
+}
+
+func TestOllamaModelCheck(t *testing.T) {
+	m := Model{Provider: &provider.OllamaProvider{}, modelID: "llama3.2"}
+	status := &daemon.OllamaStatus{Version: "0.6.2", Models: []provider.OllamaModel{{Name: "llama3.2:latest"}}}
+
+	next, _ := m.handleOllamaStatus(OllamaStatusMsg{Status: status, Model: "llama3.2"})
+	if next.(Model).pendingPull != "" {
+		t.Error("offered an installed model")
+	}
+	next, _ = m.handleOllamaStatus(OllamaStatusMsg{Status: status, Model: "qwen3:8b"})
+	if next.(Model).pendingPull != "qwen3:8b" {
+		t.Error("did not offer a missing model")
+	}
+	next, _ = m.handleOllamaStatus(OllamaStatusMsg{Status: &daemon.OllamaStatus{Error: "ollama is not reachable"}, Model: "qwen3:8b"})
+	if next.(Model).pendingPull != "" {
+		t.Error("offered a pull from an unreachable server")
+	}
+
+	if m.ollamaModelCheck("model") != nil {
+		t.Error("checked without a daemon")
+	}
+	m.Daemon = daemon.NewDaemonClient(0)
+	if m.ollamaModelCheck("model") == nil || m.ollamaModelCheck("footer.emoji") != nil {
+		t.Error("the check should run only when the model changes")
+	}
+}
+
+func TestOllamaPullStatus(t *testing.T) {
+	m := Model{ollamaPull: "llama3.2", ollamaProgress: provider.OllamaProgress{Status: "pulling dde5aa3fc5ff", Total: 2 << 30, Completed: 1 << 30}}
+	if got, want := m.ollamaPullStatus(), "Pulling llama3.2: pulling dde5aa3fc5ff 50% (1.0 GB / 2.0 GB)"; got != want {
+		t.Errorf("status = %q, want %q", got, want)
+	}
+
+	got := formatOllamaStatus(daemon.OllamaStatus{URL: "http://localhost:11434", Version: "0.6.2", Models: []provider.OllamaModel{
+		{Name: "llama3.2:latest", Size: 2019393189, ParameterSize: "3.2B", Quantization: "Q4_K_M"},
+	}})
+	if !strings.Contains(got, "Ollama 0.6.2 at http://localhost:11434") || !strings.Contains(got, "3.2B Q4_K_M") || !strings.Contains(got, "1.9 GB") {
+		t.Errorf("formatOllamaStatus = %q", got)
+	}
+}
+
+func TestOllamaFitWarning(t *testing.T) {
+	host := &hostinfo.Resources{MemoryTotal: 16 << 30, MemoryAvailable: 4 << 30}
+	st := &daemon.OllamaStatus{Host: host, Models: []provider.OllamaModel{
+		{Name: "llama3.2:latest", Size: 2 << 30},
+		{Name: "llama3.3:70b", Size: 40 << 30},
+	}}
+	m := Model{Provider: &provider.OllamaProvider{}}
+
+	if _, cmd := m.handleOllamaStatus(OllamaStatusMsg{Status: st, Model: "llama3.2"}); cmd != nil {
+		t.Error("expected no warning for a model that fits")
+	}
+	if _, cmd := m.handleOllamaStatus(OllamaStatusMsg{Status: st, Model: "llama3.3:70b"}); cmd == nil {
+		t.Error("expected a warning for a model that does not fit")
+	}
+	if got := ollamaFitWarning(host, st.Models[1]); !strings.Contains(got, "llama3.3:70b needs about") {
+		t.Errorf("warning = %q", got)
+	}
+	if got := ollamaFitWarning(nil, st.Models[1]); got != "" {
+		t.Errorf("expected no warning for a remote server, got %q", got)
+	}
+}
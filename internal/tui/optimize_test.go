@@ -0,0 +1,47 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/daemon"
+)
+
+func TestRenderOptimizeReport(t *testing.T) {
+	r := &daemon.OptimizeReport{
+		Since:      "2026-05-01",
+		SpendUSD:   20,
+		MonthlyUSD: 20,
+		Suggestions: []daemon.Suggestion{
+			{ID: "model.title", Title: "Use claude-haiku-4-5-20251001 to title sessions", Detail: "model.title is unset.", Key: "model.title", Value: "claude-haiku-4-5-20251001", MonthlyUSD: 0.12},
+			{ID: "cache", Title: "Keep the prompt cache warm", Detail: "Only 10% cached.", MonthlyUSD: 3},
+		},
+	}
+	out := renderOptimizeReport(r)
+	for _, want := range []string{"$20.00 spent", "1. Use claude-haiku", "sets model.title = claude-haiku-4-5-20251001", "2. Keep the prompt cache warm", "/optimize apply"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report missing %q:\n%s", want, out)
+		}
+	}
+
+	r.Suggestions = nil
+	if out := renderOptimizeReport(r); !strings.Contains(out, "No suggestions") || strings.Contains(out, "/optimize apply") {
+		t.Errorf("empty report = %q", out)
+	}
+}
+
+func TestApplyOptimizeSuggestions(t *testing.T) {
+	m := Model{}
+	_, cmd := m.applyOptimizeSuggestions([]string{"1"})
+	if cmd == nil {
+		t.Fatal("expected an error message without a report")
+	}
+
+	m.optimizeReport = &daemon.OptimizeReport{Suggestions: []daemon.Suggestion{{ID: "cache", Title: "Keep the prompt cache warm"}}}
+	for _, args := range [][]string{{"1"}, {"2"}, {"x"}} {
+		next, _ := m.applyOptimizeSuggestions(args)
+		if next.(Model).optimizeReport == nil {
+			t.Errorf("apply %v should fail and keep the report", args)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// ---------------------------------------------------------------------------
+// Viewport mode
+// ---------------------------------------------------------------------------
+
+// viewportMode routes scrollback output into a TranscriptPager instead of
+// the terminal's own scrollback. Set once at startup with SetViewportMode.
+var viewportMode bool
+
+// SetViewportMode turns viewport mode on or off. Call it before
+// InitialModel and pass ProgramOptions to tea.NewProgram.
+func SetViewportMode(on bool) {
+	viewportMode = on
+}
+
+// ProgramOptions returns the tea.Program options the current mode needs:
+// viewport mode runs full screen on the alternate screen.
+func ProgramOptions() []tea.ProgramOption {
+	if !viewportMode {
+		return nil
+	}
+	return []tea.ProgramOption{tea.WithAltScreen()}
+}
+
+// PagerAppendMsg adds a block to the transcript pager. Render is called
+// with the pager width whenever the block needs (re)drawing.
+type PagerAppendMsg struct {
+	Render func(width int) string
+}
+
+// PrintRendered prints the output of render to the scrollback. In viewport
+// mode the pager keeps render, so the block is drawn again at the new width
+// when the terminal is resized; otherwise it is rendered once at width.
+func PrintRendered(width int, render func(width int) string) tea.Cmd {
+	if !viewportMode {
+		return PrintToScrollback(render(width))
+	}
+	return func() tea.Msg { return PagerAppendMsg{Render: render} }
+}
+
+// rewrapText returns a render func for pre-rendered text, wrapping it when
+// the pager is narrower than the text.
+func rewrapText(text string) func(int) string {
+	return func(width int) string {
+		return ansi.Wrap(text, max(20, width), " ")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TranscriptPager
+// ---------------------------------------------------------------------------
+
+// TranscriptPager keeps rendered transcript blocks in a scrollable viewport
+// with less-style search.
+type TranscriptPager struct {
+	vp     viewport.Model
+	blocks []func(int) string
+	width  int
+	lines  []string // plain text of the rendered content, for search
+
+	searching bool   // typing a query
+	query     string // last submitted query
+	input     string // query being typed
+	matches   []int  // line numbers matching query
+	match     int    // index into matches of the current match
+}
+
+// NewTranscriptPager creates an empty pager.
+func NewTranscriptPager() *TranscriptPager {
+	vp := viewport.New(80, 10)
+	vp.KeyMap = viewport.KeyMap{} // keys are routed by HandleKey
+	return &TranscriptPager{vp: vp, width: 80}
+}
+
+// Append adds a block, following the output when the view was at the
+// bottom.
+func (p *TranscriptPager) Append(render func(int) string) {
+	p.blocks = append(p.blocks, render)
+	p.refresh()
+}
+
+// Prepend inserts a block above the transcript, such as an earlier page of
+// history, and scrolls to it.
+func (p *TranscriptPager) Prepend(render func(int) string) {
+	p.blocks = append([]func(int) string{render}, p.blocks...)
+	p.refresh()
+	p.vp.GotoTop()
+}
+
+// Clear removes every block.
+func (p *TranscriptPager) Clear() {
+	p.blocks = nil
+	p.query, p.matches = "", nil
+	p.refresh()
+}
+
+// SetWidth re-renders every block when the width changes.
+func (p *TranscriptPager) SetWidth(width int) {
+	if width <= 0 || width == p.width {
+		return
+	}
+	p.width = width
+	p.vp.Width = width
+	p.refresh()
+}
+
+// refresh rebuilds the content from the blocks at the current width.
+func (p *TranscriptPager) refresh() {
+	follow := p.vp.AtBottom()
+	rendered := make([]string, 0, len(p.blocks))
+	for _, render := range p.blocks {
+		if text := stripTrailingBlankLines(render(p.width)); strings.TrimSpace(text) != "" {
+			rendered = append(rendered, text)
+		}
+	}
+	content := strings.Join(rendered, "\n\n")
+	p.vp.SetContent(content)
+	p.lines = strings.Split(strings.ToLower(ansi.Strip(content)), "\n")
+	if p.query != "" {
+		p.findMatches()
+	}
+	if follow {
+		p.vp.GotoBottom()
+	}
+}
+
+// browsing reports whether the user has scrolled up from the newest output.
+func (p *TranscriptPager) browsing() bool {
+	return !p.vp.AtBottom()
+}
+
+// HandleKey handles pager keys and reports whether the key was consumed.
+// PgUp/PgDn and Ctrl+Home/Ctrl+End always scroll and Ctrl+F searches. While
+// scrolled up with an empty input, "/" also starts a search, n/N move
+// between matches, and Esc returns to the newest output.
+func (p *TranscriptPager) HandleKey(msg tea.KeyMsg, inputEmpty bool) bool {
+	if p.searching {
+		p.handleSearchKey(msg)
+		return true
+	}
+	switch msg.Type {
+	case tea.KeyPgUp:
+		p.vp.PageUp()
+		return true
+	case tea.KeyPgDown:
+		p.vp.PageDown()
+		return true
+	case tea.KeyCtrlHome:
+		p.vp.GotoTop()
+		return true
+	case tea.KeyCtrlEnd:
+		p.vp.GotoBottom()
+		return true
+	case tea.KeyCtrlF:
+		p.searching = true
+		p.input = ""
+		return true
+	case tea.KeyEnter:
+		// Submitting jumps back to the conversation.
+		p.vp.GotoBottom()
+		return false
+	}
+	if !inputEmpty {
+		return false
+	}
+	if msg.Type == tea.KeyEsc && (p.browsing() || p.query != "") {
+		p.query, p.matches = "", nil
+		p.vp.GotoBottom()
+		return true
+	}
+	if !p.browsing() {
+		return false
+	}
+	switch msg.String() {
+	case "/":
+		p.searching = true
+		p.input = ""
+		return true
+	case "n":
+		p.step(1)
+		return true
+	case "N":
+		p.step(-1)
+		return true
+	}
+	return false
+}
+
+func (p *TranscriptPager) handleSearchKey(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEsc, tea.KeyCtrlC:
+		p.searching = false
+	case tea.KeyEnter:
+		p.searching = false
+		p.query = strings.ToLower(strings.TrimSpace(p.input))
+		p.findMatches()
+		p.match = -1
+		// Search upwards from the bottom of the view, like reading back.
+		bottom := p.vp.YOffset + p.vp.Height
+		for i := len(p.matches) - 1; i >= 0; i-- {
+			if p.matches[i] < bottom {
+				p.match = i
+				break
+			}
+		}
+		if p.match < 0 && len(p.matches) > 0 {
+			p.match = len(p.matches) - 1
+		}
+		p.show()
+	case tea.KeyBackspace:
+		if r := []rune(p.input); len(r) > 0 {
+			p.input = string(r[:len(r)-1])
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		p.input += string(msg.Runes)
+	}
+}
+
+func (p *TranscriptPager) findMatches() {
+	p.matches = nil
+	if p.query == "" {
+		return
+	}
+	for i, line := range p.lines {
+		if strings.Contains(line, p.query) {
+			p.matches = append(p.matches, i)
+		}
+	}
+	if p.match >= len(p.matches) {
+		p.match = len(p.matches) - 1
+	}
+}
+
+// step moves dir matches back through the transcript (n) or, with a
+// negative dir, forward towards the newest output (N). Searches start at
+// the bottom, so n reads back like less's ?.
+func (p *TranscriptPager) step(dir int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.match = (p.match - dir + len(p.matches)) % len(p.matches)
+	p.show()
+}
+
+// show scrolls the current match to a third of the way down the view.
+func (p *TranscriptPager) show() {
+	if p.match < 0 || p.match >= len(p.matches) {
+		return
+	}
+	p.vp.SetYOffset(p.matches[p.match] - p.vp.Height/3)
+}
+
+// View renders the pager to fill height lines, including its status line.
+func (p *TranscriptPager) View(height int) string {
+	status := p.status()
+	vpHeight := height
+	if status != "" {
+		vpHeight--
+	}
+	vpHeight = max(1, vpHeight)
+	if vpHeight != p.vp.Height {
+		follow := p.vp.AtBottom()
+		p.vp.Height = vpHeight
+		if follow {
+			p.vp.GotoBottom()
+		}
+	}
+	if status == "" {
+		return p.vp.View()
+	}
+	return p.vp.View() + "\n" + status
+}
+
+func (p *TranscriptPager) status() string {
+	switch {
+	case p.searching:
+		return PromptStyle.Render("/") + InputStyle.Render(withInlineCursor(p.input, len([]rune(p.input))))
+	case p.query != "" && len(p.matches) == 0:
+		return FooterMeta.Render(fmt.Sprintf("Pattern not found: %s  (Esc to return)", p.query))
+	case p.query != "" && p.browsing():
+		return FooterMeta.Render(fmt.Sprintf("match %d/%d for %q  n/N next/previous · Esc to return", p.match+1, len(p.matches), p.query))
+	case p.browsing():
+		return FooterMeta.Render(fmt.Sprintf("%3.0f%%  PgUp/PgDn scroll · / search · Esc to return", p.vp.ScrollPercent()*100))
+	}
+	return ""
+}
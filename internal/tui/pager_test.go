@@ -0,0 +1,122 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func numberedLines(prefix string, n int) func(int) string {
+	return func(int) string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = fmt.Sprintf("%s %d", prefix, i)
+		}
+		return strings.Join(lines, "\n")
+	}
+}
+
+func TestTranscriptPager_followsOutput(t *testing.T) {
+	p := NewTranscriptPager()
+	p.View(5)
+	p.Append(numberedLines("first", 20))
+	if !strings.Contains(p.View(5), "first 19") {
+		t.Fatalf("expected newest line in view:\n%s", p.View(5))
+	}
+
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyPgUp}, true)
+	if !p.browsing() {
+		t.Fatal("expected PgUp to leave the bottom")
+	}
+	p.Append(numberedLines("second", 3))
+	if strings.Contains(p.View(5), "second 2") {
+		t.Error("appending should not scroll while browsing")
+	}
+	if !strings.Contains(p.View(5), "PgUp/PgDn") {
+		t.Error("expected browsing status line")
+	}
+
+	if !p.HandleKey(tea.KeyMsg{Type: tea.KeyEsc}, true) || p.browsing() {
+		t.Error("expected Esc to return to the bottom")
+	}
+	if p.HandleKey(tea.KeyMsg{Type: tea.KeyEsc}, true) {
+		t.Error("Esc at the bottom should fall through to the TUI")
+	}
+}
+
+func TestTranscriptPager_rerendersOnResize(t *testing.T) {
+	p := NewTranscriptPager()
+	p.Append(func(width int) string { return fmt.Sprintf("rendered at %d", width) })
+	p.SetWidth(120)
+	if got := p.View(3); !strings.Contains(got, "rendered at 120") {
+		t.Errorf("expected block re-rendered at new width, got:\n%s", got)
+	}
+}
+
+func TestTranscriptPager_search(t *testing.T) {
+	p := NewTranscriptPager()
+	p.View(5)
+	p.Append(func(int) string { return "alpha needle\n" + numberedLines("filler", 30)(0) + "\nomega" })
+
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyCtrlF}, true)
+	for _, r := range "Needle" {
+		p.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}}, true)
+	}
+	if !strings.Contains(p.View(5), "/Needle") {
+		t.Errorf("expected search prompt, got:\n%s", p.View(5))
+	}
+	p.HandleKey(tea.KeyMsg{Type: tea.KeyEnter}, true)
+	if len(p.matches) != 1 || p.matches[0] != 0 {
+		t.Fatalf("matches = %v, want [0]", p.matches)
+	}
+	if view := p.View(5); !strings.Contains(view, "alpha needle") || !strings.Contains(view, "match 1/1") {
+		t.Errorf("expected view scrolled to the match, got:\n%s", view)
+	}
+	if !p.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}}, true) {
+		t.Error("expected n to be handled while browsing a search")
+	}
+	if p.HandleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}}, false) {
+		t.Error("keys should reach the input while it has text")
+	}
+}
+
+func TestTranscriptPager_prependAndClear(t *testing.T) {
+	p := NewTranscriptPager()
+	p.View(5)
+	p.Append(numberedLines("new", 10))
+	p.Prepend(numberedLines("old", 2))
+	if view := p.View(5); !strings.HasPrefix(view, "old 0") {
+		t.Errorf("expected prepended page at the top, got:\n%s", view)
+	}
+	p.Clear()
+	if strings.TrimSpace(p.View(5)) != "" {
+		t.Errorf("expected empty pager after Clear, got:\n%s", p.View(5))
+	}
+}
+
+func TestPrintRendered_viewportMode(t *testing.T) {
+	orig := viewportMode
+	t.Cleanup(func() { viewportMode = orig })
+
+	SetViewportMode(true)
+	if len(ProgramOptions()) == 0 {
+		t.Error("expected alt screen option in viewport mode")
+	}
+	msg, ok := PrintRendered(80, func(w int) string { return fmt.Sprint(w) })().(PagerAppendMsg)
+	if !ok || msg.Render(42) != "42" {
+		t.Errorf("expected PagerAppendMsg, got %#v", msg)
+	}
+	if _, ok := PrintToScrollback("hello")().(PagerAppendMsg); !ok {
+		t.Error("expected PrintToScrollback to feed the pager")
+	}
+
+	SetViewportMode(false)
+	if ProgramOptions() != nil {
+		t.Error("expected no program options outside viewport mode")
+	}
+	if _, ok := PrintToScrollback("hello")().(PagerAppendMsg); ok {
+		t.Error("expected terminal scrollback outside viewport mode")
+	}
+}
@@ -18,11 +18,8 @@ const (
 
 // SessionPicker is an interactive session selector overlay.
 type SessionPicker struct {
-	sessions    []domain.Session
-	filtered    []domain.Session
-	selectedIdx int
-	filter      string
-	active      bool
+	pickerList[domain.Session]
+	active bool
 
 	mode      pickerMode
 	renameBuf string // title being edited in rename mode
@@ -32,12 +29,38 @@ type SessionPicker struct {
 
 // NewSessionPicker creates a picker with the given sessions.
 func NewSessionPicker(sessions []domain.Session) *SessionPicker {
-	return &SessionPicker{
-		sessions: sessions,
-		filtered: sessions,
-		active:   true,
-		selected: make(map[string]bool),
+	p := &SessionPicker{
+		pickerList: newPickerList("session", sessions, sessionFields, func(s domain.Session) string { return s.ID }),
+		active:     true,
+		selected:   make(map[string]bool),
 	}
+	p.detail = sessionDetail
+	return p
+}
+
+// sessionFields are matched by the filter: title, ID, and tags.
+func sessionFields(s domain.Session) []string {
+	return []string{s.Title, s.ID, s.Tags}
+}
+
+// sessionDetail is the preview pane for a session.
+func sessionDetail(s domain.Session) []string {
+	lines := []string{
+		s.Title,
+		fmt.Sprintf("%s  %s  %d msgs  %d tokens", s.ID, s.Model, s.MessageCount, s.TotalTokens),
+		fmt.Sprintf("project: %s", s.ProjectPath),
+		fmt.Sprintf("created %s, updated %s", s.CreatedAt.Local().Format("2006-01-02 15:04"), TimeAgo(s.UpdatedAt)),
+	}
+	if s.Running {
+		lines = append(lines, "running in background")
+	}
+	if s.Tags != "" {
+		lines = append(lines, "tags: "+s.Tags)
+	}
+	if s.ParentSessionID != "" && len(s.ParentSessionID) >= 8 {
+		lines = append(lines, fmt.Sprintf("branched from %s at message %d", s.ParentSessionID[:8], s.BranchPoint))
+	}
+	return lines
 }
 
 // IsActive reports whether the picker is currently shown.
@@ -64,41 +87,6 @@ func (p *SessionPicker) SelectedSession() *domain.Session {
 	return &p.filtered[p.selectedIdx]
 }
 
-// MoveUp moves the selection up.
-func (p *SessionPicker) MoveUp() {
-	if p.selectedIdx > 0 {
-		p.selectedIdx--
-	}
-}
-
-// MoveDown moves the selection down.
-func (p *SessionPicker) MoveDown() {
-	if p.selectedIdx < len(p.filtered)-1 {
-		p.selectedIdx++
-	}
-}
-
-// SetFilter replaces the filter string and re-filters.
-func (p *SessionPicker) SetFilter(f string) {
-	p.filter = f
-	p.applyFilter()
-}
-
-// AppendFilter adds a rune to the filter.
-func (p *SessionPicker) AppendFilter(r rune) {
-	p.filter += string(r)
-	p.applyFilter()
-}
-
-// BackspaceFilter removes the last rune from the filter.
-func (p *SessionPicker) BackspaceFilter() {
-	if len(p.filter) > 0 {
-		runes := []rune(p.filter)
-		p.filter = string(runes[:len(runes)-1])
-		p.applyFilter()
-	}
-}
-
 // StartRename enters rename mode, pre-filling with the current title.
 func (p *SessionPicker) StartRename() {
 	if sel := p.SelectedSession(); sel != nil {
@@ -153,9 +141,9 @@ func (p *SessionPicker) CommitRename() (id, newTitle string) {
 		return "", ""
 	}
 	// Update in both slices.
-	for i := range p.sessions {
-		if p.sessions[i].ID == id {
-			p.sessions[i].Title = newTitle
+	for i := range p.items {
+		if p.items[i].ID == id {
+			p.items[i].Title = newTitle
 			break
 		}
 	}
@@ -179,9 +167,9 @@ func (p *SessionPicker) RemoveSelected() string {
 	}
 	id := sel.ID
 	// Remove from the master sessions list.
-	for i := range p.sessions {
-		if p.sessions[i].ID == id {
-			p.sessions = append(p.sessions[:i], p.sessions[i+1:]...)
+	for i := range p.items {
+		if p.items[i].ID == id {
+			p.items = append(p.items[:i], p.items[i+1:]...)
 			break
 		}
 	}
@@ -254,15 +242,15 @@ func (p *SessionPicker) RemoveSelectedMulti() []string {
 		return nil
 	}
 	removed := make([]string, 0, len(p.selected))
-	kept := p.sessions[:0:0]
-	for _, s := range p.sessions {
+	kept := p.items[:0:0]
+	for _, s := range p.items {
 		if p.selected[s.ID] {
 			removed = append(removed, s.ID)
 		} else {
 			kept = append(kept, s)
 		}
 	}
-	p.sessions = kept
+	p.items = kept
 	p.selected = make(map[string]bool)
 	p.applyFilter()
 	if p.selectedIdx >= len(p.filtered) && p.selectedIdx > 0 {
@@ -273,39 +261,8 @@ func (p *SessionPicker) RemoveSelectedMulti() []string {
 	return removed
 }
 
-func (p *SessionPicker) applyFilter() {
-	if p.filter == "" {
-		p.filtered = p.sessions
-	} else {
-		lower := strings.ToLower(p.filter)
-		p.filtered = nil
-		for _, s := range p.sessions {
-			if fuzzyMatch(s, lower) {
-				p.filtered = append(p.filtered, s)
-			}
-		}
-	}
-	p.selectedIdx = 0
-}
-
-// fuzzyMatch checks if the session matches the filter against title, ID prefix, or tags.
-func fuzzyMatch(s domain.Session, lower string) bool {
-	if strings.Contains(strings.ToLower(s.Title), lower) {
-		return true
-	}
-	if strings.Contains(strings.ToLower(s.ID), lower) {
-		return true
-	}
-	if strings.Contains(strings.ToLower(s.Tags), lower) {
-		return true
-	}
-	return false
-}
-
 // View renders the picker as a string.
 func (p *SessionPicker) View(width int) string {
-	_ = width // reserved for future layout use
-
 	var b strings.Builder
 
 	// Header
@@ -344,15 +301,7 @@ func (p *SessionPicker) View(width int) string {
 		b.WriteString("\n")
 	} else {
 		const maxVisible = 10
-		// Determine visible window
-		start := 0
-		if p.selectedIdx >= maxVisible {
-			start = p.selectedIdx - maxVisible + 1
-		}
-		end := start + maxVisible
-		if end > len(p.filtered) {
-			end = len(p.filtered)
-		}
+		start, end := p.window(maxVisible)
 
 		for i := start; i < end; i++ {
 			s := p.filtered[i]
@@ -383,6 +332,9 @@ func (p *SessionPicker) View(width int) string {
 			if s.ParentSessionID != "" {
 				line += "  \u2514branch"
 			}
+			if s.Running {
+				line += "  \u25cf running"
+			}
 
 			if i == p.selectedIdx {
 				b.WriteString(CompletionSelStyle.Render(line))
@@ -397,6 +349,7 @@ func (p *SessionPicker) View(width int) string {
 			b.WriteString(FooterMeta.Render(more))
 			b.WriteString("\n")
 		}
+		b.WriteString(p.previewView(width))
 	}
 
 	b.WriteString("\n")
@@ -0,0 +1,211 @@
+package tui
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pickerUsageBonusCap caps the usage weight added to a fuzzy score, in
+// units of fuzzyBonusBoundary.
+const pickerUsageBonusCap = 4.0
+
+// pickerList is the filterable, scrollable list shared by the pickers. It
+// ranks filtered items by fuzzy score plus a bonus for recent and frequent
+// picks, then by their original order. An empty filter keeps the original
+// order.
+type pickerList[T any] struct {
+	items       []T
+	filtered    []T
+	filter      string
+	selectedIdx int
+
+	kind   string           // usage bucket, e.g. "session"
+	fields func(T) []string // text the filter matches against
+	key    func(T) string   // identity for usage ranking
+	detail func(T) []string // preview pane lines for the highlighted item
+}
+
+func newPickerList[T any](kind string, items []T, fields func(T) []string, key func(T) string) pickerList[T] {
+	l := pickerList[T]{kind: kind, items: items, fields: fields, key: key}
+	l.applyFilter()
+	return l
+}
+
+// MoveUp moves the selection up.
+func (l *pickerList[T]) MoveUp() {
+	if l.selectedIdx > 0 {
+		l.selectedIdx--
+	}
+}
+
+// MoveDown moves the selection down.
+func (l *pickerList[T]) MoveDown() {
+	if l.selectedIdx < len(l.filtered)-1 {
+		l.selectedIdx++
+	}
+}
+
+// SetFilter replaces the filter string and re-filters.
+func (l *pickerList[T]) SetFilter(f string) {
+	l.filter = f
+	l.applyFilter()
+}
+
+// AppendFilter adds a rune to the filter.
+func (l *pickerList[T]) AppendFilter(r rune) {
+	l.filter += string(r)
+	l.applyFilter()
+}
+
+// BackspaceFilter removes the last rune from the filter.
+func (l *pickerList[T]) BackspaceFilter() {
+	if len(l.filter) == 0 {
+		return
+	}
+	runes := []rune(l.filter)
+	l.filter = string(runes[:len(runes)-1])
+	l.applyFilter()
+}
+
+// current returns the highlighted item.
+func (l *pickerList[T]) current() (T, bool) {
+	if len(l.filtered) == 0 || l.selectedIdx < 0 || l.selectedIdx >= len(l.filtered) {
+		var zero T
+		return zero, false
+	}
+	return l.filtered[l.selectedIdx], true
+}
+
+// recordPick notes that item was chosen, for usage ranking.
+func (l *pickerList[T]) recordPick(item T) {
+	if l.key != nil {
+		pickerUsage.record(l.kind, l.key(item), time.Now())
+	}
+}
+
+func (l *pickerList[T]) applyFilter() {
+	l.selectedIdx = 0
+	pattern := strings.TrimSpace(l.filter)
+	if pattern == "" {
+		l.filtered = l.items
+		return
+	}
+	type ranked struct {
+		item T
+		rank float64
+	}
+	now := time.Now()
+	var matches []ranked
+	for _, it := range l.items {
+		score, ok := fuzzyBest(pattern, l.fields(it))
+		if !ok {
+			continue
+		}
+		r := ranked{item: it, rank: float64(score)}
+		if l.key != nil {
+			usage := pickerUsage.weight(l.kind, l.key(it), now)
+			r.rank += math.Min(usage, pickerUsageBonusCap) * fuzzyBonusBoundary
+		}
+		matches = append(matches, r)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].rank > matches[j].rank })
+	l.filtered = make([]T, len(matches))
+	for i, m := range matches {
+		l.filtered[i] = m.item
+	}
+}
+
+// window returns the range of filtered items to show so that the selection
+// stays visible.
+func (l *pickerList[T]) window(maxVisible int) (start, end int) {
+	if l.selectedIdx >= maxVisible {
+		start = l.selectedIdx - maxVisible + 1
+	}
+	end = min(start+maxVisible, len(l.filtered))
+	return start, end
+}
+
+// previewView renders the preview pane for the highlighted item, or "" if
+// there is nothing to show.
+func (l *pickerList[T]) previewView(width int) string {
+	if l.detail == nil {
+		return ""
+	}
+	it, ok := l.current()
+	if !ok {
+		return ""
+	}
+	return renderPreview(l.detail(it), width)
+}
+
+// renderPreview renders lines as a preview pane below a rule.
+func renderPreview(lines []string, width int) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	if width < 20 {
+		width = 20
+	}
+	var b strings.Builder
+	b.WriteString(FooterMeta.Render("  " + strings.Repeat("─", min(width-4, 60))))
+	b.WriteString("\n")
+	for _, line := range lines {
+		if len(line) > width-4 {
+			line = line[:width-5] + "…"
+		}
+		b.WriteString(FooterMeta.Render("  " + line))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ---------------------------------------------------------------------------
+// Usage ranking
+// ---------------------------------------------------------------------------
+
+// pickerUsageHalfLife is how long it takes a pick to lose half its weight.
+const pickerUsageHalfLife = 24 * time.Hour
+
+type pickStat struct {
+	count int
+	last  time.Time
+}
+
+// pickerUsageStore remembers picks for the lifetime of the process.
+type pickerUsageStore struct {
+	mu    sync.Mutex
+	stats map[string]map[string]pickStat
+}
+
+var pickerUsage = &pickerUsageStore{}
+
+func (s *pickerUsageStore) record(kind, key string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stats == nil {
+		s.stats = make(map[string]map[string]pickStat)
+	}
+	if s.stats[kind] == nil {
+		s.stats[kind] = make(map[string]pickStat)
+	}
+	st := s.stats[kind][key]
+	st.count++
+	st.last = now
+	s.stats[kind][key] = st
+}
+
+// weight is the pick count decayed by time since the last pick, so both
+// frequent and recent picks rank higher.
+func (s *pickerUsageStore) weight(kind, key string, now time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.stats[kind][key]
+	if !ok {
+		return 0
+	}
+	halfLives := float64(now.Sub(st.last)) / float64(pickerUsageHalfLife)
+	return float64(st.count) * math.Exp2(-halfLives)
+}
@@ -0,0 +1,61 @@
+package tui
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestList(kind string, items []string) pickerList[string] {
+	return newPickerList(kind, items, func(s string) []string { return []string{s} }, func(s string) string { return s })
+}
+
+func TestPickerList_RanksByScore(t *testing.T) {
+	l := newTestList(t.Name(), []string{"rename_and_delete", "file_read", "bash"})
+	if len(l.filtered) != 3 || l.filtered[0] != "rename_and_delete" {
+		t.Fatalf("empty filter should keep the original order, got %v", l.filtered)
+	}
+	l.SetFilter("read")
+	if len(l.filtered) != 2 || l.filtered[0] != "file_read" {
+		t.Errorf("filtered = %v, want file_read first", l.filtered)
+	}
+}
+
+func TestPickerList_RanksRecentPicksHigher(t *testing.T) {
+	kind := t.Name()
+	l := newTestList(kind, []string{"alpha_one", "alpha_two"})
+	l.SetFilter("alpha")
+	if l.filtered[0] != "alpha_one" {
+		t.Fatalf("equal scores should keep the original order, got %v", l.filtered)
+	}
+	l.recordPick("alpha_two")
+	l.SetFilter("alpha")
+	if l.filtered[0] != "alpha_two" {
+		t.Errorf("picked item should rank first, got %v", l.filtered)
+	}
+}
+
+func TestPickerUsage_Decays(t *testing.T) {
+	s := &pickerUsageStore{}
+	now := time.Now()
+	s.record("k", "a", now.Add(-pickerUsageHalfLife))
+	s.record("k", "b", now)
+	if wa, wb := s.weight("k", "a", now), s.weight("k", "b", now); wa >= wb || wa < 0.49 || wa > 0.51 {
+		t.Errorf("weights = %v, %v; want a at half of b", wa, wb)
+	}
+	if w := s.weight("k", "missing", now); w != 0 {
+		t.Errorf("unknown key weight = %v", w)
+	}
+}
+
+func TestPickerList_Window(t *testing.T) {
+	l := newTestList(t.Name(), []string{"a", "b", "c", "d", "e"})
+	if start, end := l.window(3); start != 0 || end != 3 {
+		t.Errorf("window = %d,%d, want 0,3", start, end)
+	}
+	for range 4 {
+		l.MoveDown()
+	}
+	if start, end := l.window(3); start != 2 || end != 5 {
+		t.Errorf("window = %d,%d, want 2,5", start, end)
+	}
+}
@@ -534,3 +534,17 @@ func TestSessionPicker_ViewSingleDeleteConfirm(t *testing.T) {
 		t.Error("single-delete view should show session title")
 	}
 }
+
+func TestSessionPicker_RunningInBackground(t *testing.T) {
+	sessions := testSessions()
+	sessions[0].Running = true
+	p := NewSessionPicker(sessions)
+	view := p.View(120)
+	if strings.Count(view, "running") != 2 {
+		t.Errorf("view should mark the running session in its row and preview:\n%s", view)
+	}
+	p.MoveDown()
+	if strings.Contains(p.View(120), "running in background") {
+		t.Error("preview of an idle session mentions a background turn")
+	}
+}
@@ -0,0 +1,107 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------
+// Queued messages
+// ---------------------------------------------------------------------------
+
+// queuedMessage is input submitted while a turn was running. It is sent
+// when the turn ends, in order, as if typed then.
+type queuedMessage struct {
+	text        string
+	attachments []string
+}
+
+// queueInput queues the current input and attachments behind the running
+// turn.
+func (m *Model) queueInput(trimmed string) {
+	m.queued = append(m.queued, queuedMessage{text: trimmed, attachments: m.attachments})
+	m.attachments = nil
+	if trimmed != "" {
+		m.history = append(m.history, trimmed)
+	}
+	m.historyIdx = -1
+	m.historyDraft = ""
+	m.setInput("")
+	m.appendRuntimeLog(fmt.Sprintf("queued: %s (%d waiting)", summarizeForLog(trimmed), len(m.queued)))
+}
+
+// unqueueLast moves the newest queued message back into the empty input
+// for editing. Clearing the input then drops it; Enter queues it again.
+func (m *Model) unqueueLast() bool {
+	if len(m.queued) == 0 || m.input != "" {
+		return false
+	}
+	last := m.queued[len(m.queued)-1]
+	m.queued = m.queued[:len(m.queued)-1]
+	m.attachments = append(last.attachments, m.attachments...)
+	m.setInput(last.text)
+	return true
+}
+
+// sendQueued submits queued messages once the turn is over. A queued slash
+// command that does not start a turn is followed by the next message.
+func (m Model) sendQueued() (Model, tea.Cmd) {
+	m.steering = nil
+	var cmds []tea.Cmd
+	for len(m.queued) > 0 && !m.thinking && !m.pendingAsk && m.pendingApprovalID == "" {
+		next := m.queued[0]
+		m.queued = m.queued[1:]
+		m.attachments = next.attachments
+		updated, cmd := m.submit(next.text)
+		if um, ok := updated.(Model); ok {
+			m = um
+		}
+		cmds = append(cmds, cmd)
+	}
+	return m, tea.Batch(cmds...)
+}
+
+// returnQueued puts queued messages back into the input after a turn is
+// cancelled or fails, so nothing is sent unasked and nothing is lost.
+// Steering notes not yet delivered are dropped with the turn.
+func (m *Model) returnQueued() string {
+	m.steering = nil
+	if len(m.queued) == 0 {
+		return ""
+	}
+	texts := make([]string, 0, len(m.queued)+1)
+	if strings.TrimSpace(m.input) != "" {
+		texts = append(texts, m.input)
+	}
+	for _, q := range m.queued {
+		texts = append(texts, q.text)
+		m.attachments = append(m.attachments, q.attachments...)
+	}
+	n := len(m.queued)
+	m.queued = nil
+	m.setInput(strings.Join(texts, "\n\n"))
+	if n == 1 {
+		return " The queued message is back in the input."
+	}
+	return fmt.Sprintf(" %d queued messages are back in the input.", n)
+}
+
+// renderQueued lists queued messages above the input.
+func (m Model) renderQueued(width int) string {
+	if len(m.queued) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for i, q := range m.queued {
+		text, _, _ := strings.Cut(q.text, "\n")
+		if len(q.attachments) > 0 {
+			text = fmt.Sprintf("%s [+%d attached]", text, len(q.attachments))
+		}
+		label := fmt.Sprintf("  queued %d: ", i+1)
+		b.WriteString(FooterMeta.Render(label+TruncateToWidth(text, max(width-len(label), 10))) + "\n")
+	}
+	b.WriteString(FooterMeta.Render("  ↑ on an empty prompt edits the last one; it is sent after this turn") + "\n\n")
+	return b.String()
+}
@@ -83,6 +83,10 @@ func RenderAssistantLines(content string, width int) []string {
 	firstCodeLinePendingLang := false
 	codeBuf := make([]string, 0, 32)
 
+	// Display math accumulation state.
+	inMath := false
+	var mathBuf []string
+
 	// Table accumulation state.
 	inTable := false
 	var tableHeaders []string
@@ -138,6 +142,32 @@ func RenderAssistantLines(content string, width int) []string {
 			continue
 		}
 
+		// --- Display math ($$ ... $$ or \[ ... \]) ---
+		if delim, inner, oneLine := displayMathBounds(trimmed); delim {
+			if inTable {
+				flushTable()
+			}
+			switch {
+			case oneLine:
+				out = append(out, renderMathBlock(inner, width)...)
+			case inMath:
+				out = append(out, renderMathBlock(strings.Join(mathBuf, " "), width)...)
+				inMath = false
+			default:
+				inMath = true
+				mathBuf = mathBuf[:0]
+			}
+			continue
+		}
+		if inMath {
+			mathBuf = append(mathBuf, trimmed)
+			continue
+		}
+
+		// --- Inline math ($...$ or \(...\)) ---
+		line = RenderInlineMath(line)
+		trimmed = strings.TrimSpace(line)
+
 		// --- Table handling ---
 		isTableRow := tableRowRe.MatchString(trimmed) || tableRowLooseRe.MatchString(trimmed)
 
@@ -262,6 +292,9 @@ func RenderAssistantLines(content string, width int) []string {
 	if inCode {
 		out = append(out, renderHighlightedCodeBlock(codeLang, strings.Join(codeBuf, "\n"), width)...)
 	}
+	if inMath {
+		out = append(out, renderMathBlock(strings.Join(mathBuf, " "), width)...)
+	}
 	if inTable {
 		flushTable()
 	}
@@ -968,6 +1001,8 @@ func FormatConsultResponse(model, text string, width int) string {
 // formatBytes formats a byte count into a human-readable string.
 func formatBytes(b int) string {
 	switch {
+	case b >= 1024*1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(b)/(1024*1024*1024))
 	case b >= 1024*1024:
 		return fmt.Sprintf("%.1f MB", float64(b)/(1024*1024))
 	case b >= 1024:
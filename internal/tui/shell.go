@@ -1,86 +1,45 @@
 package tui
 
 import (
+	"bytes"
+	"context"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"runtime"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/conpty"
+	"github.com/batalabs/muxd/internal/tools"
 )
 
-// findBash locates a bash executable. On Windows it checks common Git Bash
-// paths before falling back to PATH lookup.
-func findBash() string {
-	if runtime.GOOS != "windows" {
-		return "/bin/sh"
-	}
-	// Git Bash common locations.
-	candidates := []string{
-		filepath.Join(os.Getenv("ProgramFiles"), "Git", "bin", "bash.exe"),
-		filepath.Join(os.Getenv("ProgramFiles(x86)"), "Git", "bin", "bash.exe"),
-		filepath.Join(os.Getenv("LOCALAPPDATA"), "Programs", "Git", "bin", "bash.exe"),
-	}
-	for _, p := range candidates {
-		if _, err := os.Stat(p); err == nil {
-			return p
+// RunShellCmd runs a shell command in the given directory with the shell
+// chosen by shell.backend and returns the result via ShellResultMsg. On Windows
+// the command runs under a pseudo console, so programs that check for a
+// terminal behave as they do in a real one; colors are kept and other escape
+// sequences are dropped.
+func RunShellCmd(command, cwd, shellPref string, cols int) tea.Cmd {
+	return func() tea.Msg {
+		shell, err := tools.ResolveShell(shellPref)
+		if err != nil {
+			return ShellResultMsg{Output: "Error: " + err.Error(), Err: err}
 		}
-	}
-	// Fall back to PATH lookup.
-	if p, err := exec.LookPath("bash.exe"); err == nil {
-		return p
-	}
-	return "cmd.exe"
-}
-
-// cmdBuiltins are cmd.exe built-in commands that don't exist as standalone
-// executables and must be routed through cmd.exe.
-var cmdBuiltins = map[string]bool{
-	"dir": true, "type": true, "copy": true, "move": true, "del": true,
-	"ren": true, "rename": true, "cls": true, "set": true, "vol": true,
-	"ver": true, "color": true, "title": true, "mklink": true, "assoc": true,
-	"ftype": true, "pushd": true, "popd": true, "start": true, "erase": true,
-}
-
-// shellForCommand picks the right shell and args for a command on Windows.
-// Returns (shell, args). On non-Windows, always uses bash.
-func shellForCommand(command string) (string, []string) {
-	if runtime.GOOS != "windows" {
-		return findBash(), []string{"-c", command}
-	}
-
-	first := strings.ToLower(strings.Fields(command)[0])
-
-	// PowerShell cmdlets follow Verb-Noun pattern (e.g. Get-Process).
-	if strings.Contains(first, "-") && first[0] >= 'a' && first[0] <= 'z' {
-		if ps, err := exec.LookPath("pwsh.exe"); err == nil {
-			return ps, []string{"-NoProfile", "-Command", command}
+		var result []byte
+		if conpty.Available() {
+			var buf bytes.Buffer
+			var code int
+			code, err = conpty.Run(context.Background(), shell.CommandLine(command), cwd, conpty.Size{Cols: cols}, &buf)
+			if err == nil && code != 0 {
+				err = fmt.Errorf("exit status %d", code)
+			}
+			result = []byte(conpty.Sanitize(buf.String()))
+		} else {
+			c := shell.Command(context.Background(), command)
+			c.Dir = cwd
+			result, err = c.CombinedOutput()
 		}
-		return "powershell.exe", []string{"-NoProfile", "-Command", command}
-	}
-
-	// cmd.exe builtins.
-	if cmdBuiltins[first] {
-		return "cmd.exe", []string{"/c", command}
-	}
-
-	// Default to bash for everything else.
-	shell := findBash()
-	if filepath.Base(shell) == "cmd.exe" {
-		return shell, []string{"/c", command}
-	}
-	return shell, []string{"-c", command}
-}
-
-// RunShellCmd runs a shell command in the given directory and returns the
-// result via ShellResultMsg.
-func RunShellCmd(command, cwd string) tea.Cmd {
-	return func() tea.Msg {
-		shell, args := shellForCommand(command)
-		c := exec.Command(shell, args...)
-		c.Dir = cwd
-		result, err := c.CombinedOutput()
 		output := strings.TrimSpace(string(result))
 		if err != nil && output == "" {
 			output = "Error: " + err.Error()
@@ -121,7 +80,7 @@ func (m Model) handleShellKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, PrintToScrollback(WelcomeStyle.Render("Exited muxd shell."))
 		}
 		if cmd == "/help" {
-			return m, PrintToScrollback(shellHelpText())
+			return m, PrintToScrollback(shellHelpText(m.Prefs.ShellBackend))
 		}
 		if cmd == "" {
 			return m, nil
@@ -139,7 +98,7 @@ func (m Model) handleShellKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		// Echo the command before running it.
 		echo := FooterMeta.Render("$ " + cmd)
-		return m, tea.Batch(PrintToScrollback(echo), RunShellCmd(cmd, m.shellCwd))
+		return m, tea.Batch(PrintToScrollback(echo), RunShellCmd(cmd, m.shellCwd, m.Prefs.ShellBackend, m.width))
 	case tea.KeyCtrlC:
 		m.shellActive = false
 		m.shellInput = ""
@@ -208,7 +167,7 @@ func (m Model) handleShellKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 // shellHelpText returns a formatted help string for the muxd shell.
-func shellHelpText() string {
+func shellHelpText(shellPref string) string {
 	var b strings.Builder
 	b.WriteString(WelcomeStyle.Render("muxd shell") + "\n\n")
 	lines := []struct{ key, desc string }{
@@ -218,8 +177,9 @@ func shellHelpText() string {
 	for _, l := range lines {
 		b.WriteString("  " + FooterHead.Render(l.key) + "  " + FooterMeta.Render(l.desc) + "\n")
 	}
-	b.WriteString("\n" + FooterMeta.Render("  Windows commands (dir, type, etc.) are auto-detected."))
-	b.WriteString("\n" + FooterMeta.Render("  PowerShell cmdlets (Get-Process, etc.) are auto-detected."))
+	if shell, err := tools.ResolveShell(shellPref); err == nil {
+		b.WriteString("\n" + FooterMeta.Render("  Commands run with "+shell.Name()+" (set with shell.backend)."))
+	}
 	b.WriteString("\n" + FooterMeta.Render("  Git branch shown in header (green=clean, yellow=dirty)."))
 	return b.String()
 }
@@ -235,6 +195,7 @@ func (m Model) handleShellCd(cmd string) (tea.Model, tea.Cmd) {
 		}
 		target = home
 	}
+	target = tools.NormalizePath(target)
 	// Resolve relative paths against current shell cwd.
 	if !filepath.IsAbs(target) {
 		target = filepath.Join(m.shellCwd, target)
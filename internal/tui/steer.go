@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ---------------------------------------------------------------------------
+// /steer: notes for the running turn
+// ---------------------------------------------------------------------------
+
+// SteerMsg reports whether the daemon accepted a steering note.
+type SteerMsg struct {
+	Text string
+	Err  error
+}
+
+// SteeredMsg reports steering notes the agent has been given.
+type SteeredMsg struct {
+	Notes []string
+}
+
+// isSteerCommand reports whether input is a /steer command, which runs
+// mid-turn instead of waiting in the queue.
+func isSteerCommand(input string) bool {
+	cmd, _, _ := strings.Cut(input, " ")
+	return strings.EqualFold(cmd, "/steer")
+}
+
+func (m Model) handleSteerCommand(text string) (tea.Model, tea.Cmd) {
+	if text == "" {
+		return m, PrintToScrollback(m.renderError("Usage: /steer <note for the running turn>"))
+	}
+	if m.Daemon == nil || m.Session == nil {
+		return m, PrintToScrollback(m.renderError("Steering needs a daemon session."))
+	}
+	if !m.thinking {
+		return m, PrintToScrollback(m.renderError("Nothing is running; send it as a normal message."))
+	}
+	m.steering = append(m.steering, text)
+	m.appendRuntimeLog("steer: " + summarizeForLog(text))
+	d, sessionID := m.Daemon, m.Session.ID
+	return m, func() tea.Msg {
+		return SteerMsg{Text: text, Err: d.Steer(sessionID, text)}
+	}
+}
+
+func (m Model) handleSteer(msg SteerMsg) (tea.Model, tea.Cmd) {
+	if msg.Err == nil {
+		return m, nil
+	}
+	for i, note := range m.steering {
+		if note == msg.Text {
+			m.steering = append(m.steering[:i:i], m.steering[i+1:]...)
+			break
+		}
+	}
+	return m, PrintToScrollback(m.renderError(msg.Err.Error()))
+}
+
+// handleSteered marks notes delivered and records them in the transcript
+// where the agent saw them.
+func (m Model) handleSteered(msg SteeredMsg) (tea.Model, tea.Cmd) {
+	m.steering = m.steering[min(len(msg.Notes), len(m.steering)):]
+	if len(m.steering) == 0 {
+		m.steering = nil
+	}
+	lines := make([]string, len(msg.Notes))
+	for i, note := range msg.Notes {
+		lines[i] = FooterMeta.Render("  ↳ steered: " + note)
+	}
+	return m, PrintToScrollback(strings.Join(lines, "\n"))
+}
+
+// renderSteering lists notes the agent has not seen yet.
+func (m Model) renderSteering(width int) string {
+	if len(m.steering) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	for _, note := range m.steering {
+		text, _, _ := strings.Cut(note, "\n")
+		label := "  steering: "
+		b.WriteString(FooterMeta.Render(label+TruncateToWidth(text, max(width-len(label), 10))) + "\n")
+	}
+	b.WriteString(FooterMeta.Render(fmt.Sprintf("  the agent sees %s before its next step", pluralNote(len(m.steering)))) + "\n\n")
+	return b.String()
+}
+
+func pluralNote(n int) string {
+	if n == 1 {
+		return "it"
+	}
+	return "them"
+}
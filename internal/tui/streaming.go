@@ -7,19 +7,34 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/guardrail"
 )
 
 // flushStreamContent checks whether enough complete paragraphs have
 // accumulated in the unflushed portion of streamBuf.
+// In guardrail block mode nothing is flushed until the reply is screened.
 func (m *Model) flushStreamContent() tea.Cmd {
+	if m.Prefs.TUIGuardrail().Mode == guardrail.ModeBlock {
+		return nil
+	}
 	unflushed := m.streamBuf[m.streamFlushedLen:]
 	n := FindSafeFlushPoint(unflushed)
 	if n == 0 {
 		return nil
 	}
 
-	contentWidth := max(20, m.width-4)
 	text := unflushed[:n]
+	first := m.streamFlushedLen == 0
+	m.streamFlushedLen += n
+	return PrintRendered(m.width, func(width int) string {
+		return renderStreamChunk(text, first, width)
+	})
+}
+
+// renderStreamChunk renders part of a streamed reply. The first chunk of a
+// reply carries the assistant bullet; later ones are indented to match.
+func renderStreamChunk(text string, first bool, width int) string {
+	contentWidth := max(20, width-4)
 	lines := RenderAssistantLines(text, contentWidth-2)
 
 	var b strings.Builder
@@ -27,20 +42,50 @@ func (m *Model) flushStreamContent() tea.Cmd {
 		if i > 0 {
 			b.WriteString("\n")
 		}
-		if i == 0 && m.streamFlushedLen == 0 {
+		if i == 0 && first {
 			b.WriteString(AsstIconStyle.Render("\u25cf ") + line)
 		} else {
 			b.WriteString("  " + line)
 		}
 	}
+	return b.String()
+}
 
-	m.streamFlushedLen += n
-	return PrintToScrollback(b.String())
+// errorHint suggests what to do about a failed turn, by error code.
+func (m Model) errorHint(code domain.ErrorCode) string {
+	provName := "<provider>"
+	if m.Provider != nil {
+		provName = m.Provider.Name()
+	}
+	switch code {
+	case domain.ErrRateLimited:
+		return "the provider is rate limiting requests. Wait a moment and resend, or switch models with /config set model."
+	case domain.ErrOverloaded:
+		return "the provider is overloaded. Try again shortly, or switch providers with /config set model."
+	case domain.ErrAuthInvalid:
+		return fmt.Sprintf("the API key was rejected. Check it with /config set %s.api_key <key>.", provName)
+	case domain.ErrContextExceeded:
+		return "the conversation no longer fits the model's context. See /context, start over with /new, or /fork from an earlier message."
+	case domain.ErrModelNotFound:
+		if provName == "ollama" {
+			return "the model is not installed on the Ollama server. /ollama lists the installed ones."
+		}
+		return "the provider does not know this model. Pick another with /config set model."
+	case domain.ErrNetwork:
+		if provName == "ollama" {
+			return "could not reach the Ollama server. Start it with `ollama serve`, or check ollama.url; /ollama shows its status."
+		}
+		return "could not reach the provider. Check your connection, or proxy.url if you are behind a proxy."
+	case domain.ErrLoopLimit:
+		return "the agent stopped after too many steps. Send \"continue\" to let it carry on."
+	}
+	return ""
 }
 
 func (m Model) handleStreamDone(msg StreamDoneMsg) (tea.Model, tea.Cmd) {
 	if msg.Err != nil {
 		m.thinking = false
+		m.stopping = false
 		m.streaming = false
 		m.streamBuf = ""
 		m.streamFlushedLen = 0
@@ -63,18 +108,27 @@ func (m Model) handleStreamDone(msg StreamDoneMsg) (tea.Model, tea.Cmd) {
 						m.thinking = true
 						return m, tea.Batch(
 							PrintToScrollback(notice),
-							StreamViaDaemon(m.Daemon, m.Session.ID, m.lastSubmitText, m.lastSubmitImages),
+							StreamViaDaemon(m.Daemon, m.Session.ID, m.lastSubmitText, m.lastSubmitAttachments),
 							m.spinner.Tick,
 						)
 					}
 				}
 				m.appendRuntimeLog("session_recovery_failed: " + err.Error())
 			}
-			return m, PrintToScrollback(m.renderError("Error: " + msg.Err.Error() + "\nhint: session may have been lost. Use /new to start a new session."))
+			return m, PrintToScrollback(m.renderError("Error: " + msg.Err.Error() + "\nhint: session may have been lost. Use /new to start a new session." + m.returnQueued()))
 		}
 
 		errText := "Error: " + msg.Err.Error()
-		return m, PrintToScrollback(m.renderError(errText))
+		if hint := m.errorHint(msg.ErrCode); hint != "" {
+			errText += "\nhint: " + hint
+		}
+		errText += m.returnQueued()
+		printErr := PrintToScrollback(m.renderError(errText))
+		if msg.ErrCode == domain.ErrModelNotFound && m.usingOllama() && m.Daemon != nil {
+			next, offer := m.offerOllamaPull(m.modelID)
+			return next, tea.Sequence(printErr, offer)
+		}
+		return m, printErr
 	}
 
 	// Update token counts
@@ -86,6 +140,7 @@ func (m Model) handleStreamDone(msg StreamDoneMsg) (tea.Model, tea.Cmd) {
 	m.cacheReadInputTokens += msg.CacheReadInputTokens
 	m.lastCacheCreationInputTokens = msg.CacheCreationInputTokens
 	m.lastCacheReadInputTokens = msg.CacheReadInputTokens
+	m.ctxUsage = contextUsage{tokens: msg.ContextTokens, window: msg.ContextWindow, compactAt: msg.CompactAt}
 	m.appendRuntimeLog(fmt.Sprintf(
 		"stream_done: stop=%s in=%d out=%d cache_create=%d cache_read=%d",
 		msg.StopReason,
@@ -95,26 +150,40 @@ func (m Model) handleStreamDone(msg StreamDoneMsg) (tea.Model, tea.Cmd) {
 		msg.CacheReadInputTokens,
 	))
 
+	// Screen the reply before the rest of it is shown.
+	screen := m.Prefs.TUIGuardrail().Screen(m.streamBuf)
+	if screen.Flagged() {
+		m.appendRuntimeLog("guardrail: " + screen.Summary())
+	}
+
 	// Flush any remaining streamed content to viewLines before clearing
 	var cmd tea.Cmd
-	if m.streamBuf != "" {
+	if screen.Blocked {
+		cmd = PrintToScrollback(m.renderError("Reply withheld by guardrail (" + screen.Summary() + ")."))
+	} else if m.streamBuf != "" {
 		unflushed := m.streamBuf[m.streamFlushedLen:]
-		if strings.TrimSpace(unflushed) != "" {
-			contentWidth := max(20, m.width-4)
-			lines := RenderAssistantLines(unflushed, contentWidth-2)
+		first := m.streamFlushedLen == 0
+		var flagged string
+		if screen.Flagged() {
+			flagged = m.renderError("Guardrail flagged this reply: " + screen.Summary())
+		}
+		cmd = PrintRendered(m.width, func(width int) string {
 			var b strings.Builder
-			for i, line := range lines {
-				if i > 0 {
+			if strings.TrimSpace(unflushed) != "" {
+				b.WriteString(renderStreamChunk(unflushed, first, width))
+			}
+			if flagged != "" {
+				if b.Len() > 0 {
 					b.WriteString("\n")
 				}
-				if i == 0 && m.streamFlushedLen == 0 {
-					b.WriteString(AsstIconStyle.Render("\u25cf ") + line)
-				} else {
-					b.WriteString("  " + line)
-				}
+				b.WriteString(flagged)
 			}
-			cmd = PrintToScrollback(b.String())
-		}
+			return b.String()
+		})
+	}
+
+	if !screen.Blocked {
+		m.turnReply += m.streamBuf + "\n"
 	}
 
 	// Reset streaming state for next API call in the agent loop
@@ -125,55 +194,3 @@ func (m Model) handleStreamDone(msg StreamDoneMsg) (tea.Model, tea.Cmd) {
 
 	return m, cmd
 }
-
-// ---------------------------------------------------------------------------
-// Context compaction
-// ---------------------------------------------------------------------------
-
-const (
-	// CompactKeepTail is the number of recent messages to keep during compaction.
-	CompactKeepTail = 20
-)
-
-// CompactMessages trims the middle of a conversation to fit within token limits.
-func CompactMessages(msgs []domain.TranscriptMessage) []domain.TranscriptMessage {
-	if len(msgs) <= CompactKeepTail+2 {
-		return msgs
-	}
-
-	headEnd := 0
-	for i, m := range msgs {
-		if m.Role == "assistant" {
-			headEnd = i + 1
-			break
-		}
-	}
-	if headEnd == 0 {
-		headEnd = 1
-	}
-	head := msgs[:headEnd]
-
-	tailStart := len(msgs) - CompactKeepTail
-	if tailStart <= headEnd {
-		return msgs
-	}
-	for tailStart < len(msgs) && msgs[tailStart].Role != "user" {
-		tailStart++
-	}
-	if tailStart >= len(msgs) {
-		return msgs
-	}
-	tail := msgs[tailStart:]
-
-	dropped := tailStart - headEnd
-	notice := fmt.Sprintf("[%d earlier messages compacted to save context]", dropped)
-
-	compacted := make([]domain.TranscriptMessage, 0, len(head)+2+len(tail))
-	compacted = append(compacted, head...)
-	compacted = append(compacted,
-		domain.TranscriptMessage{Role: "user", Content: notice},
-		domain.TranscriptMessage{Role: "assistant", Content: "Understood. I'll continue with the context available."},
-	)
-	compacted = append(compacted, tail...)
-	return compacted
-}
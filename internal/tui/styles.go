@@ -34,6 +34,7 @@ var (
 	BlockquoteStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
 	TableBorderStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
 	TableHeaderStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("111"))
+	MathStyle          = lipgloss.NewStyle().Foreground(lipgloss.Color("180")).Italic(true)
 
 	CompletionStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("245"))
 	CompletionSelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Background(lipgloss.Color("62"))
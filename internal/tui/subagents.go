@@ -0,0 +1,65 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/daemon"
+)
+
+// ---------------------------------------------------------------------------
+// spawn_agent progress
+// ---------------------------------------------------------------------------
+
+// SubAgentMsg reports progress of a spawn_agent worker.
+type SubAgentMsg struct {
+	Info daemon.SubAgentInfo
+}
+
+// handleSubAgent records a worker's latest progress for the spinner area.
+func (m Model) handleSubAgent(msg SubAgentMsg) (tea.Model, tea.Cmd) {
+	for i := range m.subAgents {
+		if m.subAgents[i].ID == msg.Info.ID {
+			m.subAgents[i] = msg.Info
+			return m, nil
+		}
+	}
+	m.subAgents = append(m.subAgents, msg.Info)
+	m.appendRuntimeLog("subagent: " + msg.Info.Name + " " + msg.Info.State)
+	return m, nil
+}
+
+// renderSubAgents renders running and finished workers as a tree under the
+// spinner, one line each.
+func renderSubAgents(agents []daemon.SubAgentInfo) string {
+	var b strings.Builder
+	for i, a := range agents {
+		branch := "├─ "
+		if i == len(agents)-1 {
+			branch = "└─ "
+		}
+		b.WriteString("  " + branch + subAgentLine(a) + "\n")
+	}
+	return b.String()
+}
+
+func subAgentLine(a daemon.SubAgentInfo) string {
+	parts := []string{a.Name, a.State}
+	turns := fmt.Sprintf("turn %d", a.Turns)
+	if a.MaxTurns > 0 {
+		turns += fmt.Sprintf("/%d", a.MaxTurns)
+	}
+	parts = append(parts, turns)
+	if a.Tokens > 0 {
+		parts = append(parts, formatTokenCount(int64(a.Tokens))+" tokens")
+	}
+	switch {
+	case a.State == "running" && a.Tool != "":
+		parts = append(parts, a.Tool)
+	case a.Error != "":
+		parts = append(parts, a.Error)
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,33 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/batalabs/muxd/internal/daemon"
+)
+
+func TestHandleSubAgent(t *testing.T) {
+	m := Model{}
+	for _, info := range []daemon.SubAgentInfo{
+		{ID: "a", Name: "docs", State: "running", Tool: "grep", Turns: 1, MaxTurns: 10, Tokens: 1500},
+		{ID: "b", Name: "tests", State: "running", MaxTurns: 5},
+		{ID: "a", Name: "docs", State: "stopped", Turns: 10, MaxTurns: 10, Tokens: 12000, Error: "sub-agent turn limit reached (10 turns)"},
+	} {
+		next, _ := m.handleSubAgent(SubAgentMsg{Info: info})
+		m = next.(Model)
+	}
+	if len(m.subAgents) != 2 || m.subAgents[0].State != "stopped" {
+		t.Fatalf("subAgents = %+v", m.subAgents)
+	}
+
+	got := renderSubAgents(m.subAgents)
+	want := "  ├─ docs · stopped · turn 10/10 · 12.0k tokens · sub-agent turn limit reached (10 turns)\n" +
+		"  └─ tests · running · turn 0/5\n"
+	if got != want {
+		t.Errorf("renderSubAgents =\n%s\nwant\n%s", got, want)
+	}
+	if line := subAgentLine(daemon.SubAgentInfo{Name: "docs", State: "running", Tool: "grep", Turns: 1}); !strings.HasSuffix(line, " · grep") {
+		t.Errorf("running line %q should end with the current tool", line)
+	}
+}
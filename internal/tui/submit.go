@@ -1,7 +1,6 @@
 package tui
 
 import (
-	"encoding/base64"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +13,7 @@ import (
 	"github.com/batalabs/muxd/internal/daemon"
 	"github.com/batalabs/muxd/internal/docread"
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/provider"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
@@ -36,7 +36,7 @@ func (m Model) submit(trimmed string) (tea.Model, tea.Cmd) {
 		if m.Provider != nil {
 			provName = m.Provider.Name()
 		}
-		if provName != "ollama" && provName != "" {
+		if provName != "" && provider.RequiresAPIKey(provName) {
 			// Re-resolve from prefs in case the key was set after startup
 			if key, err := config.LoadProviderAPIKey(m.Prefs, provName); err == nil {
 				m.APIKey = key
@@ -67,34 +67,21 @@ func (m Model) submit(trimmed string) (tea.Model, tea.Cmd) {
 	}
 
 	var userMsg domain.TranscriptMessage
-	var images []daemon.SubmitImage
+	var attachments []daemon.SubmitAttachment
 
-	if len(imgPaths) > 0 {
+	// Inline image paths and /attach files both go as attachments.
+	for _, p := range append(imgPaths, m.attachments...) {
+		att, err := loadAttachment(p)
+		if err != nil {
+			return m, PrintToScrollback(m.renderError(err.Error()))
+		}
+		attachments = append(attachments, att)
+	}
+
+	if len(attachments) > 0 {
 		var blocks []domain.ContentBlock
-		for _, imgPath := range imgPaths {
-			data, err := os.ReadFile(imgPath)
-			if err != nil {
-				return m, PrintToScrollback(m.renderError(fmt.Sprintf("Failed to read image: %v", err)))
-			}
-			if len(data) > 20*1024*1024 {
-				return m, PrintToScrollback(m.renderError(fmt.Sprintf("Image too large (max 20MB): %s", filepath.Base(imgPath))))
-			}
-			mediaType := tools.MediaTypeFromExt(imgPath)
-			if mediaType == "" {
-				continue
-			}
-			b64 := base64.StdEncoding.EncodeToString(data)
-			blocks = append(blocks, domain.ContentBlock{
-				Type:       "image",
-				MediaType:  mediaType,
-				Base64Data: b64,
-				ImagePath:  filepath.Base(imgPath),
-			})
-			images = append(images, daemon.SubmitImage{
-				Path:      filepath.Base(imgPath),
-				MediaType: mediaType,
-				Data:      b64,
-			})
+		for _, att := range attachments {
+			blocks = append(blocks, attachmentBlock(att))
 		}
 		if remainingText != "" {
 			blocks = append(blocks, domain.ContentBlock{Type: "text", Text: remainingText})
@@ -108,7 +95,10 @@ func (m Model) submit(trimmed string) (tea.Model, tea.Cmd) {
 	}
 
 	m.messages = append(m.messages, userMsg)
-	m.history = append(m.history, trimmed)
+	m.attachments = nil
+	if trimmed != "" {
+		m.history = append(m.history, trimmed)
+	}
 	m.historyIdx = -1
 	m.historyDraft = ""
 	m.setInput("")
@@ -117,23 +107,25 @@ func (m Model) submit(trimmed string) (tea.Model, tea.Cmd) {
 	m.streamBuf = ""
 	m.streamFlushedLen = 0
 	m.turnToolCount = 0
+	m.turnReply = ""
 	m.turnFilesChanged = nil
+	m.subAgents = nil
 	m.turnStartTime = time.Now()
 	m.turnCurrentTool = ""
+	m.turnRunning = nil
 	m.appendRuntimeLog("submit: " + summarizeForLog(trimmed))
 
 	submitText := trimmed
-	if len(images) > 0 {
+	if len(attachments) > 0 {
 		submitText = remainingText
 	}
 
 	m.lastSubmitText = submitText
-	m.lastSubmitImages = images
+	m.lastSubmitAttachments = attachments
 
-	formatted := FormatMessageForScrollback(userMsg, m.width)
 	cmds := []tea.Cmd{
-		PrintToScrollback(formatted),
-		StreamViaDaemon(m.Daemon, m.Session.ID, submitText, images),
+		PrintRendered(m.width, func(width int) string { return FormatMessageForScrollback(userMsg, width) }),
+		StreamViaDaemon(m.Daemon, m.Session.ID, submitText, attachments),
 		m.spinner.Tick,
 	}
 	return m, tea.Batch(cmds...)
@@ -145,48 +137,12 @@ func (m Model) submit(trimmed string) (tea.Model, tea.Cmd) {
 
 // StreamViaDaemon sends a message to the daemon via HTTP SSE and dispatches
 // events to the TUI via Prog.Send().
-func StreamViaDaemon(d *daemon.DaemonClient, sessionID, text string, images []daemon.SubmitImage) tea.Cmd {
+func StreamViaDaemon(d *daemon.DaemonClient, sessionID, text string, attachments []daemon.SubmitAttachment) tea.Cmd {
 	return func() tea.Msg {
 		if d == nil {
 			return StreamDoneMsg{Err: fmt.Errorf("no daemon connection")}
 		}
-		err := d.Submit(sessionID, text, images, func(evt daemon.SSEEvent) {
-			if Prog == nil {
-				return
-			}
-			switch evt.Type {
-			case "delta":
-				Prog.Send(StreamDeltaMsg{Text: evt.DeltaText})
-			case "tool_start":
-				Prog.Send(ToolStatusMsg{Name: evt.ToolName, Status: "running", Input: evt.ToolInput})
-			case "tool_done":
-				Prog.Send(ToolResultMsg{Name: evt.ToolName, Result: evt.ToolResult, IsError: evt.ToolIsError})
-			case "stream_done":
-				Prog.Send(StreamDoneMsg{
-					InputTokens:              evt.InputTokens,
-					OutputTokens:             evt.OutputTokens,
-					CacheCreationInputTokens: evt.CacheCreationInputTokens,
-					CacheReadInputTokens:     evt.CacheReadInputTokens,
-					StopReason:               evt.StopReason,
-				})
-			case "ask_user":
-				Prog.Send(AskUserMsg{Prompt: evt.AskPrompt, AskID: evt.AskID})
-			case "turn_done":
-				Prog.Send(TurnDoneMsg{StopReason: evt.StopReason})
-			case "retrying":
-				Prog.Send(RetryingMsg{
-					Attempt: evt.RetryAttempt,
-					WaitMs:  evt.RetryWaitMs,
-					Message: evt.RetryMessage,
-				})
-			case "error":
-				Prog.Send(StreamDoneMsg{Err: fmt.Errorf("%s", evt.ErrorMsg)})
-			case "compacted":
-				Prog.Send(CompactedMsg{ModelUsed: evt.ModelUsed})
-			case "titled":
-				Prog.Send(TitledMsg{Title: evt.Title, Tags: evt.Tags, ModelUsed: evt.ModelUsed})
-			}
-		})
+		err := d.Submit(sessionID, text, attachments, dispatchDaemonEvent)
 		if err != nil {
 			return StreamDoneMsg{Err: err}
 		}
@@ -194,6 +150,92 @@ func StreamViaDaemon(d *daemon.DaemonClient, sessionID, text string, images []da
 	}
 }
 
+// ReattachViaDaemon follows a turn of the session that kept running in the
+// daemon after the TUI detached, dispatching its events like
+// StreamViaDaemon. It does nothing when no turn is running.
+func ReattachViaDaemon(d *daemon.DaemonClient, sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		if d == nil {
+			return nil
+		}
+		status, err := d.GetTurnStatus(sessionID)
+		if err != nil || !status.Running {
+			return nil
+		}
+		if Prog != nil {
+			Prog.Send(TurnReattachedMsg{SessionID: sessionID})
+		}
+		if err := d.FollowTurn(sessionID, status.LastEvent, dispatchDaemonEvent); err != nil {
+			return StreamDoneMsg{Err: err}
+		}
+		return nil
+	}
+}
+
+// dispatchDaemonEvent forwards a daemon stream event to the TUI.
+func dispatchDaemonEvent(evt daemon.SSEEvent) {
+	if Prog == nil {
+		return
+	}
+	switch evt.Type {
+	case "delta":
+		Prog.Send(StreamDeltaMsg{Text: evt.DeltaText})
+	case "tool_start":
+		Prog.Send(ToolStatusMsg{ID: evt.ToolUseID, Name: evt.ToolName, Status: "running", Input: evt.ToolInput})
+	case "tool_done":
+		Prog.Send(ToolResultMsg{ID: evt.ToolUseID, Name: evt.ToolName, Result: evt.ToolResult, IsError: evt.ToolIsError})
+	case "stream_done":
+		Prog.Send(StreamDoneMsg{
+			InputTokens:              evt.InputTokens,
+			OutputTokens:             evt.OutputTokens,
+			CacheCreationInputTokens: evt.CacheCreationInputTokens,
+			CacheReadInputTokens:     evt.CacheReadInputTokens,
+			ContextTokens:            evt.ContextTokens,
+			ContextWindow:            evt.ContextWindow,
+			CompactAt:                evt.CompactAt,
+			StopReason:               evt.StopReason,
+		})
+	case "ask_user":
+		Prog.Send(AskUserMsg{Prompt: evt.AskPrompt, AskID: evt.AskID, Options: evt.AskOptions})
+	case "approval_required":
+		Prog.Send(ApprovalRequiredMsg{ApprovalID: evt.ApprovalID, ToolName: evt.ToolName, Input: evt.ToolInput})
+	case "turn_done":
+		Prog.Send(TurnDoneMsg{StopReason: evt.StopReason})
+	case "retrying":
+		Prog.Send(RetryingMsg{
+			Attempt: evt.RetryAttempt,
+			WaitMs:  evt.RetryWaitMs,
+			Message: evt.RetryMessage,
+		})
+	case "budget_warning":
+		if evt.Budget != nil {
+			Prog.Send(BudgetWarningMsg{Message: evt.Budget.Message, Key: evt.Budget.Key})
+		}
+	case "error":
+		errMsg := evt.ErrorMsg
+		if evt.Budget != nil {
+			errMsg += " (/config set " + evt.Budget.Key + " <usd>)"
+		}
+		Prog.Send(StreamDoneMsg{Err: fmt.Errorf("%s", errMsg), ErrCode: evt.ErrorCode})
+	case "subagent":
+		if evt.SubAgent != nil {
+			Prog.Send(SubAgentMsg{Info: *evt.SubAgent})
+		}
+	case "verified":
+		if evt.Verify != nil {
+			Prog.Send(VerifiedMsg{Info: *evt.Verify})
+		}
+	case "steered":
+		Prog.Send(SteeredMsg{Notes: evt.Steering})
+	case "compacted":
+		Prog.Send(CompactedMsg{ModelUsed: evt.ModelUsed})
+	case "titled":
+		Prog.Send(TitledMsg{Title: evt.Title, Tags: evt.Tags, ModelUsed: evt.ModelUsed})
+	case "diagram":
+		Prog.Send(DiagramMsg{Kind: evt.DiagramKind, Path: evt.DiagramPath, Err: evt.ErrorMsg})
+	}
+}
+
 // SendAskResponseCmd sends the user's answer to the daemon for a pending ask_user.
 func SendAskResponseCmd(d *daemon.DaemonClient, sessionID, askID, answer string) tea.Cmd {
 	return func() tea.Msg {
@@ -207,6 +249,19 @@ func SendAskResponseCmd(d *daemon.DaemonClient, sessionID, askID, answer string)
 	}
 }
 
+// SendApprovalCmd sends the user's decision for a pending tool approval.
+func SendApprovalCmd(d *daemon.DaemonClient, sessionID, approvalID, decision string) tea.Cmd {
+	return func() tea.Msg {
+		if d == nil {
+			return nil
+		}
+		if err := d.SendApproval(sessionID, approvalID, decision); err != nil {
+			return StreamDoneMsg{Err: err}
+		}
+		return nil
+	}
+}
+
 // ConsultCmd sends a consult request to the daemon and returns a ConsultResponseMsg.
 func ConsultCmd(d *daemon.DaemonClient, sessionID, question string) tea.Cmd {
 	return func() tea.Msg {
@@ -220,3 +275,12 @@ func ConsultCmd(d *daemon.DaemonClient, sessionID, question string) tea.Cmd {
 		return ConsultResponseMsg{Model: model, Text: response}
 	}
 }
+
+// CompactCmd asks the daemon to summarize the session's earlier messages and
+// returns a CompactDoneMsg.
+func CompactCmd(d *daemon.DaemonClient, sessionID string) tea.Cmd {
+	return func() tea.Msg {
+		res, err := d.Compact(sessionID)
+		return CompactDoneMsg{Result: res, Err: err}
+	}
+}
@@ -5,18 +5,17 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/batalabs/muxd/internal/daemon"
 	"github.com/batalabs/muxd/internal/tools"
 )
 
 // ToolPicker is an interactive overlay for enabling/disabling tools.
 type ToolPicker struct {
-	names       []string
-	filtered    []string
-	filter      string
-	baseline    map[string]bool
-	disabled    map[string]bool
-	selectedIdx int
-	active      bool
+	pickerList[string]
+	names    []string
+	baseline map[string]bool
+	disabled map[string]bool
+	active   bool
 }
 
 // NewToolPicker creates a tool picker with the given tool names and disabled set.
@@ -31,40 +30,89 @@ func NewToolPicker(names []string, disabled map[string]bool) *ToolPicker {
 			disabledCopy[k] = true
 		}
 	}
-	return &ToolPicker{
-		names:    copiedNames,
-		filtered: copiedNames,
-		baseline: baselineCopy,
-		disabled: disabledCopy,
-		active:   true,
+	p := &ToolPicker{
+		pickerList: newPickerList("tool", copiedNames, toolFields, func(n string) string { return n }),
+		names:      copiedNames,
+		baseline:   baselineCopy,
+		disabled:   disabledCopy,
+		active:     true,
 	}
+	p.detail = toolDetail
+	return p
 }
 
-func (p *ToolPicker) IsActive() bool {
-	return p != nil && p.active
+// toolFields are matched by the filter: the display and registered names.
+func toolFields(name string) []string {
+	return []string{tools.ToolDisplayName(name), name}
 }
 
-func (p *ToolPicker) Dismiss() {
-	p.active = false
+// toolDetail is the preview pane for a tool: its description and risk tags.
+func toolDetail(name string) []string {
+	var lines []string
+	if def, ok := tools.FindTool(name); ok && def.Spec.Description != "" {
+		lines = append(lines, shortToolDescription(def.Spec.Description))
+	}
+	if risk := tools.ToolRiskTags(name); len(risk) > 0 {
+		lines = append(lines, "risk: "+strings.Join(risk, ", "))
+	}
+	return lines
 }
 
-func (p *ToolPicker) MoveUp() {
-	if p.selectedIdx > 0 {
-		p.selectedIdx--
+// daemonToolDetail is the preview pane for a tool the daemon reported:
+// its description, where it comes from, profile membership and risk tags.
+func daemonToolDetail(ti daemon.ToolInfo) []string {
+	var lines []string
+	if ti.Description != "" {
+		lines = append(lines, shortToolDescription(ti.Description))
+	}
+	if ti.Source != "" && ti.Source != "builtin" {
+		lines = append(lines, "source: "+ti.Source)
+	}
+	profiles := "none"
+	if len(ti.Profiles) > 0 {
+		profiles = strings.Join(ti.Profiles, ", ")
 	}
+	lines = append(lines, "profiles: "+profiles)
+	if risk := tools.ToolRiskTags(ti.Name); len(risk) > 0 {
+		lines = append(lines, "risk: "+strings.Join(risk, ", "))
+	}
+	return lines
 }
 
-func (p *ToolPicker) MoveDown() {
-	if p.selectedIdx < len(p.filtered)-1 {
-		p.selectedIdx++
+func shortToolDescription(desc string) string {
+	desc = strings.Join(strings.Fields(desc), " ")
+	if len(desc) > 240 {
+		desc = desc[:240] + "..."
 	}
+	return desc
 }
 
-func (p *ToolPicker) SelectedName() string {
-	if len(p.filtered) == 0 || p.selectedIdx < 0 || p.selectedIdx >= len(p.filtered) {
-		return ""
+// SetToolInfo makes the preview pane show what the daemon reported for
+// each tool, so remote MCP and custom tools get a description too.
+func (p *ToolPicker) SetToolInfo(infos []daemon.ToolInfo) {
+	byName := make(map[string]daemon.ToolInfo, len(infos))
+	for _, ti := range infos {
+		byName[ti.Name] = ti
+	}
+	p.detail = func(name string) []string {
+		if ti, ok := byName[name]; ok {
+			return daemonToolDetail(ti)
+		}
+		return toolDetail(name)
 	}
-	return p.filtered[p.selectedIdx]
+}
+
+func (p *ToolPicker) IsActive() bool {
+	return p != nil && p.active
+}
+
+func (p *ToolPicker) Dismiss() {
+	p.active = false
+}
+
+func (p *ToolPicker) SelectedName() string {
+	name, _ := p.current()
+	return name
 }
 
 func (p *ToolPicker) ToggleSelected() {
@@ -77,6 +125,7 @@ func (p *ToolPicker) ToggleSelected() {
 	} else {
 		p.disabled[name] = true
 	}
+	p.recordPick(name)
 }
 
 func (p *ToolPicker) DisabledMap() map[string]bool {
@@ -114,37 +163,6 @@ func (p *ToolPicker) ResetToBaseline() {
 	}
 }
 
-func (p *ToolPicker) AppendFilter(r rune) {
-	p.filter += string(r)
-	p.applyFilter()
-}
-
-func (p *ToolPicker) BackspaceFilter() {
-	if len(p.filter) == 0 {
-		return
-	}
-	rs := []rune(p.filter)
-	p.filter = string(rs[:len(rs)-1])
-	p.applyFilter()
-}
-
-func (p *ToolPicker) applyFilter() {
-	if strings.TrimSpace(p.filter) == "" {
-		p.filtered = append([]string(nil), p.names...)
-		p.selectedIdx = 0
-		return
-	}
-	needle := strings.ToLower(strings.TrimSpace(p.filter))
-	p.filtered = nil
-	for _, n := range p.names {
-		display := strings.ToLower(tools.ToolDisplayName(n))
-		if strings.Contains(display, needle) || strings.Contains(strings.ToLower(n), needle) {
-			p.filtered = append(p.filtered, n)
-		}
-	}
-	p.selectedIdx = 0
-}
-
 func (p *ToolPicker) View(width int) string {
 	if width < 50 {
 		width = 50
@@ -165,14 +183,7 @@ func (p *ToolPicker) View(width int) string {
 	}
 
 	const maxVisible = 12
-	start := 0
-	if p.selectedIdx >= maxVisible {
-		start = p.selectedIdx - maxVisible + 1
-	}
-	end := start + maxVisible
-	if end > len(p.filtered) {
-		end = len(p.filtered)
-	}
+	start, end := p.window(maxVisible)
 
 	// Reserve space: "  " or "> " prefix (2), padding before state (3), state (8), risk tag (~10).
 	// The rest is available for the tool name.
@@ -221,6 +232,7 @@ func (p *ToolPicker) View(width int) string {
 		b.WriteString(FooterMeta.Render("  Pending changes not applied"))
 		b.WriteString("\n")
 	}
+	b.WriteString(p.previewView(width))
 
 	return b.String()
 }
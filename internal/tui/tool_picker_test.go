@@ -3,6 +3,8 @@ package tui
 import (
 	"strings"
 	"testing"
+
+	"github.com/batalabs/muxd/internal/daemon"
 )
 
 func testToolNames() []string {
@@ -281,3 +283,21 @@ func TestToolPicker_View(t *testing.T) {
 		}
 	})
 }
+
+func TestToolPicker_SetToolInfo(t *testing.T) {
+	p := NewToolPicker([]string{"mcp__db__query", "bash"}, nil)
+	p.SetToolInfo([]daemon.ToolInfo{
+		{Name: "mcp__db__query", Description: "Run a SQL query", Source: "mcp", Enabled: true, Profiles: []string{"safe", "coder", "research"}},
+	})
+
+	got := strings.Join(p.detail("mcp__db__query"), "\n")
+	for _, want := range []string{"Run a SQL query", "source: mcp", "profiles: safe, coder, research"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("detail missing %q:\n%s", want, got)
+		}
+	}
+	// Tools the daemon did not describe fall back to the local spec.
+	if got := strings.Join(p.detail("bash"), "\n"); !strings.Contains(got, "risk:") {
+		t.Errorf("bash detail = %q, want local risk tags", got)
+	}
+}
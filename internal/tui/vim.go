@@ -0,0 +1,555 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+// ---------------------------------------------------------------------------
+// Vim keymap
+// ---------------------------------------------------------------------------
+
+type vimMode int
+
+const (
+	vimInsert vimMode = iota
+	vimNormal
+)
+
+// vimUndoLimit caps the undo history kept for normal-mode edits.
+const vimUndoLimit = 100
+
+// vimState is the modal editing state used when input.keymap is vim. Keys
+// the layer does not handle, such as Enter, arrows and Ctrl bindings, fall
+// through to the regular input handlers in both modes.
+type vimState struct {
+	mode     vimMode
+	count    int  // count typed before a command
+	opCount  int  // count typed before the pending operator
+	op       rune // pending operator: d, c or y
+	prefix   rune // pending second key: g, r, or i/a for a text object
+	register string
+	linewise bool // register holds whole lines
+	undo     []vimSnapshot
+}
+
+type vimSnapshot struct {
+	input  string
+	cursor int
+}
+
+func (v *vimState) resetPending() {
+	v.count, v.opCount, v.op, v.prefix = 0, 0, 0, 0
+}
+
+// normalMode reports whether the vim layer is in normal mode.
+func (m Model) normalMode() bool {
+	return m.Prefs.Keymap() == config.KeymapVim && m.vim.mode == vimNormal
+}
+
+// handleVimKey applies msg to the input with vim bindings and reports
+// whether it was consumed.
+func (m *Model) handleVimKey(msg tea.KeyMsg) bool {
+	if m.Prefs.Keymap() != config.KeymapVim || m.thinking {
+		return false
+	}
+	v := &m.vim
+	if v.mode == vimInsert {
+		// Esc leaves insert mode unless it has a job to do already.
+		if msg.Type != tea.KeyEsc || m.completionOn || m.pendingAsk {
+			return false
+		}
+		v.mode = vimNormal
+		v.resetPending()
+		r := []rune(m.input)
+		if m.inputCursor > lineStart(r, m.inputCursor) {
+			m.inputCursor--
+		}
+		m.clampNormalCursor()
+		return true
+	}
+
+	switch msg.Type {
+	case tea.KeyEsc:
+		if m.completionOn || m.pendingAsk {
+			return false
+		}
+		v.resetPending()
+		return true
+	case tea.KeyEnter:
+		v.resetPending()
+		v.mode = vimInsert
+		return false
+	case tea.KeyBackspace:
+		m.vimNormalKey('h')
+		return true
+	case tea.KeySpace:
+		m.vimNormalKey('l')
+		return true
+	case tea.KeyRunes:
+		if msg.Paste {
+			return false
+		}
+		for _, k := range msg.Runes {
+			m.vimNormalKey(k)
+		}
+		return true
+	}
+	return false
+}
+
+// vimNormalKey runs one normal-mode key.
+func (m *Model) vimNormalKey(k rune) {
+	v := &m.vim
+	r := []rune(m.input)
+	pos := min(max(m.inputCursor, 0), len(r))
+
+	switch v.prefix {
+	case 'r':
+		n := max(1, v.count)
+		v.resetPending()
+		if k == '\n' || pos+n > lineEnd(r, pos) {
+			return
+		}
+		m.vimSave()
+		for i := pos; i < pos+n; i++ {
+			r[i] = k
+		}
+		m.input = string(r)
+		m.inputCursor = pos + n - 1
+		m.resetHistory()
+		return
+	case 'g':
+		v.prefix = 0
+		if k == 'g' {
+			m.vimMotionTo(0, false, true)
+		}
+		v.resetPending()
+		return
+	case 'i', 'a':
+		around := v.prefix == 'a'
+		v.prefix = 0
+		if k == 'w' || k == 'W' {
+			start, end := wordObject(r, pos, around, k == 'W')
+			m.vimOperate(v.op, start, end, false)
+		}
+		v.resetPending()
+		return
+	}
+
+	if (k >= '1' && k <= '9') || (k == '0' && v.count > 0) {
+		v.count = v.count*10 + int(k-'0')
+		return
+	}
+	n := max(1, v.count)
+	if v.op != 0 {
+		n = max(1, v.opCount) * max(1, v.count)
+	}
+
+	if v.op != 0 {
+		switch k {
+		case v.op:
+			end := pos
+			for i := 1; i < n; i++ {
+				if next := lineEnd(r, end); next < len(r) {
+					end = next + 1
+				}
+			}
+			m.vimOperate(v.op, pos, end, true)
+			v.resetPending()
+		case 'i', 'a', 'g':
+			v.prefix = k
+			v.count = 0
+			v.opCount = n
+		default:
+			// cw changes to the end of the word, like ce.
+			if v.op == 'c' && (k == 'w' || k == 'W') && pos < len(r) && !unicode.IsSpace(r[pos]) {
+				if k == 'w' {
+					k = 'e'
+				} else {
+					k = 'E'
+				}
+			}
+			if target, inclusive, linewise, ok := vimMotion(r, pos, k, n); ok {
+				m.vimMotionTo(target, inclusive, linewise)
+			}
+			v.resetPending()
+		}
+		return
+	}
+
+	switch k {
+	case 'd', 'c', 'y':
+		v.op = k
+		v.opCount = v.count
+		v.count = 0
+		return
+	case 'g', 'r':
+		v.prefix = k
+		return
+	case 'i':
+		m.vimInsertAt(pos)
+	case 'a':
+		if pos < lineEnd(r, pos) {
+			pos++
+		}
+		m.vimInsertAt(pos)
+	case 'I':
+		m.vimInsertAt(firstNonBlank(r, pos))
+	case 'A':
+		m.vimInsertAt(lineEnd(r, pos))
+	case 'o':
+		m.vimSave()
+		m.inputCursor = lineEnd(r, pos)
+		m.insertInputAtCursor("\n")
+		m.vim.mode = vimInsert
+	case 'O':
+		m.vimSave()
+		m.inputCursor = lineStart(r, pos)
+		m.insertInputAtCursor("\n")
+		m.inputCursor--
+		m.vim.mode = vimInsert
+	case 'x':
+		if pos < lineEnd(r, pos) {
+			m.vimOperate('d', pos, min(pos+n, lineEnd(r, pos)), false)
+		}
+	case 'X':
+		if pos > lineStart(r, pos) {
+			m.vimOperate('d', max(pos-n, lineStart(r, pos)), pos, false)
+		}
+	case 'D':
+		m.vimOperate('d', pos, lineEnd(r, pos), false)
+	case 'C':
+		m.vimOperate('c', pos, lineEnd(r, pos), false)
+	case 's':
+		m.vimOperate('c', pos, min(pos+n, lineEnd(r, pos)), false)
+	case 'S':
+		m.vimOperate('c', pos, pos, true)
+	case 'p', 'P':
+		m.vimPut(k == 'P', n)
+	case 'u':
+		m.vimUndo()
+	case 'j', 'k':
+		// A single-line prompt has nowhere to go, so browse history.
+		if !strings.Contains(m.input, "\n") {
+			if k == 'k' {
+				m.browseHistoryBack()
+			} else {
+				m.browseHistoryForward()
+			}
+			m.clampNormalCursor()
+			break
+		}
+		fallthrough
+	default:
+		if target, _, _, ok := vimMotion(r, pos, k, n); ok {
+			m.inputCursor = target
+			m.clampNormalCursor()
+		}
+	}
+	v.count = 0
+}
+
+// vimMotionTo applies the pending operator over the text between the cursor
+// and target, or moves the cursor there when no operator is pending.
+func (m *Model) vimMotionTo(target int, inclusive, linewise bool) {
+	if m.vim.op == 0 {
+		m.inputCursor = target
+		if linewise {
+			m.inputCursor = firstNonBlank([]rune(m.input), target)
+		}
+		m.clampNormalCursor()
+		return
+	}
+	start, end := m.inputCursor, target
+	if start > end {
+		start, end = end, start
+	}
+	if inclusive {
+		end++
+	}
+	m.vimOperate(m.vim.op, start, end, linewise)
+}
+
+// vimOperate deletes, changes or yanks the runes in [start, end). Linewise
+// ranges are widened to whole lines.
+func (m *Model) vimOperate(op rune, start, end int, linewise bool) {
+	r := []rune(m.input)
+	start = min(max(start, 0), len(r))
+	end = min(max(end, start), len(r))
+	if linewise {
+		start, end = lineStart(r, start), lineEnd(r, end)
+	}
+	m.vim.register = string(r[start:end])
+	m.vim.linewise = linewise
+	if op == 'y' {
+		m.inputCursor = start
+		m.clampNormalCursor()
+		return
+	}
+	m.vimSave()
+	if linewise && op == 'd' {
+		// Take a newline with the lines so no blank line is left behind.
+		if end < len(r) {
+			end++
+		} else if start > 0 {
+			start--
+		}
+	}
+	out := append(append([]rune{}, r[:start]...), r[end:]...)
+	m.input = string(out)
+	m.inputCursor = start
+	m.resetHistory()
+	if op == 'c' {
+		m.vim.mode = vimInsert
+		return
+	}
+	if linewise {
+		m.inputCursor = firstNonBlank(out, start)
+	}
+	m.clampNormalCursor()
+}
+
+// vimPut pastes the register n times after (p) or before (P) the cursor.
+func (m *Model) vimPut(before bool, n int) {
+	if m.vim.register == "" && !m.vim.linewise {
+		return
+	}
+	m.vimSave()
+	r := []rune(m.input)
+	pos := min(max(m.inputCursor, 0), len(r))
+	text := strings.Repeat(m.vim.register, n)
+	if m.vim.linewise {
+		text = strings.Repeat(m.vim.register+"\n", n)
+		if before {
+			m.inputCursor = lineStart(r, pos)
+			m.insertInputAtCursor(text)
+			m.inputCursor = lineStart(r, pos)
+		} else {
+			m.inputCursor = lineEnd(r, pos)
+			m.insertInputAtCursor("\n" + strings.TrimSuffix(text, "\n"))
+			m.inputCursor = lineEnd(r, pos) + 1
+		}
+		m.inputCursor = firstNonBlank([]rune(m.input), m.inputCursor)
+	} else {
+		if !before && pos < lineEnd(r, pos) {
+			pos++
+		}
+		m.inputCursor = pos
+		m.insertInputAtCursor(text)
+		m.inputCursor--
+	}
+	m.resetHistory()
+	m.clampNormalCursor()
+}
+
+// vimInsertAt enters insert mode with the cursor at pos.
+func (m *Model) vimInsertAt(pos int) {
+	m.vimSave()
+	m.inputCursor = pos
+	m.vim.mode = vimInsert
+}
+
+// vimSave records the input for u. Insert mode is saved once on entry, so a
+// whole insert is undone at once.
+func (m *Model) vimSave() {
+	m.vim.undo = append(m.vim.undo, vimSnapshot{input: m.input, cursor: m.inputCursor})
+	if len(m.vim.undo) > vimUndoLimit {
+		m.vim.undo = m.vim.undo[len(m.vim.undo)-vimUndoLimit:]
+	}
+}
+
+func (m *Model) vimUndo() {
+	if len(m.vim.undo) == 0 {
+		return
+	}
+	s := m.vim.undo[len(m.vim.undo)-1]
+	m.vim.undo = m.vim.undo[:len(m.vim.undo)-1]
+	m.input = s.input
+	m.inputCursor = s.cursor
+	m.clampNormalCursor()
+}
+
+// clampNormalCursor keeps the cursor on a character, as normal mode has no
+// position past the end of a line.
+func (m *Model) clampNormalCursor() {
+	r := []rune(m.input)
+	m.inputCursor = min(max(m.inputCursor, 0), len(r))
+	if m.vim.mode != vimNormal {
+		return
+	}
+	if end := lineEnd(r, m.inputCursor); m.inputCursor == end && end > lineStart(r, m.inputCursor) {
+		m.inputCursor--
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Motions
+// ---------------------------------------------------------------------------
+
+// vimMotion returns where motion k moves the cursor from pos, repeated n
+// times, whether an operator over it includes the target character, and
+// whether it is linewise.
+func vimMotion(r []rune, pos int, k rune, n int) (target int, inclusive, linewise, ok bool) {
+	target = pos
+	switch k {
+	case 'h':
+		return max(pos-n, lineStart(r, pos)), false, false, true
+	case 'l':
+		return min(pos+n, lineEnd(r, pos)), false, false, true
+	case '0':
+		return lineStart(r, pos), false, false, true
+	case '^':
+		return firstNonBlank(r, pos), false, false, true
+	case '$':
+		return lineEnd(r, pos), false, false, true
+	case 'w', 'W':
+		for range n {
+			target = nextWordStart(r, target, k == 'W')
+		}
+		return target, false, false, true
+	case 'b', 'B':
+		for range n {
+			target = prevWordStart(r, target, k == 'B')
+		}
+		return target, false, false, true
+	case 'e', 'E':
+		for range n {
+			target = wordEnd(r, target, k == 'E')
+		}
+		return target, true, false, true
+	case 'j', 'k':
+		col := pos - lineStart(r, pos)
+		for range n {
+			if k == 'j' {
+				if end := lineEnd(r, target); end < len(r) {
+					target = end + 1
+				}
+			} else if start := lineStart(r, target); start > 0 {
+				target = lineStart(r, start-1)
+			}
+		}
+		start := lineStart(r, target)
+		return min(start+col, lineEnd(r, target)), false, true, true
+	case 'G':
+		return lineStart(r, len(r)), false, true, true
+	}
+	return pos, false, false, false
+}
+
+func lineStart(r []rune, pos int) int {
+	for pos > 0 && r[pos-1] != '\n' {
+		pos--
+	}
+	return pos
+}
+
+func lineEnd(r []rune, pos int) int {
+	for pos < len(r) && r[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
+
+func firstNonBlank(r []rune, pos int) int {
+	i := lineStart(r, pos)
+	for i < len(r) && (r[i] == ' ' || r[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// runeClass groups runes for word motions: 0 for blanks, 1 for word
+// characters and 2 for punctuation. With big set, every non-blank is 1.
+func runeClass(c rune, big bool) int {
+	switch {
+	case unicode.IsSpace(c):
+		return 0
+	case big, c == '_', unicode.IsLetter(c), unicode.IsDigit(c):
+		return 1
+	}
+	return 2
+}
+
+func nextWordStart(r []rune, pos int, big bool) int {
+	if pos >= len(r) {
+		return len(r)
+	}
+	cls := runeClass(r[pos], big)
+	for pos < len(r) && cls != 0 && runeClass(r[pos], big) == cls {
+		pos++
+	}
+	for pos < len(r) && runeClass(r[pos], big) == 0 {
+		pos++
+	}
+	return pos
+}
+
+func prevWordStart(r []rune, pos int, big bool) int {
+	pos--
+	for pos > 0 && runeClass(r[pos], big) == 0 {
+		pos--
+	}
+	if pos <= 0 {
+		return 0
+	}
+	cls := runeClass(r[pos], big)
+	for pos > 0 && runeClass(r[pos-1], big) == cls {
+		pos--
+	}
+	return pos
+}
+
+func wordEnd(r []rune, pos int, big bool) int {
+	pos++
+	for pos < len(r) && runeClass(r[pos], big) == 0 {
+		pos++
+	}
+	if pos >= len(r) {
+		return max(len(r)-1, 0)
+	}
+	cls := runeClass(r[pos], big)
+	for pos+1 < len(r) && runeClass(r[pos+1], big) == cls {
+		pos++
+	}
+	return pos
+}
+
+// wordObject returns the range of the iw/aw (or iW/aW) text object at pos.
+// The around variant takes trailing blanks, or leading ones at line end.
+func wordObject(r []rune, pos int, around, big bool) (start, end int) {
+	if pos >= len(r) || r[pos] == '\n' {
+		return pos, pos
+	}
+	class := func(c rune) int {
+		if c == '\n' {
+			return -1
+		}
+		return runeClass(c, big)
+	}
+	cls := class(r[pos])
+	start, end = pos, pos+1
+	for start > 0 && class(r[start-1]) == cls {
+		start--
+	}
+	for end < len(r) && class(r[end]) == cls {
+		end++
+	}
+	if !around || cls == 0 {
+		return start, end
+	}
+	if end < len(r) && class(r[end]) == 0 {
+		for end < len(r) && class(r[end]) == 0 {
+			end++
+		}
+		return start, end
+	}
+	for start > 0 && class(r[start-1]) == 0 {
+		start--
+	}
+	return start, end
+}
@@ -0,0 +1,115 @@
+package tui
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/batalabs/muxd/internal/config"
+)
+
+// vimModel returns a model in vim normal mode with input and the cursor at
+// cursor.
+func vimModel(input string, cursor int) *Model {
+	m := &Model{Prefs: config.Preferences{InputKeymap: config.KeymapVim}, historyIdx: -1}
+	m.input, m.inputCursor = input, cursor
+	m.vim.mode = vimNormal
+	return m
+}
+
+func typeVim(m *Model, keys string) {
+	for _, k := range keys {
+		m.handleVimKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{k}})
+	}
+}
+
+func TestVim_normalCommands(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		cursor     int
+		keys       string
+		wantInput  string
+		wantCursor int
+		wantInsert bool
+	}{
+		{"w moves to next word", "foo bar.baz", 0, "w", "foo bar.baz", 4, false},
+		{"w stops at punctuation", "foo bar.baz", 4, "w", "foo bar.baz", 7, false},
+		{"W skips punctuation", "foo bar.baz qux", 4, "W", "foo bar.baz qux", 12, false},
+		{"b moves back", "foo bar", 5, "b", "foo bar", 4, false},
+		{"e moves to word end", "foo bar", 0, "e", "foo bar", 2, false},
+		{"$ stays on last char", "foo bar", 0, "$", "foo bar", 6, false},
+		{"count repeats motion", "a b c d", 0, "3w", "a b c d", 6, false},
+		{"x deletes char", "abc", 1, "x", "ac", 1, false},
+		{"dw deletes word", "foo bar baz", 4, "dw", "foo baz", 4, false},
+		{"d2w deletes two words", "a b c d", 0, "d2w", "c d", 0, false},
+		{"de is inclusive", "foo bar", 0, "de", " bar", 0, false},
+		{"D deletes to line end", "foo bar", 3, "D", "foo", 2, false},
+		{"dd deletes line", "one\ntwo\nthree", 5, "dd", "one\nthree", 4, false},
+		{"dd on last line", "one\ntwo", 5, "dd", "one", 0, false},
+		{"2dd deletes two lines", "one\ntwo\nthree", 0, "2dd", "three", 0, false},
+		{"ciw changes word", "foo bar baz", 5, "ciw", "foo  baz", 4, true},
+		{"diw keeps spaces", "foo bar baz", 5, "diw", "foo  baz", 4, false},
+		{"daw takes trailing space", "foo bar baz", 5, "daw", "foo baz", 4, false},
+		{"cw acts like ce", "foo bar", 0, "cw", " bar", 0, true},
+		{"cc clears line", "one\ntwo", 5, "cc", "one\n", 4, true},
+		{"r replaces char", "abc", 1, "rx", "axc", 1, false},
+		{"A appends", "abc", 0, "A", "abc", 3, true},
+		{"I inserts at first non-blank", "  abc", 4, "I", "  abc", 2, true},
+		{"o opens line below", "one\ntwo", 1, "o", "one\n\ntwo", 4, true},
+		{"O opens line above", "one\ntwo", 5, "O", "one\n\ntwo", 4, true},
+		{"j keeps column", "abc\ndef", 1, "j", "abc\ndef", 5, false},
+		{"k clamps to short line", "a\ndef", 4, "k", "a\ndef", 0, false},
+		{"gg goes to first line", "one\ntwo", 6, "gg", "one\ntwo", 0, false},
+		{"G goes to last line", "one\ntwo", 1, "G", "one\ntwo", 4, false},
+		{"yy then p puts line below", "one\ntwo", 0, "yyp", "one\none\ntwo", 4, false},
+		{"yiw then P puts before", "foo bar", 4, "yiw0P", "barfoo bar", 2, false},
+		{"u undoes the last change", "foo bar", 0, "dwu", "foo bar", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := vimModel(tt.input, tt.cursor)
+			typeVim(m, tt.keys)
+			if m.input != tt.wantInput {
+				t.Errorf("input = %q, want %q", m.input, tt.wantInput)
+			}
+			if m.inputCursor != tt.wantCursor {
+				t.Errorf("cursor = %d, want %d", m.inputCursor, tt.wantCursor)
+			}
+			if insert := m.vim.mode == vimInsert; insert != tt.wantInsert {
+				t.Errorf("insert mode = %v, want %v", insert, tt.wantInsert)
+			}
+		})
+	}
+}
+
+func TestVim_modeSwitching(t *testing.T) {
+	m := vimModel("abc", 3)
+	m.vim.mode = vimInsert
+	if m.handleVimKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}) {
+		t.Error("insert mode should leave typing to the input handlers")
+	}
+	if !m.handleVimKey(tea.KeyMsg{Type: tea.KeyEsc}) {
+		t.Fatal("Esc should enter normal mode")
+	}
+	if m.vim.mode != vimNormal || m.inputCursor != 2 {
+		t.Errorf("mode = %v cursor = %d, want normal at 2", m.vim.mode, m.inputCursor)
+	}
+	if !m.normalMode() {
+		t.Error("normalMode() = false")
+	}
+	if m.handleVimKey(tea.KeyMsg{Type: tea.KeyEnter}) {
+		t.Error("Enter should fall through to submit")
+	}
+	if m.vim.mode != vimInsert {
+		t.Error("submitting should return to insert mode")
+	}
+}
+
+func TestVim_emacsKeymapIgnored(t *testing.T) {
+	m := vimModel("abc", 0)
+	m.Prefs.InputKeymap = config.KeymapEmacs
+	if m.handleVimKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")}) {
+		t.Error("vim bindings active with the emacs keymap")
+	}
+}
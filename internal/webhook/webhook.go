@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+// Events a webhook can subscribe to.
+const (
+	EventSessionCreated = "session_created"
+	EventTurnDone       = "turn_done"
+	EventToolExecuted   = "tool_executed"
+	EventError          = "error"
+)
+
+// Events lists every event in documentation order.
+var Events = []string{EventSessionCreated, EventTurnDone, EventToolExecuted, EventError}
+
+// Headers set on every delivery.
+const (
+	HeaderEvent     = "X-Muxd-Event"
+	HeaderDelivery  = "X-Muxd-Delivery"
+	HeaderTimestamp = "X-Muxd-Timestamp"
+	HeaderSignature = "X-Muxd-Signature"
+)
+
+// ParseEvents normalizes event names. An empty list subscribes to every
+// event.
+func ParseEvents(names []string) ([]string, error) {
+	var out []string
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || slices.Contains(out, name) {
+			continue
+		}
+		if !slices.Contains(Events, name) {
+			return nil, fmt.Errorf("unknown webhook event %q (use %s)", name, strings.Join(Events, ", "))
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	SessionID string    `json:"session_id,omitempty"`
+	Data      any       `json:"data,omitempty"`
+}
+
+// Sign returns the signature header value for a body sent at timestamp
+// (Unix seconds): "sha256=" and the hex HMAC-SHA256 of "<timestamp>.<body>"
+// keyed with secret. Receivers recompute it to check that a delivery came
+// from this daemon and reject old timestamps to stop replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is valid for body and timestamp.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// ---------------------------------------------------------------------------
+// Dispatcher
+// ---------------------------------------------------------------------------
+
+// Target is a webhook endpoint.
+type Target struct {
+	ID     string
+	URL    string
+	Secret string
+}
+
+// Delivery defaults.
+const (
+	DefaultMaxAttempts = 5
+	DefaultBackoff     = time.Second
+	maxBackoff         = time.Minute
+	maxInFlight        = 8
+	requestTimeout     = 10 * time.Second
+)
+
+// Dispatcher delivers payloads in the background, retrying network
+// errors, 429s, and 5xx responses with exponential backoff. Deliveries
+// are not ordered; receivers can order by created_at and dedupe by id.
+type Dispatcher struct {
+	Client      *http.Client
+	MaxAttempts int
+	Backoff     time.Duration
+	// OnResult is called after the last attempt of each delivery with its
+	// error, or nil when it was accepted.
+	OnResult func(t Target, p Payload, attempts int, err error)
+
+	sem  chan struct{}
+	wg   sync.WaitGroup
+	ctx  context.Context
+	stop context.CancelFunc
+}
+
+// NewDispatcher returns a dispatcher with the default retry policy.
+func NewDispatcher() *Dispatcher {
+	ctx, stop := context.WithCancel(context.Background())
+	return &Dispatcher{
+		Client:      &http.Client{Timeout: requestTimeout, Transport: egress.Transport(nil)},
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		sem:         make(chan struct{}, maxInFlight),
+		ctx:         ctx,
+		stop:        stop,
+	}
+}
+
+// Send queues p for t. It returns false without sending when too many
+// deliveries are already in flight.
+func (d *Dispatcher) Send(t Target, p Payload) bool {
+	select {
+	case d.sem <- struct{}{}:
+	default:
+		return false
+	}
+	d.wg.Add(1)
+	go func() {
+		defer func() { <-d.sem; d.wg.Done() }()
+		attempts, err := d.deliver(t, p)
+		if d.OnResult != nil {
+			d.OnResult(t, p, attempts, err)
+		}
+	}()
+	return true
+}
+
+// Wait blocks until queued deliveries have finished.
+func (d *Dispatcher) Wait() { d.wg.Wait() }
+
+// Close abandons pending retries and waits for deliveries to return.
+func (d *Dispatcher) Close() {
+	d.stop()
+	d.wg.Wait()
+}
+
+func (d *Dispatcher) deliver(t Target, p Payload) (int, error) {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return 0, err
+	}
+	maxAttempts := max(d.MaxAttempts, 1)
+	wait := d.Backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(t, p, body)
+		if err == nil || !retry || attempt >= maxAttempts {
+			return attempt, err
+		}
+		select {
+		case <-d.ctx.Done():
+			return attempt, err
+		case <-time.After(wait):
+		}
+		wait = min(wait*2, maxBackoff)
+	}
+}
+
+// post makes one attempt and reports whether a failure is worth retrying.
+func (d *Dispatcher) post(t Target, p Payload, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, t.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "muxd-webhook")
+	req.Header.Set(HeaderEvent, p.Event)
+	req.Header.Set(HeaderDelivery, p.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	if t.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(t.Secret, ts, body))
+	}
+	client := d.Client
+	if client == nil {
+		client = &http.Client{Transport: egress.Transport(nil)}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		var blocked *egress.BlockedError
+		return !errors.Is(err, context.Canceled) && !errors.As(err, &blocked), err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
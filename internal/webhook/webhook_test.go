@@ -0,0 +1,188 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/batalabs/muxd/internal/egress"
+)
+
+func TestParseEvents(t *testing.T) {
+	got, err := ParseEvents([]string{" Turn_Done", "error", "turn_done", ""})
+	if err != nil || !slices.Equal(got, []string{"turn_done", "error"}) {
+		t.Errorf("ParseEvents = %v, %v", got, err)
+	}
+	if got, err := ParseEvents(nil); err != nil || got != nil {
+		t.Errorf("empty = %v, %v", got, err)
+	}
+	if _, err := ParseEvents([]string{"deploy"}); err == nil || !strings.Contains(err.Error(), "deploy") {
+		t.Errorf("unknown event error = %v", err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"turn_done"}`)
+	sig := Sign("s3cret", 1700000000, body)
+	// printf '1700000000.{"event":"turn_done"}' | openssl dgst -sha256 -hmac s3cret
+	if want := "sha256=1c86a378823e239ee0f54e238a8bac9230817885fb2769669f73f2f107236d86"; sig != want {
+		t.Fatalf("signature = %q, want %q", sig, want)
+	}
+	if !Verify("s3cret", 1700000000, body, sig) {
+		t.Error("Verify rejected a valid signature")
+	}
+	if Verify("other", 1700000000, body, sig) || Verify("s3cret", 1700000001, body, sig) || Verify("s3cret", 1700000000, []byte("{}"), sig) {
+		t.Error("Verify accepted a signature for different input")
+	}
+}
+
+func newTestDispatcher() *Dispatcher {
+	d := NewDispatcher()
+	d.Backoff = time.Millisecond
+	return d
+}
+
+func TestDispatcher_deliversSignedPayload(t *testing.T) {
+	var got Payload
+	var headers http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+	}))
+	defer srv.Close()
+
+	d := newTestDispatcher()
+	defer d.Close()
+	var attempts int
+	var result error
+	d.OnResult = func(_ Target, _ Payload, n int, err error) { attempts, result = n, err }
+
+	p := Payload{ID: "d1", Event: EventTurnDone, CreatedAt: time.Now().UTC().Truncate(time.Second), SessionID: "s1", Data: map[string]any{"stop_reason": "end_turn"}}
+	if !d.Send(Target{ID: "w1", URL: srv.URL, Secret: "s3cret"}, p) {
+		t.Fatal("Send refused the delivery")
+	}
+	d.Wait()
+
+	if result != nil || attempts != 1 {
+		t.Fatalf("result = %v after %d attempts", result, attempts)
+	}
+	if got.ID != "d1" || got.Event != EventTurnDone || got.SessionID != "s1" {
+		t.Errorf("payload = %+v", got)
+	}
+	if headers.Get(HeaderEvent) != EventTurnDone || headers.Get(HeaderDelivery) != "d1" {
+		t.Errorf("headers = %v", headers)
+	}
+	ts, _ := strconv.ParseInt(headers.Get(HeaderTimestamp), 10, 64)
+	if !Verify("s3cret", ts, body, headers.Get(HeaderSignature)) {
+		t.Errorf("signature %q does not verify", headers.Get(HeaderSignature))
+	}
+}
+
+func TestDispatcher_retries(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		case 2:
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+		}
+	}))
+	defer srv.Close()
+
+	d := newTestDispatcher()
+	defer d.Close()
+	var attempts int
+	var result error
+	d.OnResult = func(_ Target, _ Payload, n int, err error) { attempts, result = n, err }
+	d.Send(Target{URL: srv.URL}, Payload{ID: "d1", Event: EventError})
+	d.Wait()
+	if result != nil || attempts != 3 {
+		t.Errorf("result = %v after %d attempts; want success on the third", result, attempts)
+	}
+}
+
+func TestDispatcher_givesUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path == "/gone" {
+			http.Error(w, "no such hook", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	d := newTestDispatcher()
+	d.MaxAttempts = 3
+	defer d.Close()
+	var attempts int
+	var result error
+	d.OnResult = func(_ Target, _ Payload, n int, err error) { attempts, result = n, err }
+
+	d.Send(Target{URL: srv.URL}, Payload{ID: "d1"})
+	d.Wait()
+	if result == nil || !strings.Contains(result.Error(), "HTTP 500") || attempts != 3 || calls.Load() != 3 {
+		t.Errorf("5xx: result = %v after %d attempts (%d calls)", result, attempts, calls.Load())
+	}
+
+	// Other client errors are not retried.
+	calls.Store(0)
+	d.Send(Target{URL: srv.URL + "/gone"}, Payload{ID: "d2"})
+	d.Wait()
+	if result == nil || attempts != 1 || calls.Load() != 1 {
+		t.Errorf("404: result = %v after %d attempts", result, attempts)
+	}
+}
+
+func TestDispatcher_egressAllowlist(t *testing.T) {
+	egress.SetDefault(egress.NewPolicy(egress.ModeAllowlist, []string{"hooks.example.com"}))
+	defer egress.SetDefault(nil)
+
+	d := newTestDispatcher()
+	defer d.Close()
+	var attempts int
+	var result error
+	d.OnResult = func(_ Target, _ Payload, n int, err error) { attempts, result = n, err }
+
+	d.Send(Target{URL: "https://blocked.example.net/hook"}, Payload{ID: "d1"})
+	d.Wait()
+	var blocked *egress.BlockedError
+	if !errors.As(result, &blocked) || attempts != 1 {
+		t.Errorf("result = %v after %d attempts; want blocked without retries", result, attempts)
+	}
+}
+
+func TestDispatcher_closeStopsRetries(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	d := NewDispatcher()
+	d.Backoff = time.Hour
+	done := make(chan int, 1)
+	d.OnResult = func(_ Target, _ Payload, n int, _ error) { done <- n }
+	d.Send(Target{URL: srv.URL}, Payload{ID: "d1"})
+	time.Sleep(50 * time.Millisecond)
+	d.Close()
+	select {
+	case n := <-done:
+		if n != 1 {
+			t.Errorf("attempts = %d, want 1", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not stop the retry wait")
+	}
+}
@@ -10,21 +10,34 @@ import (
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/batalabs/muxd/internal/agent"
+	"github.com/batalabs/muxd/internal/backup"
 	"github.com/batalabs/muxd/internal/checkpoint"
 	"github.com/batalabs/muxd/internal/config"
 	"github.com/batalabs/muxd/internal/daemon"
+	"github.com/batalabs/muxd/internal/discord"
 	"github.com/batalabs/muxd/internal/domain"
+	"github.com/batalabs/muxd/internal/egress"
+	"github.com/batalabs/muxd/internal/export"
+	"github.com/batalabs/muxd/internal/gateway"
+	"github.com/batalabs/muxd/internal/hostinfo"
 	"github.com/batalabs/muxd/internal/hub"
 	"github.com/batalabs/muxd/internal/provider"
+	"github.com/batalabs/muxd/internal/publish"
 	"github.com/batalabs/muxd/internal/service"
+	"github.com/batalabs/muxd/internal/sink"
+	"github.com/batalabs/muxd/internal/slack"
 	"github.com/batalabs/muxd/internal/store"
 	"github.com/batalabs/muxd/internal/tools"
 	"github.com/batalabs/muxd/internal/tui"
@@ -75,17 +88,30 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	versionFlag := flag.Bool("version", false, "Print version and exit")
 	modelFlag := flag.String("model", "", "Model name or alias (e.g. claude-sonnet, openai/gpt-4o)")
 	continueFlag := flag.String("c", "", "Resume a session (latest for cwd, or pass a session ID)")
 	daemonFlag := flag.Bool("daemon", false, "Run in daemon mode (no TUI)")
-	bindFlag := flag.String("bind", "", "Network interface to bind (localhost, 0.0.0.0, or specific IP)")
+	bindFlag := flag.String("bind", "", "Network interface to bind (localhost, 0.0.0.0, :: for dual-stack, or specific IP)")
 	hubFlag := flag.Bool("hub", false, "Run as hub coordinator (no agent/session machinery)")
 	hubBindFlag := flag.String("hub-bind", "", "Hub bind address (default: localhost)")
 	hubTokenFlag := flag.String("hub-token", "", "Explicit hub auth token (overrides config and database)")
 	hubInfoFlag := flag.Bool("hub-info", false, "Print hub connection info (token, address, QR) and exit")
 	remoteFlag := flag.String("remote", "", "Connect to remote daemon or hub (host:port)")
 	tokenFlag := flag.String("token", "", "Auth token for remote connection")
+	loginFlag := flag.Bool("login", false, "Sign in to the --remote hub through its identity provider, even if a sign-in is saved")
+	remoteFallbackFlag := flag.String("remote-fallback", "", "Hub to switch to when the --remote hub stops answering (host:port; default hub.fallback_url)")
+	projectDBFlag := flag.Bool("project-db", false, "Keep sessions in .muxd/muxd.db inside the project (see daemon.project_db)")
 	serviceCmd := flag.String("service", "", "Service management: install|uninstall|status|start|stop")
 	flag.Parse()
 
@@ -166,6 +192,16 @@ func main() {
 		dc := daemon.NewDaemonClient(0)
 		dc.SetBaseURL(baseURL)
 		dc.SetAuthToken(*tokenFlag)
+		fallback := *remoteFallbackFlag
+		if fallback == "" {
+			fallback = prefs.HubFallbackURL
+		}
+		if fallback != "" {
+			if err := dc.SetFallbackBaseURL(fallback); err != nil {
+				fmt.Fprintf(os.Stderr, "%v\n", err)
+				os.Exit(1)
+			}
+		}
 
 		info, err := dc.HealthCheck()
 		if err != nil {
@@ -173,6 +209,15 @@ func main() {
 			os.Exit(1)
 		}
 
+		token := *tokenFlag
+		if info.Mode == "hub" && token == "" {
+			token = hubSignIn(baseURL, info.OIDC, *loginFlag)
+			dc.SetAuthToken(token)
+		} else if *loginFlag {
+			fmt.Fprintln(os.Stderr, "--login needs a --remote hub and no --token")
+			os.Exit(1)
+		}
+
 		modelLabel := *modelFlag
 		if modelLabel == "" {
 			modelLabel = prefs.Model
@@ -183,13 +228,14 @@ func main() {
 		}
 
 		resetTerminalForTUI()
+		tui.SetViewportMode(prefs.Viewport)
 
 		if info.Mode == "hub" {
 			// Hub mode: launch TUI with node picker, no session yet
 			fmt.Fprintf(os.Stderr, "Connected to hub on %s\n", *remoteFlag)
 			m := tui.InitialModel(dc, version, modelLabel, modelID, nil, nil, false, nil, prefs, "")
-			m.SetHubConnection(baseURL, *tokenFlag)
-			p := tea.NewProgram(m)
+			m.SetHubConnection(baseURL, fallback, token)
+			p := tea.NewProgram(m, tui.ProgramOptions()...)
 			tui.SetProgram(p)
 			if _, err := p.Run(); err != nil {
 				fmt.Fprintf(os.Stderr, "muxd failed: %v\n", err)
@@ -209,7 +255,13 @@ func main() {
 				fmt.Fprintf(os.Stderr, "error loading session: %v\n", err)
 				os.Exit(1)
 			}
-			p := tea.NewProgram(tui.InitialModel(dc, version, modelLabel, modelID, nil, session, false, nil, prefs, ""))
+			// The daemon's preferences are the ones /config shows and edits.
+			if remotePrefs, err := dc.GetConfig(); err == nil {
+				prefs = *remotePrefs
+			} else {
+				fmt.Fprintf(os.Stderr, "warning: showing local config, cannot read the daemon's: %v\n", err)
+			}
+			p := tea.NewProgram(tui.InitialModel(dc, version, modelLabel, modelID, nil, session, false, nil, prefs, ""), tui.ProgramOptions()...)
 			tui.SetProgram(p)
 			if _, err := p.Run(); err != nil {
 				fmt.Fprintf(os.Stderr, "muxd failed: %v\n", err)
@@ -221,6 +273,18 @@ func main() {
 
 	provider.SetOllamaBaseURL(prefs.OllamaURL)
 	provider.SetZAICodingPlan(prefs.ZAICodingPlan)
+	provider.SetAzureEndpoint(prefs.AzureResource())
+	provider.SetAzureADCredentials(prefs.AzureAD())
+	for name, c := range prefs.CustomProviders {
+		provider.SetCustomProvider(name, c.BaseURL, c.ModelList())
+	}
+	if proxies, err := config.ParseProviderProxies(prefs.ProxyProviders); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: proxy.providers: %v\n", err)
+	} else if err := provider.SetProxies(prefs.ProxyURL, proxies); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: proxy: %v\n", err)
+	}
+	applyEgressPolicy(prefs, logger, *daemonFlag)
+	applyComplianceMode(prefs, logger)
 
 	// Resolve provider and model (no hardcoded default -user must configure)
 	modelLabel := *modelFlag
@@ -243,7 +307,35 @@ func main() {
 
 	provider.SetPricingMap(config.LoadPricing())
 
-	st, err := store.OpenStore()
+	// Per-project scoping: each project root gets its own database, lockfile,
+	// and port so daemons for different projects can run side by side.
+	// Without it, project databases keep each project's sessions in
+	// .muxd/muxd.db inside the project, all served by the one daemon.
+	var projectRoot, projectLockPath string
+	projectDBs := !prefs.DaemonPerProject && (prefs.DaemonProjectDB || *projectDBFlag)
+	if prefs.DaemonPerProject {
+		projectRoot = mustGetwd()
+		if root, ok := checkpoint.DetectGitRepo(); ok {
+			projectRoot = root
+		}
+		lockPath, err := daemon.ProjectLockfilePath(projectRoot)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error resolving project data dir: %v\n", err)
+			os.Exit(1)
+		}
+		projectLockPath = lockPath
+	}
+
+	var st *store.Store
+	var err error
+	switch {
+	case projectLockPath != "" && *projectDBFlag:
+		st, err = store.OpenStoreForProject(projectRoot)
+	case projectLockPath != "":
+		st, err = store.OpenStoreIn(filepath.Dir(projectLockPath))
+	default:
+		st, err = store.OpenStore()
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error opening database: %v\n", err)
 		os.Exit(1)
@@ -257,6 +349,11 @@ func main() {
 			fmt.Fprintf(os.Stderr, "warning: loading custom tools: %v\n", err)
 		}
 	}
+	if cmdDir, err := gateway.PromptCommandsDir(); err == nil {
+		if err := gateway.LoadPromptCommands(cmdDir); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		}
+	}
 
 	// Agent factory for the daemon server
 	agentFactory := func(key, mID, mLabel string, s *store.Store, sess *domain.Session, p provider.Provider) *agent.Service {
@@ -279,102 +376,38 @@ func main() {
 		srv.SetAgentFactory(agentFactory)
 		srv.SetDetectGitRepo(checkpoint.DetectGitRepo)
 		srv.SetBindAddress(bindAddr)
+		applyDaemonListenPrefs(srv, prefs, projectRoot)
+		if projectLockPath != "" {
+			srv.SetLockfilePath(projectLockPath, projectRoot)
+		}
+		if projectDBs {
+			if err := srv.EnableProjectDatabases(); err != nil {
+				fmt.Fprintf(os.Stderr, "error opening project databases: %v\n", err)
+				os.Exit(1)
+			}
+		}
 		srv.SetLogger(logger)
 		srv.SetCustomToolRegistry(customToolRegistry)
+		registerAdapters(srv, prefs, projectRoot, func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		})
 
 		// Handle graceful shutdown
 		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer cancel()
 
-		// Node auto-registration with hub (if configured)
-		var hubClient *hub.NodeClient
-		var hubNodeID string
-		if prefs.HubURL != "" && prefs.HubNodeToken != "" {
-			hubClient = hub.NewNodeClient(prefs.HubURL, prefs.HubNodeToken, srv.AuthToken())
-			srv.SetPushHubMemory(hubClient.PushMemory)
-			srv.SetHubDiscovery(hubDiscoveryFunc(hubClient))
-			srv.SetHubDispatch(hubClient.Dispatch)
-			go func() {
-				port := srv.Port() // blocks until listener is bound
-				name := prefs.HubNodeName
-				if name == "" {
-					hostname, _ := os.Hostname()
-					name = hostname
-				}
-				regHost := resolveHubRegistrationHost(bindAddr, prefs.HubURL)
-				nodeID, err := hubClient.Register(name, regHost, port, version, buildNodeInfo(srv))
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "hub: registration failed: %v\n", err)
-					return
-				}
-				hubNodeID = nodeID
-				fmt.Fprintf(os.Stderr, "hub: registered as node %s\n", nodeID)
-
-				// Fetch and merge hub memory
-				if msg := mergeHubMemoryMsg(hubClient); msg != "" {
-					fmt.Fprintf(os.Stderr, "%s\n", msg)
-				}
-
-				// Start heartbeat loop with periodic memory sync
-				cwd, _ := os.Getwd()
-				mem := tools.NewProjectMemory(cwd)
-				syncCounter := 0
-				hbWindow := make([]bool, 0, heartbeatWindowSize)
-				ticker := time.NewTicker(heartbeatInterval)
-				defer ticker.Stop()
-				for {
-					select {
-					case <-ticker.C:
-						if err := hubClient.Heartbeat(nodeID, buildNodeInfo(srv)); err != nil {
-							hbWindow = appendHeartbeat(hbWindow, false)
-							failures := countHeartbeatFailures(hbWindow)
-							fmt.Fprintf(os.Stderr, "hub: heartbeat failed (%d/%d): %v\n", failures, len(hbWindow), err)
-							if hub.IsNodePurgedError(err) || shouldReRegister(hbWindow) {
-								fmt.Fprintf(os.Stderr, "hub: attempting re-registration...\n")
-								newID, regErr := hubClient.Register(name, regHost, port, version, buildNodeInfo(srv))
-								if regErr != nil {
-									fmt.Fprintf(os.Stderr, "hub: re-registration failed: %v\n", regErr)
-								} else {
-									nodeID = newID
-									hubNodeID = nodeID
-									hbWindow = hbWindow[:0]
-									fmt.Fprintf(os.Stderr, "hub: re-registered as node %s\n", nodeID)
-								}
-							}
-						} else {
-							hbWindow = appendHeartbeat(hbWindow, true)
-						}
-						syncCounter++
-						if syncCounter%2 == 0 {
-							oldFacts, _ := mem.Load()
-							hubFacts, err := hubClient.FetchMemory()
-							if err == nil && len(hubFacts) > 0 {
-								newCount := 0
-								for k, v := range hubFacts {
-									if old, ok := oldFacts[k]; !ok || old != v {
-										newCount++
-									}
-								}
-								if newCount > 0 {
-									_ = mem.MergeHub(hubFacts)
-									fmt.Fprintf(os.Stderr, "hub: synced %d memory facts\n", newCount)
-								}
-							}
-						}
-					case <-ctx.Done():
-						return
-					}
-				}
-			}()
-		}
+		// Node auto-registration with hubs (if configured)
+		stopHubs := joinHubs(srv, prefs, bindAddr, func(format string, args ...any) {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		}, func(n int) {
+			fmt.Fprintf(os.Stderr, "hub: synced %d memory facts\n", n)
+		})
 
 		go func() {
 			<-ctx.Done()
-			// Deregister from hub before shutting down
-			if hubClient != nil && hubNodeID != "" {
-				if err := hubClient.Deregister(hubNodeID); err != nil {
-					fmt.Fprintf(os.Stderr, "hub: deregister failed: %v\n", err)
-				}
+			// Deregister from hubs before shutting down
+			if stopHubs != nil {
+				stopHubs()
 			}
 			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 			defer shutdownCancel()
@@ -393,14 +426,22 @@ func main() {
 	// TUI mode: check for existing daemon
 	var dc *daemon.DaemonClient
 	var embeddedServer *daemon.Server
-	var embeddedHubClient *hub.NodeClient
-	var embeddedHubNodeID string
-	var embeddedHubDone chan struct{}
+	var stopEmbeddedHubs func()
 
-	lf, lfErr := daemon.ReadLockfile()
+	var lf *daemon.LockfileData
+	var lfErr error
+	if projectLockPath != "" {
+		lf, lfErr = daemon.ReadLockfileAt(projectLockPath)
+	} else {
+		lf, lfErr = daemon.ReadLockfile()
+	}
 	if lfErr == nil && !daemon.IsLockfileStale(lf) {
 		// Connect to existing daemon
-		dc = daemon.NewDaemonClient(lf.Port)
+		if lf.SocketPath != "" {
+			dc = daemon.NewDaemonSocketClient(lf.SocketPath)
+		} else {
+			dc = daemon.NewDaemonClient(lf.Port)
+		}
 		dc.SetAuthToken(lf.Token)
 		if info, err := dc.HealthCheck(); err != nil {
 			fmt.Fprintf(os.Stderr, "warning: daemon on port %d not responding: %v\n", lf.Port, err)
@@ -420,101 +461,39 @@ func main() {
 		embeddedServer.SetDetectGitRepo(checkpoint.DetectGitRepo)
 		embeddedServer.SetQuiet(true)
 		embeddedServer.SetBindAddress(bindAddr)
+		applyDaemonListenPrefs(embeddedServer, prefs, projectRoot)
+		if projectLockPath != "" {
+			embeddedServer.SetLockfilePath(projectLockPath, projectRoot)
+		}
+		if projectDBs {
+			if err := embeddedServer.EnableProjectDatabases(); err != nil {
+				fmt.Fprintf(os.Stderr, "error opening project databases: %v\n", err)
+				os.Exit(1)
+			}
+		}
 		embeddedServer.SetLogger(logger)
 		embeddedServer.SetCustomToolRegistry(customToolRegistry)
+		registerAdapters(embeddedServer, prefs, projectRoot, logger.Printf)
 		go func() {
 			if err := embeddedServer.Start(4096); err != nil {
 				logStderr("embedded server error: %v", err)
 			}
 		}()
 		// Port() blocks until Start() has bound the listener, so no race.
-		dc = daemon.NewDaemonClient(embeddedServer.Port())
+		port := embeddedServer.Port()
+		if sock := embeddedServer.SocketPath(); sock != "" {
+			dc = daemon.NewDaemonSocketClient(sock)
+		} else {
+			dc = daemon.NewDaemonClient(port)
+		}
 		dc.SetAuthToken(embeddedServer.AuthToken())
 
-		// Hub registration for embedded server (same as daemon mode)
-		if prefs.HubURL != "" && prefs.HubNodeToken != "" {
-			embeddedHubClient = hub.NewNodeClient(prefs.HubURL, prefs.HubNodeToken, embeddedServer.AuthToken())
-			embeddedServer.SetPushHubMemory(embeddedHubClient.PushMemory)
-			embeddedServer.SetHubDiscovery(hubDiscoveryFunc(embeddedHubClient))
-			embeddedServer.SetHubDispatch(embeddedHubClient.Dispatch)
-			embeddedHubDone = make(chan struct{})
-			go func() {
-				port := embeddedServer.Port()
-				name := prefs.HubNodeName
-				if name == "" {
-					hostname, _ := os.Hostname()
-					name = hostname
-				}
-				regHost := resolveHubRegistrationHost(bindAddr, prefs.HubURL)
-				nodeID, err := embeddedHubClient.Register(name, regHost, port, version, buildNodeInfo(embeddedServer))
-				if err != nil {
-					logStderr("hub: registration failed: %v", err)
-					return
-				}
-				embeddedHubNodeID = nodeID
-
-				// Fetch and merge hub memory (batched into one print to avoid View flicker)
-				regMsg := fmt.Sprintf("hub: registered as node %s", nodeID)
-				if mergeMsg := mergeHubMemoryMsg(embeddedHubClient); mergeMsg != "" {
-					logStderr("%s\n%s", regMsg, mergeMsg)
-				} else {
-					logStderr("%s", regMsg)
-				}
-
-				// Start heartbeat loop with periodic memory sync
-				cwd, _ := os.Getwd()
-				mem := tools.NewProjectMemory(cwd)
-				syncCounter := 0
-				hbWindow := make([]bool, 0, heartbeatWindowSize)
-				ticker := time.NewTicker(heartbeatInterval)
-				defer ticker.Stop()
-				for {
-					select {
-					case <-ticker.C:
-						if err := embeddedHubClient.Heartbeat(nodeID, buildNodeInfo(embeddedServer)); err != nil {
-							hbWindow = appendHeartbeat(hbWindow, false)
-							failures := countHeartbeatFailures(hbWindow)
-							logStderr("hub: heartbeat failed (%d/%d): %v", failures, len(hbWindow), err)
-							if hub.IsNodePurgedError(err) || shouldReRegister(hbWindow) {
-								logStderr("hub: attempting re-registration...")
-								newID, regErr := embeddedHubClient.Register(name, regHost, port, version, buildNodeInfo(embeddedServer))
-								if regErr != nil {
-									logStderr("hub: re-registration failed: %v", regErr)
-								} else {
-									nodeID = newID
-									embeddedHubNodeID = nodeID
-									hbWindow = hbWindow[:0]
-									logStderr("hub: re-registered as node %s", nodeID)
-								}
-							}
-						} else {
-							hbWindow = appendHeartbeat(hbWindow, true)
-						}
-						syncCounter++
-						if syncCounter%2 == 0 {
-							oldFacts, _ := mem.Load()
-							hubFacts, err := embeddedHubClient.FetchMemory()
-							if err == nil && len(hubFacts) > 0 {
-								newCount := 0
-								for k, v := range hubFacts {
-									if old, ok := oldFacts[k]; !ok || old != v {
-										newCount++
-									}
-								}
-								if newCount > 0 {
-									_ = mem.MergeHub(hubFacts)
-									if tui.Prog != nil {
-										tui.Prog.Send(tui.HubSyncMsg{Count: newCount})
-									}
-								}
-							}
-						}
-					case <-embeddedHubDone:
-						return
-					}
-				}
-			}()
-		}
+		// Hub registration for embedded server (same as daemon mode).
+		stopEmbeddedHubs = joinHubs(embeddedServer, prefs, bindAddr, logStderr, func(n int) {
+			if tui.Prog != nil {
+				tui.Prog.Send(tui.HubSyncMsg{Count: n})
+			}
+		})
 	}
 
 	// Create or resume session
@@ -564,29 +543,31 @@ func main() {
 
 	// Ensure the first TUI frame starts from a clean terminal state.
 	resetTerminalForTUI()
+	tui.SetViewportMode(prefs.Viewport)
 
-	p := tea.NewProgram(tui.InitialModel(dc, version, modelLabel, modelID, st, session, resuming, prov, prefs, apiKey))
+	model := tui.InitialModel(dc, version, modelLabel, modelID, st, session, resuming, prov, prefs, apiKey)
+	model.EmbeddedDaemon = embeddedServer != nil
+	p := tea.NewProgram(model, tui.ProgramOptions()...)
 	tui.SetProgram(p)
 	tools.SendConsultResponse = func(model, response string) {
 		if tui.Prog != nil {
 			tui.Prog.Send(tui.ConsultResponseMsg{Model: model, Text: response})
 		}
 	}
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "muxd failed: %v\n", err)
 		os.Exit(1)
 	}
+	if fm, ok := final.(tui.Model); ok && fm.Detached() {
+		fmt.Fprintf(os.Stderr, "Turn continues in the background. Reattach with: muxd -c %s\n", fm.Session.ID)
+	}
 
 	// Cleanup embedded server
 	if embeddedServer != nil {
-		// Deregister from hub before shutting down
-		if embeddedHubClient != nil && embeddedHubNodeID != "" {
-			if err := embeddedHubClient.Deregister(embeddedHubNodeID); err != nil {
-				fmt.Fprintf(os.Stderr, "hub: deregister failed: %v\n", err)
-			}
-		}
-		if embeddedHubDone != nil {
-			close(embeddedHubDone)
+		// Deregister from hubs before shutting down
+		if stopEmbeddedHubs != nil {
+			stopEmbeddedHubs()
 		}
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), embeddedShutdownTimeout)
 		defer shutdownCancel()
@@ -635,6 +616,154 @@ func saveAuthTokenIfNew(prefs *config.Preferences, token string) {
 	}
 }
 
+// applyDaemonListenPrefs configures the daemon's unix socket and fallback
+// port range from preferences. For per-project daemons (projectRoot set) the
+// socket path is suffixed with the project key so daemons don't collide.
+func applyDaemonListenPrefs(srv *daemon.Server, prefs config.Preferences, projectRoot string) {
+	if prefs.DaemonSocketPath != "" {
+		sock := prefs.DaemonSocketPath
+		if projectRoot != "" {
+			sock += "." + config.ProjectKey(projectRoot)
+		}
+		srv.SetSocketPath(sock)
+	}
+	if prefs.DaemonPortRange != "" {
+		lo, hi, err := config.ParsePortRange(prefs.DaemonPortRange)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring daemon.port_range: %v\n", err)
+			return
+		}
+		srv.SetPortRange(lo, hi)
+	}
+}
+
+// registerAdapters makes the chat adapters available to /slack start and
+// /discord start, and starts the ones set to autostart. Compliance mode
+// leaves them out, since they message external services. logf reports
+// adapter errors.
+func registerAdapters(srv *daemon.Server, prefs config.Preferences, projectRoot string, logf func(format string, args ...any)) {
+	if config.ComplianceEnabled(prefs) {
+		return
+	}
+	if projectRoot == "" {
+		projectRoot = mustGetwd()
+	}
+	srv.SetAdapterFactory("slack", func(c *daemon.DaemonClient, p config.Preferences) (daemon.Adapter, error) {
+		return slack.New(c, slack.Config{
+			AppToken:     p.SlackAppToken,
+			BotToken:     p.SlackBotToken,
+			AllowedUsers: p.SlackAllowedUserList(),
+			Channels:     p.SlackChannelList(),
+			ProjectPath:  projectRoot,
+			Logf:         logf,
+		})
+	})
+	srv.SetAdapterFactory("discord", func(c *daemon.DaemonClient, p config.Preferences) (daemon.Adapter, error) {
+		var filesDir string
+		if dir, err := config.ProjectDataDir(projectRoot); err == nil {
+			filesDir = filepath.Join(dir, "attachments")
+		}
+		return discord.New(c, discord.Config{
+			Token:        p.DiscordToken,
+			AllowedUsers: p.DiscordAllowedUserList(),
+			Channels:     p.DiscordChannelList(),
+			AllowedPaths: p.DiscordAllowedPathList(),
+			ProjectPath:  projectRoot,
+			FilesDir:     filesDir,
+			Logf:         logf,
+		})
+	})
+	for name, on := range map[string]bool{"slack": prefs.SlackAutostart, "discord": prefs.DiscordAutostart} {
+		if !on {
+			continue
+		}
+		go func() {
+			if _, err := srv.StartAdapter(name); err != nil {
+				logf("%s: %v", name, err)
+			}
+		}()
+	}
+}
+
+// applyComplianceMode hard-disables external messaging integrations when
+// compliance mode is on (build tag or preference) and verifies the result.
+// A failed verification is fatal: a compliance build must never start with
+// messaging reachable.
+func applyComplianceMode(prefs config.Preferences, logger *config.Logger) {
+	if !config.ComplianceEnabled(prefs) {
+		return
+	}
+	tools.SetComplianceMode(true)
+	provider.SetMessagingDisabled(true)
+	if err := tools.VerifyCompliance(); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	logger.Printf("compliance mode: messaging tools disabled (%s)", strings.Join(tools.MessagingToolNames, ", "))
+}
+
+// applyEgressPolicy installs the process-wide egress policy from preferences.
+// First contact with each host is logged; alerts also go to stderr when
+// running headless, where they won't corrupt the TUI.
+func applyEgressPolicy(prefs config.Preferences, logger *config.Logger, headless bool) {
+	mode, err := egress.ParseMode(prefs.EgressMode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: ignoring egress.mode: %v\n", err)
+		return
+	}
+	if mode == egress.ModeOff {
+		return
+	}
+	policy := egress.NewPolicy(mode, prefs.EgressAllowlistHosts())
+	policy.SetLogFunc(logger.Printf)
+	policy.SetAlertFunc(func(host, reason string) {
+		logger.Printf("egress ALERT: %s (%s)", host, reason)
+		if headless {
+			fmt.Fprintf(os.Stderr, "egress alert: %s (%s)\n", host, reason)
+		}
+	})
+	egress.SetDefault(policy)
+}
+
+// hubSignIn returns the token to use with a hub when none was given: the
+// saved sign-in, or a new device sign-in when the hub offers OIDC. It
+// returns "" when the hub does not offer sign-in and none is saved.
+func hubSignIn(baseURL string, oidc, force bool) string {
+	if !force {
+		if s := hub.SavedSignIn(baseURL); s != nil {
+			return s.Token
+		}
+	}
+	if !oidc {
+		if force {
+			fmt.Fprintln(os.Stderr, "this hub does not offer sign-in; pass its token with --token")
+			os.Exit(1)
+		}
+		return ""
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	s, err := hub.NewHubClient(baseURL, "").DeviceSignIn(ctx, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Signed in as %s (%s)\n", firstNonEmptyString(s.Email, s.Name, s.Subject), s.Role)
+	if err := hub.SaveSignIn(baseURL, s); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	return s.Token
+}
+
+func firstNonEmptyString(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // saveHubTokenIfNew persists the hub auth token to preferences.
 func saveHubTokenIfNew(prefs *config.Preferences, token string) {
 	if prefs.HubAuthToken == token {
@@ -646,10 +775,161 @@ func saveHubTokenIfNew(prefs *config.Preferences, token string) {
 	}
 }
 
-// mergeHubMemoryMsg fetches shared memory facts from the hub and merges them
+// joinHubs registers srv with every hub prefs.Hubs() lists and keeps it
+// registered, syncing shared memory, until the returned stop function
+// runs; stop deregisters it. onSync gets the number of memory facts each
+// sync changed. It returns nil when no hub is configured.
+func joinHubs(srv *daemon.Server, prefs config.Preferences, bindAddr string, logf func(format string, args ...any), onSync func(n int)) (stop func()) {
+	urls := prefs.Hubs()
+	// A unix socket daemon is not reachable from a hub, so skip it.
+	if len(urls) == 0 || prefs.HubNodeToken == "" || prefs.DaemonSocketPath != "" {
+		return nil
+	}
+	nodeID, err := hub.LocalNodeID()
+	if err != nil {
+		logf("hub: %v; each hub will assign its own node ID", err)
+	}
+	hubs := hub.NewNodeClients(urls, prefs.HubNodeToken, srv.AuthToken(), nodeID)
+	srv.SetPushHubMemory(hubs.PushMemory)
+	srv.SetHubDiscovery(hubDiscoveryFunc(hubs))
+	srv.SetHubDispatch(hubs.Dispatch)
+
+	name := prefs.HubNodeName
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	done := make(chan struct{})
+	links := make([]*hubLink, len(hubs))
+	for i, c := range hubs {
+		links[i] = &hubLink{client: c, srv: srv, name: name, logf: logf}
+		go func(l *hubLink) {
+			port := srv.Port() // blocks until listener is bound
+			l.run(resolveHubRegistrationHost(bindAddr, l.client.URL()), port, done)
+		}(links[i])
+	}
+	go syncHubMemory(hubs, logf, onSync, done)
+
+	return func() {
+		close(done)
+		for _, l := range links {
+			l.deregister()
+		}
+	}
+}
+
+// hubLink keeps a node registered with one hub.
+type hubLink struct {
+	client *hub.NodeClient
+	srv    *daemon.Server
+	name   string
+	logf   func(format string, args ...any)
+
+	mu     sync.Mutex
+	nodeID string // empty until registered
+}
+
+// run registers with the hub and sends heartbeats until done is closed. A
+// hub that is down at startup is retried every heartbeat, and the node
+// re-registers when the hub forgets it or heartbeats keep failing.
+func (l *hubLink) run(host string, port int, done <-chan struct{}) {
+	url := l.client.URL()
+	register := func(verb string) bool {
+		id, err := l.client.Register(l.name, host, port, version, buildNodeInfo(l.srv))
+		if err != nil {
+			l.logf("hub %s: %s failed: %v", url, verb, err)
+			return false
+		}
+		l.mu.Lock()
+		l.nodeID = id
+		l.mu.Unlock()
+		l.logf("hub %s: %sed as node %s", url, verb, id)
+		return true
+	}
+
+	registered := register("register")
+	hbWindow := make([]bool, 0, heartbeatWindowSize)
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if !registered {
+				registered = register("register")
+				continue
+			}
+			l.mu.Lock()
+			nodeID := l.nodeID
+			l.mu.Unlock()
+			err := l.client.Heartbeat(nodeID, buildNodeInfo(l.srv))
+			if err == nil {
+				hbWindow = appendHeartbeat(hbWindow, true)
+				continue
+			}
+			hbWindow = appendHeartbeat(hbWindow, false)
+			l.logf("hub %s: heartbeat failed (%d/%d): %v", url, countHeartbeatFailures(hbWindow), len(hbWindow), err)
+			if hub.IsNodePurgedError(err) || shouldReRegister(hbWindow) {
+				l.logf("hub %s: attempting re-registration...", url)
+				if register("re-register") {
+					hbWindow = hbWindow[:0]
+				}
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// deregister removes the node from the hub if it registered.
+func (l *hubLink) deregister() {
+	l.mu.Lock()
+	nodeID := l.nodeID
+	l.mu.Unlock()
+	if nodeID == "" {
+		return
+	}
+	if err := l.client.Deregister(nodeID); err != nil {
+		l.logf("hub %s: deregister failed: %v", l.client.URL(), err)
+	}
+}
+
+// syncHubMemory merges the hubs' shared memory into the project memory now
+// and every other heartbeat until done is closed.
+func syncHubMemory(hubs hub.NodeClients, logf func(format string, args ...any), onSync func(n int), done <-chan struct{}) {
+	if msg := mergeHubMemoryMsg(hubs); msg != "" {
+		logf("%s", msg)
+	}
+	cwd, _ := os.Getwd()
+	mem := tools.NewProjectMemory(cwd)
+	ticker := time.NewTicker(2 * heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			oldFacts, _ := mem.Load()
+			hubFacts, err := hubs.FetchMemory()
+			if err != nil || len(hubFacts) == 0 {
+				continue
+			}
+			newCount := 0
+			for k, v := range hubFacts {
+				if old, ok := oldFacts[k]; !ok || old != v {
+					newCount++
+				}
+			}
+			if newCount > 0 {
+				_ = mem.MergeHub(hubFacts)
+				onSync(newCount)
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// mergeHubMemoryMsg fetches shared memory facts from the hubs and merges them
 // into the local project memory. Returns a status message (empty if nothing to report).
-func mergeHubMemoryMsg(hubClient *hub.NodeClient) string {
-	hubFacts, err := hubClient.FetchMemory()
+func mergeHubMemoryMsg(hubs hub.NodeClients) string {
+	hubFacts, err := hubs.FetchMemory()
 	if err != nil {
 		return fmt.Sprintf("hub: fetch memory failed: %v", err)
 	}
@@ -671,7 +951,7 @@ func mergeHubMemoryMsg(hubClient *hub.NodeClient) string {
 // If bindAddr is "localhost" or "0.0.0.0" (i.e. not a specific IP), it discovers
 // the local IP that routes to the hub so the hub can proxy back to this node.
 func resolveHubRegistrationHost(bindAddr, hubURL string) string {
-	if bindAddr != "localhost" && bindAddr != "0.0.0.0" && bindAddr != "" {
+	if bindAddr != "localhost" && !daemon.IsWildcardAddr(bindAddr) {
 		// Already a specific IP -use it as-is.
 		return bindAddr
 	}
@@ -686,14 +966,14 @@ func resolveHubRegistrationHost(bindAddr, hubURL string) string {
 		return bindAddr
 	}
 	hubHost := parsed.Hostname()
-	if hubHost == "" || hubHost == "localhost" || hubHost == "127.0.0.1" {
+	if hubHost == "" || daemon.IsLoopbackHost(hubHost) {
 		// Hub is on the same machine -localhost is correct.
 		return bindAddr
 	}
 
 	// UDP dial doesn't send traffic; it just lets the OS pick the source interface
 	// that routes to the hub host.
-	conn, err := net.Dial("udp4", hubHost+":4097")
+	conn, err := net.Dial("udp", net.JoinHostPort(hubHost, "4097"))
 	if err != nil {
 		if ips := daemon.GetLocalIPs(); len(ips) > 0 {
 			return ips[0]
@@ -715,7 +995,7 @@ func printHubInfo() {
 
 	// Determine display host -use LAN IP when bound to all interfaces
 	host := lf.BindAddr
-	if host == "0.0.0.0" || host == "" || host == "localhost" {
+	if daemon.IsWildcardAddr(host) || host == "localhost" {
 		if ips := daemon.GetLocalIPs(); len(ips) > 0 {
 			host = ips[0]
 		}
@@ -728,31 +1008,32 @@ func printHubInfo() {
 		fmt.Printf("\n%s\n", ascii)
 	}
 
-	fmt.Printf("  hub:   %s:%d\n", host, lf.Port)
+	fmt.Printf("  hub:   %s\n", daemon.HostPort(host, lf.Port))
 	fmt.Printf("  token: %s\n", lf.Token)
-	fmt.Printf("\n  connect: muxd --remote %s:%d --token %s\n\n", host, lf.Port, lf.Token)
+	fmt.Printf("\n  connect: muxd --remote %s --token %s\n\n", daemon.HostPort(host, lf.Port), lf.Token)
 }
 
-// hubDiscoveryFunc returns a closure that queries the hub for connected nodes.
-func hubDiscoveryFunc(hc *hub.NodeClient) func() ([]tools.HubNodeInfo, error) {
+// hubDiscoveryFunc returns a closure that queries the hubs for connected nodes.
+func hubDiscoveryFunc(hubs hub.NodeClients) func() ([]tools.HubNodeInfo, error) {
 	return func() ([]tools.HubNodeInfo, error) {
-		entries, err := hc.ListNodes()
+		entries, err := hubs.ListNodes()
 		if err != nil {
 			return nil, err
 		}
 		nodes := make([]tools.HubNodeInfo, len(entries))
 		for i, e := range entries {
 			nodes[i] = tools.HubNodeInfo{
-				ID:       e.ID,
-				Name:     e.Name,
-				Status:   e.Status,
-				Version:  e.Version,
-				Platform: e.Platform,
-				Arch:     e.Arch,
-				Provider: e.Provider,
-				Model:    e.Model,
-				Tools:    e.Tools,
-				MCPTools: e.MCPTools,
+				ID:        e.ID,
+				Name:      e.Name,
+				Status:    e.Status,
+				Version:   e.Version,
+				Platform:  e.Platform,
+				Arch:      e.Arch,
+				Provider:  e.Provider,
+				Model:     e.Model,
+				Tools:     e.Tools,
+				MCPTools:  e.MCPTools,
+				Resources: e.Resources,
 			}
 		}
 		return nodes, nil
@@ -778,5 +1059,563 @@ func buildNodeInfo(srv *daemon.Server) hub.NodeInfo {
 	if v, ok := info["mcp_tools"].([]string); ok {
 		ni.MCPTools = v
 	}
+	load := &hub.NodeLoad{}
+	load.ActiveSessions, _ = info["active_sessions"].(int)
+	load.LoadedSessions, _ = info["loaded_sessions"].(int)
+	load.TokensUsed, _ = info["tokens_used"].(int64)
+	load.StartedAt, _ = info["started_at"].(time.Time)
+	ni.Load = load
+	if res, ok := info["resources"].(hostinfo.Resources); ok && res.Known() {
+		ni.Resources = &res
+	}
 	return ni
 }
+
+// subcommands are offline commands dispatched before flag parsing
+// (e.g. "muxd publish -tag onboarding").
+var subcommands = map[string]func(args []string) error{
+	"publish": runPublish,
+	"export":  runExport,
+	"import":  runImport,
+	"audit":   runAudit,
+	"backup":  runBackup,
+	"restore": runRestore,
+	"gc":      runGC,
+}
+
+// sessionSelection holds the session filter flags shared by subcommands.
+type sessionSelection struct {
+	ids     *string
+	project *string
+	tag     *string
+	since   *string
+	until   *string
+	rating  *string
+	limit   *int
+}
+
+func addSessionSelectionFlags(fs *flag.FlagSet) sessionSelection {
+	return sessionSelection{
+		ids:     fs.String("sessions", "", "Comma-separated session IDs or prefixes (default: recent sessions)"),
+		project: fs.String("project", "", "Only sessions for this project path (\".\" for the current directory)"),
+		tag:     fs.String("tag", "", "Only sessions with this tag"),
+		since:   fs.String("since", "", "Only sessions updated on or after this date (YYYY-MM-DD)"),
+		until:   fs.String("until", "", "Only sessions updated on or before this date (YYYY-MM-DD)"),
+		rating:  fs.String("rating", "", "Only sessions with at least one reply rated good or bad"),
+		limit:   fs.Int("limit", 50, "Maximum number of sessions when -sessions is not set"),
+	}
+}
+
+// load resolves the selected sessions and their transcripts.
+func (sel sessionSelection) load(st *store.Store) ([]domain.Session, [][]domain.TranscriptMessage, error) {
+	var since, until time.Time
+	var err error
+	if *sel.since != "" {
+		if since, err = time.ParseInLocation("2006-01-02", *sel.since, time.Local); err != nil {
+			return nil, nil, fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	if *sel.until != "" {
+		if until, err = time.ParseInLocation("2006-01-02", *sel.until, time.Local); err != nil {
+			return nil, nil, fmt.Errorf("invalid -until: %w", err)
+		}
+		until = until.AddDate(0, 0, 1)
+	}
+	rating := store.RatingNone
+	if *sel.rating != "" {
+		if rating, err = store.ParseRating(*sel.rating); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var candidates []domain.Session
+	if *sel.ids != "" {
+		for _, id := range strings.Split(*sel.ids, ",") {
+			id = strings.TrimSpace(id)
+			if id == "" {
+				continue
+			}
+			sess, err := st.FindSessionByPrefix(id)
+			if err != nil {
+				return nil, nil, fmt.Errorf("session %s: %w", id, err)
+			}
+			candidates = append(candidates, *sess)
+		}
+	} else {
+		project := *sel.project
+		if project == "." {
+			project = mustGetwd()
+		}
+		candidates, err = st.ListSessions(project, *sel.limit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing sessions: %w", err)
+		}
+	}
+
+	var sessions []domain.Session
+	var transcripts [][]domain.TranscriptMessage
+	for _, sess := range candidates {
+		if *sel.tag != "" && !slices.Contains(sess.TagList(), *sel.tag) {
+			continue
+		}
+		if (!since.IsZero() && sess.UpdatedAt.Before(since)) || (!until.IsZero() && !sess.UpdatedAt.Before(until)) {
+			continue
+		}
+		if rating != store.RatingNone {
+			good, bad, err := st.SessionFeedback(sess.ID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("loading feedback for %s: %w", sess.ID, err)
+			}
+			if (rating == store.RatingGood && good == 0) || (rating == store.RatingBad && bad == 0) {
+				continue
+			}
+		}
+		msgs, err := st.GetMessages(sess.ID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading session %s: %w", sess.ID, err)
+		}
+		sessions = append(sessions, sess)
+		transcripts = append(transcripts, msgs)
+	}
+	if len(sessions) == 0 {
+		return nil, nil, fmt.Errorf("no sessions matched")
+	}
+	return sessions, transcripts, nil
+}
+
+// runPublish implements "muxd publish": render selected sessions as a static
+// HTML site and optionally push it to a git branch.
+func runPublish(args []string) error {
+	fs := flag.NewFlagSet("publish", flag.ContinueOnError)
+	sel := addSessionSelectionFlags(fs)
+	outFlag := fs.String("out", "muxd-site", "Output directory")
+	titleFlag := fs.String("title", "", "Site title")
+	branchFlag := fs.String("branch", "", "Commit the site to this branch and push it (e.g. gh-pages)")
+	remoteFlag := fs.String("remote", "origin", "Git remote name or URL used with -branch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st, err := store.OpenStore()
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = st.Close() }()
+
+	sessions, transcripts, err := sel.load(st)
+	if err != nil {
+		return err
+	}
+	entries := make([]publish.Entry, len(sessions))
+	for i := range sessions {
+		entries[i] = publish.Entry{Session: sessions[i], Messages: transcripts[i]}
+	}
+
+	if err := publish.Build(*outFlag, entries, publish.Options{Title: *titleFlag}); err != nil {
+		return err
+	}
+	fmt.Printf("Published %d session(s) to %s\n", len(entries), *outFlag)
+
+	if storage, err := sink.ExportSink(config.LoadPreferences()); err != nil {
+		return err
+	} else if storage != nil {
+		prefix := sink.ExportsDir + "sites/" + filepath.Base(filepath.Clean(*outFlag)) + "/"
+		n, err := sink.PutDir(context.Background(), storage, prefix, *outFlag)
+		if err != nil {
+			return fmt.Errorf("uploading site: %w", err)
+		}
+		fmt.Printf("Uploaded %d file(s) to %s/%s\n", n, strings.TrimSuffix(storage.String(), "/"), prefix)
+	}
+
+	if *branchFlag != "" {
+		if err := publish.Push(*outFlag, *remoteFlag, *branchFlag); err != nil {
+			return err
+		}
+		fmt.Printf("Pushed to %s %s\n", *remoteFlag, *branchFlag)
+	}
+	return nil
+}
+
+// runExport implements "muxd export": write selected sessions as JSONL
+// fine-tuning records.
+func runExport(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	sel := addSessionSelectionFlags(fs)
+	formatFlag := fs.String("format", "openai", "Record schema: openai or anthropic")
+	outFlag := fs.String("out", "", "Output file (default: stdout)")
+	systemFlag := fs.String("system", "", "System prompt to include in every record")
+	toolsFlag := fs.Bool("tools", true, "Include tool calls and results")
+	noRedactFlag := fs.Bool("no-redact", false, "Keep credentials instead of masking them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := export.ParseFormat(*formatFlag)
+	if err != nil {
+		return err
+	}
+
+	st, err := store.OpenStore()
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = st.Close() }()
+
+	_, transcripts, err := sel.load(st)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *outFlag != "" {
+		f, err := os.Create(*outFlag)
+		if err != nil {
+			return fmt.Errorf("creating output: %w", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	n, err := export.WriteFineTune(w, transcripts, export.FineTuneOptions{
+		Format: format,
+		System: *systemFlag,
+		Tools:  *toolsFlag,
+		Redact: !*noRedactFlag,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Exported %d record(s) in %s format\n", n, format)
+
+	if *outFlag == "" {
+		return nil
+	}
+	if storage, err := sink.ExportSink(config.LoadPreferences()); err != nil {
+		return err
+	} else if storage != nil {
+		if err := w.Sync(); err != nil {
+			return err
+		}
+		key := sink.ExportsDir + filepath.Base(*outFlag)
+		if err := sink.PutFile(context.Background(), storage, key, *outFlag); err != nil {
+			return fmt.Errorf("uploading export: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Uploaded to %s/%s\n", strings.TrimSuffix(storage.String(), "/"), key)
+	}
+	return nil
+}
+
+// runImport implements "muxd import": copy sessions from another agent
+// CLI's files into the store, so they can be resumed with "muxd -c".
+// Without file arguments it imports every session the CLI has for the
+// project.
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fromFlag := fs.String("from", "", "Session format: claude (Claude Code) or codex (Codex CLI)")
+	projectFlag := fs.String("project", "", "Project directory (default: current directory)")
+	memoryFlag := fs.Bool("memory", true, "Also copy the CLI's instructions file (CLAUDE.md, AGENTS.md) into project memory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *fromFlag == "" {
+		return fmt.Errorf("-from is required (claude or codex)")
+	}
+	format, err := export.LookupSessionFormat(*fromFlag)
+	if err != nil {
+		return err
+	}
+	project := *projectFlag
+	if project == "" {
+		project, _ = os.Getwd()
+	}
+	if project, err = filepath.Abs(project); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		if files, err = format.Discover(project); err != nil {
+			return fmt.Errorf("finding %s sessions: %w", format.Name, err)
+		}
+		if len(files) == 0 {
+			fmt.Fprintf(os.Stderr, "No %s sessions found for %s\n", format.Name, project)
+		}
+	}
+
+	st, err := store.OpenStore()
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = st.Close() }()
+
+	imported := 0
+	for _, path := range files {
+		id, n, err := importSession(st, format, path, project)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			continue
+		}
+		if id == "" {
+			continue
+		}
+		fmt.Printf("%s  %s (%d messages)\n", id[:8], path, n)
+		imported++
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d session(s) from %s\n", imported, format.Name)
+
+	if !*memoryFlag {
+		return nil
+	}
+	if ok, err := importMemoryFile(project, format.MemoryFile); err != nil {
+		return err
+	} else if ok {
+		fmt.Fprintf(os.Stderr, "Copied %s into project memory\n", format.MemoryFile)
+	}
+	return nil
+}
+
+// importSession reads one session file and stores it, returning the new
+// session's ID and message count. Sessions with no messages are skipped
+// (empty ID). The history is repaired the way a resumed session's is.
+func importSession(st *store.Store, format export.SessionFormat, path, project string) (string, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+	imp, err := format.Read(f)
+	if err != nil {
+		return "", 0, err
+	}
+	msgs, _ := agent.ValidateTranscript(imp.Messages)
+	if len(msgs) == 0 {
+		return "", 0, nil
+	}
+
+	projectPath := imp.Cwd
+	if projectPath == "" {
+		projectPath = project
+	}
+	sess, err := st.CreateSession(projectPath, imp.Model)
+	if err != nil {
+		return "", 0, err
+	}
+	if imp.Title != "" {
+		if err := st.UpdateSessionTitle(sess.ID, imp.Title); err != nil {
+			return "", 0, err
+		}
+	}
+	if err := st.UpdateSessionTags(sess.ID, "imported,"+format.Name); err != nil {
+		return "", 0, err
+	}
+	turn := make([]store.TurnMessage, len(msgs))
+	for i, m := range msgs {
+		turn[i] = store.TurnMessage{Role: m.Role, Content: m.Content, Blocks: m.Blocks}
+	}
+	if err := st.AppendTurnMessages(sess.ID, "", turn); err != nil {
+		return "", 0, err
+	}
+	return sess.ID, len(msgs), nil
+}
+
+// importMemoryFile copies a project's instructions file for another CLI
+// into project memory under "imported_<name>", reporting whether there was
+// one to copy.
+func importMemoryFile(project, name string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(project, name))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	mem := tools.NewProjectMemory(project)
+	facts, err := mem.Load()
+	if err != nil {
+		return false, err
+	}
+	key := "imported_" + strings.ToLower(strings.ReplaceAll(name, ".", "_"))
+	facts[key] = strings.TrimSpace(string(data))
+	return true, mem.Save(facts)
+}
+
+// runAudit implements "muxd audit": print recent outbound content decisions.
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	sessionFlag := fs.String("session", "", "Only show entries for this session ID")
+	limitFlag := fs.Int("limit", 50, "Maximum number of entries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st, err := store.OpenStore()
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = st.Close() }()
+
+	entries, err := st.ListAuditEntries(*sessionFlag, *limitFlag)
+	if err != nil {
+		return fmt.Errorf("reading audit log: %w", err)
+	}
+	if len(entries) == 0 {
+		fmt.Println("No audit entries.")
+		return nil
+	}
+	for _, e := range entries {
+		sess := e.SessionID
+		if len(sess) > 8 {
+			sess = sess[:8]
+		}
+		fmt.Printf("%s  %-8s  %-12s  %-18s  %s\n", e.CreatedAt.Format("2006-01-02 15:04:05"), sess, e.Tool, e.Decision, e.Detail)
+	}
+	return nil
+}
+
+// runBackup implements "muxd backup": write the database, config directory,
+// and project memory files to a single archive.
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	outFlag := fs.String("out", "", "Archive path (default: muxd-backup-<time>.tar.gz in the current directory)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	out := *outFlag
+	if out == "" {
+		out = backup.ArchiveName(time.Now())
+	}
+
+	st, err := store.OpenStore()
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = st.Close() }()
+
+	src, err := backup.SourcesFor(st, config.ConfigDir())
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(out, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating archive: %w", err)
+	}
+	m, err := backup.Create(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(out)
+		return err
+	}
+	fmt.Printf("Backed up %d file(s), including memory for %d project(s), to %s\n", m.Files, len(m.Memory), out)
+	return nil
+}
+
+// runGC implements "muxd gc": remove git checkpoint refs left behind by
+// deleted sessions or older than checkpoint.retention_days, and file
+// snapshots unused for that long.
+func runGC(args []string) error {
+	fs := flag.NewFlagSet("gc", flag.ContinueOnError)
+	dryRunFlag := fs.Bool("dry-run", false, "List stale refs without deleting them")
+	daysFlag := fs.Int("days", 0, "Remove refs older than this many days (default: checkpoint.retention_days)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	maxAge := config.LoadPreferences().CheckpointRetention()
+	if *daysFlag > 0 {
+		maxAge = time.Duration(*daysFlag) * 24 * time.Hour
+	}
+
+	st, err := store.OpenStore()
+	if err != nil {
+		return fmt.Errorf("opening database: %w", err)
+	}
+	defer func() { _ = st.Close() }()
+
+	results, err := checkpoint.GC(st, checkpoint.GCOptions{MaxAge: maxAge, DryRun: *dryRunFlag})
+	if err != nil {
+		return err
+	}
+	verb := "Removed"
+	if *dryRunFlag {
+		verb = "Would remove"
+	}
+	removed := 0
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", r.Repo, r.Err)
+		}
+		for _, ref := range r.Removed {
+			fmt.Printf("%s  %s\n", r.Repo, ref)
+		}
+		removed += len(r.Removed)
+	}
+	fmt.Printf("%s %d checkpoint ref(s) and snapshot object(s) across %d location(s)\n", verb, removed, len(results))
+	return nil
+}
+
+// runRestore implements "muxd restore <archive>": replace the database and
+// config with the contents of a backup.
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	noMemoryFlag := fs.Bool("no-memory", false, "Do not restore project memory files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: muxd restore [-no-memory] <archive>")
+	}
+	if lf, err := daemon.ReadLockfile(); err == nil && !daemon.IsLockfileStale(lf) {
+		return fmt.Errorf("a muxd daemon is running (pid %d); stop it before restoring", lf.PID)
+	}
+
+	dataDir, err := config.DataDir()
+	if err != nil {
+		return fmt.Errorf("data dir: %w", err)
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	targets := backup.Targets{
+		DataDir:   dataDir,
+		ConfigDir: config.ConfigDir(),
+		Memory:    !*noMemoryFlag,
+	}
+	if targets.Memory {
+		if targets.Projects, err = restoreProjects(dataDir); err != nil {
+			return err
+		}
+	}
+	res, err := backup.Restore(f, targets)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored backup from %s: database, %d config file(s), memory for %d project(s)\n",
+		res.Manifest.CreatedAt.Local().Format("2006-01-02 15:04"), res.Config, len(res.Memory))
+	if res.Previous != "" {
+		fmt.Printf("Previous database kept at %s\n", res.Previous)
+	}
+	return nil
+}
+
+// restoreProjects returns the project roots a restore may write memory
+// files to: the projects in the current database and the working directory.
+func restoreProjects(dataDir string) ([]string, error) {
+	var projects []string
+	if cwd, err := os.Getwd(); err == nil {
+		projects = append(projects, cwd)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "muxd.db")); err != nil {
+		return projects, nil
+	}
+	st, err := store.OpenStoreIn(dataDir)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer st.Close()
+	paths, err := st.ProjectPaths()
+	if err != nil {
+		return nil, fmt.Errorf("listing projects: %w", err)
+	}
+	return append(projects, paths...), nil
+}